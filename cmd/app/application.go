@@ -3,13 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sort"
 	"stackyrd/config"
 	"stackyrd/internal/server"
+	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/topology"
 	"stackyrd/pkg/tui"
 	"stackyrd/pkg/utils"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -19,6 +26,8 @@ type Application struct {
 	configManager *ConfigManager
 	config        *config.Config
 	logger        *logger.Logger
+	broadcaster   *logger.LogBroadcaster
+	sinks         *logger.SinkSet
 	bannerText    string
 }
 
@@ -104,13 +113,37 @@ func (app *Application) checkPortStep(ctx *AppContext) error {
 
 // initLoggerStep initializes the logger
 func (app *Application) initLoggerStep(ctx *AppContext) error {
+	// Every log line is also kept for the monitoring API and alerting
+	app.broadcaster = logger.NewLogBroadcaster(app.config.Monitoring.LogBufferSize)
+	ctx.Broadcaster = app.broadcaster
+	subs := app.config.Monitoring.LogSubscribers
+	policy, err := logger.ParseDropPolicy(subs.DropPolicy)
+	if err != nil {
+		return fmt.Errorf("monitoring.log_subscribers: %w", err)
+	}
+	app.broadcaster.SetSubscriberDefaults(subs.Buffer, policy)
+
+	if history := app.config.Monitoring.LogHistory; history.Enabled {
+		if err := app.broadcaster.EnablePersistence(history.Path, int64(history.MaxSizeMB)*1024*1024); err != nil {
+			return err
+		}
+	}
+
+	if configs := app.sinkConfigs(); len(configs) > 0 {
+		sinks, err := logger.NewSinkSet(configs)
+		if err != nil {
+			return err
+		}
+		app.sinks = sinks
+	}
+
 	if app.config.App.EnableTUI {
 		// For TUI mode, logger will be initialized later when we have the broadcaster
 		return nil
 	}
 
 	// For console mode, create a regular logger
-	app.logger = logger.New(app.config.App.Debug, nil)
+	app.logger = app.withSinks(logger.New(app.config.App.Debug, app.broadcaster))
 	app.logger.Info("Starting Application", "name", app.config.App.Name, "env", app.config.App.Env)
 	app.logger.Info("TUI mode disabled, using traditional console logging")
 	app.logger.Info("Initializing services...")
@@ -118,6 +151,58 @@ func (app *Application) initLoggerStep(ctx *AppContext) error {
 	return nil
 }
 
+// sinkConfigs converts the enabled logging.sinks entries for the logger.
+func (app *Application) sinkConfigs() []logger.SinkConfig {
+	var configs []logger.SinkConfig
+	for _, sc := range app.config.Logging.Sinks {
+		if !sc.Enabled {
+			continue
+		}
+		labels := sc.Labels
+		if sc.Type == logger.SinkLoki && len(labels) == 0 {
+			labels = map[string]string{"app": app.config.App.Name, "env": app.config.App.Env}
+		}
+		configs = append(configs, logger.SinkConfig{
+			Name:          sc.Name,
+			Type:          sc.Type,
+			Level:         sc.Level,
+			BufferSize:    sc.BufferSize,
+			BatchSize:     sc.BatchSize,
+			FlushInterval: time.Duration(sc.FlushInterval) * time.Millisecond,
+			Output:        sc.Output,
+			Path:          sc.Path,
+			MaxSizeMB:     sc.MaxSizeMB,
+			URL:           sc.URL,
+			Labels:        labels,
+			TenantID:      sc.TenantID,
+			Username:      sc.Username,
+			Password:      sc.Password,
+			Network:       sc.Network,
+			Address:       sc.Address,
+			Tag:           sc.Tag,
+			Facility:      sc.Facility,
+			Brokers:       sc.Brokers,
+			Topic:         sc.Topic,
+		})
+	}
+	return configs
+}
+
+// withSinks attaches the configured log sinks, if any, to l.
+func (app *Application) withSinks(l *logger.Logger) *logger.Logger {
+	if app.sinks == nil {
+		return l
+	}
+	return l.WithSinks(app.sinks)
+}
+
+// closeSinks flushes queued log lines before the process exits.
+func (app *Application) closeSinks() {
+	if app.sinks != nil {
+		app.sinks.Close()
+	}
+}
+
 // startAppStep starts the application based on TUI mode
 func (app *Application) startAppStep(ctx *AppContext) error {
 	if app.config.App.EnableTUI {
@@ -148,41 +233,165 @@ func (app *Application) runWithTUI() {
 	tuiInitQueue := make([]tui.ServiceInit, len(initQueue))
 	for i, svc := range initQueue {
 		tuiInitQueue[i] = tui.ServiceInit{
-			Name:     svc.Name,
-			Enabled:  svc.Enabled,
-			InitFunc: svc.InitFunc,
+			Name:       svc.Name,
+			Enabled:    svc.Enabled,
+			InitFunc:   svc.InitFunc,
+			DetailFunc: svc.DetailFunc,
+			Component:  svc.Component,
 		}
 	}
+	tuiConfig.ConnectTimeout = BootConnectTimeout
 
-	// Run the boot sequence TUI
-	_, _ = tui.RunBootSequence(tuiConfig, tuiInitQueue)
-
-	// Create and start Live TUI
-	liveTUI := app.createLiveTUI()
-	liveTUI.Start()
-
-	// Initialize logger with TUI output
-	app.logger = logger.NewQuiet(app.config.App.Debug, liveTUI)
-
-	// Add initial logs
-	liveTUI.AddLog(LogLevelInfo, "Server starting on port "+app.config.Server.Port)
-	liveTUI.AddLog(LogLevelInfo, "Environment: "+app.config.App.Env)
+	// Start the server behind the boot screen so it shows the real
+	// connection attempts; log lines are held until the live TUI is up
+	events, _ := infrastructure.SubscribeConnectionEvents(64)
+	bootEvents := make(chan tui.BootEvent, 64)
+	var liveTUI atomic.Pointer[tui.LiveTUI]
+	go func() {
+		for e := range events {
+			if l := liveTUI.Load(); l != nil {
+				l.AddLog(connectionLogLevel(e.Type), e.Component+": "+e.Message())
+				continue
+			}
+			select {
+			case bootEvents <- bootEvent(e):
+			default:
+			}
+		}
+	}()
 
-	// Start server
+	logs := newDeferredWriter()
+	app.logger = app.withSinks(logger.NewQuiet(app.config.App.Debug, io.MultiWriter(logs, app.broadcaster)))
 	srv := server.New(app.config, app.logger)
+	srv.SetLogBroadcaster(app.broadcaster)
+	srv.SetLogSinks(app.sinks)
+	srv.OnMigrations(func(results []server.MigrationResult) {
+		e := migrationBootEvent(results)
+		if l := liveTUI.Load(); l != nil {
+			level := LogLevelInfo
+			if e.Status == "error" {
+				level = LogLevelError
+			}
+			l.AddLog(level, "migrations: "+e.Message)
+			return
+		}
+		select {
+		case bootEvents <- e:
+		default:
+		}
+	})
 	go func() {
-		liveTUI.AddLog(LogLevelInfo, "HTTP server listening...")
 		if err := srv.Start(); err != nil {
-			liveTUI.AddLog(LogLevelFatal, "Server error: "+err.Error())
+			app.logger.Error("Server error", err)
 		}
 	}()
 
+	// Run the boot sequence TUI
+	_, _ = tui.RunBootSequenceWithEvents(tuiConfig, tuiInitQueue, bootEvents)
+
+	// Create and start Live TUI
+	live := app.createLiveTUI(srv)
+	live.Start()
+	liveTUI.Store(live)
+
+	// Show what the previous run logged before it stopped
+	if replayed := app.broadcaster.Replayed(); len(replayed) > 0 {
+		live.AddLog(LogLevelWarn, fmt.Sprintf("Replaying %d log lines from the previous run", len(replayed)))
+		for _, e := range replayed {
+			live.AddLog(e.Level, "[previous run "+e.Time.Format(time.TimeOnly)+"] "+e.Message)
+		}
+	}
+
+	// Add initial logs, then the ones written during boot
+	live.AddLog(LogLevelInfo, "Server starting on port "+app.config.Server.Port)
+	live.AddLog(LogLevelInfo, "Environment: "+app.config.App.Env)
+	logs.Attach(live)
+
 	// Wait for server to start
 	time.Sleep(StartupDelay)
-	liveTUI.AddLog(LogLevelInfo, "Server ready at http://localhost:"+app.config.Server.Port)
+	live.AddLog(LogLevelInfo, "Server ready at "+app.config.Server.BaseURL())
 
 	// Handle shutdown
-	app.handleShutdown(liveTUI, srv)
+	app.handleShutdown(live, srv)
+}
+
+// bootEvent converts a connection event for the boot screen.
+func bootEvent(e infrastructure.ConnectionEvent) tui.BootEvent {
+	status := "loading"
+	switch e.Type {
+	case infrastructure.ConnectionConnected, infrastructure.ConnectionReestablished:
+		status = "success"
+	case infrastructure.ConnectionFailed, infrastructure.ConnectionLost:
+		status = "error"
+	}
+	return tui.BootEvent{Component: e.Component, Status: status, Message: e.Message()}
+}
+
+// migrationBootEvent summarizes migration results for the boot screen.
+func migrationBootEvent(results []server.MigrationResult) tui.BootEvent {
+	e := tui.BootEvent{Component: MigrationsComponent, Status: "success"}
+	var failed []server.MigrationResult
+	var elapsed time.Duration
+	for _, result := range results {
+		elapsed += result.Duration
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	switch {
+	case len(results) == 0:
+		e.Message = "Nothing to migrate"
+	case len(failed) > 0:
+		e.Status = "error"
+		e.Message = fmt.Sprintf("%d of %d failed, %s: %v", len(failed), len(results), failed[0].Service, failed[0].Err)
+	default:
+		e.Message = fmt.Sprintf("%d applied in %s", len(results), elapsed.Round(time.Millisecond))
+	}
+	return e
+}
+
+// connectionLogLevel is the live TUI log level of a connection event.
+func connectionLogLevel(t infrastructure.ConnectionEventType) string {
+	switch t {
+	case infrastructure.ConnectionConnected, infrastructure.ConnectionReestablished:
+		return LogLevelInfo
+	case infrastructure.ConnectionFailed:
+		return LogLevelError
+	default:
+		return LogLevelWarn
+	}
+}
+
+// deferredWriter holds writes until Attach, then forwards them.
+type deferredWriter struct {
+	mu      sync.Mutex
+	pending [][]byte
+	w       io.Writer
+}
+
+func newDeferredWriter() *deferredWriter {
+	return &deferredWriter{}
+}
+
+func (d *deferredWriter) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.w != nil {
+		return d.w.Write(p)
+	}
+	d.pending = append(d.pending, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// Attach writes the held lines to w and forwards later writes to it.
+func (d *deferredWriter) Attach(w io.Writer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, p := range d.pending {
+		w.Write(p)
+	}
+	d.pending = nil
+	d.w = w
 }
 
 // runWithConsole runs the application with traditional console logging
@@ -195,7 +404,7 @@ func (app *Application) runWithConsole() {
 	}
 
 	// Initialize logger
-	app.logger = logger.New(app.config.App.Debug, nil)
+	app.logger = app.withSinks(logger.New(app.config.App.Debug, app.broadcaster))
 
 	// Log startup information
 	app.logger.Info("Starting Application", "name", app.config.App.Name, "env", app.config.App.Env)
@@ -207,6 +416,8 @@ func (app *Application) runWithConsole() {
 
 	// Start server
 	srv := server.New(app.config, app.logger)
+	srv.SetLogBroadcaster(app.broadcaster)
+	srv.SetLogSinks(app.sinks)
 	go func() {
 		app.logger.Info("HTTP server listening", "port", app.config.Server.Port)
 		if err := srv.Start(); err != nil {
@@ -216,14 +427,14 @@ func (app *Application) runWithConsole() {
 
 	// Wait for server to start
 	time.Sleep(StartupDelay)
-	app.logger.Info("Server ready", "url", "http://localhost:"+app.config.Server.Port)
+	app.logger.Info("Server ready", "url", app.config.Server.BaseURL())
 
 	// Handle shutdown
 	app.handleConsoleShutdown(srv)
 }
 
 // createLiveTUI creates and configures the Live TUI
-func (app *Application) createLiveTUI() *tui.LiveTUI {
+func (app *Application) createLiveTUI(srv *server.Server) *tui.LiveTUI {
 	return tui.NewLiveTUI(tui.LiveConfig{
 		AppName:    app.config.App.Name,
 		AppVersion: app.config.App.Version,
@@ -231,24 +442,118 @@ func (app *Application) createLiveTUI() *tui.LiveTUI {
 		Port:       app.config.Server.Port,
 		Env:        app.config.App.Env,
 		OnShutdown: utils.TriggerShutdown,
+		Actions:    func() []tui.PaletteAction { return app.paletteActions(srv) },
+		Topology:   func() []tui.TopologyNode { return topologyNodes(srv.Topology()) },
 	})
 }
 
+// topologyNodes converts the dependency graph for the TUI topology view.
+func topologyNodes(g topology.Graph) []tui.TopologyNode {
+	nodes := make([]tui.TopologyNode, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, tui.TopologyNode{
+			ID:     n.ID,
+			Label:  n.Label,
+			Kind:   n.Kind,
+			Status: n.Status,
+			Detail: n.Detail,
+			Uses:   g.DependsOn(n.ID),
+		})
+	}
+	return nodes
+}
+
+// paletteActions lists the commands of the live TUI command palette:
+// running cron jobs, switching services on or off and the log level.
+func (app *Application) paletteActions(srv *server.Server) []tui.PaletteAction {
+	var actions []tui.PaletteAction
+
+	if cron, ok := registry.GetTyped[*infrastructure.CronManager](srv.Dependencies(), "cron"); ok {
+		for _, job := range cron.GetJobs() {
+			actions = append(actions, tui.PaletteAction{
+				Category: "Cron",
+				Title:    "Run " + job.Name + " now",
+				Hint:     job.Schedule,
+				Run: func() (string, error) {
+					return "Cron job " + job.Name + " triggered", cron.RunJobNow(job.ID)
+				},
+			})
+		}
+	}
+
+	var names []string
+	for name := range registry.GetServiceFactories() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		enabled := app.config.Services.IsEnabled(name)
+		title, state := "Enable "+name, "enabled"
+		if enabled {
+			title, state = "Disable "+name, "disabled"
+		}
+		actions = append(actions, tui.PaletteAction{
+			Category: "Service",
+			Title:    title,
+			Run: func() (string, error) {
+				srv.SetServiceEnabled(name, !enabled)
+				return "Service " + name + " " + state + " until restart", nil
+			},
+		})
+	}
+
+	current := logger.Level()
+	for _, level := range []string{"debug", "info", "warn", "error"} {
+		if level == current {
+			continue
+		}
+		actions = append(actions, tui.PaletteAction{
+			Category: "Log level",
+			Title:    "Set to " + level,
+			Run: func() (string, error) {
+				return "Log level set to " + level, logger.SetLevel(level)
+			},
+		})
+	}
+	if current != "" {
+		actions = append(actions, tui.PaletteAction{
+			Category: "Log level",
+			Title:    "Reset to configured",
+			Run: func() (string, error) {
+				return "Log level reset", logger.SetLevel("")
+			},
+		})
+	}
+	return actions
+}
+
 // handleShutdown handles graceful shutdown for TUI mode
 func (app *Application) handleShutdown(liveTUI *tui.LiveTUI, srv *server.Server) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	restart := false
 	select {
-	case <-sigChan:
+	case sig := <-sigChan:
 		liveTUI.AddLog(LogLevelWarn, "Shutting down...")
-		srv.Shutdown(context.Background(), app.logger)
+		srv.SetShutdownReason("signal: " + sig.String())
+		app.shutdown(srv)
 	case <-utils.ShutdownChan:
 		liveTUI.AddLog(LogLevelWarn, "Shutting down...")
-		srv.Shutdown(context.Background(), app.logger)
+		srv.SetShutdownReason("requested from the TUI")
+		app.shutdown(srv)
+	case <-utils.RestartChan:
+		liveTUI.AddLog(LogLevelWarn, "Restarting...")
+		srv.SetShutdownReason("restart")
+		app.shutdown(srv)
+		restart = true
 	}
 
 	liveTUI.Stop()
+	app.closeSinks()
+	if restart {
+		app.restart()
+	}
 	time.Sleep(ShutdownDelay)
 	os.Exit(0)
 }
@@ -257,14 +562,58 @@ func (app *Application) handleShutdown(liveTUI *tui.LiveTUI, srv *server.Server)
 func (app *Application) handleConsoleShutdown(srv *server.Server) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
 
-	app.logger.Warn("Shutting down...")
-	srv.Shutdown(context.Background(), app.logger)
+	restart := false
+	select {
+	case sig := <-sigChan:
+		app.logger.Warn("Shutting down...")
+		srv.SetShutdownReason("signal: " + sig.String())
+	case <-utils.RestartChan:
+		app.logger.Warn("Restarting...")
+		srv.SetShutdownReason("restart")
+		restart = true
+	}
+
+	app.shutdown(srv)
+	app.closeSinks()
+	if restart {
+		app.restart()
+	}
 	time.Sleep(ShutdownDelay)
 	os.Exit(0)
 }
 
+// shutdown drains and stops srv. When draining and closing infrastructure
+// take longer than server.force_shutdown_timeout the process exits with
+// status 1 instead of hanging.
+func (app *Application) shutdown(srv *server.Server) {
+	timeout := time.Duration(app.config.Server.ForceShutdownTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = GracefulShutdownTimeout
+	}
+	force := time.AfterFunc(timeout, func() {
+		app.logger.Error("Shutdown did not finish in time, forcing exit", nil, "timeout", timeout.String())
+		if err := srv.WriteShutdownReport(); err != nil {
+			app.logger.Error("Failed to write the shutdown report", err)
+		}
+		os.Exit(1)
+	})
+	defer force.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	srv.Shutdown(ctx, app.logger)
+}
+
+// restart replaces the process with a fresh instance (dev mode config
+// reload) and exits if that is not possible.
+func (app *Application) restart() {
+	if err := utils.RestartProcess(); err != nil {
+		fmt.Fprintln(os.Stderr, "Restart failed:", err)
+		os.Exit(1)
+	}
+}
+
 // logAllServices logs the status of all services
 func (app *Application) logAllServices() {
 	// Log infrastructure services
@@ -275,7 +624,7 @@ func (app *Application) logAllServices() {
 
 	// Log application services
 	for name, enabled := range app.config.Services {
-		app.logServiceStatus("Service: "+name, enabled)
+		app.logServiceStatus("Service: "+name, bool(enabled))
 	}
 
 }
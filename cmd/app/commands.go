@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/server"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/scaffold"
+	"stackyrd/pkg/utils"
+)
+
+// command is a subcommand of the binary. Its flags follow the
+// utils.FlagDefinition model; arguments after them are in Args.
+type command struct {
+	name     string
+	args     string // positional arguments, for the usage line
+	summary  string
+	flags    []utils.FlagDefinition
+	examples []string
+	run      func(flags *utils.ParsedFlags) int
+}
+
+// Flags shared by several commands
+var (
+	configFlag = utils.FlagDefinition{
+		Name:         "c",
+		DefaultValue: "",
+		Description:  "URL to load configuration from (YAML, JSON or TOML), or an etcd://host:2379/<key> or consul://host:8500/<key> source",
+		Validator: func(value interface{}) error {
+			if urlStr, ok := value.(string); ok && urlStr != "" {
+				if _, err := url.ParseRequestURI(urlStr); err != nil {
+					return fmt.Errorf("invalid config URL format: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+	envFlag = utils.FlagDefinition{
+		Name:         "env",
+		DefaultValue: "",
+		Description:  "Environment (development/staging/production); overrides app.env and selects config.<env>.yaml",
+	}
+	verboseFlag = utils.FlagDefinition{
+		Name:         "verbose",
+		DefaultValue: false,
+		Description:  "Enable verbose logging",
+	}
+	jsonFlag = utils.FlagDefinition{
+		Name:         "json",
+		DefaultValue: false,
+		Description:  "Print JSON instead of text",
+	}
+)
+
+// commands lists the subcommands; serve runs when none is given.
+func commands() []command {
+	return []command{
+		{
+			name:    "serve",
+			summary: "run the server (the default when no command is given)",
+			flags: []utils.FlagDefinition{
+				configFlag,
+				{Name: "port", DefaultValue: "", Description: "Server port (overrides config)"},
+				verboseFlag,
+				envFlag,
+			},
+			examples: []string{
+				fmt.Sprintf("./%-40s # Load config from local config.yaml", AppName),
+				fmt.Sprintf("./%s serve -c http://example.com/config.yaml", AppName),
+				fmt.Sprintf("./%s -port 9090 -env production", AppName),
+				fmt.Sprintf("./%s -c https://config.example.com/app.yaml -verbose", AppName),
+			},
+			run: runServe,
+		},
+		{
+			name:    "validate-config",
+			summary: "load the configuration and report unknown keys and invalid values",
+			flags:   []utils.FlagDefinition{configFlag, envFlag},
+			run:     runValidateConfig,
+		},
+		{
+			name:    "migrate",
+			summary: "connect the configured databases and run the migrations of the enabled services",
+			flags: []utils.FlagDefinition{
+				configFlag,
+				envFlag,
+				verboseFlag,
+				{Name: "timeout", DefaultValue: 300, Description: "Seconds the migrations may take"},
+			},
+			run: runMigrate,
+		},
+		{
+			name:    "routes",
+			summary: "print the registered routes; services needing infrastructure are left out",
+			flags:   []utils.FlagDefinition{configFlag, envFlag, jsonFlag},
+			run:     runRoutes,
+		},
+		{
+			name:    "version",
+			summary: "print the application, build and Go versions",
+			flags:   []utils.FlagDefinition{configFlag, envFlag, jsonFlag},
+			run:     runVersion,
+		},
+		{
+			name:    "healthcheck",
+			summary: "exit 0 when /health of the running server answers ok, 1 otherwise",
+			flags: []utils.FlagDefinition{
+				configFlag,
+				envFlag,
+				{Name: "url", DefaultValue: "", Description: "Health URL (default /health on localhost at server.port)"},
+				{Name: "timeout", DefaultValue: 5, Description: "Seconds to wait for the answer"},
+				{Name: "cert", DefaultValue: "", Description: "Client certificate to present, for auth.type mtls"},
+				{Name: "key", DefaultValue: "", Description: "Key of the client certificate"},
+			},
+			examples: []string{
+				fmt.Sprintf(`HEALTHCHECK CMD ["./%s", "healthcheck"]`, AppName),
+				fmt.Sprintf("./%s healthcheck -cert client.pem -key client-key.pem", AppName),
+			},
+			run: runHealthcheck,
+		},
+		{
+			name:    "new-service",
+			args:    "<name>",
+			summary: "generate a service module, its test and its services toggle",
+			flags: []utils.FlagDefinition{
+				{Name: "c", DefaultValue: "", Description: "Config file to add the toggle to (default: the one serve loads)"},
+				{Name: "no-test", DefaultValue: false, Description: "Do not generate the test file"},
+				{Name: "no-config", DefaultValue: false, Description: "Do not add the services toggle"},
+				{Name: "dry-run", DefaultValue: false, Description: "Print what would be written without writing"},
+			},
+			examples: []string{
+				fmt.Sprintf("./%s new-service invoices", AppName),
+				fmt.Sprintf("./%s new-service OrderItems -no-test", AppName),
+			},
+			run: runNewService,
+		},
+		{
+			name:    "config",
+			args:    "<command> [value]",
+			summary: "keygen, encrypt, decrypt or schema (see config without a command)",
+			run: func(flags *utils.ParsedFlags) int {
+				return runConfigCommand(flags.Args, os.Stdin, os.Stdout, os.Stderr)
+			},
+		},
+	}
+}
+
+// runCommand runs the command named by the first argument, serve when it
+// is missing or a flag, and returns the exit code.
+func runCommand(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printCommands(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+		set := flag.NewFlagSet(AppName+" "+cmd.name, flag.ContinueOnError)
+		set.SetOutput(io.Discard)
+		flags, err := utils.ParseArgs(set, cmd.flags, args)
+		if errors.Is(err, flag.ErrHelp) {
+			cmd.usage()
+			return 0
+		}
+		if err != nil {
+			fmt.Printf("Error parsing flags: %v\n", err)
+			cmd.usage()
+			return 2
+		}
+		return cmd.run(flags)
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	printCommands(os.Stderr)
+	return 2
+}
+
+// usage prints the flags of the command; serve, the default, lists the
+// other commands too.
+func (cmd command) usage() {
+	if cmd.name == "serve" {
+		printCommands(os.Stdout)
+	}
+	line := AppName + " " + cmd.name
+	if len(cmd.flags) > 0 {
+		line += " [flags]"
+	}
+	if cmd.args != "" {
+		line += " " + cmd.args
+	}
+	utils.PrintUsage(cmd.flags, line, cmd.examples...)
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", AppName)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun %s <command> -h for the flags of a command.\n\n", AppName)
+}
+
+// runServe runs the server until it stops.
+func runServe(flags *utils.ParsedFlags) int {
+	// Create configuration manager
+	configManager := NewConfigManager(flags.ConfigURL, flags.Env)
+
+	// Create application with dependency injection
+	app := NewApplication(configManager)
+
+	// Run application with error handling
+	if err := app.Run(); err != nil {
+		fmt.Printf("Fatal error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runValidateConfig loads the configuration like serve does and checks
+// every section of the local files against Config, without checking the
+// port or connecting anything.
+func runValidateConfig(flags *utils.ParsedFlags) int {
+	configManager := NewConfigManager(flags.ConfigURL, flags.Env)
+	cfg, err := configManager.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	files := config.LoadedLayers().Files
+	var errs []error
+	if flags.ConfigURL == "" {
+		for _, file := range files {
+			if err := config.ValidateFile(file); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			}
+		}
+	}
+	if err := configManager.CheckConfig(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		for _, err := range errs {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(os.Stderr, "  %s\n", line)
+			}
+		}
+		return 1
+	}
+	fmt.Printf("Configuration is valid (%s, environment %q)\n", strings.Join(files, ", "), cfg.App.Env)
+	return 0
+}
+
+// runMigrate runs the migrations of the enabled services.
+func runMigrate(flags *utils.ParsedFlags) int {
+	cfg, err := NewConfigManager(flags.ConfigURL, flags.Env).LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	l := logger.NewQuiet(false, nil)
+	if flags.Verbose {
+		l = logger.New(true, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(flags.Timeout)*time.Second)
+	defer cancel()
+	results, connectErr := server.New(cfg, l).Migrate(ctx)
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", result.Service, result.Err)
+			continue
+		}
+		fmt.Printf("ok    %s (%s)\n", result.Service, result.Duration.Round(time.Millisecond))
+	}
+	if connectErr != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect, services using these were not migrated:\n  %s\n",
+			strings.ReplaceAll(connectErr.Error(), "\n", "\n  "))
+		return 1
+	}
+	if len(results) == 0 {
+		fmt.Println("No enabled service has migrations")
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// routeInfo is one route printed by the routes command.
+type routeInfo struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// runRoutes prints the routes of the configured services, middleware and
+// monitoring API.
+func runRoutes(flags *utils.ParsedFlags) int {
+	cfg, err := NewConfigManager(flags.ConfigURL, flags.Env).LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	var routes []routeInfo
+	for _, r := range server.New(cfg, logger.NewQuiet(false, nil)).Routes() {
+		routes = append(routes, routeInfo{Method: r.Method, Path: r.Path, Handler: r.Handler})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	if flags.JSON {
+		return printJSON(routes)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tHANDLER")
+	for _, r := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Method, r.Path, r.Handler)
+	}
+	tw.Flush()
+	return 0
+}
+
+// runVersion prints the application version from the configuration, when
+// it loads, with the VCS revision the binary was built from.
+func runVersion(flags *utils.ParsedFlags) int {
+	version := map[string]string{
+		"name":       AppName,
+		"version":    DefaultVersion,
+		"go_version": runtime.Version(),
+	}
+	if cfg, err := NewConfigManager(flags.ConfigURL, flags.Env).LoadConfig(); err == nil {
+		if cfg.App.Name != "" {
+			version["name"] = cfg.App.Name
+		}
+		if cfg.App.Version != "" {
+			version["version"] = cfg.App.Version
+		}
+		version["env"] = cfg.App.Env
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				version["revision"] = setting.Value
+			case "vcs.time":
+				version["build_time"] = setting.Value
+			case "vcs.modified":
+				version["modified"] = setting.Value
+			}
+		}
+	}
+
+	if flags.JSON {
+		return printJSON(version)
+	}
+	fmt.Printf("%s %s\n", version["name"], version["version"])
+	for _, key := range []string{"env", "revision", "build_time", "modified", "go_version"} {
+		if value := version[key]; value != "" {
+			fmt.Printf("  %-11s %s\n", key, value)
+		}
+	}
+	return 0
+}
+
+// runHealthcheck asks /health of the running server, for container health
+// checks. The server on localhost is trusted when it presents the
+// certificate of server.tls; -cert and -key present a client certificate.
+func runHealthcheck(flags *utils.ParsedFlags) int {
+	if (flags.Cert == "") != (flags.Key == "") {
+		fmt.Fprintln(os.Stderr, "-cert and -key go together")
+		return 2
+	}
+	tlsConfig := &tls.Config{}
+	if flags.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(flags.Cert, flags.Key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unhealthy: client certificate: %v\n", err)
+			return 1
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	target := flags.URL
+	if target == "" {
+		port, scheme := DefaultServerPort, "http"
+		if cfg, err := NewConfigManager(flags.ConfigURL, flags.Env).LoadConfig(); err == nil {
+			port = cfg.Server.Port
+			if cfg.Server.TLS.Enabled {
+				scheme = "https"
+				if err := trustServerCertificate(tlsConfig, cfg.Server.TLS); err != nil {
+					fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+					return 1
+				}
+			}
+		}
+		target = scheme + "://localhost:" + port + "/health"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Timeout: time.Duration(flags.Timeout) * time.Second, Transport: transport}
+	resp, err := client.Get(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data struct {
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		fmt.Fprintf(os.Stderr, "unhealthy: invalid response: %v\n", err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK || body.Data.Status != "ok" {
+		fmt.Fprintf(os.Stderr, "unhealthy: %s status %q\n", resp.Status, body.Data.Status)
+		return 1
+	}
+	fmt.Println("healthy")
+	return 0
+}
+
+// trustServerCertificate makes tlsConfig accept the server on localhost,
+// whose certificate names the public host: the certificate of cert_file
+// itself, or one valid for the first autocert domain.
+func trustServerCertificate(tlsConfig *tls.Config, cfg config.TLSConfig) error {
+	if cfg.Autocert.Enabled {
+		if len(cfg.Autocert.Domains) > 0 {
+			tlsConfig.ServerName = cfg.Autocert.Domains[0]
+		}
+		return nil
+	}
+	pair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("server.tls certificate: %w", err)
+	}
+	expected := pair.Certificate[0]
+	// The chain is not verified against a host name, only compared
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], expected) {
+			return errors.New("the server does not present the certificate of server.tls.cert_file")
+		}
+		return nil
+	}
+	return nil
+}
+
+// runNewService generates the service named by the argument in the
+// project containing the working directory.
+func runNewService(flags *utils.ParsedFlags) int {
+	if len(flags.Args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s new-service [flags] <name>\n", AppName)
+		return 2
+	}
+	result, err := scaffold.Generate(flags.Args[0], scaffold.Options{
+		ConfigFile: flags.ConfigURL,
+		SkipTest:   flags.NoTest,
+		SkipConfig: flags.NoConfig,
+		DryRun:     flags.DryRun,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	created, added := "Created", "Added"
+	if flags.DryRun {
+		created, added = "Would create", "Would add"
+	}
+	for _, file := range result.Files {
+		fmt.Printf("%s %s\n", created, file)
+	}
+	if result.ConfigFile != "" {
+		fmt.Printf("%s services.%s: true to %s\n", added, result.Names.Key, result.ConfigFile)
+	}
+	fmt.Printf("\n%s serves %s under /api/v1; run go test ./tests/services/ once its handlers are written.\n",
+		result.Names.Type, result.Names.Route)
+	return 0
+}
+
+func printJSON(v interface{}) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
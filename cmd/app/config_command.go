@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"stackyrd/config"
+)
+
+const configCommandUsage = `Usage: %s config <command> [value]
+
+Commands:
+  keygen           print a new master key for %s
+  encrypt [value]  print value (or stdin) as ENC[...] for config.yaml
+  decrypt [value]  print the plaintext of an ENC[...] value (or stdin)
+  schema [file]    print (or write to file) the JSON Schema of the config file
+
+encrypt and decrypt read the master key from %s or %s.
+`
+
+// runConfigCommand runs "config keygen|encrypt|decrypt|schema" and returns
+// the exit code.
+func runConfigCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	usage := func() int {
+		fmt.Fprintf(stderr, configCommandUsage, AppName, config.ConfigKeyEnv, config.ConfigKeyEnv, config.ConfigKeyFileEnv)
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+
+	switch args[0] {
+	case "keygen":
+		key, err := config.GenerateConfigKey()
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, key)
+		return 0
+	case "schema":
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err == nil && len(args) > 1 && args[1] != "-" {
+			err = os.WriteFile(args[1], append(data, '\n'), 0o644)
+		} else if err == nil {
+			_, err = fmt.Fprintln(stdout, string(data))
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	case "encrypt", "decrypt":
+	default:
+		return usage()
+	}
+
+	value, err := commandValue(args[1:], stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	key, err := config.LoadConfigKey()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	var out string
+	if args[0] == "encrypt" {
+		out, err = config.EncryptValue(key, value)
+	} else {
+		out, err = config.DecryptValue(key, value)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, out)
+	return 0
+}
+
+// commandValue is the value argument, or the first line of stdin when it
+// is missing or "-", so secrets need not appear in the shell history.
+func commandValue(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return args[0], nil
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("no value given")
+	}
+	return line, nil
+}
@@ -1,34 +1,75 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/remoteconfig"
 	"stackyrd/pkg/utils"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 // ConfigManager handles all configuration loading and validation
 type ConfigManager struct {
 	configURL string
+	env       string
 }
 
-// NewConfigManager creates a new configuration manager
-func NewConfigManager(configURL string) *ConfigManager {
+// NewConfigManager creates a new configuration manager; a non-empty env
+// selects the config.<env>.yaml overlay over app.env
+func NewConfigManager(configURL, env string) *ConfigManager {
 	return &ConfigManager{
 		configURL: configURL,
+		env:       env,
 	}
 }
 
-// LoadConfig loads configuration from local file or URL
+// LoadConfig loads configuration from local file, URL, etcd or Consul
 func (cm *ConfigManager) LoadConfig() (*config.Config, error) {
+	config.SetEnvironment(cm.env)
+	if remoteconfig.IsRemote(cm.configURL) {
+		return cm.loadConfigFromRemote(cm.configURL)
+	}
 	if cm.configURL != "" {
 		return cm.loadConfigFromURL(cm.configURL)
 	}
 	return cm.loadConfigFromFile()
 }
 
+// loadConfigFromRemote loads configuration from an etcd or Consul key
+func (cm *ConfigManager) loadConfigFromRemote(source string) (*config.Config, error) {
+	provider, err := remoteconfig.Open(source, remoteconfig.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Loading config from %s: %s/%s\n", provider.Name(), provider.Endpoint(), provider.Key())
+
+	value, err := provider.Get(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config from %s: %w", provider.Name(), err)
+	}
+	viper.SetConfigType(config.FormatOf(provider.Key()))
+	if err := viper.ReadConfig(bytes.NewReader(value.Data)); err != nil {
+		return nil, fmt.Errorf("failed to parse config from %s: %w", provider.Name(), err)
+	}
+
+	cfg, err := config.LoadConfigWithURL(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config from %s: %w", provider.Name(), err)
+	}
+	config.SetRemoteSource(source)
+	return cfg, nil
+}
+
 // loadConfigFromURL loads configuration from a URL
 func (cm *ConfigManager) loadConfigFromURL(configURL string) (*config.Config, error) {
 	fmt.Printf("Loading config from URL: %s\n", configURL)
@@ -67,6 +108,25 @@ func (cm *ConfigManager) ValidateConfig(cfg *config.Config) error {
 	if err := utils.CheckPortAvailability(cfg.Server.Port); err != nil {
 		return fmt.Errorf("%s: %w", ErrPortError, err)
 	}
+	return cm.CheckConfig(cfg)
+}
+
+// CheckConfig validates the values of the configuration that the config
+// loader accepts but the application does not
+func (cm *ConfigManager) CheckConfig(cfg *config.Config) error {
+	if cfg.Photos.MaxSizeMB > MaxPhotoSizeMB {
+		return fmt.Errorf("photos.max_size_mb must be at most %d", MaxPhotoSizeMB)
+	}
+	names := make([]string, 0, len(cfg.ServiceSettings))
+	for name := range cfg.ServiceSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := middleware.CheckServiceMiddleware(cfg.ServiceSettings[name].Middleware); err != nil {
+			return fmt.Errorf("services.%s.middleware: %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -92,13 +152,22 @@ func (cm *ConfigManager) LoadBanner(cfg *config.Config) (string, error) {
 
 // GetServiceConfigs returns a unified list of all service configurations
 func (cm *ConfigManager) GetServiceConfigs(cfg *config.Config) []ServiceConfig {
+	// MinIO alone has no storage component reporting connection events
+	storageComponent := ""
+	if cfg.Storage.Enabled {
+		storageComponent = "storage"
+	}
 	return []ServiceConfig{
-		{Name: ServiceGrafanaName, Enabled: cfg.Grafana.Enabled},
-		{Name: ServiceRedisCacheName, Enabled: cfg.Redis.Enabled},
-		{Name: ServiceKafkaName, Enabled: cfg.Kafka.Enabled},
-		{Name: ServicePostgreSQLName, Enabled: cfg.Postgres.Enabled},
-		{Name: ServiceMongoDBName, Enabled: cfg.Mongo.Enabled},
-		{Name: ServiceCronName, Enabled: cfg.Cron.Enabled},
+		{Name: ServiceVaultName, Enabled: cfg.Vault.Enabled, Component: "vault"},
+		{Name: ServiceGrafanaName, Enabled: cfg.Grafana.Enabled, Component: "grafana"},
+		{Name: ServiceRedisCacheName, Enabled: cfg.Redis.Enabled, Component: "redis"},
+		{Name: ServiceKafkaName, Enabled: cfg.Kafka.Enabled, Component: "kafka"},
+		{Name: ServiceNATSName, Enabled: cfg.NATS.Enabled, Component: "nats"},
+		{Name: ServiceRabbitMQName, Enabled: cfg.RabbitMQ.Enabled, Component: "rabbitmq"},
+		{Name: ServicePostgreSQLName, Enabled: cfg.Postgres.Enabled, Component: "postgres"},
+		{Name: ServiceMongoDBName, Enabled: cfg.Mongo.Enabled, Component: "mongo"},
+		{Name: ServiceStorageName, Enabled: cfg.Storage.Enabled || cfg.MinIO.Enabled, Component: storageComponent},
+		{Name: ServiceCronName, Enabled: cfg.Cron.Enabled, Component: "cron"},
 	}
 }
 
@@ -113,17 +182,25 @@ func (cm *ConfigManager) CreateServiceQueue(cfg *config.Config) []ServiceInit {
 	// Add infrastructure services
 	for _, svc := range serviceConfigs {
 		initQueue = append(initQueue, ServiceInit{
-			Name: svc.Name, Enabled: svc.Enabled, InitFunc: nil,
+			Name: svc.Name, Enabled: svc.Enabled, InitFunc: nil, Component: svc.Component,
 		})
 	}
 
+	// Provision Grafana dashboards/datasources so results show up in the boot screen
+	if cfg.Grafana.Enabled && cfg.Grafana.Provisioning.Enabled {
+		initQueue = append(initQueue, cm.grafanaProvisioningInit(cfg))
+	}
+
 	initQueue = append(initQueue, ServiceInit{Name: ServiceMiddlewareName, Enabled: true, InitFunc: nil})
 
 	// Add application services
 	for name, enabled := range cfg.Services {
-		initQueue = append(initQueue, ServiceInit{Name: "Service: " + name, Enabled: enabled, InitFunc: nil})
+		initQueue = append(initQueue, ServiceInit{Name: "Service: " + name, Enabled: bool(enabled), InitFunc: nil})
 	}
 
+	// Migrations of the registered models and migrating services, reported by the server
+	initQueue = append(initQueue, ServiceInit{Name: ServiceMigrationsName, Enabled: true, InitFunc: nil, Component: MigrationsComponent})
+
 	// Add monitoring last
 	initQueue = append(initQueue, ServiceInit{Name: ServiceMonitoringName, InitFunc: nil})
 
@@ -163,3 +240,26 @@ func (cm *ConfigManager) GetDefaultConfig() *config.Config {
 		},
 	}
 }
+
+// grafanaProvisioningInit runs Grafana provisioning during the boot sequence.
+// The infrastructure component reuses the report instead of provisioning again.
+func (cm *ConfigManager) grafanaProvisioningInit(cfg *config.Config) ServiceInit {
+	var report *infrastructure.GrafanaProvisionReport
+	return ServiceInit{
+		Name:    ServiceProvisionName,
+		Enabled: true,
+		InitFunc: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			var err error
+			report, err = infrastructure.ProvisionGrafana(ctx, cfg.Grafana, nil)
+			return err
+		},
+		DetailFunc: func() string {
+			if report == nil {
+				return "Ready"
+			}
+			return report.Summary()
+		},
+	}
+}
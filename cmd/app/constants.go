@@ -1,13 +1,13 @@
 package main
 
 import (
+	"stackyrd/pkg/logger"
 	"time"
 )
 
 // Forward declarations to avoid circular imports
 type Config struct{}
 type Logger struct{}
-type LogBroadcaster struct{}
 
 // Application constants
 const (
@@ -29,13 +29,22 @@ const (
 	ServiceMiddlewareName = "Middleware"
 	ServiceMonitoringName = "Monitoring"
 	ServiceGrafanaName    = "Grafana"
-	ServiceMinIOName      = "MinIO"
+	ServiceStorageName    = "Object Storage"
+	ServiceProvisionName  = "Grafana Provisioning"
 	ServiceRedisCacheName = "Redis Cache"
 	ServiceKafkaName      = "Kafka Messaging"
+	ServiceNATSName       = "NATS Messaging"
+	ServiceRabbitMQName   = "RabbitMQ Messaging"
 	ServicePostgreSQLName = "PostgreSQL"
 	ServiceMongoDBName    = "MongoDB"
 	ServiceCronName       = "Cron Scheduler"
 	ServiceExternalName   = "External Services"
+	ServiceVaultName      = "Vault"
+	ServiceMigrationsName = "Database Migrations"
+
+	// MigrationsComponent is the boot event component of the migrations
+	// the server runs once the services are created
+	MigrationsComponent = "migrations"
 
 	// Color codes for TUI output
 	ColorPurple = "\033[35m"
@@ -50,22 +59,25 @@ const (
 
 // ServiceInit represents a service in the initialization queue
 type ServiceInit struct {
-	Name     string
-	Enabled  bool
-	InitFunc func() error
+	Name       string
+	Enabled    bool
+	InitFunc   func() error
+	DetailFunc func() string
+	Component  string // infrastructure component reporting connection events
 }
 
 // ServiceConfig represents a service with its name and enabled status
 type ServiceConfig struct {
-	Name    string
-	Enabled bool
+	Name      string
+	Enabled   bool
+	Component string // infrastructure registry name
 }
 
 // AppContext holds the application state throughout initialization
 type AppContext struct {
 	Config      *Config
 	Logger      *Logger
-	Broadcaster *LogBroadcaster
+	Broadcaster *logger.LogBroadcaster
 	BannerText  string
 	Timestamp   string
 	ConfigURL   string
@@ -126,6 +138,7 @@ const (
 	ShutdownDelay           = 100 * time.Millisecond
 	PortCheckTimeout        = 5 * time.Second
 	GracefulShutdownTimeout = 30 * time.Second
+	BootConnectTimeout      = 30 * time.Second // boot screen wait for infrastructure connections
 )
 
 // Log levels for structured logging
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ErrBackupNotFound is returned for backup IDs with no backup file.
+var ErrBackupNotFound = errors.New("config backup not found")
+
+// backupIDFormat names a backup by when it was taken, in UTC.
+const backupIDFormat = "20060102T150405.000000000Z"
+
+// Backup is a copy of the config file taken before it was changed.
+type Backup struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	Version string    `json:"version"` // fingerprint of the backed up file
+}
+
+// BackupStore keeps copies of a config file in a directory, named
+// <name>.<time><ext>, and prunes them by count and age.
+type BackupStore struct {
+	path   string // the config file
+	dir    string
+	keep   int
+	maxAge time.Duration
+}
+
+// NewBackupStore keeps the backups of the config file at path as
+// configured; an empty cfg.Dir puts them in config-backups next to it.
+func NewBackupStore(path string, cfg ConfigBackupsConfig) *BackupStore {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(path), "config-backups")
+	}
+	return &BackupStore{path: path, dir: dir, keep: cfg.Keep, maxAge: time.Duration(cfg.MaxAge) * time.Second}
+}
+
+// Dir is where the backups are kept.
+func (b *BackupStore) Dir() string {
+	return b.dir
+}
+
+func (b *BackupStore) fileName(id string) string {
+	base := filepath.Base(b.path)
+	ext := filepath.Ext(base)
+	return filepath.Join(b.dir, strings.TrimSuffix(base, ext)+"."+id+ext)
+}
+
+// parseID maps a file in the backup directory back to its ID.
+func (b *BackupStore) parseID(name string) (string, time.Time, bool) {
+	base := filepath.Base(b.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "."
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return "", time.Time{}, false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	t, err := time.Parse(backupIDFormat, id)
+	return id, t, err == nil
+}
+
+// Create copies the current config file into a new backup and prunes the
+// old ones.
+func (b *BackupStore) Create() (Backup, error) {
+	sectionFileMu.Lock()
+	defer sectionFileMu.Unlock()
+	return b.createLocked()
+}
+
+// createLocked backs up the config file. Callers hold sectionFileMu.
+func (b *BackupStore) createLocked() (Backup, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return Backup{}, err
+	}
+	if err := os.MkdirAll(b.dir, 0o700); err != nil {
+		return Backup{}, err
+	}
+	now := time.Now().UTC()
+	id := now.Format(backupIDFormat)
+	// Backups hold credentials, like the config file itself
+	if err := os.WriteFile(b.fileName(id), data, 0o600); err != nil {
+		return Backup{}, err
+	}
+	if _, err := b.pruneLocked(); err != nil {
+		return Backup{}, err
+	}
+	return Backup{ID: id, Time: now, Size: int64(len(data)), Version: fileVersion(data)}, nil
+}
+
+// List returns the backups, newest first.
+func (b *BackupStore) List() ([]Backup, error) {
+	entries, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, e := range entries {
+		id, t, ok := b.parseID(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(b.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{ID: id, Time: t, Size: int64(len(data)), Version: fileVersion(data)})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// Read returns the content of the backup id.
+func (b *BackupStore) Read(id string) ([]byte, error) {
+	if _, err := time.Parse(backupIDFormat, id); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, id)
+	}
+	data, err := os.ReadFile(b.fileName(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, id)
+	}
+	return data, err
+}
+
+// Diff returns the unified diff from the backup id to the current config
+// file, empty when they are the same. Secret values are masked on both
+// sides, so a change of only a secret does not show.
+func (b *BackupStore) Diff(id string) (string, error) {
+	backup, err := b.Read(id)
+	if err != nil {
+		return "", err
+	}
+	current, err := os.ReadFile(b.path)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(maskSecretLines(string(backup))),
+		B:        difflib.SplitLines(maskSecretLines(string(current))),
+		FromFile: "backup/" + id,
+		ToFile:   filepath.Base(b.path),
+		Context:  3,
+	})
+}
+
+// Restore replaces the config file with the backup id after checking that
+// it still loads. The replaced file is backed up first, so a restore can be
+// undone; that backup is returned.
+func (b *BackupStore) Restore(id string) (Backup, error) {
+	data, err := b.Read(id)
+	if err != nil {
+		return Backup{}, err
+	}
+	if err := validateDocument(FormatOf(b.path), data); err != nil {
+		return Backup{}, fmt.Errorf("%w: backup %s: %v", ErrInvalidSection, id, err)
+	}
+
+	sectionFileMu.Lock()
+	defer sectionFileMu.Unlock()
+	previous, err := b.createLocked()
+	if err != nil {
+		return Backup{}, err
+	}
+	return previous, writeFileAtomic(b.path, data)
+}
+
+// Prune removes the backups beyond the configured count and age and
+// returns them.
+func (b *BackupStore) Prune() ([]Backup, error) {
+	sectionFileMu.Lock()
+	defer sectionFileMu.Unlock()
+	return b.pruneLocked()
+}
+
+// pruneLocked applies the retention. Callers hold sectionFileMu.
+func (b *BackupStore) pruneLocked() ([]Backup, error) {
+	backups, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	removed := []Backup{}
+	for i, backup := range backups {
+		tooMany := b.keep > 0 && i >= b.keep
+		tooOld := b.maxAge > 0 && time.Since(backup.Time) > b.maxAge
+		// The newest backup always stays, whatever its age
+		if i == 0 || (!tooMany && !tooOld) {
+			continue
+		}
+		if err := os.Remove(b.fileName(backup.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
+
+func fileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// secretLine matches a YAML or JSON "key: value" line, possibly a list
+// item, or a TOML "key = value" line.
+var secretLine = regexp.MustCompile(`^(\s*(?:-\s+)?["']?([A-Za-z0-9_.-]+)["']?\s*[:=]\s+)(\S.*)$`)
+
+// maskSecretLines replaces the values of secret keys and ENC[...] values
+// in config text with SecretMask, keeping every other line as is.
+func maskSecretLines(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		m := secretLine.FindStringSubmatch(body)
+		if m == nil || (!isSecretKey(m[2]) && !IsEncryptedValue(strings.Trim(m[3], `"'`))) {
+			continue
+		}
+		lines[i] = m[1] + SecretMask + line[len(body):]
+	}
+	return strings.Join(lines, "")
+}
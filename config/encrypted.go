@@ -0,0 +1,147 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Config values can be stored encrypted as ENC[AES256_GCM,<base64>], the
+// base64 holding the GCM nonce followed by the ciphertext. They are
+// decrypted while the config is decoded, with the master key from
+// ConfigKeyEnv or ConfigKeyFileEnv.
+const (
+	ConfigKeyEnv     = "STACKYRD_CONFIG_KEY"      // base64 of 32 bytes, or a vault:// reference to it
+	ConfigKeyFileEnv = "STACKYRD_CONFIG_KEY_FILE" // file holding the same, e.g. mounted by a KMS agent
+
+	encryptedPrefix = "ENC[AES256_GCM,"
+	encryptedSuffix = "]"
+)
+
+var (
+	// ErrNoConfigKey is returned when an encrypted value is met without a
+	// master key configured.
+	ErrNoConfigKey = errors.New("config master key is not set")
+	// ErrDecryptValue is returned for ENC[...] values the key cannot open.
+	ErrDecryptValue = errors.New("cannot decrypt config value")
+)
+
+// IsEncryptedValue reports whether s is an ENC[...] value.
+func IsEncryptedValue(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, encryptedPrefix) && strings.HasSuffix(s, encryptedSuffix)
+}
+
+// GenerateConfigKey returns a new random master key, base64 encoded.
+func GenerateConfigKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseConfigKey decodes a base64 master key.
+func ParseConfigKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid config master key: %d bytes, want 32", len(key))
+	}
+	return key, nil
+}
+
+// LoadConfigKey reads the master key from the environment. A vault://
+// reference is read from Vault (VAULT_ADDR, VAULT_TOKEN).
+func LoadConfigKey() ([]byte, error) {
+	r := &secretResolver{vault: VaultConfig{}.withEnvDefaults()}
+	return r.configKey()
+}
+
+// EncryptValue encrypts plaintext into an ENC[...] value.
+func EncryptValue(key []byte, plaintext string) (string, error) {
+	gcm, err := newConfigGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed) + encryptedSuffix, nil
+}
+
+// DecryptValue opens an ENC[...] value.
+func DecryptValue(key []byte, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !IsEncryptedValue(value) {
+		return "", fmt.Errorf("%w: not an %s...%s value", ErrDecryptValue, encryptedPrefix, encryptedSuffix)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(value, encryptedPrefix), encryptedSuffix))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecryptValue, err)
+	}
+	gcm, err := newConfigGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("%w: too short", ErrDecryptValue)
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		// A wrong key and a tampered value look the same
+		return "", fmt.Errorf("%w: wrong key or corrupted value", ErrDecryptValue)
+	}
+	return string(plaintext), nil
+}
+
+func newConfigGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config master key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// configKey returns the master key, read once per resolver.
+func (r *secretResolver) configKey() ([]byte, error) {
+	if r.key != nil {
+		return r.key, nil
+	}
+	encoded := os.Getenv(ConfigKeyEnv)
+	if encoded == "" {
+		if file := os.Getenv(ConfigKeyFileEnv); file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", ConfigKeyFileEnv, err)
+			}
+			encoded = string(data)
+		}
+	}
+	if encoded = strings.TrimSpace(encoded); encoded == "" {
+		return nil, fmt.Errorf("%w: set %s or %s", ErrNoConfigKey, ConfigKeyEnv, ConfigKeyFileEnv)
+	}
+	if strings.HasPrefix(encoded, VaultScheme) {
+		var err error
+		if encoded, err = r.vaultValue(encoded); err != nil {
+			return nil, err
+		}
+	}
+	key, err := ParseConfigKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	r.key = key
+	return key, nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/spf13/viper"
+)
+
+// Fingerprint returns a short digest of the effective configuration, the
+// same for equal configurations wherever they came from. Secrets are part
+// of the digest, so changing a password changes the fingerprint.
+func Fingerprint(cfg *Config) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadedFingerprint is the fingerprint of the configuration loaded last,
+// before secret resolution.
+var loadedFingerprint string
+
+// LoadedFingerprint returns the fingerprint of the configuration loaded
+// last with its ${VAR}, vault:// and ENC[...] values as written: what
+// ReadConfigFile with Unresolved fingerprints to while the file is
+// unchanged.
+func LoadedFingerprint() string {
+	return loadedFingerprint
+}
+
+// ReadOption configures ReadConfigFile.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	unresolved bool
+}
+
+// Unresolved keeps ${VAR}, vault:// and ENC[...] values as written instead
+// of resolving them, so reading the file needs neither Vault nor the
+// master key. Used to compare the file with the running configuration,
+// see LoadedFingerprint.
+func Unresolved() ReadOption {
+	return func(o *readOptions) { o.unresolved = true }
+}
+
+// ReadConfigFile loads the configuration in path the way LoadConfig does,
+// with defaults, the overlay of the environment and environment
+// overrides, without touching the loaded
+// configuration.
+func ReadConfigFile(path string, opts ...ReadOption) (*Config, error) {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	if _, err := applyOverlay(v, path, true); err != nil {
+		return nil, err
+	}
+
+	if o.unresolved {
+		return decodeUnresolved(v)
+	}
+	return decode(v)
+}
+
+// ReadConfigData loads a config document in format (FormatYAML, FormatJSON
+// or FormatTOML) the way LoadConfig does, with defaults and environment
+// overrides, without touching the loaded configuration.
+func ReadConfigData(data []byte, format string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	return decode(v)
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file formats, named like their viper config types.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// configExtensions are the extensions of config.* looked up at startup, in
+// order of preference.
+var configExtensions = []string{".yaml", ".yml", ".json", ".toml"}
+
+// FormatOf returns the format of a config file by its extension; anything
+// but .json and .toml is YAML.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// FindConfigFile returns the first config.yaml, config.yml, config.json or
+// config.toml in dirs, or "" when there is none.
+func FindConfigFile(dirs ...string) string {
+	for _, dir := range dirs {
+		for _, ext := range configExtensions {
+			path := filepath.Join(dir, "config"+ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// decodeDocument parses a config document in format into a map.
+func decodeDocument(format string, data []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &doc)
+	case FormatTOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		err = yaml.Unmarshal(data, &doc)
+	}
+	return doc, err
+}
+
+// encodeNodeJSON writes node as indented JSON, keeping the key order of
+// the file.
+func encodeNodeJSON(buf *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if err := encodeNodeJSON(buf, child, indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yaml.AliasNode:
+		return encodeNodeJSON(buf, node.Alias, indent)
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, end := "{", "}"
+		step := 2
+		if node.Kind == yaml.SequenceNode {
+			open, end, step = "[", "]", 1
+		}
+		if len(node.Content) == 0 {
+			buf.WriteString(open + end)
+			return nil
+		}
+		inner := indent + "  "
+		buf.WriteString(open + "\n")
+		for i := 0; i < len(node.Content); i += step {
+			buf.WriteString(inner)
+			if step == 2 {
+				key, _ := json.Marshal(node.Content[i].Value)
+				buf.Write(key)
+				buf.WriteString(": ")
+			}
+			if err := encodeNodeJSON(buf, node.Content[i+step-1], inner); err != nil {
+				return err
+			}
+			if i+step < len(node.Content) {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + end)
+		return nil
+	}
+	value, err := decodeNode(node)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode %q as JSON: %w", node.Value, err)
+	}
+	buf.Write(data)
+	return nil
+}
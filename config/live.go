@@ -0,0 +1,22 @@
+package config
+
+import "sync"
+
+// liveMu guards the parts of the running Config that change at runtime,
+// such as the tenants and connections provisioned through the API.
+var liveMu sync.RWMutex
+
+// Update runs fn, which changes the running Config, while no View reads it.
+func Update(fn func()) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	fn()
+}
+
+// View runs fn, which reads parts of the running Config that Update may
+// change.
+func View(fn func()) {
+	liveMu.RLock()
+	defer liveMu.RUnlock()
+	fn()
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Sources of config values other than files, as reported by Layers.
+const (
+	SourceDefault = "default"
+	SourceFlag    = "flag"
+	sourceEnv     = "env:"
+)
+
+// Layers describes where the loaded configuration came from. Precedence
+// is deterministic: defaults, then the config file, then the overlay of
+// the environment (config.<env>.yaml), then environment variables, then
+// the -env flag.
+type Layers struct {
+	Environment string            `json:"environment"`
+	Files       []string          `json:"files"`   // in precedence order, lowest first
+	Sources     map[string]string `json:"sources"` // key -> "default", a file or URL, "env:VAR" or "flag"
+}
+
+// Source returns where the value of key came from. Keys inside a value
+// set as a whole (e.g. a list) report the source of that value.
+func (l Layers) Source(key string) string {
+	for k := strings.ToLower(key); k != ""; {
+		if source, ok := l.Sources[k]; ok {
+			return source
+		}
+		i := strings.LastIndex(k, ".")
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	return SourceDefault
+}
+
+var (
+	envOverride string
+	loaded      = Layers{Sources: map[string]string{}}
+)
+
+// SetEnvironment selects the environment, and so the overlay, regardless
+// of app.env, as the -env flag does. Call it before loading.
+func SetEnvironment(env string) {
+	envOverride = env
+}
+
+// LoadedLayers returns the layers of the configuration loaded last.
+func LoadedLayers() Layers {
+	return loaded
+}
+
+// OverlayPath returns the overlay of path for env, in the same format, e.g.
+// config.production.yaml next to config.yaml.
+func OverlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// applyOverlay merges the overlay of the selected environment into v,
+// which holds the config read from source, and returns the resulting
+// layers. Only local files (local set) have overlays; a missing overlay
+// is not an error.
+func applyOverlay(v *viper.Viper, source string, local bool) (Layers, error) {
+	layers := Layers{Sources: map[string]string{}}
+	env := envOverride
+	if env == "" {
+		env = v.GetString("app.env")
+	}
+	layers.Environment = env
+
+	var overlay map[string]interface{}
+	if source != "" {
+		if !local {
+			source = withoutCredentials(source)
+		}
+		layers.Files = append(layers.Files, source)
+		if local && env != "" {
+			overlayPath := OverlayPath(source, env)
+			data, err := os.ReadFile(overlayPath)
+			switch {
+			case err == nil:
+				if overlay, err = decodeDocument(FormatOf(overlayPath), data); err != nil {
+					return layers, fmt.Errorf("parse %s: %w", overlayPath, err)
+				}
+				if err := v.MergeConfigMap(overlay); err != nil {
+					return layers, fmt.Errorf("merge %s: %w", overlayPath, err)
+				}
+				layers.Files = append(layers.Files, overlayPath)
+			case !os.IsNotExist(err):
+				return layers, err
+			}
+		}
+	}
+	if envOverride != "" {
+		v.Set("app.env", envOverride)
+	}
+
+	overlayKeys := map[string]bool{}
+	flattenKeys("", overlay, overlayKeys)
+	for _, key := range v.AllKeys() {
+		envVar := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		switch {
+		case key == "app.env" && envOverride != "":
+			layers.Sources[key] = SourceFlag
+		case os.Getenv(envVar) != "":
+			layers.Sources[key] = sourceEnv + envVar
+		case overlayKeys[key]:
+			layers.Sources[key] = layers.Files[len(layers.Files)-1]
+		case v.InConfig(key) && len(layers.Files) > 0:
+			layers.Sources[key] = layers.Files[0]
+		default:
+			layers.Sources[key] = SourceDefault
+		}
+	}
+	return layers, nil
+}
+
+// withoutCredentials strips the user info (a password or token) from a
+// config URL.
+func withoutCredentials(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.User == nil {
+		return source
+	}
+	u.User = nil
+	return u.String()
+}
+
+// flattenKeys adds the dotted, lower-case keys of the leaves of m to keys.
+func flattenKeys(prefix string, m map[string]interface{}, keys map[string]bool) {
+	for k, value := range m {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenKeys(key, nested, keys)
+			continue
+		}
+		keys[key] = true
+	}
+}
+
+// EffectiveKey is one leaf of the effective configuration.
+type EffectiveKey struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// EffectiveKeys flattens cfg, redacted, into its leaves sorted by key,
+// each with the layer its value came from.
+func EffectiveKeys(cfg *Config, layers Layers) []EffectiveKey {
+	var keys []EffectiveKey
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if m, ok := value.(map[string]interface{}); ok && (len(m) > 0 || prefix == "") {
+			for k, item := range m {
+				key := k
+				if prefix != "" {
+					key = prefix + "." + k
+				}
+				walk(key, item)
+			}
+			return
+		}
+		keys = append(keys, EffectiveKey{Key: prefix, Value: value, Source: layers.Source(prefix)})
+	}
+	walk("", Redact(cfg))
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// SchemaDraft is the JSON Schema dialect of Schema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema of the config file, generated from Config:
+// keys are the mapstructure names, unknown keys are rejected, defaults come
+// from the built-in defaults and secret:"true" fields are writeOnly. Editors
+// use it to validate config.yaml, config.json or config.toml as it is typed.
+func Schema() map[string]interface{} {
+	defaults := viper.New()
+	setDefaultValues(defaults)
+	g := schemaGenerator{defaults: defaults, visiting: make(map[reflect.Type]bool)}
+	schema := g.schema(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = SchemaDraft
+	schema["title"] = "stackyrd configuration"
+	return schema
+}
+
+// SectionSchema returns the part of Schema describing the section at a
+// dotted path like "postgres.connections.0".
+func SectionSchema(path string) (map[string]interface{}, error) {
+	schema := Schema()
+	delete(schema, "$schema")
+	delete(schema, "title")
+	for _, segment := range splitSectionPath(path) {
+		var next map[string]interface{}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			next, _ = properties[segment].(map[string]interface{})
+		}
+		if next == nil {
+			if items, ok := schema["items"].(map[string]interface{}); ok {
+				if _, err := strconv.Atoi(segment); err == nil {
+					next = items
+				}
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				next = additional
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, path)
+		}
+		schema = next
+	}
+	return schema, nil
+}
+
+type schemaGenerator struct {
+	defaults *viper.Viper
+	visiting map[reflect.Type]bool // guards against recursive types
+}
+
+// schema describes t, found under the dotted key (empty below lists and
+// maps, whose entries have no defaults).
+func (g schemaGenerator) schema(t reflect.Type, key string) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := map[string]interface{}{}
+	switch t.Kind() {
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			schema["type"] = "string"
+			break
+		}
+		schema["type"] = "array"
+		schema["items"] = g.schema(t.Elem(), "")
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = g.schema(t.Elem(), "")
+	case reflect.Struct:
+		if g.visiting[t] {
+			return schema
+		}
+		g.visiting[t] = true
+		defer delete(g.visiting, t)
+		properties := map[string]interface{}{}
+		g.fields(t, key, properties)
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	}
+	if key != "" && schema["type"] != "object" {
+		if value := g.defaults.Get(key); value != nil {
+			schema["default"] = value
+		}
+	}
+	return schema
+}
+
+// fields adds the properties of the fields of struct t under key to
+// properties. Fields sharing a key (e.g. the single and multi-connection
+// postgres sections) are merged; squashed structs are inlined.
+func (g schemaGenerator) fields(t reflect.Type, key string, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "squash") || (field.Anonymous && name == "") {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, key, properties)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		child := name
+		if key != "" {
+			child = key + "." + name
+		}
+		schema := g.schema(field.Type, child)
+		if field.Tag.Get(secretTag) == "true" {
+			schema["writeOnly"] = true
+			delete(schema, "default")
+		}
+		if existing, ok := properties[name].(map[string]interface{}); ok {
+			schema = mergeSchemas(existing, schema)
+		}
+		properties[name] = schema
+	}
+}
+
+// mergeSchemas combines the schemas of two fields sharing a key: objects
+// get the union of their properties, anything else either schema.
+func mergeSchemas(a, b map[string]interface{}) map[string]interface{} {
+	if reflect.DeepEqual(a, b) {
+		return a
+	}
+	// Maps (e.g. the services toggles and their long form) take entries
+	// of either schema
+	aa, aok := a["additionalProperties"].(map[string]interface{})
+	ba, bok := b["additionalProperties"].(map[string]interface{})
+	if aok && bok {
+		out := make(map[string]interface{}, len(a))
+		for k, v := range a {
+			out[k] = v
+		}
+		out["additionalProperties"] = mergeSchemas(aa, ba)
+		return out
+	}
+	ap, aok := a["properties"].(map[string]interface{})
+	bp, bok := b["properties"].(map[string]interface{})
+	if !aok || !bok {
+		return map[string]interface{}{"anyOf": []interface{}{a, b}}
+	}
+	merged := make(map[string]interface{}, len(ap)+len(bp))
+	for k, v := range ap {
+		merged[k] = v
+	}
+	for k, v := range bp {
+		if existing, ok := merged[k].(map[string]interface{}); ok {
+			v = mergeSchemas(existing, v.(map[string]interface{}))
+		}
+		merged[k] = v
+	}
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	out["properties"] = merged
+	return out
+}
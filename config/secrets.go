@@ -0,0 +1,196 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/vault"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// ErrUnresolvedSecret is returned when a ${VAR} or vault:// reference in a
+// config value cannot be resolved.
+var ErrUnresolvedSecret = errors.New("unresolved secret reference")
+
+// VaultScheme prefixes config values read from Vault:
+// vault://<path>#<key>, path being the API path such as secret/data/app.
+const VaultScheme = "vault://"
+
+// envRef matches ${VAR} and ${VAR:-default}; $${ escapes a literal ${.
+var envRef = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// secretResolver resolves the references and encrypted values of one
+// load, reading each Vault path and the master key once.
+type secretResolver struct {
+	vault   VaultConfig
+	client  *vault.Client
+	secrets map[string]*vault.Secret
+	key     []byte
+}
+
+func newSecretResolver(v *viper.Viper) (*secretResolver, error) {
+	r := &secretResolver{}
+	var err error
+	// The Vault settings themselves only take environment references
+	if r.vault.Address, err = expandEnv(v.GetString("vault.address")); err != nil {
+		return nil, err
+	}
+	if r.vault.Token, err = expandEnv(v.GetString("vault.token")); err != nil {
+		return nil, err
+	}
+	if r.vault.Namespace, err = expandEnv(v.GetString("vault.namespace")); err != nil {
+		return nil, err
+	}
+	r.vault.Timeout = v.GetInt("vault.timeout")
+	r.vault = r.vault.withEnvDefaults()
+	return r, nil
+}
+
+// withEnvDefaults fills the address and token from VAULT_ADDR and
+// VAULT_TOKEN, as the Vault CLI does.
+func (c VaultConfig) withEnvDefaults() VaultConfig {
+	if c.Address == "" {
+		c.Address = os.Getenv("VAULT_ADDR")
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("VAULT_TOKEN")
+	}
+	return c
+}
+
+// decodeHook resolves every string decoded into the Config.
+func (r *secretResolver) decodeHook(from, _ reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	return r.resolve(reflect.ValueOf(data).String())
+}
+
+// resolve decrypts an ENC[...] value, or expands environment references
+// in value and, when the result is a vault:// reference, replaces it with
+// the secret.
+func (r *secretResolver) resolve(value string) (string, error) {
+	if IsEncryptedValue(value) {
+		key, err := r.configKey()
+		if err != nil {
+			return "", err
+		}
+		return DecryptValue(key, value)
+	}
+	if !strings.Contains(value, "${") && !strings.HasPrefix(value, VaultScheme) {
+		return value, nil
+	}
+	value, err := expandEnv(value)
+	if err != nil || !strings.HasPrefix(value, VaultScheme) {
+		return value, err
+	}
+	return r.vaultValue(value)
+}
+
+// vaultValue reads a vault://<path>#<key> reference.
+func (r *secretResolver) vaultValue(value string) (string, error) {
+	path, key, ok := strings.Cut(strings.TrimPrefix(value, VaultScheme), "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("%w: %s: expected vault://<path>#<key>", ErrUnresolvedSecret, value)
+	}
+	secret, err := r.read(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrUnresolvedSecret, value, err)
+	}
+	v, ok := secret.Data[key]
+	if !ok || v == nil {
+		return "", fmt.Errorf("%w: %s: no key %q", ErrUnresolvedSecret, value, key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func (r *secretResolver) read(path string) (*vault.Secret, error) {
+	if secret, ok := r.secrets[path]; ok {
+		return secret, nil
+	}
+	if r.secrets == nil {
+		r.secrets = make(map[string]*vault.Secret)
+	}
+	if r.client == nil {
+		if r.vault.Address == "" {
+			return nil, errors.New("vault.address (or VAULT_ADDR) is not set")
+		}
+		r.client = vault.New(r.vault.Address, r.vault.Token, r.vault.Namespace, time.Duration(r.vault.Timeout)*time.Second)
+	}
+	secret, err := r.client.Read(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	r.secrets[path] = secret
+	return secret, nil
+}
+
+// expandEnv replaces ${VAR} with the variable and ${VAR:-default} with
+// the default when VAR is unset or empty. An unset ${VAR} is an error
+// rather than an empty password.
+func expandEnv(value string) (string, error) {
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := envRef.FindStringSubmatch(ref)
+		if v := os.Getenv(m[1]); v != "" {
+			return v
+		}
+		if strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		if _, set := os.LookupEnv(m[1]); !set {
+			missing = append(missing, m[1])
+		}
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrUnresolvedSecret, strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// decode unmarshals v into a Config, resolving ${VAR} and vault://
+// references in string values, and normalizes it.
+func decode(v *viper.Viper) (*Config, error) {
+	secrets, err := newSecretResolver(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWith(v, secrets.decodeHook)
+}
+
+// decodeUnresolved unmarshals v like decode but keeps ${VAR}, vault://
+// and ENC[...] values as written, without reading Vault or the master key.
+func decodeUnresolved(v *viper.Viper) (*Config, error) {
+	return decodeWith(v)
+}
+
+func decodeWith(v *viper.Viper, hooks ...mapstructure.DecodeHookFunc) (*Config, error) {
+	var cfg Config
+	hook := mapstructure.ComposeDecodeHookFunc(append(hooks,
+		serviceEntryHook,
+		// viper's defaults, replaced by setting a hook
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)...)
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(hook)); err != nil {
+		return nil, err
+	}
+	cfg.Vault = cfg.Vault.withEnvDefaults()
+	normalize(&cfg)
+	return &cfg, nil
+}
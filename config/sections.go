@@ -0,0 +1,603 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrSectionNotFound is returned for paths absent from the config file.
+	ErrSectionNotFound = errors.New("config section not found")
+	// ErrVersionConflict is returned when a section changed since it was read.
+	ErrVersionConflict = errors.New("config section was modified since it was read")
+	// ErrInvalidSection wraps validation failures of a section update.
+	ErrInvalidSection = errors.New("invalid config section")
+)
+
+// SecretMask replaces secret values in sections returned by SectionFile.
+// Saving a section with a secret still masked keeps the stored value.
+const SecretMask = "********"
+
+// sectionFileMu serializes writes to config files across SectionFile values.
+var sectionFileMu sync.Mutex
+
+// SectionFile reads and updates sections of a config file, addressed by
+// dotted paths like "postgres.connections.0". Updates merge into the
+// section's node, so YAML comments and the key order of YAML and JSON files
+// are kept for everything that remains (TOML files are rewritten with
+// sorted keys and without comments), and check the section's version so
+// concurrent edits of other sections do not conflict.
+type SectionFile struct {
+	path    string
+	backups *BackupStore // nil keeps no backups
+}
+
+// NewSectionFile edits the config file at path.
+func NewSectionFile(path string) *SectionFile {
+	return &SectionFile{path: path}
+}
+
+// WithBackups makes every update back up the file to backups first.
+func (f *SectionFile) WithBackups(backups *BackupStore) *SectionFile {
+	f.backups = backups
+	return f
+}
+
+// Section is the value of a config section with secrets masked.
+type Section struct {
+	Path    string      `json:"path"`
+	Value   interface{} `json:"value"`
+	Version string      `json:"version"` // changes whenever the value does
+}
+
+// SectionInfo summarizes a top-level section.
+type SectionInfo struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Size    int    `json:"size"` // bytes of YAML
+}
+
+// Sections lists the top-level sections of the file in file order.
+func (f *SectionFile) Sections() ([]SectionInfo, error) {
+	doc, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	infos := make([]SectionInfo, 0, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		node := root.Content[i+1]
+		value, err := decodeNode(node)
+		if err != nil {
+			return nil, err
+		}
+		raw, _ := yaml.Marshal(node)
+		infos = append(infos, SectionInfo{
+			Path:    root.Content[i].Value,
+			Version: sectionVersion(value),
+			Size:    len(raw),
+		})
+	}
+	return infos, nil
+}
+
+// Get returns the section at path.
+func (f *SectionFile) Get(path string) (Section, error) {
+	doc, err := f.load()
+	if err != nil {
+		return Section{}, err
+	}
+	node, _, err := findSection(doc.Content[0], splitSectionPath(path))
+	if err != nil {
+		return Section{}, err
+	}
+	return newSection(path, node)
+}
+
+// Put validates value as the section at path and writes it to the file.
+// version must match the section's current version unless empty; masked
+// secrets keep their stored value. A missing key is added when its parent
+// exists.
+func (f *SectionFile) Put(path string, value interface{}, version string) (Section, error) {
+	segments := splitSectionPath(path)
+	if len(segments) == 0 {
+		return Section{}, fmt.Errorf("%w: section path is required", ErrInvalidSection)
+	}
+
+	sectionFileMu.Lock()
+	defer sectionFileMu.Unlock()
+
+	doc, err := f.load()
+	if err != nil {
+		return Section{}, err
+	}
+	root := doc.Content[0]
+	node, parent, err := findSection(root, segments)
+	switch {
+	case errors.Is(err, ErrSectionNotFound) && parent != nil && parent.Kind == yaml.MappingNode && len(node.Content) == 0:
+		// Only the last key is missing: add it to its parent
+		if version != "" {
+			return Section{}, ErrVersionConflict
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: segments[len(segments)-1]}
+		node = &yaml.Node{}
+		parent.Content = append(parent.Content, key, node)
+	case err != nil:
+		return Section{}, err
+	default:
+		current, err := decodeNode(node)
+		if err != nil {
+			return Section{}, err
+		}
+		if version != "" && version != sectionVersion(current) {
+			return Section{}, ErrVersionConflict
+		}
+		value = restoreSecrets(segments[len(segments)-1], value, current)
+	}
+
+	if err := ValidateSection(path, value); err != nil {
+		return Section{}, err
+	}
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return Section{}, err
+	}
+	mergeNode(node, &encoded)
+
+	data, err := f.encode(doc)
+	if err != nil {
+		return Section{}, err
+	}
+	if err := validateDocument(FormatOf(f.path), data); err != nil {
+		return Section{}, fmt.Errorf("%w: %v", ErrInvalidSection, err)
+	}
+	if f.backups != nil {
+		if _, err := f.backups.createLocked(); err != nil {
+			return Section{}, fmt.Errorf("backing up the config file: %w", err)
+		}
+	}
+	if err := writeFileAtomic(f.path, data); err != nil {
+		return Section{}, err
+	}
+	return newSection(path, node)
+}
+
+// mergeNode updates dst to the value of src in place, so the comments and
+// quoting of the keys and values that remain are kept.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != src.Kind || dst.Kind != yaml.MappingNode && dst.Kind != yaml.SequenceNode {
+		head, line, foot, style := dst.HeadComment, dst.LineComment, dst.FootComment, dst.Style
+		keepStyle := dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && dst.Tag == src.Tag
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		if keepStyle {
+			dst.Style = style
+		}
+		return
+	}
+
+	if dst.Kind == yaml.SequenceNode {
+		for i, item := range src.Content {
+			if i < len(dst.Content) {
+				mergeNode(dst.Content[i], item)
+			} else {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+		dst.Content = dst.Content[:len(src.Content)]
+		return
+	}
+
+	values := make(map[string]*yaml.Node, len(src.Content)/2)
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		values[src.Content[i].Value] = src.Content[i+1]
+	}
+	content := make([]*yaml.Node, 0, len(src.Content))
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key := dst.Content[i]
+		value, ok := values[key.Value]
+		if !ok {
+			continue // removed
+		}
+		mergeNode(dst.Content[i+1], value)
+		content = append(content, key, dst.Content[i+1])
+		delete(values, key.Value)
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if _, added := values[src.Content[i].Value]; added {
+			content = append(content, src.Content[i], src.Content[i+1])
+		}
+	}
+	dst.Content = content
+}
+
+// spaceSections puts back the blank line before each top-level section
+// (and its comments) that the YAML encoder drops.
+func spaceSections(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	out := make([]string, 0, len(lines)+32)
+	for i, line := range lines {
+		topLevel := line != "" && line[0] != ' ' && line[0] != '-'
+		if topLevel && i > 0 {
+			prev := lines[i-1]
+			if prev != "" && (prev[0] == ' ' || prev[0] == '-' || line[0] != '#' && prev[0] != '#') {
+				out = append(out, "")
+			}
+		}
+		out = append(out, line)
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+func (f *SectionFile) load() (*yaml.Node, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if FormatOf(f.path) == FormatTOML {
+		// TOML is edited as the equivalent YAML tree
+		value, err := decodeDocument(FormatTOML, data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", f.path, err)
+		}
+		var root yaml.Node
+		if err := root.Encode(value); err != nil {
+			return nil, err
+		}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&root}}
+	} else if err := yaml.Unmarshal(data, &doc); err != nil {
+		// JSON parses as YAML
+		return nil, fmt.Errorf("parse %s: %w", f.path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a mapping", f.path)
+	}
+	return &doc, nil
+}
+
+// encode writes doc in the format of the file.
+func (f *SectionFile) encode(doc *yaml.Node) ([]byte, error) {
+	switch FormatOf(f.path) {
+	case FormatJSON:
+		var out bytes.Buffer
+		if err := encodeNodeJSON(&out, doc, ""); err != nil {
+			return nil, err
+		}
+		out.WriteString("\n")
+		return out.Bytes(), nil
+	case FormatTOML:
+		value, err := decodeNode(doc.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		return toml.Marshal(value)
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	return spaceSections(out.Bytes()), nil
+}
+
+func newSection(path string, node *yaml.Node) (Section, error) {
+	value, err := decodeNode(node)
+	if err != nil {
+		return Section{}, err
+	}
+	segments := splitSectionPath(path)
+	return Section{
+		Path:    strings.Join(segments, "."),
+		Value:   maskSecrets(segments[len(segments)-1], value),
+		Version: sectionVersion(value),
+	}, nil
+}
+
+// splitSectionPath accepts dots or slashes as separators.
+func splitSectionPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '.' || r == '/' })
+}
+
+// findSection walks segments from root. On ErrSectionNotFound the parent
+// of the missing segment is returned, with an empty node when only the
+// last segment was missing.
+func findSection(root *yaml.Node, segments []string) (node, parent *yaml.Node, err error) {
+	if len(segments) == 0 {
+		return nil, nil, ErrSectionNotFound
+	}
+	node = root
+	for i, segment := range segments {
+		parent = node
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == segment {
+					next = node.Content[j+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(node.Content) {
+				next = node.Content[index]
+			}
+		}
+		if next == nil {
+			if i < len(segments)-1 {
+				parent = nil
+			}
+			return &yaml.Node{}, parent, ErrSectionNotFound
+		}
+		node = next
+	}
+	return node, parent, nil
+}
+
+func decodeNode(node *yaml.Node) (interface{}, error) {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func sectionVersion(value interface{}) string {
+	data, _ := json.Marshal(value)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// isSecretKey reports whether a config key holds a credential, by name or
+// because a Config field with that key is tagged secret:"true".
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range []string{"password", "secret", "token", "api_key", "apikey", "private_key", "access_key", "authorization"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return taggedSecretKeys()[key]
+}
+
+// MaskSecrets returns a copy of a decoded JSON or YAML value with the
+// values of secret keys (passwords, tokens, API keys) and ENC[...] values
+// replaced by SecretMask, and URL passwords masked.
+func MaskSecrets(value interface{}) interface{} {
+	return maskSecrets("", value)
+}
+
+// maskSecrets returns a copy of value, found under key, with the scalar
+// values of secret keys replaced by SecretMask.
+func maskSecrets(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = maskSecrets(k, item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = maskSecrets(key, item)
+		}
+		return out
+	case nil:
+		return nil
+	default:
+		if isSecretKey(key) && fmt.Sprint(v) != "" {
+			return SecretMask
+		}
+		if s, ok := v.(string); ok {
+			if IsEncryptedValue(s) {
+				return SecretMask
+			}
+			return maskURLPassword(s)
+		}
+		return v
+	}
+}
+
+// restoreSecrets replaces values of value still equal to SecretMask with
+// the stored ones from current, so encrypted values stay encrypted.
+func restoreSecrets(key string, value, current interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		stored, _ := current.(map[string]interface{})
+		for k, item := range v {
+			v[k] = restoreSecrets(k, item, stored[k])
+		}
+	case []interface{}:
+		stored, _ := current.([]interface{})
+		for i, item := range v {
+			var old interface{}
+			if i < len(stored) {
+				old = stored[i]
+			}
+			v[i] = restoreSecrets(key, item, old)
+		}
+	case string:
+		stored, ok := current.(string)
+		if ok && v != stored && v == maskURLPassword(stored) {
+			// A URL whose password is still masked
+			return current
+		}
+		if v != SecretMask || current == nil {
+			break
+		}
+		if isSecretKey(key) || (ok && IsEncryptedValue(stored)) {
+			return current
+		}
+	}
+	return value
+}
+
+// ValidateSection checks that value decodes into the Config field at path
+// and has no keys that field does not know. Failures wrap
+// ErrInvalidSection.
+func ValidateSection(path string, value interface{}) error {
+	segments := splitSectionPath(path)
+	// Several fields can share a key (e.g. postgres): a section is valid
+	// when it decodes into all of them and each key is known to one
+	candidates := []reflect.Type{reflect.TypeOf(Config{})}
+	for _, segment := range segments {
+		var next []reflect.Type
+		for _, t := range candidates {
+			next = append(next, childTypes(t, segment)...)
+		}
+		if len(next) == 0 {
+			return fmt.Errorf("%w: unknown config section %q", ErrInvalidSection, strings.Join(segments, "."))
+		}
+		candidates = next
+	}
+
+	var unused map[string]int
+	voters := 0
+	for _, t := range candidates {
+		var md mapstructure.Metadata
+		target := reflect.New(t)
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				serviceEntryHook,
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+			),
+			WeaklyTypedInput: true,
+			Metadata:         &md,
+			Result:           target.Interface(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := decoder.Decode(value); err != nil {
+			return fmt.Errorf("%w %s: %v", ErrInvalidSection, strings.Join(segments, "."), err)
+		}
+		// The services toggles take long-form entries whole; their keys are
+		// checked against ServiceSettings
+		if t == reflect.TypeOf(ServicesConfig{}) || t == reflect.TypeOf(ServiceToggle(false)) {
+			continue
+		}
+		voters++
+		if unused == nil {
+			unused = make(map[string]int)
+		}
+		for _, key := range md.Unused {
+			unused[key]++
+		}
+	}
+
+	var unknown []string
+	for key, count := range unused {
+		if count == voters {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w %s: unknown keys %s", ErrInvalidSection, strings.Join(segments, "."), strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// ValidateFile checks every top-level section of the config file at path
+// like ValidateSection, so unknown keys and values of the wrong type are
+// reported together rather than ignored at startup.
+func ValidateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeDocument(FormatOf(path), data)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidSection, path, err)
+	}
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		if err := ValidateSection(key, doc[key]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// childTypes returns the types a config key or index leads to from t.
+func childTypes(t reflect.Type, segment string) []reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		var types []reflect.Type
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			if strings.EqualFold(name, segment) {
+				types = append(types, field.Type)
+			}
+		}
+		return types
+	case reflect.Map:
+		return []reflect.Type{t.Elem()}
+	case reflect.Slice, reflect.Array:
+		if _, err := strconv.Atoi(segment); err == nil {
+			return []reflect.Type{t.Elem()}
+		}
+	case reflect.Interface:
+		return []reflect.Type{t}
+	}
+	return nil
+}
+
+// validateDocument checks that the whole file still loads.
+func validateDocument(format string, data []byte) error {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	var cfg Config
+	return v.Unmarshal(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		serviceEntryHook,
+		// viper's defaults, replaced by setting a hook
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,471 @@
+// Package alerting evaluates alert rules against runtime signals (CPU,
+// infrastructure connectivity, external services and the application log
+// stream) and sends notifications to the configured channels when a rule
+// starts or stops firing.
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+)
+
+// Rule types understood by the engine.
+const (
+	RuleCPU               = "cpu"
+	RuleInfraDisconnected = "infra_disconnected"
+	RuleExternalDown      = "external_down"
+	RuleErrorRate         = "error_rate"
+	RuleClockSkew         = "clock_skew"
+	RuleConfigDrift       = "config_drift"
+)
+
+// Alert states.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+const (
+	rulesBucket     = "alerting_rules"
+	maxHistory      = 200
+	notifyTimeout   = 10 * time.Second
+	defaultSeverity = "warning"
+)
+
+var (
+	ErrRuleNotFound = errors.New("alert rule not found")
+	ErrInvalidRule  = errors.New("invalid alert rule")
+)
+
+// Rule is an alert rule as managed by the engine and the monitoring API.
+type Rule struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Target    string   `json:"target,omitempty"`
+	Window    int      `json:"window,omitempty"`
+	For       int      `json:"for,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+	Channels  []string `json:"channels,omitempty"`
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
+// RuleFromConfig converts a configured rule.
+func RuleFromConfig(rc config.AlertRuleConfig) Rule {
+	return Rule{
+		Name:      rc.Name,
+		Type:      rc.Type,
+		Threshold: rc.Threshold,
+		Target:    rc.Target,
+		Window:    rc.Window,
+		For:       rc.For,
+		Severity:  rc.Severity,
+		Channels:  rc.Channels,
+		Disabled:  rc.Disabled,
+	}
+}
+
+// Alert is a notification about a rule changing state.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Type      string    `json:"type"`
+	Severity  string    `json:"severity"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	StartedAt time.Time `json:"started_at"`
+	Time      time.Time `json:"time"`
+}
+
+// ruleState tracks the condition of a single rule between evaluations.
+type ruleState struct {
+	pendingSince time.Time
+	firing       bool
+	firedAt      time.Time
+	lastMessage  string
+	lastError    string
+	lastEval     time.Time
+}
+
+// Engine periodically evaluates rules and dispatches notifications.
+type Engine struct {
+	config    config.AlertingConfig
+	logger    *logger.Logger
+	sources   Sources
+	store     *infrastructure.EmbeddedStore
+	notifiers map[string]Notifier
+
+	mu      sync.RWMutex
+	rules   map[string]Rule
+	states  map[string]*ruleState
+	history []Alert
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewEngine creates an alerting engine. When store is non-nil rules are
+// persisted there and configured rules only seed an empty store.
+func NewEngine(cfg config.AlertingConfig, sources Sources, store *infrastructure.EmbeddedStore, l *logger.Logger) (*Engine, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30
+	}
+
+	e := &Engine{
+		config:    cfg,
+		logger:    l,
+		sources:   sources,
+		store:     store,
+		notifiers: make(map[string]Notifier),
+		rules:     make(map[string]Rule),
+		states:    make(map[string]*ruleState),
+		stopChan:  make(chan struct{}),
+	}
+
+	for _, ch := range cfg.Channels {
+		n, err := NewNotifier(ch, sources.Mailer)
+		if err != nil {
+			return nil, fmt.Errorf("alert channel %q: %w", ch.Name, err)
+		}
+		e.notifiers[ch.Name] = n
+	}
+
+	if err := e.loadRules(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// loadRules reads persisted rules, seeding the store from config when it
+// holds none yet.
+func (e *Engine) loadRules() error {
+	if e.store != nil {
+		count, err := e.store.Count(rulesBucket)
+		if err != nil {
+			return fmt.Errorf("failed to read alert rules: %w", err)
+		}
+		if count > 0 {
+			return e.store.ForEachPrefix(rulesBucket, "", func(_, value []byte) error {
+				var rule Rule
+				if err := json.Unmarshal(value, &rule); err != nil {
+					return err
+				}
+				e.rules[rule.Name] = rule
+				return nil
+			})
+		}
+	}
+
+	for _, rc := range e.config.Rules {
+		if err := e.PutRule(RuleFromConfig(rc)); err != nil {
+			return fmt.Errorf("alert rule %q: %w", rc.Name, err)
+		}
+	}
+	return nil
+}
+
+// Start runs the evaluation loop in the background.
+func (e *Engine) Start() {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(time.Duration(e.config.Interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.Evaluate(context.Background())
+			case <-e.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the evaluation loop.
+func (e *Engine) Close() error {
+	e.stopOnce.Do(func() {
+		close(e.stopChan)
+		e.wg.Wait()
+	})
+	return nil
+}
+
+// Rules returns all rules sorted by name.
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	rules := make([]Rule, 0, len(e.rules))
+	for _, r := range e.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// GetRule returns a rule by name.
+func (e *Engine) GetRule(name string) (Rule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	r, ok := e.rules[name]
+	return r, ok
+}
+
+// PutRule creates or replaces a rule. Replacing a rule resets its state.
+func (e *Engine) PutRule(rule Rule) error {
+	if err := e.validate(rule); err != nil {
+		return err
+	}
+	if rule.Severity == "" {
+		rule.Severity = defaultSeverity
+	}
+
+	if e.store != nil {
+		if err := e.store.PutJSON(rulesBucket, rule.Name, rule); err != nil {
+			return fmt.Errorf("failed to persist alert rule: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	e.rules[rule.Name] = rule
+	delete(e.states, rule.Name)
+	e.mu.Unlock()
+	return nil
+}
+
+// DeleteRule removes a rule.
+func (e *Engine) DeleteRule(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.rules[name]; !ok {
+		return ErrRuleNotFound
+	}
+	if e.store != nil {
+		if err := e.store.Delete(rulesBucket, name); err != nil {
+			return fmt.Errorf("failed to delete alert rule: %w", err)
+		}
+	}
+	delete(e.rules, name)
+	delete(e.states, name)
+	return nil
+}
+
+func (e *Engine) validate(rule Rule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	switch rule.Type {
+	case RuleCPU, RuleErrorRate, RuleClockSkew:
+		if rule.Threshold <= 0 {
+			return fmt.Errorf("%w: %s rules need a positive threshold", ErrInvalidRule, rule.Type)
+		}
+	case RuleInfraDisconnected, RuleExternalDown, RuleConfigDrift:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidRule, rule.Type)
+	}
+	for _, ch := range rule.Channels {
+		if _, ok := e.notifiers[ch]; !ok {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidRule, ch)
+		}
+	}
+	return nil
+}
+
+// Evaluate checks every enabled rule once and sends notifications for
+// rules that started or stopped firing.
+func (e *Engine) Evaluate(ctx context.Context) {
+	now := time.Now()
+	var alerts []Alert
+
+	for _, rule := range e.Rules() {
+		if rule.Disabled {
+			continue
+		}
+		active, message, err := e.sources.evaluate(ctx, rule)
+
+		e.mu.Lock()
+		if _, ok := e.rules[rule.Name]; !ok {
+			// Deleted while evaluating
+			e.mu.Unlock()
+			continue
+		}
+		state, ok := e.states[rule.Name]
+		if !ok {
+			state = &ruleState{}
+			e.states[rule.Name] = state
+		}
+		state.lastEval = now
+		state.lastError = ""
+		if err != nil {
+			state.lastError = err.Error()
+			e.mu.Unlock()
+			e.logger.Debug("Alert rule evaluation failed", "rule", rule.Name, "error", err.Error())
+			continue
+		}
+
+		if active {
+			state.lastMessage = message
+			if state.pendingSince.IsZero() {
+				state.pendingSince = now
+			}
+			if !state.firing && now.Sub(state.pendingSince) >= time.Duration(rule.For)*time.Second {
+				state.firing = true
+				state.firedAt = now
+				alerts = append(alerts, e.recordLocked(rule, StatusFiring, message, now, now))
+			}
+		} else {
+			if state.firing {
+				alerts = append(alerts, e.recordLocked(rule, StatusResolved, "condition cleared: "+state.lastMessage, state.firedAt, now))
+			}
+			state.pendingSince = time.Time{}
+			state.firing = false
+			state.lastMessage = ""
+		}
+		e.mu.Unlock()
+	}
+
+	for _, alert := range alerts {
+		e.dispatch(ctx, alert)
+	}
+}
+
+// recordLocked appends an alert to the history. Callers hold e.mu.
+func (e *Engine) recordLocked(rule Rule, status, message string, startedAt, now time.Time) Alert {
+	alert := Alert{
+		Rule:      rule.Name,
+		Type:      rule.Type,
+		Severity:  rule.Severity,
+		Status:    status,
+		Message:   message,
+		StartedAt: startedAt,
+		Time:      now,
+	}
+	e.history = append(e.history, alert)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	return alert
+}
+
+// dispatch sends an alert to the rule's channels, or to every channel when
+// the rule names none.
+func (e *Engine) dispatch(ctx context.Context, alert Alert) {
+	if alert.Status == StatusFiring {
+		e.logger.Warn("Alert firing", "rule", alert.Rule, "severity", alert.Severity, "message", alert.Message)
+	} else {
+		e.logger.Info("Alert resolved", "rule", alert.Rule)
+	}
+
+	rule, _ := e.GetRule(alert.Rule)
+	channels := rule.Channels
+	if len(channels) == 0 {
+		for name := range e.notifiers {
+			channels = append(channels, name)
+		}
+	}
+
+	for _, name := range channels {
+		n, ok := e.notifiers[name]
+		if !ok {
+			continue
+		}
+		nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := n.Notify(nctx, alert); err != nil {
+			e.logger.Error("Failed to send alert notification", err, "rule", alert.Rule, "channel", name)
+		}
+		cancel()
+	}
+}
+
+// Firing returns the currently firing alerts.
+func (e *Engine) Firing() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var firing []Alert
+	for name, state := range e.states {
+		if !state.firing {
+			continue
+		}
+		rule := e.rules[name]
+		firing = append(firing, Alert{
+			Rule:      name,
+			Type:      rule.Type,
+			Severity:  rule.Severity,
+			Status:    StatusFiring,
+			Message:   state.lastMessage,
+			StartedAt: state.firedAt,
+			Time:      state.lastEval,
+		})
+	}
+	sort.Slice(firing, func(i, j int) bool { return firing[i].Rule < firing[j].Rule })
+	return firing
+}
+
+// History returns up to limit most recent alerts, newest first.
+func (e *Engine) History(limit int) []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if limit <= 0 || limit > len(e.history) {
+		limit = len(e.history)
+	}
+	out := make([]Alert, 0, limit)
+	for i := len(e.history) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, e.history[i])
+	}
+	return out
+}
+
+// RuleStatus returns the evaluation state of a rule for the API.
+func (e *Engine) RuleStatus(name string) map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	state, ok := e.states[name]
+	if !ok {
+		return map[string]interface{}{"state": "unknown"}
+	}
+	status := map[string]interface{}{
+		"state":     "ok",
+		"last_eval": state.lastEval,
+	}
+	switch {
+	case state.firing:
+		status["state"] = StatusFiring
+		status["since"] = state.firedAt
+	case !state.pendingSince.IsZero():
+		status["state"] = "pending"
+		status["since"] = state.pendingSince
+	}
+	if state.lastError != "" {
+		status["error"] = state.lastError
+	}
+	return status
+}
+
+// GetStatus returns a summary of the engine.
+func (e *Engine) GetStatus() map[string]interface{} {
+	channels := make([]string, 0, len(e.notifiers))
+	for name := range e.notifiers {
+		channels = append(channels, name)
+	}
+	sort.Strings(channels)
+
+	e.mu.RLock()
+	rules := len(e.rules)
+	e.mu.RUnlock()
+
+	return map[string]interface{}{
+		"rules":    rules,
+		"firing":   len(e.Firing()),
+		"channels": channels,
+		"interval": e.config.Interval,
+	}
+}
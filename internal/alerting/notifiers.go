@@ -0,0 +1,173 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+)
+
+// Notifier delivers alerts to a channel.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Mailer sends plain text emails; the "mail" component implements it.
+type Mailer interface {
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+
+// NewNotifier creates the notifier for a configured channel. "mail"
+// channels send through the mailer returned by mailer, which may be nil.
+func NewNotifier(ch config.AlertChannelConfig, mailer func() Mailer) (Notifier, error) {
+	if ch.Name == "" {
+		return nil, fmt.Errorf("channel name is required")
+	}
+	switch ch.Type {
+	case "webhook":
+		if ch.URL == "" {
+			return nil, fmt.Errorf("webhook channel needs a url")
+		}
+		return &WebhookNotifier{URL: ch.URL, Client: &http.Client{Timeout: notifyTimeout}}, nil
+	case "slack":
+		if ch.URL == "" {
+			return nil, fmt.Errorf("slack channel needs a url")
+		}
+		return &SlackNotifier{URL: ch.URL, Client: &http.Client{Timeout: notifyTimeout}}, nil
+	case "smtp":
+		if ch.SMTPHost == "" || ch.From == "" || len(ch.To) == 0 {
+			return nil, fmt.Errorf("smtp channel needs smtp_host, from and to")
+		}
+		port := ch.SMTPPort
+		if port == 0 {
+			port = 25
+		}
+		return &SMTPNotifier{
+			Addr:     net.JoinHostPort(ch.SMTPHost, strconv.Itoa(port)),
+			Host:     ch.SMTPHost,
+			Username: ch.Username,
+			Password: ch.Password,
+			From:     ch.From,
+			To:       ch.To,
+		}, nil
+	case "mail":
+		if len(ch.To) == 0 {
+			return nil, fmt.Errorf("mail channel needs to")
+		}
+		return &MailNotifier{Mailer: mailer, To: ch.To}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", ch.Type)
+	}
+}
+
+// WebhookNotifier POSTs the alert as JSON.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.Client, n.URL, alert)
+}
+
+// SlackNotifier posts to a Slack incoming webhook.
+type SlackNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.Client, n.URL, map[string]string{"text": formatText(alert)})
+}
+
+// SMTPNotifier sends a plain text email.
+type SMTPNotifier struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject(alert))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(formatText(alert))
+	msg.WriteString("\r\n")
+
+	// net/smtp has no context support; run it so ctx bounds the wait.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(n.Addr, auth, n.From, n.To, []byte(msg.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MailNotifier sends a plain text email through the mail component.
+type MailNotifier struct {
+	Mailer func() Mailer
+	To     []string
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, alert Alert) error {
+	var mailer Mailer
+	if n.Mailer != nil {
+		mailer = n.Mailer()
+	}
+	if mailer == nil {
+		return fmt.Errorf("mail is not configured")
+	}
+	return mailer.Send(ctx, n.To, subject(alert), formatText(alert))
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("notification rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func subject(alert Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(alert.Status), alert.Severity, alert.Rule)
+}
+
+func formatText(alert Alert) string {
+	return subject(alert) + "\n" + alert.Message
+}
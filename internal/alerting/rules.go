@@ -0,0 +1,185 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/sysinfo"
+)
+
+const (
+	defaultErrorWindow = 300 // seconds
+	externalTimeout    = 5 * time.Second
+)
+
+// Sources provides the signals rules are evaluated against. Nil fields
+// make the corresponding rule types report an evaluation error.
+type Sources struct {
+	// CPUPercent returns the current system CPU usage.
+	CPUPercent func() (float64, error)
+	// Components returns GetStatus of every infrastructure component.
+	Components func() map[string]map[string]interface{}
+	// External lists the external services probed by external_down rules.
+	External []config.ExternalService
+	// HTTPClient is used for external probes (defaults to a 5s timeout client).
+	HTTPClient *http.Client
+	// Logs is the application log stream used by error_rate rules.
+	Logs *logger.LogBroadcaster
+	// ClockSkew returns the last measured skew of the local clock.
+	ClockSkew func() (time.Duration, error)
+	// ConfigDrift returns the fingerprints of the running configuration
+	// and of the config file as last read.
+	ConfigDrift func() (running, file string, err error)
+	// Mailer returns the mail component "mail" channels send through, nil
+	// when mail is not configured.
+	Mailer func() Mailer
+}
+
+// SystemCPUPercent reads CPU usage via sysinfo. It fails where the
+// platform does not report CPU usage, rather than reading as idle.
+func SystemCPUPercent() (float64, error) {
+	stats := sysinfo.Default().Collect()
+	if !stats.Available[sysinfo.MetricCPU] {
+		return 0, fmt.Errorf("cpu usage unavailable: %s", stats.Errors[sysinfo.MetricCPU])
+	}
+	return stats.CPU.Percent, nil
+}
+
+// evaluate reports whether the rule condition currently holds, with a
+// human readable description of the condition.
+func (s Sources) evaluate(ctx context.Context, rule Rule) (bool, string, error) {
+	switch rule.Type {
+	case RuleCPU:
+		return s.evaluateCPU(rule)
+	case RuleInfraDisconnected:
+		return s.evaluateInfra(rule)
+	case RuleExternalDown:
+		return s.evaluateExternal(ctx, rule)
+	case RuleErrorRate:
+		return s.evaluateErrorRate(rule)
+	case RuleClockSkew:
+		return s.evaluateClockSkew(rule)
+	case RuleConfigDrift:
+		return s.evaluateConfigDrift()
+	default:
+		return false, "", fmt.Errorf("unknown rule type %q", rule.Type)
+	}
+}
+
+func (s Sources) evaluateCPU(rule Rule) (bool, string, error) {
+	if s.CPUPercent == nil {
+		return false, "", fmt.Errorf("cpu source not available")
+	}
+	cpu, err := s.CPUPercent()
+	if err != nil {
+		return false, "", err
+	}
+	return cpu > rule.Threshold, fmt.Sprintf("CPU usage %.1f%% above %.1f%%", cpu, rule.Threshold), nil
+}
+
+func (s Sources) evaluateInfra(rule Rule) (bool, string, error) {
+	if s.Components == nil {
+		return false, "", fmt.Errorf("component source not available")
+	}
+	var down []string
+	for name, status := range s.Components() {
+		if rule.Target != "" && name != rule.Target {
+			continue
+		}
+		if connected, ok := status["connected"].(bool); ok && !connected {
+			down = append(down, name)
+		}
+	}
+	sort.Strings(down)
+	return len(down) > 0, "Infrastructure disconnected: " + strings.Join(down, ", "), nil
+}
+
+func (s Sources) evaluateExternal(ctx context.Context, rule Rule) (bool, string, error) {
+	client := s.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: externalTimeout}
+	}
+
+	var down []string
+	checked := 0
+	for _, svc := range s.External {
+		if rule.Target != "" && svc.Name != rule.Target {
+			continue
+		}
+		checked++
+		if err := probe(ctx, client, svc.URL); err != nil {
+			down = append(down, fmt.Sprintf("%s (%v)", svc.Name, err))
+		}
+	}
+	if checked == 0 {
+		return false, "", fmt.Errorf("no external service matches %q", rule.Target)
+	}
+	return len(down) > 0, "External service down: " + strings.Join(down, ", "), nil
+}
+
+// probe treats transport errors and 5xx responses as down.
+func probe(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// evaluateClockSkew fires when the absolute skew exceeds Threshold seconds.
+func (s Sources) evaluateClockSkew(rule Rule) (bool, string, error) {
+	if s.ClockSkew == nil {
+		return false, "", fmt.Errorf("clock source not available")
+	}
+	skew, err := s.ClockSkew()
+	if err != nil {
+		return false, "", err
+	}
+	return skew.Abs().Seconds() > rule.Threshold,
+		fmt.Sprintf("Clock skew %s above %gs", skew.Round(time.Millisecond), rule.Threshold), nil
+}
+
+// evaluateConfigDrift fires while the config file differs from the running
+// configuration.
+func (s Sources) evaluateConfigDrift() (bool, string, error) {
+	if s.ConfigDrift == nil {
+		return false, "", fmt.Errorf("config drift source not available")
+	}
+	running, file, err := s.ConfigDrift()
+	if err != nil {
+		return false, "", err
+	}
+	return file != running,
+		fmt.Sprintf("Config file (%s) differs from the running configuration (%s); the changes apply on the next restart", file, running), nil
+}
+
+func (s Sources) evaluateErrorRate(rule Rule) (bool, string, error) {
+	if s.Logs == nil {
+		return false, "", fmt.Errorf("log source not available")
+	}
+	window := rule.Window
+	if window <= 0 {
+		window = defaultErrorWindow
+	}
+	errorsCount, total := s.Logs.CountSince("error", time.Now().Add(-time.Duration(window)*time.Second))
+	if total == 0 {
+		return false, "", nil
+	}
+	rate := float64(errorsCount) / float64(total) * 100
+	return rate > rule.Threshold, fmt.Sprintf("Error rate %.1f%% (%d of %d log lines in %ds) above %.1f%%",
+		rate, errorsCount, total, window, rule.Threshold), nil
+}
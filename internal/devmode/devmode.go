@@ -0,0 +1,167 @@
+// Package devmode watches the config file and content directories during
+// development so changes take effect without a manual restart.
+package devmode
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher reports debounced changes to config files and to everything
+// below the watched directories.
+type Watcher struct {
+	fs          *fsnotify.Watcher
+	configFiles map[string]bool
+	contentDirs map[string]bool
+	debounce    time.Duration
+	logger      *logger.Logger
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewWatcher watches configFiles and dirs (recursively). Missing
+// directories are skipped; config files are watched through their parent
+// directory so editors that replace the file are handled.
+func NewWatcher(configFiles, dirs []string, debounce time.Duration, l *logger.Logger) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		fs:          fsw,
+		configFiles: make(map[string]bool),
+		contentDirs: make(map[string]bool),
+		debounce:    debounce,
+		logger:      l,
+		done:        make(chan struct{}),
+	}
+	if w.debounce <= 0 {
+		w.debounce = 500 * time.Millisecond
+	}
+
+	for _, file := range configFiles {
+		if file == "" {
+			continue
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		w.configFiles[abs] = true
+		if err := fsw.Add(filepath.Dir(abs)); err != nil {
+			l.Warn("Dev mode cannot watch config", "path", abs, "error", err.Error())
+		}
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			l.Debug("Dev mode watch directory not found", "path", dir)
+			continue
+		}
+		w.addTree(dir)
+	}
+	return w, nil
+}
+
+// addTree watches dir and all its subdirectories.
+func (w *Watcher) addTree(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if err := w.fs.Add(path); err != nil {
+				w.logger.Warn("Dev mode cannot watch directory", "path", path, "error", err.Error())
+				return nil
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				w.contentDirs[abs] = true
+			}
+		}
+		return nil
+	})
+}
+
+// Start delivers changes until Close: onConfig for changed config files,
+// onContent for anything else. Each callback receives the changed paths of
+// one debounce window.
+func (w *Watcher) Start(onConfig, onContent func(paths []string)) {
+	go w.run(onConfig, onContent)
+}
+
+func (w *Watcher) run(onConfig, onContent func(paths []string)) {
+	configChanged := make(map[string]bool)
+	contentChanged := make(map[string]bool)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("Dev mode watcher error", "error", err.Error())
+		case ev, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			abs, _ := filepath.Abs(ev.Name)
+			switch {
+			case w.configFiles[abs]:
+				configChanged[abs] = true
+			case w.contentDirs[filepath.Dir(abs)]:
+				if ev.Op.Has(fsnotify.Create) {
+					if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+						w.addTree(ev.Name)
+					}
+				}
+				contentChanged[ev.Name] = true
+			default:
+				// Other files next to the config file
+				continue
+			}
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			if len(configChanged) > 0 && onConfig != nil {
+				onConfig(sortedKeys(configChanged))
+			} else if len(contentChanged) > 0 && onContent != nil {
+				onContent(sortedKeys(contentChanged))
+			}
+			clear(configChanged)
+			clear(contentChanged)
+		}
+	}
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.fs.Close()
+	})
+	return err
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
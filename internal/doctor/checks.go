@@ -0,0 +1,371 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/timesync"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// connectivityChecks checks every registered component that reports a
+// connection status and every configured external service.
+func (d *Doctor) connectivityChecks() []Check {
+	var checks []Check
+	if d.dependencies != nil {
+		all := d.dependencies.GetAll()
+		seen := make(map[interface{}]bool)
+		for _, name := range sortedNames(all) {
+			component, ok := all[name].(interface{ GetStatus() map[string]interface{} })
+			if !ok {
+				continue
+			}
+			// Aliases such as "postgres.default" or "messaging" point at
+			// components registered under their own name too
+			if reflect.TypeOf(component).Comparable() {
+				if seen[component] {
+					continue
+				}
+				seen[component] = true
+			}
+			checks = append(checks, Check{
+				Name:     name,
+				Category: CategoryConnectivity,
+				Run:      func(context.Context) Result { return componentResult(component.GetStatus()) },
+			})
+		}
+	}
+
+	for _, svc := range d.config.Monitoring.External.Services {
+		url := svc.URL
+		checks = append(checks, Check{
+			Name:     "external:" + svc.Name,
+			Category: CategoryConnectivity,
+			Run: func(ctx context.Context) Result {
+				return d.checkExternal(ctx, url)
+			},
+		})
+	}
+	return checks
+}
+
+func componentResult(status map[string]interface{}) Result {
+	connected, reported := status["connected"].(bool)
+	switch {
+	case !reported:
+		return pass("No connection status reported", status)
+	case connected:
+		return pass("Connected", status)
+	}
+	msg := "Not connected"
+	if err, ok := status["error"].(string); ok && err != "" {
+		msg += ": " + err
+	}
+	return fail(msg, status)
+}
+
+// checkExternal treats transport errors and 5xx responses as down, like
+// the external_down alert rule.
+func (d *Doctor) checkExternal(ctx context.Context, url string) Result {
+	details := map[string]interface{}{"url": url}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fail("Invalid URL: "+err.Error(), details)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fail("Unreachable: "+err.Error(), details)
+	}
+	resp.Body.Close()
+	details["status_code"] = resp.StatusCode
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fail(fmt.Sprintf("Responded with status %d", resp.StatusCode), details)
+	}
+	return pass("Reachable", details)
+}
+
+// writablePaths returns the files the application writes to, by purpose.
+func (d *Doctor) writablePaths() map[string]string {
+	paths := make(map[string]string)
+	if d.config.Store.Enabled && d.config.Store.Path != "" {
+		paths["store"] = d.config.Store.Path
+	}
+	if d.config.Monitoring.LogHistory.Enabled && d.config.Monitoring.LogHistory.Path != "" {
+		paths["log_history"] = d.config.Monitoring.LogHistory.Path
+	}
+	if audit := d.config.Monitoring.Audit; audit.Enabled && audit.Backend != "store" && audit.Path != "" {
+		paths["audit"] = audit.Path
+	}
+	for _, sink := range d.config.Logging.Sinks {
+		if sink.Enabled && sink.Type == "file" && sink.Path != "" {
+			paths["log_sink:"+sink.Name] = sink.Path
+		}
+	}
+	return paths
+}
+
+// permissionChecks verifies that the directories of writablePaths accept
+// new files.
+func (d *Doctor) permissionChecks() []Check {
+	paths := d.writablePaths()
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		dir := filepath.Dir(paths[name])
+		checks = append(checks, Check{
+			Name:     "writable:" + name,
+			Category: CategoryPermissions,
+			Run:      func(context.Context) Result { return checkWritable(dir) },
+		})
+	}
+	return checks
+}
+
+func checkWritable(dir string) Result {
+	details := map[string]interface{}{"path": dir}
+	target := dir
+	// Missing directories are created on startup; check where they would be
+	for {
+		info, err := os.Stat(target)
+		if err == nil {
+			if !info.IsDir() {
+				return fail(target+" is not a directory", details)
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fail(err.Error(), details)
+		}
+		parent := filepath.Dir(target)
+		if parent == target {
+			return fail("No existing parent directory", details)
+		}
+		target = parent
+	}
+
+	f, err := os.CreateTemp(target, ".doctor-*")
+	if err != nil {
+		return fail("Not writable: "+err.Error(), details)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	if target != dir {
+		details["created_below"] = target
+		return warn("Directory does not exist yet and will be created", details)
+	}
+	return pass("Writable", details)
+}
+
+// diskChecks checks free space where the application writes, or the
+// working directory.
+func (d *Doctor) diskChecks() []Check {
+	dir := "."
+	if d.config.Store.Enabled && d.config.Store.Path != "" {
+		dir = filepath.Dir(d.config.Store.Path)
+	}
+	return []Check{{
+		Name:     "disk_space",
+		Category: CategoryDisk,
+		Run: func(ctx context.Context) Result {
+			return d.checkDisk(ctx, dir)
+		},
+	}}
+}
+
+func (d *Doctor) checkDisk(ctx context.Context, dir string) Result {
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	usage, err := disk.UsageWithContext(ctx, dir)
+	if err != nil {
+		return warn("Unable to read disk usage: "+err.Error(), map[string]interface{}{"path": dir})
+	}
+
+	freePercent := 100 - usage.UsedPercent
+	details := map[string]interface{}{
+		"path":         dir,
+		"total_mb":     usage.Total / 1024 / 1024,
+		"free_mb":      usage.Free / 1024 / 1024,
+		"free_percent": math.Round(freePercent*10) / 10,
+	}
+	min := d.config.Doctor.MinFreeDiskPercent
+	switch {
+	case freePercent < min:
+		return fail(fmt.Sprintf("Only %.1f%% free, below %.0f%%", freePercent, min), details)
+	case freePercent < 2*min:
+		return warn(fmt.Sprintf("%.1f%% free, approaching %.0f%%", freePercent, min), details)
+	}
+	return pass(fmt.Sprintf("%.1f%% free", freePercent), details)
+}
+
+// clockReference is the URL whose Date header the clock is compared with.
+func (d *Doctor) clockReference() string {
+	if d.config.Doctor.ClockReference != "" {
+		return d.config.Doctor.ClockReference
+	}
+	if services := d.config.Monitoring.External.Services; len(services) > 0 {
+		return services[0].URL
+	}
+	return ""
+}
+
+// checkClock reports the skew of the local clock. Unless a reference is
+// configured it uses the last measurement of the clock monitor, and
+// otherwise the Date header of the reference, correcting for half the round
+// trip. Date has a resolution of one second, so skew below that is not
+// reported.
+func (d *Doctor) checkClock(ctx context.Context) Result {
+	if d.config.Doctor.ClockReference == "" && d.dependencies != nil {
+		if monitor, ok := registry.GetTyped[*timesync.Monitor](d.dependencies, "clock"); ok {
+			if last, ok := monitor.Last(); ok && last.OK() {
+				return d.skewResult(last.Skew(), map[string]interface{}{
+					"reference":  last.Source,
+					"checked_at": last.CheckedAt,
+				})
+			}
+		}
+	}
+
+	url := d.clockReference()
+	if url == "" {
+		return skip("No clock reference configured")
+	}
+	details := map[string]interface{}{"reference": url}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return warn("Invalid clock reference: "+err.Error(), details)
+	}
+	sent := d.now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return warn("Clock reference unreachable: "+err.Error(), details)
+	}
+	resp.Body.Close()
+	received := d.now()
+
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return warn("Clock reference sent no usable Date header", details)
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(remote)
+	if skew.Abs() < time.Second {
+		skew = 0
+	}
+	return d.skewResult(skew, details)
+}
+
+func (d *Doctor) skewResult(skew time.Duration, details map[string]interface{}) Result {
+	details["skew_seconds"] = skew.Seconds()
+	max := time.Duration(d.config.Doctor.MaxClockSkew) * time.Second
+	switch {
+	case skew.Abs() > 10*max:
+		return fail(fmt.Sprintf("Clock is off by %s", skew.Round(time.Second)), details)
+	case skew.Abs() > max:
+		return warn(fmt.Sprintf("Clock is off by %s", skew.Round(time.Second)), details)
+	}
+	return pass("Clock in sync", details)
+}
+
+// checkConfig reports settings that are invalid or risky. Problems that
+// keep a feature from working fail the check; the rest warn.
+func (d *Doctor) checkConfig(context.Context) Result {
+	cfg := d.config
+	var errs, warnings []string
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Sprintf("server.port %q is not a valid port", cfg.Server.Port))
+	}
+	if cfg.Server.ServicesEndpoint != "" && !strings.HasPrefix(cfg.Server.ServicesEndpoint, "/") {
+		errs = append(errs, "server.services_endpoint must start with /")
+	}
+	if tls := cfg.Server.TLS; tls.Enabled {
+		if tls.Autocert.Enabled && len(tls.Autocert.Domains) == 0 {
+			errs = append(errs, "server.tls.autocert.domains is required with autocert")
+		}
+		if !tls.Autocert.Enabled && (tls.CertFile == "" || tls.KeyFile == "") {
+			errs = append(errs, "server.tls.cert_file and key_file are required unless autocert is enabled")
+		}
+	}
+	if cfg.GRPC.Enabled {
+		if port, err := strconv.Atoi(cfg.GRPC.Port); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Sprintf("grpc.port %q is not a valid port", cfg.GRPC.Port))
+		} else if cfg.GRPC.Port == cfg.Server.Port {
+			errs = append(errs, "grpc.port must differ from server.port")
+		}
+	}
+	if cfg.Server.ForceShutdownTimeout > 0 && cfg.Server.ShutdownTimeout >= cfg.Server.ForceShutdownTimeout {
+		warnings = append(warnings, "server.shutdown_timeout is not below server.force_shutdown_timeout, so draining can be cut short")
+	}
+
+	switch cfg.Auth.Type {
+	case "", "none":
+		if cfg.App.Env == "production" {
+			warnings = append(warnings, "auth.type is none in production")
+		}
+	case "mtls":
+		if !cfg.Server.TLS.Enabled {
+			errs = append(errs, "auth.type mtls requires server.tls.enabled")
+		}
+		if cfg.Auth.MTLS.CAFile == "" {
+			errs = append(errs, "auth.mtls.ca_file is required for auth.type mtls")
+		}
+	case "jwt", "apikey":
+		if cfg.Auth.Secret == "" {
+			errs = append(errs, "auth.secret is required for auth.type "+cfg.Auth.Type)
+		} else if len(cfg.Auth.Secret) < 32 {
+			warnings = append(warnings, "auth.secret is shorter than 32 characters")
+		}
+	}
+	if cfg.Encryption.Enabled && cfg.Encryption.Key == "" {
+		errs = append(errs, "encryption.key is required when encryption is enabled")
+	}
+	if cfg.App.Env == "production" && cfg.App.Debug {
+		warnings = append(warnings, "app.debug is enabled in production")
+	}
+
+	if cfg.Redis.Enabled && cfg.Redis.Address == "" {
+		errs = append(errs, "redis.address is required when redis is enabled")
+	}
+	if cfg.Kafka.Enabled && len(cfg.Kafka.Brokers) == 0 {
+		errs = append(errs, "kafka.brokers is required when kafka is enabled")
+	}
+	if cfg.Store.Enabled && cfg.Store.Path == "" {
+		errs = append(errs, "store.path is required when the store is enabled")
+	}
+	if cfg.Tracing.Enabled && (cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1) {
+		warnings = append(warnings, "tracing.sample_ratio is outside 0..1")
+	}
+
+	details := map[string]interface{}{"errors": errs, "warnings": warnings}
+	switch {
+	case len(errs) > 0:
+		return fail(fmt.Sprintf("%d config errors, %d warnings", len(errs), len(warnings)), details)
+	case len(warnings) > 0:
+		return warn(fmt.Sprintf("%d config warnings", len(warnings)), details)
+	}
+	return pass("Config looks sane", nil)
+}
@@ -0,0 +1,212 @@
+// Package doctor runs self-tests of the environment the application runs
+// in: connectivity of infrastructure and external services, permissions of
+// the directories it writes to, free disk space, clock skew and config
+// sanity. The report is machine-readable so the monitoring dashboard can
+// render it as an environment health page.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/registry"
+)
+
+// Check statuses, from best to worst.
+const (
+	StatusPass = "pass"
+	StatusSkip = "skip"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Check categories.
+const (
+	CategoryConnectivity = "connectivity"
+	CategoryPermissions  = "permissions"
+	CategoryDisk         = "disk"
+	CategoryClock        = "clock"
+	CategoryConfig       = "config"
+)
+
+const defaultTimeout = 5 * time.Second
+
+var severity = map[string]int{StatusPass: 0, StatusSkip: 0, StatusWarn: 1, StatusFail: 2}
+
+// Check is a single self-test.
+type Check struct {
+	Name     string
+	Category string
+	Run      func(ctx context.Context) Result
+}
+
+// Result is the outcome of a check. Checks fill Status, Message and
+// Details; Run sets the rest.
+type Result struct {
+	Name       string                 `json:"name"`
+	Category   string                 `json:"category"`
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+}
+
+// Report is the outcome of a doctor run. Status is the worst status of
+// its checks.
+type Report struct {
+	Status     string         `json:"status"`
+	Summary    map[string]int `json:"summary"`
+	Checks     []Result       `json:"checks"`
+	StartedAt  time.Time      `json:"started_at"`
+	DurationMS int64          `json:"duration_ms"`
+}
+
+func pass(msg string, details map[string]interface{}) Result {
+	return Result{Status: StatusPass, Message: msg, Details: details}
+}
+
+func warn(msg string, details map[string]interface{}) Result {
+	return Result{Status: StatusWarn, Message: msg, Details: details}
+}
+
+func fail(msg string, details map[string]interface{}) Result {
+	return Result{Status: StatusFail, Message: msg, Details: details}
+}
+
+func skip(msg string) Result {
+	return Result{Status: StatusSkip, Message: msg}
+}
+
+// Doctor builds and runs the checks for a configuration.
+type Doctor struct {
+	config       *config.Config
+	dependencies *registry.Dependencies
+	client       *http.Client
+	now          func() time.Time
+}
+
+// New creates a doctor for cfg. deps may be nil when no infrastructure is
+// running, e.g. before startup.
+func New(cfg *config.Config, deps *registry.Dependencies) *Doctor {
+	return &Doctor{
+		config:       cfg,
+		dependencies: deps,
+		client:       &http.Client{},
+		now:          time.Now,
+	}
+}
+
+// SetClock replaces the clock the clock skew check compares against.
+func (d *Doctor) SetClock(now func() time.Time) {
+	d.now = now
+}
+
+// timeout is the per-check timeout.
+func (d *Doctor) timeout() time.Duration {
+	if d.config.Doctor.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(d.config.Doctor.Timeout) * time.Second
+}
+
+// Checks returns every check, in report order.
+func (d *Doctor) Checks() []Check {
+	var checks []Check
+	checks = append(checks, d.connectivityChecks()...)
+	checks = append(checks, d.permissionChecks()...)
+	checks = append(checks, d.diskChecks()...)
+	checks = append(checks, Check{Name: "clock_skew", Category: CategoryClock, Run: d.checkClock})
+	checks = append(checks, Check{Name: "config", Category: CategoryConfig, Run: d.checkConfig})
+	return checks
+}
+
+// Run runs the checks of the given categories, or all of them.
+func (d *Doctor) Run(ctx context.Context, categories ...string) Report {
+	checks := d.Checks()
+	if len(categories) > 0 {
+		wanted := make(map[string]bool, len(categories))
+		for _, c := range categories {
+			wanted[c] = true
+		}
+		filtered := checks[:0]
+		for _, check := range checks {
+			if wanted[check.Category] {
+				filtered = append(filtered, check)
+			}
+		}
+		checks = filtered
+	}
+	return Run(ctx, checks, d.timeout())
+}
+
+// Run runs checks concurrently. A check still running after timeout fails;
+// its goroutine is abandoned.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	report := Report{
+		Status:    StatusPass,
+		Summary:   map[string]int{StatusPass: 0, StatusWarn: 0, StatusFail: 0, StatusSkip: 0},
+		Checks:    make([]Result, len(checks)),
+		StartedAt: time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, check, timeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, r := range report.Checks {
+		report.Summary[r.Status]++
+		if severity[r.Status] > severity[report.Status] {
+			report.Status = r.Status
+		}
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	return report
+}
+
+func runCheck(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fail(fmt.Sprintf("check panicked: %v", r), nil)
+			}
+		}()
+		done <- check.Run(ctx)
+	}()
+
+	var result Result
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = fail(fmt.Sprintf("check did not finish within %s", timeout), nil)
+	}
+	result.Name = check.Name
+	result.Category = check.Category
+	result.DurationMS = time.Since(start).Milliseconds()
+	return result
+}
+
+// sortedNames returns the keys of m in order.
+func sortedNames(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
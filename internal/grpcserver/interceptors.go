@@ -0,0 +1,144 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// interceptor wraps the call of method; it is adapted to both unary and
+// streaming RPCs so each concern is written once.
+type interceptor func(ctx context.Context, method string, next func(context.Context) error) error
+
+func unary(i interceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		err = i(ctx, info.FullMethod, func(ctx context.Context) error {
+			var herr error
+			resp, herr = handler(ctx, req)
+			return herr
+		})
+		return resp, err
+	}
+}
+
+func stream(i interceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return i(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+			return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// internalMethod reports whether method belongs to the health or
+// reflection services, which skip auth and request logging.
+func internalMethod(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.") || strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+// recovery turns a panicking handler into an Internal error.
+func recovery(l *logger.Logger) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				l.Ctx(ctx).Error("gRPC handler panicked", fmt.Errorf("%v", r), "method", method, "stack", string(debug.Stack()))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return next(ctx)
+	}
+}
+
+// metrics records every call in stats.
+func metrics(stats *Stats) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		start := time.Now()
+		done := stats.begin()
+		err := next(ctx)
+		done(method, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// requestLogger logs every call like the HTTP logger middleware: code,
+// method and latency, at a level matching the code.
+func requestLogger(l *logger.Logger) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		code := status.Code(err)
+		msg := "gRPC " + code.String() + " | " + method + " | " + time.Since(start).String()
+
+		rl := l.Ctx(ctx)
+		switch {
+		case internalMethod(method):
+			rl.Debug(msg)
+		case serverError(code):
+			rl.Error(msg, err)
+		case code != codes.OK:
+			rl.Warn(msg)
+		default:
+			rl.Info(msg)
+		}
+		return err
+	}
+}
+
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+type claimsKey struct{}
+
+// jwtAuth requires a valid bearer token in the authorization metadata, like
+// the HTTP jwt middleware. The claims are available through Claims.
+func jwtAuth(secretKey string) interceptor {
+	return func(ctx context.Context, method string, next func(context.Context) error) error {
+		if internalMethod(method) {
+			return next(ctx)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 || values[0] == "" {
+			return status.Error(codes.Unauthenticated, "missing or invalid token")
+		}
+		token := strings.TrimPrefix(values[0], "Bearer ")
+
+		claims, err := middleware.ParseToken(token, secretKey)
+		if err != nil {
+			return status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return next(context.WithValue(ctx, claimsKey{}, claims))
+	}
+}
+
+// Claims returns the JWT claims of the caller, or nil when the call was not
+// authenticated by a token.
+func Claims(ctx context.Context) *middleware.JWTClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*middleware.JWTClaims)
+	return claims
+}
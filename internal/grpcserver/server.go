@@ -0,0 +1,148 @@
+// Package grpcserver runs the optional gRPC server next to the HTTP API.
+// Services register through registry.RegisterGRPCService and share the
+// recovery, metrics, logging and auth interceptors; the standard health
+// service and, optionally, reflection are served as well.
+package grpcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server is the gRPC server and its registered services.
+type Server struct {
+	config    *config.Config
+	logger    *logger.Logger
+	grpc      *grpc.Server
+	health    *health.Server
+	stats     *Stats
+	services  []string
+	tls       bool
+	listener  net.Listener
+	startedAt time.Time
+	serving   atomic.Bool
+}
+
+// New creates the server and registers every enabled gRPC service.
+// tlsConfig, when set, is used for transport security.
+func New(cfg *config.Config, l *logger.Logger, deps *registry.Dependencies, tlsConfig *tls.Config) *Server {
+	s := &Server{
+		config: cfg,
+		logger: l,
+		health: health.NewServer(),
+		stats:  newStats(),
+		tls:    tlsConfig != nil,
+	}
+
+	chain := []interceptor{recovery(l), metrics(s.stats), requestLogger(l)}
+	if cfg.Auth.Type == "jwt" && cfg.Middleware.IsEnabled("jwt") {
+		chain = append(chain, jwtAuth(cfg.Auth.Secret))
+	}
+	var unaryChain []grpc.UnaryServerInterceptor
+	var streamChain []grpc.StreamServerInterceptor
+	for _, i := range chain {
+		unaryChain = append(unaryChain, unary(i))
+		streamChain = append(streamChain, stream(i))
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryChain...),
+		grpc.ChainStreamInterceptor(streamChain...),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.grpc = grpc.NewServer(opts...)
+
+	for _, service := range registry.AutoDiscoverGRPCServices(cfg, l, deps) {
+		service.RegisterGRPC(s.grpc)
+	}
+	for name := range s.grpc.GetServiceInfo() {
+		s.services = append(s.services, name)
+		s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	slices.Sort(s.services)
+
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	if cfg.GRPC.Reflection {
+		reflection.Register(s.grpc)
+	}
+	return s
+}
+
+// Start listens on grpc.port and serves in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", ":"+s.config.GRPC.Port)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.startedAt = time.Now()
+	s.serving.Store(true)
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			s.logger.Error("gRPC server stopped", err)
+		}
+		s.serving.Store(false)
+	}()
+	s.logger.Info("gRPC server started", "addr", listener.Addr().String(), "services", len(s.services), "tls", s.tls)
+	return nil
+}
+
+// Addr returns the address the server listens on, or "" before Start.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown reports NOT_SERVING to health checks, then waits for running
+// calls to finish until ctx expires and cancels the rest.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("gRPC drain timed out, cancelling remaining calls", "in_flight", s.stats.InFlight())
+		s.grpc.Stop()
+	}
+}
+
+// Stats returns the call statistics.
+func (s *Server) Stats() *Stats {
+	return s.stats
+}
+
+// Status reports the server state and call statistics for the monitoring
+// API.
+func (s *Server) Status() map[string]interface{} {
+	return map[string]interface{}{
+		"serving":    s.serving.Load(),
+		"addr":       s.Addr(),
+		"tls":        s.tls,
+		"reflection": s.config.GRPC.Reflection,
+		"services":   s.services,
+		"started_at": s.startedAt,
+		"in_flight":  s.stats.InFlight(),
+		"methods":    s.stats.Methods(),
+	}
+}
@@ -0,0 +1,92 @@
+package grpcserver
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// MethodStats summarises the calls of one gRPC method.
+type MethodStats struct {
+	Method       string         `json:"method"`
+	Calls        int64          `json:"calls"`
+	Errors       int64          `json:"errors"`
+	AvgLatencyMS float64        `json:"avg_latency_ms"`
+	MaxLatencyMS float64        `json:"max_latency_ms"`
+	Codes        map[string]int `json:"codes"`
+	LastCall     time.Time      `json:"last_call"`
+}
+
+type methodCounters struct {
+	calls, errors int64
+	total, max    time.Duration
+	codes         map[codes.Code]int
+	lastCall      time.Time
+}
+
+// Stats counts calls per method and the calls in flight.
+type Stats struct {
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	methods map[string]*methodCounters
+}
+
+func newStats() *Stats {
+	return &Stats{methods: make(map[string]*methodCounters)}
+}
+
+// begin marks a call as in flight; the returned func records its outcome.
+func (s *Stats) begin() func(method string, code codes.Code, latency time.Duration) {
+	s.inFlight.Add(1)
+	return func(method string, code codes.Code, latency time.Duration) {
+		s.inFlight.Add(-1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		m, ok := s.methods[method]
+		if !ok {
+			m = &methodCounters{codes: make(map[codes.Code]int)}
+			s.methods[method] = m
+		}
+		m.calls++
+		if code != codes.OK {
+			m.errors++
+		}
+		m.codes[code]++
+		m.total += latency
+		m.max = max(m.max, latency)
+		m.lastCall = time.Now()
+	}
+}
+
+// InFlight returns the number of calls being served.
+func (s *Stats) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Methods returns the stats of every called method, sorted by name.
+func (s *Stats) Methods() []MethodStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]MethodStats, 0, len(s.methods))
+	for method, m := range s.methods {
+		byCode := make(map[string]int, len(m.codes))
+		for code, n := range m.codes {
+			byCode[code.String()] = n
+		}
+		out = append(out, MethodStats{
+			Method:       method,
+			Calls:        m.calls,
+			Errors:       m.errors,
+			AvgLatencyMS: float64(m.total.Microseconds()) / float64(m.calls) / 1000,
+			MaxLatencyMS: float64(m.max.Microseconds()) / 1000,
+			Codes:        byCode,
+			LastCall:     m.lastCall,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out
+}
@@ -0,0 +1,248 @@
+// Package jobs runs long operations (exports, deletions, maintenance) in the
+// background and tracks their status, progress and result so API callers
+// can poll instead of holding a request open. Jobs are persisted in the
+// embedded store when it is available.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Job states.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	jobsBucket     = "jobs"
+	defaultWorkers = 2
+)
+
+// Job describes a background job.
+type Job struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Status     string            `json:"status"`
+	Progress   string            `json:"progress,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	Result     interface{}       `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has finished.
+func (j Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Func is the body of a job. report updates the job's progress message.
+type Func func(ctx context.Context, report func(progress string)) (interface{}, error)
+
+type jobIDKey struct{}
+
+// IDFromContext returns the ID of the job whose Func received ctx.
+func IDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// Manager executes jobs on a worker pool.
+type Manager struct {
+	logger *logger.Logger
+	store  *infrastructure.EmbeddedStore
+	pool   *infrastructure.WorkerPool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+// NewManager creates a job manager. Jobs left pending or running by a
+// previous process are marked failed.
+func NewManager(store *infrastructure.EmbeddedStore, workers int, l *logger.Logger) (*Manager, error) {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		logger: l,
+		store:  store,
+		pool:   infrastructure.NewNamedWorkerPool("jobs", workers),
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*Job),
+	}
+
+	if store != nil {
+		err := store.ForEachPrefix(jobsBucket, "", func(_, value []byte) error {
+			var job Job
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			m.jobs[job.ID] = &job
+			return nil
+		})
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to load jobs: %w", err)
+		}
+		// Writes happen outside the read transaction above
+		for _, job := range m.jobs {
+			if !job.Done() {
+				now := time.Now()
+				job.Status = StatusFailed
+				job.Error = "interrupted by restart"
+				job.FinishedAt = &now
+				m.persistLocked(job)
+			}
+		}
+	}
+
+	m.pool.Start()
+	return m, nil
+}
+
+// Submit queues fn and returns the pending job immediately.
+func (m *Manager) Submit(jobType string, params map[string]string, fn Func) Job {
+	job := &Job{
+		ID:        uuid.NewString(),
+		Type:      jobType,
+		Status:    StatusPending,
+		Params:    params,
+		CreatedAt: time.Now(),
+	}
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.persistLocked(job)
+	snapshot := *job
+	m.mu.Unlock()
+
+	// Submit blocks while the queue is full; never hold up the caller
+	go m.pool.SubmitTagged("job:"+jobType, func() error { return m.run(job, fn) })
+	return snapshot
+}
+
+// run executes a job and records its outcome, returning the job's error
+// for the pool's accounting.
+func (m *Manager) run(job *Job, fn Func) error {
+	m.update(job, func(j *Job) {
+		now := time.Now()
+		j.Status = StatusRunning
+		j.StartedAt = &now
+	})
+
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		ctx := context.WithValue(m.ctx, jobIDKey{}, job.ID)
+		return fn(ctx, func(progress string) {
+			m.update(job, func(j *Job) { j.Progress = progress })
+		})
+	}()
+
+	m.update(job, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Result = result
+		if err != nil {
+			j.Status = StatusFailed
+			j.Error = err.Error()
+		} else {
+			j.Status = StatusSucceeded
+		}
+	})
+
+	if err != nil {
+		m.logger.Error("Job failed", err, "job", job.ID, "type", job.Type)
+	} else {
+		m.logger.Info("Job completed", "job", job.ID, "type", job.Type)
+	}
+	return err
+}
+
+func (m *Manager) update(job *Job, fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(job)
+	m.persistLocked(job)
+}
+
+// persistLocked writes the job to the store. Callers hold m.mu.
+func (m *Manager) persistLocked(job *Job) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.PutJSON(jobsBucket, job.ID, job); err != nil {
+		m.logger.Warn("Failed to persist job", "job", job.ID, "error", err.Error())
+	}
+}
+
+// Get returns a job by ID.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns jobs of the given type (all types when empty), newest first.
+func (m *Manager) List(jobType string, limit int) []Job {
+	m.mu.RLock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if jobType == "" || job.Type == jobType {
+			jobs = append(jobs, *job)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs
+}
+
+// GetStatus returns job counts per state.
+func (m *Manager) GetStatus() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := map[string]int{}
+	for _, job := range m.jobs {
+		counts[job.Status]++
+	}
+	return map[string]interface{}{
+		"total":  len(m.jobs),
+		"counts": counts,
+	}
+}
+
+// Close cancels running jobs and stops the workers.
+func (m *Manager) Close() error {
+	m.cancel()
+	m.pool.Close()
+	return nil
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register per API key/user usage analytics middleware
+	RegisterMiddleware("api_usage", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		return APIUsage(usage.Default(), cfg.Monitoring.Access.APIKeys), nil
+	})
+}
+
+// APIUsage records every routed request against its principal: the name
+// of a configured API key (X-API-Key), else the user a JWT or client
+// certificate identified, else anonymous. Unknown API keys are recorded
+// under a digest of the key, never the key itself. The principal is read
+// after the handlers ran, so authentication middleware may run in any
+// order.
+func APIUsage(collector *usage.Collector, keys []config.AccessKeyConfig) gin.HandlerFunc {
+	names := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if k.Key != "" {
+			names[k.Key] = k.Name
+		}
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		typ, principal := requestPrincipal(c, names)
+		collector.Record(usage.Call{
+			Type:      typ,
+			Principal: principal,
+			Method:    c.Request.Method,
+			Route:     route,
+			Status:    c.Writer.Status(),
+			Latency:   time.Since(start),
+			Time:      start,
+		})
+	}
+}
+
+// requestPrincipal identifies who made the request.
+func requestPrincipal(c *gin.Context, names map[string]string) (typ, principal string) {
+	key := c.GetHeader("X-API-Key")
+	if name, ok := names[key]; ok && key != "" {
+		if name == "" {
+			name = keyDigest(key)
+		}
+		return usage.TypeAPIKey, name
+	}
+	if username := GetUsername(c); username != "" {
+		return usage.TypeUser, username
+	}
+	if key != "" {
+		return usage.TypeAPIKey, keyDigest(key)
+	}
+	return usage.TypeAnonymous, usage.TypeAnonymous
+}
+
+// keyDigest names an API key without revealing it.
+func keyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:6])
+}
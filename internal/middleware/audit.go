@@ -30,7 +30,7 @@ var defaultAuditConfig = AuditConfig{
 	LogRequestBody:   false,
 	LogHeaders:       false,
 	SensitiveHeaders: []string{"Authorization", "Cookie", "Set-Cookie"},
-	SkipPaths:        []string{"/health", "/health/infrastructure"},
+	SkipPaths:        []string{"/health", "/health/infrastructure", "/health/ready"},
 }
 
 // AuditWithConfig creates audit logging middleware with custom configuration
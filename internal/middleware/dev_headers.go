@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register dev mode debug headers middleware
+	RegisterMiddleware("dev_headers", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		if !cfg.DevMode() || !cfg.Dev.DebugHeaders {
+			return nil, nil
+		}
+		return DevHeaders(), nil
+	})
+}
+
+// Debug headers added in dev mode
+const (
+	HeaderDebugHandler = "X-Debug-Handler"
+	HeaderDebugRoute   = "X-Debug-Route"
+	HeaderServerTiming = "Server-Timing"
+)
+
+// devHeadersWriter adds the debug headers just before the response header
+// is written, since they cannot be changed afterwards.
+type devHeadersWriter struct {
+	gin.ResponseWriter
+	c       *gin.Context
+	start   time.Time
+	applied bool
+}
+
+func (w *devHeadersWriter) apply() {
+	if w.applied {
+		return
+	}
+	w.applied = true
+	h := w.ResponseWriter.Header()
+	h.Set(HeaderDebugHandler, w.c.HandlerName())
+	if route := w.c.FullPath(); route != "" {
+		h.Set(HeaderDebugRoute, route)
+	}
+	elapsed := float64(time.Since(w.start)) / float64(time.Millisecond)
+	h.Set(HeaderServerTiming, fmt.Sprintf("app;dur=%.2f", elapsed))
+}
+
+func (w *devHeadersWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *devHeadersWriter) Write(data []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *devHeadersWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// DevHeaders annotates responses with the handler name, the route pattern
+// and the handler time (as Server-Timing, shown by browser dev tools).
+func DevHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &devHeadersWriter{ResponseWriter: c.Writer, c: c, start: time.Now()}
+		c.Writer = w
+		c.Next()
+		if !w.Written() {
+			w.apply()
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/endpointstats"
+	"stackyrd/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register per-route request analytics middleware
+	RegisterMiddleware("endpoint_stats", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		stats := endpointstats.Default()
+		c := cfg.Monitoring.EndpointStats
+		stats.Configure(time.Duration(c.FlushInterval)*time.Second, c.LatencySamples)
+		return EndpointStats(stats), nil
+	})
+}
+
+// EndpointStats records the status, latency and payload sizes of every
+// request by registered route. Requests matching no route are not
+// recorded, so probing random paths cannot grow the route table.
+func EndpointStats(stats *endpointstats.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		stats.Record(endpointstats.Request{
+			Method:        c.Request.Method,
+			Route:         route,
+			Status:        c.Writer.Status(),
+			Latency:       time.Since(start),
+			RequestBytes:  c.Request.ContentLength,
+			ResponseBytes: int64(c.Writer.Size()),
+			Time:          start,
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register endpoint kill switch middleware
+	RegisterMiddleware("endpoint_toggles", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		toggles := GetEndpointToggles()
+		toggles.LoadConfig(cfg.Endpoints.Disabled)
+		return EndpointToggleMiddleware(toggles), nil
+	})
+}
+
+// Toggle sources
+const (
+	ToggleSourceConfig  = "config"
+	ToggleSourceRuntime = "runtime"
+)
+
+// EndpointToggle describes a disabled route (or route prefix).
+type EndpointToggle struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Reason     string    `json:"reason,omitempty"`
+	Source     string    `json:"source"`
+	DisabledBy string    `json:"disabled_by,omitempty"`
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+// EndpointToggles holds the routes that are switched off. It is safe for
+// concurrent use.
+type EndpointToggles struct {
+	mu       sync.RWMutex
+	disabled map[string]EndpointToggle
+}
+
+var (
+	globalEndpointToggles *EndpointToggles
+	endpointTogglesOnce   sync.Once
+)
+
+// GetEndpointToggles returns the singleton kill switch set shared by the
+// middleware and the monitoring API.
+func GetEndpointToggles() *EndpointToggles {
+	endpointTogglesOnce.Do(func() {
+		globalEndpointToggles = NewEndpointToggles()
+	})
+	return globalEndpointToggles
+}
+
+// NewEndpointToggles creates an empty toggle set.
+func NewEndpointToggles() *EndpointToggles {
+	return &EndpointToggles{disabled: make(map[string]EndpointToggle)}
+}
+
+func toggleKey(method, path string) string {
+	return normalizeToggleMethod(method) + " " + path
+}
+
+func normalizeToggleMethod(method string) string {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" || method == "ANY" {
+		return "*"
+	}
+	return method
+}
+
+// LoadConfig replaces the config-sourced toggles, keeping runtime ones.
+func (t *EndpointToggles) LoadConfig(entries []config.EndpointToggleConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, toggle := range t.disabled {
+		if toggle.Source == ToggleSourceConfig {
+			delete(t.disabled, key)
+		}
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if e.Path == "" {
+			continue
+		}
+		t.disabled[toggleKey(e.Method, e.Path)] = EndpointToggle{
+			Method:     normalizeToggleMethod(e.Method),
+			Path:       e.Path,
+			Reason:     e.Reason,
+			Source:     ToggleSourceConfig,
+			DisabledAt: now,
+		}
+	}
+}
+
+// Disable switches off a route at runtime.
+func (t *EndpointToggles) Disable(method, path, reason, by string) EndpointToggle {
+	toggle := EndpointToggle{
+		Method:     normalizeToggleMethod(method),
+		Path:       path,
+		Reason:     reason,
+		Source:     ToggleSourceRuntime,
+		DisabledBy: by,
+		DisabledAt: time.Now(),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disabled[toggleKey(method, path)] = toggle
+	return toggle
+}
+
+// Enable switches a route back on. It reports whether it was disabled.
+func (t *EndpointToggles) Enable(method, path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := toggleKey(method, path)
+	_, ok := t.disabled[key]
+	delete(t.disabled, key)
+	return ok
+}
+
+// Match returns the toggle disabling the route with the given method and
+// registered pattern, if any.
+func (t *EndpointToggles) Match(method, route string) (EndpointToggle, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.disabled) == 0 {
+		return EndpointToggle{}, false
+	}
+	method = strings.ToUpper(method)
+	for _, m := range []string{method, "*"} {
+		if toggle, ok := t.disabled[m+" "+route]; ok {
+			return toggle, true
+		}
+	}
+	for _, toggle := range t.disabled {
+		if toggle.Method != "*" && toggle.Method != method {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(toggle.Path, "/*"); ok && (route == prefix || strings.HasPrefix(route, prefix+"/")) {
+			return toggle, true
+		}
+	}
+	return EndpointToggle{}, false
+}
+
+// List returns all toggles sorted by path and method.
+func (t *EndpointToggles) List() []EndpointToggle {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]EndpointToggle, 0, len(t.disabled))
+	for _, toggle := range t.disabled {
+		out = append(out, toggle)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// EndpointToggleMiddleware rejects requests to disabled routes with 503.
+func EndpointToggleMiddleware(toggles *EndpointToggles) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		if toggle, disabled := toggles.Match(c.Request.Method, route); disabled {
+			details := map[string]interface{}{"method": c.Request.Method, "route": route}
+			if toggle.Reason != "" {
+				details["reason"] = toggle.Reason
+			}
+			response.Error(c, http.StatusServiceUnavailable, "ENDPOINT_DISABLED", "This endpoint is temporarily disabled", details)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/idempotency"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyReplayedHeader marks a response replayed for a retry.
+const IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKey bounds the length of an idempotency key.
+const maxIdempotencyKey = 255
+
+// IdempotencyOptions configures Idempotency.
+type IdempotencyOptions struct {
+	Header      string        // request header carrying the key, Idempotency-Key when empty
+	TTL         time.Duration // how long a response is replayed
+	LockTimeout time.Duration // how long a request being handled holds its key
+	Required    []string      // routes (e.g. /api/v1/orders/:tenant) refusing POST and PUT without a key
+}
+
+// Idempotency makes POST and PUT requests carrying an Idempotency-Key safe
+// to retry: the first request with a key is handled and its response
+// stored, and later requests with the same key and the same method, path,
+// query and body get that response again, marked Idempotent-Replayed. The key is
+// scoped to the caller's Authorization and X-API-Key headers. A retry
+// while the first request is still handled gets 409, a key reused for a
+// different request 422. Server errors and panics are not stored, so those
+// requests can be retried for real.
+func Idempotency(store idempotency.Store, opts IdempotencyOptions) gin.HandlerFunc {
+	header := opts.Header
+	if header == "" {
+		header = "Idempotency-Key"
+	}
+	required := make(map[string]bool, len(opts.Required))
+	for _, route := range opts.Required {
+		required[route] = true
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPut {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(header)
+		if key == "" {
+			if required[c.FullPath()] {
+				response.Error(c, http.StatusBadRequest, "IDEMPOTENCY_KEY_REQUIRED", header+" header is required")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			response.BadRequest(c, header+" must be at most "+strconv.Itoa(maxIdempotencyKey)+" characters")
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.BadRequest(c, "Failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Storing the response must not depend on the client waiting for it
+		ctx := context.WithoutCancel(c.Request.Context())
+		storeKey := idempotencyScope(c) + ":" + key
+		fingerprint := idempotencyFingerprint(c.Request.Method, c.Request.URL.Path+"?"+c.Request.URL.Query().Encode(), body)
+		existing, reserved, err := store.Reserve(ctx, storeKey, idempotency.Record{
+			State:       idempotency.StatePending,
+			Fingerprint: fingerprint,
+			Created:     time.Now(),
+		}, opts.LockTimeout)
+		if err != nil {
+			response.ServiceUnavailable(c, "Idempotency store unavailable, retry later")
+			c.Abort()
+			return
+		}
+		if !reserved {
+			replayIdempotent(c, existing, fingerprint, header)
+			return
+		}
+
+		// A panicking handler must not hold the key until LockTimeout
+		handled := false
+		defer func() {
+			if !handled {
+				store.Release(ctx, storeKey)
+			}
+		}()
+		writer := &cacheResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		handled = true
+		stored := make(map[string]string)
+		for _, name := range []string{"Content-Type", "Location"} {
+			if v := writer.Header().Get(name); v != "" {
+				stored[name] = v
+			}
+		}
+		store.Complete(ctx, storeKey, idempotency.Record{
+			State:       idempotency.StateDone,
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      stored,
+			Body:        writer.body.Bytes(),
+			Created:     time.Now(),
+		}, opts.TTL)
+	}
+}
+
+// replayIdempotent answers a request whose key is already kept.
+func replayIdempotent(c *gin.Context, record *idempotency.Record, fingerprint, header string) {
+	defer c.Abort()
+	switch {
+	case record.Fingerprint != fingerprint:
+		response.Error(c, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", header+" was used for a different request")
+	case record.State != idempotency.StateDone:
+		c.Header("Retry-After", "1")
+		response.Error(c, http.StatusConflict, "IDEMPOTENCY_IN_PROGRESS", "A request with this "+header+" is still being handled")
+	default:
+		for name, v := range record.Header {
+			c.Header(name, v)
+		}
+		c.Header(IdempotencyReplayedHeader, "true")
+		c.Data(record.Status, record.Header["Content-Type"], record.Body)
+	}
+}
+
+// idempotencyScope identifies the caller, so two callers choosing the same
+// key never see each other's responses.
+func idempotencyScope(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.GetHeader("Authorization") + "\x00" + c.GetHeader("X-API-Key")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// idempotencyFingerprint digests a request: method, path with its sorted
+// query, and body.
+func idempotencyFingerprint(method, target string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + target + "\x00"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
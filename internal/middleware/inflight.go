@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/inflight"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register in-flight request tracking middleware
+	RegisterMiddleware("inflight", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		return InFlight(inflight.Default()), nil
+	})
+}
+
+// InFlight records every request in tracker while it is served and gives
+// it a context the tracker can cancel. The correlation ID is resolved like
+// RequestID does and stored on the context, so both agree whichever runs
+// first.
+func InFlight(tracker *inflight.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" {
+			id = c.GetHeader("X-Correlation-ID")
+		}
+		if id == "" {
+			id = c.GetString(response.CorrelationIDKey)
+		}
+		if id == "" {
+			id = tracing.TraceID(c.Request.Context())
+		}
+		if id == "" {
+			id = "req-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		}
+		c.Set(response.CorrelationIDKey, id)
+
+		ctx, done := tracker.Begin(c.Request.Context(), inflight.Request{
+			Method:        c.Request.Method,
+			Path:          c.Request.URL.Path,
+			Route:         c.FullPath(),
+			CorrelationID: id,
+			ClientIP:      c.ClientIP(),
+		})
+		defer done()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
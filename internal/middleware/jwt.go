@@ -16,21 +16,34 @@ import (
 func init() {
 	// Register JWT middleware
 	RegisterMiddleware("jwt", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
-		// Use config for JWT secret, fallback to default
-		secretKey := "your-secret-key" // default
-		if cfg.Auth.Type == "jwt" && cfg.Auth.Secret != "" {
-			secretKey = cfg.Auth.Secret
-		}
-		return JWTRequired(secretKey), nil
+		return JWTRequired(jwtSecret(cfg)), nil
 	})
 }
 
+// jwtSecret is auth.secret with auth.type jwt, else the default secret.
+func jwtSecret(cfg *config.Config) string {
+	// Use config for JWT secret, fallback to default
+	secretKey := "your-secret-key" // default
+	if cfg.Auth.Type == "jwt" && cfg.Auth.Secret != "" {
+		secretKey = cfg.Auth.Secret
+	}
+	return secretKey
+}
+
+// MonitoringAudience is the audience of the tokens monitoring logins
+// issue. The monitoring API accepts only those for its accounts, and the
+// service API refuses them.
+const MonitoringAudience = "stackyard-monitoring"
+
+var errMonitoringToken = errors.New("monitoring login tokens are not valid here")
+
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
+	UserID       string `json:"user_id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version,omitempty"` // credentials version of a monitoring account
 	jwt.RegisteredClaims
 }
 
@@ -65,6 +78,26 @@ func GenerateToken(userID, username, email, role, secretKey string, expiration t
 	return token.SignedString([]byte(secretKey))
 }
 
+// GenerateMonitoringToken creates a monitoring login token for an account
+// at version of its credentials. It carries no role: the monitoring API
+// reads it from the account.
+func GenerateMonitoringToken(username, email string, version int, secretKey string, expiration time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:       username,
+		Username:     username,
+		Email:        email,
+		TokenVersion: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{MonitoringAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secretKey))
+}
+
 // JWTRequired middleware validates JWT tokens
 func JWTRequired(secretKey string) gin.HandlerFunc {
 	config := defaultJWTConfig
@@ -82,22 +115,17 @@ func JWT(config JWTConfig) gin.HandlerFunc {
 			return
 		}
 
-		parsedToken, err := jwt.ParseWithClaims(token, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.SecretKey), nil
-		})
-
-		if err != nil || !parsedToken.Valid {
+		claims, err := ParseToken(token, config.SecretKey)
+		if err != nil {
 			response.Unauthorized(c, "Invalid token")
 			c.Abort()
 			return
 		}
 
-		if claims, ok := parsedToken.Claims.(*JWTClaims); ok {
-			c.Set("user_id", claims.UserID)
-			c.Set("username", claims.Username)
-			c.Set("email", claims.Email)
-			c.Set("role", claims.Role)
-		}
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
 
 		c.Next()
 	}
@@ -137,6 +165,41 @@ func extractToken(c *gin.Context, tokenLookup string) (string, error) {
 	}
 }
 
+// ParseToken validates a signed token and returns its claims. Monitoring
+// login tokens are refused.
+func ParseToken(token, secretKey string) (*JWTClaims, error) {
+	claims, err := parseClaims(token, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, aud := range claims.Audience {
+		if aud == MonitoringAudience {
+			return nil, errMonitoringToken
+		}
+	}
+	return claims, nil
+}
+
+// ParseMonitoringToken validates a monitoring login token and returns its
+// claims.
+func ParseMonitoringToken(token, secretKey string) (*JWTClaims, error) {
+	return parseClaims(token, secretKey, jwt.WithAudience(MonitoringAudience))
+}
+
+func parseClaims(token, secretKey string, opts ...jwt.ParserOption) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secretKey), nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !parsedToken.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
 // JWTOptional middleware validates JWT tokens if present, but doesn't require them
 func JWTOptional(secretKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -146,21 +209,16 @@ func JWTOptional(secretKey string) gin.HandlerFunc {
 			return
 		}
 
-		parsedToken, err := jwt.ParseWithClaims(token, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secretKey), nil
-		})
-
-		if err != nil || !parsedToken.Valid {
+		claims, err := ParseToken(token, secretKey)
+		if err != nil {
 			c.Next()
 			return
 		}
 
-		if claims, ok := parsedToken.Claims.(*JWTClaims); ok {
-			c.Set("user_id", claims.UserID)
-			c.Set("username", claims.Username)
-			c.Set("email", claims.Email)
-			c.Set("role", claims.Role)
-		}
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
 
 		c.Next()
 	}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"sync"
@@ -8,6 +9,8 @@ import (
 
 	"stackyrd/config"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 )
@@ -78,6 +81,23 @@ func (r *MiddlewareRegistry) ApplyConfig(cfg *config.Config) {
 // AutoDiscoverMiddlewares creates and returns all enabled middleware
 func (r *MiddlewareRegistry) AutoDiscoverMiddlewares(cfg *config.Config, logger *logger.Logger) []gin.HandlerFunc {
 	var middlewares []gin.HandlerFunc
+	for _, mw := range r.AutoDiscoverNamed(cfg, logger) {
+		middlewares = append(middlewares, mw.Handler)
+	}
+	return middlewares
+}
+
+// Named is a middleware with the name it is registered under.
+type Named struct {
+	Name    string
+	Handler gin.HandlerFunc
+}
+
+// AutoDiscoverNamed creates all enabled middleware like
+// AutoDiscoverMiddlewares, keeping their names so services can leave some
+// out (see Chain).
+func (r *MiddlewareRegistry) AutoDiscoverNamed(cfg *config.Config, logger *logger.Logger) []Named {
+	var middlewares []Named
 
 	for name, factory := range r.factories {
 		if r.IsEnabled(name) {
@@ -88,7 +108,7 @@ func (r *MiddlewareRegistry) AutoDiscoverMiddlewares(cfg *config.Config, logger
 				continue
 			}
 			if mw != nil {
-				middlewares = append(middlewares, mw)
+				middlewares = append(middlewares, Named{Name: name, Handler: mw})
 				logger.Info("Auto-registered middleware", "middleware", name)
 			}
 		} else {
@@ -99,6 +119,22 @@ func (r *MiddlewareRegistry) AutoDiscoverMiddlewares(cfg *config.Config, logger
 	return middlewares
 }
 
+// Create creates the registered middleware name whether or not it is
+// enabled globally, for a service that uses it on its own.
+func (r *MiddlewareRegistry) Create(name string, cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown middleware %q", name)
+	}
+	return factory(cfg, logger)
+}
+
+// Has reports whether a middleware is registered as name.
+func (r *MiddlewareRegistry) Has(name string) bool {
+	_, ok := r.factories[name]
+	return ok
+}
+
 // Config holds middleware configuration
 type Config struct {
 	AuthType string
@@ -108,7 +144,7 @@ type Config struct {
 // InitMiddlewares registers global middlewares (legacy support)
 func InitMiddlewares(r *gin.Engine, cfg Config) {
 	// Request ID
-	r.Use(RequestID())
+	r.Use(RequestID(cfg.Logger))
 
 	// Custom Logger Middleware
 	r.Use(Logger(cfg.Logger))
@@ -121,7 +157,7 @@ func InitMiddlewares(r *gin.Engine, cfg Config) {
 func init() {
 	// Register core middlewares
 	RegisterMiddleware("request_id", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
-		return RequestID(), nil
+		return RequestID(logger), nil
 	})
 
 	RegisterMiddleware("logger", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
@@ -133,15 +169,36 @@ func init() {
 	})
 }
 
-func RequestID() gin.HandlerFunc {
+// RequestID assigns every request a correlation ID: the client's
+// X-Request-ID or X-Correlation-ID, else the trace ID when tracing runs
+// first, else a generated one. The ID is echoed in X-Request-ID, used as the
+// correlation_id of the response envelope and carried in the request context
+// together with a child of l logging it on every line, so handlers and
+// downstream calls pick it up (see logger.Ctx).
+func RequestID(l *logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Generate request ID if not present
 		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" {
+			requestID = c.GetHeader("X-Correlation-ID")
+		}
+		if requestID == "" {
+			requestID = c.GetString(response.CorrelationIDKey)
+		}
+		if requestID == "" {
+			requestID = tracing.TraceID(c.Request.Context())
+		}
 		if requestID == "" {
 			requestID = "req-" + strconv.FormatInt(time.Now().UnixNano(), 10)
 		}
 		c.Set("X-Request-ID", requestID)
+		c.Set(response.CorrelationIDKey, requestID)
 		c.Writer.Header().Set("X-Request-ID", requestID)
+
+		ctx := logger.WithCorrelationID(c.Request.Context(), requestID)
+		if l != nil {
+			ctx = logger.NewContext(ctx, l.With(logger.FieldCorrelationID, requestID))
+		}
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
@@ -159,12 +216,13 @@ func Logger(l *logger.Logger) gin.HandlerFunc {
 
 		msg := strconv.Itoa(status) + " | " + method + " | " + path + " | " + latency.String()
 
+		rl := l.Ctx(c.Request.Context())
 		if status >= 500 {
-			l.Error(msg, nil)
+			rl.Error(msg, nil)
 		} else if status >= 400 {
-			l.Warn(msg)
+			rl.Warn(msg)
 		} else {
-			l.Info(msg)
+			rl.Info(msg)
 		}
 	}
 }
@@ -177,7 +235,7 @@ func PermissionCheck(l *logger.Logger) gin.HandlerFunc {
 		// if they are considered "delete data".
 
 		if c.Request.Method == http.MethodDelete {
-			l.Warn("Blocked DELETE attempt due to permission policy", "path", c.Request.URL.Path, "ip", c.ClientIP())
+			l.Ctx(c.Request.Context()).Warn("Blocked DELETE attempt due to permission policy", "path", c.Request.URL.Path, "ip", c.ClientIP())
 			c.JSON(http.StatusForbidden, map[string]string{
 				"error": "Permission Denied: DELETE actions are restricted.",
 			})
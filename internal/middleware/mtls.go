@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"slices"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register client certificate middleware
+	RegisterMiddleware("mtls", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		if cfg.Auth.Type != "mtls" {
+			return nil, nil
+		}
+		return MTLS(cfg.Auth.MTLS.AllowedCNs), nil
+	})
+}
+
+// ClientCNKey is the gin context key holding the common name of the
+// verified client certificate.
+const ClientCNKey = "client_cn"
+
+// MTLS identifies clients by their certificate, which the TLS handshake
+// has already verified against the configured CA. The certificate CN is
+// stored under ClientCNKey and as "username"; with allowedCNs set, other
+// CNs are rejected.
+func MTLS(allowedCNs []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+			response.Unauthorized(c, "Client certificate required")
+			c.Abort()
+			return
+		}
+
+		cn := state.VerifiedChains[0][0].Subject.CommonName
+		if len(allowedCNs) > 0 && !slices.Contains(allowedCNs, cn) {
+			response.Forbidden(c, "Client certificate not allowed")
+			c.Abort()
+			return
+		}
+
+		c.Set(ClientCNKey, cn)
+		if c.GetString("username") == "" {
+			c.Set("username", cn)
+		}
+		c.Next()
+	}
+}
+
+// ClientCN returns the common name of the verified client certificate, or
+// "" when the request was not authenticated by MTLS.
+func ClientCN(c *gin.Context) string {
+	return c.GetString(ClientCNKey)
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedResponse is a response kept by CacheResponses.
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// ResponseCache serves successful GET responses from memory for ttl, like
+// CacheResponses on an LRU backend of maxEntries.
+func ResponseCache(ttl time.Duration, maxEntries int) gin.HandlerFunc {
+	return CacheResponses(cache.NewLRU(maxEntries), ttl)
+}
+
+// CacheResponses serves successful GET responses from backend for ttl,
+// for read-heavy routes:
+//
+//	sub.GET("/catalogue", middleware.CacheResponses(backend, time.Minute), s.catalogue)
+//
+// Responses are keyed by path, query (in any parameter order), host,
+// tenant and caller: the Authorization and X-API-Key headers, the user
+// auth middleware identified and the client certificate subject, so
+// callers never get each other's responses. Responses setting cookies are
+// not cached, and "Cache-Control: no-cache" requests skip the cache.
+// X-Cache tells HIT or MISS. A failing backend is bypassed.
+func CacheResponses(backend cache.Backend, ttl time.Duration) gin.HandlerFunc {
+	return cacheResponses(backend, ttl, false)
+}
+
+// CacheAuthenticatedResponses is CacheResponses for authenticated routes:
+// requests with no caller identity to key by are never cached.
+func CacheAuthenticatedResponses(backend cache.Backend, ttl time.Duration) gin.HandlerFunc {
+	return cacheResponses(backend, ttl, true)
+}
+
+func cacheResponses(backend cache.Backend, ttl time.Duration, authenticated bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		caller := responseCaller(c)
+		if authenticated && caller == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		key := responseCacheKey(c, caller)
+		if !strings.Contains(c.GetHeader("Cache-Control"), "no-cache") {
+			var entry cachedResponse
+			if data, ok, err := backend.Get(ctx, key); err == nil && ok && json.Unmarshal(data, &entry) == nil {
+				c.Header("X-Cache", "HIT")
+				c.Data(entry.Status, entry.ContentType, entry.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Header("X-Cache", "MISS")
+		writer := &cacheResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() == http.StatusOK && writer.Header().Get("Set-Cookie") == "" {
+			data, err := json.Marshal(cachedResponse{
+				Status:      http.StatusOK,
+				ContentType: writer.Header().Get("Content-Type"),
+				Body:        writer.body.Bytes(),
+			})
+			if err == nil {
+				backend.Set(ctx, key, data, ttl)
+			}
+		}
+	}
+}
+
+// responseCacheKey is "response:" and a digest of the path, the sorted
+// query, the host, the tenant and the caller of the request.
+func responseCacheKey(c *gin.Context, caller string) string {
+	h := sha256.New()
+	for _, part := range []string{
+		c.Request.URL.Path + "?" + c.Request.URL.Query().Encode(),
+		c.Request.Host,
+		c.GetString("tenant"),
+		c.GetHeader("X-Tenant-ID"),
+		caller,
+	} {
+		h.Write([]byte(part + "\x00"))
+	}
+	return "response:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// responseCaller identifies the caller of a request by its credentials
+// and what auth middleware set, or returns "" for an anonymous request.
+func responseCaller(c *gin.Context) string {
+	subject := ""
+	if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		subject = state.VerifiedChains[0][0].Subject.String()
+	}
+	parts := []string{
+		c.GetHeader("Authorization"),
+		c.GetHeader("X-API-Key"),
+		c.GetString("user_id"),
+		GetUsername(c),
+		ClientCN(c),
+		subject,
+	}
+	if strings.Join(parts, "") == "" {
+		return ""
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// cacheResponseWriter keeps a copy of the response body while writing it.
+type cacheResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *cacheResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
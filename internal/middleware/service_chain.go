@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/cache"
+	"stackyrd/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Service auth overrides (services.<name>.middleware.auth)
+const (
+	ServiceAuthJWT  = "jwt"
+	ServiceAuthMTLS = "mtls"
+	ServiceAuthNone = "none"
+)
+
+// defaultServiceCacheEntries bounds a service's response cache when
+// cache.max_entries is unset.
+const defaultServiceCacheEntries = 1000
+
+// Chain records where each global middleware sits in the handlers of an
+// engine, so the route group of a service can leave some out.
+type Chain struct {
+	positions map[string]int
+}
+
+// Use adds the middleware registered as name to engine.
+func (ch *Chain) Use(engine *gin.Engine, name string, handler gin.HandlerFunc) {
+	if ch.positions == nil {
+		ch.positions = make(map[string]int)
+	}
+	ch.positions[name] = len(engine.Handlers)
+	engine.Use(handler)
+}
+
+// without returns a copy of handlers, taken from a group of the engine,
+// leaving out the global middleware named in skip.
+func (ch *Chain) without(handlers gin.HandlersChain, skip map[string]bool) gin.HandlersChain {
+	left := make(map[int]bool)
+	for name := range skip {
+		if i, ok := ch.positions[name]; ok {
+			left[i] = true
+		}
+	}
+	out := make(gin.HandlersChain, 0, len(handlers))
+	for i, h := range handlers {
+		if !left[i] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// applies reports whether the global middleware registered as name runs
+// for a group that skips the middleware in skip.
+func (ch *Chain) applies(name string, skip map[string]bool) bool {
+	if ch == nil {
+		return false
+	}
+	_, ok := ch.positions[name]
+	return ok && !skip[name]
+}
+
+// CheckServiceMiddleware reports what is wrong with the middleware config
+// of a service.
+func CheckServiceMiddleware(mc config.ServiceMiddlewareConfig) error {
+	switch mc.Auth {
+	case "", ServiceAuthJWT, ServiceAuthMTLS, ServiceAuthNone:
+	default:
+		return fmt.Errorf("auth must be %s, %s or %s, not %q", ServiceAuthJWT, ServiceAuthMTLS, ServiceAuthNone, mc.Auth)
+	}
+	if len(mc.Roles) > 0 && mc.Auth == ServiceAuthNone {
+		return fmt.Errorf("roles need authentication, not auth %q", mc.Auth)
+	}
+	if mc.RateLimit.Requests < 0 || mc.RateLimit.Window < 0 {
+		return fmt.Errorf("rate_limit requests and window must not be negative")
+	}
+	if mc.Cache.TTL < 0 || mc.Cache.MaxEntries < 0 {
+		return fmt.Errorf("cache ttl and max_entries must not be negative")
+	}
+	registry := GetGlobalMiddlewareRegistry()
+	for _, names := range [][]string{mc.Use, mc.Skip} {
+		for _, name := range names {
+			if !registry.Has(name) {
+				return fmt.Errorf("unknown middleware %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// ServiceGroup returns the route group a service registers its routes on:
+// api itself when the service has no middleware of its own, else a group
+// without the global middleware it skips (jwt and mtls too when it
+// overrides auth) followed by its auth, roles, rate limit, used middleware
+// and response cache, in that order.
+func ServiceGroup(api *gin.RouterGroup, chain *Chain, mc config.ServiceMiddlewareConfig, cfg *config.Config, logger *logger.Logger) (*gin.RouterGroup, error) {
+	if mc.IsZero() {
+		return api, nil
+	}
+	if err := CheckServiceMiddleware(mc); err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range mc.Skip {
+		skip[name] = true
+	}
+	var handlers []gin.HandlerFunc
+	switch mc.Auth {
+	case ServiceAuthJWT:
+		handlers = append(handlers, JWTRequired(jwtSecret(cfg)))
+	case ServiceAuthMTLS:
+		handlers = append(handlers, MTLS(cfg.Auth.MTLS.AllowedCNs))
+	}
+	if mc.Auth != "" {
+		skip["jwt"], skip["mtls"] = true, true
+	}
+	if len(mc.Roles) > 0 {
+		handlers = append(handlers, RequireRole(mc.Roles...))
+	}
+	if rl := mc.RateLimit; rl.Requests > 0 {
+		window := time.Duration(rl.Window) * time.Second
+		if window == 0 {
+			window = time.Minute
+		}
+		if rl.PerUser {
+			handlers = append(handlers, RateLimitPerUser(rl.Requests, window))
+		} else {
+			handlers = append(handlers, RateLimitWithConfig(rl.Requests, window))
+		}
+	}
+	for _, name := range mc.Use {
+		mw, err := GetGlobalMiddlewareRegistry().Create(name, cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %w", name, err)
+		}
+		if mw != nil {
+			handlers = append(handlers, mw)
+		}
+	}
+	if mc.Cache.TTL > 0 {
+		entries := mc.Cache.MaxEntries
+		if entries == 0 {
+			entries = defaultServiceCacheEntries
+		}
+		ttl := time.Duration(mc.Cache.TTL) * time.Second
+		authenticated := mc.Auth == ServiceAuthJWT || mc.Auth == ServiceAuthMTLS ||
+			chain.applies("jwt", skip) || chain.applies("mtls", skip)
+		if authenticated {
+			handlers = append(handlers, CacheAuthenticatedResponses(cache.NewLRU(entries), ttl))
+		} else {
+			handlers = append(handlers, ResponseCache(ttl, entries))
+		}
+	}
+
+	group := api.Group("")
+	if chain != nil {
+		group.Handlers = chain.without(group.Handlers, skip)
+	}
+	group.Use(handlers...)
+	return group, nil
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"stackyrd/config"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Sources of the tenant of a request, see TenantOptions.Sources.
+const (
+	TenantFromPath      = "path"      // :tenant path parameter
+	TenantFromHeader    = "header"    // tenant header
+	TenantFromSubdomain = "subdomain" // <tenant>.<base domain>
+	TenantFromJWT       = "jwt"       // claim of the bearer token
+)
+
+// TenantOptions configures Tenant.
+type TenantOptions struct {
+	// Sources are tried in order; the first one naming a tenant wins.
+	Sources []string
+	// Header carries the tenant ID (default X-Tenant-ID).
+	Header string
+	// BaseDomain is the domain tenant subdomains live under.
+	BaseDomain string
+	// Claim is the JWT claim holding the tenant ID (default tenant_id).
+	Claim string
+	// Secret verifies the bearer token of the jwt source.
+	Secret string
+	// Required rejects requests without a tenant.
+	Required bool
+	// Registry rejects unknown and disabled tenants once it holds any.
+	Registry *tenancy.Registry
+}
+
+// TenantOptionsFromConfig builds the options of tenancy.* with the auth
+// secret for the jwt source.
+func TenantOptionsFromConfig(cfg *config.Config, registry *tenancy.Registry) TenantOptions {
+	return TenantOptions{
+		Sources:    cfg.Tenancy.Sources,
+		Header:     cfg.Tenancy.Header,
+		BaseDomain: cfg.Tenancy.BaseDomain,
+		Claim:      cfg.Tenancy.JWTClaim,
+		Secret:     jwtSecret(cfg),
+		Required:   cfg.Tenancy.Required,
+		Registry:   registry,
+	}
+}
+
+// Tenant resolves the tenant of a request from the configured sources,
+// checks it against the registry and carries it in the request context
+// (see tenancy.FromContext) and under the "tenant" key.
+func Tenant(opts TenantOptions) gin.HandlerFunc {
+	if opts.Header == "" {
+		opts.Header = tenancy.HeaderTenantID
+	}
+	if opts.Claim == "" {
+		opts.Claim = "tenant_id"
+	}
+	if len(opts.Sources) == 0 {
+		opts.Sources = []string{TenantFromPath, TenantFromHeader, TenantFromSubdomain, TenantFromJWT}
+	}
+	baseDomain := "." + strings.Trim(strings.ToLower(opts.BaseDomain), ".")
+
+	return func(c *gin.Context) {
+		var tenant string
+		for _, source := range opts.Sources {
+			switch source {
+			case TenantFromPath:
+				tenant = c.Param("tenant")
+			case TenantFromHeader:
+				tenant = strings.TrimSpace(c.GetHeader(opts.Header))
+			case TenantFromSubdomain:
+				if baseDomain != "." {
+					tenant = tenantFromHost(c.Request.Host, baseDomain)
+				}
+			case TenantFromJWT:
+				tenant = tenantFromToken(c, opts.Claim, opts.Secret)
+			}
+			if tenant != "" {
+				break
+			}
+		}
+
+		if tenant == "" {
+			if opts.Required {
+				response.Error(c, http.StatusBadRequest, "TENANT_REQUIRED", "The request does not name a tenant")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if !tenancy.ValidID(tenant) {
+			response.Error(c, http.StatusBadRequest, "INVALID_TENANT", "Invalid tenant ID")
+			c.Abort()
+			return
+		}
+		if opts.Registry.Len() > 0 {
+			t, ok := opts.Registry.Get(tenant)
+			if !ok {
+				response.Error(c, http.StatusNotFound, "TENANT_NOT_FOUND", "Unknown tenant '"+tenant+"'")
+				c.Abort()
+				return
+			}
+			if t.Disabled {
+				response.Error(c, http.StatusForbidden, "TENANT_DISABLED", "Tenant '"+tenant+"' is disabled")
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("tenant", tenant)
+		c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
+
+// tenantFromHost returns the single label in front of baseDomain (with a
+// leading dot), so "acme.example.com" names tenant "acme".
+func tenantFromHost(host, baseDomain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, baseDomain) {
+		return ""
+	}
+	label := strings.TrimSuffix(host, baseDomain)
+	if strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// tenantFromToken returns the claim of a valid bearer token. Invalid
+// tokens name no tenant; rejecting them is up to the jwt middleware.
+func tenantFromToken(c *gin.Context, claim, secret string) string {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" || secret == "" {
+		return ""
+	}
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name, jwt.SigningMethodHS384.Name, jwt.SigningMethodHS512.Name}))
+	if err != nil || !parsed.Valid {
+		return ""
+	}
+	tenant, _ := claims[claim].(string)
+	return tenant
+}
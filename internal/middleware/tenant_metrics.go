@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// Register tenant metrics middleware
+	RegisterMiddleware("tenant_metrics", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		return TenantMetrics(tenancy.DefaultCollector()), nil
+	})
+}
+
+// TenantMetrics records request count and latency per tenant. The tenant
+// is the one the tenant middleware resolved and checked against the
+// registry, read once the request is served since that middleware runs
+// later in the chain; requests without one are not tracked.
+func TenantMetrics(collector *tenancy.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if tenant := c.GetString("tenant"); tenant != "" {
+			collector.RecordRequest(tenant, c.Writer.Status(), time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	// Register tracing middleware
+	RegisterMiddleware("tracing", func(cfg *config.Config, logger *logger.Logger) (gin.HandlerFunc, error) {
+		if !cfg.Tracing.Enabled {
+			return nil, nil
+		}
+		return Tracing(), nil
+	})
+}
+
+// HeaderTraceID carries the trace ID of a request in the response.
+const HeaderTraceID = "X-Trace-ID"
+
+// Tracing starts a server span per request, continuing the trace of the
+// caller when a traceparent header is present. Without an explicit request
+// ID the trace ID becomes the correlation_id of the response envelope, so
+// responses, logs and traces share one identifier.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method
+		}
+		ctx, span := tracing.Start(ctx, name, trace.SpanKindServer,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path),
+			attribute.String("client.address", c.ClientIP()),
+			attribute.String("user_agent.original", c.Request.UserAgent()),
+		)
+		defer span.End()
+
+		correlationID := c.GetHeader("X-Request-ID")
+		if correlationID == "" {
+			correlationID = c.GetHeader("X-Correlation-ID")
+		}
+		if correlationID == "" {
+			correlationID = c.GetString(response.CorrelationIDKey)
+		}
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			c.Header(HeaderTraceID, traceID)
+			if correlationID == "" {
+				correlationID = traceID
+			}
+		}
+		if correlationID != "" {
+			c.Set(response.CorrelationIDKey, correlationID)
+			span.SetAttributes(attribute.String("correlation_id", correlationID))
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package mockserver implements the built-in mock upstream: canned
+// responses for external services with latency and error injection, so the
+// full stack can be exercised offline.
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+)
+
+// Fault modes for injected errors.
+const (
+	FaultStatus = "status"
+	FaultReset  = "reset"
+)
+
+type route struct {
+	cfg      config.MockRouteConfig
+	hits     atomic.Int64
+	failures atomic.Int64
+}
+
+func (r *route) matches(method, path string) bool {
+	if r.cfg.Method != "" && !strings.EqualFold(r.cfg.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.cfg.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return r.cfg.Path == "" || r.cfg.Path == path
+}
+
+// Server serves the configured routes.
+type Server struct {
+	cfg        config.MockConfig
+	logger     *logger.Logger
+	routes     []*route
+	unmatched  atomic.Int64
+	httpServer *http.Server
+	baseURL    string
+	mu         sync.Mutex
+}
+
+// New creates a mock server; call Start to listen on the configured port.
+func New(cfg config.MockConfig, l *logger.Logger) *Server {
+	if cfg.DefaultStatus == 0 {
+		cfg.DefaultStatus = http.StatusOK
+	}
+	s := &Server{cfg: cfg, logger: l}
+	for _, rc := range cfg.Routes {
+		if rc.Status == 0 {
+			rc.Status = http.StatusOK
+		}
+		if rc.ErrorStatus == 0 {
+			rc.ErrorStatus = http.StatusServiceUnavailable
+		}
+		s.routes = append(s.routes, &route{cfg: rc})
+	}
+	return s
+}
+
+// Name returns the display name of the component
+func (s *Server) Name() string {
+	return "Mock Upstream"
+}
+
+// Start listens on the configured port in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", ":"+s.cfg.Port)
+	if err != nil {
+		return fmt.Errorf("mock server cannot listen on port %s: %w", s.cfg.Port, err)
+	}
+	s.mu.Lock()
+	s.baseURL = fmt.Sprintf("http://127.0.0.1:%d", ln.Addr().(*net.TCPAddr).Port)
+	s.httpServer = &http.Server{Handler: s}
+	srv := s.httpServer
+	s.mu.Unlock()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Mock server stopped", err)
+		}
+	}()
+	return nil
+}
+
+// URL returns the base URL of the running server.
+func (s *Server) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.baseURL
+}
+
+// RewriteURL points rawURL of the named service at the mock, keeping path
+// and query: https://payments.example.com/health becomes
+// <mock>/payments/health.
+func (s *Server) RewriteURL(service, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	rewritten := s.URL() + "/" + url.PathEscape(service) + u.EscapedPath()
+	if u.RawQuery != "" {
+		rewritten += "?" + u.RawQuery
+	}
+	return rewritten
+}
+
+// match finds the route for a request: service routes first (by the first
+// path segment), then routes without a service against the full path.
+func (s *Server) match(method, path string) *route {
+	service, rest := "", path
+	if trimmed := strings.TrimPrefix(path, "/"); trimmed != "" {
+		if i := strings.Index(trimmed, "/"); i >= 0 {
+			service, rest = trimmed[:i], trimmed[i:]
+		} else {
+			service, rest = trimmed, "/"
+		}
+	}
+	for _, r := range s.routes {
+		if r.cfg.Service != "" && r.cfg.Service == service && r.matches(method, rest) {
+			return r
+		}
+	}
+	for _, r := range s.routes {
+		if r.cfg.Service == "" && r.matches(method, path) {
+			return r
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the canned response of the matching route.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r := s.match(req.Method, req.URL.Path)
+	if r == nil {
+		s.unmatched.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(s.cfg.DefaultStatus)
+		json.NewEncoder(w).Encode(map[string]interface{}{"mock": true, "path": req.URL.Path})
+		return
+	}
+	r.hits.Add(1)
+
+	if delay := s.delay(r.cfg); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	if r.cfg.ErrorRate > 0 && rand.Float64() < r.cfg.ErrorRate {
+		r.failures.Add(1)
+		if r.cfg.Fault == FaultReset {
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(r.cfg.ErrorStatus)
+		json.NewEncoder(w).Encode(map[string]interface{}{"mock": true, "error": "injected failure"})
+		return
+	}
+
+	for k, v := range r.cfg.Headers {
+		w.Header().Set(k, v)
+	}
+	if w.Header().Get("Content-Type") == "" && strings.HasPrefix(strings.TrimSpace(r.cfg.Body), "{") {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(r.cfg.Status)
+	fmt.Fprint(w, r.cfg.Body)
+}
+
+func (s *Server) delay(rc config.MockRouteConfig) time.Duration {
+	d := time.Duration(rc.Latency) * time.Millisecond
+	if rc.Jitter > 0 {
+		d += time.Duration(rand.IntN(rc.Jitter+1)) * time.Millisecond
+	}
+	return d
+}
+
+// GetStatus reports the address and per-route hit counts.
+func (s *Server) GetStatus() map[string]interface{} {
+	routes := make([]map[string]interface{}, 0, len(s.routes))
+	for _, r := range s.routes {
+		routes = append(routes, map[string]interface{}{
+			"service":  r.cfg.Service,
+			"method":   r.cfg.Method,
+			"path":     r.cfg.Path,
+			"hits":     r.hits.Load(),
+			"failures": r.failures.Load(),
+		})
+	}
+	return map[string]interface{}{
+		"connected": s.URL() != "",
+		"url":       s.URL(),
+		"routes":    routes,
+		"unmatched": s.unmatched.Load(),
+	}
+}
+
+// Close stops the listener.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	srv := s.httpServer
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
@@ -0,0 +1,322 @@
+package monitoring
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/accounts"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role is an access level of the monitoring API; each role includes the
+// ones below it.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+var roleNames = [...]string{"none", "viewer", "operator", "admin"}
+
+func (r Role) String() string {
+	if r < RoleNone || r > RoleAdmin {
+		return "none"
+	}
+	return roleNames[r]
+}
+
+// ParseRole parses a role name, case-insensitively.
+func ParseRole(name string) (Role, bool) {
+	for i, roleName := range roleNames {
+		if i > 0 && strings.EqualFold(name, roleName) {
+			return Role(i), true
+		}
+	}
+	return RoleNone, false
+}
+
+// routeRoles lists the routes, relative to the API group, that require
+// another role than the default: viewer for reads, operator otherwise.
+var routeRoles = map[string]Role{
+	"GET /config":                      RoleOperator,
+	"GET /config/effective":            RoleOperator,
+	"GET /config/sections":             RoleOperator,
+	"GET /config/section/*path":        RoleOperator,
+	"GET /audit":                       RoleOperator,
+	"GET /postgres/schema":             RoleOperator,
+	"GET /redis/slowlog":               RoleOperator, // commands carry their arguments
+	"GET /config/backups":              RoleOperator,
+	"GET /config/backups/:id/diff":     RoleOperator,
+	"PUT /config/section/*path":        RoleAdmin,
+	"POST /config/backups/:id/restore": RoleAdmin,
+	"POST /config/backups/prune":       RoleAdmin,
+	"POST /restart":                    RoleAdmin,
+	"POST /tenants":                    RoleAdmin, // connects databases and edits the config file
+	"POST /tenants/:tenant/export":     RoleAdmin,
+	"POST /tenants/:tenant/deletion":   RoleAdmin,
+	"POST /postgres/explain":           RoleAdmin, // analyze runs the query
+	"POST /postgres/query":             RoleAdmin,
+	"POST /queries/:name/run":          RoleAdmin,
+	"PUT /queries/:name/report":        RoleAdmin,
+	"DELETE /queries/:name/report":     RoleAdmin,
+	// Report runs keep the rows of the query
+	"GET /queries/:name/reports":        RoleAdmin,
+	"GET /queries/:name/reports/latest": RoleAdmin,
+	// Documents, Redis values and Kafka messages are raw data, like the
+	// SQL console
+	"GET /mongo/collections/:collection/documents": RoleAdmin,
+	"GET /redis/key":                     RoleAdmin,
+	"PUT /redis/key":                     RoleAdmin,
+	"PUT /redis/key/ttl":                 RoleAdmin,
+	"DELETE /redis/key":                  RoleAdmin,
+	"GET /kafka/topics/:topic/messages":  RoleAdmin,
+	"POST /kafka/topics/:topic/messages": RoleAdmin,
+	"GET /kafka/topics/:topic/tail":      RoleAdmin,
+	// Every caller manages their own preferences and query history
+	"PUT /preferences":      RoleViewer,
+	"DELETE /preferences":   RoleViewer,
+	"DELETE /query-history": RoleViewer,
+	// Identified callers without a role can still learn that they lack one
+	"GET /access/whoami": RoleNone,
+	// Accounts grant roles, so only admins manage them
+	"GET /accounts":                   RoleAdmin,
+	"POST /accounts":                  RoleAdmin,
+	"POST /accounts/disable":          RoleAdmin,
+	"POST /accounts/enable":           RoleAdmin,
+	"POST /accounts/:username/invite": RoleAdmin,
+	// Normalized statements can still carry literals; a reset loses the
+	// figures others are looking at
+	"GET /postgres/stats/queries":        RoleOperator,
+	"GET /postgres/stats/indexes":        RoleOperator,
+	"GET /postgres/stats/tables":         RoleOperator,
+	"POST /postgres/stats/queries/reset": RoleAdmin,
+	// Table layouts, like /postgres/schema
+	"GET /postgres/migrations": RoleOperator,
+	// Index builds load the server; dropping an index can stall
+	// queries or let duplicates in
+	"POST /mongo/collections/:collection/indexes":         RoleAdmin,
+	"DELETE /mongo/collections/:collection/indexes/:name": RoleAdmin,
+}
+
+// publicRoutes are how callers obtain credentials, so they are open to
+// unidentified callers.
+var publicRoutes = map[string]bool{
+	"POST /auth/login":     true,
+	"POST /auth/password":  true,
+	"POST /accounts/setup": true,
+}
+
+// RequiredRole returns the role needed for method on route, a path
+// relative to the API group as registered (e.g. "/jobs/:id").
+func RequiredRole(method, route string) Role {
+	if role, ok := routeRoles[method+" "+route]; ok {
+		return role
+	}
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return RoleViewer
+	}
+	return RoleOperator
+}
+
+// caller is who is calling the monitoring API and with which role.
+type caller struct {
+	Name string
+	Role Role
+	Via  string // api_key, account, user, default or disabled
+}
+
+// disabledRole is the role of every caller while monitoring.access is
+// disabled: nobody is identified, so nobody may act.
+const disabledRole = RoleViewer
+
+const callerKey = "monitoring_caller"
+
+// accessControl rejects requests whose caller lacks the role the route
+// requires. While monitoring.access is disabled every caller is a viewer,
+// so operator and admin routes need it enabled. base is the path of the
+// API group.
+func (m *Monitor) accessControl(base string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := m.config.Monitoring.Access
+		route := strings.TrimPrefix(c.FullPath(), base)
+		if publicRoutes[c.Request.Method+" "+route] {
+			c.Next()
+			return
+		}
+		required := RequiredRole(c.Request.Method, route)
+		if !cfg.Enabled {
+			if required > disabledRole {
+				response.Forbidden(c, fmt.Sprintf("This action requires the %s role; enable monitoring.access to grant it", required))
+				c.Abort()
+				return
+			}
+			c.Set(callerKey, caller{Name: actor(c), Role: disabledRole, Via: "disabled"})
+			c.Next()
+			return
+		}
+
+		who, rejected := m.identify(c, cfg)
+		if rejected != "" {
+			response.Unauthorized(c, rejected)
+			c.Abort()
+			return
+		}
+		c.Set(callerKey, who)
+		if who.Via == "api_key" {
+			// Audit trails name the key
+			c.Set("username", who.Name)
+		}
+
+		if who.Role < required {
+			m.logger.Warn("Monitoring API access denied", "caller", who.Name, "role", who.Role.String(), "required", required.String(), "path", c.Request.URL.Path)
+			response.Forbidden(c, fmt.Sprintf("This action requires the %s role", required))
+			c.Abort()
+			return
+		}
+		if directory, ok := registry.GetTyped[*accounts.Directory](m.dependencies, "accounts"); ok && who.Via == "account" {
+			directory.Touch(who.Name, c.ClientIP())
+		}
+		c.Next()
+	}
+}
+
+// identify finds the caller by API key, then by a monitoring login token,
+// then by the user a service JWT (checked here or by the jwt middleware)
+// or client certificate names, falling back to the default role. Roles
+// come from the accounts and monitoring.access.users, never from a token's
+// claims. rejected explains why an unidentified caller is refused.
+func (m *Monitor) identify(c *gin.Context, cfg config.AccessConfig) (who caller, rejected string) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		for _, k := range cfg.APIKeys {
+			if k.Key != "" && subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+				role, _ := ParseRole(k.Role)
+				return caller{Name: k.Name, Role: role, Via: "api_key"}, ""
+			}
+		}
+		return caller{}, "Invalid API key"
+	}
+
+	username := middleware.GetUsername(c)
+	secret := m.config.Auth.Secret
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && username == "" && secret != "" {
+		if m.config.Monitoring.Accounts.Enabled {
+			if claims, err := middleware.ParseMonitoringToken(token, secret); err == nil {
+				return m.accountCaller(cfg, claims)
+			}
+		}
+		switch {
+		case m.config.Auth.Type == "jwt":
+			claims, err := middleware.ParseToken(token, secret)
+			if err != nil {
+				return caller{}, "Invalid token"
+			}
+			username = claims.Username
+		case m.config.Monitoring.Accounts.Enabled:
+			return caller{}, "Invalid token"
+		}
+	}
+	if username != "" {
+		// Service users only get a role the config grants them
+		role, ok := cfg.Users[username]
+		if !ok {
+			role = cfg.DefaultRole
+		}
+		parsed, _ := ParseRole(role)
+		return caller{Name: username, Role: parsed, Via: "user"}, ""
+	}
+
+	if role, ok := ParseRole(cfg.DefaultRole); ok {
+		return caller{Name: c.ClientIP(), Role: role, Via: "default"}, ""
+	}
+	return caller{}, "Authentication required"
+}
+
+// accountCaller is the account a login token names, with the account's
+// role unless the config overrides it. Tokens of disabled accounts and
+// those issued before the account's credentials changed are refused.
+func (m *Monitor) accountCaller(cfg config.AccessConfig, claims *middleware.JWTClaims) (who caller, rejected string) {
+	directory, ok := registry.GetTyped[*accounts.Directory](m.dependencies, "accounts")
+	if !ok {
+		return caller{}, "Invalid token"
+	}
+	account, err := directory.Session(claims.Username, claims.TokenVersion)
+	switch {
+	case errors.Is(err, accounts.ErrDisabled):
+		return caller{}, "Account is disabled"
+	case errors.Is(err, accounts.ErrRevoked):
+		return caller{}, "Token was revoked"
+	case err != nil:
+		return caller{}, "Invalid token"
+	}
+	role := account.Role
+	if configured, ok := cfg.Users[account.Username]; ok {
+		role = configured
+	}
+	parsed, _ := ParseRole(role)
+	return caller{Name: account.Username, Role: parsed, Via: "account"}, ""
+}
+
+// checkAccessConfig warns about roles that do not exist, which grant
+// nothing.
+func (m *Monitor) checkAccessConfig() {
+	cfg := m.config.Monitoring.Access
+	if !cfg.Enabled {
+		return
+	}
+	if _, ok := ParseRole(cfg.DefaultRole); cfg.DefaultRole != "" && !ok {
+		m.logger.Warn("Unknown monitoring.access.default_role", "role", cfg.DefaultRole)
+	}
+	for _, k := range cfg.APIKeys {
+		if _, ok := ParseRole(k.Role); !ok {
+			m.logger.Warn("Unknown role for monitoring API key", "name", k.Name, "role", k.Role)
+		}
+	}
+	for user, role := range cfg.Users {
+		if _, ok := ParseRole(role); !ok {
+			m.logger.Warn("Unknown role for monitoring user", "user", user, "role", role)
+		}
+	}
+}
+
+// requireRole writes a 403 unless the caller has role. Handlers that write
+// the config file check it themselves, so a route missing from routeRoles
+// never opens them to operators or viewers.
+func requireRole(c *gin.Context, role Role) bool {
+	who, _ := c.Value(callerKey).(caller)
+	if who.Role < role {
+		response.Forbidden(c, fmt.Sprintf("This action requires the %s role", role))
+		return false
+	}
+	return true
+}
+
+func (m *Monitor) registerAccessRoutes(g *gin.RouterGroup) {
+	g.GET("/access/whoami", m.handleWhoAmI)
+}
+
+// handleWhoAmI tells a frontend who the caller is and what they may do.
+func (m *Monitor) handleWhoAmI(c *gin.Context) {
+	response.Success(c, whoAmI(c))
+}
+
+func whoAmI(c *gin.Context) map[string]interface{} {
+	who, _ := c.MustGet(callerKey).(caller)
+	return map[string]interface{}{
+		"name":     who.Name,
+		"role":     who.Role.String(),
+		"via":      who.Via,
+		"operator": who.Role >= RoleOperator,
+		"admin":    who.Role >= RoleAdmin,
+	}
+}
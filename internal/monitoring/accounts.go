@@ -0,0 +1,311 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/accounts"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBulkAccounts is the largest number of accounts a bulk request may
+// name.
+const maxBulkAccounts = 100
+
+// inviteSendTimeout bounds sending one invitation email.
+const inviteSendTimeout = 10 * time.Second
+
+func (m *Monitor) registerAccountRoutes(g *gin.RouterGroup) {
+	g.GET("/accounts", m.handleAccounts)
+	g.POST("/accounts", m.handleCreateAccounts)
+	g.POST("/accounts/disable", m.handleSetAccountsDisabled(true))
+	g.POST("/accounts/enable", m.handleSetAccountsDisabled(false))
+	g.POST("/accounts/:username/invite", m.handleInviteAccount)
+	g.POST("/accounts/setup", m.handleAccountSetup)
+	g.POST("/auth/login", m.handleLogin)
+	g.POST("/auth/password", m.handleChangePassword)
+}
+
+func (m *Monitor) accountDirectory(c *gin.Context) (*accounts.Directory, bool) {
+	directory, ok := registry.GetTyped[*accounts.Directory](m.dependencies, "accounts")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "ACCOUNTS_UNAVAILABLE", "Monitoring accounts are not enabled")
+	}
+	return directory, ok
+}
+
+// handleAccounts lists the accounts with their last login and activity.
+func (m *Monitor) handleAccounts(c *gin.Context) {
+	directory, ok := m.accountDirectory(c)
+	if !ok {
+		return
+	}
+	response.Success(c, directory.List())
+}
+
+// accountResult is the outcome of a bulk request for one account.
+type accountResult struct {
+	Username string            `json:"username"`
+	OK       bool              `json:"ok"`
+	Error    string            `json:"error,omitempty"`
+	Account  *accounts.Account `json:"account,omitempty"`
+	Invite   *inviteResult     `json:"invite,omitempty"`
+}
+
+// inviteResult tells whether the invitation email went out. The setup
+// link is only returned when it did not, so the admin can pass it on.
+type inviteResult struct {
+	Sent      bool      `json:"sent"`
+	Error     string    `json:"error,omitempty"`
+	SetupLink string    `json:"setup_link,omitempty"`
+	Expires   time.Time `json:"expires"`
+}
+
+// handleCreateAccounts creates accounts in bulk. Accounts without an
+// initial password are invited by email; the others must change their
+// password on first login.
+func (m *Monitor) handleCreateAccounts(c *gin.Context) {
+	directory, ok := m.accountDirectory(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Accounts []accounts.NewAccount `json:"accounts" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Accounts) == 0 {
+		response.BadRequest(c, "Provide the accounts to create")
+		return
+	}
+	if len(req.Accounts) > maxBulkAccounts {
+		response.BadRequest(c, fmt.Sprintf("At most %d accounts can be created at once", maxBulkAccounts))
+		return
+	}
+
+	results := make([]accountResult, len(req.Accounts))
+	created := 0
+	for i, n := range req.Accounts {
+		results[i].Username = n.Username
+		account, invite, err := directory.Create(n, actor(c))
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].OK, results[i].Account = true, &account
+		if invite != nil {
+			results[i].Invite = m.sendInvite(c.Request.Context(), invite)
+		}
+		created++
+	}
+	auditDetail(c, "created", created)
+	response.Success(c, gin.H{"created": created, "failed": len(results) - created, "results": results})
+}
+
+// handleSetAccountsDisabled disables or enables the named accounts.
+// Disabled accounts are refused at once, even with a valid token.
+func (m *Monitor) handleSetAccountsDisabled(disabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		directory, ok := m.accountDirectory(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Usernames []string `json:"usernames" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.Usernames) == 0 {
+			response.BadRequest(c, "Provide the usernames")
+			return
+		}
+		if len(req.Usernames) > maxBulkAccounts {
+			response.BadRequest(c, fmt.Sprintf("At most %d accounts can be changed at once", maxBulkAccounts))
+			return
+		}
+
+		who, _ := c.MustGet(callerKey).(caller)
+		results := make([]accountResult, len(req.Usernames))
+		changed := 0
+		for i, username := range req.Usernames {
+			results[i].Username = username
+			if disabled && who.Via == "account" && username == who.Name {
+				results[i].Error = "you cannot disable your own account"
+				continue
+			}
+			account, err := directory.SetDisabled(username, disabled, actor(c))
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].OK, results[i].Account = true, &account
+			changed++
+		}
+		auditDetail(c, "changed", changed)
+		response.Success(c, gin.H{"changed": changed, "failed": len(results) - changed, "results": results})
+	}
+}
+
+// handleInviteAccount sends a new setup link to an account, which replaces
+// its password and any earlier link.
+func (m *Monitor) handleInviteAccount(c *gin.Context) {
+	directory, ok := m.accountDirectory(c)
+	if !ok {
+		return
+	}
+	invite, err := directory.Invite(c.Param("username"))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	response.Success(c, m.sendInvite(c.Request.Context(), invite), "Invitation issued")
+}
+
+// sendInvite emails the setup link of invite through the "mail"
+// dependency, returning the link instead when no mailer is available or
+// sending fails.
+func (m *Monitor) sendInvite(ctx context.Context, invite *accounts.Invitation) *inviteResult {
+	result := &inviteResult{Expires: invite.Expires}
+	link := m.setupLink(invite.Token)
+	mailer, ok := registry.GetTyped[accounts.Mailer](m.dependencies, "mail")
+	if !ok {
+		result.Error = "no mailer is configured"
+		result.SetupLink = link
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, inviteSendTimeout)
+	defer cancel()
+	subject := fmt.Sprintf("You are invited to %s monitoring", m.config.App.Name)
+	body := fmt.Sprintf("Hello %s,\n\nAn account was created for you on %s monitoring. Set your password within %s at:\n\n%s\n\nThe link can be used once.\n",
+		invite.Username, m.config.App.Name, time.Until(invite.Expires).Round(time.Hour), link)
+	if err := mailer.Send(ctx, []string{invite.Email}, subject, body); err != nil {
+		m.logger.Warn("Failed to send account invitation", "username", invite.Username, "error", err.Error())
+		result.Error = err.Error()
+		result.SetupLink = link
+		return result
+	}
+	result.Sent = true
+	return result
+}
+
+func (m *Monitor) setupLink(token string) string {
+	u, err := url.Parse(m.config.Monitoring.Accounts.SetupURL)
+	if err != nil {
+		return m.config.Monitoring.Accounts.SetupURL + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// handleAccountSetup sets the password of an invited account with the
+// token of its setup link, and logs it in.
+func (m *Monitor) handleAccountSetup(c *gin.Context) {
+	directory, ok := m.accountDirectory(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Provide the setup token and a password")
+		return
+	}
+	account, err := directory.AcceptInvite(req.Token, req.Password)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	m.respondLogin(c, directory, account.Username, req.Password)
+}
+
+// handleLogin issues a token for an account's username and password.
+// Accounts that must reset their password are refused until they change
+// it at POST /auth/password.
+func (m *Monitor) handleLogin(c *gin.Context) {
+	directory, ok := m.accountDirectory(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Provide a username and password")
+		return
+	}
+	m.respondLogin(c, directory, req.Username, req.Password)
+}
+
+// handleChangePassword replaces an account's password given the current
+// one, which completes a required reset, and logs the account in.
+func (m *Monitor) handleChangePassword(c *gin.Context) {
+	directory, ok := m.accountDirectory(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Username        string `json:"username" binding:"required"`
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Provide the username, current and new password")
+		return
+	}
+	if _, err := directory.ChangePassword(req.Username, req.CurrentPassword, req.NewPassword); err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	m.respondLogin(c, directory, req.Username, req.NewPassword)
+}
+
+func (m *Monitor) respondLogin(c *gin.Context, directory *accounts.Directory, username, password string) {
+	secret := m.config.Auth.Secret
+	if secret == "" {
+		response.Error(c, http.StatusServiceUnavailable, "LOGIN_UNAVAILABLE", "auth.secret must be set to issue tokens")
+		return
+	}
+	account, err := directory.Login(username, password)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	ttl := time.Duration(m.config.Monitoring.Accounts.TokenTTL) * time.Second
+	token, err := middleware.GenerateMonitoringToken(account.Username, account.Email, account.TokenVersion, secret, ttl)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"token": token, "expires_at": time.Now().Add(ttl), "account": account})
+}
+
+func writeAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, accounts.ErrNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, accounts.ErrInvalidCredentials):
+		response.Unauthorized(c, err.Error())
+	case errors.Is(err, accounts.ErrDisabled):
+		response.Error(c, http.StatusForbidden, "ACCOUNT_DISABLED", err.Error())
+	case errors.Is(err, accounts.ErrResetRequired):
+		response.Error(c, http.StatusForbidden, "PASSWORD_RESET_REQUIRED", "The password must be changed before logging in")
+	case errors.Is(err, accounts.ErrInvalidToken):
+		response.Error(c, http.StatusBadRequest, "INVALID_SETUP_TOKEN", err.Error())
+	case errors.Is(err, accounts.ErrWeakPassword), errors.Is(err, accounts.ErrInvalid):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, accounts.ErrExists):
+		response.Conflict(c, err.Error())
+	default:
+		response.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,164 @@
+package monitoring
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackyrd/internal/alerting"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerAlertingRoutes(g *gin.RouterGroup) {
+	g.GET("/alerts", m.handleAlerts)
+	g.GET("/alerts/rules", m.handleAlertRules)
+	g.POST("/alerts/rules", m.handleCreateAlertRule)
+	g.GET("/alerts/rules/:name", m.handleAlertRule)
+	g.PUT("/alerts/rules/:name", m.handleUpdateAlertRule)
+	g.DELETE("/alerts/rules/:name", m.handleDeleteAlertRule)
+}
+
+// alertingEngine returns the engine or writes a 404 when alerting is off.
+func (m *Monitor) alertingEngine(c *gin.Context) (*alerting.Engine, bool) {
+	engine, ok := registry.GetTyped[*alerting.Engine](m.dependencies, "alerting")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "ALERTING_DISABLED", "Alerting is not enabled")
+	}
+	return engine, ok
+}
+
+// handleAlerts returns firing alerts and recent alert history, with
+// timestamps in the caller's timezone preference or the one given by tz.
+func (m *Monitor) handleAlerts(c *gin.Context) {
+	engine, ok := m.alertingEngine(c)
+	if !ok {
+		return
+	}
+	prefs, loc, ok := m.displayPreferences(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	response.Success(c, map[string]interface{}{
+		"firing":  alertsIn(engine.Firing(), loc),
+		"history": alertsIn(engine.History(limit), loc),
+		"display": displayMeta(prefs, loc),
+	})
+}
+
+func alertsIn(alerts []alerting.Alert, loc *time.Location) []alerting.Alert {
+	for i := range alerts {
+		alerts[i].StartedAt = alerts[i].StartedAt.In(loc)
+		alerts[i].Time = alerts[i].Time.In(loc)
+	}
+	return alerts
+}
+
+// handleAlertRules lists rules with their evaluation state.
+func (m *Monitor) handleAlertRules(c *gin.Context) {
+	engine, ok := m.alertingEngine(c)
+	if !ok {
+		return
+	}
+	rules := engine.Rules()
+	result := make([]map[string]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, map[string]interface{}{
+			"rule":   rule,
+			"status": engine.RuleStatus(rule.Name),
+		})
+	}
+	response.Success(c, result)
+}
+
+func (m *Monitor) handleAlertRule(c *gin.Context) {
+	engine, ok := m.alertingEngine(c)
+	if !ok {
+		return
+	}
+	rule, found := engine.GetRule(c.Param("name"))
+	if !found {
+		response.NotFound(c, "Alert rule not found")
+		return
+	}
+	response.Success(c, map[string]interface{}{
+		"rule":   rule,
+		"status": engine.RuleStatus(rule.Name),
+	})
+}
+
+func (m *Monitor) handleCreateAlertRule(c *gin.Context) {
+	engine, ok := m.alertingEngine(c)
+	if !ok {
+		return
+	}
+	var rule alerting.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	if _, exists := engine.GetRule(rule.Name); exists {
+		response.Conflict(c, "Alert rule already exists")
+		return
+	}
+	if !m.saveAlertRule(c, engine, rule) {
+		return
+	}
+	saved, _ := engine.GetRule(rule.Name)
+	response.Created(c, saved, "Alert rule created")
+}
+
+func (m *Monitor) handleUpdateAlertRule(c *gin.Context) {
+	engine, ok := m.alertingEngine(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	if _, exists := engine.GetRule(name); !exists {
+		response.NotFound(c, "Alert rule not found")
+		return
+	}
+	var rule alerting.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	rule.Name = name
+	if !m.saveAlertRule(c, engine, rule) {
+		return
+	}
+	saved, _ := engine.GetRule(name)
+	response.Success(c, saved, "Alert rule updated")
+}
+
+func (m *Monitor) handleDeleteAlertRule(c *gin.Context) {
+	engine, ok := m.alertingEngine(c)
+	if !ok {
+		return
+	}
+	if err := engine.DeleteRule(c.Param("name")); err != nil {
+		if errors.Is(err, alerting.ErrRuleNotFound) {
+			response.NotFound(c, "Alert rule not found")
+			return
+		}
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "Alert rule deleted")
+}
+
+func (m *Monitor) saveAlertRule(c *gin.Context, engine *alerting.Engine, rule alerting.Rule) bool {
+	if err := engine.PutRule(rule); err != nil {
+		if errors.Is(err, alerting.ErrInvalidRule) {
+			response.BadRequest(c, err.Error())
+		} else {
+			response.InternalServerError(c, err.Error())
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package monitoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/audit"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditBody is the largest request body copied into an audit entry.
+const maxAuditBody = 16 * 1024
+
+const auditDetailsKey = "monitoring_audit_details"
+
+// unauditedRoutes need operator or more but change nothing worth recording.
+var unauditedRoutes = map[string]bool{
+	"GET /audit": true,
+}
+
+func (m *Monitor) registerAuditRoutes(g *gin.RouterGroup) {
+	g.GET("/audit", m.handleAudit)
+}
+
+// auditTrail records requests to routes that require the operator role or
+// more, which covers every action and the reads of sensitive data, once
+// they complete. It runs before access control so refused attempts are
+// recorded too. base is the path of the API group.
+func (m *Monitor) auditTrail(base string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log, ok := registry.GetTyped[*audit.Log](m.dependencies, "audit")
+		route := strings.TrimPrefix(c.FullPath(), base)
+		action := c.Request.Method + " " + route
+		if !ok || route == "" || unauditedRoutes[action] || RequiredRole(c.Request.Method, route) < RoleOperator {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		request := auditRequestBody(c)
+		c.Next()
+
+		entry := audit.Entry{
+			Time:       start,
+			Actor:      actor(c),
+			Action:     action,
+			Path:       c.Request.URL.Path,
+			Request:    request,
+			Status:     c.Writer.Status(),
+			Success:    c.Writer.Status() < http.StatusBadRequest,
+			ClientIP:   c.ClientIP(),
+			RequestID:  c.Writer.Header().Get("X-Request-ID"),
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		if who, ok := c.Get(callerKey); ok && who.(caller).Via != "disabled" {
+			entry.Actor = who.(caller).Name
+			entry.Role = who.(caller).Role.String()
+		}
+		if len(c.Params) > 0 {
+			entry.Params = make(map[string]string, len(c.Params))
+			for _, p := range c.Params {
+				entry.Params[p.Key] = p.Value
+			}
+		}
+		if details, ok := c.Get(auditDetailsKey); ok {
+			entry.Details = details.(map[string]interface{})
+		}
+		if err := log.Record(entry); err != nil {
+			m.logger.Error("Failed to write audit entry", err, "action", action, "actor", entry.Actor)
+		}
+	}
+}
+
+// auditRequestBody returns the JSON request body with secrets masked,
+// leaving the body readable for the handler.
+func auditRequestBody(c *gin.Context) interface{} {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return nil
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil || len(raw) == 0 {
+		return nil
+	}
+	if len(raw) > maxAuditBody {
+		return map[string]interface{}{"truncated": true, "bytes": len(raw)}
+	}
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return map[string]interface{}{"bytes": len(raw)}
+	}
+	return config.MaskSecrets(body)
+}
+
+// auditDetail adds what a handler did, e.g. the saved version or queued
+// job, to the request's audit entry.
+func auditDetail(c *gin.Context, key string, value interface{}) {
+	details, ok := c.Get(auditDetailsKey)
+	if !ok {
+		details = make(map[string]interface{})
+		c.Set(auditDetailsKey, details)
+	}
+	details.(map[string]interface{})[key] = value
+}
+
+// handleAudit searches the audit log, newest first.
+//
+// Query parameters: actor, action (substring of the method, route or
+// path), success (true/false), from/to (RFC3339 or unix seconds), page and
+// per_page. Timestamps are returned in the caller's timezone preference,
+// or the one given by tz.
+func (m *Monitor) handleAudit(c *gin.Context) {
+	log, ok := registry.GetTyped[*audit.Log](m.dependencies, "audit")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "AUDIT_UNAVAILABLE", "Audit log is not enabled")
+		return
+	}
+
+	prefs, loc, ok := m.displayPreferences(c)
+	if !ok {
+		return
+	}
+
+	var page response.PaginationRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		response.BadRequest(c, "Invalid pagination parameters")
+		return
+	}
+
+	query := audit.Query{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Offset: page.GetOffset(),
+		Limit:  page.GetPerPage(),
+	}
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			response.BadRequest(c, "Invalid 'success', use true or false")
+			return
+		}
+		query.Success = &success
+	}
+	var err error
+	if query.From, err = parseTimeParam(c.Query("from")); err != nil {
+		response.BadRequest(c, "Invalid 'from' time, use RFC3339 or unix seconds")
+		return
+	}
+	if query.To, err = parseTimeParam(c.Query("to")); err != nil {
+		response.BadRequest(c, "Invalid 'to' time, use RFC3339 or unix seconds")
+		return
+	}
+
+	entries, total, err := log.Search(query)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	for i := range entries {
+		entries[i].Time = entries[i].Time.In(loc)
+	}
+	response.SuccessWithMeta(c, entries, response.CalculateMeta(page.GetPage(), page.GetPerPage(), int64(total), map[string]interface{}{
+		"display": displayMeta(prefs, loc),
+	}))
+}
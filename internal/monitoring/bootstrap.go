@@ -0,0 +1,175 @@
+package monitoring
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/i18n"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerBootstrapRoutes(g *gin.RouterGroup) {
+	g.GET("/bootstrap", m.handleBootstrap)
+	g.GET("/banner", m.handleBanner)
+}
+
+// bootstrapSection is one part of the bootstrap snapshot. MaxAge tells the
+// dashboard how many seconds it may reuse the data before fetching the
+// section's own endpoint again.
+type bootstrapSection struct {
+	Data   interface{} `json:"data,omitempty"`
+	MaxAge int         `json:"max_age"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// bootstrapSources are the sections of GET /bootstrap in the order they
+// are built, with their cache hints in seconds.
+var bootstrapSources = []struct {
+	name   string
+	maxAge int
+	build  func(m *Monitor, c *gin.Context) (interface{}, error)
+}{
+	{"app", 3600, (*Monitor).bootstrapApp},
+	{"access", 60, func(_ *Monitor, c *gin.Context) (interface{}, error) { return whoAmI(c), nil }},
+	{"preferences", 60, (*Monitor).bootstrapPreferences},
+	{"monitoring", 300, (*Monitor).bootstrapMonitoring},
+	{"status", 5, func(m *Monitor, _ *gin.Context) (interface{}, error) { return m.status(), nil }},
+	{"endpoints", 30, func(m *Monitor, _ *gin.Context) (interface{}, error) { return m.endpoints(), nil }},
+	{"cron", 30, (*Monitor).bootstrapCron},
+	{"services", 10, func(m *Monitor, c *gin.Context) (interface{}, error) { return m.serviceList(c.Request.Context()), nil }},
+}
+
+// handleBootstrap returns everything the dashboard needs for its first
+// render in one round trip. ?sections=status,cron limits the snapshot to
+// the listed sections. A failing section carries its error instead of
+// failing the whole response.
+func (m *Monitor) handleBootstrap(c *gin.Context) {
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(c.Query("sections"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[name] = true
+		}
+	}
+
+	known := make(map[string]bool, len(bootstrapSources))
+	for _, source := range bootstrapSources {
+		known[source.name] = true
+	}
+	for name := range wanted {
+		if !known[name] {
+			response.BadRequest(c, fmt.Sprintf("Unknown bootstrap section %q", name))
+			return
+		}
+	}
+
+	sections := make(map[string]bootstrapSection)
+	minAge := 0
+	for _, source := range bootstrapSources {
+		if len(wanted) > 0 && !wanted[source.name] {
+			continue
+		}
+		section := bootstrapSection{MaxAge: source.maxAge}
+		if data, err := source.build(m, c); err != nil {
+			section.Error = err.Error()
+		} else {
+			section.Data = data
+		}
+		sections[source.name] = section
+		if minAge == 0 || source.maxAge < minAge {
+			minAge = source.maxAge
+		}
+	}
+
+	// The snapshot is per caller and only as fresh as its most volatile
+	// section
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", minAge))
+	response.Success(c, map[string]interface{}{
+		"generated_at": time.Now(),
+		"sections":     sections,
+	})
+}
+
+// handleBanner returns the application name, version and banner text,
+// answering 304 to clients that already have them.
+func (m *Monitor) handleBanner(c *gin.Context) {
+	app, modified := m.appInfo()
+	m.successConditional(c, app, "", modified)
+}
+
+func (m *Monitor) bootstrapApp(_ *gin.Context) (interface{}, error) {
+	app, _ := m.appInfo()
+	return app, nil
+}
+
+// appInfo describes the application with its banner, if the banner file
+// exists, and when this changed last: at startup or when the banner file
+// was edited since.
+func (m *Monitor) appInfo() (map[string]interface{}, time.Time) {
+	app := map[string]interface{}{
+		"name":       m.config.App.Name,
+		"version":    m.config.App.Version,
+		"env":        m.config.App.Env,
+		"started_at": m.startedAt,
+	}
+	modified := m.startedAt
+	if path := m.config.App.BannerPath; path != "" {
+		if banner, err := os.ReadFile(path); err == nil {
+			app["banner"] = string(banner)
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(modified) {
+				modified = info.ModTime()
+			}
+		}
+	}
+	return app, modified
+}
+
+func (m *Monitor) bootstrapPreferences(c *gin.Context) (interface{}, error) {
+	store, ok := registry.GetTyped[*i18n.Store](m.dependencies, "preferences")
+	if !ok {
+		return nil, nil
+	}
+	user := actor(c)
+	prefs, saved, err := store.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	return preferencesResult(user, prefs, saved), nil
+}
+
+// bootstrapMonitoring reports which monitoring features are enabled, never
+// their secrets.
+func (m *Monitor) bootstrapMonitoring(_ *gin.Context) (interface{}, error) {
+	cfg := m.config.Monitoring
+	replay := map[string]interface{}{"enabled": false}
+	if logs, ok := registry.GetTyped[*logger.LogBroadcaster](m.dependencies, "logs"); ok {
+		replay = logs.ReplayStatus()
+	}
+	return map[string]interface{}{
+		"log_buffer_size":    cfg.LogBufferSize,
+		"log_history":        cfg.LogHistory.Enabled,
+		"log_replay":         replay,
+		"metrics_history":    cfg.Metrics.Enabled,
+		"web":                m.WebSource(),
+		"access_control":     cfg.Access.Enabled,
+		"audit":              cfg.Audit.Enabled,
+		"sql_readonly":       cfg.SQLReadOnly,
+		"sql_max_rows":       cfg.SQLMaxRows,
+		"sql_timeout":        cfg.SQLTimeout,
+		"query_history_size": cfg.QueryHistorySize,
+	}, nil
+}
+
+func (m *Monitor) bootstrapCron(_ *gin.Context) (interface{}, error) {
+	cron, ok := registry.GetTyped[*infrastructure.CronManager](m.dependencies, "cron")
+	if !ok {
+		return nil, nil
+	}
+	return cron.GetJobs(), nil
+}
@@ -0,0 +1,106 @@
+package monitoring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxContentVersions bounds the responses whose change time is tracked;
+// beyond it tracking starts over, which only makes Last-Modified newer.
+const maxContentVersions = 4096
+
+// contentVersions remembers since when each response has had its current
+// ETag, which serves as Last-Modified for data without a timestamp of its
+// own.
+type contentVersions struct {
+	mu   sync.Mutex
+	seen map[string]contentVersion
+}
+
+type contentVersion struct {
+	etag  string
+	since time.Time
+}
+
+func newContentVersions() *contentVersions {
+	return &contentVersions{seen: make(map[string]contentVersion)}
+}
+
+// since returns when key was first served with etag.
+func (v *contentVersions) since(key, etag string) time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if seen, ok := v.seen[key]; ok && seen.etag == etag {
+		return seen.since
+	}
+	if len(v.seen) >= maxContentVersions {
+		v.seen = make(map[string]contentVersion)
+	}
+	now := time.Now().Truncate(time.Second)
+	v.seen[key] = contentVersion{etag: etag, since: now}
+	return now
+}
+
+// etagOf returns a weak ETag of the JSON encoding of data.
+func etagOf(data interface{}) string {
+	raw, _ := json.Marshal(data)
+	sum := sha256.Sum256(raw)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// successConditional writes data like response.Success with ETag and
+// Last-Modified headers, or only 304 Not Modified when the request's
+// If-None-Match or If-Modified-Since shows the client already has it. An
+// empty etag is computed from data; a zero modified is the time data was
+// first served in its current form.
+func (m *Monitor) successConditional(c *gin.Context, data interface{}, etag string, modified time.Time) {
+	if etag == "" {
+		etag = etagOf(data)
+	}
+	if modified.IsZero() {
+		modified = m.versions.since(actor(c)+" "+c.Request.URL.RequestURI(), etag)
+	}
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	// Cached copies must be revalidated, which is what makes polling cheap
+	c.Header("Cache-Control", "private, no-cache")
+
+	if notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	response.Success(c, data)
+}
+
+// notModified evaluates the conditional headers of r; If-None-Match takes
+// precedence over If-Modified-Since (RFC 9110, 13.2.2).
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// weakETag strips the weak prefix; GET compares ETags weakly.
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
@@ -0,0 +1,298 @@
+package monitoring
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerConfigRoutes(g *gin.RouterGroup) {
+	g.GET("/config", m.handleConfig)
+	g.GET("/config/effective", m.handleEffectiveConfig)
+	g.GET("/config/schema", m.handleConfigSchema)
+	g.GET("/config/sections", m.handleConfigSections)
+	g.GET("/config/section/*path", m.handleConfigSection)
+	g.PUT("/config/section/*path", m.handleUpdateConfigSection)
+	g.GET("/config/backups", m.handleConfigBackups)
+	g.GET("/config/backups/:id/diff", m.handleConfigBackupDiff)
+	g.POST("/config/backups/:id/restore", m.handleRestoreConfigBackup)
+	g.POST("/config/backups/prune", m.handlePruneConfigBackups)
+}
+
+// SetConfigFingerprint gives the monitoring API the fingerprint of the
+// configuration the process started with, reported in /status.
+func (m *Monitor) SetConfigFingerprint(fingerprint string) {
+	m.fingerprint = fingerprint
+}
+
+// configFile returns the loaded config file for section editing or writes
+// a 404 when the configuration did not come from a file.
+func (m *Monitor) configFile(c *gin.Context) (*config.SectionFile, bool) {
+	path := config.ConfigFile()
+	if path == "" {
+		response.Error(c, http.StatusNotFound, "CONFIG_FILE_UNAVAILABLE", "Configuration was not loaded from a file")
+		return nil, false
+	}
+	file := config.NewSectionFile(path)
+	if cfg := m.config.Monitoring.ConfigBackups; cfg.Enabled {
+		file.WithBackups(config.NewBackupStore(path, cfg))
+	}
+	return file, true
+}
+
+// configBackups returns the backups of the loaded config file or writes a
+// 404 when there is no file or backups are disabled.
+func (m *Monitor) configBackups(c *gin.Context) (*config.BackupStore, bool) {
+	cfg := m.config.Monitoring.ConfigBackups
+	if !cfg.Enabled {
+		response.Error(c, http.StatusNotFound, "CONFIG_BACKUPS_DISABLED", "Config backups are disabled")
+		return nil, false
+	}
+	path := config.ConfigFile()
+	if path == "" {
+		response.Error(c, http.StatusNotFound, "CONFIG_FILE_UNAVAILABLE", "Configuration was not loaded from a file")
+		return nil, false
+	}
+	return config.NewBackupStore(path, cfg), true
+}
+
+// configModified is when the config file last changed, for Last-Modified.
+func configModified() time.Time {
+	info, err := os.Stat(config.ConfigFile())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// handleConfig returns the running configuration, as loaded with
+// references resolved and values decrypted, with secrets redacted. Unlike
+// the sections it reflects defaults and environment overrides.
+func (m *Monitor) handleConfig(c *gin.Context) {
+	var redacted interface{}
+	config.View(func() { redacted = config.Redact(m.config) })
+	response.Success(c, redacted)
+}
+
+// handleEffectiveConfig returns the effective configuration, merged from
+// defaults, the config file, its environment overlay and environment
+// variables, as redacted leaves with the layer each came from. ?prefix=
+// narrows to a section and ?source= to one layer (e.g. "default" or
+// "config.production.yaml").
+func (m *Monitor) handleEffectiveConfig(c *gin.Context) {
+	layers := config.LoadedLayers()
+	prefix := strings.ToLower(strings.Trim(c.Query("prefix"), "."))
+	source := c.Query("source")
+
+	keys := []config.EffectiveKey{}
+	for _, key := range config.EffectiveKeys(m.config, layers) {
+		if prefix != "" && key.Key != prefix && !strings.HasPrefix(key.Key, prefix+".") {
+			continue
+		}
+		if source != "" && key.Source != source && !strings.HasSuffix(key.Source, "/"+source) && !strings.HasPrefix(key.Source, source+":") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	files := layers.Files
+	if files == nil {
+		files = []string{}
+	}
+	response.Success(c, gin.H{
+		"environment": layers.Environment,
+		"files":       files,
+		"keys":        keys,
+	})
+}
+
+// handleConfigSchema serves the JSON Schema of the config file as is, so
+// editors can point at it, or with ?section= the schema of one section,
+// e.g. before editing it through /config/section.
+func (m *Monitor) handleConfigSchema(c *gin.Context) {
+	schema := config.Schema()
+	if section := c.Query("section"); section != "" {
+		var err error
+		if schema, err = config.SectionSchema(section); err != nil {
+			response.NotFound(c, err.Error())
+			return
+		}
+	}
+	c.Header("Content-Type", "application/schema+json")
+	c.JSON(http.StatusOK, schema)
+}
+
+// handleConfigSections lists the top-level sections with their versions
+// and sizes, so clients fetch only the sections they need. Like a single
+// section it supports conditional GET.
+func (m *Monitor) handleConfigSections(c *gin.Context) {
+	file, ok := m.configFile(c)
+	if !ok {
+		return
+	}
+	sections, err := file.Sections()
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	m.successConditional(c, sections, "", configModified())
+}
+
+// handleConfigSection returns one section, e.g. /config/section/postgres
+// or /config/section/postgres/connections/0, with secrets masked. The
+// section version is the ETag, so If-None-Match with it answers 304 while
+// the section is unchanged.
+func (m *Monitor) handleConfigSection(c *gin.Context) {
+	file, ok := m.configFile(c)
+	if !ok {
+		return
+	}
+	section, err := file.Get(c.Param("path"))
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	m.successConditional(c, section, `"`+section.Version+`"`, configModified())
+}
+
+type configSectionUpdate struct {
+	Value   interface{} `json:"value"`
+	Version string      `json:"version"`
+}
+
+// handleUpdateConfigSection validates and saves one section. The version
+// read before editing goes in the body or the If-Match header; saving
+// fails with 409 when that section changed meanwhile, while edits to other
+// sections never conflict. The running configuration changes on restart,
+// which dev mode triggers on its own.
+func (m *Monitor) handleUpdateConfigSection(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+	file, ok := m.configFile(c)
+	if !ok {
+		return
+	}
+	var update configSectionUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	if update.Version == "" {
+		update.Version = strings.Trim(c.GetHeader("If-Match"), `"`)
+	}
+
+	section, err := file.Put(c.Param("path"), update.Value, update.Version)
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	m.logger.Info("Config section saved", "section", section.Path, "by", actor(c))
+	auditDetail(c, "section", section.Path)
+	auditDetail(c, "previous_version", update.Version)
+	auditDetail(c, "version", section.Version)
+	c.Header("ETag", `"`+section.Version+`"`)
+	response.Success(c, map[string]interface{}{
+		"section":          section,
+		"restart_required": !m.config.DevMode(),
+	}, "Config section saved")
+}
+
+// handleConfigBackups lists the backups taken before config edits,
+// newest first.
+func (m *Monitor) handleConfigBackups(c *gin.Context) {
+	backups, ok := m.configBackups(c)
+	if !ok {
+		return
+	}
+	list, err := backups.List()
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, list)
+}
+
+// handleConfigBackupDiff returns the unified diff from a backup to the
+// current config file, with secret values masked.
+func (m *Monitor) handleConfigBackupDiff(c *gin.Context) {
+	backups, ok := m.configBackups(c)
+	if !ok {
+		return
+	}
+	diff, err := backups.Diff(c.Param("id"))
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	response.Success(c, map[string]interface{}{
+		"id":      c.Param("id"),
+		"changed": diff != "",
+		"diff":    diff,
+	})
+}
+
+// handleRestoreConfigBackup replaces the config file with a backup. The
+// replaced file is backed up first, so the restore itself can be undone.
+// Like section edits it applies on restart.
+func (m *Monitor) handleRestoreConfigBackup(c *gin.Context) {
+	if !requireRole(c, RoleAdmin) {
+		return
+	}
+	backups, ok := m.configBackups(c)
+	if !ok {
+		return
+	}
+	previous, err := backups.Restore(c.Param("id"))
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	m.logger.Info("Config backup restored", "backup", c.Param("id"), "by", actor(c))
+	auditDetail(c, "backup", c.Param("id"))
+	auditDetail(c, "previous_backup", previous.ID)
+	response.Success(c, map[string]interface{}{
+		"restored":         c.Param("id"),
+		"previous":         previous,
+		"restart_required": !m.config.DevMode(),
+	}, "Config backup restored")
+}
+
+// handlePruneConfigBackups removes the backups beyond
+// monitoring.config_backups.keep and max_age.
+func (m *Monitor) handlePruneConfigBackups(c *gin.Context) {
+	backups, ok := m.configBackups(c)
+	if !ok {
+		return
+	}
+	removed, err := backups.Prune()
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	auditDetail(c, "removed", len(removed))
+	response.Success(c, removed, "Config backups pruned")
+}
+
+func writeConfigError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, config.ErrSectionNotFound):
+		response.NotFound(c, "Config section not found")
+	case errors.Is(err, config.ErrBackupNotFound):
+		response.NotFound(c, "Config backup not found")
+	case errors.Is(err, config.ErrVersionConflict):
+		response.Conflict(c, err.Error())
+	case errors.Is(err, os.ErrNotExist):
+		response.Error(c, http.StatusNotFound, "CONFIG_FILE_UNAVAILABLE", err.Error())
+	case errors.Is(err, config.ErrInvalidSection):
+		response.Error(c, http.StatusUnprocessableEntity, "INVALID_CONFIG", err.Error())
+	default:
+		response.InternalServerError(c, err.Error())
+	}
+}
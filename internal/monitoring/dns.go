@@ -0,0 +1,31 @@
+package monitoring
+
+import (
+	"stackyrd/pkg/dns"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerDNSRoutes(g *gin.RouterGroup) {
+	g.GET("/dns", m.handleDNS)
+}
+
+// handleDNS reports resolution status of the configured hosts and the DNS
+// cache statistics. ?refresh=true resolves all hosts before responding.
+func (m *Monitor) handleDNS(c *gin.Context) {
+	result := gin.H{"checks_enabled": false, "cache_enabled": false}
+	if checker, ok := registry.GetTyped[*dns.Checker](m.dependencies, "dns"); ok {
+		if c.Query("refresh") == "true" {
+			checker.CheckAll(c.Request.Context())
+		}
+		result["checks_enabled"] = true
+		result["hosts"] = checker.Hosts()
+	}
+	if cache, ok := registry.GetTyped[*dns.Cache](m.dependencies, "dns_cache"); ok {
+		result["cache_enabled"] = true
+		result["cache"] = cache.Stats()
+	}
+	response.Success(c, result)
+}
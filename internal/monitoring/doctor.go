@@ -0,0 +1,26 @@
+package monitoring
+
+import (
+	"strings"
+
+	"stackyrd/internal/doctor"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerDoctorRoutes(g *gin.RouterGroup) {
+	g.GET("/doctor", m.handleDoctor)
+}
+
+// handleDoctor runs the environment self-test. ?category= limits it to a
+// comma-separated list of categories (connectivity, permissions, disk,
+// clock, config).
+func (m *Monitor) handleDoctor(c *gin.Context) {
+	var categories []string
+	if q := c.Query("category"); q != "" {
+		categories = strings.Split(q, ",")
+	}
+	report := doctor.New(m.config, m.dependencies).Run(c.Request.Context(), categories...)
+	response.Success(c, report)
+}
@@ -0,0 +1,170 @@
+package monitoring
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/endpointstats"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerEndpointRoutes(g *gin.RouterGroup) {
+	g.GET("/endpoints", m.handleEndpoints)
+	g.PUT("/endpoints/toggle", m.handleEndpointToggle)
+	g.GET("/endpoints/stats", m.handleEndpointStats)
+	g.DELETE("/endpoints/stats", m.handleResetEndpointStats)
+}
+
+// SetRoutes gives the monitoring API access to the server's route table for
+// the endpoints listing.
+func (m *Monitor) SetRoutes(routes func() gin.RoutesInfo) {
+	m.routes = routes
+}
+
+type endpointInfo struct {
+	Method  string                     `json:"method"`
+	Path    string                     `json:"path"`
+	Handler string                     `json:"handler"`
+	Enabled bool                       `json:"enabled"`
+	Toggle  *middleware.EndpointToggle `json:"toggle,omitempty"`
+	Stats   *endpointstats.RouteStats  `json:"stats,omitempty"`
+}
+
+// handleEndpoints lists every registered route with its kill switch state
+// and traffic, plus all configured toggles (which may be prefixes).
+func (m *Monitor) handleEndpoints(c *gin.Context) {
+	m.successConditional(c, m.endpoints(), "", time.Time{})
+}
+
+func (m *Monitor) endpoints() map[string]interface{} {
+	toggles := middleware.GetEndpointToggles()
+	traffic := make(map[string]endpointstats.RouteStats)
+	for _, s := range endpointstats.Default().Snapshot() {
+		traffic[s.Method+" "+s.Route] = s
+	}
+	var endpoints []endpointInfo
+	if m.routes != nil {
+		for _, r := range m.routes() {
+			info := endpointInfo{Method: r.Method, Path: r.Path, Handler: r.Handler, Enabled: true}
+			if toggle, disabled := toggles.Match(r.Method, r.Path); disabled {
+				info.Enabled = false
+				info.Toggle = &toggle
+			}
+			if s, ok := traffic[r.Method+" "+r.Path]; ok {
+				info.Stats = &s
+			}
+			endpoints = append(endpoints, info)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return map[string]interface{}{
+		"endpoints": endpoints,
+		"toggles":   toggles.List(),
+	}
+}
+
+type endpointToggleRequest struct {
+	Method  string `json:"method"`
+	Path    string `json:"path" binding:"required"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+// handleEndpointToggle disables or re-enables a route at runtime. Runtime
+// toggles are not persisted; config toggles return after a restart.
+func (m *Monitor) handleEndpointToggle(c *gin.Context) {
+	var req endpointToggleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body: path and enabled are required")
+		return
+	}
+	// The kill switch API itself must stay reachable
+	if strings.HasPrefix(req.Path, "/api/endpoints") || req.Path == "/*" || req.Path == "/api/*" {
+		response.Error(c, http.StatusUnprocessableEntity, "ENDPOINT_PROTECTED", "The endpoint toggle API cannot be disabled")
+		return
+	}
+
+	toggles := middleware.GetEndpointToggles()
+	if *req.Enabled {
+		if !toggles.Enable(req.Method, req.Path) {
+			response.NotFound(c, "Endpoint is not disabled")
+			return
+		}
+		m.logger.Warn("Endpoint re-enabled", "method", req.Method, "path", req.Path, "by", actor(c))
+		response.Success(c, nil, "Endpoint enabled")
+		return
+	}
+
+	toggle := toggles.Disable(req.Method, req.Path, req.Reason, actor(c))
+	m.logger.Warn("Endpoint disabled", "method", toggle.Method, "path", toggle.Path, "reason", req.Reason, "by", toggle.DisabledBy)
+	response.Success(c, toggle, "Endpoint disabled")
+}
+
+// endpointStatsOrders sort /endpoints/stats, busiest or slowest first.
+var endpointStatsOrders = map[string]func(a, b endpointstats.RouteStats) bool{
+	"requests":       func(a, b endpointstats.RouteStats) bool { return a.Requests > b.Requests },
+	"errors":         func(a, b endpointstats.RouteStats) bool { return a.Errors > b.Errors },
+	"p95":            func(a, b endpointstats.RouteStats) bool { return a.P95LatencyMs > b.P95LatencyMs },
+	"p99":            func(a, b endpointstats.RouteStats) bool { return a.P99LatencyMs > b.P99LatencyMs },
+	"response_bytes": func(a, b endpointstats.RouteStats) bool { return a.ResponseBytes > b.ResponseBytes },
+}
+
+// handleEndpointStats returns request count, status classes, latency
+// percentiles and payload sizes per route recorded by the endpoint_stats
+// middleware.
+//
+// Query parameters: sort (requests, errors, p95, p99 or response_bytes;
+// default requests) and limit.
+func (m *Monitor) handleEndpointStats(c *gin.Context) {
+	order := c.DefaultQuery("sort", "requests")
+	less, ok := endpointStatsOrders[order]
+	if !ok {
+		response.BadRequest(c, "Invalid sort, use requests, errors, p95, p99 or response_bytes")
+		return
+	}
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			response.BadRequest(c, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	stats := endpointstats.Default()
+	routes := stats.Snapshot()
+	sort.SliceStable(routes, func(i, j int) bool { return less(routes[i], routes[j]) })
+	var total, errors int64
+	for _, r := range routes {
+		total += r.Requests
+		errors += r.Errors
+	}
+	if limit > 0 && len(routes) > limit {
+		routes = routes[:limit]
+	}
+	response.Success(c, map[string]interface{}{
+		"since":    stats.Since(),
+		"requests": total,
+		"errors":   errors,
+		"routes":   routes,
+	})
+}
+
+// handleResetEndpointStats clears the per-route figures.
+func (m *Monitor) handleResetEndpointStats(c *gin.Context) {
+	endpointstats.Default().Reset()
+	m.logger.Info("Endpoint stats reset", "by", actor(c))
+	response.Success(c, nil, "Endpoint stats reset")
+}
@@ -0,0 +1,65 @@
+package monitoring
+
+import (
+	"net/http"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerExternalRoutes(g *gin.RouterGroup) {
+	g.GET("/external", m.handleExternal)
+	g.GET("/external/history", m.handleExternalHistory)
+}
+
+func (m *Monitor) externalChecks(c *gin.Context) (*infrastructure.HTTPManager, bool) {
+	manager, ok := registry.GetTyped[*infrastructure.HTTPManager](m.dependencies, "external")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "EXTERNAL_UNAVAILABLE", "No external services are configured")
+	}
+	return manager, ok
+}
+
+// handleExternal returns the latest check, uptime and latency percentiles
+// of every external service.
+func (m *Monitor) handleExternal(c *gin.Context) {
+	manager, ok := m.externalChecks(c)
+	if !ok {
+		return
+	}
+	m.successConditional(c, manager.Statuses(), "", time.Time{})
+}
+
+// handleExternalHistory returns the kept checks of ?service=, or of every
+// service, optionally only those since ?from= (RFC3339 or unix seconds),
+// for latency and uptime charts.
+func (m *Monitor) handleExternalHistory(c *gin.Context) {
+	manager, ok := m.externalChecks(c)
+	if !ok {
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		response.BadRequest(c, "Invalid 'from' time, use RFC3339 or unix seconds")
+		return
+	}
+
+	history := make(map[string][]infrastructure.HTTPCheck)
+	if service := c.Query("service"); service != "" {
+		checks, ok := manager.History(service, from)
+		if !ok {
+			response.NotFound(c, "Unknown external service: "+service)
+			return
+		}
+		history[service] = checks
+	} else {
+		for _, status := range manager.Statuses() {
+			history[status.Name], _ = manager.History(status.Name, from)
+		}
+	}
+	m.successConditional(c, history, "", time.Time{})
+}
@@ -0,0 +1,27 @@
+package monitoring
+
+import (
+	"time"
+
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/topology"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerGraphRoutes(g *gin.RouterGroup) {
+	g.GET("/graph", m.handleGraph)
+}
+
+// SetServices gives the monitoring API the discovered services for the
+// dependency graph and the services list.
+func (m *Monitor) SetServices(services []interfaces.Service) {
+	m.services = services
+}
+
+// handleGraph returns the dependency graph of services, infrastructure and
+// external services as nodes and edges colored by health, for the
+// dashboard's topology view.
+func (m *Monitor) handleGraph(c *gin.Context) {
+	m.successConditional(c, topology.Build(m.config.App.Name, m.dependencies, m.services), "", time.Time{})
+}
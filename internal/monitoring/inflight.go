@@ -0,0 +1,54 @@
+package monitoring
+
+import (
+	"strconv"
+
+	"stackyrd/pkg/inflight"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerInFlightRoutes(g *gin.RouterGroup) {
+	g.GET("/requests/inflight", m.handleInFlight)
+	g.DELETE("/requests/inflight/:id", m.handleCancelInFlight)
+}
+
+// handleInFlight lists the requests being served by the inflight
+// middleware, longest running first.
+//
+// Query parameters: min_duration_ms, to hide faster requests.
+func (m *Monitor) handleInFlight(c *gin.Context) {
+	var minMs float64
+	if value := c.Query("min_duration_ms"); value != "" {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n < 0 {
+			response.BadRequest(c, "Invalid min_duration_ms")
+			return
+		}
+		minMs = n
+	}
+	requests := make([]inflight.Request, 0)
+	for _, r := range inflight.Default().List() {
+		if r.DurationMs >= minMs {
+			requests = append(requests, r)
+		}
+	}
+	response.Success(c, map[string]interface{}{
+		"count":    len(requests),
+		"requests": requests,
+	})
+}
+
+// handleCancelInFlight cancels the context of a request in flight. The
+// handler stops at its next context check; one ignoring its context runs
+// to completion.
+func (m *Monitor) handleCancelInFlight(c *gin.Context) {
+	req, ok := inflight.Default().Cancel(c.Param("id"))
+	if !ok {
+		response.NotFound(c, "Request is not in flight")
+		return
+	}
+	m.logger.Warn("In-flight request cancelled", "method", req.Method, "path", req.Path, "correlation_id", req.CorrelationID, "by", actor(c))
+	response.Success(c, req, "Request cancelled")
+}
@@ -0,0 +1,48 @@
+package monitoring
+
+import (
+	"net/http"
+	"strconv"
+
+	"stackyrd/internal/jobs"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerJobRoutes(g *gin.RouterGroup) {
+	g.GET("/jobs", m.handleJobs)
+	g.GET("/jobs/:id", m.handleJob)
+}
+
+func (m *Monitor) jobManager(c *gin.Context) (*jobs.Manager, bool) {
+	manager, ok := registry.GetTyped[*jobs.Manager](m.dependencies, "jobs")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "JOBS_UNAVAILABLE", "Job manager is not running")
+	}
+	return manager, ok
+}
+
+// handleJobs lists background jobs, newest first, optionally by type.
+func (m *Monitor) handleJobs(c *gin.Context) {
+	manager, ok := m.jobManager(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	response.Success(c, manager.List(c.Query("type"), limit))
+}
+
+func (m *Monitor) handleJob(c *gin.Context) {
+	manager, ok := m.jobManager(c)
+	if !ok {
+		return
+	}
+	job, found := manager.Get(c.Param("id"))
+	if !found {
+		response.NotFound(c, "Job not found")
+		return
+	}
+	response.Success(c, job)
+}
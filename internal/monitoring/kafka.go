@@ -0,0 +1,208 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds of the Kafka console.
+const (
+	defaultKafkaMessages = 20
+	maxKafkaMessages     = 500
+	kafkaReadTimeout     = 10 * time.Second
+	kafkaTailMaxDuration = 10 * time.Minute // forgotten dashboards do not hold consumers forever
+	kafkaTailHeartbeat   = 15 * time.Second
+)
+
+func (m *Monitor) registerKafkaRoutes(g *gin.RouterGroup) {
+	g.GET("/kafka/topics", m.handleKafkaTopics)
+	g.GET("/kafka/topics/:topic/messages", m.handleKafkaMessages)
+	g.POST("/kafka/topics/:topic/messages", m.handleKafkaPublish)
+	g.GET("/kafka/topics/:topic/tail", m.handleKafkaTail)
+}
+
+func (m *Monitor) kafkaManager(c *gin.Context) (*infrastructure.KafkaManager, bool) {
+	manager, ok := registry.GetTyped[*infrastructure.KafkaManager](m.dependencies, "kafka")
+	if !ok || manager == nil {
+		response.Error(c, http.StatusNotFound, "KAFKA_UNAVAILABLE", "Kafka is not enabled")
+		return nil, false
+	}
+	return manager, true
+}
+
+func (m *Monitor) handleKafkaTopics(c *gin.Context) {
+	manager, ok := m.kafkaManager(c)
+	if !ok {
+		return
+	}
+	topics, err := manager.Topics(c.Request.Context())
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, topics)
+}
+
+// kafkaReadParams parses ?partition= (all by default) and ?decode= (auto,
+// json, string or base64).
+func kafkaReadParams(c *gin.Context) (partition int32, decode string, ok bool) {
+	partition = -1
+	if raw := c.Query("partition"); raw != "" {
+		p, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || p < 0 {
+			response.BadRequest(c, "partition must be a non-negative number")
+			return 0, "", false
+		}
+		partition = int32(p)
+	}
+	decode = c.DefaultQuery("decode", infrastructure.KafkaDecodeAuto)
+	switch decode {
+	case infrastructure.KafkaDecodeAuto, infrastructure.KafkaDecodeJSON, infrastructure.KafkaDecodeString, infrastructure.KafkaDecodeBase64:
+		return partition, decode, true
+	}
+	response.BadRequest(c, "decode must be auto, json, string or base64")
+	return 0, "", false
+}
+
+// handleKafkaMessages returns the last ?limit= (default 20, at most 500)
+// messages of a topic, newest first. When reading takes too long the
+// messages read so far are returned with complete false.
+func (m *Monitor) handleKafkaMessages(c *gin.Context) {
+	manager, ok := m.kafkaManager(c)
+	if !ok {
+		return
+	}
+	partition, decode, ok := kafkaReadParams(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultKafkaMessages)))
+	if limit <= 0 {
+		limit = defaultKafkaMessages
+	}
+	limit = min(limit, maxKafkaMessages)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), kafkaReadTimeout)
+	defer cancel()
+	messages, err := manager.LastMessages(ctx, c.Param("topic"), partition, limit, decode)
+	complete := true
+	if errors.Is(err, context.DeadlineExceeded) {
+		complete, err = false, nil
+	}
+	if err != nil {
+		writeKafkaError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"messages": messages, "count": len(messages), "complete": complete})
+}
+
+// handleKafkaPublish produces a test message. A JSON string value is sent
+// as its text, any other JSON value as is.
+func (m *Monitor) handleKafkaPublish(c *gin.Context) {
+	manager, ok := m.kafkaManager(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Key     string            `json:"key"`
+		Value   json.RawMessage   `json:"value" binding:"required"`
+		Headers map[string]string `json:"headers"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Provide the message value")
+		return
+	}
+	value := []byte(req.Value)
+	var text string
+	if json.Unmarshal(req.Value, &text) == nil {
+		value = []byte(text)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), kafkaReadTimeout)
+	defer cancel()
+	topic := c.Param("topic")
+	partition, offset, err := manager.PublishTest(ctx, topic, []byte(req.Key), value, req.Headers)
+	if err != nil {
+		writeKafkaError(c, err)
+		return
+	}
+	auditDetail(c, "partition", partition)
+	auditDetail(c, "offset", offset)
+	response.Success(c, gin.H{"topic": topic, "partition": partition, "offset": offset}, "Message published")
+}
+
+// handleKafkaTail streams the messages produced to a topic from now on as
+// server-sent "message" events, for at most ten minutes. A failure ends
+// the stream with an "error" event.
+func (m *Monitor) handleKafkaTail(c *gin.Context) {
+	manager, ok := m.kafkaManager(c)
+	if !ok {
+		return
+	}
+	partition, decode, ok := kafkaReadParams(c)
+	if !ok {
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), kafkaTailMaxDuration)
+	defer cancel()
+	messages := make(chan infrastructure.KafkaMessage)
+	done := make(chan error, 1)
+	go func() {
+		done <- manager.TailMessages(ctx, c.Param("topic"), partition, decode, func(msg infrastructure.KafkaMessage) error {
+			select {
+			case messages <- msg:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	heartbeat := time.NewTicker(kafkaTailHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg := <-messages:
+			c.SSEvent("message", msg)
+			return true
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		case err := <-done:
+			if err != nil && ctx.Err() == nil {
+				c.SSEvent("error", gin.H{"message": err.Error()})
+			}
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+func writeKafkaError(c *gin.Context, err error) {
+	if errors.Is(err, infrastructure.ErrUnknownTopic) {
+		response.NotFound(c, err.Error())
+		return
+	}
+	response.InternalServerError(c, err.Error())
+}
@@ -0,0 +1,115 @@
+package monitoring
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerLogRoutes(g *gin.RouterGroup) {
+	g.GET("/logs/history", m.handleLogHistory)
+	g.GET("/logs/stats", m.handleLogStats)
+}
+
+// logStats is the broadcaster accounting plus the delivery of each
+// logging.sinks entry.
+type logStats struct {
+	logger.BroadcasterStats
+	Sinks []logger.SinkStats `json:"sinks"`
+}
+
+// handleLogStats reports how log lines reach their consumers: the lines
+// each subscriber lost to a full buffer, those stream clients missed by
+// falling behind the ring buffer, and those each sink wrote, dropped or
+// failed to deliver.
+func (m *Monitor) handleLogStats(c *gin.Context) {
+	logs, ok := registry.GetTyped[*logger.LogBroadcaster](m.dependencies, "logs")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "LOGS_UNAVAILABLE", "Log stats are not available")
+		return
+	}
+	stats := logStats{BroadcasterStats: logs.Stats(), Sinks: []logger.SinkStats{}}
+	if sinks, ok := registry.GetTyped[*logger.SinkSet](m.dependencies, "log_sinks"); ok {
+		stats.Sinks = sinks.Stats()
+	}
+	response.Success(c, stats)
+}
+
+// maxLogHistoryWindow bounds how deep log history pages go, as a search
+// keeps the matches up to the end of the page in memory.
+const maxLogHistoryWindow = 10000
+
+// handleLogHistory searches past log lines, newest first.
+//
+// Query parameters: q (substring), level (minimum level), from/to (RFC3339
+// or unix seconds), page and per_page (at most 100). Pages past the newest
+// maxLogHistoryWindow matches give the last page within it. Timestamps are returned in the
+// caller's timezone preference, or the one given by tz.
+func (m *Monitor) handleLogHistory(c *gin.Context) {
+	logs, ok := registry.GetTyped[*logger.LogBroadcaster](m.dependencies, "logs")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "LOGS_UNAVAILABLE", "Log history is not available")
+		return
+	}
+
+	prefs, loc, ok := m.displayPreferences(c)
+	if !ok {
+		return
+	}
+
+	var page response.PaginationRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		response.BadRequest(c, "Invalid pagination parameters")
+		return
+	}
+
+	perPage := page.GetPerPage()
+	if last := maxLogHistoryWindow / perPage; page.GetPage() > last {
+		page.Page = last
+	}
+	query := logger.LogQuery{
+		Query:  c.Query("q"),
+		Level:  c.Query("level"),
+		Offset: page.GetOffset(),
+		Limit:  perPage,
+	}
+	var err error
+	if query.From, err = parseTimeParam(c.Query("from")); err != nil {
+		response.BadRequest(c, "Invalid 'from' time, use RFC3339 or unix seconds")
+		return
+	}
+	if query.To, err = parseTimeParam(c.Query("to")); err != nil {
+		response.BadRequest(c, "Invalid 'to' time, use RFC3339 or unix seconds")
+		return
+	}
+
+	entries, total, err := logs.Search(query)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	for i := range entries {
+		entries[i].Time = entries[i].Time.In(loc)
+	}
+	response.SuccessWithMeta(c, entries, response.CalculateMeta(page.GetPage(), page.GetPerPage(), int64(total), map[string]interface{}{
+		"persistent": logs.Persistent(),
+		"display":    displayMeta(prefs, loc),
+	}))
+}
+
+// parseTimeParam accepts RFC3339 or unix seconds; empty means unset.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,89 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/outbox"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerMessagingRoutes(g *gin.RouterGroup) {
+	g.GET("/messaging/buffer", m.handleMessageBuffer)
+	g.POST("/messaging/buffer/flush", m.handleMessageBufferFlush)
+	g.GET("/messaging/outbox", m.handleOutbox)
+	g.POST("/messaging/outbox/relay", m.handleOutboxRelay)
+}
+
+func (m *Monitor) messageBuffer() (*infrastructure.BufferedBroker, bool) {
+	return registry.GetTyped[*infrastructure.BufferedBroker](m.dependencies, "messaging")
+}
+
+// handleMessageBuffer returns outbound buffer depth, age and counters.
+func (m *Monitor) handleMessageBuffer(c *gin.Context) {
+	buffer, ok := m.messageBuffer()
+	if !ok {
+		response.Success(c, map[string]interface{}{"enabled": false})
+		return
+	}
+	stats := buffer.Stats()
+	stats["enabled"] = true
+	response.Success(c, stats)
+}
+
+// handleMessageBufferFlush replays buffered messages immediately.
+func (m *Monitor) handleMessageBufferFlush(c *gin.Context) {
+	buffer, ok := m.messageBuffer()
+	if !ok {
+		response.Error(c, http.StatusNotFound, "BUFFER_DISABLED", "Outbound message buffering is not enabled")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	replayed, err := buffer.Replay(ctx)
+	result := buffer.Stats()
+	result["replayed"] = replayed
+	auditDetail(c, "replayed", replayed)
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	response.Success(c, result)
+}
+
+// handleOutbox returns the outbox backlog, its age and relay counters.
+func (m *Monitor) handleOutbox(c *gin.Context) {
+	box, ok := registry.GetTyped[*outbox.Outbox](m.dependencies, "outbox")
+	if !ok {
+		response.Success(c, map[string]interface{}{"enabled": false})
+		return
+	}
+	stats := box.Stats(c.Request.Context())
+	stats["enabled"] = true
+	response.Success(c, stats)
+}
+
+// handleOutboxRelay relays pending outbox events immediately.
+func (m *Monitor) handleOutboxRelay(c *gin.Context) {
+	box, ok := registry.GetTyped[*outbox.Outbox](m.dependencies, "outbox")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "OUTBOX_DISABLED", "The outbox is not enabled")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	relayed, err := box.Relay(ctx)
+	result := box.Stats(ctx)
+	result["relayed"] = relayed
+	auditDetail(c, "relayed", relayed)
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	response.Success(c, result)
+}
@@ -0,0 +1,74 @@
+package monitoring
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/sysinfo"
+	"stackyrd/pkg/timeseries"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerMetricsRoutes(g *gin.RouterGroup) {
+	g.GET("/metrics/history", m.handleMetricsHistory)
+	g.GET("/system", m.handleSystem)
+}
+
+// handleSystem returns host CPU, memory, disk, network and load figures in
+// the same schema on every platform; "available" flags the groups the
+// platform could not provide so the dashboard can show them as such
+// instead of empty.
+func (m *Monitor) handleSystem(c *gin.Context) {
+	response.Success(c, sysinfo.Default().Collect())
+}
+
+// handleMetricsHistory returns the series of ?metrics= (comma separated,
+// all by default) at ?resolution= raw, 1m (default) or 1h, optionally
+// limited to ?from= and ?to= (RFC3339 or unix seconds), for charts.
+func (m *Monitor) handleMetricsHistory(c *gin.Context) {
+	history, ok := registry.GetTyped[*timeseries.History](m.dependencies, "metrics_history")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "METRICS_HISTORY_UNAVAILABLE", "Metrics history is not enabled")
+		return
+	}
+	resolution, err := timeseries.ParseResolution(c.DefaultQuery("resolution", string(timeseries.Minute)))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		response.BadRequest(c, "Invalid 'from' time, use RFC3339 or unix seconds")
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		response.BadRequest(c, "Invalid 'to' time, use RFC3339 or unix seconds")
+		return
+	}
+
+	names := history.Metrics()
+	if raw := c.Query("metrics"); raw != "" {
+		names = strings.Split(raw, ",")
+	}
+	series := make(map[string][]timeseries.Point, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		points, ok := history.Query(name, resolution, from, to)
+		if !ok {
+			response.NotFound(c, "Unknown metric: "+name)
+			return
+		}
+		series[name] = points
+	}
+	m.successConditional(c, gin.H{
+		"resolution":       resolution,
+		"interval_seconds": history.Interval().Seconds(),
+		"metrics":          history.Metrics(),
+		"series":           series,
+	}, "", time.Time{})
+}
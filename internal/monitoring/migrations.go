@@ -0,0 +1,48 @@
+package monitoring
+
+import (
+	"stackyrd/pkg/models"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelRegistration is a models.Registration with the model type names.
+type modelRegistration struct {
+	Service    string   `json:"service"`
+	Connection string   `json:"connection"` // "" is the default connection, "*" every one
+	Models     []string `json:"models"`
+}
+
+// handlePostgresMigrations lists the tables migrated from the registered
+// GORM models, with their columns and the outcome of the last migration
+// (?connection= narrows them to one connection), and the registrations.
+func (m *Monitor) handlePostgresMigrations(c *gin.Context) {
+	connection := c.Query("connection")
+	schemas := []models.Result{}
+	failed := 0
+	for _, result := range models.Applied() {
+		if connection != "" && result.Connection != connection {
+			continue
+		}
+		if result.Error != "" {
+			failed++
+		}
+		schemas = append(schemas, result)
+	}
+
+	registrations := []modelRegistration{}
+	for _, reg := range models.Registered() {
+		names := make([]string, 0, len(reg.Models))
+		for _, model := range reg.Models {
+			names = append(names, models.ModelName(model))
+		}
+		registrations = append(registrations, modelRegistration{Service: reg.Service, Connection: reg.Connection, Models: names})
+	}
+
+	response.Success(c, gin.H{
+		"schemas":       schemas,
+		"failed":        failed,
+		"registrations": registrations,
+	})
+}
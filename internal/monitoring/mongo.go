@@ -0,0 +1,303 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const mongoBrowseTimeout = 30 * time.Second
+
+func (m *Monitor) registerMongoRoutes(g *gin.RouterGroup) {
+	g.GET("/mongo/collections", m.handleMongoCollections)
+	g.GET("/mongo/collections/:collection/documents", m.handleMongoDocuments)
+	g.GET("/mongo/collections/:collection/stats", m.handleMongoCollectionStats)
+	g.GET("/mongo/collections/:collection/indexes", m.handleMongoIndexes)
+	g.POST("/mongo/collections/:collection/indexes", m.handleMongoCreateIndex)
+	g.DELETE("/mongo/collections/:collection/indexes/:name", m.handleMongoDropIndex)
+	g.GET("/mongo/indexes", m.handleMongoEnsuredIndexes)
+}
+
+// mongoConnection returns the named Mongo connection, the default one when
+// name is empty, or writes a 404.
+func (m *Monitor) mongoConnection(c *gin.Context, name string) (*infrastructure.MongoManager, bool) {
+	conn, err := findMongo(m.dependencies, name)
+	switch {
+	case errors.Is(err, errNotEnabled):
+		response.Error(c, http.StatusNotFound, "MONGO_UNAVAILABLE", "Mongo is not enabled")
+	case err != nil:
+		response.NotFound(c, "Mongo connection not found")
+	}
+	return conn, err == nil
+}
+
+// findMongo looks up the named Mongo connection, the default one when
+// name is empty.
+func findMongo(deps *registry.Dependencies, name string) (*infrastructure.MongoManager, error) {
+	component, _ := deps.Get("mongo")
+	switch mongo := component.(type) {
+	case *infrastructure.MongoConnectionManager:
+		conn, ok := mongo.GetDefaultConnection()
+		if name != "" {
+			conn, ok = mongo.GetConnection(name)
+		}
+		if ok {
+			return conn, nil
+		}
+		return nil, fmt.Errorf("mongo %w: %s", errNoConnection, name)
+	case *infrastructure.MongoManager:
+		if name == "" || name == "default" {
+			return mongo, nil
+		}
+		return nil, fmt.Errorf("mongo %w: %s", errNoConnection, name)
+	}
+	return nil, fmt.Errorf("mongo is %w", errNotEnabled)
+}
+
+func (m *Monitor) handleMongoCollections(c *gin.Context) {
+	conn, ok := m.mongoConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mongoBrowseTimeout)
+	defer cancel()
+	names, err := conn.ListCollections(ctx)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, names)
+}
+
+// handleMongoDocuments returns one page of a collection: ?filter= (extended
+// JSON), ?sort=-created_at,name, ?fields=name,email or -password, ?skip=
+// and ?limit= (at most infrastructure.MaxBrowseLimit). ?saved= runs the
+// filter of a saved Mongo query instead, with its parameter defaults.
+// Every browse lands in the caller's query history.
+func (m *Monitor) handleMongoDocuments(c *gin.Context) {
+	collection, connection, query := c.Param("collection"), c.Query("connection"), c.Query("filter")
+	if name := c.Query("saved"); name != "" {
+		saved, ok := m.visibleQuery(c, name)
+		if !ok {
+			return
+		}
+		if saved.Engine != querybook.EngineMongo || saved.Collection != collection {
+			response.BadRequest(c, "Saved query is not a Mongo query on this collection")
+			return
+		}
+		var err error
+		if query, _, err = saved.Bind(nil); err != nil {
+			writeQueryBookError(c, err)
+			return
+		}
+		if connection == "" {
+			connection = saved.Connection
+		}
+	}
+
+	opts := infrastructure.BrowseOptions{MaxTime: mongoBrowseTimeout}
+	var err error
+	if opts.Filter, err = infrastructure.ParseMongoFilter(query); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if opts.Sort, err = infrastructure.ParseMongoSort(c.Query("sort")); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if opts.Projection, err = infrastructure.ParseMongoProjection(c.Query("fields")); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	opts.Skip, _ = strconv.ParseInt(c.DefaultQuery("skip", "0"), 10, 64)
+	opts.Limit, _ = strconv.ParseInt(c.DefaultQuery("limit", "0"), 10, 64)
+
+	conn, ok := m.mongoConnection(c, connection)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mongoBrowseTimeout+time.Second)
+	defer cancel()
+
+	start := time.Now()
+	page, err := conn.BrowseCollection(ctx, collection, opts)
+	entry := querybook.HistoryEntry{
+		Engine:     querybook.EngineMongo,
+		Connection: connection,
+		Query:      collection + " " + compactFilter(query),
+		Saved:      c.Query("saved"),
+		Time:       start,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.RowCount = int64(len(page.Documents))
+	}
+	m.recordQuery(c, entry)
+
+	switch {
+	case errors.Is(err, infrastructure.ErrInvalidBrowse):
+		response.BadRequest(c, err.Error())
+	case err != nil:
+		response.Error(c, http.StatusUnprocessableEntity, "QUERY_FAILED", err.Error())
+	default:
+		response.Success(c, page)
+	}
+}
+
+// compactFilter strips the whitespace of a JSON filter for the history.
+func compactFilter(filter string) string {
+	if filter == "" {
+		return "{}"
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(filter), &v) != nil {
+		return filter
+	}
+	compact, _ := json.Marshal(v)
+	return string(compact)
+}
+
+func (m *Monitor) handleMongoCollectionStats(c *gin.Context) {
+	conn, ok := m.mongoConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mongoBrowseTimeout)
+	defer cancel()
+	stats, err := conn.CollectionStats(ctx, c.Param("collection"))
+	if err != nil {
+		response.Error(c, http.StatusUnprocessableEntity, "STATS_FAILED", err.Error())
+		return
+	}
+	response.Success(c, stats)
+}
+
+func (m *Monitor) handleMongoIndexes(c *gin.Context) {
+	conn, ok := m.mongoConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mongoBrowseTimeout)
+	defer cancel()
+	indexes, err := conn.ListIndexes(ctx, c.Param("collection"))
+	if err != nil {
+		response.Error(c, http.StatusUnprocessableEntity, "INDEXES_FAILED", err.Error())
+		return
+	}
+	response.Success(c, indexes)
+}
+
+// handleMongoCreateIndex builds an index on a collection from a body like
+// a mongo.connections[].indexes entry, without the collection.
+func (m *Monitor) handleMongoCreateIndex(c *gin.Context) {
+	var spec config.MongoIndexConfig
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		response.BadRequest(c, "Invalid index: "+err.Error())
+		return
+	}
+	spec.Collection = c.Param("collection")
+	conn, ok := m.mongoConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mongoBrowseTimeout)
+	defer cancel()
+	name, err := conn.CreateIndex(ctx, spec)
+	switch {
+	case errors.Is(err, infrastructure.ErrInvalidIndex):
+		response.BadRequest(c, err.Error())
+		return
+	case err != nil:
+		response.Error(c, http.StatusUnprocessableEntity, "INDEX_FAILED", err.Error())
+		return
+	}
+	auditDetail(c, "connection", c.Query("connection"))
+	auditDetail(c, "index", name)
+	response.Created(c, gin.H{"collection": spec.Collection, "name": name}, "Index created")
+}
+
+func (m *Monitor) handleMongoDropIndex(c *gin.Context) {
+	conn, ok := m.mongoConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mongoBrowseTimeout)
+	defer cancel()
+	err := conn.DropIndex(ctx, c.Param("collection"), c.Param("name"))
+	switch {
+	case errors.Is(err, infrastructure.ErrInvalidIndex):
+		response.BadRequest(c, err.Error())
+		return
+	case err != nil:
+		response.Error(c, http.StatusUnprocessableEntity, "INDEX_FAILED", err.Error())
+		return
+	}
+	auditDetail(c, "connection", c.Query("connection"))
+	response.Success(c, nil, "Index dropped")
+}
+
+// mongoIndexStatus is the outcome of ensuring the declared indexes of a
+// connection.
+type mongoIndexStatus struct {
+	Connection string                       `json:"connection"`
+	Indexes    []infrastructure.IndexStatus `json:"indexes"`
+	Failed     int                          `json:"failed"`
+}
+
+// handleMongoEnsuredIndexes reports the indexes declared in the mongo
+// config section and by services, as ensured at startup, per connection;
+// ?connection= narrows it to one.
+func (m *Monitor) handleMongoEnsuredIndexes(c *gin.Context) {
+	conns := make(map[string]*infrastructure.MongoManager)
+	if name := c.Query("connection"); name != "" {
+		conn, ok := m.mongoConnection(c, name)
+		if !ok {
+			return
+		}
+		conns[name] = conn
+	} else {
+		component, _ := m.dependencies.Get("mongo")
+		switch mongo := component.(type) {
+		case *infrastructure.MongoConnectionManager:
+			conns = mongo.GetAllConnections()
+		case *infrastructure.MongoManager:
+			conns["default"] = mongo
+		default:
+			response.Error(c, http.StatusNotFound, "MONGO_UNAVAILABLE", "Mongo is not enabled")
+			return
+		}
+	}
+
+	names := make([]string, 0, len(conns))
+	for name := range conns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	statuses := make([]mongoIndexStatus, 0, len(names))
+	for _, name := range names {
+		status := mongoIndexStatus{Connection: name, Indexes: conns[name].EnsuredIndexes()}
+		for _, index := range status.Indexes {
+			if index.Status == infrastructure.IndexFailed {
+				status.Failed++
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	response.Success(c, statuses)
+}
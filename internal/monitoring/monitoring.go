@@ -0,0 +1,149 @@
+// Package monitoring implements the operational API mounted under /api on
+// the main server: aggregated status plus per-subsystem endpoints. Each
+// subsystem lives in its own file and registers its routes in RegisterRoutes.
+package monitoring
+
+import (
+	"sort"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/grpcserver"
+	"stackyrd/pkg/configdrift"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/remoteconfig"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/timesync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Monitor serves the monitoring API.
+type Monitor struct {
+	config        *config.Config
+	logger        *logger.Logger
+	dependencies  *registry.Dependencies
+	infraInit     *infrastructure.InfraInitManager
+	startedAt     time.Time
+	tenantSizer   *infrastructure.TenantSizer
+	routes        func() gin.RoutesInfo
+	services      []interfaces.Service
+	fingerprint   string
+	versions      *contentVersions
+	statusHistory *statusHistory
+}
+
+// New creates the monitoring API handler.
+func New(cfg *config.Config, l *logger.Logger, deps *registry.Dependencies, infraInit *infrastructure.InfraInitManager) *Monitor {
+	m := &Monitor{
+		config:        cfg,
+		logger:        l,
+		dependencies:  deps,
+		infraInit:     infraInit,
+		startedAt:     time.Now(),
+		versions:      newContentVersions(),
+		statusHistory: newStatusHistory(),
+	}
+	m.tenantSizer = newTenantSizer(m)
+	m.checkAccessConfig()
+	return m
+}
+
+// RegisterRoutes mounts all monitoring endpoints on g (normally /api),
+// behind role checks when monitoring.access is enabled.
+func (m *Monitor) RegisterRoutes(g *gin.RouterGroup) {
+	g.Use(m.auditTrail(g.BasePath()), m.accessControl(g.BasePath()))
+	m.registerAccessRoutes(g)
+	m.registerAccountRoutes(g)
+	m.registerAuditRoutes(g)
+	g.GET("/status", m.handleStatus)
+	m.registerBootstrapRoutes(g)
+	m.registerEndpointRoutes(g)
+	m.registerUsageRoutes(g)
+	m.registerInFlightRoutes(g)
+	m.registerExternalRoutes(g)
+	m.registerGraphRoutes(g)
+	m.registerServiceRoutes(g)
+	m.registerMetricsRoutes(g)
+	m.registerMessagingRoutes(g)
+	m.registerAlertingRoutes(g)
+	m.registerReportRoutes(g)
+	m.registerWebhookRoutes(g)
+	m.registerLogRoutes(g)
+	m.registerStreamRoutes(g)
+	m.registerJobRoutes(g)
+	m.registerQueueRoutes(g)
+	m.registerPoolRoutes(g)
+	m.registerRestartRoutes(g)
+	m.registerTenantMetricsRoutes(g)
+	m.registerTenantRoutes(g)
+	m.registerDoctorRoutes(g)
+	m.registerShutdownRoutes(g)
+	m.registerDNSRoutes(g)
+	m.registerWebSocketRoutes(g)
+	m.registerPreferenceRoutes(g)
+	m.registerConfigRoutes(g)
+	m.registerWebRoutes(g)
+	m.registerPostgresRoutes(g)
+	m.registerMongoRoutes(g)
+	m.registerQueryRoutes(g)
+	m.registerRedisRoutes(g)
+	m.registerKafkaRoutes(g)
+}
+
+// handleStatus returns application info, the status of every
+// infrastructure component, the last clock skew measurement, the gRPC
+// server state and the config fingerprint with the last drift check.
+func (m *Monitor) handleStatus(c *gin.Context) {
+	response.Success(c, m.status())
+}
+
+func (m *Monitor) status() map[string]interface{} {
+	status := map[string]interface{}{
+		"app": map[string]interface{}{
+			"name":    m.config.App.Name,
+			"version": m.config.App.Version,
+			"env":     m.config.App.Env,
+		},
+		"started_at":     m.startedAt,
+		"uptime_seconds": int64(time.Since(m.startedAt).Seconds()),
+		"infrastructure": m.componentStatuses(),
+	}
+	if clock, ok := registry.GetTyped[*timesync.Monitor](m.dependencies, "clock"); ok {
+		status["clock"] = clock.Status()
+	}
+	if grpc, ok := registry.GetTyped[*grpcserver.Server](m.dependencies, "grpc"); ok {
+		status["grpc"] = grpc.Status()
+	}
+	configStatus := map[string]interface{}{"fingerprint": m.fingerprint}
+	if drift, ok := registry.GetTyped[*configdrift.Watcher](m.dependencies, "config_drift"); ok {
+		configStatus["drift"] = drift.Status()
+	}
+	if remote, ok := registry.GetTyped[*remoteconfig.Watcher](m.dependencies, "remote_config"); ok {
+		configStatus["remote"] = remote.Status()
+	}
+	status["config"] = configStatus
+	return status
+}
+
+// componentStatuses collects GetStatus from every registered component,
+// with secrets such as URL passwords redacted.
+func (m *Monitor) componentStatuses() map[string]interface{} {
+	statuses := make(map[string]interface{})
+	all := m.dependencies.GetAll()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if comp, ok := all[name].(interface{ GetStatus() map[string]interface{} }); ok {
+			statuses[name] = config.Redact(comp.GetStatus())
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,34 @@
+package monitoring
+
+import (
+	"strings"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerPoolRoutes(g *gin.RouterGroup) {
+	g.GET("/pools", m.handlePools)
+}
+
+// handlePools reports every worker pool with its per-origin throughput,
+// latency and failures, so a saturated pool can be traced to the subsystem
+// filling it. ?origin= keeps the origins starting with the given prefix
+// (e.g. "cron:").
+func (m *Monitor) handlePools(c *gin.Context) {
+	pools := infrastructure.WorkerPools()
+	if prefix := c.Query("origin"); prefix != "" {
+		for i := range pools {
+			origins := pools[i].Origins[:0]
+			for _, o := range pools[i].Origins {
+				if strings.HasPrefix(o.Origin, prefix) {
+					origins = append(origins, o)
+				}
+			}
+			pools[i].Origins = origins
+		}
+	}
+	response.Success(c, pools)
+}
@@ -0,0 +1,259 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/sqlguard"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultExplainTimeout = 10 * time.Second
+	maxExplainTimeout     = 60 * time.Second
+)
+
+func (m *Monitor) registerPostgresRoutes(g *gin.RouterGroup) {
+	g.POST("/postgres/explain", m.handlePostgresExplain)
+	g.GET("/postgres/schema", m.handlePostgresSchema)
+	g.POST("/postgres/query", m.handlePostgresQuery)
+	g.GET("/postgres/stats/queries", m.handlePostgresTopQueries)
+	g.POST("/postgres/stats/queries/reset", m.handlePostgresResetQueryStats)
+	g.GET("/postgres/stats/indexes", m.handlePostgresIndexUsage)
+	g.GET("/postgres/stats/tables", m.handlePostgresTableStats)
+	g.GET("/postgres/migrations", m.handlePostgresMigrations)
+}
+
+// postgresConnection returns the named Postgres connection, the default
+// one when name is empty, or writes a 404.
+func (m *Monitor) postgresConnection(c *gin.Context, name string) (*infrastructure.PostgresManager, bool) {
+	conn, err := findPostgres(m.dependencies, name)
+	switch {
+	case errors.Is(err, errNotEnabled):
+		response.Error(c, http.StatusNotFound, "POSTGRES_UNAVAILABLE", "Postgres is not enabled")
+	case err != nil:
+		response.NotFound(c, "Postgres connection not found")
+	}
+	return conn, err == nil
+}
+
+// findPostgres looks up the named Postgres connection, the default one
+// when name is empty.
+func findPostgres(deps *registry.Dependencies, name string) (*infrastructure.PostgresManager, error) {
+	component, _ := deps.Get("postgres")
+	switch pg := component.(type) {
+	case *infrastructure.PostgresConnectionManager:
+		conn, ok := pg.GetDefaultConnection()
+		if name != "" {
+			conn, ok = pg.GetConnection(name)
+		}
+		if ok {
+			return conn, nil
+		}
+		return nil, fmt.Errorf("postgres %w: %s", errNoConnection, name)
+	case *infrastructure.PostgresManager:
+		if name == "" || name == "default" {
+			return pg, nil
+		}
+		return nil, fmt.Errorf("postgres %w: %s", errNoConnection, name)
+	}
+	return nil, fmt.Errorf("postgres is %w", errNotEnabled)
+}
+
+// handlePostgresSchema lists the schemas of a connection with their
+// tables, columns, indexes, row estimates and sizes for the schema
+// explorer. ?schema= narrows it to one schema; schemas outside
+// monitoring.sql_schemas, when set, are left out.
+func (m *Monitor) handlePostgresSchema(c *gin.Context) {
+	conn, ok := m.postgresConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	schema, ok := m.allowedSchema(c)
+	if !ok {
+		return
+	}
+	allowed := m.config.Monitoring.SQLSchemas
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), defaultExplainTimeout)
+	defer cancel()
+	schemas, err := conn.Schemas(ctx, schema)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	if len(allowed) > 0 {
+		schemas = slices.DeleteFunc(schemas, func(s infrastructure.SchemaInfo) bool {
+			return !slices.Contains(allowed, s.Name)
+		})
+	}
+	response.Success(c, schemas)
+}
+
+// allowedSchema returns ?schema=, or writes a 403 when it is outside
+// monitoring.sql_schemas.
+func (m *Monitor) allowedSchema(c *gin.Context) (string, bool) {
+	allowed := m.config.Monitoring.SQLSchemas
+	schema := c.Query("schema")
+	if schema != "" && len(allowed) > 0 && !slices.Contains(allowed, schema) {
+		response.Error(c, http.StatusForbidden, "SCHEMA_NOT_ALLOWED", "Schema is not in monitoring.sql_schemas")
+		return "", false
+	}
+	return schema, true
+}
+
+type postgresExplainRequest struct {
+	Connection string `json:"connection"`
+	Query      string `json:"query" binding:"required"`
+	Analyze    bool   `json:"analyze"`
+	Buffers    bool   `json:"buffers"`
+	Timeout    int    `json:"timeout"` // seconds, capped at maxExplainTimeout
+}
+
+// handlePostgresExplain returns the plan tree of a query with exclusive
+// costs and times per node and a summary, for the dashboard's plan
+// visualizer. With analyze the query runs, read-only and rolled back,
+// under a statement timeout.
+func (m *Monitor) handlePostgresExplain(c *gin.Context) {
+	var req postgresExplainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body: query is required")
+		return
+	}
+	conn, ok := m.postgresConnection(c, req.Connection)
+	if !ok {
+		return
+	}
+
+	timeout := defaultExplainTimeout
+	if req.Timeout > 0 {
+		timeout = min(time.Duration(req.Timeout)*time.Second, maxExplainTimeout)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout+time.Second)
+	defer cancel()
+
+	plan, err := conn.Explain(ctx, req.Query, infrastructure.ExplainOptions{
+		Analyze: req.Analyze,
+		Buffers: req.Buffers,
+		Timeout: timeout,
+	})
+	if err != nil {
+		if errors.Is(err, infrastructure.ErrInvalidExplainQuery) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		// Syntax errors, missing relations, timeouts and write attempts
+		// come back from the database
+		response.Error(c, http.StatusUnprocessableEntity, "EXPLAIN_FAILED", err.Error())
+		return
+	}
+	response.Success(c, plan)
+}
+
+type postgresQueryRequest struct {
+	Connection string                 `json:"connection"`
+	Query      string                 `json:"query"`
+	Saved      string                 `json:"saved"`  // name of a saved query to run instead of query
+	Params     map[string]interface{} `json:"params"` // parameters of the saved query
+}
+
+// handlePostgresQuery runs one statement from the query console, typed or
+// saved, within the monitoring.sql_* guardrails: read-only mode, row and
+// time limits and the schema allowlist. Parameters of a saved query are
+// bound as $n arguments. Every run lands in the caller's query history.
+func (m *Monitor) handlePostgresQuery(c *gin.Context) {
+	var req postgresQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	var args []interface{}
+	if req.Saved != "" {
+		saved, ok := m.visibleQuery(c, req.Saved)
+		if !ok {
+			return
+		}
+		if saved.Engine != querybook.EnginePostgres {
+			response.BadRequest(c, "Saved query is not a Postgres query")
+			return
+		}
+		var err error
+		if req.Query, args, err = saved.Bind(req.Params); err != nil {
+			writeQueryBookError(c, err)
+			return
+		}
+		if req.Connection == "" {
+			req.Connection = saved.Connection
+		}
+	}
+	if req.Query == "" {
+		response.BadRequest(c, "Set query or saved")
+		return
+	}
+	conn, ok := m.postgresConnection(c, req.Connection)
+	if !ok {
+		return
+	}
+
+	cfg := m.config.Monitoring
+	timeout := time.Duration(cfg.SQLTimeout) * time.Second
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout+time.Second)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result, err := conn.RunConsoleQuery(ctx, req.Query, infrastructure.ConsoleOptions{
+		ReadOnly: cfg.SQLReadOnly,
+		MaxRows:  cfg.SQLMaxRows,
+		Timeout:  timeout,
+		Schemas:  cfg.SQLSchemas,
+		Args:     args,
+	})
+	entry := querybook.HistoryEntry{
+		Engine:     querybook.EnginePostgres,
+		Connection: req.Connection,
+		Query:      req.Query,
+		Saved:      req.Saved,
+		Time:       start,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else if result.RowsAffected != nil {
+		entry.RowCount = *result.RowsAffected
+	} else {
+		entry.RowCount = int64(result.RowCount)
+	}
+	m.recordQuery(c, entry)
+
+	switch {
+	case errors.Is(err, sqlguard.ErrRejected):
+		response.Error(c, http.StatusUnprocessableEntity, "QUERY_REJECTED", err.Error())
+		return
+	case errors.Is(err, infrastructure.ErrSchemaNotAllowed):
+		response.Error(c, http.StatusForbidden, "SCHEMA_NOT_ALLOWED", err.Error())
+		return
+	case err != nil:
+		response.Error(c, http.StatusUnprocessableEntity, "QUERY_FAILED", err.Error())
+		return
+	}
+	auditDetail(c, "command", result.Command)
+	auditDetail(c, "row_count", result.RowCount)
+	if result.RowsAffected != nil {
+		auditDetail(c, "rows_affected", *result.RowsAffected)
+	}
+	response.Success(c, result)
+}
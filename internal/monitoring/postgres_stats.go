@@ -0,0 +1,123 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/sqlbuilder"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlePostgresTopQueries returns the ?limit= (default 20, at most 500)
+// statements of a connection's database from pg_stat_statements with the
+// highest ?order= total (default), mean, calls, rows or reads.
+func (m *Monitor) handlePostgresTopQueries(c *gin.Context) {
+	conn, ok := m.postgresConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), defaultExplainTimeout)
+	defer cancel()
+	queries, err := conn.TopQueries(ctx, c.Query("order"), min(limit, 500))
+	switch {
+	case errors.Is(err, sqlbuilder.ErrInvalidSort):
+		response.BadRequest(c, err.Error())
+		return
+	case errors.Is(err, infrastructure.ErrStatStatementsUnavailable):
+		response.Error(c, http.StatusNotFound, "PG_STAT_STATEMENTS_UNAVAILABLE", err.Error())
+		return
+	case err != nil:
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, queries)
+}
+
+// handlePostgresResetQueryStats discards the pg_stat_statements figures
+// of a connection, to measure from a clean slate after a change.
+func (m *Monitor) handlePostgresResetQueryStats(c *gin.Context) {
+	conn, ok := m.postgresConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	err := conn.ResetQueryStats(c.Request.Context())
+	switch {
+	case errors.Is(err, infrastructure.ErrStatStatementsUnavailable):
+		response.Error(c, http.StatusNotFound, "PG_STAT_STATEMENTS_UNAVAILABLE", err.Error())
+		return
+	case err != nil:
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	auditDetail(c, "connection", c.Query("connection"))
+	response.Success(c, nil, "Query statistics reset")
+}
+
+// handlePostgresIndexUsage lists the indexes of a connection with their
+// scans and sizes, never used ones first. ?schema= narrows it to one
+// schema and ?unused=true to unused indexes; schemas outside
+// monitoring.sql_schemas, when set, are left out.
+func (m *Monitor) handlePostgresIndexUsage(c *gin.Context) {
+	conn, ok := m.postgresConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	schema, ok := m.allowedSchema(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), defaultExplainTimeout)
+	defer cancel()
+	indexes, err := conn.IndexUsage(ctx, schema)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	unused := c.Query("unused") == "true"
+	allowed := m.config.Monitoring.SQLSchemas
+	indexes = slices.DeleteFunc(indexes, func(idx infrastructure.IndexUsage) bool {
+		return (unused && !idx.Unused) || (len(allowed) > 0 && !slices.Contains(allowed, idx.Schema))
+	})
+	response.Success(c, indexes)
+}
+
+// handlePostgresTableStats lists the tables of a connection with scan
+// counters, dead tuples and estimated bloat, most bloated first. ?schema=
+// narrows it to one schema; schemas outside monitoring.sql_schemas, when
+// set, are left out.
+func (m *Monitor) handlePostgresTableStats(c *gin.Context) {
+	conn, ok := m.postgresConnection(c, c.Query("connection"))
+	if !ok {
+		return
+	}
+	schema, ok := m.allowedSchema(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), defaultExplainTimeout)
+	defer cancel()
+	tables, err := conn.TableStats(ctx, schema)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	if allowed := m.config.Monitoring.SQLSchemas; len(allowed) > 0 {
+		tables = slices.DeleteFunc(tables, func(t infrastructure.TableStats) bool {
+			return !slices.Contains(allowed, t.Schema)
+		})
+	}
+	response.Success(c, tables)
+}
@@ -0,0 +1,151 @@
+package monitoring
+
+import (
+	"net/http"
+	"time"
+
+	"stackyrd/pkg/i18n"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerPreferenceRoutes(g *gin.RouterGroup) {
+	g.GET("/i18n/locales", m.handleLocales)
+	g.GET("/preferences", m.handlePreferences)
+	g.PUT("/preferences", m.handleUpdatePreferences)
+	g.DELETE("/preferences", m.handleResetPreferences)
+}
+
+func (m *Monitor) preferenceStore(c *gin.Context) (*i18n.Store, bool) {
+	store, ok := registry.GetTyped[*i18n.Store](m.dependencies, "preferences")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "PREFERENCES_UNAVAILABLE", "Operator preferences are not available")
+	}
+	return store, ok
+}
+
+// handleLocales lists the locales operators can choose and the defaults.
+func (m *Monitor) handleLocales(c *gin.Context) {
+	store, ok := m.preferenceStore(c)
+	if !ok {
+		return
+	}
+	m.successConditional(c, map[string]interface{}{
+		"locales":  store.Settings().Locales(),
+		"defaults": store.Settings().Defaults(),
+	}, "", time.Time{})
+}
+
+// handlePreferences returns the preferences of the calling operator, who is
+// identified like the audit trail actor.
+func (m *Monitor) handlePreferences(c *gin.Context) {
+	store, ok := m.preferenceStore(c)
+	if !ok {
+		return
+	}
+	user := actor(c)
+	prefs, saved, err := store.Get(user)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	m.successConditional(c, preferencesResult(user, prefs, saved), "", time.Time{})
+}
+
+// handleUpdatePreferences saves the locale and/or timezone of the calling
+// operator; omitted fields keep their current value.
+func (m *Monitor) handleUpdatePreferences(c *gin.Context) {
+	store, ok := m.preferenceStore(c)
+	if !ok {
+		return
+	}
+	var update i18n.Preferences
+	if err := c.ShouldBindJSON(&update); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	if update.Locale == "" && update.Timezone == "" {
+		response.BadRequest(c, "Set locale and/or timezone")
+		return
+	}
+	user := actor(c)
+	prefs, err := store.Set(user, update)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, preferencesResult(user, prefs, true), "Preferences saved")
+}
+
+func (m *Monitor) handleResetPreferences(c *gin.Context) {
+	store, ok := m.preferenceStore(c)
+	if !ok {
+		return
+	}
+	user := actor(c)
+	if err := store.Reset(user); err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	prefs, _, _ := store.Get(user)
+	response.Success(c, preferencesResult(user, prefs, false), "Preferences reset")
+}
+
+func preferencesResult(user string, prefs i18n.Preferences, saved bool) map[string]interface{} {
+	result := map[string]interface{}{
+		"user":     user,
+		"locale":   prefs.Locale,
+		"timezone": prefs.Timezone,
+		"saved":    saved,
+	}
+	if loc, err := prefs.Location(); err == nil {
+		_, offset := time.Now().In(loc).Zone()
+		result["utc_offset_seconds"] = offset
+	}
+	return result
+}
+
+// displayPreferences returns the preferences timestamps of c are presented
+// in: the caller's, overridden by the locale and tz query parameters. It
+// writes a 400 when an override is invalid. Without the preferences store
+// timestamps stay in UTC.
+func (m *Monitor) displayPreferences(c *gin.Context) (i18n.Preferences, *time.Location, bool) {
+	prefs := i18n.Preferences{Timezone: "UTC"}
+	store, hasStore := registry.GetTyped[*i18n.Store](m.dependencies, "preferences")
+	if hasStore {
+		prefs, _, _ = store.Get(actor(c))
+	}
+
+	if tz := c.Query("tz"); tz != "" {
+		prefs.Timezone = tz
+	}
+	if locale := c.Query("locale"); locale != "" {
+		prefs.Locale = locale
+		if hasStore {
+			validated, err := store.Settings().Validate(prefs)
+			if err != nil {
+				response.BadRequest(c, err.Error())
+				return prefs, nil, false
+			}
+			prefs = validated
+		}
+	}
+	loc, err := prefs.Location()
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return prefs, nil, false
+	}
+	return prefs, loc, true
+}
+
+// displayMeta describes the zone timestamps were converted to.
+func displayMeta(prefs i18n.Preferences, loc *time.Location) map[string]interface{} {
+	_, offset := time.Now().In(loc).Zone()
+	return map[string]interface{}{
+		"locale":             prefs.Locale,
+		"timezone":           loc.String(),
+		"utc_offset_seconds": offset,
+	}
+}
@@ -0,0 +1,396 @@
+package monitoring
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/sqlguard"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerQueryRoutes(g *gin.RouterGroup) {
+	g.GET("/queries", m.handleSavedQueries)
+	g.POST("/queries", m.handleCreateSavedQuery)
+	g.GET("/queries/:name", m.handleSavedQuery)
+	g.PUT("/queries/:name", m.handleUpdateSavedQuery)
+	g.DELETE("/queries/:name", m.handleDeleteSavedQuery)
+	g.POST("/queries/:name/run", m.handleRunSavedQuery)
+	g.PUT("/queries/:name/report", m.handleSetQueryReport)
+	g.DELETE("/queries/:name/report", m.handleDeleteQueryReport)
+	g.GET("/queries/:name/reports", m.handleQueryReports)
+	g.GET("/queries/:name/reports/latest", m.handleLatestQueryReport)
+	g.GET("/query-history", m.handleQueryHistory)
+	g.DELETE("/query-history", m.handleClearQueryHistory)
+}
+
+func (m *Monitor) queryBook(c *gin.Context) (*querybook.Book, bool) {
+	book, ok := registry.GetTyped[*querybook.Book](m.dependencies, "querybook")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "QUERYBOOK_UNAVAILABLE", "Saved queries are not available")
+	}
+	return book, ok
+}
+
+// visibleQuery returns the saved query called name, or writes a 404 when
+// it does not exist or is private to another operator.
+func (m *Monitor) visibleQuery(c *gin.Context, name string) (querybook.SavedQuery, bool) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return querybook.SavedQuery{}, false
+	}
+	query, err := book.Get(name)
+	if err == nil && !query.VisibleTo(actor(c), callerIsAdmin(c)) {
+		err = querybook.ErrNotFound
+	}
+	if err != nil {
+		writeQueryBookError(c, err)
+		return querybook.SavedQuery{}, false
+	}
+	return query, true
+}
+
+// handleSavedQueries lists the saved queries the caller can see by name,
+// optionally of one engine.
+func (m *Monitor) handleSavedQueries(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	queries, err := book.List(c.Query("engine"))
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	visible := make([]querybook.SavedQuery, 0, len(queries))
+	for _, q := range queries {
+		if q.VisibleTo(actor(c), callerIsAdmin(c)) {
+			visible = append(visible, q)
+		}
+	}
+	response.Success(c, visible)
+}
+
+func (m *Monitor) handleSavedQuery(c *gin.Context) {
+	if query, ok := m.visibleQuery(c, c.Param("name")); ok {
+		response.Success(c, query)
+	}
+}
+
+func (m *Monitor) handleCreateSavedQuery(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	var query querybook.SavedQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	saved, err := book.Create(query, actor(c))
+	if err != nil {
+		writeQueryBookError(c, err)
+		return
+	}
+	response.Created(c, saved, "Query saved")
+}
+
+func (m *Monitor) handleUpdateSavedQuery(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	if _, ok := m.visibleQuery(c, c.Param("name")); !ok {
+		return
+	}
+	var query querybook.SavedQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	saved, err := book.Update(c.Param("name"), query, actor(c))
+	if err != nil {
+		writeQueryBookError(c, err)
+		return
+	}
+	response.Success(c, saved, "Query updated")
+}
+
+func (m *Monitor) handleDeleteSavedQuery(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	if _, ok := m.visibleQuery(c, c.Param("name")); !ok {
+		return
+	}
+	if reports, ok := registry.GetTyped[*querybook.Scheduler](m.dependencies, "query_reports"); ok {
+		reports.Unschedule(c.Param("name"))
+	}
+	if err := book.Delete(c.Param("name")); err != nil {
+		writeQueryBookError(c, err)
+		return
+	}
+	response.Success(c, nil, "Query deleted")
+}
+
+type runQueryRequest struct {
+	Connection string                 `json:"connection"` // overrides the saved connection
+	Params     map[string]interface{} `json:"params"`
+}
+
+// handleRunSavedQuery runs a saved query with the given parameters and
+// returns its rows, or with ?format=csv downloads them. The run lands in
+// the caller's query history.
+func (m *Monitor) handleRunSavedQuery(c *gin.Context) {
+	var req runQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	saved, ok := m.visibleQuery(c, c.Param("name"))
+	if !ok {
+		return
+	}
+	if req.Connection != "" {
+		saved.Connection = req.Connection
+	}
+
+	start := time.Now()
+	result, err := NewQueryRunner(m.config, m.dependencies)(c.Request.Context(), saved, req.Params)
+	entry := querybook.HistoryEntry{
+		Engine:     saved.Engine,
+		Connection: saved.Connection,
+		Query:      saved.Query,
+		Saved:      saved.Name,
+		Time:       start,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else if result.RowsAffected != nil {
+		entry.RowCount = *result.RowsAffected
+	} else {
+		entry.RowCount = int64(result.RowCount)
+	}
+	m.recordQuery(c, entry)
+	if err != nil {
+		writeQueryRunError(c, err)
+		return
+	}
+
+	auditDetail(c, "row_count", result.RowCount)
+	if c.Query("format") == "csv" {
+		writeCSV(c, saved.Name, result)
+		return
+	}
+	response.Success(c, result)
+}
+
+func (m *Monitor) queryReports(c *gin.Context) (*querybook.Scheduler, bool) {
+	reports, ok := registry.GetTyped[*querybook.Scheduler](m.dependencies, "query_reports")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "REPORTS_UNAVAILABLE", "Query reports are not available")
+	}
+	return reports, ok
+}
+
+// handleSetQueryReport schedules a saved query as a report through the
+// cron manager, replacing its previous schedule.
+func (m *Monitor) handleSetQueryReport(c *gin.Context) {
+	reports, ok := m.queryReports(c)
+	if !ok {
+		return
+	}
+	var report querybook.Report
+	if err := c.ShouldBindJSON(&report); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	if _, ok := m.visibleQuery(c, c.Param("name")); !ok {
+		return
+	}
+	saved, err := reports.SetReport(c.Param("name"), report, actor(c))
+	if errors.Is(err, querybook.ErrNoScheduler) {
+		response.Error(c, http.StatusConflict, "CRON_DISABLED", err.Error())
+		return
+	}
+	if err != nil {
+		writeQueryBookError(c, err)
+		return
+	}
+	auditDetail(c, "schedule", report.Schedule)
+	response.Success(c, saved, "Report scheduled")
+}
+
+func (m *Monitor) handleDeleteQueryReport(c *gin.Context) {
+	reports, ok := m.queryReports(c)
+	if !ok {
+		return
+	}
+	if _, ok := m.visibleQuery(c, c.Param("name")); !ok {
+		return
+	}
+	saved, err := reports.RemoveReport(c.Param("name"))
+	if err != nil {
+		writeQueryBookError(c, err)
+		return
+	}
+	response.Success(c, saved, "Report unscheduled")
+}
+
+// handleQueryReports returns the last report runs of a saved query,
+// newest first.
+func (m *Monitor) handleQueryReports(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	if _, ok := m.visibleQuery(c, c.Param("name")); !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(querybook.DefaultReportKeep)))
+	runs, err := book.Runs(c.Param("name"), limit)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, runs)
+}
+
+// handleLatestQueryReport returns the newest report run of a saved query,
+// or with ?format=csv downloads its rows.
+func (m *Monitor) handleLatestQueryReport(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	if _, ok := m.visibleQuery(c, c.Param("name")); !ok {
+		return
+	}
+	runs, err := book.Runs(c.Param("name"), 1)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	if len(runs) == 0 {
+		response.NotFound(c, "The report has not run yet")
+		return
+	}
+	run := runs[0]
+	if c.Query("format") == "csv" {
+		if run.Result == nil {
+			response.Error(c, http.StatusUnprocessableEntity, "REPORT_FAILED", run.Error)
+			return
+		}
+		writeCSV(c, run.Query+"-"+run.Time.UTC().Format("20060102-150405"), run.Result)
+		return
+	}
+	response.Success(c, run)
+}
+
+// handleQueryHistory returns the console queries of the caller, newest
+// first. Admins may pass user to see another operator's history.
+func (m *Monitor) handleQueryHistory(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	user, ok := m.historyUser(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	entries, err := book.History(user, limit)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, entries)
+}
+
+func (m *Monitor) handleClearQueryHistory(c *gin.Context) {
+	book, ok := m.queryBook(c)
+	if !ok {
+		return
+	}
+	user, ok := m.historyUser(c)
+	if !ok {
+		return
+	}
+	if err := book.ClearHistory(user); err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "Query history cleared")
+}
+
+// historyUser returns whose history the request is about: the caller, or
+// for admins the user query parameter.
+func (m *Monitor) historyUser(c *gin.Context) (string, bool) {
+	user := c.Query("user")
+	if user == "" || user == actor(c) {
+		return actor(c), true
+	}
+	if !callerIsAdmin(c) {
+		response.Forbidden(c, "Only admins can see the history of other operators")
+		return "", false
+	}
+	return user, true
+}
+
+// recordQuery adds a console run to the caller's history; failures are
+// logged, never returned, so they cannot mask the query result.
+func (m *Monitor) recordQuery(c *gin.Context, entry querybook.HistoryEntry) {
+	book, ok := registry.GetTyped[*querybook.Book](m.dependencies, "querybook")
+	if !ok {
+		return
+	}
+	entry.User = actor(c)
+	if err := book.Record(entry); err != nil {
+		m.logger.Error("Failed to record query history", err, "user", entry.User)
+	}
+}
+
+// callerIsAdmin reports whether the caller has the admin role.
+func callerIsAdmin(c *gin.Context) bool {
+	who, ok := c.Get(callerKey)
+	return ok && who.(caller).Role >= RoleAdmin
+}
+
+func writeQueryBookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, querybook.ErrNotFound):
+		response.NotFound(c, "Saved query not found")
+	case errors.Is(err, querybook.ErrExists):
+		response.Conflict(c, err.Error())
+	case errors.Is(err, querybook.ErrInvalid):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalServerError(c, err.Error())
+	}
+}
+
+// writeQueryRunError maps the failure of a saved query run to a response.
+func writeQueryRunError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, querybook.ErrInvalid), errors.Is(err, infrastructure.ErrInvalidBrowse):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, errNotEnabled), errors.Is(err, errNoConnection):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, sqlguard.ErrRejected):
+		response.Error(c, http.StatusUnprocessableEntity, "QUERY_REJECTED", err.Error())
+	case errors.Is(err, infrastructure.ErrSchemaNotAllowed):
+		response.Error(c, http.StatusForbidden, "SCHEMA_NOT_ALLOWED", err.Error())
+	default:
+		response.Error(c, http.StatusUnprocessableEntity, "QUERY_FAILED", err.Error())
+	}
+}
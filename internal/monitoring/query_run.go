@@ -0,0 +1,165 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Why a connection lookup failed.
+var (
+	errNotEnabled   = errors.New("not enabled")
+	errNoConnection = errors.New("connection not found")
+)
+
+// NewQueryRunner returns the runner of saved queries: Postgres queries go
+// through the SQL console guardrails (monitoring.sql_*), Mongo filters
+// return at most infrastructure.MaxBrowseLimit documents.
+func NewQueryRunner(cfg *config.Config, deps *registry.Dependencies) querybook.Runner {
+	return func(ctx context.Context, q querybook.SavedQuery, params map[string]interface{}) (*querybook.Result, error) {
+		query, args, err := q.Bind(params)
+		if err != nil {
+			return nil, err
+		}
+		switch q.Engine {
+		case querybook.EnginePostgres:
+			return runPostgresQuery(ctx, cfg.Monitoring, deps, q.Connection, query, args)
+		case querybook.EngineMongo:
+			return runMongoQuery(ctx, deps, q.Connection, q.Collection, query)
+		}
+		return nil, fmt.Errorf("%w: unknown engine %q", querybook.ErrInvalid, q.Engine)
+	}
+}
+
+func runPostgresQuery(ctx context.Context, cfg config.MonitoringConfig, deps *registry.Dependencies, connection, query string, args []interface{}) (*querybook.Result, error) {
+	conn, err := findPostgres(deps, connection)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(cfg.SQLTimeout) * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout+time.Second)
+		defer cancel()
+	}
+	res, err := conn.RunConsoleQuery(ctx, query, infrastructure.ConsoleOptions{
+		ReadOnly: cfg.SQLReadOnly,
+		MaxRows:  cfg.SQLMaxRows,
+		Timeout:  timeout,
+		Schemas:  cfg.SQLSchemas,
+		Args:     args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &querybook.Result{
+		Columns:      res.Columns,
+		Rows:         res.Rows,
+		RowCount:     res.RowCount,
+		Truncated:    res.Truncated,
+		RowsAffected: res.RowsAffected,
+	}, nil
+}
+
+func runMongoQuery(ctx context.Context, deps *registry.Dependencies, connection, collection, filter string) (*querybook.Result, error) {
+	conn, err := findMongo(deps, connection)
+	if err != nil {
+		return nil, err
+	}
+	opts := infrastructure.BrowseOptions{MaxTime: mongoBrowseTimeout, Limit: infrastructure.MaxBrowseLimit}
+	if opts.Filter, err = infrastructure.ParseMongoFilter(filter); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, mongoBrowseTimeout+time.Second)
+	defer cancel()
+	page, err := conn.BrowseCollection(ctx, collection, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Columns are the top-level fields of every document, _id first
+	fields := make(map[string]bool)
+	for _, doc := range page.Documents {
+		for k := range doc {
+			fields[k] = true
+		}
+	}
+	result := &querybook.Result{Columns: []string{}, Rows: make([][]interface{}, 0, len(page.Documents)), Truncated: page.HasMore}
+	if fields["_id"] {
+		result.Columns = append(result.Columns, "_id")
+		delete(fields, "_id")
+	}
+	rest := make([]string, 0, len(fields))
+	for k := range fields {
+		rest = append(rest, k)
+	}
+	sort.Strings(rest)
+	result.Columns = append(result.Columns, rest...)
+	for _, doc := range page.Documents {
+		row := make([]interface{}, len(result.Columns))
+		for i, col := range result.Columns {
+			row[i] = doc[col]
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
+
+// writeCSV sends result as a CSV attachment called name.csv.
+func writeCSV(c *gin.Context, name string, result *querybook.Result) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+	c.Status(http.StatusOK)
+	c.Writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(result.Columns)
+	record := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) {
+				record[i] = csvCell(row[i])
+			}
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+}
+
+// csvCell formats one value; documents and arrays become JSON.
+func csvCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case bool, int, int32, int64, float32, float64:
+		return fmt.Sprint(v)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,92 @@
+package monitoring
+
+import (
+	"net/http"
+	"strconv"
+
+	"stackyrd/pkg/queue"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerQueueRoutes(g *gin.RouterGroup) {
+	g.GET("/queue", m.handleQueue)
+	g.GET("/queue/dead", m.handleQueueDead)
+	g.POST("/queue/dead/:id/retry", m.handleQueueRetry)
+	g.DELETE("/queue/dead/:id", m.handleQueueDiscard)
+}
+
+func (m *Monitor) jobQueue(c *gin.Context) (*queue.Queue, bool) {
+	q, ok := registry.GetTyped[*queue.Queue](m.dependencies, "queue")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "QUEUE_DISABLED", "The job queue is not enabled")
+	}
+	return q, ok
+}
+
+// handleQueue returns the queue depth by state and the job outcomes of
+// this instance.
+func (m *Monitor) handleQueue(c *gin.Context) {
+	q, ok := m.jobQueue(c)
+	if !ok {
+		return
+	}
+	response.Success(c, q.GetStatus())
+}
+
+// handleQueueDead lists dead-lettered jobs, most recently failed first.
+func (m *Monitor) handleQueueDead(c *gin.Context) {
+	q, ok := m.jobQueue(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	jobs, err := q.Dead(c.Request.Context(), limit)
+	if err != nil {
+		response.ServiceUnavailable(c, "Job queue unavailable: "+err.Error())
+		return
+	}
+	response.Success(c, jobs)
+}
+
+// handleQueueRetry moves a dead-lettered job back to the queue.
+func (m *Monitor) handleQueueRetry(c *gin.Context) {
+	q, ok := m.jobQueue(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	auditDetail(c, "job", id)
+	found, err := q.Retry(c.Request.Context(), id)
+	if err != nil {
+		response.ServiceUnavailable(c, "Job queue unavailable: "+err.Error())
+		return
+	}
+	if !found {
+		response.NotFound(c, "Dead job not found")
+		return
+	}
+	response.Success(c, map[string]interface{}{"id": id, "requeued": true})
+}
+
+// handleQueueDiscard deletes a dead-lettered job.
+func (m *Monitor) handleQueueDiscard(c *gin.Context) {
+	q, ok := m.jobQueue(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	auditDetail(c, "job", id)
+	found, err := q.Discard(c.Request.Context(), id)
+	if err != nil {
+		response.ServiceUnavailable(c, "Job queue unavailable: "+err.Error())
+		return
+	}
+	if !found {
+		response.NotFound(c, "Dead job not found")
+		return
+	}
+	response.Success(c, map[string]interface{}{"id": id, "discarded": true})
+}
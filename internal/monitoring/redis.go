@@ -0,0 +1,304 @@
+package monitoring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys are passed as ?key= or in the body, as they may contain slashes.
+func (m *Monitor) registerRedisRoutes(g *gin.RouterGroup) {
+	g.GET("/redis/keys", m.handleRedisKeys)
+	g.GET("/redis/key", m.handleRedisKey)
+	g.PUT("/redis/key", m.handleRedisKeyEdit)
+	g.PUT("/redis/key/ttl", m.handleRedisKeyTTL)
+	g.DELETE("/redis/key", m.handleRedisKeyDelete)
+	g.GET("/redis/keyspace", m.handleRedisKeyspace)
+	g.GET("/redis/slowlog", m.handleRedisSlowLog)
+	g.GET("/redis/info", m.handleRedisInfo)
+}
+
+func (m *Monitor) redisManager(c *gin.Context) (*infrastructure.RedisManager, bool) {
+	manager, ok := registry.GetTyped[*infrastructure.RedisManager](m.dependencies, "redis")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "REDIS_UNAVAILABLE", "Redis is not enabled")
+	}
+	return manager, ok
+}
+
+// handleRedisKeys returns one page of keys matching pattern (default "*")
+// with type, TTL and memory usage. Pass the returned cursor to get the next
+// page; count (default 100, at most 1000) is approximate, as with SCAN.
+func (m *Monitor) handleRedisKeys(c *gin.Context) {
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	count, _ := strconv.Atoi(c.DefaultQuery("count", "100"))
+	page, err := manager.ScanKeysPage(c.Request.Context(), c.Query("pattern"), c.Query("cursor"), count)
+	if err != nil {
+		if errors.Is(err, infrastructure.ErrInvalidCursor) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, page)
+}
+
+// handleRedisKeyspace counts keys by prefix with their estimated memory
+// for charting. ?separator= (default ":") and ?depth= (default 1) define
+// the prefixes, ?pattern= limits the keys, ?max_keys= (default 100000)
+// bounds the scan and ?samples= (default 20) the keys per prefix whose
+// memory is measured.
+func (m *Monitor) handleRedisKeyspace(c *gin.Context) {
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	opts := infrastructure.KeyspaceOptions{Pattern: c.Query("pattern"), Separator: c.Query("separator")}
+	opts.Depth, _ = strconv.Atoi(c.DefaultQuery("depth", "1"))
+	opts.MaxKeys, _ = strconv.Atoi(c.DefaultQuery("max_keys", "0"))
+	opts.Samples, _ = strconv.Atoi(c.DefaultQuery("samples", "0"))
+	summary, err := manager.AnalyzeKeyspace(c.Request.Context(), opts)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, summary)
+}
+
+// handleRedisSlowLog returns the latest ?count= (default 50, at most 1000)
+// slow log entries, newest first.
+func (m *Monitor) handleRedisSlowLog(c *gin.Context) {
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	count, _ := strconv.ParseInt(c.DefaultQuery("count", "50"), 10, 64)
+	if count <= 0 {
+		count = 50
+	}
+	entries, err := manager.SlowLog(c.Request.Context(), min(count, 1000))
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, entries)
+}
+
+// handleRedisInfo returns INFO as JSON, of the comma-separated ?sections=
+// (e.g. memory,stats,keyspace) or the default sections.
+func (m *Monitor) handleRedisInfo(c *gin.Context) {
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	var sections []string
+	for _, section := range strings.Split(c.Query("sections"), ",") {
+		if section = strings.TrimSpace(section); section != "" {
+			sections = append(sections, section)
+		}
+	}
+	info, err := manager.InfoSections(c.Request.Context(), sections...)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, info)
+}
+
+// handleRedisKey returns the value of ?key= read according to its type,
+// with at most ?limit= elements (default 100, at most 1000), and the
+// version that confirms a change of it.
+func (m *Monitor) handleRedisKey(c *gin.Context) {
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	kv, err := manager.KeyValue(c.Request.Context(), c.Query("key"), limit)
+	if err != nil {
+		writeRedisKeyError(c, err)
+		return
+	}
+	response.Success(c, redisKeyView{kv, keyVersion(kv)})
+}
+
+// redisKeyView is a key with the version that confirms a change of it.
+type redisKeyView struct {
+	*infrastructure.KeyValue
+	Version string `json:"version"`
+}
+
+// Changes of keys are confirmed: a request without "confirm" changes
+// nothing and answers 428 with the current value, the change and the
+// version to confirm it with. Repeating the request with that version in
+// "confirm" applies the change, unless the key changed in between (409).
+type redisKeyChange struct {
+	Key     string `json:"key" binding:"required"`
+	Confirm string `json:"confirm"`
+}
+
+type redisKeyEditRequest struct {
+	redisKeyChange
+	infrastructure.KeyEdit
+}
+
+type redisKeyTTLRequest struct {
+	redisKeyChange
+	TTL *int64 `json:"ttl" binding:"required"` // seconds; 0 removes the expiry
+}
+
+// handleRedisKeyEdit changes one part of a value, see
+// infrastructure.KeyEdit.
+func (m *Monitor) handleRedisKeyEdit(c *gin.Context) {
+	var req redisKeyEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	current, ok := m.confirmKeyChange(c, manager, req.redisKeyChange, req.KeyEdit)
+	if !ok {
+		return
+	}
+	if err := manager.EditKey(c.Request.Context(), req.Key, current.Type, req.KeyEdit); err != nil {
+		writeRedisKeyError(c, err)
+		return
+	}
+	auditDetail(c, "key", req.Key)
+	auditDetail(c, "op", req.Op)
+	m.respondKey(c, manager, req.Key, "Key updated")
+}
+
+// handleRedisKeyTTL sets the time to live of a key in seconds, or removes
+// it with 0.
+func (m *Monitor) handleRedisKeyTTL(c *gin.Context) {
+	var req redisKeyTTLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+	if *req.TTL < 0 {
+		response.BadRequest(c, "ttl must not be negative")
+		return
+	}
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	if _, ok := m.confirmKeyChange(c, manager, req.redisKeyChange, map[string]int64{"ttl": *req.TTL}); !ok {
+		return
+	}
+	if err := manager.SetKeyTTL(c.Request.Context(), req.Key, time.Duration(*req.TTL)*time.Second); err != nil {
+		writeRedisKeyError(c, err)
+		return
+	}
+	auditDetail(c, "key", req.Key)
+	auditDetail(c, "ttl", *req.TTL)
+	m.respondKey(c, manager, req.Key, "TTL updated")
+}
+
+// handleRedisKeyDelete deletes ?key=, confirmed by ?confirm=.
+func (m *Monitor) handleRedisKeyDelete(c *gin.Context) {
+	change := redisKeyChange{Key: c.Query("key"), Confirm: c.Query("confirm")}
+	if change.Key == "" {
+		response.BadRequest(c, "key is required")
+		return
+	}
+	manager, ok := m.redisManager(c)
+	if !ok {
+		return
+	}
+	current, ok := m.confirmKeyChange(c, manager, change, map[string]string{"op": "delete"})
+	if !ok {
+		return
+	}
+	if err := manager.DeleteKey(c.Request.Context(), change.Key, current.Type); err != nil {
+		writeRedisKeyError(c, err)
+		return
+	}
+	auditDetail(c, "key", change.Key)
+	auditDetail(c, "type", current.Type)
+	response.Success(c, nil, "Key deleted")
+}
+
+// confirmKeyChange returns the current value of the key when the change is
+// confirmed by its version, or writes the preview or conflict.
+func (m *Monitor) confirmKeyChange(c *gin.Context, manager *infrastructure.RedisManager, req redisKeyChange, change interface{}) (*infrastructure.KeyValue, bool) {
+	current, err := manager.KeyValue(c.Request.Context(), req.Key, 0)
+	if err != nil {
+		writeRedisKeyError(c, err)
+		return nil, false
+	}
+	version := keyVersion(current)
+	if req.Confirm == version {
+		return current, true
+	}
+	preview := map[string]interface{}{"current": current, "change": change, "confirm": version}
+	if req.Confirm == "" {
+		response.Error(c, http.StatusPreconditionRequired, "CONFIRMATION_REQUIRED", "Repeat the request with the given confirm value to apply it", preview)
+	} else {
+		response.Error(c, http.StatusConflict, "KEY_CHANGED", "The key changed since the confirmation was issued", preview)
+	}
+	return nil, false
+}
+
+// respondKey writes the value of key after a change.
+func (m *Monitor) respondKey(c *gin.Context, manager *infrastructure.RedisManager, key, message string) {
+	kv, err := manager.KeyValue(c.Request.Context(), key, 0)
+	if errors.Is(err, infrastructure.ErrKeyNotFound) {
+		// Removing the last element of a collection removes the key
+		response.Success(c, nil, message)
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, redisKeyView{kv, keyVersion(kv)}, message)
+}
+
+// keyVersion identifies what the dashboard saw of a key: its type and
+// value, but not its TTL, which counts down on its own.
+func keyVersion(kv *infrastructure.KeyValue) string {
+	seen := *kv
+	seen.TTL = 0
+	raw, _ := json.Marshal(seen)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+func writeRedisKeyError(c *gin.Context, err error) {
+	var replyErr redis.Error
+	switch {
+	case errors.Is(err, infrastructure.ErrKeyNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, infrastructure.ErrKeyType), errors.Is(err, infrastructure.ErrKeyChanged):
+		response.Conflict(c, err.Error())
+	case errors.Is(err, infrastructure.ErrInvalidEdit):
+		response.BadRequest(c, err.Error())
+	case errors.As(err, &replyErr):
+		// e.g. a list index out of range
+		response.Error(c, http.StatusUnprocessableEntity, "REDIS_ERROR", err.Error())
+	default:
+		response.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,88 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"stackyrd/internal/reporting"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerReportRoutes(g *gin.RouterGroup) {
+	g.GET("/reports", m.handleReports)
+	g.POST("/reports", m.handleGenerateReport)
+	g.GET("/reports/:id", m.handleReport)
+}
+
+// reportGenerator returns the generator or writes a 404 when reports are
+// off.
+func (m *Monitor) reportGenerator(c *gin.Context) (*reporting.Generator, bool) {
+	generator, ok := registry.GetTyped[*reporting.Generator](m.dependencies, "reports")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "REPORTS_DISABLED", "Reports are not enabled")
+	}
+	return generator, ok
+}
+
+// handleReports lists the kept reports, newest first.
+func (m *Monitor) handleReports(c *gin.Context) {
+	generator, ok := m.reportGenerator(c)
+	if !ok {
+		return
+	}
+	list, err := generator.List()
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, list)
+}
+
+// handleGenerateReport generates a report now, emailing it to the
+// configured recipients with ?email=true.
+func (m *Monitor) handleGenerateReport(c *gin.Context) {
+	generator, ok := m.reportGenerator(c)
+	if !ok {
+		return
+	}
+	report, err := generator.Generate(c.Request.Context(), c.Query("email") == "true")
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	auditDetail(c, "report", report.ID)
+	response.Created(c, report, "Report generated")
+}
+
+// handleReport downloads a report as JSON, CSV or HTML (?format=).
+func (m *Monitor) handleReport(c *gin.Context) {
+	generator, ok := m.reportGenerator(c)
+	if !ok {
+		return
+	}
+	report, err := generator.Get(c.Param("id"))
+	if errors.Is(err, reporting.ErrReportNotFound) {
+		response.Error(c, http.StatusNotFound, "REPORT_NOT_FOUND", "Report not found")
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = reporting.FormatJSON
+	}
+	data, contentType, err := reporting.Render(report, format)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "report-"+report.ID+"."+format))
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,97 @@
+package monitoring
+
+import (
+	"errors"
+	"net/http"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerRestartRoutes(g *gin.RouterGroup) {
+	g.POST("/infrastructure/:component/restart", m.handleRestartComponent)
+	g.GET("/infrastructure/:component/restart", m.handleRestartStatus)
+	g.GET("/infrastructure/:component/restart/stream", m.handleRestartStream)
+	g.GET("/infrastructure/:component/restart/poll", m.handleRestartStream)
+}
+
+// restarter returns the restarter or writes a 404 when the server did not
+// register one.
+func (m *Monitor) restarter(c *gin.Context) (*infrastructure.Restarter, bool) {
+	restarter, ok := registry.GetTyped[*infrastructure.Restarter](m.dependencies, "restarter")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "RESTART_UNAVAILABLE", "Component restarts are not available")
+	}
+	return restarter, ok
+}
+
+// handleRestartComponent closes and re-creates one infrastructure manager
+// (e.g. Kafka after broker maintenance) without restarting the process.
+// It answers at once; the steps follow on the stream in Location.
+func (m *Monitor) handleRestartComponent(c *gin.Context) {
+	restarter, ok := m.restarter(c)
+	if !ok {
+		return
+	}
+	name := c.Param("component")
+	progress, err := restarter.Restart(name)
+	switch {
+	case errors.Is(err, infrastructure.ErrUnknownComponent):
+		response.NotFound(c, "Unknown infrastructure component: "+name)
+		return
+	case errors.Is(err, infrastructure.ErrRestartInProgress):
+		response.Error(c, http.StatusConflict, "RESTART_IN_PROGRESS", err.Error(), map[string]interface{}{"restart": progress.Status()})
+		return
+	case err != nil:
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	m.logger.Info("Infrastructure component restart requested", "component", name, "actor", actor(c))
+	c.Header("Location", "/api/infrastructure/"+name+"/restart/stream")
+	response.Success(c, progress.Status(), "Restart started")
+}
+
+// handleRestartStatus returns the latest restart of the component.
+func (m *Monitor) handleRestartStatus(c *gin.Context) {
+	progress, ok := m.restartProgress(c)
+	if !ok {
+		return
+	}
+	response.Success(c, progress.Status())
+}
+
+// handleRestartStream streams the steps of the latest restart of the
+// component: closing, each connection attempt, reloading, then done or
+// failed. Like the other streams it is SSE or a long poll, resumable by
+// step seq.
+func (m *Monitor) handleRestartStream(c *gin.Context) {
+	progress, ok := m.restartProgress(c)
+	if !ok {
+		return
+	}
+	serveStream(c, "restart", streamFlush{}, func(after uint64) ([]streamEvent, uint64, bool, <-chan struct{}) {
+		steps, _, wait := progress.Since(after)
+		cursor := after
+		events := make([]streamEvent, len(steps))
+		for i, step := range steps {
+			events[i] = streamEvent{ID: step.Seq, Data: step}
+			cursor = step.Seq
+		}
+		return events, cursor, false, wait
+	})
+}
+
+func (m *Monitor) restartProgress(c *gin.Context) (*infrastructure.RestartProgress, bool) {
+	restarter, ok := m.restarter(c)
+	if !ok {
+		return nil, false
+	}
+	progress, ok := restarter.Progress(c.Param("component"))
+	if !ok {
+		response.NotFound(c, "No restart of "+c.Param("component")+" since startup")
+	}
+	return progress, ok
+}
@@ -0,0 +1,65 @@
+package monitoring
+
+import (
+	"context"
+
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerServiceRoutes(g *gin.RouterGroup) {
+	g.GET("/services", m.handleServices)
+}
+
+// serviceInfo is one service of GET /services.
+type serviceInfo struct {
+	Name         string                 `json:"name"`
+	WireName     string                 `json:"wire_name"`
+	Enabled      bool                   `json:"enabled"`
+	Endpoints    []string               `json:"endpoints"`
+	Dependencies []string               `json:"dependencies"`
+	Needs        []string               `json:"needs,omitempty"`
+	Deferred     bool                   `json:"deferred"`
+	Health       registry.ServiceHealth `json:"health"`
+}
+
+// handleServices lists the services of the current engine with the
+// dependencies they looked up and the result of their health check, run
+// now: ok, down (with the error), degraded while it is deferred until its
+// needs connect, disabled or unchecked when the service has no check.
+func (m *Monitor) handleServices(c *gin.Context) {
+	response.Success(c, m.serviceList(c.Request.Context()))
+}
+
+func (m *Monitor) serviceList(ctx context.Context) map[string]interface{} {
+	health := registry.CheckHealth(ctx, m.services, registry.DefaultHealthTimeout)
+	services := make([]serviceInfo, len(m.services))
+	down, degraded := 0, 0
+	for i, s := range m.services {
+		_, deferred := s.(*registry.DeferredService)
+		services[i] = serviceInfo{
+			Name:         s.Name(),
+			WireName:     s.WireName(),
+			Enabled:      s.Enabled(),
+			Endpoints:    s.Endpoints(),
+			Dependencies: registry.ServiceDependencies(s.Name()),
+			Needs:        registry.ServiceNeeds(s.Name()),
+			Deferred:     deferred,
+			Health:       health[i],
+		}
+		switch health[i].Status {
+		case registry.HealthDown:
+			down++
+		case registry.HealthDegraded:
+			degraded++
+		}
+	}
+	return map[string]interface{}{
+		"services": services,
+		"total":    len(services),
+		"down":     down,
+		"degraded": degraded,
+	}
+}
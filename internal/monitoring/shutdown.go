@@ -0,0 +1,25 @@
+package monitoring
+
+import (
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/shutdown"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerShutdownRoutes(g *gin.RouterGroup) {
+	g.GET("/debug/last-shutdown", m.handleLastShutdown)
+}
+
+// handleLastShutdown returns the report the previous run wrote while
+// shutting down: why, how the drain went, how long each component took to
+// close and the errors. complete is false when it was forced to exit.
+func (m *Monitor) handleLastShutdown(c *gin.Context) {
+	report, ok := registry.GetTyped[*shutdown.Report](m.dependencies, "last_shutdown")
+	if !ok {
+		response.NotFound(c, "No shutdown report from a previous run")
+		return
+	}
+	response.Success(c, report)
+}
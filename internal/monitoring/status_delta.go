@@ -0,0 +1,91 @@
+package monitoring
+
+import (
+	"sync"
+	"time"
+
+	"stackyrd/pkg/jsonpatch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusDeltaHistory is how many status versions are kept to patch from;
+// a client behind by more gets a fresh snapshot.
+const statusDeltaHistory = 32
+
+// statusDelta is one event of the delta status stream: a full "snapshot"
+// or a "patch" (RFC 6902) from the client's version to Version.
+type statusDelta struct {
+	Type    string                `json:"type"`
+	Version uint64                `json:"version"`
+	From    uint64                `json:"from,omitempty"`
+	Status  interface{}           `json:"status,omitempty"`
+	Ops     []jsonpatch.Operation `json:"ops,omitempty"`
+}
+
+// statusHistory keeps the last status documents by version, shared by all
+// delta streams so any of them can patch from a version another produced.
+type statusHistory struct {
+	mu    sync.Mutex
+	docs  map[uint64]interface{}
+	order []uint64
+}
+
+func newStatusHistory() *statusHistory {
+	return &statusHistory{docs: make(map[uint64]interface{})}
+}
+
+func (h *statusHistory) record(version uint64, doc interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.docs[version]; ok {
+		return
+	}
+	h.docs[version] = doc
+	h.order = append(h.order, version)
+	if len(h.order) > statusDeltaHistory {
+		delete(h.docs, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+func (h *statusHistory) get(version uint64) (interface{}, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	doc, ok := h.docs[version]
+	return doc, ok
+}
+
+func (m *Monitor) registerStatusDeltaRoutes(g *gin.RouterGroup) {
+	g.GET("/status/delta/stream", m.handleStatusDeltas)
+	g.GET("/status/delta/poll", m.handleStatusDeltas)
+}
+
+// handleStatusDeltas streams /status as a snapshot followed by JSON
+// patches, sent only when the status changes. The status is sent without
+// uptime_seconds; clients derive it from started_at. A cursor whose
+// document is no longer known gets a snapshot again.
+func (m *Monitor) handleStatusDeltas(c *gin.Context) {
+	serveStream(c, "snapshot", streamFlush{}, func(after uint64) ([]streamEvent, uint64, bool, <-chan struct{}) {
+		wait := make(chan struct{})
+		time.AfterFunc(statusStreamInterval, func() { close(wait) })
+
+		status := stableStatus(m.status())
+		version := statusVersion(status)
+		if version == after {
+			return nil, version, false, wait
+		}
+		doc, err := jsonpatch.Normalize(status)
+		if err != nil {
+			m.logger.Error("Failed to encode status for the delta stream", err)
+			return nil, after, false, wait
+		}
+		m.statusHistory.record(version, doc)
+
+		delta := statusDelta{Type: "snapshot", Version: version, Status: doc}
+		if base, ok := m.statusHistory.get(after); ok && after != 0 {
+			delta = statusDelta{Type: "patch", Version: version, From: after, Ops: jsonpatch.Diff(base, doc)}
+		}
+		return []streamEvent{{ID: version, Event: delta.Type, Data: delta}}, version, false, wait
+	})
+}
@@ -0,0 +1,282 @@
+package monitoring
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Live streams are served as server-sent events or, where proxies strip
+// SSE, as long polls on the same cursor.
+const (
+	streamPollTimeout    = 25 * time.Second // below the idle timeout of most proxies
+	streamPollMaxTimeout = 60 * time.Second
+	streamHeartbeat      = 15 * time.Second
+	streamBatch          = 500
+	statusStreamInterval = 2 * time.Second
+)
+
+// streamFlush coalesces the server-sent events of a burst into fewer
+// flushes: after a flush, events arriving within Window are written
+// together and flushed when it ends or MaxEvents are pending. The first
+// event after a quiet Window is flushed at once. A zero Window flushes
+// every write.
+type streamFlush struct {
+	Window    time.Duration
+	MaxEvents int
+}
+
+// logStreamFlush is the coalescing of the log stream
+// (monitoring.stream).
+func (m *Monitor) logStreamFlush() streamFlush {
+	cfg := m.config.Monitoring.Stream
+	return streamFlush{
+		Window:    time.Duration(cfg.FlushInterval) * time.Millisecond,
+		MaxEvents: cfg.FlushEvents,
+	}
+}
+
+// streamEvent is one event of a live stream; ID is the cursor to resume
+// after it. Event overrides the stream's SSE event name.
+type streamEvent struct {
+	ID    uint64
+	Event string
+	Data  interface{}
+}
+
+// streamFeed returns the events after cursor, the cursor to resume from,
+// whether events after cursor were dropped, and a channel closed when more
+// events may be available.
+type streamFeed func(after uint64) (events []streamEvent, cursor uint64, missed bool, wait <-chan struct{})
+
+// pollResult answers a long poll. Passing Cursor as since to the next poll
+// continues the stream.
+type pollResult struct {
+	Events    []interface{} `json:"events"`
+	Cursor    uint64        `json:"cursor"`
+	Missed    bool          `json:"missed"` // events after since left the buffer before they were read
+	Transport string        `json:"transport"`
+}
+
+func (m *Monitor) registerStreamRoutes(g *gin.RouterGroup) {
+	g.GET("/logs/stream", m.handleLogStream)
+	g.GET("/logs/poll", m.handleLogStream)
+	g.GET("/status/stream", m.handleStatusStream)
+	g.GET("/status/poll", m.handleStatusStream)
+	m.registerStatusDeltaRoutes(g)
+}
+
+// handleLogStream streams new log lines by broadcaster sequence number,
+// from ?since= or the Last-Event-ID header of a reconnecting EventSource.
+func (m *Monitor) handleLogStream(c *gin.Context) {
+	logs, ok := registry.GetTyped[*logger.LogBroadcaster](m.dependencies, "logs")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "LOGS_UNAVAILABLE", "Log stream is not available")
+		return
+	}
+	serveStream(c, "log", m.logStreamFlush(), func(after uint64) ([]streamEvent, uint64, bool, <-chan struct{}) {
+		wait := logs.Changed()
+		entries, missed := logs.Since(after, streamBatch)
+		if missed && len(entries) > 0 {
+			logs.RecordStreamMissed(entries[0].Seq - after - 1)
+		}
+		cursor := after
+		if len(entries) == 0 && after > logs.Seq() {
+			// The cursor is from before a restart
+			cursor = logs.Seq()
+		}
+		events := make([]streamEvent, len(entries))
+		for i, e := range entries {
+			events[i] = streamEvent{ID: e.Seq, Data: e}
+			cursor = e.Seq
+		}
+		return events, cursor, missed, wait
+	})
+}
+
+// handleStatusStream sends /status whenever it changes, checked every
+// statusStreamInterval. The cursor is a version of the status, not a
+// sequence number.
+func (m *Monitor) handleStatusStream(c *gin.Context) {
+	serveStream(c, "status", streamFlush{}, func(after uint64) ([]streamEvent, uint64, bool, <-chan struct{}) {
+		status := m.status()
+		version := statusVersion(status)
+		var events []streamEvent
+		if version != after {
+			events = []streamEvent{{ID: version, Data: status}}
+		}
+		wait := make(chan struct{})
+		time.AfterFunc(statusStreamInterval, func() { close(wait) })
+		return events, version, false, wait
+	})
+}
+
+// statusVersion digests status without its uptime, which changes every
+// second. It fits in 53 bits so JavaScript clients keep it exact.
+func statusVersion(status map[string]interface{}) uint64 {
+	data, _ := json.Marshal(stableStatus(status))
+	sum := sha256.Sum256(data)
+	return binary.BigEndian.Uint64(sum[:8])&(1<<53-1) | 1
+}
+
+// stableStatus is status without uptime_seconds.
+func stableStatus(status map[string]interface{}) map[string]interface{} {
+	stable := make(map[string]interface{}, len(status))
+	for k, v := range status {
+		if k != "uptime_seconds" {
+			stable[k] = v
+		}
+	}
+	return stable
+}
+
+// streamTransport negotiates how a stream is served: ?transport=sse or
+// poll, else SSE for clients accepting text/event-stream (EventSource) on
+// the /stream routes and a long poll otherwise.
+func streamTransport(c *gin.Context) string {
+	switch t := c.Query("transport"); t {
+	case "sse", "poll":
+		return t
+	}
+	if strings.HasSuffix(c.FullPath(), "/stream") && strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return "sse"
+	}
+	return "poll"
+}
+
+func serveStream(c *gin.Context, event string, flush streamFlush, feed streamFeed) {
+	since := c.Query("since")
+	if since == "" {
+		since = c.GetHeader("Last-Event-ID")
+	}
+	var after uint64
+	if since != "" {
+		var err error
+		if after, err = strconv.ParseUint(since, 10, 64); err != nil {
+			response.BadRequest(c, "Invalid 'since', use the cursor or event id last received")
+			return
+		}
+	}
+	if streamTransport(c) == "sse" {
+		serveSSE(c, event, after, flush, feed)
+		return
+	}
+	servePoll(c, after, feed)
+}
+
+// servePoll answers as soon as there are events after the cursor, or
+// with none after ?timeout= seconds.
+func servePoll(c *gin.Context, after uint64, feed streamFeed) {
+	timeout := streamPollTimeout
+	if secs, err := strconv.Atoi(c.Query("timeout")); err == nil && secs >= 0 {
+		timeout = min(time.Duration(secs)*time.Second, streamPollMaxTimeout)
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		events, cursor, missed, wait := feed(after)
+		if len(events) > 0 || missed {
+			result := pollResult{Events: make([]interface{}, len(events)), Cursor: cursor, Missed: missed, Transport: "poll"}
+			for i, e := range events {
+				result.Events[i] = e.Data
+			}
+			c.Header("Cache-Control", "no-store")
+			response.Success(c, result)
+			return
+		}
+		select {
+		case <-wait:
+			after = cursor
+		case <-deadline.C:
+			c.Header("Cache-Control", "no-store")
+			response.Success(c, pollResult{Events: []interface{}{}, Cursor: cursor, Transport: "poll"})
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// serveSSE sends events with their cursor as the event id, so a
+// reconnecting EventSource resumes where it stopped. Dropped events are
+// announced with a "missed" event. Writes are flushed as flush allows.
+func serveSSE(c *gin.Context, event string, after uint64, flush streamFlush, feed streamFeed) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	var lastFlush time.Time
+	// Each step returns to c.Stream, which flushes what it wrote
+	c.Stream(func(w io.Writer) bool {
+		pending := 0
+		for {
+			events, cursor, missed, wait := feed(after)
+			if missed {
+				fmt.Fprintf(w, "event: missed\ndata: {\"after\":%d}\n\n", after)
+				pending++
+			}
+			for _, e := range events {
+				data, err := json.Marshal(e.Data)
+				if err != nil {
+					continue
+				}
+				name := event
+				if e.Event != "" {
+					name = e.Event
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, name, data)
+			}
+			pending += len(events)
+			after = cursor
+
+			if pending > 0 {
+				hold := flush.Window - time.Since(lastFlush)
+				if hold <= 0 || (flush.MaxEvents > 0 && pending >= flush.MaxEvents) {
+					lastFlush = time.Now()
+					return true
+				}
+				// Within the window of the last flush: keep writing what
+				// arrives until the window ends
+				timer := time.NewTimer(hold)
+				select {
+				case <-wait:
+					timer.Stop()
+					continue
+				case <-timer.C:
+					lastFlush = time.Now()
+					return true
+				case <-c.Request.Context().Done():
+					timer.Stop()
+					return false
+				}
+			}
+
+			select {
+			case <-wait:
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package monitoring
+
+import (
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenantSizeTTL bounds how often storage and database sizes are measured;
+// listing every tenant prefix is too expensive to do per request.
+const tenantSizeTTL = 5 * time.Minute
+
+func (m *Monitor) registerTenantMetricsRoutes(g *gin.RouterGroup) {
+	g.GET("/tenants/metrics", m.handleTenantMetrics)
+}
+
+// newTenantSizer measures storage under the tenant object prefix and the
+// size of Postgres connections named after a tenant.
+func newTenantSizer(m *Monitor) *infrastructure.TenantSizer {
+	sizer := &infrastructure.TenantSizer{
+		PrefixPattern: m.config.TenantData.ObjectPrefix,
+		TTL:           tenantSizeTTL,
+	}
+	sizer.Storage, _ = registry.GetTyped[*infrastructure.StorageManager](m.dependencies, "storage")
+	sizer.Postgres, _ = registry.GetTyped[*infrastructure.PostgresConnectionManager](m.dependencies, "postgres")
+	return sizer
+}
+
+// handleTenantMetrics returns per-tenant usage accumulated since startup
+// for capacity planning and chargeback.
+func (m *Monitor) handleTenantMetrics(c *gin.Context) {
+	m.tenantSizer.Refresh(c.Request.Context())
+	collector := tenancy.DefaultCollector()
+	response.Success(c, map[string]interface{}{
+		"since":   collector.Since(),
+		"tenants": collector.Snapshot(),
+	})
+}
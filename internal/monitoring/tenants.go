@@ -0,0 +1,179 @@
+package monitoring
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stackyrd/internal/tenantdata"
+	"stackyrd/internal/tenantprovision"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerTenantRoutes(g *gin.RouterGroup) {
+	g.GET("/tenants", m.handleTenants)
+	g.POST("/tenants", m.handleProvisionTenant)
+	g.POST("/tenants/:tenant/export", m.handleTenantExport)
+	g.POST("/tenants/:tenant/deletion", m.handleTenantDeletion)
+	g.GET("/tenants/:tenant/deletion", m.handleTenantDeletionPlan)
+	g.GET("/tenants/:tenant/audit", m.handleTenantAudit)
+}
+
+func (m *Monitor) tenantData(c *gin.Context) (*tenantdata.Service, bool) {
+	service, ok := registry.GetTyped[*tenantdata.Service](m.dependencies, "tenant_data")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "TENANT_DATA_DISABLED", "Tenant data workflows are not enabled")
+	}
+	return service, ok
+}
+
+// handleTenants lists the registered tenants and their connections.
+func (m *Monitor) handleTenants(c *gin.Context) {
+	response.Success(c, tenancy.DefaultRegistry().List())
+}
+
+// handleProvisionTenant connects, migrates and registers a new tenant; see
+// tenantprovision.Provisioner.
+func (m *Monitor) handleProvisionTenant(c *gin.Context) {
+	provisioner, ok := registry.GetTyped[*tenantprovision.Provisioner](m.dependencies, "tenant_provisioner")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "TENANT_PROVISIONING_DISABLED", "Tenant provisioning is not enabled")
+		return
+	}
+	var req tenantprovision.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	result, err := provisioner.Provision(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, tenantprovision.ErrInvalidTenant):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, tenantprovision.ErrTenantExists):
+			response.Conflict(c, err.Error())
+		case errors.Is(err, tenantprovision.ErrMigrationFailed):
+			response.Error(c, http.StatusInternalServerError, "TENANT_MIGRATION_FAILED", err.Error())
+		default:
+			response.Error(c, http.StatusBadGateway, "TENANT_PROVISIONING_FAILED", err.Error())
+		}
+		return
+	}
+	m.logger.Info("Tenant provisioned through the API", "tenant", req.ID, "by", actor(c))
+	auditDetail(c, "tenant", req.ID)
+	auditDetail(c, "persisted", result.Persisted)
+	response.Created(c, result, "Tenant provisioned")
+}
+
+// actor identifies who triggered a workflow for the audit trail.
+func actor(c *gin.Context) string {
+	for _, key := range []string{"username", "user_id"} {
+		if v, ok := c.Get(key); ok {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return c.ClientIP()
+}
+
+// handleTenantExport queues an export archive of all tenant data.
+func (m *Monitor) handleTenantExport(c *gin.Context) {
+	service, ok := m.tenantData(c)
+	if !ok {
+		return
+	}
+	job, err := service.StartExport(c.Param("tenant"), actor(c))
+	if err != nil {
+		response.Error(c, http.StatusConflict, "EXPORT_UNAVAILABLE", err.Error())
+		return
+	}
+	c.Header("Location", "/api/jobs/"+job.ID)
+	auditDetail(c, "job_id", job.ID)
+	response.Success(c, job, "Export queued")
+}
+
+type tenantDeletionRequest struct {
+	DryRun  *bool  `json:"dry_run"`
+	Confirm string `json:"confirm"`
+}
+
+// handleTenantDeletion runs a dry run (default) or, with the token of the
+// latest dry run in "confirm", the actual deletion.
+func (m *Monitor) handleTenantDeletion(c *gin.Context) {
+	service, ok := m.tenantData(c)
+	if !ok {
+		return
+	}
+	var req tenantDeletionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request body")
+			return
+		}
+	}
+
+	tenant := c.Param("tenant")
+	if req.DryRun == nil || *req.DryRun {
+		job := service.StartDryRun(tenant, actor(c))
+		c.Header("Location", "/api/jobs/"+job.ID)
+		auditDetail(c, "job_id", job.ID)
+		response.Success(c, job, "Deletion dry run queued")
+		return
+	}
+
+	if req.Confirm == "" {
+		response.BadRequest(c, "Deletion requires the confirmation token from a dry run")
+		return
+	}
+	job, err := service.StartDeletion(tenant, req.Confirm, actor(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, tenantdata.ErrNoPlan), errors.Is(err, tenantdata.ErrPlanExpired), errors.Is(err, tenantdata.ErrTokenMismatch):
+			response.Error(c, http.StatusConflict, "DELETION_NOT_CONFIRMED", err.Error())
+		default:
+			response.InternalServerError(c, err.Error())
+		}
+		return
+	}
+	c.Header("Location", "/api/jobs/"+job.ID)
+	auditDetail(c, "job_id", job.ID)
+	response.Success(c, job, "Deletion queued")
+}
+
+// handleTenantDeletionPlan returns the latest dry run for the tenant.
+func (m *Monitor) handleTenantDeletionPlan(c *gin.Context) {
+	service, ok := m.tenantData(c)
+	if !ok {
+		return
+	}
+	plan, found, err := service.Plan(c.Param("tenant"))
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	if !found {
+		response.NotFound(c, "No deletion dry run for tenant")
+		return
+	}
+	response.Success(c, plan)
+}
+
+func (m *Monitor) handleTenantAudit(c *gin.Context) {
+	service, ok := m.tenantData(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	records, err := service.Audit(c.Param("tenant"), limit)
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	response.Success(c, records)
+}
@@ -0,0 +1,186 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerUsageRoutes(g *gin.RouterGroup) {
+	g.GET("/usage", m.handleUsage)
+	g.GET("/usage/export", m.handleUsageExport)
+	g.GET("/usage/:type/:principal", m.handleUsagePrincipal)
+	g.DELETE("/usage", m.handleResetUsage)
+}
+
+// usageOrders sort /usage, busiest, most failing or most recent first.
+var usageOrders = map[string]func(a, b usage.Usage) bool{
+	"calls":      func(a, b usage.Usage) bool { return a.Calls > b.Calls },
+	"errors":     func(a, b usage.Usage) bool { return a.Errors > b.Errors },
+	"error_rate": func(a, b usage.Usage) bool { return a.ErrorRate > b.ErrorRate },
+	"last_seen":  func(a, b usage.Usage) bool { return a.LastSeen.After(b.LastSeen) },
+}
+
+// usageList applies the type, sort, limit and top query parameters of the
+// usage endpoints, writing a 400 when one is invalid.
+func usageList(c *gin.Context) ([]usage.Usage, bool) {
+	order := c.DefaultQuery("sort", "calls")
+	less, ok := usageOrders[order]
+	if !ok {
+		response.BadRequest(c, "Invalid sort, use calls, errors, error_rate or last_seen")
+		return nil, false
+	}
+	top := usage.DefaultTopEndpoints
+	if value := c.Query("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			response.BadRequest(c, "Invalid top")
+			return nil, false
+		}
+		top = n
+	}
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			response.BadRequest(c, "Invalid limit")
+			return nil, false
+		}
+		limit = n
+	}
+
+	all := usage.Default().Snapshot(top)
+	list := make([]usage.Usage, 0, len(all))
+	for _, u := range all {
+		if typ := c.Query("type"); typ == "" || typ == u.Type {
+			list = append(list, u)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return less(list[i], list[j]) })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, true
+}
+
+// handleUsage returns per-principal usage (API keys and users): calls,
+// error rate, top endpoints and last seen, filtered by ?type=.
+func (m *Monitor) handleUsage(c *gin.Context) {
+	list, ok := usageList(c)
+	if !ok {
+		return
+	}
+	var calls, errors int64
+	for _, u := range list {
+		calls += u.Calls
+		errors += u.Errors
+	}
+	response.Success(c, map[string]interface{}{
+		"since":      usage.Default().Since(),
+		"calls":      calls,
+		"errors":     errors,
+		"principals": list,
+	})
+}
+
+// handleUsagePrincipal returns one principal's usage with every endpoint
+// it called.
+func (m *Monitor) handleUsagePrincipal(c *gin.Context) {
+	u, ok := usage.Default().Get(c.Param("type"), c.Param("principal"))
+	if !ok {
+		response.NotFound(c, "No usage recorded for this principal")
+		return
+	}
+	response.Success(c, u)
+}
+
+// Columns of the usage export by principal and by endpoint.
+var (
+	usageColumns         = []string{"principal", "type", "calls", "errors", "server_errors", "error_rate", "avg_latency_ms", "first_seen", "last_seen"}
+	usageEndpointColumns = []string{"principal", "type", "method", "route", "calls", "errors", "last_seen"}
+)
+
+// handleUsageExport downloads usage as CSV (default) or JSON. With
+// ?by=endpoint there is one row per principal and endpoint, otherwise one
+// per principal.
+func (m *Monitor) handleUsageExport(c *gin.Context) {
+	byEndpoint := false
+	switch c.DefaultQuery("by", "principal") {
+	case "principal":
+	case "endpoint":
+		byEndpoint = true
+	default:
+		response.BadRequest(c, "Invalid by, use principal or endpoint")
+		return
+	}
+	list, ok := usageExportList(c, byEndpoint)
+	if !ok {
+		return
+	}
+	name := "usage-" + time.Now().UTC().Format("20060102-150405")
+
+	switch c.DefaultQuery("format", "csv") {
+	case "json":
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			response.InternalServerError(c, err.Error())
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		c.Data(http.StatusOK, "application/json", data)
+	case "csv":
+		writeCSV(c, name, usageTable(list, byEndpoint))
+	default:
+		response.BadRequest(c, "Invalid format, use csv or json")
+	}
+}
+
+// usageExportList is usageList with every endpoint when exporting by
+// endpoint.
+func usageExportList(c *gin.Context, byEndpoint bool) ([]usage.Usage, bool) {
+	list, ok := usageList(c)
+	if !ok || !byEndpoint {
+		return list, ok
+	}
+	for i, u := range list {
+		if full, found := usage.Default().Get(u.Type, u.Principal); found {
+			list[i].TopEndpoints = full.TopEndpoints
+		}
+	}
+	return list, true
+}
+
+// usageTable flattens usage into rows for CSV.
+func usageTable(list []usage.Usage, byEndpoint bool) *querybook.Result {
+	result := &querybook.Result{Columns: usageColumns, Rows: [][]interface{}{}}
+	if byEndpoint {
+		result.Columns = usageEndpointColumns
+	}
+	for _, u := range list {
+		if !byEndpoint {
+			result.Rows = append(result.Rows, []interface{}{u.Principal, u.Type, u.Calls, u.Errors, u.ServerErrors, u.ErrorRate, u.AvgLatencyMs, u.FirstSeen, u.LastSeen})
+			continue
+		}
+		for _, ep := range u.TopEndpoints {
+			result.Rows = append(result.Rows, []interface{}{u.Principal, u.Type, ep.Method, ep.Route, ep.Calls, ep.Errors, ep.LastSeen})
+		}
+	}
+	result.RowCount = len(result.Rows)
+	return result
+}
+
+// handleResetUsage clears the per-principal figures.
+func (m *Monitor) handleResetUsage(c *gin.Context) {
+	usage.Default().Reset()
+	m.logger.Info("API usage reset", "by", actor(c))
+	response.Success(c, nil, "API usage reset")
+}
@@ -0,0 +1,76 @@
+package monitoring
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"os"
+
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed fallback.html
+var fallbackPage string
+
+var fallbackTemplate = template.Must(template.New("fallback").Parse(fallbackPage))
+
+// Sources of the dashboard UI.
+const (
+	WebDirectory = "directory"
+	WebEmbedded  = "embedded"
+	WebDisabled  = "disabled"
+)
+
+func (m *Monitor) registerWebRoutes(g *gin.RouterGroup) {
+	g.POST("/restart", m.handleRestart)
+}
+
+// handleRestart asks the application to shut down gracefully and start
+// again with the configuration on disk.
+func (m *Monitor) handleRestart(c *gin.Context) {
+	m.logger.Warn("Restart requested through the monitoring API")
+	utils.TriggerRestart()
+	response.Success(c, map[string]interface{}{"restarting": true}, "Restarting")
+}
+
+// WebSource reports where the dashboard UI comes from: the configured
+// directory, the embedded fallback page or nowhere.
+func (m *Monitor) WebSource() string {
+	cfg := m.config.Monitoring.Web
+	if cfg.Route == "" {
+		return WebDisabled
+	}
+	if info, err := os.Stat(cfg.Path); cfg.Path != "" && err == nil && info.IsDir() {
+		return WebDirectory
+	}
+	if cfg.Fallback {
+		return WebEmbedded
+	}
+	return WebDisabled
+}
+
+// RegisterWeb serves the dashboard at monitoring.web.route: the files of
+// monitoring.web.path when the directory exists, else the embedded
+// fallback page calling the API mounted at api, so stripped-down
+// deployments keep an admin surface.
+func (m *Monitor) RegisterWeb(r gin.IRoutes, api string) string {
+	cfg := m.config.Monitoring.Web
+	source := m.WebSource()
+	switch source {
+	case WebDirectory:
+		r.Static(cfg.Route, cfg.Path)
+	case WebEmbedded:
+		r.GET(cfg.Route, func(c *gin.Context) {
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			c.Status(http.StatusOK)
+			data := map[string]string{"Name": m.config.App.Name, "API": api}
+			if err := fallbackTemplate.Execute(c.Writer, data); err != nil {
+				m.logger.Error("Failed to render fallback page", err)
+			}
+		})
+	}
+	return source
+}
@@ -0,0 +1,122 @@
+package monitoring
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerWebhookRoutes(g *gin.RouterGroup) {
+	g.GET("/webhooks", m.handleWebhooks)
+	g.GET("/webhooks/deliveries", m.handleWebhookDeliveries)
+	g.GET("/webhooks/deliveries/:id", m.handleWebhookDelivery)
+	g.POST("/webhooks/deliveries/:id/retry", m.handleRetryWebhookDelivery)
+	g.POST("/webhooks/destinations/:name/test", m.handleTestWebhook)
+}
+
+// webhookDispatcher returns the dispatcher or writes a 404 when webhooks
+// are off.
+func (m *Monitor) webhookDispatcher(c *gin.Context) (*webhook.Dispatcher, bool) {
+	dispatcher, ok := registry.GetTyped[*webhook.Dispatcher](m.dependencies, "webhooks")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "WEBHOOKS_DISABLED", "Webhooks are not enabled")
+	}
+	return dispatcher, ok
+}
+
+// handleWebhooks lists the destinations with their delivery counters and
+// the latest failures.
+func (m *Monitor) handleWebhooks(c *gin.Context) {
+	dispatcher, ok := m.webhookDispatcher(c)
+	if !ok {
+		return
+	}
+	response.Success(c, map[string]interface{}{
+		"status":          dispatcher.GetStatus(),
+		"destinations":    dispatcher.Destinations(),
+		"recent_failures": dispatcher.Deliveries(webhook.DeliveryFilter{Status: webhook.StatusFailed, Limit: 10}),
+	})
+}
+
+// handleWebhookDeliveries lists recent deliveries, newest first, filtered
+// by destination, status and event.
+func (m *Monitor) handleWebhookDeliveries(c *gin.Context) {
+	dispatcher, ok := m.webhookDispatcher(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	response.Success(c, dispatcher.Deliveries(webhook.DeliveryFilter{
+		Destination: c.Query("destination"),
+		Status:      c.Query("status"),
+		Event:       c.Query("event"),
+		Limit:       limit,
+	}))
+}
+
+func (m *Monitor) handleWebhookDelivery(c *gin.Context) {
+	dispatcher, ok := m.webhookDispatcher(c)
+	if !ok {
+		return
+	}
+	delivery, err := dispatcher.Delivery(c.Param("id"))
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	response.Success(c, delivery)
+}
+
+// handleRetryWebhookDelivery queues a failed delivery again.
+func (m *Monitor) handleRetryWebhookDelivery(c *gin.Context) {
+	dispatcher, ok := m.webhookDispatcher(c)
+	if !ok {
+		return
+	}
+	delivery, err := dispatcher.Redeliver(c.Param("id"))
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	auditDetail(c, "event", delivery.Event)
+	response.Success(c, delivery, "Delivery queued")
+}
+
+// handleTestWebhook sends a webhook.test event to one destination.
+func (m *Monitor) handleTestWebhook(c *gin.Context) {
+	dispatcher, ok := m.webhookDispatcher(c)
+	if !ok {
+		return
+	}
+	eventID, err := dispatcher.PublishTo(c.Param("name"), "webhook.test", map[string]interface{}{
+		"message": "Test event from the monitoring API",
+		"sent_at": time.Now(),
+	})
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	response.Success(c, map[string]string{"event_id": eventID}, "Test event queued")
+}
+
+func writeWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrDeliveryNotFound):
+		response.Error(c, http.StatusNotFound, "DELIVERY_NOT_FOUND", err.Error())
+	case errors.Is(err, webhook.ErrDestinationNotFound):
+		response.Error(c, http.StatusNotFound, "DESTINATION_NOT_FOUND", err.Error())
+	case errors.Is(err, webhook.ErrNotFailed):
+		response.Error(c, http.StatusConflict, "DELIVERY_NOT_FAILED", err.Error())
+	case errors.Is(err, webhook.ErrQueueFull):
+		response.Error(c, http.StatusServiceUnavailable, "QUEUE_FULL", err.Error())
+	default:
+		response.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,26 @@
+package monitoring
+
+import (
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/websocket"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Monitor) registerWebSocketRoutes(g *gin.RouterGroup) {
+	g.GET("/websockets", m.handleWebSockets)
+}
+
+// handleWebSockets reports the connection and room counts of every
+// WebSocket hub services have created.
+func (m *Monitor) handleWebSockets(c *gin.Context) {
+	hubs := websocket.Hubs()
+	stats := make([]websocket.HubStats, 0, len(hubs))
+	total := 0
+	for _, hub := range hubs {
+		s := hub.Stats()
+		total += s.Clients
+		stats = append(stats, s)
+	}
+	response.Success(c, gin.H{"hubs": stats, "total_clients": total})
+}
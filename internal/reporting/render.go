@@ -0,0 +1,191 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats reports render to.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatHTML = "html"
+)
+
+// Render renders report in format and returns it with its content type.
+func Render(report *Report, format string) ([]byte, string, error) {
+	switch format {
+	case "", FormatJSON:
+		data, err := RenderJSON(report)
+		return data, "application/json", err
+	case FormatCSV:
+		data, err := RenderCSV(report)
+		return data, "text/csv; charset=utf-8", err
+	case FormatHTML:
+		data, err := RenderHTML(report)
+		return data, "text/html; charset=utf-8", err
+	}
+	return nil, "", fmt.Errorf("unknown report format %q, use %s, %s or %s", format, FormatJSON, FormatCSV, FormatHTML)
+}
+
+// RenderJSON renders report as indented JSON.
+func RenderJSON(report *Report) ([]byte, error) {
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// RenderCSV renders report as section,name,metric,value rows.
+func RenderCSV(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{
+		{"section", "name", "metric", "value"},
+		{"report", report.ID, "generated_at", report.GeneratedAt.Format(time.RFC3339)},
+		{"report", report.ID, "from", report.From.Format(time.RFC3339)},
+		{"app", report.App, "version", report.Version},
+		{"app", report.App, "env", report.Env},
+		{"app", report.App, "uptime_seconds", strconv.FormatInt(report.UptimeSeconds, 10)},
+		{"totals", "", "requests", strconv.FormatInt(report.Requests, 10)},
+		{"totals", "", "errors", strconv.FormatInt(report.Errors, 10)},
+		{"totals", "", "components_down", strconv.Itoa(report.Down)},
+	}
+	for _, c := range report.Infrastructure {
+		rows = append(rows, []string{"infrastructure", c.Name, "status", c.Status})
+		if c.Error != "" {
+			rows = append(rows, []string{"infrastructure", c.Name, "error", c.Error})
+		}
+	}
+	for _, e := range report.Endpoints {
+		name := e.Method + " " + e.Route
+		rows = append(rows,
+			[]string{"endpoint", name, "requests", strconv.FormatInt(e.Requests, 10)},
+			[]string{"endpoint", name, "errors", strconv.FormatInt(e.Errors, 10)},
+			[]string{"endpoint", name, "error_rate", formatFloat(e.ErrorRate)},
+			[]string{"endpoint", name, "avg_latency_ms", formatFloat(e.AvgLatencyMs)},
+			[]string{"endpoint", name, "p95_latency_ms", formatFloat(e.P95LatencyMs)},
+		)
+	}
+	for _, level := range logLevels(report) {
+		rows = append(rows, []string{"logs", level, "entries", strconv.FormatUint(report.Logs[level], 10)})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// logLevels returns the levels with entries in report, sorted.
+func logLevels(report *Report) []string {
+	levels := make([]string, 0, len(report.Logs))
+	for level := range report.Logs {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	return levels
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":   func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+	"uptime": func(s int64) string { return (time.Duration(s) * time.Second).String() },
+	"levels": logLevels,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.App}} report {{.ID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #f3f3f3; }
+.up { color: #1a7f37; } .down { color: #cf222e; } .unknown { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.App}} status report</h1>
+<p>Generated {{time .GeneratedAt}}, covering {{time .From}} onwards.<br>
+Version {{.Version}} ({{.Env}}), up {{uptime .UptimeSeconds}}.</p>
+
+<h2>Infrastructure</h2>
+{{if .Infrastructure}}<table>
+<tr><th>Component</th><th>Status</th><th>Error</th></tr>
+{{range .Infrastructure}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>{{else}}<p>No components.</p>{{end}}
+
+<h2>Endpoints</h2>
+<p>{{.Requests}} requests, {{.Errors}} server errors{{if not .EndpointsSince.IsZero}} since {{time .EndpointsSince}}{{end}}.</p>
+{{if .Endpoints}}<table>
+<tr><th>Route</th><th>Requests</th><th>Errors</th><th>Error rate</th><th>Avg ms</th><th>p95 ms</th></tr>
+{{range .Endpoints}}<tr><td>{{.Method}} {{.Route}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{printf "%.2f" .ErrorRate}}%</td><td>{{printf "%.1f" .AvgLatencyMs}}</td><td>{{printf "%.1f" .P95LatencyMs}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Logs</h2>
+{{if .Logs}}<table>
+<tr><th>Level</th><th>Entries</th></tr>
+{{$logs := .Logs}}{{range levels .}}<tr><td>{{.}}</td><td>{{index $logs .}}</td></tr>
+{{end}}</table>{{else}}<p>Nothing logged.</p>{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML renders report as a standalone HTML page.
+func RenderHTML(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, report); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Subject returns the email subject of report.
+func Subject(report *Report) string {
+	state := "all components up"
+	if report.Down > 0 {
+		state = fmt.Sprintf("%d component(s) down", report.Down)
+	}
+	return fmt.Sprintf("[%s] Status report %s: %s", report.App, report.GeneratedAt.Format("2006-01-02"), state)
+}
+
+// RenderText renders report as the plain text body of an email.
+func RenderText(report *Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (%s) status report\n", report.App, report.Version, report.Env)
+	fmt.Fprintf(&b, "Generated %s, covering %s onwards\n", report.GeneratedAt.Format(time.RFC1123), report.From.Format(time.RFC1123))
+	fmt.Fprintf(&b, "Uptime %s\n\n", time.Duration(report.UptimeSeconds)*time.Second)
+
+	b.WriteString("Infrastructure\n")
+	if len(report.Infrastructure) == 0 {
+		b.WriteString("  no components\n")
+	}
+	for _, c := range report.Infrastructure {
+		fmt.Fprintf(&b, "  %-20s %s", c.Name, c.Status)
+		if c.Error != "" {
+			fmt.Fprintf(&b, " (%s)", c.Error)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\nEndpoints: %d requests, %d server errors\n", report.Requests, report.Errors)
+	for _, e := range report.Endpoints {
+		fmt.Fprintf(&b, "  %-40s %8d req %6d err %7.1f ms p95\n", e.Method+" "+e.Route, e.Requests, e.Errors, e.P95LatencyMs)
+	}
+
+	b.WriteString("\nLogs\n")
+	if len(report.Logs) == 0 {
+		b.WriteString("  nothing logged\n")
+	}
+	for _, level := range logLevels(report) {
+		fmt.Fprintf(&b, "  %-8s %d\n", level, report.Logs[level])
+	}
+	return b.String()
+}
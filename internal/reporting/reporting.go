@@ -0,0 +1,410 @@
+// Package reporting periodically snapshots the state of the application
+// (infrastructure health, uptime, endpoint traffic and logged errors) into
+// reports that the monitoring API renders as JSON, CSV or HTML and that
+// can be emailed to operators.
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/endpointstats"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+)
+
+const (
+	reportsBucket   = "reports"
+	sendTimeout     = 30 * time.Second
+	defaultKeep     = 30
+	defaultInterval = 24 // hours
+	defaultTop      = 20
+)
+
+// ErrReportNotFound is returned for an unknown report ID.
+var ErrReportNotFound = errors.New("report not found")
+
+// Mailer sends plain text emails; the "mail" dependency implements it.
+type Mailer interface {
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+
+// Sources provides what reports are made of. Nil fields leave the
+// corresponding section empty.
+type Sources struct {
+	App       config.AppConfig
+	StartedAt time.Time
+	// Components returns GetStatus of every infrastructure component.
+	Components func() map[string]map[string]interface{}
+	// Endpoints returns the per-route request figures.
+	Endpoints func() []endpointstats.RouteStats
+	// EndpointsSince is when the endpoint figures started counting.
+	EndpointsSince func() time.Time
+	// Logs counts the entries logged per level.
+	Logs *logger.LogBroadcaster
+	// Mailer returns the mailer reports are emailed with, nil when none is
+	// configured. It is looked up on every send.
+	Mailer func() Mailer
+}
+
+// Report is a snapshot of the application.
+type Report struct {
+	ID             string            `json:"id"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+	From           time.Time         `json:"from"` // the previous report, or the start of the process
+	App            string            `json:"app"`
+	Version        string            `json:"version"`
+	Env            string            `json:"env"`
+	StartedAt      time.Time         `json:"started_at"`
+	UptimeSeconds  int64             `json:"uptime_seconds"`
+	Infrastructure []ComponentHealth `json:"infrastructure"`
+	Down           int               `json:"down"` // components reporting connected: false
+	EndpointsSince time.Time         `json:"endpoints_since,omitempty"`
+	Requests       int64             `json:"requests"`
+	Errors         int64             `json:"errors"` // 5xx responses
+	Endpoints      []EndpointSummary `json:"endpoints"`
+	Logs           map[string]uint64 `json:"logs"` // entries per level logged since From
+	Emailed        []string          `json:"emailed,omitempty"`
+	EmailError     string            `json:"email_error,omitempty"`
+}
+
+// ComponentHealth is the state of one infrastructure component.
+type ComponentHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "up", "down" or "unknown" when it reports no connected flag
+	Error  string `json:"error,omitempty"`
+}
+
+// EndpointSummary is the traffic of one route.
+type EndpointSummary struct {
+	Method       string  `json:"method"`
+	Route        string  `json:"route"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"` // percent
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+}
+
+// Summary lists a report without its sections.
+type Summary struct {
+	ID          string    `json:"id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Down        int       `json:"down"`
+	Requests    int64     `json:"requests"`
+	Errors      int64     `json:"errors"`
+	LogErrors   uint64    `json:"log_errors"`
+	Emailed     bool      `json:"emailed"`
+}
+
+// Generator generates reports every interval and keeps the last ones, in
+// the embedded store when one is given.
+type Generator struct {
+	config  config.ReportsConfig
+	sources Sources
+	store   *infrastructure.EmbeddedStore
+	logger  *logger.Logger
+
+	mu         sync.Mutex
+	memory     []Report // newest last, without a store
+	lastAt     time.Time
+	lastID     time.Time // millisecond the last report ID was made of
+	lastCounts map[string]uint64
+	lastError  string
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewGenerator creates a report generator. Reports are kept in store when
+// it is non-nil, else in memory until restart.
+func NewGenerator(cfg config.ReportsConfig, sources Sources, store *infrastructure.EmbeddedStore, l *logger.Logger) *Generator {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultKeep
+	}
+	if cfg.TopEndpoints <= 0 {
+		cfg.TopEndpoints = defaultTop
+	}
+	return &Generator{
+		config:   cfg,
+		sources:  sources,
+		store:    store,
+		logger:   l,
+		lastAt:   sources.StartedAt,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start generates and emails a report every interval in the background.
+func (g *Generator) Start() {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(time.Duration(g.config.Interval) * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := g.Generate(context.Background(), true); err != nil {
+					g.logger.Error("Failed to generate report", err)
+				}
+			case <-g.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the schedule.
+func (g *Generator) Close() error {
+	g.stopOnce.Do(func() {
+		close(g.stopChan)
+		g.wg.Wait()
+	})
+	return nil
+}
+
+// Generate snapshots the application into a new report and keeps it. With
+// email set it is sent to the configured recipients; a failed send is
+// recorded on the report rather than returned.
+func (g *Generator) Generate(ctx context.Context, email bool) (*Report, error) {
+	g.mu.Lock()
+	report := g.snapshot()
+	g.mu.Unlock()
+
+	if email && len(g.config.Recipients) > 0 {
+		if err := g.send(ctx, &report); err != nil {
+			report.EmailError = err.Error()
+			g.logger.Warn("Failed to email report", "id", report.ID, "error", err.Error())
+		} else {
+			report.Emailed = g.config.Recipients
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.keep(report); err != nil {
+		g.lastError = err.Error()
+		return nil, err
+	}
+	g.lastError = ""
+	return &report, nil
+}
+
+// snapshot builds a report. Callers hold g.mu.
+func (g *Generator) snapshot() Report {
+	now := time.Now()
+	s := g.sources
+	// IDs sort like the reports; keep them unique within a millisecond
+	idTime := now.UTC().Truncate(time.Millisecond)
+	if !idTime.After(g.lastID) {
+		idTime = g.lastID.Add(time.Millisecond)
+	}
+	g.lastID = idTime
+	report := Report{
+		ID:          idTime.Format("20060102T150405.000Z"),
+		GeneratedAt: now,
+		From:        g.lastAt,
+		App:         s.App.Name,
+		Version:     s.App.Version,
+		Env:         s.App.Env,
+		StartedAt:   s.StartedAt,
+		Logs:        make(map[string]uint64),
+	}
+	if !s.StartedAt.IsZero() {
+		report.UptimeSeconds = int64(now.Sub(s.StartedAt).Seconds())
+	}
+
+	if s.Components != nil {
+		for name, status := range s.Components() {
+			health := ComponentHealth{Name: name, Status: "unknown"}
+			if connected, ok := status["connected"].(bool); ok {
+				health.Status = "up"
+				if !connected {
+					health.Status = "down"
+					report.Down++
+				}
+			}
+			if msg, ok := status["error"].(string); ok {
+				health.Error = msg
+			}
+			report.Infrastructure = append(report.Infrastructure, health)
+		}
+		sort.Slice(report.Infrastructure, func(i, j int) bool {
+			return report.Infrastructure[i].Name < report.Infrastructure[j].Name
+		})
+	}
+
+	if s.Endpoints != nil {
+		routes := s.Endpoints()
+		sort.Slice(routes, func(i, j int) bool { return routes[i].Requests > routes[j].Requests })
+		for i, r := range routes {
+			report.Requests += r.Requests
+			report.Errors += r.Errors
+			if i >= g.config.TopEndpoints {
+				continue
+			}
+			summary := EndpointSummary{
+				Method:       r.Method,
+				Route:        r.Route,
+				Requests:     r.Requests,
+				Errors:       r.Errors,
+				AvgLatencyMs: r.AvgLatencyMs,
+				P95LatencyMs: r.P95LatencyMs,
+			}
+			if r.Requests > 0 {
+				summary.ErrorRate = float64(r.Errors*10000/r.Requests) / 100
+			}
+			report.Endpoints = append(report.Endpoints, summary)
+		}
+		if s.EndpointsSince != nil {
+			report.EndpointsSince = s.EndpointsSince()
+		}
+	}
+
+	if s.Logs != nil {
+		counts := s.Logs.LevelCounts()
+		for level, n := range counts {
+			if n > g.lastCounts[level] {
+				report.Logs[level] = n - g.lastCounts[level]
+			}
+		}
+		g.lastCounts = counts
+	}
+	g.lastAt = now
+	return report
+}
+
+// keep saves report and drops the oldest beyond the configured number.
+// Callers hold g.mu.
+func (g *Generator) keep(report Report) error {
+	if g.store == nil {
+		g.memory = append(g.memory, report)
+		if extra := len(g.memory) - g.config.Keep; extra > 0 {
+			g.memory = append(g.memory[:0:0], g.memory[extra:]...)
+		}
+		return nil
+	}
+	if err := g.store.PutJSON(reportsBucket, report.ID, report); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	var ids []string
+	err := g.store.ForEachPrefix(reportsBucket, "", func(key, _ []byte) error {
+		ids = append(ids, string(key))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(ids)-g.config.Keep; i++ {
+		if err := g.store.Delete(reportsBucket, ids[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the kept reports, newest first.
+func (g *Generator) List() ([]Summary, error) {
+	reports, err := g.all()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Summary, 0, len(reports))
+	for i := len(reports) - 1; i >= 0; i-- {
+		r := reports[i]
+		list = append(list, Summary{
+			ID:          r.ID,
+			GeneratedAt: r.GeneratedAt,
+			Down:        r.Down,
+			Requests:    r.Requests,
+			Errors:      r.Errors,
+			LogErrors:   r.Logs["error"] + r.Logs["fatal"],
+			Emailed:     len(r.Emailed) > 0,
+		})
+	}
+	return list, nil
+}
+
+// all returns the kept reports, oldest first.
+func (g *Generator) all() ([]Report, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.store == nil {
+		return append([]Report(nil), g.memory...), nil
+	}
+	var reports []Report
+	err := g.store.ForEachPrefix(reportsBucket, "", func(_, value []byte) error {
+		var r Report
+		if err := json.Unmarshal(value, &r); err != nil {
+			return err
+		}
+		reports = append(reports, r)
+		return nil
+	})
+	return reports, err
+}
+
+// Get returns a kept report.
+func (g *Generator) Get(id string) (*Report, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.store == nil {
+		for i := range g.memory {
+			if g.memory[i].ID == id {
+				r := g.memory[i]
+				return &r, nil
+			}
+		}
+		return nil, ErrReportNotFound
+	}
+	var r Report
+	found, err := g.store.GetJSON(reportsBucket, id, &r)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrReportNotFound
+	}
+	return &r, nil
+}
+
+// send emails report as text to the configured recipients.
+func (g *Generator) send(ctx context.Context, report *Report) error {
+	var mailer Mailer
+	if g.sources.Mailer != nil {
+		mailer = g.sources.Mailer()
+	}
+	if mailer == nil {
+		return fmt.Errorf("no mailer is configured")
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return mailer.Send(ctx, g.config.Recipients, Subject(report), RenderText(report))
+}
+
+// GetStatus returns a summary of the generator.
+func (g *Generator) GetStatus() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := map[string]interface{}{
+		"interval_hours": g.config.Interval,
+		"keep":           g.config.Keep,
+		"recipients":     len(g.config.Recipients),
+		"last_report":    g.lastAt,
+	}
+	if g.lastError != "" {
+		status["error"] = g.lastError
+	}
+	return status
+}
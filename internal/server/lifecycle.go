@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceStartTimeout bounds the Start hooks of the services of an engine.
+const serviceStartTimeout = 30 * time.Second
+
+// serviceStopTimeout bounds the Stop hooks of the services of an engine
+// replaced on a reload.
+const serviceStopTimeout = 10 * time.Second
+
+// serve starts the lifecycle services of engine, routes new requests to it
+// and then stops the services of the engine it replaces. Services that
+// fail to start are logged and keep their routes.
+func (s *Server) serve(engine *gin.Engine, services *registry.ServiceRegistry) {
+	ctx, cancel := context.WithTimeout(context.Background(), serviceStartTimeout)
+	services.Start(ctx)
+	cancel()
+
+	s.handler.Store(engine)
+	if previous := s.serviceRegistry.Swap(services); previous != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), serviceStopTimeout)
+		defer cancel()
+		previous.Stop(ctx)
+	}
+}
+
+// stopServices stops the lifecycle services of the current engine, at
+// shutdown.
+func (s *Server) stopServices(ctx context.Context) error {
+	services := s.serviceRegistry.Swap(nil)
+	if services == nil {
+		return nil
+	}
+	return services.Stop(ctx)
+}
+
+// watchDeferred re-creates the services once the needs of a deferred
+// service are connected, e.g. after a component restart or a client
+// reconnecting on its own, checking every server.dependency_retry seconds
+// until shutdown.
+func (s *Server) watchDeferred() {
+	interval := time.Duration(s.config.Server.DependencyRetry) * time.Second
+	if interval <= 0 {
+		return
+	}
+	s.deferredWatch = make(chan struct{})
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if s.deferredReady() {
+				s.Reload()
+			}
+		}
+	}(s.deferredWatch)
+}
+
+// deferredReady reports whether a deferred service of the current engine
+// has its needs connected.
+func (s *Server) deferredReady() bool {
+	current := s.services.Load()
+	if current == nil {
+		return false
+	}
+	for _, deferred := range registry.DeferredServices(*current) {
+		if deferred.Ready(s.dependencies) {
+			s.logger.Info("Service dependencies connected, re-creating services", "service", deferred.Key)
+			return true
+		}
+	}
+	return false
+}
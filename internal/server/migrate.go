@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/models"
+	"stackyrd/pkg/registry"
+
+	"gorm.io/gorm"
+)
+
+// migrationTimeout bounds the migrations of one service at boot.
+const migrationTimeout = 2 * time.Minute
+
+// MigrationResult is the outcome of migrating one service, or one
+// registered model of a service on one connection.
+type MigrationResult struct {
+	Service  string
+	Duration time.Duration
+	Err      error
+}
+
+// OnMigrations sets a func receiving the results of the migrations run
+// at boot and on every reload, e.g. for the boot screen.
+func (s *Server) OnMigrations(fn func([]MigrationResult)) {
+	s.onMigrations = fn
+}
+
+// migrateServices runs the migrations of the enabled services, as they
+// are created at boot and on every reload. Failures are logged and the
+// service is registered anyway.
+func (s *Server) migrateServices(services []interfaces.Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+	results := s.runMigrations(ctx, services)
+	for _, result := range results {
+		if result.Err != nil {
+			s.logger.Error("Service migration failed", result.Err, "service", result.Service)
+		}
+	}
+	if s.onMigrations != nil {
+		s.onMigrations(results)
+	}
+}
+
+// Migrate connects the configured infrastructure, creates the enabled
+// services and runs their migrations, for `stackyrd migrate`. The error
+// names the components that could not connect, whose services are not
+// migrated. The connections are closed before it returns.
+func (s *Server) Migrate(ctx context.Context) ([]MigrationResult, error) {
+	s.infraInitManager = infrastructure.NewInfraInitManager(s.logger)
+	components := s.infraInitManager.StartAsyncInitialization(s.config, s.logger)
+	defer components.CloseAll()
+
+	var errs []error
+	for name, result := range components.ConnectResults() {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, result.Err))
+		}
+	}
+
+	s.dependencies = registry.NewDependencies()
+	for name, component := range components.GetAll() {
+		s.dependencies.Set(name, component)
+	}
+	s.setConnectionDefaults()
+
+	return s.runMigrations(ctx, registry.AutoDiscoverServices(s.config, s.logger, s.dependencies)), errors.Join(errs...)
+}
+
+// runMigrations migrates the models registered by the enabled services
+// (see models.Register), then runs the migrations of the services
+// implementing MigratingService.
+func (s *Server) runMigrations(ctx context.Context, services []interfaces.Service) []MigrationResult {
+	var results []MigrationResult
+	dbs, defaultName := s.postgresORMs()
+	for _, result := range models.Migrate(ctx, dbs, defaultName, s.config.Services.IsEnabled) {
+		table := result.Table
+		if table == "" {
+			table = result.Model
+		}
+		results = append(results, MigrationResult{
+			Service:  fmt.Sprintf("%s: %s on %s", result.Service, table, result.Connection),
+			Duration: result.Duration,
+			Err:      result.Err,
+		})
+	}
+	for _, service := range services {
+		migrator, ok := service.(interfaces.MigratingService)
+		if !ok || !service.Enabled() {
+			continue
+		}
+		start := time.Now()
+		err := migrator.Migrate(ctx)
+		results = append(results, MigrationResult{Service: service.Name(), Duration: time.Since(start), Err: err})
+	}
+	return results
+}
+
+// postgresORMs returns the GORM handles of the Postgres connections by
+// name, and the name of the default one ("postgres.default").
+func (s *Server) postgresORMs() (map[string]*gorm.DB, string) {
+	dbs := make(map[string]*gorm.DB)
+	if manager, ok := registry.GetTyped[*infrastructure.PostgresConnectionManager](s.dependencies, "postgres"); ok && manager != nil {
+		for name, conn := range manager.GetAllConnections() {
+			if conn.ORM != nil {
+				dbs[name] = conn.ORM
+			}
+		}
+		defaultName := ""
+		if pg, ok := registry.GetTyped[*infrastructure.PostgresManager](s.dependencies, "postgres.default"); ok && pg != nil {
+			defaultName = pg.Connection
+		}
+		return dbs, defaultName
+	}
+	if pg, ok := registry.GetTyped[*infrastructure.PostgresManager](s.dependencies, "postgres"); ok && pg != nil && pg.ORM != nil {
+		dbs["default"] = pg.ORM
+	}
+	return dbs, "default"
+}
@@ -2,35 +2,108 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
 	"fmt"
 	"maps"
+	"net"
 	"net/http"
+	"os"
+	"reflect"
+	"runtime"
 	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "stackyrd/internal/services/modules"
 
 	"stackyrd/config"
+	"stackyrd/internal/alerting"
+	"stackyrd/internal/devmode"
+	"stackyrd/internal/grpcserver"
+	"stackyrd/internal/jobs"
 	"stackyrd/internal/middleware"
+	"stackyrd/internal/mockserver"
+	"stackyrd/internal/monitoring"
+	"stackyrd/internal/reporting"
+	"stackyrd/internal/tenantdata"
+	"stackyrd/internal/tenantprovision"
+	"stackyrd/pkg/accounts"
+	"stackyrd/pkg/audit"
+	"stackyrd/pkg/cache"
+	"stackyrd/pkg/configdrift"
+	"stackyrd/pkg/dns"
+	"stackyrd/pkg/endpointstats"
+	"stackyrd/pkg/graphql"
+	"stackyrd/pkg/handles"
+	"stackyrd/pkg/i18n"
+	"stackyrd/pkg/idempotency"
 	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+	"stackyrd/pkg/outbox"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/queue"
 	"stackyrd/pkg/registry"
+	"stackyrd/pkg/remoteconfig"
 	"stackyrd/pkg/response"
+	"stackyrd/pkg/shutdown"
+	"stackyrd/pkg/sysinfo"
+	"stackyrd/pkg/tenancy"
+	"stackyrd/pkg/timeseries"
+	"stackyrd/pkg/timesync"
+	"stackyrd/pkg/topology"
+	"stackyrd/pkg/tracing"
 	"stackyrd/pkg/utils"
+	"stackyrd/pkg/webhook"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	gin              *gin.Engine
+	gin              *gin.Engine // engine being built; requests go through handler
+	handler          atomic.Pointer[gin.Engine]
+	services         atomic.Pointer[[]interfaces.Service]     // of the current engine
+	serviceRegistry  atomic.Pointer[registry.ServiceRegistry] // of the current engine, with its started services
+	httpServer       *http.Server
+	redirectServer   *http.Server
+	grpcServer       *grpcserver.Server
+	inFlight         atomic.Int64
+	conns            atomic.Int64 // open client connections
+	served           atomic.Int64 // requests received, for the request rate
+	reloadMu         sync.Mutex
+	devWatcher       *devmode.Watcher
+	deferredWatch    chan struct{}            // closed at shutdown to stop watchDeferred
+	idempotencyMem   *idempotency.MemoryStore // kept across reloads when Redis is not available
 	config           *config.Config
+	fingerprint      string // of the configuration at startup
 	logger           *logger.Logger
 	dependencies     *registry.Dependencies
 	infraInitManager *infrastructure.InfraInitManager
+	logBroadcaster   *logger.LogBroadcaster
+	logSinks         *logger.SinkSet
+	onMigrations     func([]MigrationResult) // see OnMigrations
+
+	shutdownMu     sync.Mutex
+	shutdownReason string
+	shutdownReport *shutdown.Report // of the shutdown in progress
 }
 
 func New(cfg *config.Config, l *logger.Logger) *Server {
 	gin.SetMode(gin.ReleaseMode)
+	return &Server{
+		config:      cfg,
+		fingerprint: config.Fingerprint(cfg),
+		logger:      l,
+	}
+}
+
+// newEngine creates an engine with recovery and the not-found handlers.
+func (s *Server) newEngine() *gin.Engine {
+	l := s.logger
 	r := gin.New()
 	r.Use(gin.Recovery())
 
@@ -48,14 +121,30 @@ func New(cfg *config.Config, l *logger.Logger) *Server {
 		response.Error(c, http.StatusMethodNotAllowed, "HTTP_ERROR", "Method not allowed")
 	})
 
-	return &Server{
-		gin:    r,
-		config: cfg,
-		logger: l,
-	}
+	return r
+}
+
+// SetLogBroadcaster makes the application log stream available to the
+// monitoring API and alerting as the "logs" dependency.
+func (s *Server) SetLogBroadcaster(b *logger.LogBroadcaster) {
+	s.logBroadcaster = b
+}
+
+// SetLogSinks makes the delivery stats of the logging.sinks available to
+// the monitoring API as the "log_sinks" dependency.
+func (s *Server) SetLogSinks(sinks *logger.SinkSet) {
+	s.logSinks = sinks
 }
 
 func (s *Server) Start() error {
+	// Install the DNS cache before infrastructure clients start dialing
+	var dnsCache *dns.Cache
+	if cfg := s.config.DNS.Cache; cfg.Enabled {
+		dnsCache = dns.NewCache(time.Duration(cfg.TTL)*time.Second, time.Duration(cfg.StaleTTL)*time.Second)
+		dnsCache.Install()
+		s.logger.Info("DNS cache enabled", "ttl", cfg.TTL, "stale_ttl", cfg.StaleTTL)
+	}
+
 	s.infraInitManager = infrastructure.NewInfraInitManager(s.logger)
 	s.logger.Info("Starting async infrastructure initialization...")
 	componentRegistry := s.infraInitManager.StartAsyncInitialization(s.config, s.logger)
@@ -69,30 +158,296 @@ func (s *Server) Start() error {
 		s.logger.Info("Registered infrastructure component", "name", name, "type", fmt.Sprintf("%T", component))
 	}
 
+	if s.logBroadcaster != nil {
+		s.dependencies.Set("logs", s.logBroadcaster)
+	}
+	if s.logSinks != nil {
+		s.dependencies.Set("log_sinks", s.logSinks)
+	}
+	if dnsCache != nil {
+		s.dependencies.Set("dns_cache", dnsCache)
+	}
+
+	// Restore the previous run's logs and keep this run's for the next one
+	s.setLogReplay()
+
+	// Show how the previous run shut down
+	s.setLastShutdown()
+
+	// Install the tracer provider before anything starts producing spans
+	s.setTracing()
+
 	// Handle database connection defaults
 	s.setConnectionDefaults()
 
+	// Measure clock skew against NTP or the database server time
+	s.setClock()
+
+	// Notice edits of the config file that only apply on the next restart
+	s.setConfigDrift()
+
+	// Follow the etcd or Consul key the configuration came from
+	s.setRemoteConfig()
+
+	// Track resolution of broker, database and external service hosts
+	s.setDNS()
+
+	// Expose the configured broker as the broker-agnostic "messaging" dependency
+	s.setMessagingBroker()
+
+	// Relay events services write to the Postgres outbox
+	s.setOutbox()
+
+	// Deliver events services publish to outbound webhooks
+	s.setWebhooks()
+
+	// Shared cache backend of services and cached routes
+	s.setCache()
+
+	// Serve canned upstream responses when the mock server is enabled
+	s.setMockUpstream()
+
+	// Check external services, after the mock may have redirected them
+	s.setExternalChecks()
+
+	// Sample system, request and infrastructure metrics for charts
+	s.setMetricsHistory()
+
+	// Start rule evaluation when alerting is enabled
+	s.setAlerting()
+
+	// Scheduled status reports
+	s.setReports()
+
+	// Background jobs and the workflows built on them
+	s.setJobs()
+
+	// Persistent job queue services enqueue typed jobs in
+	s.setQueue()
+
+	// Tenants registered at runtime through the monitoring API
+	s.setTenantProvisioning()
+
+	// Operator locale and timezone preferences for the monitoring API
+	s.setPreferences()
+
+	// Record actions taken through the monitoring API
+	s.setAudit()
+
+	// Saved queries and query history of the DB console
+	s.setQueryBook()
+
+	// Password accounts of the monitoring API
+	s.setAccounts()
+
+	// Soft restarts of single infrastructure components
+	s.setRestarter()
+
+	s.serve(s.buildEngine())
+
+	// Run queued jobs once the services registered their handlers
+	if q, ok := registry.GetTyped[*queue.Queue](s.dependencies, "queue"); ok {
+		q.Start()
+	}
+
+	// Watch config and content directories during development
+	s.startDevMode()
+
+	// Create deferred services once their dependencies connect
+	s.watchDeferred()
+
+	port := s.config.Server.Port
+	s.logger.Info("HTTP server starting immediately", "port", port, "env", s.config.App.Env)
+	s.logger.Info("Infrastructure components initializing in background...")
+
+	tlsSetup, err := NewTLSSetup(s.config.Server, s.config.Auth)
+	if err != nil {
+		return err
+	}
+	if err := s.startGRPC(tlsSetup); err != nil {
+		return err
+	}
+	s.httpServer = &http.Server{Addr: ":" + port, Handler: s, ConnState: s.trackConn}
+	if tlsSetup == nil {
+		err = s.httpServer.ListenAndServe()
+	} else {
+		s.httpServer.TLSConfig = tlsSetup.Config
+		s.startRedirectServer(tlsSetup.HTTPHandler)
+		s.logger.Info("Serving HTTPS with HTTP/2", "port", port, "autocert", s.config.Server.TLS.Autocert.Enabled)
+		err = s.httpServer.ListenAndServeTLS("", "")
+	}
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// startRedirectServer serves handler on the plain HTTP port next to HTTPS.
+func (s *Server) startRedirectServer(handler http.Handler) {
+	if handler == nil {
+		return
+	}
+	port := s.config.Server.TLS.HTTPPort
+	s.redirectServer = &http.Server{Addr: ":" + port, Handler: handler}
+	go func() {
+		if err := s.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("HTTP redirect listener failed", err, "port", port)
+		}
+	}()
+	s.logger.Info("Redirecting HTTP to HTTPS", "port", port)
+}
+
+// startGRPC starts the gRPC server when enabled, sharing the HTTPS
+// certificates, and registers it as the "grpc" dependency.
+func (s *Server) startGRPC(tlsSetup *TLSSetup) error {
+	if !s.config.GRPC.Enabled {
+		return nil
+	}
+	var tlsConfig *tls.Config
+	if tlsSetup != nil {
+		tlsConfig = tlsSetup.Config
+	}
+	s.grpcServer = grpcserver.New(s.config, s.logger, s.dependencies, tlsConfig)
+	if err := s.grpcServer.Start(); err != nil {
+		return fmt.Errorf("grpc server: %w", err)
+	}
+	s.dependencies.Set("grpc", s.grpcServer)
+	return nil
+}
+
+// ServeHTTP dispatches to the current engine, which Reload may replace.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.inFlight.Add(1)
+	s.served.Add(1)
+	defer s.inFlight.Add(-1)
+	s.handler.Load().ServeHTTP(w, r)
+}
+
+// InFlight returns the number of requests currently being served.
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// trackConn counts the open client connections.
+func (s *Server) trackConn(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.conns.Add(1)
+	case http.StateClosed, http.StateHijacked:
+		s.conns.Add(-1)
+	}
+}
+
+// drainTimeout is how long Shutdown waits for in-flight requests.
+func (s *Server) drainTimeout() time.Duration {
+	if s.config.Server.ShutdownTimeout <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(s.config.Server.ShutdownTimeout) * time.Second
+}
+
+// drain stops accepting connections and waits for in-flight requests to
+// finish until the drain timeout or ctx expires, then closes the
+// connections still open.
+func (s *Server) drain(ctx context.Context, logger *logger.Logger) shutdown.Drain {
+	if s.httpServer == nil {
+		return shutdown.Drain{}
+	}
+	timeout := s.drainTimeout()
+	start := time.Now()
+	report := shutdown.Drain{OpenConnections: s.conns.Load(), InFlight: s.InFlight()}
+	logger.Info("Draining HTTP connections...", "in_flight", report.InFlight, "connections", report.OpenConnections, "timeout", timeout.String())
+
+	s.httpServer.SetKeepAlivesEnabled(false)
+	drainCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if s.redirectServer != nil {
+		s.redirectServer.Shutdown(drainCtx)
+	}
+	if s.grpcServer != nil {
+		s.grpcServer.Shutdown(drainCtx)
+	}
+	if err := s.httpServer.Shutdown(drainCtx); err != nil {
+		remaining := s.InFlight()
+		s.httpServer.Close()
+		if remaining > 0 {
+			report.TimedOut, report.Remaining = true, remaining
+			logger.Warn("Drain timed out, closing remaining connections", "in_flight", remaining, "error", err.Error())
+		} else {
+			// Only connections without a request were left (net/http waits
+			// 5s for a new one to send its first); closing them cuts
+			// nothing off
+			logger.Info("HTTP connections drained, closing idle connections")
+		}
+	} else {
+		logger.Info("HTTP connections drained")
+	}
+	report.DurationMS = shutdown.Millis(time.Since(start))
+	return report
+}
+
+// buildEngine registers middleware, health endpoints, services, the
+// monitoring API and Swagger on a fresh engine. The services are created
+// but not started; see serve.
+func (s *Server) buildEngine() (*gin.Engine, *registry.ServiceRegistry) {
+	s.gin = s.newEngine()
+
 	s.logger.Info("Initializing Middleware...")
 
 	// Apply middleware configuration from config
 	middleware.GetGlobalMiddlewareRegistry().ApplyConfig(s.config)
 
-	// Auto-discover and register all enabled middlewares
-	middlewares := middleware.GetGlobalMiddlewareRegistry().AutoDiscoverMiddlewares(s.config, s.logger)
-	for _, mw := range middlewares {
-		if mw != nil {
-			s.gin.Use(mw)
+	// Auto-discover and register all enabled middlewares, by name so
+	// services can leave some out
+	var chain middleware.Chain
+	for _, mw := range middleware.GetGlobalMiddlewareRegistry().AutoDiscoverNamed(s.config, s.logger) {
+		chain.Use(s.gin, mw.Name, mw.Handler)
+	}
+
+	// Tenant of each request from the configured sources, checked against
+	// the tenant registry
+	tenants := tenancy.DefaultRegistry()
+	if s.config.Tenancy.Enabled {
+		if err := tenants.Load(s.config.Tenancy.Tenants); err != nil {
+			s.logger.Error("Invalid tenancy.tenants, keeping the registered tenants", err)
 		}
+		chain.Use(s.gin, "tenant", middleware.Tenant(middleware.TenantOptionsFromConfig(s.config, tenants)))
+	}
+
+	// Tenant-scoped infrastructure handles in the request context; opt-in
+	// since most services take their managers from the constructor
+	if s.config.Middleware["handles"] || s.config.Tenancy.Enabled {
+		chain.Use(s.gin, "handles", handles.Middleware(s.dependencies, handles.Options{
+			ObjectPrefix: s.config.TenantData.ObjectPrefix,
+			Tenants:      tenants,
+		}))
+	}
+
+	// Replay responses of POST/PUT retries carrying an Idempotency-Key
+	if s.config.Idempotency.Enabled {
+		cfg := s.config.Idempotency
+		chain.Use(s.gin, "idempotency", middleware.Idempotency(s.idempotencyStore(), middleware.IdempotencyOptions{
+			Header:      cfg.Header,
+			TTL:         time.Duration(cfg.TTL) * time.Second,
+			LockTimeout: time.Duration(cfg.LockTimeout) * time.Second,
+			Required:    cfg.Required,
+		}))
 	}
 
 	s.logger.Info("Booting Services...")
 	serviceRegistry := registry.NewServiceRegistry(s.logger)
+	// Middleware of services.<name>.middleware on top of the global chain
+	serviceRegistry.SetRouteGroup(func(key string, api *gin.RouterGroup) (*gin.RouterGroup, error) {
+		return middleware.ServiceGroup(api, &chain, s.config.ServiceSettings[key].Middleware, s.config, s.logger)
+	})
 	s.registerHealthEndpoints()
 
 	services := registry.AutoDiscoverServices(s.config, s.logger, s.dependencies)
 	for _, service := range services {
 		serviceRegistry.Register(service)
 	}
+	s.services.Store(&services)
+	s.migrateServices(services)
 
 	if len(services) <= 0 {
 		s.logger.Warn("No services registered!")
@@ -101,6 +456,27 @@ func (s *Server) Start() error {
 	serviceRegistry.Boot(s.gin)
 	s.logger.Info("All services boot successfully")
 
+	if s.config.GraphQL.Enabled {
+		s.registerGraphQL(services)
+	}
+
+	// Register monitoring API
+	if s.config.Monitoring.Enabled {
+		monitor := monitoring.New(s.config, s.logger, s.dependencies, s.infraInitManager)
+		monitor.SetRoutes(s.gin.Routes)
+		monitor.SetServices(services)
+		monitor.SetConfigFingerprint(s.fingerprint)
+		monitor.RegisterRoutes(s.gin.Group("/api"))
+		s.logger.Info("Monitoring API available at /api")
+		switch source := monitor.RegisterWeb(s.gin, "/api"); source {
+		case monitoring.WebEmbedded:
+			s.logger.Warn("Dashboard directory not found, serving the fallback status page",
+				"path", s.config.Monitoring.Web.Path, "route", s.config.Monitoring.Web.Route)
+		case monitoring.WebDirectory:
+			s.logger.Info("Dashboard available", "route", s.config.Monitoring.Web.Route)
+		}
+	}
+
 	// Register Swagger UI
 	if s.config.Swagger.Enabled {
 		s.logger.Info("Registering Swagger UI documentation...")
@@ -111,11 +487,140 @@ func (s *Server) Start() error {
 		s.logger.Info("Swagger UI available at /swagger/index.html")
 	}
 
-	port := s.config.Server.Port
-	s.logger.Info("HTTP server starting immediately", "port", port, "env", s.config.App.Env)
-	s.logger.Info("Infrastructure components initializing in background...")
+	return s.gin, serviceRegistry
+}
 
-	return s.gin.Run(":" + port)
+// idempotencyStore returns where idempotent responses are kept: Redis when
+// it is connected, else memory of this instance.
+func (s *Server) idempotencyStore() idempotency.Store {
+	if redis, ok := registry.GetTyped[*infrastructure.RedisManager](s.dependencies, "redis"); ok && redis != nil {
+		return idempotency.NewRedisStore(redis.Client)
+	}
+	if s.idempotencyMem == nil {
+		s.logger.Warn("Redis not available, idempotency keys are kept per instance")
+		s.idempotencyMem = idempotency.NewMemoryStore()
+	}
+	return s.idempotencyMem
+}
+
+// registerGraphQL mounts the GraphQL endpoint with the schema fields of
+// the enabled services implementing interfaces.GraphQLService. Without
+// fields, or when they conflict, the endpoint is left out.
+func (s *Server) registerGraphQL(services []interfaces.Service) {
+	schema := graphql.NewSchema(s.config.GraphQL.MaxDepth)
+	for _, service := range services {
+		if provider, ok := service.(interfaces.GraphQLService); ok && service.Enabled() {
+			provider.RegisterGraphQL(schema)
+		}
+	}
+	if err := schema.Build(); err != nil {
+		s.logger.Warn("GraphQL API not available", "error", err.Error())
+		return
+	}
+
+	path := s.config.GraphQL.Path
+	handler := graphql.Handler(schema)
+	s.gin.GET(path, handler)
+	s.gin.POST(path, handler)
+	queries, mutations := schema.Fields()
+	s.logger.Info("GraphQL API available", "path", path, "queries", len(queries), "mutations", len(mutations))
+}
+
+// Reload re-creates middleware and services and swaps in a new engine with
+// their routes. Requests in flight finish on the previous engine.
+func (s *Server) Reload() {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.logger.Info("Re-registering routes...")
+	s.serve(s.buildEngine())
+	s.logger.Info("Routes re-registered")
+}
+
+// SetServiceEnabled switches a service on or off and re-registers routes
+// so the change applies to new requests. It is not persisted.
+func (s *Server) SetServiceEnabled(name string, enabled bool) {
+	s.reloadMu.Lock()
+	services := make(config.ServicesConfig, len(s.config.Services)+1)
+	for k, v := range s.config.Services {
+		services[k] = v
+	}
+	services[name] = config.ServiceToggle(enabled)
+	s.config.Services = services
+	s.reloadMu.Unlock()
+	s.Reload()
+}
+
+// Topology returns the dependency graph of the services, infrastructure and
+// external services, as served at /api/graph.
+func (s *Server) Topology() topology.Graph {
+	var services []interfaces.Service
+	if current := s.services.Load(); current != nil {
+		services = *current
+	}
+	return topology.Build(s.config.App.Name, s.dependencies, services)
+}
+
+// Routes returns the routes the configuration registers, without
+// connecting infrastructure: services that need a connection are left
+// out, as on a boot where connecting failed.
+func (s *Server) Routes() gin.RoutesInfo {
+	if s.dependencies == nil {
+		s.dependencies = registry.NewDependencies()
+	}
+	engine, _ := s.buildEngine()
+	return engine.Routes()
+}
+
+// Dependencies returns the infrastructure and subsystems registered at
+// Start, or nil before.
+func (s *Server) Dependencies() *registry.Dependencies {
+	return s.dependencies
+}
+
+// startDevMode restarts the process on config changes and re-registers
+// routes when templates or seeds change.
+func (s *Server) startDevMode() {
+	if !s.config.DevMode() {
+		return
+	}
+	debounce := time.Duration(s.config.Dev.Debounce) * time.Millisecond
+	watcher, err := devmode.NewWatcher([]string{config.ConfigFile()}, s.config.Dev.WatchDirs, debounce, s.logger)
+	if err != nil {
+		s.logger.Error("Failed to start dev mode watcher", err)
+		return
+	}
+	watcher.Start(
+		func(paths []string) {
+			s.logger.Warn("Config changed, restarting", "files", paths)
+			utils.TriggerRestart()
+		},
+		func(paths []string) {
+			s.logger.Info("Content changed", "files", paths)
+			s.Reload()
+		},
+	)
+	s.devWatcher = watcher
+	s.logger.Info("Dev mode enabled", "config", config.ConfigFile(), "watch_dirs", s.config.Dev.WatchDirs)
+}
+
+// setLogReplay keeps recent log entries in the embedded store and restores
+// the ones of the previous run into the log buffer.
+func (s *Server) setLogReplay() {
+	cfg := s.config.Monitoring.LogHistory
+	if s.logBroadcaster == nil || !cfg.Replay || cfg.ReplayWindow <= 0 {
+		return
+	}
+	store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store")
+	if !ok {
+		return
+	}
+	window := time.Duration(cfg.ReplayWindow) * time.Second
+	n, err := s.logBroadcaster.EnableReplay(logger.NewStoreReplay(store), window)
+	if err != nil {
+		s.logger.Warn("Failed to enable log replay", "error", err.Error())
+		return
+	}
+	s.logger.Info("Log replay enabled", "window", window.String(), "replayed", n)
 }
 
 func (s *Server) setConnectionDefaults() {
@@ -142,7 +647,820 @@ func (s *Server) setConnectionDefaults() {
 	}
 }
 
+// setMessagingBroker registers the broker selected by messaging.broker under
+// the "messaging" dependency so services depend on messaging.Broker only.
+func (s *Server) setMessagingBroker() {
+	brokerType := s.config.Messaging.Broker
+	if brokerType == "" {
+		return
+	}
+
+	if brokerType == messaging.BrokerMemory {
+		s.dependencies.Set("messaging", messaging.NewMemoryBroker())
+		s.logger.Info("Messaging broker selected", "broker", brokerType)
+		return
+	}
+
+	component, ok := s.dependencies.Get(brokerType)
+	if !ok {
+		s.logger.Debug("Messaging broker not available", "broker", brokerType)
+		return
+	}
+	broker, ok := component.(messaging.Broker)
+	if !ok {
+		s.logger.Warn("Component does not implement messaging.Broker", "broker", brokerType)
+		return
+	}
+
+	// Buffer outbound messages in the embedded store during broker outages
+	if s.config.Messaging.Buffer.Enabled {
+		store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store")
+		if !ok {
+			s.logger.Warn("Messaging buffer requires the embedded store (store.enabled), buffering disabled")
+		} else if buffered, err := infrastructure.NewBufferedBroker(broker, store, s.config.Messaging.Buffer, s.logger); err != nil {
+			s.logger.Error("Failed to start messaging buffer", err)
+		} else {
+			broker = buffered
+		}
+	}
+
+	s.dependencies.Set("messaging", broker)
+	s.logger.Info("Messaging broker selected", "broker", brokerType)
+}
+
+// setOutbox starts the relay of messaging.outbox and registers it as the
+// "outbox" dependency services enqueue events through. The connection and
+// the "messaging" broker are looked up on every pass, so events wait in
+// the table until both are available.
+func (s *Server) setOutbox() {
+	cfg := s.config.Messaging.Outbox
+	if !cfg.Enabled {
+		return
+	}
+	box := outbox.New(
+		func() *sql.DB {
+			if pg := s.postgresConnection(cfg.Connection); pg != nil {
+				return pg.DB
+			}
+			return nil
+		},
+		func() messaging.Broker {
+			broker, _ := registry.GetTyped[messaging.Broker](s.dependencies, "messaging")
+			return broker
+		},
+		outbox.Options{
+			Table:     cfg.Table,
+			Interval:  time.Duration(cfg.Interval) * time.Second,
+			BatchSize: cfg.BatchSize,
+			Retention: time.Duration(cfg.Retention) * time.Hour,
+			Logger:    s.logger,
+		},
+	)
+	box.Start()
+	s.dependencies.Set("outbox", box)
+	s.logger.Info("Outbox relay started", "table", box.Table(), "connection", cfg.Connection)
+}
+
+// setWebhooks starts the outbound webhook dispatcher and registers it as
+// the "webhooks" dependency services publish events to.
+func (s *Server) setWebhooks() {
+	cfg := s.config.Webhooks
+	if !cfg.Enabled {
+		return
+	}
+	dests := make([]webhook.Destination, 0, len(cfg.Destinations))
+	for _, dc := range cfg.Destinations {
+		if dc.Disabled {
+			continue
+		}
+		dests = append(dests, webhook.Destination{
+			Name:    dc.Name,
+			URL:     dc.URL,
+			Secret:  dc.Secret,
+			Events:  dc.Events,
+			Headers: dc.Headers,
+			Timeout: time.Duration(dc.Timeout) * time.Second,
+		})
+	}
+	dispatcher, err := webhook.New(dests, webhook.Options{
+		Workers:     cfg.Workers,
+		QueueSize:   cfg.QueueSize,
+		LogSize:     cfg.LogSize,
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     time.Duration(cfg.Backoff) * time.Second,
+		MaxBackoff:  time.Duration(cfg.MaxBackoff) * time.Second,
+		Logger:      s.logger,
+	})
+	if err != nil {
+		s.logger.Error("Failed to start webhooks", err)
+		return
+	}
+	dispatcher.Start()
+	s.dependencies.Set("webhooks", dispatcher)
+	s.logger.Info("Webhooks enabled", "destinations", len(dests))
+}
+
+// setCache registers the cache backend of cache.backend as the "cache"
+// dependency, in memory when Redis is not connected.
+func (s *Server) setCache() {
+	cfg := s.config.Cache
+	if cfg.Backend == cache.BackendRedis {
+		if redis, ok := registry.GetTyped[*infrastructure.RedisManager](s.dependencies, "redis"); ok && redis != nil {
+			s.dependencies.Set("cache", cache.NewRedis(redis.Client, cfg.Prefix))
+			s.logger.Info("Cache backend selected", "backend", cfg.Backend)
+			return
+		}
+		s.logger.Warn("Redis not available, caching in memory")
+	}
+	s.dependencies.Set("cache", cache.NewLRU(cfg.MaxEntries))
+	s.logger.Info("Cache backend selected", "backend", cache.BackendMemory, "max_entries", cfg.MaxEntries)
+}
+
+// setRestarter registers the "restarter" dependency, which restarts one
+// infrastructure component at a time and switches its users to the new one.
+func (s *Server) setRestarter() {
+	restarter := infrastructure.NewRestarter(infrastructure.GetGlobalRegistry())
+	restarter.OnRestarted = s.componentRestarted
+	s.dependencies.Set("restarter", restarter)
+}
+
+// componentRestarted replaces the dependency name and what is derived from
+// it, then re-creates the services so they take the new component.
+// Subsystems that resolved the component once at startup keep the old one.
+func (s *Server) componentRestarted(name string, component infrastructure.InfrastructureComponent, result infrastructure.ConnectResult) {
+	if component != nil {
+		s.dependencies.Set(name, component)
+	} else {
+		s.dependencies.Delete(name)
+	}
+	s.infraInitManager.RecordConnect(name, result)
+
+	switch name {
+	case "postgres", "mongo":
+		s.dependencies.Delete(name + ".default")
+		s.setConnectionDefaults()
+		s.dependencies.Delete("tenant_provisioner")
+		s.setTenantProvisioning()
+	case s.config.Messaging.Broker:
+		if buffered, ok := registry.GetTyped[*infrastructure.BufferedBroker](s.dependencies, "messaging"); ok {
+			buffered.Close()
+		}
+		s.dependencies.Delete("messaging")
+		s.setMessagingBroker()
+	}
+
+	s.logger.Info("Infrastructure component restarted", "name", name, "connected", component != nil, "attempts", result.Attempts)
+	s.Reload()
+}
+
+// setMockUpstream starts the mock upstream server as the "mock" dependency
+// and, unless disabled, points the external services and the outbound
+// clients of http_clients at it.
+func (s *Server) setMockUpstream() {
+	if !s.config.Mock.Enabled {
+		return
+	}
+	mock := mockserver.New(s.config.Mock, s.logger)
+	if err := mock.Start(); err != nil {
+		s.logger.Error("Failed to start mock upstream", err)
+		return
+	}
+	s.dependencies.Set("mock", mock)
+	s.logger.Warn("Mock upstream enabled, external services are simulated", "url", mock.URL(), "routes", len(s.config.Mock.Routes))
+
+	if !s.config.Mock.RedirectExternal {
+		return
+	}
+	services := s.config.Monitoring.External.Services
+	for i := range services {
+		services[i].URL = mock.RewriteURL(services[i].Name, services[i].URL)
+		s.logger.Debug("External service redirected to mock", "name", services[i].Name, "url", services[i].URL)
+	}
+
+	// The outbound clients were created before the mock started, so they
+	// are created again from the rewritten base URLs
+	redirected := false
+	for name, client := range s.config.HTTPClients {
+		if client.BaseURL == "" {
+			continue
+		}
+		client.BaseURL = mock.RewriteURL(name, client.BaseURL)
+		s.config.HTTPClients[name] = client
+		redirected = true
+		s.logger.Debug("HTTP client redirected to mock", "name", name, "base_url", client.BaseURL)
+	}
+	if !redirected {
+		return
+	}
+	if clients, ok := registry.GetTyped[*infrastructure.HTTPClientManager](s.dependencies, "http_clients"); ok && clients != nil {
+		clients.Close()
+	}
+	component, result, err := infrastructure.GetGlobalRegistry().Reconnect("http_clients")
+	s.infraInitManager.RecordConnect("http_clients", result)
+	if err != nil || component == nil {
+		s.dependencies.Delete("http_clients")
+		if err != nil {
+			s.logger.Error("Failed to redirect HTTP clients to mock", err)
+		}
+		return
+	}
+	s.dependencies.Set("http_clients", component)
+}
+
+// setTracing installs the OpenTelemetry provider and registers it as the
+// "tracing" dependency so shutdown flushes pending spans.
+func (s *Server) setTracing() {
+	if !s.config.Tracing.Enabled {
+		return
+	}
+	provider, err := tracing.Setup(context.Background(), s.config.Tracing, s.config.App.Name, s.config.App.Version, s.config.App.Env)
+	if err != nil {
+		s.logger.Error("Failed to set up tracing", err)
+		return
+	}
+	s.dependencies.Set("tracing", provider)
+	s.logger.Info("Tracing enabled", "endpoint", s.config.Tracing.Endpoint, "sample_ratio", s.config.Tracing.SampleRatio)
+}
+
+// setClock starts periodic clock skew checks as the "clock" dependency.
+func (s *Server) setClock() {
+	cfg := s.config.Clock
+	if !cfg.Enabled {
+		return
+	}
+
+	var sources []timesync.Source
+	for _, server := range cfg.NTPServers {
+		sources = append(sources, timesync.NTPSource{Server: server})
+	}
+	if pg, ok := registry.GetTyped[*infrastructure.PostgresManager](s.dependencies, "postgres.default"); ok && cfg.Database {
+		sources = append(sources, timesync.ServerTimeSource{
+			SourceName: "postgres",
+			Now: func(ctx context.Context) (time.Time, error) {
+				var now time.Time
+				if pg.DB == nil {
+					return now, fmt.Errorf("not connected")
+				}
+				err := pg.QueryRow(ctx, "SELECT now()").Scan(&now)
+				return now, err
+			},
+		})
+	}
+	if len(sources) == 0 {
+		s.logger.Warn("Clock skew detection enabled without sources")
+		return
+	}
+
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	monitor := timesync.NewMonitor(sources, interval, time.Duration(cfg.MaxSkew)*time.Second, s.logger)
+	monitor.Start()
+	s.dependencies.Set("clock", monitor)
+}
+
+// setConfigDrift compares the config file with the configuration the
+// process started with as the "config_drift" dependency.
+func (s *Server) setConfigDrift() {
+	cfg := s.config.ConfigDrift
+	path := config.ConfigFile()
+	if !cfg.Enabled || path == "" {
+		return
+	}
+
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	// Compared as written, so checks resolve no vault:// reference
+	watcher := configdrift.New(path, config.LoadedFingerprint(), interval, s.logger)
+	watcher.Start()
+	s.dependencies.Set("config_drift", watcher)
+}
+
+// setRemoteConfig follows the etcd or Consul key the configuration was
+// loaded from as the "remote_config" dependency. With on_change restart a
+// changed config is applied by restarting, which loads it again.
+func (s *Server) setRemoteConfig() {
+	cfg := s.config.RemoteConfig
+	source := config.RemoteSource()
+	if source == "" || cfg.Refresh == "off" {
+		return
+	}
+	provider, err := remoteconfig.Open(source, time.Duration(cfg.Timeout)*time.Second)
+	if err != nil {
+		s.logger.Error("Failed to open remote config source", err)
+		return
+	}
+
+	watcher := remoteconfig.NewWatcher(provider, s.fingerprint, cfg.Refresh, time.Duration(cfg.Interval)*time.Second, s.logger)
+	if cfg.OnChange == "restart" {
+		watcher.OnChange = func(state remoteconfig.State) {
+			s.logger.Warn("Restarting to apply the remote config", "version", state.Version)
+			utils.TriggerRestart()
+		}
+	}
+	watcher.Start()
+	s.dependencies.Set("remote_config", watcher)
+}
+
+// setDNS starts resolution checks for the configured hostnames as the "dns"
+// dependency.
+func (s *Server) setDNS() {
+	cfg := s.config.DNS.Checks
+	if !cfg.Enabled {
+		return
+	}
+	hosts := dns.ConfigHosts(s.config, cfg.Hosts)
+	if len(hosts) == 0 {
+		return
+	}
+
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	checker := dns.NewChecker(hosts, interval, s.logger)
+	checker.Start()
+	s.dependencies.Set("dns", checker)
+}
+
+// setExternalChecks starts the periodic checks of the external services
+// as the "external" dependency.
+func (s *Server) setExternalChecks() {
+	cfg := s.config.Monitoring.External
+	if len(cfg.Services) == 0 {
+		return
+	}
+	manager := infrastructure.NewHTTPManager(cfg, s.logger)
+	manager.Start()
+	s.dependencies.Set("external", manager)
+	s.logger.Info("External service checks enabled", "services", len(cfg.Services), "interval", cfg.Interval)
+}
+
+// setMetricsHistory starts sampling metrics as the "metrics_history"
+// dependency, persisting the rollups in the embedded store or Postgres when
+// configured.
+func (s *Server) setMetricsHistory() {
+	cfg := s.config.Monitoring.Metrics
+	if !s.config.Monitoring.Enabled || !cfg.Enabled {
+		if s.config.TSDB.Enabled {
+			s.logger.Warn("tsdb needs monitoring.metrics.enabled, no samples will be shipped")
+		}
+		return
+	}
+	var backend timeseries.Backend
+	switch cfg.Backend {
+	case "":
+	case "store":
+		if store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store"); ok {
+			backend = timeseries.NewStoreBackend(store)
+		} else {
+			s.logger.Warn("Embedded store is not enabled, keeping the metrics history in memory")
+		}
+	case "postgres":
+		if pg := s.postgresConnection(cfg.Connection); pg != nil {
+			backend = timeseries.NewSQLBackend(func() *sql.DB { return pg.DB })
+		} else {
+			s.logger.Warn("PostgreSQL connection not found, keeping the metrics history in memory", "connection", cfg.Connection)
+		}
+	default:
+		s.logger.Warn("Unknown metrics history backend, keeping it in memory", "backend", cfg.Backend)
+	}
+
+	// Samples are also shipped to the external time-series database
+	var onSample func(time.Time, map[string]float64)
+	if sink, ok := registry.GetTyped[*infrastructure.TSDBManager](s.dependencies, "tsdb"); ok {
+		onSample = sink.Record
+	}
+
+	history := timeseries.New(timeseries.Options{
+		Sample:          s.metricsSampler(),
+		Interval:        time.Duration(cfg.Interval) * time.Second,
+		RawSize:         cfg.RawSize,
+		MinuteRetention: time.Duration(cfg.MinuteRetention) * time.Hour,
+		HourRetention:   time.Duration(cfg.HourRetention) * 24 * time.Hour,
+		Backend:         backend,
+		Logger:          s.logger,
+		OnSample:        onSample,
+	})
+	history.Start()
+	s.dependencies.Set("metrics_history", history)
+	s.logger.Info("Metrics history enabled", "interval", history.Interval(), "backend", cfg.Backend, "tsdb", onSample != nil)
+}
+
+// postgresConnection returns the named Postgres connection, or the default
+// one for an empty name.
+func (s *Server) postgresConnection(name string) *infrastructure.PostgresManager {
+	if manager, ok := registry.GetTyped[*infrastructure.PostgresConnectionManager](s.dependencies, "postgres"); ok {
+		if name == "" {
+			pg, _ := manager.GetDefaultConnection()
+			return pg
+		}
+		pg, _ := manager.GetConnection(name)
+		return pg
+	}
+	if name == "" {
+		pg, _ := registry.GetTyped[*infrastructure.PostgresManager](s.dependencies, "postgres")
+		return pg
+	}
+	return nil
+}
+
+// metricsSampler samples CPU, memory and disk usage, load, goroutines, the
+// request rate since the previous sample, requests in flight, the outbox
+// backlog and, per infrastructure component reporting a "connected" flag,
+// 1 when connected and 0 otherwise as infra.<name>.
+func (s *Server) metricsSampler() timeseries.Sampler {
+	lastServed, lastTime := s.served.Load(), time.Now()
+	return func() map[string]float64 {
+		values := map[string]float64{
+			"goroutines":         float64(runtime.NumGoroutine()),
+			"requests_in_flight": float64(s.inFlight.Load()),
+		}
+		// Metrics the platform cannot read are left out rather than charted
+		// as zero
+		stats := sysinfo.Default().Collect()
+		if stats.Available[sysinfo.MetricCPU] {
+			values["cpu_percent"] = stats.CPU.Percent
+		}
+		if stats.Available[sysinfo.MetricMemory] {
+			values["memory_percent"] = stats.Memory.UsedPercent
+		}
+		if stats.Available[sysinfo.MetricDisk] {
+			values["disk_percent"] = stats.Disk.UsedPercent
+		}
+		if stats.Available[sysinfo.MetricLoad] {
+			values["load1"] = stats.Load.Load1
+		}
+
+		served, now := s.served.Load(), time.Now()
+		if elapsed := now.Sub(lastTime).Seconds(); elapsed > 0 {
+			values["requests_per_second"] = float64(served-lastServed) / elapsed
+		}
+		lastServed, lastTime = served, now
+
+		seen := make(map[interface{}]bool)
+		all := s.dependencies.GetAll()
+		for _, name := range slices.Sorted(maps.Keys(all)) {
+			comp, ok := all[name].(interface{ GetStatus() map[string]interface{} })
+			if !ok || seen[all[name]] {
+				continue
+			}
+			seen[all[name]] = true
+			if connected, ok := comp.GetStatus()["connected"].(bool); ok {
+				values["infra."+name] = 0
+				if connected {
+					values["infra."+name] = 1
+				}
+			}
+		}
+
+		if box, ok := registry.GetTyped[*outbox.Outbox](s.dependencies, "outbox"); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if backlog, _, err := box.Backlog(ctx); err == nil {
+				values["outbox_backlog"] = float64(backlog)
+			}
+			cancel()
+		}
+		return values
+	}
+}
+
+// setAlerting starts the alerting engine and registers it as the "alerting"
+// dependency for the monitoring API.
+func (s *Server) setAlerting() {
+	if !s.config.Alerting.Enabled {
+		return
+	}
+
+	sources := alerting.Sources{
+		CPUPercent: alerting.SystemCPUPercent,
+		Components: func() map[string]map[string]interface{} {
+			statuses := make(map[string]map[string]interface{})
+			for name, component := range s.dependencies.GetAll() {
+				if comp, ok := component.(interface{ GetStatus() map[string]interface{} }); ok && name != "alerting" {
+					statuses[name] = comp.GetStatus()
+				}
+			}
+			return statuses
+		},
+		External: s.config.Monitoring.External.Services,
+		Logs:     s.logBroadcaster,
+		Mailer: func() alerting.Mailer {
+			mailer, _ := registry.GetTyped[alerting.Mailer](s.dependencies, "mail")
+			return mailer
+		},
+	}
+	if clock, ok := registry.GetTyped[*timesync.Monitor](s.dependencies, "clock"); ok {
+		sources.ClockSkew = func() (time.Duration, error) {
+			last, ok := clock.Last()
+			switch {
+			case !ok:
+				return 0, fmt.Errorf("clock skew not measured yet")
+			case !last.OK():
+				return 0, fmt.Errorf("%s", last.Error)
+			}
+			return last.Skew(), nil
+		}
+	}
+	if drift, ok := registry.GetTyped[*configdrift.Watcher](s.dependencies, "config_drift"); ok {
+		sources.ConfigDrift = func() (string, string, error) {
+			last, ok := drift.Last()
+			switch {
+			case !ok:
+				return "", "", fmt.Errorf("config file not checked yet")
+			case !last.OK():
+				return "", "", fmt.Errorf("%s", last.Error)
+			}
+			return drift.Running(), last.FileFingerprint, nil
+		}
+	} else if remote, ok := registry.GetTyped[*remoteconfig.Watcher](s.dependencies, "remote_config"); ok {
+		sources.ConfigDrift = func() (string, string, error) {
+			last, ok := remote.Last()
+			switch {
+			case !ok:
+				return "", "", fmt.Errorf("remote config not read yet")
+			case !last.OK():
+				return "", "", fmt.Errorf("%s", last.Error)
+			}
+			return s.fingerprint, last.Fingerprint, nil
+		}
+	}
+	store, _ := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store")
+
+	engine, err := alerting.NewEngine(s.config.Alerting, sources, store, s.logger)
+	if err != nil {
+		s.logger.Error("Failed to start alerting", err)
+		return
+	}
+	engine.Start()
+	s.dependencies.Set("alerting", engine)
+	s.logger.Info("Alerting enabled", "rules", len(engine.Rules()), "interval", s.config.Alerting.Interval)
+}
+
+// setReports starts the scheduled status reports and registers the
+// generator as the "reports" dependency for the monitoring API.
+func (s *Server) setReports() {
+	if !s.config.Reports.Enabled {
+		return
+	}
+
+	sources := reporting.Sources{
+		App:       s.config.App,
+		StartedAt: time.Now(),
+		Components: func() map[string]map[string]interface{} {
+			statuses := make(map[string]map[string]interface{})
+			for name, component := range s.dependencies.GetAll() {
+				if comp, ok := component.(interface{ GetStatus() map[string]interface{} }); ok && name != "reports" {
+					statuses[name] = comp.GetStatus()
+				}
+			}
+			return statuses
+		},
+		Endpoints:      endpointstats.Default().Snapshot,
+		EndpointsSince: endpointstats.Default().Since,
+		Logs:           s.logBroadcaster,
+		Mailer: func() reporting.Mailer {
+			mailer, _ := registry.GetTyped[reporting.Mailer](s.dependencies, "mail")
+			return mailer
+		},
+	}
+	store, _ := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store")
+
+	generator := reporting.NewGenerator(s.config.Reports, sources, store, s.logger)
+	generator.Start()
+	s.dependencies.Set("reports", generator)
+	s.logger.Info("Scheduled reports enabled", "interval_hours", s.config.Reports.Interval, "recipients", len(s.config.Reports.Recipients))
+}
+
+// setPreferences registers the operator preferences store as
+// "preferences", persisted in the embedded store when it is enabled.
+func (s *Server) setPreferences() {
+	if !s.config.Monitoring.Enabled {
+		return
+	}
+	cfg := s.config.Monitoring.I18n
+	settings, err := i18n.NewSettings(cfg.Locales, i18n.Preferences{Locale: cfg.Locale, Timezone: cfg.Timezone})
+	if err != nil {
+		s.logger.Warn("Invalid monitoring.i18n settings, using the built-in locales", "error", err.Error())
+		if settings, err = i18n.NewSettings(nil, i18n.Preferences{}); err != nil {
+			return
+		}
+	}
+
+	var kv i18n.KV
+	if store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store"); ok {
+		kv = store
+	}
+	s.dependencies.Set("preferences", i18n.NewStore(settings, kv))
+}
+
+// setAudit registers the monitoring audit log as "audit", in the embedded
+// store when configured and available, in a JSON lines file otherwise.
+func (s *Server) setAudit() {
+	cfg := s.config.Monitoring.Audit
+	if !s.config.Monitoring.Enabled || !cfg.Enabled {
+		return
+	}
+	if cfg.Backend == "store" {
+		if store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store"); ok {
+			s.dependencies.Set("audit", audit.NewStoreLog(store))
+			return
+		}
+		s.logger.Warn("Embedded store is not enabled, writing the audit log to a file", "path", cfg.Path)
+	}
+	log, err := audit.NewFileLog(cfg.Path, int64(cfg.MaxSizeMB)*1024*1024)
+	if err != nil {
+		s.logger.Error("Failed to open audit log", err, "path", cfg.Path)
+		return
+	}
+	s.dependencies.Set("audit", log)
+}
+
+// setQueryBook registers the DB console's saved queries and history as
+// "querybook", persisted in the embedded store when enabled, and the
+// runner of their scheduled reports as "query_reports".
+func (s *Server) setQueryBook() {
+	if !s.config.Monitoring.Enabled {
+		return
+	}
+	var kv querybook.KV
+	if store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store"); ok {
+		kv = store
+	}
+	book := querybook.New(kv, s.config.Monitoring.QueryHistorySize)
+	s.dependencies.Set("querybook", book)
+
+	// Reports need the cron manager; without it they can be listed but not
+	// scheduled
+	var cron querybook.Cron
+	if manager, ok := registry.GetTyped[*infrastructure.CronManager](s.dependencies, "cron"); ok {
+		cron = manager
+	}
+	reports := querybook.NewScheduler(book, cron, monitoring.NewQueryRunner(s.config, s.dependencies), s.logger)
+	if err := reports.Start(); err != nil {
+		s.logger.Error("Failed to schedule query reports", err)
+	}
+	s.dependencies.Set("query_reports", reports)
+}
+
+// setAccounts registers the monitoring accounts as "accounts", persisted
+// in the embedded store when enabled.
+func (s *Server) setAccounts() {
+	cfg := s.config.Monitoring.Accounts
+	if !s.config.Monitoring.Enabled || !cfg.Enabled {
+		return
+	}
+	if s.config.Auth.Secret == "" {
+		s.logger.Warn("auth.secret is not set, monitoring accounts cannot log in")
+	}
+	var kv accounts.KV
+	if store, ok := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store"); ok {
+		kv = store
+	} else {
+		s.logger.Warn("Embedded store is not enabled, monitoring accounts are kept in memory only")
+	}
+	directory, err := accounts.New(kv, accounts.Options{
+		InviteTTL:         time.Duration(cfg.InviteTTL) * time.Second,
+		MinPasswordLength: cfg.MinPasswordLength,
+		ValidRole: func(role string) bool {
+			_, ok := monitoring.ParseRole(role)
+			return ok
+		},
+	})
+	if err != nil {
+		s.logger.Error("Failed to load monitoring accounts", err)
+		return
+	}
+	s.dependencies.Set("accounts", directory)
+}
+
+// setJobs registers the background job manager as "jobs" and, when
+// enabled, the tenant data export/deletion workflows as "tenant_data".
+func (s *Server) setJobs() {
+	store, _ := registry.GetTyped[*infrastructure.EmbeddedStore](s.dependencies, "store")
+	jobManager, err := jobs.NewManager(store, s.config.Jobs.Workers, s.logger)
+	if err != nil {
+		s.logger.Error("Failed to start job manager", err)
+		return
+	}
+	s.dependencies.Set("jobs", jobManager)
+
+	if !s.config.TenantData.Enabled {
+		return
+	}
+	storage, _ := registry.GetTyped[*infrastructure.StorageManager](s.dependencies, "storage")
+	sources := tenantdata.SourcesFromDependencies(s.config.TenantData, s.dependencies)
+	service, err := tenantdata.NewService(s.config.TenantData, sources, storage, jobManager, store, s.logger)
+	if err != nil {
+		s.logger.Warn("Tenant data workflows disabled", "reason", err.Error())
+		return
+	}
+	s.dependencies.Set("tenant_data", service)
+	s.logger.Info("Tenant data workflows enabled", "sources", len(sources))
+}
+
+// setTenantProvisioning registers the tenant provisioner as
+// "tenant_provisioner" when tenancy.provisioning is enabled. New tenants
+// run the migrations of the current services.
+func (s *Server) setTenantProvisioning() {
+	if !s.config.Tenancy.Provisioning.Enabled {
+		return
+	}
+	opts := tenantprovision.Options{
+		Tenants: tenancy.DefaultRegistry(),
+		Migrate: func(ctx context.Context) error {
+			var services []interfaces.Service
+			if current := s.services.Load(); current != nil {
+				services = *current
+			}
+			var errs []error
+			for _, result := range s.runMigrations(ctx, services) {
+				if result.Err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", result.Service, result.Err))
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+	if pg, ok := registry.GetTyped[*infrastructure.PostgresConnectionManager](s.dependencies, "postgres"); ok && pg != nil {
+		opts.Postgres = pg
+	}
+	if mongo, ok := registry.GetTyped[*infrastructure.MongoConnectionManager](s.dependencies, "mongo"); ok && mongo != nil {
+		opts.Mongo = mongo
+	}
+	if path := config.ConfigFile(); path != "" {
+		opts.ConfigFile = config.NewSectionFile(path)
+		if cfg := s.config.Monitoring.ConfigBackups; cfg.Enabled {
+			opts.ConfigFile.WithBackups(config.NewBackupStore(path, cfg))
+		}
+	}
+	provisioner, err := tenantprovision.New(s.config, opts, s.logger)
+	if err != nil {
+		s.logger.Warn("Tenant provisioning disabled", "reason", err.Error())
+		return
+	}
+	s.dependencies.Set("tenant_provisioner", provisioner)
+}
+
+// setQueue registers the job queue of queue.backend as the "queue"
+// dependency. Its workers start once the services are booted.
+func (s *Server) setQueue() {
+	cfg := s.config.Queue
+	if !cfg.Enabled {
+		return
+	}
+	var backend queue.Backend
+	switch cfg.Backend {
+	case queue.BackendRedis:
+		if redis, ok := registry.GetTyped[*infrastructure.RedisManager](s.dependencies, "redis"); ok && redis != nil {
+			backend = queue.NewRedisBackend(redis.Client, cfg.Prefix)
+		} else {
+			s.logger.Warn("Redis not available, keeping queued jobs in memory")
+		}
+	case queue.BackendPostgres:
+		backend = queue.NewPostgresBackend(func() *sql.DB {
+			if pg := s.postgresConnection(cfg.Connection); pg != nil {
+				return pg.DB
+			}
+			return nil
+		}, cfg.Table)
+	case queue.BackendMemory:
+	default:
+		s.logger.Warn("Unknown queue backend, keeping queued jobs in memory", "backend", cfg.Backend)
+	}
+	if backend == nil {
+		backend = queue.NewMemoryBackend()
+	}
+
+	q := queue.New(backend, queue.Options{
+		Workers:      cfg.Workers,
+		PollInterval: time.Duration(cfg.PollInterval) * time.Millisecond,
+		Lease:        time.Duration(cfg.Lease) * time.Second,
+		Retry: queue.RetryPolicy{
+			MaxAttempts: cfg.Retry.MaxAttempts,
+			Backoff:     time.Duration(cfg.Retry.Backoff) * time.Second,
+			MaxBackoff:  time.Duration(cfg.Retry.MaxBackoff) * time.Second,
+		},
+		Logger: s.logger,
+	})
+	s.dependencies.Set("queue", q)
+	s.logger.Info("Job queue enabled", "backend", backend.Name(), "workers", cfg.Workers)
+}
+
 func (s *Server) registerHealthEndpoints() {
+	s.gin.GET("/version", func(c *gin.Context) {
+		response.Success(c, map[string]interface{}{
+			"name":               s.config.App.Name,
+			"version":            s.config.App.Version,
+			"env":                s.config.App.Env,
+			"go_version":         runtime.Version(),
+			"config_fingerprint": s.fingerprint,
+		})
+	})
+
 	s.gin.GET("/health", func(c *gin.Context) {
 		response.Success(c, map[string]interface{}{
 			"status":                  "ok",
@@ -152,6 +1470,27 @@ func (s *Server) registerHealthEndpoints() {
 		})
 	})
 
+	// Readiness: every enabled service with a health check passes it
+	s.gin.GET("/health/ready", func(c *gin.Context) {
+		services := s.serviceRegistry.Load()
+		if services == nil {
+			response.ServiceUnavailable(c, "Services are not started yet")
+			return
+		}
+		checks := services.Health(c.Request.Context(), registry.DefaultHealthTimeout)
+		for _, check := range checks {
+			if !check.Healthy() {
+				response.Error(c, http.StatusServiceUnavailable, "NOT_READY", "Some services are unhealthy",
+					map[string]interface{}{"services": checks})
+				return
+			}
+		}
+		response.Success(c, map[string]interface{}{
+			"status":   "ready",
+			"services": checks,
+		})
+	})
+
 	s.gin.GET("/health/infrastructure", func(c *gin.Context) {
 		response.Success(c, s.infraInitManager.GetStatus())
 	})
@@ -177,15 +1516,121 @@ func (s *Server) registerHealthEndpoints() {
 	})
 }
 
+// SetShutdownReason records why the next Shutdown happens, for its report.
+func (s *Server) SetShutdownReason(reason string) {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	s.shutdownReason = reason
+}
+
+// updateShutdown changes the report of the shutdown in progress.
+func (s *Server) updateShutdown(update func(r *shutdown.Report)) {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	if s.shutdownReport != nil {
+		update(s.shutdownReport)
+	}
+}
+
+// WriteShutdownReport saves the report of the shutdown in progress as
+// incomplete, for when the process is forced to exit before it finishes.
+func (s *Server) WriteShutdownReport() error {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	if s.shutdownReport == nil || s.config.Server.ShutdownReport == "" {
+		return nil
+	}
+	report := *s.shutdownReport
+	report.Components = slices.Clone(report.Components)
+	report.Errors = append(slices.Clone(report.Errors), "forced exit: shutdown did not finish in time")
+	report.Finish(false)
+	return shutdown.Write(s.config.Server.ShutdownReport, &report)
+}
+
+// setLastShutdown registers the report the previous run left as the
+// "last_shutdown" dependency.
+func (s *Server) setLastShutdown() {
+	path := s.config.Server.ShutdownReport
+	if path == "" {
+		return
+	}
+	report, err := shutdown.Read(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("Failed to read the last shutdown report", "path", path, "error", err.Error())
+		}
+		return
+	}
+	s.dependencies.Set("last_shutdown", report)
+	if !report.Complete || len(report.Errors) > 0 {
+		s.logger.Warn("The previous shutdown did not finish cleanly", "reason", report.Reason, "errors", len(report.Errors), "complete", report.Complete)
+	}
+}
+
 func (s *Server) Shutdown(ctx context.Context, logger *logger.Logger) error {
 	utils.ClearScreen()
 	logger.Info("Starting graceful shutdown of infrastructure...")
 
+	s.shutdownMu.Lock()
+	reason := s.shutdownReason
+	if reason == "" {
+		reason = "shutdown"
+	}
+	s.shutdownReport = shutdown.New(reason, s.config.App.Version)
+	s.shutdownMu.Unlock()
+
 	if s.infraInitManager != nil {
 		logger.Info("Stopping async infrastructure initialization manager...")
 	}
 
-	var shutdownErrors []error
+	if s.devWatcher != nil {
+		s.devWatcher.Close()
+	}
+	if s.deferredWatch != nil {
+		close(s.deferredWatch)
+	}
+
+	// Stop accepting requests and let in-flight ones finish before the
+	// infrastructure they use goes away
+	drained := s.drain(ctx, logger)
+	s.updateShutdown(func(r *shutdown.Report) { r.Drain = drained })
+
+	// Stop the services' background work while the infrastructure it uses
+	// is still open
+	servicesStart := time.Now()
+	servicesErr := s.stopServices(ctx)
+
+	// Save the queued log entries while the embedded store is still open
+	if s.logBroadcaster != nil {
+		s.logBroadcaster.StopReplay()
+	}
+
+	var (
+		shutdownErrors []error
+		errorsMu       sync.Mutex
+	)
+	addError := func(err error) {
+		errorsMu.Lock()
+		shutdownErrors = append(shutdownErrors, err)
+		errorsMu.Unlock()
+		s.updateShutdown(func(r *shutdown.Report) { r.Errors = append(r.Errors, err.Error()) })
+	}
+	if drained.TimedOut {
+		addError(fmt.Errorf("http drain timed out with %d requests in flight", drained.Remaining))
+	}
+	recordComponent := func(name, status string, start time.Time, err error) {
+		c := shutdown.Component{Name: name, Status: status, DurationMS: shutdown.Millis(time.Since(start))}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		s.updateShutdown(func(r *shutdown.Report) { r.Components = append(r.Components, c) })
+	}
+	if servicesErr != nil {
+		addError(fmt.Errorf("services stop error: %w", servicesErr))
+		recordComponent("services", shutdown.StatusError, servicesStart, servicesErr)
+	} else {
+		recordComponent("services", shutdown.StatusOK, servicesStart, nil)
+	}
 
 	shutdownComponent := func(name string, closer interface{}) {
 		if closer == nil {
@@ -194,32 +1639,57 @@ func (s *Server) Shutdown(ctx context.Context, logger *logger.Logger) error {
 
 		logger.Info("Shutting down " + name + "...")
 		if c, ok := closer.(interface{ Close() error }); ok {
-			done := make(chan struct{}, 1)
+			start := time.Now()
+			done := make(chan error, 1)
 			go func() {
 				err := c.Close()
 				if err != nil {
-					shutdownErrors = append(shutdownErrors, fmt.Errorf("%s shutdown error: %w", name, err))
+					addError(fmt.Errorf("%s shutdown error: %w", name, err))
 					logger.Error("Error shutting down "+name, err)
 				} else {
 					logger.Info(name + " shut down successfully")
 				}
-				done <- struct{}{}
+				done <- err
 			}()
 			select {
-			case <-done:
-				// completed normally
+			case err := <-done:
+				if err != nil {
+					recordComponent(name, shutdown.StatusError, start, err)
+				} else {
+					recordComponent(name, shutdown.StatusOK, start, nil)
+				}
 			case <-time.After(10 * time.Second):
-				shutdownErrors = append(shutdownErrors, fmt.Errorf("%s: forced shutdown (timeout)", name))
+				err := fmt.Errorf("%s: forced shutdown (timeout)", name)
+				addError(err)
+				recordComponent(name, shutdown.StatusTimeout, start, err)
 				logger.Warn(name + " shutdown timed out after 10s, continuing")
+			case <-ctx.Done():
+				err := fmt.Errorf("%s: forced shutdown (%w)", name, ctx.Err())
+				addError(err)
+				recordComponent(name, shutdown.StatusAbandoned, start, err)
+				logger.Warn(name + " shutdown abandoned, shutdown deadline reached")
 			}
 		}
 	}
 
-	// Dynamically shut down all registered components
+	// Dynamically shut down all registered components. Aliases such as
+	// "postgres.default" or "messaging" point at components that are also
+	// registered under their own name, so close every instance only once.
+	closed := make(map[interface{}]bool)
 	for name, component := range s.dependencies.GetAll() {
+		if component != nil && reflect.TypeOf(component).Comparable() {
+			if closed[component] {
+				continue
+			}
+			closed[component] = true
+		}
 		shutdownComponent(name, component)
 	}
 
+	s.writeShutdownReport(logger)
+
+	errorsMu.Lock()
+	defer errorsMu.Unlock()
 	if len(shutdownErrors) > 0 {
 		logger.Warn("Graceful shutdown completed with errors", "error_count", len(shutdownErrors))
 		for _, err := range shutdownErrors {
@@ -231,3 +1701,19 @@ func (s *Server) Shutdown(ctx context.Context, logger *logger.Logger) error {
 	logger.Info("Graceful shutdown completed successfully")
 	return nil
 }
+
+// writeShutdownReport finishes the report of this shutdown and saves it.
+func (s *Server) writeShutdownReport(logger *logger.Logger) {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	path := s.config.Server.ShutdownReport
+	if s.shutdownReport == nil || path == "" {
+		return
+	}
+	s.shutdownReport.Finish(true)
+	if err := shutdown.Write(path, s.shutdownReport); err != nil {
+		logger.Error("Failed to write the shutdown report", err, "path", path)
+		return
+	}
+	logger.Info("Shutdown report written", "path", path, "duration_ms", s.shutdownReport.DurationMS)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"stackyrd/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSSetup is the TLS configuration of the main server plus the handler of
+// the plain HTTP listener, if one is needed.
+type TLSSetup struct {
+	Config *tls.Config
+	// HTTPHandler serves HTTP→HTTPS redirects and ACME HTTP-01 challenges;
+	// nil when no plain HTTP listener is needed.
+	HTTPHandler http.Handler
+}
+
+// NewTLSSetup builds the TLS configuration of server, or returns nil when
+// TLS is disabled. HTTP/2 is negotiated over ALPN. With auth.type mtls
+// clients must present a certificate issued by auth.mtls.ca_file.
+func NewTLSSetup(server config.ServerConfig, auth config.AuthConfig) (*TLSSetup, error) {
+	cfg := server.TLS
+	if !cfg.Enabled {
+		if auth.Type == "mtls" {
+			return nil, errors.New("auth.type mtls requires server.tls.enabled")
+		}
+		return nil, nil
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	switch cfg.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		minVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls.min_version %q", cfg.MinVersion)
+	}
+
+	setup := &TLSSetup{}
+	var redirect http.Handler
+	if cfg.RedirectHTTP {
+		redirect = redirectToHTTPS(server.Port)
+	}
+
+	if cfg.Autocert.Enabled {
+		if len(cfg.Autocert.Domains) == 0 {
+			return nil, errors.New("tls.autocert.domains is required")
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Autocert.Domains...),
+			Email:      cfg.Autocert.Email,
+		}
+		if cfg.Autocert.CacheDir != "" {
+			manager.Cache = autocert.DirCache(cfg.Autocert.CacheDir)
+		}
+		setup.Config = manager.TLSConfig()
+		// Answer HTTP-01 challenges even without redirects; other requests
+		// get a 404 then, as a nil fallback would redirect them to :443
+		fallback := redirect
+		if fallback == nil {
+			fallback = http.NotFoundHandler()
+		}
+		setup.HTTPHandler = manager.HTTPHandler(fallback)
+	} else {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("tls.cert_file and tls.key_file are required unless autocert is enabled")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		setup.Config = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+		setup.HTTPHandler = redirect
+	}
+	setup.Config.MinVersion = minVersion
+
+	if auth.Type == "mtls" {
+		pool, err := loadCAPool(auth.MTLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		setup.Config.ClientCAs = pool
+		setup.Config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return setup, nil
+}
+
+func loadCAPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, errors.New("auth.mtls.ca_file is required for auth.type mtls")
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// redirectToHTTPS redirects to the same host and path on port.
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		target := "https://" + host
+		if port != "" && port != "443" {
+			target += ":" + port
+		}
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
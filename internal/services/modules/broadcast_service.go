@@ -1,18 +1,22 @@
 package modules
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
 	"stackyrd/pkg/registry"
 	"stackyrd/pkg/request"
 	"stackyrd/pkg/response"
 	"stackyrd/pkg/utils"
+	"stackyrd/pkg/websocket"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,43 +25,52 @@ import (
 type SimpleStreamGenerator struct {
 	streamID    string
 	broadcaster *utils.EventBroadcaster
-	running     bool
-	stopChan    chan struct{}
+	hub         *websocket.Hub
+
+	mu   sync.Mutex
+	stop chan struct{} // nil while stopped
+	done chan struct{} // closed when the running generator returns
 }
 
-func NewSimpleStreamGenerator(streamID string, broadcaster *utils.EventBroadcaster) *SimpleStreamGenerator {
+func NewSimpleStreamGenerator(streamID string, broadcaster *utils.EventBroadcaster, hub *websocket.Hub) *SimpleStreamGenerator {
 	return &SimpleStreamGenerator{
 		streamID:    streamID,
 		broadcaster: broadcaster,
-		stopChan:    make(chan struct{}),
+		hub:         hub,
 	}
 }
 
 func (sg *SimpleStreamGenerator) Start() {
-	if sg.running {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	if sg.stop != nil {
 		return
 	}
-	sg.running = true
-	go sg.generateEvents()
+	sg.stop, sg.done = make(chan struct{}), make(chan struct{})
+	go sg.generateEvents(sg.stop, sg.done)
 }
 
+// Stop stops the generator and waits for its goroutine to return.
 func (sg *SimpleStreamGenerator) Stop() {
-	if !sg.running {
+	sg.mu.Lock()
+	stop, done := sg.stop, sg.done
+	sg.stop, sg.done = nil, nil
+	sg.mu.Unlock()
+	if stop == nil {
 		return
 	}
-	sg.running = false
-	select {
-	case sg.stopChan <- struct{}{}:
-	default:
-		close(sg.stopChan)
-	}
+	close(stop)
+	<-done
 }
 
 func (sg *SimpleStreamGenerator) IsRunning() bool {
-	return sg.running
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	return sg.stop != nil
 }
 
-func (sg *SimpleStreamGenerator) generateEvents() {
+func (sg *SimpleStreamGenerator) generateEvents(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
 	ticker := time.NewTicker(3 * time.Second)
 	defer ticker.Stop()
 
@@ -75,7 +88,7 @@ func (sg *SimpleStreamGenerator) generateEvents() {
 	i := 0
 	for {
 		select {
-		case <-sg.stopChan:
+		case <-stop:
 			return
 		case <-ticker.C:
 			event := events[i%len(events)]
@@ -90,6 +103,7 @@ func (sg *SimpleStreamGenerator) generateEvents() {
 			data["demo_id"] = i
 
 			sg.broadcaster.Broadcast(sg.streamID, event.Type, event.Message, data)
+			relayToHub(sg.hub, sg.streamID, event.Type, event.Message, data)
 		}
 	}
 }
@@ -98,8 +112,15 @@ func (sg *SimpleStreamGenerator) generateEvents() {
 type BroadcastService struct {
 	enabled     bool
 	broadcaster *utils.EventBroadcaster
+	hub         *websocket.Hub
+	streamsMu   sync.Mutex
 	streams     map[string]*SimpleStreamGenerator
 	logger      *logger.Logger
+
+	// deps provides the Postgres connections whose notify channels are
+	// bridged to streams; bridges stops them.
+	deps    *registry.Dependencies
+	bridges []func()
 }
 
 func NewBroadcastService(enabled bool, logger *logger.Logger) *BroadcastService {
@@ -111,35 +132,70 @@ func NewBroadcastService(enabled bool, logger *logger.Logger) *BroadcastService
 	}
 
 	if enabled {
-		logger.Info("Broadcast Service starting - broadcasting made easy!")
-		service.startDemoStreams()
-		logger.Info("Broadcast Service ready!")
+		service.hub = websocket.NewHub("broadcast_service", websocket.Options{}, logger)
 	}
 
 	return service
 }
 
+// Start starts the demo streams and bridges the notify channels of the
+// Postgres connections to their streams.
+func (s *BroadcastService) Start(ctx context.Context) error {
+	s.startDemoStreams()
+	s.bridgePostgres()
+	s.logger.Info("Broadcast Service ready!")
+	return nil
+}
+
+// Stop stops every stream generator and disconnects the WebSocket clients.
+func (s *BroadcastService) Stop(ctx context.Context) error {
+	s.streamsMu.Lock()
+	generators := s.streams
+	s.streams = make(map[string]*SimpleStreamGenerator)
+	bridges := s.bridges
+	s.bridges = nil
+	s.streamsMu.Unlock()
+
+	for _, stop := range bridges {
+		stop()
+	}
+	for _, generator := range generators {
+		generator.Stop()
+	}
+	if s.hub != nil {
+		s.hub.Close()
+	}
+	return nil
+}
+
 func (s *BroadcastService) Name() string     { return "Broadcast Service" }
 func (s *BroadcastService) WireName() string { return "broadcast-service" }
 func (s *BroadcastService) Enabled() bool    { return s.enabled }
 func (s *BroadcastService) Get() interface{} { return s }
 func (s *BroadcastService) Endpoints() []string {
-	return []string{"/events/stream/{stream_id}", "/events/broadcast", "/events/streams"}
+	return []string{"/events/stream/{stream_id}", "/events/ws", "/events/broadcast", "/events/streams"}
 }
 
 func (s *BroadcastService) RegisterRoutes(g *gin.RouterGroup) {
 	events := g.Group("/events")
 	events.GET("/stream/:stream_id", s.streamEvents)
+	events.GET("/ws", s.hub.Handler())
 	events.POST("/broadcast", s.broadcastEvent)
 	events.GET("/streams", s.getActiveStreams)
 	events.POST("/stream/:stream_id/start", s.startStream)
 	events.POST("/stream/:stream_id/stop", s.stopStream)
 }
 
-// streamEvents handles SSE connections
+// streamEvents handles SSE connections. A client reconnecting with the
+// Last-Event-ID header (or ?last_event_id=) first gets the buffered events
+// it missed, or a "missed" event when some are no longer buffered.
 func (s *BroadcastService) streamEvents(c *gin.Context) {
 	streamID := c.Param("stream_id")
-	client := s.broadcaster.Subscribe(streamID)
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	client, replay, missed := s.broadcaster.SubscribeFrom(streamID, lastEventID)
 	defer s.broadcaster.Unsubscribe(client.ID)
 
 	// SSE headers
@@ -159,6 +215,20 @@ func (s *BroadcastService) streamEvents(c *gin.Context) {
 	}
 
 	s.sendSSEEvent(c, initialEvent)
+	if missed {
+		s.sendSSEEvent(c, utils.EventData{
+			Type:      "missed",
+			Message:   "Some events after " + lastEventID + " are no longer buffered",
+			Data:      map[string]interface{}{"last_event_id": lastEventID},
+			Timestamp: time.Now().Unix(),
+			StreamID:  streamID,
+		})
+	}
+	for _, event := range replay {
+		if err := s.sendSSEEvent(c, event); err != nil {
+			return
+		}
+	}
 
 	// Listen for events
 	for {
@@ -192,6 +262,7 @@ func (s *BroadcastService) broadcastEvent(c *gin.Context) {
 		return
 	}
 
+	relayToHub(s.hub, req.StreamID, req.Type, req.Message, req.Data)
 	if req.StreamID == "" {
 		s.broadcaster.BroadcastToAll(req.Type, req.Message, req.Data)
 		response.Success(c, nil, "Event broadcasted to all streams")
@@ -218,6 +289,7 @@ func (s *BroadcastService) getActiveStreams(c *gin.Context) {
 		"streams":       streamInfo,
 		"total_clients": totalClients,
 		"stream_count":  streamCount,
+		"websocket":     s.hub.Stats(),
 		"service":       "broadcast_service",
 	}
 
@@ -227,30 +299,36 @@ func (s *BroadcastService) getActiveStreams(c *gin.Context) {
 func (s *BroadcastService) startStream(c *gin.Context) {
 	streamID := c.Param("stream_id")
 
-	if generator, exists := s.streams[streamID]; exists {
-		generator.Start()
+	s.streamsMu.Lock()
+	generator, exists := s.streams[streamID]
+	if !exists {
+		generator = NewSimpleStreamGenerator(streamID, s.broadcaster, s.hub)
+		s.streams[streamID] = generator
+	}
+	s.streamsMu.Unlock()
+	generator.Start()
+
+	if exists {
 		response.Success(c, nil, fmt.Sprintf("Stream '%s' restarted", streamID))
 		return
 	}
 
-	generator := NewSimpleStreamGenerator(streamID, s.broadcaster)
-	s.streams[streamID] = generator
-	generator.Start()
-
 	response.Created(c, nil, fmt.Sprintf("Stream '%s' created and started", streamID))
 }
 
 func (s *BroadcastService) stopStream(c *gin.Context) {
 	streamID := c.Param("stream_id")
 
+	s.streamsMu.Lock()
 	generator, exists := s.streams[streamID]
+	delete(s.streams, streamID)
+	s.streamsMu.Unlock()
 	if !exists {
 		response.NotFound(c, fmt.Sprintf("Stream '%s' not found", streamID))
 		return
 	}
 
 	generator.Stop()
-	delete(s.streams, streamID)
 
 	response.Success(c, nil, fmt.Sprintf("Stream '%s' stopped and removed", streamID))
 }
@@ -261,6 +339,14 @@ func (s *BroadcastService) sendSSEEvent(c *gin.Context, event utils.EventData) e
 		return err
 	}
 
+	// Broadcast events carry their ID so a reconnecting EventSource
+	// resumes after the last one it received
+	if _, ok := utils.EventSeq(event.ID); ok {
+		_, err = fmt.Fprintf(c.Writer, "id: %s\n", event.ID)
+		if err != nil {
+			return err
+		}
+	}
 	_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", eventJSON)
 	if err != nil {
 		return err
@@ -272,19 +358,66 @@ func (s *BroadcastService) sendSSEEvent(c *gin.Context, event utils.EventData) e
 	return nil
 }
 
+// relayToHub sends an event to the WebSocket clients in the stream's room,
+// or to every client when streamID is empty.
+func relayToHub(hub *websocket.Hub, streamID, eventType, message string, data map[string]interface{}) {
+	if hub == nil {
+		return
+	}
+	hub.BroadcastMessage(eventType, streamID, utils.EventData{
+		Type:      eventType,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().Unix(),
+		StreamID:  streamID,
+	})
+}
+
 func (s *BroadcastService) startDemoStreams() {
 	streams := []string{"demo-notifications", "demo-metrics", "demo-alerts"}
 
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
 	for _, streamID := range streams {
-		generator := NewSimpleStreamGenerator(streamID, s.broadcaster)
+		generator := NewSimpleStreamGenerator(streamID, s.broadcaster, s.hub)
 		s.streams[streamID] = generator
 		generator.Start()
 	}
 }
 
+// bridgePostgres broadcasts the notifications of the channels listed under
+// notify of each Postgres connection to their streams.
+func (s *BroadcastService) bridgePostgres() {
+	if s.deps == nil {
+		return
+	}
+	connections := map[string]*infrastructure.PostgresManager{}
+	if manager, ok := registry.GetTyped[*infrastructure.PostgresConnectionManager](s.deps, "postgres"); ok && manager != nil {
+		connections = manager.GetAllConnections()
+	} else if pg, ok := registry.GetTyped[*infrastructure.PostgresManager](s.deps, "postgres"); ok && pg != nil {
+		connections["default"] = pg
+	}
+
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	for name, pg := range connections {
+		for _, bridge := range pg.NotifyBridges() {
+			stop, err := pg.BridgeNotifications(bridge.Channel, s.broadcaster, bridge.Stream)
+			if err != nil {
+				s.logger.Warn("Failed to bridge Postgres notifications", "connection", name, "channel", bridge.Channel, "error", err)
+				continue
+			}
+			s.bridges = append(s.bridges, stop)
+			s.logger.Info("Bridging Postgres notifications", "connection", name, "channel", bridge.Channel, "stream", bridge.Stream)
+		}
+	}
+}
+
 // Auto-registration function
 func init() {
 	registry.RegisterService("broadcast_service", func(config *config.Config, logger *logger.Logger, deps *registry.Dependencies) interfaces.Service {
-		return NewBroadcastService(config.Services.IsEnabled("broadcast_service"), logger)
+		service := NewBroadcastService(config.Services.IsEnabled("broadcast_service"), logger)
+		service.deps = deps
+		return service
 	})
 }
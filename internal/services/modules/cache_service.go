@@ -16,13 +16,15 @@ import (
 
 type CacheService struct {
 	enabled bool
-	store   *cache.Cache[string]
+	store   *cache.Store[string]
 }
 
-func NewCacheService(enabled bool) *CacheService {
+// NewCacheService creates the service keeping values in backend, the
+// shared "cache" dependency.
+func NewCacheService(enabled bool, backend cache.Backend) *CacheService {
 	return &CacheService{
 		enabled: enabled,
-		store:   cache.New[string](),
+		store:   cache.NewStore[string](backend, 0),
 	}
 }
 
@@ -56,10 +58,15 @@ func (s *CacheService) RegisterRoutes(g *gin.RouterGroup) {
 // @Param key path string true "Cache key"
 // @Success 200 {object} response.Response "Success"
 // @Failure 404 {object} response.Response "Key not found or expired"
+// @Failure 503 {object} response.Response "Cache unavailable"
 // @Router /cache/{key} [get]
 func (s *CacheService) GetCachedValue(c *gin.Context) {
 	key := c.Param("key")
-	val, found := s.store.Get(key)
+	val, found, err := s.store.Get(c.Request.Context(), key)
+	if err != nil {
+		response.ServiceUnavailable(c, "Cache unavailable")
+		return
+	}
 	if !found {
 		response.NotFound(c, "Key not found or expired")
 		return
@@ -77,6 +84,7 @@ func (s *CacheService) GetCachedValue(c *gin.Context) {
 // @Param request body CacheRequest true "Cache request"
 // @Success 200 {object} response.Response "Cached successfully"
 // @Failure 400 {object} response.Response "Invalid body"
+// @Failure 503 {object} response.Response "Cache unavailable"
 // @Router /cache/{key} [post]
 func (s *CacheService) SetCachedValue(c *gin.Context) {
 	key := c.Param("key")
@@ -87,7 +95,10 @@ func (s *CacheService) SetCachedValue(c *gin.Context) {
 	}
 
 	ttl := time.Duration(req.TTL) * time.Second
-	s.store.Set(key, req.Value, ttl)
+	if err := s.store.Set(c.Request.Context(), key, req.Value, ttl); err != nil {
+		response.ServiceUnavailable(c, "Cache unavailable")
+		return
+	}
 
 	response.Success(c, map[string]string{
 		"message": "Cached successfully",
@@ -99,6 +110,10 @@ func (s *CacheService) SetCachedValue(c *gin.Context) {
 // Auto-registration function - called when package is imported
 func init() {
 	registry.RegisterService("cache_service", func(config *config.Config, logger *logger.Logger, deps *registry.Dependencies) interfaces.Service {
-		return NewCacheService(config.Services.IsEnabled("cache_service"))
+		backend, ok := registry.GetTyped[cache.Backend](deps, "cache")
+		if !ok || backend == nil {
+			backend = cache.NewLRU(config.Cache.MaxEntries)
+		}
+		return NewCacheService(config.Services.IsEnabled("cache_service"), backend)
 	})
 }
@@ -74,7 +74,7 @@ func (s *GrafanaService) createDashboard(c *gin.Context) {
 
 	result, err := s.grafanaManager.CreateDashboard(c.Request.Context(), dashboard)
 	if err != nil {
-		s.logger.Error("Failed to create Grafana dashboard", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to create Grafana dashboard", err)
 		response.InternalServerError(c, "Failed to create dashboard")
 		return
 	}
@@ -111,7 +111,7 @@ func (s *GrafanaService) updateDashboard(c *gin.Context) {
 
 	result, err := s.grafanaManager.UpdateDashboard(c.Request.Context(), dashboard)
 	if err != nil {
-		s.logger.Error("Failed to update Grafana dashboard", err, "uid", uid)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to update Grafana dashboard", err, "uid", uid)
 		response.InternalServerError(c, "Failed to update dashboard")
 		return
 	}
@@ -139,7 +139,7 @@ func (s *GrafanaService) getDashboard(c *gin.Context) {
 
 	dashboard, err := s.grafanaManager.GetDashboard(c.Request.Context(), uid)
 	if err != nil {
-		s.logger.Error("Failed to get Grafana dashboard", err, "uid", uid)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to get Grafana dashboard", err, "uid", uid)
 		response.NotFound(c, "Dashboard not found")
 		return
 	}
@@ -167,7 +167,7 @@ func (s *GrafanaService) deleteDashboard(c *gin.Context) {
 
 	err := s.grafanaManager.DeleteDashboard(c.Request.Context(), uid)
 	if err != nil {
-		s.logger.Error("Failed to delete Grafana dashboard", err, "uid", uid)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to delete Grafana dashboard", err, "uid", uid)
 		response.InternalServerError(c, "Failed to delete dashboard")
 		return
 	}
@@ -204,7 +204,7 @@ func (s *GrafanaService) listDashboards(c *gin.Context) {
 
 	dashboards, err := s.grafanaManager.ListDashboards(c.Request.Context())
 	if err != nil {
-		s.logger.Error("Failed to list Grafana dashboards", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to list Grafana dashboards", err)
 		response.InternalServerError(c, "Failed to list dashboards")
 		return
 	}
@@ -244,7 +244,7 @@ func (s *GrafanaService) createDataSource(c *gin.Context) {
 
 	result, err := s.grafanaManager.CreateDataSource(c.Request.Context(), ds)
 	if err != nil {
-		s.logger.Error("Failed to create Grafana data source", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to create Grafana data source", err)
 		response.InternalServerError(c, "Failed to create data source")
 		return
 	}
@@ -272,7 +272,7 @@ func (s *GrafanaService) createAnnotation(c *gin.Context) {
 
 	result, err := s.grafanaManager.CreateAnnotation(c.Request.Context(), annotation)
 	if err != nil {
-		s.logger.Error("Failed to create Grafana annotation", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to create Grafana annotation", err)
 		response.InternalServerError(c, "Failed to create annotation")
 		return
 	}
@@ -292,7 +292,7 @@ func (s *GrafanaService) createAnnotation(c *gin.Context) {
 func (s *GrafanaService) getHealth(c *gin.Context) {
 	health, err := s.grafanaManager.GetHealth(c.Request.Context())
 	if err != nil {
-		s.logger.Error("Failed to get Grafana health", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to get Grafana health", err)
 		response.ServiceUnavailable(c, "Grafana is not available")
 		return
 	}
@@ -315,5 +315,5 @@ func init() {
 		}
 
 		return NewGrafanaService(&grafanaManager, true, logger)
-	})
+	}, "grafana")
 }
@@ -1,9 +1,11 @@
 package modules
 
 import (
+	"context"
 	"fmt"
 
 	"stackyrd/config"
+	"stackyrd/pkg/handles"
 	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
@@ -28,21 +30,33 @@ type Product struct {
 	Tags        []string           `json:"tags" bson:"tags"`
 }
 
+// productIndexes are ensured on the products collection of every Mongo
+// connection when the service starts.
+var productIndexes = []config.MongoIndexConfig{
+	{Collection: "products", Keys: []string{"category", "-price"}},
+	{Collection: "products", Keys: []string{"tags"}},
+}
+
 // MongoDBService demonstrates using multiple MongoDB connections with NoSQL operations
 type MongoDBService struct {
 	enabled                bool
 	mongoConnectionManager *infrastructure.MongoConnectionManager
+	deps                   *registry.Dependencies
 	logger                 *logger.Logger
 }
 
+// NewMongoDBService creates the service. Requests reach the tenant's
+// database through handles.TenantMongo, resolved from deps.
 func NewMongoDBService(
 	mongoConnectionManager *infrastructure.MongoConnectionManager,
+	deps *registry.Dependencies,
 	enabled bool,
 	logger *logger.Logger,
 ) *MongoDBService {
 	return &MongoDBService{
 		enabled:                enabled,
 		mongoConnectionManager: mongoConnectionManager,
+		deps:                   deps,
 		logger:                 logger,
 	}
 }
@@ -55,8 +69,32 @@ func (s *MongoDBService) Endpoints() []string {
 }
 func (s *MongoDBService) Get() interface{} { return s }
 
+// HealthCheck pings the default MongoDB connection.
+func (s *MongoDBService) HealthCheck(ctx context.Context) error {
+	db, ok := s.mongoConnectionManager.GetDefaultConnection()
+	if !ok || db.Client == nil {
+		return fmt.Errorf("mongodb is not connected")
+	}
+	return db.Client.Ping(ctx, nil)
+}
+
+// Start ensures the product indexes on every connection; a failing index
+// is logged and the service still starts.
+func (s *MongoDBService) Start(ctx context.Context) error {
+	for name, conn := range s.mongoConnectionManager.GetAllConnections() {
+		for _, status := range conn.EnsureIndexes(ctx, "mongodb_service", productIndexes...) {
+			if status.Status == infrastructure.IndexFailed {
+				s.logger.Warn("Failed to ensure product index", "connection", name, "index", status.Name, "error", status.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MongoDBService) Stop(ctx context.Context) error { return nil }
+
 func (s *MongoDBService) RegisterRoutes(g *gin.RouterGroup) {
-	sub := g.Group("/products")
+	sub := g.Group("/products", handles.Ensure(s.deps, handles.Options{}))
 
 	sub.GET("/:tenant", s.listProductsByTenant)
 	sub.POST("/:tenant", s.createProduct)
@@ -79,14 +117,14 @@ func (s *MongoDBService) RegisterRoutes(g *gin.RouterGroup) {
 // @Failure 500 {object} response.Response "Failed to query tenant database"
 // @Router /products/{tenant} [get]
 func (s *MongoDBService) listProductsByTenant(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	if tenant == "" {
 		response.BadRequest(c, "Tenant identifier is required")
 		return
 	}
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -94,7 +132,7 @@ func (s *MongoDBService) listProductsByTenant(c *gin.Context) {
 	ctx := c.Request.Context()
 	cursor, err := conn.Find(ctx, "products", bson.M{})
 	if err != nil {
-		s.logger.Error("Failed to query products", err, "tenant", tenant)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to query products", err, "tenant", tenant)
 		response.InternalServerError(c, "Failed to query tenant database")
 		return
 	}
@@ -102,7 +140,7 @@ func (s *MongoDBService) listProductsByTenant(c *gin.Context) {
 
 	var products []Product
 	if err := cursor.All(ctx, &products); err != nil {
-		s.logger.Error("Failed to decode products", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to decode products", err)
 		response.InternalServerError(c, "Failed to decode products")
 		return
 	}
@@ -123,7 +161,7 @@ func (s *MongoDBService) listProductsByTenant(c *gin.Context) {
 // @Failure 404 {object} response.Response "Tenant database not found"
 // @Router /products/{tenant} [post]
 func (s *MongoDBService) createProduct(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	if tenant == "" {
 		response.BadRequest(c, "Tenant identifier is required")
 		return
@@ -135,8 +173,8 @@ func (s *MongoDBService) createProduct(c *gin.Context) {
 		return
 	}
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -144,7 +182,7 @@ func (s *MongoDBService) createProduct(c *gin.Context) {
 	ctx := c.Request.Context()
 	result, err := conn.InsertOne(ctx, "products", product)
 	if err != nil {
-		s.logger.Error("Failed to create product", err, "tenant", tenant)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to create product", err, "tenant", tenant)
 		response.InternalServerError(c, "Failed to create product")
 		return
 	}
@@ -169,7 +207,7 @@ func (s *MongoDBService) createProduct(c *gin.Context) {
 // @Failure 404 {object} response.Response "Product or tenant not found"
 // @Router /products/{tenant}/{id} [get]
 func (s *MongoDBService) getProductByTenant(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	id := c.Param("id")
 
 	if tenant == "" || id == "" {
@@ -183,8 +221,8 @@ func (s *MongoDBService) getProductByTenant(c *gin.Context) {
 		return
 	}
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -214,7 +252,7 @@ func (s *MongoDBService) getProductByTenant(c *gin.Context) {
 // @Failure 404 {object} response.Response "Product or tenant not found"
 // @Router /products/{tenant}/{id} [put]
 func (s *MongoDBService) updateProduct(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	id := c.Param("id")
 
 	if tenant == "" || id == "" {
@@ -234,8 +272,8 @@ func (s *MongoDBService) updateProduct(c *gin.Context) {
 		return
 	}
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -255,7 +293,7 @@ func (s *MongoDBService) updateProduct(c *gin.Context) {
 
 	result, err := conn.UpdateOne(ctx, "products", bson.M{"_id": objectID}, update)
 	if err != nil {
-		s.logger.Error("Failed to update product", err, "tenant", tenant)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to update product", err, "tenant", tenant)
 		response.InternalServerError(c, "Failed to update product")
 		return
 	}
@@ -281,7 +319,7 @@ func (s *MongoDBService) updateProduct(c *gin.Context) {
 // @Failure 404 {object} response.Response "Product or tenant not found"
 // @Router /products/{tenant}/{id} [delete]
 func (s *MongoDBService) deleteProduct(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	id := c.Param("id")
 
 	if tenant == "" || id == "" {
@@ -295,8 +333,8 @@ func (s *MongoDBService) deleteProduct(c *gin.Context) {
 		return
 	}
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -304,7 +342,7 @@ func (s *MongoDBService) deleteProduct(c *gin.Context) {
 	ctx := c.Request.Context()
 	result, err := conn.DeleteOne(ctx, "products", bson.M{"_id": objectID})
 	if err != nil {
-		s.logger.Error("Failed to delete product", err, "tenant", tenant)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to delete product", err, "tenant", tenant)
 		response.InternalServerError(c, "Failed to delete product")
 		return
 	}
@@ -329,7 +367,7 @@ func (s *MongoDBService) deleteProduct(c *gin.Context) {
 // @Failure 400 {object} response.Response "Missing tenant"
 // @Router /products/{tenant}/search [get]
 func (s *MongoDBService) searchProducts(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	if tenant == "" {
 		response.BadRequest(c, "Tenant identifier is required")
 		return
@@ -337,8 +375,8 @@ func (s *MongoDBService) searchProducts(c *gin.Context) {
 
 	query := c.Query("q")
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -359,7 +397,7 @@ func (s *MongoDBService) searchProducts(c *gin.Context) {
 
 	cursor, err := conn.Find(ctx, "products", filter)
 	if err != nil {
-		s.logger.Error("Failed to search products", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to search products", err)
 		response.InternalServerError(c, "Failed to search products")
 		return
 	}
@@ -367,7 +405,7 @@ func (s *MongoDBService) searchProducts(c *gin.Context) {
 
 	var products []Product
 	if err := cursor.All(ctx, &products); err != nil {
-		s.logger.Error("Failed to decode products", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to decode products", err)
 		response.InternalServerError(c, "Failed to decode products")
 		return
 	}
@@ -386,14 +424,14 @@ func (s *MongoDBService) searchProducts(c *gin.Context) {
 // @Failure 400 {object} response.Response "Missing tenant"
 // @Router /products/{tenant}/analytics [get]
 func (s *MongoDBService) getProductAnalytics(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	if tenant == "" {
 		response.BadRequest(c, "Tenant identifier is required")
 		return
 	}
 
-	conn, exists := s.mongoConnectionManager.GetConnection(tenant)
-	if !exists {
+	conn, err := handles.TenantMongo(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found", tenant))
 		return
 	}
@@ -412,7 +450,7 @@ func (s *MongoDBService) getProductAnalytics(c *gin.Context) {
 
 	cursor, err := conn.Aggregate(ctx, "products", pipeline)
 	if err != nil {
-		s.logger.Error("Failed to get analytics", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to get analytics", err)
 		response.InternalServerError(c, "Failed to get analytics")
 		return
 	}
@@ -420,7 +458,7 @@ func (s *MongoDBService) getProductAnalytics(c *gin.Context) {
 
 	var analytics []bson.M
 	if err := cursor.All(ctx, &analytics); err != nil {
-		s.logger.Error("Failed to decode analytics", err)
+		s.logger.Ctx(c.Request.Context()).Error("Failed to decode analytics", err)
 		response.InternalServerError(c, "Failed to decode analytics")
 		return
 	}
@@ -437,11 +475,11 @@ func init() {
 			return nil
 		}
 
-		mongoManager, ok := registry.GetTyped[infrastructure.MongoConnectionManager](deps, "mongo")
+		mongoManager, ok := registry.GetTyped[*infrastructure.MongoConnectionManager](deps, "mongo")
 		if !helper.RequireDependency("MongoConnectionManager", ok) {
 			return nil
 		}
 
-		return NewMongoDBService(&mongoManager, true, logger)
-	})
+		return NewMongoDBService(mongoManager, deps, true, logger)
+	}, "mongo")
 }
@@ -1,16 +1,21 @@
 package modules
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strconv"
 
 	"stackyrd/config"
+	"stackyrd/pkg/handles"
 	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/models"
 	"stackyrd/pkg/registry"
 	"stackyrd/pkg/request"
 	"stackyrd/pkg/response"
+	"stackyrd/pkg/sqlbuilder"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -27,36 +32,61 @@ type MultiTenantOrder struct {
 	Status      string  `json:"status" gorm:"not null;default:'pending'"`
 }
 
+// orderSort whitelists the orders the order list can be sorted by.
+var orderSort = sqlbuilder.Sort{
+	Columns: map[string]string{
+		"created":     "created_at",
+		"total_price": "total_price",
+		"quantity":    "quantity",
+		"status":      "status",
+	},
+	Default:  "created",
+	Tiebreak: "id",
+}
+
 // MultiTenantService demonstrates using multiple PostgreSQL connections with GORM
 type MultiTenantService struct {
 	enabled                   bool
 	postgresConnectionManager *infrastructure.PostgresConnectionManager
+	deps                      *registry.Dependencies
 	logger                    *logger.Logger
 }
 
+// NewMultiTenantService creates the service. Requests reach the tenant's
+// database through handles.TenantDB, resolved from deps.
 func NewMultiTenantService(
 	postgresConnectionManager *infrastructure.PostgresConnectionManager,
+	deps *registry.Dependencies,
 	enabled bool,
 	logger *logger.Logger,
 ) *MultiTenantService {
-	if enabled && postgresConnectionManager != nil {
-		allConnections := postgresConnectionManager.GetAllConnections()
-		for tenant, db := range allConnections {
-			if db.ORM != nil {
-				if err := db.ORM.AutoMigrate(&MultiTenantOrder{}); err != nil {
-					logger.Error("Error migrating MultiTenantOrder", err, "tenant", tenant)
-				}
-			}
-		}
-	}
-
 	return &MultiTenantService{
 		enabled:                   enabled,
 		postgresConnectionManager: postgresConnectionManager,
+		deps:                      deps,
 		logger:                    logger,
 	}
 }
 
+// HealthCheck pings every tenant database.
+func (s *MultiTenantService) HealthCheck(ctx context.Context) error {
+	connections := s.postgresConnectionManager.GetAllConnections()
+	if len(connections) == 0 {
+		return fmt.Errorf("no tenant database is connected")
+	}
+	var errs []error
+	for tenant, db := range connections {
+		if db.DB == nil {
+			errs = append(errs, fmt.Errorf("tenant %s: not connected", tenant))
+			continue
+		}
+		if err := db.DB.PingContext(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (s *MultiTenantService) Name() string     { return "Multi-Tenant Service" }
 func (s *MultiTenantService) WireName() string { return "multitenant-service" }
 func (s *MultiTenantService) Enabled() bool    { return s.enabled }
@@ -66,7 +96,7 @@ func (s *MultiTenantService) Endpoints() []string {
 func (s *MultiTenantService) Get() interface{} { return s }
 
 func (s *MultiTenantService) RegisterRoutes(g *gin.RouterGroup) {
-	sub := g.Group("/orders")
+	sub := g.Group("/orders", handles.Ensure(s.deps, handles.Options{}))
 
 	sub.GET("/:tenant", s.listOrdersByTenant)
 	sub.POST("/:tenant", s.createOrder)
@@ -77,32 +107,55 @@ func (s *MultiTenantService) RegisterRoutes(g *gin.RouterGroup) {
 
 // listOrdersByTenant godoc
 // @Summary List orders by tenant
-// @Description Retrieve all orders from a specific tenant's database
+// @Description Retrieve a page of orders from a specific tenant's database
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param tenant path string true "Tenant identifier"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page" default(10)
+// @Param sort query string false "created, total_price, quantity or status" default(created)
+// @Param order query string false "asc or desc" default(desc)
 // @Success 200 {object} response.Response "Orders retrieved from tenant database"
+// @Failure 400 {object} response.Response "Invalid pagination or sort"
 // @Failure 404 {object} response.Response "Tenant database not found"
 // @Failure 500 {object} response.Response "Failed to query tenant database"
 // @Router /orders/{tenant} [get]
 func (s *MultiTenantService) listOrdersByTenant(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 
-	dbConn, exists := s.postgresConnectionManager.GetConnection(tenant)
-	if !exists {
+	dbConn, err := handles.TenantDB(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found or not connected", tenant))
 		return
 	}
 
+	var page response.PaginationRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		response.BadRequest(c, "Invalid pagination parameters")
+		return
+	}
+	orderBy, err := orderSort.OrderBy(page.Sort, page.Order)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	var orders []MultiTenantOrder
-	result := dbConn.ORM.Where("tenant_id = ?", tenant).Order("created_at DESC").Find(&orders)
+	var total int64
+	query := dbConn.ORM.WithContext(c.Request.Context()).Model(&MultiTenantOrder{}).Where("tenant_id = ?", tenant).Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		response.InternalServerError(c, fmt.Sprintf("Failed to query tenant '%s' database: %v", tenant, err))
+		return
+	}
+	result := query.Order(orderBy).Limit(page.GetPerPage()).Offset(page.GetOffset()).Find(&orders)
 	if result.Error != nil {
 		response.InternalServerError(c, fmt.Sprintf("Failed to query tenant '%s' database: %v", tenant, result.Error))
 		return
 	}
 
-	response.Success(c, orders, fmt.Sprintf("Orders retrieved from tenant '%s' database", tenant))
+	response.SuccessWithMeta(c, orders, response.CalculateMeta(page.GetPage(), page.GetPerPage(), total),
+		fmt.Sprintf("Orders retrieved from tenant '%s' database", tenant))
 }
 
 // createOrder godoc
@@ -119,10 +172,10 @@ func (s *MultiTenantService) listOrdersByTenant(c *gin.Context) {
 // @Failure 500 {object} response.Response "Failed to create order"
 // @Router /orders/{tenant} [post]
 func (s *MultiTenantService) createOrder(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 
-	dbConn, exists := s.postgresConnectionManager.GetConnection(tenant)
-	if !exists {
+	dbConn, err := handles.TenantDB(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found or not connected", tenant))
 		return
 	}
@@ -159,7 +212,7 @@ func (s *MultiTenantService) createOrder(c *gin.Context) {
 // @Failure 500 {object} response.Response "Failed to query tenant database"
 // @Router /orders/{tenant}/{id} [get]
 func (s *MultiTenantService) getOrderByTenant(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -167,8 +220,8 @@ func (s *MultiTenantService) getOrderByTenant(c *gin.Context) {
 		return
 	}
 
-	dbConn, exists := s.postgresConnectionManager.GetConnection(tenant)
-	if !exists {
+	dbConn, err := handles.TenantDB(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found or not connected", tenant))
 		return
 	}
@@ -202,7 +255,7 @@ func (s *MultiTenantService) getOrderByTenant(c *gin.Context) {
 // @Failure 500 {object} response.Response "Failed to update order"
 // @Router /orders/{tenant}/{id} [put]
 func (s *MultiTenantService) updateOrder(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -210,8 +263,8 @@ func (s *MultiTenantService) updateOrder(c *gin.Context) {
 		return
 	}
 
-	dbConn, exists := s.postgresConnectionManager.GetConnection(tenant)
-	if !exists {
+	dbConn, err := handles.TenantDB(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found or not connected", tenant))
 		return
 	}
@@ -278,7 +331,7 @@ func (s *MultiTenantService) updateOrder(c *gin.Context) {
 // @Failure 500 {object} response.Response "Failed to delete order"
 // @Router /orders/{tenant}/{id} [delete]
 func (s *MultiTenantService) deleteOrder(c *gin.Context) {
-	tenant := c.Param("tenant")
+	tenant := handles.Tenant(c)
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -286,8 +339,8 @@ func (s *MultiTenantService) deleteOrder(c *gin.Context) {
 		return
 	}
 
-	dbConn, exists := s.postgresConnectionManager.GetConnection(tenant)
-	if !exists {
+	dbConn, err := handles.TenantDB(c)
+	if err != nil {
 		response.NotFound(c, fmt.Sprintf("Tenant database '%s' not found or not connected", tenant))
 		return
 	}
@@ -315,11 +368,13 @@ func init() {
 			return nil
 		}
 
-		postgresConnectionManager, ok := registry.GetTyped[infrastructure.PostgresConnectionManager](deps, "postgres")
+		postgresConnectionManager, ok := registry.GetTyped[*infrastructure.PostgresConnectionManager](deps, "postgres")
 		if !helper.RequireDependency("PostgresConnectionManager", ok) {
 			return nil
 		}
 
-		return NewMultiTenantService(&postgresConnectionManager, true, logger)
-	})
+		return NewMultiTenantService(postgresConnectionManager, deps, true, logger)
+	}, "postgres")
+	// Every tenant database has the orders table
+	models.Register("multi_tenant_service", models.AllConnections, &MultiTenantOrder{})
 }
@@ -2,12 +2,14 @@ package modules
 
 import (
 	"stackyrd/config"
+	"stackyrd/pkg/graphql"
 	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
 	"stackyrd/pkg/registry"
 	"stackyrd/pkg/response"
 
 	"github.com/gin-gonic/gin"
+	gql "github.com/graphql-go/graphql"
 )
 
 type ProductsService struct {
@@ -68,6 +70,26 @@ func (s *ProductsService) getProducts(c *gin.Context) {
 	response.Success(c, products, "Products retrieved successfully")
 }
 
+// productType is the GraphQL type of ProductItem.
+var productType = gql.NewObject(gql.ObjectConfig{
+	Name: "Product",
+	Fields: gql.Fields{
+		"id":    {Type: gql.NewNonNull(gql.Int)},
+		"name":  {Type: gql.NewNonNull(gql.String)},
+		"price": {Type: gql.NewNonNull(gql.Float)},
+	},
+})
+
+// RegisterGraphQL exposes the catalogue as the products query.
+func (s *ProductsService) RegisterGraphQL(schema *graphql.Schema) {
+	schema.Query("products", &gql.Field{
+		Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(productType))),
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return products, nil
+		},
+	})
+}
+
 // Auto-registration function - called when package is imported
 func init() {
 	registry.RegisterService("products_service", func(config *config.Config, logger *logger.Logger, deps *registry.Dependencies) interfaces.Service {
@@ -1,12 +1,15 @@
 package modules
 
 import (
+	"context"
+	"fmt"
 	"strconv"
 
 	"stackyrd/config"
 	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/models"
 	"stackyrd/pkg/registry"
 	"stackyrd/pkg/request"
 	"stackyrd/pkg/response"
@@ -29,11 +32,6 @@ type TasksService struct {
 }
 
 func NewTasksService(db *infrastructure.PostgresManager, enabled bool, logger *logger.Logger) *TasksService {
-	if enabled && db != nil && db.ORM != nil {
-		if err := db.ORM.AutoMigrate(&Task{}); err != nil {
-			logger.Error("Error migrating Task model", err)
-		}
-	}
 	return &TasksService{
 		db:      db,
 		logger:  logger,
@@ -50,6 +48,14 @@ func (s *TasksService) Enabled() bool {
 
 func (s *TasksService) Get() interface{} { return s }
 
+// HealthCheck pings the tasks database.
+func (s *TasksService) HealthCheck(ctx context.Context) error {
+	if s.db == nil || s.db.DB == nil {
+		return fmt.Errorf("postgres is not connected")
+	}
+	return s.db.DB.PingContext(ctx)
+}
+
 func (s *TasksService) Endpoints() []string { return []string{"/tasks"} }
 
 func (s *TasksService) RegisterRoutes(g *gin.RouterGroup) {
@@ -182,5 +188,6 @@ func init() {
 		}
 
 		return NewTasksService(&postgresManager, true, logger)
-	})
+	}, "postgres")
+	models.Register("tasks_service", models.DefaultConnection, &Task{})
 }
@@ -1,23 +1,41 @@
 package modules
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"stackyrd/config"
+	"stackyrd/pkg/graphql"
+	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/interfaces"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/photos"
 	"stackyrd/pkg/registry"
 	"stackyrd/pkg/request"
 	"stackyrd/pkg/response"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	gql "github.com/graphql-go/graphql"
 )
 
 type UsersService struct {
 	enabled bool
 	logger  *logger.Logger
+	photos  *photos.Store
+
+	// Orphaned photo cleanup, run between Start and Stop
+	cleanupInterval time.Duration
+	cleanupGrace    time.Duration
+	stopCleanup     context.CancelFunc
+	cleanupDone     chan struct{}
 }
 
 type User struct {
@@ -27,6 +45,7 @@ type User struct {
 	Phone    string `json:"phone" validate:"phone"`
 	Username string `json:"username" validate:"username"`
 	Age      int    `json:"age" validate:"gte=0,lte=130"`
+	Photo    string `json:"photo,omitempty"` // object name, set by the photo endpoints
 }
 
 func NewUsersService(enabled bool, logger *logger.Logger) *UsersService {
@@ -52,6 +71,44 @@ func (s *UsersService) Endpoints() []string {
 	return []string{
 		"/users",
 		"/users/:id",
+		"/users/:id/photo",
+	}
+}
+
+// SetPhotoStore enables the photo endpoints.
+func (s *UsersService) SetPhotoStore(store *photos.Store) {
+	s.photos = store
+}
+
+// SetPhotoCleanup makes Start run CleanupPhotos every interval, removing
+// photos older than grace.
+func (s *UsersService) SetPhotoCleanup(interval, grace time.Duration) {
+	s.cleanupInterval, s.cleanupGrace = interval, grace
+}
+
+// Start starts the orphaned photo cleanup, when configured.
+func (s *UsersService) Start(ctx context.Context) error {
+	if s.photos == nil || s.cleanupInterval <= 0 {
+		return nil
+	}
+	cleanupCtx, cancel := context.WithCancel(context.Background())
+	s.stopCleanup, s.cleanupDone = cancel, make(chan struct{})
+	go s.runPhotoCleanup(cleanupCtx, s.cleanupDone)
+	return nil
+}
+
+// Stop stops the photo cleanup, waiting for a run in progress until ctx
+// is done.
+func (s *UsersService) Stop(ctx context.Context) error {
+	if s.stopCleanup == nil {
+		return nil
+	}
+	s.stopCleanup()
+	select {
+	case <-s.cleanupDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("photo cleanup still running: %w", ctx.Err())
 	}
 }
 
@@ -110,7 +167,32 @@ func (s *UsersService) RegisterRoutes(g *gin.RouterGroup) {
 		// @Router /users/{id} [put]
 		sub.PUT("/:id", s.updateUser)
 
+		// @Summary Upload user photo
+		// @Description Replace the photo of a user. The photo is sent as the "photo" field of a multipart form or as the request body; its type is detected from the data.
+		// @Tags users
+		// @Accept multipart/form-data
+		// @Produce json
+		// @Param id path int true "User ID"
+		// @Param photo formData file true "Photo"
+		// @Success 200 {object} response.Response{data=photos.Photo} "Photo uploaded"
+		// @Failure 404 {object} response.Response "User not found"
+		// @Failure 413 {object} response.Response "Photo too large"
+		// @Failure 415 {object} response.Response "Unsupported photo type"
+		// @Router /users/{id}/photo [put]
+		sub.PUT("/:id/photo", s.uploadPhoto)
+
+		// @Summary Get user photo
+		// @Description Get the photo of a user
+		// @Tags users
+		// @Produce image/jpeg,image/png,image/webp
+		// @Param id path int true "User ID"
+		// @Success 200 {file} binary "Photo"
+		// @Failure 404 {object} response.Response "No photo"
+		// @Router /users/{id}/photo [get]
+		sub.GET("/:id/photo", s.getPhoto)
+
 		// DELETE is blocked by PermissionCheck middleware
+		sub.DELETE("/:id/photo", s.deletePhoto)
 	}
 }
 
@@ -187,6 +269,9 @@ func (s *UsersService) createUser(c *gin.Context) {
 		return
 	}
 
+	// Photos are uploaded through their own endpoint
+	user.Photo = ""
+
 	// Assign new ID
 	usersMu.Lock()
 	user.ID = len(usersList) + 1
@@ -215,6 +300,7 @@ func (s *UsersService) updateUser(c *gin.Context) {
 	for i, u := range usersList {
 		if u.ID == id {
 			user.ID = id
+			user.Photo = u.Photo // changed through the photo endpoints only
 			usersList[i] = user
 			usersIdx[id] = &usersList[i]
 			response.Success(c, user, "User updated successfully")
@@ -225,11 +311,230 @@ func (s *UsersService) updateUser(c *gin.Context) {
 	response.NotFound(c, "User not found")
 }
 
+// photoStore returns the photo store or writes a 404 when photos are not
+// configured.
+func (s *UsersService) photoStore(c *gin.Context) (*photos.Store, bool) {
+	if s.photos == nil {
+		response.Error(c, http.StatusNotFound, "PHOTOS_UNAVAILABLE", "Photo storage is not configured")
+		return nil, false
+	}
+	return s.photos, true
+}
+
+func (s *UsersService) uploadPhoto(c *gin.Context) {
+	store, ok := s.photoStore(c)
+	if !ok {
+		return
+	}
+	id, _ := strconv.Atoi(c.Param("id"))
+	usersMu.RLock()
+	_, exists := usersIdx[id]
+	usersMu.RUnlock()
+	if !exists {
+		response.NotFound(c, "User not found")
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("photo")
+		if err != nil {
+			response.BadRequest(c, "The photo form field is required")
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	photo, err := store.Upload(c.Request.Context(), strconv.Itoa(id), body)
+	if err != nil {
+		switch {
+		case errors.Is(err, photos.ErrTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "PHOTO_TOO_LARGE", err.Error())
+		case errors.Is(err, photos.ErrUnsupportedType):
+			response.Error(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_PHOTO_TYPE", err.Error())
+		case errors.Is(err, photos.ErrInvalidImage):
+			response.BadRequest(c, err.Error())
+		default:
+			s.logger.Error("Failed to store user photo", err, "user_id", id)
+			response.InternalServerError(c, "Failed to store photo")
+		}
+		return
+	}
+
+	usersMu.Lock()
+	if u, ok := usersIdx[id]; ok {
+		u.Photo = photo.Name
+	}
+	usersMu.Unlock()
+	response.Success(c, photo, "Photo uploaded successfully")
+}
+
+func (s *UsersService) getPhoto(c *gin.Context) {
+	store, ok := s.photoStore(c)
+	if !ok {
+		return
+	}
+	id, _ := strconv.Atoi(c.Param("id"))
+	usersMu.RLock()
+	name := ""
+	if u, ok := usersIdx[id]; ok {
+		name = u.Photo
+	}
+	usersMu.RUnlock()
+	if name == "" {
+		response.NotFound(c, "User has no photo")
+		return
+	}
+
+	rc, info, err := store.Open(c.Request.Context(), name)
+	if errors.Is(err, photos.ErrNotFound) {
+		response.NotFound(c, "User has no photo")
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err.Error())
+		return
+	}
+	defer rc.Close()
+	// A new photo gets a new name, so the ETag of a name never goes stale
+	c.Header("ETag", `"`+name+`"`)
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, rc, nil)
+}
+
+func (s *UsersService) deletePhoto(c *gin.Context) {
+	store, ok := s.photoStore(c)
+	if !ok {
+		return
+	}
+	id, _ := strconv.Atoi(c.Param("id"))
+	usersMu.Lock()
+	u, exists := usersIdx[id]
+	if exists {
+		u.Photo = ""
+	}
+	usersMu.Unlock()
+	if !exists {
+		response.NotFound(c, "User not found")
+		return
+	}
+	if err := store.Delete(c.Request.Context(), strconv.Itoa(id)); err != nil {
+		// The photo is no longer referenced; the cleanup retries
+		s.logger.Warn("Failed to delete user photo", "user_id", id, "error", err)
+	}
+	response.Success(c, nil, "Photo deleted successfully")
+}
+
+// CleanupPhotos removes the photos of deleted users and those left behind
+// by failed uploads once they are older than grace.
+func (s *UsersService) CleanupPhotos(ctx context.Context, grace time.Duration) (int, error) {
+	if s.photos == nil {
+		return 0, nil
+	}
+	return s.photos.Cleanup(ctx, func(owner, name string) bool {
+		id, err := strconv.Atoi(owner)
+		if err != nil {
+			return false
+		}
+		usersMu.RLock()
+		defer usersMu.RUnlock()
+		u, ok := usersIdx[id]
+		return ok && u.Photo == name
+	}, grace)
+}
+
+// runPhotoCleanup runs CleanupPhotos every cleanup interval until ctx is
+// done, then closes done.
+func (s *UsersService) runPhotoCleanup(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		removed, err := s.CleanupPhotos(ctx, s.cleanupGrace)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.logger.Warn("Photo cleanup failed", "error", err, "removed", removed)
+		} else if removed > 0 {
+			s.logger.Info("Removed orphaned photos", "count", removed)
+		}
+	}
+}
+
+// userType is the GraphQL type of User.
+var userType = gql.NewObject(gql.ObjectConfig{
+	Name: "User",
+	Fields: gql.Fields{
+		"id":       {Type: gql.NewNonNull(gql.Int)},
+		"name":     {Type: gql.NewNonNull(gql.String)},
+		"email":    {Type: gql.NewNonNull(gql.String)},
+		"phone":    {Type: gql.String},
+		"username": {Type: gql.String},
+		"age":      {Type: gql.Int},
+		"photo":    {Type: gql.String, Description: "Object name of the photo, if any"},
+	},
+})
+
+// RegisterGraphQL exposes the users as the users and user(id) queries.
+func (s *UsersService) RegisterGraphQL(schema *graphql.Schema) {
+	schema.Query("users", &gql.Field{
+		Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(userType))),
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			usersMu.RLock()
+			defer usersMu.RUnlock()
+			return append([]User(nil), usersList...), nil
+		},
+	})
+	schema.Query("user", &gql.Field{
+		Type: userType,
+		Args: gql.FieldConfigArgument{
+			"id": {Type: gql.NewNonNull(gql.Int)},
+		},
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			id := p.Args["id"].(int)
+			usersMu.RLock()
+			defer usersMu.RUnlock()
+			if u, ok := usersIdx[id]; ok {
+				return *u, nil
+			}
+			return nil, fmt.Errorf("user %d not found", id)
+		},
+	})
+}
+
 // Auto-registration function - called when package is imported
 func init() {
 	// Service registration is handled by the registry package
 	registry.RegisterService("users_service", func(config *config.Config, logger *logger.Logger, deps *registry.Dependencies) interfaces.Service {
-		return NewUsersService(config.Services.IsEnabled("users_service"), logger)
+		service := NewUsersService(config.Services.IsEnabled("users_service"), logger)
+		if !service.Enabled() {
+			return service
+		}
+
+		storage, _ := registry.GetTyped[*infrastructure.StorageManager](deps, "storage")
+		objects, err := photos.Backend(config.Photos, storage)
+		if err != nil {
+			logger.Warn("User photos disabled", "error", err)
+			return service
+		}
+		if _, local := objects.(infrastructure.LocalObjectStorage); local {
+			logger.Warn("User photos are stored locally and lost on redeploy unless photos.local_dir is a persistent volume", "dir", config.Photos.LocalDir)
+		}
+		service.SetPhotoStore(photos.New(objects, config.Photos))
+		service.SetPhotoCleanup(time.Duration(config.Photos.CleanupInterval)*time.Second, time.Duration(config.Photos.CleanupGrace)*time.Second)
+		return service
 	})
 }
 
@@ -0,0 +1,340 @@
+package tenantdata
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/sqlbuilder"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Source is a place tenant data lives. Counts are keyed by a stable path
+// such as "postgres/primary/public.orders".
+type Source interface {
+	Name() string
+	Count(ctx context.Context, tenant string) (map[string]int64, error)
+	Export(ctx context.Context, tenant string, archive *zip.Writer) (map[string]int64, error)
+	Delete(ctx context.Context, tenant string) (map[string]int64, error)
+}
+
+// PostgresSource covers rows whose tenant column matches, in every table of
+// every connection that has that column.
+type PostgresSource struct {
+	Connections map[string]*infrastructure.PostgresManager
+	Column      string
+}
+
+func (s *PostgresSource) Name() string { return "postgres" }
+
+type pgTable struct{ schema, name string }
+
+func (t pgTable) ident() string { return sqlbuilder.Ident(t.schema, t.name) }
+
+func (s *PostgresSource) tables(ctx context.Context, db *infrastructure.PostgresManager) ([]pgTable, error) {
+	rows, err := db.Query(ctx, `SELECT c.table_schema, c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.column_name = $1 AND t.table_type = 'BASE TABLE'
+		AND c.table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY 1, 2`, s.Column)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []pgTable
+	for rows.Next() {
+		var t pgTable
+		if err := rows.Scan(&t.schema, &t.name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// each calls fn for every tenant table, keyed by its count path.
+func (s *PostgresSource) each(ctx context.Context, fn func(key string, db *infrastructure.PostgresManager, t pgTable) error) error {
+	for name, db := range s.Connections {
+		if db == nil || db.DB == nil {
+			continue
+		}
+		tables, err := s.tables(ctx, db)
+		if err != nil {
+			return fmt.Errorf("postgres %s: %w", name, err)
+		}
+		for _, t := range tables {
+			if err := fn(fmt.Sprintf("postgres/%s/%s.%s", name, t.schema, t.name), db, t); err != nil {
+				return fmt.Errorf("postgres %s %s: %w", name, t.ident(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *PostgresSource) Count(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, func(key string, db *infrastructure.PostgresManager, t pgTable) error {
+		var n int64
+		q, args := sqlbuilder.Select().From(t.ident()).Where(sqlbuilder.Ident(s.Column)+" = ?", tenant).CountSQL()
+		if err := db.QueryRow(ctx, q, args...).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			counts[key] = n
+		}
+		return nil
+	})
+	return counts, err
+}
+
+func (s *PostgresSource) Export(ctx context.Context, tenant string, archive *zip.Writer) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, func(key string, db *infrastructure.PostgresManager, t pgTable) error {
+		q, args := sqlbuilder.Select("row_to_json(r)::text").From(t.ident()+" r").Where(sqlbuilder.Ident(s.Column)+" = ?", tenant).SQL()
+		rows, err := db.Query(ctx, q, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var w *bufio.Writer
+		var n int64
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				return err
+			}
+			if w == nil {
+				f, err := archive.Create(key + ".jsonl")
+				if err != nil {
+					return err
+				}
+				w = bufio.NewWriter(f)
+			}
+			w.WriteString(line)
+			w.WriteByte('\n')
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if w != nil {
+			counts[key] = n
+			return w.Flush()
+		}
+		return nil
+	})
+	return counts, err
+}
+
+func (s *PostgresSource) Delete(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, func(key string, db *infrastructure.PostgresManager, t pgTable) error {
+		n, err := db.Delete(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = $1", t.ident(), sqlbuilder.Ident(s.Column)), tenant)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			counts[key] = n
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// MongoSource covers every document of a connection named after the
+// tenant, and documents whose tenant field matches in other connections.
+type MongoSource struct {
+	Connections map[string]*infrastructure.MongoManager
+	Field       string
+}
+
+func (s *MongoSource) Name() string { return "mongo" }
+
+func (s *MongoSource) each(ctx context.Context, tenant string, fn func(key string, db *infrastructure.MongoManager, collection string, filter bson.M) error) error {
+	for name, db := range s.Connections {
+		if db == nil || db.Database == nil {
+			continue
+		}
+		filter := bson.M{s.Field: tenant}
+		if name == tenant {
+			filter = bson.M{}
+		}
+		collections, err := db.ListCollections(ctx)
+		if err != nil {
+			return fmt.Errorf("mongo %s: %w", name, err)
+		}
+		for _, coll := range collections {
+			if strings.HasPrefix(coll, "system.") {
+				continue
+			}
+			if err := fn(fmt.Sprintf("mongo/%s/%s", name, coll), db, coll, filter); err != nil {
+				return fmt.Errorf("mongo %s.%s: %w", name, coll, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MongoSource) Count(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, tenant, func(key string, db *infrastructure.MongoManager, coll string, filter bson.M) error {
+		n, err := db.CountDocuments(ctx, coll, filter)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			counts[key] = n
+		}
+		return nil
+	})
+	return counts, err
+}
+
+func (s *MongoSource) Export(ctx context.Context, tenant string, archive *zip.Writer) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, tenant, func(key string, db *infrastructure.MongoManager, coll string, filter bson.M) error {
+		cursor, err := db.Find(ctx, coll, filter)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		var w *bufio.Writer
+		var n int64
+		for cursor.Next(ctx) {
+			line, err := bson.MarshalExtJSON(cursor.Current, false, false)
+			if err != nil {
+				return err
+			}
+			if w == nil {
+				f, err := archive.Create(key + ".jsonl")
+				if err != nil {
+					return err
+				}
+				w = bufio.NewWriter(f)
+			}
+			w.Write(line)
+			w.WriteByte('\n')
+			n++
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+		if w != nil {
+			counts[key] = n
+			return w.Flush()
+		}
+		return nil
+	})
+	return counts, err
+}
+
+func (s *MongoSource) Delete(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, tenant, func(key string, db *infrastructure.MongoManager, coll string, filter bson.M) error {
+		res, err := db.DeleteMany(ctx, coll, filter)
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount > 0 {
+			counts[key] = res.DeletedCount
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// StorageSource covers objects under the tenant prefix in every bucket.
+// Previous export archives count as tenant data for deletion but are not
+// exported again.
+type StorageSource struct {
+	Storage       *infrastructure.StorageManager
+	PrefixPattern string // "{tenant}" is replaced with the tenant ID
+	ExportBucket  string
+	ExportPrefix  string
+}
+
+func (s *StorageSource) Name() string { return "storage" }
+
+func (s *StorageSource) prefix(tenant string) string {
+	return strings.ReplaceAll(s.PrefixPattern, "{tenant}", tenant)
+}
+
+// each calls fn for every tenant object.
+func (s *StorageSource) each(ctx context.Context, tenant string, includeExports bool, fn func(key string, bucket *infrastructure.StorageBucket, object string) error) error {
+	type scope struct{ bucket, prefix string }
+	var scopes []scope
+	for _, name := range s.Storage.BucketNames() {
+		scopes = append(scopes, scope{name, s.prefix(tenant)})
+	}
+	if includeExports && s.ExportPrefix != "" {
+		scopes = append(scopes, scope{s.ExportBucket, s.ExportPrefix + tenant + "/"})
+	}
+
+	for _, sc := range scopes {
+		bucket, err := s.Storage.GetBucket(sc.bucket)
+		if err != nil {
+			continue
+		}
+		objects, err := bucket.ListObjects(ctx, sc.prefix, true)
+		if err != nil {
+			return fmt.Errorf("storage %s: %w", bucket.Name, err)
+		}
+		for _, obj := range objects {
+			if err := fn("storage/"+bucket.Name, bucket, obj.Key); err != nil {
+				return fmt.Errorf("storage %s/%s: %w", bucket.Name, obj.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *StorageSource) Count(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, tenant, true, func(key string, _ *infrastructure.StorageBucket, _ string) error {
+		counts[key]++
+		return nil
+	})
+	return counts, err
+}
+
+func (s *StorageSource) Export(ctx context.Context, tenant string, archive *zip.Writer) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, tenant, false, func(key string, bucket *infrastructure.StorageBucket, object string) error {
+		obj, err := bucket.GetObject(ctx, object)
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		f, err := archive.Create(path.Join(key, object))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, obj); err != nil {
+			return err
+		}
+		counts[key]++
+		return nil
+	})
+	return counts, err
+}
+
+func (s *StorageSource) Delete(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	err := s.each(ctx, tenant, true, func(key string, bucket *infrastructure.StorageBucket, object string) error {
+		if err := bucket.DeleteObject(ctx, object); err != nil {
+			return err
+		}
+		counts[key]++
+		return nil
+	})
+	return counts, err
+}
@@ -0,0 +1,433 @@
+// Package tenantdata implements per-tenant data export and verified
+// deletion (GDPR "right to access" and "right to erasure") across Postgres,
+// MongoDB and object storage. Both workflows run as background jobs and
+// leave audit records in the embedded store.
+package tenantdata
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/jobs"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+)
+
+// Job types and audit actions.
+const (
+	JobExport   = "tenant_export"
+	JobDeletion = "tenant_deletion"
+
+	ActionExport         = "export"
+	ActionDeletionDryRun = "deletion_dry_run"
+	ActionDeletion       = "deletion"
+)
+
+const (
+	plansBucket  = "tenant_deletion_plans"
+	auditBucket  = "tenant_audit"
+	exportURLTTL = 24 * time.Hour
+)
+
+var (
+	ErrNoPlan        = errors.New("no deletion dry run found for tenant, run a dry run first")
+	ErrPlanExpired   = errors.New("deletion dry run has expired, run a new dry run")
+	ErrTokenMismatch = errors.New("confirmation token does not match the latest dry run")
+	ErrNoStorage     = errors.New("tenant export requires object storage (storage.enabled)")
+)
+
+// DeletionPlan is the result of a dry run. Its token must be presented to
+// execute the deletion.
+type DeletionPlan struct {
+	Tenant    string           `json:"tenant"`
+	Counts    map[string]int64 `json:"counts"`
+	Total     int64            `json:"total"`
+	Token     string           `json:"token"`
+	CreatedAt time.Time        `json:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// DeletionResult is the outcome of an executed deletion.
+type DeletionResult struct {
+	Tenant    string           `json:"tenant"`
+	Planned   map[string]int64 `json:"planned"`
+	Deleted   map[string]int64 `json:"deleted"`
+	Remaining map[string]int64 `json:"remaining"`
+	Verified  bool             `json:"verified"`
+}
+
+// ExportResult describes an uploaded export archive.
+type ExportResult struct {
+	Tenant string           `json:"tenant"`
+	Bucket string           `json:"bucket"`
+	Object string           `json:"object"`
+	Size   int64            `json:"size"`
+	Counts map[string]int64 `json:"counts"`
+	URL    string           `json:"url,omitempty"`
+}
+
+// AuditRecord is written for every export, dry run and deletion.
+type AuditRecord struct {
+	Time    time.Time              `json:"time"`
+	Tenant  string                 `json:"tenant"`
+	Action  string                 `json:"action"`
+	Actor   string                 `json:"actor"`
+	JobID   string                 `json:"job_id"`
+	Success bool                   `json:"success"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Service orchestrates tenant exports and deletions.
+type Service struct {
+	config  config.TenantDataConfig
+	sources []Source
+	storage *infrastructure.StorageManager
+	jobs    *jobs.Manager
+	store   *infrastructure.EmbeddedStore
+	logger  *logger.Logger
+}
+
+// NewService creates the service. store is required for plans and audit
+// records; storage is only needed for exports.
+func NewService(cfg config.TenantDataConfig, sources []Source, storage *infrastructure.StorageManager, jobManager *jobs.Manager, store *infrastructure.EmbeddedStore, l *logger.Logger) (*Service, error) {
+	if store == nil {
+		return nil, fmt.Errorf("tenant data workflows require the embedded store (store.enabled)")
+	}
+	if jobManager == nil {
+		return nil, fmt.Errorf("tenant data workflows require the job manager")
+	}
+	if cfg.PlanTTL <= 0 {
+		cfg.PlanTTL = 3600
+	}
+	return &Service{
+		config:  cfg,
+		sources: sources,
+		storage: storage,
+		jobs:    jobManager,
+		store:   store,
+		logger:  l,
+	}, nil
+}
+
+// SourcesFromDependencies builds sources for every connected data store.
+func SourcesFromDependencies(cfg config.TenantDataConfig, deps *registry.Dependencies) []Source {
+	var sources []Source
+
+	switch pg := getDep(deps, "postgres").(type) {
+	case *infrastructure.PostgresConnectionManager:
+		sources = append(sources, &PostgresSource{Connections: pg.GetAllConnections(), Column: cfg.TenantColumn})
+	case *infrastructure.PostgresManager:
+		sources = append(sources, &PostgresSource{Connections: map[string]*infrastructure.PostgresManager{"default": pg}, Column: cfg.TenantColumn})
+	}
+
+	switch mg := getDep(deps, "mongo").(type) {
+	case *infrastructure.MongoConnectionManager:
+		sources = append(sources, &MongoSource{Connections: mg.GetAllConnections(), Field: cfg.TenantColumn})
+	case *infrastructure.MongoManager:
+		sources = append(sources, &MongoSource{Connections: map[string]*infrastructure.MongoManager{"default": mg}, Field: cfg.TenantColumn})
+	}
+
+	if storage, ok := registry.GetTyped[*infrastructure.StorageManager](deps, "storage"); ok {
+		sources = append(sources, &StorageSource{
+			Storage:       storage,
+			PrefixPattern: cfg.ObjectPrefix,
+			ExportBucket:  cfg.ExportBucket,
+			ExportPrefix:  cfg.ExportPrefix,
+		})
+	}
+	return sources
+}
+
+func getDep(deps *registry.Dependencies, name string) interface{} {
+	dep, _ := deps.Get(name)
+	return dep
+}
+
+// Count returns the amount of tenant data per location.
+func (s *Service) Count(ctx context.Context, tenant string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, src := range s.sources {
+		c, err := src.Count(ctx, tenant)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c {
+			counts[k] += v
+		}
+	}
+	return counts, nil
+}
+
+// WriteArchive writes all tenant data as a zip archive to w, with a
+// manifest.json listing the exported counts.
+func WriteArchive(ctx context.Context, tenant string, sources []Source, w io.Writer) (map[string]int64, error) {
+	archive := zip.NewWriter(w)
+	counts := make(map[string]int64)
+	for _, src := range sources {
+		c, err := src.Export(ctx, tenant, archive)
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+		for k, v := range c {
+			counts[k] += v
+		}
+	}
+
+	manifest, err := archive.Create("manifest.json")
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	enc := json.NewEncoder(manifest)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(map[string]interface{}{
+		"tenant":      tenant,
+		"exported_at": time.Now().UTC(),
+		"counts":      counts,
+	}); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return counts, archive.Close()
+}
+
+// StartExport queues an export of all tenant data to object storage.
+func (s *Service) StartExport(tenant, actor string) (jobs.Job, error) {
+	if s.storage == nil {
+		return jobs.Job{}, ErrNoStorage
+	}
+	params := map[string]string{"tenant": tenant, "actor": actor}
+	return s.jobs.Submit(JobExport, params, func(ctx context.Context, report func(string)) (interface{}, error) {
+		result, err := s.export(ctx, tenant, report)
+		s.audit(AuditRecord{Tenant: tenant, Action: ActionExport, Actor: actor, JobID: jobs.IDFromContext(ctx)}, result, err)
+		return result, err
+	}), nil
+}
+
+func (s *Service) export(ctx context.Context, tenant string, report func(string)) (*ExportResult, error) {
+	bucket, err := s.storage.GetBucket(s.config.ExportBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "tenant-export-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	report("collecting data")
+	counts, err := WriteArchive(ctx, tenant, s.sources, tmp)
+	if err != nil {
+		return nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	report("uploading archive")
+	object := fmt.Sprintf("%s%s/%s.zip", s.config.ExportPrefix, tenant, time.Now().UTC().Format("20060102-150405"))
+	if _, err := bucket.UploadFile(ctx, object, tmp, size, "application/zip"); err != nil {
+		return nil, fmt.Errorf("failed to upload archive: %w", err)
+	}
+
+	result := &ExportResult{Tenant: tenant, Bucket: bucket.Name, Object: object, Size: size, Counts: counts}
+	if u, err := bucket.PresignedURL(ctx, object, exportURLTTL); err == nil {
+		result.URL = u.String()
+	}
+	return result, nil
+}
+
+// StartDryRun queues a dry run that counts tenant data and stores a
+// deletion plan whose token confirms the deletion.
+func (s *Service) StartDryRun(tenant, actor string) jobs.Job {
+	params := map[string]string{"tenant": tenant, "actor": actor, "dry_run": "true"}
+	return s.jobs.Submit(JobDeletion, params, func(ctx context.Context, report func(string)) (interface{}, error) {
+		report("counting tenant data")
+		plan, err := s.plan(ctx, tenant)
+		s.audit(AuditRecord{Tenant: tenant, Action: ActionDeletionDryRun, Actor: actor, JobID: jobs.IDFromContext(ctx)}, plan, err)
+		return plan, err
+	})
+}
+
+func (s *Service) plan(ctx context.Context, tenant string) (*DeletionPlan, error) {
+	counts, err := s.Count(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	plan := &DeletionPlan{
+		Tenant:    tenant,
+		Counts:    counts,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(s.config.PlanTTL) * time.Second),
+	}
+	for _, n := range counts {
+		plan.Total += n
+	}
+	plan.Token = planToken(plan)
+	if err := s.store.PutJSON(plansBucket, tenant, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planToken derives the confirmation token from the plan contents.
+func planToken(plan *DeletionPlan) string {
+	keys := make([]string, 0, len(plan.Counts))
+	for k := range plan.Counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d", plan.Tenant, plan.CreatedAt.UnixNano())
+	for _, k := range keys {
+		fmt.Fprintf(h, "|%s=%d", k, plan.Counts[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Plan returns the latest dry run for a tenant.
+func (s *Service) Plan(tenant string) (*DeletionPlan, bool, error) {
+	var plan DeletionPlan
+	found, err := s.store.GetJSON(plansBucket, tenant, &plan)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return &plan, true, nil
+}
+
+// StartDeletion verifies token against the latest dry run and queues the
+// deletion. After deleting, data is counted again and the job fails unless
+// nothing remains.
+func (s *Service) StartDeletion(tenant, token, actor string) (jobs.Job, error) {
+	plan, found, err := s.Plan(tenant)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	if !found {
+		return jobs.Job{}, ErrNoPlan
+	}
+	if time.Now().After(plan.ExpiresAt) {
+		return jobs.Job{}, ErrPlanExpired
+	}
+	if token != plan.Token {
+		return jobs.Job{}, ErrTokenMismatch
+	}
+	// A token is good for one deletion only
+	if err := s.store.Delete(plansBucket, tenant); err != nil {
+		return jobs.Job{}, err
+	}
+
+	params := map[string]string{"tenant": tenant, "actor": actor, "dry_run": "false"}
+	return s.jobs.Submit(JobDeletion, params, func(ctx context.Context, report func(string)) (interface{}, error) {
+		result, err := s.delete(ctx, plan, report)
+		s.audit(AuditRecord{Tenant: tenant, Action: ActionDeletion, Actor: actor, JobID: jobs.IDFromContext(ctx)}, result, err)
+		return result, err
+	}), nil
+}
+
+func (s *Service) delete(ctx context.Context, plan *DeletionPlan, report func(string)) (*DeletionResult, error) {
+	result := &DeletionResult{Tenant: plan.Tenant, Planned: plan.Counts, Deleted: make(map[string]int64)}
+	for _, src := range s.sources {
+		report("deleting from " + src.Name())
+		deleted, err := src.Delete(ctx, plan.Tenant)
+		for k, v := range deleted {
+			result.Deleted[k] += v
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	report("verifying deletion")
+	remaining, err := s.Count(ctx, plan.Tenant)
+	if err != nil {
+		return result, fmt.Errorf("failed to verify deletion: %w", err)
+	}
+	result.Remaining = remaining
+	result.Verified = len(remaining) == 0
+	if !result.Verified {
+		return result, fmt.Errorf("tenant data remains after deletion in %d locations", len(remaining))
+	}
+	return result, nil
+}
+
+// audit appends an audit record; failures are logged, never returned, so
+// they cannot mask the outcome of the workflow itself.
+func (s *Service) audit(record AuditRecord, result interface{}, err error) {
+	record.Time = time.Now()
+	record.Success = err == nil
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if result != nil {
+		raw, _ := json.Marshal(result)
+		json.Unmarshal(raw, &record.Details)
+	}
+	raw, mErr := json.Marshal(record)
+	if mErr == nil {
+		_, mErr = s.store.Append(auditBucket, raw)
+	}
+	if mErr != nil {
+		s.logger.Error("Failed to write tenant audit record", mErr, "tenant", record.Tenant, "action", record.Action)
+		return
+	}
+	s.logger.Info("Tenant data workflow finished", "tenant", record.Tenant, "action", record.Action, "actor", record.Actor, "success", record.Success)
+}
+
+// Audit returns audit records for a tenant (all tenants when empty),
+// newest first.
+func (s *Service) Audit(tenant string, limit int) ([]AuditRecord, error) {
+	var records []AuditRecord
+	err := s.store.ForEachPrefix(auditBucket, "", func(_, value []byte) error {
+		var r AuditRecord
+		if err := json.Unmarshal(value, &r); err != nil {
+			return nil
+		}
+		if tenant == "" || r.Tenant == tenant {
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// GetStatus summarises the configured sources.
+func (s *Service) GetStatus() map[string]interface{} {
+	names := make([]string, 0, len(s.sources))
+	for _, src := range s.sources {
+		names = append(names, src.Name())
+	}
+	return map[string]interface{}{
+		"sources":        names,
+		"export_enabled": s.storage != nil,
+	}
+}
@@ -0,0 +1,490 @@
+// Package accounts keeps the monitoring accounts that log in with a
+// password: invitations with one-time setup tokens, disabled accounts,
+// forced password resets and each account's last login and activity. The
+// accounts live in the embedded store when available and in memory
+// otherwise.
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrNotFound           = errors.New("account not found")
+	ErrExists             = errors.New("account already exists")
+	ErrInvalid            = errors.New("invalid account")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrDisabled           = errors.New("account is disabled")
+	ErrResetRequired      = errors.New("password reset required")
+	ErrInvalidToken       = errors.New("invalid or expired setup token")
+	ErrWeakPassword       = errors.New("password is too weak")
+	ErrRevoked            = errors.New("token was revoked")
+)
+
+// activityInterval is how often the last activity of an account is written
+// at most, so busy dashboards do not write on every request.
+const activityInterval = time.Minute
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// Account is a monitoring account as listed to admins.
+type Account struct {
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	Role              string     `json:"role"`
+	Disabled          bool       `json:"disabled"`
+	MustResetPassword bool       `json:"must_reset_password"`
+	InvitePending     bool       `json:"invite_pending"`
+	InviteExpires     *time.Time `json:"invite_expires,omitempty"`
+	CreatedBy         string     `json:"created_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+	DisabledBy        string     `json:"disabled_by,omitempty"`
+	DisabledAt        *time.Time `json:"disabled_at,omitempty"`
+	LastLoginAt       *time.Time `json:"last_login_at,omitempty"`
+	LastActivityAt    *time.Time `json:"last_activity_at,omitempty"`
+	LastActivityIP    string     `json:"last_activity_ip,omitempty"`
+	// TokenVersion changes with the credentials (password change, new
+	// invitation, disabling), revoking the login tokens issued before
+	TokenVersion int `json:"token_version"`
+}
+
+// record is an account as stored, with its secrets.
+type record struct {
+	Account
+	PasswordHash string `json:"password_hash,omitempty"`
+	InviteHash   string `json:"invite_hash,omitempty"` // sha256 of the setup token
+}
+
+// NewAccount is an account to create. Without a password the account is
+// invited and sets its password through the setup link; with one, the
+// password must be changed on first login.
+type NewAccount struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Password string `json:"password,omitempty"`
+}
+
+// Invitation is a one-time setup token of an account.
+type Invitation struct {
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Token    string    `json:"-"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Mailer sends invitation emails.
+type Mailer interface {
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+
+// KV is the persistence the directory needs; the embedded store
+// implements it.
+type KV interface {
+	PutJSON(bucket, key string, v interface{}) error
+	GetJSON(bucket, key string, v interface{}) (bool, error)
+	ForEachPrefix(bucket, prefix string, fn func(key, value []byte) error) error
+}
+
+const accountsBucket = "monitoring_accounts"
+
+// Options configure a Directory.
+type Options struct {
+	InviteTTL         time.Duration
+	MinPasswordLength int
+	ValidRole         func(role string) bool
+}
+
+// Directory stores the accounts.
+type Directory struct {
+	kv   KV
+	opts Options
+
+	mu       sync.Mutex
+	accounts map[string]*record
+}
+
+// New creates a directory persisted in kv, which may be nil, and loads the
+// stored accounts.
+func New(kv KV, opts Options) (*Directory, error) {
+	if opts.InviteTTL <= 0 {
+		opts.InviteTTL = 72 * time.Hour
+	}
+	if opts.MinPasswordLength <= 0 {
+		opts.MinPasswordLength = 12
+	}
+	if opts.ValidRole == nil {
+		opts.ValidRole = func(string) bool { return true }
+	}
+	d := &Directory{kv: kv, opts: opts, accounts: make(map[string]*record)}
+	if kv != nil {
+		err := kv.ForEachPrefix(accountsBucket, "", func(_, value []byte) error {
+			var r record
+			if err := json.Unmarshal(value, &r); err != nil {
+				return err
+			}
+			d.accounts[r.Username] = &r
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load accounts: %w", err)
+		}
+	}
+	return d, nil
+}
+
+func (d *Directory) save(r *record) error {
+	if d.kv == nil {
+		return nil
+	}
+	return d.kv.PutJSON(accountsBucket, r.Username, r)
+}
+
+// List returns every account by username.
+func (d *Directory) List() []Account {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]Account, 0, len(d.accounts))
+	for _, r := range d.accounts {
+		list = append(list, r.Account)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// Get returns the account of username.
+func (d *Directory) Get(username string) (Account, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.accounts[username]
+	if !ok {
+		return Account{}, false
+	}
+	return r.Account, true
+}
+
+// Create adds an account. Accounts without a password get an invitation.
+func (d *Directory) Create(n NewAccount, by string) (Account, *Invitation, error) {
+	n.Username, n.Email = strings.TrimSpace(n.Username), strings.TrimSpace(n.Email)
+	switch {
+	case !usernamePattern.MatchString(n.Username):
+		return Account{}, nil, fmt.Errorf("%w: username must be 1-64 letters, digits or ._@-", ErrInvalid)
+	case !strings.Contains(n.Email, "@"):
+		return Account{}, nil, fmt.Errorf("%w: email is required", ErrInvalid)
+	case !d.opts.ValidRole(n.Role):
+		return Account{}, nil, fmt.Errorf("%w: unknown role %q", ErrInvalid, n.Role)
+	}
+
+	r := &record{Account: Account{
+		Username:  n.Username,
+		Email:     n.Email,
+		Role:      n.Role,
+		CreatedBy: by,
+		CreatedAt: time.Now(),
+	}}
+	var invite *Invitation
+	if n.Password != "" {
+		hash, err := d.hashPassword(n.Password)
+		if err != nil {
+			return Account{}, nil, err
+		}
+		r.PasswordHash = hash
+		r.MustResetPassword = true
+	} else {
+		invite = d.invite(r)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.accounts[n.Username]; exists {
+		return Account{}, nil, fmt.Errorf("%w: %s", ErrExists, n.Username)
+	}
+	if err := d.save(r); err != nil {
+		return Account{}, nil, err
+	}
+	d.accounts[n.Username] = r
+	return r.Account, invite, nil
+}
+
+// Invite issues a new setup token for username, invalidating the previous
+// one and the password; the account must set a new password to log in.
+func (d *Directory) Invite(username string) (*Invitation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.accounts[username]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, username)
+	}
+	updated := *r
+	updated.PasswordHash = ""
+	updated.TokenVersion++
+	invite := d.invite(&updated)
+	if err := d.save(&updated); err != nil {
+		return nil, err
+	}
+	d.accounts[username] = &updated
+	return invite, nil
+}
+
+// invite sets a new setup token on r.
+func (d *Directory) invite(r *record) *Invitation {
+	token := randomToken()
+	expires := time.Now().Add(d.opts.InviteTTL)
+	r.InviteHash = hashToken(token)
+	r.InvitePending = true
+	r.InviteExpires = &expires
+	return &Invitation{Username: r.Username, Email: r.Email, Token: token, Expires: expires}
+}
+
+// SetDisabled disables or enables username.
+func (d *Directory) SetDisabled(username string, disabled bool, by string) (Account, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.accounts[username]
+	if !ok {
+		return Account{}, fmt.Errorf("%w: %s", ErrNotFound, username)
+	}
+	updated := *r
+	updated.Disabled = disabled
+	updated.DisabledBy, updated.DisabledAt = "", nil
+	if disabled {
+		now := time.Now()
+		updated.DisabledBy, updated.DisabledAt = by, &now
+		updated.TokenVersion++
+	}
+	if err := d.save(&updated); err != nil {
+		return Account{}, err
+	}
+	d.accounts[username] = &updated
+	return updated.Account, nil
+}
+
+// AcceptInvite sets the password of the account token was issued for,
+// which uses up the token.
+func (d *Directory) AcceptInvite(token, password string) (Account, error) {
+	hash := hashToken(token)
+	invited, err := d.invited(hash)
+	if err != nil {
+		return Account{}, err
+	}
+	passwordHash, err := d.hashPassword(password)
+	if err != nil {
+		return Account{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// The token may have been used, reissued or the account disabled
+	// while hashing
+	r, ok := d.accounts[invited.Username]
+	if !ok || r.InviteHash != hash {
+		return Account{}, ErrInvalidToken
+	}
+	if r.Disabled {
+		return Account{}, ErrDisabled
+	}
+	updated := *r
+	updated.PasswordHash = passwordHash
+	updated.InviteHash, updated.InvitePending, updated.InviteExpires = "", false, nil
+	updated.MustResetPassword = false
+	updated.TokenVersion++
+	if err := d.save(&updated); err != nil {
+		return Account{}, err
+	}
+	d.accounts[r.Username] = &updated
+	return updated.Account, nil
+}
+
+// invited returns the account with the unexpired setup token of hash.
+func (d *Directory) invited(hash string) (*record, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range d.accounts {
+		if r.InviteHash == "" || subtle.ConstantTimeCompare([]byte(r.InviteHash), []byte(hash)) != 1 {
+			continue
+		}
+		if r.InviteExpires == nil || time.Now().After(*r.InviteExpires) {
+			return nil, ErrInvalidToken
+		}
+		if r.Disabled {
+			return nil, ErrDisabled
+		}
+		return r, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// Login checks the password of username and records the login. An account
+// that must reset its password gets ErrResetRequired after a correct
+// password, and is not logged in.
+func (d *Directory) Login(username, password string) (Account, error) {
+	checked, err := d.authenticate(username, password)
+	if err != nil {
+		return Account{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, err := d.current(username, checked)
+	if err != nil {
+		return Account{}, err
+	}
+	if r.MustResetPassword {
+		return r.Account, ErrResetRequired
+	}
+	updated := *r
+	now := time.Now()
+	updated.LastLoginAt, updated.LastActivityAt = &now, &now
+	if err := d.save(&updated); err != nil {
+		return Account{}, err
+	}
+	d.accounts[username] = &updated
+	return updated.Account, nil
+}
+
+// ChangePassword replaces the password of username after checking the
+// current one, which completes a required reset.
+func (d *Directory) ChangePassword(username, current, password string) (Account, error) {
+	checked, err := d.authenticate(username, current)
+	if err != nil {
+		return Account{}, err
+	}
+	if current == password {
+		return Account{}, fmt.Errorf("%w: the new password must differ from the current one", ErrWeakPassword)
+	}
+	passwordHash, err := d.hashPassword(password)
+	if err != nil {
+		return Account{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, err := d.current(username, checked)
+	if err != nil {
+		return Account{}, err
+	}
+	updated := *r
+	updated.PasswordHash = passwordHash
+	updated.MustResetPassword = false
+	updated.TokenVersion++
+	if err := d.save(&updated); err != nil {
+		return Account{}, err
+	}
+	d.accounts[username] = &updated
+	return updated.Account, nil
+}
+
+// Session returns the account a login token issued at version names,
+// provided it is enabled and its credentials did not change since.
+func (d *Directory) Session(username string, version int) (Account, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.accounts[username]
+	switch {
+	case !ok:
+		return Account{}, fmt.Errorf("%w: %s", ErrNotFound, username)
+	case r.Disabled:
+		return Account{}, ErrDisabled
+	case r.TokenVersion != version:
+		return Account{}, ErrRevoked
+	}
+	return r.Account, nil
+}
+
+// authenticate checks the password of an enabled account. The bcrypt
+// comparison runs without d.mu, which every authenticated request takes
+// through Touch; callers re-check the record with current before saving.
+func (d *Directory) authenticate(username, password string) (*record, error) {
+	d.mu.Lock()
+	r, ok := d.accounts[username]
+	d.mu.Unlock()
+	if !ok || r.PasswordHash == "" {
+		// Spend the time of a hash comparison either way
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(r.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if r.Disabled {
+		return nil, ErrDisabled
+	}
+	return r, nil
+}
+
+// current returns the record of username as it is now, provided its
+// password is still the one authenticate checked and it was not disabled
+// since; d.mu is held.
+func (d *Directory) current(username string, checked *record) (*record, error) {
+	r, ok := d.accounts[username]
+	if !ok || r.PasswordHash != checked.PasswordHash {
+		return nil, ErrInvalidCredentials
+	}
+	if r.Disabled {
+		return nil, ErrDisabled
+	}
+	return r, nil
+}
+
+// Touch records activity of username from ip, writing it at most once a
+// minute. Unknown usernames are ignored.
+func (d *Directory) Touch(username, ip string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.accounts[username]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if r.LastActivityAt != nil && now.Sub(*r.LastActivityAt) < activityInterval && r.LastActivityIP == ip {
+		return
+	}
+	updated := *r
+	updated.LastActivityAt, updated.LastActivityIP = &now, ip
+	if d.save(&updated) == nil {
+		d.accounts[username] = &updated
+	}
+}
+
+// hashPassword checks the strength of password and hashes it. It is slow
+// by design, so never call it with d.mu held.
+func (d *Directory) hashPassword(password string) (string, error) {
+	if len(password) < d.opts.MinPasswordLength {
+		return "", fmt.Errorf("%w: use at least %d characters", ErrWeakPassword, d.opts.MinPasswordLength)
+	}
+	if len(password) > 72 {
+		return "", fmt.Errorf("%w: use at most 72 characters", ErrWeakPassword)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
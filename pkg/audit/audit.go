@@ -0,0 +1,255 @@
+// Package audit records who did what, and when, through the monitoring API:
+// config edits, workflows, toggles and other actions. Entries are appended
+// to a JSON lines file or to the embedded store and searched newest first.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one audited request.
+type Entry struct {
+	Time       time.Time              `json:"time"`
+	Actor      string                 `json:"actor"`
+	Role       string                 `json:"role,omitempty"`
+	Action     string                 `json:"action"` // method and route, e.g. "PUT /config/section/*path"
+	Path       string                 `json:"path"`
+	Params     map[string]string      `json:"params,omitempty"`
+	Request    interface{}            `json:"request,omitempty"` // JSON body, secrets masked
+	Details    map[string]interface{} `json:"details,omitempty"` // added by the handler
+	Status     int                    `json:"status"`
+	Success    bool                   `json:"success"`
+	ClientIP   string                 `json:"client_ip,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+}
+
+// Query filters the audit log. Zero values match everything.
+type Query struct {
+	Actor   string    // exact actor
+	Action  string    // case-insensitive substring of the action or path
+	Success *bool     // only successful or only failed requests
+	From    time.Time // inclusive
+	To      time.Time // inclusive
+	Offset  int
+	Limit   int
+}
+
+// Matches reports whether an entry satisfies the query filters.
+func (q Query) Matches(e Entry) bool {
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.Success != nil && e.Success != *q.Success {
+		return false
+	}
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && e.Time.After(q.To) {
+		return false
+	}
+	if q.Action == "" {
+		return true
+	}
+	needle := strings.ToLower(q.Action)
+	return strings.Contains(strings.ToLower(e.Action), needle) || strings.Contains(strings.ToLower(e.Path), needle)
+}
+
+// backend persists entries and replays them oldest first.
+type backend interface {
+	append(line []byte) error
+	scan(fn func(line []byte)) error
+	close() error
+}
+
+// Log is an append-only audit log.
+type Log struct {
+	backend backend
+}
+
+// NewFileLog appends entries to path as JSON lines, keeping one rotated
+// backup (path + ".1") once the file grows beyond maxBytes.
+func NewFileLog(path string, maxBytes int64) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Log{backend: &fileBackend{path: path, maxBytes: maxBytes, file: f, size: info.Size()}}, nil
+}
+
+// Store is the persistence the store backend needs; the embedded store
+// implements it.
+type Store interface {
+	Append(bucket string, value []byte) (uint64, error)
+	ForEachPrefix(bucket, prefix string, fn func(key, value []byte) error) error
+}
+
+// Bucket holds the entries of the store backend.
+const Bucket = "monitoring_audit"
+
+// NewStoreLog appends entries to the embedded store.
+func NewStoreLog(store Store) *Log {
+	return &Log{backend: storeBackend{store: store}}
+}
+
+// Record appends an entry, stamping the time when unset.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	return l.backend.append(line)
+}
+
+// Search returns entries matching q, newest first, together with the total
+// number of matches.
+func (l *Log) Search(q Query) ([]Entry, int, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	// Keep only the newest offset+limit matches (in a ring) while scanning
+	// oldest first
+	keep := q.Offset + q.Limit
+	var ring []Entry
+	total := 0
+	err := l.backend.scan(func(line []byte) {
+		var e Entry
+		if json.Unmarshal(line, &e) != nil || !q.Matches(e) {
+			return
+		}
+		if len(ring) < keep {
+			ring = append(ring, e)
+		} else {
+			ring[total%keep] = e
+		}
+		total++
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	page := make([]Entry, 0, q.Limit)
+	for i := q.Offset; i < len(ring) && len(page) < q.Limit; i++ {
+		page = append(page, ring[(total-1-i)%keep])
+	}
+	return page, total, nil
+}
+
+// Close releases the backend; the store backend's store is left open.
+func (l *Log) Close() error {
+	return l.backend.close()
+}
+
+type fileBackend struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64
+}
+
+func (b *fileBackend) append(line []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	line = append(line, '\n')
+	if b.maxBytes > 0 && b.size > 0 && b.size+int64(len(line)) > b.maxBytes {
+		if err := b.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := b.file.Write(line)
+	b.size += int64(n)
+	return err
+}
+
+// rotate moves the current file to the backup slot. Callers hold b.mu.
+func (b *fileBackend) rotate() error {
+	b.file.Close()
+	os.Rename(b.path, b.path+".1")
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		b.file = nil
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	b.file = f
+	b.size = 0
+	return nil
+}
+
+func (b *fileBackend) scan(fn func(line []byte)) error {
+	for _, path := range []string{b.path + ".1", b.path} {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fn(scanner.Bytes())
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *fileBackend) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
+
+type storeBackend struct {
+	store Store
+}
+
+func (b storeBackend) append(line []byte) error {
+	_, err := b.store.Append(Bucket, line)
+	return err
+}
+
+func (b storeBackend) scan(fn func(line []byte)) error {
+	return b.store.ForEachPrefix(Bucket, "", func(_, value []byte) error {
+		fn(value)
+		return nil
+	})
+}
+
+func (b storeBackend) close() error { return nil }
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend names (cache.backend).
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Backend keeps encoded values by key. A ttl of 0 keeps a value until it
+// is evicted or deleted.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// LRU is an in-memory Backend holding at most a fixed number of entries,
+// evicting the least recently used first.
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // front is the most recently used
+	entries    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero for no expiry
+}
+
+// NewLRU creates an LRU backend holding at most maxEntries (at least 1).
+func NewLRU(maxEntries int) *LRU {
+	return &LRU{
+		maxEntries: max(maxEntries, 1),
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements Backend.
+func (l *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		l.remove(el)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set implements Backend.
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		el.Value = &lruEntry{key: key, value: value, expires: expires}
+		l.order.MoveToFront(el)
+		return nil
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.maxEntries {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Backend.
+func (l *LRU) Delete(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		l.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of entries, expired ones included until they are
+// read or evicted.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*lruEntry).key)
+}
+
+// Redis is a Backend keeping values in Redis under a key prefix, shared by
+// every instance.
+type Redis struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedis creates a Redis backend keeping values as "<prefix><key>".
+func NewRedis(client redis.Cmdable, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Get implements Backend.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set implements Backend.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+// Delete implements Backend.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key).Err()
+}
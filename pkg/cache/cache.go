@@ -1,3 +1,6 @@
+// Package cache caches values in memory or Redis: Cache is a plain
+// in-process map with expiry, Store caches typed values in a pluggable
+// Backend (LRU or Redis) with GetOrLoad deduplicating concurrent loads.
 package cache
 
 import (
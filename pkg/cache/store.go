@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Store caches values of type T in a Backend, encoded as JSON so any
+// backend can hold them. Concurrent GetOrLoad calls for the same key share
+// one load.
+type Store[T any] struct {
+	backend Backend
+	ttl     time.Duration
+	loads   singleflight.Group
+
+	hits, misses, loaded, errors atomic.Int64
+}
+
+// NewStore creates a store in backend whose values live ttl unless Set
+// says otherwise.
+func NewStore[T any](backend Backend, ttl time.Duration) *Store[T] {
+	return &Store[T]{backend: backend, ttl: ttl}
+}
+
+// Get returns the value of key; found is false on a miss.
+func (s *Store[T]) Get(ctx context.Context, key string) (value T, found bool, err error) {
+	data, found, err := s.backend.Get(ctx, key)
+	if err != nil {
+		s.errors.Add(1)
+		return value, false, err
+	}
+	if !found {
+		s.misses.Add(1)
+		return value, false, nil
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		// Written by another version of T; treat it as missing
+		s.misses.Add(1)
+		return value, false, nil
+	}
+	s.hits.Add(1)
+	return value, true, nil
+}
+
+// Set stores value for ttl, the store's TTL when none is given.
+func (s *Store[T]) Set(ctx context.Context, key string, value T, ttl ...time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	expiry := s.ttl
+	if len(ttl) > 0 {
+		expiry = ttl[0]
+	}
+	return s.backend.Set(ctx, key, data, expiry)
+}
+
+// Delete removes key.
+func (s *Store[T]) Delete(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, key)
+}
+
+// GetOrLoad returns the value of key, loading and storing it on a miss.
+// Callers missing the same key at once wait for a single load, which is
+// not cancelled when the caller that started it gives up. A backend that
+// fails is bypassed: the value is loaded, and only load errors are
+// returned.
+func (s *Store[T]) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if value, found, _ := s.Get(ctx, key); found {
+		return value, nil
+	}
+
+	loadCtx := context.WithoutCancel(ctx)
+	ch := s.loads.DoChan(key, func() (interface{}, error) {
+		value, err := load(loadCtx)
+		if err != nil {
+			return value, err
+		}
+		s.loaded.Add(1)
+		if err := s.Set(loadCtx, key, value); err != nil {
+			s.errors.Add(1)
+		}
+		return value, nil
+	})
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			var zero T
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	}
+}
+
+// GetStats returns the hits, misses, loads and backend errors of the
+// store.
+func (s *Store[T]) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"hits":   s.hits.Load(),
+		"misses": s.misses.Load(),
+		"loads":  s.loaded.Load(),
+		"errors": s.errors.Load(),
+	}
+}
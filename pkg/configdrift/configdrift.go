@@ -0,0 +1,157 @@
+// Package configdrift notices when the config file no longer matches the
+// configuration the process runs with, e.g. after config.yaml was edited
+// without a restart. Such edits silently take effect on the next restart,
+// so the Watcher compares fingerprints periodically and logs a warning
+// when they differ.
+package configdrift
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+)
+
+// State is the result of a drift check.
+type State struct {
+	CheckedAt       time.Time `json:"checked_at"`
+	FileFingerprint string    `json:"file_fingerprint,omitempty"`
+	FileModified    time.Time `json:"file_modified"`
+	Drifted         bool      `json:"drifted"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// OK reports whether the file could be read.
+func (s State) OK() bool {
+	return s.Error == ""
+}
+
+// Watcher checks the config file periodically against the fingerprint of
+// the running configuration.
+type Watcher struct {
+	path     string
+	running  string
+	interval time.Duration
+	logger   *logger.Logger
+
+	mu   sync.RWMutex
+	last State
+	has  bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a watcher of the config file at path for a process running
+// with the configuration fingerprinted as running, unresolved (see
+// config.LoadedFingerprint); call Start to begin checking.
+func New(path, running string, interval time.Duration, l *logger.Logger) *Watcher {
+	return &Watcher{
+		path:     path,
+		running:  running,
+		interval: interval,
+		logger:   l,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Name returns the display name of the component.
+func (w *Watcher) Name() string {
+	return "Config Drift"
+}
+
+// Running returns the fingerprint of the running configuration.
+func (w *Watcher) Running() string {
+	return w.running
+}
+
+// Start checks the file now and then every interval until Close.
+func (w *Watcher) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			w.Check()
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops periodic checks.
+func (w *Watcher) Close() error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	w.wg.Wait()
+	return nil
+}
+
+// Check reads the file now, compares its fingerprint with the running one
+// and records the result. Secret references are compared as written, so a
+// check never reads Vault or decrypts values.
+func (w *Watcher) Check() State {
+	state := State{CheckedAt: time.Now()}
+	if info, err := os.Stat(w.path); err == nil {
+		state.FileModified = info.ModTime()
+	}
+	cfg, err := config.ReadConfigFile(w.path, config.Unresolved())
+	if err != nil {
+		state.Error = err.Error()
+	} else {
+		state.FileFingerprint = config.Fingerprint(cfg)
+		state.Drifted = state.FileFingerprint != w.running
+	}
+
+	w.mu.Lock()
+	prev, hadPrev := w.last, w.has
+	w.last, w.has = state, true
+	w.mu.Unlock()
+
+	w.logTransition(prev, hadPrev, state)
+	return state
+}
+
+func (w *Watcher) logTransition(prev State, hadPrev bool, cur State) {
+	if w.logger == nil {
+		return
+	}
+	switch {
+	case !cur.OK():
+		if !hadPrev || prev.OK() {
+			w.logger.Warn("Config drift check failed", "path", w.path, "error", cur.Error)
+		}
+	case cur.Drifted:
+		if !hadPrev || !prev.Drifted || prev.FileFingerprint != cur.FileFingerprint {
+			w.logger.Warn("Config file differs from the running configuration; the changes apply on the next restart",
+				"path", w.path, "running", w.running, "file", cur.FileFingerprint)
+		}
+	case hadPrev && prev.Drifted:
+		w.logger.Info("Config file matches the running configuration again", "path", w.path)
+	}
+}
+
+// Last returns the most recent check, if any.
+func (w *Watcher) Last() (State, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.last, w.has
+}
+
+// Status summarizes the watcher for the monitoring API.
+func (w *Watcher) Status() map[string]interface{} {
+	status := map[string]interface{}{
+		"path":             w.path,
+		"interval_seconds": int64(w.interval.Seconds()),
+	}
+	if last, ok := w.Last(); ok {
+		status["last"] = last
+	}
+	return status
+}
@@ -0,0 +1,157 @@
+// Package dns checks resolution of the hostnames the application depends
+// on and provides an in-process DNS cache. The cache reuses resolutions for
+// a fixed TTL regardless of the record TTL and keeps serving the last known
+// addresses while the resolver fails, so short resolver outages do not
+// break new connections.
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dialTimeout matches the dialer of http.DefaultTransport.
+const dialTimeout = 30 * time.Second
+
+// LookupFunc resolves a host to addresses, like net.Resolver.LookupHost.
+type LookupFunc func(ctx context.Context, host string) ([]string, error)
+
+type cacheEntry struct {
+	addrs      []string
+	resolvedAt time.Time
+}
+
+// Cache caches host lookups for TTL and serves entries up to StaleTTL old
+// when a fresh lookup fails.
+type Cache struct {
+	ttl      time.Duration
+	staleTTL time.Duration
+	lookup   LookupFunc
+	now      func() time.Time
+
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+
+	hits, misses, stale, failures atomic.Int64
+}
+
+// NewCache creates a cache over the default resolver.
+func NewCache(ttl, staleTTL time.Duration) *Cache {
+	return &Cache{
+		ttl:      ttl,
+		staleTTL: staleTTL,
+		lookup:   net.DefaultResolver.LookupHost,
+		now:      time.Now,
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+// SetLookup replaces the resolver, e.g. in tests.
+func (c *Cache) SetLookup(lookup LookupFunc) {
+	c.lookup = lookup
+}
+
+// SetClock replaces the clock used for expiry, e.g. in tests.
+func (c *Cache) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// LookupHost returns the addresses of host from the cache or the resolver.
+// IP literals are returned as is.
+func (c *Cache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[host]
+	c.mu.RUnlock()
+	age := c.now().Sub(entry.resolvedAt)
+	if ok && age < c.ttl {
+		c.hits.Add(1)
+		return entry.addrs, nil
+	}
+
+	c.misses.Add(1)
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		c.failures.Add(1)
+		if ok && age < c.staleTTL {
+			c.stale.Add(1)
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = cacheEntry{addrs: addrs, resolvedAt: c.now()}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// DialContext dials addr ("host:port"), resolving host through the cache
+// and trying its addresses in order.
+func (c *Cache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	var errs []error
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Stats reports cache effectiveness.
+func (c *Cache) Stats() map[string]interface{} {
+	c.mu.RLock()
+	size := len(c.entries)
+	c.mu.RUnlock()
+	return map[string]interface{}{
+		"entries":           size,
+		"hits":              c.hits.Load(),
+		"misses":            c.misses.Load(),
+		"stale_served":      c.stale.Load(),
+		"lookup_failures":   c.failures.Load(),
+		"ttl_seconds":       int64(c.ttl.Seconds()),
+		"stale_ttl_seconds": int64(c.staleTTL.Seconds()),
+	}
+}
+
+var installed atomic.Pointer[Cache]
+
+// Install makes c the resolver of DialContext and of http.DefaultTransport.
+func (c *Cache) Install() {
+	installed.Store(c)
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.DialContext = c.DialContext
+	}
+}
+
+// DialContext dials through the installed cache, or directly when no
+// cache is installed. Infrastructure clients use it as their dialer.
+func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c := installed.Load(); c != nil {
+		return c.DialContext(ctx, network, addr)
+	}
+	dialer := net.Dialer{Timeout: dialTimeout}
+	return dialer.DialContext(ctx, network, addr)
+}
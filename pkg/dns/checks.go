@@ -0,0 +1,272 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+)
+
+const checkTimeout = 5 * time.Second
+
+// HostStatus is the resolution history of a host.
+type HostStatus struct {
+	Host        string    `json:"host"`
+	UsedBy      []string  `json:"used_by"`
+	Addresses   []string  `json:"addresses,omitempty"`
+	Resolved    bool      `json:"resolved"`
+	LatencyMS   float64   `json:"latency_ms"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastChecked time.Time `json:"last_checked,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Checks      int64     `json:"checks"`
+	Failures    int64     `json:"failures"`
+}
+
+// Checker resolves a set of hosts periodically, bypassing the cache, and
+// tracks latency and failures per host.
+type Checker struct {
+	lookup   LookupFunc
+	interval time.Duration
+	logger   *logger.Logger
+
+	mu    sync.RWMutex
+	hosts map[string]*HostStatus
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewChecker creates a checker for hosts, which maps each hostname to the
+// config entries using it.
+func NewChecker(hosts map[string][]string, interval time.Duration, l *logger.Logger) *Checker {
+	c := &Checker{
+		lookup:   net.DefaultResolver.LookupHost,
+		interval: interval,
+		logger:   l,
+		hosts:    make(map[string]*HostStatus, len(hosts)),
+		stop:     make(chan struct{}),
+	}
+	for host, usedBy := range hosts {
+		c.hosts[host] = &HostStatus{Host: host, UsedBy: usedBy}
+	}
+	return c
+}
+
+// SetLookup replaces the resolver, e.g. in tests.
+func (c *Checker) SetLookup(lookup LookupFunc) {
+	c.lookup = lookup
+}
+
+// Name returns the display name of the component.
+func (c *Checker) Name() string {
+	return "DNS Checker"
+}
+
+// Start checks all hosts now and then every interval until Close.
+func (c *Checker) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.CheckAll(context.Background())
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops periodic checks.
+func (c *Checker) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.wg.Wait()
+	return nil
+}
+
+// CheckAll resolves every host concurrently.
+func (c *Checker) CheckAll(ctx context.Context) {
+	c.mu.RLock()
+	hosts := make([]string, 0, len(c.hosts))
+	for host := range c.hosts {
+		hosts = append(hosts, host)
+	}
+	c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			c.check(ctx, host)
+		}(host)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) check(ctx context.Context, host string) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := c.lookup(ctx, host)
+	latency := time.Since(start)
+
+	c.mu.Lock()
+	status := c.hosts[host]
+	wasResolved := status.Checks == 0 || status.Resolved
+	status.Checks++
+	status.LastChecked = start
+	status.LatencyMS = float64(latency.Microseconds()) / 1000
+	if err != nil {
+		status.Resolved = false
+		status.Failures++
+		status.LastError = err.Error()
+	} else {
+		sort.Strings(addrs)
+		status.Resolved = true
+		status.Addresses = addrs
+		status.LastError = ""
+		status.LastSuccess = start
+	}
+	c.mu.Unlock()
+
+	if c.logger == nil {
+		return
+	}
+	switch {
+	case err != nil && wasResolved:
+		c.logger.Warn("DNS resolution failed", "host", host, "error", err.Error())
+	case err == nil && !wasResolved:
+		c.logger.Info("DNS resolution recovered", "host", host, "latency_ms", status.LatencyMS)
+	}
+}
+
+// Hosts returns the status of every host, sorted by name.
+func (c *Checker) Hosts() []HostStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]HostStatus, 0, len(c.hosts))
+	for _, status := range c.hosts {
+		out = append(out, *status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// ConfigHosts collects the hostnames of enabled infrastructure, external
+// services and extra, mapped to the config entries using them. IP literals
+// are skipped.
+func ConfigHosts(cfg *config.Config, extra []string) map[string][]string {
+	hosts := make(map[string][]string)
+	add := func(host, usedBy string) {
+		if host == "" || net.ParseIP(host) != nil {
+			return
+		}
+		for _, u := range hosts[host] {
+			if u == usedBy {
+				return
+			}
+		}
+		hosts[host] = append(hosts[host], usedBy)
+	}
+
+	if cfg.Redis.Enabled {
+		add(hostOf(cfg.Redis.Address), "redis")
+	}
+	if cfg.Kafka.Enabled {
+		for _, broker := range cfg.Kafka.Brokers {
+			add(hostOf(broker), "kafka")
+		}
+	}
+	if cfg.NATS.Enabled {
+		for _, u := range strings.Split(cfg.NATS.URL, ",") {
+			add(urlHost(u), "nats")
+		}
+	}
+	if cfg.RabbitMQ.Enabled {
+		add(urlHost(cfg.RabbitMQ.URL), "rabbitmq")
+	}
+	if cfg.PostgresMultiConfig.Enabled {
+		for _, conn := range cfg.PostgresMultiConfig.Connections {
+			if conn.Enabled {
+				add(conn.Host, "postgres."+conn.Name)
+			}
+		}
+	}
+	if cfg.Postgres.Enabled {
+		add(cfg.Postgres.Host, "postgres")
+	}
+	if cfg.MongoMultiConfig.Enabled {
+		for _, conn := range cfg.MongoMultiConfig.Connections {
+			if conn.Enabled {
+				for _, h := range mongoHosts(conn.URI) {
+					add(h, "mongo."+conn.Name)
+				}
+			}
+		}
+	}
+	if cfg.Storage.Enabled {
+		for _, bucket := range cfg.Storage.Buckets {
+			add(hostOf(strings.TrimPrefix(strings.TrimPrefix(bucket.Endpoint, "https://"), "http://")), "storage."+bucket.Name)
+		}
+	}
+	if cfg.Grafana.Enabled {
+		add(urlHost(cfg.Grafana.URL), "grafana")
+	}
+	for _, svc := range cfg.Monitoring.External.Services {
+		add(urlHost(svc.URL), "external."+svc.Name)
+	}
+	for _, host := range extra {
+		add(host, "dns.checks.hosts")
+	}
+	return hosts
+}
+
+// hostOf returns the host of "host:port" or "host".
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func urlHost(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// mongoHosts returns the hosts of a mongodb:// URI, which may list several.
+// For mongodb+srv the SRV name itself is checked.
+func mongoHosts(uri string) []string {
+	rest := uri
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		rest = rest[i+1:]
+	}
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest = rest[:i]
+	}
+	var hosts []string
+	for _, h := range strings.Split(rest, ",") {
+		if h = hostOf(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
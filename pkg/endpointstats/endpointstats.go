@@ -0,0 +1,253 @@
+// Package endpointstats aggregates request count, status classes, latency
+// percentiles and payload sizes per route. Requests are buffered and
+// folded into the per-route figures in batches, so recording stays cheap
+// on the request path.
+package endpointstats
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults of the collector.
+const (
+	DefaultFlushInterval  = 10 * time.Second
+	DefaultLatencySamples = 1000
+	flushBatch            = 1024 // pending requests that force a flush
+)
+
+// MaxTrackedRoutes bounds the number of distinct routes tracked; requests
+// to further routes are attributed to OverflowRoute.
+const (
+	MaxTrackedRoutes = 2000
+	OverflowRoute    = "_other"
+)
+
+// Request is one served request.
+type Request struct {
+	Method        string
+	Route         string // registered path, e.g. /users/:id
+	Status        int
+	Latency       time.Duration
+	RequestBytes  int64
+	ResponseBytes int64
+	Time          time.Time
+}
+
+// RouteStats are the figures of one route since counting started.
+type RouteStats struct {
+	Method           string           `json:"method"`
+	Route            string           `json:"route"`
+	Requests         int64            `json:"requests"`
+	Errors           int64            `json:"errors"` // 5xx responses
+	StatusClasses    map[string]int64 `json:"status_classes"`
+	AvgLatencyMs     float64          `json:"avg_latency_ms"`
+	P50LatencyMs     float64          `json:"p50_latency_ms"`
+	P95LatencyMs     float64          `json:"p95_latency_ms"`
+	P99LatencyMs     float64          `json:"p99_latency_ms"`
+	MaxLatencyMs     float64          `json:"max_latency_ms"`
+	RequestBytes     int64            `json:"request_bytes"`
+	ResponseBytes    int64            `json:"response_bytes"`
+	AvgRequestBytes  float64          `json:"avg_request_bytes"`
+	AvgResponseBytes float64          `json:"avg_response_bytes"`
+	LastSeen         time.Time        `json:"last_seen"`
+}
+
+type route struct {
+	stats     RouteStats
+	latencyMs float64   // sum, for the average
+	samples   []float64 // most recent latencies, a ring of latencySamples
+	next      int
+}
+
+// Collector accumulates per-route figures. It is safe for concurrent use.
+type Collector struct {
+	mu             sync.Mutex
+	pending        []Request
+	lastFlush      time.Time
+	flushInterval  time.Duration
+	latencySamples int
+
+	statsMu sync.Mutex
+	routes  map[string]*route
+	since   time.Time
+}
+
+var defaultCollector = NewCollector(DefaultFlushInterval, DefaultLatencySamples)
+
+// Default returns the process-wide collector fed by the endpoint_stats
+// middleware.
+func Default() *Collector {
+	return defaultCollector
+}
+
+// NewCollector creates an empty collector that folds buffered requests
+// into the figures every flushInterval and keeps the last latencySamples
+// latencies per route for percentiles.
+func NewCollector(flushInterval time.Duration, latencySamples int) *Collector {
+	c := &Collector{routes: make(map[string]*route), since: time.Now(), lastFlush: time.Now()}
+	c.Configure(flushInterval, latencySamples)
+	return c
+}
+
+// Configure changes the flush interval and latency sample size; zero
+// values use the defaults. Routes keep their samples until they refill.
+func (c *Collector) Configure(flushInterval time.Duration, latencySamples int) {
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	if latencySamples <= 0 {
+		latencySamples = DefaultLatencySamples
+	}
+	c.mu.Lock()
+	c.flushInterval = flushInterval
+	c.mu.Unlock()
+	c.statsMu.Lock()
+	c.latencySamples = latencySamples
+	c.statsMu.Unlock()
+}
+
+// Record buffers a request. The buffer is flushed once the flush interval
+// has passed or it is full.
+func (c *Collector) Record(r Request) {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	c.mu.Lock()
+	c.pending = append(c.pending, r)
+	due := len(c.pending) >= flushBatch || r.Time.Sub(c.lastFlush) >= c.flushInterval
+	c.mu.Unlock()
+	if due {
+		c.Flush()
+	}
+}
+
+// Flush folds the buffered requests into the per-route figures.
+func (c *Collector) Flush() {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = nil
+	c.lastFlush = time.Now()
+	c.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	for _, r := range batch {
+		c.add(r)
+	}
+}
+
+// add folds r into its route. Callers hold c.statsMu.
+func (c *Collector) add(r Request) {
+	key := r.Method + " " + r.Route
+	rt, ok := c.routes[key]
+	if !ok {
+		if len(c.routes) >= MaxTrackedRoutes {
+			key = OverflowRoute
+			r.Method, r.Route = "", OverflowRoute
+			rt = c.routes[key]
+		}
+		if rt == nil {
+			rt = &route{stats: RouteStats{Method: r.Method, Route: r.Route, StatusClasses: make(map[string]int64)}}
+			c.routes[key] = rt
+		}
+	}
+
+	s := &rt.stats
+	s.Requests++
+	if r.Status >= 500 {
+		s.Errors++
+	}
+	s.StatusClasses[StatusClass(r.Status)]++
+	ms := float64(r.Latency) / float64(time.Millisecond)
+	rt.latencyMs += ms
+	s.MaxLatencyMs = max(s.MaxLatencyMs, ms)
+	s.RequestBytes += max(r.RequestBytes, 0)
+	s.ResponseBytes += max(r.ResponseBytes, 0)
+	if r.Time.After(s.LastSeen) {
+		s.LastSeen = r.Time
+	}
+
+	if len(rt.samples) < c.latencySamples {
+		rt.samples = append(rt.samples, ms)
+	} else {
+		rt.samples[rt.next%len(rt.samples)] = ms
+		rt.next++
+	}
+}
+
+// StatusClass returns the class of an HTTP status, e.g. "2xx".
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "other"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// Snapshot flushes and returns the figures of every route, sorted by route
+// and method.
+func (c *Collector) Snapshot() []RouteStats {
+	c.Flush()
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	out := make([]RouteStats, 0, len(c.routes))
+	for _, rt := range c.routes {
+		s := rt.stats
+		s.StatusClasses = make(map[string]int64, len(rt.stats.StatusClasses))
+		for class, n := range rt.stats.StatusClasses {
+			s.StatusClasses[class] = n
+		}
+		if s.Requests > 0 {
+			s.AvgLatencyMs = rt.latencyMs / float64(s.Requests)
+			s.AvgRequestBytes = float64(s.RequestBytes) / float64(s.Requests)
+			s.AvgResponseBytes = float64(s.ResponseBytes) / float64(s.Requests)
+		}
+		sorted := slices.Clone(rt.samples)
+		sort.Float64s(sorted)
+		s.P50LatencyMs = percentile(sorted, 0.50)
+		s.P95LatencyMs = percentile(sorted, 0.95)
+		s.P99LatencyMs = percentile(sorted, 0.99)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Route != out[j].Route {
+			return out[i].Route < out[j].Route
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// Since returns when counting started (creation or last Reset).
+func (c *Collector) Since() time.Time {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.since
+}
+
+// Reset clears all figures and buffered requests.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	c.pending = nil
+	c.lastFlush = time.Now()
+	c.mu.Unlock()
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.routes = make(map[string]*route)
+	c.since = time.Now()
+}
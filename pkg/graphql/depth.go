@@ -0,0 +1,73 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+	"github.com/graphql-go/graphql/language/visitor"
+)
+
+// MaxDepthRule is a validation rule rejecting operations whose selections
+// nest deeper than maxDepth fields, following fragments. Introspection
+// fields (__schema, __type) are not counted, so GraphiQL and code
+// generators can load the schema under any limit.
+func MaxDepthRule(maxDepth int) gql.ValidationRuleFn {
+	return func(ctx *gql.ValidationContext) *gql.ValidationRuleInstance {
+		return &gql.ValidationRuleInstance{
+			VisitorOpts: &visitor.VisitorOptions{
+				KindFuncMap: map[string]visitor.NamedVisitFuncs{
+					kinds.OperationDefinition: {
+						Kind: func(p visitor.VisitFuncParams) (string, interface{}) {
+							op, ok := p.Node.(*ast.OperationDefinition)
+							if ok && op.SelectionSet != nil {
+								if depth := selectionDepth(ctx, op.SelectionSet, map[string]bool{}); depth > maxDepth {
+									ctx.ReportError(gqlerrors.NewError(
+										fmt.Sprintf("operation nests %d fields deep, over the maximum depth of %d", depth, maxDepth),
+										[]ast.Node{op}, "", nil, []int{}, nil,
+									))
+								}
+							}
+							return visitor.ActionSkip, nil
+						},
+					},
+				},
+			},
+		}
+	}
+}
+
+// selectionDepth returns the deepest field nesting of set. Unknown and
+// cyclic fragments count as empty; other rules report them.
+func selectionDepth(ctx *gql.ValidationContext, set *ast.SelectionSet, spreading map[string]bool) int {
+	deepest := 0
+	for _, selection := range set.Selections {
+		depth := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			depth = 1
+			if selection.SelectionSet != nil {
+				depth += selectionDepth(ctx, selection.SelectionSet, spreading)
+			}
+		case *ast.InlineFragment:
+			depth = selectionDepth(ctx, selection.SelectionSet, spreading)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := ctx.Fragment(name)
+			if fragment == nil || spreading[name] {
+				continue
+			}
+			spreading[name] = true
+			depth = selectionDepth(ctx, fragment.SelectionSet, spreading)
+			delete(spreading, name)
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
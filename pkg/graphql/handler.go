@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Handler serves schema over HTTP: POST with a JSON Request body, or GET
+// with query, operationName and variables parameters for queries. The
+// correlation ID of the request is returned in extensions.correlation_id
+// and the X-Correlation-ID header, like the REST envelope.
+func Handler(schema *Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationID := response.CorrelationID(c)
+		c.Header("X-Correlation-ID", correlationID)
+		reply := func(status int, res *gql.Result) {
+			res.Extensions = map[string]interface{}{"correlation_id": correlationID}
+			c.JSON(status, res)
+		}
+
+		var req Request
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if vars := c.Query("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					reply(http.StatusBadRequest, requestError("invalid variables: "+err.Error()))
+					return
+				}
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			reply(http.StatusBadRequest, requestError("invalid request body"))
+			return
+		}
+
+		if strings.TrimSpace(req.Query) == "" {
+			reply(http.StatusBadRequest, requestError("query is required"))
+			return
+		}
+		if c.Request.Method == http.MethodGet && isMutation(req) {
+			reply(http.StatusMethodNotAllowed, requestError("mutations require POST"))
+			return
+		}
+
+		res := schema.Execute(c.Request.Context(), req)
+		status := http.StatusOK
+		if res.Data == nil && len(res.Errors) > 0 {
+			// The document could not be executed at all
+			status = http.StatusBadRequest
+		}
+		reply(status, res)
+	}
+}
+
+func requestError(message string) *gql.Result {
+	return &gql.Result{Errors: []gqlerrors.FormattedError{{Message: message}}}
+}
+
+// isMutation reports whether req selects a mutation operation.
+func isMutation(req Request) bool {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok || (req.OperationName != "" && (op.Name == nil || op.Name.Value != req.OperationName)) {
+			continue
+		}
+		return op.Operation == ast.OperationTypeMutation
+	}
+	return false
+}
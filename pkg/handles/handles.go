@@ -0,0 +1,257 @@
+// Package handles puts tenant-scoped infrastructure handles into the request
+// context, so thin handlers can reach the database, cache and object storage
+// without carrying managers from their constructor. Tests swap the handles
+// with Set or Static.
+package handles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin context key holding the request's *Handles.
+const ContextKey = "handles"
+
+// Handles are the infrastructure handles selected for one request. A nil
+// field means the component is not available.
+type Handles struct {
+	Tenant  string
+	DB      *infrastructure.PostgresManager
+	Mongo   *infrastructure.MongoManager
+	Cache   *infrastructure.RedisManager
+	Storage *infrastructure.StorageBucket
+
+	// ObjectPrefix is prepended to object keys by ObjectKey
+	ObjectPrefix string
+
+	// The tenant's own connections, set by Resolve; DB and Mongo fall
+	// back to the default connections
+	resolved    bool
+	tenantDB    *infrastructure.PostgresManager
+	tenantMongo *infrastructure.MongoManager
+}
+
+// Errors of TenantDB and TenantMongo.
+var (
+	ErrNoTenant           = errors.New("the request names no tenant")
+	ErrTenantNotConnected = errors.New("tenant database not found or not connected")
+)
+
+// CacheKey scopes key to the tenant as "tenant:<id>:<key>"; without a
+// tenant it returns key unchanged.
+func (h *Handles) CacheKey(key string) string {
+	if h.Tenant == "" {
+		return key
+	}
+	return "tenant:" + h.Tenant + ":" + key
+}
+
+// ObjectKey scopes an object name to the tenant's storage prefix.
+func (h *Handles) ObjectKey(name string) string {
+	return h.ObjectPrefix + name
+}
+
+// Options configures Middleware.
+type Options struct {
+	// ObjectPrefix is the tenant object prefix pattern; "{tenant}" is
+	// replaced with the tenant ID (see tenant_data.object_prefix).
+	ObjectPrefix string
+
+	// Tenants maps tenants to their connections; tenancy.DefaultRegistry()
+	// when nil.
+	Tenants *tenancy.Registry
+}
+
+// Middleware resolves the request's tenant from the request context (see
+// the tenant middleware), the :tenant path parameter or the X-Tenant-ID
+// header and stores its handles. The tenant's connections in the registry,
+// else a Postgres or Mongo connection named after the tenant, are preferred
+// over the default ones.
+func Middleware(deps *registry.Dependencies, opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		Set(c, Resolve(deps, requestTenant(c), opts))
+		c.Next()
+	}
+}
+
+// Ensure is Middleware for route groups relying on the handles: it
+// resolves them unless a global handles middleware already did.
+func Ensure(deps *registry.Dependencies, opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(ContextKey); !ok {
+			Set(c, Resolve(deps, requestTenant(c), opts))
+		}
+		c.Next()
+	}
+}
+
+func requestTenant(c *gin.Context) string {
+	if tenant := tenancy.FromContext(c.Request.Context()); tenant != "" {
+		return tenant
+	}
+	if tenant := c.Param("tenant"); tenant != "" {
+		return tenant
+	}
+	return c.GetHeader(tenancy.HeaderTenantID)
+}
+
+// Resolve selects the handles for tenant from the registered dependencies.
+func Resolve(deps *registry.Dependencies, tenant string, opts Options) *Handles {
+	h := &Handles{Tenant: tenant, resolved: true}
+	if tenant != "" && opts.ObjectPrefix != "" {
+		h.ObjectPrefix = strings.ReplaceAll(opts.ObjectPrefix, "{tenant}", tenant)
+	}
+	if deps == nil {
+		return h
+	}
+
+	tenants := opts.Tenants
+	if tenants == nil {
+		tenants = tenancy.DefaultRegistry()
+	}
+	t, registered := tenants.Get(tenant)
+	if !registered {
+		t = tenancy.Tenant{ID: tenant}
+	}
+	// Registered tenants without a connection of their own share the
+	// default one; disabled tenants have none
+	usable := tenant != "" && !t.Disabled
+
+	if pg, ok := registry.GetTyped[*infrastructure.PostgresConnectionManager](deps, "postgres"); ok && pg != nil {
+		def, _ := pg.GetDefaultConnection()
+		if conn, ok := pg.GetConnection(t.PostgresConnection()); ok && usable {
+			h.tenantDB = conn
+		} else if registered && usable && t.Postgres == "" {
+			h.tenantDB = def
+		}
+		h.DB = h.tenantDB
+		if h.DB == nil {
+			h.DB = def
+		}
+	}
+	if mongo, ok := registry.GetTyped[*infrastructure.MongoConnectionManager](deps, "mongo"); ok && mongo != nil {
+		def, _ := mongo.GetDefaultConnection()
+		if conn, ok := mongo.GetConnection(t.MongoConnection()); ok && usable {
+			h.tenantMongo = conn
+		} else if registered && usable && t.Mongo == "" {
+			h.tenantMongo = def
+		}
+		h.Mongo = h.tenantMongo
+		if h.Mongo == nil {
+			h.Mongo = def
+		}
+	}
+	if redis, ok := registry.GetTyped[*infrastructure.RedisManager](deps, "redis"); ok && redis != nil {
+		h.Cache = redis
+	}
+	if storage, ok := registry.GetTyped[*infrastructure.StorageManager](deps, "storage"); ok && storage != nil {
+		if bucket, err := storage.GetDefaultBucket(); err == nil {
+			h.Storage = bucket
+		}
+	}
+	return h
+}
+
+// Static stores fixed handles on every request, for tests and for services
+// wiring their own.
+func Static(h *Handles) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		Set(c, h)
+		c.Next()
+	}
+}
+
+// Set stores h as the request's handles and carries its tenant in the
+// request context.
+func Set(c *gin.Context, h *Handles) {
+	c.Set(ContextKey, h)
+	if h != nil && h.Tenant != "" && tenancy.FromContext(c.Request.Context()) == "" {
+		c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), h.Tenant))
+	}
+}
+
+// From returns the request's handles; without the middleware it returns
+// empty handles, never nil.
+func From(c *gin.Context) *Handles {
+	if v, ok := c.Get(ContextKey); ok {
+		if h, ok := v.(*Handles); ok && h != nil {
+			return h
+		}
+	}
+	return &Handles{}
+}
+
+// Tenant returns the request's tenant, or "".
+func Tenant(c *gin.Context) string {
+	if h := From(c); h.Tenant != "" {
+		return h.Tenant
+	}
+	return tenancy.FromContext(c.Request.Context())
+}
+
+// TenantDB returns the Postgres connection of the request's tenant. Unlike
+// DB it never falls back to the default connection for tenants that do not
+// share it, so one tenant's rows cannot land in another's database.
+func TenantDB(c *gin.Context) (*infrastructure.PostgresManager, error) {
+	h := From(c)
+	if h.Tenant == "" {
+		return nil, ErrNoTenant
+	}
+	db := h.DB
+	if h.resolved {
+		db = h.tenantDB
+	}
+	if db == nil {
+		return nil, fmt.Errorf("%w: postgres for tenant %q", ErrTenantNotConnected, h.Tenant)
+	}
+	return db, nil
+}
+
+// TenantMongo returns the MongoDB connection of the request's tenant, like
+// TenantDB.
+func TenantMongo(c *gin.Context) (*infrastructure.MongoManager, error) {
+	h := From(c)
+	if h.Tenant == "" {
+		return nil, ErrNoTenant
+	}
+	conn := h.Mongo
+	if h.resolved {
+		conn = h.tenantMongo
+	}
+	if conn == nil {
+		return nil, fmt.Errorf("%w: mongo for tenant %q", ErrTenantNotConnected, h.Tenant)
+	}
+	return conn, nil
+}
+
+// DB returns the request's Postgres connection.
+func DB(c *gin.Context) (*infrastructure.PostgresManager, bool) {
+	h := From(c)
+	return h.DB, h.DB != nil
+}
+
+// Mongo returns the request's MongoDB connection.
+func Mongo(c *gin.Context) (*infrastructure.MongoManager, bool) {
+	h := From(c)
+	return h.Mongo, h.Mongo != nil
+}
+
+// Cache returns the Redis manager.
+func Cache(c *gin.Context) (*infrastructure.RedisManager, bool) {
+	h := From(c)
+	return h.Cache, h.Cache != nil
+}
+
+// Storage returns the default object storage bucket; scope object names
+// with ObjectKey.
+func Storage(c *gin.Context) (*infrastructure.StorageBucket, bool) {
+	h := From(c)
+	return h.Storage, h.Storage != nil
+}
@@ -0,0 +1,217 @@
+// Package i18n keeps the locale and timezone preferences of monitoring
+// operators: the locales on offer, validation, and a per-user store the
+// monitoring API reads to present timestamps in the operator's zone.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// DefaultLocales are offered when the configuration lists none.
+var DefaultLocales = []string{
+	"en-US", "en-GB", "de-DE", "fr-FR", "es-ES", "it-IT", "nl-NL", "pt-BR",
+	"pl-PL", "sv-SE", "tr-TR", "ru-RU", "uk-UA", "ar-SA", "hi-IN", "id-ID",
+	"ja-JP", "ko-KR", "zh-CN", "zh-TW",
+}
+
+// Locale describes an offered locale.
+type Locale struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"` // in the locale itself, e.g. "Deutsch (Deutschland)"
+	EnglishName string `json:"english_name"`
+}
+
+// Preferences are the locale and IANA timezone an operator sees.
+type Preferences struct {
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+}
+
+// Location loads the timezone of p.
+func (p Preferences) Location() (*time.Location, error) {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", p.Timezone)
+	}
+	return loc, nil
+}
+
+// Settings are the locales on offer and the preferences of operators who
+// saved none.
+type Settings struct {
+	locales  []Locale
+	offered  map[string]bool
+	defaults Preferences
+}
+
+// NewSettings validates the offered locale codes (DefaultLocales when
+// empty) and the defaults; the default locale must be one of them.
+func NewSettings(codes []string, defaults Preferences) (*Settings, error) {
+	if len(codes) == 0 {
+		codes = DefaultLocales
+	}
+	s := &Settings{offered: make(map[string]bool, len(codes))}
+	for _, code := range codes {
+		tag, err := language.Parse(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid locale %q", code)
+		}
+		canonical := tag.String()
+		if s.offered[canonical] {
+			continue
+		}
+		s.offered[canonical] = true
+		s.locales = append(s.locales, Locale{
+			Code:        canonical,
+			Name:        display.Tags(tag).Name(tag),
+			EnglishName: display.English.Tags().Name(tag),
+		})
+	}
+
+	if defaults.Locale == "" {
+		defaults.Locale = s.locales[0].Code
+	}
+	if defaults.Timezone == "" {
+		defaults.Timezone = "UTC"
+	}
+	var err error
+	if s.defaults, err = s.Validate(defaults); err != nil {
+		return nil, fmt.Errorf("default preferences: %w", err)
+	}
+	return s, nil
+}
+
+// Locales returns the offered locales in configuration order.
+func (s *Settings) Locales() []Locale {
+	return append([]Locale(nil), s.locales...)
+}
+
+// Defaults returns the preferences of operators who saved none.
+func (s *Settings) Defaults() Preferences {
+	return s.defaults
+}
+
+// Validate checks that p names an offered locale and a known timezone and
+// returns it with the locale code in canonical form.
+func (s *Settings) Validate(p Preferences) (Preferences, error) {
+	tag, err := language.Parse(p.Locale)
+	if err != nil || !s.offered[tag.String()] {
+		return Preferences{}, fmt.Errorf("locale %q is not offered", p.Locale)
+	}
+	p.Locale = tag.String()
+	if strings.TrimSpace(p.Timezone) == "" {
+		return Preferences{}, fmt.Errorf("timezone is required")
+	}
+	if _, err := p.Location(); err != nil {
+		return Preferences{}, err
+	}
+	return p, nil
+}
+
+// KV is the persistence the store needs; the embedded store implements it.
+type KV interface {
+	PutJSON(bucket, key string, v interface{}) error
+	GetJSON(bucket, key string, v interface{}) (bool, error)
+	Delete(bucket, key string) error
+}
+
+const preferencesBucket = "operator_preferences"
+
+// Store keeps the preferences of each operator, in kv when given and in
+// memory otherwise.
+type Store struct {
+	settings *Settings
+	kv       KV
+
+	mu     sync.RWMutex
+	memory map[string]Preferences
+}
+
+// NewStore creates a store; kv may be nil.
+func NewStore(settings *Settings, kv KV) *Store {
+	return &Store{settings: settings, kv: kv, memory: make(map[string]Preferences)}
+}
+
+// Settings returns the offered locales and defaults.
+func (s *Store) Settings() *Settings {
+	return s.settings
+}
+
+// Get returns the preferences of user and whether they were saved, the
+// defaults filling in what was not.
+func (s *Store) Get(user string) (Preferences, bool, error) {
+	var saved Preferences
+	found := false
+	if s.kv != nil {
+		var err error
+		if found, err = s.kv.GetJSON(preferencesBucket, user, &saved); err != nil {
+			return Preferences{}, false, err
+		}
+	} else {
+		s.mu.RLock()
+		saved, found = s.memory[user]
+		s.mu.RUnlock()
+	}
+	return s.merge(saved), found, nil
+}
+
+// Set validates update merged over the current preferences of user and
+// saves the result.
+func (s *Store) Set(user string, update Preferences) (Preferences, error) {
+	current, _, err := s.Get(user)
+	if err != nil {
+		return Preferences{}, err
+	}
+	if update.Locale != "" {
+		current.Locale = update.Locale
+	}
+	if update.Timezone != "" {
+		current.Timezone = update.Timezone
+	}
+	prefs, err := s.settings.Validate(current)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	if s.kv != nil {
+		return prefs, s.kv.PutJSON(preferencesBucket, user, prefs)
+	}
+	s.mu.Lock()
+	s.memory[user] = prefs
+	s.mu.Unlock()
+	return prefs, nil
+}
+
+// Reset forgets the preferences of user.
+func (s *Store) Reset(user string) error {
+	if s.kv != nil {
+		return s.kv.Delete(preferencesBucket, user)
+	}
+	s.mu.Lock()
+	delete(s.memory, user)
+	s.mu.Unlock()
+	return nil
+}
+
+// merge fills the empty or no longer offered fields of saved with the
+// defaults, e.g. after a locale was removed from the configuration.
+func (s *Store) merge(saved Preferences) Preferences {
+	prefs := s.settings.Defaults()
+	if saved.Locale != "" {
+		if _, err := s.settings.Validate(Preferences{Locale: saved.Locale, Timezone: prefs.Timezone}); err == nil {
+			prefs.Locale = saved.Locale
+		}
+	}
+	if saved.Timezone != "" {
+		if _, err := saved.Location(); err == nil {
+			prefs.Timezone = saved.Timezone
+		}
+	}
+	return prefs
+}
@@ -0,0 +1,146 @@
+// Package idempotency keeps the responses of requests made with an
+// Idempotency-Key, so a client retrying a POST or PUT gets the original
+// response instead of creating the order (or anything else) twice. Records
+// live in Redis so every instance sees them; MemoryStore serves a single
+// instance and tests.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Record states.
+const (
+	StatePending = "pending" // the first request is still being handled
+	StateDone    = "done"    // the response is stored
+)
+
+// Record is what is kept for one idempotency key.
+type Record struct {
+	State       string            `json:"state"`
+	Fingerprint string            `json:"fingerprint"` // digest of method, path and body
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	Created     time.Time         `json:"created"`
+}
+
+// Store keeps records by key.
+type Store interface {
+	// Reserve stores a pending record unless one is kept for key, in which
+	// case it returns that record and false.
+	Reserve(ctx context.Context, key string, pending Record, ttl time.Duration) (*Record, bool, error)
+	// Complete replaces the record of key.
+	Complete(ctx context.Context, key string, record Record, ttl time.Duration) error
+	// Release removes the record of key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// RedisStore keeps records in Redis under Prefix.
+type RedisStore struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+// NewRedisStore returns a store keeping records in client as
+// "idempotency:<key>".
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{Client: client, Prefix: "idempotency:"}
+}
+
+// Reserve implements Store with SETNX, so concurrent retries on other
+// instances see the pending record.
+func (s *RedisStore) Reserve(ctx context.Context, key string, pending Record, ttl time.Duration) (*Record, bool, error) {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := s.Client.SetNX(ctx, s.Prefix+key, data, ttl).Result()
+	if err != nil || ok {
+		return nil, ok, err
+	}
+	raw, err := s.Client.Get(ctx, s.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired in between; take it
+		return s.Reserve(ctx, key, pending, ttl)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var existing Record
+	if err := json.Unmarshal(raw, &existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// Complete implements Store.
+func (s *RedisStore) Complete(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, s.Prefix+key, data, ttl).Err()
+}
+
+// Release implements Store.
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.Client.Del(ctx, s.Prefix+key).Err()
+}
+
+// MemoryStore keeps records in memory, for a single instance and tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+}
+
+type memoryRecord struct {
+	record  Record
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]memoryRecord)}
+}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(_ context.Context, key string, pending Record, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if kept, ok := s.records[key]; ok && now.Before(kept.expires) {
+		existing := kept.record
+		return &existing, false, nil
+	}
+	// Drop expired records while holding the lock anyway
+	for k, kept := range s.records {
+		if !now.Before(kept.expires) {
+			delete(s.records, k)
+		}
+	}
+	s.records[key] = memoryRecord{record: pending, expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// Complete implements Store.
+func (s *MemoryStore) Complete(_ context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryRecord{record: record, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
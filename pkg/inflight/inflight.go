@@ -0,0 +1,116 @@
+// Package inflight tracks the requests currently being served, like
+// pg_stat_activity for HTTP, and lets operators cancel one through its
+// context.
+package inflight
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCancelled is the cause of the context of a request cancelled through
+// the tracker.
+var ErrCancelled = errors.New("request cancelled by an operator")
+
+// Request describes a request being served.
+type Request struct {
+	ID            string    `json:"id"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Route         string    `json:"route,omitempty"` // registered path, e.g. /users/:id
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	ClientIP      string    `json:"client_ip,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	DurationMs    float64   `json:"duration_ms"` // so far, when listed
+	Cancelled     bool      `json:"cancelled"`
+}
+
+type entry struct {
+	req    Request
+	cancel context.CancelCauseFunc
+}
+
+// Tracker holds the requests in flight. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	requests map[string]*entry
+	nextID   atomic.Uint64
+}
+
+var defaultTracker = NewTracker()
+
+// Default returns the process-wide tracker fed by the inflight middleware.
+func Default() *Tracker {
+	return defaultTracker
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{requests: make(map[string]*entry)}
+}
+
+// Begin records req as in flight and returns a context derived from ctx
+// that Cancel cancels, and a function to call when the request is done.
+func (t *Tracker) Begin(ctx context.Context, req Request) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	req.ID = strconv.FormatUint(t.nextID.Add(1), 10)
+	if req.StartedAt.IsZero() {
+		req.StartedAt = time.Now()
+	}
+	t.mu.Lock()
+	t.requests[req.ID] = &entry{req: req, cancel: cancel}
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.requests, req.ID)
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// List returns the requests in flight, longest running first.
+func (t *Tracker) List() []Request {
+	now := time.Now()
+	t.mu.Lock()
+	out := make([]Request, 0, len(t.requests))
+	for _, e := range t.requests {
+		r := e.req
+		r.DurationMs = float64(now.Sub(r.StartedAt)) / float64(time.Millisecond)
+		out = append(out, r)
+	}
+	t.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].StartedAt.Equal(out[j].StartedAt) {
+			return out[i].StartedAt.Before(out[j].StartedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Cancel cancels the context of the request with the given ID and reports
+// whether it is in flight. Handlers that ignore their context keep running.
+func (t *Tracker) Cancel(id string) (Request, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.requests[id]
+	if !ok {
+		return Request{}, false
+	}
+	e.req.Cancelled = true
+	e.cancel(ErrCancelled)
+	return e.req, true
+}
+
+// Len returns the number of requests in flight.
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.requests)
+}
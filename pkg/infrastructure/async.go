@@ -13,6 +13,9 @@ type AsyncResult[T any] struct {
 	Value T
 	Error error
 	Done  chan struct{}
+	// cancel cancels the context the operation runs with; nil for results
+	// not created by ExecuteAsync.
+	cancel context.CancelFunc
 }
 
 // NewAsyncResult creates a new async result
@@ -29,13 +32,17 @@ func (r *AsyncResult[T]) Complete(value T, err error) {
 	close(r.Done)
 }
 
-// Wait blocks until the operation is complete and returns the result
+// Wait blocks until the operation is complete and returns the result.
+// It only returns once the operation does; prefer WaitContext or
+// WaitWithTimeout when the caller may give up first.
 func (r *AsyncResult[T]) Wait() (T, error) {
 	<-r.Done
 	return r.Value, r.Error
 }
 
-// WaitWithTimeout waits for the operation with a timeout.
+// WaitWithTimeout waits for the operation with a timeout. When the timeout
+// expires first the operation is cancelled and context.DeadlineExceeded is
+// returned.
 // Uses time.NewTimer so the underlying timer resource is always reclaimed
 // via defer timer.Stop(), preventing a goroutine + FD leak per call.
 func (r *AsyncResult[T]) WaitWithTimeout(timeout time.Duration) (T, error) {
@@ -46,11 +53,36 @@ func (r *AsyncResult[T]) WaitWithTimeout(timeout time.Duration) (T, error) {
 	case <-r.Done:
 		return r.Value, r.Error
 	case <-timer.C:
+		r.Cancel()
 		var zero T
 		return zero, context.DeadlineExceeded
 	}
 }
 
+// WaitContext waits for the operation until ctx is done, in which case the
+// operation is cancelled and ctx.Err() is returned. Handlers pass their
+// request context so an early return never leaves the operation running.
+func (r *AsyncResult[T]) WaitContext(ctx context.Context) (T, error) {
+	select {
+	case <-r.Done:
+		return r.Value, r.Error
+	case <-ctx.Done():
+		r.Cancel()
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Cancel cancels the context of the operation. The operation stops as soon
+// as it observes the cancellation and completes with its own error; Cancel
+// does not wait for that. Cancelling a completed operation is a no-op, except
+// that values bound to its context (such as *sql.Rows) are released.
+func (r *AsyncResult[T]) Cancel() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
 // IsDone checks if the operation is complete without blocking
 func (r *AsyncResult[T]) IsDone() bool {
 	select {
@@ -64,9 +96,14 @@ func (r *AsyncResult[T]) IsDone() bool {
 // AsyncOperation represents an operation that can be executed asynchronously
 type AsyncOperation[T any] func(ctx context.Context) (T, error)
 
-// ExecuteAsync executes an operation asynchronously and returns an AsyncResult
+// ExecuteAsync executes an operation asynchronously and returns an AsyncResult.
+// The operation runs with a context derived from ctx: it is cancelled when
+// ctx is, or through the result (Cancel, WaitContext, WaitWithTimeout), so a
+// result abandoned by a handler that returned early stops with the
+// handler's request context.
 func ExecuteAsync[T any](ctx context.Context, operation AsyncOperation[T]) *AsyncResult[T] {
 	result := NewAsyncResult[T]()
+	ctx, result.cancel = context.WithCancel(ctx)
 
 	go func() {
 		defer func() {
@@ -83,12 +120,24 @@ func ExecuteAsync[T any](ctx context.Context, operation AsyncOperation[T]) *Asyn
 	return result
 }
 
+// ExecuteAsyncWithTimeout is ExecuteAsync with a deadline on the operation,
+// so a result nobody waits for is cleaned up after timeout at the latest.
+// Values bound to the operation's context (such as *sql.Rows) must be
+// consumed before the deadline.
+func ExecuteAsyncWithTimeout[T any](ctx context.Context, timeout time.Duration, operation AsyncOperation[T]) *AsyncResult[T] {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	result := ExecuteAsync(ctx, operation)
+	result.cancel = cancel // cancelling the parent cancels the derived context too
+	return result
+}
+
 // BatchAsyncResult represents the result of a batch asynchronous operation
 type BatchAsyncResult[T any] struct {
 	Results    []AsyncResult[T]
 	Done        chan struct{}
 	batchSize   int
 	pending     int32 // number of results outstanding; CompleteResult is the sole completer
+	cancel      context.CancelFunc
 }
 
 // NewBatchAsyncResult creates a new batch async result
@@ -134,6 +183,27 @@ func (br *BatchAsyncResult[T]) WaitAll() ([]T, []error) {
 	return values, errors
 }
 
+// WaitAllContext is WaitAll until ctx is done, in which case the remaining
+// operations are cancelled and ctx.Err() is returned.
+func (br *BatchAsyncResult[T]) WaitAllContext(ctx context.Context) ([]T, []error, error) {
+	select {
+	case <-br.Done:
+		values, errs := br.WaitAll()
+		return values, errs, nil
+	case <-ctx.Done():
+		br.Cancel()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// Cancel cancels the context of the batch; operations not started yet
+// complete with context.Canceled without running.
+func (br *BatchAsyncResult[T]) Cancel() {
+	if br.cancel != nil {
+		br.cancel()
+	}
+}
+
 // ExecuteBatchAsync executes multiple operations asynchronously, capping the
 // number of concurrent goroutines at batchSize (waves).  A batchSize of 0 or
 // less falls back to the default of 100.
@@ -144,6 +214,7 @@ func ExecuteBatchAsync[T any](ctx context.Context, operations []AsyncOperation[T
 	}
 
 	result := NewBatchAsyncResult[T](len(operations), limit)
+	ctx, result.cancel = context.WithCancel(ctx)
 	var wg sync.WaitGroup
 	wg.Add(len(operations))
 
@@ -164,6 +235,11 @@ func ExecuteBatchAsync[T any](ctx context.Context, operations []AsyncOperation[T
 				}
 			}()
 
+			if err := ctx.Err(); err != nil {
+				result.Results[i].Error = err
+				result.CompleteResult(i)
+				return
+			}
 			value, err := operation(ctx)
 			result.Results[i].Value = value
 			result.Results[i].Error = err
@@ -180,67 +256,224 @@ func ExecuteBatchAsync[T any](ctx context.Context, operations []AsyncOperation[T
 	return result
 }
 
-// WorkerPool manages a pool of goroutines for executing async operations
+// WorkerPool manages a pool of goroutines for executing async operations.
+// Every job carries an origin (the subsystem that submitted it) and the pool
+// keeps throughput, latency and failure counts per origin. A pool created
+// with MaxWorkers above MinWorkers grows while jobs wait in its queue and
+// shrinks back once its workers have been idle for a while.
 type WorkerPool struct {
-	workers  int
-	jobQueue chan func()
+	name     string
+	jobQueue chan poolJob
 	stopChan chan struct{}
 	stopped  chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	stats    *poolStats
+
+	sizeMu        sync.Mutex
+	workers       int // running, or to run once started
+	minWorkers    int
+	maxWorkers    int
+	scaleInterval time.Duration
+	retire        chan struct{} // a worker receiving from it exits
+	idleTicks     int
+	scaleUps      int64
+	scaleDowns    int64
+}
+
+// PoolOptions sizes a WorkerPool.
+type PoolOptions struct {
+	Workers       int           // workers at start
+	MinWorkers    int           // fewest workers when scaling; Workers when 0
+	MaxWorkers    int           // most workers when scaling; above MinWorkers enables scaling
+	QueueSize     int           // jobs waiting before Submit blocks; twice the most workers when 0
+	ScaleInterval time.Duration // how often the backlog is checked when scaling; 1s when 0
+}
+
+// scaleDownTicks is how many scale checks in a row a pool must have idle
+// workers and an empty queue before it retires one.
+const scaleDownTicks = 10
+
+// poolJob is a queued job with its origin and submission time.
+type poolJob struct {
+	origin    string
+	run       func() error
+	submitted time.Time
 }
 
 // NewWorkerPool creates a new worker pool
 func NewWorkerPool(workers int) *WorkerPool {
+	return NewNamedWorkerPool("", workers)
+}
+
+// NewNamedWorkerPool creates a worker pool listed under name by
+// WorkerPools.
+func NewNamedWorkerPool(name string, workers int) *WorkerPool {
+	return NewWorkerPoolWithOptions(name, PoolOptions{Workers: workers})
+}
+
+// NewWorkerPoolWithOptions creates a worker pool listed under name by
+// WorkerPools, sized by opts.
+func NewWorkerPoolWithOptions(name string, opts PoolOptions) *WorkerPool {
+	workers := max(opts.Workers, 1)
+	minWorkers := opts.MinWorkers
+	if minWorkers <= 0 {
+		minWorkers = workers
+	}
+	maxWorkers := max(opts.MaxWorkers, minWorkers)
+	workers = min(max(workers, minWorkers), maxWorkers)
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = maxWorkers * 2
+	}
+	interval := opts.ScaleInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
 	return &WorkerPool{
-		workers:  workers,
-		jobQueue: make(chan func(), workers*2),
-		stopChan: make(chan struct{}),
-		stopped:  make(chan struct{}),
+		name:          name,
+		workers:       workers,
+		minWorkers:    minWorkers,
+		maxWorkers:    maxWorkers,
+		scaleInterval: interval,
+		jobQueue:      make(chan poolJob, queueSize),
+		stopChan:      make(chan struct{}),
+		stopped:       make(chan struct{}),
+		retire:        make(chan struct{}, maxWorkers),
+		stats:         newPoolStats(),
 	}
 }
 
 // Start starts the worker pool
 func (wp *WorkerPool) Start() {
+	registerPool(wp)
+	wp.sizeMu.Lock()
 	for i := 0; i < wp.workers; i++ {
+		wp.wg.Add(1)
 		go wp.worker()
 	}
+	wp.sizeMu.Unlock()
+	if wp.autoscaling() {
+		wp.wg.Add(1)
+		go wp.scale()
+	}
+}
+
+func (wp *WorkerPool) autoscaling() bool {
+	return wp.maxWorkers > wp.minWorkers
+}
+
+// scale adjusts the number of workers to the backlog until the pool stops.
+func (wp *WorkerPool) scale() {
+	defer wp.wg.Done()
+	ticker := time.NewTicker(wp.scaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			wp.rescale()
+		case <-wp.stopChan:
+			return
+		}
+	}
+}
+
+// rescale adds a worker per waiting job up to the maximum, or retires one
+// worker once workers have been idle for scaleDownTicks checks.
+func (wp *WorkerPool) rescale() {
+	queued := len(wp.jobQueue)
+	busy := wp.stats.busy()
+	wp.sizeMu.Lock()
+	defer wp.sizeMu.Unlock()
+	switch {
+	case queued > 0 && wp.workers < wp.maxWorkers:
+		add := min(queued, wp.maxWorkers-wp.workers)
+		for i := 0; i < add; i++ {
+			wp.wg.Add(1)
+			go wp.worker()
+		}
+		wp.workers += add
+		wp.scaleUps++
+		wp.idleTicks = 0
+	case queued == 0 && busy < int64(wp.workers) && wp.workers > wp.minWorkers:
+		wp.idleTicks++
+		if wp.idleTicks >= scaleDownTicks {
+			wp.retire <- struct{}{}
+			wp.workers--
+			wp.scaleDowns++
+			wp.idleTicks = 0
+		}
+	default:
+		wp.idleTicks = 0
+	}
 }
 
 // Stop stops the worker pool, draining any queued jobs first.
 func (wp *WorkerPool) Stop() {
-	// Drain buffered jobs before signalling workers to stop so that Submit
-	// never races with close (only Stop ever closes stopChan).
-	for len(wp.jobQueue) > 0 {
-		<-wp.jobQueue
-	}
-	close(wp.stopChan)
+	wp.stopOnce.Do(func() {
+		// Drain buffered jobs before signalling workers to stop so that Submit
+		// never races with close (only Stop ever closes stopChan).
+		for len(wp.jobQueue) > 0 {
+			job := <-wp.jobQueue
+			wp.stats.dropped(job.origin)
+		}
+		close(wp.stopChan)
+		wp.wg.Wait()
+		unregisterPool(wp)
+		close(wp.stopped)
+	})
 	<-wp.stopped
 }
 
-// Submit submits a job to the worker pool.
+// Submit submits an untagged job to the worker pool.
 func (wp *WorkerPool) Submit(job func()) {
-	wp.jobQueue <- job
+	wp.SubmitTagged("", func() error {
+		job()
+		return nil
+	})
 }
 
-func (wp *WorkerPool) worker() {
-	defer func() {
-		if r := recover(); r != nil {
-			// Log panic and continue
-		}
-	}()
+// SubmitTagged submits a job on behalf of origin, such as a service name,
+// handler or cron job ("cron:cleanup"). A returned error or a panic counts
+// as a failure of the origin.
+func (wp *WorkerPool) SubmitTagged(origin string, job func() error) {
+	if origin == "" {
+		origin = UntaggedOrigin
+	}
+	wp.stats.submitted(origin)
+	wp.jobQueue <- poolJob{origin: origin, run: job, submitted: time.Now()}
+}
 
+func (wp *WorkerPool) worker() {
+	defer wp.wg.Done()
 	for {
 		select {
 		case job := <-wp.jobQueue:
-			job()
+			wp.run(job)
+		case <-wp.retire:
+			return
 		case <-wp.stopChan:
 			return
 		}
 	}
 }
 
+// run executes job, recovering from a panic so the worker survives it.
+func (wp *WorkerPool) run(job poolJob) {
+	start := time.Now()
+	wp.stats.started(job.origin, start.Sub(job.submitted))
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.run()
+	}()
+	wp.stats.finished(job.origin, time.Since(start), err)
+}
+
 // Close closes the worker pool
 func (wp *WorkerPool) Close() {
 	wp.Stop()
-	close(wp.jobQueue)
-	close(wp.stopped)
 }
@@ -16,6 +16,7 @@ type InfraInitStatus struct {
 	StartTime   time.Time     `json:"start_time"`
 	Duration    time.Duration `json:"duration,omitempty"`
 	Progress    float64       `json:"progress"` // 0.0 to 1.0
+	Attempts    int           `json:"attempts"`
 }
 
 // InfraInitManager manages asynchronous infrastructure initialization
@@ -44,22 +45,17 @@ func (im *InfraInitManager) StartAsyncInitialization(cfg *config.Config, logger
 		logger.Error("Failed to initialize infrastructure components", err)
 	}
 
+	// Record how connecting went, including components that failed
+	for name, result := range registry.ConnectResults() {
+		im.RecordConnect(name, result)
+	}
+
 	// Start async health checks and monitoring (non-blocking)
 	components := registry.GetAll()
 	for name, component := range components {
 		name := name
 		component := component
 		go func(compName string, comp InfrastructureComponent) {
-			startTime := time.Now()
-			// Update status to initialized
-			im.updateStatus(compName, &InfraInitStatus{
-				Name:        compName,
-				Initialized: true,
-				StartTime:   startTime,
-				Duration:    time.Since(startTime),
-				Progress:    1.0,
-			})
-
 			// Perform health check
 			status := comp.GetStatus()
 			if connected, ok := status["connected"].(bool); ok && connected {
@@ -76,6 +72,23 @@ func (im *InfraInitManager) StartAsyncInitialization(cfg *config.Config, logger
 	return registry
 }
 
+// RecordConnect records how connecting a component went, at boot or on a
+// soft restart.
+func (im *InfraInitManager) RecordConnect(name string, result ConnectResult) {
+	status := &InfraInitStatus{
+		Name:        name,
+		Initialized: result.Err == nil,
+		StartTime:   result.Started,
+		Duration:    result.Duration,
+		Progress:    1.0,
+		Attempts:    result.Attempts,
+	}
+	if result.Err != nil {
+		status.Error = result.Err.Error()
+	}
+	im.updateStatus(name, status)
+}
+
 // updateStatus updates the initialization status of a component
 func (im *InfraInitManager) updateStatus(name string, status *InfraInitStatus) {
 	im.mu.Lock()
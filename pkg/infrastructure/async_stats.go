@@ -0,0 +1,291 @@
+package infrastructure
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// UntaggedOrigin is the origin of jobs submitted without one.
+const UntaggedOrigin = "untagged"
+
+// OriginStats is the accounting of the jobs one origin submitted to a
+// worker pool. Times are in milliseconds.
+type OriginStats struct {
+	Origin      string     `json:"origin"`
+	Submitted   int64      `json:"submitted"`
+	Completed   int64      `json:"completed"`
+	Failed      int64      `json:"failed"`
+	Dropped     int64      `json:"dropped"` // discarded from the queue when the pool stopped
+	Queued      int64      `json:"queued"`
+	Running     int64      `json:"running"`
+	PerMinute   int64      `json:"per_minute"`   // jobs finished in the last minute
+	FailureRate float64    `json:"failure_rate"` // percent of finished jobs
+	AvgWaitMS   float64    `json:"avg_wait_ms"`  // time spent queued
+	MaxWaitMS   float64    `json:"max_wait_ms"`
+	AvgRunMS    float64    `json:"avg_run_ms"`
+	MaxRunMS    float64    `json:"max_run_ms"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+// PoolStatus is a snapshot of a worker pool and its origins, busiest
+// first. Latencies cover the jobs of every origin, in milliseconds.
+type PoolStatus struct {
+	Name          string        `json:"name"`
+	Workers       int           `json:"workers"`
+	MinWorkers    int           `json:"min_workers"`
+	MaxWorkers    int           `json:"max_workers"`
+	Autoscale     bool          `json:"autoscale"`
+	ScaleUps      int64         `json:"scale_ups"`
+	ScaleDowns    int64         `json:"scale_downs"`
+	Busy          int64         `json:"busy"`
+	Queued        int           `json:"queued"`
+	QueueCapacity int           `json:"queue_capacity"`
+	Saturated     bool          `json:"saturated"` // every worker busy and the queue full
+	AvgWaitMS     float64       `json:"avg_wait_ms"`
+	MaxWaitMS     float64       `json:"max_wait_ms"`
+	AvgRunMS      float64       `json:"avg_run_ms"`
+	MaxRunMS      float64       `json:"max_run_ms"`
+	Origins       []OriginStats `json:"origins"`
+}
+
+type originCounters struct {
+	OriginStats
+	waitTotal, runTotal time.Duration
+	waitMax, runMax     time.Duration
+	// recent counts finished jobs per second over the last minute
+	recent [60]struct {
+		sec   int64
+		count int64
+	}
+}
+
+type poolStats struct {
+	mu      sync.Mutex
+	origins map[string]*originCounters
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{origins: make(map[string]*originCounters)}
+}
+
+// origin returns the counters of name; callers hold s.mu.
+func (s *poolStats) origin(name string) *originCounters {
+	o, ok := s.origins[name]
+	if !ok {
+		o = &originCounters{OriginStats: OriginStats{Origin: name}}
+		s.origins[name] = o
+	}
+	return o
+}
+
+func (s *poolStats) submitted(origin string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.origin(origin)
+	o.Submitted++
+	o.Queued++
+}
+
+func (s *poolStats) dropped(origin string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.origin(origin)
+	o.Queued--
+	o.Dropped++
+}
+
+func (s *poolStats) started(origin string, wait time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.origin(origin)
+	o.Queued--
+	o.Running++
+	o.waitTotal += wait
+	o.waitMax = max(o.waitMax, wait)
+}
+
+func (s *poolStats) finished(origin string, took time.Duration, err error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.origin(origin)
+	o.Running--
+	if err != nil {
+		o.Failed++
+		o.LastError = err.Error()
+		o.LastFailure = &now
+	} else {
+		o.Completed++
+	}
+	o.runTotal += took
+	o.runMax = max(o.runMax, took)
+
+	bucket := &o.recent[now.Unix()%int64(len(o.recent))]
+	if bucket.sec != now.Unix() {
+		bucket.sec, bucket.count = now.Unix(), 0
+	}
+	bucket.count++
+}
+
+// busy counts the jobs running across origins.
+func (s *poolStats) busy() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, o := range s.origins {
+		n += o.Running
+	}
+	return n
+}
+
+// latency returns the wait and run times of the jobs of every origin.
+func (s *poolStats) latency() (avgWait, maxWait, avgRun, maxRun time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var waitTotal, runTotal time.Duration
+	var started, finished int64
+	for _, o := range s.origins {
+		waitTotal += o.waitTotal
+		runTotal += o.runTotal
+		started += o.Completed + o.Failed + o.Running
+		finished += o.Completed + o.Failed
+		maxWait, maxRun = max(maxWait, o.waitMax), max(maxRun, o.runMax)
+	}
+	if started > 0 {
+		avgWait = waitTotal / time.Duration(started)
+	}
+	if finished > 0 {
+		avgRun = runTotal / time.Duration(finished)
+	}
+	return avgWait, maxWait, avgRun, maxRun
+}
+
+// snapshot returns the stats of every origin, most submitted first.
+func (s *poolStats) snapshot() []OriginStats {
+	now := time.Now().Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]OriginStats, 0, len(s.origins))
+	for _, o := range s.origins {
+		stats := o.OriginStats
+		if finished := o.Completed + o.Failed; finished > 0 {
+			stats.FailureRate = round2(float64(o.Failed) / float64(finished) * 100)
+			stats.AvgRunMS = durationMS(o.runTotal / time.Duration(finished))
+		}
+		if started := o.Completed + o.Failed + o.Running; started > 0 {
+			stats.AvgWaitMS = durationMS(o.waitTotal / time.Duration(started))
+		}
+		stats.MaxWaitMS, stats.MaxRunMS = durationMS(o.waitMax), durationMS(o.runMax)
+		for _, b := range o.recent {
+			if now-b.sec < int64(len(o.recent)) {
+				stats.PerMinute += b.count
+			}
+		}
+		list = append(list, stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Submitted != list[j].Submitted {
+			return list[i].Submitted > list[j].Submitted
+		}
+		return list[i].Origin < list[j].Origin
+	})
+	return list
+}
+
+func durationMS(d time.Duration) float64 {
+	return round2(float64(d.Microseconds()) / 1000)
+}
+
+// Name returns the name the pool was created with.
+func (wp *WorkerPool) Name() string {
+	return wp.name
+}
+
+// Stats returns the current load of the pool and its per-origin
+// accounting.
+func (wp *WorkerPool) Stats() PoolStatus {
+	wp.sizeMu.Lock()
+	status := PoolStatus{
+		Name:          wp.name,
+		Workers:       wp.workers,
+		MinWorkers:    wp.minWorkers,
+		MaxWorkers:    wp.maxWorkers,
+		Autoscale:     wp.autoscaling(),
+		ScaleUps:      wp.scaleUps,
+		ScaleDowns:    wp.scaleDowns,
+		Queued:        len(wp.jobQueue),
+		QueueCapacity: cap(wp.jobQueue),
+	}
+	wp.sizeMu.Unlock()
+	status.Origins = wp.stats.snapshot()
+	for _, o := range status.Origins {
+		status.Busy += o.Running
+	}
+	avgWait, maxWait, avgRun, maxRun := wp.stats.latency()
+	status.AvgWaitMS, status.MaxWaitMS = durationMS(avgWait), durationMS(maxWait)
+	status.AvgRunMS, status.MaxRunMS = durationMS(avgRun), durationMS(maxRun)
+	status.Saturated = status.Busy >= int64(status.Workers) && status.Queued >= status.QueueCapacity
+	return status
+}
+
+// GetStatus summarizes the load of the pool for the status of the
+// component owning it.
+func (wp *WorkerPool) GetStatus() map[string]interface{} {
+	status := wp.Stats()
+	return map[string]interface{}{
+		"workers":        status.Workers,
+		"min_workers":    status.MinWorkers,
+		"max_workers":    status.MaxWorkers,
+		"autoscale":      status.Autoscale,
+		"busy":           status.Busy,
+		"queued":         status.Queued,
+		"queue_capacity": status.QueueCapacity,
+		"saturated":      status.Saturated,
+		"avg_wait_ms":    status.AvgWaitMS,
+		"avg_run_ms":     status.AvgRunMS,
+	}
+}
+
+// Started pools, so operators can see all of them without each component
+// exposing its own.
+var (
+	poolsMu sync.Mutex
+	pools   []*WorkerPool
+)
+
+func registerPool(wp *WorkerPool) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	for _, p := range pools {
+		if p == wp {
+			return
+		}
+	}
+	pools = append(pools, wp)
+}
+
+func unregisterPool(wp *WorkerPool) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	for i, p := range pools {
+		if p == wp {
+			pools = append(pools[:i], pools[i+1:]...)
+			return
+		}
+	}
+}
+
+// WorkerPools returns the status of every started worker pool by name.
+func WorkerPools() []PoolStatus {
+	poolsMu.Lock()
+	list := append([]*WorkerPool(nil), pools...)
+	poolsMu.Unlock()
+	statuses := make([]PoolStatus, 0, len(list))
+	for _, wp := range list {
+		statuses = append(statuses, wp.Stats())
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -34,7 +34,7 @@ func (c *CronManager) Name() string {
 
 func NewCronManager() *CronManager {
 	// Initialize worker pool for async job execution
-	pool := NewWorkerPool(5) // Small pool for cron jobs
+	pool := NewComponentPool("cron", "cron", 5) // Small pool for cron jobs
 	pool.Start()
 
 	return &CronManager{
@@ -98,10 +98,14 @@ func (c *CronManager) GetStatus() map[string]interface{} {
 	if c == nil {
 		return map[string]interface{}{"active": false, "jobs": []interface{}{}}
 	}
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"active": true, // Always true if manager exists
 		"jobs":   c.GetJobs(),
 	}
+	if c.pool != nil {
+		status["pool"] = c.pool.GetStatus()
+	}
+	return status
 }
 
 // Async Cron Operations
@@ -113,7 +117,7 @@ func (c *CronManager) AddAsyncJob(name, schedule string, cmd func()) (int, error
 
 	// Wrap cmd to execute in worker pool
 	wrappedCmd := func() {
-		c.SubmitAsyncJob(cmd)
+		c.submitJob(name, cmd)
 	}
 
 	id, err := c.cron.AddFunc(schedule, wrappedCmd)
@@ -141,11 +145,11 @@ func (c *CronManager) RunJobNow(jobID int) error {
 		return fmt.Errorf("job with ID %d not found", jobID)
 	}
 	// Take a copy of the closure while we hold the lock
-	cmd := job.cmd
+	cmd, name := job.cmd, job.Name
 	c.mu.Unlock()
 
 	if cmd != nil {
-		c.SubmitAsyncJob(cmd)
+		c.submitJob(name, cmd)
 	}
 	return nil
 }
@@ -226,6 +230,19 @@ func (c *CronManager) SubmitAsyncJob(job func()) {
 	}
 }
 
+// submitJob runs the cron job called name in the worker pool, tagged
+// "cron:<name>" so the pool accounts for it per job.
+func (c *CronManager) submitJob(name string, cmd func()) {
+	if c.pool == nil {
+		go cmd()
+		return
+	}
+	c.pool.SubmitTagged("cron:"+name, func() error {
+		cmd()
+		return nil
+	})
+}
+
 // GetPoolStatus returns the status of the worker pool
 func (c *CronManager) GetPoolStatus() map[string]interface{} {
 	if c.pool == nil {
@@ -235,10 +252,13 @@ func (c *CronManager) GetPoolStatus() map[string]interface{} {
 		}
 	}
 
-	// Note: WorkerPool doesn't expose internal stats, so we return basic info
+	stats := c.pool.Stats()
 	return map[string]interface{}{
 		"available": true,
-		"workers":   5, // We know this from initialization
+		"workers":   stats.Workers,
+		"busy":      stats.Busy,
+		"queued":    stats.Queued,
+		"origins":   stats.Origins,
 	}
 }
 
@@ -0,0 +1,115 @@
+package infrastructure
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConnectionEventType is the kind of a ConnectionEvent.
+type ConnectionEventType string
+
+const (
+	ConnectionRetrying      ConnectionEventType = "retrying"     // an attempt failed, another follows
+	ConnectionConnected     ConnectionEventType = "connected"    // the component is up
+	ConnectionFailed        ConnectionEventType = "failed"       // all attempts failed
+	ConnectionLost          ConnectionEventType = "disconnected" // an established connection dropped
+	ConnectionReestablished ConnectionEventType = "reconnected"  // a dropped connection is back
+)
+
+// ConnectionEvent reports a change in the connection state of an
+// infrastructure component, e.g. "kafka connected after 3 retries in 4.2s".
+type ConnectionEvent struct {
+	Component string              `json:"component"`
+	Type      ConnectionEventType `json:"type"`
+	Attempt   int                 `json:"attempt"`         // 1-based attempt the event refers to
+	Elapsed   time.Duration       `json:"elapsed"`         // since the first attempt
+	RetryIn   time.Duration       `json:"retry_in"`        // wait before the next attempt, for retrying
+	Error     string              `json:"error,omitempty"` // last error, for retrying, failed and disconnected
+	Time      time.Time           `json:"time"`
+}
+
+// Message describes the event without the component name.
+func (e ConnectionEvent) Message() string {
+	elapsed := e.Elapsed.Round(100 * time.Millisecond)
+	switch e.Type {
+	case ConnectionRetrying:
+		return fmt.Sprintf("attempt %d failed, retrying in %s: %s", e.Attempt, e.RetryIn, e.Error)
+	case ConnectionConnected:
+		switch retries := e.Attempt - 1; retries {
+		case 0:
+			return fmt.Sprintf("connected in %s", elapsed)
+		case 1:
+			return fmt.Sprintf("connected after 1 retry in %s", elapsed)
+		default:
+			return fmt.Sprintf("connected after %d retries in %s", retries, elapsed)
+		}
+	case ConnectionFailed:
+		return fmt.Sprintf("failed after %d attempts in %s: %s", e.Attempt, elapsed, e.Error)
+	case ConnectionLost:
+		if e.Error == "" {
+			return "disconnected"
+		}
+		return "disconnected: " + e.Error
+	case ConnectionReestablished:
+		return "reconnected"
+	}
+	return string(e.Type)
+}
+
+// connectionHistorySize bounds the events kept for late subscribers.
+const connectionHistorySize = 200
+
+var connectionEvents = struct {
+	mu          sync.Mutex
+	history     []ConnectionEvent
+	subscribers map[chan ConnectionEvent]struct{}
+}{subscribers: make(map[chan ConnectionEvent]struct{})}
+
+// PublishConnectionEvent records e and delivers it to every subscriber.
+// Subscribers that are not keeping up miss the event rather than blocking
+// the connecting component.
+func PublishConnectionEvent(e ConnectionEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	connectionEvents.mu.Lock()
+	defer connectionEvents.mu.Unlock()
+	connectionEvents.history = append(connectionEvents.history, e)
+	if over := len(connectionEvents.history) - connectionHistorySize; over > 0 {
+		connectionEvents.history = connectionEvents.history[over:]
+	}
+	for ch := range connectionEvents.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// SubscribeConnectionEvents returns a channel receiving every event
+// published from now on, and a func that ends the subscription and closes
+// the channel.
+func SubscribeConnectionEvents(buffer int) (<-chan ConnectionEvent, func()) {
+	ch := make(chan ConnectionEvent, buffer)
+	connectionEvents.mu.Lock()
+	connectionEvents.subscribers[ch] = struct{}{}
+	connectionEvents.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			connectionEvents.mu.Lock()
+			delete(connectionEvents.subscribers, ch)
+			connectionEvents.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// ConnectionHistory returns the most recent events, oldest first.
+func ConnectionHistory() []ConnectionEvent {
+	connectionEvents.mu.Lock()
+	defer connectionEvents.mu.Unlock()
+	return append([]ConnectionEvent(nil), connectionEvents.history...)
+}
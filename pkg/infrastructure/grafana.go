@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"stackyrd/config"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/resilience"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +17,9 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 )
 
-// GrafanaManager manages Grafana API interactions
+// GrafanaManager manages Grafana API interactions. Calls go through
+// grafana.resilience: retried when idempotent, refused while the breaker
+// is open.
 type GrafanaManager struct {
 	Client   *retryablehttp.Client
 	BaseURL  string
@@ -26,6 +29,8 @@ type GrafanaManager struct {
 	Pool     *WorkerPool // Async worker pool
 	logger   *logger.Logger
 
+	transport *resilience.Transport
+
 	// statusCache avoids re-running an HTTP health-check on every /health poll.
 	statusCache  map[string]interface{}
 	statusExpiry time.Time
@@ -192,21 +197,21 @@ func NewGrafanaManager(cfg config.GrafanaConfig, logger *logger.Logger) (*Grafan
 
 	logger.Info("Initializing Grafana manager", "url", cfg.URL)
 
-	// Create HTTP client with retry logic
+	// Grafana is an outbound client like those of http_clients; its
+	// transport retries, so the retryablehttp client does not
+	outbound, err := NewHTTPClient("grafana", grafanaClientConfig(cfg, 30))
+	if err != nil {
+		return nil, err
+	}
 	client := retryablehttp.NewClient()
-	client.RetryMax = 3
-	client.RetryWaitMin = time.Second
-	client.RetryWaitMax = 5 * time.Second
-	client.HTTPClient.Timeout = 30 * time.Second
+	client.RetryMax = 0
+	client.HTTPClient = outbound.HTTP
+	transport := outbound.transport
 
 	// Set custom logger for go-retryablehttp
 	client.Logger = &grafanaLoggerAdapter{logger: logger}
 
-	// Add authentication if provided
 	if cfg.APIKey != "" {
-		client.RequestLogHook = func(logger retryablehttp.Logger, req *http.Request, retryNumber int) {
-			req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-		}
 		logger.Debug("Using API key authentication")
 	} else if cfg.Username != "" {
 		logger.Debug("Using basic authentication", "username", cfg.Username)
@@ -219,6 +224,8 @@ func NewGrafanaManager(cfg config.GrafanaConfig, logger *logger.Logger) (*Grafan
 		Username: cfg.Username,
 		Password: cfg.Password,
 		logger:   logger,
+
+		transport: transport,
 	}
 
 	// Test connection
@@ -230,15 +237,40 @@ func NewGrafanaManager(cfg config.GrafanaConfig, logger *logger.Logger) (*Grafan
 	logger.Info("Grafana connection test successful")
 
 	// Initialize worker pool for async operations
-	pool := NewWorkerPool(5) // Default 5 workers
+	pool := NewComponentPool("grafana", "grafana", 5)
 	pool.Start()
 
 	manager.Pool = pool
 	logger.Info("Grafana manager initialized with worker pool")
 
+	// Provision declared dashboards/datasources unless the boot sequence
+	// already did it for this process
+	if cfg.Provisioning.Enabled && LastGrafanaProvisionReport() == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		report := manager.Provision(ctx, cfg.Provisioning)
+		cancel()
+		if err := report.Err(); err != nil {
+			logger.Warn("Grafana provisioning incomplete", "error", err)
+		}
+	}
+
 	return manager, nil
 }
 
+// grafanaClientConfig is the outbound client config of the Grafana API
+// with the given timeout in seconds: the API key as bearer token, else
+// basic auth.
+func grafanaClientConfig(cfg config.GrafanaConfig, timeout int) config.HTTPClientConfig {
+	client := config.HTTPClientConfig{BaseURL: cfg.URL, Timeout: timeout, Resilience: cfg.Resilience}
+	switch {
+	case cfg.APIKey != "":
+		client.Auth = config.HTTPClientAuth{Type: HTTPAuthBearer, Token: cfg.APIKey}
+	case cfg.Username != "":
+		client.Auth = config.HTTPClientAuth{Type: HTTPAuthBasic, Username: cfg.Username, Password: cfg.Password}
+	}
+	return client
+}
+
 // testConnection tests the connection to Grafana
 func (gm *GrafanaManager) testConnection() error {
 	req, err := retryablehttp.NewRequest("GET", gm.BaseURL+"/api/health", nil)
@@ -594,6 +626,10 @@ func (gm *GrafanaManager) GetStatus() map[string]interface{} {
 		}
 		if pool != nil {
 			cached["pool_active"] = true
+			cached["pool"] = pool.GetStatus()
+		}
+		if gm.transport != nil {
+			cached["resilience"] = gm.transport.GetStats()
 		}
 		return cached
 	}
@@ -606,6 +642,9 @@ func (gm *GrafanaManager) GetStatus() map[string]interface{} {
 		stats["connected"] = false
 		stats["error"] = err.Error()
 		stats["url"] = baseURL
+		if gm.transport != nil {
+			stats["resilience"] = gm.transport.GetStats()
+		}
 		gm.statusMu.Lock()
 		gm.statusCache = stats
 		gm.statusExpiry = time.Now().Add(30 * time.Second)
@@ -618,8 +657,16 @@ func (gm *GrafanaManager) GetStatus() map[string]interface{} {
 	stats["version"] = health["version"]
 	stats["database"] = health["database"]
 
+	if report := LastGrafanaProvisionReport(); report != nil {
+		stats["provisioning"] = report
+	}
+
 	if pool != nil {
 		stats["pool_active"] = true
+		stats["pool"] = pool.GetStatus()
+	}
+	if gm.transport != nil {
+		stats["resilience"] = gm.transport.GetStats()
 	}
 
 	gm.statusMu.Lock()
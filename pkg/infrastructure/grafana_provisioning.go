@@ -0,0 +1,427 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"gopkg.in/yaml.v3"
+)
+
+// Provisioning actions reported per object
+const (
+	ProvisionCreated   = "created"
+	ProvisionUpdated   = "updated"
+	ProvisionUnchanged = "unchanged"
+	ProvisionFailed    = "failed"
+)
+
+// grafanaHashKey is stored in provisioned dashboards so that unchanged
+// definitions are detected without diffing Grafana's normalized JSON.
+const grafanaHashKey = "stackyrdProvisionHash"
+
+// GrafanaProvisionResult is the outcome of provisioning a single object.
+type GrafanaProvisionResult struct {
+	Kind   string `json:"kind"` // "dashboard" or "datasource"
+	Name   string `json:"name"`
+	UID    string `json:"uid,omitempty"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GrafanaProvisionReport summarizes a provisioning run.
+type GrafanaProvisionReport struct {
+	Results   []GrafanaProvisionResult `json:"results"`
+	Created   int                      `json:"created"`
+	Updated   int                      `json:"updated"`
+	Unchanged int                      `json:"unchanged"`
+	Failed    int                      `json:"failed"`
+	StartedAt time.Time                `json:"started_at"`
+	Duration  string                   `json:"duration"`
+}
+
+func (r *GrafanaProvisionReport) add(res GrafanaProvisionResult) {
+	switch res.Action {
+	case ProvisionCreated:
+		r.Created++
+	case ProvisionUpdated:
+		r.Updated++
+	case ProvisionUnchanged:
+		r.Unchanged++
+	default:
+		r.Failed++
+	}
+	r.Results = append(r.Results, res)
+}
+
+// Summary returns a short human-readable summary, used by the boot TUI.
+func (r *GrafanaProvisionReport) Summary() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged, %d failed", r.Created, r.Updated, r.Unchanged, r.Failed)
+}
+
+// Err returns an error describing failed objects, or nil.
+func (r *GrafanaProvisionReport) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	var failed []string
+	for _, res := range r.Results {
+		if res.Action == ProvisionFailed {
+			failed = append(failed, res.Kind+" "+res.Name)
+		}
+	}
+	return fmt.Errorf("%d of %d provisioning failed: %s", r.Failed, len(r.Results), strings.Join(failed, ", "))
+}
+
+// The last report is kept process-wide so the boot sequence and the
+// infrastructure component do not provision twice.
+var (
+	grafanaProvisionMu   sync.RWMutex
+	grafanaProvisionLast *GrafanaProvisionReport
+)
+
+// LastGrafanaProvisionReport returns the most recent provisioning report.
+func LastGrafanaProvisionReport() *GrafanaProvisionReport {
+	grafanaProvisionMu.RLock()
+	defer grafanaProvisionMu.RUnlock()
+	return grafanaProvisionLast
+}
+
+// ProvisionGrafana runs provisioning with a short-lived client. It is used
+// before the infrastructure registry is started (e.g. by the boot TUI).
+func ProvisionGrafana(ctx context.Context, cfg config.GrafanaConfig, l *logger.Logger) (*GrafanaProvisionReport, error) {
+	if l == nil {
+		l = logger.NewQuiet(false, io.Discard)
+	}
+	clientCfg := grafanaClientConfig(cfg, 10)
+	clientCfg.Resilience = config.ResilienceConfig{RetryAttempts: 2}
+	outbound, err := NewHTTPClient("grafana", clientCfg)
+	if err != nil {
+		return nil, err
+	}
+	client := retryablehttp.NewClient()
+	client.RetryMax = 0
+	client.HTTPClient = outbound.HTTP
+	client.Logger = nil
+
+	gm := &GrafanaManager{
+		Client:   client,
+		BaseURL:  strings.TrimSuffix(cfg.URL, "/"),
+		APIKey:   cfg.APIKey,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		logger:   l,
+	}
+	report := gm.Provision(ctx, cfg.Provisioning)
+	return report, report.Err()
+}
+
+// Provision creates or updates the configured dashboards and data sources.
+// Re-running with the same definitions is a no-op reported as "unchanged".
+func (gm *GrafanaManager) Provision(ctx context.Context, cfg config.GrafanaProvisioningConfig) *GrafanaProvisionReport {
+	report := &GrafanaProvisionReport{StartedAt: time.Now()}
+
+	for _, item := range cfg.Datasources {
+		report.add(gm.provisionDatasource(ctx, item))
+	}
+	for _, item := range cfg.Dashboards {
+		report.add(gm.provisionDashboard(ctx, item))
+	}
+	report.Duration = time.Since(report.StartedAt).Round(time.Millisecond).String()
+
+	gm.logger.Info("Grafana provisioning finished",
+		"created", report.Created, "updated", report.Updated,
+		"unchanged", report.Unchanged, "failed", report.Failed)
+
+	grafanaProvisionMu.Lock()
+	grafanaProvisionLast = report
+	grafanaProvisionMu.Unlock()
+
+	return report
+}
+
+func (gm *GrafanaManager) provisionDashboard(ctx context.Context, item config.GrafanaProvisionItem) GrafanaProvisionResult {
+	res := GrafanaProvisionResult{Kind: "dashboard", Name: item.Name}
+	def, err := loadProvisionDefinition(item)
+	if err != nil {
+		return failProvision(res, err)
+	}
+	// Accept exported dashboards wrapped as {"dashboard": {...}}
+	if inner, ok := def["dashboard"].(map[string]interface{}); ok {
+		def = inner
+	}
+
+	if title, _ := def["title"].(string); title == "" {
+		if item.Name == "" {
+			return failProvision(res, fmt.Errorf("dashboard title or name is required"))
+		}
+		def["title"] = item.Name
+	}
+	if res.Name == "" {
+		res.Name, _ = def["title"].(string)
+	}
+	uid, _ := def["uid"].(string)
+	if uid == "" {
+		uid = grafanaUID(res.Name)
+		def["uid"] = uid
+	}
+	res.UID = uid
+	delete(def, "id")
+	delete(def, "version")
+	delete(def, grafanaHashKey)
+
+	hash, err := provisionHash(def)
+	if err != nil {
+		return failProvision(res, err)
+	}
+
+	status, body, err := gm.doJSON(ctx, http.MethodGet, "/api/dashboards/uid/"+url.PathEscape(uid), nil)
+	if err != nil {
+		return failProvision(res, err)
+	}
+	switch status {
+	case http.StatusNotFound:
+		res.Action = ProvisionCreated
+	case http.StatusOK:
+		var existing struct {
+			Dashboard map[string]interface{} `json:"dashboard"`
+		}
+		if err := json.Unmarshal(body, &existing); err != nil {
+			return failProvision(res, fmt.Errorf("failed to decode dashboard: %w", err))
+		}
+		if existing.Dashboard[grafanaHashKey] == hash {
+			res.Action = ProvisionUnchanged
+			return res
+		}
+		res.Action = ProvisionUpdated
+	default:
+		return failProvision(res, fmt.Errorf("failed to get dashboard (status: %d): %s", status, string(body)))
+	}
+
+	def[grafanaHashKey] = hash
+	payload := map[string]interface{}{
+		"dashboard": def,
+		"overwrite": true,
+		"message":   "Provisioned from config",
+	}
+	status, body, err = gm.doJSON(ctx, http.MethodPost, "/api/dashboards/db", payload)
+	if err != nil {
+		return failProvision(res, err)
+	}
+	if status != http.StatusOK {
+		return failProvision(res, fmt.Errorf("failed to save dashboard (status: %d): %s", status, string(body)))
+	}
+	return res
+}
+
+func (gm *GrafanaManager) provisionDatasource(ctx context.Context, item config.GrafanaProvisionItem) GrafanaProvisionResult {
+	res := GrafanaProvisionResult{Kind: "datasource", Name: item.Name}
+	def, err := loadProvisionDefinition(item)
+	if err != nil {
+		return failProvision(res, err)
+	}
+
+	name, _ := def["name"].(string)
+	if name == "" {
+		if item.Name == "" {
+			return failProvision(res, fmt.Errorf("datasource name is required"))
+		}
+		name = item.Name
+		def["name"] = name
+	}
+	res.Name = name
+	if t, _ := def["type"].(string); t == "" {
+		return failProvision(res, fmt.Errorf("datasource type is required"))
+	}
+
+	status, body, err := gm.doJSON(ctx, http.MethodGet, "/api/datasources/name/"+url.PathEscape(name), nil)
+	if err != nil {
+		return failProvision(res, err)
+	}
+	switch status {
+	case http.StatusNotFound:
+		status, body, err = gm.doJSON(ctx, http.MethodPost, "/api/datasources", def)
+		if err != nil {
+			return failProvision(res, err)
+		}
+		if status != http.StatusOK {
+			return failProvision(res, fmt.Errorf("failed to create datasource (status: %d): %s", status, string(body)))
+		}
+		var created struct {
+			Datasource struct {
+				UID string `json:"uid"`
+			} `json:"datasource"`
+		}
+		_ = json.Unmarshal(body, &created)
+		res.UID = created.Datasource.UID
+		res.Action = ProvisionCreated
+		return res
+	case http.StatusOK:
+	default:
+		return failProvision(res, fmt.Errorf("failed to get datasource (status: %d): %s", status, string(body)))
+	}
+
+	var existing map[string]interface{}
+	if err := json.Unmarshal(body, &existing); err != nil {
+		return failProvision(res, fmt.Errorf("failed to decode datasource: %w", err))
+	}
+	res.UID, _ = existing["uid"].(string)
+
+	// Secure fields are write-only, so they can't be compared and are only
+	// sent when something else changed.
+	if datasourceMatches(existing, def) {
+		res.Action = ProvisionUnchanged
+		return res
+	}
+
+	id, ok := existing["id"].(float64)
+	if !ok {
+		return failProvision(res, fmt.Errorf("datasource %s has no id", name))
+	}
+	def["id"] = int(id)
+	if res.UID != "" {
+		def["uid"] = res.UID
+	}
+	status, body, err = gm.doJSON(ctx, http.MethodPut, fmt.Sprintf("/api/datasources/%d", int(id)), def)
+	if err != nil {
+		return failProvision(res, err)
+	}
+	if status != http.StatusOK {
+		return failProvision(res, fmt.Errorf("failed to update datasource (status: %d): %s", status, string(body)))
+	}
+	res.Action = ProvisionUpdated
+	return res
+}
+
+// doJSON performs an API request and returns the status code and raw body.
+func (gm *GrafanaManager) doJSON(ctx context.Context, method, path string, payload interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, gm.BaseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if gm.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+gm.APIKey)
+	} else if gm.Username != "" {
+		req.SetBasicAuth(gm.Username, gm.Password)
+	}
+
+	resp, err := gm.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+// loadProvisionDefinition reads a JSON or YAML definition from a file or
+// from the inline config value. YAML is a superset of JSON, so one decoder
+// handles both.
+func loadProvisionDefinition(item config.GrafanaProvisionItem) (map[string]interface{}, error) {
+	var data []byte
+	switch {
+	case item.File != "":
+		b, err := os.ReadFile(item.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", item.File, err)
+		}
+		data = b
+	case item.Inline != "":
+		data = []byte(item.Inline)
+	default:
+		return nil, fmt.Errorf("either file or inline definition is required")
+	}
+
+	var def map[string]interface{}
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+	if def == nil {
+		return nil, fmt.Errorf("empty definition")
+	}
+	return def, nil
+}
+
+// provisionHash returns a stable hash of a definition. encoding/json sorts
+// map keys, so equal definitions always produce the same hash.
+func provisionHash(def map[string]interface{}) (string, error) {
+	data, err := json.Marshal(def)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal definition: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// datasourceMatches reports whether every non-secure field of the desired
+// definition already has the same value in Grafana.
+func datasourceMatches(existing, desired map[string]interface{}) bool {
+	for k, v := range desired {
+		if k == "secureJsonData" || k == "id" || k == "uid" {
+			continue
+		}
+		// Round-trip through JSON so numbers and nested maps compare equally
+		want, err1 := json.Marshal(v)
+		got, err2 := json.Marshal(existing[k])
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		var a, b interface{}
+		_ = json.Unmarshal(want, &a)
+		_ = json.Unmarshal(got, &b)
+		if !reflect.DeepEqual(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+var grafanaUIDPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// grafanaUID derives a stable dashboard UID (max 40 chars) from a title.
+// Titles without ASCII letters or digits get a hash of the title, since an
+// empty UID makes Grafana pick a random one and duplicate the dashboard on
+// every run.
+func grafanaUID(title string) string {
+	uid := strings.Trim(grafanaUIDPattern.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(uid) > 40 {
+		uid = strings.TrimRight(uid[:40], "-")
+	}
+	if uid == "" {
+		sum := sha256.Sum256([]byte(title))
+		uid = "dashboard-" + hex.EncodeToString(sum[:6])
+	}
+	return uid
+}
+
+func failProvision(res GrafanaProvisionResult, err error) GrafanaProvisionResult {
+	res.Action = ProvisionFailed
+	res.Error = err.Error()
+	return res
+}
@@ -0,0 +1,326 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/resilience"
+)
+
+// Defaults of external service checks.
+const (
+	defaultCheckInterval   = 30 * time.Second
+	defaultCheckTimeout    = 5 * time.Second
+	defaultCheckHistory    = 2880
+	defaultCertWarningDays = 14
+	maxCheckBody           = 64 * 1024 // body bytes searched for the expected substring
+)
+
+// HTTPCheck is the result of one check of an external service.
+type HTTPCheck struct {
+	Time          time.Time  `json:"time"`
+	Up            bool       `json:"up"`
+	StatusCode    int        `json:"status_code,omitempty"`
+	LatencyMS     float64    `json:"latency_ms"`
+	Error         string     `json:"error,omitempty"`
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+}
+
+// HTTPServiceStatus summarizes the kept checks of an external service.
+// Uptime is the percentage of checks that passed; latencies are of the
+// passing checks.
+type HTTPServiceStatus struct {
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	Interval      float64    `json:"interval_seconds"`
+	Last          *HTTPCheck `json:"last,omitempty"`
+	Checks        int        `json:"checks"`
+	Uptime        float64    `json:"uptime_percent"`
+	LatencyP50MS  float64    `json:"latency_p50_ms"`
+	LatencyP95MS  float64    `json:"latency_p95_ms"`
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+	CertExpiring  bool       `json:"cert_expiring,omitempty"` // within cert_warning_days
+	Breaker       string     `json:"breaker"`                 // closed, open or half-open
+}
+
+// HTTPManager checks the external services periodically and keeps a
+// rolling history of the results. Checks go through monitoring.external.
+// resilience: while the breaker of a failing host is open its checks fail
+// without a request.
+type HTTPManager struct {
+	services    []config.ExternalService
+	interval    time.Duration
+	timeout     time.Duration
+	historySize int
+	Client      *http.Client
+	transport   *resilience.Transport
+	logger      *logger.Logger
+
+	mu      sync.RWMutex
+	history map[string][]HTTPCheck // oldest first
+
+	ctx    context.Context // cancelled by Close, ending checks in flight
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewHTTPManager creates a manager for the services of cfg; call Start to
+// begin checking.
+func NewHTTPManager(cfg config.ExternalConfig, l *logger.Logger) *HTTPManager {
+	transport := resilientTransport("external", nil, cfg.Resilience)
+	h := &HTTPManager{
+		services:    cfg.Services,
+		interval:    time.Duration(cfg.Interval) * time.Second,
+		timeout:     time.Duration(cfg.Timeout) * time.Second,
+		historySize: cfg.HistorySize,
+		// Checks report redirects as they are, like a monitoring probe
+		Client: &http.Client{Transport: transport, CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+		transport: transport,
+		logger:    l,
+		history:   make(map[string][]HTTPCheck),
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	if h.interval <= 0 {
+		h.interval = defaultCheckInterval
+	}
+	if h.timeout <= 0 {
+		h.timeout = defaultCheckTimeout
+	}
+	if h.historySize <= 0 {
+		h.historySize = defaultCheckHistory
+	}
+	return h
+}
+
+// Name returns the display name of the component.
+func (h *HTTPManager) Name() string {
+	return "External Services"
+}
+
+// Start checks every service now and then at its interval until Close.
+func (h *HTTPManager) Start() {
+	for _, svc := range h.services {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			ticker := time.NewTicker(h.intervalOf(svc))
+			defer ticker.Stop()
+			for {
+				check := h.Check(h.ctx, svc)
+				if h.ctx.Err() != nil {
+					return
+				}
+				h.record(svc.Name, check)
+				select {
+				case <-h.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// Close stops the periodic checks.
+func (h *HTTPManager) Close() error {
+	h.cancel()
+	h.wg.Wait()
+	return nil
+}
+
+func (h *HTTPManager) intervalOf(svc config.ExternalService) time.Duration {
+	if svc.Interval > 0 {
+		return time.Duration(svc.Interval) * time.Second
+	}
+	return h.interval
+}
+
+// Check requests svc once. Without expected statuses any status below 500
+// passes, like the doctor and the external_down alert rule.
+func (h *HTTPManager) Check(ctx context.Context, svc config.ExternalService) HTTPCheck {
+	timeout := h.timeout
+	if svc.Timeout > 0 {
+		timeout = time.Duration(svc.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	check := HTTPCheck{Time: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, svc.URL, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		check.LatencyMS = msSince(check.Time)
+		check.Error = err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+	var body []byte
+	if svc.ExpectedBody != "" {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxCheckBody))
+	}
+	check.LatencyMS = msSince(check.Time)
+	check.StatusCode = resp.StatusCode
+	if svc.CheckCertificate {
+		check.CertExpiresAt = certificateExpiry(resp.TLS)
+	}
+
+	switch {
+	case len(svc.ExpectedStatus) > 0 && !slices.Contains(svc.ExpectedStatus, resp.StatusCode):
+		check.Error = fmt.Sprintf("status %d, expected %v", resp.StatusCode, svc.ExpectedStatus)
+	case len(svc.ExpectedStatus) == 0 && resp.StatusCode >= http.StatusInternalServerError:
+		check.Error = fmt.Sprintf("status %d", resp.StatusCode)
+	case err != nil:
+		check.Error = "reading body: " + err.Error()
+	case svc.ExpectedBody != "" && !strings.Contains(string(body), svc.ExpectedBody):
+		check.Error = fmt.Sprintf("body does not contain %q", svc.ExpectedBody)
+	case svc.CheckCertificate && check.CertExpiresAt == nil:
+		check.Error = "no TLS certificate"
+	default:
+		check.Up = true
+	}
+	return check
+}
+
+// certificateExpiry returns when the leaf certificate of state expires.
+func certificateExpiry(state *tls.ConnectionState) *time.Time {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	expires := state.PeerCertificates[0].NotAfter
+	return &expires
+}
+
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+func (h *HTTPManager) record(name string, check HTTPCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	checks := h.history[name]
+	if len(checks) >= h.historySize {
+		checks = slices.Delete(checks, 0, len(checks)-h.historySize+1)
+	}
+	h.history[name] = append(checks, check)
+	if !check.Up && (len(checks) == 0 || checks[len(checks)-1].Up) && h.logger != nil {
+		h.logger.Warn("External service check failed", "service", name, "error", check.Error)
+	}
+}
+
+// Statuses summarizes the checks of every service, in configuration
+// order.
+func (h *HTTPManager) Statuses() []HTTPServiceStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	statuses := make([]HTTPServiceStatus, 0, len(h.services))
+	for _, svc := range h.services {
+		statuses = append(statuses, h.summarize(svc, h.history[svc.Name]))
+	}
+	return statuses
+}
+
+func (h *HTTPManager) summarize(svc config.ExternalService, checks []HTTPCheck) HTTPServiceStatus {
+	status := HTTPServiceStatus{
+		Name:     svc.Name,
+		URL:      svc.URL,
+		Interval: h.intervalOf(svc).Seconds(),
+		Checks:   len(checks),
+		Breaker:  resilience.StateClosed.String(),
+	}
+	if u, err := url.Parse(svc.URL); err == nil {
+		status.Breaker = h.transport.State(u.Host).String()
+	}
+	if len(checks) == 0 {
+		return status
+	}
+	last := checks[len(checks)-1]
+	status.Last = &last
+
+	var latencies []float64
+	for _, check := range checks {
+		if check.Up {
+			latencies = append(latencies, check.LatencyMS)
+		}
+	}
+	status.Uptime = float64(len(latencies)) * 100 / float64(len(checks))
+	sort.Float64s(latencies)
+	status.LatencyP50MS = percentile(latencies, 0.50)
+	status.LatencyP95MS = percentile(latencies, 0.95)
+
+	for i := len(checks) - 1; i >= 0; i-- {
+		if expires := checks[i].CertExpiresAt; expires != nil {
+			warning := svc.CertWarningDays
+			if warning <= 0 {
+				warning = defaultCertWarningDays
+			}
+			status.CertExpiresAt = expires
+			status.CertExpiring = time.Until(*expires) < time.Duration(warning)*24*time.Hour
+			break
+		}
+	}
+	return status
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// History returns the kept checks of service since the given time, oldest
+// first. ok is false for services that are not configured.
+func (h *HTTPManager) History(service string, since time.Time) (checks []HTTPCheck, ok bool) {
+	for _, svc := range h.services {
+		ok = ok || svc.Name == service
+	}
+	if !ok {
+		return nil, false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	all := h.history[service]
+	start := sort.Search(len(all), func(i int) bool { return !all[i].Time.Before(since) })
+	return slices.Clone(all[start:]), true
+}
+
+// GetStatus reports how many services are up and the breakers of their
+// hosts. It deliberately reports no "connected" flag: an external outage
+// is no infrastructure failure.
+func (h *HTTPManager) GetStatus() map[string]interface{} {
+	var up, down []string
+	for _, status := range h.Statuses() {
+		switch {
+		case status.Last == nil:
+		case status.Last.Up:
+			up = append(up, status.Name)
+		default:
+			down = append(down, status.Name)
+		}
+	}
+	return map[string]interface{}{
+		"services":   len(h.services),
+		"up":         len(up),
+		"down":       down,
+		"resilience": h.transport.GetStats(),
+	}
+}
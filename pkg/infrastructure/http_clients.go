@@ -0,0 +1,280 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/dns"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/resilience"
+)
+
+// Auth types of outbound clients (http_clients.<name>.auth.type).
+const (
+	HTTPAuthBearer = "bearer"
+	HTTPAuthBasic  = "basic"
+	HTTPAuthHeader = "header"
+)
+
+// defaultHTTPClientTimeout bounds a request of an outbound client whose
+// timeout is not configured.
+const defaultHTTPClientTimeout = 30 * time.Second
+
+// maxErrorBody bounds the response body kept in an HTTPStatusError.
+const maxErrorBody = 4096
+
+// HTTPClient is a named outbound HTTP client: requests go through the
+// breaker, retries and bulkhead of its resilience config and carry its
+// auth and headers. HTTP is the configured *http.Client, usable directly.
+type HTTPClient struct {
+	Name    string
+	BaseURL string
+	HTTP    *http.Client
+
+	base      *url.URL
+	transport *resilience.Transport
+}
+
+// NewHTTPClient creates the outbound client name from cfg.
+func NewHTTPClient(name string, cfg config.HTTPClientConfig) (*HTTPClient, error) {
+	c := &HTTPClient{Name: name, BaseURL: strings.TrimSuffix(cfg.BaseURL, "/")}
+	if cfg.BaseURL != "" {
+		base, err := url.Parse(c.BaseURL + "/")
+		if err != nil || base.Scheme == "" || base.Host == "" {
+			return nil, fmt.Errorf("http client %s: invalid base_url %q", name, cfg.BaseURL)
+		}
+		c.base = base
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DialContext = dns.DialContext
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("http client %s: invalid proxy %q", name, cfg.Proxy)
+		}
+		base.Proxy = http.ProxyURL(proxy)
+	}
+	switch cfg.Auth.Type {
+	case "", HTTPAuthBearer, HTTPAuthBasic:
+	case HTTPAuthHeader:
+		if cfg.Auth.Header == "" {
+			return nil, fmt.Errorf("http client %s: auth type header needs auth.header", name)
+		}
+	default:
+		return nil, fmt.Errorf("http client %s: unknown auth type %q", name, cfg.Auth.Type)
+	}
+
+	c.transport = resilientTransport(name, base, cfg.Resilience)
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHTTPClientTimeout
+	}
+	c.HTTP = &http.Client{
+		Transport: &headerTransport{base: c.transport, auth: cfg.Auth, headers: cfg.Headers},
+		Timeout:   timeout,
+	}
+	return c, nil
+}
+
+// URL resolves path, which may carry a query, against the base URL;
+// absolute URLs are kept.
+func (c *HTTPClient) URL(path string) string {
+	if c.base == nil || strings.Contains(path, "://") {
+		return path
+	}
+	ref, err := url.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return c.BaseURL + "/" + strings.TrimPrefix(path, "/")
+	}
+	return c.base.ResolveReference(ref).String()
+}
+
+// NewRequest creates a request for path relative to the base URL.
+func (c *HTTPClient) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, method, c.URL(path), body)
+}
+
+// Do sends req.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return c.HTTP.Do(req)
+}
+
+// Get requests path.
+func (c *HTTPClient) Get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// HTTPStatusError is the error of a JSON call answered with a status
+// outside 2xx.
+type HTTPStatusError struct {
+	Client string
+	Method string
+	URL    string
+	Status int
+	Body   string // at most 4 KiB of the response body
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s %s %s: status %d", e.Client, e.Method, e.URL, e.Status)
+}
+
+// DoJSON sends in (when not nil) as JSON to path and decodes the response
+// into out (when not nil). A status outside 2xx is an *HTTPStatusError.
+func (c *HTTPClient) DoJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := c.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &HTTPStatusError{Client: c.Name, Method: method, URL: req.URL.Redacted(), Status: resp.StatusCode, Body: string(data)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s %s: decoding response: %w", c.Name, method, req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// GetStats returns the base URL and the breakers and bulkhead of the
+// client.
+func (c *HTTPClient) GetStats() map[string]interface{} {
+	stats := c.transport.GetStats()
+	stats["base_url"] = c.BaseURL
+	return stats
+}
+
+// headerTransport adds the auth and headers of a client to its requests.
+type headerTransport struct {
+	base    http.RoundTripper
+	auth    config.HTTPClientAuth
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	switch t.auth.Type {
+	case HTTPAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+t.auth.Token)
+	case HTTPAuthBasic:
+		req.SetBasicAuth(t.auth.Username, t.auth.Password)
+	case HTTPAuthHeader:
+		req.Header.Set(t.auth.Header, t.auth.Token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// HTTPClientManager holds the outbound clients of http_clients, registered
+// as "http_clients". Services take their client by name instead of
+// building one.
+type HTTPClientManager struct {
+	clients map[string]*HTTPClient
+}
+
+// NewHTTPClientManager creates the clients of cfg.
+func NewHTTPClientManager(cfg config.HTTPClientsConfig) (*HTTPClientManager, error) {
+	m := &HTTPClientManager{clients: make(map[string]*HTTPClient, len(cfg))}
+	for name, c := range cfg {
+		client, err := NewHTTPClient(name, c)
+		if err != nil {
+			return nil, err
+		}
+		m.clients[name] = client
+	}
+	return m, nil
+}
+
+// Name returns the display name of the component.
+func (m *HTTPClientManager) Name() string {
+	return "HTTP Clients"
+}
+
+// Client returns the client configured as name.
+func (m *HTTPClientManager) Client(name string) (*HTTPClient, bool) {
+	if m == nil {
+		return nil, false
+	}
+	c, ok := m.clients[name]
+	return c, ok
+}
+
+// Names returns the names of the clients, sorted.
+func (m *HTTPClientManager) Names() []string {
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes the idle connections of every client.
+func (m *HTTPClientManager) Close() error {
+	for _, c := range m.clients {
+		c.HTTP.CloseIdleConnections()
+	}
+	return nil
+}
+
+// GetStatus reports the clients with their breakers. Like the external
+// checker it reports no "connected" flag: a failing upstream is no
+// infrastructure failure.
+func (m *HTTPClientManager) GetStatus() map[string]interface{} {
+	clients := make(map[string]interface{}, len(m.clients))
+	for name, c := range m.clients {
+		clients[name] = c.GetStats()
+	}
+	return map[string]interface{}{
+		"count":   len(m.clients),
+		"clients": clients,
+	}
+}
+
+func init() {
+	RegisterComponent("http_clients", func(cfg *config.Config, l *logger.Logger) (InfrastructureComponent, error) {
+		if len(cfg.HTTPClients) == 0 {
+			return nil, nil
+		}
+		return NewHTTPClientManager(cfg.HTTPClients)
+	})
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"stackyrd/config"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+	"stackyrd/pkg/tracing"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -40,7 +43,7 @@ func NewKafkaManager(cfg config.KafkaConfig, logger *logger.Logger) (*KafkaManag
 	}
 
 	// Initialize worker pool for async operations
-	pool := NewWorkerPool(5) // Fewer workers for Kafka (producer heavy)
+	pool := NewComponentPool("kafka", "kafka", 5) // Fewer workers for Kafka (producer heavy)
 	pool.Start()
 
 	return &KafkaManager{
@@ -68,6 +71,9 @@ func (k *KafkaManager) GetStatus() map[string]interface{} {
 	stats["connected"] = true // Assuming connected if initialized for now, complex to check liveness without producing
 	stats["brokers"] = k.Brokers
 	stats["group_id"] = k.GroupID
+	if k.Pool != nil {
+		stats["pool"] = k.Pool.GetStatus()
+	}
 	return stats
 }
 
@@ -119,7 +125,10 @@ func (h *consumerHandler) Setup(sarama.ConsumerGroupSession) error   { return ni
 func (h *consumerHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
 func (h *consumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for message := range claim.Messages() {
-		if err := h.handler(message.Key, message.Value); err != nil {
+		_, span := startConsumeSpan(session.Context(), message)
+		err := h.handler(message.Key, message.Value)
+		tracing.End(span, err)
+		if err != nil {
 			h.logger.Error("Error handling message", err)
 		}
 		session.MarkMessage(message, "")
@@ -174,11 +183,18 @@ func (k *KafkaManager) PublishBatchWithKeysAsync(ctx context.Context, topic stri
 // ConsumeAsync starts consuming messages asynchronously.
 // This method starts the consumer in a goroutine and returns immediately.
 func (k *KafkaManager) ConsumeAsync(ctx context.Context, topic string, handler func(key, value []byte) error) {
-	k.SubmitAsyncJob(func() {
-		if err := k.Consume(ctx, topic, handler); err != nil {
+	consume := func() error {
+		err := k.Consume(ctx, topic, handler)
+		if err != nil {
 			k.logger.Error("Async consumer error", err, "topic", topic)
 		}
-	})
+		return err
+	}
+	if k.Pool == nil {
+		go consume()
+		return
+	}
+	k.Pool.SubmitTagged("kafka:consume:"+topic, consume)
 }
 
 // Sync Methods (for backward compatibility and internal use)
@@ -189,8 +205,7 @@ func (k *KafkaManager) Publish(ctx context.Context, topic string, message []byte
 		Value: sarama.ByteEncoder(message),
 	}
 
-	_, _, err := k.Producer.SendMessage(msg)
-	return err
+	return k.sendMessage(ctx, msg)
 }
 
 func (k *KafkaManager) PublishWithKey(ctx context.Context, topic string, key, message []byte) error {
@@ -200,8 +215,176 @@ func (k *KafkaManager) PublishWithKey(ctx context.Context, topic string, key, me
 		Value: sarama.ByteEncoder(message),
 	}
 
-	_, _, err := k.Producer.SendMessage(msg)
-	return err
+	return k.sendMessage(ctx, msg)
+}
+
+// Broker-agnostic messaging (messaging.Broker)
+
+var _ messaging.Broker = (*KafkaManager)(nil)
+
+// BrokerType implements messaging.Broker.
+func (k *KafkaManager) BrokerType() string {
+	return messaging.BrokerKafka
+}
+
+// PublishMessage implements messaging.Broker. Headers are sent as Kafka
+// record headers.
+func (k *KafkaManager) PublishMessage(ctx context.Context, msg messaging.Message) error {
+	if k == nil || k.Producer == nil {
+		return messaging.ErrNotConnected
+	}
+
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if len(msg.Key) > 0 {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	if !msg.Timestamp.IsZero() {
+		pm.Timestamp = msg.Timestamp
+	}
+	for name, value := range msg.Headers {
+		pm.Headers = append(pm.Headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(value)})
+	}
+
+	return k.sendMessage(ctx, pm)
+}
+
+// Subscribe implements messaging.Broker using a consumer group. Ack marks
+// the offset. Offsets commit cumulatively per partition, so a message
+// nacked with requeue stops its claim with the offset reset to it, and the
+// consumer rejoins and redelivers it after kafkaRedeliveryDelay. Ending a
+// claim ends the whole session, so a requeue also restarts the claims of
+// the subscription's other partitions from their marked offsets; a nack
+// without requeue marks it like an ack. With ManualAck, ack or nack before
+// the handler returns, in order: marking a message commits the ones before
+// it.
+func (k *KafkaManager) Subscribe(ctx context.Context, topic string, opts messaging.SubscribeOptions, handler messaging.Handler) (messaging.Subscription, error) {
+	if k == nil || k.Producer == nil {
+		return nil, messaging.ErrNotConnected
+	}
+
+	groupID := opts.Group
+	if groupID == "" {
+		groupID = k.GroupID
+	}
+
+	config := sarama.NewConfig()
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	consumerGroup, err := sarama.NewConsumerGroup(k.Brokers, groupID, config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating consumer group: %w", err)
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &kafkaSubscription{topic: topic, group: consumerGroup, cancel: cancel, done: make(chan struct{})}
+	claimHandler := KafkaClaimHandler(subCtx, opts, handler, k.logger)
+
+	go func() {
+		defer close(sub.done)
+		for subCtx.Err() == nil {
+			if err := consumerGroup.Consume(subCtx, []string{topic}, claimHandler); err != nil {
+				k.logger.Error("Kafka subscription error", err, "topic", topic)
+				select {
+				case <-subCtx.Done():
+				case <-time.After(time.Second):
+				}
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+type kafkaSubscription struct {
+	topic  string
+	group  sarama.ConsumerGroup
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (s *kafkaSubscription) Topic() string { return s.topic }
+
+func (s *kafkaSubscription) Unsubscribe() error {
+	s.cancel()
+	<-s.done
+	return s.group.Close()
+}
+
+// kafkaRedeliveryDelay is how long a claim waits after a requeued nack
+// before the consumer rejoins, so a failing message is not retried in a
+// tight loop.
+const kafkaRedeliveryDelay = time.Second
+
+// KafkaClaimHandler returns the consumer group handler Subscribe consumes
+// with, which turns the messages of each claim into deliveries for
+// handler. It is exported so the acknowledgement of claims can be tested
+// with mock sessions.
+func KafkaClaimHandler(ctx context.Context, opts messaging.SubscribeOptions, handler messaging.Handler, logger *logger.Logger) sarama.ConsumerGroupHandler {
+	return &brokerClaimHandler{ctx: ctx, opts: opts, handler: handler, logger: logger}
+}
+
+// brokerClaimHandler adapts sarama claims to messaging deliveries
+type brokerClaimHandler struct {
+	ctx     context.Context
+	opts    messaging.SubscribeOptions
+	handler messaging.Handler
+	logger  *logger.Logger
+}
+
+func (h *brokerClaimHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *brokerClaimHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+func (h *brokerClaimHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		message := message
+		headers := make(map[string]string, len(message.Headers))
+		for _, hdr := range message.Headers {
+			headers[string(hdr.Key)] = string(hdr.Value)
+		}
+		var requeued atomic.Bool
+		d := messaging.NewDelivery(messaging.Message{
+			Topic:     message.Topic,
+			Key:       message.Key,
+			Value:     message.Value,
+			Headers:   headers,
+			Timestamp: message.Timestamp,
+		}, func() error {
+			session.MarkMessage(message, "")
+			return nil
+		}, func(requeue bool) error {
+			if requeue {
+				requeued.Store(true)
+			} else {
+				session.MarkMessage(message, "")
+			}
+			return nil
+		})
+
+		ctx, span := startConsumeSpan(h.ctx, message)
+		err := messaging.Dispatch(ctx, d, h.opts, h.handler)
+		tracing.End(span, err)
+		if err != nil {
+			h.logger.Error("Error handling message", err, "topic", message.Topic)
+		}
+
+		if requeued.Load() {
+			// Marking a later message would commit past this one, so end
+			// the claim here: sarama cancels the session as soon as any
+			// claim returns, so every claim of this member stops, and the
+			// next session starts this partition again at this offset and
+			// the others at their marked offsets
+			session.ResetOffset(message.Topic, message.Partition, message.Offset, "")
+			select {
+			case <-session.Context().Done():
+			case <-time.After(kafkaRedeliveryDelay):
+			}
+			return nil
+		}
+	}
+	return nil
 }
 
 // Worker Pool Operations
@@ -0,0 +1,322 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/IBM/sarama"
+)
+
+// Decodings of message keys and values in the Kafka console.
+const (
+	KafkaDecodeAuto   = "auto"   // JSON objects and arrays, otherwise text
+	KafkaDecodeJSON   = "json"   // any JSON value, otherwise text
+	KafkaDecodeString = "string" // text
+	KafkaDecodeBase64 = "base64" // raw bytes
+)
+
+// ErrUnknownTopic is returned for topics or partitions the cluster does not
+// have.
+var ErrUnknownTopic = errors.New("unknown topic or partition")
+
+// KafkaTopic is a topic of the cluster.
+type KafkaTopic struct {
+	Name       string `json:"name"`
+	Partitions int    `json:"partitions"`
+}
+
+// KafkaMessage is a consumed message, its key and value decoded. Encoding
+// tells how: "json", "string" or "base64" when the bytes are not valid
+// text.
+type KafkaMessage struct {
+	Topic       string            `json:"topic"`
+	Partition   int32             `json:"partition"`
+	Offset      int64             `json:"offset"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Key         interface{}       `json:"key,omitempty"`
+	KeyEncoding string            `json:"key_encoding,omitempty"`
+	Value       interface{}       `json:"value"`
+	Encoding    string            `json:"encoding"`
+	Size        int               `json:"size"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// DecodeKafkaPayload decodes data as mode asks, falling back to text and
+// then base64 when data is not valid JSON or text.
+func DecodeKafkaPayload(data []byte, mode string) (interface{}, string) {
+	switch mode {
+	case KafkaDecodeBase64:
+		return base64.StdEncoding.EncodeToString(data), KafkaDecodeBase64
+	case KafkaDecodeJSON, KafkaDecodeAuto, "":
+		var v interface{}
+		if json.Unmarshal(data, &v) == nil {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				return v, KafkaDecodeJSON
+			}
+			if mode == KafkaDecodeJSON {
+				return v, KafkaDecodeJSON
+			}
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data), KafkaDecodeString
+	}
+	return base64.StdEncoding.EncodeToString(data), KafkaDecodeBase64
+}
+
+// consoleConfig is the client configuration of the Kafka console, which
+// reads partitions directly without a consumer group and gives up early on
+// unreachable brokers, as someone is waiting for the answer.
+func consoleConfig() *sarama.Config {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "stackyrd-console"
+	cfg.Consumer.Return.Errors = true
+	cfg.Net.DialTimeout = 5 * time.Second
+	cfg.Net.ReadTimeout = 10 * time.Second
+	cfg.Metadata.Retry.Max = 1
+	return cfg
+}
+
+// Topics lists the topics of the cluster by name, without internal ones.
+func (k *KafkaManager) Topics(ctx context.Context) ([]KafkaTopic, error) {
+	client, err := sarama.NewClient(k.Brokers, consoleConfig())
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	names, err := client.Topics()
+	if err != nil {
+		return nil, err
+	}
+	topics := make([]KafkaTopic, 0, len(names))
+	for _, name := range names {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		partitions, err := client.Partitions(name)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, KafkaTopic{Name: name, Partitions: len(partitions)})
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics, nil
+}
+
+// PublishTest produces one message to topic and returns where it was
+// written. Trace and correlation headers are added as for any message.
+func (k *KafkaManager) PublishTest(ctx context.Context, topic string, key, value []byte, headers map[string]string) (int32, int64, error) {
+	if k == nil || k.Producer == nil {
+		return 0, 0, errors.New("kafka producer is not connected")
+	}
+	msg := &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)}
+	if len(key) > 0 {
+		msg.Key = sarama.ByteEncoder(key)
+	}
+	for name, v := range headers {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(v)})
+	}
+	return k.produce(ctx, msg)
+}
+
+// LastMessages reads the latest limit messages of topic, of one partition
+// or of all when partition is negative, newest first. Reading stops when
+// ctx is done; the messages read until then are returned with ctx's error.
+func (k *KafkaManager) LastMessages(ctx context.Context, topic string, partition int32, limit int, decode string) ([]KafkaMessage, error) {
+	client, err := sarama.NewClient(k.Brokers, consoleConfig())
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	partitions, err := topicPartitions(client, topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	messages := []KafkaMessage{}
+	for _, p := range partitions {
+		oldest, err := client.GetOffset(topic, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := client.GetOffset(topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+		if newest <= oldest {
+			continue
+		}
+		read, err := readPartition(ctx, consumer, topic, p, max(oldest, newest-int64(limit)), newest, decode)
+		messages = append(messages, read...)
+		if err != nil {
+			sortNewestFirst(messages)
+			return messages, err
+		}
+	}
+	sortNewestFirst(messages)
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// readPartition reads the messages of partition p from start up to end,
+// exclusive. Offsets without a message, such as the marker closing a
+// transaction, are skipped by the consumer; when one is the last offset,
+// reading ends with ctx.
+func readPartition(ctx context.Context, consumer sarama.Consumer, topic string, p int32, start, end int64, decode string) ([]KafkaMessage, error) {
+	pc, err := consumer.ConsumePartition(topic, p, start)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+	var messages []KafkaMessage
+	for {
+		select {
+		case msg := <-pc.Messages():
+			messages = append(messages, decodeKafkaMessage(msg, decode))
+			if msg.Offset+1 >= end {
+				return messages, nil
+			}
+		case err := <-pc.Errors():
+			return messages, err
+		case <-ctx.Done():
+			return messages, ctx.Err()
+		}
+	}
+}
+
+// TailMessages calls fn with every message produced to topic, of one
+// partition or of all when partition is negative, from now until ctx is
+// done or fn fails.
+func (k *KafkaManager) TailMessages(ctx context.Context, topic string, partition int32, decode string, fn func(KafkaMessage) error) error {
+	client, err := sarama.NewClient(k.Brokers, consoleConfig())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	partitions, err := topicPartitions(client, topic, partition)
+	if err != nil {
+		return err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages := make(chan *sarama.ConsumerMessage)
+	errs := make(chan error, len(partitions))
+	for _, p := range partitions {
+		pc, err := consumer.ConsumePartition(topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+		defer pc.Close()
+		go func() {
+			for {
+				select {
+				case msg, ok := <-pc.Messages():
+					if !ok {
+						return
+					}
+					select {
+					case messages <- msg:
+					case <-ctx.Done():
+						return
+					}
+				case err, ok := <-pc.Errors():
+					if ok {
+						errs <- err
+					}
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case msg := <-messages:
+			if err := fn(decodeKafkaMessage(msg, decode)); err != nil {
+				return err
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// topicPartitions returns the partitions of topic, or only partition when
+// it is not negative.
+func topicPartitions(client sarama.Client, topic string, partition int32) ([]int32, error) {
+	partitions, err := client.Partitions(topic)
+	if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if partition < 0 {
+		return partitions, nil
+	}
+	for _, p := range partitions {
+		if p == partition {
+			return []int32{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s has no partition %d", ErrUnknownTopic, topic, partition)
+}
+
+func decodeKafkaMessage(msg *sarama.ConsumerMessage, decode string) KafkaMessage {
+	m := KafkaMessage{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+		Size:      len(msg.Value),
+	}
+	if msg.Key != nil {
+		m.Key, m.KeyEncoding = DecodeKafkaPayload(msg.Key, KafkaDecodeString)
+	}
+	m.Value, m.Encoding = DecodeKafkaPayload(msg.Value, decode)
+	if len(msg.Headers) > 0 {
+		m.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			m.Headers[string(h.Key)] = string(h.Value)
+		}
+	}
+	return m
+}
+
+func sortNewestFirst(messages []KafkaMessage) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		if a.Partition != b.Partition {
+			return a.Partition < b.Partition
+		}
+		return a.Offset > b.Offset
+	})
+}
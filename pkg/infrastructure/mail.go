@@ -0,0 +1,505 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+)
+
+// TLS modes of the mail component (mail.tls).
+const (
+	MailTLSStartTLS = "starttls"
+	MailTLSImplicit = "tls"
+	MailTLSNone     = "none"
+)
+
+const defaultMailTimeout = 30 * time.Second
+
+// ErrMailTemplateNotFound is returned for an unknown template name.
+var ErrMailTemplateNotFound = errors.New("mail template not found")
+
+// MailMessage is an email to send. Text, HTML or both make up the body;
+// with both it is sent as multipart/alternative.
+type MailMessage struct {
+	To      []string
+	Cc      []string
+	Bcc     []string
+	ReplyTo string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// mailTemplate is a parsed template file. html is nil when it defines no
+// "html" block.
+type mailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// MailManager sends email over SMTP on its worker pool, bounding the
+// sessions open at once, and keeps the outcome of the last send for
+// GetStatus.
+type MailManager struct {
+	addr      string
+	host      string
+	from      string
+	fromAddr  string
+	auth      smtp.Auth
+	tlsMode   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	logger    *logger.Logger
+	Pool      *WorkerPool
+
+	templatesMu sync.RWMutex
+	templates   map[string]mailTemplate
+
+	mu        sync.Mutex
+	closed    bool
+	sent      int64
+	failed    int64
+	lastSend  time.Time
+	lastTo    int
+	lastError string
+}
+
+// NewMailManager creates the mail component and loads the templates of
+// cfg.TemplatesDir. Nothing is sent until the first message.
+func NewMailManager(cfg config.MailConfig, l *logger.Logger) (*MailManager, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, fmt.Errorf("mail: host and from are required")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid from address %q: %w", cfg.From, err)
+	}
+	mode := strings.ToLower(cfg.TLS)
+	switch mode {
+	case "":
+		mode = MailTLSStartTLS
+	case MailTLSStartTLS, MailTLSImplicit, MailTLSNone:
+	default:
+		return nil, fmt.Errorf("mail: unknown tls mode %q, use %s, %s or %s", cfg.TLS, MailTLSStartTLS, MailTLSImplicit, MailTLSNone)
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+		if mode == MailTLSImplicit {
+			port = 465
+		}
+	}
+
+	m := &MailManager{
+		addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		host:      cfg.Host,
+		from:      from.String(),
+		fromAddr:  from.Address,
+		tlsMode:   mode,
+		tlsConfig: &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify},
+		timeout:   time.Duration(cfg.Timeout) * time.Second,
+		logger:    l,
+		templates: make(map[string]mailTemplate),
+	}
+	if m.timeout <= 0 {
+		m.timeout = defaultMailTimeout
+	}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	if cfg.TemplatesDir != "" {
+		if err := m.LoadTemplates(cfg.TemplatesDir); err != nil {
+			return nil, err
+		}
+	}
+
+	m.Pool = NewComponentPool("mail", "mail", 2) // SMTP sessions are few and slow
+	m.Pool.Start()
+	return m, nil
+}
+
+// Name returns the display name of the component.
+func (m *MailManager) Name() string {
+	return "Mail"
+}
+
+// Send sends a plain text email and waits for the server to accept it or
+// ctx to end. It implements the mailer used by account invites and
+// reports.
+func (m *MailManager) Send(ctx context.Context, to []string, subject, body string) error {
+	return m.Deliver(ctx, MailMessage{To: to, Subject: subject, Text: body})
+}
+
+// Deliver sends msg and waits for the server to accept it or ctx to end.
+func (m *MailManager) Deliver(ctx context.Context, msg MailMessage) error {
+	_, err := m.SendAsync(ctx, msg).WaitContext(ctx)
+	return err
+}
+
+// SendAsync queues msg on the worker pool. The result completes once the
+// server accepted or rejected it.
+func (m *MailManager) SendAsync(ctx context.Context, msg MailMessage) *AsyncResult[struct{}] {
+	result := NewAsyncResult[struct{}]()
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		result.Complete(struct{}{}, fmt.Errorf("mail: no recipients"))
+		return result
+	}
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		result.Complete(struct{}{}, fmt.Errorf("mail: component is closed"))
+		return result
+	}
+	m.Pool.SubmitTagged("mail:send", func() error {
+		if err := ctx.Err(); err != nil {
+			result.Complete(struct{}{}, err)
+			return err
+		}
+		err := m.send(ctx, msg)
+		m.record(msg, err)
+		result.Complete(struct{}{}, err)
+		return err
+	})
+	return result
+}
+
+// SendTemplate renders the template name with data and sends it to to.
+func (m *MailManager) SendTemplate(ctx context.Context, to []string, name string, data interface{}) error {
+	msg, err := m.Render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return m.Deliver(ctx, msg)
+}
+
+// AddTemplate parses src as the template name. It must define a "subject"
+// and a "text" block and may define an "html" one, which is escaped as
+// HTML:
+//
+//	{{define "subject"}}Welcome, {{.Name}}{{end}}
+//	{{define "text"}}Hello {{.Name}}, ...{{end}}
+//	{{define "html"}}<p>Hello {{.Name}}, ...</p>{{end}}
+func (m *MailManager) AddTemplate(name, src string) error {
+	text, err := texttemplate.New(name).Parse(src)
+	if err != nil {
+		return fmt.Errorf("mail template %s: %w", name, err)
+	}
+	for _, block := range []string{"subject", "text"} {
+		if text.Lookup(block) == nil {
+			return fmt.Errorf("mail template %s: no %q block", name, block)
+		}
+	}
+	tmpl := mailTemplate{text: text}
+	if text.Lookup("html") != nil {
+		if tmpl.html, err = htmltemplate.New(name).Parse(src); err != nil {
+			return fmt.Errorf("mail template %s: %w", name, err)
+		}
+	}
+	m.templatesMu.Lock()
+	m.templates[name] = tmpl
+	m.templatesMu.Unlock()
+	return nil
+}
+
+// LoadTemplates adds every <name>.tmpl file of dir as the template name.
+func (m *MailManager) LoadTemplates(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("mail: %w", err)
+		}
+		if err := m.AddTemplate(strings.TrimSuffix(filepath.Base(file), ".tmpl"), string(src)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Templates returns the names of the loaded templates.
+func (m *MailManager) Templates() []string {
+	m.templatesMu.RLock()
+	defer m.templatesMu.RUnlock()
+	names := make([]string, 0, len(m.templates))
+	for name := range m.templates {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Render executes the template name with data into a message without
+// recipients.
+func (m *MailManager) Render(name string, data interface{}) (MailMessage, error) {
+	m.templatesMu.RLock()
+	tmpl, ok := m.templates[name]
+	m.templatesMu.RUnlock()
+	if !ok {
+		return MailMessage{}, fmt.Errorf("%w: %s", ErrMailTemplateNotFound, name)
+	}
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return MailMessage{}, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return MailMessage{}, err
+	}
+	if tmpl.html != nil {
+		if err := tmpl.html.ExecuteTemplate(&html, "html", data); err != nil {
+			return MailMessage{}, err
+		}
+	}
+	return MailMessage{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    strings.TrimSpace(html.String()),
+	}, nil
+}
+
+// send runs one SMTP session for msg.
+func (m *MailManager) send(ctx context.Context, msg MailMessage) error {
+	data, err := m.compose(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if m.tlsMode == MailTLSImplicit {
+		conn = tls.Client(conn, m.tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer client.Close()
+
+	if m.tlsMode == MailTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("mail: %s does not support STARTTLS", m.addr)
+		}
+		if err := client.StartTLS(m.tlsConfig); err != nil {
+			return fmt.Errorf("mail: starttls: %w", err)
+		}
+	}
+	if m.auth != nil {
+		if err := client.Auth(m.auth); err != nil {
+			return fmt.Errorf("mail: auth: %w", err)
+		}
+	}
+	if err := client.Mail(m.fromAddr); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	for _, rcpt := range recipients(msg) {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("mail: recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	return client.Quit()
+}
+
+// recipients returns the envelope addresses of msg.
+func recipients(msg MailMessage) []string {
+	var rcpts []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, to := range list {
+			if addr, err := mail.ParseAddress(to); err == nil {
+				rcpts = append(rcpts, addr.Address)
+			} else {
+				rcpts = append(rcpts, to)
+			}
+		}
+	}
+	return rcpts
+}
+
+// compose builds the RFC 5322 message of msg. Bcc recipients get no header.
+func (m *MailManager) compose(msg MailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", m.from)
+	if len(msg.To) > 0 {
+		header("To", strings.Join(msg.To, ", "))
+	}
+	if len(msg.Cc) > 0 {
+		header("Cc", strings.Join(msg.Cc, ", "))
+	}
+	if msg.ReplyTo != "" {
+		header("Reply-To", msg.ReplyTo)
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", m.messageID())
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" || msg.Text == "" {
+		body, contentType := msg.Text, "text/plain; charset=utf-8"
+		if msg.Text == "" {
+			body, contentType = msg.HTML, "text/html; charset=utf-8"
+		}
+		header("Content-Type", contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the domain of the sender.
+func (m *MailManager) messageID() string {
+	domain := m.host
+	if at := strings.LastIndex(m.fromAddr, "@"); at >= 0 {
+		domain = m.fromAddr[at+1:]
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
+
+func (m *MailManager) record(msg MailMessage, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSend = time.Now()
+	m.lastTo = len(msg.To) + len(msg.Cc) + len(msg.Bcc)
+	if err != nil {
+		m.failed++
+		m.lastError = err.Error()
+		m.logger.Warn("Failed to send email", "subject", msg.Subject, "error", err.Error())
+		return
+	}
+	m.sent++
+	m.lastError = ""
+}
+
+// GetStatus reports the server, the send counters and the outcome of the
+// last send. The component counts as connected until a send fails.
+func (m *MailManager) GetStatus() map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{"connected": false}
+	}
+	m.mu.Lock()
+	status := map[string]interface{}{
+		"connected": m.lastError == "",
+		"address":   m.addr,
+		"from":      m.from,
+		"tls":       m.tlsMode,
+		"sent":      m.sent,
+		"failed":    m.failed,
+	}
+	if !m.lastSend.IsZero() {
+		last := map[string]interface{}{
+			"time":       m.lastSend,
+			"recipients": m.lastTo,
+			"ok":         m.lastError == "",
+		}
+		if m.lastError != "" {
+			last["error"] = m.lastError
+			status["error"] = m.lastError
+		}
+		status["last_send"] = last
+	}
+	m.mu.Unlock()
+	status["templates"] = len(m.Templates())
+	status["pool"] = m.Pool.GetStatus()
+	return status
+}
+
+// Close waits for the messages being sent and stops the worker pool;
+// messages still queued are dropped.
+func (m *MailManager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.Pool.Close()
+	return nil
+}
+
+func init() {
+	RegisterComponent("mail", func(cfg *config.Config, l *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.Mail.Enabled {
+			return nil, nil
+		}
+		return NewMailManager(cfg.Mail, l)
+	})
+}
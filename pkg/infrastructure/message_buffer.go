@@ -0,0 +1,334 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+	"sync"
+	"time"
+)
+
+// messageBufferBucket is the embedded store bucket holding buffered messages.
+const messageBufferBucket = "messaging_buffer"
+
+// ErrBufferFull is returned when a publish fails and the buffer has no room,
+// so callers always learn about messages that could not be kept.
+var ErrBufferFull = errors.New("messaging: outbound buffer full")
+
+// bufferedMessage is the stored form of a buffered message.
+type bufferedMessage struct {
+	Message    messaging.Message `json:"message"`
+	BufferedAt time.Time         `json:"buffered_at"`
+}
+
+// BufferedBroker wraps a messaging.Broker and parks outbound messages in the
+// embedded store while the broker is unreachable, replaying them in order
+// once publishing succeeds again.
+type BufferedBroker struct {
+	inner  messaging.Broker
+	store  *EmbeddedStore
+	cfg    config.MessagingBufferConfig
+	logger *logger.Logger
+
+	replayMu sync.Mutex // one replay at a time, to keep ordering
+
+	// mu guards the counters and the store appends; it is never held while
+	// the broker is called, so a slow broker does not hold up publishers
+	mu            sync.Mutex
+	depth         int
+	oldest        time.Time
+	bufferedTotal int64
+	replayedTotal int64
+	rejectedTotal int64
+	lastError     string
+	lastErrorAt   time.Time
+	lastReplayAt  time.Time
+
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var _ messaging.Broker = (*BufferedBroker)(nil)
+
+// NewBufferedBroker wraps inner and starts the background replay loop.
+func NewBufferedBroker(inner messaging.Broker, store *EmbeddedStore, cfg config.MessagingBufferConfig, logger *logger.Logger) (*BufferedBroker, error) {
+	if inner == nil || store == nil {
+		return nil, fmt.Errorf("buffered broker requires a broker and an embedded store")
+	}
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = 10000
+	}
+	if cfg.ReplayInterval <= 0 {
+		cfg.ReplayInterval = 5
+	}
+	if cfg.ReplayBatch <= 0 {
+		cfg.ReplayBatch = 100
+	}
+
+	b := &BufferedBroker{
+		inner:   inner,
+		store:   store,
+		cfg:     cfg,
+		logger:  logger,
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	// Pick up messages left over from a previous run
+	if err := b.refreshStats(); err != nil {
+		return nil, err
+	}
+	if b.depth > 0 {
+		logger.Info("Found buffered outbound messages", "count", b.depth)
+	}
+
+	go b.replayLoop()
+	return b, nil
+}
+
+// Name returns the display name of the component
+func (b *BufferedBroker) Name() string {
+	return "Message Buffer"
+}
+
+// BrokerType implements messaging.Broker.
+func (b *BufferedBroker) BrokerType() string {
+	return b.inner.BrokerType()
+}
+
+// Unwrap returns the underlying broker.
+func (b *BufferedBroker) Unwrap() messaging.Broker {
+	return b.inner
+}
+
+// PublishMessage publishes directly while the buffer is empty. If the
+// broker rejects the message, or older messages are still waiting, the
+// message is buffered instead so ordering is preserved.
+func (b *BufferedBroker) PublishMessage(ctx context.Context, msg messaging.Message) error {
+	// Replays run without the request context, so keep the ID in the message
+	msg = messaging.WithCorrelation(ctx, msg)
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	b.mu.Lock()
+	if b.depth == 0 {
+		b.mu.Unlock()
+		err := b.inner.PublishMessage(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		b.logger.Warn("Broker publish failed, buffering message", "topic", msg.Topic, "error", err)
+		b.mu.Lock()
+		b.recordError(err)
+	}
+	defer b.mu.Unlock()
+
+	if b.depth >= b.cfg.MaxMessages {
+		b.rejectedTotal++
+		return fmt.Errorf("%w (%d messages)", ErrBufferFull, b.depth)
+	}
+
+	data, err := json.Marshal(bufferedMessage{Message: msg, BufferedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to encode buffered message: %w", err)
+	}
+	if _, err := b.store.Append(messageBufferBucket, data); err != nil {
+		return fmt.Errorf("failed to buffer message: %w", err)
+	}
+	if b.depth == 0 {
+		b.oldest = time.Now()
+	}
+	b.depth++
+	b.bufferedTotal++
+	return nil
+}
+
+// Subscribe implements messaging.Broker by delegating to the wrapped broker.
+func (b *BufferedBroker) Subscribe(ctx context.Context, topic string, opts messaging.SubscribeOptions, handler messaging.Handler) (messaging.Subscription, error) {
+	return b.inner.Subscribe(ctx, topic, opts, handler)
+}
+
+// Flush triggers an immediate replay attempt.
+func (b *BufferedBroker) Flush() {
+	select {
+	case b.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (b *BufferedBroker) replayLoop() {
+	defer close(b.done)
+	ticker := time.NewTicker(time.Duration(b.cfg.ReplayInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		case <-b.trigger:
+		}
+		if _, err := b.Replay(context.Background()); err != nil {
+			b.logger.Debug("Message buffer replay paused", "error", err)
+		}
+	}
+}
+
+// Replay publishes buffered messages oldest first, stopping at the first
+// failure. It returns the number of messages delivered. Messages published
+// meanwhile are buffered behind the batch being replayed.
+func (b *BufferedBroker) Replay(ctx context.Context) (int, error) {
+	b.replayMu.Lock()
+	defer b.replayMu.Unlock()
+
+	b.mu.Lock()
+	if b.depth == 0 {
+		b.mu.Unlock()
+		return 0, nil
+	}
+	b.lastReplayAt = time.Now()
+	b.mu.Unlock()
+
+	replayed := 0
+	for {
+		entries, err := b.store.First(messageBufferBucket, b.cfg.ReplayBatch)
+		if err != nil {
+			return replayed, err
+		}
+		if len(entries) == 0 {
+			if err := b.refreshStats(); err != nil {
+				return replayed, err
+			}
+			break
+		}
+
+		var sent [][]byte
+		var publishErr error
+		for _, e := range entries {
+			var bm bufferedMessage
+			if err := json.Unmarshal(e.Value, &bm); err != nil {
+				// Drop undecodable entries rather than blocking the queue forever
+				b.logger.Error("Dropping corrupt buffered message", err)
+				sent = append(sent, e.Key)
+				continue
+			}
+			if err := b.inner.PublishMessage(ctx, bm.Message); err != nil {
+				publishErr = err
+				break
+			}
+			sent = append(sent, e.Key)
+		}
+
+		b.mu.Lock()
+		if len(sent) > 0 {
+			if err := b.store.DeleteKeys(messageBufferBucket, sent); err != nil {
+				b.mu.Unlock()
+				return replayed, err
+			}
+			replayed += len(sent)
+			b.replayedTotal += int64(len(sent))
+		}
+		err = b.refreshStatsLocked()
+		if err == nil && publishErr != nil {
+			b.recordError(publishErr)
+			err = publishErr
+		}
+		depth := b.depth
+		b.mu.Unlock()
+		if err != nil {
+			return replayed, err
+		}
+		if depth == 0 {
+			break
+		}
+	}
+
+	if replayed > 0 {
+		b.logger.Info("Replayed buffered messages", "count", replayed)
+	}
+	return replayed, nil
+}
+
+func (b *BufferedBroker) refreshStats() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.refreshStatsLocked()
+}
+
+func (b *BufferedBroker) refreshStatsLocked() error {
+	depth, err := b.store.Count(messageBufferBucket)
+	if err != nil {
+		return err
+	}
+	b.depth = depth
+	b.oldest = time.Time{}
+	if depth > 0 {
+		entries, err := b.store.First(messageBufferBucket, 1)
+		if err != nil {
+			return err
+		}
+		var bm bufferedMessage
+		if len(entries) == 1 && json.Unmarshal(entries[0].Value, &bm) == nil {
+			b.oldest = bm.BufferedAt
+		}
+	}
+	return nil
+}
+
+func (b *BufferedBroker) recordError(err error) {
+	b.lastError = err.Error()
+	b.lastErrorAt = time.Now()
+}
+
+// Stats returns buffer depth/age and counters.
+func (b *BufferedBroker) Stats() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"broker":         b.inner.BrokerType(),
+		"depth":          b.depth,
+		"max_messages":   b.cfg.MaxMessages,
+		"buffered_total": b.bufferedTotal,
+		"replayed_total": b.replayedTotal,
+		"rejected_total": b.rejectedTotal,
+	}
+	if !b.oldest.IsZero() {
+		stats["oldest_buffered_at"] = b.oldest
+		stats["oldest_age_seconds"] = int64(time.Since(b.oldest).Seconds())
+	}
+	if b.lastError != "" {
+		stats["last_error"] = b.lastError
+		stats["last_error_at"] = b.lastErrorAt
+	}
+	if !b.lastReplayAt.IsZero() {
+		stats["last_replay_at"] = b.lastReplayAt
+	}
+	return stats
+}
+
+// GetStatus reports the buffer as healthy while it is draining normally.
+func (b *BufferedBroker) GetStatus() map[string]interface{} {
+	stats := b.Stats()
+	stats["connected"] = stats["depth"] == 0
+	return stats
+}
+
+// Close stops the replay loop. Buffered messages stay in the store and are
+// replayed on the next start.
+func (b *BufferedBroker) Close() error {
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+	<-b.done
+	return nil
+}
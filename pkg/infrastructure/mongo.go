@@ -20,11 +20,18 @@ type MongoManager struct {
 	Client   *mongo.Client
 	Database *mongo.Database
 	Pool     *WorkerPool // Async worker pool
+	// Connection is the connection name, set by the connection manager.
+	// Command time outside a request tenant goes to the tenant owning it, see
+	// tenancy.Registry.MongoOwner.
+	Connection string
 	// statusCache avoids re-running Ping + dbStats on every /health call.
 	statusTTL    time.Duration
 	statusExpiry time.Time
 	statusCache  map[string]interface{}
 	statusMu     sync.Mutex
+	// ensured holds the outcome of EnsureIndexes by collection and index
+	ensured   map[string]IndexStatus
+	ensuredMu sync.Mutex
 }
 
 // Name returns the display name of the component
@@ -53,6 +60,9 @@ func NewMongoDB(cfg config.MongoConfig, l *logger.Logger) (*MongoManager, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Allocated up front so the command monitor can read Connection
+	manager := &MongoManager{}
+
 	// Set client options with timeout configurations
 	clientOptions := options.Client().
 		ApplyURI(cfg.URI).
@@ -64,7 +74,8 @@ func NewMongoDB(cfg config.MongoConfig, l *logger.Logger) (*MongoManager, error)
 		SetMaxPoolSize(50).
 		SetMinPoolSize(5).
 		SetMaxConnecting(10).
-		SetReadPreference(readpref.PrimaryPreferred())
+		SetReadPreference(readpref.PrimaryPreferred()).
+		SetMonitor(chainCommandMonitors(tenantCommandMonitor(manager), tracingCommandMonitor()))
 
 	// Connect to MongoDB with timeout
 	client, err := mongo.Connect(ctx, clientOptions)
@@ -90,14 +101,23 @@ func NewMongoDB(cfg config.MongoConfig, l *logger.Logger) (*MongoManager, error)
 	database := client.Database(cfg.Database)
 
 	// Initialize worker pool for async operations
-	pool := NewWorkerPool(12) // Moderate pool for document operations
+	pool := NewComponentPool("mongo", "mongo:"+cfg.Database, 12) // Moderate pool for document operations
 	pool.Start()
 
-	return &MongoManager{
-		Client:   client,
-		Database: database,
-		Pool:     pool,
-	}, nil
+	manager.Client = client
+	manager.Database = database
+	manager.Pool = pool
+
+	if len(cfg.Indexes) > 0 {
+		indexCtx, indexCancel := context.WithTimeout(context.Background(), ensureIndexesTimeout)
+		defer indexCancel()
+		for _, status := range manager.EnsureIndexes(indexCtx, IndexSourceConfig, cfg.Indexes...) {
+			if status.Status == IndexFailed {
+				l.Warn("Failed to ensure MongoDB index", "database", cfg.Database, "collection", status.Collection, "index", status.Name, "error", status.Error)
+			}
+		}
+	}
+	return manager, nil
 }
 
 func NewMongoConnectionManager(cfg config.MongoMultiConfig, l *logger.Logger) (*MongoConnectionManager, error) {
@@ -121,6 +141,7 @@ func NewMongoConnectionManager(cfg config.MongoMultiConfig, l *logger.Logger) (*
 			Enabled:  connCfg.Enabled,
 			URI:      connCfg.URI,
 			Database: connCfg.Database,
+			Indexes:  connCfg.Indexes,
 		}
 
 		db, err := NewMongoDB(singleCfg, l)
@@ -131,6 +152,7 @@ func NewMongoConnectionManager(cfg config.MongoMultiConfig, l *logger.Logger) (*
 		}
 
 		if db != nil {
+			db.Connection = connCfg.Name
 			manager.connections[connCfg.Name] = db
 			l.Info("MongoDB connection established", "name", connCfg.Name, "database", connCfg.Database)
 		}
@@ -140,6 +162,40 @@ func NewMongoConnectionManager(cfg config.MongoMultiConfig, l *logger.Logger) (*
 	return manager, nil
 }
 
+// AddConnection connects cfg and adds it as cfg.Name, for tenants
+// provisioned at runtime.
+func (m *MongoConnectionManager) AddConnection(cfg config.MongoConnectionConfig, l *logger.Logger) (*MongoManager, error) {
+	if _, exists := m.GetConnection(cfg.Name); exists {
+		return nil, fmt.Errorf("mongo connection %q already exists", cfg.Name)
+	}
+	db, err := NewMongoDB(config.MongoConfig{Enabled: true, URI: cfg.URI, Database: cfg.Database, Indexes: cfg.Indexes}, l)
+	if err != nil {
+		return nil, err
+	}
+	db.Connection = cfg.Name
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.connections[cfg.Name]; exists {
+		db.Close()
+		return nil, fmt.Errorf("mongo connection %q already exists", cfg.Name)
+	}
+	m.connections[cfg.Name] = db
+	return db, nil
+}
+
+// RemoveConnection disconnects a connection and removes it.
+func (m *MongoConnectionManager) RemoveConnection(name string) error {
+	m.mu.Lock()
+	conn, exists := m.connections[name]
+	delete(m.connections, name)
+	m.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("mongo connection %q not found", name)
+	}
+	return conn.Close()
+}
+
 // GetConnection returns a specific named connection
 func (m *MongoConnectionManager) GetConnection(name string) (*MongoManager, bool) {
 	m.mu.RLock()
@@ -225,6 +281,9 @@ func (m *MongoManager) GetStatus() map[string]interface{} {
 	// Slow path: actually ping the server and collect stats.
 	err := m.Client.Ping(context.Background(), nil)
 	stats["connected"] = err == nil
+	if m.Pool != nil {
+		stats["pool"] = m.Pool.GetStatus()
+	}
 
 	if err != nil {
 		m.statusMu.Lock()
@@ -601,7 +660,10 @@ func init() {
 			return nil, nil
 		}
 		if cfg.MongoMultiConfig.Enabled {
-			return NewMongoConnectionManager(cfg.MongoMultiConfig, log)
+			// Provisioned tenants add connections while the server runs
+			var multi config.MongoMultiConfig
+			config.View(func() { multi = cfg.MongoMultiConfig })
+			return NewMongoConnectionManager(multi, log)
 		}
 		return NewMongoDB(cfg.Mongo, log)
 	})
@@ -0,0 +1,258 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Limits of a collection browser page.
+const (
+	DefaultBrowseLimit = 50
+	MaxBrowseLimit     = 500
+)
+
+// ErrInvalidBrowse is returned for browse parameters that cannot be used.
+var ErrInvalidBrowse = errors.New("invalid browse parameters")
+
+// serverSideJS are the query operators that run JavaScript on the server,
+// which a browser never needs.
+var serverSideJS = map[string]bool{"$where": true, "$function": true, "$accumulator": true}
+
+// BrowseOptions selects a page of documents from a collection.
+type BrowseOptions struct {
+	Filter     bson.M // nil matches every document
+	Projection bson.D // nil returns whole documents
+	Sort       bson.D // nil is natural order
+	Skip       int64
+	Limit      int64 // DefaultBrowseLimit when zero, at most MaxBrowseLimit
+	MaxTime    time.Duration
+}
+
+// BrowsePage is one page of documents. EstimatedTotal is only set without
+// a filter, from the collection metadata.
+type BrowsePage struct {
+	Documents      []bson.M `json:"documents"`
+	Skip           int64    `json:"skip"`
+	Limit          int64    `json:"limit"`
+	HasMore        bool     `json:"has_more"`
+	EstimatedTotal *int64   `json:"estimated_total,omitempty"`
+}
+
+// CollectionStats are the storage statistics of a collection.
+type CollectionStats struct {
+	Name            string           `json:"name"`
+	Count           int64            `json:"count"`
+	SizeBytes       int64            `json:"size_bytes"`    // uncompressed data
+	StorageBytes    int64            `json:"storage_bytes"` // allocated on disk
+	AvgObjectBytes  int64            `json:"avg_object_bytes"`
+	TotalIndexBytes int64            `json:"total_index_bytes"`
+	IndexCount      int64            `json:"index_count"`
+	IndexSizes      map[string]int64 `json:"index_sizes"`
+	Capped          bool             `json:"capped"`
+}
+
+// BrowseCollection returns one page of collection, unlike ExecuteRawQuery
+// which reads every match.
+func (m *MongoManager) BrowseCollection(ctx context.Context, collection string, opts BrowseOptions) (*BrowsePage, error) {
+	if op := findOperator(opts.Filter, serverSideJS); op != "" {
+		return nil, fmt.Errorf("%w: %s is not allowed", ErrInvalidBrowse, op)
+	}
+	if opts.Skip < 0 || opts.Limit < 0 {
+		return nil, fmt.Errorf("%w: skip and limit must not be negative", ErrInvalidBrowse)
+	}
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultBrowseLimit
+	}
+	opts.Limit = min(opts.Limit, MaxBrowseLimit)
+	filter := opts.Filter
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	// One extra document tells whether there is a next page
+	find := options.Find().SetSkip(opts.Skip).SetLimit(opts.Limit + 1)
+	if opts.Projection != nil {
+		find.SetProjection(opts.Projection)
+	}
+	if opts.Sort != nil {
+		find.SetSort(opts.Sort)
+	}
+	if opts.MaxTime > 0 {
+		find.SetMaxTime(opts.MaxTime)
+	}
+	coll := m.Database.Collection(collection)
+	cursor, err := coll.Find(ctx, filter, find)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	page := &BrowsePage{Documents: []bson.M{}, Skip: opts.Skip, Limit: opts.Limit}
+	for cursor.Next(ctx) {
+		if int64(len(page.Documents)) == opts.Limit {
+			page.HasMore = true
+			break
+		}
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		page.Documents = append(page.Documents, doc)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if len(filter) == 0 {
+		if total, err := coll.EstimatedDocumentCount(ctx); err == nil {
+			page.EstimatedTotal = &total
+		}
+	}
+	return page, nil
+}
+
+// CollectionStats returns the collStats of collection.
+func (m *MongoManager) CollectionStats(ctx context.Context, collection string) (*CollectionStats, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	var raw bson.M
+	if err := m.Database.RunCommand(ctx, bson.D{{Key: "collStats", Value: collection}}).Decode(&raw); err != nil {
+		return nil, err
+	}
+	stats := &CollectionStats{
+		Name:            collection,
+		Count:           int64Value(raw["count"]),
+		SizeBytes:       int64Value(raw["size"]),
+		StorageBytes:    int64Value(raw["storageSize"]),
+		AvgObjectBytes:  int64Value(raw["avgObjSize"]),
+		TotalIndexBytes: int64Value(raw["totalIndexSize"]),
+		IndexCount:      int64Value(raw["nindexes"]),
+		IndexSizes:      make(map[string]int64),
+	}
+	stats.Capped, _ = raw["capped"].(bool)
+	if sizes, ok := raw["indexSizes"].(bson.M); ok {
+		for name, size := range sizes {
+			stats.IndexSizes[name] = int64Value(size)
+		}
+	}
+	return stats, nil
+}
+
+// ParseMongoFilter parses a filter in MongoDB extended JSON, so
+// {"_id": {"$oid": "..."}} and {"at": {"$date": "..."}} work.
+func ParseMongoFilter(s string) (bson.M, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var filter bson.M
+	if err := bson.UnmarshalExtJSON([]byte(s), false, &filter); err != nil {
+		return nil, fmt.Errorf("%w: filter is not a JSON object: %v", ErrInvalidBrowse, err)
+	}
+	return filter, nil
+}
+
+// ParseMongoSort parses "-created_at,name" (descending created_at, then
+// ascending name).
+func ParseMongoSort(s string) (bson.D, error) {
+	var sort bson.D
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		order := 1
+		if name, ok := strings.CutPrefix(field, "-"); ok {
+			field, order = name, -1
+		} else {
+			field = strings.TrimPrefix(field, "+")
+		}
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("%w: invalid sort field %q", ErrInvalidBrowse, field)
+		}
+		sort = append(sort, bson.E{Key: field, Value: order})
+	}
+	return sort, nil
+}
+
+// ParseMongoProjection parses "name,email" (only these fields) or
+// "-password,-token" (all but these). Inclusions and exclusions cannot be
+// mixed, except for excluding _id.
+func ParseMongoProjection(s string) (bson.D, error) {
+	var projection bson.D
+	include, exclude := false, false
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		value := 1
+		if name, ok := strings.CutPrefix(field, "-"); ok {
+			field, value = name, 0
+		}
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("%w: invalid projection field %q", ErrInvalidBrowse, field)
+		}
+		if field != "_id" {
+			include = include || value == 1
+			exclude = exclude || value == 0
+		}
+		projection = append(projection, bson.E{Key: field, Value: value})
+	}
+	if include && exclude {
+		return nil, fmt.Errorf("%w: projection cannot both include and exclude fields", ErrInvalidBrowse)
+	}
+	return projection, nil
+}
+
+// findOperator returns the first key of v, at any depth, in operators.
+func findOperator(v interface{}, operators map[string]bool) string {
+	switch v := v.(type) {
+	case bson.M:
+		for key, value := range v {
+			if operators[key] {
+				return key
+			}
+			if op := findOperator(value, operators); op != "" {
+				return op
+			}
+		}
+	case bson.D:
+		for _, e := range v {
+			if operators[e.Key] {
+				return e.Key
+			}
+			if op := findOperator(e.Value, operators); op != "" {
+				return op
+			}
+		}
+	case bson.A:
+		for _, value := range v {
+			if op := findOperator(value, operators); op != "" {
+				return op
+			}
+		}
+	}
+	return ""
+}
+
+// int64Value converts the numeric types of BSON documents.
+func int64Value(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,292 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ensureIndexesTimeout bounds ensuring the indexes of the config section
+// when a connection is established.
+const ensureIndexesTimeout = 30 * time.Second
+
+// IndexSourceConfig is the source of the indexes declared in the mongo
+// config section; services pass their registry name.
+const IndexSourceConfig = "config"
+
+// Outcomes of ensuring an index.
+const (
+	IndexCreated = "created"
+	IndexExists  = "exists"
+	IndexFailed  = "failed"
+	IndexDropped = "dropped" // through DropIndex since it was ensured
+)
+
+// ErrInvalidIndex is returned for index specs that cannot be used.
+var ErrInvalidIndex = errors.New("invalid index")
+
+// indexTypes are the index types a key may name instead of an order.
+var indexTypes = map[string]bool{"text": true, "2d": true, "2dsphere": true, "hashed": true}
+
+// MongoIndex is an index of a collection.
+type MongoIndex struct {
+	Name          string      `json:"name"`
+	Keys          []IndexKey  `json:"keys"`
+	Unique        bool        `json:"unique"`
+	Sparse        bool        `json:"sparse"`
+	TTLSeconds    *int64      `json:"ttl_seconds,omitempty"`
+	PartialFilter interface{} `json:"partial_filter,omitempty"`
+	SizeBytes     int64       `json:"size_bytes"`
+}
+
+// IndexKey is a field of an index: 1 or -1 for ascending and descending,
+// or the index type ("text", "2dsphere", "hashed").
+type IndexKey struct {
+	Field string      `json:"field"`
+	Order interface{} `json:"order"`
+}
+
+// IndexStatus is the outcome of ensuring a declared index.
+type IndexStatus struct {
+	Collection string    `json:"collection"`
+	Name       string    `json:"name"`
+	Keys       []string  `json:"keys"`
+	Source     string    `json:"source"` // IndexSourceConfig or the declaring service
+	Status     string    `json:"status"` // IndexCreated, IndexExists, IndexFailed or IndexDropped
+	Error      string    `json:"error,omitempty"`
+	EnsuredAt  time.Time `json:"ensured_at"`
+}
+
+// ListIndexes lists the indexes of collection with their sizes.
+func (m *MongoManager) ListIndexes(ctx context.Context, collection string) ([]MongoIndex, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	cursor, err := m.Database.Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sizes map[string]int64
+	if stats, err := m.CollectionStats(ctx, collection); err == nil {
+		sizes = stats.IndexSizes
+	}
+	indexes := []MongoIndex{}
+	for cursor.Next(ctx) {
+		var spec struct {
+			Name               string `bson:"name"`
+			Key                bson.D `bson:"key"`
+			Unique             bool   `bson:"unique"`
+			Sparse             bool   `bson:"sparse"`
+			ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+			PartialFilter      bson.M `bson:"partialFilterExpression"`
+		}
+		if err := cursor.Decode(&spec); err != nil {
+			return nil, err
+		}
+		index := MongoIndex{
+			Name:       spec.Name,
+			Unique:     spec.Unique,
+			Sparse:     spec.Sparse,
+			TTLSeconds: spec.ExpireAfterSeconds,
+			SizeBytes:  sizes[spec.Name],
+		}
+		if spec.PartialFilter != nil {
+			index.PartialFilter = spec.PartialFilter
+		}
+		for _, key := range spec.Key {
+			index.Keys = append(index.Keys, IndexKey{Field: key.Key, Order: key.Value})
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, cursor.Err()
+}
+
+// CreateIndex builds the index spec declares and returns its name. An
+// index with the same name and spec is left as is; one with the same name
+// but other keys or options fails.
+func (m *MongoManager) CreateIndex(ctx context.Context, spec config.MongoIndexConfig) (string, error) {
+	model, err := indexModel(spec)
+	if err != nil {
+		return "", err
+	}
+	if m.Database == nil {
+		return "", fmt.Errorf("database connection is nil")
+	}
+	name, err := m.Database.Collection(spec.Collection).Indexes().CreateOne(ctx, model)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == 85 || cmdErr.Code == 86) {
+		// IndexOptionsConflict, IndexKeySpecsConflict
+		return "", fmt.Errorf("%w; drop the index to rebuild it with this spec", err)
+	}
+	return name, err
+}
+
+// DropIndex drops the index name of collection. The _id index cannot be
+// dropped.
+func (m *MongoManager) DropIndex(ctx context.Context, collection, name string) error {
+	if name == "" || name == "*" || name == "_id_" {
+		return fmt.Errorf("%w: index %q cannot be dropped", ErrInvalidIndex, name)
+	}
+	if m.Database == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if _, err := m.Database.Collection(collection).Indexes().DropOne(ctx, name); err != nil {
+		return err
+	}
+
+	m.ensuredMu.Lock()
+	defer m.ensuredMu.Unlock()
+	if status, ok := m.ensured[collection+"\x00"+name]; ok {
+		status.Status, status.Error = IndexDropped, ""
+		m.ensured[collection+"\x00"+name] = status
+	}
+	return nil
+}
+
+// EnsureIndexes creates the indexes of specs that are missing, on behalf
+// of source, and records the outcome for EnsuredIndexes. A failing index
+// does not stop the others.
+func (m *MongoManager) EnsureIndexes(ctx context.Context, source string, specs ...config.MongoIndexConfig) []IndexStatus {
+	existing := make(map[string]map[string]bool) // collection -> index names
+	statuses := make([]IndexStatus, 0, len(specs))
+	for _, spec := range specs {
+		status := IndexStatus{Collection: spec.Collection, Name: spec.Name, Keys: spec.Keys, Source: source, EnsuredAt: time.Now()}
+		if model, err := indexModel(spec); err == nil {
+			status.Name = *model.Options.Name
+		}
+		if _, ok := existing[spec.Collection]; !ok && m.Database != nil {
+			existing[spec.Collection] = m.indexNames(ctx, spec.Collection)
+		}
+
+		status.Status = IndexCreated
+		if existing[spec.Collection][status.Name] {
+			status.Status = IndexExists
+		}
+		if _, err := m.CreateIndex(ctx, spec); err != nil {
+			status.Status, status.Error = IndexFailed, err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+
+	m.ensuredMu.Lock()
+	defer m.ensuredMu.Unlock()
+	if m.ensured == nil {
+		m.ensured = make(map[string]IndexStatus)
+	}
+	for _, status := range statuses {
+		m.ensured[status.Collection+"\x00"+status.Name] = status
+	}
+	return statuses
+}
+
+// EnsuredIndexes returns the last outcome of every index ensured on the
+// connection, by collection, then name.
+func (m *MongoManager) EnsuredIndexes() []IndexStatus {
+	m.ensuredMu.Lock()
+	list := make([]IndexStatus, 0, len(m.ensured))
+	for _, status := range m.ensured {
+		list = append(list, status)
+	}
+	m.ensuredMu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Collection != list[j].Collection {
+			return list[i].Collection < list[j].Collection
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// indexNames returns the names of the indexes of collection; none when it
+// does not exist yet.
+func (m *MongoManager) indexNames(ctx context.Context, collection string) map[string]bool {
+	names := make(map[string]bool)
+	specs, err := m.Database.Collection(collection).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return names
+	}
+	for _, spec := range specs {
+		names[spec.Name] = true
+	}
+	return names
+}
+
+// ParseIndexKeys parses the keys of an index spec: "tenant_id" is
+// ascending, "-created_at" descending and "name:text" an index type.
+func ParseIndexKeys(keys []string) (bson.D, error) {
+	var spec bson.D
+	for _, key := range keys {
+		field := strings.TrimSpace(key)
+		var order interface{} = 1
+		if name, kind, ok := strings.Cut(field, ":"); ok {
+			if !indexTypes[kind] {
+				return nil, fmt.Errorf("%w: unknown index type %q", ErrInvalidIndex, kind)
+			}
+			field, order = name, kind
+		} else if name, ok := strings.CutPrefix(field, "-"); ok {
+			field, order = name, -1
+		}
+		if field == "" || strings.HasPrefix(field, "$") || strings.HasPrefix(field, "-") {
+			return nil, fmt.Errorf("%w: invalid key %q", ErrInvalidIndex, key)
+		}
+		spec = append(spec, bson.E{Key: field, Value: order})
+	}
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("%w: an index needs at least one key", ErrInvalidIndex)
+	}
+	return spec, nil
+}
+
+// indexModel converts spec, naming the index like the server does when it
+// has no name.
+func indexModel(spec config.MongoIndexConfig) (mongo.IndexModel, error) {
+	if spec.Collection == "" {
+		return mongo.IndexModel{}, fmt.Errorf("%w: collection is required", ErrInvalidIndex)
+	}
+	keys, err := ParseIndexKeys(spec.Keys)
+	if err != nil {
+		return mongo.IndexModel{}, err
+	}
+	name := spec.Name
+	if name == "" {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+		}
+		name = strings.Join(parts, "_")
+	}
+
+	opts := options.Index().SetName(name)
+	if spec.Unique {
+		opts.SetUnique(true)
+	}
+	if spec.Sparse {
+		opts.SetSparse(true)
+	}
+	if spec.TTL < 0 || spec.TTL > math.MaxInt32 {
+		return mongo.IndexModel{}, fmt.Errorf("%w: ttl must be 0 to %d seconds", ErrInvalidIndex, math.MaxInt32)
+	}
+	if spec.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(spec.TTL))
+	}
+	if strings.TrimSpace(spec.PartialFilter) != "" {
+		var filter bson.M
+		if err := bson.UnmarshalExtJSON([]byte(spec.PartialFilter), false, &filter); err != nil {
+			return mongo.IndexModel{}, fmt.Errorf("%w: partial_filter is not a JSON object: %v", ErrInvalidIndex, err)
+		}
+		opts.SetPartialFilterExpression(filter)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}, nil
+}
@@ -0,0 +1,235 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsKeyHeader carries messaging.Message.Key, which core NATS has no field for.
+const natsKeyHeader = "Stackyrd-Msg-Key"
+
+// NATSManager manages a core NATS connection.
+type NATSManager struct {
+	Conn   *nats.Conn
+	URL    string
+	Queue  string
+	logger *logger.Logger
+	Pool   *WorkerPool // Async worker pool
+}
+
+// Name returns the display name of the component
+func (n *NATSManager) Name() string {
+	return "NATS"
+}
+
+func NewNATSManager(cfg config.NATSConfig, logger *logger.Logger) (*NATSManager, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = "stackyrd"
+	}
+
+	conn, err := nats.Connect(cfg.URL,
+		nats.Name(name),
+		nats.Timeout(5*time.Second),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logger.Warn("NATS disconnected", "error", err)
+				PublishConnectionEvent(ConnectionEvent{Component: "nats", Type: ConnectionLost, Error: err.Error()})
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			logger.Info("NATS reconnected", "url", c.ConnectedUrl())
+			PublishConnectionEvent(ConnectionEvent{Component: "nats", Type: ConnectionReestablished})
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+
+	// Initialize worker pool for async operations
+	pool := NewComponentPool("nats", "nats", 5)
+	pool.Start()
+
+	return &NATSManager{
+		Conn:   conn,
+		URL:    cfg.URL,
+		Queue:  cfg.Queue,
+		logger: logger,
+		Pool:   pool,
+	}, nil
+}
+
+func (n *NATSManager) GetStatus() map[string]interface{} {
+	stats := make(map[string]interface{})
+	if n == nil || n.Conn == nil {
+		stats["connected"] = false
+		return stats
+	}
+
+	stats["connected"] = n.Conn.IsConnected()
+	stats["url"] = n.URL
+	stats["status"] = n.Conn.Status().String()
+	if n.Conn.IsConnected() {
+		stats["server"] = n.Conn.ConnectedUrlRedacted()
+	}
+	s := n.Conn.Stats()
+	stats["in_msgs"] = s.InMsgs
+	stats["out_msgs"] = s.OutMsgs
+	stats["reconnects"] = s.Reconnects
+	if n.Pool != nil {
+		stats["pool_active"] = true
+		stats["pool"] = n.Pool.GetStatus()
+	}
+	return stats
+}
+
+// Publish publishes raw data to a subject.
+func (n *NATSManager) Publish(ctx context.Context, subject string, data []byte) error {
+	return n.PublishMessage(ctx, messaging.Message{Topic: subject, Value: data})
+}
+
+// PublishAsync asynchronously publishes raw data to a subject.
+func (n *NATSManager) PublishAsync(ctx context.Context, subject string, data []byte) *AsyncResult[struct{}] {
+	return ExecuteAsync(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, n.Publish(ctx, subject, data)
+	})
+}
+
+var _ messaging.Broker = (*NATSManager)(nil)
+
+// BrokerType implements messaging.Broker.
+func (n *NATSManager) BrokerType() string {
+	return messaging.BrokerNATS
+}
+
+// PublishMessage implements messaging.Broker. The connection is flushed so
+// the call returns once the server has received the message.
+func (n *NATSManager) PublishMessage(ctx context.Context, msg messaging.Message) error {
+	if n == nil || n.Conn == nil || !n.Conn.IsConnected() {
+		return messaging.ErrNotConnected
+	}
+
+	msg = messaging.WithCorrelation(ctx, msg)
+	m := nats.NewMsg(msg.Topic)
+	m.Data = msg.Value
+	for k, v := range msg.Headers {
+		m.Header.Set(k, v)
+	}
+	if len(msg.Key) > 0 {
+		m.Header.Set(natsKeyHeader, string(msg.Key))
+	}
+
+	if err := n.Conn.PublishMsg(m); err != nil {
+		return err
+	}
+	return n.Conn.FlushWithContext(ctx)
+}
+
+// Subscribe implements messaging.Broker. Core NATS delivers at most once, so
+// Ack and Nack are no-ops; subscribers sharing a Group form a queue group.
+func (n *NATSManager) Subscribe(ctx context.Context, subject string, opts messaging.SubscribeOptions, handler messaging.Handler) (messaging.Subscription, error) {
+	if n == nil || n.Conn == nil {
+		return nil, messaging.ErrNotConnected
+	}
+
+	queue := opts.Group
+	if queue == "" {
+		queue = n.Queue
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	cb := func(m *nats.Msg) {
+		headers := make(map[string]string, len(m.Header))
+		for k := range m.Header {
+			if k != natsKeyHeader {
+				headers[k] = m.Header.Get(k)
+			}
+		}
+		d := messaging.NewDelivery(messaging.Message{
+			Topic:     m.Subject,
+			Key:       []byte(m.Header.Get(natsKeyHeader)),
+			Value:     m.Data,
+			Headers:   headers,
+			Timestamp: time.Now(),
+		}, nil, nil)
+		if err := messaging.Dispatch(subCtx, d, opts, handler); err != nil {
+			n.logger.Error("Error handling message", err, "subject", m.Subject)
+		}
+	}
+
+	var sub *nats.Subscription
+	var err error
+	if queue != "" {
+		sub, err = n.Conn.QueueSubscribe(subject, queue, cb)
+	} else {
+		sub, err = n.Conn.Subscribe(subject, cb)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Tie the subscription lifetime to ctx
+	go func() {
+		<-subCtx.Done()
+		_ = sub.Unsubscribe()
+	}()
+
+	return &natsSubscription{sub: sub, cancel: cancel}, nil
+}
+
+type natsSubscription struct {
+	sub    *nats.Subscription
+	cancel context.CancelFunc
+}
+
+func (s *natsSubscription) Topic() string { return s.sub.Subject }
+
+func (s *natsSubscription) Unsubscribe() error {
+	s.cancel()
+	if !s.sub.IsValid() {
+		return nil
+	}
+	return s.sub.Unsubscribe()
+}
+
+// SubmitAsyncJob submits an async job to the worker pool.
+func (n *NATSManager) SubmitAsyncJob(job func()) {
+	if n.Pool != nil {
+		n.Pool.Submit(job)
+	} else {
+		// Fallback to direct execution if pool not available
+		go job()
+	}
+}
+
+// Close drains the connection and stops the worker pool.
+func (n *NATSManager) Close() error {
+	if n.Pool != nil {
+		n.Pool.Close()
+	}
+	if n.Conn != nil {
+		return n.Conn.Drain()
+	}
+	return nil
+}
+
+func init() {
+	RegisterComponent("nats", func(cfg *config.Config, log *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.NATS.Enabled {
+			return nil, nil
+		}
+		return NewNATSManager(cfg.NATS, log)
+	})
+}
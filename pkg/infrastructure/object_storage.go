@@ -0,0 +1,202 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ErrObjectNotFound is returned for objects that do not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// StoredObject describes an object of an ObjectStorage.
+type StoredObject struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	Modified    time.Time `json:"modified"`
+}
+
+// ObjectStorage keeps named blobs. Names are slash-separated paths without
+// "." or ".." elements.
+type ObjectStorage interface {
+	Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, name string) (io.ReadCloser, *StoredObject, error)
+	Delete(ctx context.Context, name string) error
+	// List returns the objects whose name starts with prefix.
+	List(ctx context.Context, prefix string) ([]StoredObject, error)
+}
+
+// checkObjectName rejects names that could escape their prefix.
+func checkObjectName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || path.Clean(name) != name || strings.HasPrefix(name, "../") || name == ".." {
+		return fmt.Errorf("invalid object name %q", name)
+	}
+	return nil
+}
+
+// BucketObjectStorage is an ObjectStorage in a bucket of the StorageManager,
+// which survives redeploys of the application.
+type BucketObjectStorage struct {
+	Bucket *StorageBucket
+}
+
+func (s BucketObjectStorage) Put(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	if err := checkObjectName(name); err != nil {
+		return err
+	}
+	_, err := s.Bucket.UploadFile(ctx, name, r, size, contentType)
+	return err
+}
+
+func (s BucketObjectStorage) Get(ctx context.Context, name string) (io.ReadCloser, *StoredObject, error) {
+	if err := checkObjectName(name); err != nil {
+		return nil, nil, err
+	}
+	// GetObject is lazy; Stat surfaces a missing object
+	obj, err := s.Bucket.GetObject(ctx, name)
+	if err != nil {
+		return nil, nil, bucketError(err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, nil, bucketError(err)
+	}
+	return obj, &StoredObject{Name: name, Size: info.Size, ContentType: info.ContentType, Modified: info.LastModified}, nil
+}
+
+func (s BucketObjectStorage) Delete(ctx context.Context, name string) error {
+	if err := checkObjectName(name); err != nil {
+		return err
+	}
+	return bucketError(s.Bucket.DeleteObject(ctx, name))
+}
+
+func (s BucketObjectStorage) List(ctx context.Context, prefix string) ([]StoredObject, error) {
+	infos, err := s.Bucket.ListObjects(ctx, prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]StoredObject, len(infos))
+	for i, info := range infos {
+		objects[i] = StoredObject{Name: info.Key, Size: info.Size, ContentType: info.ContentType, Modified: info.LastModified}
+	}
+	return objects, nil
+}
+
+func bucketError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return fmt.Errorf("%w: %v", ErrObjectNotFound, err)
+	}
+	return err
+}
+
+// LocalObjectStorage is an ObjectStorage in a directory. Unless the
+// directory is on a persistent volume, its objects are lost when the
+// container is replaced.
+type LocalObjectStorage struct {
+	Dir string
+}
+
+func (s LocalObjectStorage) path(name string) (string, error) {
+	if err := checkObjectName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(name)), nil
+}
+
+// Put writes the object to a temporary file first, so readers never see a
+// partial object.
+func (s LocalObjectStorage) Put(_ context.Context, name string, r io.Reader, _ int64, _ string) error {
+	target, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s LocalObjectStorage) Get(_ context.Context, name string) (io.ReadCloser, *StoredObject, error) {
+	target, err := s.path(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, &StoredObject{Name: name, Size: info.Size(), ContentType: mime.TypeByExtension(path.Ext(name)), Modified: info.ModTime()}, nil
+}
+
+func (s LocalObjectStorage) Delete(_ context.Context, name string) error {
+	target, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s LocalObjectStorage) List(_ context.Context, prefix string) ([]StoredObject, error) {
+	objects := []StoredObject{}
+	err := filepath.WalkDir(s.Dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StoredObject{Name: name, Size: info.Size(), ContentType: mime.TypeByExtension(path.Ext(name)), Modified: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
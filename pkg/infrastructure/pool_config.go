@@ -0,0 +1,46 @@
+package infrastructure
+
+import (
+	"sync"
+
+	"stackyrd/config"
+)
+
+// Pool sizes by component kind (infrastructure.pools), set before the
+// components are created.
+var (
+	poolConfigMu sync.RWMutex
+	poolConfigs  map[string]config.PoolConfig
+)
+
+// ConfigurePools sets the sizes of the worker pools components create
+// afterwards, by component kind.
+func ConfigurePools(pools map[string]config.PoolConfig) {
+	poolConfigMu.Lock()
+	defer poolConfigMu.Unlock()
+	poolConfigs = pools
+}
+
+// PoolOptionsFor returns the options of the worker pool of a component
+// kind, with defaultWorkers when its size is not configured.
+func PoolOptionsFor(kind string, defaultWorkers int) PoolOptions {
+	poolConfigMu.RLock()
+	pc := poolConfigs[kind]
+	poolConfigMu.RUnlock()
+	opts := PoolOptions{
+		Workers:    pc.Workers,
+		MinWorkers: pc.MinWorkers,
+		MaxWorkers: pc.MaxWorkers,
+		QueueSize:  pc.QueueSize,
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = max(defaultWorkers, opts.MinWorkers)
+	}
+	return opts
+}
+
+// NewComponentPool creates the worker pool of a component of kind, listed
+// under name by WorkerPools and sized by infrastructure.pools.<kind>.
+func NewComponentPool(kind, name string, defaultWorkers int) *WorkerPool {
+	return NewWorkerPoolWithOptions(name, PoolOptionsFor(kind, defaultWorkers))
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"stackyrd/config"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/tracing"
 	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -19,11 +21,27 @@ type PostgresManager struct {
 	ORM  *gorm.DB
 	Pool *WorkerPool // Async worker pool
 
+	// Connection is the connection name, set by the connection manager.
+	// Query time outside a request tenant goes to the tenant owning it, see
+	// tenancy.Registry.PostgresOwner.
+	Connection string
+
 	// statusCache avoids re-running Ping on every /health call.
 	statusTTL    time.Duration
 	statusExpiry time.Time
 	statusCache  map[string]interface{}
 	statusMu     sync.Mutex
+
+	// credentials is set for connections with a vault_role.
+	credentials *postgresCredentials
+	// replicas take the reads, see Reader.
+	replicas postgresReplicas
+	// statements are the named prepared statements, see Prepare.
+	statements postgresStatements
+	// listener delivers the notifications of Listen.
+	listener postgresListener
+	// notify are the channels bridged to event streams, see NotifyBridges.
+	notify []config.PostgresNotifyConfig
 }
 
 type PostgresConnectionManager struct {
@@ -46,6 +64,10 @@ func NewPostgresDB(cfg config.PostgresConfig) (*PostgresManager, error) {
 		return nil, nil
 	}
 
+	if cfg.VaultRole != "" {
+		return newVaultPostgresDB(cfg)
+	}
+
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
 
@@ -55,10 +77,17 @@ func NewPostgresDB(cfg config.PostgresConfig) (*PostgresManager, error) {
 		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
 	}
 
+	applyPostgresPool(sqlDB, cfg)
+
 	if err := sqlDB.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
+	return newPostgresManager(sqlDB, cfg)
+}
 
+// newPostgresManager wraps a connected pool with GORM and the worker pool,
+// and opens the read replicas of cfg.
+func newPostgresManager(sqlDB *sql.DB, cfg config.PostgresConfig) (*PostgresManager, error) {
 	// Initialize GORM with the existing SQL connection
 	gormDB, err := gorm.Open(postgres.New(postgres.Config{
 		Conn: sqlDB,
@@ -68,14 +97,23 @@ func NewPostgresDB(cfg config.PostgresConfig) (*PostgresManager, error) {
 	}
 
 	// Initialize worker pool for async operations
-	pool := NewWorkerPool(15) // Moderate pool for DB operations
+	pool := NewComponentPool("postgres", "postgres:"+cfg.DBName, 15) // Moderate pool for DB operations
 	pool.Start()
 
-	return &PostgresManager{
-		DB:   sqlDB,
-		ORM:  gormDB,
-		Pool: pool,
-	}, nil
+	manager := &PostgresManager{
+		DB:     sqlDB,
+		ORM:    gormDB,
+		Pool:   pool,
+		notify: cfg.Notify,
+	}
+	registerTenantCallbacks(gormDB, func() string { return manager.Connection })
+	registerTracingCallbacks(gormDB)
+	registerReplicaCallbacks(gormDB, &manager.replicas)
+	if err := manager.openPostgresReplicas(cfg); err != nil {
+		manager.Close()
+		return nil, err
+	}
+	return manager, nil
 }
 
 func NewPostgresConnectionManager(cfg config.PostgresMultiConfig) (*PostgresConnectionManager, error) {
@@ -92,18 +130,7 @@ func NewPostgresConnectionManager(cfg config.PostgresMultiConfig) (*PostgresConn
 			continue
 		}
 
-		// Convert connection config to single config for backward compatibility
-		singleCfg := config.PostgresConfig{
-			Enabled:  connCfg.Enabled,
-			Host:     connCfg.Host,
-			Port:     connCfg.Port,
-			User:     connCfg.User,
-			Password: connCfg.Password,
-			DBName:   connCfg.DBName,
-			SSLMode:  connCfg.SSLMode,
-		}
-
-		db, err := NewPostgresDB(singleCfg)
+		db, err := NewPostgresDB(postgresConfigOf(connCfg))
 		if err != nil {
 			// Log error but continue with other connections
 			// Don't fail the entire manager initialization
@@ -111,6 +138,7 @@ func NewPostgresConnectionManager(cfg config.PostgresMultiConfig) (*PostgresConn
 		}
 
 		if db != nil {
+			db.Connection = connCfg.Name
 			manager.connections[connCfg.Name] = db
 		}
 	}
@@ -118,6 +146,84 @@ func NewPostgresConnectionManager(cfg config.PostgresMultiConfig) (*PostgresConn
 	return manager, nil
 }
 
+// postgresConfigOf converts a connection config to the single config for
+// backward compatibility.
+func postgresConfigOf(connCfg config.PostgresConnectionConfig) config.PostgresConfig {
+	return config.PostgresConfig{
+		Enabled:   connCfg.Enabled,
+		Host:      connCfg.Host,
+		Port:      connCfg.Port,
+		User:      connCfg.User,
+		Password:  connCfg.Password,
+		DBName:    connCfg.DBName,
+		SSLMode:   connCfg.SSLMode,
+		VaultRole: connCfg.VaultRole,
+
+		MaxOpenConns:    connCfg.MaxOpenConns,
+		MaxIdleConns:    connCfg.MaxIdleConns,
+		ConnMaxLifetime: connCfg.ConnMaxLifetime,
+		ConnMaxIdleTime: connCfg.ConnMaxIdleTime,
+		Replicas:        connCfg.Replicas,
+		Notify:          connCfg.Notify,
+	}
+}
+
+// AddConnection connects cfg and adds it as cfg.Name, for tenants
+// provisioned at runtime.
+func (m *PostgresConnectionManager) AddConnection(cfg config.PostgresConnectionConfig) (*PostgresManager, error) {
+	if _, exists := m.GetConnection(cfg.Name); exists {
+		return nil, fmt.Errorf("postgres connection %q already exists", cfg.Name)
+	}
+	cfg.Enabled = true
+	db, err := NewPostgresDB(postgresConfigOf(cfg))
+	if err != nil {
+		return nil, err
+	}
+	db.Connection = cfg.Name
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.connections[cfg.Name]; exists {
+		db.Close()
+		return nil, fmt.Errorf("postgres connection %q already exists", cfg.Name)
+	}
+	m.connections[cfg.Name] = db
+	return db, nil
+}
+
+// RemoveConnection closes a connection and removes it.
+func (m *PostgresConnectionManager) RemoveConnection(name string) error {
+	m.mu.Lock()
+	conn, exists := m.connections[name]
+	delete(m.connections, name)
+	m.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("postgres connection %q not found", name)
+	}
+	return conn.Close()
+}
+
+// CreateDatabase creates database name through the connection via unless
+// it exists, and reports whether it did.
+func (m *PostgresConnectionManager) CreateDatabase(ctx context.Context, via, name string) (bool, error) {
+	conn, exists := m.GetConnection(via)
+	if !exists || conn.DB == nil {
+		return false, fmt.Errorf("postgres connection %q not found", via)
+	}
+	var found bool
+	if err := conn.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&found); err != nil {
+		return false, fmt.Errorf("failed to look up database %q: %w", name, err)
+	}
+	if found {
+		return false, nil
+	}
+	// CREATE DATABASE takes no parameters
+	if _, err := conn.DB.ExecContext(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return false, fmt.Errorf("failed to create database %q: %w", name, err)
+	}
+	return true, nil
+}
+
 // GetConnection returns a specific named connection
 func (m *PostgresConnectionManager) GetConnection(name string) (*PostgresManager, bool) {
 	m.mu.RLock()
@@ -173,9 +279,11 @@ func (m *PostgresConnectionManager) CloseAll() error {
 
 	var errors []error
 	for name, conn := range m.connections {
+		conn.listener.close()
 		if err := conn.DB.Close(); err != nil {
 			errors = append(errors, fmt.Errorf("failed to close connection '%s': %w", name, err))
 		}
+		conn.credentials.release()
 	}
 
 	if len(errors) > 0 {
@@ -211,6 +319,20 @@ func (p *PostgresManager) GetStatus() map[string]interface{} {
 	stats["idle"] = dbStats.Idle
 	stats["wait_count"] = dbStats.WaitCount
 	stats["wait_duration_ms"] = dbStats.WaitDuration.Milliseconds()
+	stats["max_open_connections"] = dbStats.MaxOpenConnections
+	if replicas := p.replicas.status(); replicas != nil {
+		stats["replicas"] = replicas
+	}
+	stats["prepared_statements"] = p.statements.len()
+	if listen := p.listener.status(); listen != nil {
+		stats["listen"] = listen
+	}
+	if p.Pool != nil {
+		stats["pool"] = p.Pool.GetStatus()
+	}
+	if p.credentials != nil {
+		stats["credentials"] = p.credentials.status()
+	}
 
 	p.statusMu.Lock()
 	p.statusCache = stats
@@ -220,19 +342,33 @@ func (p *PostgresManager) GetStatus() map[string]interface{} {
 	return stats
 }
 
-// Query executes a query that returns rows, typically a SELECT.
+// Query executes a query that returns rows, typically a SELECT, on a
+// replica if the connection has a healthy one (see Reader).
 func (p *PostgresManager) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return p.DB.QueryContext(ctx, query, args...)
+	defer p.recordQuery(ctx, time.Now())
+	ctx, span := startSQLSpan(ctx, query)
+	rows, err := p.Reader(ctx).QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
 }
 
-// QueryRow executes a query that is expected to return at most one row.
+// QueryRow executes a query that is expected to return at most one row,
+// on a replica like Query.
 func (p *PostgresManager) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return p.DB.QueryRowContext(ctx, query, args...)
+	defer p.recordQuery(ctx, time.Now())
+	ctx, span := startSQLSpan(ctx, query)
+	row := p.Reader(ctx).QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
 }
 
-// Exec executes a query without returning any rows.
+// Exec executes a query without returning any rows, on the primary.
 func (p *PostgresManager) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return p.DB.ExecContext(ctx, query, args...)
+	defer p.recordQuery(ctx, time.Now())
+	ctx, span := startSQLSpan(ctx, query)
+	result, err := p.DB.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
 }
 
 // Select is a semantic alias for Query.
@@ -556,11 +692,16 @@ func (p *PostgresManager) SubmitAsyncJob(job func()) {
 	}
 }
 
-// Close closes the Postgres manager and its worker pool.
+// Close closes the Postgres manager, its worker pool, prepared statements
+// and replicas, and revokes its Vault credentials.
 func (p *PostgresManager) Close() error {
+	defer p.credentials.release()
 	if p.Pool != nil {
 		p.Pool.Close()
 	}
+	p.listener.close()
+	p.statements.close()
+	p.replicas.close()
 	if p.DB != nil {
 		return p.DB.Close()
 	}
@@ -572,8 +713,15 @@ func init() {
 		if !cfg.Postgres.Enabled && !cfg.PostgresMultiConfig.Enabled {
 			return nil, nil
 		}
-		if cfg.PostgresMultiConfig.Enabled {
-			return NewPostgresConnectionManager(cfg.PostgresMultiConfig)
+		// Provisioned tenants add connections while the server runs
+		var multi config.PostgresMultiConfig
+		var vaultCredentials bool
+		config.View(func() { multi, vaultCredentials = cfg.PostgresMultiConfig, usesVaultCredentials(cfg) })
+		if vaultCredentials && !cfg.Vault.Enabled {
+			return nil, fmt.Errorf("postgres: vault_role needs vault.enabled")
+		}
+		if multi.Enabled {
+			return NewPostgresConnectionManager(multi)
 		}
 		return NewPostgresDB(cfg.Postgres)
 	})
@@ -0,0 +1,145 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"stackyrd/pkg/sqlguard"
+)
+
+// ErrSchemaNotAllowed is returned when a console query uses a schema
+// outside ConsoleOptions.Schemas.
+var ErrSchemaNotAllowed = errors.New("schema not allowed")
+
+// ConsoleOptions are the guardrails of a query console run.
+type ConsoleOptions struct {
+	ReadOnly bool          // only read-only statements, in a read-only transaction
+	MaxRows  int           // rows returned at most; zero means no limit
+	Timeout  time.Duration // statement timeout; zero means none
+	Schemas  []string      // allowed schemas of the relations and FROM functions read; empty allows every schema
+	Args     []interface{} // values of the $1, $2... placeholders
+}
+
+// ConsoleResult is the outcome of a console query. Statements that return
+// no rows report RowsAffected instead.
+type ConsoleResult struct {
+	Command      string          `json:"command"`
+	Columns      []string        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowCount     int             `json:"row_count"`
+	Truncated    bool            `json:"truncated"` // more rows than MaxRows
+	RowsAffected *int64          `json:"rows_affected,omitempty"`
+	DurationMS   float64         `json:"duration_ms"`
+}
+
+// RunConsoleQuery runs one statement typed into the monitoring query
+// console after sqlguard accepts it. Read-only statements always run in a
+// read-only transaction; with Schemas set, the schemas the statement
+// touches are read from its plan (EXPLAIN VERBOSE) before it runs.
+func (p *PostgresManager) RunConsoleQuery(ctx context.Context, query string, opts ConsoleOptions) (*ConsoleResult, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	stmt, err := sqlguard.Check(query, sqlguard.Policy{ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := p.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: opts.ReadOnly || stmt.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if opts.Timeout > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", opts.Timeout.Milliseconds())); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Schemas) > 0 && stmt.Command != "SHOW" {
+		if err := checkSchemas(ctx, tx, stmt.Target, opts.Args, opts.Schemas); err != nil {
+			return nil, err
+		}
+	}
+
+	defer p.recordQuery(ctx, time.Now())
+	start := time.Now()
+	result := &ConsoleResult{Command: stmt.Command, Columns: []string{}, Rows: [][]interface{}{}}
+	if stmt.ReturnsRows {
+		err = readConsoleRows(ctx, tx, stmt.SQL, opts.Args, opts.MaxRows, result)
+	} else {
+		var res sql.Result
+		if res, err = tx.ExecContext(ctx, stmt.SQL, opts.Args...); err == nil {
+			affected, _ := res.RowsAffected()
+			result.RowsAffected = &affected
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	return result, nil
+}
+
+// checkSchemas plans target and rejects it when a relation or function
+// scanned by the plan lives outside allowed. Functions only called in
+// expressions are not seen (see PlanNode.Schemas); sqlguard refuses the
+// unsafe ones.
+func checkSchemas(ctx context.Context, tx *sql.Tx, target string, args []interface{}, allowed []string) error {
+	var raw []byte
+	if err := tx.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON, VERBOSE) "+target, args...).Scan(&raw); err != nil {
+		return fmt.Errorf("%w: the schemas of the statement could not be determined: %v", ErrSchemaNotAllowed, err)
+	}
+	plan, err := ParseExplainJSON(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaNotAllowed, err)
+	}
+	for _, schema := range plan.Plan.Schemas() {
+		if !slices.Contains(allowed, schema) {
+			return fmt.Errorf("%w: %s (allowed: %s)", ErrSchemaNotAllowed, schema, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// readConsoleRows reads up to maxRows rows into result, noting whether
+// more were available.
+func readConsoleRows(ctx context.Context, tx *sql.Tx, query string, args []interface{}, maxRows int, result *ConsoleResult) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if result.Columns, err = rows.Columns(); err != nil {
+		return err
+	}
+	for rows.Next() {
+		if maxRows > 0 && len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(result.Columns))
+		ptrs := make([]interface{}, len(values))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	result.RowCount = len(result.Rows)
+	return rows.Err()
+}
@@ -0,0 +1,300 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExplainQuery is returned for queries EXPLAIN cannot wrap.
+var ErrInvalidExplainQuery = errors.New("invalid query")
+
+// ExplainOptions controls an EXPLAIN run.
+type ExplainOptions struct {
+	Analyze bool          // execute the query for actual times and rows
+	Buffers bool          // shared/local buffer usage; requires Analyze
+	Timeout time.Duration // statement timeout; zero means none
+}
+
+// PlanNode is one node of a query plan with the costs a plan visualizer
+// needs. Exclusive values exclude the node's children.
+type PlanNode struct {
+	NodeType      string   `json:"node_type"`
+	Schema        string   `json:"schema,omitempty"` // with VERBOSE
+	Relation      string   `json:"relation,omitempty"`
+	Alias         string   `json:"alias,omitempty"`
+	Index         string   `json:"index,omitempty"`
+	JoinType      string   `json:"join_type,omitempty"`
+	StartupCost   float64  `json:"startup_cost"`
+	TotalCost     float64  `json:"total_cost"`
+	ExclusiveCost float64  `json:"exclusive_cost"`
+	CostPercent   float64  `json:"cost_percent"` // of the whole plan
+	PlanRows      float64  `json:"plan_rows"`
+	PlanWidth     int      `json:"plan_width"`
+	ActualRows    *float64 `json:"actual_rows,omitempty"`
+	ActualLoops   *float64 `json:"actual_loops,omitempty"`
+	ActualTimeMS  *float64 `json:"actual_time_ms,omitempty"`    // inclusive, over all loops
+	ExclusiveMS   *float64 `json:"exclusive_time_ms,omitempty"` // over all loops
+	// RowsMisestimate is actual/planned rows (>1 means underestimated).
+	RowsMisestimate *float64 `json:"rows_misestimate,omitempty"`
+	// Details holds the remaining EXPLAIN attributes (conditions, sort
+	// keys, buffers) as returned by PostgreSQL.
+	Details map[string]interface{} `json:"details,omitempty"`
+	Plans   []*PlanNode            `json:"plans,omitempty"`
+}
+
+// PlanSummary aggregates a plan for an overview.
+type PlanSummary struct {
+	TotalCost       float64        `json:"total_cost"`
+	PlanRows        float64        `json:"plan_rows"`
+	ActualRows      *float64       `json:"actual_rows,omitempty"`
+	PlanningTimeMS  *float64       `json:"planning_time_ms,omitempty"`
+	ExecutionTimeMS *float64       `json:"execution_time_ms,omitempty"`
+	Nodes           int            `json:"nodes"`
+	Depth           int            `json:"depth"`
+	NodeTypes       map[string]int `json:"node_types"`
+	SeqScans        []string       `json:"seq_scans,omitempty"` // relations read sequentially
+	// CostliestNode and SlowestNode are the node types with the largest
+	// exclusive cost and time.
+	CostliestNode string `json:"costliest_node"`
+	SlowestNode   string `json:"slowest_node,omitempty"`
+}
+
+// QueryPlan is the parsed result of EXPLAIN (FORMAT JSON).
+type QueryPlan struct {
+	Plan     *PlanNode       `json:"plan"`
+	Summary  PlanSummary     `json:"summary"`
+	Analyzed bool            `json:"analyzed"`
+	Raw      json.RawMessage `json:"raw"`
+}
+
+// Explain runs EXPLAIN (FORMAT JSON) for query inside a read-only
+// transaction that is always rolled back, so even with Analyze the query
+// cannot change data.
+func (p *PostgresManager) Explain(ctx context.Context, query string, opts ExplainOptions) (*QueryPlan, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	if query == "" {
+		return nil, fmt.Errorf("%w: query is empty", ErrInvalidExplainQuery)
+	}
+	if strings.HasPrefix(strings.ToUpper(query), "EXPLAIN") {
+		return nil, fmt.Errorf("%w: submit the query without EXPLAIN", ErrInvalidExplainQuery)
+	}
+
+	options := []string{"FORMAT JSON"}
+	if opts.Analyze {
+		options = append(options, "ANALYZE")
+		if opts.Buffers {
+			options = append(options, "BUFFERS")
+		}
+	}
+
+	tx, err := p.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if opts.Timeout > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", opts.Timeout.Milliseconds())); err != nil {
+			return nil, err
+		}
+	}
+
+	defer p.recordQuery(ctx, time.Now())
+	var raw []byte
+	if err := tx.QueryRowContext(ctx, "EXPLAIN ("+strings.Join(options, ", ")+") "+query).Scan(&raw); err != nil {
+		return nil, err
+	}
+	return ParseExplainJSON(raw)
+}
+
+// ParseExplainJSON parses the output of EXPLAIN (FORMAT JSON) and computes
+// exclusive costs, times and the summary.
+func ParseExplainJSON(raw []byte) (*QueryPlan, error) {
+	var doc []map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil || len(doc) == 0 {
+		return nil, fmt.Errorf("unexpected EXPLAIN output")
+	}
+	root, ok := doc[0]["Plan"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected EXPLAIN output: no plan")
+	}
+
+	plan := &QueryPlan{Plan: parsePlanNode(root), Raw: raw}
+	plan.Analyzed = plan.Plan.ActualTimeMS != nil
+	s := &plan.Summary
+	s.TotalCost = plan.Plan.TotalCost
+	s.PlanRows = plan.Plan.PlanRows
+	s.ActualRows = plan.Plan.ActualRows
+	s.PlanningTimeMS = number(doc[0]["Planning Time"])
+	s.ExecutionTimeMS = number(doc[0]["Execution Time"])
+	s.NodeTypes = make(map[string]int)
+
+	var costliest, slowest *PlanNode
+	seq := make(map[string]bool)
+	var walk func(n *PlanNode, depth int)
+	walk = func(n *PlanNode, depth int) {
+		if s.TotalCost > 0 {
+			n.CostPercent = round2(n.ExclusiveCost / s.TotalCost * 100)
+		}
+		s.Nodes++
+		s.Depth = max(s.Depth, depth)
+		s.NodeTypes[n.NodeType]++
+		if n.NodeType == "Seq Scan" && n.Relation != "" {
+			seq[n.Relation] = true
+		}
+		if costliest == nil || n.ExclusiveCost > costliest.ExclusiveCost {
+			costliest = n
+		}
+		if n.ExclusiveMS != nil && (slowest == nil || *n.ExclusiveMS > *slowest.ExclusiveMS) {
+			slowest = n
+		}
+		for _, child := range n.Plans {
+			walk(child, depth+1)
+		}
+	}
+	walk(plan.Plan, 1)
+
+	for relation := range seq {
+		s.SeqScans = append(s.SeqScans, relation)
+	}
+	sort.Strings(s.SeqScans)
+	s.CostliestNode = describeNode(costliest)
+	if slowest != nil {
+		s.SlowestNode = describeNode(slowest)
+	}
+	return plan, nil
+}
+
+// planKeys are the EXPLAIN attributes mapped to PlanNode fields rather
+// than kept in Details.
+var planKeys = map[string]bool{
+	"Node Type": true, "Schema": true, "Relation Name": true, "Alias": true, "Index Name": true, "Join Type": true,
+	"Startup Cost": true, "Total Cost": true, "Plan Rows": true, "Plan Width": true,
+	"Actual Startup Time": true, "Actual Total Time": true, "Actual Rows": true, "Actual Loops": true,
+	"Plans": true,
+}
+
+func parsePlanNode(raw map[string]interface{}) *PlanNode {
+	n := &PlanNode{
+		NodeType:    fmt.Sprint(raw["Node Type"]),
+		Schema:      stringValue(raw["Schema"]),
+		Relation:    stringValue(raw["Relation Name"]),
+		Alias:       stringValue(raw["Alias"]),
+		Index:       stringValue(raw["Index Name"]),
+		JoinType:    stringValue(raw["Join Type"]),
+		StartupCost: numberOrZero(raw["Startup Cost"]),
+		TotalCost:   numberOrZero(raw["Total Cost"]),
+		PlanRows:    numberOrZero(raw["Plan Rows"]),
+		PlanWidth:   int(numberOrZero(raw["Plan Width"])),
+		ActualRows:  number(raw["Actual Rows"]),
+		ActualLoops: number(raw["Actual Loops"]),
+	}
+	for key, value := range raw {
+		if !planKeys[key] {
+			if n.Details == nil {
+				n.Details = make(map[string]interface{})
+			}
+			n.Details[key] = value
+		}
+	}
+
+	// Actual times are per loop
+	loops := 1.0
+	if n.ActualLoops != nil && *n.ActualLoops > 0 {
+		loops = *n.ActualLoops
+	}
+	if total := number(raw["Actual Total Time"]); total != nil {
+		inclusive := round2(*total * loops)
+		n.ActualTimeMS = &inclusive
+	}
+	if n.ActualRows != nil && n.PlanRows > 0 {
+		ratio := round2(*n.ActualRows / n.PlanRows)
+		n.RowsMisestimate = &ratio
+	}
+
+	n.ExclusiveCost = n.TotalCost
+	exclusive := 0.0
+	if n.ActualTimeMS != nil {
+		exclusive = *n.ActualTimeMS
+	}
+	children, _ := raw["Plans"].([]interface{})
+	for _, c := range children {
+		childRaw, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		child := parsePlanNode(childRaw)
+		n.Plans = append(n.Plans, child)
+		n.ExclusiveCost -= child.TotalCost
+		if child.ActualTimeMS != nil {
+			exclusive -= *child.ActualTimeMS
+		}
+	}
+	n.ExclusiveCost = round2(max(n.ExclusiveCost, 0))
+	if n.ActualTimeMS != nil {
+		exclusive = round2(max(exclusive, 0))
+		n.ExclusiveMS = &exclusive
+	}
+	return n
+}
+
+// Schemas returns the schemas EXPLAIN VERBOSE reports on the plan nodes:
+// those of the relations read and of the functions scanned in FROM.
+// Functions called in expressions (output lists, filters, conditions) are
+// not reported, as VERBOSE leaves their names unqualified whenever they
+// are on the search_path.
+func (n *PlanNode) Schemas() []string {
+	seen := make(map[string]bool)
+	var walk func(n *PlanNode)
+	walk = func(n *PlanNode) {
+		if n.Schema != "" {
+			seen[n.Schema] = true
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(n)
+	schemas := make([]string, 0, len(seen))
+	for schema := range seen {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+	return schemas
+}
+
+func describeNode(n *PlanNode) string {
+	if n.Relation != "" {
+		return n.NodeType + " on " + n.Relation
+	}
+	return n.NodeType
+}
+
+func number(v interface{}) *float64 {
+	if f, ok := v.(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+func numberOrZero(v interface{}) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func round2(f float64) float64 {
+	return float64(int64(f*100+0.5)) / 100
+}
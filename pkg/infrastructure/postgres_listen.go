@@ -0,0 +1,397 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	// listenMinBackoff and listenMaxBackoff bound the doubling wait before
+	// the listener reconnects after losing its connection.
+	listenMinBackoff = 500 * time.Millisecond
+	listenMaxBackoff = 30 * time.Second
+	// listenBuffer is the number of notifications a subscriber may fall
+	// behind by; further ones are dropped for it.
+	listenBuffer = 64
+	// maxChannelLen is Postgres' identifier length; longer channel names
+	// are truncated by the server and would never match.
+	maxChannelLen = 63
+)
+
+// ErrListenerClosed is returned by Listen once the connection is closed.
+var ErrListenerClosed = errors.New("postgres: listener is closed")
+
+// errListenIdle ends the listener when its last subscriber is gone.
+var errListenIdle = errors.New("postgres: no channel to listen on")
+
+// Notification is a NOTIFY received on a channel of Listen.
+type Notification struct {
+	Connection string `json:"connection"` // name of the connection, see PostgresManager.Tenant
+	Channel    string `json:"channel"`
+	Payload    string `json:"payload"`
+	PID        uint32 `json:"pid"` // backend process of the notifying session
+}
+
+// Data returns the notification as event data. JSON object and array
+// payloads, as triggers usually send with json_build_object, are decoded.
+func (n Notification) Data() map[string]interface{} {
+	data := map[string]interface{}{
+		"connection": n.Connection,
+		"channel":    n.Channel,
+		"payload":    n.Payload,
+		"pid":        n.PID,
+	}
+	if trimmed := strings.TrimSpace(n.Payload); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var payload interface{}
+		if err := json.Unmarshal([]byte(trimmed), &payload); err == nil {
+			data["payload"] = payload
+		}
+	}
+	return data
+}
+
+// postgresListener shares one connection between the Listen subscribers of
+// a connection. It holds a pool connection while there are subscribers and
+// takes a new one, LISTENing again, when it is lost. The zero value has no
+// subscribers.
+type postgresListener struct {
+	mu      sync.Mutex
+	subs    map[string][]chan Notification // channel -> subscribers
+	running bool
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+	// wake interrupts the wait for notifications so changed channels are
+	// LISTENed or UNLISTENed.
+	wake context.CancelFunc
+
+	connected  bool
+	reconnects int
+	dropped    int64
+	lastErr    string
+}
+
+// Listen subscribes to the notifications of channel. The channel stays
+// open across reconnections, notifications sent while the connection is
+// down are lost; it is closed by Unlisten or Close. The listener holds one
+// connection of the pool (see max_open_conns) while any channel has
+// subscribers.
+func (p *PostgresManager) Listen(channel string) (<-chan Notification, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	if channel == "" || len(channel) > maxChannelLen {
+		return nil, fmt.Errorf("postgres: channel name must be 1 to %d bytes, got %q", maxChannelLen, channel)
+	}
+
+	l := &p.listener
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrListenerClosed
+	}
+	ch := make(chan Notification, listenBuffer)
+	if l.subs == nil {
+		l.subs = make(map[string][]chan Notification)
+	}
+	l.subs[channel] = append(l.subs[channel], ch)
+	if !l.running {
+		l.running = true
+		l.stop, l.done = make(chan struct{}), make(chan struct{})
+		go p.listenLoop(l.stop, l.done)
+	} else if l.wake != nil {
+		l.wake()
+	}
+	return ch, nil
+}
+
+// Unlisten ends the subscription of ch and closes it. The channel is
+// UNLISTENed when it has no subscribers left.
+func (p *PostgresManager) Unlisten(ch <-chan Notification) {
+	l := &p.listener
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for channel, subs := range l.subs {
+		for i, sub := range subs {
+			if sub != ch {
+				continue
+			}
+			if len(subs) == 1 {
+				delete(l.subs, channel)
+			} else {
+				l.subs[channel] = append(subs[:i:i], subs[i+1:]...)
+			}
+			close(sub)
+			if l.wake != nil {
+				l.wake()
+			}
+			return
+		}
+	}
+}
+
+// BridgeNotifications broadcasts the notifications of channel to streamID
+// of b as "pg_notify" events (see Notification.Data), so services can push
+// database changes to SSE clients. stop ends the bridge.
+func (p *PostgresManager) BridgeNotifications(channel string, b *utils.EventBroadcaster, streamID string) (stop func(), err error) {
+	ch, err := p.Listen(channel)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for n := range ch {
+			b.Broadcast(streamID, "pg_notify", "NOTIFY "+n.Channel, n.Data())
+		}
+	}()
+	return func() { p.Unlisten(ch) }, nil
+}
+
+// NotifyBridges returns the notify entries of the connection's config,
+// the stream defaulting to the channel name.
+func (p *PostgresManager) NotifyBridges() []config.PostgresNotifyConfig {
+	bridges := make([]config.PostgresNotifyConfig, 0, len(p.notify))
+	for _, n := range p.notify {
+		if n.Stream == "" {
+			n.Stream = n.Channel
+		}
+		bridges = append(bridges, n)
+	}
+	return bridges
+}
+
+// listenLoop keeps a listening connection until stop is closed or no
+// subscriber is left, reconnecting with a doubling backoff.
+func (p *PostgresManager) listenLoop(stop, done chan struct{}) {
+	defer close(done)
+	l := &p.listener
+	backoff := listenMinBackoff
+	for {
+		err := p.listenConn(stop)
+		if errors.Is(err, errListenIdle) {
+			return
+		}
+
+		l.mu.Lock()
+		if l.connected {
+			backoff = listenMinBackoff
+		}
+		l.connected = false
+		if err != nil {
+			l.lastErr = err.Error()
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, listenMaxBackoff)
+
+		l.mu.Lock()
+		if len(l.subs) == 0 {
+			l.running = false
+			l.lastErr = ""
+			l.mu.Unlock()
+			return
+		}
+		l.reconnects++
+		l.mu.Unlock()
+	}
+}
+
+// listenConn takes a connection of the pool and listens on it until it
+// fails, stop is closed or no subscriber is left. The connection is never
+// handed back to the pool, as it may still be listening.
+func (p *PostgresManager) listenConn(stop chan struct{}) (err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rawErr := conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			err = fmt.Errorf("postgres: LISTEN needs the pgx driver, got %T", driverConn)
+		} else {
+			err = p.serveListen(ctx, c.Conn())
+		}
+		return driver.ErrBadConn
+	})
+	if err == nil {
+		err = rawErr
+	}
+	return err
+}
+
+// serveListen LISTENs on the subscribed channels and delivers the
+// notifications, until conn fails or no subscriber is left.
+func (p *PostgresManager) serveListen(ctx context.Context, conn *pgx.Conn) error {
+	l := &p.listener
+	listened := make(map[string]bool)
+	for {
+		if err := l.syncChannels(ctx, conn, listened); err != nil {
+			return err
+		}
+
+		waitCtx, wake := context.WithCancel(ctx)
+		l.mu.Lock()
+		if len(l.subs) == 0 {
+			l.running = false
+			l.mu.Unlock()
+			wake()
+			return errListenIdle
+		}
+		l.connected = true
+		l.lastErr = ""
+		l.wake = wake
+		if l.changedLocked(listened) {
+			wake() // subscribed or unsubscribed while LISTENing
+		}
+		l.mu.Unlock()
+
+		// A cancelled wait leaves the connection usable: pgx interrupts
+		// reads with a deadline
+		n, err := conn.WaitForNotification(waitCtx)
+		l.mu.Lock()
+		l.wake = nil
+		if err == nil {
+			l.deliverLocked(p.Connection, n)
+		}
+		l.mu.Unlock()
+		wake()
+		if err != nil && (ctx.Err() != nil || waitCtx.Err() == nil) {
+			return err
+		}
+	}
+}
+
+// syncChannels LISTENs on the channels subscribed since the last call and
+// UNLISTENs those without subscribers.
+func (l *postgresListener) syncChannels(ctx context.Context, conn *pgx.Conn, listened map[string]bool) error {
+	var listen, unlisten []string
+	l.mu.Lock()
+	for channel := range l.subs {
+		if !listened[channel] {
+			listen = append(listen, channel)
+		}
+	}
+	for channel := range listened {
+		if _, ok := l.subs[channel]; !ok {
+			unlisten = append(unlisten, channel)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, channel := range listen {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to listen on %q: %w", channel, err)
+		}
+		listened[channel] = true
+	}
+	for _, channel := range unlisten {
+		if _, err := conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to unlisten %q: %w", channel, err)
+		}
+		delete(listened, channel)
+	}
+	return nil
+}
+
+func (l *postgresListener) changedLocked(listened map[string]bool) bool {
+	if len(l.subs) != len(listened) {
+		return true
+	}
+	for channel := range l.subs {
+		if !listened[channel] {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverLocked hands n to the subscribers of its channel; a subscriber
+// with a full buffer misses it.
+func (l *postgresListener) deliverLocked(connection string, n *pgconn.Notification) {
+	for _, sub := range l.subs[n.Channel] {
+		select {
+		case sub <- Notification{Connection: connection, Channel: n.Channel, Payload: n.Payload, PID: n.PID}:
+		default:
+			l.dropped++
+		}
+	}
+}
+
+func (l *postgresListener) status() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.subs) == 0 && l.lastErr == "" {
+		return nil
+	}
+	channels := make([]string, 0, len(l.subs))
+	subscribers := 0
+	for channel, subs := range l.subs {
+		channels = append(channels, channel)
+		subscribers += len(subs)
+	}
+	sort.Strings(channels)
+	status := map[string]interface{}{
+		"connected":   l.connected,
+		"channels":    channels,
+		"subscribers": subscribers,
+		"reconnects":  l.reconnects,
+		"dropped":     l.dropped,
+	}
+	if l.lastErr != "" {
+		status["error"] = l.lastErr
+	}
+	return status
+}
+
+// close stops the listener and closes the subscriber channels; Listen
+// fails afterwards.
+func (l *postgresListener) close() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	running, stop, done := l.running, l.stop, l.done
+	l.running = false
+	for _, subs := range l.subs {
+		for _, sub := range subs {
+			close(sub)
+		}
+	}
+	l.subs = nil
+	l.mu.Unlock()
+	if running {
+		close(stop)
+		<-done
+	}
+}
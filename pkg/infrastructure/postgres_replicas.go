@@ -0,0 +1,251 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"stackyrd/config"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// replicaCheckInterval is how often replicas are pinged; a replica
+	// that fails a ping gets no reads until it answers again.
+	replicaCheckInterval = 10 * time.Second
+	replicaPingTimeout   = 2 * time.Second
+
+	gormPrimaryPoolKey = "replicas:primary_pool"
+)
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary, for reads
+// that must see the caller's own writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usesPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// postgresReplica is a read replica and its last health check.
+type postgresReplica struct {
+	name    string // host:port, without credentials
+	db      *sql.DB
+	healthy atomic.Bool
+
+	mu      sync.Mutex
+	lastErr string
+}
+
+// postgresReplicas spreads reads round-robin over the healthy replicas of
+// a connection. The zero value has no replicas.
+type postgresReplicas struct {
+	mu   sync.RWMutex
+	list []*postgresReplica
+	next atomic.Uint64
+	stop chan struct{}
+}
+
+// applyPostgresPool applies the configured pool settings to db; zero
+// settings keep database/sql's defaults.
+func applyPostgresPool(db *sql.DB, cfg config.PostgresConfig) {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
+	}
+}
+
+// openPostgresReplicas adds the replicas of cfg to p with the pool
+// settings of the primary. An unreachable replica is added unhealthy and
+// starts taking reads once a health check reaches it.
+func (p *PostgresManager) openPostgresReplicas(cfg config.PostgresConfig) error {
+	for _, dsn := range cfg.Replicas {
+		connConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return fmt.Errorf("invalid postgres replica dsn: %w", err)
+		}
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return fmt.Errorf("failed to open postgres replica %s: %w", connConfig.Host, err)
+		}
+		applyPostgresPool(db, cfg)
+		p.AddReplica(net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port))), db)
+	}
+	return nil
+}
+
+// AddReplica adds db as a read replica called name. Query, QueryRow,
+// Select and GORM finds go to a healthy replica, round-robin; Close
+// closes it.
+func (p *PostgresManager) AddReplica(name string, db *sql.DB) {
+	replica := &postgresReplica{name: name, db: db}
+	replica.check()
+
+	r := &p.replicas
+	r.mu.Lock()
+	r.list = append(r.list, replica)
+	if r.stop == nil {
+		r.stop = make(chan struct{})
+		go r.checkLoop(r.stop)
+	}
+	r.mu.Unlock()
+}
+
+// Reader returns the pool reads of ctx go to: a healthy replica, else the
+// primary.
+func (p *PostgresManager) Reader(ctx context.Context) *sql.DB {
+	if db := p.replicas.pick(ctx); db != nil {
+		return db
+	}
+	return p.DB
+}
+
+// pick returns the next healthy replica, or nil when ctx asks for the
+// primary or no replica is healthy.
+func (r *postgresReplicas) pick(ctx context.Context) *sql.DB {
+	if usesPrimary(ctx) {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := len(r.list)
+	if n == 0 {
+		return nil
+	}
+	start := int(r.next.Add(1) % uint64(n))
+	for i := 0; i < n; i++ {
+		if replica := r.list[(start+i)%n]; replica.healthy.Load() {
+			return replica.db
+		}
+	}
+	return nil
+}
+
+func (r *postgresReplicas) checkLoop(stop chan struct{}) {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.mu.RLock()
+			list := append([]*postgresReplica(nil), r.list...)
+			r.mu.RUnlock()
+			for _, replica := range list {
+				replica.check()
+			}
+		}
+	}
+}
+
+func (r *postgresReplica) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+	err := r.db.PingContext(ctx)
+	r.healthy.Store(err == nil)
+	r.mu.Lock()
+	r.lastErr = ""
+	if err != nil {
+		r.lastErr = err.Error()
+	}
+	r.mu.Unlock()
+}
+
+func (r *postgresReplicas) status() []map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.list) == 0 {
+		return nil
+	}
+	status := make([]map[string]interface{}, 0, len(r.list))
+	for _, replica := range r.list {
+		stats := replica.db.Stats()
+		entry := map[string]interface{}{
+			"name":             replica.name,
+			"healthy":          replica.healthy.Load(),
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+		}
+		replica.mu.Lock()
+		if replica.lastErr != "" {
+			entry["error"] = replica.lastErr
+		}
+		replica.mu.Unlock()
+		status = append(status, entry)
+	}
+	return status
+}
+
+// close stops the health checks and closes the replicas.
+func (r *postgresReplicas) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	var firstErr error
+	for _, replica := range r.list {
+		if err := replica.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.list = nil
+	return firstErr
+}
+
+// registerReplicaCallbacks sends GORM queries to a replica. Queries in a
+// transaction, locking queries (FOR UPDATE) and contexts of WithPrimary
+// stay on the primary.
+func registerReplicaCallbacks(db *gorm.DB, replicas *postgresReplicas) {
+	before := func(tx *gorm.DB) {
+		if _, inTx := tx.Statement.ConnPool.(gorm.TxCommitter); inTx {
+			return
+		}
+		if _, locking := tx.Statement.Clauses["FOR"]; locking {
+			return
+		}
+		replica := replicas.pick(tx.Statement.Context)
+		if replica == nil {
+			return
+		}
+		tx.InstanceSet(gormPrimaryPoolKey, tx.Statement.ConnPool)
+		tx.Statement.ConnPool = replica
+	}
+	// Statements of chained calls are reused, so the next write on the
+	// same *gorm.DB must find the primary again.
+	after := func(tx *gorm.DB) {
+		v, _ := tx.InstanceGet(gormPrimaryPoolKey)
+		if pool, ok := v.(gorm.ConnPool); ok {
+			tx.Statement.ConnPool = pool
+			tx.InstanceSet(gormPrimaryPoolKey, nil)
+		}
+	}
+
+	cb := db.Callback()
+	cb.Query().Before("gorm:query").Register("replicas:before_query", before)
+	cb.Query().After("gorm:query").Register("replicas:after_query", after)
+}
@@ -0,0 +1,188 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchemaInfo is one schema of a database with its tables.
+type SchemaInfo struct {
+	Name      string      `json:"name"`
+	Owner     string      `json:"owner"`
+	SizeBytes int64       `json:"size_bytes"` // tables and their indexes
+	Tables    []TableInfo `json:"tables"`
+}
+
+// TableInfo describes a table, view, materialized view or foreign table.
+type TableInfo struct {
+	Name        string       `json:"name"`
+	Kind        string       `json:"kind"`
+	Comment     string       `json:"comment,omitempty"`
+	RowEstimate *int64       `json:"row_estimate"` // from the planner statistics; null before the first ANALYZE
+	TotalBytes  int64        `json:"total_bytes"`  // table, indexes and TOAST
+	TableBytes  int64        `json:"table_bytes"`
+	IndexBytes  int64        `json:"index_bytes"`
+	Columns     []ColumnInfo `json:"columns"`
+	Indexes     []IndexInfo  `json:"indexes"`
+}
+
+// ColumnInfo is a column of a table.
+type ColumnInfo struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Nullable   bool    `json:"nullable"`
+	Default    *string `json:"default"`
+	PrimaryKey bool    `json:"primary_key"`
+}
+
+// IndexInfo is an index of a table.
+type IndexInfo struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+	Unique     bool   `json:"unique"`
+	Primary    bool   `json:"primary"`
+	SizeBytes  int64  `json:"size_bytes"`
+}
+
+// relationKinds maps pg_class.relkind to TableInfo.Kind.
+var relationKinds = map[string]string{
+	"r": "table",
+	"p": "partitioned table",
+	"v": "view",
+	"m": "materialized view",
+	"f": "foreign table",
+}
+
+// userSchemas restricts a catalog query on namespace n to the schemas of
+// users, and to $1 when it is not empty.
+const userSchemas = `n.nspname NOT IN ('pg_catalog', 'information_schema')
+	AND n.nspname NOT LIKE 'pg\_toast%' AND n.nspname NOT LIKE 'pg\_temp\_%'
+	AND ($1 = '' OR n.nspname = $1)`
+
+// Schemas lists the user schemas of the database (or only schema, when
+// not empty) with their tables, columns, indexes, row estimates and sizes.
+// Everything comes from the system catalogs; no table is read.
+func (p *PostgresManager) Schemas(ctx context.Context, schema string) ([]SchemaInfo, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	defer p.recordQuery(ctx, time.Now())
+
+	var schemas []SchemaInfo
+	bySchema := make(map[string]*SchemaInfo)
+	err := scanRows(ctx, p.DB, `SELECT n.nspname, pg_get_userbyid(n.nspowner)
+		FROM pg_namespace n WHERE `+userSchemas+` ORDER BY 1`, schema, func(rows *sql.Rows) error {
+		var s SchemaInfo
+		if err := rows.Scan(&s.Name, &s.Owner); err != nil {
+			return err
+		}
+		s.Tables = []TableInfo{}
+		schemas = append(schemas, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range schemas {
+		bySchema[schemas[i].Name] = &schemas[i]
+	}
+
+	tables := make(map[string]*TableInfo)
+	err = scanRows(ctx, p.DB, `SELECT n.nspname, c.relname, c.relkind, COALESCE(obj_description(c.oid, 'pg_class'), ''),
+			c.reltuples::bigint, pg_total_relation_size(c.oid), pg_relation_size(c.oid), pg_indexes_size(c.oid)
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f') AND `+userSchemas+`
+		ORDER BY 1, 2`, schema, func(rows *sql.Rows) error {
+		var schemaName, kind string
+		var estimate int64
+		t := TableInfo{Columns: []ColumnInfo{}, Indexes: []IndexInfo{}}
+		if err := rows.Scan(&schemaName, &t.Name, &kind, &t.Comment, &estimate, &t.TotalBytes, &t.TableBytes, &t.IndexBytes); err != nil {
+			return err
+		}
+		t.Kind = relationKinds[kind]
+		// reltuples is -1 for tables never vacuumed or analyzed
+		if estimate >= 0 && kind != "v" {
+			t.RowEstimate = &estimate
+		}
+		if s, ok := bySchema[schemaName]; ok {
+			s.Tables = append(s.Tables, t)
+			s.SizeBytes += t.TotalBytes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range schemas {
+		for j := range schemas[i].Tables {
+			tables[schemas[i].Name+"."+schemas[i].Tables[j].Name] = &schemas[i].Tables[j]
+		}
+	}
+
+	err = scanRows(ctx, p.DB, `SELECT n.nspname, c.relname, a.attname, format_type(a.atttypid, a.atttypmod),
+			NOT a.attnotnull, pg_get_expr(d.adbin, d.adrelid),
+			EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey))
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attnum > 0 AND NOT a.attisdropped AND c.relkind IN ('r', 'p', 'v', 'm', 'f') AND `+userSchemas+`
+		ORDER BY n.nspname, c.relname, a.attnum`, schema, func(rows *sql.Rows) error {
+		var schemaName, table string
+		var col ColumnInfo
+		if err := rows.Scan(&schemaName, &table, &col.Name, &col.Type, &col.Nullable, &col.Default, &col.PrimaryKey); err != nil {
+			return err
+		}
+		if t, ok := tables[schemaName+"."+table]; ok {
+			t.Columns = append(t.Columns, col)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanRows(ctx, p.DB, `SELECT n.nspname, t.relname, i.relname, pg_get_indexdef(i.oid),
+			x.indisunique, x.indisprimary, pg_relation_size(i.oid)
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE `+userSchemas+`
+		ORDER BY 1, 2, 3`, schema, func(rows *sql.Rows) error {
+		var schemaName, table string
+		var idx IndexInfo
+		if err := rows.Scan(&schemaName, &table, &idx.Name, &idx.Definition, &idx.Unique, &idx.Primary, &idx.SizeBytes); err != nil {
+			return err
+		}
+		if t, ok := tables[schemaName+"."+table]; ok {
+			t.Indexes = append(t.Indexes, idx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if schemas == nil {
+		schemas = []SchemaInfo{}
+	}
+	return schemas, nil
+}
+
+// scanRows runs a catalog query with one argument and calls fn per row.
+func scanRows(ctx context.Context, db *sql.DB, query, arg string, fn func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, strings.TrimSpace(arg))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
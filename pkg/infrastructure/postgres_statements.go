@@ -0,0 +1,145 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/tracing"
+)
+
+// ErrStatementConflict is returned by Prepare when the name is taken by
+// another query.
+var ErrStatementConflict = errors.New("postgres: statement name is prepared with another query")
+
+// Statement is a named prepared statement of a connection. It is prepared
+// on the primary by Prepare and on a replica the first time a read goes
+// there; database/sql re-prepares it on new pool connections.
+type Statement struct {
+	Name string
+	SQL  string
+
+	pg      *PostgresManager
+	mu      sync.Mutex
+	primary *sql.Stmt
+	byPool  map[*sql.DB]*sql.Stmt // replica statements
+}
+
+// postgresStatements are the named statements of a connection.
+type postgresStatements struct {
+	mu     sync.Mutex
+	byName map[string]*Statement
+}
+
+// Prepare prepares query as statement name on the primary. Preparing a
+// name again with the same query returns the registered statement, so
+// services can prepare their statements on every Init.
+func (p *PostgresManager) Prepare(ctx context.Context, name, query string) (*Statement, error) {
+	p.statements.mu.Lock()
+	defer p.statements.mu.Unlock()
+	if s, ok := p.statements.byName[name]; ok {
+		if s.SQL != query {
+			return nil, fmt.Errorf("%w: %q", ErrStatementConflict, name)
+		}
+		return s, nil
+	}
+	stmt, err := p.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement %q: %w", name, err)
+	}
+	s := &Statement{Name: name, SQL: query, pg: p, primary: stmt}
+	if p.statements.byName == nil {
+		p.statements.byName = make(map[string]*Statement)
+	}
+	p.statements.byName[name] = s
+	return s, nil
+}
+
+// Statement returns the statement prepared as name.
+func (p *PostgresManager) Statement(name string) (*Statement, bool) {
+	p.statements.mu.Lock()
+	defer p.statements.mu.Unlock()
+	s, ok := p.statements.byName[name]
+	return s, ok
+}
+
+// Query runs the statement on a replica if the connection has a healthy
+// one (see Reader), else on the primary.
+func (s *Statement) Query(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	defer s.pg.recordQuery(ctx, time.Now())
+	ctx, span := startSQLSpan(ctx, s.SQL)
+	rows, err := s.reader(ctx).QueryContext(ctx, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+// QueryRow runs the statement for at most one row, on a replica like
+// Query.
+func (s *Statement) QueryRow(ctx context.Context, args ...interface{}) *sql.Row {
+	defer s.pg.recordQuery(ctx, time.Now())
+	ctx, span := startSQLSpan(ctx, s.SQL)
+	row := s.reader(ctx).QueryRowContext(ctx, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+// Exec runs the statement on the primary.
+func (s *Statement) Exec(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	defer s.pg.recordQuery(ctx, time.Now())
+	ctx, span := startSQLSpan(ctx, s.SQL)
+	result, err := s.primary.ExecContext(ctx, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+// reader returns the statement on the pool reads of ctx go to. A replica
+// it cannot be prepared on leaves the read to the primary.
+func (s *Statement) reader(ctx context.Context) *sql.Stmt {
+	db := s.pg.replicas.pick(ctx)
+	if db == nil {
+		return s.primary
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stmt, ok := s.byPool[db]; ok {
+		return stmt
+	}
+	stmt, err := db.PrepareContext(ctx, s.SQL)
+	if err != nil {
+		return s.primary
+	}
+	if s.byPool == nil {
+		s.byPool = make(map[*sql.DB]*sql.Stmt)
+	}
+	s.byPool[db] = stmt
+	return stmt
+}
+
+func (s *Statement) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.primary.Close()
+	for _, stmt := range s.byPool {
+		stmt.Close()
+	}
+	s.byPool = nil
+}
+
+func (st *postgresStatements) len() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.byName)
+}
+
+// close closes the statements; they are closed before their pools.
+func (st *postgresStatements) close() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, s := range st.byName {
+		s.close()
+	}
+	st.byName = nil
+}
@@ -0,0 +1,241 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"stackyrd/pkg/sqlbuilder"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrStatStatementsUnavailable is returned by TopQueries and
+// ResetQueryStats when the pg_stat_statements extension is not installed
+// in the database or not loaded through shared_preload_libraries.
+var ErrStatStatementsUnavailable = errors.New("pg_stat_statements is not available")
+
+// QueryStat is a normalized statement of pg_stat_statements with its
+// accumulated execution statistics.
+type QueryStat struct {
+	QueryID        int64   `json:"query_id"`
+	Query          string  `json:"query"`
+	User           string  `json:"user"`
+	Calls          int64   `json:"calls"`
+	TotalMS        float64 `json:"total_ms"`
+	MeanMS         float64 `json:"mean_ms"`
+	MinMS          float64 `json:"min_ms"`
+	MaxMS          float64 `json:"max_ms"`
+	StddevMS       float64 `json:"stddev_ms"`
+	Rows           int64   `json:"rows"`
+	SharedBlksHit  int64   `json:"shared_blks_hit"`
+	SharedBlksRead int64   `json:"shared_blks_read"`
+	CacheHitRatio  float64 `json:"cache_hit_ratio"`  // shared buffer hits of all block reads, 1 without reads
+	PercentOfTotal float64 `json:"percent_of_total"` // of the total time of all statements of the database
+}
+
+// IndexUsage is the scan count and size of an index, from
+// pg_stat_user_indexes.
+type IndexUsage struct {
+	Schema     string `json:"schema"`
+	Table      string `json:"table"`
+	Index      string `json:"index"`
+	Definition string `json:"definition"`
+	Scans      int64  `json:"scans"`
+	TupRead    int64  `json:"tuples_read"`
+	TupFetch   int64  `json:"tuples_fetched"`
+	SizeBytes  int64  `json:"size_bytes"`
+	Unique     bool   `json:"unique"`
+	Primary    bool   `json:"primary"`
+	// Unused is set for never scanned indexes that enforce no constraint;
+	// they cost writes and space for nothing.
+	Unused bool `json:"unused"`
+}
+
+// TableStats are the scan counters and dead tuples of a table, from
+// pg_stat_user_tables, with a bloat estimate.
+type TableStats struct {
+	Schema     string  `json:"schema"`
+	Table      string  `json:"table"`
+	TableBytes int64   `json:"table_bytes"`
+	LiveTuples int64   `json:"live_tuples"`
+	DeadTuples int64   `json:"dead_tuples"`
+	SeqScans   int64   `json:"seq_scans"`
+	SeqTupRead int64   `json:"seq_tuples_read"`
+	IndexScans int64   `json:"index_scans"`
+	DeadRatio  float64 `json:"dead_ratio"`
+	// BloatBytes estimates the space held by dead tuples: the table size
+	// times their share of all tuples. VACUUM makes it reusable, VACUUM
+	// FULL or pg_repack returns it.
+	BloatBytes      int64      `json:"estimated_bloat_bytes"`
+	LastVacuum      *time.Time `json:"last_vacuum"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum"`
+	LastAnalyze     *time.Time `json:"last_analyze"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze"`
+}
+
+// TopQueries returns the limit statements of the connection's database
+// from pg_stat_statements with the highest total or mean time, calls,
+// rows or block reads (order "total", "mean", "calls", "rows" or "reads";
+// others fail with sqlbuilder.ErrInvalidSort).
+func (p *PostgresManager) TopQueries(ctx context.Context, order string, limit int) ([]QueryStat, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	defer p.recordQuery(ctx, time.Now())
+
+	var installed bool
+	if err := p.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')").Scan(&installed); err != nil {
+		return nil, err
+	}
+	if !installed {
+		return nil, fmt.Errorf("%w: run CREATE EXTENSION pg_stat_statements", ErrStatStatementsUnavailable)
+	}
+	var version int
+	if err := p.DB.QueryRowContext(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return nil, err
+	}
+	query, args, err := topQueriesSQL(version, order, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, statStatementsError(err)
+	}
+	defer rows.Close()
+	stats := []QueryStat{}
+	for rows.Next() {
+		var s QueryStat
+		var queryID sql.NullInt64
+		var percent sql.NullFloat64
+		if err := rows.Scan(&queryID, &s.Query, &s.User, &s.Calls, &s.TotalMS, &s.MeanMS, &s.MinMS, &s.MaxMS, &s.StddevMS,
+			&s.Rows, &s.SharedBlksHit, &s.SharedBlksRead, &percent); err != nil {
+			return nil, err
+		}
+		s.QueryID = queryID.Int64
+		s.PercentOfTotal = percent.Float64
+		s.CacheHitRatio = 1
+		if blocks := s.SharedBlksHit + s.SharedBlksRead; blocks > 0 {
+			s.CacheHitRatio = float64(s.SharedBlksHit) / float64(blocks)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// topQueriesSQL builds the pg_stat_statements query for a server version;
+// the time columns got an _exec_ infix in Postgres 13.
+func topQueriesSQL(version int, order string, limit int) (string, []interface{}, error) {
+	col := func(stat string) string { return stat + "_time" }
+	if version >= 130000 {
+		col = func(stat string) string { return stat + "_exec_time" }
+	}
+	orderBy, err := sqlbuilder.Sort{
+		Columns: map[string]string{
+			"total": col("total"),
+			"mean":  col("mean"),
+			"calls": "calls",
+			"rows":  "rows",
+			"reads": "shared_blks_read",
+		},
+		Default: "total",
+	}.OrderBy(order, "desc")
+	if err != nil {
+		return "", nil, err
+	}
+	query, args := sqlbuilder.Select(
+		"queryid", "query", "pg_get_userbyid(userid)", "calls",
+		col("total"), col("mean"), col("min"), col("max"), col("stddev"),
+		"rows", "shared_blks_hit", "shared_blks_read",
+		"100 * "+col("total")+" / NULLIF(sum("+col("total")+") OVER (), 0)",
+	).From("pg_stat_statements").
+		Where("dbid = (SELECT oid FROM pg_database WHERE datname = current_database())").
+		OrderBy(orderBy).
+		Limit(limit).
+		SQL()
+	return query, args, nil
+}
+
+// ResetQueryStats discards the statistics pg_stat_statements gathered.
+func (p *PostgresManager) ResetQueryStats(ctx context.Context) error {
+	if p.DB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	defer p.recordQuery(ctx, time.Now())
+	if _, err := p.DB.ExecContext(ctx, "SELECT pg_stat_statements_reset()"); err != nil {
+		return statStatementsError(err)
+	}
+	return nil
+}
+
+// statStatementsError wraps the errors of a server without the extension
+// (42883, undefined function) or without the library loaded (55000) in
+// ErrStatStatementsUnavailable.
+func statStatementsError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "55000" || pgErr.Code == "42883") {
+		return fmt.Errorf("%w: %s", ErrStatStatementsUnavailable, pgErr.Message)
+	}
+	return err
+}
+
+// IndexUsage lists the indexes of the user schemas (or only schema, when
+// not empty), least scanned and largest first.
+func (p *PostgresManager) IndexUsage(ctx context.Context, schema string) ([]IndexUsage, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	defer p.recordQuery(ctx, time.Now())
+
+	indexes := []IndexUsage{}
+	err := scanRows(ctx, p.DB, `SELECT s.schemaname, s.relname, s.indexrelname, pg_get_indexdef(s.indexrelid),
+			s.idx_scan, s.idx_tup_read, s.idx_tup_fetch, pg_relation_size(s.indexrelid), x.indisunique, x.indisprimary
+		FROM pg_stat_user_indexes s JOIN pg_index x ON x.indexrelid = s.indexrelid
+		WHERE ($1 = '' OR s.schemaname = $1)
+		ORDER BY s.idx_scan, pg_relation_size(s.indexrelid) DESC, 1, 2, 3`, schema, func(rows *sql.Rows) error {
+		var idx IndexUsage
+		if err := rows.Scan(&idx.Schema, &idx.Table, &idx.Index, &idx.Definition,
+			&idx.Scans, &idx.TupRead, &idx.TupFetch, &idx.SizeBytes, &idx.Unique, &idx.Primary); err != nil {
+			return err
+		}
+		idx.Unused = idx.Scans == 0 && !idx.Unique && !idx.Primary
+		indexes = append(indexes, idx)
+		return nil
+	})
+	return indexes, err
+}
+
+// TableStats lists the tables of the user schemas (or only schema, when
+// not empty), most estimated bloat first.
+func (p *PostgresManager) TableStats(ctx context.Context, schema string) ([]TableStats, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	defer p.recordQuery(ctx, time.Now())
+
+	tables := []TableStats{}
+	err := scanRows(ctx, p.DB, `SELECT schemaname, relname, pg_relation_size(relid), n_live_tup, n_dead_tup,
+			seq_scan, seq_tup_read, COALESCE(idx_scan, 0),
+			last_vacuum, last_autovacuum, last_analyze, last_autoanalyze
+		FROM pg_stat_user_tables
+		WHERE ($1 = '' OR schemaname = $1)
+		ORDER BY pg_relation_size(relid) * n_dead_tup / GREATEST(n_live_tup + n_dead_tup, 1) DESC, 1, 2`, schema, func(rows *sql.Rows) error {
+		var t TableStats
+		if err := rows.Scan(&t.Schema, &t.Table, &t.TableBytes, &t.LiveTuples, &t.DeadTuples,
+			&t.SeqScans, &t.SeqTupRead, &t.IndexScans,
+			&t.LastVacuum, &t.LastAutovacuum, &t.LastAnalyze, &t.LastAutoanalyze); err != nil {
+			return err
+		}
+		if tuples := t.LiveTuples + t.DeadTuples; tuples > 0 {
+			t.DeadRatio = float64(t.DeadTuples) / float64(tuples)
+			t.BloatBytes = int64(float64(t.TableBytes) * t.DeadRatio)
+		}
+		tables = append(tables, t)
+		return nil
+	})
+	return tables, err
+}
@@ -0,0 +1,193 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	// vaultAwaitTimeout bounds how long a Postgres connection with a
+	// vault_role waits for the vault component at boot.
+	vaultAwaitTimeout = 30 * time.Second
+	// postgresMaxIdleConns is database/sql's default, restored after the
+	// idle connections of rotated credentials are closed unless
+	// max_idle_conns is set.
+	postgresMaxIdleConns = 2
+)
+
+// postgresCredentials are the Vault-issued credentials a pool logs in
+// with. Rotation swaps them for new connections and closes the idle ones
+// of the old user; connections in use are retired by the pool's
+// connection lifetime, a quarter of the lease TTL, before the old lease
+// runs out.
+type postgresCredentials struct {
+	connConfig *pgx.ConnConfig // without user and password
+	idleConns  int             // max_idle_conns, restored after rotation
+
+	mu        sync.RWMutex
+	db        *sql.DB
+	role      string
+	username  string
+	password  string
+	rotations int
+	rotatedAt time.Time
+	releaseFn func()
+}
+
+// newVaultPostgresDB connects with dynamic credentials of cfg.VaultRole.
+// The pool logs in through a BeforeConnect hook, so DB and ORM stay the
+// same when the credentials rotate.
+func newVaultPostgresDB(cfg config.PostgresConfig) (*PostgresManager, error) {
+	vault, err := awaitVault(vaultAwaitTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("postgres vault_role %q: %w", cfg.VaultRole, err)
+	}
+	connConfig, err := pgx.ParseConfig(fmt.Sprintf("host=%s port=%d dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postgres config: %w", err)
+	}
+
+	creds := &postgresCredentials{connConfig: connConfig, role: cfg.VaultRole}
+	issued, release, err := vault.DatabaseCredentials(context.Background(), cfg.VaultRole, creds.rotate)
+	if err != nil {
+		return nil, err
+	}
+	creds.username, creds.password, creds.releaseFn = issued.Username, issued.Password, release
+
+	sqlDB := stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+		cc.User, cc.Password = creds.current()
+		return nil
+	}))
+	applyPostgresPool(sqlDB, cfg)
+	creds.idleConns = postgresMaxIdleConns
+	if cfg.MaxIdleConns > 0 {
+		creds.idleConns = cfg.MaxIdleConns
+	}
+	// Connections must be retired well before the lease runs out
+	if lifetime := issued.TTL / 4; lifetime > 0 && (cfg.ConnMaxLifetime <= 0 || lifetime < time.Duration(cfg.ConnMaxLifetime)*time.Second) {
+		sqlDB.SetConnMaxLifetime(lifetime)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		release()
+		return nil, fmt.Errorf("failed to connect to postgres as %s: %w", issued.Username, err)
+	}
+	creds.mu.Lock()
+	creds.db = sqlDB
+	creds.mu.Unlock()
+
+	manager, err := newPostgresManager(sqlDB, cfg)
+	if err != nil {
+		sqlDB.Close()
+		release()
+		return nil, err
+	}
+	manager.credentials = creds
+	return manager, nil
+}
+
+func (c *postgresCredentials) current() (username, password string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username, c.password
+}
+
+// rotate checks that the new credentials log in, then switches the pool to
+// them. On error the pool keeps the old ones.
+func (c *postgresCredentials) rotate(next DatabaseCredentials) error {
+	cc := c.connConfig.Copy()
+	cc.User, cc.Password = next.Username, next.Password
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := pgx.ConnectConfig(ctx, cc)
+	if err != nil {
+		return fmt.Errorf("postgres login as %s failed: %w", next.Username, err)
+	}
+	conn.Close(ctx)
+
+	c.mu.Lock()
+	c.username, c.password = next.Username, next.Password
+	c.rotations++
+	c.rotatedAt = time.Now()
+	db := c.db
+	c.mu.Unlock()
+	if db != nil {
+		db.SetMaxIdleConns(0)
+		db.SetMaxIdleConns(c.idleConns)
+	}
+	return nil
+}
+
+// release revokes the lease; nil for connections without a vault_role.
+func (c *postgresCredentials) release() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	release := c.releaseFn
+	c.releaseFn = nil
+	c.mu.Unlock()
+	if release != nil {
+		release()
+	}
+}
+
+func (c *postgresCredentials) status() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	status := map[string]interface{}{
+		"source":    "vault",
+		"role":      c.role,
+		"username":  c.username,
+		"rotations": c.rotations,
+	}
+	if !c.rotatedAt.IsZero() {
+		status["rotated_at"] = c.rotatedAt
+	}
+	return status
+}
+
+// usesVaultCredentials reports whether an enabled Postgres connection
+// takes its credentials from Vault.
+func usesVaultCredentials(cfg *config.Config) bool {
+	if cfg.PostgresMultiConfig.Enabled {
+		for _, conn := range cfg.PostgresMultiConfig.Connections {
+			if conn.Enabled && conn.VaultRole != "" {
+				return true
+			}
+		}
+		return false
+	}
+	return cfg.Postgres.VaultRole != ""
+}
+
+// awaitVault returns the vault component. The components connect
+// concurrently at boot, so it waits up to timeout for vault to connect.
+func awaitVault(timeout time.Duration) (*VaultManager, error) {
+	registry := GetGlobalRegistry()
+	deadline := time.Now().Add(timeout)
+	for {
+		if component, ok := registry.Get("vault"); ok {
+			if vault, ok := component.(*VaultManager); ok {
+				return vault, nil
+			}
+		}
+		if result, ok := registry.ConnectResults()["vault"]; ok && result.Err != nil {
+			return nil, fmt.Errorf("vault is not connected: %w", result.Err)
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("vault component is not available")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,277 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQManager publishes to a single exchange with publisher confirms.
+// Topics are used as routing keys.
+type RabbitMQManager struct {
+	Conn     *amqp.Connection
+	Exchange string
+	Queue    string
+	Prefetch int
+	logger   *logger.Logger
+	Pool     *WorkerPool // Async worker pool
+
+	publishCh *amqp.Channel
+	publishMu sync.Mutex // amqp channels are not safe for concurrent publishing
+}
+
+// Name returns the display name of the component
+func (r *RabbitMQManager) Name() string {
+	return "RabbitMQ"
+}
+
+func NewRabbitMQManager(cfg config.RabbitMQConfig, logger *logger.Logger) (*RabbitMQManager, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	conn, err := amqp.DialConfig(cfg.URL, amqp.Config{
+		Heartbeat: 10 * time.Second,
+		Dial:      amqp.DefaultDial(5 * time.Second),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to rabbitmq: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open rabbitmq channel: %w", err)
+	}
+
+	exchangeType := cfg.ExchangeType
+	if exchangeType == "" {
+		exchangeType = amqp.ExchangeTopic
+	}
+	if err := ch.ExchangeDeclare(cfg.Exchange, exchangeType, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare exchange %s: %w", cfg.Exchange, err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	// Initialize worker pool for async operations
+	pool := NewComponentPool("rabbitmq", "rabbitmq", 5)
+	pool.Start()
+
+	return &RabbitMQManager{
+		Conn:      conn,
+		Exchange:  cfg.Exchange,
+		Queue:     cfg.Queue,
+		Prefetch:  cfg.Prefetch,
+		logger:    logger,
+		Pool:      pool,
+		publishCh: ch,
+	}, nil
+}
+
+func (r *RabbitMQManager) GetStatus() map[string]interface{} {
+	stats := make(map[string]interface{})
+	if r == nil || r.Conn == nil {
+		stats["connected"] = false
+		return stats
+	}
+
+	stats["connected"] = !r.Conn.IsClosed()
+	stats["exchange"] = r.Exchange
+	stats["queue"] = r.Queue
+	stats["prefetch"] = r.Prefetch
+	if r.Pool != nil {
+		stats["pool_active"] = true
+		stats["pool"] = r.Pool.GetStatus()
+	}
+	return stats
+}
+
+// Publish publishes raw data with the given routing key.
+func (r *RabbitMQManager) Publish(ctx context.Context, routingKey string, data []byte) error {
+	return r.PublishMessage(ctx, messaging.Message{Topic: routingKey, Value: data})
+}
+
+// PublishAsync asynchronously publishes raw data with the given routing key.
+func (r *RabbitMQManager) PublishAsync(ctx context.Context, routingKey string, data []byte) *AsyncResult[struct{}] {
+	return ExecuteAsync(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.Publish(ctx, routingKey, data)
+	})
+}
+
+var _ messaging.Broker = (*RabbitMQManager)(nil)
+
+// BrokerType implements messaging.Broker.
+func (r *RabbitMQManager) BrokerType() string {
+	return messaging.BrokerRabbitMQ
+}
+
+// PublishMessage implements messaging.Broker. It waits for the publisher
+// confirm, so a nil error means the broker has taken ownership.
+func (r *RabbitMQManager) PublishMessage(ctx context.Context, msg messaging.Message) error {
+	if r == nil || r.Conn == nil || r.Conn.IsClosed() {
+		return messaging.ErrNotConnected
+	}
+
+	msg = messaging.WithCorrelation(ctx, msg)
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	ts := msg.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	pub := amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/octet-stream",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    ts,
+		MessageId:    string(msg.Key),
+		Body:         msg.Value,
+	}
+
+	r.publishMu.Lock()
+	confirm, err := r.publishCh.PublishWithDeferredConfirmWithContext(ctx, r.Exchange, msg.Topic, false, false, pub)
+	r.publishMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("rabbitmq: message to %s was nacked by broker", msg.Topic)
+	}
+	return nil
+}
+
+// Subscribe implements messaging.Broker. Group names a durable queue bound
+// to the topic; without a group an exclusive, auto-deleted queue is used.
+func (r *RabbitMQManager) Subscribe(ctx context.Context, topic string, opts messaging.SubscribeOptions, handler messaging.Handler) (messaging.Subscription, error) {
+	if r == nil || r.Conn == nil || r.Conn.IsClosed() {
+		return nil, messaging.ErrNotConnected
+	}
+
+	ch, err := r.Conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rabbitmq channel: %w", err)
+	}
+	if r.Prefetch > 0 {
+		if err := ch.Qos(r.Prefetch, 0, false); err != nil {
+			ch.Close()
+			return nil, err
+		}
+	}
+
+	queueName := opts.Group
+	if queueName == "" {
+		queueName = r.Queue
+	}
+	durable := queueName != ""
+	q, err := ch.QueueDeclare(queueName, durable, !durable, !durable, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to declare queue: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, topic, r.Exchange, false, nil); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	deliveries, err := ch.ConsumeWithContext(subCtx, q.Name, "", false, false, false, false, nil)
+	if err != nil {
+		cancel()
+		ch.Close()
+		return nil, err
+	}
+
+	sub := &rabbitSubscription{topic: topic, ch: ch, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(sub.done)
+		for del := range deliveries {
+			del := del
+			headers := make(map[string]string, len(del.Headers))
+			for k, v := range del.Headers {
+				headers[k] = fmt.Sprint(v)
+			}
+			d := messaging.NewDelivery(messaging.Message{
+				Topic:     del.RoutingKey,
+				Key:       []byte(del.MessageId),
+				Value:     del.Body,
+				Headers:   headers,
+				Timestamp: del.Timestamp,
+			}, func() error {
+				return del.Ack(false)
+			}, func(requeue bool) error {
+				return del.Nack(false, requeue)
+			})
+			if err := messaging.Dispatch(subCtx, d, opts, handler); err != nil {
+				r.logger.Error("Error handling message", err, "routing_key", del.RoutingKey)
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+type rabbitSubscription struct {
+	topic  string
+	ch     *amqp.Channel
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (s *rabbitSubscription) Topic() string { return s.topic }
+
+func (s *rabbitSubscription) Unsubscribe() error {
+	s.cancel()
+	err := s.ch.Close()
+	<-s.done
+	return err
+}
+
+// SubmitAsyncJob submits an async job to the worker pool.
+func (r *RabbitMQManager) SubmitAsyncJob(job func()) {
+	if r.Pool != nil {
+		r.Pool.Submit(job)
+	} else {
+		// Fallback to direct execution if pool not available
+		go job()
+	}
+}
+
+// Close closes the channel, the connection and the worker pool.
+func (r *RabbitMQManager) Close() error {
+	if r.Pool != nil {
+		r.Pool.Close()
+	}
+	if r.publishCh != nil {
+		_ = r.publishCh.Close()
+	}
+	if r.Conn != nil && !r.Conn.IsClosed() {
+		return r.Conn.Close()
+	}
+	return nil
+}
+
+func init() {
+	RegisterComponent("rabbitmq", func(cfg *config.Config, log *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.RabbitMQ.Enabled {
+			return nil, nil
+		}
+		return NewRabbitMQManager(cfg.RabbitMQ, log)
+	})
+}
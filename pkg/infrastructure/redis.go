@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"stackyrd/config"
+	"stackyrd/pkg/dns"
 	"stackyrd/pkg/logger"
 	"sync"
 	"time"
@@ -39,8 +40,12 @@ func NewRedisClient(cfg config.RedisConfig) (*RedisManager, error) {
 		PoolSize:     25,
 		MinIdleConns: 5,
 		PoolTimeout:  4 * time.Second,
+		Dialer:       dns.DialContext,
 	})
 
+	client.AddHook(tenantCacheHook{})
+	client.AddHook(tracingHook{})
+
 	// Test connection
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
@@ -56,9 +61,11 @@ func NewRedisClient(cfg config.RedisConfig) (*RedisManager, error) {
 // startPool lazily initialises the worker pool on first async use.
 func (r *RedisManager) startPool() {
 	r.once.Do(func() {
-		pool := NewWorkerPool(10)
+		pool := NewComponentPool("redis", "redis", 10)
 		pool.Start()
+		r.statusMu.Lock() // GetStatus reads the pool from other goroutines
 		r.Pool = pool
+		r.statusMu.Unlock()
 	})
 }
 
@@ -116,6 +123,9 @@ func (r *RedisManager) GetStatus() map[string]interface{} {
 	stats["pool_idle_conns"] = pool.IdleConns
 
 	r.statusMu.Lock()
+	if r.Pool != nil {
+		stats["pool"] = r.Pool.GetStatus()
+	}
 	r.statusCache = stats
 	r.statusExpiry = time.Now().Add(2 * time.Second)
 	r.statusMu.Unlock()
@@ -128,7 +138,8 @@ func (r *RedisManager) GetInfo(ctx context.Context) (string, error) {
 	return r.Client.Info(ctx).Result()
 }
 
-// ScanKeys returns a list of keys matching the pattern. Limit to 100 for safety.
+// ScanKeys returns every key matching the pattern, scanning 100 keys per
+// call. Use ScanKeysPage to browse large keyspaces.
 func (r *RedisManager) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
 	var keys []string
 	iter := r.Client.Scan(ctx, 0, pattern, 100).Iterator()
@@ -141,8 +152,8 @@ func (r *RedisManager) ScanKeys(ctx context.Context, pattern string) ([]string,
 	return keys, nil
 }
 
-// GetValue returns the value of a specific key for monitoring.
-// It assumes string for simplicity, but could be extended.
+// GetValue returns the value of a string key; KeyValue reads keys of any
+// type.
 func (r *RedisManager) GetValue(ctx context.Context, key string) (string, error) {
 	val, err := r.Client.Get(ctx, key).Result()
 	if err != nil {
@@ -0,0 +1,292 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Defaults and limits of a keyspace analysis.
+const (
+	defaultAnalyzeKeys    = 100_000
+	maxAnalyzeKeys        = 1_000_000
+	defaultPrefixSamples  = 20
+	maxPrefixSamples      = 500
+	maxKeyspacePrefixes   = 100
+	keyspaceOtherPrefixes = "(other)"
+)
+
+// KeyspaceOptions select the keys of a keyspace analysis and how they are
+// grouped.
+type KeyspaceOptions struct {
+	Pattern   string // "*" when empty
+	Separator string // ":" when empty
+	Depth     int    // prefix segments a group shares, 1 when zero
+	MaxKeys   int    // keys scanned at most, 100000 by default
+	Samples   int    // keys per prefix whose type and memory are read, 20 by default
+}
+
+// PrefixStats aggregates the keys sharing a prefix. Types and memory come
+// from a sample of the keys; EstimatedBytes extrapolates the sample to all
+// keys of the prefix.
+type PrefixStats struct {
+	Prefix         string           `json:"prefix"`
+	Keys           int64            `json:"keys"`
+	SampledKeys    int              `json:"sampled_keys"`
+	SampledBytes   int64            `json:"sampled_bytes"`
+	EstimatedBytes int64            `json:"estimated_bytes"`
+	Types          map[string]int64 `json:"types"` // among the sampled keys
+}
+
+// KeyspaceSummary is the result of a keyspace analysis, prefixes by key
+// count descending. Complete is false when MaxKeys stopped the scan, so the
+// counts cover only part of the keyspace.
+type KeyspaceSummary struct {
+	Scanned        int64         `json:"scanned"`
+	Complete       bool          `json:"complete"`
+	EstimatedBytes int64         `json:"estimated_bytes"`
+	Prefixes       []PrefixStats `json:"prefixes"`
+	Duration       float64       `json:"duration_ms"`
+}
+
+// SlowLogEntry is a command the server logged as slow.
+type SlowLogEntry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	DurationUS int64     `json:"duration_us"`
+	Command    []string  `json:"command"`
+	ClientAddr string    `json:"client_addr,omitempty"`
+	ClientName string    `json:"client_name,omitempty"`
+}
+
+// prefixGroup collects the keys of a prefix while scanning.
+type prefixGroup struct {
+	keys    int64
+	samples []string
+}
+
+// AnalyzeKeyspace counts the keys matching opts.Pattern by prefix and
+// estimates the memory of each prefix from MEMORY USAGE of a sample of its
+// keys. Like SCAN it does not block the server, and keys changed meanwhile
+// may be missed or counted twice. Prefixes beyond the 100 largest are
+// summed up as "(other)", which is not sampled.
+func (r *RedisManager) AnalyzeKeyspace(ctx context.Context, opts KeyspaceOptions) (*KeyspaceSummary, error) {
+	start := time.Now()
+	if opts.Pattern == "" {
+		opts.Pattern = "*"
+	}
+	if opts.Separator == "" {
+		opts.Separator = ":"
+	}
+	opts.Depth = max(opts.Depth, 1)
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = defaultAnalyzeKeys
+	}
+	opts.MaxKeys = min(opts.MaxKeys, maxAnalyzeKeys)
+	if opts.Samples <= 0 {
+		opts.Samples = defaultPrefixSamples
+	}
+	opts.Samples = min(opts.Samples, maxPrefixSamples)
+
+	groups := make(map[string]*prefixGroup)
+	summary := &KeyspaceSummary{}
+	var cursor uint64
+	for {
+		count := min(1000, int64(opts.MaxKeys)-summary.Scanned)
+		keys, next, err := r.Client.Scan(ctx, cursor, opts.Pattern, count).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			prefix := keyPrefix(key, opts.Separator, opts.Depth)
+			group, ok := groups[prefix]
+			if !ok {
+				group = &prefixGroup{}
+				groups[prefix] = group
+			}
+			group.keys++
+			if len(group.samples) < opts.Samples {
+				group.samples = append(group.samples, key)
+			}
+		}
+		summary.Scanned += int64(len(keys))
+		if cursor = next; cursor == 0 {
+			summary.Complete = true
+			break
+		}
+		if summary.Scanned >= int64(opts.MaxKeys) {
+			break
+		}
+	}
+
+	prefixes := make([]PrefixStats, 0, len(groups))
+	for prefix, group := range groups {
+		prefixes = append(prefixes, PrefixStats{Prefix: prefix, Keys: group.keys, Types: make(map[string]int64)})
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i], prefixes[j]
+		if a.Keys != b.Keys {
+			return a.Keys > b.Keys
+		}
+		return a.Prefix < b.Prefix
+	})
+
+	// Only the largest prefixes are sampled; the rest are estimated from
+	// their average key size
+	var other *PrefixStats
+	if len(prefixes) > maxKeyspacePrefixes {
+		other = &PrefixStats{Prefix: keyspaceOtherPrefixes, Types: make(map[string]int64)}
+		for _, p := range prefixes[maxKeyspacePrefixes:] {
+			other.Keys += p.Keys
+		}
+		prefixes = prefixes[:maxKeyspacePrefixes]
+	}
+	if err := r.samplePrefixes(ctx, prefixes, groups); err != nil {
+		return nil, err
+	}
+	var sampledKeys, sampledBytes int64
+	for _, p := range prefixes {
+		summary.EstimatedBytes += p.EstimatedBytes
+		sampledKeys += int64(p.SampledKeys)
+		sampledBytes += p.SampledBytes
+	}
+	if other != nil {
+		if sampledKeys > 0 {
+			other.EstimatedBytes = sampledBytes * other.Keys / sampledKeys
+		}
+		summary.EstimatedBytes += other.EstimatedBytes
+		prefixes = append(prefixes, *other)
+	}
+	summary.Prefixes = prefixes
+	summary.Duration = float64(time.Since(start).Microseconds()) / 1000
+	return summary, nil
+}
+
+// samplePrefixes reads type and memory usage of the sampled keys of
+// prefixes, in pipelines of up to 1000 keys.
+func (r *RedisManager) samplePrefixes(ctx context.Context, prefixes []PrefixStats, groups map[string]*prefixGroup) error {
+	var keys []string
+	var owners []int
+	for i, p := range prefixes {
+		for _, key := range groups[p.Prefix].samples {
+			keys = append(keys, key)
+			owners = append(owners, i)
+		}
+	}
+	for start := 0; start < len(keys); start += maxKeyPageSize {
+		end := min(start+maxKeyPageSize, len(keys))
+		infos, err := r.describeKeys(ctx, keys[start:end])
+		if err != nil {
+			return err
+		}
+		for i, info := range infos {
+			// Keys that expired since the scan are no sample
+			if info.Type == "" || info.Type == "none" {
+				continue
+			}
+			p := &prefixes[owners[start+i]]
+			p.SampledKeys++
+			p.SampledBytes += info.MemoryBytes
+			p.Types[info.Type]++
+		}
+	}
+	for i := range prefixes {
+		if p := &prefixes[i]; p.SampledKeys > 0 {
+			p.EstimatedBytes = p.SampledBytes * p.Keys / int64(p.SampledKeys)
+		}
+	}
+	return nil
+}
+
+// keyPrefix returns the first depth segments of key with their separator,
+// or the whole key when it has no more segments.
+func keyPrefix(key, separator string, depth int) string {
+	end := 0
+	for i := 0; i < depth; i++ {
+		n := strings.Index(key[end:], separator)
+		if n < 0 {
+			return key
+		}
+		end += n + len(separator)
+	}
+	return key[:end]
+}
+
+// SlowLog returns the latest count entries of the slow log, newest first.
+func (r *RedisManager) SlowLog(ctx context.Context, count int64) ([]SlowLogEntry, error) {
+	logs, err := r.Client.SlowLogGet(ctx, count).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]SlowLogEntry, len(logs))
+	for i, log := range logs {
+		entries[i] = SlowLogEntry{
+			ID:         log.ID,
+			Time:       log.Time,
+			DurationUS: log.Duration.Microseconds(),
+			Command:    log.Args,
+			ClientAddr: log.ClientAddr,
+			ClientName: log.ClientName,
+		}
+	}
+	return entries, nil
+}
+
+// InfoSections returns INFO parsed by ParseRedisInfo, of the given sections
+// or the default ones.
+func (r *RedisManager) InfoSections(ctx context.Context, sections ...string) (map[string]map[string]interface{}, error) {
+	raw, err := r.Client.Info(ctx, sections...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return ParseRedisInfo(raw), nil
+}
+
+// ParseRedisInfo parses the reply of INFO into its sections, keyed by the
+// lower-case section name. Numbers become int64 or float64; compound values
+// such as "keys=5,expires=0" (the keyspace section) become maps.
+func ParseRedisInfo(raw string) map[string]map[string]interface{} {
+	sections := make(map[string]map[string]interface{})
+	var current map[string]interface{}
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(line, "# "); ok {
+			current = make(map[string]interface{})
+			sections[strings.ToLower(name)] = current
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || current == nil {
+			continue
+		}
+		current[key] = infoValue(value)
+	}
+	return sections
+}
+
+func infoValue(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if !strings.Contains(value, "=") {
+		return value
+	}
+	fields := make(map[string]interface{})
+	for _, field := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			// Not a compound value after all, e.g. a command line
+			return value
+		}
+		fields[k] = infoValue(v)
+	}
+	return fields
+}
@@ -0,0 +1,121 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidCursor is returned for a cursor token ScanKeysPage did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	defaultKeyPageSize = 100
+	maxKeyPageSize     = 1000
+	// maxScanCalls bounds the SCAN calls per page so a sparse pattern over
+	// a huge keyspace returns a short page with a cursor instead of
+	// blocking until the page is full.
+	maxScanCalls = 20
+)
+
+// KeyInfo describes a key for the key browser.
+type KeyInfo struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	TTL         int64  `json:"ttl"`          // seconds; -1 without expiry, -2 when gone
+	MemoryBytes int64  `json:"memory_bytes"` // MEMORY USAGE; 0 when unavailable
+}
+
+// KeyPage is one page of a keyspace scan. Cursor is passed back to get the
+// next page; it is empty once the scan is complete.
+type KeyPage struct {
+	Keys   []KeyInfo `json:"keys"`
+	Cursor string    `json:"cursor"`
+	Done   bool      `json:"done"`
+}
+
+// ScanKeysPage returns about count keys matching pattern starting at cursor
+// ("" or "0" for the first page), with their type, TTL and memory usage.
+// Pages hold only what one request needs, so browsing scales to keyspaces
+// of any size; as with SCAN, keys changed during the scan may be missed or
+// returned twice.
+func (r *RedisManager) ScanKeysPage(ctx context.Context, pattern, cursor string, count int) (*KeyPage, error) {
+	var position uint64
+	if cursor != "" {
+		var err error
+		if position, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+		}
+	}
+	if count <= 0 {
+		count = defaultKeyPageSize
+	}
+	count = min(count, maxKeyPageSize)
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	var keys []string
+	for calls := 0; calls < maxScanCalls && len(keys) < count; calls++ {
+		batch, next, err := r.Client.Scan(ctx, position, pattern, int64(count-len(keys))).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		position = next
+		if position == 0 {
+			break
+		}
+	}
+
+	infos, err := r.describeKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	page := &KeyPage{Keys: infos, Done: position == 0}
+	if !page.Done {
+		page.Cursor = strconv.FormatUint(position, 10)
+	}
+	return page, nil
+}
+
+// describeKeys fetches type, TTL and memory usage of keys in one pipeline.
+func (r *RedisManager) describeKeys(ctx context.Context, keys []string) ([]KeyInfo, error) {
+	infos := make([]KeyInfo, len(keys))
+	if len(keys) == 0 {
+		return infos, nil
+	}
+	types := make([]*redis.StatusCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	memory := make([]*redis.IntCmd, len(keys))
+	pipe := r.Client.Pipeline()
+	for i, key := range keys {
+		types[i] = pipe.Type(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+		memory[i] = pipe.MemoryUsage(ctx, key)
+	}
+	// Per-key errors (MEMORY USAGE may be disabled, keys may expire
+	// meanwhile) leave zero values; only connection errors fail the page
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		var replyErr redis.Error
+		if !errors.As(err, &replyErr) {
+			return nil, err
+		}
+	}
+	for i, key := range keys {
+		infos[i] = KeyInfo{Key: key, Type: types[i].Val(), TTL: ttlSeconds(ttls[i].Val()), MemoryBytes: memory[i].Val()}
+	}
+	return infos, nil
+}
+
+// ttlSeconds converts a PTTL reply, which keeps -1 and -2 as nanoseconds.
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl < 0 {
+		return int64(ttl)
+	}
+	return int64(ttl.Seconds())
+}
@@ -0,0 +1,360 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors of the key editor.
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyType     = errors.New("key has another type")
+	ErrKeyChanged  = errors.New("key changed")
+	ErrInvalidEdit = errors.New("invalid edit")
+)
+
+const (
+	defaultValueLimit = 100
+	maxValueLimit     = 1000
+	// maxStringBytes bounds how much of a string value is read.
+	maxStringBytes = 1 << 20
+)
+
+// KeyValue is a key with its value, read according to its type: a string
+// for strings, a field map for hashes, a slice of elements for lists and
+// sets, of ZMember for sorted sets and of StreamEntry for streams. Length
+// is the byte length of strings and the element count otherwise; Truncated
+// tells that Value holds only part of it.
+type KeyValue struct {
+	Key       string      `json:"key"`
+	Type      string      `json:"type"`
+	TTL       int64       `json:"ttl"` // seconds; -1 without expiry
+	Length    int64       `json:"length"`
+	Value     interface{} `json:"value"`
+	Truncated bool        `json:"truncated"`
+}
+
+// ZMember is an element of a sorted set.
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// StreamEntry is an entry of a stream.
+type StreamEntry struct {
+	ID     string                 `json:"id"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// KeyEdit changes one part of a value. Op is "set", "add" or "remove":
+//
+//	string  set (Value replaces the value, keeping the TTL)
+//	hash    set or remove Field (set writes Value)
+//	list    set the element at Index to Value, add Value at the tail,
+//	        remove every element equal to Value
+//	set     add or remove Member
+//	zset    add Member with Score (updating its score), remove Member
+//
+// Streams are append-only logs and are not edited.
+type KeyEdit struct {
+	Op     string   `json:"op"`
+	Field  string   `json:"field,omitempty"`
+	Index  *int64   `json:"index,omitempty"`
+	Member string   `json:"member,omitempty"`
+	Score  *float64 `json:"score,omitempty"`
+	Value  string   `json:"value,omitempty"`
+}
+
+// KeyValue reads key with at most limit elements (default 100, at most
+// 1000) of a collection, or the first megabyte of a string.
+func (r *RedisManager) KeyValue(ctx context.Context, key string, limit int) (*KeyValue, error) {
+	if limit <= 0 {
+		limit = defaultValueLimit
+	}
+	limit = min(limit, maxValueLimit)
+
+	pipe := r.Client.Pipeline()
+	typeCmd := pipe.Type(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	kv := &KeyValue{Key: key, Type: typeCmd.Val(), TTL: ttlSeconds(ttlCmd.Val())}
+	if kv.Type == "none" {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+
+	var err error
+	switch kv.Type {
+	case "string":
+		err = r.readString(ctx, kv)
+	case "hash":
+		err = r.readHash(ctx, kv, limit)
+	case "list":
+		err = r.readList(ctx, kv, limit)
+	case "set":
+		err = r.readSet(ctx, kv, limit)
+	case "zset":
+		err = r.readZSet(ctx, kv, limit)
+	case "stream":
+		err = r.readStream(ctx, kv, limit)
+	default:
+		// Module types have no generic read command
+		kv.Truncated = true
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+func (r *RedisManager) readString(ctx context.Context, kv *KeyValue) error {
+	pipe := r.Client.Pipeline()
+	length := pipe.StrLen(ctx, kv.Key)
+	value := pipe.GetRange(ctx, kv.Key, 0, maxStringBytes-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	kv.Length, kv.Value = length.Val(), value.Val()
+	kv.Truncated = kv.Length > maxStringBytes
+	return nil
+}
+
+func (r *RedisManager) readHash(ctx context.Context, kv *KeyValue, limit int) error {
+	length, err := r.Client.HLen(ctx, kv.Key).Result()
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	err = scanAll(limit, func(cursor uint64, count int64) ([]string, uint64, error) {
+		return r.Client.HScan(ctx, kv.Key, cursor, "*", count).Result()
+	}, func(pair []string) bool {
+		fields[pair[0]] = pair[1]
+		return len(fields) < limit
+	}, 2)
+	if err != nil {
+		return err
+	}
+	kv.Length, kv.Value = length, fields
+	kv.Truncated = int64(len(fields)) < length
+	return nil
+}
+
+func (r *RedisManager) readList(ctx context.Context, kv *KeyValue, limit int) error {
+	pipe := r.Client.Pipeline()
+	length := pipe.LLen(ctx, kv.Key)
+	elements := pipe.LRange(ctx, kv.Key, 0, int64(limit-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	kv.Length, kv.Value = length.Val(), elements.Val()
+	kv.Truncated = int64(len(elements.Val())) < kv.Length
+	return nil
+}
+
+func (r *RedisManager) readSet(ctx context.Context, kv *KeyValue, limit int) error {
+	length, err := r.Client.SCard(ctx, kv.Key).Result()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	err = scanAll(limit, func(cursor uint64, count int64) ([]string, uint64, error) {
+		return r.Client.SScan(ctx, kv.Key, cursor, "*", count).Result()
+	}, func(member []string) bool {
+		seen[member[0]] = true
+		return len(seen) < limit
+	}, 1)
+	if err != nil {
+		return err
+	}
+	members := make([]string, 0, len(seen))
+	for member := range seen {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	kv.Length, kv.Value = length, members
+	kv.Truncated = int64(len(members)) < length
+	return nil
+}
+
+func (r *RedisManager) readZSet(ctx context.Context, kv *KeyValue, limit int) error {
+	pipe := r.Client.Pipeline()
+	length := pipe.ZCard(ctx, kv.Key)
+	elements := pipe.ZRangeWithScores(ctx, kv.Key, 0, int64(limit-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	members := make([]ZMember, len(elements.Val()))
+	for i, z := range elements.Val() {
+		members[i] = ZMember{Member: fmt.Sprint(z.Member), Score: z.Score}
+	}
+	kv.Length, kv.Value = length.Val(), members
+	kv.Truncated = int64(len(members)) < kv.Length
+	return nil
+}
+
+func (r *RedisManager) readStream(ctx context.Context, kv *KeyValue, limit int) error {
+	pipe := r.Client.Pipeline()
+	length := pipe.XLen(ctx, kv.Key)
+	messages := pipe.XRangeN(ctx, kv.Key, "-", "+", int64(limit))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	entries := make([]StreamEntry, len(messages.Val()))
+	for i, msg := range messages.Val() {
+		entries[i] = StreamEntry{ID: msg.ID, Values: msg.Values}
+	}
+	kv.Length, kv.Value = length.Val(), entries
+	kv.Truncated = int64(len(entries)) < kv.Length
+	return nil
+}
+
+// scanAll runs a HSCAN or SSCAN until visit returns false or the scan is
+// complete, calling visit with groups of size elements of each reply.
+func scanAll(limit int, scan func(cursor uint64, count int64) ([]string, uint64, error), visit func([]string) bool, size int) error {
+	var cursor uint64
+	for {
+		batch, next, err := scan(cursor, int64(limit))
+		if err != nil {
+			return err
+		}
+		for i := 0; i+size <= len(batch); i += size {
+			if !visit(batch[i : i+size]) {
+				return nil
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// DeleteKey deletes key if it still has keyType (any type when empty).
+func (r *RedisManager) DeleteKey(ctx context.Context, key, keyType string) error {
+	return r.watchKey(ctx, key, keyType, func(pipe redis.Pipeliner, _ string) error {
+		pipe.Del(ctx, key)
+		return nil
+	})
+}
+
+// SetKeyTTL lets key expire after ttl, or never when ttl is zero.
+func (r *RedisManager) SetKeyTTL(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("%w: ttl must not be negative", ErrInvalidEdit)
+	}
+	return r.watchKey(ctx, key, "", func(pipe redis.Pipeliner, _ string) error {
+		if ttl == 0 {
+			pipe.Persist(ctx, key)
+		} else {
+			pipe.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+}
+
+// EditKey applies edit to key if it still has keyType (any type when
+// empty). Edits keep the key's TTL.
+func (r *RedisManager) EditKey(ctx context.Context, key, keyType string, edit KeyEdit) error {
+	return r.watchKey(ctx, key, keyType, func(pipe redis.Pipeliner, actual string) error {
+		return queueEdit(ctx, pipe, key, actual, edit)
+	})
+}
+
+// watchKey queues the commands of apply in a transaction that fails if
+// key changes in between, after checking that key exists with keyType.
+func (r *RedisManager) watchKey(ctx context.Context, key, keyType string, apply func(pipe redis.Pipeliner, actual string) error) error {
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		actual, err := tx.Type(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if actual == "none" {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+		}
+		if keyType != "" && actual != keyType {
+			return fmt.Errorf("%w: %s is a %s, not a %s", ErrKeyType, key, actual, keyType)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return apply(pipe, actual)
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("%w: %s changed during the update", ErrKeyChanged, key)
+	}
+	return err
+}
+
+func queueEdit(ctx context.Context, pipe redis.Pipeliner, key, keyType string, edit KeyEdit) error {
+	invalid := func() error {
+		return fmt.Errorf("%w: %q is not an edit of a %s", ErrInvalidEdit, edit.Op, keyType)
+	}
+	switch keyType {
+	case "string":
+		if edit.Op != "set" {
+			return invalid()
+		}
+		pipe.SetArgs(ctx, key, edit.Value, redis.SetArgs{KeepTTL: true})
+	case "hash":
+		if edit.Field == "" {
+			return fmt.Errorf("%w: field is required", ErrInvalidEdit)
+		}
+		switch edit.Op {
+		case "set":
+			pipe.HSet(ctx, key, edit.Field, edit.Value)
+		case "remove":
+			pipe.HDel(ctx, key, edit.Field)
+		default:
+			return invalid()
+		}
+	case "list":
+		switch edit.Op {
+		case "set":
+			if edit.Index == nil {
+				return fmt.Errorf("%w: index is required", ErrInvalidEdit)
+			}
+			pipe.LSet(ctx, key, *edit.Index, edit.Value)
+		case "add":
+			pipe.RPush(ctx, key, edit.Value)
+		case "remove":
+			pipe.LRem(ctx, key, 0, edit.Value)
+		default:
+			return invalid()
+		}
+	case "set":
+		if edit.Member == "" {
+			return fmt.Errorf("%w: member is required", ErrInvalidEdit)
+		}
+		switch edit.Op {
+		case "add":
+			pipe.SAdd(ctx, key, edit.Member)
+		case "remove":
+			pipe.SRem(ctx, key, edit.Member)
+		default:
+			return invalid()
+		}
+	case "zset":
+		if edit.Member == "" {
+			return fmt.Errorf("%w: member is required", ErrInvalidEdit)
+		}
+		switch edit.Op {
+		case "add", "set":
+			if edit.Score == nil {
+				return fmt.Errorf("%w: score is required", ErrInvalidEdit)
+			}
+			pipe.ZAdd(ctx, key, redis.Z{Score: *edit.Score, Member: edit.Member})
+		case "remove":
+			pipe.ZRem(ctx, key, edit.Member)
+		default:
+			return invalid()
+		}
+	default:
+		return fmt.Errorf("%w: %s values cannot be edited", ErrInvalidEdit, keyType)
+	}
+	return nil
+}
@@ -9,18 +9,24 @@ import (
 )
 
 // ComponentRegistry manages all infrastructure components.
-// After boot the component and factory maps are write-once, so a
-// regular map protected by sync.RWMutex is cheaper than sync.Map for
-// the hot read path (no interface boxing/type assertions on every access).
+// After boot the component and factory maps only change on a soft restart
+// (Reconnect), so a regular map protected by sync.RWMutex is cheaper than
+// sync.Map for the hot read path (no interface boxing/type assertions on
+// every access).
 type ComponentRegistry struct {
-	components     map[string]InfrastructureComponent // write-once after boot
+	components     map[string]InfrastructureComponent // written at boot and by Reconnect
 	factories      map[string]ComponentFactory        // write-once at init
+	connectResults map[string]ConnectResult           // outcome of Initialize per enabled component
 	componentsMu   sync.RWMutex                      // guards components map
 	factoriesMu    sync.Mutex                       // guards factories map (init phase only)
 	cachedSnapshot map[string]InfrastructureComponent // TTL-cached GetAll copy; nil = stale
 	cacheExpiry    time.Time
 	cacheMu        sync.Mutex
 	cacheTTL       time.Duration
+	// config, logger and policy of Initialize, reused by Reconnect
+	cfg    *config.Config
+	logger *logger.Logger
+	policy RetryPolicy
 }
 
 // Global registry instance
@@ -55,28 +61,98 @@ func (r *ComponentRegistry) Register(name string, factory ComponentFactory) {
 }
 
 // Initialize creates and stores every registered component.  Called once at
-// boot; after this all component writes are complete.
+// boot; after this all component writes are complete.  Components connect
+// concurrently, each retried according to infrastructure.connect_attempts.
 func (r *ComponentRegistry) Initialize(cfg *config.Config, logger *logger.Logger) error {
 	r.factoriesMu.Lock()
 	defer r.factoriesMu.Unlock()
 
+	ConfigurePools(cfg.Infrastructure.Pools)
+	policy := RetryPolicy{
+		Attempts: cfg.Infrastructure.ConnectAttempts,
+		Backoff:  time.Duration(cfg.Infrastructure.ConnectBackoff) * time.Second,
+	}
+
+	r.componentsMu.Lock()
 	if r.components == nil {
 		r.components = make(map[string]InfrastructureComponent)
 	}
+	r.connectResults = make(map[string]ConnectResult)
+	r.cfg, r.logger, r.policy = cfg, logger, policy
+	r.componentsMu.Unlock()
+
+	var wg sync.WaitGroup
 	for name, factory := range r.factories {
-		component, err := factory(cfg, logger)
-		if err != nil {
-			logger.Error("Failed to initialize "+name, err)
-			continue
-		}
-		if component != nil {
-			r.components[name] = component
-			logger.Info(name + " initialized")
-		}
+		wg.Add(1)
+		go func(name string, factory ComponentFactory) {
+			defer wg.Done()
+			component, result := Supervise(name, policy, func() (InfrastructureComponent, error) {
+				return factory(cfg, logger)
+			})
+			if result.Err != nil {
+				logger.Error("Failed to initialize "+name, result.Err, "attempts", result.Attempts)
+			}
+
+			r.componentsMu.Lock()
+			defer r.componentsMu.Unlock()
+			if result.Err != nil || component != nil {
+				r.connectResults[name] = result
+			}
+			if component != nil {
+				r.components[name] = component
+				logger.Info(name+" initialized", "attempts", result.Attempts, "duration", result.Duration.String())
+			}
+		}(name, factory)
 	}
+	wg.Wait()
 	return nil
 }
 
+// Reconnect creates the component name anew from its factory, retried like
+// at boot, and replaces the registered one, which the caller closes first.
+// A component the config now disables is removed and returned as nil.
+func (r *ComponentRegistry) Reconnect(name string) (InfrastructureComponent, ConnectResult, error) {
+	r.factoriesMu.Lock()
+	factory, ok := r.factories[name]
+	r.factoriesMu.Unlock()
+	r.componentsMu.RLock()
+	cfg, logger, policy := r.cfg, r.logger, r.policy
+	r.componentsMu.RUnlock()
+	if !ok || cfg == nil {
+		return nil, ConnectResult{}, fmt.Errorf("%w: %s", ErrUnknownComponent, name)
+	}
+
+	component, result := Supervise(name, policy, func() (InfrastructureComponent, error) {
+		return factory(cfg, logger)
+	})
+
+	r.componentsMu.Lock()
+	r.connectResults[name] = result
+	if component != nil {
+		r.components[name] = component
+	} else {
+		delete(r.components, name)
+	}
+	r.componentsMu.Unlock()
+
+	r.cacheMu.Lock()
+	r.cachedSnapshot = nil
+	r.cacheMu.Unlock()
+	return component, result, result.Err
+}
+
+// ConnectResults returns how connecting each enabled component went during
+// Initialize, including components that failed.
+func (r *ComponentRegistry) ConnectResults() map[string]ConnectResult {
+	r.componentsMu.RLock()
+	defer r.componentsMu.RUnlock()
+	results := make(map[string]ConnectResult, len(r.connectResults))
+	for k, v := range r.connectResults {
+		results[k] = v
+	}
+	return results
+}
+
 // Get retrieves a component by name — RLock read path, no interface boxing.
 func (r *ComponentRegistry) Get(name string) (InfrastructureComponent, bool) {
 	r.componentsMu.RLock()
@@ -106,7 +182,6 @@ func (r *ComponentRegistry) GetAll() map[string]InfrastructureComponent {
 		return r.cachedSnapshot
 	}
 
-	// Read lock-free: components map is write-once after boot (no concurrent writers).
 	r.componentsMu.RLock()
 	result := make(map[string]InfrastructureComponent, len(r.components))
 	for k, v := range r.components {
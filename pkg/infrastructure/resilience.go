@@ -0,0 +1,32 @@
+package infrastructure
+
+import (
+	"net/http"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/resilience"
+)
+
+// resilienceMaxWait bounds how long an outbound call waits for a slot of a
+// full bulkhead.
+const resilienceMaxWait = 30 * time.Second
+
+// resilientTransport wraps base (http.DefaultTransport when nil) in the
+// breakers, retries and bulkhead of cfg. The breakers are named
+// "<name>:<host>".
+func resilientTransport(name string, base http.RoundTripper, cfg config.ResilienceConfig) *resilience.Transport {
+	retry := resilience.DefaultRetryConfig()
+	retry.MaxAttempts = max(cfg.RetryAttempts, 1)
+	if cfg.RetryDelay > 0 {
+		retry.InitialDelay = time.Duration(cfg.RetryDelay) * time.Millisecond
+	}
+	return resilience.NewTransport(base, resilience.TransportConfig{
+		Name:          name,
+		MaxFailures:   cfg.MaxFailures,
+		ResetTimeout:  time.Duration(cfg.ResetTimeout) * time.Second,
+		Retry:         retry,
+		MaxConcurrent: cfg.MaxConcurrent,
+		MaxWait:       resilienceMaxWait,
+	})
+}
@@ -0,0 +1,223 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrUnknownComponent is returned for a component with no registered
+	// factory, or before the registry was initialized.
+	ErrUnknownComponent = errors.New("unknown infrastructure component")
+	// ErrRestartInProgress is returned while the component is restarting.
+	ErrRestartInProgress = errors.New("component restart already in progress")
+)
+
+// Steps of a soft restart besides the ConnectionEventType of each
+// connection attempt (retrying, connected, failed). Every restart ends
+// with RestartDone, carrying the error when it failed.
+const (
+	RestartClosing   = "closing"
+	RestartClosed    = "closed"
+	RestartReloading = "reloading"
+	RestartDone      = "done"
+)
+
+// Restart states.
+const (
+	RestartRunning   = "running"
+	RestartSucceeded = "succeeded"
+	RestartFailed    = "failed"
+)
+
+// restartSeq numbers the steps of all restarts, so a stream cursor stays
+// valid across restarts of the same component.
+var restartSeq atomic.Uint64
+
+// RestartStep is one step of a soft restart.
+type RestartStep struct {
+	Seq     uint64    `json:"seq"`
+	Step    string    `json:"step"`
+	Message string    `json:"message"`
+	Attempt int       `json:"attempt,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// RestartStatus is a snapshot of a soft restart.
+type RestartStatus struct {
+	Component  string        `json:"component"`
+	State      string        `json:"state"` // running, succeeded or failed
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Attempts   int           `json:"attempts"`
+	Error      string        `json:"error,omitempty"`
+	Steps      []RestartStep `json:"steps"`
+}
+
+// RestartProgress follows the soft restart of one component.
+type RestartProgress struct {
+	mu      sync.Mutex
+	status  RestartStatus
+	changed chan struct{} // closed and replaced on every step
+	done    chan struct{}
+}
+
+func newRestartProgress(name string) *RestartProgress {
+	return &RestartProgress{
+		status:  RestartStatus{Component: name, State: RestartRunning, StartedAt: time.Now(), Steps: []RestartStep{}},
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// add appends a step and wakes up the streams waiting for one.
+func (p *RestartProgress) add(step RestartStep) {
+	step.Seq = restartSeq.Add(1)
+	if step.Time.IsZero() {
+		step.Time = time.Now()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Steps = append(p.status.Steps, step)
+	if step.Attempt > p.status.Attempts {
+		p.status.Attempts = step.Attempt
+	}
+	if step.Step == RestartDone {
+		now := step.Time
+		p.status.FinishedAt = &now
+		p.status.State = RestartSucceeded
+		if step.Error != "" {
+			p.status.State = RestartFailed
+			p.status.Error = step.Error
+		}
+		close(p.done)
+	}
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Status returns a snapshot of the restart.
+func (p *RestartProgress) Status() RestartStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := p.status
+	status.Steps = append([]RestartStep(nil), p.status.Steps...)
+	return status
+}
+
+// Since returns the steps after seq, whether the restart has finished and
+// a channel closed when another step is added.
+func (p *RestartProgress) Since(seq uint64) (steps []RestartStep, finished bool, wait <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, step := range p.status.Steps {
+		if step.Seq > seq {
+			steps = append(steps, step)
+		}
+	}
+	return steps, p.status.FinishedAt != nil, p.changed
+}
+
+// Done is closed when the restart has finished.
+func (p *RestartProgress) Done() <-chan struct{} {
+	return p.done
+}
+
+// Restarter restarts single infrastructure components without restarting
+// the process: it closes the component, connects a new one from its
+// factory, retried like at boot, and hands it to OnRestarted.
+type Restarter struct {
+	registry *ComponentRegistry
+
+	// OnRestarted is called with the new component, or nil when it could
+	// not be re-created, so its users switch to it.
+	OnRestarted func(name string, component InfrastructureComponent, result ConnectResult)
+
+	mu       sync.Mutex
+	progress map[string]*RestartProgress // latest restart per component
+}
+
+// NewRestarter creates a restarter of the components of registry.
+func NewRestarter(registry *ComponentRegistry) *Restarter {
+	return &Restarter{registry: registry, progress: make(map[string]*RestartProgress)}
+}
+
+// Restart starts restarting the component name in the background and
+// returns its progress.
+func (r *Restarter) Restart(name string) (*RestartProgress, error) {
+	r.registry.factoriesMu.Lock()
+	_, known := r.registry.factories[name]
+	r.registry.factoriesMu.Unlock()
+	if !known {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownComponent, name)
+	}
+
+	r.mu.Lock()
+	if p, ok := r.progress[name]; ok && p.Status().State == RestartRunning {
+		r.mu.Unlock()
+		return p, ErrRestartInProgress
+	}
+	p := newRestartProgress(name)
+	r.progress[name] = p
+	r.mu.Unlock()
+
+	go r.run(name, p)
+	return p, nil
+}
+
+// Progress returns the latest restart of the component name.
+func (r *Restarter) Progress(name string) (*RestartProgress, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.progress[name]
+	return p, ok
+}
+
+func (r *Restarter) run(name string, p *RestartProgress) {
+	if old, ok := r.registry.Get(name); ok {
+		p.add(RestartStep{Step: RestartClosing, Message: "closing " + name})
+		step := RestartStep{Step: RestartClosed, Message: "closed"}
+		if err := old.Close(); err != nil {
+			// The old connection may already be broken; connect anyway
+			step.Message = "closed with an error"
+			step.Error = err.Error()
+		}
+		p.add(step)
+	}
+
+	// Relay the connection attempts of this component
+	events, unsubscribe := SubscribeConnectionEvents(32)
+	relayed := make(chan struct{})
+	go func() {
+		defer close(relayed)
+		for e := range events {
+			if e.Component == name {
+				p.add(RestartStep{Step: string(e.Type), Message: e.Message(), Attempt: e.Attempt, Error: e.Error, Time: e.Time})
+			}
+		}
+	}()
+	component, result, err := r.registry.Reconnect(name)
+	unsubscribe()
+	<-relayed
+
+	if err == nil && component == nil {
+		err = fmt.Errorf("%s is disabled in the configuration", name)
+	}
+	if r.OnRestarted != nil {
+		message := "switching services to the new " + name
+		if component == nil {
+			message = "removing " + name + " from services"
+		}
+		p.add(RestartStep{Step: RestartReloading, Message: message})
+		r.OnRestarted(name, component, result)
+	}
+	if err != nil {
+		p.add(RestartStep{Step: RestartDone, Message: "restart failed", Attempt: result.Attempts, Error: err.Error()})
+		return
+	}
+	p.add(RestartStep{Step: RestartDone, Message: fmt.Sprintf("restarted in %s", time.Since(p.status.StartedAt).Round(time.Millisecond)), Attempt: result.Attempts})
+}
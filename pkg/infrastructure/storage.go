@@ -0,0 +1,508 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// Default endpoints used when a bucket does not specify one.
+const (
+	storageS3Endpoint  = "s3.amazonaws.com"
+	storageGCSEndpoint = "storage.googleapis.com"
+)
+
+// StorageBucket is a single named bucket handled by the StorageManager.
+type StorageBucket struct {
+	Name      string // logical name
+	Bucket    string // physical bucket name
+	Provider  string
+	Endpoint  string
+	Region    string
+	Client    *minio.Client
+	Connected bool
+	Created   bool // bucket was auto-created on startup
+	Lifecycle int  // number of lifecycle rules applied
+	LastError string
+}
+
+// StorageManager manages multiple S3-compatible buckets (MinIO, AWS S3, GCS).
+type StorageManager struct {
+	buckets       map[string]*StorageBucket
+	defaultBucket string
+	mu            sync.RWMutex
+	logger        *logger.Logger
+	Pool          *WorkerPool // Async worker pool
+
+	// Status cache
+	statusCache  map[string]interface{}
+	statusExpiry time.Time
+	statusMu     sync.RWMutex
+}
+
+// Name returns the display name of the component
+func (m *StorageManager) Name() string {
+	return "Storage"
+}
+
+// NewStorageManager connects every configured bucket. A failing bucket is
+// recorded in its status instead of failing the whole manager.
+func NewStorageManager(cfg config.StorageConfig, l *logger.Logger) (*StorageManager, error) {
+	m := &StorageManager{
+		buckets:       make(map[string]*StorageBucket),
+		defaultBucket: cfg.DefaultBucket,
+		logger:        l,
+	}
+	if !cfg.Enabled || len(cfg.Buckets) == 0 {
+		return m, nil
+	}
+
+	clients := make(map[string]*minio.Client)
+	for _, bc := range cfg.Buckets {
+		if bc.Name == "" {
+			return nil, fmt.Errorf("storage bucket name is required")
+		}
+		if _, exists := m.buckets[bc.Name]; exists {
+			return nil, fmt.Errorf("duplicate storage bucket name: %s", bc.Name)
+		}
+
+		bucket := &StorageBucket{
+			Name:     bc.Name,
+			Bucket:   bc.Bucket,
+			Provider: strings.ToLower(bc.Provider),
+			Endpoint: bc.Endpoint,
+			Region:   bc.Region,
+		}
+		if bucket.Bucket == "" {
+			bucket.Bucket = bc.Name
+		}
+		if bucket.Provider == "" {
+			bucket.Provider = "minio"
+		}
+		m.buckets[bc.Name] = bucket
+		if m.defaultBucket == "" {
+			m.defaultBucket = bc.Name
+		}
+
+		useSSL := bc.UseSSL
+		switch bucket.Provider {
+		case "s3":
+			if bucket.Endpoint == "" {
+				bucket.Endpoint = storageS3Endpoint
+			}
+			useSSL = true
+		case "gcs":
+			if bucket.Endpoint == "" {
+				bucket.Endpoint = storageGCSEndpoint
+			}
+			useSSL = true
+		case "minio":
+			if bucket.Endpoint == "" {
+				bucket.LastError = "endpoint is required for minio buckets"
+				continue
+			}
+		default:
+			bucket.LastError = fmt.Sprintf("unsupported provider: %s", bc.Provider)
+			continue
+		}
+
+		// Buckets on the same endpoint with the same credentials share a client
+		key := fmt.Sprintf("%s|%s|%s|%t", bucket.Endpoint, bc.AccessKeyID, bucket.Region, useSSL)
+		client, ok := clients[key]
+		if !ok {
+			var err error
+			client, err = minio.New(bucket.Endpoint, &minio.Options{
+				Creds:  credentials.NewStaticV4(bc.AccessKeyID, bc.SecretAccessKey, ""),
+				Secure: useSSL,
+				Region: bucket.Region,
+			})
+			if err != nil {
+				bucket.LastError = err.Error()
+				continue
+			}
+			clients[key] = client
+		}
+		bucket.Client = client
+
+		if err := m.prepareBucket(bucket, bc); err != nil {
+			bucket.LastError = err.Error()
+			if l != nil {
+				l.Warn("Storage bucket not ready", "bucket", bc.Name, "error", err)
+			}
+			continue
+		}
+		bucket.Connected = true
+	}
+
+	// Initialize worker pool for async operations
+	m.Pool = NewComponentPool("storage", "storage", 8) // Moderate pool for file operations
+	m.Pool.Start()
+
+	return m, nil
+}
+
+// prepareBucket verifies the bucket, creates it when auto_create is set and
+// applies the configured lifecycle rules.
+func (m *StorageManager) prepareBucket(bucket *StorageBucket, bc config.StorageBucketConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	exists, err := bucket.Client.BucketExists(ctx, bucket.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if !bc.AutoCreate {
+			return fmt.Errorf("bucket %s does not exist", bucket.Bucket)
+		}
+		if err := bucket.Client.MakeBucket(ctx, bucket.Bucket, minio.MakeBucketOptions{Region: bucket.Region}); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucket.Bucket, err)
+		}
+		bucket.Created = true
+		if m.logger != nil {
+			m.logger.Info("Storage bucket created", "bucket", bucket.Bucket, "provider", bucket.Provider)
+		}
+	}
+
+	if len(bc.Lifecycle) > 0 {
+		if err := bucket.Client.SetBucketLifecycle(ctx, bucket.Bucket, buildLifecycle(bc)); err != nil {
+			return fmt.Errorf("failed to apply lifecycle rules: %w", err)
+		}
+		bucket.Lifecycle = len(bc.Lifecycle)
+	}
+	return nil
+}
+
+// buildLifecycle converts configured rules into an S3 lifecycle configuration.
+func buildLifecycle(bc config.StorageBucketConfig) *lifecycle.Configuration {
+	lc := lifecycle.NewConfiguration()
+	for i, r := range bc.Lifecycle {
+		id := r.ID
+		if id == "" {
+			id = fmt.Sprintf("%s-rule-%d", bc.Name, i+1)
+		}
+		rule := lifecycle.Rule{
+			ID:         id,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: r.Prefix},
+		}
+		if r.ExpirationDays > 0 {
+			rule.Expiration = lifecycle.Expiration{Days: lifecycle.ExpirationDays(r.ExpirationDays)}
+		}
+		if r.NoncurrentExpirationDays > 0 {
+			rule.NoncurrentVersionExpiration = lifecycle.NoncurrentVersionExpiration{NoncurrentDays: lifecycle.ExpirationDays(r.NoncurrentExpirationDays)}
+		}
+		if r.AbortIncompleteUploadDays > 0 {
+			rule.AbortIncompleteMultipartUpload = lifecycle.AbortIncompleteMultipartUpload{DaysAfterInitiation: lifecycle.ExpirationDays(r.AbortIncompleteUploadDays)}
+		}
+		lc.Rules = append(lc.Rules, rule)
+	}
+	return lc
+}
+
+// GetBucket returns a connected bucket by logical name. An empty name selects
+// the default bucket.
+func (m *StorageManager) GetBucket(name string) (*StorageBucket, error) {
+	if name == "" {
+		name = m.defaultBucket
+	}
+	m.mu.RLock()
+	bucket, ok := m.buckets[name]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("storage bucket not found: %s", name)
+	}
+	if !bucket.Connected {
+		return nil, fmt.Errorf("storage bucket not connected: %s", name)
+	}
+	return bucket, nil
+}
+
+// GetDefaultBucket returns the default bucket.
+func (m *StorageManager) GetDefaultBucket() (*StorageBucket, error) {
+	return m.GetBucket("")
+}
+
+// BucketNames returns the logical names of all configured buckets.
+func (m *StorageManager) BucketNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.buckets))
+	for name := range m.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetStatus returns the manager status with per-bucket details.
+func (m *StorageManager) GetStatus() map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{"connected": false}
+	}
+
+	m.statusMu.RLock()
+	if m.statusCache != nil && time.Now().Before(m.statusExpiry) {
+		cached := m.statusCache
+		m.statusMu.RUnlock()
+		return cached
+	}
+	m.statusMu.RUnlock()
+
+	buckets := make(map[string]interface{})
+	connected := 0
+	for _, name := range m.BucketNames() {
+		m.mu.RLock()
+		b := m.buckets[name]
+		m.mu.RUnlock()
+
+		status := map[string]interface{}{
+			"bucket":          b.Bucket,
+			"provider":        b.Provider,
+			"endpoint":        b.Endpoint,
+			"connected":       b.Connected,
+			"auto_created":    b.Created,
+			"lifecycle_rules": b.Lifecycle,
+		}
+		if b.Region != "" {
+			status["region"] = b.Region
+		}
+		if b.Connected {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			exists, err := b.Client.BucketExists(ctx, b.Bucket)
+			cancel()
+			switch {
+			case err != nil:
+				status["status"] = "Unreachable"
+				status["error"] = err.Error()
+			case !exists:
+				status["status"] = "Bucket not found"
+			default:
+				status["status"] = "Healthy"
+				connected++
+			}
+		} else {
+			status["status"] = "Disconnected"
+			if b.LastError != "" {
+				status["error"] = b.LastError
+			}
+		}
+		buckets[name] = status
+	}
+
+	result := map[string]interface{}{
+		"connected":      connected > 0,
+		"default_bucket": m.defaultBucket,
+		"total_buckets":  len(buckets),
+		"healthy":        connected,
+		"buckets":        buckets,
+	}
+	if m.Pool != nil {
+		result["pool_active"] = true
+		result["pool"] = m.Pool.GetStatus()
+	}
+
+	m.statusMu.Lock()
+	m.statusCache = result
+	m.statusExpiry = time.Now().Add(5 * time.Second)
+	m.statusMu.Unlock()
+
+	return result
+}
+
+// Bucket operations
+
+// UploadFile uploads an object synchronously.
+func (b *StorageBucket) UploadFile(ctx context.Context, objectName string, reader io.Reader, objectSize int64, contentType string) (minio.UploadInfo, error) {
+	return b.Client.PutObject(ctx, b.Bucket, objectName, reader, objectSize, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+}
+
+// GetObject retrieves an object.
+func (b *StorageBucket) GetObject(ctx context.Context, objectName string) (*minio.Object, error) {
+	return b.Client.GetObject(ctx, b.Bucket, objectName, minio.GetObjectOptions{})
+}
+
+// DeleteObject removes an object.
+func (b *StorageBucket) DeleteObject(ctx context.Context, objectName string) error {
+	return b.Client.RemoveObject(ctx, b.Bucket, objectName, minio.RemoveObjectOptions{})
+}
+
+// StatObject returns object information.
+func (b *StorageBucket) StatObject(ctx context.Context, objectName string) (minio.ObjectInfo, error) {
+	return b.Client.StatObject(ctx, b.Bucket, objectName, minio.StatObjectOptions{})
+}
+
+// ListObjects lists objects under a prefix.
+func (b *StorageBucket) ListObjects(ctx context.Context, prefix string, recursive bool) ([]minio.ObjectInfo, error) {
+	var objects []minio.ObjectInfo
+	objectCh := b.Client.ListObjects(ctx, b.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: recursive,
+	})
+	for object := range objectCh {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// PresignedURL generates a presigned GET URL for the object.
+func (b *StorageBucket) PresignedURL(ctx context.Context, objectName string, expiry time.Duration) (*url.URL, error) {
+	return b.Client.PresignedGetObject(ctx, b.Bucket, objectName, expiry, nil)
+}
+
+// GetFileUrl generates a presigned URL for the object (expires in 7 days).
+func (b *StorageBucket) GetFileUrl(objectName string) string {
+	u, err := b.PresignedURL(context.Background(), objectName, 7*24*time.Hour)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// Async Storage Operations
+
+// UploadFileAsync asynchronously uploads a file to the named bucket.
+func (m *StorageManager) UploadFileAsync(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) *AsyncResult[minio.UploadInfo] {
+	return ExecuteAsync(ctx, func(ctx context.Context) (minio.UploadInfo, error) {
+		b, err := m.GetBucket(bucketName)
+		if err != nil {
+			return minio.UploadInfo{}, err
+		}
+		return b.UploadFile(ctx, objectName, reader, objectSize, contentType)
+	})
+}
+
+// GetObjectAsync asynchronously retrieves an object from the named bucket.
+func (m *StorageManager) GetObjectAsync(ctx context.Context, bucketName, objectName string) *AsyncResult[*minio.Object] {
+	return ExecuteAsync(ctx, func(ctx context.Context) (*minio.Object, error) {
+		b, err := m.GetBucket(bucketName)
+		if err != nil {
+			return nil, err
+		}
+		return b.GetObject(ctx, objectName)
+	})
+}
+
+// DeleteObjectAsync asynchronously deletes an object from the named bucket.
+func (m *StorageManager) DeleteObjectAsync(ctx context.Context, bucketName, objectName string) *AsyncResult[struct{}] {
+	return ExecuteAsync(ctx, func(ctx context.Context) (struct{}, error) {
+		b, err := m.GetBucket(bucketName)
+		if err != nil {
+			return struct{}{}, err
+		}
+		return struct{}{}, b.DeleteObject(ctx, objectName)
+	})
+}
+
+// ListObjectsAsync asynchronously lists objects in the named bucket.
+func (m *StorageManager) ListObjectsAsync(ctx context.Context, bucketName, prefix string, recursive bool) *AsyncResult[[]minio.ObjectInfo] {
+	return ExecuteAsync(ctx, func(ctx context.Context) ([]minio.ObjectInfo, error) {
+		b, err := m.GetBucket(bucketName)
+		if err != nil {
+			return nil, err
+		}
+		return b.ListObjects(ctx, prefix, recursive)
+	})
+}
+
+// GetObjectInfoAsync asynchronously gets object information.
+func (m *StorageManager) GetObjectInfoAsync(ctx context.Context, bucketName, objectName string) *AsyncResult[minio.ObjectInfo] {
+	return ExecuteAsync(ctx, func(ctx context.Context) (minio.ObjectInfo, error) {
+		b, err := m.GetBucket(bucketName)
+		if err != nil {
+			return minio.ObjectInfo{}, err
+		}
+		return b.StatObject(ctx, objectName)
+	})
+}
+
+// Batch Operations
+
+// StorageUpload describes a single object upload in a batch.
+type StorageUpload struct {
+	ObjectName  string
+	Reader      io.Reader
+	ObjectSize  int64
+	ContentType string
+}
+
+// UploadBatchAsync asynchronously uploads multiple files to the named bucket.
+func (m *StorageManager) UploadBatchAsync(ctx context.Context, bucketName string, uploads []StorageUpload) *BatchAsyncResult[minio.UploadInfo] {
+	operations := make([]AsyncOperation[minio.UploadInfo], len(uploads))
+
+	for i, upload := range uploads {
+		upload := upload // Capture loop variable
+		operations[i] = func(ctx context.Context) (minio.UploadInfo, error) {
+			b, err := m.GetBucket(bucketName)
+			if err != nil {
+				return minio.UploadInfo{}, err
+			}
+			return b.UploadFile(ctx, upload.ObjectName, upload.Reader, upload.ObjectSize, upload.ContentType)
+		}
+	}
+
+	return ExecuteBatchAsync(ctx, operations, 10)
+}
+
+// DeleteBatchAsync asynchronously deletes multiple objects from the named bucket.
+func (m *StorageManager) DeleteBatchAsync(ctx context.Context, bucketName string, objectNames []string) *BatchAsyncResult[struct{}] {
+	operations := make([]AsyncOperation[struct{}], len(objectNames))
+
+	for i, objectName := range objectNames {
+		objectName := objectName // Capture loop variable
+		operations[i] = func(ctx context.Context) (struct{}, error) {
+			b, err := m.GetBucket(bucketName)
+			if err != nil {
+				return struct{}{}, err
+			}
+			return struct{}{}, b.DeleteObject(ctx, objectName)
+		}
+	}
+
+	return ExecuteBatchAsync(ctx, operations, 10)
+}
+
+// Worker Pool Operations
+
+// SubmitAsyncJob submits an async job to the worker pool.
+func (m *StorageManager) SubmitAsyncJob(job func()) {
+	if m.Pool != nil {
+		m.Pool.Submit(job)
+	} else {
+		// Fallback to direct execution if pool not available
+		go job()
+	}
+}
+
+// Close closes the storage manager and its worker pool.
+func (m *StorageManager) Close() error {
+	if m.Pool != nil {
+		m.Pool.Close()
+	}
+	return nil
+}
+
+func init() {
+	RegisterComponent("storage", func(cfg *config.Config, l *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.Storage.Enabled {
+			return nil, nil
+		}
+		return NewStorageManager(cfg.Storage, l)
+	})
+}
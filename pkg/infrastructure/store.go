@@ -0,0 +1,256 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// StoreEntry is a key/value pair read from the embedded store.
+type StoreEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// EmbeddedStore is a local key/value store (bbolt) for state that must
+// survive restarts without an external database: buffered messages,
+// histories, bookmarks and similar subsystem data.
+type EmbeddedStore struct {
+	DB     *bolt.DB
+	Path   string
+	logger *logger.Logger
+}
+
+// Name returns the display name of the component
+func (s *EmbeddedStore) Name() string {
+	return "Embedded Store"
+}
+
+// NewEmbeddedStore opens (or creates) the store file.
+func NewEmbeddedStore(cfg config.StoreConfig, logger *logger.Logger) (*EmbeddedStore, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = "data/stackyrd.db"
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded store %s: %w", path, err)
+	}
+
+	return &EmbeddedStore{DB: db, Path: path, logger: logger}, nil
+}
+
+// Put stores a value under bucket/key, creating the bucket when needed.
+func (s *EmbeddedStore) Put(bucket, key string, value []byte) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+// PutMany stores several values in one transaction.
+func (s *EmbeddedStore) PutMany(bucket string, values map[string][]byte) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the value for bucket/key and whether it exists.
+func (s *EmbeddedStore) Get(bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(key)); v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return value, value != nil, err
+}
+
+// PutJSON stores v encoded as JSON.
+func (s *EmbeddedStore) PutJSON(bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Put(bucket, key, data)
+}
+
+// GetJSON decodes the value at bucket/key into v.
+func (s *EmbeddedStore) GetJSON(bucket, key string, v interface{}) (bool, error) {
+	data, ok, err := s.Get(bucket, key)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// Delete removes bucket/key. Missing keys are not an error.
+func (s *EmbeddedStore) Delete(bucket, key string) error {
+	return s.DeleteKeys(bucket, [][]byte{[]byte(key)})
+}
+
+// DeleteKeys removes several keys in one transaction.
+func (s *EmbeddedStore) DeleteKeys(bucket string, keys [][]byte) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Append stores value under the bucket's next sequence number. Keys are
+// big-endian so iteration order equals insertion order, which makes a
+// bucket usable as a durable FIFO queue.
+func (s *EmbeddedStore) Append(bucket string, value []byte) (uint64, error) {
+	var seq uint64
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		seq, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put(SequenceKey(seq), value)
+	})
+	return seq, err
+}
+
+// SequenceKey encodes a sequence number as used by Append.
+func SequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// First returns up to limit entries from the start of the bucket.
+func (s *EmbeddedStore) First(bucket string, limit int) ([]StoreEntry, error) {
+	var entries []StoreEntry
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil && (limit <= 0 || len(entries) < limit); k, v = c.Next() {
+			entries = append(entries, StoreEntry{
+				Key:   append([]byte(nil), k...),
+				Value: append([]byte(nil), v...),
+			})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// ForEachPrefix calls fn for every key starting with prefix, in key order.
+func (s *EmbeddedStore) ForEachPrefix(bucket, prefix string, fn func(key, value []byte) error) error {
+	return s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		p := []byte(prefix)
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Count returns the number of keys in the bucket.
+func (s *EmbeddedStore) Count(bucket string) (int, error) {
+	var n int
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			n = b.Stats().KeyN
+		}
+		return nil
+	})
+	return n, err
+}
+
+// GetStatus returns the store status.
+func (s *EmbeddedStore) GetStatus() map[string]interface{} {
+	stats := make(map[string]interface{})
+	if s == nil || s.DB == nil {
+		stats["connected"] = false
+		return stats
+	}
+
+	stats["connected"] = true
+	stats["path"] = s.Path
+	var buckets int
+	_ = s.DB.View(func(tx *bolt.Tx) error {
+		stats["size_bytes"] = tx.Size()
+		return tx.ForEach(func(_ []byte, _ *bolt.Bucket) error {
+			buckets++
+			return nil
+		})
+	})
+	stats["buckets"] = buckets
+	return stats
+}
+
+// Close closes the store file.
+func (s *EmbeddedStore) Close() error {
+	if s.DB != nil {
+		return s.DB.Close()
+	}
+	return nil
+}
+
+func init() {
+	RegisterComponent("store", func(cfg *config.Config, l *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.Store.Enabled {
+			return nil, nil
+		}
+		return NewEmbeddedStore(cfg.Store, l)
+	})
+}
@@ -0,0 +1,65 @@
+package infrastructure
+
+import (
+	"time"
+)
+
+// RetryPolicy controls how often connecting a component is attempted.
+type RetryPolicy struct {
+	Attempts int           // total attempts; values below 1 mean a single attempt
+	Backoff  time.Duration // wait after the first failure, doubled after each further one
+}
+
+// ConnectResult is the outcome of connecting a component.
+type ConnectResult struct {
+	Attempts int
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Supervise calls connect until it succeeds or the attempts of policy are
+// used up, publishing a ConnectionEvent for every failed attempt and for
+// the outcome. connect returning (nil, nil) means the component is disabled,
+// and no event is published.
+func Supervise(name string, policy RetryPolicy, connect func() (InfrastructureComponent, error)) (InfrastructureComponent, ConnectResult) {
+	attempts := max(policy.Attempts, 1)
+	backoff := policy.Backoff
+	result := ConnectResult{Started: time.Now()}
+
+	for attempt := 1; ; attempt++ {
+		component, err := connect()
+		result.Attempts = attempt
+		result.Duration = time.Since(result.Started)
+		if err == nil {
+			if component != nil {
+				PublishConnectionEvent(ConnectionEvent{
+					Component: name,
+					Type:      ConnectionConnected,
+					Attempt:   attempt,
+					Elapsed:   result.Duration,
+				})
+			}
+			return component, result
+		}
+
+		event := ConnectionEvent{
+			Component: name,
+			Type:      ConnectionRetrying,
+			Attempt:   attempt,
+			Elapsed:   result.Duration,
+			RetryIn:   backoff,
+			Error:     err.Error(),
+		}
+		if attempt >= attempts {
+			event.Type = ConnectionFailed
+			event.RetryIn = 0
+			PublishConnectionEvent(event)
+			result.Err = err
+			return nil, result
+		}
+		PublishConnectionEvent(event)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
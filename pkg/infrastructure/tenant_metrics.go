@@ -0,0 +1,208 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/tenancy"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/event"
+	"gorm.io/gorm"
+)
+
+// recordQuery attributes a SQL query started at start, see queryTenant.
+func (p *PostgresManager) recordQuery(ctx context.Context, start time.Time) {
+	tenancy.DefaultCollector().RecordQuery(queryTenant(ctx, tenancy.DefaultRegistry().PostgresOwner, p.Connection), time.Since(start))
+}
+
+// queryTenant returns the tenant database work on the named connection is
+// billed to: the tenant in the request context, else the registered tenant
+// owning the connection, so background work on a tenant's own database is
+// still accounted. Work on shared connections goes to
+// tenancy.UnattributedTenant.
+func queryTenant(ctx context.Context, owner func(conn string) (string, bool), conn string) string {
+	if tenant := tenancy.FromContext(ctx); tenant != "" {
+		return tenant
+	}
+	if tenant, ok := owner(conn); ok {
+		return tenant
+	}
+	return tenancy.UnattributedTenant
+}
+
+const gormStartKey = "tenancy:start"
+
+// registerTenantCallbacks times every GORM operation on db; conn returns
+// the name of the owning connection.
+func registerTenantCallbacks(db *gorm.DB, conn func() string) {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(gormStartKey, time.Now())
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+		start, _ := v.(time.Time)
+		tenancy.DefaultCollector().RecordQuery(queryTenant(tx.Statement.Context, tenancy.DefaultRegistry().PostgresOwner, conn()), time.Since(start))
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("gorm:create").Register("tenancy:before_create", before)
+	cb.Create().After("gorm:create").Register("tenancy:after_create", after)
+	cb.Query().Before("gorm:query").Register("tenancy:before_query", before)
+	cb.Query().After("gorm:query").Register("tenancy:after_query", after)
+	cb.Update().Before("gorm:update").Register("tenancy:before_update", before)
+	cb.Update().After("gorm:update").Register("tenancy:after_update", after)
+	cb.Delete().Before("gorm:delete").Register("tenancy:before_delete", before)
+	cb.Delete().After("gorm:delete").Register("tenancy:after_delete", after)
+	cb.Raw().Before("gorm:raw").Register("tenancy:before_raw", before)
+	cb.Raw().After("gorm:raw").Register("tenancy:after_raw", after)
+	cb.Row().Before("gorm:row").Register("tenancy:before_row", before)
+	cb.Row().After("gorm:row").Register("tenancy:after_row", after)
+}
+
+// tenantCommandMonitor records the duration of every MongoDB command. The
+// driver reports completion with the command's context, so the request
+// tenant is still available.
+func tenantCommandMonitor(m *MongoManager) *event.CommandMonitor {
+	record := func(ctx context.Context, d time.Duration, command string) {
+		// Handshakes and heartbeats are driver overhead, not tenant work
+		switch command {
+		case "hello", "isMaster", "ismaster", "ping", "saslStart", "saslContinue":
+			return
+		}
+		tenancy.DefaultCollector().RecordQuery(queryTenant(ctx, tenancy.DefaultRegistry().MongoOwner, m.Connection), d)
+	}
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			record(ctx, e.Duration, e.CommandName)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			record(ctx, e.Duration, e.CommandName)
+		},
+	}
+}
+
+// tenantCacheHook counts Redis operations per tenant. Reads that return
+// redis.Nil are misses.
+type tenantCacheHook struct{}
+
+var cacheReadCommands = map[string]bool{
+	"get": true, "mget": true, "hget": true, "hmget": true, "hgetall": true,
+	"getex": true, "getdel": true, "exists": true, "smembers": true, "lrange": true,
+}
+
+func (tenantCacheHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tenantCacheHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		recordCacheCmd(ctx, cmd)
+		return err
+	}
+}
+
+func (tenantCacheHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			recordCacheCmd(ctx, cmd)
+		}
+		return err
+	}
+}
+
+func recordCacheCmd(ctx context.Context, cmd redis.Cmder) {
+	tenant := tenancy.FromContext(ctx)
+	if tenant == "" {
+		return
+	}
+	name := strings.ToLower(cmd.Name())
+	if !cacheReadCommands[name] {
+		tenancy.DefaultCollector().RecordCache(tenant, false, false)
+		return
+	}
+	miss := errors.Is(cmd.Err(), redis.Nil)
+	if !miss {
+		if n, ok := cmd.(*redis.IntCmd); ok && name == "exists" {
+			miss = n.Val() == 0
+		}
+	}
+	tenancy.DefaultCollector().RecordCache(tenant, !miss && cmd.Err() == nil, miss)
+}
+
+// TenantSizer periodically measures the storage footprint of tenants: the
+// objects under each tenant's prefix and the size of Postgres databases
+// dedicated to a tenant (connections the tenant registry maps to it).
+type TenantSizer struct {
+	Storage       *StorageManager
+	Postgres      *PostgresConnectionManager
+	PrefixPattern string // e.g. "tenants/{tenant}/"
+	TTL           time.Duration
+
+	mu       sync.Mutex
+	measured time.Time
+}
+
+// Refresh measures all known tenants unless the last measurement is younger
+// than TTL. Listing buckets is expensive, so callers may invoke it on every
+// read of the metrics.
+func (s *TenantSizer) Refresh(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.measured) < s.TTL {
+		return
+	}
+	s.measured = time.Now()
+
+	collector := tenancy.DefaultCollector()
+	tenants := collector.Tenants()
+
+	if s.Postgres != nil {
+		for name, conn := range s.Postgres.GetAllConnections() {
+			tenant, ok := tenancy.DefaultRegistry().PostgresOwner(name)
+			if !ok {
+				continue
+			}
+			var size int64
+			if err := conn.DB.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&size); err == nil {
+				collector.SetDatabaseSize(tenant, size)
+			}
+		}
+	}
+
+	if s.Storage != nil && s.PrefixPattern != "" {
+		for _, tenant := range tenants {
+			if tenant == tenancy.OverflowTenant || tenant == tenancy.UnattributedTenant {
+				continue
+			}
+			prefix := strings.ReplaceAll(s.PrefixPattern, "{tenant}", tenant)
+			var objects, bytes int64
+			for _, name := range s.Storage.BucketNames() {
+				bucket, err := s.Storage.GetBucket(name)
+				if err != nil {
+					continue
+				}
+				infos, err := bucket.ListObjects(ctx, prefix, true)
+				if err != nil {
+					continue
+				}
+				for _, info := range infos {
+					objects++
+					bytes += info.Size
+				}
+			}
+			collector.SetStorage(tenant, objects, bytes)
+		}
+	}
+}
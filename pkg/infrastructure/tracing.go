@@ -0,0 +1,297 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+	"stackyrd/pkg/tracing"
+
+	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// maxStatementLength bounds the statement recorded on database spans.
+const maxStatementLength = 2048
+
+func truncateStatement(s string) string {
+	if len(s) > maxStatementLength {
+		return s[:maxStatementLength] + "..."
+	}
+	return s
+}
+
+// startSQLSpan starts a client span for a raw SQL statement. The span is
+// named after the leading keyword so traces group by operation, not by
+// statement text.
+func startSQLSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := "SQL"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	return tracing.Start(ctx, "postgres "+operation, trace.SpanKindClient,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.query.text", truncateStatement(query)),
+	)
+}
+
+const gormSpanKey = "tracing:span"
+
+// registerTracingCallbacks creates a span around every GORM operation on db.
+func registerTracingCallbacks(db *gorm.DB) {
+	before := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			if !tracing.Enabled() || tx.Statement.Context == nil {
+				return
+			}
+			ctx, span := tracing.Start(tx.Statement.Context, "postgres "+operation, trace.SpanKindClient,
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation.name", operation),
+				attribute.String("db.collection.name", tx.Statement.Table),
+			)
+			tx.Statement.Context = ctx
+			tx.InstanceSet(gormSpanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(gormSpanKey)
+		if !ok {
+			return
+		}
+		span, ok := v.(trace.Span)
+		if !ok {
+			return
+		}
+		span.SetAttributes(
+			attribute.String("db.query.text", truncateStatement(tx.Statement.SQL.String())),
+			attribute.Int64("db.response.rows_affected", tx.Statement.RowsAffected),
+		)
+		err := tx.Error
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("gorm:create").Register("tracing:before_create", before("INSERT"))
+	cb.Create().After("gorm:create").Register("tracing:after_create", after)
+	cb.Query().Before("gorm:query").Register("tracing:before_query", before("SELECT"))
+	cb.Query().After("gorm:query").Register("tracing:after_query", after)
+	cb.Update().Before("gorm:update").Register("tracing:before_update", before("UPDATE"))
+	cb.Update().After("gorm:update").Register("tracing:after_update", after)
+	cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before("DELETE"))
+	cb.Delete().After("gorm:delete").Register("tracing:after_delete", after)
+	cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before("RAW"))
+	cb.Raw().After("gorm:raw").Register("tracing:after_raw", after)
+	cb.Row().Before("gorm:row").Register("tracing:before_row", before("ROW"))
+	cb.Row().After("gorm:row").Register("tracing:after_row", after)
+}
+
+// tracingCommandMonitor creates a span per MongoDB command. The driver
+// reports start and completion separately, so open spans are kept by
+// request ID until the command finishes.
+func tracingCommandMonitor() *event.CommandMonitor {
+	var spans sync.Map // int64 -> trace.Span
+	finish := func(requestID int64, err error) {
+		if v, ok := spans.LoadAndDelete(requestID); ok {
+			tracing.End(v.(trace.Span), err)
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if !tracing.Enabled() {
+				return
+			}
+			switch e.CommandName {
+			case "hello", "isMaster", "ismaster", "ping", "saslStart", "saslContinue", "endSessions":
+				return
+			}
+			_, span := tracing.Start(ctx, "mongodb "+e.CommandName, trace.SpanKindClient,
+				attribute.String("db.system", "mongodb"),
+				attribute.String("db.namespace", e.DatabaseName),
+				attribute.String("db.operation.name", e.CommandName),
+			)
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, nil)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, errors.New(e.Failure))
+		},
+	}
+}
+
+// chainCommandMonitors combines command monitors into one, since the driver
+// accepts a single monitor per client.
+func chainCommandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+// tracingHook creates a span per Redis command or pipeline. Nil replies are
+// not errors.
+type tracingHook struct{}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !tracing.Enabled() {
+			return next(ctx, cmd)
+		}
+		name := strings.ToUpper(cmd.Name())
+		ctx, span := tracing.Start(ctx, "redis "+name, trace.SpanKindClient,
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation.name", name),
+		)
+		err := next(ctx, cmd)
+		tracing.End(span, redisSpanError(err))
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !tracing.Enabled() {
+			return next(ctx, cmds)
+		}
+		ctx, span := tracing.Start(ctx, "redis pipeline", trace.SpanKindClient,
+			attribute.String("db.system", "redis"),
+			attribute.Int("db.operation.batch.size", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		tracing.End(span, redisSpanError(err))
+		return err
+	}
+}
+
+func redisSpanError(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// kafkaHeaderCarrier adapts Kafka record headers to the propagation API.
+type kafkaHeaderCarrier struct {
+	headers *[]sarama.RecordHeader
+}
+
+func (c kafkaHeaderCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaHeaderCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if string(h.Key) == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c kafkaHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, string(h.Key))
+	}
+	return keys
+}
+
+// sendMessage produces msg within a producer span and carries the trace
+// context and the correlation ID in the record headers, so consumers
+// continue the same trace.
+func (k *KafkaManager) sendMessage(ctx context.Context, msg *sarama.ProducerMessage) error {
+	_, _, err := k.produce(ctx, msg)
+	return err
+}
+
+// produce sends msg like sendMessage and returns where it was written.
+func (k *KafkaManager) produce(ctx context.Context, msg *sarama.ProducerMessage) (int32, int64, error) {
+	ctx, span := tracing.Start(ctx, msg.Topic+" publish", trace.SpanKindProducer,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.String("messaging.operation.type", "send"),
+	)
+	if tracing.Enabled() {
+		tracing.Inject(ctx, kafkaHeaderCarrier{headers: &msg.Headers})
+	}
+	if id := logger.CorrelationID(ctx); id != "" {
+		carrier := kafkaHeaderCarrier{headers: &msg.Headers}
+		if carrier.Get(messaging.HeaderCorrelationID) == "" {
+			carrier.Set(messaging.HeaderCorrelationID, id)
+		}
+	}
+	partition, offset, err := k.Producer.SendMessage(msg)
+	if err == nil {
+		span.SetAttributes(
+			attribute.Int64("messaging.destination.partition.id", int64(partition)),
+			attribute.Int64("messaging.kafka.offset", offset),
+		)
+	}
+	tracing.End(span, err)
+	return partition, offset, err
+}
+
+// startConsumeSpan starts a consumer span for message, continuing the trace
+// of the producer when the record carries one.
+func startConsumeSpan(ctx context.Context, message *sarama.ConsumerMessage) (context.Context, trace.Span) {
+	if tracing.Enabled() {
+		headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+		for _, h := range message.Headers {
+			if h != nil {
+				headers = append(headers, *h)
+			}
+		}
+		ctx = tracing.Extract(ctx, kafkaHeaderCarrier{headers: &headers})
+	}
+	return tracing.Start(ctx, message.Topic+" process", trace.SpanKindConsumer,
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", message.Topic),
+		attribute.String("messaging.operation.type", "process"),
+		attribute.Int64("messaging.destination.partition.id", int64(message.Partition)),
+		attribute.Int64("messaging.kafka.offset", message.Offset),
+	)
+}
@@ -0,0 +1,379 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+)
+
+// Kinds of time-series database the tsdb component writes to (tsdb.type).
+const (
+	TSDBInfluxDB    = "influxdb"
+	TSDBRemoteWrite = "remote_write"
+)
+
+const (
+	defaultTSDBBatchSize     = 100
+	defaultTSDBFlushInterval = 10 * time.Second
+	defaultTSDBBufferSize    = 10000
+	defaultTSDBRetryBackoff  = 500 * time.Millisecond
+	defaultTSDBTimeout       = 10 * time.Second
+)
+
+// TSDBSample is the value of every metric at one point in time.
+type TSDBSample struct {
+	Time   time.Time
+	Values map[string]float64
+}
+
+// tsdbStatusError is a write the database answered with an error status.
+type tsdbStatusError struct {
+	code int
+	body string
+}
+
+func (e *tsdbStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("tsdb returned status %d", e.code)
+	}
+	return fmt.Sprintf("tsdb returned status %d: %s", e.code, e.body)
+}
+
+// retryable reports whether writing the same batch again may succeed.
+// Other client errors mean the database refuses the data itself.
+func (e *tsdbStatusError) retryable() bool {
+	return e.code >= 500 || e.code == http.StatusTooManyRequests || e.code == http.StatusRequestTimeout
+}
+
+// TSDBManager buffers metric samples and writes them to InfluxDB (line
+// protocol) or a Prometheus remote-write endpoint such as VictoriaMetrics
+// in batches. A batch is written when batch_size samples are buffered or
+// every flush_interval; failed writes are retried with backoff and stay
+// buffered while the database is unreachable, dropping the oldest samples
+// beyond buffer_size.
+type TSDBManager struct {
+	kind         string
+	endpoint     string
+	header       http.Header
+	encode       func([]TSDBSample) []byte
+	client       *http.Client
+	batchSize    int
+	bufferSize   int
+	maxRetries   int
+	retryBackoff time.Duration
+	interval     time.Duration
+	timeout      time.Duration
+	logger       *logger.Logger
+
+	mu        sync.Mutex
+	buffer    []TSDBSample
+	closed    bool
+	written   int64
+	dropped   int64
+	failures  int64
+	lastWrite time.Time
+	lastError string
+
+	flushMu sync.Mutex
+	trigger chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewTSDBManager creates the tsdb component and starts its flush loop.
+// Nothing is written until samples are recorded.
+func NewTSDBManager(cfg config.TSDBConfig, l *logger.Logger) (*TSDBManager, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("tsdb: url is required")
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("tsdb: invalid url %q", cfg.URL)
+	}
+
+	m := &TSDBManager{
+		kind:         strings.ToLower(cfg.Type),
+		header:       make(http.Header),
+		batchSize:    cfg.BatchSize,
+		bufferSize:   cfg.BufferSize,
+		maxRetries:   max(cfg.MaxRetries, 0),
+		retryBackoff: time.Duration(cfg.RetryBackoff) * time.Millisecond,
+		interval:     time.Duration(cfg.FlushInterval) * time.Second,
+		timeout:      time.Duration(cfg.Timeout) * time.Second,
+		logger:       l,
+		trigger:      make(chan struct{}, 1),
+	}
+	if m.batchSize <= 0 {
+		m.batchSize = defaultTSDBBatchSize
+	}
+	if m.bufferSize < m.batchSize {
+		m.bufferSize = max(defaultTSDBBufferSize, m.batchSize)
+	}
+	if m.retryBackoff <= 0 {
+		m.retryBackoff = defaultTSDBRetryBackoff
+	}
+	if m.interval <= 0 {
+		m.interval = defaultTSDBFlushInterval
+	}
+	if m.timeout <= 0 {
+		m.timeout = defaultTSDBTimeout
+	}
+	m.client = &http.Client{Timeout: m.timeout}
+
+	switch m.kind {
+	case "", TSDBInfluxDB:
+		m.kind = TSDBInfluxDB
+		m.endpoint = influxWriteURL(base, cfg)
+		m.header.Set("Content-Type", "text/plain; charset=utf-8")
+		measurement := cfg.Measurement
+		if measurement == "" {
+			measurement = "stackyrd"
+		}
+		m.encode = func(samples []TSDBSample) []byte {
+			return EncodeInfluxLines(measurement, cfg.Tags, samples)
+		}
+	case TSDBRemoteWrite:
+		m.endpoint = base.String()
+		m.header.Set("Content-Type", "application/x-protobuf")
+		m.header.Set("Content-Encoding", "snappy")
+		m.header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+		m.encode = func(samples []TSDBSample) []byte {
+			return EncodeRemoteWrite(cfg.Prefix, cfg.Tags, samples)
+		}
+	default:
+		return nil, fmt.Errorf("tsdb: unknown type %q, use %s or %s", cfg.Type, TSDBInfluxDB, TSDBRemoteWrite)
+	}
+	if cfg.Token != "" {
+		m.header.Set("Authorization", "Token "+cfg.Token)
+	} else if cfg.Username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+		m.header.Set("Authorization", "Basic "+credentials)
+	}
+
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.wg.Add(1)
+	go m.loop()
+	return m, nil
+}
+
+// influxWriteURL returns the v2 write API when a bucket is configured and
+// the v1 one otherwise; VictoriaMetrics accepts both.
+func influxWriteURL(base *url.URL, cfg config.TSDBConfig) string {
+	u := *base
+	q := url.Values{"precision": {"ns"}}
+	if cfg.Bucket != "" {
+		u.Path = strings.TrimRight(u.Path, "/") + "/api/v2/write"
+		q.Set("org", cfg.Org)
+		q.Set("bucket", cfg.Bucket)
+	} else {
+		u.Path = strings.TrimRight(u.Path, "/") + "/write"
+		if cfg.Database != "" {
+			q.Set("db", cfg.Database)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Name returns the display name of the component.
+func (m *TSDBManager) Name() string {
+	return "Time-Series Database"
+}
+
+// Record buffers a sample for the next write. The values are copied.
+func (m *TSDBManager) Record(t time.Time, values map[string]float64) {
+	if len(values) == 0 {
+		return
+	}
+	copied := make(map[string]float64, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.buffer = append(m.buffer, TSDBSample{Time: t, Values: copied})
+	m.trimLocked()
+	full := len(m.buffer) >= m.batchSize
+	m.mu.Unlock()
+	if full {
+		select {
+		case m.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// trimLocked drops the oldest samples beyond the buffer size.
+func (m *TSDBManager) trimLocked() {
+	if over := len(m.buffer) - m.bufferSize; over > 0 {
+		m.buffer = append(m.buffer[:0:0], m.buffer[over:]...)
+		m.dropped += int64(over)
+	}
+}
+
+func (m *TSDBManager) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.trigger:
+		}
+		if err := m.Flush(m.ctx); err != nil && m.ctx.Err() == nil {
+			m.logger.Warn("Failed to write metrics to the time-series database", "type", m.kind, "error", err.Error())
+		}
+	}
+}
+
+// Flush writes the buffered samples batch by batch. A batch that still
+// fails after the retries goes back to the buffer for the next flush,
+// unless the database rejected it as invalid.
+func (m *TSDBManager) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	for {
+		m.mu.Lock()
+		n := min(len(m.buffer), m.batchSize)
+		batch := append([]TSDBSample(nil), m.buffer[:n]...)
+		m.buffer = m.buffer[n:]
+		m.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		err := m.writeWithRetry(ctx, batch)
+		m.mu.Lock()
+		if err == nil {
+			m.written += int64(n)
+			m.lastWrite = time.Now()
+			m.lastError = ""
+			m.mu.Unlock()
+			continue
+		}
+		m.failures++
+		m.lastError = err.Error()
+		var status *tsdbStatusError
+		if errors.As(err, &status) && !status.retryable() {
+			m.dropped += int64(n)
+		} else {
+			m.buffer = append(batch, m.buffer...)
+			m.trimLocked()
+		}
+		m.mu.Unlock()
+		return err
+	}
+}
+
+// writeWithRetry writes a batch, retrying network errors, 408, 429 and
+// 5xx up to max_retries times with doubling backoff.
+func (m *TSDBManager) writeWithRetry(ctx context.Context, batch []TSDBSample) error {
+	body := m.encode(batch)
+	backoff := m.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := m.write(ctx, body)
+		var status *tsdbStatusError
+		if err == nil || attempt >= m.maxRetries || (errors.As(err, &status) && !status.retryable()) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (m *TSDBManager) write(ctx context.Context, body []byte) error {
+	if len(body) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = m.header.Clone()
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 300 {
+		return &tsdbStatusError{code: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// GetStatus reports the database, the write counters and the buffered
+// samples. The component counts as connected until a write fails.
+func (m *TSDBManager) GetStatus() map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{"connected": false}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := map[string]interface{}{
+		"connected":      m.lastError == "",
+		"type":           m.kind,
+		"endpoint":       m.endpoint,
+		"buffered":       len(m.buffer),
+		"written":        m.written,
+		"dropped":        m.dropped,
+		"failed_writes":  m.failures,
+		"batch_size":     m.batchSize,
+		"flush_interval": m.interval.String(),
+	}
+	if !m.lastWrite.IsZero() {
+		status["last_write"] = m.lastWrite
+	}
+	if m.lastError != "" {
+		status["error"] = m.lastError
+	}
+	return status
+}
+
+// Close stops the flush loop and writes what is still buffered, giving up
+// after the write timeout.
+func (m *TSDBManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+	m.cancel()
+	m.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return m.Flush(ctx)
+}
+
+func init() {
+	RegisterComponent("tsdb", func(cfg *config.Config, l *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.TSDB.Enabled {
+			return nil, nil
+		}
+		return NewTSDBManager(cfg.TSDB, l)
+	})
+}
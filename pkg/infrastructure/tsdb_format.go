@@ -0,0 +1,139 @@
+package infrastructure
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// EncodeInfluxLines renders samples as InfluxDB line protocol, one line per
+// sample with the metrics as fields and nanosecond timestamps. Non-finite
+// values are skipped, as line protocol cannot carry them.
+func EncodeInfluxLines(measurement string, tags map[string]string, samples []TSDBSample) []byte {
+	var series strings.Builder
+	series.WriteString(influxEscape(measurement, ", "))
+	for _, k := range sortedKeys(tags) {
+		series.WriteString("," + influxEscape(k, ",= ") + "=" + influxEscape(tags[k], ",= "))
+	}
+	prefix := series.String()
+
+	var b strings.Builder
+	for _, s := range samples {
+		fields := 0
+		for _, name := range sortedKeys(s.Values) {
+			v := s.Values[name]
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			if fields == 0 {
+				b.WriteString(prefix + " ")
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(influxEscape(name, ",= ") + "=" + strconv.FormatFloat(v, 'g', -1, 64))
+			fields++
+		}
+		if fields > 0 {
+			b.WriteString(" " + strconv.FormatInt(s.Time.UnixNano(), 10) + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// influxEscape backslash-escapes the given characters.
+func influxEscape(s, chars string) string {
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// EncodeRemoteWrite renders samples as a snappy-compressed Prometheus
+// remote-write request: one series per metric, named prefix plus the
+// metric name with invalid characters replaced by "_", labelled with tags.
+func EncodeRemoteWrite(prefix string, tags map[string]string, samples []TSDBSample) []byte {
+	type point struct {
+		value float64
+		ms    int64
+	}
+	points := make(map[string][]point)
+	for _, s := range samples {
+		for name, v := range s.Values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			metric := promName(prefix+name, true)
+			points[metric] = append(points[metric], point{v, s.Time.UnixMilli()})
+		}
+	}
+
+	labels := make(map[string]string, len(tags))
+	for k, v := range tags {
+		labels[promName(k, false)] = v
+	}
+	var req []byte
+	for _, metric := range sortedKeys(points) {
+		labels["__name__"] = metric
+		var ts []byte
+		for _, k := range sortedKeys(labels) {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, k)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, labels[k])
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		series := points[metric]
+		sort.SliceStable(series, func(i, j int) bool { return series[i].ms < series[j].ms })
+		for _, p := range series {
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(p.value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(p.ms))
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sample)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	if len(req) == 0 {
+		return nil
+	}
+	return snappy.Encode(nil, req)
+}
+
+// promName replaces the characters not allowed in a Prometheus metric
+// name, or label name when metric is false, with "_".
+func promName(s string, metric bool) string {
+	b := []byte(s)
+	for i, c := range b {
+		ok := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9') || (metric && c == ':')
+		if !ok {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,196 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/vault"
+)
+
+const (
+	defaultVaultRenewInterval = time.Minute
+	defaultVaultDatabaseMount = "database"
+)
+
+// VaultManager holds the Vault client and keeps its token renewed: the
+// token is looked up every renew interval and renewed once less than half
+// of its last granted TTL, or two intervals, remain. The leases of the
+// database credentials it issues are renewed on the same schedule.
+type VaultManager struct {
+	client        *vault.Client
+	interval      time.Duration
+	increment     time.Duration
+	databaseMount string
+	logger        *logger.Logger
+
+	mu          sync.RWMutex
+	token       vault.TokenInfo
+	granted     time.Duration // TTL of the last lookup or renewal that raised it
+	lastRenewal time.Time
+	renewals    int
+	lastError   string
+	leases      map[*databaseLease]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewVaultManager checks the token against Vault and starts renewing it.
+func NewVaultManager(cfg config.VaultConfig, l *logger.Logger) (*VaultManager, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault: address is not set (vault.address or VAULT_ADDR)")
+	}
+	v := &VaultManager{
+		client:        vault.New(cfg.Address, cfg.Token, cfg.Namespace, time.Duration(cfg.Timeout)*time.Second),
+		interval:      time.Duration(cfg.RenewInterval) * time.Second,
+		increment:     time.Duration(cfg.RenewIncrement) * time.Second,
+		databaseMount: strings.Trim(cfg.DatabaseMount, "/"),
+		logger:        l,
+		leases:        make(map[*databaseLease]struct{}),
+	}
+	if v.interval <= 0 {
+		v.interval = defaultVaultRenewInterval
+	}
+	if v.databaseMount == "" {
+		v.databaseMount = defaultVaultDatabaseMount
+	}
+	v.ctx, v.cancel = context.WithCancel(context.Background())
+
+	info, err := v.client.LookupSelf(v.ctx)
+	if err != nil {
+		v.cancel()
+		return nil, fmt.Errorf("vault: token lookup failed: %w", err)
+	}
+	v.token, v.granted = info, info.TTL
+
+	v.wg.Add(1)
+	go v.renewLoop()
+	return v, nil
+}
+
+// Name returns the display name of the component.
+func (v *VaultManager) Name() string {
+	return "Vault"
+}
+
+// Client is the Vault client, authenticated with the renewed token.
+func (v *VaultManager) Client() *vault.Client {
+	return v.client
+}
+
+// Value reads key of the secret at path.
+func (v *VaultManager) Value(ctx context.Context, path, key string) (string, error) {
+	return v.client.Value(ctx, path, key)
+}
+
+func (v *VaultManager) renewLoop() {
+	defer v.wg.Done()
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-v.ctx.Done():
+			return
+		case <-ticker.C:
+			v.RenewIfNeeded(v.ctx)
+			v.RenewLeases(v.ctx)
+		}
+	}
+}
+
+// RenewIfNeeded looks the token up and renews it when it is due.
+func (v *VaultManager) RenewIfNeeded(ctx context.Context) {
+	info, err := v.client.LookupSelf(ctx)
+	if err != nil {
+		v.fail("Vault token lookup failed", err)
+		return
+	}
+	v.mu.Lock()
+	v.token = info
+	v.lastError = ""
+	if info.TTL > v.granted {
+		v.granted = info.TTL
+	}
+	granted := v.granted
+	v.mu.Unlock()
+
+	// Tokens without a TTL, like root tokens, never expire
+	if info.TTL <= 0 || (info.TTL > granted/2 && info.TTL > 2*v.interval) {
+		return
+	}
+	if !info.Renewable {
+		v.logger.Warn("Vault token expires soon and is not renewable", "ttl", info.TTL.String())
+		return
+	}
+	renewed, err := v.client.RenewSelf(ctx, v.increment)
+	if err != nil {
+		v.fail("Vault token renewal failed", err)
+		return
+	}
+	v.mu.Lock()
+	v.token, v.granted = renewed, renewed.TTL
+	v.lastRenewal = time.Now()
+	v.renewals++
+	v.mu.Unlock()
+	v.logger.Info("Vault token renewed", "ttl", renewed.TTL.String())
+}
+
+func (v *VaultManager) fail(msg string, err error) {
+	v.mu.Lock()
+	v.lastError = err.Error()
+	v.mu.Unlock()
+	v.logger.Error(msg, err)
+}
+
+// GetStatus reports the token's TTL, the renewals and the database
+// credential leases.
+func (v *VaultManager) GetStatus() map[string]interface{} {
+	if v == nil {
+		return map[string]interface{}{"connected": false}
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	status := map[string]interface{}{
+		"connected":         v.lastError == "",
+		"address":           v.client.Address(),
+		"token_renewable":   v.token.Renewable,
+		"token_ttl_seconds": int64(v.token.TTL.Seconds()),
+		"renewals":          v.renewals,
+	}
+	if !v.token.ExpireAt.IsZero() {
+		status["token_expires_at"] = v.token.ExpireAt
+	}
+	if !v.lastRenewal.IsZero() {
+		status["last_renewal"] = v.lastRenewal
+	}
+	if v.lastError != "" {
+		status["error"] = v.lastError
+	}
+	if len(v.leases) > 0 {
+		status["database_leases"] = v.leaseStatus()
+	}
+	return status
+}
+
+// Close stops renewing the token and the leases; the credentials still in
+// use stay valid until their leases expire.
+func (v *VaultManager) Close() error {
+	v.cancel()
+	v.wg.Wait()
+	return nil
+}
+
+func init() {
+	RegisterComponent("vault", func(cfg *config.Config, l *logger.Logger) (InfrastructureComponent, error) {
+		if !cfg.Vault.Enabled {
+			return nil, nil
+		}
+		return NewVaultManager(cfg.Vault, l)
+	})
+}
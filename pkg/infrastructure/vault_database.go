@@ -0,0 +1,195 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DatabaseCredentials are dynamic credentials issued by a role of the
+// Vault database secrets engine.
+type DatabaseCredentials struct {
+	Role     string
+	Username string
+	Password string
+	LeaseID  string
+	TTL      time.Duration // lease TTL at issue
+}
+
+// databaseLease is a credential lease the manager keeps renewed.
+type databaseLease struct {
+	creds     DatabaseCredentials
+	expireAt  time.Time
+	renewable bool
+	onRotate  func(DatabaseCredentials) error
+	renewals  int
+	rotations int
+	rotatedAt time.Time
+	lastError string
+}
+
+// DatabaseCredentials issues credentials for role and keeps their lease
+// renewed. Once Vault no longer extends the lease past half its TTL (the
+// role's max_ttl is near, or it is not renewable), new credentials are
+// issued and handed to onRotate; when onRotate fails they are revoked and
+// the rotation is retried on the next check while the old ones still
+// work. release stops renewing and revokes the current lease.
+func (v *VaultManager) DatabaseCredentials(ctx context.Context, role string, onRotate func(DatabaseCredentials) error) (creds DatabaseCredentials, release func(), err error) {
+	creds, renewable, err := v.issueDatabaseCredentials(ctx, role)
+	if err != nil {
+		return DatabaseCredentials{}, nil, err
+	}
+	lease := &databaseLease{
+		creds:     creds,
+		expireAt:  time.Now().Add(creds.TTL),
+		renewable: renewable,
+		onRotate:  onRotate,
+	}
+	v.mu.Lock()
+	v.leases[lease] = struct{}{}
+	v.mu.Unlock()
+
+	release = func() {
+		v.mu.Lock()
+		_, ok := v.leases[lease]
+		delete(v.leases, lease)
+		leaseID := lease.creds.LeaseID
+		v.mu.Unlock()
+		if ok {
+			v.revoke(leaseID)
+		}
+	}
+	return creds, release, nil
+}
+
+func (v *VaultManager) issueDatabaseCredentials(ctx context.Context, role string) (DatabaseCredentials, bool, error) {
+	secret, err := v.client.Read(ctx, v.databaseMount+"/creds/"+role)
+	if err != nil {
+		return DatabaseCredentials{}, false, fmt.Errorf("vault: database credentials for role %q: %w", role, err)
+	}
+	username, _ := secret.Data["username"].(string)
+	password, _ := secret.Data["password"].(string)
+	if username == "" {
+		return DatabaseCredentials{}, false, fmt.Errorf("vault: database credentials for role %q carry no username", role)
+	}
+	creds := DatabaseCredentials{
+		Role:     role,
+		Username: username,
+		Password: password,
+		LeaseID:  secret.LeaseID,
+		TTL:      secret.LeaseTTL,
+	}
+	return creds, secret.Renewable, nil
+}
+
+// RenewLeases renews the credential leases that are due and rotates
+// those Vault no longer extends.
+func (v *VaultManager) RenewLeases(ctx context.Context) {
+	v.mu.RLock()
+	leases := make([]*databaseLease, 0, len(v.leases))
+	for lease := range v.leases {
+		leases = append(leases, lease)
+	}
+	v.mu.RUnlock()
+
+	for _, lease := range leases {
+		v.mu.RLock()
+		creds, expireAt, renewable := lease.creds, lease.expireAt, lease.renewable
+		v.mu.RUnlock()
+
+		// Leases without a TTL never expire
+		remaining := time.Until(expireAt)
+		if creds.TTL <= 0 || (remaining > creds.TTL/2 && remaining > 2*v.interval) {
+			continue
+		}
+		if renewable && creds.LeaseID != "" {
+			info, err := v.client.RenewLease(ctx, creds.LeaseID, creds.TTL)
+			if err == nil {
+				v.mu.Lock()
+				lease.expireAt = time.Now().Add(info.TTL)
+				lease.renewable = info.Renewable
+				lease.renewals++
+				lease.lastError = ""
+				v.mu.Unlock()
+			} else {
+				v.logger.Warn("Vault lease renewal failed, rotating the credentials", "role", creds.Role, "error", err.Error())
+			}
+			if err == nil && info.TTL > creds.TTL/2 {
+				continue
+			}
+		}
+		v.rotate(ctx, lease)
+	}
+}
+
+// rotate issues new credentials for the lease and hands them over. The old
+// lease is left to expire, as connections may still use its credentials.
+func (v *VaultManager) rotate(ctx context.Context, lease *databaseLease) {
+	v.mu.RLock()
+	role := lease.creds.Role
+	v.mu.RUnlock()
+
+	creds, renewable, err := v.issueDatabaseCredentials(ctx, role)
+	if err == nil && lease.onRotate != nil {
+		if err = lease.onRotate(creds); err != nil {
+			v.revoke(creds.LeaseID)
+		}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err != nil {
+		lease.lastError = err.Error()
+		v.logger.Error("Vault database credential rotation failed", err, "role", role)
+		return
+	}
+	if _, ok := v.leases[lease]; !ok {
+		// Released meanwhile
+		go v.revoke(creds.LeaseID)
+		return
+	}
+	lease.creds = creds
+	lease.expireAt = time.Now().Add(creds.TTL)
+	lease.renewable = renewable
+	lease.rotations++
+	lease.rotatedAt = time.Now()
+	lease.lastError = ""
+	v.logger.Info("Vault database credentials rotated", "role", role, "username", creds.Username, "ttl", creds.TTL.String())
+}
+
+func (v *VaultManager) revoke(leaseID string) {
+	if leaseID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := v.client.RevokeLease(ctx, leaseID); err != nil {
+		v.logger.Warn("Failed to revoke Vault lease", "lease_id", leaseID, "error", err.Error())
+	}
+}
+
+// leaseStatus lists the credential leases for GetStatus, without the
+// passwords. Call with v.mu held.
+func (v *VaultManager) leaseStatus() []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(v.leases))
+	for lease := range v.leases {
+		entry := map[string]interface{}{
+			"role":        lease.creds.Role,
+			"username":    lease.creds.Username,
+			"ttl_seconds": int64(time.Until(lease.expireAt).Seconds()),
+			"expires_at":  lease.expireAt,
+			"renewable":   lease.renewable,
+			"renewals":    lease.renewals,
+			"rotations":   lease.rotations,
+		}
+		if !lease.rotatedAt.IsZero() {
+			entry["rotated_at"] = lease.rotatedAt
+		}
+		if lease.lastError != "" {
+			entry["error"] = lease.lastError
+		}
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i]["username"].(string) < list[j]["username"].(string) })
+	return list
+}
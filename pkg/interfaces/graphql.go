@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"stackyrd/pkg/graphql"
+)
+
+// GraphQLService is implemented by services that contribute fields to the
+// GraphQL endpoint
+type GraphQLService interface {
+	// RegisterGraphQL registers the service's typed query and mutation
+	// fields
+	RegisterGraphQL(s *graphql.Schema)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"google.golang.org/grpc"
+)
+
+// GRPCService defines the interface that gRPC services must implement
+type GRPCService interface {
+	// Name returns the human-readable name of the service
+	Name() string
+
+	// RegisterGRPC registers the service's implementations with the gRPC server
+	RegisterGRPC(s grpc.ServiceRegistrar)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+)
+
+// HealthCheckService is implemented by services that can tell whether they
+// can serve requests, e.g. by pinging their database. The results are
+// served at /health/ready and /api/services.
+type HealthCheckService interface {
+	// HealthCheck returns nil when the service is healthy. It should
+	// return promptly once ctx is done
+	HealthCheck(ctx context.Context) error
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+)
+
+// LifecycleService is implemented by services that run work of their own,
+// such as goroutines or tickers. Start runs once the service's routes are
+// registered, before it gets requests; Stop runs when it is replaced on a
+// reload and during graceful shutdown, and must return once that work has
+// ended or ctx is done. Constructors must not start such work.
+type LifecycleService interface {
+	// Start starts the service's background work
+	Start(ctx context.Context) error
+
+	// Stop stops the work started by Start
+	Stop(ctx context.Context) error
+}
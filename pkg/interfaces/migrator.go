@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+)
+
+// MigratingService is implemented by services that own database tables.
+// Migrate runs when the service is created and on `stackyrd migrate`, so
+// it must be safe to run again
+type MigratingService interface {
+	// Migrate creates or updates the service's tables
+	Migrate(ctx context.Context) error
+}
@@ -0,0 +1,169 @@
+// Package jsonpatch computes and applies RFC 6902 JSON Patches between
+// decoded JSON documents (maps, slices and scalars as produced by
+// encoding/json). Diff only emits add, remove and replace operations.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch is returned when a patch does not apply to a document.
+var ErrInvalidPatch = errors.New("invalid json patch")
+
+// Operation is one JSON Patch operation.
+type Operation struct {
+	Op    string      `json:"op"` // add, remove or replace
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Normalize converts v to its decoded JSON form, so structs and typed maps
+// compare like the documents clients hold.
+func Normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	return out, json.Unmarshal(data, &out)
+}
+
+// Diff returns the operations turning from into to, both decoded JSON.
+// Objects are compared key by key; arrays of the same length element by
+// element, others are replaced whole. Equal documents give no operations.
+func Diff(from, to interface{}) []Operation {
+	var ops []Operation
+	diff("", from, to, &ops)
+	return ops
+}
+
+func diff(path string, from, to interface{}, ops *[]Operation) {
+	switch a := from.(type) {
+	case map[string]interface{}:
+		b, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := path + "/" + escape(k)
+			av, inA := a[k]
+			bv, inB := b[k]
+			switch {
+			case !inB:
+				*ops = append(*ops, Operation{Op: "remove", Path: child})
+			case !inA:
+				*ops = append(*ops, Operation{Op: "add", Path: child, Value: bv})
+			default:
+				diff(child, av, bv, ops)
+			}
+		}
+		return
+	case []interface{}:
+		b, ok := to.([]interface{})
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range a {
+			diff(path+"/"+strconv.Itoa(i), a[i], b[i], ops)
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		*ops = append(*ops, Operation{Op: "replace", Path: path, Value: to})
+	}
+}
+
+// Apply returns doc with ops applied. doc is modified in place where
+// possible; the result must be used.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	for _, op := range ops {
+		var err error
+		if doc, err = apply(doc, op); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc interface{}, op Operation) (interface{}, error) {
+	if op.Path == "" {
+		if op.Op == "remove" {
+			return nil, nil
+		}
+		return op.Value, nil
+	}
+	if !strings.HasPrefix(op.Path, "/") {
+		return nil, fmt.Errorf("%w: path %q", ErrInvalidPatch, op.Path)
+	}
+	segments := strings.Split(op.Path[1:], "/")
+	parent := doc
+	for _, s := range segments[:len(segments)-1] {
+		child, err := lookup(parent, unescape(s))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPatch, op.Path, err)
+		}
+		parent = child
+	}
+
+	last := unescape(segments[len(segments)-1])
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		switch op.Op {
+		case "add", "replace":
+			p[last] = op.Value
+		case "remove":
+			delete(p, last)
+		default:
+			return nil, fmt.Errorf("%w: unsupported op %q", ErrInvalidPatch, op.Op)
+		}
+		return doc, nil
+	case []interface{}:
+		i, err := strconv.Atoi(last)
+		if err != nil || i < 0 || i >= len(p) || op.Op != "replace" {
+			// Diff replaces arrays that change length whole
+			return nil, fmt.Errorf("%w: %s %s", ErrInvalidPatch, op.Op, op.Path)
+		}
+		p[i] = op.Value
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: %s: parent is not a container", ErrInvalidPatch, op.Path)
+}
+
+func lookup(node interface{}, key string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if v, ok := n[key]; ok {
+			return v, nil
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n) {
+			return n[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%q not found", key)
+}
+
+// escape encodes a key as a JSON Pointer token (RFC 6901).
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func unescape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
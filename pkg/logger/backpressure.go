@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DropPolicy decides which entry a subscriber whose buffer is full loses.
+// Publish never waits for a subscriber.
+type DropPolicy string
+
+// Drop policies (monitoring.log_subscribers.drop_policy).
+const (
+	// DropNewest keeps the buffered entries and loses the one published.
+	DropNewest DropPolicy = "drop_newest"
+	// DropOldest discards the oldest buffered entry to make room, so a
+	// subscriber that catches up sees the latest lines.
+	DropOldest DropPolicy = "drop_oldest"
+)
+
+// DefaultSubscriberBuffer is the subscriber buffer when none is given.
+const DefaultSubscriberBuffer = 100
+
+// ParseDropPolicy returns the policy named s; empty is DropNewest.
+func ParseDropPolicy(s string) (DropPolicy, error) {
+	switch DropPolicy(s) {
+	case "", DropNewest:
+		return DropNewest, nil
+	case DropOldest:
+		return DropOldest, nil
+	}
+	return "", fmt.Errorf("unknown drop policy %q, use %s or %s", s, DropNewest, DropOldest)
+}
+
+// SubscribeOptions configures a subscription.
+type SubscribeOptions struct {
+	Name   string     // shown in the broadcaster stats
+	Buffer int        // entries buffered for the subscriber; the broadcaster default when 0
+	Policy DropPolicy // the broadcaster default when empty
+}
+
+// subscriber is the channel of a subscription with its delivery counters.
+type subscriber struct {
+	ch        chan LogEntry
+	name      string
+	policy    DropPolicy
+	since     time.Time
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// deliver hands entry to the subscriber without blocking, dropping an
+// entry by its policy when the buffer is full. Callers hold the
+// broadcaster's read lock so the channel is not closed meanwhile.
+func (s *subscriber) deliver(entry LogEntry) {
+	select {
+	case s.ch <- entry:
+		s.delivered.Add(1)
+		return
+	default:
+	}
+	if s.policy == DropOldest {
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.ch <- entry:
+			s.delivered.Add(1)
+			return
+		default:
+		}
+	}
+	s.dropped.Add(1)
+}
+
+// SubscriberStats is the delivery accounting of one subscription.
+type SubscriberStats struct {
+	Name      string     `json:"name"`
+	Policy    DropPolicy `json:"policy"`
+	Buffer    int        `json:"buffer"`
+	Queued    int        `json:"queued"`
+	Delivered uint64     `json:"delivered"`
+	Dropped   uint64     `json:"dropped"`
+	Since     time.Time  `json:"since"`
+}
+
+// BroadcasterStats reports how published entries reached subscribers and
+// stream clients.
+type BroadcasterStats struct {
+	Published     uint64            `json:"published"`
+	BufferSize    int               `json:"buffer_size"` // entries kept for history and streams
+	Buffered      int               `json:"buffered"`
+	DefaultBuffer int               `json:"default_buffer"`
+	DefaultPolicy DropPolicy        `json:"default_policy"`
+	Dropped       uint64            `json:"dropped"`       // by every subscriber, including cancelled ones
+	StreamMissed  uint64            `json:"stream_missed"` // lost by stream clients that fell behind the buffer
+	Subscribers   []SubscriberStats `json:"subscribers"`   // most dropped first
+}
+
+// SetSubscriberDefaults sets the buffer and drop policy of subscriptions
+// that do not choose their own.
+func (b *LogBroadcaster) SetSubscriberDefaults(buffer int, policy DropPolicy) {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	if policy == "" {
+		policy = DropNewest
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subBuffer, b.subPolicy = buffer, policy
+}
+
+// SubscribeWith returns a channel receiving new entries and a function
+// that cancels the subscription.
+func (b *LogBroadcaster) SubscribeWith(opts SubscribeOptions) (<-chan LogEntry, func()) {
+	b.mu.Lock()
+	if opts.Buffer <= 0 {
+		opts.Buffer = b.subBuffer
+	}
+	if opts.Policy == "" {
+		opts.Policy = b.subPolicy
+	}
+	sub := &subscriber{
+		ch:     make(chan LogEntry, opts.Buffer),
+		name:   opts.Name,
+		policy: opts.Policy,
+		since:  time.Now(),
+	}
+	b.subscribers[sub.ch] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, sub.ch)
+			b.retiredDropped += sub.dropped.Load()
+			close(sub.ch)
+		})
+	}
+}
+
+// RecordStreamMissed counts entries a stream client lost because they
+// left the ring buffer before it read them.
+func (b *LogBroadcaster) RecordStreamMissed(n uint64) {
+	b.streamMissed.Add(n)
+}
+
+// Stats returns the delivery accounting of the broadcaster and its
+// subscribers.
+func (b *LogBroadcaster) Stats() BroadcasterStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := BroadcasterStats{
+		Published:     b.seq,
+		BufferSize:    b.size,
+		Buffered:      b.count,
+		DefaultBuffer: b.subBuffer,
+		DefaultPolicy: b.subPolicy,
+		Dropped:       b.retiredDropped,
+		StreamMissed:  b.streamMissed.Load(),
+		Subscribers:   make([]SubscriberStats, 0, len(b.subscribers)),
+	}
+	for _, sub := range b.subscribers {
+		s := SubscriberStats{
+			Name:      sub.name,
+			Policy:    sub.policy,
+			Buffer:    cap(sub.ch),
+			Queued:    len(sub.ch),
+			Delivered: sub.delivered.Load(),
+			Dropped:   sub.dropped.Load(),
+			Since:     sub.since,
+		}
+		stats.Dropped += s.Dropped
+		stats.Subscribers = append(stats.Subscribers, s)
+	}
+	sort.Slice(stats.Subscribers, func(i, j int) bool {
+		a, c := stats.Subscribers[i], stats.Subscribers[j]
+		if a.Dropped != c.Dropped {
+			return a.Dropped > c.Dropped
+		}
+		return a.Since.Before(c.Since)
+	})
+	return stats
+}
@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogEntry is a single parsed log line kept by the LogBroadcaster.
+type LogEntry struct {
+	Seq     uint64                 `json:"seq"`
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	// Replayed marks entries restored from a previous run; their Seq is 0.
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// LogBroadcaster is an io.Writer that receives every log line, keeps the
+// most recent ones in a ring buffer and fans them out to subscribers
+// (monitoring API, alerting). It accepts both zerolog JSON lines and the
+// console format written in quiet/TUI mode.
+type LogBroadcaster struct {
+	mu          sync.RWMutex
+	ring        []LogEntry
+	size        int
+	next        int
+	count       int
+	seq         uint64
+	subscribers map[chan LogEntry]*subscriber
+	subBuffer   int           // default subscriber buffer
+	subPolicy   DropPolicy    // default subscriber drop policy
+	changed     chan struct{} // closed and replaced by every Publish
+	levelCounts map[string]uint64
+	persist     *logFile
+	replay      *replayWriter
+	replayed    []LogEntry
+
+	retiredDropped uint64 // dropped by cancelled subscriptions
+	streamMissed   atomic.Uint64
+}
+
+// DefaultLogBufferSize is the ring buffer capacity when none is given.
+const DefaultLogBufferSize = 1000
+
+// NewLogBroadcaster creates a broadcaster keeping the last size entries.
+func NewLogBroadcaster(size int) *LogBroadcaster {
+	if size <= 0 {
+		size = DefaultLogBufferSize
+	}
+	return &LogBroadcaster{
+		ring:        make([]LogEntry, size),
+		size:        size,
+		subscribers: make(map[chan LogEntry]*subscriber),
+		subBuffer:   DefaultSubscriberBuffer,
+		subPolicy:   DropNewest,
+		changed:     make(chan struct{}),
+		levelCounts: make(map[string]uint64),
+	}
+}
+
+// Write implements io.Writer. A write may contain several lines.
+func (b *LogBroadcaster) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(p), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		b.Publish(ParseLogLine(line))
+	}
+	return len(p), nil
+}
+
+// Publish records an entry and delivers it to subscribers. Slow subscribers
+// lose entries by their DropPolicy rather than blocking the logger.
+func (b *LogBroadcaster) Publish(entry LogEntry) {
+	b.mu.Lock()
+	b.seq++
+	entry.Seq = b.seq
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	b.ring[b.next] = entry
+	b.next = (b.next + 1) % b.size
+	if b.count < b.size {
+		b.count++
+	}
+	b.levelCounts[entry.Level]++
+	close(b.changed)
+	b.changed = make(chan struct{})
+	persist := b.persist
+	replay := b.replay
+	b.mu.Unlock()
+
+	if persist != nil {
+		persist.append(entry)
+	}
+	if replay != nil {
+		replay.enqueue(entry)
+	}
+
+	// Delivery never blocks, and the read lock keeps cancelled
+	// subscriptions from closing their channel meanwhile.
+	b.mu.RLock()
+	for _, sub := range b.subscribers {
+		sub.deliver(entry)
+	}
+	b.mu.RUnlock()
+}
+
+// Subscribe returns a channel receiving new entries and a function that
+// cancels the subscription and closes the channel, safe to call while
+// entries are published. buffer <= 0 uses the default buffer; a full
+// buffer loses entries by the default drop policy.
+func (b *LogBroadcaster) Subscribe(buffer int) (<-chan LogEntry, func()) {
+	return b.SubscribeWith(SubscribeOptions{Buffer: buffer})
+}
+
+// Recent returns up to n most recent entries, oldest first. n <= 0 returns
+// the whole buffer.
+func (b *LogBroadcaster) Recent(n int) []LogEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.recentLocked(n)
+}
+
+// recentLocked implements Recent. Callers hold b.mu.
+func (b *LogBroadcaster) recentLocked(n int) []LogEntry {
+	if n <= 0 || n > b.count {
+		n = b.count
+	}
+	out := make([]LogEntry, n)
+	start := (b.next - n + b.size) % b.size
+	for i := 0; i < n; i++ {
+		out[i] = b.ring[(start+i)%b.size]
+	}
+	return out
+}
+
+// Seq returns the sequence number of the latest entry, zero before the
+// first one.
+func (b *LogBroadcaster) Seq() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.seq
+}
+
+// Changed returns a channel closed when the next entry is published. Take
+// it before reading with Since so no entry is missed in between.
+func (b *LogBroadcaster) Changed() <-chan struct{} {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.changed
+}
+
+// Since returns up to limit buffered entries with a sequence number above
+// seq, oldest first; seq zero returns the last limit entries. missed
+// reports entries after seq that already left the buffer.
+func (b *LogBroadcaster) Since(seq uint64, limit int) (entries []LogEntry, missed bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if seq == 0 || seq > b.seq {
+		return b.recentLocked(limit), false
+	}
+	newer := int(b.seq - seq)
+	if newer == 0 {
+		return nil, false
+	}
+	if newer > b.count {
+		missed = true
+		newer = b.count
+	}
+	entries = b.recentLocked(newer)
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, missed
+}
+
+// CountSince returns how many buffered entries at the given level (all
+// levels when empty) were logged after since, and the total in that window.
+func (b *LogBroadcaster) CountSince(level string, since time.Time) (matched, total int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for i := 0; i < b.count; i++ {
+		e := b.ring[(b.next-1-i+b.size)%b.size]
+		if e.Time.Before(since) {
+			break
+		}
+		total++
+		if level == "" || e.Level == level {
+			matched++
+		}
+	}
+	return matched, total
+}
+
+// LevelCounts returns the number of entries seen per level since start.
+func (b *LogBroadcaster) LevelCounts() map[string]uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	counts := make(map[string]uint64, len(b.levelCounts))
+	for k, v := range b.levelCounts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// ParseLogLine parses a zerolog JSON line or a console formatted line
+// ("15:04:05 INF message key=value").
+func ParseLogLine(line string) LogEntry {
+	if strings.HasPrefix(line, "{") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &raw); err == nil {
+			entry := LogEntry{Level: "info"}
+			if lvl, ok := raw["level"].(string); ok {
+				entry.Level = normalizeLevel(lvl)
+			}
+			if msg, ok := raw["message"].(string); ok {
+				entry.Message = msg
+			}
+			if ts, ok := raw["time"].(string); ok {
+				entry.Time, _ = time.Parse(time.RFC3339, ts)
+			}
+			delete(raw, "level")
+			delete(raw, "message")
+			delete(raw, "time")
+			if len(raw) > 0 {
+				entry.Fields = raw
+			}
+			return entry
+		}
+	}
+
+	entry := LogEntry{Level: "info", Message: line, Time: time.Now()}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) >= 2 && len(parts[0]) == 8 && strings.Count(parts[0], ":") == 2 {
+		entry.Level = normalizeLevel(parts[1])
+		if len(parts) == 3 {
+			entry.Message = parts[2]
+		} else {
+			entry.Message = ""
+		}
+	}
+	return entry
+}
+
+func normalizeLevel(level string) string {
+	switch strings.ToUpper(level) {
+	case "DBG", "DEBUG", "TRC", "TRACE":
+		return "debug"
+	case "WRN", "WARN", "WARNING":
+		return "warn"
+	case "ERR", "ERROR":
+		return "error"
+	case "FTL", "FATAL", "PNC", "PANIC":
+		return "fatal"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,65 @@
+package logger
+
+import "context"
+
+// FieldCorrelationID is the log field carrying the request correlation ID.
+const FieldCorrelationID = "correlation_id"
+
+type loggerKey struct{}
+
+type correlationKey struct{}
+
+// With returns a child logger that adds keyvals to every event.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	ctx := l.z.With()
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			continue
+		}
+		ctx = ctx.Interface(key, keyvals[i+1])
+	}
+	return &Logger{z: ctx.Logger(), quiet: l.quiet, config: l.config}
+}
+
+// NewContext returns ctx carrying l, typically a request-scoped child logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or nil.
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(loggerKey{}).(*Logger)
+	return l
+}
+
+// Ctx returns the request-scoped logger of ctx when there is one, so log
+// lines written while serving a request carry its correlation ID, and l
+// otherwise.
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	if scoped := FromContext(ctx); scoped != nil {
+		return scoped
+	}
+	if id := CorrelationID(ctx); id != "" {
+		return l.With(FieldCorrelationID, id)
+	}
+	return l
+}
+
+// WithCorrelationID returns ctx carrying the correlation ID of the request
+// or message being processed, for downstream calls to forward.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or "".
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
@@ -0,0 +1,237 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogQuery filters the log history. Zero values match everything.
+type LogQuery struct {
+	Query  string    // case-insensitive substring of the message or any field value
+	Level  string    // minimum level ("debug", "info", "warn", "error", "fatal")
+	From   time.Time // inclusive
+	To     time.Time // inclusive
+	Offset int
+	Limit  int
+}
+
+var levelRank = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3, "fatal": 4}
+
+// Matches reports whether an entry satisfies the query filters.
+func (q LogQuery) Matches(e LogEntry) bool {
+	if q.Level != "" && levelRank[e.Level] < levelRank[normalizeLevel(q.Level)] {
+		return false
+	}
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && e.Time.After(q.To) {
+		return false
+	}
+	if q.Query == "" {
+		return true
+	}
+	needle := strings.ToLower(q.Query)
+	if strings.Contains(strings.ToLower(e.Message), needle) {
+		return true
+	}
+	for k, v := range e.Fields {
+		if strings.Contains(strings.ToLower(k+"="+fmt.Sprint(v)), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// logFile appends entries as JSON lines and keeps one rotated backup
+// (path + ".1") once the file grows beyond maxBytes.
+type logFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64
+}
+
+func openLogFile(path string, maxBytes int64) (*logFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &logFile{path: path, maxBytes: maxBytes, file: f, size: info.Size()}, nil
+}
+
+func (l *logFile) append(entry LogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.appendLine(append(line, '\n'))
+}
+
+// appendLine writes one newline-terminated line, rotating first if needed.
+func (l *logFile) appendLine(line []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	if l.maxBytes > 0 && l.size+int64(len(line)) > l.maxBytes {
+		l.rotate()
+	}
+	n, _ := l.file.Write(line)
+	l.size += int64(n)
+}
+
+// rotate moves the current file to the backup slot. Callers hold l.mu.
+func (l *logFile) rotate() {
+	l.file.Close()
+	os.Rename(l.path, l.path+".1")
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		l.file = nil
+		return
+	}
+	l.file = f
+	l.size = 0
+}
+
+// scan calls fn for every persisted entry, oldest first. It reads without
+// holding the write lock so searches never stall logging.
+func (l *logFile) scan(fn func(LogEntry)) error {
+	for _, path := range []string{l.path + ".1", l.path} {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var entry LogEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				fn(entry)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *logFile) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// EnablePersistence appends every subsequent entry to path so history
+// survives restarts and reaches further back than the ring buffer.
+func (b *LogBroadcaster) EnablePersistence(path string, maxBytes int64) error {
+	f, err := openLogFile(path, maxBytes)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.persist = f
+	b.mu.Unlock()
+	return nil
+}
+
+// Persistent reports whether entries are written to disk.
+func (b *LogBroadcaster) Persistent() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.persist != nil
+}
+
+// Search returns entries matching q, newest first, together with the total
+// number of matches. Persisted history is searched when enabled, otherwise
+// the in-memory ring buffer.
+func (b *LogBroadcaster) Search(q LogQuery) ([]LogEntry, int, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	// Keep only the newest offset+limit matches (in a ring) while scanning
+	// oldest first
+	keep := math.MaxInt
+	if q.Offset < math.MaxInt-q.Limit {
+		keep = q.Offset + q.Limit
+	}
+	var ring []LogEntry
+	total := 0
+	collect := func(e LogEntry) {
+		if !q.Matches(e) {
+			return
+		}
+		if len(ring) < keep {
+			ring = append(ring, e)
+		} else {
+			ring[total%keep] = e
+		}
+		total++
+	}
+
+	b.mu.RLock()
+	persist := b.persist
+	b.mu.RUnlock()
+
+	if persist != nil {
+		if err := persist.scan(collect); err != nil {
+			return nil, 0, fmt.Errorf("failed to read log history: %w", err)
+		}
+	} else {
+		for _, e := range b.Recent(0) {
+			collect(e)
+		}
+	}
+
+	page := make([]LogEntry, 0, min(q.Limit, len(ring)))
+	for i := q.Offset; i < len(ring) && len(page) < q.Limit; i++ {
+		page = append(page, ring[(total-1-i)%keep])
+	}
+	return page, total, nil
+}
+
+// Close stops persisting entries and saves the queued replay entries. The
+// in-memory buffer keeps working.
+func (b *LogBroadcaster) Close() error {
+	b.StopReplay()
+	b.mu.Lock()
+	persist := b.persist
+	b.persist = nil
+	b.mu.Unlock()
+	if persist == nil {
+		return nil
+	}
+	return persist.close()
+}
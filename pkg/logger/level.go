@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// levelOverride is the minimum level set through SetLevel; nil leaves each
+// logger at the level it was created with.
+var levelOverride atomic.Pointer[zerolog.Level]
+
+// SetLevel changes the minimum level of every logger at runtime, e.g. to
+// turn on debug output without a restart. "" restores the configured
+// levels.
+func SetLevel(level string) error {
+	if level == "" {
+		levelOverride.Store(nil)
+		return nil
+	}
+	parsed, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil || parsed == zerolog.NoLevel {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	levelOverride.Store(&parsed)
+	return nil
+}
+
+// Level returns the level set through SetLevel, or "" when loggers use
+// their configured level.
+func Level() string {
+	if override := levelOverride.Load(); override != nil {
+		return override.String()
+	}
+	return ""
+}
+
+// enabled reports whether events at level are written.
+func (l *Logger) enabled(level zerolog.Level) bool {
+	if override := levelOverride.Load(); override != nil {
+		return level >= *override
+	}
+	if l.config.Debug {
+		return level >= zerolog.DebugLevel
+	}
+	return level >= zerolog.InfoLevel
+}
+
+// event starts an event at level, or returns nil (a no-op event) when the
+// level is disabled.
+func (l *Logger) event(level zerolog.Level) *zerolog.Event {
+	if !l.enabled(level) {
+		return nil
+	}
+	return l.z.WithLevel(level)
+}
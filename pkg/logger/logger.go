@@ -36,6 +36,7 @@ type LoggerConfig struct {
 	Debug       bool
 	Quiet       bool // suppress console output (logs still go to broadcaster)
 	Broadcaster io.Writer
+	Sinks       io.Writer // receives raw JSON events regardless of Quiet
 	Output      OutputConfig
 }
 
@@ -121,12 +122,13 @@ func NewWithConfig(cfg LoggerConfig) *Logger {
 		}
 	}
 
-	logLevel := zerolog.InfoLevel
-	if cfg.Debug {
-		logLevel = zerolog.DebugLevel
+	if cfg.Sinks != nil {
+		multi = zerolog.MultiLevelWriter(multi, cfg.Sinks)
 	}
 
-	z := zerolog.New(multi).Level(logLevel).With().Timestamp().Logger()
+	// Levels are checked per call (see enabled) so SetLevel can change them
+	// at runtime
+	z := zerolog.New(multi).Level(zerolog.TraceLevel).With().Timestamp().Logger()
 
 	return &Logger{z: z, quiet: cfg.Quiet, config: cfg}
 }
@@ -209,6 +211,13 @@ func (l *Logger) WithQuiet(quiet bool) *Logger {
 	return NewWithConfig(cfg)
 }
 
+// WithSinks returns a new logger that also writes every event to sinks
+func (l *Logger) WithSinks(sinks io.Writer) *Logger {
+	cfg := l.config
+	cfg.Sinks = sinks
+	return NewWithConfig(cfg)
+}
+
 // GetConfig returns the current logger configuration
 func (l *Logger) GetConfig() LoggerConfig {
 	return l.config
@@ -221,26 +230,26 @@ func (l *Logger) IsQuiet() bool {
 
 // Info logs an info message
 func (l *Logger) Info(msg string, keyvals ...interface{}) {
-	l.log(l.z.Info(), msg, keyvals...)
+	l.log(l.event(zerolog.InfoLevel), msg, keyvals...)
 }
 
 // Error logs an error message
 func (l *Logger) Error(msg string, err error, keyvals ...interface{}) {
 	if err != nil {
-		l.z.Error().Err(err).Fields(keyvals).Msg(msg)
+		l.event(zerolog.ErrorLevel).Err(err).Fields(keyvals).Msg(msg)
 	} else {
-		l.log(l.z.Error(), msg, keyvals...)
+		l.log(l.event(zerolog.ErrorLevel), msg, keyvals...)
 	}
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(msg string, keyvals ...interface{}) {
-	l.log(l.z.Debug(), msg, keyvals...)
+	l.log(l.event(zerolog.DebugLevel), msg, keyvals...)
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(msg string, keyvals ...interface{}) {
-	l.log(l.z.Warn(), msg, keyvals...)
+	l.log(l.event(zerolog.WarnLevel), msg, keyvals...)
 }
 
 // Fatal logs a fatal message and exits
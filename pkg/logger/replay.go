@@ -0,0 +1,268 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReplayStore keeps recent entries across restarts so a restarted process
+// can show what happened before it, e.g. in a crash loop.
+type ReplayStore interface {
+	Save(entries []LogEntry) error
+	Load(since time.Time) ([]LogEntry, error)
+	Prune(before time.Time) error
+}
+
+// Replay defaults.
+const (
+	replayFlushInterval = time.Second
+	replayBatch         = 256
+	replayQueue         = 4096
+	replayPruneInterval = time.Minute
+)
+
+// replayWriter saves published entries to the store in batches off the
+// logging path.
+type replayWriter struct {
+	store  ReplayStore
+	window time.Duration
+	queue  chan LogEntry
+
+	mu      sync.Mutex
+	dropped uint64
+	failed  bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func (w *replayWriter) enqueue(entry LogEntry) {
+	select {
+	case w.queue <- entry:
+	default:
+		w.mu.Lock()
+		w.dropped++
+		w.mu.Unlock()
+	}
+}
+
+func (w *replayWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(replayFlushInterval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+	batch := make([]LogEntry, 0, replayBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := w.store.Save(batch)
+		w.mu.Lock()
+		w.failed = err != nil
+		w.mu.Unlock()
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case entry := <-w.queue:
+			batch = append(batch, entry)
+			if len(batch) >= replayBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			if time.Since(lastPrune) >= replayPruneInterval {
+				_ = w.store.Prune(time.Now().Add(-w.window))
+				lastPrune = time.Now()
+			}
+		case <-w.stop:
+			for {
+				select {
+				case entry := <-w.queue:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *replayWriter) close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// EnableReplay restores the entries store kept from the last window into
+// the ring buffer, before the ones logged so far, and from then on saves
+// every entry to store asynchronously in batches. Replayed entries are
+// marked Replayed and keep their original time; it returns how many were
+// restored.
+func (b *LogBroadcaster) EnableReplay(store ReplayStore, window time.Duration) (int, error) {
+	previous, err := store.Load(time.Now().Add(-window))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load log replay: %w", err)
+	}
+	sort.SliceStable(previous, func(i, j int) bool { return previous[i].Time.Before(previous[j].Time) })
+
+	w := &replayWriter{
+		store:  store,
+		window: window,
+		queue:  make(chan LogEntry, replayQueue),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.replay != nil {
+		b.mu.Unlock()
+		return 0, fmt.Errorf("log replay is already enabled")
+	}
+	current := b.recentLocked(0)
+	if room := b.size - len(current); len(previous) > room {
+		previous = previous[len(previous)-room:]
+	}
+	for i := range previous {
+		previous[i].Seq = 0
+		previous[i].Replayed = true
+	}
+	b.next, b.count = 0, 0
+	for _, e := range append(previous, current...) {
+		b.ring[b.next] = e
+		b.next = (b.next + 1) % b.size
+		b.count++
+	}
+	b.replayed = previous
+	b.replay = w
+	b.mu.Unlock()
+
+	// This run's entries so far were never saved
+	for _, e := range current {
+		w.enqueue(e)
+	}
+	go w.run()
+	return len(previous), nil
+}
+
+// Replayed returns the entries restored by EnableReplay, oldest first.
+func (b *LogBroadcaster) Replayed() []LogEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]LogEntry(nil), b.replayed...)
+}
+
+// ReplayStatus reports whether replay is enabled, how many entries were
+// dropped because the store fell behind and whether the last save failed.
+func (b *LogBroadcaster) ReplayStatus() map[string]interface{} {
+	b.mu.RLock()
+	w := b.replay
+	replayed := len(b.replayed)
+	b.mu.RUnlock()
+	if w == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return map[string]interface{}{
+		"enabled":        true,
+		"window_seconds": int64(w.window.Seconds()),
+		"replayed":       replayed,
+		"dropped":        w.dropped,
+		"save_failed":    w.failed,
+	}
+}
+
+// StopReplay saves the queued entries and stops saving new ones, e.g.
+// before the store closes at shutdown.
+func (b *LogBroadcaster) StopReplay() {
+	b.mu.Lock()
+	w := b.replay
+	b.replay = nil
+	b.mu.Unlock()
+	if w != nil {
+		w.close()
+	}
+}
+
+// KeyValueStore is the persistence the store replay needs; the embedded
+// store implements it.
+type KeyValueStore interface {
+	PutMany(bucket string, values map[string][]byte) error
+	ForEachPrefix(bucket, prefix string, fn func(key, value []byte) error) error
+	DeleteKeys(bucket string, keys [][]byte) error
+}
+
+// ReplayBucket holds the entries of the store replay.
+const ReplayBucket = "log_replay"
+
+// StoreReplay keeps entries in a key/value store under
+// "<unix nanoseconds>/<seq>", so keys sort by time.
+type StoreReplay struct {
+	store KeyValueStore
+}
+
+// NewStoreReplay keeps replayed entries in store.
+func NewStoreReplay(store KeyValueStore) *StoreReplay {
+	return &StoreReplay{store: store}
+}
+
+func replayKey(t time.Time, seq uint64) string {
+	return fmt.Sprintf("%020d/%020d", t.UnixNano(), seq)
+}
+
+// Save stores entries in one write.
+func (s *StoreReplay) Save(entries []LogEntry) error {
+	values := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		values[replayKey(e.Time, e.Seq)] = data
+	}
+	return s.store.PutMany(ReplayBucket, values)
+}
+
+// Load returns the entries logged since the given time, oldest first.
+func (s *StoreReplay) Load(since time.Time) ([]LogEntry, error) {
+	first := replayKey(since, 0)
+	var entries []LogEntry
+	err := s.store.ForEachPrefix(ReplayBucket, "", func(key, value []byte) error {
+		if string(key) < first {
+			return nil
+		}
+		var e LogEntry
+		if json.Unmarshal(value, &e) == nil {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Prune deletes the entries logged before the given time.
+func (s *StoreReplay) Prune(before time.Time) error {
+	last := replayKey(before, 0)
+	var old [][]byte
+	err := s.store.ForEachPrefix(ReplayBucket, "", func(key, _ []byte) error {
+		if string(key) >= last {
+			return errStopScan
+		}
+		old = append(old, append([]byte(nil), key...))
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		return err
+	}
+	if len(old) == 0 {
+		return nil
+	}
+	return s.store.DeleteKeys(ReplayBucket, old)
+}
+
+var errStopScan = fmt.Errorf("stop scan")
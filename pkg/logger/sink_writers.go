@@ -0,0 +1,277 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/rs/zerolog"
+)
+
+// consoleSink writes JSON lines to stdout or stderr, e.g. for container
+// log collection alongside the human-readable console.
+type consoleSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func newConsoleSink(cfg SinkConfig) *consoleSink {
+	if cfg.Output == "stderr" {
+		return &consoleSink{out: os.Stderr}
+	}
+	return &consoleSink{out: os.Stdout}
+}
+
+func (s *consoleSink) WriteBatch(ctx context.Context, records []SinkRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range records {
+		if _, err := s.out.Write(rec.Line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *consoleSink) Close() error { return nil }
+
+// fileSink appends JSON lines to a file with the same single-backup
+// rotation as the log history.
+type fileSink struct {
+	file *logFile
+}
+
+func newFileSink(cfg SinkConfig) (*fileSink, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("sink %s: file path is required", cfg.Name)
+	}
+	f, err := openLogFile(cfg.Path, int64(cfg.MaxSizeMB)*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: f}, nil
+}
+
+func (s *fileSink) WriteBatch(ctx context.Context, records []SinkRecord) error {
+	for _, rec := range records {
+		s.file.appendLine(rec.Line)
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error { return s.file.close() }
+
+// lokiSink pushes batches to the Loki push API as one stream.
+type lokiSink struct {
+	cfg    SinkConfig
+	url    string
+	client *http.Client
+}
+
+func newLokiSink(cfg SinkConfig) (*lokiSink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("sink %s: loki url is required", cfg.Name)
+	}
+	url := strings.TrimSuffix(cfg.URL, "/")
+	if !strings.HasSuffix(url, "/loki/api/v1/push") {
+		url += "/loki/api/v1/push"
+	}
+	if len(cfg.Labels) == 0 {
+		cfg.Labels = map[string]string{"job": "stackyrd"}
+	}
+	return &lokiSink{cfg: cfg, url: url, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) WriteBatch(ctx context.Context, records []SinkRecord) error {
+	// Loki indexes labels, so the level becomes one stream per level
+	streams := make(map[zerolog.Level]*lokiStream)
+	var order []zerolog.Level
+	for _, rec := range records {
+		stream, ok := streams[rec.Level]
+		if !ok {
+			labels := make(map[string]string, len(s.cfg.Labels)+1)
+			for k, v := range s.cfg.Labels {
+				labels[k] = v
+			}
+			labels["level"] = rec.Level.String()
+			stream = &lokiStream{Stream: labels}
+			streams[rec.Level] = stream
+			order = append(order, rec.Level)
+		}
+		line := strings.TrimRight(string(rec.Line), "\n")
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(rec.Time.UnixNano(), 10), line})
+	}
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, level := range order {
+		payload.Streams = append(payload.Streams, streams[level])
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.cfg.TenantID)
+	}
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("loki push returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *lokiSink) Close() error { return nil }
+
+// syslogSink sends RFC 5424 messages over UDP, TCP or a unix socket. It is
+// implemented on net directly so it also works where log/syslog does not.
+type syslogSink struct {
+	cfg      SinkConfig
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogSink(cfg SinkConfig) (*syslogSink, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("sink %s: syslog address is required", cfg.Name)
+	}
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+	if cfg.Tag == "" {
+		cfg.Tag = "stackyrd"
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = 16 // local0
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &syslogSink{cfg: cfg, hostname: hostname}, nil
+}
+
+// syslogSeverity maps zerolog levels to RFC 5424 severities.
+func syslogSeverity(level zerolog.Level) int {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return 7
+	case zerolog.WarnLevel:
+		return 4
+	case zerolog.ErrorLevel:
+		return 3
+	case zerolog.FatalLevel:
+		return 2
+	case zerolog.PanicLevel:
+		return 0
+	default:
+		return 6
+	}
+}
+
+func (s *syslogSink) WriteBatch(ctx context.Context, records []SinkRecord) error {
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	for _, rec := range records {
+		msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s\n",
+			s.cfg.Facility*8+syslogSeverity(rec.Level),
+			rec.Time.Format(time.RFC3339Nano), s.hostname, s.cfg.Tag, os.Getpid(),
+			strings.TrimRight(string(rec.Line), "\n"))
+		if _, err := io.WriteString(s.conn, msg); err != nil {
+			// Reconnect on the next batch
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// kafkaSink produces every line to a topic. The producer is created on the
+// first batch so an unavailable cluster does not prevent startup.
+type kafkaSink struct {
+	cfg      SinkConfig
+	producer sarama.SyncProducer
+}
+
+func newKafkaSink(cfg SinkConfig) (*kafkaSink, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, fmt.Errorf("sink %s: kafka brokers and topic are required", cfg.Name)
+	}
+	return &kafkaSink{cfg: cfg}, nil
+}
+
+func (s *kafkaSink) WriteBatch(ctx context.Context, records []SinkRecord) error {
+	if s.producer == nil {
+		config := sarama.NewConfig()
+		config.Producer.Return.Successes = true
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+		config.Producer.Compression = sarama.CompressionSnappy
+		producer, err := sarama.NewSyncProducer(s.cfg.Brokers, config)
+		if err != nil {
+			return fmt.Errorf("failed to start kafka producer: %w", err)
+		}
+		s.producer = producer
+	}
+	msgs := make([]*sarama.ProducerMessage, len(records))
+	for i, rec := range records {
+		msgs[i] = &sarama.ProducerMessage{
+			Topic:     s.cfg.Topic,
+			Value:     sarama.ByteEncoder(bytes.TrimRight(rec.Line, "\n")),
+			Timestamp: rec.Time,
+		}
+	}
+	return s.producer.SendMessages(msgs)
+}
+
+func (s *kafkaSink) Close() error {
+	if s.producer == nil {
+		return nil
+	}
+	return s.producer.Close()
+}
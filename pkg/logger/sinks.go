@@ -0,0 +1,298 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Sink types supported by NewSinkSet.
+const (
+	SinkConsole = "console"
+	SinkFile    = "file"
+	SinkLoki    = "loki"
+	SinkSyslog  = "syslog"
+	SinkKafka   = "kafka"
+)
+
+// SinkConfig configures one log sink. Only the fields of the selected type
+// are used.
+type SinkConfig struct {
+	Name          string
+	Type          string
+	Level         string // minimum level, default "debug"
+	BufferSize    int    // queued lines before new ones are dropped
+	BatchSize     int
+	FlushInterval time.Duration
+
+	// console
+	Output string // "stdout" (default) or "stderr"
+	// file
+	Path      string
+	MaxSizeMB int
+	// loki
+	URL      string
+	Labels   map[string]string
+	TenantID string
+	Username string
+	Password string
+	// syslog
+	Network  string // "udp" (default), "tcp" or "unix"
+	Address  string
+	Tag      string
+	Facility int
+	// kafka
+	Brokers []string
+	Topic   string
+}
+
+// SinkRecord is one log line handed to a sink: the raw JSON event as
+// emitted by the logger plus its level.
+type SinkRecord struct {
+	Time  time.Time
+	Level zerolog.Level
+	Line  []byte
+}
+
+// SinkWriter delivers batches of records to a destination.
+type SinkWriter interface {
+	WriteBatch(ctx context.Context, records []SinkRecord) error
+	Close() error
+}
+
+// SinkStats reports the delivery state of a sink.
+type SinkStats struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Level     string `json:"level"`
+	Written   uint64 `json:"written"`
+	Dropped   uint64 `json:"dropped"`
+	Failed    uint64 `json:"failed"`
+	Queued    int    `json:"queued"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// bufferedSink queues records and flushes them to its writer in batches
+// from a single goroutine, so a slow destination never blocks logging.
+type bufferedSink struct {
+	cfg    SinkConfig
+	level  zerolog.Level
+	writer SinkWriter
+	queue  chan SinkRecord
+	done   chan struct{}
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	errMu   sync.Mutex
+	lastErr string
+}
+
+func newBufferedSink(cfg SinkConfig, writer SinkWriter) (*bufferedSink, error) {
+	level := zerolog.DebugLevel
+	if cfg.Level != "" {
+		parsed, err := zerolog.ParseLevel(strings.ToLower(cfg.Level))
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: invalid level %q", cfg.Name, cfg.Level)
+		}
+		level = parsed
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	s := &bufferedSink{
+		cfg:    cfg,
+		level:  level,
+		writer: writer,
+		queue:  make(chan SinkRecord, cfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *bufferedSink) enqueue(rec SinkRecord) {
+	if rec.Level < s.level {
+		return
+	}
+	select {
+	case s.queue <- rec:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *bufferedSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SinkRecord, 0, s.cfg.BatchSize)
+	for {
+		select {
+		case rec, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= s.cfg.BatchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			s.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (s *bufferedSink) flush(batch []SinkRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.writer.WriteBatch(ctx, batch); err != nil {
+		// Logging the failure would feed it back into this sink
+		s.failed.Add(uint64(len(batch)))
+		s.errMu.Lock()
+		s.lastErr = err.Error()
+		s.errMu.Unlock()
+		return
+	}
+	s.written.Add(uint64(len(batch)))
+}
+
+func (s *bufferedSink) close() error {
+	close(s.queue)
+	<-s.done
+	return s.writer.Close()
+}
+
+func (s *bufferedSink) stats() SinkStats {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return SinkStats{
+		Name:      s.cfg.Name,
+		Type:      s.cfg.Type,
+		Level:     s.level.String(),
+		Written:   s.written.Load(),
+		Dropped:   s.dropped.Load(),
+		Failed:    s.failed.Load(),
+		Queued:    len(s.queue),
+		LastError: s.lastErr,
+	}
+}
+
+// SinkSet fans every log event out to the configured sinks. It implements
+// zerolog.LevelWriter; attach it with Logger.WithSinks.
+type SinkSet struct {
+	mu     sync.RWMutex
+	sinks  []*bufferedSink
+	closed bool
+}
+
+// NewSinkSet creates and starts all sinks. An invalid sink closes the ones
+// already started and returns an error.
+func NewSinkSet(configs []SinkConfig) (*SinkSet, error) {
+	set := &SinkSet{}
+	for i, cfg := range configs {
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("%s-%d", cfg.Type, i)
+		}
+		writer, err := newSinkWriter(cfg)
+		if err == nil {
+			var sink *bufferedSink
+			if sink, err = newBufferedSink(cfg, writer); err == nil {
+				set.sinks = append(set.sinks, sink)
+				continue
+			}
+			writer.Close()
+		}
+		set.Close()
+		return nil, err
+	}
+	return set, nil
+}
+
+func newSinkWriter(cfg SinkConfig) (SinkWriter, error) {
+	switch cfg.Type {
+	case SinkConsole:
+		return newConsoleSink(cfg), nil
+	case SinkFile:
+		return newFileSink(cfg)
+	case SinkLoki:
+		return newLokiSink(cfg)
+	case SinkSyslog:
+		return newSyslogSink(cfg)
+	case SinkKafka:
+		return newKafkaSink(cfg)
+	default:
+		return nil, fmt.Errorf("sink %s: unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// Write implements io.Writer for callers without level information.
+func (s *SinkSet) Write(p []byte) (int, error) {
+	return s.WriteLevel(zerolog.InfoLevel, p)
+}
+
+// WriteLevel queues the event on every sink whose level admits it. The
+// buffer is copied because zerolog reuses it.
+func (s *SinkSet) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed || len(s.sinks) == 0 {
+		return len(p), nil
+	}
+	line := make([]byte, len(p))
+	copy(line, p)
+	rec := SinkRecord{Time: time.Now(), Level: level, Line: line}
+	for _, sink := range s.sinks {
+		sink.enqueue(rec)
+	}
+	return len(p), nil
+}
+
+// Stats returns delivery counters for every sink.
+func (s *SinkSet) Stats() []SinkStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := make([]SinkStats, 0, len(s.sinks))
+	for _, sink := range s.sinks {
+		stats = append(stats, sink.stats())
+	}
+	return stats
+}
+
+// Close flushes queued records and closes every sink.
+func (s *SinkSet) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	sinks := s.sinks
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, sink := range sinks {
+		if err := sink.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
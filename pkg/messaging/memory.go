@@ -0,0 +1,170 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryMaxRedeliveries bounds requeues so a failing handler can't spin forever.
+const memoryMaxRedeliveries = 5
+
+// MemoryBroker is an in-process Broker for development and tests. Messages
+// fan out to every group; within a group subscribers take turns.
+type MemoryBroker struct {
+	mu     sync.RWMutex
+	groups map[string]map[string]*memoryGroup // topic -> group -> subscribers
+	wg     sync.WaitGroup
+	seq    int
+	closed bool
+}
+
+type memoryGroup struct {
+	subs []*memorySubscription
+	next int
+}
+
+type memorySubscription struct {
+	broker  *MemoryBroker
+	topic   string
+	group   string
+	opts    SubscribeOptions
+	handler Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewMemoryBroker creates an in-process broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{groups: make(map[string]map[string]*memoryGroup)}
+}
+
+// BrokerType implements Broker.
+func (b *MemoryBroker) BrokerType() string {
+	return BrokerMemory
+}
+
+// PublishMessage delivers the message asynchronously to all subscribers.
+func (b *MemoryBroker) PublishMessage(ctx context.Context, msg Message) error {
+	msg = WithCorrelation(ctx, msg)
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrNotConnected
+	}
+	var targets []*memorySubscription
+	for _, g := range b.groups[msg.Topic] {
+		if len(g.subs) == 0 {
+			continue
+		}
+		targets = append(targets, g.subs[g.next%len(g.subs)])
+		g.next++
+	}
+	b.wg.Add(len(targets))
+	b.mu.Unlock()
+
+	for _, sub := range targets {
+		go func(sub *memorySubscription) {
+			defer b.wg.Done()
+			sub.deliver(msg, 0)
+		}(sub)
+	}
+	return nil
+}
+
+func (s *memorySubscription) deliver(msg Message, attempt int) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	var requeue bool
+	d := NewDelivery(msg, nil, func(r bool) error {
+		requeue = r
+		return nil
+	})
+	_ = Dispatch(s.ctx, d, s.opts, s.handler)
+	if requeue && attempt < memoryMaxRedeliveries {
+		s.deliver(msg, attempt+1)
+	}
+}
+
+// Subscribe implements Broker.
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string, opts SubscribeOptions, handler Handler) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrNotConnected
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := &memorySubscription{
+		broker:  b,
+		topic:   topic,
+		group:   opts.Group,
+		opts:    opts,
+		handler: handler,
+		ctx:     subCtx,
+		cancel:  cancel,
+	}
+	// Subscribers without a group each get their own copy
+	groupKey := opts.Group
+	if groupKey == "" {
+		b.seq++
+		groupKey = fmt.Sprintf("\x00%d", b.seq)
+		sub.group = groupKey
+	}
+	if b.groups[topic] == nil {
+		b.groups[topic] = make(map[string]*memoryGroup)
+	}
+	g := b.groups[topic][groupKey]
+	if g == nil {
+		g = &memoryGroup{}
+		b.groups[topic][groupKey] = g
+	}
+	g.subs = append(g.subs, sub)
+	return sub, nil
+}
+
+// Topic implements Subscription.
+func (s *memorySubscription) Topic() string {
+	return s.topic
+}
+
+// Unsubscribe implements Subscription.
+func (s *memorySubscription) Unsubscribe() error {
+	s.cancel()
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	g := s.broker.groups[s.topic][s.group]
+	if g == nil {
+		return nil
+	}
+	for i, sub := range g.subs {
+		if sub == s {
+			g.subs = append(g.subs[:i], g.subs[i+1:]...)
+			break
+		}
+	}
+	if len(g.subs) == 0 {
+		delete(s.broker.groups[s.topic], s.group)
+	}
+	return nil
+}
+
+// Wait blocks until all in-flight deliveries have been handled.
+func (b *MemoryBroker) Wait() {
+	b.wg.Wait()
+}
+
+// Close rejects further publishes and waits for in-flight deliveries.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.wg.Wait()
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package messaging provides a broker-agnostic publish/subscribe API.
+//
+// Broker managers in pkg/infrastructure (Kafka, NATS, RabbitMQ) implement
+// Broker, and the server exposes the one selected by `messaging.broker` in
+// config as the "messaging" dependency, so services never depend on a
+// concrete broker client.
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/logger"
+)
+
+// Supported broker types for `messaging.broker`.
+const (
+	BrokerKafka    = "kafka"
+	BrokerNATS     = "nats"
+	BrokerRabbitMQ = "rabbitmq"
+	BrokerMemory   = "memory"
+)
+
+var (
+	// ErrNotConnected is returned when the broker has no live connection.
+	ErrNotConnected = errors.New("messaging: broker not connected")
+	// ErrAlreadyAcked is returned when a delivery is acked or nacked twice.
+	ErrAlreadyAcked = errors.New("messaging: delivery already acknowledged")
+)
+
+// HeaderCorrelationID carries the correlation ID of the request that
+// published a message, so consumer logs can be tied back to it.
+const HeaderCorrelationID = "X-Correlation-ID"
+
+// Message is a broker-agnostic message. Topic maps to a Kafka topic, a NATS
+// subject or a RabbitMQ routing key.
+type Message struct {
+	Topic     string            `json:"topic"`
+	Key       []byte            `json:"key,omitempty"`
+	Value     []byte            `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Delivery is a received message together with its acknowledgement hooks.
+type Delivery struct {
+	Message
+
+	mu     sync.Mutex
+	done   bool
+	ackFn  func() error
+	nackFn func(requeue bool) error
+}
+
+// NewDelivery wraps a message with broker specific ack/nack callbacks.
+// Either callback may be nil for brokers without acknowledgements.
+func NewDelivery(msg Message, ack func() error, nack func(requeue bool) error) *Delivery {
+	return &Delivery{Message: msg, ackFn: ack, nackFn: nack}
+}
+
+// Ack confirms the message was processed.
+func (d *Delivery) Ack() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done {
+		return ErrAlreadyAcked
+	}
+	d.done = true
+	if d.ackFn == nil {
+		return nil
+	}
+	return d.ackFn()
+}
+
+// Nack rejects the message. With requeue the broker redelivers it where
+// supported; otherwise it is dropped or dead-lettered.
+func (d *Delivery) Nack(requeue bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done {
+		return ErrAlreadyAcked
+	}
+	d.done = true
+	if d.nackFn == nil {
+		return nil
+	}
+	return d.nackFn(requeue)
+}
+
+// Acknowledged reports whether Ack or Nack has been called.
+func (d *Delivery) Acknowledged() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.done
+}
+
+// Handler processes a delivery.
+type Handler func(ctx context.Context, d *Delivery) error
+
+// SubscribeOptions controls how a subscription consumes messages.
+type SubscribeOptions struct {
+	// Group shares messages between subscribers: Kafka consumer group, NATS
+	// queue group or RabbitMQ queue name. Empty uses the broker default.
+	Group string
+	// ManualAck leaves acknowledgement to the handler. Otherwise a delivery
+	// is acked when the handler returns nil and nacked with requeue on error.
+	ManualAck bool
+}
+
+// Subscription is an active subscription.
+type Subscription interface {
+	Topic() string
+	Unsubscribe() error
+}
+
+// Broker is implemented by every message broker manager.
+type Broker interface {
+	// BrokerType returns one of the Broker* constants.
+	BrokerType() string
+	// PublishMessage publishes a message and returns once the broker has accepted it.
+	PublishMessage(ctx context.Context, msg Message) error
+	// Subscribe starts consuming a topic in the background.
+	Subscribe(ctx context.Context, topic string, opts SubscribeOptions, handler Handler) (Subscription, error)
+}
+
+// WithCorrelation returns msg with the correlation ID of ctx in its headers,
+// unless the publisher set one. Broker implementations call it on publish.
+func WithCorrelation(ctx context.Context, msg Message) Message {
+	id := logger.CorrelationID(ctx)
+	if id == "" || msg.Headers[HeaderCorrelationID] != "" {
+		return msg
+	}
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderCorrelationID] = id
+	msg.Headers = headers
+	return msg
+}
+
+// Dispatch invokes handler and applies the automatic ack semantics described
+// on SubscribeOptions. Broker implementations call it for every delivery.
+// The handler context carries the correlation ID of the message, if any.
+func Dispatch(ctx context.Context, d *Delivery, opts SubscribeOptions, handler Handler) error {
+	if id := d.Headers[HeaderCorrelationID]; id != "" {
+		ctx = logger.WithCorrelationID(ctx, id)
+	}
+	err := handler(ctx, d)
+	if opts.ManualAck || d.Acknowledged() {
+		return err
+	}
+	if err != nil {
+		_ = d.Nack(true)
+		return err
+	}
+	return d.Ack()
+}
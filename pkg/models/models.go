@@ -0,0 +1,186 @@
+// Package models is the registry of the GORM models services own. A
+// service registers its models for a Postgres connection in init, next to
+// its factory:
+//
+//	models.Register("tasks_service", models.DefaultConnection, &Task{})
+//
+// The server migrates the models of the enabled services at boot, on every
+// reload, on `stackyrd migrate` and for tenants provisioned at runtime, and
+// keeps the outcome for the monitoring API (Applied).
+package models
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultConnection registers models on the default Postgres
+	// connection.
+	DefaultConnection = ""
+	// AllConnections registers models on every Postgres connection, for
+	// the tables each tenant database has.
+	AllConnections = "*"
+)
+
+// Registration is the models a service registered for a connection.
+type Registration struct {
+	Service    string        // registry key of the service, e.g. "tasks_service"
+	Connection string        // connection name, DefaultConnection or AllConnections
+	Models     []interface{} // pointers to the model structs, in migration order
+}
+
+// Column is a column of a migrated model.
+type Column struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	PrimaryKey bool   `json:"primary_key,omitempty"`
+}
+
+// Result is the outcome of migrating one model on one connection.
+type Result struct {
+	Service    string        `json:"service"`
+	Connection string        `json:"connection"`
+	Model      string        `json:"model"` // Go type, e.g. "modules.Task"
+	Table      string        `json:"table"`
+	Columns    []Column      `json:"columns"`
+	Duration   time.Duration `json:"-"`
+	DurationMS int64         `json:"duration_ms"`
+	Err        error         `json:"-"`
+	Error      string        `json:"error,omitempty"`
+	MigratedAt time.Time     `json:"migrated_at"`
+}
+
+var (
+	mu            sync.Mutex
+	registrations []Registration
+	// applied holds the last result per connection and table
+	applied = make(map[string]Result)
+)
+
+// Register registers models of service for connection. Models are
+// migrated in the order given, so register the models others reference
+// first.
+func Register(service, connection string, models ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	registrations = append(registrations, Registration{Service: service, Connection: connection, Models: models})
+}
+
+// Registered returns the registrations by service, then connection.
+func Registered() []Registration {
+	mu.Lock()
+	list := append([]Registration(nil), registrations...)
+	mu.Unlock()
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Service != list[j].Service {
+			return list[i].Service < list[j].Service
+		}
+		return list[i].Connection < list[j].Connection
+	})
+	return list
+}
+
+// Migrate runs AutoMigrate for the models of the services enabled reports
+// (every service when nil) on dbs, the Postgres connections by name;
+// defaultName is the connection DefaultConnection stands for. Models of a
+// connection missing from dbs are skipped, and a failing model does not
+// stop the others.
+func Migrate(ctx context.Context, dbs map[string]*gorm.DB, defaultName string, enabled func(service string) bool) []Result {
+	var results []Result
+	for _, reg := range Registered() {
+		if enabled != nil && !enabled(reg.Service) {
+			continue
+		}
+		for _, name := range connections(reg.Connection, dbs, defaultName) {
+			for _, model := range reg.Models {
+				results = append(results, migrate(ctx, dbs[name], reg.Service, name, model))
+			}
+		}
+	}
+
+	mu.Lock()
+	for _, result := range results {
+		applied[result.Connection+"\x00"+result.Table+"\x00"+result.Model] = result
+	}
+	mu.Unlock()
+	return results
+}
+
+// Applied returns the last result of every model migrated so far, by
+// connection, then table.
+func Applied() []Result {
+	mu.Lock()
+	list := make([]Result, 0, len(applied))
+	for _, result := range applied {
+		list = append(list, result)
+	}
+	mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Connection != list[j].Connection {
+			return list[i].Connection < list[j].Connection
+		}
+		return list[i].Table < list[j].Table
+	})
+	return list
+}
+
+// connections returns the names of dbs a registration for connection
+// applies to.
+func connections(connection string, dbs map[string]*gorm.DB, defaultName string) []string {
+	switch connection {
+	case AllConnections:
+		names := make([]string, 0, len(dbs))
+		for name := range dbs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	case DefaultConnection:
+		connection = defaultName
+	}
+	if _, ok := dbs[connection]; !ok {
+		return nil
+	}
+	return []string{connection}
+}
+
+func migrate(ctx context.Context, db *gorm.DB, service, connection string, model interface{}) Result {
+	result := Result{Service: service, Connection: connection, Model: ModelName(model), MigratedAt: time.Now()}
+	stmt := &gorm.Statement{DB: db}
+	if result.Err = stmt.Parse(model); result.Err == nil {
+		result.Table = stmt.Schema.Table
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			result.Columns = append(result.Columns, Column{Name: field.DBName, Type: db.Dialector.DataTypeOf(field), PrimaryKey: field.PrimaryKey})
+		}
+		start := time.Now()
+		result.Err = db.WithContext(ctx).AutoMigrate(model)
+		result.Duration = time.Since(start)
+	}
+	result.DurationMS = result.Duration.Milliseconds()
+	if result.Err != nil {
+		result.Error = result.Err.Error()
+	}
+	return result
+}
+
+// ModelName returns the Go type of model without the pointer, e.g.
+// "modules.Task".
+func ModelName(model interface{}) string {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return "<nil>"
+	}
+	return t.String()
+}
@@ -0,0 +1,293 @@
+package monitorclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/querybook"
+)
+
+// Status is GET /status. Component statuses differ per component and are
+// left as decoded JSON.
+type Status struct {
+	App struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Env     string `json:"env"`
+	} `json:"app"`
+	StartedAt      time.Time                         `json:"started_at"`
+	UptimeSeconds  int64                             `json:"uptime_seconds"`
+	Infrastructure map[string]map[string]interface{} `json:"infrastructure"`
+	Clock          map[string]interface{}            `json:"clock,omitempty"`
+	GRPC           map[string]interface{}            `json:"grpc,omitempty"`
+	Config         struct {
+		Fingerprint string                 `json:"fingerprint"`
+		Drift       map[string]interface{} `json:"drift,omitempty"`
+		Remote      map[string]interface{} `json:"remote,omitempty"`
+	} `json:"config"`
+}
+
+// Connected reports whether the component name reports itself connected.
+func (s Status) Connected(name string) bool {
+	connected, _ := s.Infrastructure[name]["connected"].(bool)
+	return connected
+}
+
+// Status returns the application and infrastructure status.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.Get(ctx, "/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// LogQuery filters LogHistory; zero fields are unset.
+type LogQuery struct {
+	Query   string // substring
+	Level   string // minimum level
+	From    time.Time
+	To      time.Time
+	Page    int
+	PerPage int
+}
+
+// LogHistory searches past log lines, newest first, returning one page.
+func (c *Client) LogHistory(ctx context.Context, q LogQuery) ([]logger.LogEntry, *Meta, error) {
+	values := url.Values{"q": {q.Query}, "level": {q.Level}}
+	if !q.From.IsZero() {
+		values.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		values.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Page > 0 {
+		values.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PerPage > 0 {
+		values.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	var entries []logger.LogEntry
+	meta, err := c.call(ctx, request{method: http.MethodGet, path: "/logs/history" + query(values)}, &entries)
+	return entries, meta, err
+}
+
+// Config returns the running configuration with secrets redacted.
+func (c *Client) Config(ctx context.Context) (map[string]interface{}, error) {
+	var cfg map[string]interface{}
+	err := c.Get(ctx, "/config", &cfg)
+	return cfg, err
+}
+
+// EffectiveConfig is GET /config/effective.
+type EffectiveConfig struct {
+	Environment string                `json:"environment"`
+	Files       []string              `json:"files"`
+	Keys        []config.EffectiveKey `json:"keys"`
+}
+
+// EffectiveConfig returns the effective config keys with the layer each
+// came from, optionally below prefix (e.g. "postgres") or from one source
+// (e.g. "default").
+func (c *Client) EffectiveConfig(ctx context.Context, prefix, source string) (*EffectiveConfig, error) {
+	var effective EffectiveConfig
+	if err := c.Get(ctx, "/config/effective"+query(url.Values{"prefix": {prefix}, "source": {source}}), &effective); err != nil {
+		return nil, err
+	}
+	return &effective, nil
+}
+
+// ConfigSchema returns the JSON Schema of the config file, or of one
+// section when section is set.
+func (c *Client) ConfigSchema(ctx context.Context, section string) (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	_, err := c.call(ctx, request{method: http.MethodGet, path: "/config/schema" + query(url.Values{"section": {section}}), raw: true}, &schema)
+	return schema, err
+}
+
+// ConfigSections lists the top-level sections of the config file.
+func (c *Client) ConfigSections(ctx context.Context) ([]config.SectionInfo, error) {
+	var sections []config.SectionInfo
+	err := c.Get(ctx, "/config/sections", &sections)
+	return sections, err
+}
+
+// ConfigSection reads one section of the config file at a dotted or
+// slashed path, e.g. "postgres.connections.0", with secrets masked.
+func (c *Client) ConfigSection(ctx context.Context, path string) (*config.Section, error) {
+	var section config.Section
+	if err := c.Get(ctx, "/config/section/"+sectionPath(path), &section); err != nil {
+		return nil, err
+	}
+	return &section, nil
+}
+
+// SavedSection is the outcome of SaveConfigSection.
+type SavedSection struct {
+	Section         config.Section `json:"section"`
+	RestartRequired bool           `json:"restart_required"`
+}
+
+// SaveConfigSection replaces one section of the config file. version is
+// the Version read with ConfigSection; IsConflict(err) tells that the
+// section changed since.
+func (c *Client) SaveConfigSection(ctx context.Context, path string, value interface{}, version string) (*SavedSection, error) {
+	var saved SavedSection
+	body := map[string]interface{}{"value": value, "version": version}
+	if err := c.Do(ctx, http.MethodPut, "/config/section/"+sectionPath(path), body, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// sectionPath escapes the segments of a config section path.
+func sectionPath(path string) string {
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '.' || r == '/' })
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// Queries lists the saved queries visible to the caller, of one engine
+// when engine is set.
+func (c *Client) Queries(ctx context.Context, engine string) ([]querybook.SavedQuery, error) {
+	var queries []querybook.SavedQuery
+	err := c.Get(ctx, "/queries"+query(url.Values{"engine": {engine}}), &queries)
+	return queries, err
+}
+
+// Query returns the saved query called name.
+func (c *Client) Query(ctx context.Context, name string) (*querybook.SavedQuery, error) {
+	var saved querybook.SavedQuery
+	if err := c.Get(ctx, "/queries/"+url.PathEscape(name), &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// CreateQuery saves a new query.
+func (c *Client) CreateQuery(ctx context.Context, q querybook.SavedQuery) (*querybook.SavedQuery, error) {
+	var saved querybook.SavedQuery
+	if err := c.Do(ctx, http.MethodPost, "/queries", q, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// UpdateQuery replaces the saved query called name.
+func (c *Client) UpdateQuery(ctx context.Context, name string, q querybook.SavedQuery) (*querybook.SavedQuery, error) {
+	var saved querybook.SavedQuery
+	if err := c.Do(ctx, http.MethodPut, "/queries/"+url.PathEscape(name), q, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteQuery deletes the saved query called name and its report.
+func (c *Client) DeleteQuery(ctx context.Context, name string) error {
+	return c.Do(ctx, http.MethodDelete, "/queries/"+url.PathEscape(name), nil, nil)
+}
+
+// RunQuery runs the saved query called name with params, on connection
+// instead of the saved one when set.
+func (c *Client) RunQuery(ctx context.Context, name, connection string, params map[string]interface{}) (*querybook.Result, error) {
+	var result querybook.Result
+	body := map[string]interface{}{"connection": connection, "params": params}
+	if err := c.Do(ctx, http.MethodPost, "/queries/"+url.PathEscape(name)+"/run", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryReports returns the last report runs of a saved query, newest
+// first; limit 0 uses the server's default.
+func (c *Client) QueryReports(ctx context.Context, name string, limit int) ([]querybook.ReportRun, error) {
+	values := url.Values{}
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+	var runs []querybook.ReportRun
+	err := c.Get(ctx, "/queries/"+url.PathEscape(name)+"/reports"+query(values), &runs)
+	return runs, err
+}
+
+// QueryHistory returns the console queries of the caller, or of user for
+// admins, newest first; limit 0 uses the server's default.
+func (c *Client) QueryHistory(ctx context.Context, user string, limit int) ([]querybook.HistoryEntry, error) {
+	values := url.Values{"user": {user}}
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+	var entries []querybook.HistoryEntry
+	err := c.Get(ctx, "/query-history"+query(values), &entries)
+	return entries, err
+}
+
+// CronJob is a job of the cron scheduler.
+type CronJob struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	LastRun  time.Time `json:"last_run"`
+	NextRun  time.Time `json:"next_run"`
+}
+
+// CronJobs lists the jobs of the cron scheduler, read from the cron
+// section of /bootstrap.
+func (c *Client) CronJobs(ctx context.Context) ([]CronJob, error) {
+	var bootstrap struct {
+		Sections struct {
+			Cron struct {
+				Data  []CronJob `json:"data"`
+				Error string    `json:"error"`
+			} `json:"cron"`
+		} `json:"sections"`
+	}
+	if err := c.Get(ctx, "/bootstrap?sections=cron", &bootstrap); err != nil {
+		return nil, err
+	}
+	if cron := bootstrap.Sections.Cron; cron.Error != "" {
+		return nil, fmt.Errorf("monitoring API cron jobs: %s", cron.Error)
+	}
+	return bootstrap.Sections.Cron.Data, nil
+}
+
+// ServiceHealth is the health check result of a service.
+type ServiceHealth struct {
+	Status    string    `json:"status"` // ok, down, degraded, disabled or unchecked
+	Error     string    `json:"error,omitempty"`
+	LatencyMS float64   `json:"latency_ms,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Service is one service of GET /services.
+type Service struct {
+	Name         string        `json:"name"`
+	WireName     string        `json:"wire_name"`
+	Enabled      bool          `json:"enabled"`
+	Endpoints    []string      `json:"endpoints"`
+	Dependencies []string      `json:"dependencies"`
+	Needs        []string      `json:"needs,omitempty"`
+	Deferred     bool          `json:"deferred"` // waiting for its needs, without routes
+	Health       ServiceHealth `json:"health"`
+}
+
+// Services lists the services with their health, checked by the server
+// on every call.
+func (c *Client) Services(ctx context.Context) ([]Service, error) {
+	var list struct {
+		Services []Service `json:"services"`
+	}
+	if err := c.Get(ctx, "/services", &list); err != nil {
+		return nil, err
+	}
+	return list.Services, nil
+}
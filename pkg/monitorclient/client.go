@@ -0,0 +1,351 @@
+// Package monitorclient is a Go client of the monitoring API (/api): typed
+// calls for status, logs, config, saved queries and cron, with API key,
+// token or password authentication and retries of idempotent requests.
+// Automation and remote tools use it instead of raw HTTP calls.
+package monitorclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/resilience"
+)
+
+// Options configures a Client. Set one of APIKey, Token or Username and
+// Password; without any, requests get the API's default role.
+type Options struct {
+	APIKey   string // sent as X-API-Key
+	Token    string // JWT sent as a bearer token
+	Username string // logged in at /auth/login for a token, again when it expires
+	Password string
+
+	Timeout    time.Duration // per request, 30s by default; long polls add their own wait
+	MaxRetries int           // retries of idempotent requests, 2 by default, negative for none
+	RetryDelay time.Duration // first retry delay, doubled per retry, 200ms by default
+	HTTPClient *http.Client  // replaces the default client, e.g. for TLS settings
+}
+
+// Client calls the monitoring API of one stackyrd instance. It is safe
+// for concurrent use.
+type Client struct {
+	base    string
+	opts    Options
+	http    *http.Client
+	timeout time.Duration
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New returns a client of the instance at baseURL, e.g.
+// http://localhost:8080; the /api prefix is added unless baseURL has a
+// path already.
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid monitoring API URL %q", baseURL)
+	}
+	if u.Path == "" {
+		u.Path = "/api"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 2
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 200 * time.Millisecond
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		// Timeouts are per request through the context, so long polls can
+		// wait longer
+		client = &http.Client{}
+	}
+	return &Client{base: u.String(), opts: opts, http: client, timeout: opts.Timeout, token: opts.Token}, nil
+}
+
+// BaseURL is the API root requests are sent to.
+func (c *Client) BaseURL() string {
+	return c.base
+}
+
+// APIError is an error response of the API.
+type APIError struct {
+	Status  int    // HTTP status
+	Code    string // error code, e.g. NOT_FOUND or RESTART_IN_PROGRESS
+	Message string
+	Details map[string]interface{}
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("monitoring API: status %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("monitoring API: %s (%d): %s", e.Code, e.Status, e.Message)
+}
+
+// IsStatus reports whether err is an APIError with the HTTP status.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
+
+// IsNotFound reports whether err is a 404, e.g. for a feature that is
+// disabled on the server.
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is a 409, e.g. a config section that
+// changed since it was read.
+func IsConflict(err error) bool {
+	return IsStatus(err, http.StatusConflict)
+}
+
+// envelope is the body of every JSON response of the API.
+type envelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Meta    *Meta           `json:"meta"`
+	Error   *struct {
+		Code    string                 `json:"code"`
+		Message string                 `json:"message"`
+		Details map[string]interface{} `json:"details"`
+	} `json:"error"`
+
+	body []byte
+}
+
+// Meta is the pagination metadata of list responses.
+type Meta struct {
+	Page       int                    `json:"page"`
+	PerPage    int                    `json:"per_page"`
+	Total      int64                  `json:"total"`
+	TotalPages int                    `json:"total_pages"`
+	Extra      map[string]interface{} `json:"extra,omitempty"`
+}
+
+// request is one API call.
+type request struct {
+	method string
+	path   string // below the API root, with its query string
+	body   interface{}
+	header http.Header
+	wait   time.Duration // added to the timeout for long polls
+	raw    bool          // decode the whole body, for responses without the envelope
+}
+
+// Get calls GET path (e.g. "/jobs?status=failed") and decodes the data of
+// the response into out, for endpoints without a typed call.
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	_, err := c.call(ctx, request{method: http.MethodGet, path: path}, out)
+	return err
+}
+
+// Do calls any endpoint with a JSON body (nil for none) and decodes the
+// data of the response into out (nil to discard it).
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	_, err := c.call(ctx, request{method: method, path: path, body: body}, out)
+	return err
+}
+
+// call sends req, retrying idempotent methods on network errors and 502,
+// 503 and 504, logs in again once when a password login expired, and
+// decodes the data into out. It returns the pagination metadata, if any.
+func (c *Client) call(ctx context.Context, req request, out interface{}) (*Meta, error) {
+	retries := 0
+	if idempotent(req.method) && c.opts.MaxRetries > 0 {
+		retries = c.opts.MaxRetries
+	}
+	relogged := false
+	if c.opts.Username != "" && c.currentToken() == "" {
+		if err := c.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var meta *Meta
+	err := resilience.RetryWithContext(ctx, func() error {
+		env, err := c.send(ctx, req)
+		if IsStatus(err, http.StatusUnauthorized) && c.opts.Username != "" && !relogged {
+			relogged = true
+			if err = c.Login(ctx); err == nil {
+				env, err = c.send(ctx, req)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		meta = env.Meta
+		data := env.Data
+		if req.raw {
+			data = env.body
+		}
+		if out != nil && len(data) > 0 && string(data) != "null" {
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("monitoring API %s %s: decode response: %w", req.method, req.path, err)
+			}
+		}
+		return nil
+	}, resilience.RetryConfig{
+		MaxAttempts:   retries + 1,
+		InitialDelay:  c.opts.RetryDelay,
+		MaxDelay:      10 * time.Second,
+		BackoffFactor: 2,
+		Jitter:        true,
+		RetryIf:       resilience.RetryIfRetryable(),
+	})
+	return meta, unwrapRetryable(err)
+}
+
+// unwrapRetryable returns the error inside a resilience.RetryableError.
+func unwrapRetryable(err error) error {
+	var retryable *resilience.RetryableError
+	if errors.As(err, &retryable) {
+		return retryable.Err
+	}
+	return err
+}
+
+// send makes one attempt of req. Errors worth retrying are wrapped as
+// resilience.RetryableError.
+func (c *Client) send(ctx context.Context, req request) (*envelope, error) {
+	var body io.Reader
+	if req.body != nil {
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, c.timeout+req.wait)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(reqCtx, req.method, c.base+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	c.authorize(httpReq)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		err = fmt.Errorf("monitoring API %s %s: %w", req.method, req.path, err)
+		if ctx.Err() != nil {
+			// Canceled by the caller, not timed out
+			return nil, err
+		}
+		return nil, resilience.NewRetryableError(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resilience.NewRetryableError(fmt.Errorf("monitoring API %s %s: %w", req.method, req.path, err))
+	}
+	var env envelope
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &env); err != nil {
+			if resp.StatusCode < 300 {
+				return nil, fmt.Errorf("monitoring API %s %s: invalid response: %w", req.method, req.path, err)
+			}
+			// e.g. the HTML error page of a proxy
+			env = envelope{}
+		}
+	}
+	env.body = data
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		if env.Error != nil {
+			apiErr.Code, apiErr.Message, apiErr.Details = env.Error.Code, env.Error.Message, env.Error.Details
+		}
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, resilience.NewRetryableError(apiErr)
+		}
+		return nil, apiErr
+	}
+	return &env, nil
+}
+
+// authorize adds the credentials of the client to req.
+func (c *Client) authorize(req *http.Request) {
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+		return
+	}
+	if token := c.currentToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Login exchanges Username and Password for a token. Calls log in on their
+// own when they need to; calling Login first surfaces bad credentials
+// early.
+func (c *Client) Login(ctx context.Context) error {
+	if c.opts.Username == "" {
+		return errors.New("monitoring API login: no username configured")
+	}
+	env, err := c.send(ctx, request{
+		method: http.MethodPost,
+		path:   "/auth/login",
+		body:   map[string]string{"username": c.opts.Username, "password": c.opts.Password},
+	})
+	if err != nil {
+		return unwrapRetryable(err)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(env.Data, &login); err != nil || login.Token == "" {
+		return errors.New("monitoring API login: no token in response")
+	}
+	c.mu.Lock()
+	c.token = login.Token
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) currentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// query encodes the non-empty values as a query string, "" when there are
+// none.
+func query(values url.Values) string {
+	for k, v := range values {
+		if len(v) == 0 || v[0] == "" {
+			delete(values, k)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
@@ -0,0 +1,133 @@
+package monitorclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"stackyrd/pkg/logger"
+)
+
+// pollWait is how long a long poll waits on the server for new events,
+// below the server's cap and the idle timeout of most proxies.
+const pollWait = 25 * time.Second
+
+// maxFollowBackoff caps the wait between polls after the server could not
+// be reached, e.g. while it restarts.
+const maxFollowBackoff = 10 * time.Second
+
+// pollPage is one long poll of a stream (/logs/poll, /status/poll, ...).
+type pollPage struct {
+	Events []json.RawMessage `json:"events"`
+	Cursor uint64            `json:"cursor"`
+	Missed bool              `json:"missed"`
+}
+
+// poll waits up to wait for events of the stream at path after since.
+func (c *Client) poll(ctx context.Context, path string, since uint64, wait time.Duration) (*pollPage, error) {
+	values := url.Values{"timeout": {strconv.Itoa(int(wait.Seconds()))}}
+	if since > 0 {
+		values.Set("since", strconv.FormatUint(since, 10))
+	}
+	var page pollPage
+	if _, err := c.call(ctx, request{method: http.MethodGet, path: path + query(values), wait: wait}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// follow long polls the stream at path from since, passing every page to
+// handle, until ctx is done or handle or the API fails. Unreachable
+// servers and 5xx answers are waited out.
+func (c *Client) follow(ctx context.Context, path string, since uint64, handle func(*pollPage) error) error {
+	backoff := c.opts.RetryDelay
+	for {
+		page, err := c.poll(ctx, path, since, pollWait)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Status < http.StatusInternalServerError {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxFollowBackoff)
+			continue
+		}
+		backoff = c.opts.RetryDelay
+		if len(page.Events) == 0 && !page.Missed {
+			since = page.Cursor
+			continue
+		}
+		if err := handle(page); err != nil {
+			return err
+		}
+		since = page.Cursor
+	}
+}
+
+// LogBatch is the log lines of one poll of the log stream.
+type LogBatch struct {
+	Entries []logger.LogEntry
+	Cursor  uint64 // since of the next poll
+	Missed  bool   // lines after the previous cursor left the server's buffer unread
+}
+
+// Logs returns the log lines after since (0 for the whole buffer),
+// waiting up to wait for one when there are none.
+func (c *Client) Logs(ctx context.Context, since uint64, wait time.Duration) (*LogBatch, error) {
+	page, err := c.poll(ctx, "/logs/poll", since, wait)
+	if err != nil {
+		return nil, err
+	}
+	return logBatch(page)
+}
+
+// FollowLogs passes the log lines after since to handle as they are
+// logged, until ctx is done or handle returns an error. It keeps following
+// across server restarts.
+func (c *Client) FollowLogs(ctx context.Context, since uint64, handle func(LogBatch) error) error {
+	return c.follow(ctx, "/logs/poll", since, func(page *pollPage) error {
+		batch, err := logBatch(page)
+		if err != nil {
+			return err
+		}
+		return handle(*batch)
+	})
+}
+
+func logBatch(page *pollPage) (*LogBatch, error) {
+	batch := &LogBatch{Entries: make([]logger.LogEntry, len(page.Events)), Cursor: page.Cursor, Missed: page.Missed}
+	for i, raw := range page.Events {
+		if err := json.Unmarshal(raw, &batch.Entries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// FollowStatus passes the status to handle now and whenever it changes,
+// until ctx is done or handle returns an error.
+func (c *Client) FollowStatus(ctx context.Context, handle func(*Status) error) error {
+	return c.follow(ctx, "/status/poll", 0, func(page *pollPage) error {
+		for _, raw := range page.Events {
+			var status Status
+			if err := json.Unmarshal(raw, &status); err != nil {
+				return err
+			}
+			if err := handle(&status); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,431 @@
+// Package outbox implements the transactional outbox: services insert the
+// events of a change into a Postgres table in the same transaction as the
+// change, and a relay publishes them to the message broker afterwards. An
+// event is published at least once, and only if its transaction committed.
+//
+//	tx, _ := db.BeginTx(ctx, nil)
+//	tx.ExecContext(ctx, `UPDATE orders SET status = 'paid' WHERE id = $1`, id)
+//	box.Enqueue(ctx, tx, messaging.Message{Topic: "orders.paid", Key: []byte(id), Value: payload})
+//	tx.Commit()
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTable holds the events when no table is configured.
+const DefaultTable = "stackyrd_outbox"
+
+var (
+	// ErrNotConnected is returned while the database is not connected.
+	ErrNotConnected = errors.New("outbox: database is not connected")
+	// ErrNoBroker is returned by Relay while no broker is available.
+	ErrNoBroker = errors.New("outbox: no message broker")
+)
+
+const sqlTimeout = 10 * time.Second
+
+// Execer runs a statement; *sql.Tx, *sql.Conn and *sql.DB implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Options configures an Outbox.
+type Options struct {
+	Table     string        // DefaultTable when empty
+	Interval  time.Duration // between relay passes
+	BatchSize int           // events read per query
+	Retention time.Duration // how long published events are kept; 0 deletes them once published
+	Logger    *logger.Logger
+}
+
+// Outbox stores events in a Postgres table, created on first use, and
+// relays them to a broker in insertion order. The connection and broker
+// are looked up on every call as they may connect after the outbox starts.
+// Instances sharing the table take turns: one relays at a time.
+type Outbox struct {
+	db     func() *sql.DB
+	broker func() messaging.Broker
+	opts   Options
+
+	mu    sync.Mutex
+	ready bool
+
+	relayMu     sync.Mutex // one pass at a time
+	published   atomic.Int64
+	failed      atomic.Int64
+	statsMu     sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+	lastRelayAt time.Time
+
+	trigger   chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// New creates an outbox in the database db returns and relaying to the
+// broker broker returns, either nil while not connected.
+func New(db func() *sql.DB, broker func() messaging.Broker, opts Options) *Outbox {
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 2 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.NewQuiet(false, nil)
+	}
+	return &Outbox{
+		db:      db,
+		broker:  broker,
+		opts:    opts,
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Name returns the display name of the component
+func (o *Outbox) Name() string {
+	return "Outbox"
+}
+
+// Table returns the name of the table holding the events.
+func (o *Outbox) Table() string {
+	return o.opts.Table
+}
+
+func (o *Outbox) conn(ctx context.Context) (*sql.DB, error) {
+	db := o.db()
+	if db == nil {
+		return nil, ErrNotConnected
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.ready {
+		_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+o.opts.Table+` (
+			id BIGSERIAL PRIMARY KEY,
+			topic TEXT NOT NULL,
+			key BYTEA,
+			value BYTEA NOT NULL,
+			headers JSONB,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			published_at TIMESTAMPTZ,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT
+		)`)
+		if err == nil {
+			_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS `+o.opts.Table+`_pending
+				ON `+o.opts.Table+` (id) WHERE published_at IS NULL`)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", o.opts.Table, err)
+		}
+		o.ready = true
+	}
+	return db, nil
+}
+
+// Enqueue inserts msgs through tx, the transaction of the change they
+// describe, so they are published only if it commits.
+func (o *Outbox) Enqueue(ctx context.Context, tx Execer, msgs ...messaging.Message) error {
+	if _, err := o.conn(ctx); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		msg = messaging.WithCorrelation(ctx, msg)
+		var headers []byte
+		if len(msg.Headers) > 0 {
+			var err error
+			if headers, err = json.Marshal(msg.Headers); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO `+o.opts.Table+` (topic, key, value, headers) VALUES ($1, $2, $3, $4)`,
+			msg.Topic, msg.Key, msg.Value, nullJSON(headers))
+		if err != nil {
+			return fmt.Errorf("failed to enqueue %s event: %w", msg.Topic, err)
+		}
+	}
+	o.notify()
+	return nil
+}
+
+// EnqueueORM inserts msgs through the GORM transaction tx:
+//
+//	orm.Transaction(func(tx *gorm.DB) error {
+//		if err := tx.Save(&order).Error; err != nil {
+//			return err
+//		}
+//		return box.EnqueueORM(tx, event)
+//	})
+func (o *Outbox) EnqueueORM(tx *gorm.DB, msgs ...messaging.Message) error {
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return o.Enqueue(ctx, tx.Statement.ConnPool, msgs...)
+}
+
+func nullJSON(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
+
+// notify wakes the relay; the transaction may not have committed yet, in
+// which case the next pass picks the events up.
+func (o *Outbox) notify() {
+	select {
+	case o.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs relay passes every interval, and soon after events are
+// enqueued, until Close.
+func (o *Outbox) Start() {
+	o.startOnce.Do(func() { go o.loop() })
+}
+
+func (o *Outbox) loop() {
+	defer close(o.done)
+	ticker := time.NewTicker(o.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+		case <-o.trigger:
+			// Let the enqueuing transaction commit
+			select {
+			case <-o.stop:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*o.opts.Interval+sqlTimeout)
+		_, err := o.Relay(ctx)
+		cancel()
+		if err != nil && !errors.Is(err, ErrNotConnected) && !errors.Is(err, ErrNoBroker) {
+			o.opts.Logger.Warn("Outbox relay failed", "table", o.opts.Table, "error", err.Error())
+		}
+	}
+}
+
+// Relay publishes pending events in order until none are left or one fails,
+// and deletes published events older than the retention. It returns the
+// number of events published; another instance relaying counts as none.
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	o.relayMu.Lock()
+	defer o.relayMu.Unlock()
+
+	db, err := o.conn(ctx)
+	if err != nil {
+		return 0, o.recordError(err)
+	}
+	broker := o.broker()
+	if broker == nil {
+		return 0, o.recordError(ErrNoBroker)
+	}
+	// Publish to the broker itself: its buffer would hide an outage the
+	// outbox already survives and reports
+	if inner, ok := broker.(interface{ Unwrap() messaging.Broker }); ok {
+		broker = inner.Unwrap()
+	}
+
+	o.statsMu.Lock()
+	o.lastRelayAt = time.Now()
+	o.statsMu.Unlock()
+
+	total := 0
+	for {
+		n, more, err := o.relayBatch(ctx, db, broker)
+		total += n
+		if err != nil {
+			return total, o.recordError(err)
+		}
+		if !more {
+			break
+		}
+	}
+	o.clearError()
+	if err := o.prune(ctx, db); err != nil {
+		o.opts.Logger.Warn("Failed to prune outbox", "table", o.opts.Table, "error", err.Error())
+	}
+	return total, nil
+}
+
+// relayBatch publishes one batch in a transaction holding the relay lock
+// of the table, marking each event as it is published.
+func (o *Outbox) relayBatch(ctx context.Context, db *sql.DB, broker messaging.Broker) (published int, more bool, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext($1))`, o.opts.Table).Scan(&locked); err != nil {
+		return 0, false, err
+	}
+	if !locked {
+		return 0, false, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, topic, key, value, headers, created_at FROM `+o.opts.Table+`
+		WHERE published_at IS NULL ORDER BY id LIMIT $1`, o.opts.BatchSize)
+	if err != nil {
+		return 0, false, err
+	}
+	type event struct {
+		id  int64
+		msg messaging.Message
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		var headers []byte
+		if err := rows.Scan(&e.id, &e.msg.Topic, &e.msg.Key, &e.msg.Value, &headers, &e.msg.Timestamp); err != nil {
+			rows.Close()
+			return 0, false, err
+		}
+		if len(headers) > 0 {
+			if err := json.Unmarshal(headers, &e.msg.Headers); err != nil {
+				rows.Close()
+				return 0, false, fmt.Errorf("event %d: invalid headers: %w", e.id, err)
+			}
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, false, err
+	}
+
+	var publishErr error
+	for _, e := range events {
+		if publishErr = broker.PublishMessage(ctx, e.msg); publishErr != nil {
+			o.failed.Add(1)
+			_, err := tx.ExecContext(ctx, `UPDATE `+o.opts.Table+` SET attempts = attempts + 1, last_error = $2 WHERE id = $1`,
+				e.id, publishErr.Error())
+			if err != nil {
+				return published, false, err
+			}
+			publishErr = fmt.Errorf("event %d (%s): %w", e.id, e.msg.Topic, publishErr)
+			break
+		}
+		// A crash before the commit publishes the event again
+		if _, err := tx.ExecContext(ctx, `UPDATE `+o.opts.Table+` SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, e.id); err != nil {
+			return published, false, err
+		}
+		published++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	o.published.Add(int64(published))
+	return published, publishErr == nil && len(events) == o.opts.BatchSize, publishErr
+}
+
+func (o *Outbox) prune(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM `+o.opts.Table+` WHERE published_at < $1`, time.Now().Add(-o.opts.Retention))
+	return err
+}
+
+func (o *Outbox) recordError(err error) error {
+	o.statsMu.Lock()
+	defer o.statsMu.Unlock()
+	o.lastError = err.Error()
+	o.lastErrorAt = time.Now()
+	return err
+}
+
+func (o *Outbox) clearError() {
+	o.statsMu.Lock()
+	defer o.statsMu.Unlock()
+	o.lastError = ""
+}
+
+// Backlog returns the number of events waiting to be published and the
+// time the oldest was enqueued, zero when none is waiting.
+func (o *Outbox) Backlog(ctx context.Context) (int64, time.Time, error) {
+	db, err := o.conn(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var count int64
+	var oldest sql.NullTime
+	err = db.QueryRowContext(ctx, `SELECT count(*), min(created_at) FROM `+o.opts.Table+` WHERE published_at IS NULL`).Scan(&count, &oldest)
+	return count, oldest.Time, err
+}
+
+// Stats returns the backlog, its age and the relay counters.
+func (o *Outbox) Stats(ctx context.Context) map[string]interface{} {
+	o.statsMu.Lock()
+	stats := map[string]interface{}{
+		"table":           o.opts.Table,
+		"published_total": o.published.Load(),
+		"failed_total":    o.failed.Load(),
+	}
+	if o.lastError != "" {
+		stats["last_error"] = o.lastError
+		stats["last_error_at"] = o.lastErrorAt
+	}
+	if !o.lastRelayAt.IsZero() {
+		stats["last_relay_at"] = o.lastRelayAt
+	}
+	o.statsMu.Unlock()
+
+	backlog, oldest, err := o.Backlog(ctx)
+	if err != nil {
+		stats["error"] = err.Error()
+		return stats
+	}
+	stats["backlog"] = backlog
+	if !oldest.IsZero() {
+		stats["oldest_enqueued_at"] = oldest
+		stats["oldest_age_seconds"] = int64(time.Since(oldest).Seconds())
+	}
+	return stats
+}
+
+// GetStatus reports the outbox as healthy while relaying succeeds.
+func (o *Outbox) GetStatus() map[string]interface{} {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stats := o.Stats(ctx)
+	_, failing := stats["last_error"]
+	stats["connected"] = !failing && stats["error"] == nil
+	return stats
+}
+
+// Close stops the relay. Pending events stay in the table and are relayed
+// on the next start.
+func (o *Outbox) Close() error {
+	o.stopOnce.Do(func() { close(o.stop) })
+	// Never started: nothing to wait for, and Start does nothing from now on
+	o.startOnce.Do(func() { close(o.done) })
+	<-o.done
+	return nil
+}
@@ -0,0 +1,220 @@
+// Package photos stores user photos in an infrastructure.ObjectStorage,
+// validating size, content type and dimensions on upload and removing
+// photos no user refers to anymore.
+package photos
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrTooLarge        = errors.New("photo is too large")
+	ErrUnsupportedType = errors.New("unsupported photo type")
+	ErrInvalidImage    = errors.New("photo is not a valid image")
+	ErrNotFound        = errors.New("photo not found")
+)
+
+// extensions are the object name extensions of the supported types.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Photo is a stored photo. Name is the object name, which changes with
+// every upload, so clients may cache a photo by name indefinitely.
+type Photo struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// Store keeps one photo per owner under <prefix><owner>/.
+type Store struct {
+	objects      infrastructure.ObjectStorage
+	prefix       string
+	maxBytes     int64
+	maxDimension int
+	allowed      map[string]bool
+}
+
+// Backend returns the object storage cfg selects: a bucket of storage or
+// the local directory. Without a configured backend, storage is used when
+// available.
+func Backend(cfg config.PhotosConfig, storage *infrastructure.StorageManager) (infrastructure.ObjectStorage, error) {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = "local"
+		if storage != nil {
+			backend = "storage"
+		}
+	}
+	switch backend {
+	case "storage":
+		if storage == nil {
+			return nil, errors.New("photos backend storage requires storage to be enabled")
+		}
+		bucket, err := storage.GetDefaultBucket()
+		if cfg.Bucket != "" {
+			bucket, err = storage.GetBucket(cfg.Bucket)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return infrastructure.BucketObjectStorage{Bucket: bucket}, nil
+	case "local":
+		return infrastructure.LocalObjectStorage{Dir: cfg.LocalDir}, nil
+	}
+	return nil, fmt.Errorf("unknown photos backend %q", backend)
+}
+
+// New returns a Store in objects configured by cfg.
+func New(objects infrastructure.ObjectStorage, cfg config.PhotosConfig) *Store {
+	s := &Store{
+		objects:      objects,
+		prefix:       cfg.Prefix,
+		maxBytes:     int64(cfg.MaxSizeMB) << 20,
+		maxDimension: cfg.MaxDimension,
+		allowed:      make(map[string]bool),
+	}
+	for _, contentType := range cfg.AllowedTypes {
+		s.allowed[strings.ToLower(contentType)] = true
+	}
+	return s
+}
+
+// Upload validates the photo in r and stores it as the photo of owner,
+// replacing the previous one.
+func (s *Store) Upload(ctx context.Context, owner string, r io.Reader) (*Photo, error) {
+	if err := checkOwner(owner); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, fmt.Errorf("%w: the limit is %d MB", ErrTooLarge, s.maxBytes>>20)
+	}
+
+	// The declared content type and file name are the client's claim; the
+	// data decides
+	contentType := http.DetectContentType(data)
+	ext, known := extensions[contentType]
+	if !known || !s.allowed[contentType] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if s.maxDimension > 0 && (cfg.Width > s.maxDimension || cfg.Height > s.maxDimension) {
+		return nil, fmt.Errorf("%w: %dx%d exceeds %dx%d pixels", ErrTooLarge, cfg.Width, cfg.Height, s.maxDimension, s.maxDimension)
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	photo := &Photo{
+		Name:        s.prefix + owner + "/" + hex.EncodeToString(id) + ext,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+	}
+	if err := s.objects.Put(ctx, photo.Name, bytes.NewReader(data), photo.Size, contentType); err != nil {
+		return nil, err
+	}
+	// A failure here leaves an orphan for Cleanup, not a broken photo
+	s.removeExcept(ctx, owner, photo.Name)
+	return photo, nil
+}
+
+// Open returns the photo stored as name.
+func (s *Store) Open(ctx context.Context, name string) (io.ReadCloser, *infrastructure.StoredObject, error) {
+	if !strings.HasPrefix(name, s.prefix) {
+		return nil, nil, ErrNotFound
+	}
+	rc, info, err := s.objects.Get(ctx, name)
+	if errors.Is(err, infrastructure.ErrObjectNotFound) {
+		return nil, nil, ErrNotFound
+	}
+	return rc, info, err
+}
+
+// Delete removes every photo of owner.
+func (s *Store) Delete(ctx context.Context, owner string) error {
+	if err := checkOwner(owner); err != nil {
+		return err
+	}
+	return s.removeExcept(ctx, owner, "")
+}
+
+func (s *Store) removeExcept(ctx context.Context, owner, keep string) error {
+	objects, err := s.objects.List(ctx, s.prefix+owner+"/")
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, obj := range objects {
+		if obj.Name == keep {
+			continue
+		}
+		if err := s.objects.Delete(ctx, obj.Name); err != nil && !errors.Is(err, infrastructure.ErrObjectNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Cleanup removes the photos older than grace for which current returns
+// false: those of deleted owners and those left behind by failed uploads.
+// The grace period spares photos uploaded but not yet recorded by the
+// owner. It returns the number of photos removed.
+func (s *Store) Cleanup(ctx context.Context, current func(owner, name string) bool, grace time.Duration) (int, error) {
+	objects, err := s.objects.List(ctx, s.prefix)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var errs []error
+	for _, obj := range objects {
+		owner, _, _ := strings.Cut(strings.TrimPrefix(obj.Name, s.prefix), "/")
+		if time.Since(obj.Modified) < grace || current(owner, obj.Name) {
+			continue
+		}
+		if err := s.objects.Delete(ctx, obj.Name); err != nil && !errors.Is(err, infrastructure.ErrObjectNotFound) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+func checkOwner(owner string) error {
+	if owner == "" || owner == "." || owner == ".." || strings.ContainsAny(owner, "/\\") {
+		return fmt.Errorf("invalid photo owner %q", owner)
+	}
+	return nil
+}
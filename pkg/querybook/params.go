@@ -0,0 +1,100 @@
+package querybook
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Parameter is a value a saved query takes when it runs, referenced as
+// {{name}} in the query. Without a default it is required.
+type Parameter struct {
+	Name        string      `json:"name"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+var (
+	placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	// quotedPlaceholder is a whole JSON string, as in mongo queries
+	quotedPlaceholder = regexp.MustCompile(`"\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}"`)
+	parameterName     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validateParameters checks that parameter names are identifiers, declared
+// once, and that the query references no undeclared one. Mongo queries
+// must reference them as whole JSON strings ("{{name}}") so the query
+// stays valid JSON.
+func (q SavedQuery) validateParameters() error {
+	declared := make(map[string]bool, len(q.Parameters))
+	for _, p := range q.Parameters {
+		if !parameterName.MatchString(p.Name) {
+			return fmt.Errorf("%w: parameter names are identifiers, got %q", ErrInvalid, p.Name)
+		}
+		if declared[p.Name] {
+			return fmt.Errorf("%w: parameter %q is declared twice", ErrInvalid, p.Name)
+		}
+		declared[p.Name] = true
+	}
+	for _, m := range placeholder.FindAllStringSubmatchIndex(q.Query, -1) {
+		name := q.Query[m[2]:m[3]]
+		if !declared[name] {
+			return fmt.Errorf("%w: parameter %q is not declared", ErrInvalid, name)
+		}
+		if q.Engine == EngineMongo && (m[0] == 0 || m[1] == len(q.Query) || q.Query[m[0]-1] != '"' || q.Query[m[1]] != '"') {
+			return fmt.Errorf("%w: mongo parameters are written as \"{{%s}}\"", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// Bind resolves the parameters from values, falling back to their
+// defaults. Postgres placeholders become $1, $2... with the values in
+// args, so they are never spliced into the SQL; mongo placeholders are
+// replaced by the JSON encoding of their value.
+func (q SavedQuery) Bind(values map[string]interface{}) (query string, args []interface{}, err error) {
+	resolved := make(map[string]interface{}, len(q.Parameters))
+	for _, p := range q.Parameters {
+		v, ok := values[p.Name]
+		if !ok {
+			v = p.Default
+		}
+		if v == nil {
+			return "", nil, fmt.Errorf("%w: parameter %q is required", ErrInvalid, p.Name)
+		}
+		resolved[p.Name] = v
+	}
+	for name := range values {
+		if _, ok := resolved[name]; !ok {
+			return "", nil, fmt.Errorf("%w: unknown parameter %q", ErrInvalid, name)
+		}
+	}
+
+	if q.Engine == EngineMongo {
+		query = quotedPlaceholder.ReplaceAllStringFunc(q.Query, func(s string) string {
+			if err != nil {
+				return s
+			}
+			name := quotedPlaceholder.FindStringSubmatch(s)[1]
+			encoded, merr := json.Marshal(resolved[name])
+			if merr != nil {
+				err = fmt.Errorf("%w: parameter %q: %v", ErrInvalid, name, merr)
+				return s
+			}
+			return string(encoded)
+		})
+		return query, nil, err
+	}
+
+	positions := make(map[string]int)
+	query = placeholder.ReplaceAllStringFunc(q.Query, func(s string) string {
+		name := placeholder.FindStringSubmatch(s)[1]
+		if _, ok := positions[name]; !ok {
+			args = append(args, resolved[name])
+			positions[name] = len(args)
+		}
+		return "$" + strconv.Itoa(positions[name])
+	})
+	return query, args, nil
+}
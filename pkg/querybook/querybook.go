@@ -0,0 +1,330 @@
+// Package querybook keeps the named saved queries of the monitoring DB
+// console (Postgres and Mongo) and each operator's history of executed
+// queries, in the embedded store when available and in memory otherwise.
+package querybook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Engines of the DB console.
+const (
+	EnginePostgres = "postgres"
+	EngineMongo    = "mongo"
+)
+
+var (
+	ErrNotFound = errors.New("saved query not found")
+	ErrExists   = errors.New("saved query already exists")
+	ErrInvalid  = errors.New("invalid saved query")
+)
+
+// SavedQuery is a named query, shared by all operators unless Private.
+// The query may reference Parameters as {{name}}.
+type SavedQuery struct {
+	Name        string      `json:"name"`
+	Engine      string      `json:"engine"` // postgres or mongo
+	Connection  string      `json:"connection,omitempty"`
+	Collection  string      `json:"collection,omitempty"` // mongo
+	Query       string      `json:"query"`                // SQL, or a JSON filter or pipeline for mongo
+	Parameters  []Parameter `json:"parameters,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Private     bool        `json:"private"` // only visible to its creator and admins
+	Report      *Report     `json:"report,omitempty"`
+	CreatedBy   string      `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedBy   string      `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// HistoryEntry is one query an operator ran from the console.
+type HistoryEntry struct {
+	User       string    `json:"user"`
+	Engine     string    `json:"engine"`
+	Connection string    `json:"connection,omitempty"`
+	Query      string    `json:"query"`
+	Saved      string    `json:"saved,omitempty"` // name of the saved query run
+	Time       time.Time `json:"time"`
+	DurationMS float64   `json:"duration_ms"`
+	RowCount   int64     `json:"row_count"` // rows returned or affected
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// KV is the persistence the book needs; the embedded store implements it.
+type KV interface {
+	PutJSON(bucket, key string, v interface{}) error
+	GetJSON(bucket, key string, v interface{}) (bool, error)
+	Delete(bucket, key string) error
+	ForEachPrefix(bucket, prefix string, fn func(key, value []byte) error) error
+}
+
+const (
+	savedBucket   = "console_saved_queries"
+	historyBucket = "console_query_history"
+	reportBucket  = "console_query_reports"
+)
+
+// Book stores saved queries and query history.
+type Book struct {
+	kv          KV
+	historySize int
+
+	mu      sync.Mutex
+	saved   map[string]SavedQuery
+	history map[string][]HistoryEntry // per user, oldest first
+	runs    map[string][]ReportRun    // per saved query, oldest first
+}
+
+// New creates a book keeping historySize entries per user; kv may be nil.
+func New(kv KV, historySize int) *Book {
+	if historySize <= 0 {
+		historySize = 100
+	}
+	return &Book{kv: kv, historySize: historySize, saved: make(map[string]SavedQuery), history: make(map[string][]HistoryEntry), runs: make(map[string][]ReportRun)}
+}
+
+// Validate checks a saved query before it is stored.
+func (q SavedQuery) Validate() error {
+	switch {
+	case strings.TrimSpace(q.Name) == "" || len(q.Name) > 100 || strings.ContainsAny(q.Name, "/\\"):
+		return fmt.Errorf("%w: name must be 1-100 characters without slashes", ErrInvalid)
+	case q.Engine != EnginePostgres && q.Engine != EngineMongo:
+		return fmt.Errorf("%w: engine must be %q or %q", ErrInvalid, EnginePostgres, EngineMongo)
+	case strings.TrimSpace(q.Query) == "":
+		return fmt.Errorf("%w: query is required", ErrInvalid)
+	}
+	if err := q.validateParameters(); err != nil {
+		return err
+	}
+	if q.Engine == EngineMongo {
+		if q.Collection == "" {
+			return fmt.Errorf("%w: collection is required for mongo queries", ErrInvalid)
+		}
+		if !json.Valid([]byte(q.Query)) {
+			return fmt.Errorf("%w: mongo queries are a JSON filter or pipeline", ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// List returns the saved queries of engine (all when empty) by name.
+func (b *Book) List(engine string) ([]SavedQuery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var all []SavedQuery
+	if b.kv != nil {
+		err := b.kv.ForEachPrefix(savedBucket, "", func(_, value []byte) error {
+			var q SavedQuery
+			if json.Unmarshal(value, &q) == nil {
+				all = append(all, q)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, q := range b.saved {
+			all = append(all, q)
+		}
+	}
+
+	queries := make([]SavedQuery, 0, len(all))
+	for _, q := range all {
+		if engine == "" || q.Engine == engine {
+			queries = append(queries, q)
+		}
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	return queries, nil
+}
+
+// Get returns the saved query called name.
+func (b *Book) Get(name string) (SavedQuery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.get(name)
+}
+
+func (b *Book) get(name string) (SavedQuery, error) {
+	var q SavedQuery
+	found := false
+	if b.kv != nil {
+		var err error
+		if found, err = b.kv.GetJSON(savedBucket, name, &q); err != nil {
+			return SavedQuery{}, err
+		}
+	} else {
+		q, found = b.saved[name]
+	}
+	if !found {
+		return SavedQuery{}, ErrNotFound
+	}
+	return q, nil
+}
+
+func (b *Book) put(q SavedQuery) error {
+	if b.kv != nil {
+		return b.kv.PutJSON(savedBucket, q.Name, q)
+	}
+	b.saved[q.Name] = q
+	return nil
+}
+
+// Create saves a new query on behalf of user.
+func (b *Book) Create(q SavedQuery, user string) (SavedQuery, error) {
+	if err := q.Validate(); err != nil {
+		return SavedQuery{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.get(q.Name); err == nil {
+		return SavedQuery{}, ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return SavedQuery{}, err
+	}
+	now := time.Now()
+	q.CreatedBy, q.CreatedAt, q.UpdatedBy, q.UpdatedAt = user, now, "", now
+	q.Report = nil // scheduled through SetReport
+	return q, b.put(q)
+}
+
+// Update replaces the saved query called name, keeping who created it and
+// its report schedule.
+func (b *Book) Update(name string, q SavedQuery, user string) (SavedQuery, error) {
+	q.Name = name
+	if err := q.Validate(); err != nil {
+		return SavedQuery{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	current, err := b.get(name)
+	if err != nil {
+		return SavedQuery{}, err
+	}
+	q.CreatedBy, q.CreatedAt, q.Report = current.CreatedBy, current.CreatedAt, current.Report
+	q.UpdatedBy, q.UpdatedAt = user, time.Now()
+	return q, b.put(q)
+}
+
+// Delete removes the saved query called name and its report runs.
+func (b *Book) Delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.get(name); err != nil {
+		return err
+	}
+	if err := b.deleteRuns(name); err != nil {
+		return err
+	}
+	if b.kv != nil {
+		return b.kv.Delete(savedBucket, name)
+	}
+	delete(b.saved, name)
+	return nil
+}
+
+// VisibleTo reports whether user may see q: shared queries are visible to
+// every operator, private ones to their creator and admins.
+func (q SavedQuery) VisibleTo(user string, admin bool) bool {
+	return !q.Private || admin || q.CreatedBy == user
+}
+
+// historyKey orders a user's entries by time within the user's prefix.
+func historyKey(user string, t time.Time) string {
+	return fmt.Sprintf("%s/%020d", user, t.UnixNano())
+}
+
+// Record appends e to the history of e.User, dropping the oldest entries
+// beyond the history size.
+func (b *Book) Record(e HistoryEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.kv == nil {
+		entries := append(b.history[e.User], e)
+		if len(entries) > b.historySize {
+			entries = entries[len(entries)-b.historySize:]
+		}
+		b.history[e.User] = entries
+		return nil
+	}
+
+	if err := b.kv.PutJSON(historyBucket, historyKey(e.User, e.Time), e); err != nil {
+		return err
+	}
+	var keys []string
+	err := b.kv.ForEachPrefix(historyBucket, e.User+"/", func(key, _ []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys[:max(len(keys)-b.historySize, 0)] {
+		if err := b.kv.Delete(historyBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History returns up to limit entries of user, newest first.
+func (b *Book) History(user string, limit int) ([]HistoryEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries []HistoryEntry
+	if b.kv != nil {
+		err := b.kv.ForEachPrefix(historyBucket, user+"/", func(_, value []byte) error {
+			var e HistoryEntry
+			if json.Unmarshal(value, &e) == nil {
+				entries = append(entries, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		entries = append(entries, b.history[user]...)
+	}
+
+	newest := make([]HistoryEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0 && (limit <= 0 || len(newest) < limit); i-- {
+		newest = append(newest, entries[i])
+	}
+	return newest, nil
+}
+
+// ClearHistory forgets the history of user.
+func (b *Book) ClearHistory(user string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.kv == nil {
+		delete(b.history, user)
+		return nil
+	}
+	var keys []string
+	err := b.kv.ForEachPrefix(historyBucket, user+"/", func(key, _ []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := b.kv.Delete(historyBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
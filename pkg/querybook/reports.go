@@ -0,0 +1,330 @@
+package querybook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/logger"
+)
+
+// ErrNoScheduler is returned when a report is scheduled without the cron
+// manager.
+var ErrNoScheduler = errors.New("reports need the cron scheduler (cron.enabled)")
+
+// Report runs a saved query on a cron schedule and keeps its last results.
+type Report struct {
+	Schedule  string                 `json:"schedule"` // cron expression with seconds, e.g. "0 0 6 * * *"
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Keep      int                    `json:"keep,omitempty"` // runs kept, DefaultReportKeep when zero
+	UpdatedBy string                 `json:"updated_by,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// Report defaults.
+const (
+	DefaultReportKeep = 10
+	MaxReportKeep     = 100
+)
+
+func (r *Report) keep() int {
+	if r == nil || r.Keep <= 0 {
+		return DefaultReportKeep
+	}
+	return min(r.Keep, MaxReportKeep)
+}
+
+// Result is the tabular outcome of running a saved query. Mongo documents
+// become rows over the union of their top-level fields.
+type Result struct {
+	Columns      []string        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowCount     int             `json:"row_count"`
+	Truncated    bool            `json:"truncated"`
+	RowsAffected *int64          `json:"rows_affected,omitempty"`
+}
+
+// ReportRun is one scheduled run of a saved query.
+type ReportRun struct {
+	Query      string    `json:"query"` // saved query name
+	Time       time.Time `json:"time"`
+	DurationMS float64   `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Result     *Result   `json:"result,omitempty"`
+}
+
+func runKey(name string, t time.Time) string {
+	return fmt.Sprintf("%s/%020d", name, t.UnixNano())
+}
+
+// RecordRun stores a report run, dropping the oldest beyond keep.
+func (b *Book) RecordRun(run ReportRun, keep int) error {
+	if run.Time.IsZero() {
+		run.Time = time.Now()
+	}
+	if keep <= 0 {
+		keep = DefaultReportKeep
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.kv == nil {
+		runs := append(b.runs[run.Query], run)
+		if len(runs) > keep {
+			runs = runs[len(runs)-keep:]
+		}
+		b.runs[run.Query] = runs
+		return nil
+	}
+
+	if err := b.kv.PutJSON(reportBucket, runKey(run.Query, run.Time), run); err != nil {
+		return err
+	}
+	keys, err := b.runKeys(run.Query)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys[:max(len(keys)-keep, 0)] {
+		if err := b.kv.Delete(reportBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs returns up to limit report runs of the saved query name, newest
+// first.
+func (b *Book) Runs(name string, limit int) ([]ReportRun, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var runs []ReportRun
+	if b.kv != nil {
+		err := b.kv.ForEachPrefix(reportBucket, name+"/", func(_, value []byte) error {
+			var r ReportRun
+			if json.Unmarshal(value, &r) == nil {
+				runs = append(runs, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		runs = append(runs, b.runs[name]...)
+	}
+
+	newest := make([]ReportRun, 0, len(runs))
+	for i := len(runs) - 1; i >= 0 && (limit <= 0 || len(newest) < limit); i-- {
+		newest = append(newest, runs[i])
+	}
+	return newest, nil
+}
+
+func (b *Book) runKeys(name string) ([]string, error) {
+	var keys []string
+	err := b.kv.ForEachPrefix(reportBucket, name+"/", func(key, _ []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	return keys, err
+}
+
+// deleteRuns forgets the runs of name. Callers hold b.mu.
+func (b *Book) deleteRuns(name string) error {
+	if b.kv == nil {
+		delete(b.runs, name)
+		return nil
+	}
+	keys, err := b.runKeys(name)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := b.kv.Delete(reportBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setReport stores the report schedule of name; nil removes it.
+func (b *Book) setReport(name string, report *Report) (SavedQuery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, err := b.get(name)
+	if err != nil {
+		return SavedQuery{}, err
+	}
+	q.Report = report
+	return q, b.put(q)
+}
+
+// Cron is the part of the cron manager reports need.
+type Cron interface {
+	AddAsyncJob(name, schedule string, cmd func()) (int, error)
+	RemoveJob(jobID int) error
+}
+
+// Runner runs a saved query with the given parameter values.
+type Runner func(ctx context.Context, q SavedQuery, params map[string]interface{}) (*Result, error)
+
+// ReportTimeout bounds one scheduled run.
+const ReportTimeout = 5 * time.Minute
+
+// Scheduler runs saved queries on demand and on their report schedule
+// through the cron manager.
+type Scheduler struct {
+	book   *Book
+	cron   Cron // nil without the cron manager
+	run    Runner
+	logger *logger.Logger
+
+	mu   sync.Mutex
+	jobs map[string]int // cron job per saved query
+}
+
+// NewScheduler creates a scheduler running queries with run; cron may be
+// nil, in which case reports cannot be scheduled.
+func NewScheduler(book *Book, cron Cron, run Runner, l *logger.Logger) *Scheduler {
+	return &Scheduler{book: book, cron: cron, run: run, logger: l, jobs: make(map[string]int)}
+}
+
+// Book returns the book of the saved queries.
+func (s *Scheduler) Book() *Book {
+	return s.book
+}
+
+// Start schedules the reports of every saved query.
+func (s *Scheduler) Start() error {
+	if s.cron == nil {
+		return nil
+	}
+	queries, err := s.book.List("")
+	if err != nil {
+		return err
+	}
+	for _, q := range queries {
+		if q.Report == nil {
+			continue
+		}
+		if err := s.schedule(q); err != nil && s.logger != nil {
+			s.logger.Error("Failed to schedule query report", err, "query", q.Name, "schedule", q.Report.Schedule)
+		}
+	}
+	return nil
+}
+
+// Run runs q with the given parameter values.
+func (s *Scheduler) Run(ctx context.Context, q SavedQuery, params map[string]interface{}) (*Result, error) {
+	return s.run(ctx, q, params)
+}
+
+// SetReport schedules the saved query name as a report on behalf of user,
+// replacing its previous schedule. The parameters are checked first, so a
+// report that cannot run is never scheduled.
+func (s *Scheduler) SetReport(name string, report Report, user string) (SavedQuery, error) {
+	if s.cron == nil {
+		return SavedQuery{}, ErrNoScheduler
+	}
+	if strings.TrimSpace(report.Schedule) == "" {
+		return SavedQuery{}, fmt.Errorf("%w: schedule is required", ErrInvalid)
+	}
+	q, err := s.book.Get(name)
+	if err != nil {
+		return SavedQuery{}, err
+	}
+	if _, _, err := q.Bind(report.Params); err != nil {
+		return SavedQuery{}, err
+	}
+	report.UpdatedBy, report.UpdatedAt = user, time.Now()
+	q.Report = &report
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, scheduled := s.jobs[name]
+	if err := s.scheduleLocked(q); err != nil {
+		return SavedQuery{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if scheduled {
+		_ = s.cron.RemoveJob(previous)
+	}
+	saved, err := s.book.setReport(name, q.Report)
+	if err != nil {
+		_ = s.cron.RemoveJob(s.jobs[name])
+		delete(s.jobs, name)
+		return SavedQuery{}, err
+	}
+	return saved, nil
+}
+
+// RemoveReport unschedules the report of the saved query name.
+func (s *Scheduler) RemoveReport(name string) (SavedQuery, error) {
+	s.Unschedule(name)
+	return s.book.setReport(name, nil)
+}
+
+// Unschedule stops the cron job of name, e.g. when the query is deleted.
+func (s *Scheduler) Unschedule(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.jobs[name]; ok {
+		_ = s.cron.RemoveJob(id)
+		delete(s.jobs, name)
+	}
+}
+
+func (s *Scheduler) schedule(q SavedQuery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scheduleLocked(q)
+}
+
+// scheduleLocked adds the cron job of q. Callers hold s.mu.
+func (s *Scheduler) scheduleLocked(q SavedQuery) error {
+	name := q.Name
+	id, err := s.cron.AddAsyncJob("report: "+name, q.Report.Schedule, func() { s.RunReport(name) })
+	if err != nil {
+		return err
+	}
+	s.jobs[name] = id
+	return nil
+}
+
+// RunReport runs the report of the saved query name now and records the
+// run. The query is read again so edits apply to the next run.
+func (s *Scheduler) RunReport(name string) (ReportRun, error) {
+	q, err := s.book.Get(name)
+	if err != nil {
+		return ReportRun{}, err
+	}
+	var params map[string]interface{}
+	if q.Report != nil {
+		params = q.Report.Params
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReportTimeout)
+	defer cancel()
+	start := time.Now()
+	result, err := s.run(ctx, q, params)
+	run := ReportRun{
+		Query:      name,
+		Time:       start,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Success:    err == nil,
+		Result:     result,
+	}
+	if err != nil {
+		run.Error = err.Error()
+		if s.logger != nil {
+			s.logger.Warn("Query report failed", "query", name, "error", err.Error())
+		}
+	}
+	if rerr := s.book.RecordRun(run, q.Report.keep()); rerr != nil {
+		return run, rerr
+	}
+	return run, nil
+}
@@ -0,0 +1,194 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backend names (queue.backend).
+const (
+	BackendMemory   = "memory"
+	BackendRedis    = "redis"
+	BackendPostgres = "postgres"
+)
+
+// Backend keeps the jobs of a queue. Claim must hand a job to one caller
+// only, across every instance sharing the backend.
+type Backend interface {
+	// Name returns one of the Backend* constants.
+	Name() string
+	// Push stores a new job, due at its RunAt.
+	Push(ctx context.Context, job Job) error
+	// Claim takes the due job of highest priority, due first among equals,
+	// for lease; a job claimed earlier whose lease expired is due again.
+	// It returns nil when no job is due.
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error)
+	// Complete deletes a claimed job.
+	Complete(ctx context.Context, job Job) error
+	// Retry stores a claimed job again, due at its RunAt.
+	Retry(ctx context.Context, job Job) error
+	// Bury moves a claimed job to the dead letters.
+	Bury(ctx context.Context, job Job) error
+	// Requeue moves a dead job back, due now with its attempts reset.
+	Requeue(ctx context.Context, id string, now time.Time) (bool, error)
+	// Discard deletes a dead job.
+	Discard(ctx context.Context, id string) (bool, error)
+	// Dead returns up to limit dead jobs, most recently failed first.
+	Dead(ctx context.Context, limit int) ([]Job, error)
+	// Depth counts the jobs by state.
+	Depth(ctx context.Context) (Depth, error)
+}
+
+// requeued returns a dead job as it goes back to the queue.
+func requeued(job Job, now time.Time) Job {
+	job.Attempts = 0
+	job.FailedAt = nil
+	job.RunAt = now
+	return job
+}
+
+// MemoryBackend keeps jobs in process memory, for development and tests:
+// they are lost on restart and not shared between instances.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	pending map[string]Job       // ready and scheduled
+	running map[string]time.Time // lease expiry by job
+	jobs    map[string]Job       // running jobs
+	dead    map[string]Job
+}
+
+// NewMemoryBackend creates an empty in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		pending: make(map[string]Job),
+		running: make(map[string]time.Time),
+		jobs:    make(map[string]Job),
+		dead:    make(map[string]Job),
+	}
+}
+
+// Name implements Backend.
+func (m *MemoryBackend) Name() string { return BackendMemory }
+
+// Push implements Backend.
+func (m *MemoryBackend) Push(_ context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[job.ID] = job
+	return nil
+}
+
+// Claim implements Backend.
+func (m *MemoryBackend) Claim(_ context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, until := range m.running {
+		if now.After(until) {
+			m.pending[id] = m.jobs[id]
+			delete(m.running, id)
+			delete(m.jobs, id)
+		}
+	}
+	var next *Job
+	for _, job := range m.pending {
+		if job.RunAt.After(now) {
+			continue
+		}
+		if next == nil || job.Priority > next.Priority ||
+			job.Priority == next.Priority && job.RunAt.Before(next.RunAt) {
+			job := job
+			next = &job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	delete(m.pending, next.ID)
+	m.running[next.ID] = now.Add(lease)
+	m.jobs[next.ID] = *next
+	return next, nil
+}
+
+// Complete implements Backend.
+func (m *MemoryBackend) Complete(_ context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.running, job.ID)
+	delete(m.jobs, job.ID)
+	return nil
+}
+
+// Retry implements Backend.
+func (m *MemoryBackend) Retry(_ context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.running, job.ID)
+	delete(m.jobs, job.ID)
+	m.pending[job.ID] = job
+	return nil
+}
+
+// Bury implements Backend.
+func (m *MemoryBackend) Bury(_ context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.running, job.ID)
+	delete(m.jobs, job.ID)
+	m.dead[job.ID] = job
+	return nil
+}
+
+// Requeue implements Backend.
+func (m *MemoryBackend) Requeue(_ context.Context, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.dead[id]
+	if !ok {
+		return false, nil
+	}
+	delete(m.dead, id)
+	m.pending[id] = requeued(job, now)
+	return true, nil
+}
+
+// Discard implements Backend.
+func (m *MemoryBackend) Discard(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.dead[id]
+	delete(m.dead, id)
+	return ok, nil
+}
+
+// Dead implements Backend.
+func (m *MemoryBackend) Dead(_ context.Context, limit int) ([]Job, error) {
+	m.mu.Lock()
+	jobs := make([]Job, 0, len(m.dead))
+	for _, job := range m.dead {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].FailedAt.After(*jobs[j].FailedAt) })
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// Depth implements Backend.
+func (m *MemoryBackend) Depth(_ context.Context) (Depth, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	d := Depth{Running: int64(len(m.running)), Dead: int64(len(m.dead))}
+	for _, job := range m.pending {
+		if job.RunAt.After(now) {
+			d.Scheduled++
+		} else {
+			d.Ready++
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,257 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTable holds the jobs of the Postgres backend when no table is
+// configured.
+const DefaultTable = "stackyrd_jobs"
+
+// Job states of the Postgres backend.
+const (
+	statePending = "pending"
+	stateRunning = "running"
+	stateDead    = "dead"
+)
+
+// ErrNotConnected is returned by the Postgres backend while the database
+// is not connected.
+var ErrNotConnected = errors.New("queue: database is not connected")
+
+// PostgresBackend keeps jobs in a Postgres table, created on first use.
+// Workers claim jobs with FOR UPDATE SKIP LOCKED, so instances never wait
+// on each other. The connection is looked up on every call as the
+// database may connect after the queue starts.
+type PostgresBackend struct {
+	db    func() *sql.DB
+	table string
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewPostgresBackend keeps jobs in table (DefaultTable when empty) of the
+// database db returns, nil while not connected.
+func NewPostgresBackend(db func() *sql.DB, table string) *PostgresBackend {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &PostgresBackend{db: db, table: table}
+}
+
+func (p *PostgresBackend) conn(ctx context.Context) (*sql.DB, error) {
+	db := p.db()
+	if db == nil {
+		return nil, ErrNotConnected
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.ready {
+		_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+p.table+` (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			payload JSONB,
+			priority INTEGER NOT NULL DEFAULT 0,
+			state TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 0,
+			run_at TIMESTAMPTZ NOT NULL,
+			locked_until TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL,
+			failed_at TIMESTAMPTZ,
+			last_error TEXT
+		)`)
+		if err == nil {
+			_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS `+p.table+`_due
+				ON `+p.table+` (priority DESC, run_at) WHERE state <> 'dead'`)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", p.table, err)
+		}
+		p.ready = true
+	}
+	return db, nil
+}
+
+// Name implements Backend.
+func (p *PostgresBackend) Name() string { return BackendPostgres }
+
+func nullPayload(job Job) interface{} {
+	if len(job.Payload) == 0 {
+		return nil
+	}
+	return string(job.Payload)
+}
+
+// Push implements Backend.
+func (p *PostgresBackend) Push(ctx context.Context, job Job) error {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO `+p.table+`
+		(id, type, payload, priority, state, attempts, max_attempts, run_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		job.ID, job.Type, nullPayload(job), job.Priority, statePending, job.Attempts, job.MaxAttempts, job.RunAt, job.CreatedAt)
+	return err
+}
+
+const jobColumns = `id, type, payload, priority, attempts, max_attempts, run_at, created_at, failed_at, last_error`
+
+func scanJob(scan func(dest ...interface{}) error) (Job, error) {
+	var job Job
+	var payload []byte
+	var failedAt sql.NullTime
+	var lastError sql.NullString
+	err := scan(&job.ID, &job.Type, &payload, &job.Priority, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &job.CreatedAt, &failedAt, &lastError)
+	if len(payload) > 0 {
+		job.Payload = payload
+	}
+	if failedAt.Valid {
+		job.FailedAt = &failedAt.Time
+	}
+	job.LastError = lastError.String
+	return job, err
+}
+
+// Claim implements Backend.
+func (p *PostgresBackend) Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row := db.QueryRowContext(ctx, `UPDATE `+p.table+` SET state = $3, locked_until = $2
+		WHERE id = (
+			SELECT id FROM `+p.table+`
+			WHERE (state = $4 AND run_at <= $1) OR (state = $3 AND locked_until < $1)
+			ORDER BY priority DESC, run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns, now, now.Add(lease), stateRunning, statePending)
+	job, err := scanJob(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Complete implements Backend.
+func (p *PostgresBackend) Complete(ctx context.Context, job Job) error {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM `+p.table+` WHERE id = $1`, job.ID)
+	return err
+}
+
+// Retry implements Backend.
+func (p *PostgresBackend) Retry(ctx context.Context, job Job) error {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `UPDATE `+p.table+`
+		SET state = $2, attempts = $3, run_at = $4, last_error = $5, locked_until = NULL WHERE id = $1`,
+		job.ID, statePending, job.Attempts, job.RunAt, job.LastError)
+	return err
+}
+
+// Bury implements Backend.
+func (p *PostgresBackend) Bury(ctx context.Context, job Job) error {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return err
+	}
+	failedAt := time.Now()
+	if job.FailedAt != nil {
+		failedAt = *job.FailedAt
+	}
+	_, err = db.ExecContext(ctx, `UPDATE `+p.table+`
+		SET state = $2, attempts = $3, failed_at = $4, last_error = $5, locked_until = NULL WHERE id = $1`,
+		job.ID, stateDead, job.Attempts, failedAt, job.LastError)
+	return err
+}
+
+// Requeue implements Backend.
+func (p *PostgresBackend) Requeue(ctx context.Context, id string, now time.Time) (bool, error) {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := db.ExecContext(ctx, `UPDATE `+p.table+`
+		SET state = $2, attempts = 0, run_at = $3, failed_at = NULL WHERE id = $1 AND state = $4`,
+		id, statePending, now, stateDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Discard implements Backend.
+func (p *PostgresBackend) Discard(ctx context.Context, id string) (bool, error) {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM `+p.table+` WHERE id = $1 AND state = $2`, id, stateDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Dead implements Backend.
+func (p *PostgresBackend) Dead(ctx context.Context, limit int) ([]Job, error) {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 1000
+	}
+	rows, err := db.QueryContext(ctx, `SELECT `+jobColumns+` FROM `+p.table+`
+		WHERE state = $1 ORDER BY failed_at DESC LIMIT $2`, stateDead, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Depth implements Backend.
+func (p *PostgresBackend) Depth(ctx context.Context) (Depth, error) {
+	db, err := p.conn(ctx)
+	if err != nil {
+		return Depth{}, err
+	}
+	var d Depth
+	err = db.QueryRowContext(ctx, `SELECT
+			count(*) FILTER (WHERE state = $1 AND run_at <= now()),
+			count(*) FILTER (WHERE state = $1 AND run_at > now()),
+			count(*) FILTER (WHERE state = $2),
+			count(*) FILTER (WHERE state = $3)
+		FROM `+p.table, statePending, stateRunning, stateDead).Scan(&d.Ready, &d.Scheduled, &d.Running, &d.Dead)
+	return d, err
+}
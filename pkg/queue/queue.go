@@ -0,0 +1,413 @@
+// Package queue is a persistent job queue: services enqueue typed jobs,
+// possibly delayed or prioritised, and workers of any instance run them,
+// retrying failures with backoff and moving jobs out of attempts to a
+// dead-letter list an operator can retry them from. Jobs live in a Backend
+// (Redis, Postgres or memory), so they survive restarts.
+//
+//	var welcome = queue.NewType[WelcomeEmail]("send_welcome_email")
+//
+//	welcome.Handle(q, s.sendWelcome, queue.RetryPolicy{MaxAttempts: 3})
+//	welcome.Enqueue(ctx, q, WelcomeEmail{UserID: id}, queue.Delay(time.Minute))
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"stackyrd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Job is a unit of work in the queue.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Priority    int             `json:"priority"`               // higher runs first among due jobs, -100 to 100
+	Attempts    int             `json:"attempts"`               // runs so far
+	MaxAttempts int             `json:"max_attempts,omitempty"` // 0 uses the retry policy of the type
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	FailedAt    *time.Time      `json:"failed_at,omitempty"` // when it was dead-lettered
+}
+
+// Bounds of Job.Priority.
+const (
+	MinPriority = -100
+	MaxPriority = 100
+)
+
+// Depth counts the jobs of a queue by state.
+type Depth struct {
+	Ready     int64 `json:"ready"`     // due, waiting for a worker
+	Scheduled int64 `json:"scheduled"` // delayed or waiting for a retry
+	Running   int64 `json:"running"`
+	Dead      int64 `json:"dead"`
+}
+
+// Handler runs a job. An error retries it, unless wrapped with Permanent.
+type Handler func(ctx context.Context, job Job) error
+
+// RetryPolicy says how often and when a failed job runs again.
+type RetryPolicy struct {
+	MaxAttempts int           // runs before the job is dead-lettered, the first included
+	Backoff     time.Duration // delay before the first retry, doubled for each further one
+	MaxBackoff  time.Duration // longest delay between retries
+}
+
+// DefaultRetryPolicy applies to the fields a policy leaves zero.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, Backoff: 10 * time.Second, MaxBackoff: time.Hour}
+
+func (p RetryPolicy) withDefaults(d RetryPolicy) RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = d.MaxAttempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = d.Backoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = d.MaxBackoff
+	}
+	return p
+}
+
+// Delay returns how long to wait before the run following attempt.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: the job is dead-lettered at
+// once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// EnqueueOption adjusts a job being enqueued.
+type EnqueueOption func(*Job)
+
+// Delay runs the job no earlier than d from now.
+func Delay(d time.Duration) EnqueueOption {
+	return func(j *Job) { j.RunAt = time.Now().Add(d) }
+}
+
+// At runs the job no earlier than t.
+func At(t time.Time) EnqueueOption {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// Priority runs the job before due jobs of lower priority.
+func Priority(p int) EnqueueOption {
+	return func(j *Job) { j.Priority = max(MinPriority, min(p, MaxPriority)) }
+}
+
+// MaxAttempts overrides the attempts of the type's retry policy.
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) { j.MaxAttempts = n }
+}
+
+// Options configures a Queue.
+type Options struct {
+	Workers      int           // jobs run at once by this instance
+	PollInterval time.Duration // how often idle workers look for due jobs
+	Lease        time.Duration // how long a job may run before another worker takes it over
+	Retry        RetryPolicy   // defaults of the types' policies
+	Logger       *logger.Logger
+}
+
+type registration struct {
+	handler Handler
+	policy  RetryPolicy
+}
+
+// Queue enqueues jobs in a backend and runs those of the registered types.
+// Handlers should be idempotent: a job whose worker dies, or outlives its
+// lease, runs again.
+type Queue struct {
+	backend Backend
+	opts    Options
+
+	mu       sync.RWMutex
+	handlers map[string]registration
+
+	wake    chan struct{}
+	wg      sync.WaitGroup
+	started atomic.Bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	succeeded, retried, dead atomic.Int64
+}
+
+// New creates a queue on backend. Workers run once Start is called.
+func New(backend Backend, opts Options) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	opts.Retry = opts.Retry.withDefaults(DefaultRetryPolicy)
+	if opts.Logger == nil {
+		opts.Logger = logger.NewQuiet(false, nil)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		backend:  backend,
+		opts:     opts,
+		handlers: make(map[string]registration),
+		wake:     make(chan struct{}, opts.Workers),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Name returns the display name of the component
+func (q *Queue) Name() string {
+	return "Job Queue"
+}
+
+// Register runs jobs of jobType with handler, retried by policy (fields
+// left zero take the queue's defaults).
+func (q *Queue) Register(jobType string, handler Handler, policy RetryPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = registration{handler: handler, policy: policy.withDefaults(q.opts.Retry)}
+}
+
+// Types returns the registered job types.
+func (q *Queue) Types() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	types := make([]string, 0, len(q.handlers))
+	for name := range q.handlers {
+		types = append(types, name)
+	}
+	return types
+}
+
+// Enqueue adds a job of jobType with payload encoded as JSON, due now
+// unless an option delays it. Any instance registering jobType may run it.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...EnqueueOption) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("job %s: encoding payload: %w", jobType, err)
+	}
+	now := time.Now()
+	job := Job{ID: uuid.NewString(), Type: jobType, Payload: data, RunAt: now, CreatedAt: now}
+	for _, opt := range opts {
+		opt(&job)
+	}
+	if err := q.backend.Push(ctx, job); err != nil {
+		return Job{}, fmt.Errorf("job %s: %w", jobType, err)
+	}
+	if !job.RunAt.After(now) {
+		q.notify()
+	}
+	return job, nil
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start starts the workers.
+func (q *Queue) Start() {
+	if !q.started.CompareAndSwap(false, true) {
+		return
+	}
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for q.ctx.Err() == nil {
+		job, err := q.backend.Claim(q.ctx, time.Now(), q.opts.Lease)
+		if err != nil && q.ctx.Err() == nil {
+			q.opts.Logger.Warn("Failed to claim job", "error", err.Error())
+		}
+		if job != nil {
+			q.run(*job)
+			continue
+		}
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
+
+// run runs a claimed job and completes, retries or buries it.
+func (q *Queue) run(job Job) {
+	// Finish bookkeeping even when the queue is closing
+	ctx := context.WithoutCancel(q.ctx)
+	q.mu.RLock()
+	reg, ok := q.handlers[job.Type]
+	q.mu.RUnlock()
+	if !ok {
+		// Another instance may handle it; look again later without using
+		// up an attempt
+		job.RunAt = time.Now().Add(time.Minute)
+		job.LastError = fmt.Sprintf("no handler for job type %q", job.Type)
+		if err := q.backend.Retry(ctx, job); err != nil {
+			q.opts.Logger.Warn("Failed to reschedule job", "job", job.ID, "type", job.Type, "error", err.Error())
+		}
+		return
+	}
+
+	job.Attempts++
+	runCtx, cancel := context.WithTimeout(q.ctx, q.opts.Lease)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return reg.handler(runCtx, job)
+	}()
+	cancel()
+
+	if err == nil {
+		q.succeeded.Add(1)
+		if err := q.backend.Complete(ctx, job); err != nil {
+			q.opts.Logger.Warn("Failed to complete job", "job", job.ID, "type", job.Type, "error", err.Error())
+		}
+		return
+	}
+
+	job.LastError = err.Error()
+	maxAttempts := reg.policy.MaxAttempts
+	if job.MaxAttempts > 0 {
+		maxAttempts = job.MaxAttempts
+	}
+	var permanent permanentError
+	if errors.As(err, &permanent) || job.Attempts >= maxAttempts {
+		now := time.Now()
+		job.FailedAt = &now
+		q.dead.Add(1)
+		q.opts.Logger.Error("Job failed, moved to dead letters", err, "job", job.ID, "type", job.Type, "attempts", job.Attempts)
+		if err := q.backend.Bury(ctx, job); err != nil {
+			q.opts.Logger.Warn("Failed to dead-letter job", "job", job.ID, "type", job.Type, "error", err.Error())
+		}
+		return
+	}
+	job.RunAt = time.Now().Add(reg.policy.Delay(job.Attempts))
+	q.retried.Add(1)
+	q.opts.Logger.Warn("Job failed, retrying", "job", job.ID, "type", job.Type, "attempt", job.Attempts, "retry_at", job.RunAt, "error", err.Error())
+	if err := q.backend.Retry(ctx, job); err != nil {
+		q.opts.Logger.Warn("Failed to reschedule job", "job", job.ID, "type", job.Type, "error", err.Error())
+	}
+}
+
+// Depth counts the jobs by state.
+func (q *Queue) Depth(ctx context.Context) (Depth, error) {
+	return q.backend.Depth(ctx)
+}
+
+// Dead returns up to limit dead-lettered jobs, most recent first.
+func (q *Queue) Dead(ctx context.Context, limit int) ([]Job, error) {
+	return q.backend.Dead(ctx, limit)
+}
+
+// Retry moves the dead-lettered job id back to the queue with its attempts
+// reset, reporting false when there is no such dead job.
+func (q *Queue) Retry(ctx context.Context, id string) (bool, error) {
+	ok, err := q.backend.Requeue(ctx, id, time.Now())
+	if ok {
+		q.notify()
+	}
+	return ok, err
+}
+
+// Discard deletes the dead-lettered job id, reporting false when there is
+// no such dead job.
+func (q *Queue) Discard(ctx context.Context, id string) (bool, error) {
+	return q.backend.Discard(ctx, id)
+}
+
+// GetStatus reports the depth of the queue and the outcomes of the jobs
+// run by this instance.
+func (q *Queue) GetStatus() map[string]interface{} {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status := map[string]interface{}{
+		"backend":   q.backend.Name(),
+		"workers":   q.opts.Workers,
+		"types":     q.Types(),
+		"succeeded": q.succeeded.Load(),
+		"retried":   q.retried.Load(),
+		"dead":      q.dead.Load(),
+	}
+	depth, err := q.backend.Depth(ctx)
+	status["connected"] = err == nil
+	if err != nil {
+		status["error"] = err.Error()
+	} else {
+		status["depth"] = depth
+	}
+	return status
+}
+
+// Close stops the workers, cancelling running jobs; they run again once
+// their lease expires.
+func (q *Queue) Close() error {
+	q.cancel()
+	q.wg.Wait()
+	return nil
+}
+
+// Type is a job type whose payload is a T, so enqueuers and the handler
+// agree on it.
+type Type[T any] struct {
+	Name string
+}
+
+// NewType declares the job type name with payloads of type T.
+func NewType[T any](name string) Type[T] {
+	return Type[T]{Name: name}
+}
+
+// Enqueue adds a job of the type with payload.
+func (t Type[T]) Enqueue(ctx context.Context, q *Queue, payload T, opts ...EnqueueOption) (Job, error) {
+	return q.Enqueue(ctx, t.Name, payload, opts...)
+}
+
+// Handle runs jobs of the type with fn. A payload that does not decode
+// is dead-lettered.
+func (t Type[T]) Handle(q *Queue, fn func(ctx context.Context, payload T) error, policy RetryPolicy) {
+	q.Register(t.Name, func(ctx context.Context, job Job) error {
+		var payload T
+		if len(job.Payload) > 0 {
+			if err := json.Unmarshal(job.Payload, &payload); err != nil {
+				return Permanent(fmt.Errorf("decoding payload: %w", err))
+			}
+		}
+		return fn(ctx, payload)
+	}, policy)
+}
@@ -0,0 +1,257 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackend keeps jobs in Redis under a key prefix: each job as JSON at
+// "<prefix>job:<id>", and their IDs in sorted sets of ready jobs (by
+// priority, then due time), scheduled jobs (by due time), running jobs (by
+// lease expiry) and dead jobs (by failure time).
+type RedisBackend struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisBackend creates a Redis backend under prefix, e.g. "queue:".
+// A prefix without a hash tag is wrapped in one ("{queue}:"), so every key
+// of the queue is in the same Redis Cluster slot, as the claim script
+// needs.
+func NewRedisBackend(client redis.Cmdable, prefix string) *RedisBackend {
+	return &RedisBackend{client: client, prefix: hashTagged(prefix)}
+}
+
+// hashTagged returns prefix with a hash tag: the part before the trailing
+// colons in braces, unless it has a non-empty one already.
+func hashTagged(prefix string) string {
+	if open := strings.Index(prefix, "{"); open >= 0 && strings.Index(prefix[open:], "}") > 1 {
+		return prefix
+	}
+	name := strings.TrimRight(prefix, ":")
+	if name == "" {
+		return "{queue}" + prefix
+	}
+	return "{" + name + "}" + prefix[len(name):]
+}
+
+func (r *RedisBackend) key(name string) string  { return r.prefix + name }
+func (r *RedisBackend) jobKey(id string) string { return r.prefix + "job:" + id }
+func (r *RedisBackend) keys() (ready, scheduled, running, dead, scores string) {
+	return r.key("ready"), r.key("scheduled"), r.key("running"), r.key("dead"), r.key("scores")
+}
+
+// readyScore orders ready jobs by priority, then due time; priorities are
+// bounded so the score stays exact in a float64.
+func readyScore(job Job) float64 {
+	return float64(-job.Priority)*1e13 + float64(job.RunAt.UnixMilli())
+}
+
+// Name implements Backend.
+func (r *RedisBackend) Name() string { return BackendRedis }
+
+// Push implements Backend.
+func (r *RedisBackend) Push(ctx context.Context, job Job) error {
+	return r.schedule(ctx, job, time.Now())
+}
+
+// schedule stores job as pending: ready when due at now, else scheduled.
+func (r *RedisBackend) schedule(ctx context.Context, job Job, now time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ready, scheduled, running, _, scores := r.keys()
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.jobKey(job.ID), data, 0)
+		pipe.ZRem(ctx, running, job.ID)
+		pipe.HSet(ctx, scores, job.ID, readyScore(job))
+		if job.RunAt.After(now) {
+			pipe.ZAdd(ctx, scheduled, redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: job.ID})
+		} else {
+			pipe.ZAdd(ctx, ready, redis.Z{Score: readyScore(job), Member: job.ID})
+		}
+		return nil
+	})
+	return err
+}
+
+// claimScript moves due scheduled jobs and expired leases to the ready
+// set, then leases the first ready job and returns it. The job key is only
+// known once popped, so it is built from ARGV[3]; the hash tag of the
+// prefix keeps it in the slot of KEYS.
+var claimScript = redis.NewScript(`
+for _, source in ipairs({KEYS[2], KEYS[3]}) do
+	local due = redis.call('ZRANGEBYSCORE', source, '-inf', ARGV[1], 'LIMIT', 0, 100)
+	for _, id in ipairs(due) do
+		redis.call('ZREM', source, id)
+		redis.call('ZADD', KEYS[1], redis.call('HGET', KEYS[4], id) or 0, id)
+	end
+end
+local popped = redis.call('ZPOPMIN', KEYS[1])
+if #popped == 0 then
+	return false
+end
+local data = redis.call('GET', ARGV[3] .. popped[1])
+if not data then
+	redis.call('HDEL', KEYS[4], popped[1])
+	return false
+end
+redis.call('ZADD', KEYS[3], ARGV[2], popped[1])
+return data
+`)
+
+// Claim implements Backend.
+func (r *RedisBackend) Claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	ready, scheduled, running, _, scores := r.keys()
+	data, err := claimScript.Run(ctx, r.client, []string{ready, scheduled, running, scores},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), r.prefix+"job:").Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Complete implements Backend.
+func (r *RedisBackend) Complete(ctx context.Context, job Job) error {
+	_, _, running, _, scores := r.keys()
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.jobKey(job.ID))
+		pipe.ZRem(ctx, running, job.ID)
+		pipe.HDel(ctx, scores, job.ID)
+		return nil
+	})
+	return err
+}
+
+// Retry implements Backend.
+func (r *RedisBackend) Retry(ctx context.Context, job Job) error {
+	return r.schedule(ctx, job, time.Now())
+}
+
+// Bury implements Backend.
+func (r *RedisBackend) Bury(ctx context.Context, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	failedAt := time.Now()
+	if job.FailedAt != nil {
+		failedAt = *job.FailedAt
+	}
+	_, _, running, dead, scores := r.keys()
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.jobKey(job.ID), data, 0)
+		pipe.ZRem(ctx, running, job.ID)
+		pipe.HDel(ctx, scores, job.ID)
+		pipe.ZAdd(ctx, dead, redis.Z{Score: float64(failedAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	return err
+}
+
+// Requeue implements Backend.
+func (r *RedisBackend) Requeue(ctx context.Context, id string, now time.Time) (bool, error) {
+	_, _, _, dead, _ := r.keys()
+	// Removing the ID first keeps two operators from requeueing it twice
+	removed, err := r.client.ZRem(ctx, dead, id).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+	job, err := r.get(ctx, id)
+	if err != nil || job == nil {
+		return false, err
+	}
+	return true, r.schedule(ctx, requeued(*job, now), now)
+}
+
+// Discard implements Backend.
+func (r *RedisBackend) Discard(ctx context.Context, id string) (bool, error) {
+	_, _, _, dead, _ := r.keys()
+	removed, err := r.client.ZRem(ctx, dead, id).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+	return true, r.client.Del(ctx, r.jobKey(id)).Err()
+}
+
+func (r *RedisBackend) get(ctx context.Context, id string) (*Job, error) {
+	data, err := r.client.Get(ctx, r.jobKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Dead implements Backend.
+func (r *RedisBackend) Dead(ctx context.Context, limit int) ([]Job, error) {
+	_, _, _, dead, _ := r.keys()
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit) - 1
+	}
+	ids, err := r.client.ZRevRange(ctx, dead, 0, stop).Result()
+	if err != nil || len(ids) == 0 {
+		return []Job{}, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.jobKey(id)
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// Depth implements Backend.
+func (r *RedisBackend) Depth(ctx context.Context) (Depth, error) {
+	ready, scheduled, running, dead, _ := r.keys()
+	var counts [4]*redis.IntCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range []string{ready, scheduled, running, dead} {
+			counts[i] = pipe.ZCard(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return Depth{}, err
+	}
+	return Depth{
+		Ready:     counts[0].Val(),
+		Scheduled: counts[1].Val(),
+		Running:   counts[2].Val(),
+		Dead:      counts[3].Val(),
+	}, nil
+}
@@ -1,12 +1,20 @@
 package registry
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
 
 // Dependencies holds all infrastructure dependencies that services might need
 type Dependencies struct {
+	*store
+	// lookups records the names looked up through a view made by tracked
+	lookups *lookups
+}
+
+// store is the component map shared by a container and its tracked views
+type store struct {
 	// Dynamic component store - no static declarations
 	components map[string]interface{}
 	mu         sync.RWMutex
@@ -18,10 +26,16 @@ type Dependencies struct {
 
 // NewDependencies creates a new dependencies container
 func NewDependencies() *Dependencies {
-	return &Dependencies{
+	return &Dependencies{store: &store{
 		components: make(map[string]interface{}),
 		cacheTTL:   2 * time.Second, // reduced copy frequency 4x from 500ms default
-	}
+	}}
+}
+
+// tracked returns a view of d, sharing its components, that records the
+// names looked up through it.
+func (d *Dependencies) tracked() *Dependencies {
+	return &Dependencies{store: d.store, lookups: &lookups{names: make(map[string]bool)}}
 }
 
 // Set stores a component by name
@@ -36,10 +50,24 @@ func (d *Dependencies) Set(name string, component interface{}) {
 
 // Get retrieves a component by name
 func (d *Dependencies) Get(name string) (interface{}, bool) {
+	if d.lookups != nil {
+		d.lookups.add(name)
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	comp, ok := d.components[name]
 	return comp, ok
 }
 
+// Delete removes a component, e.g. one that could not be re-created
+func (d *Dependencies) Delete(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.components, name)
+	d.cachedAll = nil
+	d.cacheExpiry = time.Time{}
+}
+
 // GetAll returns all registered components — returns a TTL-cached snapshot
 // to avoid allocating and copying the entire map on every /health/dependencies call.
 func (d *Dependencies) GetAll() map[string]interface{} {
@@ -78,3 +106,27 @@ func GetTyped[T any](d *Dependencies, name string) (T, bool) {
 	typed, ok := comp.(T)
 	return typed, ok
 }
+
+// lookups is the set of names looked up through a tracked view
+type lookups struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func (l *lookups) add(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names[name] = true
+}
+
+// list returns the names looked up so far, sorted
+func (l *lookups) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.names))
+	for name := range l.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
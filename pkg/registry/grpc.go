@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"sort"
+	"stackyrd/config"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/logger"
+	"sync"
+)
+
+// GRPCServiceFactory creates a gRPC service instance with dependencies
+type GRPCServiceFactory func(config *config.Config, logger *logger.Logger, deps *Dependencies) interfaces.GRPCService
+
+// Global registry of gRPC service factories, parallel to serviceFactories
+var grpcServiceFactories = &sync.Map{}
+
+// RegisterGRPCService registers a gRPC service factory for automatic
+// discovery. Like HTTP services it is toggled by services.<name>.
+func RegisterGRPCService(name string, factory GRPCServiceFactory) {
+	if _, exist := grpcServiceFactories.Load(name); !exist && factory != nil {
+		grpcServiceFactories.Store(name, factory)
+	}
+}
+
+// AutoDiscoverGRPCServices creates all enabled gRPC services, in name order
+func AutoDiscoverGRPCServices(
+	config *config.Config,
+	logger *logger.Logger,
+	deps *Dependencies,
+) []interfaces.GRPCService {
+	var names []string
+	grpcServiceFactories.Range(func(nameObj, _ interface{}) bool {
+		names = append(names, nameObj.(string))
+		return true
+	})
+	sort.Strings(names)
+
+	var services []interfaces.GRPCService
+	for _, name := range names {
+		if !config.Services.IsEnabled(name) {
+			logger.Debug("gRPC service disabled via config", "service", name)
+			continue
+		}
+		factoryObj, _ := grpcServiceFactories.Load(name)
+		if service := factoryObj.(GRPCServiceFactory)(config, logger, deps); service != nil {
+			services = append(services, service)
+			logger.Info("Auto-registered gRPC service", "service", name)
+		} else {
+			logger.Warn("gRPC service factory returned nil", "service", name)
+		}
+	}
+	return services
+}
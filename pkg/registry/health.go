@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/interfaces"
+)
+
+// Service health statuses
+const (
+	HealthOK        = "ok"
+	HealthDown      = "down"
+	HealthDegraded  = "degraded" // deferred until its needs connect; see DeferredService
+	HealthDisabled  = "disabled"
+	HealthUnchecked = "unchecked" // the service has no HealthCheck
+)
+
+// DefaultHealthTimeout bounds each service's HealthCheck.
+const DefaultHealthTimeout = 5 * time.Second
+
+// ServiceHealth is the result of one service's health check.
+type ServiceHealth struct {
+	Name      string    `json:"name"`
+	WireName  string    `json:"wire_name"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS float64   `json:"latency_ms,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Healthy reports whether the service does not keep its instance from
+// being ready: it is up, disabled, deferred or has no check. A deferred
+// service has no routes, like one whose factory gave up, so the others
+// keep serving.
+func (h ServiceHealth) Healthy() bool {
+	return h.Status != HealthDown
+}
+
+// CheckHealth runs the HealthCheck of the enabled services implementing
+// interfaces.HealthCheckService concurrently, each bounded by timeout
+// (DefaultHealthTimeout when zero), and returns a result per service in
+// the order given.
+func CheckHealth(ctx context.Context, services []interfaces.Service, timeout time.Duration) []ServiceHealth {
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	results := make([]ServiceHealth, len(services))
+	var wg sync.WaitGroup
+	for i, s := range services {
+		results[i] = ServiceHealth{Name: s.Name(), WireName: s.WireName(), CheckedAt: time.Now()}
+		checker, ok := s.(interfaces.HealthCheckService)
+		switch {
+		case !s.Enabled():
+			results[i].Status = HealthDisabled
+			continue
+		case !ok:
+			results[i].Status = HealthUnchecked
+			continue
+		}
+		wg.Add(1)
+		go func(result *ServiceHealth) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			// A check that ignores ctx is abandoned at the timeout
+			done := make(chan error, 1)
+			go func() { done <- checker.HealthCheck(checkCtx) }()
+			var err error
+			select {
+			case err = <-done:
+			case <-checkCtx.Done():
+				err = checkCtx.Err()
+			}
+			result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+			switch {
+			case errors.Is(err, ErrNeedsUnmet):
+				result.Status, result.Error = HealthDegraded, err.Error()
+				return
+			case err != nil:
+				result.Status, result.Error = HealthDown, err.Error()
+				return
+			}
+			result.Status = HealthOK
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// Health checks the registered services; see CheckHealth.
+func (r *ServiceRegistry) Health(ctx context.Context, timeout time.Duration) []ServiceHealth {
+	return CheckHealth(ctx, r.services, timeout)
+}
@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"stackyrd/pkg/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNeedsUnmet is the health check error of a DeferredService.
+var ErrNeedsUnmet = errors.New("waiting for dependencies")
+
+var (
+	// factoryNeeds holds the needs declared with RegisterService, by
+	// registry key
+	factoryNeeds = &sync.Map{}
+
+	// serviceNeeds holds the needs of each discovered service, by service
+	// name
+	serviceNeeds = &sync.Map{}
+)
+
+// ServiceNeeds returns the needs the discovered service declared when it
+// was registered.
+func ServiceNeeds(name string) []string {
+	val, ok := serviceNeeds.Load(name)
+	if !ok {
+		return nil
+	}
+	return val.([]string)
+}
+
+// NeedComponent returns the dependency a need is about: "postgres" for
+// "postgres:tenant_a".
+func NeedComponent(need string) string {
+	component, _, _ := strings.Cut(need, ":")
+	return component
+}
+
+// UnmetNeeds returns the needs that are not connected in deps, in the
+// order given. A need is a dependency name, e.g. "kafka", met when it is
+// registered and its status does not report it disconnected, or a
+// dependency and one of its connections, e.g. "postgres:tenant_a", met
+// when that connection reports itself connected. Looking needs up is not
+// recorded as a use of the dependency.
+func UnmetNeeds(deps *Dependencies, needs []string) []string {
+	var unmet []string
+	for _, need := range needs {
+		if !needMet(deps, need) {
+			unmet = append(unmet, need)
+		}
+	}
+	return unmet
+}
+
+func needMet(deps *Dependencies, need string) bool {
+	name, connection, qualified := strings.Cut(need, ":")
+	deps.mu.RLock()
+	component, ok := deps.components[name]
+	deps.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	reporter, ok := component.(interface{ GetStatus() map[string]interface{} })
+	if !ok {
+		return !qualified
+	}
+	status := reporter.GetStatus()
+	if qualified {
+		return connected(status[connection])
+	}
+	if _, ok := status["connected"]; ok {
+		return connected(status)
+	}
+	// A connection manager is connected when one of its connections is
+	for _, value := range status {
+		if connected(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// connected reports whether status is a component status with
+// "connected": true.
+func connected(status interface{}) bool {
+	fields, ok := status.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	up, _ := fields["connected"].(bool)
+	return up
+}
+
+// DeferredService stands in for an enabled service whose needs were not
+// connected when the services were discovered: its factory was not called
+// and it has no routes. Its health check reports it degraded with the
+// missing needs until the services are discovered again.
+type DeferredService struct {
+	Key     string   // registry key, e.g. tasks_service
+	Needs   []string // all the needs declared
+	Missing []string // the needs not connected
+}
+
+func (s *DeferredService) Name() string                      { return s.Key }
+func (s *DeferredService) WireName() string                  { return strings.TrimSuffix(s.Key, "_service") }
+func (s *DeferredService) Enabled() bool                     { return true }
+func (s *DeferredService) Endpoints() []string               { return nil }
+func (s *DeferredService) RegisterRoutes(g *gin.RouterGroup) {}
+func (s *DeferredService) Get() interface{}                  { return s }
+
+// HealthCheck reports the needs that were missing.
+func (s *DeferredService) HealthCheck(ctx context.Context) error {
+	return fmt.Errorf("%w: %s", ErrNeedsUnmet, strings.Join(s.Missing, ", "))
+}
+
+// Ready reports whether the needs of the deferred service are connected
+// now, so discovering the services again would create it.
+func (s *DeferredService) Ready(deps *Dependencies) bool {
+	return len(UnmetNeeds(deps, s.Needs)) == 0
+}
+
+// DeferredServices returns the deferred services among services.
+func DeferredServices(services []interfaces.Service) []*DeferredService {
+	var deferred []*DeferredService
+	for _, s := range services {
+		if d, ok := s.(*DeferredService); ok {
+			deferred = append(deferred, d)
+		}
+	}
+	return deferred
+}
+
+// needLookups records the dependencies of needs as looked up, so the
+// dependency graph links a deferred service to them.
+func needLookups(needs []string) *lookups {
+	l := &lookups{names: make(map[string]bool)}
+	for _, need := range needs {
+		l.add(NeedComponent(need))
+	}
+	return l
+}
+
+// sortedKeys returns the registry keys of the factories, sorted.
+func sortedKeys() []string {
+	var keys []string
+	serviceFactories.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,8 @@
 package registry
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"stackyrd/config"
 	"stackyrd/pkg/interfaces"
@@ -21,17 +23,37 @@ var serviceFactories = &sync.Map{}
 var (
 	serviceDiscovered = &sync.Map{}
 	// serviceDiscoveredMu removed: sync.Map is lock-free for reads
+
+	// serviceLookups holds the dependency names each discovered service
+	// looked up, by service name
+	serviceLookups = &sync.Map{}
+
+	// serviceKeys holds the registry key of each discovered service, by
+	// service name
+	serviceKeys = &sync.Map{}
 )
 
-// RegisterService registers a service factory for automatic discovery
-func RegisterService(name string, factory ServiceFactory) {
+// RouteGroupFunc returns the route group the service registered as key
+// registers its routes on, given the services group.
+type RouteGroupFunc func(key string, api *gin.RouterGroup) (*gin.RouterGroup, error)
+
+// RegisterService registers a service factory for automatic discovery.
+// needs are the dependencies the service cannot work without, e.g. "kafka"
+// or "postgres:tenant_a" for one connection; while one is not connected
+// the factory is not called and a DeferredService stands in for it (see
+// UnmetNeeds).
+func RegisterService(name string, factory ServiceFactory, needs ...string) {
 	// avoid duplicate register if service has same name
 	if _, exist := serviceFactories.Load(name); !exist && factory != nil {
 		serviceFactories.Store(name, factory)
+		factoryNeeds.Store(name, needs)
 	}
 }
 
-// AutoDiscoverServices automatically discovers and creates all enabled services
+// AutoDiscoverServices automatically discovers and creates all enabled
+// services, in the order of their registry keys. Services whose needs are
+// not connected in deps are not created; a DeferredService takes their
+// place.
 func AutoDiscoverServices(
 	config *config.Config,
 	logger *logger.Logger,
@@ -39,23 +61,41 @@ func AutoDiscoverServices(
 ) []interfaces.Service {
 	var services []interfaces.Service
 
-	serviceFactories.Range(func(nameObj, factoryObj interface{}) bool {
-		name := nameObj.(string)
+	for _, name := range sortedKeys() {
+		factoryObj, _ := serviceFactories.Load(name)
 		factory := factoryObj.(ServiceFactory)
+		if !config.Services.IsEnabled(name) {
+			logger.Debug("Service disabled via config", "service", name)
+			continue
+		}
+
+		var needs []string
+		if val, ok := factoryNeeds.Load(name); ok {
+			needs = val.([]string)
+		}
+		if missing := UnmetNeeds(deps, needs); len(missing) > 0 {
+			deferred := &DeferredService{Key: name, Needs: needs, Missing: missing}
+			services = append(services, deferred)
+			logger.Warn("Service deferred until its dependencies connect", "service", name, "missing", missing)
+			serviceLookups.Store(deferred.Name(), needLookups(needs))
+			serviceNeeds.Store(deferred.Name(), needs)
+			serviceKeys.Store(deferred.Name(), name)
+			continue
+		}
+
 		logger.Debug("Creating service", "name", name)
-		if config.Services.IsEnabled(name) {
-			if service := factory(config, logger, deps); service != nil {
-				services = append(services, service)
-				logger.Info("Auto-registered service", "service", name)
-				serviceDiscovered.Store(service.Name(), service.Get())
-			} else {
-				logger.Warn("Service factory returned nil", "service", name)
-			}
+		view := deps.tracked()
+		if service := factory(config, logger, view); service != nil {
+			services = append(services, service)
+			logger.Info("Auto-registered service", "service", name)
+			serviceDiscovered.Store(service.Name(), service.Get())
+			serviceLookups.Store(service.Name(), view.lookups)
+			serviceNeeds.Store(service.Name(), needs)
+			serviceKeys.Store(service.Name(), name)
 		} else {
-			logger.Debug("Service disabled via config", "service", name)
+			logger.Warn("Service factory returned nil", "service", name)
 		}
-		return true
-	})
+	}
 
 	return services
 }
@@ -64,6 +104,11 @@ func AutoDiscoverServices(
 type ServiceRegistry struct {
 	services []interfaces.Service
 	logger   *logger.Logger
+
+	mu      sync.Mutex
+	started []interfaces.Service // started lifecycle services, in start order
+
+	routeGroup RouteGroupFunc
 }
 
 // NewServiceRegistry creates a new service registry
@@ -84,6 +129,26 @@ func GetServiceFactories() map[string]ServiceFactory {
 	return result
 }
 
+// ServiceDependencies returns the names of the dependencies the discovered
+// service looked up, at creation or since, sorted. Names that were not
+// registered are included.
+func ServiceDependencies(name string) []string {
+	val, ok := serviceLookups.Load(name)
+	if !ok {
+		return nil
+	}
+	return val.(*lookups).list()
+}
+
+// ServiceKey returns the registry key, which is also the services config
+// key, of the discovered service, or name when it was not discovered.
+func ServiceKey(name string) string {
+	if val, ok := serviceKeys.Load(name); ok {
+		return val.(string)
+	}
+	return name
+}
+
 func GetService(name string) interface{} {
 	val, _ := serviceDiscovered.Load(name)
 	return val
@@ -123,29 +188,87 @@ func (r *ServiceRegistry) GetServices() []interfaces.Service {
 	return r.services
 }
 
+// SetRouteGroup makes Boot register the routes of each service on the
+// group fn returns for it, e.g. one with the service's own middleware. A
+// service whose group fails is not given routes.
+func (r *ServiceRegistry) SetRouteGroup(fn RouteGroupFunc) {
+	r.routeGroup = fn
+}
+
 // Boot initializes enabled services and registers their routes
 func (r *ServiceRegistry) Boot(engine *gin.Engine) {
 	api := engine.Group(viper.GetString("server.services_endpoint"))
 
 	for _, s := range r.services {
-		if s.Enabled() {
-			r.logger.Info("Starting Service...", "service", s.Name())
-			s.RegisterRoutes(api)
-			r.logger.Info("Service Started", "service", s.Name())
-		} else {
-			r.logger.Warn("Service Skipped (Disabled via config)", "service", s.Name())
-		}
+		r.boot(api, s)
 	}
 }
 
 // BootService boots a single service (for dynamic registration)
 func (r *ServiceRegistry) BootService(engine *gin.Engine, s interfaces.Service) {
-	if s.Enabled() {
-		api := engine.Group(viper.GetString("server.services_endpoint"))
-		r.logger.Info("Starting Service...", "service", s.Name())
-		s.RegisterRoutes(api)
-		r.logger.Info("Service Started", "service", s.Name())
-	} else {
+	r.boot(engine.Group(viper.GetString("server.services_endpoint")), s)
+}
+
+func (r *ServiceRegistry) boot(api *gin.RouterGroup, s interfaces.Service) {
+	if !s.Enabled() {
 		r.logger.Warn("Service Skipped (Disabled via config)", "service", s.Name())
+		return
+	}
+	group := api
+	if r.routeGroup != nil {
+		var err error
+		if group, err = r.routeGroup(ServiceKey(s.Name()), api); err != nil {
+			r.logger.Error("Service Skipped (invalid middleware)", err, "service", s.Name())
+			return
+		}
+	}
+	r.logger.Info("Starting Service...", "service", s.Name())
+	s.RegisterRoutes(group)
+	r.logger.Info("Service Started", "service", s.Name())
+}
+
+// Start calls Start on the enabled services implementing
+// interfaces.LifecycleService, in registration order. A service that fails
+// to start is logged, left out of Stop and reported in the error; the
+// others still start.
+func (r *ServiceRegistry) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, s := range r.services {
+		lifecycle, ok := s.(interfaces.LifecycleService)
+		if !ok || !s.Enabled() {
+			continue
+		}
+		if err := lifecycle.Start(ctx); err != nil {
+			r.logger.Error("Service failed to start", err, "service", s.Name())
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		r.started = append(r.started, s)
+		r.logger.Debug("Service background work started", "service", s.Name())
+	}
+	return errors.Join(errs...)
+}
+
+// Stop calls Stop on the services Start started, in reverse order, and
+// reports the ones that failed or did not stop before ctx was done.
+func (r *ServiceRegistry) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	started := r.started
+	r.started = nil
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		s := started[i]
+		if err := s.(interfaces.LifecycleService).Stop(ctx); err != nil {
+			r.logger.Error("Service failed to stop", err, "service", s.Name())
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		r.logger.Debug("Service background work stopped", "service", s.Name())
 	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,95 @@
+package remoteconfig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// consulWait is how long a Consul blocking query waits for a change.
+const consulWait = 5 * time.Minute
+
+// Consul reads a key of the Consul KV store, watching it with blocking
+// queries.
+type Consul struct {
+	base    string
+	key     string
+	dc      string
+	token   string
+	timeout time.Duration
+	http    *http.Client
+}
+
+func (c *Consul) Name() string     { return "consul" }
+func (c *Consul) Endpoint() string { return c.base }
+func (c *Consul) Key() string      { return c.key }
+
+// Get reads the current value.
+func (c *Consul) Get(ctx context.Context) (Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.read(ctx, "", 0)
+}
+
+// Wait issues blocking queries until the index moves past after.
+func (c *Consul) Wait(ctx context.Context, after string) (Value, error) {
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, consulWait+c.timeout)
+		v, err := c.read(reqCtx, after, consulWait)
+		cancel()
+		if ctx.Err() != nil {
+			return Value{}, ctx.Err()
+		}
+		if err != nil || v.Version != after {
+			return v, err
+		}
+	}
+}
+
+// read fetches the raw value, blocking up to wait for the index to move
+// past index when both are set.
+func (c *Consul) read(ctx context.Context, index string, wait time.Duration) (Value, error) {
+	query := url.Values{"raw": {""}}
+	if c.dc != "" {
+		query.Set("dc", c.dc)
+	}
+	if index != "" && wait > 0 {
+		query.Set("index", index)
+		query.Set("wait", fmt.Sprintf("%ds", int(wait.Seconds())))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/v1/kv/"+c.key+"?"+query.Encode(), nil)
+	if err != nil {
+		return Value{}, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Value{}, fmt.Errorf("consul: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Value{}, fmt.Errorf("consul: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return Value{Data: data, Version: resp.Header.Get("X-Consul-Index")}, nil
+	case http.StatusNotFound:
+		return Value{Version: resp.Header.Get("X-Consul-Index")}, fmt.Errorf("consul %s: %w", c.key, ErrNotFound)
+	}
+	return Value{}, fmt.Errorf("consul %s: HTTP %d: %s", c.key, resp.StatusCode, truncate(data))
+}
+
+// truncate shortens an error body for messages.
+func truncate(data []byte) string {
+	if len(data) > 200 {
+		return string(data[:200]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,208 @@
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Etcd reads a key of etcd through its v3 JSON gateway, watching it with
+// a watch stream.
+type Etcd struct {
+	base     string
+	key      string
+	user     string
+	password string
+	timeout  time.Duration
+	http     *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+func (e *Etcd) Name() string     { return "etcd" }
+func (e *Etcd) Endpoint() string { return e.base }
+func (e *Etcd) Key() string      { return e.key }
+
+// etcdKV is a key-value pair; the gateway encodes bytes as base64 and
+// 64-bit integers as strings.
+type etcdKV struct {
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+func (kv etcdKV) decode() (Value, error) {
+	data, err := base64.StdEncoding.DecodeString(kv.Value)
+	if err != nil {
+		return Value{}, fmt.Errorf("etcd: %w", err)
+	}
+	return Value{Data: data, Version: kv.ModRevision}, nil
+}
+
+// Get reads the current value.
+func (e *Etcd) Get(ctx context.Context) (Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	var out struct {
+		Kvs []etcdKV `json:"kvs"`
+	}
+	if err := e.call(ctx, "/v3/kv/range", map[string]string{"key": e.encodedKey()}, &out); err != nil {
+		return Value{}, err
+	}
+	if len(out.Kvs) == 0 {
+		return Value{}, fmt.Errorf("etcd %s: %w", e.key, ErrNotFound)
+	}
+	return out.Kvs[0].decode()
+}
+
+// Wait opens a watch starting after revision after and returns the first
+// change.
+func (e *Etcd) Wait(ctx context.Context, after string) (Value, error) {
+	create := map[string]interface{}{"key": e.encodedKey()}
+	if rev, err := strconv.ParseInt(after, 10, 64); err == nil {
+		create["start_revision"] = strconv.FormatInt(rev+1, 10)
+	}
+	resp, err := e.do(ctx, "/v3/watch", map[string]interface{}{"create_request": create})
+	if err != nil {
+		return Value{}, err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Canceled     bool   `json:"canceled"`
+				CancelReason string `json:"cancel_reason"`
+				Events       []struct {
+					Type string `json:"type"`
+					KV   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if ctx.Err() != nil {
+				return Value{}, ctx.Err()
+			}
+			return Value{}, fmt.Errorf("etcd watch: %w", err)
+		}
+		if msg.Error != nil {
+			return Value{}, fmt.Errorf("etcd watch: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			return Value{}, fmt.Errorf("etcd watch canceled: %s", msg.Result.CancelReason)
+		}
+		for _, ev := range msg.Result.Events {
+			if ev.Type == "DELETE" {
+				return Value{Version: ev.KV.ModRevision}, fmt.Errorf("etcd %s: %w", e.key, ErrNotFound)
+			}
+			if ev.KV.ModRevision != after {
+				return ev.KV.decode()
+			}
+		}
+	}
+}
+
+func (e *Etcd) encodedKey() string {
+	return base64.StdEncoding.EncodeToString([]byte(e.key))
+}
+
+// call posts body to path and decodes the JSON response into out.
+func (e *Etcd) call(ctx context.Context, path string, body, out interface{}) error {
+	resp, err := e.do(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// do posts body to path with the auth token, authenticating first when
+// credentials are set, and returns a 200 response.
+func (e *Etcd) do(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		token, err := e.authToken(ctx, attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.base+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := e.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("etcd: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// An expired token is renewed once
+		if resp.StatusCode == http.StatusUnauthorized && e.user != "" && attempt == 0 {
+			continue
+		}
+		return nil, fmt.Errorf("etcd %s: HTTP %d: %s", path, resp.StatusCode, truncate(data))
+	}
+}
+
+// authToken returns the token for user/password auth, authenticating when
+// there is none yet or renew is set. Without credentials it is empty.
+func (e *Etcd) authToken(ctx context.Context, renew bool) (string, error) {
+	if e.user == "" {
+		return "", nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token != "" && !renew {
+		return e.token, nil
+	}
+
+	authCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	payload, _ := json.Marshal(map[string]string{"name": e.user, "password": e.password})
+	req, err := http.NewRequestWithContext(authCtx, http.MethodPost, e.base+"/v3/auth/authenticate", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("etcd auth: %w", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Token string `json:"token"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("etcd auth: HTTP %d: %s", resp.StatusCode, truncate(data))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("etcd auth: %w", err)
+	}
+	if out.Token == "" {
+		return "", errors.New("etcd auth: no token returned")
+	}
+	e.token = out.Token
+	return e.token, nil
+}
@@ -0,0 +1,102 @@
+// Package remoteconfig loads the configuration from a central key-value
+// store (etcd or Consul KV) and watches it, so fleets of instances share
+// one centrally managed config. Sources are URLs given to -c:
+//
+//	etcd://[user:password@]host:2379/<key>    (etcds:// for TLS)
+//	consul://[token@]host:8500/<key>?dc=<dc>  (consuls:// for TLS)
+//
+// The key is the URL path without its leading slash, so an etcd key
+// starting with "/" needs two. The Consul token may also come from
+// CONSUL_HTTP_TOKEN. The document is YAML unless the key ends in .json or
+// .toml.
+package remoteconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the key does not exist (or was deleted).
+var ErrNotFound = errors.New("remote config key not found")
+
+// DefaultTimeout bounds requests that do not wait for changes.
+const DefaultTimeout = 10 * time.Second
+
+// Value is the config document stored under the key and its version in
+// the store (the Consul index or etcd mod revision).
+type Value struct {
+	Data    []byte
+	Version string
+}
+
+// Provider reads one key of a key-value store.
+type Provider interface {
+	// Name is the store, "etcd" or "consul".
+	Name() string
+	// Endpoint is the store address without credentials.
+	Endpoint() string
+	// Key is the key holding the config document.
+	Key() string
+	// Get reads the current value.
+	Get(ctx context.Context) (Value, error)
+	// Wait blocks until the value's version differs from after and returns
+	// it, or returns ctx.Err() when ctx ends first.
+	Wait(ctx context.Context, after string) (Value, error)
+}
+
+var schemes = map[string]bool{"etcd": true, "etcds": true, "consul": true, "consuls": true}
+
+// IsRemote reports whether source names a key-value store rather than a
+// plain HTTP URL.
+func IsRemote(source string) bool {
+	scheme, _, ok := strings.Cut(source, "://")
+	return ok && schemes[strings.ToLower(scheme)]
+}
+
+// Open returns the provider for a source URL. timeout bounds every
+// request other than the long waits for changes.
+func Open(source string, timeout time.Duration) (Provider, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote config source: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("remote config source needs a host and a key: %s", u.Redacted())
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	httpScheme := "http"
+	if strings.HasSuffix(scheme, "s") {
+		httpScheme = "https"
+	}
+	base := httpScheme + "://" + u.Host
+	// Waits outlive the request timeout, so it is applied per request
+	client := &http.Client{}
+
+	switch strings.TrimSuffix(scheme, "s") {
+	case "etcd":
+		e := &Etcd{base: base, key: key, timeout: timeout, http: client}
+		if u.User != nil {
+			e.user = u.User.Username()
+			e.password, _ = u.User.Password()
+		}
+		return e, nil
+	case "consul":
+		c := &Consul{base: base, key: key, timeout: timeout, http: client, dc: u.Query().Get("dc"), token: os.Getenv("CONSUL_HTTP_TOKEN")}
+		if u.User != nil && u.User.Username() != "" {
+			c.token = u.User.Username()
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("unsupported remote config source %q, use etcd:// or consul://", u.Scheme)
+}
@@ -0,0 +1,199 @@
+package remoteconfig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/logger"
+)
+
+// Refresh modes.
+const (
+	RefreshWatch = "watch"
+	RefreshPoll  = "poll"
+)
+
+// State is the result of the latest read of the remote config.
+type State struct {
+	CheckedAt   time.Time `json:"checked_at"`
+	Version     string    `json:"version,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Changed     bool      `json:"changed"` // differs from the running configuration
+	Error       string    `json:"error,omitempty"`
+}
+
+// OK reports whether the value could be read and decoded.
+func (s State) OK() bool {
+	return s.Error == ""
+}
+
+// Watcher follows the remote config, by watching the key or polling it,
+// and compares it with the configuration the process runs with. A change
+// that decodes is handed to OnChange once per new fingerprint.
+type Watcher struct {
+	provider Provider
+	running  string
+	mode     string
+	interval time.Duration
+	logger   *logger.Logger
+
+	// OnChange is called with each new valid config that differs from the
+	// running one, e.g. to restart with it.
+	OnChange func(State)
+
+	mu       sync.RWMutex
+	last     State
+	has      bool
+	notified string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWatcher creates a watcher of p for a process running with the
+// configuration fingerprinted as running; call Start to begin.
+func NewWatcher(p Provider, running, mode string, interval time.Duration, l *logger.Logger) *Watcher {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if mode != RefreshPoll {
+		mode = RefreshWatch
+	}
+	return &Watcher{provider: p, running: running, mode: mode, interval: interval, logger: l}
+}
+
+// Name returns the display name of the component.
+func (w *Watcher) Name() string {
+	return "Remote Config"
+}
+
+// Start reads the value now and then follows it until Close.
+func (w *Watcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(ctx)
+	}()
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	for {
+		state := w.Check(ctx)
+		if w.mode == RefreshWatch && state.OK() {
+			// Follow changes until the watch fails, then read again
+			for {
+				v, err := w.provider.Wait(ctx, state.Version)
+				if ctx.Err() != nil {
+					return
+				}
+				state = w.record(v, err)
+				if !state.OK() {
+					break
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// Close stops following the remote config.
+func (w *Watcher) Close() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+	return nil
+}
+
+// Check reads the remote config now and records the result.
+func (w *Watcher) Check(ctx context.Context) State {
+	v, err := w.provider.Get(ctx)
+	return w.record(v, err)
+}
+
+// record decodes a read value, compares it with the running configuration
+// and reports transitions.
+func (w *Watcher) record(v Value, err error) State {
+	state := State{CheckedAt: time.Now(), Version: v.Version}
+	if err == nil {
+		var cfg *config.Config
+		if cfg, err = config.ReadConfigData(v.Data, config.FormatOf(w.provider.Key())); err == nil {
+			state.Fingerprint = config.Fingerprint(cfg)
+			state.Changed = state.Fingerprint != w.running
+		}
+	}
+	if err != nil {
+		state.Error = err.Error()
+	}
+
+	w.mu.Lock()
+	prev, hadPrev := w.last, w.has
+	w.last, w.has = state, true
+	notify := state.Changed && state.Fingerprint != w.notified
+	if notify {
+		w.notified = state.Fingerprint
+	}
+	w.mu.Unlock()
+
+	w.logTransition(prev, hadPrev, state, err)
+	if notify && w.OnChange != nil {
+		w.OnChange(state)
+	}
+	return state
+}
+
+func (w *Watcher) logTransition(prev State, hadPrev bool, cur State, err error) {
+	if w.logger == nil {
+		return
+	}
+	source := w.provider.Name() + ":" + w.provider.Key()
+	switch {
+	case !cur.OK():
+		if !hadPrev || prev.OK() {
+			if errors.Is(err, ErrNotFound) {
+				w.logger.Warn("Remote config key is missing; keeping the running configuration", "source", source)
+			} else {
+				w.logger.Warn("Remote config read failed; keeping the running configuration", "source", source, "error", cur.Error)
+			}
+		}
+	case cur.Changed:
+		if !hadPrev || !prev.Changed || prev.Fingerprint != cur.Fingerprint {
+			w.logger.Warn("Remote config changed", "source", source, "version", cur.Version,
+				"running", w.running, "remote", cur.Fingerprint)
+		}
+	case hadPrev && !prev.OK():
+		w.logger.Info("Remote config readable again", "source", source, "version", cur.Version)
+	}
+}
+
+// Last returns the most recent read, if any.
+func (w *Watcher) Last() (State, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.last, w.has
+}
+
+// Status summarizes the watcher for the monitoring API.
+func (w *Watcher) Status() map[string]interface{} {
+	status := map[string]interface{}{
+		"provider":         w.provider.Name(),
+		"endpoint":         w.provider.Endpoint(),
+		"key":              w.provider.Key(),
+		"refresh":          w.mode,
+		"interval_seconds": int64(w.interval.Seconds()),
+		"running":          w.running,
+	}
+	if last, ok := w.Last(); ok {
+		status["last"] = last
+	}
+	return status
+}
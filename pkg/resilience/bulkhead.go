@@ -0,0 +1,74 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBulkheadFull is returned for calls refused by a full bulkhead.
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// Bulkhead bounds the calls in flight to a dependency, so a slow
+// dependency cannot take every goroutine and connection of the process.
+type Bulkhead struct {
+	slots    chan struct{}
+	maxWait  time.Duration
+	rejected atomic.Int64
+}
+
+// NewBulkhead creates a bulkhead letting maxConcurrent calls through at
+// once; a call waits at most maxWait for a slot.
+func NewBulkhead(maxConcurrent int, maxWait time.Duration) *Bulkhead {
+	return &Bulkhead{
+		slots:   make(chan struct{}, max(maxConcurrent, 1)),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire takes a slot, waiting at most maxWait or until ctx is done, and
+// returns the function giving it back.
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	release = func() { <-b.slots }
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if b.maxWait <= 0 {
+		b.rejected.Add(1)
+		return nil, ErrBulkheadFull
+	}
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		b.rejected.Add(1)
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Execute runs fn in a slot of the bulkhead.
+func (b *Bulkhead) Execute(ctx context.Context, fn func() error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// GetStats returns bulkhead statistics
+func (b *Bulkhead) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"max_concurrent": cap(b.slots),
+		"in_flight":      len(b.slots),
+		"rejected":       b.rejected.Load(),
+	}
+}
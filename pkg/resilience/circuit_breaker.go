@@ -6,6 +6,9 @@ import (
 	"time"
 )
 
+// ErrCircuitOpen is returned for calls refused by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
 // State represents the circuit breaker state
 type State int
 
@@ -54,7 +57,8 @@ type CircuitBreaker struct {
 	failures        int
 	successes       int
 	lastFailureTime time.Time
-	halfOpenCount   int
+	halfOpenCount   int // trial requests let through while half-open
+	halfOpenSuccess int // of which succeeded
 	mu              sync.RWMutex
 }
 
@@ -69,7 +73,7 @@ func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
 // Execute executes a function with circuit breaker protection
 func (cb *CircuitBreaker) Execute(fn func() error) error {
 	if !cb.AllowRequest() {
-		return errors.New("circuit breaker is open")
+		return ErrCircuitOpen
 	}
 
 	err := fn()
@@ -89,7 +93,7 @@ func (cb *CircuitBreaker) ExecuteWithFallback(fn func() error, fallback func() e
 		if fallback != nil {
 			return fallback()
 		}
-		return errors.New("circuit breaker is open")
+		return ErrCircuitOpen
 	}
 
 	err := fn()
@@ -106,21 +110,31 @@ func (cb *CircuitBreaker) ExecuteWithFallback(fn func() error, fallback func() e
 	return nil
 }
 
-// AllowRequest checks if a request is allowed
+// AllowRequest checks if a request is allowed. Once ResetTimeout has passed
+// since the last failure, an open breaker turns half-open and lets
+// HalfOpenMaxRequests trial requests through; each request allowed must be
+// followed by RecordSuccess or RecordFailure.
 func (cb *CircuitBreaker) AllowRequest() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
 	switch cb.state {
 	case StateClosed:
 		return true
 	case StateOpen:
-		if time.Since(cb.lastFailureTime) > cb.config.ResetTimeout {
-			return true
+		if time.Since(cb.lastFailureTime) <= cb.config.ResetTimeout {
+			return false
 		}
-		return false
+		cb.setState(StateHalfOpen)
+		cb.halfOpenCount = 0
+		cb.halfOpenSuccess = 0
+		fallthrough
 	case StateHalfOpen:
-		return cb.halfOpenCount < cb.config.HalfOpenMaxRequests
+		if cb.halfOpenCount >= max(cb.config.HalfOpenMaxRequests, 1) {
+			return false
+		}
+		cb.halfOpenCount++
+		return true
 	default:
 		return false
 	}
@@ -134,11 +148,12 @@ func (cb *CircuitBreaker) RecordSuccess() {
 	cb.successes++
 
 	if cb.state == StateHalfOpen {
-		cb.halfOpenCount++
-		if cb.halfOpenCount >= cb.config.HalfOpenMaxRequests {
+		cb.halfOpenSuccess++
+		if cb.halfOpenSuccess >= max(cb.config.HalfOpenMaxRequests, 1) {
 			cb.setState(StateClosed)
 			cb.failures = 0
 			cb.halfOpenCount = 0
+			cb.halfOpenSuccess = 0
 		}
 	} else if cb.state == StateClosed {
 		cb.failures = 0
@@ -156,11 +171,22 @@ func (cb *CircuitBreaker) RecordFailure() {
 	if cb.state == StateHalfOpen {
 		cb.setState(StateOpen)
 		cb.halfOpenCount = 0
+		cb.halfOpenSuccess = 0
 	} else if cb.state == StateClosed && cb.failures >= cb.config.MaxFailures {
 		cb.setState(StateOpen)
 	}
 }
 
+// forget gives back the trial slot of an allowed request that ended
+// without telling whether the dependency works.
+func (cb *CircuitBreaker) forget() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == StateHalfOpen && cb.halfOpenCount > 0 {
+		cb.halfOpenCount--
+	}
+}
+
 // setState changes the circuit breaker state
 func (cb *CircuitBreaker) setState(newState State) {
 	if cb.state != newState {
@@ -203,6 +229,7 @@ func (cb *CircuitBreaker) Reset() {
 	cb.failures = 0
 	cb.successes = 0
 	cb.halfOpenCount = 0
+	cb.halfOpenSuccess = 0
 }
 
 // CircuitBreakerManager manages multiple circuit breakers
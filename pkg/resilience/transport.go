@@ -0,0 +1,174 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// TransportConfig configures a Transport.
+type TransportConfig struct {
+	Name          string        // prefixes the breaker names, e.g. "grafana"
+	MaxFailures   int           // consecutive failures opening the breaker of a host; 0 disables breakers
+	ResetTimeout  time.Duration // how long a breaker stays open before a trial request
+	Retry         RetryConfig   // MaxAttempts counts the first attempt
+	MaxConcurrent int           // requests in flight at once; 0 for no bulkhead
+	MaxWait       time.Duration // how long a request waits for the bulkhead
+}
+
+// Transport is an http.RoundTripper guarding outbound calls with a circuit
+// breaker per host, bounded retries with jitter and a bulkhead. Requests
+// fail on transport errors and 5xx responses; idempotent requests (GET,
+// HEAD, OPTIONS, PUT, DELETE, or any carrying an Idempotency-Key) are
+// retried on those and on 429, others are sent once. A request refused by
+// an open breaker fails with ErrCircuitOpen, one refused by a full bulkhead
+// with ErrBulkheadFull.
+type Transport struct {
+	base     http.RoundTripper
+	config   TransportConfig
+	breakers *CircuitBreakerManager
+	bulkhead *Bulkhead
+}
+
+// NewTransport wraps base, http.DefaultTransport when nil.
+func NewTransport(base http.RoundTripper, cfg TransportConfig) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{base: base, config: cfg, breakers: NewCircuitBreakerManager()}
+	if cfg.MaxConcurrent > 0 {
+		t.bulkhead = NewBulkhead(cfg.MaxConcurrent, cfg.MaxWait)
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.bulkhead != nil {
+		release, err := t.bulkhead.Acquire(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", t.config.Name, req.URL.Host, err)
+		}
+		defer release()
+	}
+
+	breaker := t.breaker(req.URL.Host)
+	attempts := 1
+	if retryableRequest(req) {
+		attempts = max(t.config.Retry.MaxAttempts, 1)
+	}
+	for attempt := 1; ; attempt++ {
+		if breaker != nil && !breaker.AllowRequest() {
+			return nil, fmt.Errorf("%s %s: %w", t.config.Name, req.URL.Host, ErrCircuitOpen)
+		}
+		sent := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			sent = req.Clone(ctx)
+			sent.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(sent)
+		if breaker != nil {
+			switch {
+			case err == nil && resp.StatusCode < http.StatusInternalServerError:
+				breaker.RecordSuccess()
+			case errors.Is(err, context.Canceled):
+				// The caller gave up; the host did not fail
+				breaker.forget()
+			default:
+				breaker.RecordFailure()
+			}
+		}
+		if !retryableResult(resp, err) || attempt >= attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(calculateDelay(attempt, t.config.Retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *Transport) breaker(host string) *CircuitBreaker {
+	if t.config.MaxFailures <= 0 {
+		return nil
+	}
+	return t.breakers.GetOrCreate(CircuitBreakerConfig{
+		Name:                t.breakerName(host),
+		MaxFailures:         t.config.MaxFailures,
+		ResetTimeout:        t.config.ResetTimeout,
+		HalfOpenMaxRequests: 1,
+	})
+}
+
+// State returns the state of the breaker of host, closed for hosts not
+// called yet.
+func (t *Transport) State(host string) State {
+	if cb, ok := t.breakers.Get(t.breakerName(host)); ok {
+		return cb.GetState()
+	}
+	return StateClosed
+}
+
+func (t *Transport) breakerName(host string) string {
+	if t.config.Name != "" {
+		return t.config.Name + ":" + host
+	}
+	return host
+}
+
+// GetStats returns the breaker of every host called, by name, and the
+// bulkhead.
+func (t *Transport) GetStats() map[string]interface{} {
+	all := t.breakers.GetAll()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	breakers := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		breakers = append(breakers, all[name].GetStats())
+	}
+	stats := map[string]interface{}{"breakers": breakers}
+	if t.bulkhead != nil {
+		stats["bulkhead"] = t.bulkhead.GetStats()
+	}
+	return stats
+}
+
+// retryableRequest reports whether req may be sent again.
+func retryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryableResult(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
@@ -228,6 +228,16 @@ func Error(c *gin.Context, statusCode int, errorCode string, message string, det
 	})
 }
 
+// CorrelationIDKey is the gin context key under which middleware may store
+// the correlation ID of a request, e.g. the trace ID set by tracing.
+const CorrelationIDKey = "correlation_id"
+
+// CorrelationID returns the correlation ID of the request, the same one
+// the response envelope carries, for handlers that write their own body.
+func CorrelationID(c *gin.Context) string {
+	return getCorrelationID(c)
+}
+
 // getCorrelationID extracts or generates the correlation ID
 func getCorrelationID(c *gin.Context) string {
 	// Try standard request ID
@@ -235,6 +245,9 @@ func getCorrelationID(c *gin.Context) string {
 	if id == "" {
 		id = c.GetHeader("X-Correlation-ID")
 	}
+	if id == "" {
+		id = c.GetString(CorrelationIDKey)
+	}
 
 	// If still empty, generate a UUID v4 without allocating via crypto/rand each call
 	if id == "" {
@@ -0,0 +1,343 @@
+// Package scaffold generates the skeleton of a new service module: the
+// service in internal/services/modules, its test in tests/services and its
+// toggle in the services section of the config file.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"stackyrd/config"
+)
+
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.tmpl"))
+
+// Where the generated files go, relative to the project root
+const (
+	ServicesDir = "internal/services/modules"
+	TestsDir    = "tests/services"
+)
+
+// ErrExists is returned when the service's files or config key already
+// exist.
+var ErrExists = errors.New("service already exists")
+
+var wordPattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// Names are the identifiers derived from a service name.
+type Names struct {
+	Type     string // Go type, e.g. OrderItemsService
+	Item     string // Go type of one item, e.g. OrderItem
+	Display  string // Name(), e.g. Order Items Service
+	Plural   string // e.g. Order items
+	Singular string // e.g. Order item
+	Key      string // registry and services config key, e.g. order_items_service
+	Wire     string // WireName(), e.g. order_items
+	Route    string // route group, e.g. /order-items
+	File     string // e.g. order_items_service.go
+	TestFile string // e.g. order_items_service_test.go
+}
+
+// NewNames derives the identifiers of the service called name, given in
+// CamelCase, snake_case, kebab-case or as words. A trailing "service" word
+// is dropped.
+func NewNames(name string) (Names, error) {
+	words := splitWords(name)
+	if len(words) > 0 && words[len(words)-1] == "service" {
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 {
+		return Names{}, fmt.Errorf("invalid service name %q", name)
+	}
+	for _, word := range words {
+		if !wordPattern.MatchString(word) {
+			return Names{}, fmt.Errorf("invalid service name %q: words must start with a letter and hold only letters and digits", name)
+		}
+	}
+
+	title := make([]string, len(words))
+	for i, word := range words {
+		title[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	camel := strings.Join(title, "")
+	snake := strings.Join(words, "_")
+	singular := append(append([]string{}, words[:len(words)-1]...), strings.TrimSuffix(words[len(words)-1], "s"))
+	if singular[len(singular)-1] == "" {
+		singular[len(singular)-1] = words[len(words)-1]
+	}
+	singularTitle := make([]string, len(singular))
+	for i, word := range singular {
+		singularTitle[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	sentence := strings.Join(words, " ")
+	singularSentence := strings.Join(singular, " ")
+
+	return Names{
+		Type:     camel + "Service",
+		Item:     strings.Join(singularTitle, ""),
+		Display:  strings.Join(title, " ") + " Service",
+		Plural:   strings.ToUpper(sentence[:1]) + sentence[1:],
+		Singular: strings.ToUpper(singularSentence[:1]) + singularSentence[1:],
+		Key:      snake + "_service",
+		Wire:     snake,
+		Route:    "/" + strings.Join(words, "-"),
+		File:     snake + "_service.go",
+		TestFile: snake + "_service_test.go",
+	}, nil
+}
+
+// splitWords splits name at separators and lower-to-upper case changes,
+// lowercasing the words.
+func splitWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(strings.TrimSpace(name))
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+			(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			// orderItems, order2Items, HTTPClient
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// Options configure Generate.
+type Options struct {
+	Root       string // project root; the nearest directory with a go.mod when empty
+	ConfigFile string // config file to add the toggle to; the one the app would load when empty
+	SkipTest   bool   // do not generate the test file
+	SkipConfig bool   // do not add the services toggle
+	DryRun     bool   // report what would be written without writing
+}
+
+// Result lists what Generate wrote, relative to the root.
+type Result struct {
+	Names      Names
+	Files      []string
+	ConfigFile string // the config file given the toggle, empty when none
+}
+
+// Generate writes the service called name, its test and its services
+// toggle (enabled). It fails with ErrExists, writing nothing, when one of
+// the files exists or the key is already registered or configured.
+func Generate(name string, opts Options) (*Result, error) {
+	names, err := NewNames(name)
+	if err != nil {
+		return nil, err
+	}
+	root := opts.Root
+	if root == "" {
+		if root, err = FindRoot("."); err != nil {
+			return nil, err
+		}
+	}
+
+	type file struct{ path, template string }
+	files := []file{{filepath.Join(ServicesDir, names.File), "service.go.tmpl"}}
+	if !opts.SkipTest {
+		files = append(files, file{filepath.Join(TestsDir, names.TestFile), "service_test.go.tmpl"})
+	}
+	result := &Result{Names: names}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(root, f.path)); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrExists, f.path)
+		}
+		result.Files = append(result.Files, f.path)
+	}
+	if err := checkRegistered(filepath.Join(root, ServicesDir), names.Key); err != nil {
+		return nil, err
+	}
+
+	configFile := opts.ConfigFile
+	if configFile == "" && !opts.SkipConfig {
+		configFile = config.FindConfigFile(root, filepath.Join(root, "config"))
+	}
+	var sections *config.SectionFile
+	if !opts.SkipConfig && configFile != "" {
+		sections = config.NewSectionFile(configFile)
+		if _, err := sections.Get("services/" + names.Key); err == nil {
+			return nil, fmt.Errorf("%w: services.%s is already in %s", ErrExists, names.Key, configFile)
+		} else if !errors.Is(err, config.ErrSectionNotFound) {
+			return nil, err
+		}
+		result.ConfigFile = configFile
+		if rel, err := filepath.Rel(root, configFile); err == nil && !strings.HasPrefix(rel, "..") {
+			result.ConfigFile = rel
+		}
+	}
+
+	sources := make([][]byte, len(files))
+	for i, f := range files {
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, f.template, names); err != nil {
+			return nil, err
+		}
+		source, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("formatting %s: %w", f.path, err)
+		}
+		sources[i] = source
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	for i, f := range files {
+		path := filepath.Join(root, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, sources[i], 0o644); err != nil {
+			return nil, err
+		}
+	}
+	if sections != nil {
+		if err := addToggle(sections, configFile, names.Key); err != nil {
+			return nil, fmt.Errorf("adding services.%s to %s: %w", names.Key, configFile, err)
+		}
+	}
+	return result, nil
+}
+
+// FindRoot returns dir or the nearest parent of it holding a go.mod.
+func FindRoot(dir string) (string, error) {
+	current, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", fmt.Errorf("no go.mod in %s or its parents", dir)
+		}
+		current = parent
+	}
+}
+
+// checkRegistered fails when a module in dir already registers key.
+func checkRegistered(dir, key string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	needle := []byte(fmt.Sprintf("registry.RegisterService(%q", key))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, needle) {
+			return fmt.Errorf("%w: %s is registered in %s", ErrExists, key, entry.Name())
+		}
+	}
+	return nil
+}
+
+// addToggle enables key in the services section, adding the section when
+// the file has none. YAML files get one line inserted so their layout and
+// comments stay as they are; other formats are saved as a section.
+func addToggle(sections *config.SectionFile, path, key string) error {
+	if config.FormatOf(path) == config.FormatYAML {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if updated, ok := insertYAMLToggle(data, key); ok {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(path, updated, info.Mode().Perm())
+		}
+	}
+
+	if _, err := sections.Get("services"); errors.Is(err, config.ErrSectionNotFound) {
+		_, err = sections.Put("services", map[string]interface{}{key: true}, "")
+		return err
+	}
+	_, err := sections.Put("services/"+key, true, "")
+	return err
+}
+
+// servicesLine matches the top-level services key of a block mapping.
+var servicesLine = regexp.MustCompile(`^services:\s*(#.*)?$`)
+
+// insertYAMLToggle adds "key: true" after the last entry of the top-level
+// services block, indented like its first entry (long-form entries span
+// several lines). It reports false when the file has no such block, e.g.
+// an inline "services: {}".
+func insertYAMLToggle(data []byte, key string) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	start := -1
+	for i, line := range lines {
+		if servicesLine.MatchString(strings.TrimRight(line, "\r\n")) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, false
+	}
+
+	last, indent := -1, ""
+	for i := start + 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if trimmed == line {
+			break // the next top-level key
+		}
+		if last < 0 {
+			indent = line[:len(line)-len(trimmed)]
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil, false
+	}
+
+	entry := indent + key + ": true\n"
+	if !strings.HasSuffix(lines[last], "\n") {
+		entry = "\n" + entry
+	}
+	out := append([]string{}, lines[:last+1]...)
+	out = append(out, entry)
+	out = append(out, lines[last+1:]...)
+	return []byte(strings.Join(out, "")), true
+}
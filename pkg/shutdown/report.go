@@ -0,0 +1,102 @@
+// Package shutdown records what happened during a graceful shutdown, so the
+// next run can show how the previous one ended.
+package shutdown
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Component outcomes.
+const (
+	StatusOK        = "ok"
+	StatusError     = "error"
+	StatusTimeout   = "timeout"   // Close exceeded its own deadline
+	StatusAbandoned = "abandoned" // the shutdown deadline passed first
+)
+
+// Report describes one shutdown.
+type Report struct {
+	Reason     string      `json:"reason"` // e.g. "signal: terminated", "restart"
+	PID        int         `json:"pid"`
+	Version    string      `json:"version,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+	DurationMS float64     `json:"duration_ms"`
+	Complete   bool        `json:"complete"` // false when the process was forced to exit first
+	Drain      Drain       `json:"drain"`
+	Components []Component `json:"components"`
+	Errors     []string    `json:"errors"`
+}
+
+// Drain describes how in-flight requests were drained.
+type Drain struct {
+	OpenConnections int64   `json:"open_connections"` // at drain start
+	InFlight        int64   `json:"in_flight"`        // requests being served at drain start
+	Remaining       int64   `json:"remaining"`        // requests cut off when the drain timed out
+	DurationMS      float64 `json:"duration_ms"`
+	TimedOut        bool    `json:"timed_out"`
+}
+
+// Component is the outcome of closing one component.
+type Component struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// New starts a report for a shutdown beginning now.
+func New(reason, version string) *Report {
+	return &Report{
+		Reason:     reason,
+		PID:        os.Getpid(),
+		Version:    version,
+		StartedAt:  time.Now(),
+		Components: []Component{},
+		Errors:     []string{},
+	}
+}
+
+// Finish stamps the end of the shutdown.
+func (r *Report) Finish(complete bool) {
+	r.FinishedAt = time.Now()
+	r.DurationMS = Millis(r.FinishedAt.Sub(r.StartedAt))
+	r.Complete = complete
+}
+
+// Millis converts d to fractional milliseconds.
+func Millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Write saves r as JSON at path, replacing the previous report atomically.
+func Write(path string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Read loads the report at path; a missing file returns os.ErrNotExist.
+func Read(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
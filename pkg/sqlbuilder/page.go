@@ -0,0 +1,73 @@
+package sqlbuilder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"stackyrd/pkg/response"
+)
+
+// ErrInvalidSort is returned for a sort key or direction a Sort does not
+// allow.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// Sort whitelists the orders a client may ask for. Sort keys come from
+// the request and only ever select one of Columns, so they never reach
+// the query.
+type Sort struct {
+	// Columns maps sort keys to column expressions, e.g. "created" to
+	// "created_at".
+	Columns map[string]string
+	// Default is the key used when the request names none.
+	Default string
+	// Tiebreak is appended in the same direction so rows with equal sort
+	// values keep their order across pages, typically "id".
+	Tiebreak string
+}
+
+// OrderBy returns the ORDER BY expression for key and direction ("asc" or
+// "desc", default desc).
+func (s Sort) OrderBy(key, direction string) (string, error) {
+	if key == "" {
+		key = s.Default
+	}
+	column, ok := s.Columns[key]
+	if !ok {
+		return "", fmt.Errorf("%w: cannot sort by %q, use one of %s", ErrInvalidSort, key, strings.Join(s.Keys(), ", "))
+	}
+	dir := "DESC"
+	switch strings.ToLower(direction) {
+	case "", "desc":
+	case "asc":
+		dir = "ASC"
+	default:
+		return "", fmt.Errorf("%w: order must be asc or desc, got %q", ErrInvalidSort, direction)
+	}
+	expr := column + " " + dir
+	if s.Tiebreak != "" && s.Tiebreak != column {
+		expr += ", " + s.Tiebreak + " " + dir
+	}
+	return expr, nil
+}
+
+// Keys returns the sort keys in order.
+func (s Sort) Keys() []string {
+	keys := make([]string, 0, len(s.Columns))
+	for key := range s.Columns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Page orders b by the requested sort and limits it to the requested page
+// (per_page capped at 100, see response.PaginationRequest).
+func (b *SelectBuilder) Page(req response.PaginationRequest, s Sort) (*SelectBuilder, error) {
+	orderBy, err := s.OrderBy(req.Sort, req.Order)
+	if err != nil {
+		return nil, err
+	}
+	return b.OrderBy(orderBy).Limit(req.GetPerPage()).Offset(req.GetOffset()), nil
+}
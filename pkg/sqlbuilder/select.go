@@ -0,0 +1,182 @@
+// Package sqlbuilder builds the Postgres queries services keep writing by
+// hand: filtered SELECTs with $n placeholders, their counts, and pages
+// sorted by a whitelisted column.
+package sqlbuilder
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SelectBuilder builds a SELECT. Table, columns and conditions are SQL
+// written by the caller; values only ever travel as arguments.
+type SelectBuilder struct {
+	columns []string
+	table   string
+	where   []string
+	args    []interface{}
+	orderBy string
+	limit   int
+	offset  int
+}
+
+// Select starts a SELECT of columns, all of them ("*") when none are given.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns}
+}
+
+// From sets the table, e.g. "orders" or Ident("billing", "invoices").
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.table = table
+	return b
+}
+
+// Where adds a condition, ANDed with the others. "?" in cond stands for
+// the next argument and is numbered ($1, $2...) when the query is built.
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	b.where = append(b.where, cond)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// OrderBy sets the ORDER BY expression, see Sort.OrderBy for request input.
+func (b *SelectBuilder) OrderBy(expr string) *SelectBuilder {
+	b.orderBy = expr
+	return b
+}
+
+// Limit caps the rows; 0 returns all of them.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset skips the first n rows.
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// SQL returns the query and its arguments. LIMIT and OFFSET are arguments
+// too, and OFFSET is always written with a limit, so the query text of
+// every page, the first included, is the same and can be prepared.
+func (b *SelectBuilder) SQL() (string, []interface{}) {
+	var sb strings.Builder
+	columns := "*"
+	if len(b.columns) > 0 {
+		columns = strings.Join(b.columns, ", ")
+	}
+	sb.WriteString("SELECT " + columns + " FROM " + b.table)
+	b.writeWhere(&sb)
+	if b.orderBy != "" {
+		sb.WriteString(" ORDER BY " + b.orderBy)
+	}
+	args := append([]interface{}(nil), b.args...)
+	if b.limit > 0 {
+		args = append(args, b.limit)
+		sb.WriteString(" LIMIT ?")
+	}
+	if b.limit > 0 || b.offset > 0 {
+		args = append(args, max(b.offset, 0))
+		sb.WriteString(" OFFSET ?")
+	}
+	return Rebind(sb.String()), args
+}
+
+// CountSQL returns the query counting the rows the SELECT matches,
+// ignoring order and page.
+func (b *SelectBuilder) CountSQL() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT count(*) FROM " + b.table)
+	b.writeWhere(&sb)
+	return Rebind(sb.String()), append([]interface{}(nil), b.args...)
+}
+
+func (b *SelectBuilder) writeWhere(sb *strings.Builder) {
+	if len(b.where) == 0 {
+		return
+	}
+	sb.WriteString(" WHERE ")
+	for i, cond := range b.where {
+		if i > 0 {
+			sb.WriteString(" AND ")
+		}
+		if len(b.where) > 1 {
+			cond = "(" + cond + ")"
+		}
+		sb.WriteString(cond)
+	}
+}
+
+// Rebind numbers the "?" placeholders of query as $1, $2... "?" inside
+// quoted strings, quoted identifiers and -- and (nested) /* */ comments is
+// left alone, as are
+// the jsonb operators "?|" and "?&"; write jsonb_exists() for "?".
+func Rebind(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := blockCommentEnd(query[i:])
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?' && i+1 < len(query) && (query[i+1] == '|' || query[i+1] == '&'):
+			sb.WriteString(query[i : i+2])
+			i++
+		case c == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// blockCommentEnd returns the length of the block comment query starts
+// with, counting nested comments like PostgreSQL, or len(query) when it is
+// not closed.
+func blockCommentEnd(query string) int {
+	depth := 0
+	for i := 0; i+1 < len(query); i++ {
+		switch query[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(query)
+}
+
+// Ident quotes a possibly schema-qualified identifier, for table and
+// column names that are not literals in the code.
+func Ident(parts ...string) string {
+	return pgx.Identifier(parts).Sanitize()
+}
@@ -0,0 +1,326 @@
+// Package sqlguard inspects SQL submitted to the monitoring query console
+// before it reaches PostgreSQL: one statement per query, read-only
+// statements when required, and no functions with side effects. It
+// tokenizes rather than fully parses, which is enough to find statement
+// boundaries and keywords outside literals and comments; read-only
+// transactions back it up at execution time.
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrRejected wraps every reason a statement is refused.
+var ErrRejected = errors.New("statement rejected")
+
+// Policy is what Check enforces.
+type Policy struct {
+	ReadOnly bool // only SELECT, WITH, VALUES, TABLE, SHOW and EXPLAIN
+}
+
+// Statement is the result of a successful Check.
+type Statement struct {
+	SQL         string // without trailing semicolons
+	Command     string // first keyword, upper case, e.g. "SELECT"
+	ReadOnly    bool   // no data or schema changes found
+	ReturnsRows bool   // rows are expected rather than an affected count
+	Analyze     bool   // EXPLAIN ANALYZE, which executes the statement
+	Target      string // the statement itself, or the one EXPLAIN wraps
+}
+
+// readCommands start statements that only read.
+var readCommands = map[string]bool{
+	"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true, "SHOW": true, "EXPLAIN": true,
+}
+
+// writeKeywords make a statement that starts like a read write data, as
+// in a data-modifying CTE or SELECT ... INTO. Other writing statements
+// start with a command that is not a read command.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "INTO": true,
+}
+
+// unsafeFunctions have side effects a read-only transaction does not stop
+// (signals, file access, sequences, remote connections).
+var unsafeFunctions = map[string]bool{
+	"PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true, "PG_RELOAD_CONF": true,
+	"PG_ROTATE_LOGFILE": true, "SET_CONFIG": true, "NEXTVAL": true, "SETVAL": true,
+	"LO_IMPORT": true, "LO_EXPORT": true, "LO_UNLINK": true, "PG_READ_FILE": true,
+	"PG_READ_BINARY_FILE": true, "PG_LS_DIR": true, "PG_STAT_FILE": true,
+	"DBLINK": true, "DBLINK_EXEC": true, "DBLINK_CONNECT": true,
+	"PG_ADVISORY_LOCK": true, "PG_ADVISORY_XACT_LOCK": true,
+	"PG_CREATE_RESTORE_POINT": true, "PG_SWITCH_WAL": true,
+}
+
+// Check tokenizes query and enforces p.
+func Check(query string, p Policy) (*Statement, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	// Trailing semicolons end the statement; anything after one is a
+	// second statement
+	end := len(tokens)
+	for end > 0 && tokens[end-1].text == ";" {
+		end--
+	}
+	tokens = tokens[:end]
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: query is empty", ErrRejected)
+	}
+	for _, t := range tokens {
+		if t.text == ";" {
+			return nil, fmt.Errorf("%w: only one statement per query is allowed", ErrRejected)
+		}
+	}
+
+	stmt := &Statement{
+		SQL:      strings.TrimSpace(query[:tokens[len(tokens)-1].end]),
+		Command:  tokens[0].keyword(),
+		ReadOnly: readCommands[tokens[0].keyword()],
+	}
+	stmt.ReturnsRows = stmt.ReadOnly
+	body := tokens[1:]
+	stmt.Target = stmt.SQL
+	if stmt.Command == "EXPLAIN" {
+		body = explainTarget(stmt, body)
+		if len(body) == 0 || !readCommands[body[0].keyword()] {
+			stmt.ReadOnly = false
+		}
+		if len(body) > 0 {
+			stmt.Target = strings.TrimSpace(query[body[0].start:tokens[len(tokens)-1].end])
+		}
+	}
+
+	unsafe := ""
+	for i, t := range body {
+		word := t.keyword()
+		if word == "RETURNING" {
+			stmt.ReturnsRows = true
+		}
+		if writeKeywords[word] {
+			stmt.ReadOnly = false
+		}
+		// Quoted names call the function too, alone or schema-qualified
+		if name := t.identifier(); unsafeFunctions[name] && i+1 < len(body) && body[i+1].text == "(" {
+			unsafe = strings.ToLower(name)
+		}
+	}
+	if stmt.Command == "EXPLAIN" && !stmt.Analyze {
+		// Without ANALYZE nothing runs
+		stmt.ReadOnly = true
+	} else if unsafe != "" {
+		stmt.ReadOnly = false
+		if p.ReadOnly {
+			return nil, fmt.Errorf("%w: function %s is not allowed in read-only mode", ErrRejected, unsafe)
+		}
+	}
+
+	if p.ReadOnly && !stmt.ReadOnly {
+		return nil, fmt.Errorf("%w: only read-only statements (SELECT, WITH, VALUES, TABLE, SHOW, EXPLAIN) are allowed", ErrRejected)
+	}
+	return stmt, nil
+}
+
+// explainTarget skips EXPLAIN options, noting ANALYZE, and returns the
+// tokens of the explained statement.
+func explainTarget(stmt *Statement, tokens []token) []token {
+	if len(tokens) > 0 && tokens[0].text == "(" {
+		depth := 0
+		for i, t := range tokens {
+			switch t.text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			if t.keyword() == "ANALYZE" && (i+1 >= len(tokens) || !isFalse(tokens[i+1])) {
+				stmt.Analyze = true
+			}
+			if depth == 0 {
+				return tokens[i+1:]
+			}
+		}
+		return nil
+	}
+	for len(tokens) > 0 {
+		switch tokens[0].keyword() {
+		case "ANALYZE", "ANALYSE":
+			stmt.Analyze = true
+		case "VERBOSE":
+		default:
+			return tokens
+		}
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+func isFalse(t token) bool {
+	switch t.keyword() {
+	case "FALSE", "OFF", "0":
+		return true
+	}
+	return false
+}
+
+type token struct {
+	text   string
+	quoted bool // literal or quoted identifier, never a keyword
+	start  int  // byte offsets in the query
+	end    int
+}
+
+func (t token) keyword() string {
+	if t.quoted {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// identifier returns the name a word or quoted identifier refers to, upper
+// case, or "" for literals and punctuation. Quoted names are matched upper
+// case too, so "PG_TERMINATE_BACKEND" is refused like pg_terminate_backend.
+func (t token) identifier() string {
+	if !t.quoted {
+		return strings.ToUpper(t.text)
+	}
+	if !strings.HasPrefix(t.text, `"`) {
+		return ""
+	}
+	return strings.ToUpper(strings.ReplaceAll(t.text[1:len(t.text)-1], `""`, `"`))
+}
+
+// tokenize splits query into words and punctuation, skipping whitespace
+// and comments. String literals (standard, escape and dollar-quoted) and
+// quoted identifiers become single tokens.
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			next := strings.IndexByte(query[i:], '\n')
+			if next < 0 {
+				return tokens, nil
+			}
+			i += next + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			// Block comments nest in PostgreSQL
+			depth, j := 0, i
+			for j < len(query) {
+				if strings.HasPrefix(query[j:], "/*") {
+					depth++
+					j += 2
+				} else if strings.HasPrefix(query[j:], "*/") {
+					depth--
+					j += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					j++
+				}
+			}
+			if depth != 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i = j
+		case c == '\'' || ((c == 'E' || c == 'e') && i+1 < len(query) && query[i+1] == '\''):
+			start := i
+			escapes := c != '\''
+			if escapes {
+				i++
+			}
+			j, err := skipString(query, i+1, escapes)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: query[start:j], quoted: true, start: start, end: j})
+			i = j
+		case c == '"':
+			j := i + 1
+			for {
+				k := strings.IndexByte(query[j:], '"')
+				if k < 0 {
+					return nil, errors.New("unterminated quoted identifier")
+				}
+				j += k + 1
+				if j < len(query) && query[j] == '"' {
+					j++
+					continue
+				}
+				break
+			}
+			tokens = append(tokens, token{text: query[i:j], quoted: true, start: i, end: j})
+			i = j
+		case c == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			k := strings.Index(query[i+len(tag):], tag)
+			if k < 0 {
+				return nil, errors.New("unterminated dollar-quoted string")
+			}
+			j := i + len(tag) + k + len(tag)
+			tokens = append(tokens, token{text: query[i:j], quoted: true, start: i, end: j})
+			i = j
+		case isWordByte(c):
+			j := i
+			for j < len(query) && (isWordByte(query[j]) || query[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, token{text: query[i:j], start: i, end: j})
+			i = j
+		default:
+			tokens = append(tokens, token{text: query[i : i+1], start: i, end: i + 1})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// skipString returns the offset after the string literal whose content
+// starts at i.
+func skipString(query string, i int, backslashEscapes bool) (int, error) {
+	for i < len(query) {
+		switch query[i] {
+		case '\\':
+			if backslashEscapes {
+				i += 2
+				continue
+			}
+		case '\'':
+			if i+1 < len(query) && query[i+1] == '\'' {
+				i += 2
+				continue
+			}
+			return i + 1, nil
+		}
+		i++
+	}
+	return 0, errors.New("unterminated string literal")
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string ("$$" or
+// "$name$") at the start of s, or "" when s does not start one ($1 is a
+// parameter).
+func dollarTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		if s[j] == '$' {
+			return s[:j+1]
+		}
+		if !isWordByte(s[j]) || (j == 1 && s[j] >= '0' && s[j] <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
@@ -0,0 +1,100 @@
+package sysinfo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// ErrUnsupported flags a metric the platform does not provide.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+type host struct{}
+
+// Host returns the Source reading the machine through gopsutil.
+func Host() Source {
+	return host{}
+}
+
+func (host) CPUPercent(interval time.Duration) (float64, error) {
+	c, err := cpu.Percent(interval, false)
+	if err != nil {
+		return 0, err
+	}
+	if len(c) == 0 {
+		return 0, fmt.Errorf("no cpu figures")
+	}
+	return c[0], nil
+}
+
+func (host) CPUCores() (int, error) {
+	return cpu.Counts(true)
+}
+
+func (host) Memory() (MemoryStats, error) {
+	v, err := mem.VirtualMemory()
+	if err != nil {
+		return MemoryStats{}, err
+	}
+	return MemoryStats{
+		TotalMB:     v.Total / 1024 / 1024,
+		UsedMB:      v.Used / 1024 / 1024,
+		UsedPercent: v.UsedPercent,
+	}, nil
+}
+
+func (host) DiskUsage(path string) (DiskStats, error) {
+	u, err := disk.Usage(path)
+	if err != nil {
+		return DiskStats{}, err
+	}
+	return DiskStats{
+		Path:        u.Path,
+		TotalGB:     u.Total / 1024 / 1024 / 1024,
+		UsedGB:      u.Used / 1024 / 1024 / 1024,
+		UsedPercent: u.UsedPercent,
+	}, nil
+}
+
+func (host) DiskPartitions() ([]string, error) {
+	parts, err := disk.Partitions(false)
+	if err != nil {
+		return nil, err
+	}
+	mounts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		mounts = append(mounts, p.Mountpoint)
+	}
+	return mounts, nil
+}
+
+func (host) Network() (NetworkStats, error) {
+	counters, err := net.IOCounters(false)
+	if err != nil {
+		return NetworkStats{}, err
+	}
+	if len(counters) == 0 {
+		return NetworkStats{}, fmt.Errorf("no network interfaces")
+	}
+	c := counters[0]
+	return NetworkStats{
+		BytesSent:   c.BytesSent,
+		BytesRecv:   c.BytesRecv,
+		PacketsSent: c.PacketsSent,
+		PacketsRecv: c.PacketsRecv,
+	}, nil
+}
+
+func (host) Load() (LoadStats, error) {
+	a, err := load.Avg()
+	if err != nil {
+		return LoadStats{}, err
+	}
+	return LoadStats{Load1: a.Load1, Load5: a.Load5, Load15: a.Load15}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package sysinfo
+
+const loadSupported = true
+
+// diskPath is the root file system.
+func diskPath() string {
+	return "/"
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package sysinfo
+
+import "os"
+
+// Windows has no load average; gopsutil only approximates one after it has
+// sampled for a while.
+const loadSupported = false
+
+// diskPath is the system drive, normally C:\.
+func diskPath() string {
+	if drive := os.Getenv("SystemDrive"); drive != "" {
+		return drive + `\`
+	}
+	return `C:\`
+}
@@ -0,0 +1,189 @@
+// Package sysinfo collects host CPU, memory, disk, network and load
+// figures in one schema on every platform. Metrics the platform (or the
+// container) cannot provide are flagged unavailable with the reason
+// instead of failing the whole collection or reading as zero.
+package sysinfo
+
+import (
+	"runtime"
+	"time"
+)
+
+// Metric groups, the keys of Stats.Available and Stats.Errors.
+const (
+	MetricCPU     = "cpu"
+	MetricMemory  = "memory"
+	MetricDisk    = "disk"
+	MetricNetwork = "network"
+	MetricLoad    = "load"
+)
+
+// Metrics lists every metric group in display order.
+var Metrics = []string{MetricCPU, MetricMemory, MetricDisk, MetricNetwork, MetricLoad}
+
+// CPUStats is the host CPU usage.
+type CPUStats struct {
+	Percent float64 `json:"percent"`
+	Cores   int     `json:"cores"`
+}
+
+// MemoryStats is the host memory usage.
+type MemoryStats struct {
+	TotalMB     uint64  `json:"total_mb"`
+	UsedMB      uint64  `json:"used_mb"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// DiskStats is the usage of the volume holding the system.
+type DiskStats struct {
+	Path        string  `json:"path"`
+	TotalGB     uint64  `json:"total_gb"`
+	UsedGB      uint64  `json:"used_gb"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// NetworkStats are the counters summed over all interfaces since boot.
+type NetworkStats struct {
+	BytesSent   uint64 `json:"bytes_sent"`
+	BytesRecv   uint64 `json:"bytes_recv"`
+	PacketsSent uint64 `json:"packets_sent"`
+	PacketsRecv uint64 `json:"packets_recv"`
+}
+
+// LoadStats are the 1, 5 and 15 minute load averages.
+type LoadStats struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// Stats is one collection. Every field is always present; the figures of a
+// metric group with Available false are zero and Errors says why.
+type Stats struct {
+	OS          string            `json:"os"`
+	Arch        string            `json:"arch"`
+	Goroutines  int               `json:"goroutines"`
+	CollectedAt time.Time         `json:"collected_at"`
+	CPU         CPUStats          `json:"cpu"`
+	Memory      MemoryStats       `json:"memory"`
+	Disk        DiskStats         `json:"disk"`
+	Network     NetworkStats      `json:"network"`
+	Load        LoadStats         `json:"load"`
+	Available   map[string]bool   `json:"available"`
+	Errors      map[string]string `json:"errors,omitempty"`
+}
+
+// Source reads the raw figures; Host() reads them through gopsutil and
+// tests substitute their own.
+type Source interface {
+	CPUPercent(interval time.Duration) (float64, error)
+	CPUCores() (int, error)
+	Memory() (MemoryStats, error)
+	DiskUsage(path string) (DiskStats, error)
+	DiskPartitions() ([]string, error) // mount points
+	Network() (NetworkStats, error)
+	Load() (LoadStats, error)
+}
+
+// DefaultCPUInterval is how long Default measures CPU usage.
+const DefaultCPUInterval = 100 * time.Millisecond
+
+// Collector collects Stats from a Source.
+type Collector struct {
+	source      Source
+	cpuInterval time.Duration
+	diskPath    string
+}
+
+var defaultCollector = New(Host(), DefaultCPUInterval)
+
+// Default returns the collector reading the host with DefaultCPUInterval.
+func Default() *Collector {
+	return defaultCollector
+}
+
+// New creates a collector measuring CPU usage over cpuInterval; zero
+// compares with the previous call instead of blocking.
+func New(source Source, cpuInterval time.Duration) *Collector {
+	return &Collector{source: source, cpuInterval: cpuInterval, diskPath: diskPath()}
+}
+
+// WithDiskPath returns a copy of c reporting the volume holding path
+// instead of the system volume.
+func (c *Collector) WithDiskPath(path string) *Collector {
+	cp := *c
+	cp.diskPath = path
+	return &cp
+}
+
+// Collect reads every metric group. It never fails as a whole.
+func (c *Collector) Collect() Stats {
+	s := Stats{
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Goroutines:  runtime.NumGoroutine(),
+		CollectedAt: time.Now(),
+		Available:   make(map[string]bool, len(Metrics)),
+	}
+	record := func(metric string, err error) {
+		s.Available[metric] = err == nil
+		if err != nil {
+			if s.Errors == nil {
+				s.Errors = make(map[string]string)
+			}
+			s.Errors[metric] = err.Error()
+		}
+	}
+
+	var err error
+	if s.CPU.Percent, err = c.source.CPUPercent(c.cpuInterval); err == nil {
+		if s.CPU.Cores, err = c.source.CPUCores(); err != nil || s.CPU.Cores <= 0 {
+			// Some ARM boards and containers hide the topology
+			s.CPU.Cores, err = runtime.NumCPU(), nil
+		}
+	} else {
+		s.CPU = CPUStats{}
+	}
+	record(MetricCPU, err)
+
+	if s.Memory, err = c.source.Memory(); err != nil {
+		s.Memory = MemoryStats{}
+	}
+	record(MetricMemory, err)
+
+	s.Disk, err = c.Disk()
+	record(MetricDisk, err)
+
+	if s.Network, err = c.source.Network(); err != nil {
+		s.Network = NetworkStats{}
+	}
+	record(MetricNetwork, err)
+
+	if !loadSupported {
+		err = ErrUnsupported
+	} else if s.Load, err = c.source.Load(); err != nil {
+		s.Load = LoadStats{}
+	}
+	record(MetricLoad, err)
+
+	return s
+}
+
+// Disk reads only the disk usage: the configured volume, else the first
+// partition that can be read.
+func (c *Collector) Disk() (DiskStats, error) {
+	usage, err := c.source.DiskUsage(c.diskPath)
+	if err == nil {
+		return usage, nil
+	}
+	parts, perr := c.source.DiskPartitions()
+	if perr != nil {
+		return DiskStats{}, err
+	}
+	for _, p := range parts {
+		if usage, perr := c.source.DiskUsage(p); perr == nil {
+			return usage, nil
+		}
+	}
+	return DiskStats{}, err
+}
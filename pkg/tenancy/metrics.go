@@ -0,0 +1,182 @@
+package tenancy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxTrackedTenants bounds the number of distinct tenants tracked; usage of
+// further tenants is attributed to OverflowTenant. Database work outside a
+// request tenant on a connection no registered tenant owns is attributed to
+// UnattributedTenant.
+const (
+	MaxTrackedTenants  = 10000
+	OverflowTenant     = "_other"
+	UnattributedTenant = "_unattributed"
+)
+
+// Usage is the resource usage attributed to a tenant.
+type Usage struct {
+	Tenant         string    `json:"tenant"`
+	Requests       int64     `json:"requests"`
+	Errors         int64     `json:"errors"`
+	RequestTimeMs  float64   `json:"request_time_ms"`
+	AvgRequestMs   float64   `json:"avg_request_ms"`
+	DBQueries      int64     `json:"db_queries"`
+	DBTimeMs       float64   `json:"db_time_ms"`
+	CacheOps       int64     `json:"cache_ops"`
+	CacheHits      int64     `json:"cache_hits"`
+	CacheMisses    int64     `json:"cache_misses"`
+	StorageObjects int64     `json:"storage_objects"`
+	StorageBytes   int64     `json:"storage_bytes"`
+	DatabaseBytes  int64     `json:"database_bytes"`
+	LastSeen       time.Time `json:"last_seen,omitempty"`
+}
+
+// Collector accumulates per-tenant usage. It is safe for concurrent use.
+type Collector struct {
+	mu      sync.Mutex
+	tenants map[string]*Usage
+	since   time.Time
+}
+
+var defaultCollector = NewCollector()
+
+// DefaultCollector returns the process-wide collector fed by the tenant
+// middleware and the infrastructure managers.
+func DefaultCollector() *Collector {
+	return defaultCollector
+}
+
+// NewCollector creates an empty collector.
+func NewCollector() *Collector {
+	return &Collector{tenants: make(map[string]*Usage), since: time.Now()}
+}
+
+// usage returns the entry for tenant. Callers hold c.mu.
+func (c *Collector) usage(tenant string) *Usage {
+	u, ok := c.tenants[tenant]
+	if !ok {
+		if len(c.tenants) >= MaxTrackedTenants {
+			tenant = OverflowTenant
+			if u, ok = c.tenants[tenant]; ok {
+				return u
+			}
+		}
+		u = &Usage{Tenant: tenant}
+		c.tenants[tenant] = u
+	}
+	return u
+}
+
+// RecordRequest records an HTTP request; 5xx responses count as errors.
+func (c *Collector) RecordRequest(tenant string, status int, d time.Duration) {
+	if tenant == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.usage(tenant)
+	u.Requests++
+	if status >= 500 {
+		u.Errors++
+	}
+	u.RequestTimeMs += float64(d) / float64(time.Millisecond)
+	u.LastSeen = time.Now()
+}
+
+// RecordQuery records a database query or command.
+func (c *Collector) RecordQuery(tenant string, d time.Duration) {
+	if tenant == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.usage(tenant)
+	u.DBQueries++
+	u.DBTimeMs += float64(d) / float64(time.Millisecond)
+}
+
+// RecordCache records a cache operation. Reads report hit or miss; writes
+// pass neither.
+func (c *Collector) RecordCache(tenant string, hit, miss bool) {
+	if tenant == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.usage(tenant)
+	u.CacheOps++
+	if hit {
+		u.CacheHits++
+	}
+	if miss {
+		u.CacheMisses++
+	}
+}
+
+// SetStorage records the object storage footprint of a tenant.
+func (c *Collector) SetStorage(tenant string, objects, bytes int64) {
+	if tenant == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u := c.usage(tenant)
+	u.StorageObjects = objects
+	u.StorageBytes = bytes
+}
+
+// SetDatabaseSize records the size of a tenant's dedicated database.
+func (c *Collector) SetDatabaseSize(tenant string, bytes int64) {
+	if tenant == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage(tenant).DatabaseBytes = bytes
+}
+
+// Tenants returns the names of all tracked tenants, sorted.
+func (c *Collector) Tenants() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.tenants))
+	for name := range c.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snapshot returns a copy of all usage, sorted by tenant.
+func (c *Collector) Snapshot() []Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Usage, 0, len(c.tenants))
+	for _, u := range c.tenants {
+		snap := *u
+		if snap.Requests > 0 {
+			snap.AvgRequestMs = snap.RequestTimeMs / float64(snap.Requests)
+		}
+		out = append(out, snap)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tenant < out[j].Tenant })
+	return out
+}
+
+// Since returns when counting started (creation or last Reset).
+func (c *Collector) Since() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.since
+}
+
+// Reset clears all counters, e.g. at the start of a billing period.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tenants = make(map[string]*Usage)
+	c.since = time.Now()
+}
@@ -0,0 +1,181 @@
+package tenancy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"stackyrd/config"
+)
+
+// Tenant is a registered tenant and the connections holding its data.
+type Tenant struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Postgres string `json:"postgres,omitempty"` // connection name, see PostgresConnection
+	Mongo    string `json:"mongo,omitempty"`    // connection name, see MongoConnection
+	Disabled bool   `json:"disabled"`
+}
+
+// PostgresConnection names the tenant's Postgres connection: the mapped
+// one, else the connection named after the tenant.
+func (t Tenant) PostgresConnection() string {
+	if t.Postgres != "" {
+		return t.Postgres
+	}
+	return t.ID
+}
+
+// MongoConnection names the tenant's MongoDB connection: the mapped one,
+// else the connection named after the tenant.
+func (t Tenant) MongoConnection() string {
+	if t.Mongo != "" {
+		return t.Mongo
+	}
+	return t.ID
+}
+
+// ValidID reports whether id can identify a tenant: 1 to 64 letters,
+// digits, "-" or "_". Tenant IDs end up in connection names, cache keys
+// and object prefixes.
+func ValidID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		ok := c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry maps tenant IDs to tenants. An empty registry knows no tenants
+// and callers accept any tenant, as before tenants were registered.
+type Registry struct {
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the process-wide registry loaded from
+// tenancy.tenants.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry creates a registry holding tenants.
+func NewRegistry(tenants ...Tenant) *Registry {
+	r := &Registry{tenants: make(map[string]Tenant)}
+	for _, t := range tenants {
+		r.tenants[t.ID] = t
+	}
+	return r
+}
+
+// Load replaces the registered tenants with those of the configuration.
+func (r *Registry) Load(tenants []config.TenantConfig) error {
+	loaded := make(map[string]Tenant, len(tenants))
+	for _, t := range tenants {
+		if !ValidID(t.ID) {
+			return fmt.Errorf("tenancy: invalid tenant id %q", t.ID)
+		}
+		if _, ok := loaded[t.ID]; ok {
+			return fmt.Errorf("tenancy: tenant %q is listed twice", t.ID)
+		}
+		loaded[t.ID] = Tenant{ID: t.ID, Name: t.Name, Postgres: t.Postgres, Mongo: t.Mongo, Disabled: t.Disabled}
+	}
+	r.mu.Lock()
+	r.tenants = loaded
+	r.mu.Unlock()
+	return nil
+}
+
+// Register adds or replaces a tenant.
+func (r *Registry) Register(t Tenant) error {
+	if !ValidID(t.ID) {
+		return fmt.Errorf("tenancy: invalid tenant id %q", t.ID)
+	}
+	r.mu.Lock()
+	r.tenants[t.ID] = t
+	r.mu.Unlock()
+	return nil
+}
+
+// Remove unregisters a tenant and reports whether it was registered.
+func (r *Registry) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.tenants[id]
+	delete(r.tenants, id)
+	return ok
+}
+
+// Get returns the registered tenant, disabled or not.
+func (r *Registry) Get(id string) (Tenant, bool) {
+	if r == nil {
+		return Tenant{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	return t, ok
+}
+
+// List returns the registered tenants ordered by ID.
+func (r *Registry) List() []Tenant {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	list := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		list = append(list, t)
+	}
+	r.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// PostgresOwner returns the tenant whose Postgres connection is conn,
+// unless several registered tenants share it.
+func (r *Registry) PostgresOwner(conn string) (string, bool) {
+	return r.owner(conn, Tenant.PostgresConnection)
+}
+
+// MongoOwner returns the tenant whose MongoDB connection is conn, unless
+// several registered tenants share it.
+func (r *Registry) MongoOwner(conn string) (string, bool) {
+	return r.owner(conn, Tenant.MongoConnection)
+}
+
+func (r *Registry) owner(conn string, connection func(Tenant) string) (string, bool) {
+	if r == nil || conn == "" {
+		return "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	owner := ""
+	for _, t := range r.tenants {
+		if connection(t) != conn {
+			continue
+		}
+		if owner != "" {
+			return "", false
+		}
+		owner = t.ID
+	}
+	return owner, owner != ""
+}
+
+// Len returns the number of registered tenants.
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tenants)
+}
@@ -0,0 +1,38 @@
+// Package tenancy carries the current tenant through request contexts and
+// attributes resource usage (requests, database time, cache and storage) to
+// tenants for capacity planning and chargeback.
+package tenancy
+
+import "context"
+
+// HeaderTenantID identifies the tenant on requests without a :tenant path
+// parameter.
+const HeaderTenantID = "X-Tenant-ID"
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	if tenant == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the tenant carried by ctx, or "".
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Resolve returns the tenant from ctx, falling back to the given tenant
+// (usually the name of a per-tenant connection).
+func Resolve(ctx context.Context, fallback string) string {
+	if tenant := FromContext(ctx); tenant != "" {
+		return tenant
+	}
+	return fallback
+}
@@ -0,0 +1,190 @@
+package testkit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/pkg/response"
+)
+
+// envelope mirrors response.Response with the data kept raw, so it can be
+// decoded into the caller's type.
+type envelope struct {
+	Success       bool                  `json:"success"`
+	Status        int                   `json:"status"`
+	Message       string                `json:"message"`
+	Data          json.RawMessage       `json:"data"`
+	Error         *response.ErrorDetail `json:"error"`
+	Meta          *response.Meta        `json:"meta"`
+	Timestamp     int64                 `json:"timestamp"`
+	CorrelationID string                `json:"correlation_id"`
+}
+
+// Result holds a response and asserts on its envelope. Assertions report
+// failures with t.Errorf and return the result, so they chain.
+type Result struct {
+	t        testing.TB
+	request  string
+	Recorder *httptest.ResponseRecorder
+	envelope envelope
+	isJSON   bool
+}
+
+func newResult(t testing.TB, request string, rec *httptest.ResponseRecorder) *Result {
+	r := &Result{t: t, request: request, Recorder: rec}
+	r.isJSON = json.Unmarshal(rec.Body.Bytes(), &r.envelope) == nil
+	return r
+}
+
+func (r *Result) requireEnvelope() bool {
+	r.t.Helper()
+	if !r.isJSON {
+		r.t.Errorf("%s: response is not a JSON envelope: %s", r.request, r.Recorder.Body.String())
+	}
+	return r.isJSON
+}
+
+// Status asserts the HTTP status code and, for JSON envelopes, that the
+// envelope reports the same status.
+func (r *Result) Status(code int) *Result {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("%s: expected status %d, got %d: %s", r.request, code, r.Recorder.Code, r.Recorder.Body.String())
+		return r
+	}
+	if r.isJSON && r.envelope.Status != 0 && r.envelope.Status != code {
+		r.t.Errorf("%s: envelope status %d does not match HTTP status %d", r.request, r.envelope.Status, code)
+	}
+	return r
+}
+
+// OK asserts a 200 response with success set.
+func (r *Result) OK() *Result {
+	r.t.Helper()
+	return r.Status(200).Success()
+}
+
+// Success asserts the envelope reports success.
+func (r *Result) Success() *Result {
+	r.t.Helper()
+	if r.requireEnvelope() && !r.envelope.Success {
+		r.t.Errorf("%s: expected success, got error %+v", r.request, r.envelope.Error)
+	}
+	return r
+}
+
+// ErrorCode asserts a failed envelope with the given error code, e.g.
+// "NOT_FOUND" or "VALIDATION_ERROR".
+func (r *Result) ErrorCode(code string) *Result {
+	r.t.Helper()
+	if !r.requireEnvelope() {
+		return r
+	}
+	switch {
+	case r.envelope.Success:
+		r.t.Errorf("%s: expected error %s, got success", r.request, code)
+	case r.envelope.Error == nil:
+		r.t.Errorf("%s: expected error %s, envelope has no error", r.request, code)
+	case r.envelope.Error.Code != code:
+		r.t.Errorf("%s: expected error %s, got %s (%s)", r.request, code, r.envelope.Error.Code, r.envelope.Error.Message)
+	}
+	return r
+}
+
+// ErrorDetail asserts the error details contain key.
+func (r *Result) ErrorDetail(key string) *Result {
+	r.t.Helper()
+	if !r.requireEnvelope() {
+		return r
+	}
+	if r.envelope.Error == nil {
+		r.t.Errorf("%s: expected error detail %q, envelope has no error", r.request, key)
+	} else if _, ok := r.envelope.Error.Details[key]; !ok {
+		r.t.Errorf("%s: error details %v have no %q", r.request, r.envelope.Error.Details, key)
+	}
+	return r
+}
+
+// Message asserts the envelope message.
+func (r *Result) Message(msg string) *Result {
+	r.t.Helper()
+	if r.requireEnvelope() && r.envelope.Message != msg {
+		r.t.Errorf("%s: expected message %q, got %q", r.request, msg, r.envelope.Message)
+	}
+	return r
+}
+
+// Pagination asserts the pagination meta; total pages follow from the
+// arguments as in response.CalculateMeta.
+func (r *Result) Pagination(page, perPage int, total int64) *Result {
+	r.t.Helper()
+	if !r.requireEnvelope() {
+		return r
+	}
+	if r.envelope.Meta == nil {
+		r.t.Errorf("%s: expected pagination meta, envelope has none", r.request)
+		return r
+	}
+	want := response.CalculateMeta(page, perPage, total)
+	got := r.envelope.Meta
+	if got.Page != want.Page || got.PerPage != want.PerPage || got.Total != want.Total || got.TotalPages != want.TotalPages {
+		r.t.Errorf("%s: expected meta page=%d per_page=%d total=%d total_pages=%d, got page=%d per_page=%d total=%d total_pages=%d",
+			r.request, want.Page, want.PerPage, want.Total, want.TotalPages, got.Page, got.PerPage, got.Total, got.TotalPages)
+	}
+	return r
+}
+
+// CorrelationID asserts the envelope carries a correlation ID, equal to id
+// when id is not empty.
+func (r *Result) CorrelationID(id string) *Result {
+	r.t.Helper()
+	if !r.requireEnvelope() {
+		return r
+	}
+	if r.envelope.CorrelationID == "" {
+		r.t.Errorf("%s: envelope has no correlation_id", r.request)
+	} else if id != "" && r.envelope.CorrelationID != id {
+		r.t.Errorf("%s: expected correlation_id %q, got %q", r.request, id, r.envelope.CorrelationID)
+	}
+	return r
+}
+
+// Header asserts a response header.
+func (r *Result) Header(key, value string) *Result {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(key); got != value {
+		r.t.Errorf("%s: expected header %s=%q, got %q", r.request, key, value, got)
+	}
+	return r
+}
+
+// Data decodes the envelope data into v; a decoding failure fails the test.
+func (r *Result) Data(v interface{}) *Result {
+	r.t.Helper()
+	if !r.requireEnvelope() {
+		r.t.FailNow()
+	}
+	if err := json.Unmarshal(r.envelope.Data, v); err != nil {
+		r.t.Fatalf("%s: decode data: %v", r.request, err)
+	}
+	return r
+}
+
+// Meta returns the envelope meta, or nil.
+func (r *Result) Meta() *response.Meta {
+	return r.envelope.Meta
+}
+
+// Envelope returns the decoded envelope fields other than data.
+func (r *Result) Envelope() response.Response {
+	return response.Response{
+		Success:       r.envelope.Success,
+		Status:        r.envelope.Status,
+		Message:       r.envelope.Message,
+		Error:         r.envelope.Error,
+		Meta:          r.envelope.Meta,
+		Timestamp:     r.envelope.Timestamp,
+		CorrelationID: r.envelope.CorrelationID,
+	}
+}
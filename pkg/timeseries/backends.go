@@ -0,0 +1,178 @@
+package timeseries
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store is the persistence the store backend needs; the embedded store
+// implements it.
+type Store interface {
+	PutJSON(bucket, key string, v interface{}) error
+	ForEachPrefix(bucket, prefix string, fn func(key, value []byte) error) error
+	DeleteKeys(bucket string, keys [][]byte) error
+}
+
+// Bucket holds the rollups of the store backend.
+const Bucket = "metrics_history"
+
+// StoreBackend keeps the rollups in the embedded store under
+// "<resolution>/<unix seconds>/<metric>", so keys sort by time.
+type StoreBackend struct {
+	store Store
+}
+
+// NewStoreBackend persists rollups in store.
+func NewStoreBackend(store Store) *StoreBackend {
+	return &StoreBackend{store: store}
+}
+
+func storeKey(res Resolution, t time.Time, metric string) string {
+	return fmt.Sprintf("%s/%012d/%s", res, t.Unix(), metric)
+}
+
+// Save stores a rollup, replacing the one of the same bucket.
+func (b *StoreBackend) Save(res Resolution, metric string, p Point) error {
+	return b.store.PutJSON(Bucket, storeKey(res, p.Time, metric), p)
+}
+
+// Load returns the rollups at res since the given time by metric.
+func (b *StoreBackend) Load(res Resolution, since time.Time) (map[string][]Point, error) {
+	first := storeKey(res, since, "")
+	points := make(map[string][]Point)
+	err := b.store.ForEachPrefix(Bucket, string(res)+"/", func(key, value []byte) error {
+		if string(key) < first {
+			return nil
+		}
+		var p Point
+		if err := json.Unmarshal(value, &p); err != nil {
+			return err
+		}
+		metric := string(key[len(first):]) // timestamps have a fixed width
+		points[metric] = append(points[metric], p)
+		return nil
+	})
+	return points, err
+}
+
+// Prune deletes the rollups at res older than before.
+func (b *StoreBackend) Prune(res Resolution, before time.Time) error {
+	last := storeKey(res, before, "")
+	var old [][]byte
+	err := b.store.ForEachPrefix(Bucket, string(res)+"/", func(key, _ []byte) error {
+		if string(key) < last {
+			old = append(old, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	if err != nil || len(old) == 0 {
+		return err
+	}
+	return b.store.DeleteKeys(Bucket, old)
+}
+
+// SQLTable holds the rollups of the Postgres backend.
+const SQLTable = "stackyrd_metrics_history"
+
+const sqlTimeout = 10 * time.Second
+
+// SQLBackend keeps the rollups in a Postgres table, created on first use.
+// The connection is looked up on every call as the database may connect
+// after the history starts.
+type SQLBackend struct {
+	db func() *sql.DB
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewSQLBackend persists rollups through the connection db returns, nil
+// while not connected.
+func NewSQLBackend(db func() *sql.DB) *SQLBackend {
+	return &SQLBackend{db: db}
+}
+
+func (b *SQLBackend) conn(ctx context.Context) (*sql.DB, error) {
+	db := b.db()
+	if db == nil {
+		return nil, errors.New("database is not connected")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.ready {
+		_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+SQLTable+` (
+			resolution TEXT NOT NULL,
+			metric TEXT NOT NULL,
+			bucket TIMESTAMPTZ NOT NULL,
+			avg DOUBLE PRECISION NOT NULL,
+			min DOUBLE PRECISION NOT NULL,
+			max DOUBLE PRECISION NOT NULL,
+			count INTEGER NOT NULL,
+			PRIMARY KEY (resolution, metric, bucket)
+		)`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", SQLTable, err)
+		}
+		b.ready = true
+	}
+	return db, nil
+}
+
+// Save stores a rollup, replacing the one of the same bucket.
+func (b *SQLBackend) Save(res Resolution, metric string, p Point) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	db, err := b.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO `+SQLTable+` (resolution, metric, bucket, avg, min, max, count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (resolution, metric, bucket) DO UPDATE
+		SET avg = EXCLUDED.avg, min = EXCLUDED.min, max = EXCLUDED.max, count = EXCLUDED.count`,
+		string(res), metric, p.Time, p.Avg, p.Min, p.Max, p.Count)
+	return err
+}
+
+// Load returns the rollups at res since the given time by metric.
+func (b *SQLBackend) Load(res Resolution, since time.Time) (map[string][]Point, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	db, err := b.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT metric, bucket, avg, min, max, count FROM `+SQLTable+`
+		WHERE resolution = $1 AND bucket >= $2 ORDER BY bucket`, string(res), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	points := make(map[string][]Point)
+	for rows.Next() {
+		var metric string
+		var p Point
+		if err := rows.Scan(&metric, &p.Time, &p.Avg, &p.Min, &p.Max, &p.Count); err != nil {
+			return nil, err
+		}
+		points[metric] = append(points[metric], p)
+	}
+	return points, rows.Err()
+}
+
+// Prune deletes the rollups at res older than before.
+func (b *SQLBackend) Prune(res Resolution, before time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	db, err := b.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM `+SQLTable+` WHERE resolution = $1 AND bucket < $2`, string(res), before)
+	return err
+}
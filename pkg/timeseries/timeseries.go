@@ -0,0 +1,352 @@
+// Package timeseries keeps a short history of system, request and
+// infrastructure figures for dashboard charts: raw samples taken every few
+// seconds plus 1 minute and 1 hour rollups, in memory and optionally, for
+// the rollups, in a persistent backend so charts survive restarts.
+package timeseries
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/logger"
+)
+
+// Resolution of a series.
+type Resolution string
+
+// Resolutions kept for every metric.
+const (
+	Raw    Resolution = "raw"
+	Minute Resolution = "1m"
+	Hour   Resolution = "1h"
+)
+
+// ParseResolution returns the resolution named s.
+func ParseResolution(s string) (Resolution, error) {
+	switch r := Resolution(s); r {
+	case Raw, Minute, Hour:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown resolution %q, use raw, 1m or 1h", s)
+}
+
+// Defaults of the history.
+const (
+	defaultInterval        = 10 * time.Second
+	defaultRawSize         = 360 // an hour at the default interval
+	defaultMinuteRetention = 24 * time.Hour
+	defaultHourRetention   = 30 * 24 * time.Hour
+)
+
+// Point is a sample or a rollup of the samples in the bucket starting at
+// Time. Raw samples have Count 1 and Avg, Min and Max equal to the value.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Avg   float64   `json:"avg"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int       `json:"count"`
+}
+
+func (p *Point) add(v float64) {
+	p.Avg += (v - p.Avg) / float64(p.Count+1)
+	p.Min = min(p.Min, v)
+	p.Max = max(p.Max, v)
+	p.Count++
+}
+
+// Sampler returns the current value of every metric.
+type Sampler func() map[string]float64
+
+// Backend persists the closed rollups. The embedded store and Postgres
+// backends implement it.
+type Backend interface {
+	Save(res Resolution, metric string, p Point) error
+	Load(res Resolution, since time.Time) (map[string][]Point, error)
+	Prune(res Resolution, before time.Time) error
+}
+
+// Options configure a History; zero values use the defaults.
+type Options struct {
+	Sample          Sampler
+	Interval        time.Duration
+	RawSize         int           // raw samples kept per metric
+	MinuteRetention time.Duration // how long 1m rollups are kept
+	HourRetention   time.Duration // how long 1h rollups are kept
+	Backend         Backend       // nil keeps the history in memory only
+	Logger          *logger.Logger
+
+	// OnSample, when set, receives every sample taken by Start, e.g. to
+	// ship it to an external time-series database. It runs on the sampling
+	// goroutine and must not block.
+	OnSample func(t time.Time, values map[string]float64)
+}
+
+type series struct {
+	raw, minutes, hours []Point // oldest first
+	minute, hour        *Point  // open buckets
+}
+
+// History samples metrics periodically and keeps their raw values and
+// rollups.
+type History struct {
+	opts       Options
+	minuteSize int
+	hourSize   int
+
+	mu     sync.RWMutex
+	series map[string]*series
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a history; call Start to begin sampling.
+func New(opts Options) *History {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.RawSize <= 0 {
+		opts.RawSize = defaultRawSize
+	}
+	if opts.MinuteRetention <= 0 {
+		opts.MinuteRetention = defaultMinuteRetention
+	}
+	if opts.HourRetention <= 0 {
+		opts.HourRetention = defaultHourRetention
+	}
+	return &History{
+		opts:       opts,
+		minuteSize: int(opts.MinuteRetention / time.Minute),
+		hourSize:   max(int(opts.HourRetention/time.Hour), 1),
+		series:     make(map[string]*series),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Name returns the display name of the component.
+func (h *History) Name() string {
+	return "Metrics History"
+}
+
+// Interval returns the time between samples.
+func (h *History) Interval() time.Duration {
+	return h.opts.Interval
+}
+
+// Start samples now and every interval until Close. The persisted rollups
+// are restored first, or retried with every sample while the backend
+// fails, as databases may connect later.
+func (h *History) Start() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.opts.Interval)
+		defer ticker.Stop()
+		restored, warned := false, false
+		for {
+			if !restored {
+				err := h.Restore()
+				restored = err == nil
+				if err != nil && !warned {
+					h.warn("Failed to restore metrics history, retrying", err)
+					warned = true
+				}
+			}
+			if h.opts.Sample != nil {
+				now, values := time.Now(), h.opts.Sample()
+				h.Add(now, values)
+				if h.opts.OnSample != nil {
+					h.opts.OnSample(now, values)
+				}
+			}
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops sampling.
+func (h *History) Close() error {
+	h.stopOnce.Do(func() { close(h.stop) })
+	h.wg.Wait()
+	return nil
+}
+
+// Restore loads the persisted rollups within retention into the history,
+// before any point it already has.
+func (h *History) Restore() error {
+	if h.opts.Backend == nil {
+		return nil
+	}
+	now := time.Now()
+	minutes, err := h.opts.Backend.Load(Minute, now.Add(-h.opts.MinuteRetention))
+	if err != nil {
+		return err
+	}
+	hours, err := h.opts.Backend.Load(Hour, now.Add(-h.opts.HourRetention))
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, points := range minutes {
+		s := h.seriesOf(name)
+		s.minutes = bounded(merge(points, s.minutes), h.minuteSize)
+	}
+	for name, points := range hours {
+		s := h.seriesOf(name)
+		s.hours = bounded(merge(points, s.hours), h.hourSize)
+	}
+	return nil
+}
+
+// merge puts the restored points older than the kept ones before them.
+func merge(restored, kept []Point) []Point {
+	sort.Slice(restored, func(i, j int) bool { return restored[i].Time.Before(restored[j].Time) })
+	if len(kept) > 0 {
+		n := sort.Search(len(restored), func(i int) bool { return !restored[i].Time.Before(kept[0].Time) })
+		restored = restored[:n]
+	}
+	return append(restored, kept...)
+}
+
+// Add records the values sampled at t. Rollup buckets close when a sample
+// falls into the next one; closed rollups are persisted.
+func (h *History) Add(t time.Time, values map[string]float64) {
+	type closed struct {
+		res    Resolution
+		metric string
+		point  Point
+	}
+	var done []closed
+	pruneHours := false
+
+	h.mu.Lock()
+	for name, v := range values {
+		s := h.seriesOf(name)
+		s.raw = bounded(append(s.raw, Point{Time: t, Avg: v, Min: v, Max: v, Count: 1}), h.opts.RawSize)
+		if p, ok := fold(&s.minute, t.Truncate(time.Minute), v); ok {
+			s.minutes = bounded(append(s.minutes, p), h.minuteSize)
+			done = append(done, closed{Minute, name, p})
+		}
+		if p, ok := fold(&s.hour, t.Truncate(time.Hour), v); ok {
+			s.hours = bounded(append(s.hours, p), h.hourSize)
+			done = append(done, closed{Hour, name, p})
+			pruneHours = true
+		}
+	}
+	h.mu.Unlock()
+
+	if h.opts.Backend == nil {
+		return
+	}
+	for _, c := range done {
+		if err := h.opts.Backend.Save(c.res, c.metric, c.point); err != nil {
+			h.warn("Failed to persist metrics rollup", err)
+			break
+		}
+	}
+	// Persisted rollups past retention go once an hour
+	if pruneHours {
+		if err := h.opts.Backend.Prune(Minute, t.Add(-h.opts.MinuteRetention)); err != nil {
+			h.warn("Failed to prune metrics history", err)
+		}
+		if err := h.opts.Backend.Prune(Hour, t.Add(-h.opts.HourRetention)); err != nil {
+			h.warn("Failed to prune metrics history", err)
+		}
+	}
+}
+
+func (h *History) warn(msg string, err error) {
+	if h.opts.Logger != nil {
+		h.opts.Logger.Warn(msg, "error", err)
+	}
+}
+
+func (h *History) seriesOf(name string) *series {
+	s, ok := h.series[name]
+	if !ok {
+		s = &series{}
+		h.series[name] = s
+	}
+	return s
+}
+
+// fold adds v to the open bucket, first closing it when start begins a
+// later bucket; the closed bucket is returned.
+func fold(open **Point, start time.Time, v float64) (Point, bool) {
+	var closed Point
+	ok := false
+	if *open != nil && (*open).Time.Before(start) {
+		closed, ok = **open, true
+		*open = nil
+	}
+	if *open == nil {
+		*open = &Point{Time: start, Min: v, Max: v}
+	}
+	(*open).add(v)
+	return closed, ok
+}
+
+func bounded(points []Point, size int) []Point {
+	if len(points) > size {
+		points = slices.Delete(points, 0, len(points)-size)
+	}
+	return points
+}
+
+// Metrics returns the names of the recorded metrics, sorted.
+func (h *History) Metrics() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make([]string, 0, len(h.series))
+	for name := range h.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns the points of metric at res between from and to, oldest
+// first; a zero to has no upper bound. Rollups end with the open bucket,
+// which still grows. ok is false for metrics never recorded.
+func (h *History) Query(metric string, res Resolution, from, to time.Time) (points []Point, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.series[metric]
+	if !ok {
+		return nil, false
+	}
+	var all []Point
+	switch res {
+	case Raw:
+		all = s.raw
+	case Minute:
+		all = withOpen(s.minutes, s.minute)
+	case Hour:
+		all = withOpen(s.hours, s.hour)
+	}
+	points = []Point{}
+	for _, p := range all {
+		if !p.Time.Before(from) && (to.IsZero() || !p.Time.After(to)) {
+			points = append(points, p)
+		}
+	}
+	return points, true
+}
+
+func withOpen(points []Point, open *Point) []Point {
+	if open == nil {
+		return points
+	}
+	return append(slices.Clip(points), *open)
+}
@@ -0,0 +1,84 @@
+package timesync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Seconds between the NTP epoch (1900) and the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+const defaultNTPTimeout = 5 * time.Second
+
+// QueryNTP asks an NTP server for the time with a single SNTPv4 request
+// and returns the skew of the local clock: positive when the local clock is
+// ahead of the server. server is a host, optionally with a port (123).
+func QueryNTP(ctx context.Context, server string) (time.Duration, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, "123")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultNTPTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // leap indicator 0, version 4, mode 3 (client)
+	t1 := time.Now()
+	sent := toNTP(t1)
+	binary.BigEndian.PutUint64(req[40:], sent)
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	t4 := time.Now()
+	if n < 48 {
+		return 0, errors.New("ntp: short response")
+	}
+	if mode := resp[0] & 0x7; mode != 4 {
+		return 0, fmt.Errorf("ntp: unexpected mode %d", mode)
+	}
+	if resp[1] == 0 {
+		return 0, errors.New("ntp: server sent kiss-of-death")
+	}
+	if binary.BigEndian.Uint64(resp[24:]) != sent {
+		return 0, errors.New("ntp: response does not match request")
+	}
+
+	t2 := fromNTP(binary.BigEndian.Uint64(resp[32:]))
+	t3 := fromNTP(binary.BigEndian.Uint64(resp[40:]))
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	return -offset, nil
+}
+
+// toNTP converts t to a 64-bit NTP timestamp.
+func toNTP(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	return secs<<32 | frac
+}
+
+// fromNTP converts a 64-bit NTP timestamp to a time.
+func fromNTP(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(secs, nanos)
+}
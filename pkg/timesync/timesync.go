@@ -0,0 +1,206 @@
+// Package timesync detects skew of the local clock against NTP servers or
+// the server time of a database. Skew silently breaks JWT expiry checks,
+// signed requests and cron schedules, so the Monitor measures it
+// periodically and logs a warning when it exceeds the tolerated maximum.
+package timesync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/logger"
+)
+
+// Source measures the skew of the local clock: positive when the local
+// clock is ahead of the source.
+type Source interface {
+	Name() string
+	Skew(ctx context.Context) (time.Duration, error)
+}
+
+// NTPSource measures skew against an NTP server.
+type NTPSource struct {
+	Server string
+}
+
+func (s NTPSource) Name() string { return "ntp:" + s.Server }
+
+func (s NTPSource) Skew(ctx context.Context) (time.Duration, error) {
+	return QueryNTP(ctx, s.Server)
+}
+
+// ServerTimeSource measures skew against a remote clock read by Now, such
+// as SELECT now() on a database, correcting for half the round trip.
+type ServerTimeSource struct {
+	SourceName string
+	Now        func(ctx context.Context) (time.Time, error)
+}
+
+func (s ServerTimeSource) Name() string { return s.SourceName }
+
+func (s ServerTimeSource) Skew(ctx context.Context) (time.Duration, error) {
+	sent := time.Now()
+	remote, err := s.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	local := sent.Add(received.Sub(sent) / 2)
+	return local.Sub(remote), nil
+}
+
+// Measurement is the result of a skew check.
+type Measurement struct {
+	Source    string    `json:"source,omitempty"`
+	SkewMS    int64     `json:"skew_ms"`
+	Exceeded  bool      `json:"exceeded"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Skew returns the measured skew.
+func (m Measurement) Skew() time.Duration {
+	return time.Duration(m.SkewMS) * time.Millisecond
+}
+
+// OK reports whether a source could be reached.
+func (m Measurement) OK() bool {
+	return m.Error == ""
+}
+
+const checkTimeout = 10 * time.Second
+
+// Monitor checks the clock periodically against the first source that
+// answers.
+type Monitor struct {
+	sources  []Source
+	interval time.Duration
+	maxSkew  time.Duration
+	logger   *logger.Logger
+
+	mu   sync.RWMutex
+	last Measurement
+	has  bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewMonitor creates a monitor; call Start to begin checking.
+func NewMonitor(sources []Source, interval, maxSkew time.Duration, l *logger.Logger) *Monitor {
+	return &Monitor{
+		sources:  sources,
+		interval: interval,
+		maxSkew:  maxSkew,
+		logger:   l,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Name returns the display name of the component.
+func (m *Monitor) Name() string {
+	return "Clock Monitor"
+}
+
+// Start checks the clock now and then every interval until Close.
+func (m *Monitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+			m.Check(ctx)
+			cancel()
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops periodic checks.
+func (m *Monitor) Close() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.wg.Wait()
+	return nil
+}
+
+// Check measures the skew now, trying sources in order, and records the
+// result.
+func (m *Monitor) Check(ctx context.Context) Measurement {
+	measurement := Measurement{CheckedAt: time.Now()}
+	var errs []error
+	for _, source := range m.sources {
+		skew, err := source.Skew(ctx)
+		if err != nil {
+			errs = append(errs, errors.New(source.Name()+": "+err.Error()))
+			continue
+		}
+		measurement.Source = source.Name()
+		measurement.SkewMS = skew.Milliseconds()
+		measurement.Exceeded = m.maxSkew > 0 && skew.Abs() > m.maxSkew
+		break
+	}
+	if measurement.Source == "" {
+		if len(errs) == 0 {
+			errs = append(errs, errors.New("no clock source configured"))
+		}
+		measurement.Error = errors.Join(errs...).Error()
+	}
+
+	m.mu.Lock()
+	prev, hadPrev := m.last, m.has
+	m.last, m.has = measurement, true
+	m.mu.Unlock()
+
+	m.logTransition(prev, hadPrev, measurement)
+	return measurement
+}
+
+func (m *Monitor) logTransition(prev Measurement, hadPrev bool, cur Measurement) {
+	if m.logger == nil {
+		return
+	}
+	switch {
+	case !cur.OK():
+		if !hadPrev || prev.OK() {
+			m.logger.Warn("Clock skew check failed", "error", cur.Error)
+		}
+	case cur.Exceeded:
+		m.logger.Warn("Clock skew detected: JWT validation, signed requests and cron schedules may misbehave",
+			"skew", cur.Skew().String(), "max", m.maxSkew.String(), "source", cur.Source)
+	case hadPrev && prev.Exceeded:
+		m.logger.Info("Clock back in sync", "skew", cur.Skew().String(), "source", cur.Source)
+	}
+}
+
+// Last returns the most recent measurement, if any.
+func (m *Monitor) Last() (Measurement, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.last, m.has
+}
+
+// Status summarizes the monitor for the monitoring API.
+func (m *Monitor) Status() map[string]interface{} {
+	names := make([]string, len(m.sources))
+	for i, s := range m.sources {
+		names[i] = s.Name()
+	}
+	status := map[string]interface{}{
+		"sources":          names,
+		"max_skew_ms":      m.maxSkew.Milliseconds(),
+		"interval_seconds": int64(m.interval.Seconds()),
+	}
+	if last, ok := m.Last(); ok {
+		status["last"] = last
+	}
+	return status
+}
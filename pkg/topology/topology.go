@@ -0,0 +1,277 @@
+// Package topology builds the dependency graph of the application: its
+// services, the infrastructure connections they use and the external
+// services checked, each colored by health.
+package topology
+
+import (
+	"sort"
+	"strings"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/registry"
+)
+
+// Kinds of nodes.
+const (
+	KindApp            = "app"
+	KindService        = "service"
+	KindInfrastructure = "infrastructure"
+	KindExternal       = "external"
+)
+
+// Health of nodes, from best to worst; disabled and unknown nodes do not
+// affect the nodes depending on them.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+	StatusUnknown  = "unknown"
+	StatusDisabled = "disabled"
+)
+
+// Colors of the statuses, for dashboards drawing the graph.
+var Colors = map[string]string{
+	StatusOK:       "#22c55e",
+	StatusDegraded: "#f59e0b",
+	StatusDown:     "#ef4444",
+	StatusUnknown:  "#9ca3af",
+	StatusDisabled: "#6b7280",
+}
+
+// Node is a service, infrastructure component or external service. IDs
+// are the kind followed by the name, e.g. "infrastructure:redis".
+type Node struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Color  string `json:"color"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Edge points from a node to one it depends on.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the dependency graph, its nodes ordered app, services,
+// infrastructure and external services.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node returns the node with the given ID.
+func (g Graph) Node(id string) (Node, bool) {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return Node{}, false
+}
+
+// DependsOn returns the IDs of the nodes id points to, in edge order.
+func (g Graph) DependsOn(id string) []string {
+	var ids []string
+	for _, e := range g.Edges {
+		if e.From == id {
+			ids = append(ids, e.To)
+		}
+	}
+	return ids
+}
+
+// Build builds the graph of the app named appName from the components of
+// deps and the services. Services point to the components they looked up
+// (registry.ServiceDependencies); components no service uses hang off the
+// app, and the external services off the "external" checker. Deferred
+// services (registry.DeferredService) are degraded and point to the
+// components they need, down when not registered at all.
+func Build(appName string, deps *registry.Dependencies, services []interfaces.Service) Graph {
+	g := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	app := node("app", appName, KindApp, StatusOK, "")
+	g.Nodes = append(g.Nodes, app)
+
+	components, aliases := componentsOf(deps)
+	infra := make(map[string]Node, len(components))
+	for name, comp := range components {
+		infra[name] = componentNode(name, comp)
+	}
+
+	used := make(map[string]bool)
+	services = append([]interfaces.Service(nil), services...)
+	sort.Slice(services, func(i, j int) bool { return services[i].Name() < services[j].Name() })
+	var serviceNodes []Node
+	var serviceEdges []Edge
+	for _, svc := range services {
+		n := node(KindService+":"+svc.WireName(), svc.Name(), KindService, StatusOK, "")
+		deferred, isDeferred := svc.(*registry.DeferredService)
+		if isDeferred {
+			for _, need := range deferred.Missing {
+				name := registry.NeedComponent(need)
+				if _, ok := infra[name]; !ok && aliases[name] == "" {
+					infra[name] = node(KindInfrastructure+":"+name, name, KindInfrastructure, StatusDown, "not registered")
+				}
+			}
+		}
+		seen := make(map[string]bool)
+		for _, name := range registry.ServiceDependencies(svc.Name()) {
+			if canonical, ok := aliases[name]; ok {
+				name = canonical
+			}
+			dep, ok := infra[name]
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name], used[name] = true, true
+			serviceEdges = append(serviceEdges, Edge{From: n.ID, To: dep.ID})
+			if affects(dep.Status) {
+				n.Status, n.Detail = StatusDegraded, dep.Label+" is "+dep.Status
+			}
+		}
+		if isDeferred {
+			n.Status, n.Detail = StatusDegraded, "waiting for "+strings.Join(deferred.Missing, ", ")
+		}
+		if !svc.Enabled() {
+			n.Status, n.Detail = StatusDisabled, ""
+		}
+		n.Color = Colors[n.Status]
+		serviceNodes = append(serviceNodes, n)
+		g.Edges = append(g.Edges, Edge{From: app.ID, To: n.ID})
+	}
+	g.Nodes = append(g.Nodes, serviceNodes...)
+	g.Edges = append(g.Edges, serviceEdges...)
+
+	names := make([]string, 0, len(infra))
+	for name := range infra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.Nodes = append(g.Nodes, infra[name])
+		if !used[name] {
+			g.Edges = append(g.Edges, Edge{From: app.ID, To: infra[name].ID})
+		}
+	}
+
+	checkers := make(map[string]bool)
+	for _, name := range names {
+		checker, ok := components[name].(*infrastructure.HTTPManager)
+		if !ok {
+			continue
+		}
+		checkers[infra[name].ID] = true
+		for _, status := range checker.Statuses() {
+			n := externalNode(status)
+			g.Nodes = append(g.Nodes, n)
+			g.Edges = append(g.Edges, Edge{From: infra[name].ID, To: n.ID})
+		}
+	}
+
+	// Like the infrastructure_down alert, the app ignores external outages
+	for _, n := range g.Nodes[1:] {
+		if affects(n.Status) && n.Kind != KindExternal && !checkers[n.ID] {
+			g.Nodes[0].Status, g.Nodes[0].Color = StatusDegraded, Colors[StatusDegraded]
+		}
+	}
+	return g
+}
+
+func node(id, label, kind, status, detail string) Node {
+	return Node{ID: id, Label: label, Kind: kind, Status: status, Color: Colors[status], Detail: detail}
+}
+
+// affects reports whether a node in status degrades the nodes using it.
+func affects(status string) bool {
+	return status == StatusDegraded || status == StatusDown
+}
+
+// componentsOf returns the components of deps reporting a status, each
+// once under its first name in sorted order, and the other names of
+// components registered under several.
+func componentsOf(deps *registry.Dependencies) (map[string]interface{}, map[string]string) {
+	all := deps.GetAll()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	components := make(map[string]interface{})
+	aliases := make(map[string]string)
+	first := make(map[interface{}]string)
+	for _, name := range names {
+		comp := all[name]
+		if _, ok := comp.(interface{ GetStatus() map[string]interface{} }); !ok {
+			continue
+		}
+		if canonical, ok := first[comp]; ok {
+			aliases[name] = canonical
+			continue
+		}
+		first[comp] = name
+		components[name] = comp
+	}
+	return components, aliases
+}
+
+// componentNode reports a component as ok or down by the "connected" flag
+// of its status, the external checker by how many services are up, and
+// anything else as unknown.
+func componentNode(name string, comp interface{}) Node {
+	label := name
+	if named, ok := comp.(interface{ Name() string }); ok {
+		label = named.Name()
+	}
+	id := KindInfrastructure + ":" + name
+
+	if checker, ok := comp.(*infrastructure.HTTPManager); ok {
+		var up, down int
+		for _, status := range checker.Statuses() {
+			switch {
+			case status.Last == nil:
+			case status.Last.Up:
+				up++
+			default:
+				down++
+			}
+		}
+		switch {
+		case down > 0 && up == 0:
+			return node(id, label, KindInfrastructure, StatusDown, "all external services are down")
+		case down > 0:
+			return node(id, label, KindInfrastructure, StatusDegraded, "some external services are down")
+		case up > 0:
+			return node(id, label, KindInfrastructure, StatusOK, "")
+		}
+		return node(id, label, KindInfrastructure, StatusUnknown, "")
+	}
+
+	status := comp.(interface{ GetStatus() map[string]interface{} }).GetStatus()
+	connected, ok := status["connected"].(bool)
+	switch {
+	case !ok:
+		return node(id, label, KindInfrastructure, StatusUnknown, "")
+	case !connected:
+		return node(id, label, KindInfrastructure, StatusDown, "not connected")
+	}
+	return node(id, label, KindInfrastructure, StatusOK, "")
+}
+
+// externalNode reports an external service by its last check; a passing
+// check with a certificate about to expire is degraded.
+func externalNode(status infrastructure.HTTPServiceStatus) Node {
+	id := KindExternal + ":" + status.Name
+	switch {
+	case status.Last == nil:
+		return node(id, status.Name, KindExternal, StatusUnknown, "not checked yet")
+	case !status.Last.Up:
+		return node(id, status.Name, KindExternal, StatusDown, status.Last.Error)
+	case status.CertExpiring:
+		return node(id, status.Name, KindExternal, StatusDegraded, "certificate expires soon")
+	}
+	return node(id, status.Name, KindExternal, StatusOK, "")
+}
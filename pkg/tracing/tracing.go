@@ -0,0 +1,156 @@
+// Package tracing sets up OpenTelemetry tracing with an OTLP/HTTP exporter
+// and provides the helpers the HTTP middleware and the infrastructure
+// managers use to create spans. When tracing is disabled the global no-op
+// provider stays in place and every helper is effectively free.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"stackyrd/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the tracer name used for all spans of the app.
+const InstrumentationName = "stackyrd"
+
+var enabled atomic.Bool
+
+// Enabled reports whether a tracer provider has been installed.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Provider owns the installed tracer provider. It is registered as the
+// "tracing" dependency so shutdown flushes pending spans.
+type Provider struct {
+	cfg      config.TracingConfig
+	provider *sdktrace.TracerProvider
+}
+
+// Setup installs a global tracer provider exporting to cfg.Endpoint and the
+// W3C trace context propagator. serviceName is used when the config does not
+// name the service.
+func Setup(ctx context.Context, cfg config.TracingConfig, serviceName, version, env string) (*Provider, error) {
+	opts := []otlptracehttp.Option{}
+	if strings.HasPrefix(cfg.Endpoint, "http://") || strings.HasPrefix(cfg.Endpoint, "https://") {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	} else if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = serviceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+		attribute.String("service.version", version),
+		attribute.String("deployment.environment", env),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	return Install(cfg, sdktrace.NewBatchSpanProcessor(exporter), res), nil
+}
+
+// Install sets up a global provider around an existing span processor. Setup
+// uses it with the OTLP exporter; tests pass an in-memory one.
+func Install(cfg config.TracingConfig, processor sdktrace.SpanProcessor, res *resource.Resource) *Provider {
+	// Unset, sample_ratio is 1 by the config default; 0 starts no traces
+	// but still follows callers that sampled theirs
+	ratio := min(max(cfg.SampleRatio, 0), 1)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+	if res != nil {
+		opts = append(opts, sdktrace.WithResource(res))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled.Store(true)
+	return &Provider{cfg: cfg, provider: tp}
+}
+
+// Name returns the display name of the component
+func (p *Provider) Name() string {
+	return "Tracing"
+}
+
+// GetStatus reports the exporter configuration.
+func (p *Provider) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"connected":    true,
+		"service_name": p.cfg.ServiceName,
+		"endpoint":     p.cfg.Endpoint,
+		"sample_ratio": p.cfg.SampleRatio,
+	}
+}
+
+// Close flushes pending spans and stops the exporter.
+func (p *Provider) Close() error {
+	enabled.Store(false)
+	return p.provider.Shutdown(context.Background())
+}
+
+// Tracer returns the app tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start starts a span of the given kind.
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID returns the trace ID of the span in ctx, or "" without a valid
+// span.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Inject writes the trace context of ctx into carrier.
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the remote trace context found in carrier.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
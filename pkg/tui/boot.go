@@ -19,8 +19,26 @@ type ServiceInit struct {
 	Name     string
 	Enabled  bool
 	InitFunc ServiceInitFunc
+	// DetailFunc optionally replaces the "Ready" message after a successful init
+	DetailFunc func() string
+	// Component names the infrastructure component behind the entry. With
+	// boot events, the entry follows the events of that component instead
+	// of running InitFunc.
+	Component string
 }
 
+// BootEvent reports the connection state of an infrastructure component
+// to the boot sequence.
+type BootEvent struct {
+	Component string
+	Status    string // "loading", "success" or "error"
+	Message   string
+}
+
+// defaultConnectTimeout is how long the boot sequence waits for connection
+// events when StartupConfig.ConnectTimeout is not set.
+const defaultConnectTimeout = 30 * time.Second
+
 // BootModel is the Bubble Tea model for the boot sequence
 type BootModel struct {
 	spinner       spinner.Model
@@ -35,6 +53,7 @@ type BootModel struct {
 	animFrame     int
 	countdown     int       // remaining seconds in countdown
 	countdownTime time.Time // when countdown started
+	events        <-chan BootEvent
 }
 
 // Simple spinner frames
@@ -92,6 +111,7 @@ var (
 // Messages for boot model
 type bootTickMsg time.Time
 type bootDoneMsg struct{}
+type bootEventMsg BootEvent
 
 // NewBootModel creates a new boot model
 func NewBootModel(cfg StartupConfig, initQueue []ServiceInit) BootModel {
@@ -116,19 +136,65 @@ func NewBootModel(cfg StartupConfig, initQueue []ServiceInit) BootModel {
 		initQueue: initQueue,
 		results:   results,
 		config:    cfg,
-		startTime: time.Now(),
+		startTime: env.Now(),
 		width:     100,
 		phase:     "starting",
 	}
 }
 
+// WithEvents makes entries with a Component follow the connection events
+// received on events until they succeed or fail.
+func (m BootModel) WithEvents(events <-chan BootEvent) BootModel {
+	m.events = events
+	return m
+}
+
 func (m BootModel) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
 		bootTickCmd(),
+		waitForBootEvent(m.events),
 	)
 }
 
+// waitForBootEvent delivers the next event, or nothing once events is
+// closed or unset.
+func waitForBootEvent(events <-chan BootEvent) tea.Cmd {
+	if events == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		e, ok := <-events
+		if !ok {
+			return nil
+		}
+		return bootEventMsg(e)
+	}
+}
+
+// followsEvents reports whether the entry at i is resolved by events.
+func (m BootModel) followsEvents(i int) bool {
+	return m.events != nil && m.initQueue[i].Component != ""
+}
+
+// connecting reports whether an entry still waits for its connection.
+func (m BootModel) connecting() bool {
+	for i, r := range m.results {
+		if m.followsEvents(i) && (r.Status == "loading" || r.Status == "pending") {
+			return true
+		}
+	}
+	return false
+}
+
+// connectTimeout is how long the boot sequence waits for connections.
+func (m BootModel) connectTimeout() time.Duration {
+	if m.config.ConnectTimeout > 0 {
+		return m.config.ConnectTimeout
+	}
+	return defaultConnectTimeout
+}
+
 func bootTickCmd() tea.Cmd {
 	return tea.Every(time.Millisecond*80, func(t time.Time) tea.Msg {
 		return bootTickMsg(t)
@@ -151,6 +217,15 @@ func (m BootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
 
+	case bootEventMsg:
+		for i := range m.results {
+			if m.followsEvents(i) && m.initQueue[i].Component == msg.Component && m.results[i].Status != "skipped" {
+				m.results[i].Status = msg.Status
+				m.results[i].Message = msg.Message
+			}
+		}
+		return m, waitForBootEvent(m.events)
+
 	case bootTickMsg:
 		m.animFrame = (m.animFrame + 1) % len(bootFrames)
 
@@ -172,13 +247,26 @@ func (m BootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 
+			if m.current >= len(m.initQueue) && m.connecting() {
+				if since(m.startTime) < m.connectTimeout() {
+					return m, tea.Batch(m.spinner.Tick, bootTickCmd())
+				}
+				// Give up waiting; the components keep connecting in the background
+				for i, r := range m.results {
+					if m.followsEvents(i) && (r.Status == "loading" || r.Status == "pending") {
+						m.results[i].Status = "error"
+						m.results[i].Message = fmt.Sprintf("not connected after %s", m.connectTimeout())
+					}
+				}
+			}
+
 			if m.current >= len(m.initQueue) {
 				m.phase = "complete"
 				m.done = true
 				// Start countdown if configured
 				if m.config.IdleSeconds > 0 {
 					m.countdown = m.config.IdleSeconds
-					m.countdownTime = time.Now()
+					m.countdownTime = env.Now()
 					m.phase = "countdown"
 					return m, tea.Batch(m.spinner.Tick, bootTickCmd())
 				}
@@ -187,6 +275,16 @@ func (m BootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				})
 			}
 
+			// Connections are reported by events; wait for them at the end
+			if m.followsEvents(m.current) {
+				if m.results[m.current].Status == "pending" {
+					m.results[m.current].Status = "loading"
+					m.results[m.current].Message = "Connecting..."
+				}
+				m.current++
+				return m, tea.Batch(m.spinner.Tick, bootTickCmd())
+			}
+
 			// Initialize current service
 			if m.results[m.current].Status == "pending" {
 				m.results[m.current].Status = "loading"
@@ -202,6 +300,9 @@ func (m BootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					} else {
 						m.results[m.current].Status = "success"
 						m.results[m.current].Message = "Ready"
+						if svc.DetailFunc != nil {
+							m.results[m.current].Message = svc.DetailFunc()
+						}
 					}
 				} else {
 					m.results[m.current].Status = "success"
@@ -215,7 +316,7 @@ func (m BootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		if m.phase == "countdown" {
 			// Update countdown based on elapsed time
-			elapsed := int(time.Since(m.countdownTime).Seconds())
+			elapsed := int(since(m.countdownTime).Seconds())
 			m.countdown = m.config.IdleSeconds - elapsed
 
 			if m.countdown <= 0 {
@@ -296,7 +397,7 @@ func (m BootModel) View() string {
 
 	// Final message
 	if m.done {
-		elapsed := time.Since(m.startTime).Round(time.Millisecond)
+		elapsed := since(m.startTime).Round(time.Millisecond)
 
 		switch m.phase {
 		case "complete":
@@ -410,7 +511,13 @@ func (m BootModel) HasErrors() bool {
 
 // RunBootSequence runs the boot sequence TUI
 func RunBootSequence(cfg StartupConfig, initQueue []ServiceInit) ([]ServiceStatus, error) {
-	m := NewBootModel(cfg, initQueue)
+	return RunBootSequenceWithEvents(cfg, initQueue, nil)
+}
+
+// RunBootSequenceWithEvents runs the boot sequence TUI, resolving entries
+// with a Component from the connection events on events.
+func RunBootSequenceWithEvents(cfg StartupConfig, initQueue []ServiceInit, events <-chan BootEvent) ([]ServiceStatus, error) {
+	m := NewBootModel(cfg, initQueue).WithEvents(events)
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	finalModel, err := p.Run()
 	if err != nil {
@@ -6,15 +6,19 @@ import (
 	"strings"
 	"time"
 
+	"stackyrd/pkg/sysinfo"
+
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/mem"
 )
 
+// dashboardSystem reads system stats without blocking the UI; CPU usage is
+// measured between ticks.
+var dashboardSystem = sysinfo.New(sysinfo.Host(), 0)
+
 // DashboardConfig contains configuration for the dashboard TUI
 type DashboardConfig struct {
 	AppName    string
@@ -43,10 +47,7 @@ type DashboardModel struct {
 	filteredSvc   []ServiceStatus
 	filterText    string
 	showFilter    bool
-	cpuPercent    float64
-	memPercent    float64
-	memUsed       uint64
-	memTotal      uint64
+	system        sysinfo.Stats
 	goroutines    int
 	lastUpdate    time.Time
 	width         int
@@ -141,7 +142,7 @@ func NewDashboardModel(cfg DashboardConfig, infra []InfraStatus, services []Serv
 		allServices:   services,
 		filteredInfra: infra,
 		filteredSvc:   services,
-		lastUpdate:    time.Now(),
+		lastUpdate:    env.Now(),
 		width:         80,
 		height:        24,
 	}
@@ -237,18 +238,11 @@ func (m DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case dashTickMsg:
 		m.frame = (m.frame + 1) % len(runningFrames)
-		m.lastUpdate = time.Now()
+		m.lastUpdate = env.Now()
 		m.goroutines = runtime.NumGoroutine()
 
 		// Update system stats
-		if v, err := mem.VirtualMemory(); err == nil {
-			m.memPercent = v.UsedPercent
-			m.memUsed = v.Used / 1024 / 1024
-			m.memTotal = v.Total / 1024 / 1024
-		}
-		if c, err := cpu.Percent(0, false); err == nil && len(c) > 0 {
-			m.cpuPercent = c[0]
-		}
+		m.system = dashboardSystem.Collect()
 
 		return m, tea.Batch(m.spinner.Tick, dashTickCmd())
 	}
@@ -296,7 +290,7 @@ func (m DashboardModel) View() string {
 
 	// Running animation
 	animation := lipgloss.NewStyle().Foreground(lipgloss.Color(pulseColor)).Render(runningFrames[m.frame])
-	uptime := time.Since(m.config.StartTime).Round(time.Second)
+	uptime := since(m.config.StartTime).Round(time.Second)
 	statusLine := fmt.Sprintf("  %s %s  Uptime: %s  Port: %s  Env: %s",
 		m.spinner.View(),
 		animation,
@@ -354,29 +348,18 @@ func (m DashboardModel) renderSystemBox() string {
 	var lines []string
 	lines = append(lines, dashHeaderStyle.Render("⊙ System Resources"))
 
-	// CPU with color-coded bar
-	cpuBar := m.renderProgressBar(m.cpuPercent, 15)
-	cpuLine := fmt.Sprintf("%s %s %s",
-		dashLabelStyle.Render("CPU:"),
-		cpuBar,
-		m.getPercentStyle(m.cpuPercent).Render(fmt.Sprintf("%.1f%%", m.cpuPercent)),
-	)
-	lines = append(lines, cpuLine)
-
-	// Memory with color-coded bar
-	memBar := m.renderProgressBar(m.memPercent, 15)
-	memLine := fmt.Sprintf("%s %s %s",
-		dashLabelStyle.Render("RAM:"),
-		memBar,
-		m.getPercentStyle(m.memPercent).Render(fmt.Sprintf("%.1f%%", m.memPercent)),
-	)
-	lines = append(lines, memLine)
-
-	memDetail := fmt.Sprintf("     %s / %s MB",
-		dashValueStyle.Render(fmt.Sprintf("%d", m.memUsed)),
-		dashDimStyle.Render(fmt.Sprintf("%d", m.memTotal)),
-	)
-	lines = append(lines, memDetail)
+	// CPU, memory and disk with color-coded bars; n/a where the platform
+	// does not report them
+	lines = append(lines, m.renderPercentLine("CPU: ", sysinfo.MetricCPU, m.system.CPU.Percent))
+	lines = append(lines, m.renderPercentLine("RAM: ", sysinfo.MetricMemory, m.system.Memory.UsedPercent))
+	if m.available(sysinfo.MetricMemory) {
+		memDetail := fmt.Sprintf("     %s / %s MB",
+			dashValueStyle.Render(fmt.Sprintf("%d", m.system.Memory.UsedMB)),
+			dashDimStyle.Render(fmt.Sprintf("%d", m.system.Memory.TotalMB)),
+		)
+		lines = append(lines, memDetail)
+	}
+	lines = append(lines, m.renderPercentLine("Disk:", sysinfo.MetricDisk, m.system.Disk.UsedPercent))
 
 	// Goroutines
 	goLine := fmt.Sprintf("%s %s",
@@ -472,6 +455,26 @@ func (m DashboardModel) renderServicesBox() string {
 	return dashBoxStyle.Render(content)
 }
 
+// available reports whether metric can be shown: it is readable, or no
+// stats were collected yet.
+func (m DashboardModel) available(metric string) bool {
+	ok, collected := m.system.Available[metric]
+	return ok || !collected
+}
+
+// renderPercentLine renders a labelled usage bar, or n/a when the metric
+// is unavailable on this platform.
+func (m DashboardModel) renderPercentLine(label, metric string, percent float64) string {
+	if !m.available(metric) {
+		return fmt.Sprintf("%s %s", dashLabelStyle.Render(label), dashDimStyle.Render("n/a"))
+	}
+	return fmt.Sprintf("%s %s %s",
+		dashLabelStyle.Render(label),
+		m.renderProgressBar(percent, 15),
+		m.getPercentStyle(percent).Render(fmt.Sprintf("%.1f%%", percent)),
+	)
+}
+
 func (m DashboardModel) renderProgressBar(percent float64, width int) string {
 	filled := int(percent / 100.0 * float64(width))
 	if filled > width {
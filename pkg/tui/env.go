@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"time"
+
+	"stackyrd/pkg/utils"
+)
+
+// Environment supplies the clock and process figures the views render.
+// Snapshot tests replace it with fixed values so rendered output does not
+// depend on when or where it runs.
+type Environment struct {
+	Now        func() time.Time
+	MemSelfMiB func() uint64
+	Goroutines func() int
+}
+
+// DefaultEnvironment reads the wall clock and the running process.
+func DefaultEnvironment() Environment {
+	return Environment{
+		Now:        time.Now,
+		MemSelfMiB: func() uint64 { return uint64(utils.GetMemSelf()) },
+		Goroutines: func() int { return int(utils.GetRoutine()) },
+	}
+}
+
+var env = DefaultEnvironment()
+
+// SetEnvironment replaces the environment of all views and returns a
+// function restoring the previous one. Unset fields keep their defaults.
+func SetEnvironment(e Environment) (restore func()) {
+	prev := env
+	def := DefaultEnvironment()
+	if e.Now == nil {
+		e.Now = def.Now
+	}
+	if e.MemSelfMiB == nil {
+		e.MemSelfMiB = def.MemSelfMiB
+	}
+	if e.Goroutines == nil {
+		e.Goroutines = def.Goroutines
+	}
+	env = e
+	return func() { env = prev }
+}
+
+// since is time.Since on the environment clock.
+func since(t time.Time) time.Duration {
+	return env.Now().Sub(t)
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"os"
+	"sort"
 	"stackyrd/pkg/tui/template"
 	"stackyrd/pkg/utils"
 	"strings"
@@ -23,6 +24,26 @@ type LiveConfig struct {
 	Port       string
 	Env        string
 	OnShutdown func() // Callback function to trigger shutdown
+	// ContextLines is how many lines around the selected log line the
+	// context view shows on each side (default 5)
+	ContextLines int
+	// Actions lists application commands for the ctrl+k palette, next to
+	// the built-in log view commands; it is called each time the palette
+	// opens
+	Actions func() []PaletteAction
+	// Topology returns the dependency graph for the topology view (t or
+	// the palette), first node the root; nil hides the view. It is called
+	// off the UI goroutine each time the view opens or refreshes
+	Topology func() []TopologyNode
+}
+
+// PaletteAction is an application command offered in the command palette.
+// Run is called off the UI goroutine; its message, or error, is logged.
+type PaletteAction struct {
+	Category string
+	Title    string
+	Hint     string
+	Run      func() (string, error)
 }
 
 // LogEntry represents a log entry
@@ -39,8 +60,10 @@ type LiveModel struct {
 	config          LiveConfig
 	allLogs         []LogEntry
 	filteredLogs    []LogEntry
+	filteredSeqs    []int // sequence numbers of filteredLogs
 	logsMutex       sync.RWMutex
 	filterText      string
+	logSeq          int  // number of logs received, numbering each entry
 	scrollOffset    int  // Current scroll position in the log list
 	maxVisibleLines int  // Maximum number of log lines to show
 	autoScroll      bool // Whether to auto-scroll to bottom on new logs
@@ -52,10 +75,25 @@ type LiveModel struct {
 	maxLogs         int
 	program         *tea.Program
 
+	// Selected line of the filtered view and the context view around it,
+	// both by sequence number so they survive old logs being dropped
+	selectedSeq  int
+	contextMode  bool
+	contextSeq   int
+	contextLines int
+
+	// Topology view replacing the logs, rendered when its graph arrives
+	topologyMode  bool
+	topologyLines []string
+
 	// Reusable dialog components
 	exitDialog   *template.DialogModel
 	filterDialog *template.DialogModel
 	queryDialog  *template.DialogModel
+
+	// Command palette and the commands of the items it currently shows
+	palette        *template.PaletteModel
+	paletteActions map[string]func() tea.Cmd
 }
 
 // Live TUI styles
@@ -102,6 +140,11 @@ func NewLiveModel(cfg LiveConfig) *LiveModel {
 	ti.Prompt = ""
 	ti.Cursor.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#8daea5"))
 
+	contextLines := cfg.ContextLines
+	if contextLines <= 0 {
+		contextLines = 5
+	}
+
 	// Initialize reusable dialogs
 	exitDialog := template.NewExitConfirmationDialog()
 	filterDialog := template.NewFilterDialog("")
@@ -115,19 +158,27 @@ func NewLiveModel(cfg LiveConfig) *LiveModel {
 		filteredLogs:    make([]LogEntry, 0),
 		maxVisibleLines: 15,   // Default number of log lines to show
 		autoScroll:      true, // Start with auto-scroll enabled
-		startTime:       time.Now(),
+		startTime:       env.Now(),
 		width:           80,
 		height:          24,
 		maxLogs:         1000, // Unlimited logs (0 disables the limit)
 		exitDialog:      exitDialog,
 		filterDialog:    filterDialog,
 		queryDialog:     queryDialog,
+		palette:         template.NewPalette("Command Palette"),
+		contextLines:    contextLines,
 	}
 }
 
 type liveTickMsg time.Time
 type logMsg LogEntry
 
+// LogEntryMsg wraps entry as a message that appends it to the log view,
+// e.g. to feed recorded or synthetic logs through Update.
+func LogEntryMsg(entry LogEntry) tea.Msg {
+	return logMsg(entry)
+}
+
 func liveTickCmd() tea.Cmd {
 	return tea.Every(time.Millisecond*100, func(t time.Time) tea.Msg {
 		return liveTickMsg(t)
@@ -170,17 +221,32 @@ func (m *LiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if result.Confirmed {
 					// Apply filter
 					m.filterText = result.Value
+					m.contextMode = false
 					m.updateFilteredLogs()
 					m.scrollToTop() // Scroll to top to show first filtered results
+					if len(m.filteredSeqs) > 0 {
+						m.selectedSeq = m.filteredSeqs[0]
+					}
 				} else {
 					// Filter cancelled, reset
 					m.filterText = ""
+					m.contextMode = false
 					m.updateFilteredLogs()
 				}
 			}
 			return m, cmd
 		}
 
+		if m.palette.IsActive() {
+			cmd := m.palette.Update(msg)
+			if item := m.palette.GetResult(); item != nil {
+				if run, ok := m.paletteActions[item.ID]; ok {
+					return m, tea.Batch(cmd, run())
+				}
+			}
+			return m, cmd
+		}
+
 		if m.queryDialog.IsActive() {
 			cmd := m.queryDialog.Update(msg)
 			if result := m.queryDialog.GetResult(); result != nil {
@@ -193,6 +259,44 @@ func (m *LiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 
+		if m.topologyMode {
+			switch msg.String() {
+			case "esc", "t":
+				m.topologyMode = false
+				return m, nil
+			case "r":
+				return m, m.loadTopology()
+			}
+		}
+
+		// The context view and the filtered view's selection take the
+		// navigation keys before the plain log view
+		if m.contextMode {
+			switch msg.String() {
+			case "esc", "enter", "c":
+				m.leaveContext()
+				return m, nil
+			case "+", "=":
+				m.resizeContext(5)
+				return m, nil
+			case "-":
+				m.resizeContext(-5)
+				return m, nil
+			}
+		} else if m.filterText != "" {
+			switch msg.String() {
+			case "down", "j":
+				m.moveSelection(1)
+				return m, nil
+			case "up", "k":
+				m.moveSelection(-1)
+				return m, nil
+			case "enter", "c":
+				m.enterContext()
+				return m, nil
+			}
+		}
+
 		// Handle normal navigation
 		switch msg.String() {
 		case "ctrl+c":
@@ -207,6 +311,13 @@ func (m *LiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Show query dialog
 			m.queryDialog.Show()
 			return m, nil
+		case "ctrl+k":
+			// Show command palette
+			m.openPalette()
+			return m, nil
+		case "t":
+			// Show service topology
+			return m, m.loadTopology()
 		case "down", "j":
 			// Scroll down
 			m.scrollDown()
@@ -263,9 +374,15 @@ func (m *LiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// 	m.frame = (m.frame + 1) % len(loopingProgressFrames)
 	// 	return m, tea.Batch(m.spinner.Tick, liveTickCmd())
 
+	case topologyMsg:
+		m.topologyMode = true
+		m.topologyLines = RenderTopology(msg)
+		return m, nil
+
 	case logMsg:
 		m.logsMutex.Lock()
 		m.allLogs = append(m.allLogs, LogEntry(msg))
+		m.logSeq++
 		// Keep only the last maxLogs entries (if maxLogs > 0)
 		if m.maxLogs > 0 && len(m.allLogs) > m.maxLogs {
 			m.allLogs = m.allLogs[len(m.allLogs)-m.maxLogs:]
@@ -274,10 +391,7 @@ func (m *LiveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Auto-scroll to bottom if enabled
 		if m.autoScroll {
-			logsToShow := m.filteredLogs
-			if m.filterText == "" {
-				logsToShow = m.allLogs
-			}
+			logsToShow, _ := m.shownLogs()
 
 			// Calculate available height (same as in View method)
 			totalHeight := m.height
@@ -344,10 +458,7 @@ func (m *LiveModel) View() string {
 
 	// If auto-scroll is enabled, ensure we're at the bottom
 	if m.autoScroll {
-		logsToShow := m.filteredLogs
-		if m.filterText == "" {
-			logsToShow = m.allLogs
-		}
+		logsToShow, _ := m.shownLogs()
 		m.scrollOffset = len(logsToShow) - availableHeight
 		if m.scrollOffset < 0 {
 			m.scrollOffset = 0
@@ -382,14 +493,14 @@ func (m *LiveModel) View() string {
 	mainContent.WriteString("\n")
 
 	// Status line
-	uptime := time.Since(m.startTime).Round(time.Second)
+	uptime := since(m.startTime).Round(time.Second)
 	statusLine := fmt.Sprintf("  %s %s ● Service Port: %s ● Env: %s ● Usage: %s ● Routine: %s ● Uptime: %s",
 		m.spinner.View(),
 		liveStatusStyle.Render("RUNNING"),
 		liveInfoStyle.Render(m.config.Port),
 		liveInfoStyle.Render(m.config.Env),
-		liveInfoStyle.Render(fmt.Sprintf("%d MiB", utils.GetMemSelf())),
-		liveInfoStyle.Render(fmt.Sprintf("%d", utils.GetRoutine())),
+		liveInfoStyle.Render(fmt.Sprintf("%d MiB", env.MemSelfMiB())),
+		liveInfoStyle.Render(fmt.Sprintf("%d", env.Goroutines())),
 		liveInfoStyle.Render(uptime.String()),
 	)
 	mainContent.WriteString(statusLine)
@@ -404,17 +515,29 @@ func (m *LiveModel) View() string {
 		logWidth = 136
 	}
 
+	logsTitle := "▪ Live Logs"
+	if m.contextMode {
+		logsTitle = fmt.Sprintf("▪ Live Logs ● Context ±%d", m.contextLines)
+	}
+	if m.topologyMode {
+		logsTitle = "▪ Topology"
+	}
 	stickyLogsHeader := lipgloss.NewStyle().
 		Bold(true).
 		Foreground(lipgloss.Color("#626262ff")).
-		Render("▪ Live Logs")
+		Render(logsTitle)
 	mainContent.WriteString(stickyLogsHeader)
 	mainContent.WriteString("\n")
 	mainContent.WriteString(liveDimStyle.Render(strings.Repeat("─", logWidth)))
 	mainContent.WriteString("\n")
 
 	// SCROLLABLE CONTENT - Only the log entries (no header/border)
-	logLines := m.renderLogEntriesOnly()
+	var logLines []string
+	if m.topologyMode {
+		logLines = m.topologyView(availableHeight)
+	} else {
+		logLines = m.renderLogEntriesOnly()
+	}
 	if len(logLines) > availableHeight {
 		// Apply scrolling offset to log entries only
 		startLine := m.scrollOffset
@@ -453,6 +576,14 @@ func (m *LiveModel) View() string {
 		footerText = liveDimStyle.Render("Enter: apply filter ● Esc: cancel")
 	} else if m.queryDialog.IsActive() {
 		footerText = liveDimStyle.Render("Enter: exec query ● Esc: cancel")
+	} else if m.topologyMode {
+		footerText = liveDimStyle.Render("Topology ● r: refresh ● Esc: back to logs ● ctrl+c: exit")
+	} else if m.contextMode {
+		footerText = liveDimStyle.Render(fmt.Sprintf("Context of '%s' match ● ↑/↓: scroll ● +/-: more/less context ● Esc: back to filtered logs",
+			m.filterText))
+	} else if m.filterText != "" {
+		footerText = liveDimStyle.Render(fmt.Sprintf("Filter: '%s' (%d matches) ● ↑/↓: select ● Enter: context ● ctrl+k: commands ● ctrl+c: exit",
+			m.filterText, len(m.filteredLogs)))
 	} else {
 		filterInfo := ""
 		if m.filterText != "" {
@@ -462,8 +593,8 @@ func (m *LiveModel) View() string {
 		if m.autoScroll {
 			autoScrollInfo = "Auto-scroll: ON ● "
 		}
-		footerText = liveDimStyle.Render(fmt.Sprintf("%s%sLast update: %s ● ctrl+c: exit ● /: filter ● ctrl+l: auto-scroll ● F2: clear logs",
-			filterInfo, autoScrollInfo, time.Now().Format("15:04:05")))
+		footerText = liveDimStyle.Render(fmt.Sprintf("%s%sLast update: %s ● ctrl+c: exit ● ctrl+k: commands ● /: filter ● ctrl+l: auto-scroll ● F2: clear logs",
+			filterInfo, autoScrollInfo, env.Now().Format("15:04:05")))
 	}
 	mainContent.WriteString("\n")
 	mainContent.WriteString(footerText)
@@ -484,6 +615,10 @@ func (m *LiveModel) View() string {
 		return m.queryDialog.View(m.width, m.height)
 	}
 
+	if m.palette.IsActive() {
+		return m.palette.View(m.width, m.height)
+	}
+
 	// Wrap entire content with minimal padding
 	containerStyle := lipgloss.NewStyle().Padding(1)
 	return containerStyle.Render(b.String())
@@ -505,15 +640,12 @@ func (m *LiveModel) renderLogEntriesOnly() []string {
 	m.logsMutex.RLock()
 	defer m.logsMutex.RUnlock()
 
-	logsToShow := m.filteredLogs
-	if m.filterText == "" {
-		logsToShow = m.allLogs
-	}
+	logsToShow, selected := m.shownLogs()
 
 	if len(logsToShow) == 0 {
 		lines = append(lines, liveDimStyle.Render("  Waiting for logs..."))
 	} else {
-		for _, log := range logsToShow {
+		for i, log := range logsToShow {
 			levelStyle := m.getLevelStyle(log.Level)
 			timeStr := log.Time.Format("15:04:05")
 			levelStr := fmt.Sprintf("[%-5s]", strings.ToUpper(log.Level))
@@ -528,8 +660,13 @@ func (m *LiveModel) renderLogEntriesOnly() []string {
 				msg = msg[:maxMsgLen-3] + "..."
 			}
 
-			// Build the line with proper formatting
-			line := fmt.Sprintf("  %s %s %s",
+			// Build the line with proper formatting, marking the selection
+			marker := "  "
+			if i == selected {
+				marker = liveStatusStyle.Render("▸ ")
+			}
+			line := fmt.Sprintf("%s%s %s %s",
+				marker,
 				liveDimStyle.Render(timeStr),
 				levelStyle.Render(levelStr),
 				lipgloss.NewStyle().Foreground(lipgloss.Color("#F8F8F2")).Render(msg),
@@ -562,7 +699,7 @@ func (m *LiveModel) getLevelStyle(level string) lipgloss.Style {
 func (m *LiveModel) AddLog(level, message string) {
 	if m.program != nil {
 		m.program.Send(logMsg{
-			Time:    time.Now(),
+			Time:    env.Now(),
 			Level:   level,
 			Message: message,
 		})
@@ -685,20 +822,115 @@ func (m *LiveModel) updateFilteredLogs() {
 		// No filter, show all logs
 		m.filteredLogs = make([]LogEntry, len(m.allLogs))
 		copy(m.filteredLogs, m.allLogs)
+		m.filteredSeqs = nil
 		return
 	}
 
 	filterLower := strings.ToLower(m.filterText)
 	var filtered []LogEntry
+	var seqs []int
 
-	for _, log := range m.allLogs {
+	first := m.logSeq - len(m.allLogs)
+	for i, log := range m.allLogs {
 		if strings.Contains(strings.ToLower(log.Level), filterLower) ||
 			strings.Contains(strings.ToLower(log.Message), filterLower) {
 			filtered = append(filtered, log)
+			seqs = append(seqs, first+i)
 		}
 	}
 
 	m.filteredLogs = filtered
+	m.filteredSeqs = seqs
+}
+
+// shownLogs returns the logs the view currently lists: the context window,
+// the filtered logs or all of them. The index of the highlighted line is -1
+// when there is none.
+func (m *LiveModel) shownLogs() ([]LogEntry, int) {
+	if m.contextMode {
+		target := m.contextSeq - (m.logSeq - len(m.allLogs))
+		if target < 0 || target >= len(m.allLogs) {
+			// The line was dropped from the buffer meanwhile
+			return nil, -1
+		}
+		lo := max(target-m.contextLines, 0)
+		hi := min(target+m.contextLines+1, len(m.allLogs))
+		return m.allLogs[lo:hi], target - lo
+	}
+	if m.filterText == "" {
+		return m.allLogs, -1
+	}
+	return m.filteredLogs, m.selectedIndex()
+}
+
+// selectedIndex returns the index of the selected line in the filtered
+// logs, falling back to the first match once the selection was dropped.
+func (m *LiveModel) selectedIndex() int {
+	if len(m.filteredSeqs) == 0 {
+		return -1
+	}
+	i := sort.SearchInts(m.filteredSeqs, m.selectedSeq)
+	if i == len(m.filteredSeqs) || m.filteredSeqs[i] != m.selectedSeq {
+		return 0
+	}
+	return i
+}
+
+// moveSelection moves the filtered view's selection by delta lines and
+// scrolls to keep it visible.
+func (m *LiveModel) moveSelection(delta int) {
+	i := m.selectedIndex()
+	if i < 0 {
+		return
+	}
+	i = min(max(i+delta, 0), len(m.filteredSeqs)-1)
+	m.selectedSeq = m.filteredSeqs[i]
+	m.autoScroll = false
+
+	if i < m.scrollOffset {
+		m.scrollOffset = i
+	} else if i >= m.scrollOffset+m.maxVisibleLines {
+		m.scrollOffset = i - m.maxVisibleLines + 1
+	}
+}
+
+// enterContext shows the selected filtered line among its neighbours in the
+// unfiltered stream.
+func (m *LiveModel) enterContext() {
+	i := m.selectedIndex()
+	if i < 0 {
+		return
+	}
+	m.contextSeq = m.filteredSeqs[i]
+	m.contextMode = true
+	m.autoScroll = false
+	m.centerContext()
+}
+
+// leaveContext returns to the filtered view with the selection in sight.
+func (m *LiveModel) leaveContext() {
+	m.contextMode = false
+	m.scrollOffset = 0
+	m.moveSelection(0)
+}
+
+// resizeContext changes the number of context lines by delta, keeping at
+// least one on each side.
+func (m *LiveModel) resizeContext(delta int) {
+	m.contextLines = max(m.contextLines+delta, 1)
+	m.centerContext()
+}
+
+// centerContext scrolls the context view so the target line is centered.
+func (m *LiveModel) centerContext() {
+	logs, target := m.shownLogs()
+	m.scrollOffset = target - m.maxVisibleLines/2
+	if maxOffset := len(logs) - m.maxVisibleLines; m.scrollOffset > maxOffset {
+		m.scrollOffset = maxOffset
+	}
+	if m.scrollOffset < 0 {
+		m.scrollOffset = 0
+	}
 }
 
 func (m *LiveModel) updateQuery(query string) {
@@ -708,12 +940,78 @@ func (m *LiveModel) updateQuery(query string) {
 	}()
 }
 
+// openPalette shows the command palette with the built-in commands followed
+// by the application's.
+func (m *LiveModel) openPalette() {
+	type command struct {
+		item template.PaletteItem
+		run  func() tea.Cmd
+	}
+	// Built-in commands change the model and run on the UI goroutine
+	local := func(f func()) func() tea.Cmd {
+		return func() tea.Cmd {
+			f()
+			return nil
+		}
+	}
+	commands := []command{
+		{template.PaletteItem{Category: "Logs", Title: "Filter logs", Hint: "/"}, local(m.filterDialog.Show)},
+		{template.PaletteItem{Category: "Logs", Title: "Clear filter"}, local(func() {
+			m.filterText = ""
+			m.contextMode = false
+			m.updateFilteredLogs()
+		})},
+		{template.PaletteItem{Category: "Logs", Title: "Toggle auto-scroll", Hint: "ctrl+l"}, local(func() {
+			m.autoScroll = !m.autoScroll
+			if m.autoScroll {
+				m.scrollToBottom()
+			}
+		})},
+		{template.PaletteItem{Category: "Logs", Title: "Jump to top", Hint: "g"}, local(m.scrollToTop)},
+		{template.PaletteItem{Category: "Logs", Title: "Jump to bottom", Hint: "G"}, local(m.scrollToBottom)},
+		{template.PaletteItem{Category: "Logs", Title: "Clear logs", Hint: "F2"}, local(m.clearLogs)},
+		{template.PaletteItem{Category: "App", Title: "Run command query", Hint: "ctrl+p"}, local(m.queryDialog.Show)},
+		{template.PaletteItem{Category: "App", Title: "Exit", Hint: "ctrl+c"}, local(m.exitDialog.Show)},
+	}
+	if m.config.Topology != nil {
+		commands = append(commands, command{template.PaletteItem{Category: "App", Title: "Show topology", Hint: "t"}, m.loadTopology})
+	}
+	if m.filterText != "" && !m.contextMode {
+		commands = append(commands, command{template.PaletteItem{Category: "Logs", Title: "Show selected line in context", Hint: "enter"}, local(m.enterContext)})
+	}
+
+	if m.config.Actions != nil {
+		for _, action := range m.config.Actions() {
+			action := action
+			item := template.PaletteItem{Category: action.Category, Title: action.Title, Hint: action.Hint}
+			commands = append(commands, command{item, func() tea.Cmd {
+				return func() tea.Msg {
+					message, err := action.Run()
+					if err != nil {
+						return logMsg{Time: env.Now(), Level: "error", Message: item.Category + ": " + item.Title + " failed: " + err.Error()}
+					}
+					if message == "" {
+						message = item.Category + ": " + item.Title
+					}
+					return logMsg{Time: env.Now(), Level: "info", Message: message}
+				}
+			}})
+		}
+	}
+
+	items := make([]template.PaletteItem, len(commands))
+	m.paletteActions = make(map[string]func() tea.Cmd, len(commands))
+	for i, c := range commands {
+		c.item.ID = fmt.Sprintf("%d", i)
+		items[i] = c.item
+		m.paletteActions[c.item.ID] = c.run
+	}
+	m.palette.Show(items)
+}
+
 // Scroll methods for navigating through logs
 func (m *LiveModel) scrollDown() {
-	logsToShow := m.filteredLogs
-	if m.filterText == "" {
-		logsToShow = m.allLogs
-	}
+	logsToShow, _ := m.shownLogs()
 
 	if m.scrollOffset < len(logsToShow)-m.maxVisibleLines {
 		m.scrollOffset++
@@ -729,10 +1027,7 @@ func (m *LiveModel) scrollUp() {
 }
 
 func (m *LiveModel) pageDown() {
-	logsToShow := m.filteredLogs
-	if m.filterText == "" {
-		logsToShow = m.allLogs
-	}
+	logsToShow, _ := m.shownLogs()
 
 	m.scrollOffset += m.maxVisibleLines
 	maxOffset := len(logsToShow) - m.maxVisibleLines
@@ -759,10 +1054,7 @@ func (m *LiveModel) scrollToTop() {
 }
 
 func (m *LiveModel) scrollToBottom() {
-	logsToShow := m.filteredLogs
-	if m.filterText == "" {
-		logsToShow = m.allLogs
-	}
+	logsToShow, _ := m.shownLogs()
 
 	m.scrollOffset = len(logsToShow) - m.maxVisibleLines
 	if m.scrollOffset < 0 {
@@ -779,10 +1071,12 @@ func (m *LiveModel) clearLogs() {
 	// Clear all logs
 	m.allLogs = make([]LogEntry, 0)
 	m.filteredLogs = make([]LogEntry, 0)
+	m.filteredSeqs = nil
 
 	// Reset scroll and filter state
 	m.scrollOffset = 0
 	m.filterText = ""
+	m.contextMode = false
 	m.textinput.SetValue("")
 
 	// Keep auto-scroll state as-is
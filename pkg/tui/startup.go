@@ -26,6 +26,9 @@ type StartupConfig struct {
 	Port        string
 	Env         string
 	IdleSeconds int // How long to display the boot screen (0 to skip immediately)
+	// ConnectTimeout bounds how long the boot sequence waits for connection
+	// events (default 30s)
+	ConnectTimeout time.Duration
 }
 
 // StartupModel is the Bubble Tea model for startup animation
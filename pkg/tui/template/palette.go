@@ -0,0 +1,218 @@
+package template
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PaletteItem is an entry of a command palette.
+type PaletteItem struct {
+	ID       string // identifies the item to the caller
+	Title    string
+	Category string
+	Hint     string // e.g. the keybinding doing the same
+}
+
+// label is the text the query is matched against.
+func (i PaletteItem) label() string {
+	if i.Category == "" {
+		return i.Title
+	}
+	return i.Category + ": " + i.Title
+}
+
+// PaletteModel is a command palette: a query input over a list of items
+// narrowed by fuzzy search.
+type PaletteModel struct {
+	title     string
+	textinput textinput.Model
+	items     []PaletteItem
+	matches   []PaletteItem
+	cursor    int
+	result    *PaletteItem
+	isActive  bool
+	maxRows   int
+}
+
+// NewPalette creates an inactive palette.
+func NewPalette(title string) *PaletteModel {
+	ti := textinput.New()
+	ti.Placeholder = "Type a command..."
+	ti.Prompt = "> "
+	ti.CharLimit = 60
+	ti.Width = 40
+	ti.Cursor.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#8daea5"))
+	ti.Focus()
+	return &PaletteModel{title: title, textinput: ti, maxRows: 10}
+}
+
+// Show opens the palette with items and an empty query.
+func (p *PaletteModel) Show(items []PaletteItem) {
+	p.items = items
+	p.result = nil
+	p.isActive = true
+	p.textinput.SetValue("")
+	p.filter()
+}
+
+// Hide closes the palette.
+func (p *PaletteModel) Hide() {
+	p.isActive = false
+}
+
+// IsActive returns whether the palette is open.
+func (p *PaletteModel) IsActive() bool {
+	return p.isActive
+}
+
+// GetResult returns the chosen item once the palette closed with enter,
+// nil otherwise.
+func (p *PaletteModel) GetResult() *PaletteItem {
+	return p.result
+}
+
+// Matches returns the items matching the query, best match first.
+func (p *PaletteModel) Matches() []PaletteItem {
+	return p.matches
+}
+
+// Update handles palette key presses.
+func (p *PaletteModel) Update(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !p.isActive || !ok {
+		return nil
+	}
+
+	switch keyMsg.String() {
+	case "esc", "ctrl+k":
+		p.isActive = false
+		return nil
+	case "enter":
+		if p.cursor < len(p.matches) {
+			item := p.matches[p.cursor]
+			p.result = &item
+		}
+		p.isActive = false
+		return nil
+	case "up", "ctrl+p":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+		return nil
+	case "down", "ctrl+n", "tab":
+		if p.cursor < len(p.matches)-1 {
+			p.cursor++
+		}
+		return nil
+	}
+
+	var cmd tea.Cmd
+	previous := p.textinput.Value()
+	p.textinput, cmd = p.textinput.Update(msg)
+	if p.textinput.Value() != previous {
+		p.filter()
+	}
+	return cmd
+}
+
+// filter narrows the items down to the query and resets the cursor.
+func (p *PaletteModel) filter() {
+	query := p.textinput.Value()
+	type scored struct {
+		item  PaletteItem
+		score int
+		index int
+	}
+	var found []scored
+	for i, item := range p.items {
+		if score, ok := FuzzyScore(query, item.label()); ok {
+			found = append(found, scored{item, score, i})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+
+	p.matches = make([]PaletteItem, len(found))
+	for i, f := range found {
+		p.matches[i] = f.item
+	}
+	p.cursor = 0
+}
+
+// FuzzyScore reports whether every character of query appears in target in
+// order, ignoring case, and scores the match: consecutive characters and
+// characters at the start of a word score higher. An empty query matches
+// everything with score 0.
+func FuzzyScore(query, target string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(target)
+	score, qi, prev := 0, 0, -2
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if unicode.ToLower(t[ti]) != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 2
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 3
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+var (
+	paletteSelectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#8daea5"))
+	paletteDimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262ff"))
+)
+
+// View renders the palette centered on a blank screen.
+func (p *PaletteModel) View(width, height int) string {
+	if !p.isActive {
+		return ""
+	}
+
+	var lines []string
+	lines = append(lines, p.title, "", p.textinput.View(), "")
+
+	// Keep the cursor inside the visible window of matches
+	start := 0
+	if p.cursor >= p.maxRows {
+		start = p.cursor - p.maxRows + 1
+	}
+	end := min(start+p.maxRows, len(p.matches))
+	if len(p.matches) == 0 {
+		lines = append(lines, paletteDimStyle.Render("  No matching commands"))
+	}
+	for i := start; i < end; i++ {
+		item := p.matches[i]
+		line := item.label()
+		if item.Hint != "" {
+			line += "  " + paletteDimStyle.Render("("+item.Hint+")")
+		}
+		if i == p.cursor {
+			line = paletteSelectedStyle.Render("▸ " + line)
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", paletteDimStyle.Render("↑/↓: select ● Enter: run ● Esc: close"))
+
+	block := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#8daea5")).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, block)
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TopologyNode is a node of the dependency graph shown by the live TUI's
+// topology view
+type TopologyNode struct {
+	ID     string
+	Label  string
+	Kind   string // app, service, infrastructure or external
+	Status string // ok, degraded, down, unknown or disabled
+	Detail string
+	Uses   []string // IDs of the nodes it depends on
+}
+
+type topologyMsg []TopologyNode
+
+// Topology status styles
+var topologyStatusStyles = map[string]lipgloss.Style{
+	"ok":       lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")),
+	"degraded": lipgloss.NewStyle().Foreground(lipgloss.Color("#F1FA8C")),
+	"down":     lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")),
+}
+
+// RenderTopology draws the graph as a tree from the first node, one line
+// per node with its status. A node used by several others is expanded
+// under the first only; nodes nothing leads to follow as further roots.
+func RenderTopology(nodes []TopologyNode) []string {
+	byID := make(map[string]TopologyNode, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+
+	var lines []string
+	expanded := make(map[string]bool)
+	var walk func(id, prefix, branch string)
+	walk = func(id, prefix, branch string) {
+		n, ok := byID[id]
+		if !ok {
+			return
+		}
+		line := prefix + branch + topologyLabel(n)
+		if expanded[id] {
+			lines = append(lines, line+liveDimStyle.Render(" ↑"))
+			return
+		}
+		lines = append(lines, line)
+		expanded[id] = true
+
+		switch branch {
+		case "├─ ":
+			prefix += "│  "
+		case "└─ ":
+			prefix += "   "
+		}
+		for i, child := range n.Uses {
+			if i == len(n.Uses)-1 {
+				walk(child, prefix, "└─ ")
+			} else {
+				walk(child, prefix, "├─ ")
+			}
+		}
+	}
+	for _, n := range nodes {
+		if !expanded[n.ID] {
+			walk(n.ID, "", "")
+		}
+	}
+	return lines
+}
+
+func topologyLabel(n TopologyNode) string {
+	style, ok := topologyStatusStyles[n.Status]
+	if !ok {
+		style = liveDimStyle
+	}
+	label := style.Render("●") + " " + n.Label
+	if n.Kind != "" && n.Kind != "app" {
+		label += liveDimStyle.Render(" " + n.Kind)
+	}
+	if n.Status != "ok" && n.Status != "" {
+		label += " " + style.Render(n.Status)
+	}
+	if n.Detail != "" {
+		label += liveDimStyle.Render(" — " + n.Detail)
+	}
+	return label
+}
+
+// loadTopology asks the application for the graph off the UI goroutine
+func (m *LiveModel) loadTopology() tea.Cmd {
+	if m.config.Topology == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return topologyMsg(m.config.Topology())
+	}
+}
+
+// topologyView returns the lines of the topology view, clipped to height
+func (m *LiveModel) topologyView(height int) []string {
+	lines := m.topologyLines
+	if len(lines) == 0 {
+		lines = []string{liveDimStyle.Render("No services or infrastructure")}
+	}
+	if len(lines) > height {
+		lines = append(lines[:height-1:height-1], liveDimStyle.Render(strings.Repeat("·", 3)))
+	}
+	return lines
+}
@@ -0,0 +1,224 @@
+// Package usage aggregates API calls per principal (API key or user):
+// call counts, error rates, busiest endpoints and when each was last seen,
+// for usage dashboards and exports of API programs.
+package usage
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Principal types.
+const (
+	TypeAPIKey    = "api_key"
+	TypeUser      = "user"
+	TypeAnonymous = "anonymous"
+)
+
+// Bounds of the collector: principals beyond MaxTrackedPrincipals are
+// attributed to OverflowPrincipal and endpoints beyond
+// MaxEndpointsPerPrincipal to OverflowEndpoint.
+const (
+	MaxTrackedPrincipals     = 10000
+	MaxEndpointsPerPrincipal = 200
+	OverflowPrincipal        = "_other"
+	OverflowEndpoint         = "_other"
+	DefaultTopEndpoints      = 5
+)
+
+// Call is one request made by a principal.
+type Call struct {
+	Type      string
+	Principal string
+	Method    string
+	Route     string // registered path, e.g. /users/:id
+	Status    int
+	Latency   time.Duration
+	Time      time.Time
+}
+
+// Endpoint is a principal's usage of one route.
+type Endpoint struct {
+	Method   string    `json:"method"`
+	Route    string    `json:"route"`
+	Calls    int64     `json:"calls"`
+	Errors   int64     `json:"errors"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Usage is the usage of one principal.
+type Usage struct {
+	Principal    string     `json:"principal"`
+	Type         string     `json:"type"`
+	Calls        int64      `json:"calls"`
+	Errors       int64      `json:"errors"`        // 4xx and 5xx responses
+	ServerErrors int64      `json:"server_errors"` // 5xx responses
+	ErrorRate    float64    `json:"error_rate"`    // errors / calls
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	FirstSeen    time.Time  `json:"first_seen"`
+	LastSeen     time.Time  `json:"last_seen"`
+	TopEndpoints []Endpoint `json:"top_endpoints"`
+}
+
+type principal struct {
+	usage     Usage
+	latencyMs float64
+	endpoints map[string]*Endpoint
+}
+
+// Collector accumulates per-principal usage. It is safe for concurrent use.
+type Collector struct {
+	mu         sync.Mutex
+	principals map[string]*principal
+	since      time.Time
+}
+
+var defaultCollector = NewCollector()
+
+// Default returns the process-wide collector fed by the api_usage
+// middleware.
+func Default() *Collector {
+	return defaultCollector
+}
+
+// NewCollector creates an empty collector.
+func NewCollector() *Collector {
+	return &Collector{principals: make(map[string]*principal), since: time.Now()}
+}
+
+// Record adds a call to its principal.
+func (c *Collector) Record(call Call) {
+	if call.Principal == "" {
+		return
+	}
+	if call.Time.IsZero() {
+		call.Time = time.Now()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := call.Type + ":" + call.Principal
+	p, ok := c.principals[key]
+	if !ok {
+		if len(c.principals) >= MaxTrackedPrincipals {
+			key, call.Type, call.Principal = ":"+OverflowPrincipal, "", OverflowPrincipal
+			p = c.principals[key]
+		}
+		if p == nil {
+			p = &principal{
+				usage:     Usage{Principal: call.Principal, Type: call.Type, FirstSeen: call.Time},
+				endpoints: make(map[string]*Endpoint),
+			}
+			c.principals[key] = p
+		}
+	}
+
+	u := &p.usage
+	u.Calls++
+	failed := call.Status >= 400
+	if failed {
+		u.Errors++
+	}
+	if call.Status >= 500 {
+		u.ServerErrors++
+	}
+	p.latencyMs += float64(call.Latency) / float64(time.Millisecond)
+	if call.Time.After(u.LastSeen) {
+		u.LastSeen = call.Time
+	}
+
+	route := call.Method + " " + call.Route
+	ep, ok := p.endpoints[route]
+	if !ok {
+		if len(p.endpoints) >= MaxEndpointsPerPrincipal {
+			route = OverflowEndpoint
+			ep = p.endpoints[route]
+			if ep == nil {
+				ep = &Endpoint{Route: OverflowEndpoint}
+			}
+		} else {
+			ep = &Endpoint{Method: call.Method, Route: call.Route}
+		}
+		p.endpoints[route] = ep
+	}
+	ep.Calls++
+	if failed {
+		ep.Errors++
+	}
+	if call.Time.After(ep.LastSeen) {
+		ep.LastSeen = call.Time
+	}
+}
+
+// snapshot returns the figures of p with its top endpoints, all of them
+// when top is negative. Callers hold c.mu.
+func (p *principal) snapshot(top int) Usage {
+	u := p.usage
+	if u.Calls > 0 {
+		u.ErrorRate = float64(u.Errors) / float64(u.Calls)
+		u.AvgLatencyMs = p.latencyMs / float64(u.Calls)
+	}
+	endpoints := make([]Endpoint, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		endpoints = append(endpoints, *ep)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Calls != endpoints[j].Calls {
+			return endpoints[i].Calls > endpoints[j].Calls
+		}
+		if endpoints[i].Route != endpoints[j].Route {
+			return endpoints[i].Route < endpoints[j].Route
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	if top >= 0 && len(endpoints) > top {
+		endpoints = endpoints[:top]
+	}
+	u.TopEndpoints = endpoints
+	return u
+}
+
+// Snapshot returns the usage of every principal with its top endpoints,
+// busiest first.
+func (c *Collector) Snapshot(top int) []Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Usage, 0, len(c.principals))
+	for _, p := range c.principals {
+		out = append(out, p.snapshot(top))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Principal < out[j].Principal
+	})
+	return out
+}
+
+// Get returns the usage of one principal with all its endpoints.
+func (c *Collector) Get(typ, name string) (Usage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.principals[typ+":"+name]
+	if !ok {
+		return Usage{}, false
+	}
+	return p.snapshot(-1), true
+}
+
+// Since returns when counting started (creation or last Reset).
+func (c *Collector) Since() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.since
+}
+
+// Reset clears all figures.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.principals = make(map[string]*principal)
+	c.since = time.Now()
+}
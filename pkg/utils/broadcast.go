@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,22 +28,46 @@ type StreamClient struct {
 	lastSeen        atomic.Int64 // unix timestamp updated on subscribe / successful broadcast
 }
 
-// EventBroadcaster manages multiple event streams and their clients
+// DefaultReplaySize is the number of recent events kept per stream for
+// clients that reconnect with Last-Event-ID.
+const DefaultReplaySize = 100
+
+// replayTTL is how long the recent events of a stream without clients are
+// kept.
+const replayTTL = 10 * time.Minute
+
+// EventBroadcaster manages multiple event streams and their clients. It
+// keeps the last events of each stream so a client reconnecting with the
+// ID of the last event it received gets the ones it missed.
 type EventBroadcaster struct {
-	streams   map[string][]*StreamClient // streamID -> clients
-	clients   map[string]*StreamClient   // clientID -> client
-	mu        sync.RWMutex
-	nextID    int
-	clientTTL time.Duration
+	streams    map[string][]*StreamClient // streamID -> clients
+	clients    map[string]*StreamClient   // clientID -> client
+	history    map[string][]EventData     // streamID -> recent events, oldest first
+	evicted    map[string]uint64          // streamID -> sequence of the last event dropped from history
+	mu         sync.RWMutex
+	nextID     int
+	seq        uint64 // last event sequence number
+	replaySize int
+	clientTTL  time.Duration
 }
 
-// NewEventBroadcaster creates a new event broadcaster
+// NewEventBroadcaster creates a new event broadcaster keeping
+// DefaultReplaySize events per stream.
 func NewEventBroadcaster() *EventBroadcaster {
+	return NewEventBroadcasterWithReplay(DefaultReplaySize)
+}
+
+// NewEventBroadcasterWithReplay creates a new event broadcaster keeping
+// the last replaySize events per stream; 0 keeps none.
+func NewEventBroadcasterWithReplay(replaySize int) *EventBroadcaster {
 	eb := &EventBroadcaster{
-		streams:   make(map[string][]*StreamClient),
-		clients:   make(map[string]*StreamClient),
-		nextID:    1,
-		clientTTL: 24 * time.Hour, // Clients automatically removed after 24 hours
+		streams:    make(map[string][]*StreamClient),
+		clients:    make(map[string]*StreamClient),
+		history:    make(map[string][]EventData),
+		evicted:    make(map[string]uint64),
+		nextID:     1,
+		replaySize: max(replaySize, 0),
+		clientTTL:  24 * time.Hour, // Clients automatically removed after 24 hours
 	}
 
 	// Start cleanup routine
@@ -104,6 +130,14 @@ func (eb *EventBroadcaster) expireStaleClientsLocked() {
 			eb.unsubscribeNoLock(clientID)
 		}
 	}
+	// Forget the events of streams nobody listened to for a while
+	for streamID, events := range eb.history {
+		last := events[len(events)-1]
+		if len(eb.streams[streamID]) == 0 && now-last.Timestamp > int64(replayTTL.Seconds()) {
+			delete(eb.history, streamID)
+			delete(eb.evicted, streamID)
+		}
+	}
 }
 
 // cleanupRoutine checks client TTLs every 30 minutes and garbage-collects
@@ -120,7 +154,11 @@ func (eb *EventBroadcaster) cleanupRoutine() {
 func (eb *EventBroadcaster) Subscribe(streamID string) *StreamClient {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
+	return eb.subscribeLocked(streamID)
+}
 
+// subscribeLocked implements Subscribe. Callers hold eb.mu.
+func (eb *EventBroadcaster) subscribeLocked(streamID string) *StreamClient {
 	clientID := fmt.Sprintf("client_%d", eb.nextID)
 	eb.nextID++
 
@@ -138,6 +176,65 @@ func (eb *EventBroadcaster) Subscribe(streamID string) *StreamClient {
 	return client
 }
 
+// SubscribeFrom subscribes to a stream like Subscribe and also returns the
+// buffered events published after lastEventID, oldest first, which the
+// client must send before reading its channel. missed reports events after
+// lastEventID that are no longer buffered, or an ID the broadcaster does
+// not know (e.g. from before a restart). An empty lastEventID replays
+// nothing.
+func (eb *EventBroadcaster) SubscribeFrom(streamID, lastEventID string) (client *StreamClient, replay []EventData, missed bool) {
+	// One critical section with Broadcast, so an event is either replayed
+	// or delivered to the channel, never both
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	client = eb.subscribeLocked(streamID)
+	if lastEventID == "" {
+		return client, nil, false
+	}
+	after, ok := EventSeq(lastEventID)
+	if !ok || after > eb.seq {
+		return client, nil, true
+	}
+	for i, event := range eb.history[streamID] {
+		if seq, _ := EventSeq(event.ID); seq > after {
+			replay = append(replay, eb.history[streamID][i:]...)
+			break
+		}
+	}
+	return client, replay, after < eb.evicted[streamID]
+}
+
+// EventSeq returns the sequence number of an event ID ("evt_<seq>").
+func EventSeq(id string) (uint64, bool) {
+	if !strings.HasPrefix(id, "evt_") {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimPrefix(id, "evt_"), 10, 64)
+	return seq, err == nil
+}
+
+// newEventLocked numbers a new event and keeps it in the history of the
+// given streams. Callers hold eb.mu.
+func (eb *EventBroadcaster) newEventLocked(event EventData, streamIDs ...string) EventData {
+	eb.seq++
+	event.ID = fmt.Sprintf("evt_%d", eb.seq)
+	if eb.replaySize == 0 {
+		return event
+	}
+	for _, streamID := range streamIDs {
+		kept := event
+		kept.StreamID = streamID
+		events := append(eb.history[streamID], kept)
+		if len(events) > eb.replaySize {
+			drop := len(events) - eb.replaySize
+			eb.evicted[streamID], _ = EventSeq(events[drop-1].ID)
+			events = append(events[:0:0], events[drop:]...)
+		}
+		eb.history[streamID] = events
+	}
+	return event
+}
+
 // Unsubscribe removes a client from all streams
 func (eb *EventBroadcaster) Unsubscribe(clientID string) {
 	eb.mu.Lock()
@@ -171,18 +268,16 @@ func (eb *EventBroadcaster) Unsubscribe(clientID string) {
 
 // Broadcast sends an event to all clients subscribed to a stream
 func (eb *EventBroadcaster) Broadcast(streamID string, eventType string, message string, data map[string]interface{}) {
-	eb.mu.RLock()
+	eb.mu.Lock()
 	clients := eb.streams[streamID]
-	eb.mu.RUnlock()
-
-	event := EventData{
-		ID:        fmt.Sprintf("evt_%d", time.Now().UnixNano()),
+	event := eb.newEventLocked(EventData{
 		Type:      eventType,
 		Message:   message,
 		Data:      data,
 		Timestamp: time.Now().Unix(),
 		StreamID:  streamID,
-	}
+	}, streamID)
+	eb.mu.Unlock()
 
 	var toUnsubscribe []string
 
@@ -213,17 +308,20 @@ func (eb *EventBroadcaster) Broadcast(streamID string, eventType string, message
 
 // BroadcastToAll sends an event to all clients across all streams
 func (eb *EventBroadcaster) BroadcastToAll(eventType string, message string, data map[string]interface{}) {
-	eb.mu.RLock()
-	clients := eb.streams
-	eb.mu.RUnlock()
-
-	event := EventData{
-		ID:        fmt.Sprintf("evt_%d", time.Now().UnixNano()),
+	eb.mu.Lock()
+	clients := make(map[string][]*StreamClient, len(eb.streams))
+	streamIDs := make([]string, 0, len(eb.streams))
+	for streamID, streamClients := range eb.streams {
+		clients[streamID] = streamClients
+		streamIDs = append(streamIDs, streamID)
+	}
+	event := eb.newEventLocked(EventData{
 		Type:      eventType,
 		Message:   message,
 		Data:      data,
 		Timestamp: time.Now().Unix(),
-	}
+	}, streamIDs...)
+	eb.mu.Unlock()
 
 	var toUnsubscribe []string
 
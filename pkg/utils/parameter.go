@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -23,11 +25,28 @@ type ParsedFlags struct {
 	Port      string // -port flag value
 	Verbose   bool   // -verbose flag value
 	Env       string // -env flag value
+	URL       string // -url flag value
+	Timeout   int    // -timeout flag value (seconds)
+	JSON      bool   // -json flag value
+	NoTest    bool   // -no-test flag value
+	NoConfig  bool   // -no-config flag value
+	DryRun    bool   // -dry-run flag value
+	Cert      string // -cert flag value
+	Key       string // -key flag value
 	// Add new flags here as needed
+
+	Args []string // arguments after the flags
 }
 
 // ParseFlags parses command line flags based on provided definitions and returns structured flag values
 func ParseFlags(flagDefinitions []FlagDefinition) (*ParsedFlags, error) {
+	return ParseArgs(flag.CommandLine, flagDefinitions, os.Args[1:])
+}
+
+// ParseArgs parses args into set based on provided definitions, e.g. the
+// arguments of a subcommand with its own flag set, and returns structured
+// flag values
+func ParseArgs(set *flag.FlagSet, flagDefinitions []FlagDefinition, args []string) (*ParsedFlags, error) {
 	parsed := &ParsedFlags{}
 
 	// Create a map to hold flag pointers
@@ -37,18 +56,21 @@ func ParseFlags(flagDefinitions []FlagDefinition) (*ParsedFlags, error) {
 	for _, def := range flagDefinitions {
 		switch v := def.DefaultValue.(type) {
 		case string:
-			flagPtrs[def.Name] = flag.String(def.Name, v, def.Description)
+			flagPtrs[def.Name] = set.String(def.Name, v, def.Description)
 		case int:
-			flagPtrs[def.Name] = flag.Int(def.Name, v, def.Description)
+			flagPtrs[def.Name] = set.Int(def.Name, v, def.Description)
 		case bool:
-			flagPtrs[def.Name] = flag.Bool(def.Name, v, def.Description)
+			flagPtrs[def.Name] = set.Bool(def.Name, v, def.Description)
 		default:
 			return nil, fmt.Errorf("unsupported flag type for %s: %T", def.Name, v)
 		}
 	}
 
 	// Parse the flags
-	flag.Parse()
+	if err := set.Parse(args); err != nil {
+		return nil, err
+	}
+	parsed.Args = set.Args()
 
 	// Extract values and validate
 	for _, def := range flagDefinitions {
@@ -63,15 +85,32 @@ func ParseFlags(flagDefinitions []FlagDefinition) (*ParsedFlags, error) {
 				parsed.Port = *ptr
 			} else if def.Name == "env" {
 				parsed.Env = *ptr
+			} else if def.Name == "url" {
+				parsed.URL = *ptr
+			} else if def.Name == "cert" {
+				parsed.Cert = *ptr
+			} else if def.Name == "key" {
+				parsed.Key = *ptr
 			}
 			// Add new string flag assignments here
 		case *int:
 			value = *ptr
+			if def.Name == "timeout" {
+				parsed.Timeout = *ptr
+			}
 			// Add new int flag assignments here
 		case *bool:
 			value = *ptr
 			if def.Name == "verbose" {
 				parsed.Verbose = *ptr
+			} else if def.Name == "json" {
+				parsed.JSON = *ptr
+			} else if def.Name == "no-test" {
+				parsed.NoTest = *ptr
+			} else if def.Name == "no-config" {
+				parsed.NoConfig = *ptr
+			} else if def.Name == "dry-run" {
+				parsed.DryRun = *ptr
 			}
 			// Add new bool flag assignments here
 		}
@@ -101,11 +140,8 @@ func LoadConfigFromURL(configURL string) error {
 		return fmt.Errorf("failed to fetch config from URL %s: HTTP %d %s", configURL, resp.StatusCode, resp.Status)
 	}
 
-	// Check content type
-	contentType := resp.Header.Get("Content-Type")
-	if contentType != "" && !contains(contentType, "yaml") && !contains(contentType, "yml") {
-		fmt.Fprintf(os.Stderr, "Warning: Content-Type '%s' does not indicate YAML format\n", contentType)
-	}
+	// The format follows the Content-Type, else the URL's extension
+	viper.SetConfigType(configURLFormat(configURL, resp.Header.Get("Content-Type")))
 
 	// Read the response body and set it as config
 	if err := viper.ReadConfig(resp.Body); err != nil {
@@ -115,25 +151,31 @@ func LoadConfigFromURL(configURL string) error {
 	return nil
 }
 
-// contains checks if a string contains a substring (case-insensitive)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) &&
-		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
-			containsAny(s, substr)))
-}
-
-// containsAny checks if string contains substring anywhere
-func containsAny(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+// configURLFormat returns the viper config type of a config served at
+// configURL with contentType: JSON or TOML when either says so, else YAML.
+func configURLFormat(configURL, contentType string) string {
+	contentType = strings.ToLower(contentType)
+	path := strings.ToLower(configURL)
+	if u, err := url.Parse(configURL); err == nil {
+		path = strings.ToLower(u.Path)
 	}
-	return false
+	switch {
+	case strings.Contains(contentType, "json"):
+		return "json"
+	case strings.Contains(contentType, "toml"):
+		return "toml"
+	case strings.Contains(contentType, "yaml"), strings.Contains(contentType, "yml"):
+		return "yaml"
+	case strings.HasSuffix(path, ".json"):
+		return "json"
+	case strings.HasSuffix(path, ".toml"):
+		return "toml"
+	}
+	return "yaml"
 }
 
 // PrintUsage prints the usage information for command line flags based on provided definitions
-func PrintUsage(flagDefinitions []FlagDefinition, appName string) {
+func PrintUsage(flagDefinitions []FlagDefinition, appName string, examples ...string) {
 	fmt.Printf("Usage of %s:\n", appName)
 	for _, def := range flagDefinitions {
 		switch def.DefaultValue.(type) {
@@ -151,10 +193,12 @@ func PrintUsage(flagDefinitions []FlagDefinition, appName string) {
 		fmt.Println()
 	}
 	fmt.Println()
+	if len(examples) == 0 {
+		return
+	}
 	fmt.Println("Examples:")
-	fmt.Printf("  ./%-40s # Load config from local config.yaml\n", appName)
-	fmt.Printf("  ./%s -c http://example.com/config.yaml\n", appName)
-	fmt.Printf("  ./%s -port 9090 -env production\n", appName)
-	fmt.Printf("  ./%s -c https://config.example.com/app.yaml -verbose\n", appName)
+	for _, example := range examples {
+		fmt.Printf("  %s\n", example)
+	}
 	fmt.Println()
 }
@@ -8,11 +8,11 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
+	"stackyrd/pkg/sysinfo"
+
 	"github.com/shirou/gopsutil/v3/process"
 )
 
@@ -32,26 +32,31 @@ var (
 	routineValue          atomic.Int32
 )
 
-// GetSystemStats gathers CPU and Memory usage.
+// GetSystemStats gathers CPU and memory usage, plus disk, network and load
+// figures, through sysinfo. Keys are the same on every platform; the
+// "available" map flags the groups that could not be read, whose values
+// are zero. It fails only when neither CPU nor memory can be read.
 func GetSystemStats() (map[string]interface{}, error) {
-	v, err := mem.VirtualMemory()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get memory info: %w", err)
-	}
-
-	c, err := cpu.Percent(100*time.Millisecond, false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get cpu stats: %w", err)
+	s := sysinfo.Default().Collect()
+	if !s.Available[sysinfo.MetricCPU] && !s.Available[sysinfo.MetricMemory] {
+		return nil, fmt.Errorf("failed to get system stats: %s", s.Errors[sysinfo.MetricMemory])
 	}
 
 	stats := map[string]interface{}{
-		"cpu_percent":         c[0],
-		"memory_total_mb":     v.Total / 1024 / 1024,
-		"memory_used_mb":      v.Used / 1024 / 1024,
-		"memory_used_percent": v.UsedPercent,
-		"go_routines":         runtime.NumGoroutine(),
-		"os":                  runtime.GOOS,
-		"arch":                runtime.GOARCH,
+		"cpu_percent":         s.CPU.Percent,
+		"cpu_cores":           s.CPU.Cores,
+		"memory_total_mb":     s.Memory.TotalMB,
+		"memory_used_mb":      s.Memory.UsedMB,
+		"memory_used_percent": s.Memory.UsedPercent,
+		"disk_path":           s.Disk.Path,
+		"disk_used_percent":   s.Disk.UsedPercent,
+		"network_bytes_sent":  s.Network.BytesSent,
+		"network_bytes_recv":  s.Network.BytesRecv,
+		"load1":               s.Load.Load1,
+		"go_routines":         s.Goroutines,
+		"os":                  s.OS,
+		"arch":                s.Arch,
+		"available":           s.Available,
 	}
 
 	return stats, nil
@@ -84,36 +89,18 @@ func GetProcessInfo() (map[string]interface{}, error) {
 	return info, nil
 }
 
-// GetDiskUsage gathers disk usage info for root path.
+// GetDiskUsage gathers disk usage info for the system volume (/ or the
+// Windows system drive), falling back to the first readable partition.
 func GetDiskUsage() (map[string]interface{}, error) {
-	parts, err := disk.Partitions(false)
-	if err != nil {
-		return nil, err
-	}
-
-	// Just check the first partition or root usually
-	var usage *disk.UsageStat
-	if runtime.GOOS == "windows" {
-		usage, err = disk.Usage("C:\\")
-	} else {
-		usage, err = disk.Usage("/")
-	}
-
-	if err != nil {
-		// Fallback to first partition if C:\ or / fails?
-		if len(parts) > 0 {
-			usage, err = disk.Usage(parts[0].Mountpoint)
-		}
-	}
-
+	usage, err := sysinfo.Default().Disk()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get disk usage: %w", err)
 	}
 
 	return map[string]interface{}{
 		"path":         usage.Path,
-		"total_gb":     usage.Total / 1024 / 1024 / 1024,
-		"used_gb":      usage.Used / 1024 / 1024 / 1024,
+		"total_gb":     usage.TotalGB,
+		"used_gb":      usage.UsedGB,
 		"used_percent": usage.UsedPercent,
 	}, nil
 }
@@ -247,3 +234,37 @@ func TriggerShutdown() {
 		// Channel is full or closed, ignore
 	}
 }
+
+// RestartChan signals the main thread to shut down gracefully and restart
+// the process (used by dev mode on config changes)
+var RestartChan = make(chan struct{}, 1)
+
+// TriggerRestart requests a graceful restart; repeated requests coalesce
+func TriggerRestart() {
+	select {
+	case RestartChan <- struct{}{}:
+	default:
+	}
+}
+
+// RestartProcess replaces the current process with a fresh instance of the
+// same executable and arguments. Where exec is unsupported (Windows) a child
+// process is started instead and the current process exits.
+func RestartProcess() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to resolve executable: %w", err)
+	}
+	if err := syscall.Exec(executable, os.Args, os.Environ()); err == nil {
+		return nil
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to restart: %w", err)
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package vault is a small HashiCorp Vault HTTP client: reading secrets
+// (KV v1 and v2) and dynamic credentials, renewing and revoking their
+// leases, and looking up and renewing its own token.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSecretNotFound is returned for paths or keys Vault has no value for.
+var ErrSecretNotFound = errors.New("vault secret not found")
+
+// Client talks to one Vault server with a token.
+type Client struct {
+	address   string
+	namespace string
+	http      *http.Client
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New returns a client for the Vault server at address (e.g.
+// https://vault:8200). namespace is only sent when set (Vault Enterprise).
+func New(address, token, namespace string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Client{
+		address:   strings.TrimRight(address, "/"),
+		namespace: namespace,
+		token:     token,
+		http:      &http.Client{Timeout: timeout},
+	}
+}
+
+// Address is the Vault server the client talks to.
+func (c *Client) Address() string {
+	return c.address
+}
+
+// SetToken replaces the token, e.g. after a new login.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// response is the envelope of Vault API responses.
+type response struct {
+	Data      map[string]interface{} `json:"data"`
+	Auth      *Auth                  `json:"auth"`
+	Errors    []string               `json:"errors"`
+	LeaseID   string                 `json:"lease_id"`
+	Lease     int                    `json:"lease_duration"`
+	Renewable bool                   `json:"renewable"`
+}
+
+// Auth is the auth block of a login or renewal.
+type Auth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"` // seconds
+	Renewable     bool   `json:"renewable"`
+}
+
+// Secret is a secret read from Vault; Data is already unwrapped for KV v2.
+type Secret struct {
+	Data      map[string]interface{}
+	LeaseID   string
+	LeaseTTL  time.Duration
+	Renewable bool
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.address+"/v1/"+strings.TrimLeft(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	req.Header.Set("X-Vault-Token", c.token)
+	c.mu.RUnlock()
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var out response
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("vault %s %s: invalid response: %w", method, path, err)
+		}
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("vault %s %s: status %d: %s", method, path, resp.StatusCode, strings.Join(out.Errors, "; "))
+	}
+	return &out, nil
+}
+
+// Read reads the secret at path (the API path without /v1/, e.g.
+// secret/data/app for KV v2).
+func (c *Client) Read(ctx context.Context, path string) (*Secret, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data := resp.Data
+	// KV v2 nests the values under data.data next to data.metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	}
+	return &Secret{Data: data, LeaseID: resp.LeaseID, LeaseTTL: time.Duration(resp.Lease) * time.Second, Renewable: resp.Renewable}, nil
+}
+
+// Value reads key of the secret at path as a string.
+func (c *Client) Value(ctx context.Context, path, key string) (string, error) {
+	secret, err := c.Read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := secret.Data[key]
+	if !ok || v == nil {
+		return "", fmt.Errorf("%w: %s#%s", ErrSecretNotFound, path, key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// TokenInfo describes the client's token.
+type TokenInfo struct {
+	TTL       time.Duration
+	Renewable bool
+	ExpireAt  time.Time // zero for tokens that never expire
+}
+
+// LookupSelf describes the client's own token.
+func (c *Client) LookupSelf(ctx context.Context) (TokenInfo, error) {
+	resp, err := c.do(ctx, http.MethodGet, "auth/token/lookup-self", nil)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+	var info TokenInfo
+	if ttl, ok := resp.Data["ttl"].(float64); ok {
+		info.TTL = time.Duration(ttl) * time.Second
+	}
+	info.Renewable, _ = resp.Data["renewable"].(bool)
+	if info.TTL > 0 {
+		info.ExpireAt = time.Now().Add(info.TTL)
+	}
+	return info, nil
+}
+
+// RenewSelf extends the client's token by increment (0 lets Vault pick)
+// and returns its new TTL.
+func (c *Client) RenewSelf(ctx context.Context, increment time.Duration) (TokenInfo, error) {
+	body := map[string]interface{}{}
+	if increment > 0 {
+		body["increment"] = fmt.Sprintf("%ds", int(increment.Seconds()))
+	}
+	resp, err := c.do(ctx, http.MethodPost, "auth/token/renew-self", body)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+	if resp.Auth == nil {
+		return TokenInfo{}, errors.New("vault renew-self: no auth in response")
+	}
+	info := TokenInfo{TTL: time.Duration(resp.Auth.LeaseDuration) * time.Second, Renewable: resp.Auth.Renewable}
+	if info.TTL > 0 {
+		info.ExpireAt = time.Now().Add(info.TTL)
+	}
+	return info, nil
+}
+
+// LeaseInfo describes a renewed lease.
+type LeaseInfo struct {
+	TTL       time.Duration
+	Renewable bool
+}
+
+// RenewLease extends the lease of a dynamic secret by increment (0 lets
+// Vault pick). Vault caps the TTL at the role's max_ttl.
+func (c *Client) RenewLease(ctx context.Context, leaseID string, increment time.Duration) (LeaseInfo, error) {
+	body := map[string]interface{}{"lease_id": leaseID}
+	if increment > 0 {
+		body["increment"] = int(increment.Seconds())
+	}
+	resp, err := c.do(ctx, http.MethodPut, "sys/leases/renew", body)
+	if err != nil {
+		return LeaseInfo{}, err
+	}
+	return LeaseInfo{TTL: time.Duration(resp.Lease) * time.Second, Renewable: resp.Renewable}, nil
+}
+
+// RevokeLease revokes the lease of a dynamic secret, e.g. dropping the
+// database user it created.
+func (c *Client) RevokeLease(ctx context.Context, leaseID string) error {
+	_, err := c.do(ctx, http.MethodPut, "sys/leases/revoke", map[string]interface{}{"lease_id": leaseID})
+	return err
+}
@@ -0,0 +1,609 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"stackyrd/pkg/logger"
+)
+
+// Delivery states.
+const (
+	StatusPending   = "pending"
+	StatusRetrying  = "retrying"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Headers of every delivery. The signature is the hex HMAC-SHA256 of the
+// body with the destination secret, as checked by VerifySignature.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-ID"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderAttempt   = "X-Webhook-Attempt"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	maxResponseBytes = 1024
+)
+
+var (
+	// ErrQueueFull is returned by Publish when deliveries could not be
+	// queued; they are recorded as failed.
+	ErrQueueFull = errors.New("webhook: delivery queue is full")
+	// ErrDestinationNotFound is returned for an unknown destination name.
+	ErrDestinationNotFound = errors.New("webhook: destination not found")
+	// ErrDeliveryNotFound is returned for a delivery no longer in the log.
+	ErrDeliveryNotFound = errors.New("webhook: delivery not found")
+	// ErrNotFailed is returned when redelivering a delivery that has not
+	// failed.
+	ErrNotFailed = errors.New("webhook: delivery has not failed")
+)
+
+// Destination is a named endpoint events are delivered to.
+type Destination struct {
+	Name    string
+	URL     string
+	Secret  string            // signs deliveries when set
+	Events  []string          // event type patterns (path.Match, e.g. "orders.*"); all events when empty
+	Headers map[string]string // added to every request
+	Timeout time.Duration     // per attempt; 10s when 0
+}
+
+// matches reports whether the destination takes events of eventType.
+func (d Destination) matches(eventType string) bool {
+	if len(d.Events) == 0 {
+		return true
+	}
+	for _, pattern := range d.Events {
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures a Dispatcher.
+type Options struct {
+	Workers     int           // concurrent deliveries; 4 when 0
+	QueueSize   int           // deliveries waiting for a worker; 1000 when 0
+	LogSize     int           // finished deliveries kept; 500 when 0
+	MaxAttempts int           // per delivery; 6 when 0
+	Backoff     time.Duration // before the first retry, doubled per attempt; 5s when 0
+	MaxBackoff  time.Duration // 10m when 0
+	Client      *http.Client  // timeouts come from the destinations
+	Logger      *logger.Logger
+}
+
+// Delivery is the record of one event sent to one destination.
+type Delivery struct {
+	ID          string     `json:"id"`
+	EventID     string     `json:"event_id"`
+	Event       string     `json:"event"`
+	Destination string     `json:"destination"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	StatusCode  int        `json:"status_code,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Response    string     `json:"response,omitempty"` // start of the last response body
+	DurationMs  float64    `json:"duration_ms,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+
+	payload []byte
+}
+
+func (d *Delivery) finished() bool {
+	return d.Status == StatusDelivered || d.Status == StatusFailed
+}
+
+// DeliveryFilter selects deliveries of the log; empty fields match all.
+type DeliveryFilter struct {
+	Destination string
+	Status      string
+	Event       string
+	Limit       int // 50 when 0
+}
+
+// DestinationStats is a destination with its delivery counters.
+type DestinationStats struct {
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Events      []string   `json:"events,omitempty"`
+	Signed      bool       `json:"signed"`
+	Delivered   int64      `json:"delivered"`
+	Failed      int64      `json:"failed"`
+	Retries     int64      `json:"retries"`
+	InFlight    int        `json:"in_flight"` // pending or retrying
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// destination is a Destination with its counters, guarded by the
+// dispatcher's mutex.
+type destination struct {
+	Destination
+	stats DestinationStats
+}
+
+// envelope is the JSON body of a delivery.
+type envelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher delivers events services publish to the destinations taking
+// them, retrying failures with exponential backoff, and keeps a log of
+// recent deliveries. Deliveries are kept in memory; those not delivered
+// when the process stops are lost.
+//
+//	id, err := hooks.Publish("orders.paid", order)
+type Dispatcher struct {
+	opts  Options
+	dests map[string]*destination
+	names []string
+
+	mu       sync.Mutex
+	log      []*Delivery // oldest first
+	byID     map[string]*Delivery
+	seq      uint64
+	timers   map[string]*time.Timer // scheduled retries by delivery ID
+	stopped  bool
+	queue    chan *Delivery
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// New creates a dispatcher for dests. Destination names must be unique.
+func New(dests []Destination, opts Options) (*Dispatcher, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.LogSize <= 0 {
+		opts.LogSize = 500
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 6
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 5 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Minute
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{}
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.NewQuiet(false, nil)
+	}
+
+	d := &Dispatcher{
+		opts:   opts,
+		dests:  make(map[string]*destination),
+		byID:   make(map[string]*Delivery),
+		timers: make(map[string]*time.Timer),
+		queue:  make(chan *Delivery, opts.QueueSize),
+		stop:   make(chan struct{}),
+	}
+	for _, dest := range dests {
+		if dest.Name == "" || dest.URL == "" {
+			return nil, fmt.Errorf("webhook destination needs a name and url")
+		}
+		if _, dup := d.dests[dest.Name]; dup {
+			return nil, fmt.Errorf("webhook destination %q is defined twice", dest.Name)
+		}
+		for _, pattern := range dest.Events {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("webhook destination %q: invalid event pattern %q", dest.Name, pattern)
+			}
+		}
+		if dest.Timeout <= 0 {
+			dest.Timeout = defaultTimeout
+		}
+		d.dests[dest.Name] = &destination{Destination: dest}
+		d.names = append(d.names, dest.Name)
+	}
+	sort.Strings(d.names)
+	return d, nil
+}
+
+// Name returns the display name of the component
+func (d *Dispatcher) Name() string {
+	return "Webhooks"
+}
+
+// Start starts the delivery workers.
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+}
+
+// Close stops the workers after their current attempt and cancels the
+// scheduled retries.
+func (d *Dispatcher) Close() error {
+	d.stopOnce.Do(func() {
+		d.mu.Lock()
+		d.stopped = true
+		for id, timer := range d.timers {
+			timer.Stop()
+			delete(d.timers, id)
+		}
+		d.mu.Unlock()
+		close(d.stop)
+		d.wg.Wait()
+	})
+	return nil
+}
+
+// Publish delivers an event of eventType with data as its payload to every
+// destination taking it and returns the event ID. Delivery is
+// asynchronous; follow it through Deliveries.
+func (d *Dispatcher) Publish(eventType string, data interface{}) (string, error) {
+	var targets []*destination
+	for _, name := range d.names {
+		if dest := d.dests[name]; dest.matches(eventType) {
+			targets = append(targets, dest)
+		}
+	}
+	return d.publish(eventType, data, targets)
+}
+
+// PublishTo delivers an event to the destination name whatever its event
+// patterns, e.g. to test it.
+func (d *Dispatcher) PublishTo(name, eventType string, data interface{}) (string, error) {
+	dest, ok := d.dests[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrDestinationNotFound, name)
+	}
+	return d.publish(eventType, data, []*destination{dest})
+}
+
+func (d *Dispatcher) publish(eventType string, data interface{}, targets []*destination) (string, error) {
+	if eventType == "" {
+		return "", fmt.Errorf("webhook: event type is required")
+	}
+	now := time.Now()
+	id := "evt_" + randomHex()
+	body, err := json.Marshal(envelope{ID: id, Type: eventType, Timestamp: now, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("webhook: %w", err)
+	}
+
+	var queueErr error
+	for _, dest := range targets {
+		d.mu.Lock()
+		d.seq++
+		delivery := &Delivery{
+			ID:          fmt.Sprintf("dlv_%d", d.seq),
+			EventID:     id,
+			Event:       eventType,
+			Destination: dest.Name,
+			Status:      StatusPending,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			payload:     body,
+		}
+		d.record(delivery)
+		d.mu.Unlock()
+		if err := d.enqueue(delivery); err != nil {
+			queueErr = err
+		}
+	}
+	return id, queueErr
+}
+
+// record adds delivery to the log, dropping the oldest finished ones
+// beyond the log size. Callers hold d.mu.
+func (d *Dispatcher) record(delivery *Delivery) {
+	d.log = append(d.log, delivery)
+	d.byID[delivery.ID] = delivery
+	excess := len(d.log) - d.opts.LogSize
+	if excess <= 0 {
+		return
+	}
+	kept := d.log[:0]
+	for _, entry := range d.log {
+		if excess > 0 && entry.finished() {
+			delete(d.byID, entry.ID)
+			excess--
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for i := len(kept); i < len(d.log); i++ {
+		d.log[i] = nil
+	}
+	d.log = kept
+}
+
+// enqueue hands delivery to the workers, failing it when the queue is
+// full.
+func (d *Dispatcher) enqueue(delivery *Delivery) error {
+	select {
+	case d.queue <- delivery:
+		return nil
+	default:
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.finish(delivery, StatusFailed, ErrQueueFull.Error())
+	return ErrQueueFull
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case delivery := <-d.queue:
+			d.attempt(delivery)
+		}
+	}
+}
+
+// attempt sends delivery once and records the outcome, scheduling a retry
+// of retryable failures while attempts remain.
+func (d *Dispatcher) attempt(delivery *Delivery) {
+	dest := d.dests[delivery.Destination]
+	d.mu.Lock()
+	delivery.Attempts++
+	delivery.NextAttempt = nil
+	attempt := delivery.Attempts
+	d.mu.Unlock()
+
+	start := time.Now()
+	code, response, retryAfter, err := d.send(dest, delivery, attempt)
+	elapsed := time.Since(start)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery.StatusCode = code
+	delivery.Response = response
+	delivery.DurationMs = float64(elapsed.Microseconds()) / 1000
+	if err == nil {
+		d.finish(delivery, StatusDelivered, "")
+		return
+	}
+	retryable := code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	if !retryable || attempt >= d.opts.MaxAttempts || d.stopped {
+		d.finish(delivery, StatusFailed, err.Error())
+		d.opts.Logger.Warn("Webhook delivery failed", "destination", dest.Name, "event", delivery.Event, "attempts", attempt, "error", err.Error())
+		return
+	}
+
+	wait := d.backoff(attempt)
+	if retryAfter > wait {
+		wait = min(retryAfter, d.opts.MaxBackoff)
+	}
+	next := time.Now().Add(wait)
+	delivery.Status = StatusRetrying
+	delivery.Error = err.Error()
+	delivery.UpdatedAt = time.Now()
+	delivery.NextAttempt = &next
+	dest.stats.Retries++
+	d.timers[delivery.ID] = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		delete(d.timers, delivery.ID)
+		stopped := d.stopped
+		d.mu.Unlock()
+		if !stopped {
+			_ = d.enqueue(delivery)
+		}
+	})
+}
+
+// backoff returns the wait after the attempt-th failed attempt.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.Backoff
+	for i := 1; i < attempt && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+// finish records the final state of delivery. Callers hold d.mu.
+func (d *Dispatcher) finish(delivery *Delivery, status, errMsg string) {
+	now := time.Now()
+	delivery.Status = status
+	delivery.Error = errMsg
+	delivery.UpdatedAt = now
+	delivery.NextAttempt = nil
+	stats := &d.dests[delivery.Destination].stats
+	if status == StatusDelivered {
+		stats.Delivered++
+		stats.LastSuccess = &now
+		return
+	}
+	stats.Failed++
+	stats.LastFailure = &now
+	stats.LastError = errMsg
+}
+
+// send POSTs the delivery and returns the response status and the start of
+// its body, with the Retry-After the destination asked for.
+func (d *Dispatcher) send(dest *destination, delivery *Delivery, attempt int) (int, string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dest.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest.URL, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "stackyrd-Webhook/1.0")
+	for key, value := range dest.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderAttempt, strconv.Itoa(attempt))
+	if dest.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(delivery.payload, dest.Secret))
+	}
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, string(body), 0, nil
+	}
+	var retryAfter time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		retryAfter = time.Duration(secs) * time.Second
+	}
+	return resp.StatusCode, string(body), retryAfter, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
+
+// Redeliver queues a failed delivery again with a fresh set of attempts.
+func (d *Dispatcher) Redeliver(id string) (Delivery, error) {
+	d.mu.Lock()
+	delivery, ok := d.byID[id]
+	if !ok {
+		d.mu.Unlock()
+		return Delivery{}, fmt.Errorf("%w: %s", ErrDeliveryNotFound, id)
+	}
+	if delivery.Status != StatusFailed {
+		d.mu.Unlock()
+		return Delivery{}, fmt.Errorf("%w: %s is %s", ErrNotFailed, id, delivery.Status)
+	}
+	delivery.Status = StatusPending
+	delivery.Attempts = 0
+	delivery.UpdatedAt = time.Now()
+	d.mu.Unlock()
+
+	err := d.enqueue(delivery)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return *delivery, err
+}
+
+// Deliveries returns the logged deliveries matching filter, newest first.
+func (d *Dispatcher) Deliveries(filter DeliveryFilter) []Delivery {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var list []Delivery
+	for i := len(d.log) - 1; i >= 0 && len(list) < filter.Limit; i-- {
+		entry := d.log[i]
+		if (filter.Destination != "" && entry.Destination != filter.Destination) ||
+			(filter.Status != "" && entry.Status != filter.Status) ||
+			(filter.Event != "" && entry.Event != filter.Event) {
+			continue
+		}
+		list = append(list, *entry)
+	}
+	return list
+}
+
+// Delivery returns a logged delivery.
+func (d *Dispatcher) Delivery(id string) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery, ok := d.byID[id]
+	if !ok {
+		return Delivery{}, fmt.Errorf("%w: %s", ErrDeliveryNotFound, id)
+	}
+	return *delivery, nil
+}
+
+// Destinations returns the destinations with their counters, by name.
+func (d *Dispatcher) Destinations() []DestinationStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	inFlight := make(map[string]int)
+	for _, entry := range d.log {
+		if !entry.finished() {
+			inFlight[entry.Destination]++
+		}
+	}
+	list := make([]DestinationStats, 0, len(d.names))
+	for _, name := range d.names {
+		dest := d.dests[name]
+		stats := dest.stats
+		stats.Name = dest.Name
+		stats.URL = dest.URL
+		stats.Events = dest.Events
+		stats.Signed = dest.Secret != ""
+		stats.InFlight = inFlight[name]
+		list = append(list, stats)
+	}
+	return list
+}
+
+// GetStatus returns delivery totals over every destination.
+func (d *Dispatcher) GetStatus() map[string]interface{} {
+	var delivered, failed, retries int64
+	var inFlight int
+	for _, dest := range d.Destinations() {
+		delivered += dest.Delivered
+		failed += dest.Failed
+		retries += dest.Retries
+		inFlight += dest.InFlight
+	}
+	return map[string]interface{}{
+		"destinations": len(d.names),
+		"queued":       len(d.queue),
+		"in_flight":    inFlight,
+		"delivered":    delivered,
+		"failed":       failed,
+		"retries":      retries,
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of payload with secret, the value of
+// the X-Webhook-Signature header.
+func Sign(payload []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func randomHex() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
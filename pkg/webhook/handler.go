@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -180,17 +178,12 @@ func (wm *WebhookManager) doRequest(ctx context.Context, payload []byte) (*Webho
 
 // SignPayload signs a payload with HMAC-SHA256
 func (wm *WebhookManager) SignPayload(payload []byte) string {
-	h := hmac.New(sha256.New, []byte(wm.config.Secret))
-	h.Write(payload)
-	return hex.EncodeToString(h.Sum(nil))
+	return Sign(payload, wm.config.Secret)
 }
 
 // VerifySignature verifies a webhook signature
 func VerifySignature(payload []byte, signature, secret string) bool {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
-	expected := hex.EncodeToString(h.Sum(nil))
-	return hmac.Equal([]byte(signature), []byte(expected))
+	return hmac.Equal([]byte(signature), []byte(Sign(payload, secret)))
 }
 
 // WebhookHandler handles incoming webhook requests
@@ -1,224 +1,488 @@
+// Package websocket provides a WebSocket hub services can mount on a route:
+// clients join rooms, the hub broadcasts to everyone, a room or a single
+// client, and keeps connections alive with ping/pong. Every hub registers
+// itself by name so the monitoring API can report connection counts.
 package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"stackyrd/pkg/logger"
+
+	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/labstack/echo/v4"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// Message is the JSON envelope exchanged with clients. Clients send "join"
+// and "leave" with a room and "ping"; anything else goes to OnMessage.
+type Message struct {
+	Type    string      `json:"type"`
+	Room    string      `json:"room,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// Options tunes a hub. Zero values use the defaults noted per field.
+type Options struct {
+	PingInterval   time.Duration // server pings this often (30s)
+	PongWait       time.Duration // connection dropped without a pong for this long (60s)
+	WriteWait      time.Duration // time allowed to write a message (10s)
+	SendBuffer     int           // queued messages per client before it is dropped as too slow (256)
+	MaxMessageSize int64         // largest message accepted from a client (64 KiB)
+	// CheckOrigin validates the Origin header of the upgrade request; nil
+	// accepts every origin
+	CheckOrigin func(r *http.Request) bool
+	// OnMessage receives client messages other than join, leave and ping
+	OnMessage func(c *Client, msg Message)
+}
+
+func (o Options) withDefaults() Options {
+	if o.PingInterval <= 0 {
+		o.PingInterval = 30 * time.Second
+	}
+	if o.PongWait <= 0 {
+		o.PongWait = 60 * time.Second
+	}
+	if o.WriteWait <= 0 {
+		o.WriteWait = 10 * time.Second
+	}
+	if o.SendBuffer <= 0 {
+		o.SendBuffer = 256
+	}
+	if o.MaxMessageSize <= 0 {
+		o.MaxMessageSize = 64 << 10
+	}
+	if o.CheckOrigin == nil {
+		o.CheckOrigin = func(*http.Request) bool { return true }
+	}
+	return o
 }
 
-// Client represents a WebSocket client
+// Client is a connection to a hub.
 type Client struct {
-	ID   string
-	Conn *websocket.Conn
-	Send chan []byte
-	Hub  *Hub
+	ID          string
+	ConnectedAt time.Time
+
+	hub   *Hub
+	conn  *websocket.Conn
+	send  chan []byte
+	done  chan struct{}
+	once  sync.Once
+	rooms map[string]struct{} // guarded by hub.mu
+}
+
+// Send queues msg for the client. A client whose queue is full is
+// disconnected; Send then returns false.
+func (c *Client) Send(msg []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		c.hub.sent.Add(1)
+		return true
+	default:
+		c.hub.dropped.Add(1)
+		c.hub.logWarn("WebSocket client too slow, disconnecting", "hub", c.hub.name, "client", c.ID)
+		c.Close()
+		return false
+	}
+}
+
+// SendMessage queues msg encoded as JSON.
+func (c *Client) SendMessage(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if !c.Send(data) {
+		return fmt.Errorf("client %s disconnected", c.ID)
+	}
+	return nil
+}
+
+// Join adds the client to room.
+func (c *Client) Join(room string) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	if _, ok := c.hub.clients[c.ID]; !ok {
+		return
+	}
+	c.rooms[room] = struct{}{}
+	if c.hub.rooms[room] == nil {
+		c.hub.rooms[room] = make(map[string]*Client)
+	}
+	c.hub.rooms[room][c.ID] = c
+}
+
+// Leave removes the client from room.
+func (c *Client) Leave(room string) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.leaveLocked(c, room)
+}
+
+// Rooms returns the rooms the client is in.
+func (c *Client) Rooms() []string {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Close disconnects the client.
+func (c *Client) Close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.hub.unregister(c)
+	})
 }
 
-// Hub manages WebSocket connections
+// Hub tracks the clients connected through its handler.
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
+	name     string
+	opts     Options
+	logger   *logger.Logger
+	upgrader websocket.Upgrader
+
+	mu      sync.RWMutex
+	clients map[string]*Client
+	rooms   map[string]map[string]*Client
+
+	nextID        atomic.Int64
+	connects      atomic.Int64
+	sent, dropped atomic.Int64
 }
 
-// Message represents a WebSocket message
-type Message struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
-	Room    string      `json:"room,omitempty"`
+var (
+	hubsMu sync.RWMutex
+	hubs   = make(map[string]*Hub)
+)
+
+// NewHub creates a hub and registers it under name for monitoring,
+// replacing an earlier hub of the same name.
+func NewHub(name string, opts Options, l *logger.Logger) *Hub {
+	opts = opts.withDefaults()
+	h := &Hub{
+		name:     name,
+		opts:     opts,
+		logger:   l,
+		upgrader: websocket.Upgrader{CheckOrigin: opts.CheckOrigin},
+		clients:  make(map[string]*Client),
+		rooms:    make(map[string]map[string]*Client),
+	}
+	hubsMu.Lock()
+	hubs[name] = h
+	hubsMu.Unlock()
+	return h
 }
 
-// NewHub creates a new WebSocket hub
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+// Hubs returns every registered hub, sorted by name.
+func Hubs() []*Hub {
+	hubsMu.RLock()
+	defer hubsMu.RUnlock()
+	out := make([]*Hub, 0, len(hubs))
+	for _, h := range hubs {
+		out = append(out, h)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
 }
 
-// Run starts the hub
-func (h *Hub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			log.Printf("Client connected: %s", client.ID)
-
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.Send)
-			}
-			h.mu.Unlock()
-			log.Printf("Client disconnected: %s", client.ID)
-
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.clients, client)
+// Name returns the name the hub is registered under.
+func (h *Hub) Name() string {
+	return h.name
+}
+
+// Handler upgrades requests to WebSocket connections. The client ID comes
+// from ?client_id= (generated otherwise) and ?room= joins comma-separated
+// rooms right away.
+func (h *Hub) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// The upgrader has already written the error response
+			h.logWarn("WebSocket upgrade failed", "hub", h.name, "error", err.Error())
+			return
+		}
+
+		id := c.Query("client_id")
+		if id == "" {
+			id = fmt.Sprintf("ws_%d", h.nextID.Add(1))
+		}
+		client := h.register(id, conn)
+		if rooms := c.Query("room"); rooms != "" {
+			for _, room := range strings.Split(rooms, ",") {
+				if room = strings.TrimSpace(room); room != "" {
+					client.Join(room)
 				}
 			}
-			h.mu.RUnlock()
 		}
+
+		go client.writePump()
+		client.readPump()
 	}
 }
 
-// Broadcast sends a message to all clients
-func (h *Hub) Broadcast(message []byte) {
-	h.broadcast <- message
+func (h *Hub) register(id string, conn *websocket.Conn) *Client {
+	client := &Client{
+		ID:          id,
+		ConnectedAt: time.Now(),
+		hub:         h,
+		conn:        conn,
+		send:        make(chan []byte, h.opts.SendBuffer),
+		done:        make(chan struct{}),
+		rooms:       make(map[string]struct{}),
+	}
+
+	h.mu.Lock()
+	previous := h.clients[id]
+	h.clients[id] = client
+	h.mu.Unlock()
+	h.connects.Add(1)
+
+	// A reconnecting client replaces its stale connection
+	if previous != nil {
+		previous.Close()
+	}
+	h.logDebug("WebSocket client connected", "hub", h.name, "client", id)
+	return client
 }
 
-// SendToClient sends a message to a specific client
-func (h *Hub) SendToClient(clientID string, message []byte) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	if h.clients[c.ID] == c {
+		delete(h.clients, c.ID)
+	}
+	for room := range c.rooms {
+		h.leaveLocked(c, room)
+	}
+	h.mu.Unlock()
+	c.conn.Close()
+	h.logDebug("WebSocket client disconnected", "hub", h.name, "client", c.ID)
+}
 
-	for client := range h.clients {
-		if client.ID == clientID {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(h.clients, client)
-			}
-			break
+func (h *Hub) leaveLocked(c *Client, room string) {
+	delete(c.rooms, room)
+	if members, ok := h.rooms[room]; ok && members[c.ID] == c {
+		delete(members, c.ID)
+		if len(members) == 0 {
+			delete(h.rooms, room)
 		}
 	}
 }
 
-// GetConnectedClients returns the number of connected clients
+// Broadcast sends msg to every client.
+func (h *Hub) Broadcast(msg []byte) {
+	h.mu.RLock()
+	targets := make([]*Client, 0, len(h.clients))
+	for _, c := range h.clients {
+		targets = append(targets, c)
+	}
+	h.mu.RUnlock()
+	for _, c := range targets {
+		c.Send(msg)
+	}
+}
+
+// BroadcastToRoom sends msg to the clients in room.
+func (h *Hub) BroadcastToRoom(room string, msg []byte) {
+	h.mu.RLock()
+	targets := make([]*Client, 0, len(h.rooms[room]))
+	for _, c := range h.rooms[room] {
+		targets = append(targets, c)
+	}
+	h.mu.RUnlock()
+	for _, c := range targets {
+		c.Send(msg)
+	}
+}
+
+// SendToClient sends msg to the client with id and reports whether it was
+// queued.
+func (h *Hub) SendToClient(id string, msg []byte) bool {
+	h.mu.RLock()
+	c, ok := h.clients[id]
+	h.mu.RUnlock()
+	return ok && c.Send(msg)
+}
+
+// BroadcastMessage sends a JSON message to room, or to every client when
+// room is empty.
+func (h *Hub) BroadcastMessage(messageType, room string, payload interface{}) error {
+	data, err := json.Marshal(Message{Type: messageType, Room: room, Payload: payload})
+	if err != nil {
+		return err
+	}
+	if room == "" {
+		h.Broadcast(data)
+	} else {
+		h.BroadcastToRoom(room, data)
+	}
+	return nil
+}
+
+// GetConnectedClients returns the number of connected clients.
 func (h *Hub) GetConnectedClients() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	return len(h.clients)
 }
 
-// HandleWebSocket handles WebSocket connections
-func HandleWebSocket(hub *Hub) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
-		if err != nil {
-			log.Printf("WebSocket upgrade error: %v", err)
-			return err
-		}
-
-		clientID := c.QueryParam("client_id")
-		if clientID == "" {
-			clientID = c.RealIP()
-		}
+// Close disconnects every client and removes the hub from monitoring.
+func (h *Hub) Close() {
+	hubsMu.Lock()
+	if hubs[h.name] == h {
+		delete(hubs, h.name)
+	}
+	hubsMu.Unlock()
 
-		client := &Client{
-			ID:   clientID,
-			Conn: conn,
-			Send: make(chan []byte, 256),
-			Hub:  hub,
-		}
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+	for _, c := range clients {
+		c.Close()
+	}
+}
 
-		hub.register <- client
+// HubStats is a snapshot of a hub for monitoring.
+type HubStats struct {
+	Name            string         `json:"name"`
+	Clients         int            `json:"clients"`
+	Rooms           map[string]int `json:"rooms"`
+	TotalConnects   int64          `json:"total_connects"`
+	MessagesSent    int64          `json:"messages_sent"`
+	MessagesDropped int64          `json:"messages_dropped"`
+}
 
-		go client.writePump()
-		go client.readPump()
+// Stats returns the current connection counts.
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make(map[string]int, len(h.rooms))
+	for room, members := range h.rooms {
+		rooms[room] = len(members)
+	}
+	return HubStats{
+		Name:            h.name,
+		Clients:         len(h.clients),
+		Rooms:           rooms,
+		TotalConnects:   h.connects.Load(),
+		MessagesSent:    h.sent.Load(),
+		MessagesDropped: h.dropped.Load(),
+	}
+}
 
-		return nil
+// GetHubStats returns hub statistics
+func GetHubStats(hub *Hub) map[string]interface{} {
+	return map[string]interface{}{
+		"connected_clients": hub.GetConnectedClients(),
 	}
 }
 
-// readPump reads messages from the WebSocket connection
+// readPump reads client messages until the connection fails or closes.
 func (c *Client) readPump() {
-	defer func() {
-		c.Hub.unregister <- c
-		c.Conn.Close()
-	}()
+	defer c.Close()
+
+	opts := c.hub.opts
+	c.conn.SetReadLimit(opts.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(opts.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(opts.PongWait))
+	})
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket read error: %v", err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseAbnormalClosure) {
+				c.hub.logWarn("WebSocket read failed", "hub", c.hub.name, "client", c.ID, "error", err.Error())
 			}
-			break
+			return
 		}
 
 		var msg Message
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("JSON unmarshal error: %v", err)
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.SendMessage(Message{Type: "error", Payload: "invalid message"})
 			continue
 		}
-
 		c.handleMessage(msg)
 	}
 }
 
-// writePump writes messages to the WebSocket connection
+// writePump writes queued messages and pings until the client closes.
 func (c *Client) writePump() {
-	defer c.Conn.Close()
+	opts := c.hub.opts
+	ticker := time.NewTicker(opts.PingInterval)
+	defer ticker.Stop()
 
-	for message := range c.Send {
-		if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			log.Printf("WebSocket write error: %v", err)
-			break
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(opts.WriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(opts.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(opts.WriteWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
 		}
 	}
 }
 
-// handleMessage handles incoming messages
+// handleMessage applies the built-in client messages and passes the rest
+// to OnMessage.
 func (c *Client) handleMessage(msg Message) {
 	switch msg.Type {
 	case "ping":
-		response := Message{
-			Type:    "pong",
-			Payload: "pong",
+		c.SendMessage(Message{Type: "pong"})
+	case "join":
+		if msg.Room != "" {
+			c.Join(msg.Room)
+			c.SendMessage(Message{Type: "joined", Room: msg.Room})
+		}
+	case "leave":
+		if msg.Room != "" {
+			c.Leave(msg.Room)
+			c.SendMessage(Message{Type: "left", Room: msg.Room})
 		}
-		data, _ := json.Marshal(response)
-		c.Send <- data
-
-	case "broadcast":
-		data, _ := json.Marshal(msg)
-		c.Hub.Broadcast(data)
-
 	default:
-		log.Printf("Unknown message type: %s", msg.Type)
+		if c.hub.opts.OnMessage != nil {
+			c.hub.opts.OnMessage(c, msg)
+		}
 	}
 }
 
-// BroadcastMessage broadcasts a message to all connected clients
-func BroadcastMessage(hub *Hub, messageType string, payload interface{}) {
-	msg := Message{
-		Type:    messageType,
-		Payload: payload,
+func (h *Hub) logWarn(msg string, keyvals ...interface{}) {
+	if h.logger != nil {
+		h.logger.Warn(msg, keyvals...)
 	}
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("JSON marshal error: %v", err)
-		return
-	}
-	hub.Broadcast(data)
 }
 
-// GetHubStats returns hub statistics
-func GetHubStats(hub *Hub) map[string]interface{} {
-	return map[string]interface{}{
-		"connected_clients": hub.GetConnectedClients(),
+func (h *Hub) logDebug(msg string, keyvals ...interface{}) {
+	if h.logger != nil {
+		h.logger.Debug(msg, keyvals...)
 	}
 }
@@ -0,0 +1,160 @@
+package accounts_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/accounts"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func options() accounts.Options {
+	return accounts.Options{
+		InviteTTL:         time.Hour,
+		MinPasswordLength: 8,
+		ValidRole:         func(role string) bool { return role == "viewer" || role == "admin" },
+	}
+}
+
+func TestInvitation(t *testing.T) {
+	d, err := accounts.New(nil, options())
+	require.NoError(t, err)
+
+	account, invite, err := d.Create(accounts.NewAccount{Username: "ada", Email: "ada@example.com", Role: "viewer"}, "root")
+	require.NoError(t, err)
+	require.NotNil(t, invite)
+	assert.True(t, account.InvitePending)
+	assert.Equal(t, "root", account.CreatedBy)
+
+	_, _, err = d.Create(accounts.NewAccount{Username: "ada", Email: "ada@example.com", Role: "viewer"}, "root")
+	assert.ErrorIs(t, err, accounts.ErrExists)
+	_, _, err = d.Create(accounts.NewAccount{Username: "bob", Email: "bob@example.com", Role: "owner"}, "root")
+	assert.ErrorIs(t, err, accounts.ErrInvalid)
+
+	// Invited accounts have no password until they use the link
+	_, err = d.Login("ada", "")
+	assert.ErrorIs(t, err, accounts.ErrInvalidCredentials)
+	_, err = d.AcceptInvite(invite.Token, "short")
+	assert.ErrorIs(t, err, accounts.ErrWeakPassword)
+	account, err = d.AcceptInvite(invite.Token, "correct horse")
+	require.NoError(t, err)
+	assert.False(t, account.InvitePending)
+
+	// The link works once
+	_, err = d.AcceptInvite(invite.Token, "another password")
+	assert.ErrorIs(t, err, accounts.ErrInvalidToken)
+
+	account, err = d.Login("ada", "correct horse")
+	require.NoError(t, err)
+	assert.NotNil(t, account.LastLoginAt)
+	_, err = d.Session("ada", account.TokenVersion)
+	require.NoError(t, err)
+
+	// A new invitation replaces the password and revokes the sessions
+	invite, err = d.Invite("ada")
+	require.NoError(t, err)
+	_, err = d.Login("ada", "correct horse")
+	assert.ErrorIs(t, err, accounts.ErrInvalidCredentials)
+	_, err = d.Session("ada", account.TokenVersion)
+	assert.ErrorIs(t, err, accounts.ErrRevoked)
+	_, err = d.AcceptInvite(invite.Token, "battery staple")
+	require.NoError(t, err)
+}
+
+func TestExpiredInvitation(t *testing.T) {
+	opts := options()
+	opts.InviteTTL = time.Nanosecond
+	d, err := accounts.New(nil, opts)
+	require.NoError(t, err)
+	_, invite, err := d.Create(accounts.NewAccount{Username: "ada", Email: "ada@example.com", Role: "viewer"}, "root")
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = d.AcceptInvite(invite.Token, "correct horse")
+	assert.ErrorIs(t, err, accounts.ErrInvalidToken)
+}
+
+func TestPasswordResetAndDisable(t *testing.T) {
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	d, err := accounts.New(store, options())
+	require.NoError(t, err)
+
+	// An initial password must be changed on first login
+	_, invite, err := d.Create(accounts.NewAccount{Username: "bob", Email: "bob@example.com", Role: "admin", Password: "initial pw"}, "root")
+	require.NoError(t, err)
+	assert.Nil(t, invite)
+	_, err = d.Login("bob", "initial pw")
+	assert.ErrorIs(t, err, accounts.ErrResetRequired)
+	_, err = d.ChangePassword("bob", "wrong pw", "my own password")
+	assert.ErrorIs(t, err, accounts.ErrInvalidCredentials)
+	_, err = d.ChangePassword("bob", "initial pw", "initial pw")
+	assert.ErrorIs(t, err, accounts.ErrWeakPassword)
+	_, err = d.ChangePassword("bob", "initial pw", "my own password")
+	require.NoError(t, err)
+	_, err = d.Login("bob", "my own password")
+	require.NoError(t, err)
+
+	account, err := d.SetDisabled("bob", true, "root")
+	require.NoError(t, err)
+	assert.Equal(t, "root", account.DisabledBy)
+	_, err = d.Login("bob", "my own password")
+	assert.ErrorIs(t, err, accounts.ErrDisabled)
+
+	d.Touch("bob", "10.0.0.1")
+
+	// Accounts survive a restart
+	reloaded, err := accounts.New(store, options())
+	require.NoError(t, err)
+	account, ok := reloaded.Get("bob")
+	require.True(t, ok)
+	assert.True(t, account.Disabled)
+	assert.Equal(t, "10.0.0.1", account.LastActivityIP)
+	_, err = reloaded.SetDisabled("bob", false, "root")
+	require.NoError(t, err)
+	_, err = reloaded.Login("bob", "my own password")
+	assert.NoError(t, err)
+}
+
+func TestLoginDoesNotHoldTheDirectory(t *testing.T) {
+	d, err := accounts.New(nil, options())
+	require.NoError(t, err)
+	_, _, err = d.Create(accounts.NewAccount{Username: "ada", Email: "ada@example.com", Role: "viewer", Password: "initial pw"}, "root")
+	require.NoError(t, err)
+	_, err = d.ChangePassword("ada", "initial pw", "correct horse")
+	require.NoError(t, err)
+
+	// Logins hash for tens of milliseconds; requests touching accounts
+	// meanwhile must not wait for them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 4; i++ {
+			d.Login("ada", "wrong password")
+		}
+	}()
+	time.Sleep(5 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		d.Touch("ada", "10.0.0.1")
+		d.List()
+	}
+	elapsed := time.Since(start)
+	select {
+	case <-done:
+		t.Skip("the logins ended before the directory was used")
+	default:
+	}
+	assert.Less(t, elapsed, 20*time.Millisecond)
+	<-done
+
+	account, err := d.Login("ada", "correct horse")
+	require.NoError(t, err)
+	assert.NotNil(t, account.LastLoginAt)
+	assert.Equal(t, "10.0.0.1", account.LastActivityIP, "the login keeps activity recorded meanwhile")
+}
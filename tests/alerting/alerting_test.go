@@ -0,0 +1,225 @@
+package alerting_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/alerting"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRecorder collects alerts posted to a fake webhook endpoint.
+type webhookRecorder struct {
+	mu     sync.Mutex
+	alerts []alerting.Alert
+}
+
+func (w *webhookRecorder) handler(rw http.ResponseWriter, r *http.Request) {
+	var alert alerting.Alert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err == nil {
+		w.mu.Lock()
+		w.alerts = append(w.alerts, alert)
+		w.mu.Unlock()
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (w *webhookRecorder) statuses() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []string
+	for _, a := range w.alerts {
+		out = append(out, a.Rule+":"+a.Status)
+	}
+	return out
+}
+
+func TestEngine_FiresAndResolvesViaWebhook(t *testing.T) {
+	rec := &webhookRecorder{}
+	hook := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer hook.Close()
+
+	cpu := 95.0
+	connected := false
+	sources := alerting.Sources{
+		CPUPercent: func() (float64, error) { return cpu, nil },
+		Components: func() map[string]map[string]interface{} {
+			return map[string]map[string]interface{}{"redis": {"connected": connected}}
+		},
+	}
+	cfg := config.AlertingConfig{
+		Channels: []config.AlertChannelConfig{{Name: "ops", Type: "webhook", URL: hook.URL}},
+		Rules: []config.AlertRuleConfig{
+			{Name: "high-cpu", Type: alerting.RuleCPU, Threshold: 90},
+			{Name: "redis-down", Type: alerting.RuleInfraDisconnected, Target: "redis", Severity: "critical"},
+		},
+	}
+	engine, err := alerting.NewEngine(cfg, sources, nil, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, err)
+
+	engine.Evaluate(context.Background())
+	assert.ElementsMatch(t, []string{"high-cpu:firing", "redis-down:firing"}, rec.statuses())
+	assert.Len(t, engine.Firing(), 2)
+
+	// Still firing: no duplicate notifications
+	engine.Evaluate(context.Background())
+	assert.Len(t, rec.statuses(), 2)
+
+	cpu, connected = 10, true
+	engine.Evaluate(context.Background())
+	assert.ElementsMatch(t, []string{"high-cpu:firing", "redis-down:firing", "high-cpu:resolved", "redis-down:resolved"}, rec.statuses())
+	assert.Empty(t, engine.Firing())
+	assert.Len(t, engine.History(0), 4)
+}
+
+func TestEngine_ForDelaysFiring(t *testing.T) {
+	sources := alerting.Sources{CPUPercent: func() (float64, error) { return 99, nil }}
+	cfg := config.AlertingConfig{
+		Rules: []config.AlertRuleConfig{{Name: "cpu", Type: alerting.RuleCPU, Threshold: 90, For: 3600}},
+	}
+	engine, err := alerting.NewEngine(cfg, sources, nil, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, err)
+
+	engine.Evaluate(context.Background())
+	assert.Empty(t, engine.Firing())
+	assert.Equal(t, "pending", engine.RuleStatus("cpu")["state"])
+}
+
+func TestEngine_ErrorRateAndExternal(t *testing.T) {
+	logs := logger.NewLogBroadcaster(100)
+	l := logger.New(false, logs)
+	l.Info("ok")
+	l.Error("boom", io.ErrUnexpectedEOF)
+	l.Error("boom again", io.ErrUnexpectedEOF)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	sources := alerting.Sources{
+		Logs:     logs,
+		External: []config.ExternalService{{Name: "payments", URL: down.URL}},
+	}
+	cfg := config.AlertingConfig{
+		Rules: []config.AlertRuleConfig{
+			{Name: "errors", Type: alerting.RuleErrorRate, Threshold: 50, Window: 60},
+			{Name: "payments", Type: alerting.RuleExternalDown, Target: "payments"},
+		},
+	}
+	engine, err := alerting.NewEngine(cfg, sources, nil, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, err)
+
+	engine.Evaluate(context.Background())
+	firing := engine.Firing()
+	require.Len(t, firing, 2)
+	assert.Equal(t, "errors", firing[0].Rule)
+	assert.Contains(t, firing[1].Message, "payments")
+}
+
+func TestEngine_ClockSkew(t *testing.T) {
+	skew := -5 * time.Second
+	sources := alerting.Sources{ClockSkew: func() (time.Duration, error) { return skew, nil }}
+	cfg := config.AlertingConfig{
+		Rules: []config.AlertRuleConfig{{Name: "clock", Type: alerting.RuleClockSkew, Threshold: 2}},
+	}
+	engine, err := alerting.NewEngine(cfg, sources, nil, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, err)
+
+	engine.Evaluate(context.Background())
+	require.Len(t, engine.Firing(), 1)
+	assert.Contains(t, engine.Firing()[0].Message, "-5s")
+
+	skew = 500 * time.Millisecond
+	engine.Evaluate(context.Background())
+	assert.Empty(t, engine.Firing())
+}
+
+func TestEngine_ConfigDrift(t *testing.T) {
+	file := "abc"
+	sources := alerting.Sources{ConfigDrift: func() (string, string, error) { return "abc", file, nil }}
+	cfg := config.AlertingConfig{
+		Rules: []config.AlertRuleConfig{{Name: "drift", Type: alerting.RuleConfigDrift}},
+	}
+	engine, err := alerting.NewEngine(cfg, sources, nil, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, err)
+
+	engine.Evaluate(context.Background())
+	assert.Empty(t, engine.Firing())
+
+	file = "def"
+	engine.Evaluate(context.Background())
+	require.Len(t, engine.Firing(), 1)
+	assert.Contains(t, engine.Firing()[0].Message, "next restart")
+}
+
+func TestEngine_RuleCRUDPersists(t *testing.T) {
+	l := logger.NewQuiet(false, io.Discard)
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, l)
+	require.NoError(t, err)
+	defer store.Close()
+
+	cfg := config.AlertingConfig{
+		Rules: []config.AlertRuleConfig{{Name: "seed", Type: alerting.RuleInfraDisconnected}},
+	}
+	engine, err := alerting.NewEngine(cfg, alerting.Sources{}, store, l)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, engine.PutRule(alerting.Rule{Name: "bad", Type: "nope"}), alerting.ErrInvalidRule)
+	assert.ErrorIs(t, engine.PutRule(alerting.Rule{Name: "cpu", Type: alerting.RuleCPU}), alerting.ErrInvalidRule)
+	assert.ErrorIs(t, engine.PutRule(alerting.Rule{Name: "x", Type: alerting.RuleExternalDown, Channels: []string{"missing"}}), alerting.ErrInvalidRule)
+
+	require.NoError(t, engine.PutRule(alerting.Rule{Name: "cpu", Type: alerting.RuleCPU, Threshold: 80}))
+	require.NoError(t, engine.DeleteRule("seed"))
+	assert.ErrorIs(t, engine.DeleteRule("seed"), alerting.ErrRuleNotFound)
+
+	// A new engine loads the stored rules instead of re-seeding from config
+	reloaded, err := alerting.NewEngine(cfg, alerting.Sources{}, store, l)
+	require.NoError(t, err)
+	rules := reloaded.Rules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "cpu", rules[0].Name)
+	assert.Equal(t, "warning", rules[0].Severity)
+}
+
+// mailRecorder collects the emails of "mail" channels.
+type mailRecorder struct {
+	to      []string
+	subject string
+}
+
+func (m *mailRecorder) Send(_ context.Context, to []string, subject, _ string) error {
+	m.to, m.subject = to, subject
+	return nil
+}
+
+func TestMailNotifier(t *testing.T) {
+	ch := config.AlertChannelConfig{Name: "ops", Type: "mail", To: []string{"ops@example.com"}}
+	var mailer alerting.Mailer
+	n, err := alerting.NewNotifier(ch, func() alerting.Mailer { return mailer })
+	require.NoError(t, err)
+
+	alert := alerting.Alert{Rule: "infra-down", Severity: "critical", Status: alerting.StatusFiring, Message: "redis disconnected"}
+	assert.Error(t, n.Notify(context.Background(), alert), "mail is not configured yet")
+
+	rec := &mailRecorder{}
+	mailer = rec
+	require.NoError(t, n.Notify(context.Background(), alert))
+	assert.Equal(t, []string{"ops@example.com"}, rec.to)
+	assert.Equal(t, "[FIRING] critical: infra-down", rec.subject)
+
+	_, err = alerting.NewNotifier(config.AlertChannelConfig{Name: "ops", Type: "mail"}, nil)
+	assert.Error(t, err, "needs recipients")
+}
@@ -0,0 +1,93 @@
+package audit_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/audit"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func record(t *testing.T, log *audit.Log) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, log.Record(audit.Entry{
+			Time:    base.Add(time.Duration(i) * time.Minute),
+			Actor:   []string{"alice", "bob"}[i%2],
+			Action:  "PUT /config/section/*path",
+			Path:    fmt.Sprintf("/api/config/section/s%d", i),
+			Status:  200,
+			Success: i != 3,
+		}))
+	}
+}
+
+func assertSearch(t *testing.T, log *audit.Log) {
+	entries, total, err := log.Search(audit.Query{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/api/config/section/s4", entries[0].Path, "newest first")
+
+	entries, total, _ = log.Search(audit.Query{Actor: "bob"})
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "/api/config/section/s3", entries[0].Path)
+
+	failed := false
+	_, total, _ = log.Search(audit.Query{Success: &failed})
+	assert.Equal(t, 1, total)
+
+	entries, total, _ = log.Search(audit.Query{Action: "S2"})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "alice", entries[0].Actor)
+
+	_, total, _ = log.Search(audit.Query{From: time.Date(2026, 1, 1, 12, 3, 0, 0, time.UTC)})
+	assert.Equal(t, 2, total)
+}
+
+func TestFileLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.NewFileLog(path, 0)
+	require.NoError(t, err)
+	record(t, log)
+	assertSearch(t, log)
+	require.NoError(t, log.Close())
+
+	// Entries survive reopening
+	log, err = audit.NewFileLog(path, 0)
+	require.NoError(t, err)
+	defer log.Close()
+	_, total, err := log.Search(audit.Query{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+}
+
+func TestFileLog_Rotation(t *testing.T) {
+	log, err := audit.NewFileLog(filepath.Join(t.TempDir(), "audit.jsonl"), 300)
+	require.NoError(t, err)
+	defer log.Close()
+	record(t, log)
+
+	// One rotated backup is kept, so the oldest entries are gone
+	entries, total, err := log.Search(audit.Query{})
+	require.NoError(t, err)
+	assert.Less(t, total, 5)
+	assert.Greater(t, total, 0)
+	assert.Equal(t, "/api/config/section/s4", entries[0].Path)
+}
+
+func TestStoreLog(t *testing.T) {
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, nil)
+	require.NoError(t, err)
+	defer store.Close()
+
+	log := audit.NewStoreLog(store)
+	record(t, log)
+	assertSearch(t, log)
+}
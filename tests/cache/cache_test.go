@@ -0,0 +1,110 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(2)
+	require.NoError(t, lru.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, lru.Set(ctx, "b", []byte("2"), 0))
+	_, found, _ := lru.Get(ctx, "a") // a is now the most recently used
+	require.True(t, found)
+	require.NoError(t, lru.Set(ctx, "c", []byte("3"), 0))
+
+	_, found, _ = lru.Get(ctx, "b")
+	assert.False(t, found, "least recently used entry evicted")
+	value, found, _ := lru.Get(ctx, "a")
+	assert.True(t, found)
+	assert.Equal(t, "1", string(value))
+	assert.Equal(t, 2, lru.Len())
+
+	require.NoError(t, lru.Set(ctx, "short", []byte("x"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, found, _ = lru.Get(ctx, "short")
+	assert.False(t, found, "expired")
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	type item struct {
+		Name  string
+		Price int
+	}
+	store := cache.NewStore[item](cache.NewLRU(10), time.Minute)
+
+	_, found, err := store.Get(ctx, "apple")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, store.Set(ctx, "apple", item{Name: "apple", Price: 3}))
+	got, found, err := store.Get(ctx, "apple")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, item{Name: "apple", Price: 3}, got)
+
+	require.NoError(t, store.Delete(ctx, "apple"))
+	_, found, _ = store.Get(ctx, "apple")
+	assert.False(t, found)
+
+	stats := store.GetStats()
+	assert.Equal(t, int64(1), stats["hits"])
+	assert.Equal(t, int64(2), stats["misses"])
+}
+
+func TestStore_GetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewStore[string](cache.NewLRU(10), time.Minute)
+
+	var loads atomic.Int64
+	release := make(chan struct{})
+	load := func(context.Context) (string, error) {
+		loads.Add(1)
+		<-release
+		return "loaded", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := store.GetOrLoad(ctx, "key", load)
+			assert.NoError(t, err)
+			results[i] = value
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), loads.Load(), "concurrent misses share one load")
+	for _, value := range results {
+		assert.Equal(t, "loaded", value)
+	}
+
+	value, err := store.GetOrLoad(ctx, "key", func(context.Context) (string, error) {
+		return "", errors.New("not called")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "loaded", value, "served from the cache")
+
+	_, err = store.GetOrLoad(ctx, "broken", func(context.Context) (string, error) {
+		return "", errors.New("upstream down")
+	})
+	assert.EqualError(t, err, "upstream down")
+	_, found, _ := store.Get(ctx, "broken")
+	assert.False(t, found, "failed loads are not cached")
+}
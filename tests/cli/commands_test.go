@@ -0,0 +1,293 @@
+package cli_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// binary is the application built once for the tests of this package.
+var binary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "stackyrd-cli")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	_, file, _, _ := runtime.Caller(0)
+	binary = filepath.Join(dir, "stackyrd")
+	build := exec.Command("go", "build", "-o", binary, "./cmd/app")
+	build.Dir = filepath.Join(filepath.Dir(file), "..", "..")
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building the application: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// run runs the application in dir, where config.yaml is config when not
+// empty, and returns its output and exit code.
+func run(t *testing.T, config string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	dir := t.TempDir()
+	if config != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0o600))
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = dir
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), errOut.String(), exitErr.ExitCode()
+	}
+	require.NoError(t, err)
+	return out.String(), errOut.String(), 0
+}
+
+const appConfig = `app:
+  name: "inventory"
+  version: "2.3.4"
+  env: "staging"
+  startup_delay: 0
+services:
+  users_service: false
+`
+
+func TestCommands_Dispatch(t *testing.T) {
+	stdout, _, code := run(t, "", "help")
+	assert.Equal(t, 0, code)
+	for _, name := range []string{"serve", "validate-config", "migrate", "routes", "version", "healthcheck", "new-service", "config"} {
+		assert.Contains(t, stdout, "  "+name+" ")
+	}
+
+	_, stderr, code := run(t, "", "deploy")
+	assert.Equal(t, 2, code, "unknown commands exit 2")
+	assert.Contains(t, stderr, `Unknown command "deploy"`)
+	assert.Contains(t, stderr, "Commands:")
+
+	stdout, _, code = run(t, "", "version", "-bogus")
+	assert.Equal(t, 2, code, "unknown flags exit 2")
+	assert.Contains(t, stdout, "Error parsing flags")
+
+	_, _, code = run(t, "", "routes", "-h")
+	assert.Equal(t, 0, code)
+}
+
+func TestCommands_Version(t *testing.T) {
+	stdout, _, code := run(t, appConfig, "version")
+	require.Equal(t, 0, code)
+	lines := strings.Split(stdout, "\n")
+	assert.Equal(t, "inventory 2.3.4", lines[0])
+	assert.Contains(t, stdout, "env         staging")
+	assert.Contains(t, stdout, "go_version  "+runtime.Version())
+
+	stdout, _, code = run(t, appConfig, "version", "-json")
+	require.Equal(t, 0, code)
+	var version map[string]string
+	require.NoError(t, json.Unmarshal([]byte(stdout), &version), stdout)
+	assert.Equal(t, "inventory", version["name"])
+	assert.Equal(t, "2.3.4", version["version"])
+	assert.Equal(t, runtime.Version(), version["go_version"])
+
+	// Without a config file the defaults are printed
+	stdout, _, code = run(t, "", "version")
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, " 1.0.0\n")
+}
+
+func TestCommands_Routes(t *testing.T) {
+	stdout, _, code := run(t, appConfig, "routes")
+	require.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Regexp(t, `^METHOD\s+PATH\s+HANDLER$`, lines[0])
+	assert.Regexp(t, `(?m)^GET\s+/health\s+\S+`, stdout)
+
+	stdout, _, code = run(t, appConfig, "routes", "-json")
+	require.Equal(t, 0, code)
+	var routes []struct {
+		Method  string `json:"method"`
+		Path    string `json:"path"`
+		Handler string `json:"handler"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &routes), stdout)
+	require.NotEmpty(t, routes)
+	for i := 1; i < len(routes); i++ {
+		assert.LessOrEqual(t, routes[i-1].Path, routes[i].Path, "sorted by path")
+	}
+}
+
+func TestCommands_Healthcheck(t *testing.T) {
+	// The handler runs on the server's goroutines
+	var mu sync.Mutex
+	status, httpStatus := "ok", http.StatusOK
+	respond := func(s string, code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status, httpStatus = s, code
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		s, code := status, httpStatus
+		mu.Unlock()
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"success":true,"data":{"status":%q}}`, s)
+	}))
+	defer srv.Close()
+
+	stdout, _, code := run(t, "", "healthcheck", "-url", srv.URL+"/health")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "healthy\n", stdout)
+
+	respond("degraded", http.StatusOK)
+	_, stderr, code := run(t, "", "healthcheck", "-url", srv.URL+"/health")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `status "degraded"`)
+
+	respond("ok", http.StatusServiceUnavailable)
+	_, _, code = run(t, "", "healthcheck", "-url", srv.URL+"/health")
+	assert.Equal(t, 1, code)
+
+	// The port of server.port on localhost, when no URL is given
+	respond("ok", http.StatusOK)
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	_, _, code = run(t, "server:\n  port: \""+port+"\"\n", "healthcheck")
+	assert.Equal(t, 0, code)
+
+	srv.Close()
+	_, stderr, code = run(t, "", "healthcheck", "-url", srv.URL+"/health", "-timeout", "1")
+	assert.Equal(t, 1, code, "an unreachable server is unhealthy")
+	assert.Contains(t, stderr, "unhealthy")
+
+	_, _, code = run(t, "", "healthcheck", "-cert", "client.pem")
+	assert.Equal(t, 2, code, "-cert needs -key")
+}
+
+func TestCommands_HealthcheckMTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newCA(t)
+	serverCert, serverKey := issue(t, dir, "server", ca, caKey, x509.ExtKeyUsageServerAuth, "api.example.com")
+	clientCert, clientKey := issue(t, dir, "client", ca, caKey, x509.ExtKeyUsageClientAuth, "healthcheck")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	pair, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"data":{"status":"ok"}}`)
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // the failed handshakes
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	// The certificate names the public host, and is trusted as the one
+	// server.tls serves
+	config := fmt.Sprintf(`server:
+  port: %q
+  tls:
+    enabled: true
+    cert_file: %q
+    key_file: %q
+`, port, serverCert, serverKey)
+
+	_, stderr, code := run(t, config, "healthcheck")
+	assert.Equal(t, 1, code, "without a client certificate the handshake fails")
+	assert.Contains(t, stderr, "unhealthy")
+
+	stdout, stderr, code := run(t, config, "healthcheck", "-cert", clientCert, "-key", clientKey)
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "healthy\n", stdout)
+
+	// Another certificate than the configured one is not trusted
+	otherCert, otherKey := issue(t, dir, "other", ca, caKey, x509.ExtKeyUsageServerAuth, "api.example.com")
+	other := strings.NewReplacer(serverCert, otherCert, serverKey, otherKey).Replace(config)
+	_, stderr, code = run(t, other, "healthcheck", "-cert", clientCert, "-key", clientKey)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "server.tls.cert_file")
+}
+
+func newCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return ca, key
+}
+
+// issue writes a certificate for name signed by ca, and its key, to dir.
+func issue(t *testing.T, dir, file string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, usage x509.ExtKeyUsage, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, file+".pem")
+	keyFile = filepath.Join(dir, file+"-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackups_EditDiffRestore(t *testing.T) {
+	file, path := sampleFile(t)
+	backups := config.NewBackupStore(path, config.ConfigBackupsConfig{Enabled: true, Keep: 10})
+	file.WithBackups(backups)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "config-backups"), backups.Dir())
+
+	list, err := backups.List()
+	require.NoError(t, err)
+	assert.Empty(t, list, "no backups before the first edit")
+
+	_, err = file.Put("grafana", map[string]interface{}{"enabled": true, "api_key": "rotated"}, "")
+	require.NoError(t, err)
+	list, err = backups.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	original := list[0]
+	assert.Equal(t, int64(len(sampleConfig)), original.Size)
+
+	info, err := os.Stat(filepath.Join(backups.Dir(), "config."+original.ID+".yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	diff, err := backups.Diff(original.ID)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "--- backup/"+original.ID)
+	assert.Contains(t, diff, "-  enabled: false")
+	assert.Contains(t, diff, "+  enabled: true")
+	assert.NotContains(t, diff, "abc", "secrets are masked")
+	assert.NotContains(t, diff, "rotated")
+
+	previous, err := backups.Restore(original.ID)
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, sampleConfig, string(data))
+
+	// The restore backed up the edited file, so it can be undone
+	diff, err = backups.Diff(previous.ID)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+  enabled: false")
+	diff, err = backups.Diff(original.ID)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	_, err = backups.Diff("../config")
+	assert.ErrorIs(t, err, config.ErrBackupNotFound)
+	_, err = backups.Restore("20000101T000000.000000000Z")
+	assert.ErrorIs(t, err, config.ErrBackupNotFound)
+}
+
+func TestBackups_RestoreRejectsInvalidFile(t *testing.T) {
+	_, path := sampleFile(t)
+	backups := config.NewBackupStore(path, config.ConfigBackupsConfig{Enabled: true})
+	backup, err := backups.Create()
+	require.NoError(t, err)
+
+	broken := filepath.Join(backups.Dir(), "config."+backup.ID+".yaml")
+	require.NoError(t, os.WriteFile(broken, []byte("app: [unterminated"), 0o600))
+	_, err = backups.Restore(backup.ID)
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, sampleConfig, string(data), "the config file is left alone")
+}
+
+func TestBackups_Prune(t *testing.T) {
+	_, path := sampleFile(t)
+	backups := config.NewBackupStore(path, config.ConfigBackupsConfig{Enabled: true, Keep: 2})
+	for range 4 {
+		_, err := backups.Create()
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	list, err := backups.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 2, "creating a backup applies the retention")
+
+	// An old backup is pruned by age, but the newest always stays
+	old := filepath.Join(backups.Dir(), "config.20000101T000000.000000000Z.yaml")
+	require.NoError(t, os.WriteFile(old, []byte(sampleConfig), 0o600))
+	aged := config.NewBackupStore(path, config.ConfigBackupsConfig{Enabled: true, MaxAge: 3600})
+	removed, err := aged.Prune()
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, "20000101T000000.000000000Z", removed[0].ID)
+	list, _ = aged.List()
+	assert.Len(t, list, 2)
+}
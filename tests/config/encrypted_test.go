@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedValues_RoundTrip(t *testing.T) {
+	encoded, err := config.GenerateConfigKey()
+	require.NoError(t, err)
+	key, err := config.ParseConfigKey(encoded)
+	require.NoError(t, err)
+
+	value, err := config.EncryptValue(key, "s3cret")
+	require.NoError(t, err)
+	assert.True(t, config.IsEncryptedValue(value))
+	again, _ := config.EncryptValue(key, "s3cret")
+	assert.NotEqual(t, value, again, "every encryption has its own nonce")
+
+	plain, err := config.DecryptValue(key, value)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plain)
+
+	other, _ := config.GenerateConfigKey()
+	otherKey, _ := config.ParseConfigKey(other)
+	_, err = config.DecryptValue(otherKey, value)
+	assert.ErrorIs(t, err, config.ErrDecryptValue)
+	_, err = config.ParseConfigKey("c2hvcnQ=")
+	assert.Error(t, err)
+}
+
+func TestEncryptedValues_DecryptedAtLoad(t *testing.T) {
+	encoded, _ := config.GenerateConfigKey()
+	key, _ := config.ParseConfigKey(encoded)
+	value, err := config.EncryptValue(key, "db-pass")
+	require.NoError(t, err)
+	path := writeConfig(t, "postgres:\n  enabled: true\n  password: \""+value+"\"\n")
+
+	t.Setenv(config.ConfigKeyEnv, "")
+	t.Setenv(config.ConfigKeyFileEnv, "")
+	_, err = config.ReadConfigFile(path)
+	assert.ErrorIs(t, err, config.ErrNoConfigKey)
+
+	// The key may come from a file, as KMS agents mount it
+	keyFile := writeConfig(t, encoded+"\n")
+	t.Setenv(config.ConfigKeyFileEnv, keyFile)
+	cfg, err := config.ReadConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "db-pass", cfg.Postgres.Password)
+	assert.Equal(t, "db-pass", cfg.PostgresMultiConfig.Connections[0].Password)
+
+	// The key itself can live in Vault
+	srv := fakeVaultKey(t, encoded)
+	t.Setenv(config.ConfigKeyFileEnv, "")
+	t.Setenv(config.ConfigKeyEnv, "vault://secret/data/stackyrd#config_key")
+	t.Setenv("VAULT_ADDR", srv)
+	t.Setenv("VAULT_TOKEN", "root-token")
+	cfg, err = config.ReadConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "db-pass", cfg.Postgres.Password)
+}
+
+func TestEncryptedValues_Masked(t *testing.T) {
+	encoded, _ := config.GenerateConfigKey()
+	key, _ := config.ParseConfigKey(encoded)
+	value, err := config.EncryptValue(key, "https://grafana.internal")
+	require.NoError(t, err)
+	path := writeConfig(t, "grafana:\n  enabled: false\n  url: \""+value+"\"\n")
+	file := config.NewSectionFile(path)
+
+	section, err := file.Get("grafana")
+	require.NoError(t, err)
+	assert.Equal(t, config.SecretMask, section.Value.(map[string]interface{})["url"], "encrypted values are masked whatever their key")
+
+	_, err = file.Put("grafana", map[string]interface{}{"enabled": true, "url": config.SecretMask}, "")
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), value, "a masked encrypted value is kept as stored")
+}
+
+// fakeVaultKey serves the master key at secret/data/stackyrd#config_key.
+func fakeVaultKey(t *testing.T, key string) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/stackyrd" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"data":{"config_key":%q},"metadata":{}}}`, key)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
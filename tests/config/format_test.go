@@ -0,0 +1,121 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jsonConfig = `{
+  "app": {
+    "name": "stackyrd",
+    "env": "production"
+  },
+  "server": {
+    "port": "8080"
+  },
+  "postgres": {
+    "enabled": true,
+    "connections": [
+      {"name": "primary", "host": "db1", "port": 5432, "password": "s3cret"}
+    ]
+  }
+}
+`
+
+const tomlConfig = `[app]
+name = "stackyrd"
+env = "production"
+
+[server]
+port = "8080"
+
+[[postgres.connections]]
+name = "primary"
+host = "db1"
+port = 5432
+password = "s3cret"
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestFormats_LoadJSONAndTOMLWithOverlays(t *testing.T) {
+	for name, tc := range map[string]struct{ base, overlay string }{
+		"config.json": {jsonConfig, `{"server": {"port": "9090"}}`},
+		"config.toml": {tomlConfig, "[server]\nport = \"9090\"\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeFile(t, dir, name, tc.base)
+			writeFile(t, dir, filepath.Base(config.OverlayPath(path, "production")), tc.overlay)
+
+			cfg, err := config.ReadConfigFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, "stackyrd", cfg.App.Name)
+			assert.Equal(t, "9090", cfg.Server.Port)
+			require.Len(t, cfg.PostgresMultiConfig.Connections, 1)
+			assert.Equal(t, 5432, cfg.PostgresMultiConfig.Connections[0].Port)
+		})
+	}
+}
+
+func TestFormats_FindConfigFilePrefersYAML(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, "", config.FindConfigFile(dir))
+	writeFile(t, dir, "config.toml", tomlConfig)
+	assert.Equal(t, filepath.Join(dir, "config.toml"), config.FindConfigFile(dir))
+	writeFile(t, dir, "config.json", jsonConfig)
+	assert.Equal(t, filepath.Join(dir, "config.json"), config.FindConfigFile(dir))
+	writeFile(t, dir, "config.yaml", "app:\n  name: x\n")
+	assert.Equal(t, filepath.Join(dir, "config.yaml"), config.FindConfigFile(t.TempDir(), dir))
+
+	assert.Equal(t, config.FormatJSON, config.FormatOf("a/config.JSON"))
+	assert.Equal(t, config.FormatTOML, config.FormatOf("config.toml"))
+	assert.Equal(t, config.FormatYAML, config.FormatOf("config.yml"))
+}
+
+func TestFormats_SectionEditsKeepTheFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	jsonPath := writeFile(t, dir, "config.json", jsonConfig)
+	file := config.NewSectionFile(jsonPath)
+	section, err := file.Get("postgres.connections.0")
+	require.NoError(t, err)
+	value := section.Value.(map[string]interface{})
+	value["host"] = "db9"
+	_, err = file.Put("postgres.connections.0", value, section.Version)
+	require.NoError(t, err)
+	data, err := os.ReadFile(jsonPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"host": "db9"`)
+	assert.Contains(t, string(data), `"password": "s3cret"`, "masked secrets keep their value")
+	// Key order of the file is kept
+	assert.Less(t, strings.Index(string(data), `"app"`), strings.Index(string(data), `"server"`))
+	assert.Less(t, strings.Index(string(data), `"server"`), strings.Index(string(data), `"postgres"`))
+	cfg, err := config.ReadConfigFile(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, "db9", cfg.PostgresMultiConfig.Connections[0].Host)
+
+	tomlPath := writeFile(t, dir, "other.toml", tomlConfig)
+	file = config.NewSectionFile(tomlPath)
+	_, err = file.Put("server", map[string]interface{}{"port": "7070"}, "")
+	require.NoError(t, err)
+	cfg, err = config.ReadConfigFile(tomlPath)
+	require.NoError(t, err)
+	assert.Equal(t, "7070", cfg.Server.Port)
+	assert.Equal(t, "s3cret", cfg.PostgresMultiConfig.Connections[0].Password)
+
+	_, err = file.Put("server", map[string]interface{}{"port": "7070", "prot": 1}, "")
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+}
@@ -0,0 +1,97 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const overlayBase = `
+app:
+  name: "stackyrd"
+  env: "production"
+server:
+  port: "8080"
+redis:
+  address: "localhost:6379"
+  password: "base-pass"
+`
+
+func writeOverlay(t *testing.T, base, env, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(config.OverlayPath(base, env), []byte(content), 0o600))
+}
+
+func TestOverlay_MergesEnvironmentFile(t *testing.T) {
+	base := writeConfig(t, overlayBase)
+	writeOverlay(t, base, "production", `
+server:
+  port: "9090"
+redis:
+  password: "prod-pass"
+`)
+	t.Setenv("REDIS_ADDRESS", "cache.internal:6379")
+
+	cfg, err := config.ReadConfigFile(base)
+	require.NoError(t, err)
+	assert.Equal(t, "stackyrd", cfg.App.Name)                 // base file
+	assert.Equal(t, "9090", cfg.Server.Port)                  // overlay over base
+	assert.Equal(t, "prod-pass", cfg.Redis.Password)          // siblings merge, not replace
+	assert.Equal(t, "cache.internal:6379", cfg.Redis.Address) // environment over files
+}
+
+func TestOverlay_EnvironmentFlagWins(t *testing.T) {
+	base := writeConfig(t, overlayBase)
+	writeOverlay(t, base, "production", "server:\n  port: \"9090\"\n")
+	writeOverlay(t, base, "staging", "server:\n  port: \"7070\"\n")
+	config.SetEnvironment("staging")
+	t.Cleanup(func() { config.SetEnvironment("") })
+
+	cfg, err := config.ReadConfigFile(base)
+	require.NoError(t, err)
+	assert.Equal(t, "staging", cfg.App.Env)
+	assert.Equal(t, "7070", cfg.Server.Port)
+
+	// Without an overlay for the environment the base file applies as is
+	config.SetEnvironment("qa")
+	cfg, err = config.ReadConfigFile(base)
+	require.NoError(t, err)
+	assert.Equal(t, "qa", cfg.App.Env)
+	assert.Equal(t, "8080", cfg.Server.Port)
+}
+
+func TestOverlay_LoadedLayersReportSources(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(overlayBase), 0o600))
+	writeOverlay(t, filepath.Join(dir, "config.yaml"), "production", "server:\n  port: \"9090\"\n")
+	t.Setenv("APP_NAME", "from-env")
+	t.Chdir(dir)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	layers := config.LoadedLayers()
+	assert.Equal(t, "production", layers.Environment)
+	require.Len(t, layers.Files, 2)
+	assert.Equal(t, "config.production.yaml", filepath.Base(layers.Files[1]))
+
+	sources := map[string]string{}
+	values := map[string]interface{}{}
+	for _, key := range config.EffectiveKeys(cfg, layers) {
+		sources[key.Key] = key.Source
+		values[key.Key] = key.Value
+	}
+	assert.Equal(t, "env:APP_NAME", sources["app.name"])
+	assert.Equal(t, "from-env", values["app.name"])
+	assert.Equal(t, layers.Files[1], sources["server.port"])
+	assert.Equal(t, layers.Files[0], sources["redis.address"])
+	assert.Equal(t, config.SourceDefault, sources["server.shutdown_timeout"])
+	assert.Equal(t, config.SecretMask, values["redis.password"])
+}
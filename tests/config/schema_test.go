@@ -0,0 +1,119 @@
+package config_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// schemaErrors checks value against the subset of JSON Schema that
+// config.Schema emits.
+func schemaErrors(schema map[string]interface{}, value interface{}, path string) []string {
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, option := range anyOf {
+			if len(schemaErrors(option.(map[string]interface{}), value, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{path + ": matches no option"}
+	}
+	var errs []string
+	switch schema["type"] {
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an object", path, value)}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for k, v := range m {
+			if p, ok := properties[k].(map[string]interface{}); ok {
+				errs = append(errs, schemaErrors(p, v, path+"."+k)...)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				errs = append(errs, schemaErrors(additional, v, path+"."+k)...)
+			} else if schema["additionalProperties"] == false {
+				errs = append(errs, path+"."+k+": unknown key")
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an array", path, value)}
+		}
+		for i, item := range items {
+			errs = append(errs, schemaErrors(schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s.%d", path, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok && value != nil {
+			errs = append(errs, fmt.Sprintf("%s: %T is not a string", path, value))
+		}
+	case "integer", "number":
+		switch value.(type) {
+		case int, int64, float64, nil:
+		default:
+			errs = append(errs, fmt.Sprintf("%s: %T is not a number", path, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok && value != nil {
+			errs = append(errs, fmt.Sprintf("%s: %T is not a boolean", path, value))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+func TestSchema_AcceptsSampleConfig(t *testing.T) {
+	schema := config.Schema()
+	assert.Equal(t, config.SchemaDraft, schema["$schema"])
+	// Round trip through JSON like editors read it
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	raw, err := os.ReadFile(filepath.Join("..", "..", "config.yaml"))
+	require.NoError(t, err)
+	var sample map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(raw, &sample))
+	assert.Empty(t, schemaErrors(decoded, sample, "$"))
+
+	bad := map[string]interface{}{
+		"server": map[string]interface{}{"shutdown_timeout": "soon", "prot": "8080"},
+	}
+	assert.Equal(t, []string{"$.server.prot: unknown key", "$.server.shutdown_timeout: string is not a number"},
+		schemaErrors(decoded, bad, "$"))
+}
+
+func TestSchema_DefaultsSecretsAndSections(t *testing.T) {
+	server, err := config.SectionSchema("server")
+	require.NoError(t, err)
+	port := server["properties"].(map[string]interface{})["port"].(map[string]interface{})
+	assert.Equal(t, "string", port["type"])
+	assert.Equal(t, "8080", port["default"])
+
+	conn, err := config.SectionSchema("postgres.connections.0")
+	require.NoError(t, err)
+	password := conn["properties"].(map[string]interface{})["password"].(map[string]interface{})
+	assert.Equal(t, true, password["writeOnly"])
+
+	// Both postgres forms are accepted under one key
+	postgres, err := config.SectionSchema("postgres")
+	require.NoError(t, err)
+	assert.Contains(t, postgres["properties"], "connections")
+	assert.Contains(t, postgres["properties"], "host")
+
+	middleware, err := config.SectionSchema("middleware.jwt")
+	require.NoError(t, err)
+	assert.Equal(t, "boolean", middleware["type"])
+
+	_, err = config.SectionSchema("nosuch")
+	assert.ErrorIs(t, err, config.ErrSectionNotFound)
+}
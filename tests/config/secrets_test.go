@@ -0,0 +1,142 @@
+package config_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/spf13/viper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault serves one KV v2 secret at secret/data/db.
+func fakeVault(t *testing.T, token string) (*httptest.Server, *atomic.Int32) {
+	var reads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/db" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		reads.Add(1)
+		_, _ = w.Write([]byte(`{"data":{"data":{"password":"s3cret","user":"app","port":5433},"metadata":{"version":3}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reads
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestSecrets_EnvAndVault(t *testing.T) {
+	srv, reads := fakeVault(t, "root-token")
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("APP_VAULT_TOKEN", "root-token")
+	t.Setenv("REDIS_HOST", "cache.internal")
+
+	cfg, err := config.ReadConfigFile(writeConfig(t, `
+app:
+  name: "${APP_NAME:-stackyrd}"
+vault:
+  token: "${APP_VAULT_TOKEN}"
+postgres:
+  enabled: true
+  user: "vault://secret/data/db#user"
+  password: "vault://secret/data/db#password"
+redis:
+  address: "${REDIS_HOST}:6379"
+  password: "literal $${NOT_EXPANDED}"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "stackyrd", cfg.App.Name)
+	assert.Equal(t, "cache.internal:6379", cfg.Redis.Address)
+	assert.Equal(t, "literal ${NOT_EXPANDED}", cfg.Redis.Password)
+	assert.Equal(t, "app", cfg.Postgres.User)
+	assert.Equal(t, "s3cret", cfg.Postgres.Password)
+	assert.Equal(t, "s3cret", cfg.PostgresMultiConfig.Connections[0].Password)
+	assert.Equal(t, srv.URL, cfg.Vault.Address, "VAULT_ADDR fills the address")
+	assert.Equal(t, "root-token", cfg.Vault.Token)
+	assert.EqualValues(t, 1, reads.Load(), "each path is read once per load")
+}
+
+func TestSecrets_Unresolved(t *testing.T) {
+	srv, _ := fakeVault(t, "root-token")
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+	os.Unsetenv("STACKYRD_UNSET_VAR")
+
+	cases := map[string]string{
+		"unset env":   "redis:\n  password: \"${STACKYRD_UNSET_VAR}\"\n",
+		"missing key": "redis:\n  password: \"vault://secret/data/db#nope\"\n",
+		"no key":      "redis:\n  password: \"vault://secret/data/db\"\n",
+		"no secret":   "redis:\n  password: \"vault://secret/data/other#password\"\n",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.ReadConfigFile(writeConfig(t, content))
+			assert.ErrorIs(t, err, config.ErrUnresolvedSecret)
+		})
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	_, err := config.ReadConfigFile(writeConfig(t, cases["missing key"]))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+}
+
+func TestSecrets_ReadUnresolved(t *testing.T) {
+	srv, reads := fakeVault(t, "root-token")
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+	t.Setenv(config.ConfigKeyEnv, "")
+	os.Unsetenv("STACKYRD_UNSET_VAR")
+	content := `
+postgres:
+  enabled: true
+  password: "vault://secret/data/db#password"
+redis:
+  address: "${STACKYRD_UNSET_VAR}:6379"
+  password: "ENC[AES256_GCM,bm90LWEta2V5]"
+`
+	path := writeConfig(t, content)
+
+	// Neither Vault, the environment nor the master key are consulted
+	cfg, err := config.ReadConfigFile(path, config.Unresolved())
+	require.NoError(t, err)
+	assert.Equal(t, "vault://secret/data/db#password", cfg.PostgresMultiConfig.Connections[0].Password)
+	assert.Equal(t, "${STACKYRD_UNSET_VAR}:6379", cfg.Redis.Address)
+	assert.Equal(t, "ENC[AES256_GCM,bm90LWEta2V5]", cfg.Redis.Password)
+	assert.Zero(t, reads.Load())
+
+	// LoadConfig records the same fingerprint for the file it loads
+	t.Setenv("STACKYRD_UNSET_VAR", "cache.internal")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(content, `"ENC[AES256_GCM,bm90LWEta2V5]"`, `"plain"`, 1)), 0o600))
+	t.Chdir(filepath.Dir(path))
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	loaded, err := config.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", loaded.Postgres.Password)
+	unresolved, err := config.ReadConfigFile(path, config.Unresolved())
+	require.NoError(t, err)
+	assert.Equal(t, config.Fingerprint(unresolved), config.LoadedFingerprint())
+	assert.NotEqual(t, config.Fingerprint(loaded), config.LoadedFingerprint())
+}
@@ -0,0 +1,135 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleConfig = `# Application settings
+app:
+  name: "stackyrd"
+  debug: true # verbose logging
+
+postgres:
+  enabled: true
+  connections:
+    - name: "primary"
+      host: "db1"
+      port: 5432
+      password: "s3cret"
+    - name: "tenant_a"
+      host: "db2"
+      port: 5432
+      password: "other"
+
+grafana:
+  enabled: false
+  api_key: "abc"
+`
+
+func sampleFile(t *testing.T) (*config.SectionFile, string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(sampleConfig), 0o600))
+	return config.NewSectionFile(path), path
+}
+
+func TestSectionFile_Get(t *testing.T) {
+	file, _ := sampleFile(t)
+
+	sections, err := file.Sections()
+	require.NoError(t, err)
+	require.Len(t, sections, 3)
+	assert.Equal(t, "app", sections[0].Path)
+	assert.Equal(t, "postgres", sections[1].Path)
+	assert.Greater(t, sections[1].Size, sections[0].Size)
+
+	section, err := file.Get("postgres/connections/1")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres.connections.1", section.Path)
+	value := section.Value.(map[string]interface{})
+	assert.Equal(t, "tenant_a", value["name"])
+	assert.Equal(t, config.SecretMask, value["password"], "secrets are masked")
+
+	_, err = file.Get("postgres.connections.5")
+	assert.ErrorIs(t, err, config.ErrSectionNotFound)
+}
+
+func TestSectionFile_Put(t *testing.T) {
+	file, path := sampleFile(t)
+	section, err := file.Get("postgres.connections.0")
+	require.NoError(t, err)
+	other, err := file.Get("app")
+	require.NoError(t, err)
+
+	update := section.Value.(map[string]interface{})
+	update["host"] = "db1.internal"
+	saved, err := file.Put("postgres.connections.0", update, section.Version)
+	require.NoError(t, err)
+	assert.NotEqual(t, section.Version, saved.Version)
+
+	// The masked password kept its value, comments and other sections stay
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "db1.internal")
+	assert.Contains(t, string(data), "s3cret")
+	assert.Contains(t, string(data), "# verbose logging")
+	assert.Contains(t, string(data), "tenant_a")
+
+	// A stale version of the edited section conflicts, other sections do not
+	_, err = file.Put("postgres.connections.0", update, section.Version)
+	assert.ErrorIs(t, err, config.ErrVersionConflict)
+	_, err = file.Put("app.debug", false, "")
+	require.NoError(t, err)
+	app, _ := file.Get("app")
+	assert.NotEqual(t, other.Version, app.Version)
+	_, err = file.Put("app", app.Value, app.Version)
+	assert.NoError(t, err)
+}
+
+func TestSectionFile_Validation(t *testing.T) {
+	file, path := sampleFile(t)
+	before, _ := os.ReadFile(path)
+
+	_, err := file.Put("postgres.connections.0.port", "not a number", "")
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+
+	_, err = file.Put("grafana", map[string]interface{}{"enabled": true, "api_kye": "x"}, "")
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+	assert.ErrorContains(t, err, "api_kye")
+
+	_, err = file.Put("no_such_section", map[string]interface{}{"enabled": true}, "")
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+
+	after, _ := os.ReadFile(path)
+	assert.Equal(t, string(before), string(after), "invalid updates are not written")
+
+	// Keys of either struct sharing the postgres key are accepted
+	require.NoError(t, config.ValidateSection("postgres", map[string]interface{}{
+		"enabled": true, "host": "db", "connections": []interface{}{map[string]interface{}{"name": "x"}},
+	}))
+
+	// Missing keys are added to an existing parent
+	_, err = file.Put("grafana.url", "http://grafana:3000", "")
+	require.NoError(t, err)
+	section, err := file.Get("grafana.url")
+	require.NoError(t, err)
+	assert.Equal(t, "http://grafana:3000", section.Value)
+}
+
+func TestValidateFile(t *testing.T) {
+	_, path := sampleFile(t)
+	require.NoError(t, config.ValidateFile(path))
+
+	bad := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(bad, []byte(`{"app": {"name": "x", "nmae": "y"}, "redis": {"port": "six"}}`), 0o600))
+	err := config.ValidateFile(bad)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nmae")
+	assert.Contains(t, err.Error(), "redis")
+}
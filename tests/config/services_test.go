@@ -0,0 +1,65 @@
+package config_test
+
+import (
+	"testing"
+
+	"stackyrd/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServices_ToggleAndLongForm(t *testing.T) {
+	path := writeConfig(t, `
+services:
+  users_service: false
+  products_service:
+    middleware:
+      auth: none
+      rate_limit: {requests: 10, window: 30}
+      cache: {ttl: 5}
+  tasks_service:
+    enabled: false
+    middleware:
+      skip: [permission_check]
+`)
+	cfg, err := config.ReadConfigFile(path)
+	require.NoError(t, err)
+
+	assert.False(t, cfg.Services.IsEnabled("users_service"))
+	assert.True(t, cfg.Services.IsEnabled("products_service"), "long-form entries are enabled unless they say otherwise")
+	assert.False(t, cfg.Services.IsEnabled("tasks_service"))
+	assert.True(t, cfg.Services.IsEnabled("cache_service"))
+
+	products := cfg.ServiceSettings["products_service"].Middleware
+	assert.Equal(t, "none", products.Auth)
+	assert.Equal(t, config.ServiceRateLimit{Requests: 10, Window: 30}, products.RateLimit)
+	assert.Equal(t, 5, products.Cache.TTL)
+	assert.Equal(t, []string{"permission_check"}, cfg.ServiceSettings["tasks_service"].Middleware.Skip)
+	assert.False(t, cfg.ServiceSettings["tasks_service"].Middleware.IsZero())
+	assert.False(t, cfg.ServiceSettings["users_service"].Enabled)
+	assert.True(t, cfg.ServiceSettings["users_service"].Middleware.IsZero())
+}
+
+func TestServices_ValidateSection(t *testing.T) {
+	assert.NoError(t, config.ValidateSection("services", map[string]interface{}{
+		"users_service":    true,
+		"products_service": map[string]interface{}{"middleware": map[string]interface{}{"roles": []interface{}{"admin"}}},
+	}))
+	assert.NoError(t, config.ValidateSection("services/products_service", map[string]interface{}{"enabled": true}))
+	assert.NoError(t, config.ValidateSection("services/products_service", false))
+
+	err := config.ValidateSection("services/products_service", map[string]interface{}{"middleware": map[string]interface{}{"ratelimit": 10}})
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+	assert.Contains(t, err.Error(), "ratelimit")
+	err = config.ValidateSection("services", map[string]interface{}{
+		"products_service": map[string]interface{}{"middleware": map[string]interface{}{"cache": map[string]interface{}{"tll": 5}}},
+	})
+	assert.ErrorIs(t, err, config.ErrInvalidSection)
+	assert.Contains(t, err.Error(), "tll")
+
+	schema, err := config.SectionSchema("services")
+	require.NoError(t, err)
+	entry := schema["additionalProperties"].(map[string]interface{})
+	assert.Len(t, entry["anyOf"], 2, "an entry is a toggle or a long-form object")
+}
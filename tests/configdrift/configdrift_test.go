@@ -0,0 +1,137 @@
+package configdrift_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/configdrift"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const original = `app:
+  name: "drift-test"
+postgres:
+  enabled: true
+  host: "db"
+  password: "first"
+`
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestReadConfigFile_Fingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, original)
+
+	cfg, err := config.ReadConfigFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "drift-test", cfg.App.Name)
+	assert.Equal(t, "8080", cfg.Server.Port, "defaults apply")
+	require.Len(t, cfg.PostgresMultiConfig.Connections, 1, "legacy sections are normalized")
+
+	again, err := config.ReadConfigFile(path)
+	require.NoError(t, err)
+	fingerprint := config.Fingerprint(cfg)
+	assert.Len(t, fingerprint, 16)
+	assert.Equal(t, fingerprint, config.Fingerprint(again))
+
+	// Secrets are part of the fingerprint
+	again.Postgres.Password = "second"
+	assert.NotEqual(t, fingerprint, config.Fingerprint(again))
+
+	_, err = config.ReadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestWatcher_Drift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, original)
+	cfg, err := config.ReadConfigFile(path, config.Unresolved())
+	require.NoError(t, err)
+
+	w := configdrift.New(path, config.Fingerprint(cfg), time.Minute, logger.NewQuiet(false, io.Discard))
+	_, ok := w.Last()
+	assert.False(t, ok)
+
+	state := w.Check()
+	require.True(t, state.OK())
+	assert.False(t, state.Drifted)
+	assert.Equal(t, w.Running(), state.FileFingerprint)
+
+	// Formatting and comments do not count as drift
+	writeConfig(t, path, "# edited\n"+original)
+	assert.False(t, w.Check().Drifted)
+
+	writeConfig(t, path, original+"  port: 6543\n")
+	state = w.Check()
+	assert.True(t, state.Drifted)
+	assert.NotEqual(t, w.Running(), state.FileFingerprint)
+	last, ok := w.Last()
+	require.True(t, ok)
+	assert.Equal(t, state, last)
+
+	writeConfig(t, path, "app: [broken")
+	state = w.Check()
+	assert.False(t, state.OK())
+	assert.False(t, state.Drifted)
+
+	writeConfig(t, path, original)
+	assert.False(t, w.Check().Drifted)
+	assert.Equal(t, path, w.Status()["path"])
+}
+
+func TestWatcher_Start(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, original)
+
+	w := configdrift.New(path, "stale", 10*time.Millisecond, nil)
+	w.Start()
+	require.Eventually(t, func() bool {
+		last, ok := w.Last()
+		return ok && last.Drifted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, w.Close())
+}
+
+func TestWatcher_SecretReferencesAreNotResolved(t *testing.T) {
+	var reads atomic.Int32
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		w.Write([]byte(`{"data":{"data":{"password":"s3cret"}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	referenced := strings.Replace(original, `"first"`, `"vault://secret/data/app#password"`, 1)
+	writeConfig(t, path, referenced)
+	cfg, err := config.ReadConfigFile(path, config.Unresolved())
+	require.NoError(t, err)
+
+	w := configdrift.New(path, config.Fingerprint(cfg), time.Minute, nil)
+	state := w.Check()
+	require.True(t, state.OK(), state.Error)
+	assert.False(t, state.Drifted)
+	assert.Zero(t, reads.Load(), "checks do not read Vault")
+
+	// Nor is a reference Vault cannot resolve an error
+	vault.Close()
+	writeConfig(t, path, strings.Replace(referenced, "#password", "#other", 1))
+	state = w.Check()
+	require.True(t, state.OK(), state.Error)
+	assert.True(t, state.Drifted)
+}
@@ -0,0 +1,50 @@
+package devmode_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/internal/devmode"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_SeparatesConfigAndContentChanges(t *testing.T) {
+	root := t.TempDir()
+	configFile := filepath.Join(root, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("app: {}\n"), 0644))
+	templates := filepath.Join(root, "templates")
+	require.NoError(t, os.MkdirAll(filepath.Join(templates, "mail"), 0755))
+
+	w, err := devmode.NewWatcher([]string{configFile}, []string{templates, filepath.Join(root, "missing")}, 50*time.Millisecond, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, err)
+	defer w.Close()
+
+	configs := make(chan []string, 4)
+	contents := make(chan []string, 4)
+	w.Start(func(p []string) { configs <- p }, func(p []string) { contents <- p })
+
+	// Unrelated files next to the config are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("x"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(templates, "mail", "welcome.html"), []byte("<p>hi</p>"), 0644))
+	select {
+	case paths := <-contents:
+		assert.Contains(t, paths, filepath.Join(templates, "mail", "welcome.html"))
+	case <-time.After(3 * time.Second):
+		t.Fatal("content change not reported")
+	}
+
+	require.NoError(t, os.WriteFile(configFile, []byte("app: {name: x}\n"), 0644))
+	select {
+	case paths := <-configs:
+		assert.Equal(t, []string{configFile}, paths)
+	case <-time.After(3 * time.Second):
+		t.Fatal("config change not reported")
+	}
+	assert.Empty(t, contents)
+}
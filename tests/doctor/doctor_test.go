@@ -0,0 +1,160 @@
+package doctor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/doctor"
+	"stackyrd/pkg/registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeComponent struct {
+	status map[string]interface{}
+}
+
+func (f *fakeComponent) GetStatus() map[string]interface{} { return f.status }
+
+func baseConfig(t *testing.T) *config.Config {
+	cfg := &config.Config{}
+	cfg.Server.Port = "8080"
+	cfg.Server.ServicesEndpoint = "/api/v1"
+	cfg.Store.Enabled = true
+	cfg.Store.Path = filepath.Join(t.TempDir(), "data", "stackyrd.db")
+	cfg.Doctor.MaxClockSkew = 2
+	cfg.Doctor.Timeout = 2
+	return cfg
+}
+
+func byName(report doctor.Report) map[string]doctor.Result {
+	results := make(map[string]doctor.Result)
+	for _, r := range report.Checks {
+		results[r.Name] = r
+	}
+	return results
+}
+
+func TestDoctor_Report(t *testing.T) {
+	ext := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ext.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	cfg := baseConfig(t)
+	cfg.Monitoring.External.Services = []config.ExternalService{
+		{Name: "billing", URL: ext.URL},
+		{Name: "search", URL: down.URL},
+	}
+
+	up := &fakeComponent{status: map[string]interface{}{"connected": true}}
+	deps := registry.NewDependencies()
+	deps.Set("redis", up)
+	deps.Set("redis.alias", up)
+	deps.Set("kafka", &fakeComponent{status: map[string]interface{}{"connected": false, "error": "no brokers"}})
+	deps.Set("flag", true)
+
+	report := doctor.New(cfg, deps).Run(context.Background())
+	results := byName(report)
+
+	assert.Equal(t, doctor.StatusPass, results["redis"].Status)
+	assert.NotContains(t, results, "redis.alias")
+	assert.NotContains(t, results, "flag")
+	assert.Equal(t, doctor.StatusFail, results["kafka"].Status)
+	assert.Contains(t, results["kafka"].Message, "no brokers")
+	assert.Equal(t, doctor.StatusPass, results["external:billing"].Status)
+	assert.Equal(t, doctor.StatusFail, results["external:search"].Status)
+
+	// The store directory does not exist yet but can be created
+	assert.Equal(t, doctor.StatusWarn, results["writable:store"].Status)
+	assert.Contains(t, results, "disk_space")
+	assert.Equal(t, doctor.StatusPass, results["config"].Status)
+
+	assert.Equal(t, doctor.StatusFail, report.Status)
+	assert.Equal(t, 2, report.Summary[doctor.StatusFail])
+	for _, r := range report.Checks {
+		assert.NotEmpty(t, r.Category, r.Name)
+	}
+}
+
+func TestDoctor_Categories(t *testing.T) {
+	cfg := baseConfig(t)
+	require.NoError(t, os.MkdirAll(filepath.Dir(cfg.Store.Path), 0o750))
+
+	report := doctor.New(cfg, nil).Run(context.Background(), doctor.CategoryPermissions, doctor.CategoryClock)
+	results := byName(report)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, doctor.StatusPass, results["writable:store"].Status)
+	assert.Equal(t, doctor.StatusSkip, results["clock_skew"].Status)
+	assert.Equal(t, doctor.StatusPass, report.Status)
+}
+
+func TestDoctor_ClockSkew(t *testing.T) {
+	ref := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ref.Close()
+
+	for _, tc := range []struct {
+		offset time.Duration
+		status string
+	}{
+		{0, doctor.StatusPass},
+		{10 * time.Second, doctor.StatusWarn},
+		{-5 * time.Minute, doctor.StatusFail},
+	} {
+		cfg := baseConfig(t)
+		cfg.Doctor.ClockReference = ref.URL
+		d := doctor.New(cfg, nil)
+		d.SetClock(func() time.Time { return time.Now().Add(tc.offset) })
+
+		report := d.Run(context.Background(), doctor.CategoryClock)
+		assert.Equal(t, tc.status, report.Checks[0].Status, "offset %s", tc.offset)
+	}
+}
+
+func TestDoctor_ConfigSanity(t *testing.T) {
+	cfg := baseConfig(t)
+	cfg.Server.Port = "http"
+	cfg.Auth.Type = "jwt"
+	cfg.Kafka.Enabled = true
+	cfg.App.Env = "production"
+	cfg.App.Debug = true
+	cfg.Tracing.Enabled = true
+	cfg.Tracing.SampleRatio = 0
+
+	report := doctor.New(cfg, nil).Run(context.Background(), doctor.CategoryConfig)
+	result := report.Checks[0]
+	assert.Equal(t, doctor.StatusFail, result.Status)
+	assert.Len(t, result.Details["errors"], 3)
+	assert.Len(t, result.Details["warnings"], 1, "a sample ratio of 0 is valid")
+
+	cfg.Tracing.SampleRatio = 1.5
+	result = doctor.New(cfg, nil).Run(context.Background(), doctor.CategoryConfig).Checks[0]
+	assert.Len(t, result.Details["warnings"], 2)
+}
+
+func TestRun_Timeout(t *testing.T) {
+	report := doctor.Run(context.Background(), []doctor.Check{{
+		Name:     "slow",
+		Category: doctor.CategoryConnectivity,
+		Run: func(ctx context.Context) doctor.Result {
+			time.Sleep(time.Second)
+			return doctor.Result{Status: doctor.StatusPass}
+		},
+	}}, 50*time.Millisecond)
+
+	assert.Equal(t, doctor.StatusFail, report.Status)
+	assert.Contains(t, report.Checks[0].Message, "did not finish")
+}
@@ -0,0 +1,59 @@
+package endpointstats_test
+
+import (
+	"testing"
+	"time"
+
+	"stackyrd/pkg/endpointstats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_Figures(t *testing.T) {
+	c := endpointstats.NewCollector(time.Hour, 50)
+	start := time.Now()
+	for i := 1; i <= 100; i++ {
+		status := 200
+		if i%10 == 0 {
+			status = 503
+		}
+		c.Record(endpointstats.Request{
+			Method: "GET", Route: "/orders", Status: status,
+			Latency:      time.Duration(i) * time.Millisecond,
+			RequestBytes: -1, ResponseBytes: 100,
+			Time: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+
+	routes := c.Snapshot()
+	require.Len(t, routes, 1)
+	s := routes[0]
+	assert.Equal(t, int64(100), s.Requests)
+	assert.Equal(t, int64(10), s.Errors)
+	assert.Equal(t, map[string]int64{"2xx": 90, "5xx": 10}, s.StatusClasses)
+	assert.InDelta(t, 50.5, s.AvgLatencyMs, 0.001)
+	assert.Equal(t, 100.0, s.MaxLatencyMs)
+	// Percentiles cover the latest 50 latencies, 51ms to 100ms
+	assert.Equal(t, 75.0, s.P50LatencyMs)
+	assert.Equal(t, 98.0, s.P95LatencyMs)
+	assert.Equal(t, 100.0, s.P99LatencyMs)
+	assert.Equal(t, int64(0), s.RequestBytes, "unknown request sizes count as zero")
+	assert.Equal(t, 100.0, s.AvgResponseBytes)
+	assert.True(t, s.LastSeen.Equal(start.Add(100*time.Millisecond)))
+
+	c.Reset()
+	assert.Empty(t, c.Snapshot())
+}
+
+func TestCollector_StatusClasses(t *testing.T) {
+	c := endpointstats.NewCollector(0, 0)
+	c.Record(endpointstats.Request{Method: "GET", Route: "/a", Status: 200})
+	c.Record(endpointstats.Request{Method: "GET", Route: "/a", Status: 404})
+	c.Record(endpointstats.Request{Method: "DELETE", Route: "/a", Status: 204})
+	routes := c.Snapshot()
+	require.Len(t, routes, 2)
+	assert.Equal(t, "DELETE", routes[0].Method)
+	assert.Equal(t, map[string]int64{"2xx": 1, "4xx": 1}, routes[1].StatusClasses)
+	assert.Equal(t, "other", endpointstats.StatusClass(0))
+}
@@ -0,0 +1,278 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"stackyrd/pkg/graphql"
+
+	"github.com/gin-gonic/gin"
+	gql "github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Email   string   `json:"email"`
+	Address *address `json:"address,omitempty"`
+}
+
+type address struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+var (
+	addressType = gql.NewObject(gql.ObjectConfig{
+		Name: "Address",
+		Fields: gql.Fields{
+			"city":    {Type: gql.String},
+			"country": {Type: gql.String},
+		},
+	})
+	userType = gql.NewObject(gql.ObjectConfig{
+		Name: "User",
+		Fields: gql.Fields{
+			"id":      {Type: gql.NewNonNull(gql.Int)},
+			"name":    {Type: gql.String},
+			"email":   {Type: gql.String},
+			"address": {Type: addressType},
+		},
+	})
+	productType = gql.NewObject(gql.ObjectConfig{
+		Name: "Product",
+		Fields: gql.Fields{
+			"name":  {Type: gql.String},
+			"price": {Type: gql.Float},
+		},
+	})
+)
+
+// testSchema registers the fields two services would contribute.
+func testSchema(t *testing.T) *graphql.Schema {
+	t.Helper()
+	users := []user{
+		{ID: 1, Name: "Alice", Email: "alice@example.com", Address: &address{City: "Oslo", Country: "NO"}},
+		{ID: 2, Name: "Bob", Email: "bob@example.com"},
+	}
+	schema := graphql.NewSchema(4)
+	schema.Query("users", &gql.Field{
+		Type:    gql.NewList(userType),
+		Resolve: func(p gql.ResolveParams) (interface{}, error) { return users, nil },
+	})
+	schema.Query("user", &gql.Field{
+		Type: userType,
+		Args: gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.Int)}},
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			for _, u := range users {
+				if u.ID == p.Args["id"].(int) {
+					return u, nil
+				}
+			}
+			return nil, errors.New("user not found")
+		},
+	})
+	schema.Query("panics", &gql.Field{
+		Type:    gql.String,
+		Resolve: func(p gql.ResolveParams) (interface{}, error) { panic(errors.New("boom")) },
+	})
+	schema.Query("products", &gql.Field{
+		Type: gql.NewList(productType),
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return []map[string]interface{}{{"name": "Laptop", "price": 999.99}}, nil
+		},
+	})
+	schema.Mutation("rename", &gql.Field{
+		Type: userType,
+		Args: gql.FieldConfigArgument{"name": {Type: gql.NewNonNull(gql.String)}},
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return user{ID: 1, Name: p.Args["name"].(string)}, nil
+		},
+	})
+	require.NoError(t, schema.Build())
+	return schema
+}
+
+// execute runs req and returns the response as JSON text.
+func execute(t *testing.T, req graphql.Request) string {
+	t.Helper()
+	out, err := json.Marshal(testSchema(t).Execute(context.Background(), req))
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestBuild(t *testing.T) {
+	schema := graphql.NewSchema(0)
+	assert.ErrorIs(t, schema.Build(), graphql.ErrNoQueries)
+	res := schema.Execute(context.Background(), graphql.Request{Query: "{ users { id } }"})
+	require.NotEmpty(t, res.Errors, "an unbuilt schema executes nothing")
+
+	schema.Query("a", &gql.Field{Type: userType})
+	schema.Query("b", &gql.Field{Type: gql.NewObject(gql.ObjectConfig{Name: "User", Fields: gql.Fields{"x": {Type: gql.Int}}})})
+	assert.Error(t, schema.Build(), "two types of the same name conflict")
+}
+
+func TestExecute_SeveralServicesInOneRequest(t *testing.T) {
+	out := execute(t, graphql.Request{Query: `{
+		users { name address { city } }
+		products { name }
+	}`})
+	assert.JSONEq(t, `{"data":{
+		"users":[{"name":"Alice","address":{"city":"Oslo"}},{"name":"Bob","address":null}],
+		"products":[{"name":"Laptop"}]
+	}}`, out)
+}
+
+func TestExecute_VariablesAliasesFragmentsDirectives(t *testing.T) {
+	out := execute(t, graphql.Request{
+		Query: `query Pair($first: Int!, $second: Int = 2, $withEmail: Boolean!) {
+			a: user(id: $first) { ...userFields }
+			b: user(id: $second) { ...userFields email @include(if: $withEmail) }
+			c: user(id: 1) { ... on User { id } name @skip(if: true) }
+			__typename
+		}
+		fragment userFields on User { id name }`,
+		Variables: map[string]interface{}{"first": 1, "withEmail": true},
+	})
+	assert.JSONEq(t, `{"data":{
+		"a":{"id":1,"name":"Alice"},
+		"b":{"id":2,"name":"Bob","email":"bob@example.com"},
+		"c":{"id":1},
+		"__typename":"Query"
+	}}`, out)
+}
+
+func TestExecute_FieldErrorsArePartial(t *testing.T) {
+	res := testSchema(t).Execute(context.Background(), graphql.Request{Query: `{ user(id: 9) { name } users { name } panics }`})
+	data := res.Data.(map[string]interface{})
+	assert.Nil(t, data["user"])
+	assert.Len(t, data["users"], 2)
+	assert.Nil(t, data["panics"])
+	require.Len(t, res.Errors, 2)
+	paths := map[string][]interface{}{}
+	for _, e := range res.Errors {
+		paths[e.Message] = e.Path
+		assert.NotEmpty(t, e.Locations)
+	}
+	assert.Equal(t, []interface{}{"user"}, paths["user not found"])
+	assert.Equal(t, []interface{}{"panics"}, paths["boom"])
+}
+
+func TestExecute_ValidatedAgainstTheSchema(t *testing.T) {
+	for name, tc := range map[string]struct {
+		req     graphql.Request
+		message string
+	}{
+		"syntax":         {graphql.Request{Query: `{ users { name }`}, "Syntax Error"},
+		"misspelled":     {graphql.Request{Query: `{ users { nmae } }`}, `Cannot query field "nmae" on type "User"`},
+		"unknown root":   {graphql.Request{Query: `{ missing }`}, `Cannot query field "missing" on type "Query"`},
+		"argument type":  {graphql.Request{Query: `{ user(id: "one") { id } }`}, `Argument "id" has invalid value`},
+		"missing arg":    {graphql.Request{Query: `{ user { id } }`}, `argument "id" of type "Int!" is required`},
+		"within depth":   {graphql.Request{Query: `{ users { address { city } } user(id: 1) { ...deep } } fragment deep on User { address { city } }`}, ""},
+		"within depth 2": {graphql.Request{Query: `{ a: users { ...a } } fragment a on User { address { ... on Address { city } } }`}, ""},
+		"cycle":          {graphql.Request{Query: `{ users { ...a } } fragment a on User { ...b } fragment b on User { ...a }`}, "Cannot spread fragment"},
+		"scalar":         {graphql.Request{Query: `{ users { name { first } } }`}, `must not have a sub selection`},
+		"ambiguous":      {graphql.Request{Query: `query A { users { id } } query B { users { id } }`}, "Must provide operation name"},
+		"subscription":   {graphql.Request{Query: `subscription { users { id } }`}, "subscription"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res := testSchema(t).Execute(context.Background(), tc.req)
+			if tc.message == "" {
+				// Within the limit of 4
+				assert.Empty(t, res.Errors)
+				return
+			}
+			require.NotEmpty(t, res.Errors)
+			assert.Contains(t, res.Errors[0].Message, tc.message)
+			assert.Nil(t, res.Data)
+		})
+	}
+}
+
+func TestExecute_MaxDepth(t *testing.T) {
+	schema := graphql.NewSchema(2)
+	schema.Query("users", &gql.Field{Type: gql.NewList(userType)})
+	require.NoError(t, schema.Build())
+
+	res := schema.Execute(context.Background(), graphql.Request{Query: `{ users { address { city } } }`})
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0].Message, "maximum depth of 2")
+	res = schema.Execute(context.Background(), graphql.Request{Query: `{ users { ...f } } fragment f on User { address { ... on Address { city } } }`})
+	require.Len(t, res.Errors, 1, "fragments and inline fragments count where they are spread")
+	res = schema.Execute(context.Background(), graphql.Request{Query: `{ users { address { __typename } } }`})
+	assert.Empty(t, res.Errors)
+
+	// Introspection is deeper than any sensible limit, but not counted
+	res = schema.Execute(context.Background(), graphql.Request{Query: `{ __schema { queryType { fields { name type { kind ofType { kind ofType { name } } } } } } }`})
+	require.Empty(t, res.Errors)
+	assert.Contains(t, mustJSON(t, res.Data), `"name":"users"`)
+}
+
+func TestExecute_Mutations(t *testing.T) {
+	out := execute(t, graphql.Request{
+		Query:     `mutation Rename($name: String!) { rename(name: $name) { id name } }`,
+		Variables: map[string]interface{}{"name": "Carol!"},
+	})
+	assert.JSONEq(t, `{"data":{"rename":{"id":1,"name":"Carol!"}}}`, out)
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/api/graphql", graphql.Handler(testSchema(t)))
+
+	do := func(req *http.Request) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w, body
+	}
+
+	t.Run("post", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(`{"query":"query($id: Int!) { user(id: $id) { name } }","variables":{"id":2}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Correlation-ID", "corr-123")
+		w, body := do(req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "corr-123", w.Header().Get("X-Correlation-ID"))
+		assert.Equal(t, map[string]interface{}{"user": map[string]interface{}{"name": "Bob"}}, body["data"])
+		assert.Equal(t, "corr-123", body["extensions"].(map[string]interface{})["correlation_id"])
+	})
+
+	t.Run("get", func(t *testing.T) {
+		w, body := do(httptest.NewRequest(http.MethodGet, "/api/graphql?query="+url.QueryEscape("{ products { name } }"), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotNil(t, body["data"])
+		assert.NotEmpty(t, w.Header().Get("X-Correlation-ID"))
+	})
+
+	t.Run("get mutation", func(t *testing.T) {
+		w, _ := do(httptest.NewRequest(http.MethodGet, "/api/graphql?query="+url.QueryEscape(`mutation { rename(name: "x") { id } }`), nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(`{"query":"{ users { nmae } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w, body := do(req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NotEmpty(t, body["errors"])
+		assert.Nil(t, body["data"])
+	})
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(out)
+}
@@ -0,0 +1,115 @@
+package grpcserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/grpcserver"
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const secret = "grpc-test-secret-of-at-least-32-chars"
+
+// whoamiService returns the username of the caller, without generated code.
+type whoamiService struct{}
+
+func (whoamiService) Name() string { return "Whoami" }
+
+func (whoamiService) RegisterGRPC(s grpc.ServiceRegistrar) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Whoami",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Get",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req any) (any, error) {
+					if claims := grpcserver.Claims(ctx); claims != nil {
+						return wrapperspb.String(claims.Username), nil
+					}
+					return wrapperspb.String(""), nil
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Whoami/Get"}, handler)
+			},
+		}},
+	}, struct{}{})
+}
+
+func init() {
+	registry.RegisterGRPCService("whoami", func(*config.Config, *logger.Logger, *registry.Dependencies) interfaces.GRPCService {
+		return whoamiService{}
+	})
+}
+
+func startServer(t *testing.T) (*grpcserver.Server, *grpc.ClientConn) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.GRPC = config.GRPCConfig{Enabled: true, Port: "0", Reflection: true}
+	cfg.Auth = config.AuthConfig{Type: "jwt", Secret: secret}
+
+	srv := grpcserver.New(cfg, logger.New(false, nil), registry.NewDependencies(), nil)
+	require.NoError(t, srv.Start())
+	conn, err := grpc.NewClient(srv.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv, conn
+}
+
+func TestServer_HealthAndAuth(t *testing.T) {
+	srv, conn := startServer(t)
+	ctx := t.Context()
+
+	// Health checks need no token
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "test.Whoami"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	out := new(wrapperspb.StringValue)
+	err = conn.Invoke(ctx, "/test.Whoami/Get", &emptypb.Empty{}, out)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	token, err := middleware.GenerateToken("1", "alice", "alice@example.com", "user", secret, time.Minute)
+	require.NoError(t, err)
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	require.NoError(t, conn.Invoke(authCtx, "/test.Whoami/Get", &emptypb.Empty{}, out))
+	assert.Equal(t, "alice", out.Value)
+
+	methods := srv.Stats().Methods()
+	var whoami grpcserver.MethodStats
+	for _, m := range methods {
+		if m.Method == "/test.Whoami/Get" {
+			whoami = m
+		}
+	}
+	assert.EqualValues(t, 2, whoami.Calls)
+	assert.EqualValues(t, 1, whoami.Errors)
+	assert.Equal(t, map[string]int{"OK": 1, "Unauthenticated": 1}, whoami.Codes)
+
+	st := srv.Status()
+	assert.Equal(t, true, st["serving"])
+	assert.Equal(t, []string{"test.Whoami"}, st["services"])
+}
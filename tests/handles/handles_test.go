@@ -0,0 +1,153 @@
+package handles_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/pkg/handles"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func serve(t *testing.T, mw gin.HandlerFunc, path, target string, header map[string]string, fn func(c *gin.Context)) {
+	t.Helper()
+	r := gin.New()
+	r.Use(mw)
+	r.GET(path, func(c *gin.Context) {
+		fn(c)
+		c.Status(http.StatusNoContent)
+	})
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestMiddleware_ResolvesTenant(t *testing.T) {
+	opts := handles.Options{ObjectPrefix: "tenants/{tenant}/"}
+	mw := handles.Middleware(registry.NewDependencies(), opts)
+
+	serve(t, mw, "/orders/:tenant", "/orders/acme", nil, func(c *gin.Context) {
+		h := handles.From(c)
+		assert.Equal(t, "acme", h.Tenant)
+		assert.Equal(t, "tenants/acme/invoice.pdf", h.ObjectKey("invoice.pdf"))
+		assert.Equal(t, "tenant:acme:orders", h.CacheKey("orders"))
+		assert.Equal(t, "acme", tenancy.FromContext(c.Request.Context()))
+	})
+
+	serve(t, mw, "/orders", "/orders", map[string]string{tenancy.HeaderTenantID: "globex"}, func(c *gin.Context) {
+		assert.Equal(t, "globex", handles.From(c).Tenant)
+	})
+
+	serve(t, mw, "/orders", "/orders", nil, func(c *gin.Context) {
+		h := handles.From(c)
+		assert.Empty(t, h.Tenant)
+		assert.Equal(t, "orders", h.CacheKey("orders"))
+		assert.Equal(t, "invoice.pdf", h.ObjectKey("invoice.pdf"))
+	})
+}
+
+func TestAccessors_MissingComponents(t *testing.T) {
+	serve(t, handles.Middleware(registry.NewDependencies(), handles.Options{}), "/x", "/x", nil, func(c *gin.Context) {
+		_, ok := handles.DB(c)
+		assert.False(t, ok)
+		_, ok = handles.Mongo(c)
+		assert.False(t, ok)
+		_, ok = handles.Cache(c)
+		assert.False(t, ok)
+		_, ok = handles.Storage(c)
+		assert.False(t, ok)
+	})
+
+	// Without the middleware the accessors report nothing rather than panic
+	serve(t, func(c *gin.Context) { c.Next() }, "/x", "/x", nil, func(c *gin.Context) {
+		assert.NotNil(t, handles.From(c))
+		_, ok := handles.DB(c)
+		assert.False(t, ok)
+	})
+}
+
+func TestResolve_Cache(t *testing.T) {
+	deps := registry.NewDependencies()
+	redis := &infrastructure.RedisManager{}
+	deps.Set("redis", redis)
+
+	h := handles.Resolve(deps, "acme", handles.Options{})
+	assert.Same(t, redis, h.Cache)
+	assert.Nil(t, h.DB)
+	assert.Nil(t, h.Storage)
+}
+
+func TestStatic_SwapsHandles(t *testing.T) {
+	bucket := &infrastructure.StorageBucket{Name: "fake", Connected: true}
+	pg := &infrastructure.PostgresManager{}
+	fake := &handles.Handles{Tenant: "test", DB: pg, Storage: bucket, ObjectPrefix: "t/"}
+
+	serve(t, handles.Static(fake), "/x", "/x", nil, func(c *gin.Context) {
+		db, ok := handles.DB(c)
+		require.True(t, ok)
+		assert.Same(t, pg, db)
+		got, ok := handles.Storage(c)
+		require.True(t, ok)
+		assert.Same(t, bucket, got)
+		assert.Equal(t, "t/a", handles.From(c).ObjectKey("a"))
+		assert.Equal(t, "test", tenancy.FromContext(c.Request.Context()))
+	})
+}
+
+func TestTenantDB(t *testing.T) {
+	tenants := tenancy.NewRegistry(tenancy.Tenant{ID: "acme", Postgres: "acme_pg"})
+	mw := handles.Middleware(registry.NewDependencies(), handles.Options{Tenants: tenants})
+
+	serve(t, mw, "/orders", "/orders", nil, func(c *gin.Context) {
+		_, err := handles.TenantDB(c)
+		assert.ErrorIs(t, err, handles.ErrNoTenant)
+		_, err = handles.TenantMongo(c)
+		assert.ErrorIs(t, err, handles.ErrNoTenant)
+	})
+	serve(t, mw, "/orders/:tenant", "/orders/acme", nil, func(c *gin.Context) {
+		assert.Equal(t, "acme", handles.Tenant(c))
+		_, err := handles.TenantDB(c)
+		assert.ErrorIs(t, err, handles.ErrTenantNotConnected)
+		_, err = handles.TenantMongo(c)
+		assert.ErrorIs(t, err, handles.ErrTenantNotConnected)
+	})
+
+	// Static handles are the tenant's own
+	pg := &infrastructure.PostgresManager{}
+	serve(t, handles.Static(&handles.Handles{Tenant: "test", DB: pg}), "/x", "/x", nil, func(c *gin.Context) {
+		db, err := handles.TenantDB(c)
+		require.NoError(t, err)
+		assert.Same(t, pg, db)
+	})
+}
+
+func TestEnsure_KeepsGlobalHandles(t *testing.T) {
+	fake := &handles.Handles{Tenant: "test"}
+	r := gin.New()
+	r.Use(handles.Static(fake))
+	r.GET("/orders/:tenant", handles.Ensure(registry.NewDependencies(), handles.Options{}), func(c *gin.Context) {
+		assert.Same(t, fake, handles.From(c))
+		c.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/acme", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	serve(t, handles.Ensure(registry.NewDependencies(), handles.Options{}), "/orders/:tenant", "/orders/acme", nil, func(c *gin.Context) {
+		assert.Equal(t, "acme", handles.Tenant(c))
+	})
+}
@@ -0,0 +1,98 @@
+package i18n_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/i18n"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings(t *testing.T) {
+	settings, err := i18n.NewSettings([]string{"en-us", "de-DE", "en-US"}, i18n.Preferences{Timezone: "Europe/Berlin"})
+	require.NoError(t, err)
+
+	locales := settings.Locales()
+	require.Len(t, locales, 2, "codes are canonicalized and deduplicated")
+	assert.Equal(t, "en-US", locales[0].Code)
+	assert.Equal(t, "German (Germany)", locales[1].EnglishName)
+	assert.Equal(t, "Deutsch (Deutschland)", locales[1].Name)
+	assert.Equal(t, i18n.Preferences{Locale: "en-US", Timezone: "Europe/Berlin"}, settings.Defaults())
+
+	_, err = settings.Validate(i18n.Preferences{Locale: "fr-FR", Timezone: "UTC"})
+	assert.ErrorContains(t, err, "not offered")
+	_, err = settings.Validate(i18n.Preferences{Locale: "de-DE", Timezone: "Mars/Olympus"})
+	assert.ErrorContains(t, err, "unknown timezone")
+
+	_, err = i18n.NewSettings([]string{"en-US"}, i18n.Preferences{Locale: "ja-JP"})
+	assert.Error(t, err, "the default locale must be offered")
+
+	all, err := i18n.NewSettings(nil, i18n.Preferences{})
+	require.NoError(t, err)
+	assert.Len(t, all.Locales(), len(i18n.DefaultLocales))
+	assert.Equal(t, "UTC", all.Defaults().Timezone)
+}
+
+func TestStore(t *testing.T) {
+	settings, err := i18n.NewSettings([]string{"en-US", "de-DE", "ja-JP"}, i18n.Preferences{})
+	require.NoError(t, err)
+	embedded, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { embedded.Close() })
+
+	for name, store := range map[string]*i18n.Store{
+		"memory":   i18n.NewStore(settings, nil),
+		"embedded": i18n.NewStore(settings, embedded),
+	} {
+		t.Run(name, func(t *testing.T) {
+			prefs, saved, err := store.Get("alice")
+			require.NoError(t, err)
+			assert.False(t, saved)
+			assert.Equal(t, settings.Defaults(), prefs)
+
+			prefs, err = store.Set("alice", i18n.Preferences{Timezone: "Asia/Tokyo"})
+			require.NoError(t, err)
+			assert.Equal(t, i18n.Preferences{Locale: "en-US", Timezone: "Asia/Tokyo"}, prefs)
+
+			// Partial updates keep the other field
+			_, err = store.Set("alice", i18n.Preferences{Locale: "ja-jp"})
+			require.NoError(t, err)
+			prefs, saved, err = store.Get("alice")
+			require.NoError(t, err)
+			assert.True(t, saved)
+			assert.Equal(t, i18n.Preferences{Locale: "ja-JP", Timezone: "Asia/Tokyo"}, prefs)
+
+			_, err = store.Set("alice", i18n.Preferences{Locale: "xx-invalid-"})
+			assert.Error(t, err)
+
+			other, _, _ := store.Get("bob")
+			assert.Equal(t, settings.Defaults(), other)
+
+			require.NoError(t, store.Reset("alice"))
+			prefs, saved, _ = store.Get("alice")
+			assert.False(t, saved)
+			assert.Equal(t, settings.Defaults(), prefs)
+		})
+	}
+}
+
+// Saved locales that are no longer offered fall back to the default
+func TestStore_LocaleNoLongerOffered(t *testing.T) {
+	embedded, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { embedded.Close() })
+
+	before, _ := i18n.NewSettings([]string{"en-US", "de-DE"}, i18n.Preferences{})
+	_, err = i18n.NewStore(before, embedded).Set("alice", i18n.Preferences{Locale: "de-DE", Timezone: "Europe/Berlin"})
+	require.NoError(t, err)
+
+	after, _ := i18n.NewSettings([]string{"en-US"}, i18n.Preferences{})
+	prefs, saved, err := i18n.NewStore(after, embedded).Get("alice")
+	require.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, i18n.Preferences{Locale: "en-US", Timezone: "Europe/Berlin"}, prefs)
+}
@@ -0,0 +1,70 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool_OriginAccounting(t *testing.T) {
+	pool := infrastructure.NewNamedWorkerPool("test-origins", 2)
+	pool.Start()
+
+	var wg sync.WaitGroup
+	wg.Add(6)
+	for i := 0; i < 3; i++ {
+		pool.SubmitTagged("cron:cleanup", func() error {
+			defer wg.Done()
+			return nil
+		})
+	}
+	pool.SubmitTagged("handler:upload", func() error {
+		defer wg.Done()
+		return errors.New("disk full")
+	})
+	pool.SubmitTagged("handler:upload", func() error {
+		defer wg.Done()
+		panic("boom")
+	})
+	pool.Submit(func() { wg.Done() })
+	wg.Wait()
+
+	var status infrastructure.PoolStatus
+	for _, p := range infrastructure.WorkerPools() {
+		if p.Name == "test-origins" {
+			status = p
+		}
+	}
+	require.Equal(t, 2, status.Workers)
+
+	// Counters are updated right after the job returns
+	require.Eventually(t, func() bool {
+		status = pool.Stats()
+		return status.Busy == 0
+	}, time.Second, 10*time.Millisecond)
+
+	origins := make(map[string]infrastructure.OriginStats)
+	for _, o := range status.Origins {
+		origins[o.Origin] = o
+	}
+	assert.Equal(t, "cron:cleanup", status.Origins[0].Origin, "busiest origin first")
+	assert.Equal(t, int64(3), origins["cron:cleanup"].Completed)
+	assert.Equal(t, int64(3), origins["cron:cleanup"].PerMinute)
+
+	upload := origins["handler:upload"]
+	assert.Equal(t, int64(2), upload.Failed, "errors and panics are failures")
+	assert.Equal(t, float64(100), upload.FailureRate)
+	assert.Contains(t, upload.LastError, "boom")
+	assert.Equal(t, int64(1), origins[infrastructure.UntaggedOrigin].Completed)
+
+	pool.Stop()
+	for _, p := range infrastructure.WorkerPools() {
+		assert.NotEqual(t, "test-origins", p.Name, "stopped pools are no longer listed")
+	}
+}
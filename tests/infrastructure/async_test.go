@@ -0,0 +1,106 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockUntilCancelled is an operation that only returns once its context
+// is cancelled, reporting that on stopped.
+func blockUntilCancelled(stopped chan<- error) infrastructure.AsyncOperation[int] {
+	return func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return 0, ctx.Err()
+	}
+}
+
+func requireStopped(t *testing.T, stopped <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-stopped:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("operation kept running")
+		return nil
+	}
+}
+
+func TestAsyncResult_WaitWithTimeoutCancels(t *testing.T) {
+	stopped := make(chan error, 1)
+	result := infrastructure.ExecuteAsync(context.Background(), blockUntilCancelled(stopped))
+
+	_, err := result.WaitWithTimeout(20 * time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, requireStopped(t, stopped), context.Canceled)
+
+	_, err = result.Wait()
+	assert.ErrorIs(t, err, context.Canceled, "the operation completes with its own error")
+}
+
+func TestAsyncResult_WaitContext(t *testing.T) {
+	stopped := make(chan error, 1)
+	result := infrastructure.ExecuteAsync(context.Background(), blockUntilCancelled(stopped))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := result.WaitContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	requireStopped(t, stopped)
+
+	done := infrastructure.ExecuteAsync(context.Background(), func(context.Context) (int, error) { return 7, nil })
+	value, err := done.WaitContext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 7, value)
+}
+
+func TestAsyncResult_AbandonedResults(t *testing.T) {
+	// A result nobody waits for stops with the caller's context...
+	stopped := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	infrastructure.ExecuteAsync(ctx, blockUntilCancelled(stopped))
+	cancel()
+	assert.ErrorIs(t, requireStopped(t, stopped), context.Canceled)
+
+	// ...or at the deadline it was given
+	infrastructure.ExecuteAsyncWithTimeout(context.Background(), 20*time.Millisecond, blockUntilCancelled(stopped))
+	assert.ErrorIs(t, requireStopped(t, stopped), context.DeadlineExceeded)
+
+	// Cancel is explicit
+	result := infrastructure.ExecuteAsync(context.Background(), blockUntilCancelled(stopped))
+	result.Cancel()
+	requireStopped(t, stopped)
+	<-result.Done
+	result.Cancel() // no-op once completed
+}
+
+func TestBatchAsyncResult_Cancel(t *testing.T) {
+	var ran atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ops := make([]infrastructure.AsyncOperation[int], 5)
+	for i := range ops {
+		ops[i] = func(ctx context.Context) (int, error) {
+			ran.Add(1)
+			cancel()
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+	}
+	// One at a time: the first operation cancels, the rest never run
+	result := infrastructure.ExecuteBatchAsync(ctx, ops, 1)
+	_, errs, err := result.WaitAllContext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), ran.Load())
+	for _, err := range errs {
+		assert.True(t, errors.Is(err, context.Canceled))
+	}
+}
@@ -0,0 +1,159 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGrafana stores dashboards and datasources in memory.
+type fakeGrafana struct {
+	mu          sync.Mutex
+	dashboards  map[string]map[string]interface{}
+	datasources map[string]map[string]interface{}
+	saves       int
+}
+
+func newFakeGrafana() *fakeGrafana {
+	return &fakeGrafana{
+		dashboards:  map[string]map[string]interface{}{},
+		datasources: map[string]map[string]interface{}{},
+	}
+}
+
+func (f *fakeGrafana) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/dashboards/uid/"):
+		d, ok := f.dashboards[strings.TrimPrefix(r.URL.Path, "/api/dashboards/uid/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"dashboard": d})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/dashboards/db":
+		var payload struct {
+			Dashboard map[string]interface{} `json:"dashboard"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		f.dashboards[payload.Dashboard["uid"].(string)] = payload.Dashboard
+		f.saves++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success"})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/datasources/name/"):
+		ds, ok := f.datasources[strings.TrimPrefix(r.URL.Path, "/api/datasources/name/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(ds)
+	case r.URL.Path == "/api/datasources" || strings.HasPrefix(r.URL.Path, "/api/datasources/"):
+		var ds map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&ds)
+		ds["id"] = 1
+		ds["uid"] = "ds-1"
+		f.datasources[ds["name"].(string)] = ds
+		f.saves++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"datasource": ds})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGrafanaProvisioning_Idempotent(t *testing.T) {
+	fake := newFakeGrafana()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := config.GrafanaConfig{
+		Enabled: true,
+		URL:     srv.URL,
+		Provisioning: config.GrafanaProvisioningConfig{
+			Enabled: true,
+			Datasources: []config.GrafanaProvisionItem{
+				{Name: "Prometheus", Inline: "type: prometheus\nurl: http://prometheus:9090\naccess: proxy\n"},
+			},
+			Dashboards: []config.GrafanaProvisionItem{
+				{Name: "App Overview", Inline: `{"title": "App Overview", "panels": []}`},
+			},
+		},
+	}
+
+	report, err := infrastructure.ProvisionGrafana(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, "app-overview", report.Results[1].UID)
+
+	// Second run with identical definitions changes nothing
+	report, err = infrastructure.ProvisionGrafana(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Unchanged)
+	assert.Equal(t, 2, fake.saves)
+
+	// Changed definitions are updated in place
+	cfg.Provisioning.Datasources[0].Inline = "type: prometheus\nurl: http://prom:9090\naccess: proxy\n"
+	cfg.Provisioning.Dashboards[0].Inline = `{"title": "App Overview", "refresh": "10s"}`
+	report, err = infrastructure.ProvisionGrafana(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Updated)
+	assert.Same(t, report, infrastructure.LastGrafanaProvisionReport())
+}
+
+func TestGrafanaProvisioning_NonASCIITitle(t *testing.T) {
+	fake := newFakeGrafana()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := config.GrafanaConfig{
+		Enabled: true,
+		URL:     srv.URL,
+		Provisioning: config.GrafanaProvisioningConfig{
+			Enabled: true,
+			Dashboards: []config.GrafanaProvisionItem{
+				{Name: "概要", Inline: `{"title": "概要"}`},
+				{Name: "Обзор", Inline: `{"title": "Обзор"}`},
+			},
+		},
+	}
+
+	report, err := infrastructure.ProvisionGrafana(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	uid := report.Results[0].UID
+	assert.Regexp(t, `^dashboard-[0-9a-f]{12}$`, uid)
+	assert.NotEqual(t, uid, report.Results[1].UID)
+
+	// The hashed UID is stable, so the next run finds the dashboard
+	report, err = infrastructure.ProvisionGrafana(context.Background(), cfg, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Unchanged)
+	assert.Equal(t, uid, report.Results[0].UID)
+}
+
+func TestGrafanaProvisioning_ReportsFailures(t *testing.T) {
+	srv := httptest.NewServer(newFakeGrafana())
+	defer srv.Close()
+
+	report, err := infrastructure.ProvisionGrafana(context.Background(), config.GrafanaConfig{
+		URL: srv.URL,
+		Provisioning: config.GrafanaProvisioningConfig{
+			Dashboards:  []config.GrafanaProvisionItem{{Name: "missing", File: "does-not-exist.json"}},
+			Datasources: []config.GrafanaProvisionItem{{Name: "untyped", Inline: "url: http://x"}},
+		},
+	}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, infrastructure.ProvisionFailed, report.Results[0].Action)
+	assert.Contains(t, report.Results[0].Error, "type is required")
+}
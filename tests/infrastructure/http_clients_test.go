@@ -0,0 +1,85 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_Requests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/missing" {
+			http.Error(w, "no such thing", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"path":   r.URL.RequestURI(),
+			"auth":   r.Header.Get("Authorization"),
+			"client": r.Header.Get("X-Client"),
+		})
+	}))
+	defer upstream.Close()
+
+	client, err := infrastructure.NewHTTPClient("payments", config.HTTPClientConfig{
+		BaseURL: upstream.URL + "/api/",
+		Auth:    config.HTTPClientAuth{Type: infrastructure.HTTPAuthBearer, Token: "t0k"},
+		Headers: map[string]string{"X-Client": "stackyrd"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, upstream.URL+"/api/charges?limit=1", client.URL("/charges?limit=1"))
+	assert.Equal(t, "http://other/x", client.URL("http://other/x"))
+
+	var got map[string]string
+	require.NoError(t, client.DoJSON(context.Background(), http.MethodGet, "charges?limit=1", nil, &got))
+	assert.Equal(t, "/api/charges?limit=1", got["path"])
+	assert.Equal(t, "Bearer t0k", got["auth"])
+	assert.Equal(t, "stackyrd", got["client"])
+
+	err = client.DoJSON(context.Background(), http.MethodGet, "missing", nil, nil)
+	var statusErr *infrastructure.HTTPStatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.Status)
+	assert.Contains(t, statusErr.Body, "no such thing")
+}
+
+func TestHTTPClientManager(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		w.Header().Set("X-Seen", user+":"+pass+"|"+r.Header.Get("X-API-Key"))
+	}))
+	defer upstream.Close()
+
+	manager, err := infrastructure.NewHTTPClientManager(config.HTTPClientsConfig{
+		"basic":  {BaseURL: upstream.URL, Auth: config.HTTPClientAuth{Type: infrastructure.HTTPAuthBasic, Username: "u", Password: "p"}},
+		"header": {BaseURL: upstream.URL, Auth: config.HTTPClientAuth{Type: infrastructure.HTTPAuthHeader, Header: "X-API-Key", Token: "k"}},
+	})
+	require.NoError(t, err)
+	defer manager.Close()
+	assert.Equal(t, []string{"basic", "header"}, manager.Names())
+
+	for name, seen := range map[string]string{"basic": "u:p|", "header": ":|k"} {
+		client, ok := manager.Client(name)
+		require.True(t, ok)
+		resp, err := client.Get(context.Background(), "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, seen, resp.Header.Get("X-Seen"), name)
+	}
+	_, ok := manager.Client("unknown")
+	assert.False(t, ok)
+	assert.Equal(t, 2, manager.GetStatus()["count"])
+
+	_, err = infrastructure.NewHTTPClientManager(config.HTTPClientsConfig{"bad": {Auth: config.HTTPClientAuth{Type: "oauth"}}})
+	assert.ErrorContains(t, err, "unknown auth type")
+	_, err = infrastructure.NewHTTPClientManager(config.HTTPClientsConfig{"bad": {BaseURL: "not a url"}})
+	assert.ErrorContains(t, err, "invalid base_url")
+}
@@ -0,0 +1,122 @@
+package infrastructure_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPManager_Check(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			http.Error(w, "boom", http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+	manager := infrastructure.NewHTTPManager(config.ExternalConfig{}, nil)
+	ctx := context.Background()
+
+	check := manager.Check(ctx, config.ExternalService{Name: "api", URL: upstream.URL + "/health", ExpectedStatus: []int{200}, ExpectedBody: `"ok"`})
+	assert.True(t, check.Up, check.Error)
+	assert.Equal(t, 200, check.StatusCode)
+
+	// Without expected statuses only 5xx fail
+	assert.True(t, manager.Check(ctx, config.ExternalService{URL: upstream.URL + "/missing"}).Up)
+	assert.False(t, manager.Check(ctx, config.ExternalService{URL: upstream.URL + "/down"}).Up)
+	check = manager.Check(ctx, config.ExternalService{URL: upstream.URL + "/missing", ExpectedStatus: []int{200}})
+	assert.False(t, check.Up)
+	assert.Contains(t, check.Error, "status 404")
+
+	check = manager.Check(ctx, config.ExternalService{URL: upstream.URL + "/health", ExpectedBody: "healthy"})
+	assert.False(t, check.Up)
+	assert.Contains(t, check.Error, "body does not contain")
+
+	// Plain HTTP has no certificate to check
+	check = manager.Check(ctx, config.ExternalService{URL: upstream.URL + "/health", CheckCertificate: true})
+	assert.False(t, check.Up)
+}
+
+func TestHTTPManager_Certificate(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	manager := infrastructure.NewHTTPManager(config.ExternalConfig{}, nil)
+	manager.Client = upstream.Client()
+
+	check := manager.Check(context.Background(), config.ExternalService{URL: upstream.URL, CheckCertificate: true})
+	require.True(t, check.Up, check.Error)
+	require.NotNil(t, check.CertExpiresAt)
+	assert.Equal(t, upstream.Certificate().NotAfter, *check.CertExpiresAt)
+}
+
+func TestHTTPManager_History(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	manager := infrastructure.NewHTTPManager(config.ExternalConfig{
+		Interval: 3600,
+		Services: []config.ExternalService{
+			{Name: "api", URL: upstream.URL},
+			{Name: "gone", URL: "http://127.0.0.1:1/"},
+		},
+	}, nil)
+	start := time.Now()
+	manager.Start()
+	require.Eventually(t, func() bool {
+		statuses := manager.Statuses()
+		return statuses[0].Checks == 1 && statuses[1].Checks == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, manager.Close())
+
+	statuses := manager.Statuses()
+	assert.Equal(t, float64(100), statuses[0].Uptime)
+	assert.Equal(t, float64(3600), statuses[0].Interval)
+	assert.Equal(t, float64(0), statuses[1].Uptime)
+	assert.Equal(t, []string{"gone"}, manager.GetStatus()["down"])
+
+	checks, ok := manager.History("api", start)
+	require.True(t, ok)
+	assert.Len(t, checks, 1)
+	checks, _ = manager.History("api", time.Now().Add(time.Minute))
+	assert.Empty(t, checks)
+	_, ok = manager.History("unknown", time.Time{})
+	assert.False(t, ok)
+}
+
+func TestHTTPManager_Breaker(t *testing.T) {
+	var calls atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+	svc := config.ExternalService{Name: "api", URL: upstream.URL}
+	manager := infrastructure.NewHTTPManager(config.ExternalConfig{
+		Services:   []config.ExternalService{svc},
+		Resilience: config.ResilienceConfig{MaxFailures: 2, ResetTimeout: 60, RetryAttempts: 2, RetryDelay: 1},
+	}, nil)
+
+	check := manager.Check(context.Background(), svc)
+	assert.False(t, check.Up)
+	assert.Equal(t, int64(2), calls.Load(), "retried once")
+
+	// The breaker is open: no request until the reset timeout
+	check = manager.Check(context.Background(), svc)
+	assert.Contains(t, check.Error, "circuit breaker is open")
+	assert.Equal(t, int64(2), calls.Load())
+	assert.Equal(t, "open", manager.Statuses()[0].Breaker)
+	assert.Contains(t, manager.GetStatus(), "resilience")
+}
@@ -0,0 +1,97 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// claimSession is a consumer group session over one partition that
+// records marked and reset offsets. Ending a claim cancels it, like sarama
+// does.
+type claimSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	marked []int64
+	reset  int64
+}
+
+func newClaimSession() *claimSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &claimSession{ctx: ctx, cancel: cancel, reset: -1}
+}
+
+func (s *claimSession) Context() context.Context { return s.ctx }
+
+func (s *claimSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *claimSession) ResetOffset(_ string, _ int32, offset int64, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset = offset
+	s.cancel()
+}
+
+// partitionClaim hands out the messages of a partition from an offset.
+type partitionClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func newPartitionClaim(values []string, from int64) *partitionClaim {
+	c := &partitionClaim{messages: make(chan *sarama.ConsumerMessage, len(values))}
+	for offset := from; offset < int64(len(values)); offset++ {
+		c.messages <- &sarama.ConsumerMessage{Topic: "orders", Partition: 0, Offset: offset, Value: []byte(values[offset])}
+	}
+	close(c.messages)
+	return c
+}
+
+func (c *partitionClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestKafkaClaimHandler_RequeueRedelivers(t *testing.T) {
+	values := []string{"first", "second", "third"}
+	var delivered []string
+	failed := false
+	handler := infrastructure.KafkaClaimHandler(context.Background(), messaging.SubscribeOptions{}, func(ctx context.Context, d *messaging.Delivery) error {
+		delivered = append(delivered, string(d.Value))
+		if string(d.Value) == "second" && !failed {
+			failed = true
+			return errors.New("not yet")
+		}
+		return nil
+	}, logger.NewQuiet(false, io.Discard))
+
+	first := newClaimSession()
+	start := time.Now()
+	require.NoError(t, handler.ConsumeClaim(first, newPartitionClaim(values, 0)))
+	assert.Less(t, time.Since(start), time.Second, "a cancelled session does not wait out the redelivery delay")
+	assert.Equal(t, []int64{0}, first.marked, "nothing past the nacked message is marked")
+	assert.Equal(t, int64(1), first.reset, "the offset is reset to the nacked message")
+	assert.Equal(t, []string{"first", "second"}, delivered, "the claim ends at the nacked message")
+
+	// The next session starts the partition at the reset offset
+	second := newClaimSession()
+	require.NoError(t, handler.ConsumeClaim(second, newPartitionClaim(values, first.reset)))
+	assert.Equal(t, []int64{1, 2}, second.marked)
+	assert.Equal(t, int64(-1), second.reset)
+	assert.Equal(t, []string{"first", "second", "second", "third"}, delivered)
+}
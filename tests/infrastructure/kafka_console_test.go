@@ -0,0 +1,104 @@
+package infrastructure_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startKafka(t *testing.T) (*sarama.MockBroker, *infrastructure.KafkaManager) {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("orders", 0, broker.BrokerID()).
+			SetLeader("orders", 1, broker.BrokerID()).
+			SetLeader("__consumer_offsets", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("orders", 0, sarama.OffsetOldest, 0).
+			SetOffset("orders", 0, sarama.OffsetNewest, 3).
+			SetOffset("orders", 1, sarama.OffsetOldest, 5).
+			SetOffset("orders", 1, sarama.OffsetNewest, 6),
+		"FetchRequest": sarama.NewMockFetchResponse(t, 1).
+			SetMessage("orders", 0, 0, sarama.StringEncoder(`{"id":1}`)).
+			SetMessageWithKey("orders", 0, 1, sarama.StringEncoder("order-2"), sarama.StringEncoder(`{"id":2}`)).
+			SetMessage("orders", 0, 2, sarama.ByteEncoder([]byte{0xff, 0x00})).
+			SetMessage("orders", 1, 5, sarama.StringEncoder("plain text")).
+			SetHighWaterMark("orders", 0, 3).
+			SetHighWaterMark("orders", 1, 6),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+	return broker, &infrastructure.KafkaManager{Brokers: []string{broker.Addr()}}
+}
+
+func TestKafkaTopics(t *testing.T) {
+	_, manager := startKafka(t)
+	topics, err := manager.Topics(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []infrastructure.KafkaTopic{{Name: "orders", Partitions: 2}}, topics)
+}
+
+func TestKafkaLastMessages(t *testing.T) {
+	_, manager := startKafka(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := manager.LastMessages(ctx, "orders", 0, 2, infrastructure.KafkaDecodeAuto)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	// Newest first; bytes that are no text come back as base64
+	assert.Equal(t, int64(2), messages[0].Offset)
+	assert.Equal(t, "/wA=", messages[0].Value)
+	assert.Equal(t, infrastructure.KafkaDecodeBase64, messages[0].Encoding)
+	assert.Equal(t, int64(1), messages[1].Offset)
+	assert.Equal(t, "order-2", messages[1].Key)
+	assert.Equal(t, map[string]interface{}{"id": float64(2)}, messages[1].Value)
+
+	// Every partition, each read from its own newest offsets
+	messages, err = manager.LastMessages(ctx, "orders", -1, 10, infrastructure.KafkaDecodeString)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Contains(t, messages, infrastructure.KafkaMessage{Topic: "orders", Partition: 1, Offset: 5, Timestamp: messages[3].Timestamp, Value: "plain text", Encoding: "string", Size: 10})
+
+	_, err = manager.LastMessages(ctx, "orders", 7, 10, infrastructure.KafkaDecodeAuto)
+	assert.ErrorIs(t, err, infrastructure.ErrUnknownTopic)
+}
+
+func TestKafkaPublishTest(t *testing.T) {
+	broker, manager := startKafka(t)
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer([]string{broker.Addr()}, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { producer.Close() })
+	manager.Producer = producer
+
+	partition, _, err := manager.PublishTest(context.Background(), "orders", []byte("k"), []byte(`{"id":3}`), map[string]string{"source": "console"})
+	require.NoError(t, err)
+	assert.Contains(t, []int32{0, 1}, partition)
+}
+
+func TestDecodeKafkaPayload(t *testing.T) {
+	v, enc := infrastructure.DecodeKafkaPayload([]byte(`[1,2]`), infrastructure.KafkaDecodeAuto)
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, v)
+	assert.Equal(t, "json", enc)
+
+	// Scalars are JSON only when asked for
+	v, enc = infrastructure.DecodeKafkaPayload([]byte(`42`), infrastructure.KafkaDecodeAuto)
+	assert.Equal(t, "42", v)
+	assert.Equal(t, "string", enc)
+	v, _ = infrastructure.DecodeKafkaPayload([]byte(`42`), infrastructure.KafkaDecodeJSON)
+	assert.Equal(t, float64(42), v)
+
+	v, enc = infrastructure.DecodeKafkaPayload([]byte(`{"a":1}`), infrastructure.KafkaDecodeBase64)
+	assert.Equal(t, "eyJhIjoxfQ==", v)
+	assert.Equal(t, "base64", enc)
+}
@@ -0,0 +1,229 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpMessage is a message received by fakeSMTP.
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP is a plain SMTP server accepting every message, rejecting
+// recipients at reject.example.com.
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			msg = smtpMessage{From: strings.Trim(cmd[10:], "<> ")}
+			reply("250 ok")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			rcpt := strings.Trim(cmd[8:], "<> ")
+			if strings.HasSuffix(rcpt, "@reject.example.com") {
+				reply("550 no such user")
+				continue
+			}
+			msg.To = append(msg.To, rcpt)
+			reply("250 ok")
+		case upper == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case upper == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func newTestMailManager(t *testing.T, s *fakeSMTP, templatesDir string) *infrastructure.MailManager {
+	m, err := infrastructure.NewMailManager(config.MailConfig{
+		Host:         "127.0.0.1",
+		Port:         s.port(),
+		From:         "stackyrd <noreply@example.com>",
+		TLS:          infrastructure.MailTLSNone,
+		Timeout:      5,
+		TemplatesDir: templatesDir,
+	}, logger.New(false, nil))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMailManager_Send(t *testing.T) {
+	s := newFakeSMTP(t)
+	m := newTestMailManager(t, s, "")
+
+	require.NoError(t, m.Send(context.Background(), []string{"Ops <ops@example.com>"}, "Report ready", "All components up"))
+	got := s.received()
+	require.Len(t, got, 1)
+	assert.Equal(t, "noreply@example.com", got[0].From)
+	assert.Equal(t, []string{"ops@example.com"}, got[0].To)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got[0].Data))
+	require.NoError(t, err)
+	assert.Equal(t, "Report ready", parsed.Header.Get("Subject"))
+	assert.Equal(t, "Ops <ops@example.com>", parsed.Header.Get("To"))
+	assert.Contains(t, parsed.Header.Get("Content-Type"), "text/plain")
+	assert.NotEmpty(t, parsed.Header.Get("Message-ID"))
+
+	status := m.GetStatus()
+	assert.Equal(t, true, status["connected"])
+	assert.Equal(t, int64(1), status["sent"])
+	last := status["last_send"].(map[string]interface{})
+	assert.Equal(t, true, last["ok"])
+}
+
+func TestMailManager_FailureInStatus(t *testing.T) {
+	s := newFakeSMTP(t)
+	m := newTestMailManager(t, s, "")
+
+	err := m.Deliver(context.Background(), infrastructure.MailMessage{
+		To:      []string{"nobody@reject.example.com"},
+		Subject: "Hello",
+		Text:    "Hi",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nobody@reject.example.com")
+	assert.Empty(t, s.received())
+
+	status := m.GetStatus()
+	assert.Equal(t, false, status["connected"])
+	assert.Equal(t, int64(1), status["failed"])
+	assert.Contains(t, status["error"], "550")
+
+	assert.Error(t, m.Deliver(context.Background(), infrastructure.MailMessage{Subject: "no one"}), "no recipients")
+
+	// A later success clears the error
+	require.NoError(t, m.Send(context.Background(), []string{"ops@example.com"}, "Hello", "Hi"))
+	assert.Equal(t, true, m.GetStatus()["connected"])
+}
+
+func TestMailManager_Templates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "welcome.tmpl"), []byte(`
+{{define "subject"}}Welcome, {{.Name}}{{end}}
+{{define "text"}}Hello {{.Name}}, your account is ready.{{end}}
+{{define "html"}}<p>Hello <b>{{.Name}}</b></p>{{end}}
+`), 0o644))
+	s := newFakeSMTP(t)
+	m := newTestMailManager(t, s, dir)
+	assert.Equal(t, []string{"welcome"}, m.Templates())
+
+	msg, err := m.Render("welcome", map[string]string{"Name": "<Ada>"})
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome, <Ada>", msg.Subject)
+	assert.Equal(t, "Hello <Ada>, your account is ready.", msg.Text)
+	assert.Equal(t, "<p>Hello <b>&lt;Ada&gt;</b></p>", msg.HTML, "html is escaped")
+
+	require.NoError(t, m.SendTemplate(context.Background(), []string{"ada@example.com"}, "welcome", map[string]string{"Name": "Ada"}))
+	got := s.received()
+	require.Len(t, got, 1)
+	assert.Contains(t, got[0].Data, "multipart/alternative")
+	assert.Contains(t, got[0].Data, "Hello <b>Ada</b>")
+
+	_, err = m.Render("missing", nil)
+	assert.ErrorIs(t, err, infrastructure.ErrMailTemplateNotFound)
+	assert.Error(t, m.AddTemplate("broken", `{{define "text"}}no subject{{end}}`))
+}
+
+func TestMailManager_Config(t *testing.T) {
+	l := logger.New(false, nil)
+	_, err := infrastructure.NewMailManager(config.MailConfig{Host: "smtp.example.com"}, l)
+	assert.Error(t, err, "from is required")
+	_, err = infrastructure.NewMailManager(config.MailConfig{Host: "smtp.example.com", From: "a@example.com", TLS: "ssl"}, l)
+	assert.Error(t, err)
+
+	m, err := infrastructure.NewMailManager(config.MailConfig{Host: "smtp.example.com", From: "a@example.com", TLS: "tls"}, l)
+	require.NoError(t, err)
+	defer m.Close()
+	status := m.GetStatus()
+	assert.Equal(t, "smtp.example.com:465", status["address"])
+	assert.Equal(t, infrastructure.MailTLSImplicit, status["tls"])
+
+	// Nothing is sent once closed
+	m.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Error(t, m.Send(ctx, []string{"ops@example.com"}, "late", "late"))
+}
@@ -0,0 +1,196 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyBroker fails every publish while down is set.
+type flakyBroker struct {
+	mu        sync.Mutex
+	down      bool
+	published []string
+}
+
+func (f *flakyBroker) BrokerType() string { return "fake" }
+
+func (f *flakyBroker) PublishMessage(ctx context.Context, msg messaging.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("connection refused")
+	}
+	f.published = append(f.published, string(msg.Value))
+	return nil
+}
+
+func (f *flakyBroker) Subscribe(ctx context.Context, topic string, opts messaging.SubscribeOptions, handler messaging.Handler) (messaging.Subscription, error) {
+	return nil, nil
+}
+
+func (f *flakyBroker) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func openTestStore(t *testing.T, path string) *infrastructure.EmbeddedStore {
+	t.Helper()
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: path}, nil)
+	require.NoError(t, err)
+	return store
+}
+
+func TestBufferedBroker_BuffersAndReplaysInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store := openTestStore(t, path)
+	l := logger.NewQuiet(false, io.Discard)
+	inner := &flakyBroker{}
+	cfg := config.MessagingBufferConfig{Enabled: true, MaxMessages: 4, ReplayInterval: 3600}
+
+	b, err := infrastructure.NewBufferedBroker(inner, store, cfg, l)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("1")}))
+
+	inner.setDown(true)
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("2")}))
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("3")}))
+
+	stats := b.Stats()
+	assert.Equal(t, 2, stats["depth"])
+	assert.Contains(t, stats["last_error"], "connection refused")
+	assert.NotNil(t, stats["oldest_buffered_at"])
+
+	// Broker recovered, but queued messages must still go out first
+	inner.setDown(false)
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("4")}))
+	inner.setDown(true)
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("5")}))
+
+	// Full buffer rejects instead of dropping silently
+	err = b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("6")})
+	assert.ErrorIs(t, err, infrastructure.ErrBufferFull)
+	require.NoError(t, b.Close())
+	require.NoError(t, store.Close())
+
+	// Buffered messages survive a restart and replay in order
+	store = openTestStore(t, path)
+	defer store.Close()
+	b, err = infrastructure.NewBufferedBroker(inner, store, cfg, l)
+	require.NoError(t, err)
+	defer b.Close()
+	assert.Equal(t, 4, b.Stats()["depth"])
+
+	inner.setDown(false)
+	replayed, err := b.Replay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, replayed)
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, inner.published)
+	assert.Equal(t, 0, b.Stats()["depth"])
+}
+
+// stalledBroker holds every publish until release is closed, like a
+// broker that stopped answering.
+type stalledBroker struct {
+	flakyBroker
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *stalledBroker) PublishMessage(ctx context.Context, msg messaging.Message) error {
+	select {
+	case s.entered <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.flakyBroker.PublishMessage(ctx, msg)
+}
+
+func TestBufferedBroker_SlowBrokerDoesNotBlockPublishers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store := openTestStore(t, path)
+	defer store.Close()
+	l := logger.NewQuiet(false, io.Discard)
+	cfg := config.MessagingBufferConfig{Enabled: true, MaxMessages: 10, ReplayInterval: 3600}
+	ctx := context.Background()
+
+	down := &flakyBroker{down: true}
+	b, err := infrastructure.NewBufferedBroker(down, store, cfg, l)
+	require.NoError(t, err)
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("1")}))
+	require.NoError(t, b.Close())
+
+	inner := &stalledBroker{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	b, err = infrastructure.NewBufferedBroker(inner, store, cfg, l)
+	require.NoError(t, err)
+	defer b.Close()
+
+	replayed := make(chan int)
+	go func() {
+		n, _ := b.Replay(ctx)
+		replayed <- n
+	}()
+	<-inner.entered
+
+	// The replay waits on the broker; publishers queue behind it at once
+	published := make(chan error)
+	go func() { published <- b.PublishMessage(ctx, messaging.Message{Topic: "t", Value: []byte("2")}) }()
+	select {
+	case err := <-published:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("publish waited for the stalled broker")
+	}
+	assert.Equal(t, 2, b.Stats()["depth"])
+
+	close(inner.release)
+	assert.Equal(t, 2, <-replayed)
+	assert.Equal(t, []string{"1", "2"}, inner.published)
+}
+
+func TestEmbeddedStore_BasicOperations(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "store.db"))
+	defer store.Close()
+
+	require.NoError(t, store.PutJSON("prefs", "user:1", map[string]string{"tz": "UTC"}))
+	require.NoError(t, store.Put("prefs", "user:2", []byte(`{}`)))
+	require.NoError(t, store.Put("prefs", "team:1", []byte(`{}`)))
+
+	var prefs map[string]string
+	ok, err := store.GetJSON("prefs", "user:1", &prefs)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "UTC", prefs["tz"])
+
+	var keys []string
+	require.NoError(t, store.ForEachPrefix("prefs", "user:", func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	}))
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+	require.NoError(t, store.Delete("prefs", "user:1"))
+	_, ok, err = store.Get("prefs", "user:1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	n, err := store.Count("prefs")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, true, store.GetStatus()["connected"])
+}
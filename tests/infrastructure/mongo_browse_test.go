@@ -0,0 +1,54 @@
+package infrastructure_test
+
+import (
+	"context"
+	"testing"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMongoFilter(t *testing.T) {
+	filter, err := infrastructure.ParseMongoFilter(`{"_id": {"$oid": "5f1d7f3e9d1e8a3b2c4d5e6f"}, "status": "open"}`)
+	require.NoError(t, err)
+	assert.IsType(t, primitive.ObjectID{}, filter["_id"])
+	assert.Equal(t, "open", filter["status"])
+
+	filter, err = infrastructure.ParseMongoFilter(" ")
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = infrastructure.ParseMongoFilter(`[{"$match": {}}]`)
+	assert.ErrorIs(t, err, infrastructure.ErrInvalidBrowse)
+}
+
+func TestParseMongoSortAndProjection(t *testing.T) {
+	sort, err := infrastructure.ParseMongoSort("-created_at, name")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "created_at", Value: -1}, {Key: "name", Value: 1}}, sort)
+	_, err = infrastructure.ParseMongoSort("$natural")
+	assert.ErrorIs(t, err, infrastructure.ErrInvalidBrowse)
+
+	projection, err := infrastructure.ParseMongoProjection("name,email,-_id")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "name", Value: 1}, {Key: "email", Value: 1}, {Key: "_id", Value: 0}}, projection)
+	projection, err = infrastructure.ParseMongoProjection("-password")
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "password", Value: 0}}, projection)
+	_, err = infrastructure.ParseMongoProjection("name,-password")
+	assert.ErrorIs(t, err, infrastructure.ErrInvalidBrowse)
+}
+
+func TestBrowseCollection_RejectsServerSideJS(t *testing.T) {
+	filter, err := infrastructure.ParseMongoFilter(`{"$or": [{"a": 1}, {"$where": "sleep(100)"}]}`)
+	require.NoError(t, err)
+	// The filter is checked before the database is used
+	m := &infrastructure.MongoManager{Database: nil}
+	_, err = m.BrowseCollection(context.Background(), "orders", infrastructure.BrowseOptions{Filter: filter})
+	assert.ErrorIs(t, err, infrastructure.ErrInvalidBrowse)
+	assert.Contains(t, err.Error(), "$where")
+}
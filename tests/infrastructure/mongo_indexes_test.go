@@ -0,0 +1,68 @@
+package infrastructure_test
+
+import (
+	"context"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseIndexKeys(t *testing.T) {
+	keys, err := infrastructure.ParseIndexKeys([]string{"tenant_id", " -created_at", "body:text"})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "body", Value: "text"}}, keys)
+
+	for _, invalid := range [][]string{nil, {""}, {"-"}, {"$where"}, {"--name"}, {"name:btree"}, {"-name:text"}} {
+		_, err := infrastructure.ParseIndexKeys(invalid)
+		assert.ErrorIs(t, err, infrastructure.ErrInvalidIndex, "%q", invalid)
+	}
+}
+
+func TestMongoIndexes_ValidatedBeforeTheDatabase(t *testing.T) {
+	ctx := context.Background()
+	m := &infrastructure.MongoManager{Database: nil}
+
+	for _, spec := range []config.MongoIndexConfig{
+		{Keys: []string{"email"}},
+		{Collection: "users"},
+		{Collection: "sessions", Keys: []string{"expires_at"}, TTL: -1},
+		{Collection: "users", Keys: []string{"email"}, PartialFilter: "[1, 2]"},
+	} {
+		_, err := m.CreateIndex(ctx, spec)
+		assert.ErrorIs(t, err, infrastructure.ErrInvalidIndex, "%+v", spec)
+	}
+	_, err := m.CreateIndex(ctx, config.MongoIndexConfig{Collection: "users", Keys: []string{"email"}, Unique: true, PartialFilter: `{"deleted": false}`})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, infrastructure.ErrInvalidIndex)
+
+	assert.ErrorIs(t, m.DropIndex(ctx, "users", "_id_"), infrastructure.ErrInvalidIndex)
+	assert.ErrorIs(t, m.DropIndex(ctx, "users", "*"), infrastructure.ErrInvalidIndex)
+}
+
+func TestMongoEnsureIndexes_RecordsOutcomes(t *testing.T) {
+	m := &infrastructure.MongoManager{Database: nil}
+	statuses := m.EnsureIndexes(context.Background(), infrastructure.IndexSourceConfig,
+		config.MongoIndexConfig{Collection: "orders", Keys: []string{"tenant_id", "-created_at"}},
+		config.MongoIndexConfig{Collection: "audit", Name: "by_actor", Keys: []string{"actor"}},
+		config.MongoIndexConfig{Collection: "audit", Keys: []string{"$bad"}},
+	)
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "tenant_id_1_created_at_-1", statuses[0].Name, "unnamed indexes are named like the server does")
+	assert.Equal(t, "by_actor", statuses[1].Name)
+	for _, status := range statuses {
+		assert.Equal(t, infrastructure.IndexFailed, status.Status)
+		assert.Equal(t, infrastructure.IndexSourceConfig, status.Source)
+		assert.NotEmpty(t, status.Error)
+	}
+
+	m.EnsureIndexes(context.Background(), "orders_service", config.MongoIndexConfig{Collection: "orders", Keys: []string{"tenant_id", "-created_at"}})
+	ensured := m.EnsuredIndexes()
+	require.Len(t, ensured, 3, "the last outcome per index is kept")
+	assert.Equal(t, []string{"audit", "audit", "orders"}, []string{ensured[0].Collection, ensured[1].Collection, ensured[2].Collection})
+	assert.Equal(t, "orders_service", ensured[2].Source)
+}
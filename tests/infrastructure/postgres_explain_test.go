@@ -0,0 +1,95 @@
+package infrastructure_test
+
+import (
+	"testing"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const analyzedPlan = `[{
+  "Plan": {
+    "Node Type": "Hash Join", "Join Type": "Inner",
+    "Startup Cost": 10.5, "Total Cost": 120.0, "Plan Rows": 100, "Plan Width": 64,
+    "Actual Startup Time": 0.2, "Actual Total Time": 5.0, "Actual Rows": 400, "Actual Loops": 1,
+    "Hash Cond": "(o.user_id = u.id)",
+    "Plans": [
+      {"Node Type": "Seq Scan", "Relation Name": "orders", "Alias": "o",
+       "Startup Cost": 0, "Total Cost": 80.0, "Plan Rows": 1000, "Plan Width": 32,
+       "Actual Startup Time": 0.01, "Actual Total Time": 3.0, "Actual Rows": 1000, "Actual Loops": 1},
+      {"Node Type": "Hash",
+       "Startup Cost": 10.0, "Total Cost": 10.0, "Plan Rows": 10, "Plan Width": 32,
+       "Actual Startup Time": 0.1, "Actual Total Time": 0.1, "Actual Rows": 10, "Actual Loops": 1,
+       "Plans": [
+         {"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey",
+          "Startup Cost": 0, "Total Cost": 10.0, "Plan Rows": 10, "Plan Width": 32,
+          "Actual Startup Time": 0.01, "Actual Total Time": 0.02, "Actual Rows": 1, "Actual Loops": 4}
+       ]}
+    ]
+  },
+  "Planning Time": 0.3,
+  "Execution Time": 5.2
+}]`
+
+func TestParseExplainJSON(t *testing.T) {
+	plan, err := infrastructure.ParseExplainJSON([]byte(analyzedPlan))
+	require.NoError(t, err)
+	assert.True(t, plan.Analyzed)
+
+	root := plan.Plan
+	assert.Equal(t, "Hash Join", root.NodeType)
+	assert.Equal(t, "(o.user_id = u.id)", root.Details["Hash Cond"])
+	assert.Equal(t, 30.0, root.ExclusiveCost, "children's total cost is excluded")
+	assert.Equal(t, 25.0, root.CostPercent)
+	require.NotNil(t, root.ExclusiveMS)
+	assert.Equal(t, 1.9, *root.ExclusiveMS)
+	assert.Equal(t, 4.0, *root.RowsMisestimate)
+
+	index := root.Plans[1].Plans[0]
+	assert.Equal(t, "users_pkey", index.Index)
+	assert.Equal(t, 0.08, *index.ActualTimeMS, "times cover every loop")
+
+	s := plan.Summary
+	assert.Equal(t, 4, s.Nodes)
+	assert.Equal(t, 3, s.Depth)
+	assert.Equal(t, []string{"orders"}, s.SeqScans)
+	assert.Equal(t, "Seq Scan on orders", s.CostliestNode)
+	assert.Equal(t, "Seq Scan on orders", s.SlowestNode)
+	assert.Equal(t, 5.2, *s.ExecutionTimeMS)
+	assert.Equal(t, 400.0, *s.ActualRows)
+}
+
+func TestParseExplainJSON_EstimateOnly(t *testing.T) {
+	plan, err := infrastructure.ParseExplainJSON([]byte(`[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "t", "Total Cost": 35.5, "Plan Rows": 2550, "Plan Width": 4}}]`))
+	require.NoError(t, err)
+	assert.False(t, plan.Analyzed)
+	assert.Nil(t, plan.Plan.ActualTimeMS)
+	assert.Nil(t, plan.Summary.ExecutionTimeMS)
+	assert.Empty(t, plan.Summary.SlowestNode)
+	assert.Equal(t, 100.0, plan.Plan.CostPercent)
+
+	_, err = infrastructure.ParseExplainJSON([]byte(`{"not": "a plan"}`))
+	assert.Error(t, err)
+}
+
+func TestPlanNode_Schemas(t *testing.T) {
+	plan, err := infrastructure.ParseExplainJSON([]byte(`[{"Plan": {"Node Type": "Nested Loop", "Total Cost": 20, "Plans": [
+		{"Node Type": "Seq Scan", "Schema": "public", "Relation Name": "orders", "Total Cost": 5},
+		{"Node Type": "Function Scan", "Schema": "billing", "Function Name": "invoices_for", "Total Cost": 5},
+		{"Node Type": "Index Scan", "Schema": "public", "Relation Name": "users", "Total Cost": 5}
+	]}}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"billing", "public"}, plan.Plan.Schemas())
+	assert.Equal(t, "invoices_for", plan.Plan.Plans[1].Details["Function Name"])
+}
+
+func TestPlanNode_SchemasIgnoreExpressionFunctions(t *testing.T) {
+	// Functions called in expressions only show up in Output and Filter
+	// text, so their schemas are not reported
+	plan, err := infrastructure.ParseExplainJSON([]byte(`[{"Plan": {"Node Type": "Seq Scan", "Schema": "public", "Relation Name": "orders",
+		"Output": ["billing.total_for(orders.id)"], "Filter": "(billing.is_paid(orders.id))", "Total Cost": 5}}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"public"}, plan.Plan.Schemas())
+}
@@ -0,0 +1,55 @@
+package infrastructure_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresListen_Subscriptions(t *testing.T) {
+	pg := &infrastructure.PostgresManager{DB: openRoute(t, "listen-primary")}
+
+	_, err := pg.Listen("")
+	assert.Error(t, err)
+	_, err = pg.Listen(strings.Repeat("x", 64))
+	assert.Error(t, err, "longer names are truncated by the server")
+
+	ch, err := pg.Listen("orders_changed")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		listen, ok := pg.GetStatus()["listen"].(map[string]interface{})
+		return ok && listen["connected"] == false && strings.Contains(fmt.Sprint(listen["error"]), "pgx driver")
+	}, 5*time.Second, 50*time.Millisecond, "a listener that cannot LISTEN keeps retrying")
+
+	pg.Unlisten(ch)
+	_, open := <-ch
+	assert.False(t, open, "Unlisten closes the channel")
+
+	other, err := pg.Listen("orders_changed")
+	require.NoError(t, err)
+	require.NoError(t, pg.Close())
+	_, open = <-other
+	assert.False(t, open, "Close ends the subscriptions")
+	_, err = pg.Listen("orders_changed")
+	assert.ErrorIs(t, err, infrastructure.ErrListenerClosed)
+}
+
+func TestNotification_Data(t *testing.T) {
+	n := infrastructure.Notification{Connection: "primary", Channel: "orders_changed", Payload: `{"op": "INSERT", "id": 7}`, PID: 4242}
+	data := n.Data()
+	assert.Equal(t, "primary", data["connection"])
+	assert.Equal(t, "orders_changed", data["channel"])
+	assert.Equal(t, uint32(4242), data["pid"])
+	assert.Equal(t, map[string]interface{}{"op": "INSERT", "id": 7.0}, data["payload"], "JSON objects are decoded")
+
+	for _, payload := range []string{"7", "order 7 shipped", "{not json"} {
+		n.Payload = payload
+		assert.Equal(t, payload, n.Data()["payload"])
+	}
+}
@@ -0,0 +1,174 @@
+package infrastructure_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// routeDriver is a database/sql driver whose connections answer every
+// query with the name they were opened with, so tests see where a
+// statement went. Names containing "down" fail to connect.
+type routeDriver struct {
+	mu       sync.Mutex
+	execs    []string
+	prepares map[string]int
+}
+
+func (d *routeDriver) Open(name string) (driver.Conn, error) {
+	if strings.Contains(name, "down") {
+		return nil, errors.New("connection refused")
+	}
+	return &routeConn{name: name, driver: d}, nil
+}
+
+func (d *routeDriver) prepared(name string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.prepares[name]
+}
+
+func (d *routeDriver) executed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.execs...)
+}
+
+type routeConn struct {
+	name   string
+	driver *routeDriver
+}
+
+func (c *routeConn) Close() error              { return nil }
+func (c *routeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *routeConn) Prepare(string) (driver.Stmt, error) {
+	c.driver.mu.Lock()
+	if c.driver.prepares == nil {
+		c.driver.prepares = make(map[string]int)
+	}
+	c.driver.prepares[c.name]++
+	c.driver.mu.Unlock()
+	return &routeStmt{conn: c}, nil
+}
+
+func (c *routeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &routeRows{value: c.name}, nil
+}
+
+func (c *routeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	c.driver.mu.Lock()
+	c.driver.execs = append(c.driver.execs, c.name)
+	c.driver.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+type routeStmt struct{ conn *routeConn }
+
+func (s *routeStmt) Close() error  { return nil }
+func (s *routeStmt) NumInput() int { return -1 }
+func (s *routeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &routeRows{value: s.conn.name}, nil
+}
+func (s *routeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), "", nil)
+}
+
+type routeRows struct {
+	value string
+	done  bool
+}
+
+func (r *routeRows) Columns() []string { return []string{"source"} }
+func (r *routeRows) Close() error      { return nil }
+func (r *routeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+var (
+	routes         = &routeDriver{}
+	registerRoutes sync.Once
+)
+
+func openRoute(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	registerRoutes.Do(func() { sql.Register("route", routes) })
+	db, err := sql.Open("route", name)
+	require.NoError(t, err)
+	return db
+}
+
+func querySource(ctx context.Context, t *testing.T, pg *infrastructure.PostgresManager) string {
+	t.Helper()
+	var source string
+	require.NoError(t, pg.QueryRow(ctx, "SELECT 1").Scan(&source))
+	return source
+}
+
+func TestPostgresReplicas_Routing(t *testing.T) {
+	ctx := context.Background()
+	pg := &infrastructure.PostgresManager{DB: openRoute(t, "primary")}
+	defer pg.Close()
+
+	assert.Equal(t, "primary", querySource(ctx, t, pg), "without replicas reads stay on the primary")
+
+	pg.AddReplica("replica-a", openRoute(t, "replica-a"))
+	pg.AddReplica("replica-down", openRoute(t, "replica-down"))
+	pg.AddReplica("replica-b", openRoute(t, "replica-b"))
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[querySource(ctx, t, pg)]++
+	}
+	assert.Zero(t, seen["primary"])
+	assert.Zero(t, seen["replica-down"], "unhealthy replicas get no reads")
+	assert.Positive(t, seen["replica-a"])
+	assert.Positive(t, seen["replica-b"])
+
+	rows, err := pg.Select(ctx, "SELECT 1")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	var source string
+	require.NoError(t, rows.Scan(&source))
+	rows.Close()
+	assert.True(t, strings.HasPrefix(source, "replica-"))
+
+	assert.Equal(t, "primary", querySource(infrastructure.WithPrimary(ctx), t, pg))
+
+	_, err = pg.Exec(ctx, "UPDATE t SET x = 1")
+	require.NoError(t, err)
+	_, err = pg.Insert(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"primary", "primary"}, routes.executed(), "writes go to the primary")
+}
+
+func TestPostgresReplicas_Status(t *testing.T) {
+	pg := &infrastructure.PostgresManager{DB: openRoute(t, "status-primary")}
+	defer pg.Close()
+	pg.AddReplica("10.0.0.2:5432", openRoute(t, "status-replica"))
+	pg.AddReplica("10.0.0.3:5432", openRoute(t, "status-down"))
+
+	status := pg.GetStatus()
+	replicas, ok := status["replicas"].([]map[string]interface{})
+	require.True(t, ok)
+	require.Len(t, replicas, 2)
+	assert.Equal(t, "10.0.0.2:5432", replicas[0]["name"])
+	assert.Equal(t, true, replicas[0]["healthy"])
+	assert.Equal(t, false, replicas[1]["healthy"])
+	assert.Contains(t, replicas[1]["error"], "connection refused")
+}
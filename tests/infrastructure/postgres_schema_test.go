@@ -0,0 +1,143 @@
+package infrastructure_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaScript answers the catalog queries of Schemas for a database with
+// a public and an audit schema.
+func schemaScript() map[string]scriptResult {
+	return map[string]scriptResult{
+		"FROM pg_namespace n WHERE": {
+			columns: []string{"nspname", "owner"},
+			rows:    [][]driver.Value{{"audit", "auditor"}, {"public", "app"}},
+		},
+		"c.reltuples::bigint": {
+			columns: []string{"nspname", "relname", "relkind", "comment", "reltuples", "total", "table", "indexes"},
+			rows: [][]driver.Value{
+				{"audit", "events", "r", "", int64(-1), int64(8192), int64(8192), int64(0)},
+				{"public", "orders", "r", "Customer orders", int64(1200), int64(98304), int64(65536), int64(32768)},
+				{"public", "recent_orders", "v", "", int64(0), int64(0), int64(0), int64(0)},
+				{"public", "ghost", "r", "", int64(5), int64(1), int64(1), int64(0)},
+			},
+		},
+		"FROM pg_attribute a": {
+			columns: []string{"nspname", "relname", "attname", "type", "nullable", "default", "primary"},
+			rows: [][]driver.Value{
+				{"audit", "events", "payload", "jsonb", true, nil, false},
+				{"public", "orders", "id", "bigint", false, "nextval('orders_id_seq'::regclass)", true},
+				{"public", "orders", "note", "text", true, nil, false},
+				{"public", "recent_orders", "id", "bigint", true, nil, false},
+				{"public", "dropped_since", "id", "bigint", false, nil, false},
+			},
+		},
+		"FROM pg_index x": {
+			columns: []string{"nspname", "table", "index", "definition", "unique", "primary", "size"},
+			rows: [][]driver.Value{
+				{"public", "orders", "orders_pkey", "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)", true, true, int64(16384)},
+				{"public", "orders", "orders_note_idx", "CREATE INDEX orders_note_idx ON public.orders USING btree (note)", false, false, int64(16384)},
+			},
+		},
+	}
+}
+
+func TestPostgresSchemas(t *testing.T) {
+	pg := openScript(t, "schemas", schemaScript())
+	schemas, err := pg.Schemas(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, schemas, 2)
+
+	audit := schemas[0]
+	assert.Equal(t, "audit", audit.Name)
+	assert.Equal(t, "auditor", audit.Owner)
+	require.Len(t, audit.Tables, 1)
+	assert.Nil(t, audit.Tables[0].RowEstimate, "reltuples is -1 before the first ANALYZE")
+	assert.Len(t, audit.Tables[0].Columns, 1)
+	assert.Empty(t, audit.Tables[0].Indexes)
+
+	public := schemas[1]
+	assert.Equal(t, int64(98304+1), public.SizeBytes, "the total sizes of its tables")
+	require.Len(t, public.Tables, 3)
+	orders := public.Tables[0]
+	assert.Equal(t, "table", orders.Kind)
+	assert.Equal(t, "Customer orders", orders.Comment)
+	require.NotNil(t, orders.RowEstimate)
+	assert.Equal(t, int64(1200), *orders.RowEstimate)
+	assert.Equal(t, int64(65536), orders.TableBytes)
+	assert.Equal(t, int64(32768), orders.IndexBytes)
+	require.Len(t, orders.Columns, 2)
+	assert.True(t, orders.Columns[0].PrimaryKey)
+	assert.False(t, orders.Columns[0].Nullable)
+	require.NotNil(t, orders.Columns[0].Default)
+	assert.Equal(t, "nextval('orders_id_seq'::regclass)", *orders.Columns[0].Default)
+	assert.Nil(t, orders.Columns[1].Default)
+	require.Len(t, orders.Indexes, 2)
+	assert.True(t, orders.Indexes[0].Primary)
+	assert.False(t, orders.Indexes[1].Unique)
+
+	view := public.Tables[1]
+	assert.Equal(t, "view", view.Kind)
+	assert.Nil(t, view.RowEstimate, "views have no row estimate")
+	assert.Len(t, view.Columns, 1)
+	assert.Empty(t, public.Tables[2].Columns, "the columns of relations not listed are dropped")
+
+	_, err = pg.Schemas(context.Background(), "public")
+	require.NoError(t, err)
+	assert.Contains(t, lastQuery(), "n.nspname = $1", "a schema narrows the catalog queries")
+
+	empty := openScript(t, "no-schemas", map[string]scriptResult{
+		"FROM pg_namespace n WHERE": {columns: []string{"nspname", "owner"}},
+		"c.reltuples::bigint":       {columns: []string{"nspname", "relname", "relkind", "comment", "reltuples", "total", "table", "indexes"}},
+		"FROM pg_attribute a":       {columns: []string{"nspname", "relname", "attname", "type", "nullable", "default", "primary"}},
+		"FROM pg_index x":           {columns: []string{"nspname", "table", "index", "definition", "unique", "primary", "size"}},
+	})
+	schemas, err = empty.Schemas(context.Background(), "")
+	require.NoError(t, err)
+	assert.NotNil(t, schemas, "no schemas is an empty list, not null")
+	assert.Empty(t, schemas)
+}
+
+func TestPostgresSchemas_SQLSchemas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	deps.Set("postgres", openScript(t, "sql-schemas", schemaScript()))
+	cfg := &config.Config{}
+	cfg.Monitoring.SQLSchemas = []string{"public"}
+	cfg.Monitoring.Access = config.AccessConfig{Enabled: true, DefaultRole: "operator"}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/postgres/schema")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data []infrastructure.SchemaInfo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1, "schemas outside sql_schemas are left out")
+	assert.Equal(t, "public", resp.Data[0].Name)
+
+	w = get("/api/postgres/schema?schema=audit")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "SCHEMA_NOT_ALLOWED")
+}
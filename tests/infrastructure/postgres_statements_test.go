@@ -0,0 +1,59 @@
+package infrastructure_test
+
+import (
+	"context"
+	"testing"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStatements(t *testing.T) {
+	ctx := context.Background()
+	pg := &infrastructure.PostgresManager{DB: openRoute(t, "stmt-primary")}
+	defer pg.Close()
+
+	stmt, err := pg.Prepare(ctx, "order_by_id", "SELECT * FROM orders WHERE id = $1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, routes.prepared("stmt-primary"))
+
+	again, err := pg.Prepare(ctx, "order_by_id", "SELECT * FROM orders WHERE id = $1")
+	require.NoError(t, err)
+	assert.Same(t, stmt, again, "preparing the same query again reuses the statement")
+	_, err = pg.Prepare(ctx, "order_by_id", "SELECT 1")
+	assert.ErrorIs(t, err, infrastructure.ErrStatementConflict)
+
+	found, ok := pg.Statement("order_by_id")
+	require.True(t, ok)
+	assert.Same(t, stmt, found)
+	_, ok = pg.Statement("missing")
+	assert.False(t, ok)
+
+	var source string
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stmt.QueryRow(ctx, 1).Scan(&source))
+		assert.Equal(t, "stmt-primary", source)
+	}
+	assert.Equal(t, 1, routes.prepared("stmt-primary"), "executions reuse the prepared statement")
+
+	pg.AddReplica("replica", openRoute(t, "stmt-replica"))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stmt.QueryRow(ctx, 1).Scan(&source))
+		assert.Equal(t, "stmt-replica", source, "reads go to the replica")
+	}
+	assert.Equal(t, 1, routes.prepared("stmt-replica"), "the replica prepares the statement once")
+
+	require.NoError(t, stmt.QueryRow(infrastructure.WithPrimary(ctx), 1).Scan(&source))
+	assert.Equal(t, "stmt-primary", source)
+
+	before := len(routes.executed())
+	_, err = stmt.Exec(ctx, 1)
+	require.NoError(t, err)
+	executed := routes.executed()
+	require.Len(t, executed, before+1)
+	assert.Equal(t, "stmt-primary", executed[before], "writes go to the primary")
+
+	assert.Equal(t, 1, pg.GetStatus()["prepared_statements"])
+}
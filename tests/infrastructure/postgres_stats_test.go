@@ -0,0 +1,178 @@
+package infrastructure_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/sqlbuilder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptResult is the answer of a scripted connection to a query.
+type scriptResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// scriptDriver answers queries with the result whose key the query
+// contains, per data source name, and remembers the queries it saw.
+type scriptDriver struct {
+	mu      sync.Mutex
+	scripts map[string]map[string]scriptResult
+	queries []string
+}
+
+func (d *scriptDriver) Open(name string) (driver.Conn, error) {
+	return &scriptConn{name: name, driver: d}, nil
+}
+
+type scriptConn struct {
+	name   string
+	driver *scriptDriver
+}
+
+func (c *scriptConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *scriptConn) Close() error                        { return nil }
+func (c *scriptConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *scriptConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.queries = append(c.driver.queries, query)
+	for key, result := range c.driver.scripts[c.name] {
+		if strings.Contains(query, key) {
+			return &scriptRows{result: result}, nil
+		}
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+type scriptRows struct {
+	result scriptResult
+	next   int
+}
+
+func (r *scriptRows) Columns() []string { return r.result.columns }
+func (r *scriptRows) Close() error      { return nil }
+func (r *scriptRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+var (
+	scripts         = &scriptDriver{scripts: make(map[string]map[string]scriptResult)}
+	registerScripts sync.Once
+)
+
+func openScript(t *testing.T, name string, script map[string]scriptResult) *infrastructure.PostgresManager {
+	t.Helper()
+	registerScripts.Do(func() { sql.Register("script", scripts) })
+	scripts.mu.Lock()
+	scripts.scripts[name] = script
+	scripts.mu.Unlock()
+	db, err := sql.Open("script", name)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return &infrastructure.PostgresManager{DB: db}
+}
+
+func lastQuery() string {
+	scripts.mu.Lock()
+	defer scripts.mu.Unlock()
+	return scripts.queries[len(scripts.queries)-1]
+}
+
+func one(column string, value driver.Value) scriptResult {
+	return scriptResult{columns: []string{column}, rows: [][]driver.Value{{value}}}
+}
+
+func TestPostgresTopQueries(t *testing.T) {
+	ctx := context.Background()
+
+	pg := openScript(t, "no-extension", map[string]scriptResult{"pg_extension": one("exists", false)})
+	_, err := pg.TopQueries(ctx, "", 10)
+	assert.ErrorIs(t, err, infrastructure.ErrStatStatementsUnavailable)
+
+	stats := scriptResult{
+		columns: []string{"queryid", "query", "user", "calls", "total", "mean", "min", "max", "stddev", "rows", "hit", "read", "percent"},
+		rows: [][]driver.Value{
+			{int64(42), "SELECT * FROM orders WHERE id = $1", "app", int64(100), 250.0, 2.5, 0.5, 30.0, 1.2, int64(100), int64(90), int64(10), 62.5},
+			{nil, "VACUUM", "postgres", int64(1), 150.0, 150.0, 150.0, 150.0, 0.0, int64(0), int64(0), int64(0), nil},
+		},
+	}
+	pg = openScript(t, "pg15", map[string]scriptResult{
+		"pg_extension":            one("exists", true),
+		"server_version_num":      one("version", int64(150004)),
+		"FROM pg_stat_statements": stats,
+	})
+	queries, err := pg.TopQueries(ctx, "mean", 10)
+	require.NoError(t, err)
+	assert.Contains(t, lastQuery(), "ORDER BY mean_exec_time DESC LIMIT $1")
+	require.Len(t, queries, 2)
+	assert.Equal(t, int64(42), queries[0].QueryID)
+	assert.Equal(t, "app", queries[0].User)
+	assert.Equal(t, 2.5, queries[0].MeanMS)
+	assert.Equal(t, 0.9, queries[0].CacheHitRatio)
+	assert.Equal(t, 62.5, queries[0].PercentOfTotal)
+	assert.Equal(t, 1.0, queries[1].CacheHitRatio, "statements without reads hit the cache")
+
+	pg = openScript(t, "pg12", map[string]scriptResult{
+		"pg_extension":            one("exists", true),
+		"server_version_num":      one("version", int64(120010)),
+		"FROM pg_stat_statements": stats,
+	})
+	_, err = pg.TopQueries(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Contains(t, lastQuery(), "ORDER BY total_time DESC", "before Postgres 13 the columns lack _exec_")
+	_, err = pg.TopQueries(ctx, "query; DROP TABLE orders", 10)
+	assert.ErrorIs(t, err, sqlbuilder.ErrInvalidSort)
+}
+
+func TestPostgresIndexAndTableStats(t *testing.T) {
+	ctx := context.Background()
+	pg := openScript(t, "stats", map[string]scriptResult{
+		"pg_stat_user_indexes": {
+			columns: []string{"schema", "table", "index", "def", "scans", "read", "fetch", "size", "unique", "primary"},
+			rows: [][]driver.Value{
+				{"public", "orders", "orders_status_idx", "CREATE INDEX ...", int64(0), int64(0), int64(0), int64(8192), false, false},
+				{"public", "orders", "orders_ref_key", "CREATE UNIQUE INDEX ...", int64(0), int64(0), int64(0), int64(8192), true, false},
+				{"public", "orders", "orders_pkey", "CREATE UNIQUE INDEX ...", int64(12), int64(40), int64(12), int64(16384), true, true},
+			},
+		},
+		"pg_stat_user_tables": {
+			columns: []string{"schema", "table", "bytes", "live", "dead", "seq", "seq_read", "idx", "v", "av", "a", "aa"},
+			rows: [][]driver.Value{
+				{"public", "orders", int64(1000), int64(75), int64(25), int64(3), int64(300), int64(12), nil, nil, nil, nil},
+				{"public", "empty", int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), nil, nil, nil, nil},
+			},
+		},
+	})
+
+	indexes, err := pg.IndexUsage(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, indexes, 3)
+	assert.True(t, indexes[0].Unused)
+	assert.False(t, indexes[1].Unused, "unique indexes enforce a constraint")
+	assert.False(t, indexes[2].Unused)
+
+	tables, err := pg.TableStats(ctx, "public")
+	require.NoError(t, err)
+	require.Len(t, tables, 2)
+	assert.Equal(t, 0.25, tables[0].DeadRatio)
+	assert.Equal(t, int64(250), tables[0].BloatBytes)
+	assert.Nil(t, tables[0].LastVacuum)
+	assert.Zero(t, tables[1].BloatBytes)
+}
@@ -0,0 +1,84 @@
+package infrastructure_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeKeyspace(t *testing.T) {
+	f := &fakeRedis{}
+	for i := 0; i < 300; i++ {
+		f.keys = append(f.keys, fmt.Sprintf("session:%d", i))
+	}
+	for i := 0; i < 40; i++ {
+		f.keys = append(f.keys, fmt.Sprintf("cache:user:%d", i), fmt.Sprintf("cache:product:%d", i))
+	}
+	f.keys = append(f.keys, "config")
+	manager := startFakeRedis(t, f)
+	ctx := context.Background()
+
+	summary, err := manager.AnalyzeKeyspace(ctx, infrastructure.KeyspaceOptions{Samples: 10})
+	require.NoError(t, err)
+	assert.True(t, summary.Complete)
+	assert.Equal(t, int64(381), summary.Scanned)
+	require.Len(t, summary.Prefixes, 3)
+
+	session := summary.Prefixes[0]
+	assert.Equal(t, "session:", session.Prefix)
+	assert.Equal(t, int64(300), session.Keys)
+	assert.Equal(t, 10, session.SampledKeys)
+	assert.Equal(t, map[string]int64{"string": 10}, session.Types)
+	// The fake reports 50 bytes plus the key length
+	assert.InDelta(t, 300*(50+len("session:100")), session.EstimatedBytes, 300*2)
+	assert.Equal(t, "cache:", summary.Prefixes[1].Prefix)
+	assert.Equal(t, infrastructure.PrefixStats{Prefix: "config", Keys: 1, SampledKeys: 1, SampledBytes: 56, EstimatedBytes: 56, Types: map[string]int64{"string": 1}}, summary.Prefixes[2])
+
+	// Deeper prefixes split the cache
+	summary, err = manager.AnalyzeKeyspace(ctx, infrastructure.KeyspaceOptions{Pattern: "cache:*", Depth: 2})
+	require.NoError(t, err)
+	require.Len(t, summary.Prefixes, 2)
+	assert.Equal(t, "cache:product:", summary.Prefixes[0].Prefix)
+	assert.Equal(t, int64(40), summary.Prefixes[1].Keys)
+
+	// A bounded scan reports that it is partial
+	summary, err = manager.AnalyzeKeyspace(ctx, infrastructure.KeyspaceOptions{MaxKeys: 1})
+	require.NoError(t, err)
+	assert.False(t, summary.Complete)
+}
+
+func TestSlowLogAndInfo(t *testing.T) {
+	manager := startFakeRedis(t, &fakeRedis{keys: []string{"a", "b"}})
+	ctx := context.Background()
+
+	entries, err := manager.SlowLog(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, infrastructure.SlowLogEntry{
+		ID:         7,
+		Time:       time.Unix(1700000000, 0),
+		DurationUS: 15000,
+		Command:    []string{"KEYS", "*"},
+		ClientAddr: "10.0.0.1:5000",
+		ClientName: "worker",
+	}, entries[0])
+
+	info, err := manager.InfoSections(ctx, "memory", "keyspace")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), info["memory"]["used_memory"])
+	assert.Equal(t, 1.25, info["memory"]["mem_fragmentation_ratio"])
+	assert.Equal(t, map[string]interface{}{"keys": int64(2), "expires": int64(0), "avg_ttl": int64(0)}, info["keyspace"]["db0"])
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	info := infrastructure.ParseRedisInfo("# Server\r\nredis_version:7.2.4\r\nexecutable:/usr/bin/redis-server\r\n\r\n# Commandstats\r\ncmdstat_get:calls=10,usec=20,usec_per_call=2.00\r\n")
+	assert.Equal(t, "7.2.4", info["server"]["redis_version"])
+	assert.Equal(t, "/usr/bin/redis-server", info["server"]["executable"])
+	assert.Equal(t, map[string]interface{}{"calls": int64(10), "usec": int64(20), "usec_per_call": 2.0}, info["commandstats"]["cmdstat_get"])
+}
@@ -0,0 +1,183 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis answers the commands the key browser and keyspace analytics
+// send over RESP2. Keys
+// are string keys without expiry unless listed in ttls (milliseconds).
+type fakeRedis struct {
+	keys []string
+	ttls map[string]int64
+}
+
+func startFakeRedis(t *testing.T, f *fakeRedis) *infrastructure.RedisManager {
+	sort.Strings(f.keys)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() { client.Close() })
+	return &infrastructure.RedisManager{Client: client}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, f.reply(args))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func (f *fakeRedis) has(key string) bool {
+	i := sort.SearchStrings(f.keys, key)
+	return i < len(f.keys) && f.keys[i] == key
+}
+
+func (f *fakeRedis) reply(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "SCAN":
+		cursor, _ := strconv.Atoi(args[1])
+		pattern, count := "*", 10
+		for i := 2; i+1 < len(args); i += 2 {
+			switch strings.ToUpper(args[i]) {
+			case "MATCH":
+				pattern = args[i+1]
+			case "COUNT":
+				count, _ = strconv.Atoi(args[i+1])
+			}
+		}
+		end := min(cursor+count, len(f.keys))
+		var matched []string
+		for _, key := range f.keys[cursor:end] {
+			if ok, _ := path.Match(pattern, key); ok {
+				matched = append(matched, key)
+			}
+		}
+		if end == len(f.keys) {
+			end = 0
+		}
+		out := "*2\r\n" + bulk(strconv.Itoa(end)) + fmt.Sprintf("*%d\r\n", len(matched))
+		for _, key := range matched {
+			out += bulk(key)
+		}
+		return out
+	case "TYPE":
+		if !f.has(args[1]) {
+			return "+none\r\n"
+		}
+		return "+string\r\n"
+	case "PTTL":
+		if !f.has(args[1]) {
+			return ":-2\r\n"
+		}
+		if ttl, ok := f.ttls[args[1]]; ok {
+			return fmt.Sprintf(":%d\r\n", ttl)
+		}
+		return ":-1\r\n"
+	case "MEMORY":
+		if !f.has(args[2]) {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", 50+len(args[2]))
+	case "INFO":
+		return bulk("# Memory\r\nused_memory:1024\r\nmem_fragmentation_ratio:1.25\r\n\r\n# Keyspace\r\ndb0:keys=" + strconv.Itoa(len(f.keys)) + ",expires=0,avg_ttl=0\r\n")
+	case "SLOWLOG":
+		// One entry: id, unix time, microseconds, arguments, client address and name
+		return "*1\r\n*6\r\n:7\r\n:1700000000\r\n:15000\r\n*2\r\n" + bulk("KEYS") + bulk("*") + bulk("10.0.0.1:5000") + bulk("worker")
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestScanKeysPage(t *testing.T) {
+	f := &fakeRedis{ttls: map[string]int64{"session:3": 90_500}}
+	for i := 0; i < 250; i++ {
+		f.keys = append(f.keys, fmt.Sprintf("session:%d", i), fmt.Sprintf("cache:%d", i))
+	}
+	manager := startFakeRedis(t, f)
+	ctx := context.Background()
+
+	// Paging through every session key visits each exactly once
+	seen := make(map[string]bool)
+	cursor, pages := "", 0
+	for {
+		page, err := manager.ScanKeysPage(ctx, "session:*", cursor, 40)
+		require.NoError(t, err)
+		pages++
+		for _, key := range page.Keys {
+			assert.False(t, seen[key.Key], "key returned twice")
+			seen[key.Key] = true
+			assert.Equal(t, "string", key.Type)
+			if key.Key == "session:3" {
+				assert.Equal(t, int64(90), key.TTL)
+			}
+		}
+		if page.Done {
+			assert.Empty(t, page.Cursor)
+			break
+		}
+		require.NotEmpty(t, page.Cursor)
+		cursor = page.Cursor
+	}
+	assert.Len(t, seen, 250)
+	assert.Greater(t, pages, 1)
+
+	page, err := manager.ScanKeysPage(ctx, "cache:1", "", 0)
+	require.NoError(t, err)
+	require.Len(t, page.Keys, 1)
+	assert.Equal(t, infrastructure.KeyInfo{Key: "cache:1", Type: "string", TTL: -1, MemoryBytes: 57}, page.Keys[0])
+
+	_, err = manager.ScanKeysPage(ctx, "*", "not-a-cursor", 10)
+	assert.ErrorIs(t, err, infrastructure.ErrInvalidCursor)
+}
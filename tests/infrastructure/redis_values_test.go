@@ -0,0 +1,299 @@
+package infrastructure_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// typedRedis holds strings, hashes, lists and sorted sets and answers the
+// commands the key editor sends, including WATCH/MULTI/EXEC.
+type typedRedis struct {
+	mu      sync.Mutex
+	values  map[string]interface{} // string, map[string]string, []string, map[string]float64
+	ttls    map[string]int64       // milliseconds
+	version map[string]int         // bumped by writes, for WATCH
+}
+
+func startTypedRedis(t *testing.T, f *typedRedis) *infrastructure.RedisManager {
+	f.version = make(map[string]int)
+	if f.ttls == nil {
+		f.ttls = make(map[string]int64)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() { client.Close() })
+	return &infrastructure.RedisManager{Client: client}
+}
+
+func (f *typedRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	watched := make(map[string]int)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "WATCH":
+			f.mu.Lock()
+			for _, key := range args[1:] {
+				watched[key] = f.version[key]
+			}
+			f.mu.Unlock()
+			io.WriteString(conn, "+OK\r\n")
+		case "UNWATCH":
+			clear(watched)
+			io.WriteString(conn, "+OK\r\n")
+		case "MULTI":
+			inMulti, queued = true, nil
+			io.WriteString(conn, "+OK\r\n")
+		case "EXEC":
+			f.mu.Lock()
+			out := fmt.Sprintf("*%d\r\n", len(queued))
+			for key, version := range watched {
+				if f.version[key] != version {
+					out = "*-1\r\n"
+					queued = nil
+				}
+			}
+			for _, cmd := range queued {
+				out += f.reply(cmd)
+			}
+			f.mu.Unlock()
+			clear(watched)
+			inMulti = false
+			io.WriteString(conn, out)
+		default:
+			if inMulti {
+				queued = append(queued, args)
+				io.WriteString(conn, "+QUEUED\r\n")
+				continue
+			}
+			f.mu.Lock()
+			out := f.reply(args)
+			f.mu.Unlock()
+			io.WriteString(conn, out)
+		}
+	}
+}
+
+func array(items ...string) string {
+	out := fmt.Sprintf("*%d\r\n", len(items))
+	for _, item := range items {
+		out += bulk(item)
+	}
+	return out
+}
+
+func integer(n int) string { return fmt.Sprintf(":%d\r\n", n) }
+
+const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+// reply runs one command; f.mu is held.
+func (f *typedRedis) reply(args []string) string {
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	value, exists := f.values[key]
+	switch strings.ToUpper(args[0]) {
+	case "TYPE":
+		switch value.(type) {
+		case string:
+			return "+string\r\n"
+		case map[string]string:
+			return "+hash\r\n"
+		case []string:
+			return "+list\r\n"
+		case map[string]float64:
+			return "+zset\r\n"
+		}
+		return "+none\r\n"
+	case "PTTL":
+		if !exists {
+			return ":-2\r\n"
+		}
+		if ttl, ok := f.ttls[key]; ok {
+			return fmt.Sprintf(":%d\r\n", ttl)
+		}
+		return ":-1\r\n"
+	case "STRLEN":
+		return integer(len(value.(string)))
+	case "GETRANGE":
+		return bulk(value.(string))
+	case "SET":
+		f.values[key] = args[2]
+	case "HLEN":
+		return integer(len(value.(map[string]string)))
+	case "HSCAN":
+		hash := value.(map[string]string)
+		fields := make([]string, 0, len(hash))
+		for field := range hash {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		var items []string
+		for _, field := range fields {
+			items = append(items, field, hash[field])
+		}
+		return "*2\r\n" + bulk("0") + array(items...)
+	case "HSET":
+		hash, ok := value.(map[string]string)
+		if !ok {
+			return wrongType
+		}
+		hash[args[2]] = args[3]
+	case "LLEN":
+		return integer(len(value.([]string)))
+	case "LRANGE":
+		list := value.([]string)
+		stop, _ := strconv.Atoi(args[3])
+		return array(list[:min(stop+1, len(list))]...)
+	case "RPUSH":
+		f.values[key] = append(value.([]string), args[2:]...)
+	case "LSET":
+		list := value.([]string)
+		index, _ := strconv.Atoi(args[2])
+		if index >= len(list) {
+			return "-ERR index out of range\r\n"
+		}
+		list[index] = args[3]
+	case "ZCARD":
+		return integer(len(value.(map[string]float64)))
+	case "ZRANGE":
+		zset := value.(map[string]float64)
+		members := make([]string, 0, len(zset))
+		for member := range zset {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool { return zset[members[i]] < zset[members[j]] })
+		var items []string
+		for _, member := range members {
+			items = append(items, member, strconv.FormatFloat(zset[member], 'f', -1, 64))
+		}
+		return array(items...)
+	case "DEL":
+		if !exists {
+			return integer(0)
+		}
+		delete(f.values, key)
+		delete(f.ttls, key)
+	case "EXPIRE":
+		seconds, _ := strconv.Atoi(args[2])
+		f.ttls[key] = int64(seconds) * 1000
+	case "PERSIST":
+		delete(f.ttls, key)
+	default:
+		return "-ERR unknown command\r\n"
+	}
+	f.version[key]++
+	return integer(1)
+}
+
+func TestKeyValue(t *testing.T) {
+	f := &typedRedis{
+		values: map[string]interface{}{
+			"greeting": "hello",
+			"user:1":   map[string]string{"name": "Ada", "role": "admin"},
+			"queue":    []string{"a", "b", "c"},
+			"scores":   map[string]float64{"bob": 2, "ann": 1},
+		},
+		ttls: map[string]int64{"greeting": 30_000},
+	}
+	manager := startTypedRedis(t, f)
+	ctx := context.Background()
+
+	kv, err := manager.KeyValue(ctx, "greeting", 0)
+	require.NoError(t, err)
+	assert.Equal(t, infrastructure.KeyValue{Key: "greeting", Type: "string", TTL: 30, Length: 5, Value: "hello"}, *kv)
+
+	kv, err = manager.KeyValue(ctx, "user:1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "hash", kv.Type)
+	assert.Equal(t, int64(-1), kv.TTL)
+	assert.Equal(t, map[string]string{"name": "Ada", "role": "admin"}, kv.Value)
+	assert.False(t, kv.Truncated)
+
+	// Collections beyond the limit are truncated
+	kv, err = manager.KeyValue(ctx, "queue", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, kv.Value)
+	assert.Equal(t, int64(3), kv.Length)
+	assert.True(t, kv.Truncated)
+
+	kv, err = manager.KeyValue(ctx, "scores", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []infrastructure.ZMember{{Member: "ann", Score: 1}, {Member: "bob", Score: 2}}, kv.Value)
+
+	_, err = manager.KeyValue(ctx, "missing", 0)
+	assert.ErrorIs(t, err, infrastructure.ErrKeyNotFound)
+}
+
+func TestEditKey(t *testing.T) {
+	f := &typedRedis{
+		values: map[string]interface{}{
+			"user:1": map[string]string{"name": "Ada"},
+			"queue":  []string{"a"},
+			"token":  "secret",
+		},
+		ttls: map[string]int64{"token": 60_000},
+	}
+	manager := startTypedRedis(t, f)
+	ctx := context.Background()
+
+	require.NoError(t, manager.EditKey(ctx, "user:1", "hash", infrastructure.KeyEdit{Op: "set", Field: "role", Value: "admin"}))
+	assert.Equal(t, map[string]string{"name": "Ada", "role": "admin"}, f.values["user:1"])
+
+	// The type the caller saw must still be the key's type
+	err := manager.EditKey(ctx, "queue", "hash", infrastructure.KeyEdit{Op: "set", Field: "x"})
+	assert.ErrorIs(t, err, infrastructure.ErrKeyType)
+
+	require.NoError(t, manager.EditKey(ctx, "queue", "", infrastructure.KeyEdit{Op: "add", Value: "b"}))
+	assert.Equal(t, []string{"a", "b"}, f.values["queue"])
+	err = manager.EditKey(ctx, "user:1", "", infrastructure.KeyEdit{Op: "add", Field: "x"})
+	assert.ErrorIs(t, err, infrastructure.ErrInvalidEdit)
+
+	index := int64(5)
+	err = manager.EditKey(ctx, "queue", "list", infrastructure.KeyEdit{Op: "set", Index: &index, Value: "z"})
+	var replyErr redis.Error
+	assert.ErrorAs(t, err, &replyErr)
+
+	require.NoError(t, manager.SetKeyTTL(ctx, "token", 0))
+	assert.NotContains(t, f.ttls, "token")
+	require.NoError(t, manager.SetKeyTTL(ctx, "token", 90*time.Second))
+	assert.Equal(t, int64(90_000), f.ttls["token"])
+	assert.ErrorIs(t, manager.SetKeyTTL(ctx, "token", -time.Second), infrastructure.ErrInvalidEdit)
+
+	require.NoError(t, manager.DeleteKey(ctx, "token", "string"))
+	assert.NotContains(t, f.values, "token")
+	assert.ErrorIs(t, manager.DeleteKey(ctx, "token", ""), infrastructure.ErrKeyNotFound)
+}
@@ -0,0 +1,145 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generation is a component that remembers which connect created it.
+type generation struct {
+	n      int32
+	closed atomic.Bool
+}
+
+func (g *generation) Name() string { return "Generation" }
+func (g *generation) Close() error { g.closed.Store(true); return nil }
+func (g *generation) GetStatus() map[string]interface{} {
+	return map[string]interface{}{"connected": !g.closed.Load(), "generation": g.n}
+}
+
+// newRestartRegistry returns an initialized registry with one component
+// whose connects run connect.
+func newRestartRegistry(t *testing.T, connect func(n int32) (infrastructure.InfrastructureComponent, error)) *infrastructure.ComponentRegistry {
+	t.Helper()
+	var connects atomic.Int32
+	registry := &infrastructure.ComponentRegistry{}
+	registry.Register("kafka", func(*config.Config, *logger.Logger) (infrastructure.InfrastructureComponent, error) {
+		return connect(connects.Add(1))
+	})
+	cfg := &config.Config{}
+	cfg.Infrastructure.ConnectAttempts = 2
+	require.NoError(t, registry.Initialize(cfg, logger.New(false, nil)))
+	return registry
+}
+
+func waitRestart(t *testing.T, p *infrastructure.RestartProgress) infrastructure.RestartStatus {
+	t.Helper()
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("restart did not finish")
+	}
+	return p.Status()
+}
+
+func steps(status infrastructure.RestartStatus) []string {
+	var names []string
+	for _, s := range status.Steps {
+		names = append(names, s.Step)
+	}
+	return names
+}
+
+func TestRestarter_ReplacesComponent(t *testing.T) {
+	registry := newRestartRegistry(t, func(n int32) (infrastructure.InfrastructureComponent, error) {
+		return &generation{n: n}, nil
+	})
+	first, ok := registry.Get("kafka")
+	require.True(t, ok)
+
+	restarter := infrastructure.NewRestarter(registry)
+	var handedOver infrastructure.InfrastructureComponent
+	restarter.OnRestarted = func(name string, component infrastructure.InfrastructureComponent, result infrastructure.ConnectResult) {
+		assert.Equal(t, "kafka", name)
+		handedOver = component
+	}
+	p, err := restarter.Restart("kafka")
+	require.NoError(t, err)
+	status := waitRestart(t, p)
+
+	assert.Equal(t, infrastructure.RestartSucceeded, status.State)
+	assert.Equal(t, []string{"closing", "closed", "connected", "reloading", "done"}, steps(status))
+	assert.True(t, first.(*generation).closed.Load())
+	current, _ := registry.Get("kafka")
+	assert.Equal(t, int32(2), current.(*generation).n)
+	assert.Same(t, current, handedOver)
+	assert.Equal(t, 1, status.Attempts)
+
+	// Steps are numbered across restarts, so a stream cursor stays valid
+	last := status.Steps[len(status.Steps)-1].Seq
+	p, err = restarter.Restart("kafka")
+	require.NoError(t, err)
+	again := waitRestart(t, p)
+	assert.Greater(t, again.Steps[0].Seq, last)
+	latest, ok := restarter.Progress("kafka")
+	require.True(t, ok)
+	assert.Same(t, p, latest)
+}
+
+func TestRestarter_ReportsFailedReconnect(t *testing.T) {
+	registry := newRestartRegistry(t, func(n int32) (infrastructure.InfrastructureComponent, error) {
+		if n > 1 {
+			return nil, errors.New("broker unreachable")
+		}
+		return &generation{n: n}, nil
+	})
+	restarter := infrastructure.NewRestarter(registry)
+	var handedOver infrastructure.InfrastructureComponent = &generation{}
+	restarter.OnRestarted = func(_ string, component infrastructure.InfrastructureComponent, result infrastructure.ConnectResult) {
+		handedOver = component
+		assert.Error(t, result.Err)
+	}
+
+	p, err := restarter.Restart("kafka")
+	require.NoError(t, err)
+	status := waitRestart(t, p)
+	assert.Equal(t, infrastructure.RestartFailed, status.State)
+	assert.Equal(t, []string{"closing", "closed", "retrying", "failed", "reloading", "done"}, steps(status))
+	assert.Contains(t, status.Error, "broker unreachable")
+	assert.Equal(t, 2, status.Attempts)
+	assert.Nil(t, handedOver)
+	_, ok := registry.Get("kafka")
+	assert.False(t, ok)
+}
+
+func TestRestarter_RejectsUnknownAndConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	registry := newRestartRegistry(t, func(n int32) (infrastructure.InfrastructureComponent, error) {
+		if n > 1 {
+			<-release
+		}
+		return &generation{n: n}, nil
+	})
+	restarter := infrastructure.NewRestarter(registry)
+
+	_, err := restarter.Restart("cassandra")
+	assert.ErrorIs(t, err, infrastructure.ErrUnknownComponent)
+
+	p, err := restarter.Restart("kafka")
+	require.NoError(t, err)
+	running, err := restarter.Restart("kafka")
+	assert.ErrorIs(t, err, infrastructure.ErrRestartInProgress)
+	assert.Same(t, p, running)
+
+	close(release)
+	assert.Equal(t, infrastructure.RestartSucceeded, waitRestart(t, p).State)
+}
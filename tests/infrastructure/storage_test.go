@@ -0,0 +1,65 @@
+package infrastructure_test
+
+import (
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageManager_Disabled(t *testing.T) {
+	m, err := infrastructure.NewStorageManager(config.StorageConfig{Enabled: false}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Storage", m.Name())
+	assert.Empty(t, m.BucketNames())
+
+	_, err = m.GetDefaultBucket()
+	assert.Error(t, err)
+	assert.NoError(t, m.Close())
+}
+
+func TestStorageManager_PerBucketStatus(t *testing.T) {
+	m, err := infrastructure.NewStorageManager(config.StorageConfig{
+		Enabled: true,
+		Buckets: []config.StorageBucketConfig{
+			{Name: "archive", Provider: "ftp"},
+			{Name: "local", Provider: "minio"},
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer m.Close()
+
+	assert.Equal(t, []string{"archive", "local"}, m.BucketNames())
+
+	// The first configured bucket becomes the default
+	_, err = m.GetDefaultBucket()
+	assert.ErrorContains(t, err, "not connected: archive")
+
+	status := m.GetStatus()
+	assert.Equal(t, false, status["connected"])
+	assert.Equal(t, 2, status["total_buckets"])
+
+	buckets := status["buckets"].(map[string]interface{})
+	archive := buckets["archive"].(map[string]interface{})
+	assert.Equal(t, "Disconnected", archive["status"])
+	assert.Contains(t, archive["error"], "unsupported provider")
+	assert.Equal(t, "archive", archive["bucket"])
+
+	local := buckets["local"].(map[string]interface{})
+	assert.Contains(t, local["error"], "endpoint is required")
+}
+
+func TestStorageManager_DuplicateBucketName(t *testing.T) {
+	_, err := infrastructure.NewStorageManager(config.StorageConfig{
+		Enabled: true,
+		Buckets: []config.StorageBucketConfig{
+			{Name: "a", Provider: "ftp"},
+			{Name: "a", Provider: "ftp"},
+		},
+	}, nil)
+	assert.Error(t, err)
+}
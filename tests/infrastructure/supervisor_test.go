@@ -0,0 +1,82 @@
+package infrastructure_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeComponent struct{}
+
+func (fakeComponent) Name() string { return "Fake" }
+func (fakeComponent) Close() error { return nil }
+func (fakeComponent) GetStatus() map[string]interface{} {
+	return map[string]interface{}{"connected": true}
+}
+
+// collect returns the events received on events so far.
+func collect(events <-chan infrastructure.ConnectionEvent) []infrastructure.ConnectionEvent {
+	var out []infrastructure.ConnectionEvent
+	for {
+		select {
+		case e := <-events:
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
+
+func TestSupervise_RetriesUntilConnected(t *testing.T) {
+	events, unsubscribe := infrastructure.SubscribeConnectionEvents(16)
+	defer unsubscribe()
+
+	calls := 0
+	component, result := infrastructure.Supervise("kafka", infrastructure.RetryPolicy{Attempts: 5, Backoff: time.Millisecond}, func() (infrastructure.InfrastructureComponent, error) {
+		calls++
+		if calls < 4 {
+			return nil, errors.New("connection refused")
+		}
+		return fakeComponent{}, nil
+	})
+	require.NoError(t, result.Err)
+	assert.NotNil(t, component)
+	assert.Equal(t, 4, result.Attempts)
+
+	got := collect(events)
+	require.Len(t, got, 4)
+	assert.Equal(t, infrastructure.ConnectionRetrying, got[0].Type)
+	assert.Equal(t, "attempt 1 failed, retrying in 1ms: connection refused", got[0].Message())
+	assert.Equal(t, 2*time.Millisecond, got[1].RetryIn)
+	assert.Equal(t, infrastructure.ConnectionConnected, got[3].Type)
+	assert.Contains(t, got[3].Message(), "connected after 3 retries in ")
+}
+
+func TestSupervise_FailsAfterAttempts(t *testing.T) {
+	events, unsubscribe := infrastructure.SubscribeConnectionEvents(16)
+	defer unsubscribe()
+
+	_, result := infrastructure.Supervise("redis", infrastructure.RetryPolicy{Attempts: 2, Backoff: time.Millisecond}, func() (infrastructure.InfrastructureComponent, error) {
+		return nil, errors.New("no route to host")
+	})
+	assert.EqualError(t, result.Err, "no route to host")
+	assert.Equal(t, 2, result.Attempts)
+
+	got := collect(events)
+	require.Len(t, got, 2)
+	assert.Equal(t, infrastructure.ConnectionFailed, got[1].Type)
+	assert.Contains(t, got[1].Message(), "failed after 2 attempts")
+
+	// A disabled component publishes nothing
+	component, result := infrastructure.Supervise("nats", infrastructure.RetryPolicy{}, func() (infrastructure.InfrastructureComponent, error) {
+		return nil, nil
+	})
+	assert.Nil(t, component)
+	assert.NoError(t, result.Err)
+	assert.Empty(t, collect(events))
+}
@@ -0,0 +1,54 @@
+package infrastructure_test
+
+import (
+	"context"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresQueryAttribution(t *testing.T) {
+	collector, tenants := tenancy.DefaultCollector(), tenancy.DefaultRegistry()
+	collector.Reset()
+	require.NoError(t, tenants.Load([]config.TenantConfig{
+		{ID: "acme", Postgres: "acme_pg"},
+		{ID: "globex", Postgres: "shared"},
+		{ID: "initech", Postgres: "shared"},
+	}))
+	t.Cleanup(func() {
+		collector.Reset()
+		tenants.Load(nil)
+	})
+
+	query := func(ctx context.Context, conn string) {
+		t.Helper()
+		pg := openScript(t, "attribution-"+conn, map[string]scriptResult{"SELECT 1": one("one", int64(1))})
+		pg.Connection = conn
+		var one int64
+		require.NoError(t, pg.QueryRow(ctx, "SELECT 1").Scan(&one))
+	}
+	queries := func(tenant string) int64 {
+		for _, u := range collector.Snapshot() {
+			if u.Tenant == tenant {
+				return u.DBQueries
+			}
+		}
+		return 0
+	}
+	ctx := context.Background()
+
+	query(ctx, "acme_pg")
+	assert.Equal(t, int64(1), queries("acme"), "background work on a tenant's own connection")
+	query(tenancy.WithTenant(ctx, "globex"), "acme_pg")
+	assert.Equal(t, int64(1), queries("globex"), "the request tenant comes first")
+
+	query(ctx, "default")
+	query(ctx, "shared")
+	query(ctx, "")
+	assert.Equal(t, int64(3), queries(tenancy.UnattributedTenant), "connections no single tenant owns")
+	assert.Equal(t, []string{"_unattributed", "acme", "globex"}, collector.Tenants(), "no pseudo-tenants")
+}
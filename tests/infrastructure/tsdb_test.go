@@ -0,0 +1,282 @@
+package infrastructure_test
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// tsdbServer records the writes it receives and answers with the queued
+// status codes, then 204.
+type tsdbServer struct {
+	mu       sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *tsdbServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	code := http.StatusNoContent
+	if len(s.codes) > 0 {
+		code, s.codes = s.codes[0], s.codes[1:]
+	}
+	s.mu.Unlock()
+	w.WriteHeader(code)
+}
+
+func (s *tsdbServer) writes() ([]*http.Request, [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests...), append([][]byte(nil), s.bodies...)
+}
+
+func newTestTSDB(t *testing.T, cfg config.TSDBConfig) *infrastructure.TSDBManager {
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = 3600 // tests flush explicitly
+	}
+	cfg.RetryBackoff = 1
+	m, err := infrastructure.NewTSDBManager(cfg, logger.New(false, nil))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+var tsdbBase = time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+
+func TestTSDBManager_InfluxV2(t *testing.T) {
+	rec := &tsdbServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestTSDB(t, config.TSDBConfig{
+		URL:         srv.URL,
+		Org:         "ops",
+		Bucket:      "metrics",
+		Token:       "t0ken",
+		Measurement: "stackyrd",
+		Tags:        map[string]string{"instance": "web 1"},
+	})
+
+	m.Record(tsdbBase, map[string]float64{"cpu_percent": 12.5, "infra.redis": 1, "bad": math.NaN()})
+	m.Record(tsdbBase.Add(10*time.Second), map[string]float64{"cpu_percent": 20})
+	require.NoError(t, m.Flush(context.Background()))
+
+	reqs, bodies := rec.writes()
+	require.Len(t, reqs, 1, "one batch")
+	assert.Equal(t, "/api/v2/write", reqs[0].URL.Path)
+	assert.Equal(t, "ops", reqs[0].URL.Query().Get("org"))
+	assert.Equal(t, "metrics", reqs[0].URL.Query().Get("bucket"))
+	assert.Equal(t, "ns", reqs[0].URL.Query().Get("precision"))
+	assert.Equal(t, "Token t0ken", reqs[0].Header.Get("Authorization"))
+	assert.Equal(t, "stackyrd,instance=web\\ 1 cpu_percent=12.5,infra.redis=1 1735830000000000000\n"+
+		"stackyrd,instance=web\\ 1 cpu_percent=20 1735830010000000000\n", string(bodies[0]))
+
+	status := m.GetStatus()
+	assert.Equal(t, true, status["connected"])
+	assert.Equal(t, int64(2), status["written"])
+	assert.Equal(t, 0, status["buffered"])
+	assert.Equal(t, infrastructure.TSDBInfluxDB, status["type"])
+}
+
+func TestTSDBManager_InfluxV1(t *testing.T) {
+	rec := &tsdbServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestTSDB(t, config.TSDBConfig{URL: srv.URL, Database: "stackyrd", Username: "u", Password: "p", BatchSize: 2, FlushInterval: 1})
+
+	// A full batch is written without waiting for the flush interval
+	m.Record(tsdbBase, map[string]float64{"load1": 0.5})
+	m.Record(tsdbBase.Add(time.Second), map[string]float64{"load1": 0.7})
+	require.Eventually(t, func() bool {
+		reqs, _ := rec.writes()
+		return len(reqs) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	reqs, _ := rec.writes()
+	assert.Equal(t, "/write", reqs[0].URL.Path)
+	assert.Equal(t, "stackyrd", reqs[0].URL.Query().Get("db"))
+	user, pass, ok := reqs[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "u", user)
+	assert.Equal(t, "p", pass)
+}
+
+func TestTSDBManager_RetryAndBuffer(t *testing.T) {
+	rec := &tsdbServer{codes: []int{500, 503, 500}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestTSDB(t, config.TSDBConfig{URL: srv.URL, MaxRetries: 1, BatchSize: 10, BufferSize: 10})
+
+	for i := 0; i < 3; i++ {
+		m.Record(tsdbBase.Add(time.Duration(i)*time.Second), map[string]float64{"goroutines": float64(i)})
+	}
+	// Two attempts fail: the samples stay buffered
+	assert.Error(t, m.Flush(context.Background()))
+	status := m.GetStatus()
+	assert.Equal(t, false, status["connected"])
+	assert.Equal(t, 3, status["buffered"])
+	assert.Equal(t, int64(1), status["failed_writes"])
+	assert.Contains(t, status["error"], "status 503")
+
+	// The buffer keeps the newest samples
+	for i := 3; i < 12; i++ {
+		m.Record(tsdbBase.Add(time.Duration(i)*time.Second), map[string]float64{"goroutines": float64(i)})
+	}
+	assert.Equal(t, int64(2), m.GetStatus()["dropped"])
+
+	// The next flush retries once more, then succeeds
+	require.NoError(t, m.Flush(context.Background()))
+	_, bodies := rec.writes()
+	require.Len(t, bodies, 4)
+	last := string(bodies[3])
+	assert.Equal(t, 10, strings.Count(last, "\n"))
+	assert.True(t, strings.HasPrefix(last, "stackyrd goroutines=2 "), "oldest kept sample first: %s", last)
+	status = m.GetStatus()
+	assert.Equal(t, true, status["connected"])
+	assert.Equal(t, int64(10), status["written"])
+}
+
+func TestTSDBManager_RejectedBatchIsDropped(t *testing.T) {
+	rec := &tsdbServer{codes: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestTSDB(t, config.TSDBConfig{URL: srv.URL, MaxRetries: 3})
+
+	m.Record(tsdbBase, map[string]float64{"goroutines": 1})
+	assert.Error(t, m.Flush(context.Background()))
+	reqs, _ := rec.writes()
+	assert.Len(t, reqs, 1, "client errors are not retried")
+	status := m.GetStatus()
+	assert.Equal(t, 0, status["buffered"])
+	assert.Equal(t, int64(1), status["dropped"])
+}
+
+func TestTSDBManager_RemoteWrite(t *testing.T) {
+	rec := &tsdbServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestTSDB(t, config.TSDBConfig{
+		Type:   infrastructure.TSDBRemoteWrite,
+		URL:    srv.URL + "/api/v1/write",
+		Prefix: "stackyrd_",
+		Tags:   map[string]string{"instance": "web-1"},
+	})
+
+	m.Record(tsdbBase, map[string]float64{"cpu_percent": 12.5, "infra.redis": 1})
+	m.Record(tsdbBase.Add(10*time.Second), map[string]float64{"cpu_percent": 20})
+	require.NoError(t, m.Flush(context.Background()))
+
+	reqs, bodies := rec.writes()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "/api/v1/write", reqs[0].URL.Path)
+	assert.Equal(t, "snappy", reqs[0].Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", reqs[0].Header.Get("Content-Type"))
+
+	raw, err := snappy.Decode(nil, bodies[0])
+	require.NoError(t, err)
+	series := decodeWriteRequest(t, raw)
+	require.Len(t, series, 2)
+	assert.Equal(t, map[string]string{"__name__": "stackyrd_cpu_percent", "instance": "web-1"}, series[0].labels)
+	assert.Equal(t, []remoteSample{{12.5, tsdbBase.UnixMilli()}, {20, tsdbBase.Add(10 * time.Second).UnixMilli()}}, series[0].samples)
+	assert.Equal(t, "stackyrd_infra_redis", series[1].labels["__name__"])
+	assert.Equal(t, []remoteSample{{1, tsdbBase.UnixMilli()}}, series[1].samples)
+}
+
+func TestTSDBManager_Config(t *testing.T) {
+	l := logger.New(false, nil)
+	_, err := infrastructure.NewTSDBManager(config.TSDBConfig{}, l)
+	assert.Error(t, err, "url is required")
+	_, err = infrastructure.NewTSDBManager(config.TSDBConfig{URL: "http://localhost:8086", Type: "graphite"}, l)
+	assert.Error(t, err)
+
+	// Close writes what is still buffered
+	rec := &tsdbServer{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m, err := infrastructure.NewTSDBManager(config.TSDBConfig{URL: srv.URL, FlushInterval: 3600}, l)
+	require.NoError(t, err)
+	m.Record(tsdbBase, map[string]float64{"goroutines": 1})
+	require.NoError(t, m.Close())
+	reqs, _ := rec.writes()
+	assert.Len(t, reqs, 1)
+	m.Record(tsdbBase, map[string]float64{"goroutines": 2})
+	assert.Equal(t, 0, m.GetStatus()["buffered"], "nothing is recorded once closed")
+}
+
+type remoteSample struct {
+	Value float64
+	Ms    int64
+}
+
+type remoteSeries struct {
+	labels  map[string]string
+	samples []remoteSample
+}
+
+// decodeWriteRequest decodes the prometheus.WriteRequest fields the
+// component writes.
+func decodeWriteRequest(t *testing.T, b []byte) []remoteSeries {
+	var out []remoteSeries
+	for _, ts := range protoFields(t, b)[1] {
+		fields := protoFields(t, ts)
+		s := remoteSeries{labels: map[string]string{}}
+		for _, label := range fields[1] {
+			lf := protoFields(t, label)
+			s.labels[string(lf[1][0])] = string(lf[2][0])
+		}
+		for _, sample := range fields[2] {
+			var rs remoteSample
+			for len(sample) > 0 {
+				num, typ, n := protowire.ConsumeTag(sample)
+				require.GreaterOrEqual(t, n, 0)
+				sample = sample[n:]
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					v, n := protowire.ConsumeFixed64(sample)
+					rs.Value, sample = math.Float64frombits(v), sample[n:]
+				case num == 2 && typ == protowire.VarintType:
+					v, n := protowire.ConsumeVarint(sample)
+					rs.Ms, sample = int64(v), sample[n:]
+				default:
+					t.Fatalf("unexpected sample field %d", num)
+				}
+			}
+			s.samples = append(s.samples, rs)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// protoFields returns the length-delimited fields of a message by number.
+func protoFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	fields := make(map[protowire.Number][][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		require.Equal(t, protowire.BytesType, typ)
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		require.GreaterOrEqual(t, n, 0)
+		fields[num] = append(fields[num], v)
+		b = b[n:]
+	}
+	return fields
+}
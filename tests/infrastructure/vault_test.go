@@ -0,0 +1,179 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultManager_RenewsToken(t *testing.T) {
+	var ttl, renewals atomic.Int32
+	ttl.Store(3600)
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "app-token", r.Header.Get("X-Vault-Token"))
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprintf(w, `{"data":{"ttl":%d,"renewable":true}}`, ttl.Load())
+		case "/v1/auth/token/renew-self":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, "7200s", body["increment"])
+			renewals.Add(1)
+			ttl.Store(7200)
+			fmt.Fprint(w, `{"auth":{"lease_duration":7200,"renewable":true}}`)
+		case "/v1/secret/data/app":
+			fmt.Fprint(w, `{"data":{"data":{"api_key":"k"},"metadata":{}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vault.Close()
+
+	manager, err := infrastructure.NewVaultManager(config.VaultConfig{
+		Address: vault.URL, Token: "app-token", RenewInterval: 600, RenewIncrement: 7200,
+	}, logger.New(false, nil))
+	require.NoError(t, err)
+	defer manager.Close()
+
+	// Most of the TTL is left: nothing to do
+	manager.RenewIfNeeded(context.Background())
+	assert.Zero(t, renewals.Load())
+
+	// Below half of the granted TTL the token is renewed
+	ttl.Store(1000)
+	manager.RenewIfNeeded(context.Background())
+	assert.EqualValues(t, 1, renewals.Load())
+	status := manager.GetStatus()
+	assert.Equal(t, true, status["connected"])
+	assert.Equal(t, 1, status["renewals"])
+	assert.EqualValues(t, 7200, status["token_ttl_seconds"])
+
+	value, err := manager.Value(context.Background(), "secret/data/app", "api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "k", value)
+}
+
+func TestVaultManager_RejectsBadToken(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors":["permission denied"]}`)
+	}))
+	defer vault.Close()
+	_, err := infrastructure.NewVaultManager(config.VaultConfig{Address: vault.URL, Token: "bad"}, logger.New(false, nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+}
+
+// fakeDatabaseVault issues numbered credentials for database/creds/app and
+// renews leases for renewTTL seconds.
+type fakeDatabaseVault struct {
+	issued   atomic.Int32
+	renewTTL atomic.Int32
+	mu       sync.Mutex
+	renewed  []string
+	revoked  []string
+}
+
+func (f *fakeDatabaseVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	switch r.URL.Path {
+	case "/v1/auth/token/lookup-self":
+		fmt.Fprint(w, `{"data":{"ttl":0,"renewable":false}}`)
+	case "/v1/database/creds/app":
+		n := f.issued.Add(1)
+		fmt.Fprintf(w, `{"lease_id":"database/creds/app/%d","lease_duration":3600,"renewable":true,"data":{"username":"v-app-%d","password":"p%d"}}`, n, n, n)
+	case "/v1/sys/leases/renew":
+		f.mu.Lock()
+		f.renewed = append(f.renewed, body["lease_id"].(string))
+		f.mu.Unlock()
+		fmt.Fprintf(w, `{"lease_id":%q,"lease_duration":%d,"renewable":true}`, body["lease_id"], f.renewTTL.Load())
+	case "/v1/sys/leases/revoke":
+		f.mu.Lock()
+		f.revoked = append(f.revoked, body["lease_id"].(string))
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDatabaseVault) leases() (renewed, revoked []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.renewed...), append([]string(nil), f.revoked...)
+}
+
+func TestVaultManager_DatabaseCredentials(t *testing.T) {
+	fake := &fakeDatabaseVault{}
+	fake.renewTTL.Store(3600)
+	vault := httptest.NewServer(fake)
+	defer vault.Close()
+
+	// With a renew interval longer than the lease every check is due
+	manager, err := infrastructure.NewVaultManager(config.VaultConfig{
+		Address: vault.URL, Token: "app-token", RenewInterval: 3600,
+	}, logger.New(false, nil))
+	require.NoError(t, err)
+	defer manager.Close()
+
+	var rotated []infrastructure.DatabaseCredentials
+	rotateErr := error(nil)
+	creds, release, err := manager.DatabaseCredentials(context.Background(), "app", func(c infrastructure.DatabaseCredentials) error {
+		rotated = append(rotated, c)
+		return rotateErr
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "v-app-1", creds.Username)
+	assert.Equal(t, "p1", creds.Password)
+	assert.Equal(t, time.Hour, creds.TTL)
+
+	// Renewed for the full TTL: the credentials stay
+	manager.RenewLeases(context.Background())
+	renewed, _ := fake.leases()
+	assert.Equal(t, []string{"database/creds/app/1"}, renewed)
+	assert.Empty(t, rotated)
+
+	// Capped by max_ttl below half the TTL: new credentials are handed over
+	fake.renewTTL.Store(600)
+	manager.RenewLeases(context.Background())
+	require.Len(t, rotated, 1)
+	assert.Equal(t, "v-app-2", rotated[0].Username)
+	leases := manager.GetStatus()["database_leases"].([]map[string]interface{})
+	require.Len(t, leases, 1)
+	assert.Equal(t, "v-app-2", leases[0]["username"])
+	assert.Equal(t, 1, leases[0]["rotations"])
+	assert.NotContains(t, leases[0], "password")
+
+	// A failed hand-over revokes the new credentials and keeps the old ones
+	rotateErr = fmt.Errorf("login failed")
+	manager.RenewLeases(context.Background())
+	require.Len(t, rotated, 2)
+	_, revoked := fake.leases()
+	assert.Equal(t, []string{"database/creds/app/3"}, revoked)
+	leases = manager.GetStatus()["database_leases"].([]map[string]interface{})
+	assert.Equal(t, "v-app-2", leases[0]["username"])
+	assert.Equal(t, "login failed", leases[0]["error"])
+
+	// Releasing revokes the current lease and stops renewing it
+	release()
+	_, revoked = fake.leases()
+	assert.Equal(t, []string{"database/creds/app/3", "database/creds/app/2"}, revoked)
+	assert.NotContains(t, manager.GetStatus(), "database_leases")
+	release()
+	_, revoked = fake.leases()
+	assert.Len(t, revoked, 2, "released once")
+}
@@ -0,0 +1,96 @@
+package infrastructure_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool_Autoscale(t *testing.T) {
+	pool := infrastructure.NewWorkerPoolWithOptions("test-autoscale", infrastructure.PoolOptions{
+		Workers:       1,
+		MaxWorkers:    4,
+		QueueSize:     16,
+		ScaleInterval: 5 * time.Millisecond,
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(8)
+	for i := 0; i < 8; i++ {
+		pool.Submit(func() {
+			defer wg.Done()
+			<-release
+		})
+	}
+
+	require.Eventually(t, func() bool { return pool.Stats().Workers == 4 }, time.Second, 5*time.Millisecond,
+		"the pool grows while jobs wait")
+	status := pool.Stats()
+	assert.True(t, status.Autoscale)
+	assert.Equal(t, 1, status.MinWorkers)
+	assert.Positive(t, status.ScaleUps)
+	require.Eventually(t, func() bool { return pool.Stats().Busy == 4 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 4, pool.Stats().Workers, "never above max_workers")
+
+	close(release)
+	wg.Wait()
+	require.Eventually(t, func() bool { return pool.Stats().Workers == 1 }, 2*time.Second, 5*time.Millisecond,
+		"idle workers retire down to min_workers")
+	status = pool.Stats()
+	assert.Equal(t, int64(3), status.ScaleDowns)
+	assert.Positive(t, status.MaxWaitMS)
+	assert.Positive(t, status.AvgRunMS)
+
+	// Work still runs on the remaining worker
+	done := make(chan struct{})
+	pool.Submit(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job not run after scaling down")
+	}
+}
+
+func TestWorkerPool_GetStatus(t *testing.T) {
+	pool := infrastructure.NewNamedWorkerPool("test-status", 3)
+	pool.Start()
+	defer pool.Stop()
+
+	status := pool.GetStatus()
+	assert.Equal(t, 3, status["workers"])
+	assert.Equal(t, false, status["autoscale"])
+	assert.Equal(t, 6, status["queue_capacity"])
+	assert.Equal(t, false, status["saturated"])
+}
+
+func TestNewComponentPool_Configured(t *testing.T) {
+	infrastructure.ConfigurePools(map[string]config.PoolConfig{
+		"postgres": {MinWorkers: 2, MaxWorkers: 10, QueueSize: 50},
+		"storage":  {Workers: 3},
+	})
+	defer infrastructure.ConfigurePools(nil)
+
+	pg := infrastructure.NewComponentPool("postgres", "postgres:test", 15).Stats()
+	assert.Equal(t, 10, pg.Workers, "the default is kept within min and max")
+	assert.Equal(t, 2, pg.MinWorkers)
+	assert.Equal(t, 10, pg.MaxWorkers)
+	assert.Equal(t, 50, pg.QueueCapacity)
+	assert.True(t, pg.Autoscale)
+
+	storage := infrastructure.NewComponentPool("storage", "storage", 8).Stats()
+	assert.Equal(t, 3, storage.Workers)
+	assert.False(t, storage.Autoscale)
+
+	cron := infrastructure.NewComponentPool("cron", "cron", 5).Stats()
+	assert.Equal(t, 5, cron.Workers, "unconfigured kinds keep their default")
+	assert.Equal(t, 10, cron.QueueCapacity)
+}
@@ -0,0 +1,55 @@
+package jsonpatch_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"stackyrd/pkg/jsonpatch"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestDiffAndApply(t *testing.T) {
+	from := decode(t, `{"a":1,"b":{"c":"x","d":[1,2]},"gone":true,"list":[1],"a/b~":0}`)
+	to := decode(t, `{"a":2,"b":{"c":"x","d":[1,3]},"new":{"k":null},"list":[1,2],"a/b~":1}`)
+
+	ops := jsonpatch.Diff(from, to)
+	assert.Equal(t, []jsonpatch.Operation{
+		{Op: "replace", Path: "/a", Value: 2.0},
+		{Op: "replace", Path: "/a~1b~0", Value: 1.0},
+		{Op: "replace", Path: "/b/d/1", Value: 3.0},
+		{Op: "remove", Path: "/gone"},
+		{Op: "replace", Path: "/list", Value: []interface{}{1.0, 2.0}},
+		{Op: "add", Path: "/new", Value: map[string]interface{}{"k": nil}},
+	}, ops)
+
+	patched, err := jsonpatch.Apply(decode(t, `{"a":1,"b":{"c":"x","d":[1,2]},"gone":true,"list":[1],"a/b~":0}`), ops)
+	require.NoError(t, err)
+	assert.Equal(t, to, patched)
+
+	assert.Empty(t, jsonpatch.Diff(to, decode(t, `{"a":2,"b":{"c":"x","d":[1,3]},"new":{"k":null},"list":[1,2],"a/b~":1}`)))
+	assert.Equal(t, []jsonpatch.Operation{{Op: "replace", Path: "", Value: "x"}}, jsonpatch.Diff(1.0, "x"))
+}
+
+func TestApplyRejectsMissingPath(t *testing.T) {
+	_, err := jsonpatch.Apply(decode(t, `{"a":{}}`), []jsonpatch.Operation{{Op: "replace", Path: "/b/c", Value: 1}})
+	assert.ErrorIs(t, err, jsonpatch.ErrInvalidPatch)
+	_, err = jsonpatch.Apply(decode(t, `{"a":[1]}`), []jsonpatch.Operation{{Op: "add", Path: "/a/1", Value: 1}})
+	assert.ErrorIs(t, err, jsonpatch.ErrInvalidPatch)
+}
+
+func TestNormalize(t *testing.T) {
+	type status struct {
+		Connected bool `json:"connected"`
+	}
+	doc, err := jsonpatch.Normalize(map[string]interface{}{"db": status{Connected: true}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"db": map[string]interface{}{"connected": true}}, doc)
+}
@@ -0,0 +1,106 @@
+package logger_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(ch <-chan logger.LogEntry) []string {
+	var messages []string
+	for {
+		select {
+		case e := <-ch:
+			messages = append(messages, e.Message)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestLogBroadcaster_DropPolicies(t *testing.T) {
+	b := logger.NewLogBroadcaster(10)
+	newest, cancelNewest := b.SubscribeWith(logger.SubscribeOptions{Name: "newest", Buffer: 2, Policy: logger.DropNewest})
+	defer cancelNewest()
+	oldest, cancelOldest := b.SubscribeWith(logger.SubscribeOptions{Name: "oldest", Buffer: 2, Policy: logger.DropOldest})
+	defer cancelOldest()
+
+	for i := 1; i <= 5; i++ {
+		b.Publish(logger.LogEntry{Message: fmt.Sprintf("line %d", i)})
+	}
+
+	stats := b.Stats()
+	assert.Equal(t, uint64(5), stats.Published)
+	assert.Equal(t, uint64(6), stats.Dropped)
+	require.Len(t, stats.Subscribers, 2)
+	for _, sub := range stats.Subscribers {
+		assert.Equal(t, 2, sub.Buffer)
+		assert.Equal(t, 2, sub.Queued)
+		assert.Equal(t, uint64(3), sub.Dropped, sub.Name)
+	}
+
+	assert.Equal(t, []string{"line 1", "line 2"}, drain(newest), "drop_newest keeps the buffered lines")
+	assert.Equal(t, []string{"line 4", "line 5"}, drain(oldest), "drop_oldest keeps the latest lines")
+}
+
+func TestLogBroadcaster_SubscriberDefaults(t *testing.T) {
+	b := logger.NewLogBroadcaster(10)
+	b.SetSubscriberDefaults(3, logger.DropOldest)
+	ch, cancel := b.Subscribe(0)
+
+	for i := 1; i <= 4; i++ {
+		b.Publish(logger.LogEntry{Message: fmt.Sprintf("line %d", i)})
+	}
+	sub := b.Stats().Subscribers[0]
+	assert.Equal(t, 3, sub.Buffer)
+	assert.Equal(t, logger.DropOldest, sub.Policy)
+	assert.Equal(t, []string{"line 2", "line 3", "line 4"}, drain(ch))
+
+	// Drops of a cancelled subscription still count
+	cancel()
+	cancel()
+	stats := b.Stats()
+	assert.Empty(t, stats.Subscribers)
+	assert.Equal(t, uint64(1), stats.Dropped)
+
+	_, err := logger.ParseDropPolicy("drop_random")
+	assert.Error(t, err)
+	policy, err := logger.ParseDropPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, logger.DropNewest, policy)
+}
+
+func TestLogBroadcaster_CancelWhilePublishing(t *testing.T) {
+	b := logger.NewLogBroadcaster(10)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					b.Publish(logger.LogEntry{Message: "line"})
+				}
+			}
+		}()
+	}
+
+	// Cancelling closes the channel; a publisher must never send on it
+	// afterwards
+	for i := 0; i < 100; i++ {
+		ch, cancel := b.Subscribe(1)
+		<-ch
+		cancel()
+	}
+	close(stop)
+	wg.Wait()
+}
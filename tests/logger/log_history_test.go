@@ -0,0 +1,133 @@
+package logger_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogBroadcaster_ParsesJSONAndConsoleLines(t *testing.T) {
+	b := logger.NewLogBroadcaster(10)
+	fmt.Fprintln(b, `{"level":"warn","time":"2026-01-02T03:04:05Z","message":"disk low","free_mb":12}`)
+	fmt.Fprintln(b, "15:04:05 ERR connection refused")
+
+	recent := b.Recent(0)
+	require.Len(t, recent, 2)
+	assert.Equal(t, "warn", recent[0].Level)
+	assert.Equal(t, "disk low", recent[0].Message)
+	assert.Equal(t, float64(12), recent[0].Fields["free_mb"])
+	assert.Equal(t, "error", recent[1].Level)
+	assert.Equal(t, "connection refused", recent[1].Message)
+}
+
+func TestLogBroadcaster_SinceFollowsSequence(t *testing.T) {
+	b := logger.NewLogBroadcaster(3)
+	changed := b.Changed()
+	for i := 1; i <= 5; i++ {
+		b.Publish(logger.LogEntry{Message: fmt.Sprintf("line %d", i)})
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Changed was not closed by Publish")
+	}
+	assert.Equal(t, uint64(5), b.Seq())
+
+	entries, missed := b.Since(3, 0)
+	assert.False(t, missed)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(4), entries[0].Seq)
+
+	entries, missed = b.Since(1, 1)
+	assert.True(t, missed, "lines 2 left the buffer")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "line 3", entries[0].Message)
+
+	entries, _ = b.Since(5, 0)
+	assert.Empty(t, entries)
+	entries, _ = b.Since(0, 2)
+	assert.Len(t, entries, 2, "no cursor returns the tail")
+}
+
+func TestLogBroadcaster_SearchFiltersAndPages(t *testing.T) {
+	b := logger.NewLogBroadcaster(100)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		level := "info"
+		if i%2 == 0 {
+			level = "error"
+		}
+		b.Publish(logger.LogEntry{Time: base.Add(time.Duration(i) * time.Minute), Level: level, Message: fmt.Sprintf("request %d", i)})
+	}
+
+	entries, total, err := b.Search(logger.LogQuery{Level: "warn", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "request 8", entries[0].Message)
+	assert.Equal(t, "request 6", entries[1].Message)
+
+	entries, _, err = b.Search(logger.LogQuery{Level: "warn", Offset: 4, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "request 0", entries[0].Message)
+
+	entries, total, err = b.Search(logger.LogQuery{Query: "REQUEST 3"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "request 3", entries[0].Message)
+
+	_, total, err = b.Search(logger.LogQuery{From: base.Add(2 * time.Minute), To: base.Add(4 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+}
+
+func TestLogBroadcaster_SearchHugePages(t *testing.T) {
+	b := logger.NewLogBroadcaster(10)
+	for i := 0; i < 3; i++ {
+		b.Publish(logger.LogEntry{Message: fmt.Sprintf("line %d", i)})
+	}
+
+	entries, total, err := b.Search(logger.LogQuery{Offset: math.MaxInt - 5, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Empty(t, entries)
+
+	entries, total, err = b.Search(logger.LogQuery{Limit: math.MaxInt})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, entries, 3)
+}
+
+func TestLogBroadcaster_PersistenceSurvivesRestartAndRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+
+	b := logger.NewLogBroadcaster(2)
+	require.NoError(t, b.EnablePersistence(path, 400))
+	for i := 0; i < 6; i++ {
+		b.Publish(logger.LogEntry{Level: "info", Message: fmt.Sprintf("line %d", i)})
+	}
+	require.NoError(t, b.Close())
+
+	_, err := os.Stat(path + ".1")
+	require.NoError(t, err, "log file should have rotated")
+
+	// A fresh broadcaster finds entries beyond its ring buffer on disk
+	reopened := logger.NewLogBroadcaster(2)
+	require.NoError(t, reopened.EnablePersistence(path, 400))
+	defer reopened.Close()
+	assert.True(t, reopened.Persistent())
+
+	entries, total, err := reopened.Search(logger.LogQuery{Query: "line"})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, total, 3)
+	assert.Equal(t, "line 5", entries[0].Message)
+}
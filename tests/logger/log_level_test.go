@@ -0,0 +1,38 @@
+package logger_test
+
+import (
+	"bytes"
+	"testing"
+
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLevel_OverridesConfiguredLevel(t *testing.T) {
+	t.Cleanup(func() { logger.SetLevel("") })
+	var buf bytes.Buffer
+	l := logger.NewQuiet(false, &buf)
+
+	l.Debug("hidden")
+	assert.NotContains(t, buf.String(), "hidden")
+
+	require.NoError(t, logger.SetLevel("debug"))
+	assert.Equal(t, "debug", logger.Level())
+	l.Debug("shown")
+	assert.Contains(t, buf.String(), "shown")
+
+	require.NoError(t, logger.SetLevel("WARN"))
+	l.Info("quiet info")
+	l.Warn("loud warning")
+	assert.NotContains(t, buf.String(), "quiet info")
+	assert.Contains(t, buf.String(), "loud warning")
+
+	require.NoError(t, logger.SetLevel(""))
+	assert.Empty(t, logger.Level())
+	l.Info("back to info")
+	assert.Contains(t, buf.String(), "back to info")
+
+	assert.Error(t, logger.SetLevel("verbose"))
+}
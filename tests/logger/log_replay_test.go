@@ -0,0 +1,100 @@
+package logger_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openReplayStore(t *testing.T, path string) *infrastructure.EmbeddedStore {
+	t.Helper()
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: path}, nil)
+	require.NoError(t, err)
+	return store
+}
+
+func TestLogBroadcaster_ReplaysPreviousRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.db")
+
+	// First run logs until it "crashes"; Close saves the queued entries
+	store := openReplayStore(t, path)
+	first := logger.NewLogBroadcaster(10)
+	first.Publish(logger.LogEntry{Level: "info", Message: "booting"})
+	n, err := first.EnableReplay(logger.NewStoreReplay(store), time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	first.Publish(logger.LogEntry{Level: "error", Message: "out of memory"})
+	require.NoError(t, first.Close())
+	require.NoError(t, store.Close())
+
+	// Second run restores them before its own entries
+	store = openReplayStore(t, path)
+	defer store.Close()
+	second := logger.NewLogBroadcaster(10)
+	second.Publish(logger.LogEntry{Level: "info", Message: "booting again"})
+	n, err = second.EnableReplay(logger.NewStoreReplay(store), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	defer second.Close()
+
+	recent := second.Recent(0)
+	require.Len(t, recent, 3)
+	assert.Equal(t, "booting", recent[0].Message)
+	assert.Equal(t, "out of memory", recent[1].Message)
+	assert.True(t, recent[1].Replayed)
+	assert.Zero(t, recent[1].Seq)
+	assert.Equal(t, "booting again", recent[2].Message)
+	assert.False(t, recent[2].Replayed)
+
+	replayed := second.Replayed()
+	require.Len(t, replayed, 2)
+	assert.Equal(t, "error", replayed[1].Level)
+	assert.Equal(t, 2, second.ReplayStatus()["replayed"])
+}
+
+func TestLogBroadcaster_ReplayKeepsWindowAndBufferSize(t *testing.T) {
+	store := openReplayStore(t, filepath.Join(t.TempDir(), "replay.db"))
+	defer store.Close()
+	replay := logger.NewStoreReplay(store)
+
+	now := time.Now()
+	var entries []logger.LogEntry
+	entries = append(entries, logger.LogEntry{Seq: 1, Time: now.Add(-2 * time.Hour), Level: "info", Message: "too old"})
+	for i := 0; i < 5; i++ {
+		entries = append(entries, logger.LogEntry{Seq: uint64(i + 2), Time: now.Add(time.Duration(i-10) * time.Second), Level: "info", Message: "recent"})
+	}
+	require.NoError(t, replay.Save(entries))
+
+	b := logger.NewLogBroadcaster(4)
+	b.Publish(logger.LogEntry{Level: "info", Message: "current"})
+	n, err := b.EnableReplay(replay, time.Hour)
+	require.NoError(t, err)
+	defer b.Close()
+
+	// Only the newest that fit next to this run's entry are restored
+	assert.Equal(t, 3, n)
+	recent := b.Recent(0)
+	require.Len(t, recent, 4)
+	assert.Equal(t, "current", recent[3].Message)
+	for _, e := range recent[:3] {
+		assert.Equal(t, "recent", e.Message)
+	}
+
+	_, err = b.EnableReplay(replay, time.Hour)
+	assert.Error(t, err)
+
+	// Stopping saves this run's entry; pruning drops what left the window
+	b.StopReplay()
+	require.NoError(t, replay.Prune(now.Add(-time.Hour)))
+	kept, err := replay.Load(time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, kept, 6)
+	assert.Equal(t, "current", kept[5].Message)
+}
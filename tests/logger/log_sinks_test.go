@@ -0,0 +1,116 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinkSet_FileSinkFiltersByLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.jsonl")
+	sinks, err := logger.NewSinkSet([]logger.SinkConfig{{Name: "file", Type: logger.SinkFile, Level: "warn", Path: path}})
+	require.NoError(t, err)
+
+	l := logger.NewQuiet(true, io.Discard).WithSinks(sinks)
+	l.Debug("noise")
+	l.Info("started")
+	l.Warn("disk low", "free_mb", 12)
+	l.Error("write failed", nil)
+	require.NoError(t, sinks.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var first map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "warn", first["level"])
+	assert.Equal(t, "disk low", first["message"])
+	assert.Equal(t, float64(12), first["free_mb"])
+
+	stats := sinks.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, uint64(2), stats[0].Written)
+}
+
+func TestSinkSet_LokiPushesBatchedStreams(t *testing.T) {
+	var mu sync.Mutex
+	var pushed []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/push", r.URL.Path)
+		assert.Equal(t, "acme", r.Header.Get("X-Scope-OrgID"))
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		pushed = append(pushed, body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sinks, err := logger.NewSinkSet([]logger.SinkConfig{{
+		Type:     logger.SinkLoki,
+		URL:      srv.URL,
+		TenantID: "acme",
+		Labels:   map[string]string{"app": "test"},
+	}})
+	require.NoError(t, err)
+
+	l := logger.NewQuiet(false, io.Discard).WithSinks(sinks)
+	l.Info("one")
+	l.Info("two")
+	l.Warn("three")
+	require.NoError(t, sinks.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, pushed, 1)
+	streams := pushed[0]["streams"].([]interface{})
+	require.Len(t, streams, 2)
+	info := streams[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"app": "test", "level": "info"}, info["stream"])
+	assert.Len(t, info["values"], 2)
+}
+
+func TestSinkSet_SyslogSendsRFC5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sinks, err := logger.NewSinkSet([]logger.SinkConfig{{Type: logger.SinkSyslog, Address: conn.LocalAddr().String(), Tag: "app"}})
+	require.NoError(t, err)
+	logger.NewQuiet(false, io.Discard).WithSinks(sinks).Error("boom", nil)
+	require.NoError(t, sinks.Close())
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	// local0 (16) * 8 + error (3)
+	assert.True(t, strings.HasPrefix(msg, "<131>1 "), msg)
+	assert.Contains(t, msg, " app ")
+	assert.Contains(t, msg, `"message":"boom"`)
+}
+
+func TestSinkSet_RejectsInvalidConfig(t *testing.T) {
+	_, err := logger.NewSinkSet([]logger.SinkConfig{{Type: "carrier-pigeon"}})
+	assert.Error(t, err)
+	_, err = logger.NewSinkSet([]logger.SinkConfig{{Type: logger.SinkKafka}})
+	assert.Error(t, err)
+	_, err = logger.NewSinkSet([]logger.SinkConfig{{Type: logger.SinkConsole, Level: "loud"}})
+	assert.Error(t, err)
+}
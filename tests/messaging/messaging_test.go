@@ -0,0 +1,116 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatch_AutoAck(t *testing.T) {
+	var acked, nacked, requeued bool
+	newDelivery := func() *messaging.Delivery {
+		acked, nacked, requeued = false, false, false
+		return messaging.NewDelivery(messaging.Message{Topic: "t"}, func() error {
+			acked = true
+			return nil
+		}, func(requeue bool) error {
+			nacked, requeued = true, requeue
+			return nil
+		})
+	}
+	ok := func(ctx context.Context, d *messaging.Delivery) error { return nil }
+	fail := func(ctx context.Context, d *messaging.Delivery) error { return errors.New("boom") }
+
+	require.NoError(t, messaging.Dispatch(context.Background(), newDelivery(), messaging.SubscribeOptions{}, ok))
+	assert.True(t, acked)
+
+	assert.Error(t, messaging.Dispatch(context.Background(), newDelivery(), messaging.SubscribeOptions{}, fail))
+	assert.True(t, nacked)
+	assert.True(t, requeued)
+
+	// Manual ack leaves the delivery untouched
+	d := newDelivery()
+	require.NoError(t, messaging.Dispatch(context.Background(), d, messaging.SubscribeOptions{ManualAck: true}, ok))
+	assert.False(t, d.Acknowledged())
+	require.NoError(t, d.Ack())
+	assert.ErrorIs(t, d.Nack(false), messaging.ErrAlreadyAcked)
+}
+
+func TestMemoryBroker_FanOutAndGroups(t *testing.T) {
+	b := messaging.NewMemoryBroker()
+	var loose, grouped atomic.Int32
+	count := func(n *atomic.Int32) messaging.Handler {
+		return func(ctx context.Context, d *messaging.Delivery) error {
+			n.Add(1)
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+	_, err := b.Subscribe(ctx, "orders", messaging.SubscribeOptions{}, count(&loose))
+	require.NoError(t, err)
+	_, err = b.Subscribe(ctx, "orders", messaging.SubscribeOptions{}, count(&loose))
+	require.NoError(t, err)
+	_, err = b.Subscribe(ctx, "orders", messaging.SubscribeOptions{Group: "workers"}, count(&grouped))
+	require.NoError(t, err)
+	sub, err := b.Subscribe(ctx, "orders", messaging.SubscribeOptions{Group: "workers"}, count(&grouped))
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "orders", Value: []byte("x")}))
+	}
+	b.Wait()
+
+	// Ungrouped subscribers each see every message; a group shares them
+	assert.Equal(t, int32(8), loose.Load())
+	assert.Equal(t, int32(4), grouped.Load())
+
+	require.NoError(t, sub.Unsubscribe())
+	assert.Equal(t, "orders", sub.Topic())
+
+	require.NoError(t, b.Close())
+	assert.ErrorIs(t, b.PublishMessage(ctx, messaging.Message{Topic: "orders"}), messaging.ErrNotConnected)
+}
+
+func TestMemoryBroker_RequeueOnError(t *testing.T) {
+	b := messaging.NewMemoryBroker()
+	var attempts atomic.Int32
+	_, err := b.Subscribe(context.Background(), "jobs", messaging.SubscribeOptions{}, func(ctx context.Context, d *messaging.Delivery) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("retry")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, b.PublishMessage(context.Background(), messaging.Message{Topic: "jobs"}))
+	b.Wait()
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestMemoryBroker_PropagatesCorrelationID(t *testing.T) {
+	b := messaging.NewMemoryBroker()
+	defer b.Close()
+
+	var header, fromCtx atomic.Value
+	_, err := b.Subscribe(context.Background(), "orders", messaging.SubscribeOptions{}, func(ctx context.Context, d *messaging.Delivery) error {
+		header.Store(d.Headers[messaging.HeaderCorrelationID])
+		fromCtx.Store(logger.CorrelationID(ctx))
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx := logger.WithCorrelationID(context.Background(), "req-7")
+	require.NoError(t, b.PublishMessage(ctx, messaging.Message{Topic: "orders", Value: []byte("x")}))
+	b.Wait()
+
+	assert.Equal(t, "req-7", header.Load())
+	assert.Equal(t, "req-7", fromCtx.Load())
+}
@@ -0,0 +1,28 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevHeaders_AnnotatesResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.DevHeaders())
+	r.GET("/items/:id", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	r.DELETE("/items/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/items/1", nil))
+		assert.Equal(t, "/items/:id", w.Header().Get(middleware.HeaderDebugRoute), method)
+		assert.Contains(t, w.Header().Get(middleware.HeaderDebugHandler), "func", method)
+		assert.Contains(t, w.Header().Get(middleware.HeaderServerTiming), "app;dur=", method)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/endpointstats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointStats_RecordsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stats := endpointstats.NewCollector(time.Hour, 0)
+	r := gin.New()
+	r.Use(middleware.EndpointStats(stats))
+	r.GET("/items/:id", func(c *gin.Context) { c.String(http.StatusOK, "item") })
+	r.POST("/items", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	for _, id := range []string{"1", "2", "3"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/"+id, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"x"}`)))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	routes := stats.Snapshot()
+	require.Len(t, routes, 2, "unmatched paths are not recorded")
+	get := routes[1]
+	assert.Equal(t, "/items/:id", get.Route)
+	assert.Equal(t, int64(3), get.Requests)
+	assert.Equal(t, map[string]int64{"2xx": 3}, get.StatusClasses)
+	assert.Equal(t, int64(12), get.ResponseBytes)
+	post := routes[0]
+	assert.Equal(t, http.MethodPost, post.Method)
+	assert.Equal(t, int64(1), post.Errors)
+	assert.Equal(t, int64(12), post.RequestBytes)
+}
@@ -0,0 +1,54 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newToggleRouter(toggles *middleware.EndpointToggles) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.EndpointToggleMiddleware(toggles))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/orders/:tenant/:id", ok)
+	r.DELETE("/orders/:tenant/:id", ok)
+	r.GET("/reports/daily", ok)
+	return r
+}
+
+func serve(r *gin.Engine, method, path string) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+func TestEndpointToggles_ConfigAndRuntime(t *testing.T) {
+	toggles := middleware.NewEndpointToggles()
+	toggles.LoadConfig([]config.EndpointToggleConfig{{Method: "delete", Path: "/orders/:tenant/:id", Reason: "incident"}})
+	r := newToggleRouter(toggles)
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(r, http.MethodDelete, "/orders/acme/1"))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/orders/acme/1"))
+
+	toggles.Disable("*", "/reports/*", "maintenance", "ops")
+	assert.Equal(t, http.StatusServiceUnavailable, serve(r, http.MethodGet, "/reports/daily"))
+	assert.Len(t, toggles.List(), 2)
+
+	assert.True(t, toggles.Enable("DELETE", "/orders/:tenant/:id"))
+	assert.False(t, toggles.Enable("DELETE", "/orders/:tenant/:id"))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodDelete, "/orders/acme/1"))
+
+	// Reloading config keeps runtime toggles
+	toggles.LoadConfig(nil)
+	toggle, disabled := toggles.Match(http.MethodGet, "/reports/daily")
+	assert.True(t, disabled)
+	assert.Equal(t, middleware.ToggleSourceRuntime, toggle.Source)
+	assert.Equal(t, "ops", toggle.DisabledBy)
+}
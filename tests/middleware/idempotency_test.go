@@ -0,0 +1,146 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/idempotency"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idempotentRouter(store idempotency.Store, created *atomic.Int64, status *atomic.Int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Idempotency(store, middleware.IdempotencyOptions{
+		TTL:         time.Minute,
+		LockTimeout: time.Minute,
+		Required:    []string{"/orders/:tenant"},
+	}))
+	handler := func(c *gin.Context) {
+		n := created.Add(1)
+		if s := status.Load(); s != 0 {
+			c.Status(int(s))
+			return
+		}
+		c.Header("Location", "/orders/"+c.Param("tenant")+"/1")
+		c.JSON(http.StatusCreated, gin.H{"order": n})
+	}
+	r.POST("/orders/:tenant", handler)
+	r.POST("/optional", handler)
+	return r
+}
+
+func postIdempotent(r http.Handler, path, key, body, auth string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	var created, status atomic.Int64
+	r := idempotentRouter(idempotency.NewMemoryStore(), &created, &status)
+
+	first := postIdempotent(r, "/orders/acme", "k1", `{"qty":1}`, "")
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(middleware.IdempotencyReplayedHeader))
+
+	retry := postIdempotent(r, "/orders/acme", "k1", `{"qty":1}`, "")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotencyReplayedHeader))
+	assert.Equal(t, "/orders/acme/1", retry.Header().Get("Location"))
+	assert.Equal(t, int64(1), created.Load())
+
+	// Another caller with the same key gets its own order
+	other := postIdempotent(r, "/orders/acme", "k1", `{"qty":1}`, "Bearer other")
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Equal(t, int64(2), created.Load())
+}
+
+func TestIdempotency_RejectsMisuse(t *testing.T) {
+	var created, status atomic.Int64
+	r := idempotentRouter(idempotency.NewMemoryStore(), &created, &status)
+
+	assert.Equal(t, http.StatusBadRequest, postIdempotent(r, "/orders/acme", "", `{}`, "").Code)
+	assert.Equal(t, http.StatusCreated, postIdempotent(r, "/optional", "", `{}`, "").Code)
+
+	require.Equal(t, http.StatusCreated, postIdempotent(r, "/orders/acme", "k2", `{"qty":1}`, "").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, postIdempotent(r, "/orders/acme", "k2", `{"qty":2}`, "").Code)
+}
+
+func TestIdempotency_InProgressAndServerErrors(t *testing.T) {
+	var created, status atomic.Int64
+	r := idempotentRouter(idempotency.NewMemoryStore(), &created, &status)
+
+	status.Store(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusInternalServerError, postIdempotent(r, "/orders/acme", "k3", `{}`, "").Code)
+	status.Store(0)
+	// Server errors are not kept, so the retry is handled
+	assert.Equal(t, http.StatusCreated, postIdempotent(r, "/orders/acme", "k3", `{}`, "").Code)
+	assert.Equal(t, int64(2), created.Load())
+
+	gin.SetMode(gin.TestMode)
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := gin.New()
+	slow.Use(middleware.Idempotency(idempotency.NewMemoryStore(), middleware.IdempotencyOptions{TTL: time.Minute, LockTimeout: time.Minute}))
+	slow.POST("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+	done := make(chan int)
+	go func() { done <- postIdempotent(slow, "/slow", "k4", `{}`, "").Code }()
+	<-started
+	busy := postIdempotent(slow, "/slow", "k4", `{}`, "")
+	assert.Equal(t, http.StatusConflict, busy.Code)
+	assert.Equal(t, "1", busy.Header().Get("Retry-After"))
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+}
+
+func TestIdempotency_QueryIsPartOfTheRequest(t *testing.T) {
+	var created, status atomic.Int64
+	r := idempotentRouter(idempotency.NewMemoryStore(), &created, &status)
+
+	require.Equal(t, http.StatusCreated, postIdempotent(r, "/orders/acme?notify=1&dry_run=1", "k5", `{}`, "").Code)
+	retry := postIdempotent(r, "/orders/acme?dry_run=1&notify=1", "k5", `{}`, "")
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotencyReplayedHeader), "query order does not matter")
+	assert.Equal(t, http.StatusUnprocessableEntity, postIdempotent(r, "/orders/acme?notify=1&dry_run=0", "k5", `{}`, "").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, postIdempotent(r, "/orders/acme", "k5", `{}`, "").Code)
+	assert.Equal(t, int64(1), created.Load())
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls atomic.Int64
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.Use(middleware.Idempotency(idempotency.NewMemoryStore(), middleware.IdempotencyOptions{TTL: time.Minute, LockTimeout: time.Hour}))
+	r.POST("/flaky", func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, postIdempotent(r, "/flaky", "k6", `{}`, "").Code)
+	// The retry is handled rather than waiting out the lock
+	assert.Equal(t, http.StatusCreated, postIdempotent(r, "/flaky", "k6", `{}`, "").Code)
+	assert.Equal(t, int64(2), calls.Load())
+}
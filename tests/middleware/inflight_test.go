@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/inflight"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlight_TracksAndCancels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := inflight.NewTracker()
+	started := make(chan struct{})
+	var cause error
+	var correlation string
+
+	r := gin.New()
+	r.Use(middleware.InFlight(tracker), middleware.RequestID(nil))
+	r.GET("/slow/:id", func(c *gin.Context) {
+		correlation = c.GetString(response.CorrelationIDKey)
+		close(started)
+		select {
+		case <-c.Request.Context().Done():
+			cause = context.Cause(c.Request.Context())
+			c.Status(http.StatusServiceUnavailable)
+		case <-time.After(5 * time.Second):
+			c.Status(http.StatusOK)
+		}
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/slow/7", nil)
+		req.Header.Set("X-Correlation-ID", "abc-123")
+		r.ServeHTTP(w, req)
+		done <- w
+	}()
+	<-started
+
+	list := tracker.List()
+	require.Len(t, list, 1)
+	assert.Equal(t, "/slow/7", list[0].Path)
+	assert.Equal(t, "/slow/:id", list[0].Route)
+	assert.Equal(t, "abc-123", list[0].CorrelationID)
+	assert.Equal(t, "abc-123", correlation)
+	assert.False(t, list[0].Cancelled)
+
+	_, ok := tracker.Cancel("missing")
+	assert.False(t, ok)
+	cancelled, ok := tracker.Cancel(list[0].ID)
+	require.True(t, ok)
+	assert.True(t, cancelled.Cancelled)
+
+	select {
+	case w := <-done:
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("request was not cancelled")
+	}
+	assert.ErrorIs(t, cause, inflight.ErrCancelled)
+	assert.Zero(t, tracker.Len(), "finished requests are removed")
+}
@@ -0,0 +1,47 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func mtlsRequest(cn string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if cn != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return req
+}
+
+func TestMTLS_ExposesClientCN(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.MTLS([]string{"billing-worker", "reporting"}))
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.ClientCN(c)+"|"+c.GetString("username"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, mtlsRequest("billing-worker"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "billing-worker|billing-worker", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, mtlsRequest("intruder"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Plain HTTP or an unverified certificate
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, mtlsRequest(""))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
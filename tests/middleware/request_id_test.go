@@ -0,0 +1,52 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID_ScopesLoggerAndEnvelope(t *testing.T) {
+	var logs bytes.Buffer
+	l := logger.NewQuiet(false, &logs)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID(l))
+	r.GET("/items", func(c *gin.Context) {
+		l.Ctx(c.Request.Context()).Info("listing items")
+		assert.Equal(t, c.Writer.Header().Get("X-Request-ID"), logger.CorrelationID(c.Request.Context()))
+		response.Success(c, nil)
+	})
+
+	// Generated IDs reach the header, the envelope and the log line
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	id := w.Header().Get("X-Request-ID")
+	require.NotEmpty(t, id)
+	var body struct {
+		CorrelationID string `json:"correlation_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, id, body.CorrelationID)
+	assert.Contains(t, logs.String(), id)
+
+	// A client supplied correlation ID is kept
+	logs.Reset()
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("X-Correlation-ID", "corr-1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "corr-1", w.Header().Get("X-Request-ID"))
+	assert.Contains(t, logs.String(), "corr-1")
+}
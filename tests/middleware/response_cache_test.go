@@ -0,0 +1,115 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	hits := 0
+	engine.GET("/products", middleware.CacheResponses(cache.NewLRU(10), time.Minute), func(c *gin.Context) {
+		hits++
+		c.JSON(http.StatusOK, gin.H{"hits": hits, "page": c.Query("page")})
+	})
+	fetch := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	first := fetch("/products?page=1&size=10")
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	again := fetch("/products?size=10&page=1")
+	assert.Equal(t, "HIT", again.Header().Get("X-Cache"), "query order does not matter")
+	assert.Equal(t, first.Body.String(), again.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", again.Header().Get("Content-Type"))
+
+	assert.Equal(t, "MISS", fetch("/products?page=2&size=10").Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", fetch("/products?page=1&size=10", "Authorization", "Bearer other").Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", fetch("/products?page=1&size=10", "Cache-Control", "no-cache").Header().Get("X-Cache"))
+	assert.Equal(t, 4, hits)
+}
+
+func TestCacheResponses_PerCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if tenant := c.GetHeader("X-Tenant-ID"); tenant != "" {
+			c.Set("tenant", tenant)
+		}
+	})
+	engine.GET("/me", middleware.CacheResponses(cache.NewLRU(10), time.Minute), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"key": c.GetHeader("X-API-Key"), "tenant": c.GetString("tenant")})
+	})
+	fetch := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	alice := fetch("X-API-Key", "alice-key")
+	assert.Equal(t, "MISS", alice.Header().Get("X-Cache"))
+	bob := fetch("X-API-Key", "bob-key")
+	assert.Equal(t, "MISS", bob.Header().Get("X-Cache"))
+	assert.Contains(t, bob.Body.String(), "bob-key")
+	assert.NotContains(t, bob.Body.String(), "alice-key")
+
+	again := fetch("X-API-Key", "alice-key")
+	assert.Equal(t, "HIT", again.Header().Get("X-Cache"))
+	assert.Equal(t, alice.Body.String(), again.Body.String())
+
+	other := fetch("X-API-Key", "alice-key", "X-Tenant-ID", "acme")
+	assert.Equal(t, "MISS", other.Header().Get("X-Cache"), "tenants are cached apart")
+	assert.Contains(t, other.Body.String(), `"tenant":"acme"`)
+}
+
+func TestCacheAuthenticatedResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("username", user)
+		}
+	})
+	hits := 0
+	engine.GET("/me", middleware.CacheAuthenticatedResponses(cache.NewLRU(10), time.Minute), func(c *gin.Context) {
+		hits++
+		c.JSON(http.StatusOK, gin.H{"user": middleware.GetUsername(c), "hits": hits})
+	})
+	fetch := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without an identity to key by, nothing is cached
+	assert.Empty(t, fetch("").Header().Get("X-Cache"))
+	assert.Empty(t, fetch("").Header().Get("X-Cache"))
+
+	assert.Equal(t, "MISS", fetch("ada").Header().Get("X-Cache"))
+	assert.Equal(t, "HIT", fetch("ada").Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", fetch("bob").Header().Get("X-Cache"))
+	assert.Equal(t, 4, hits)
+}
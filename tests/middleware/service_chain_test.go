@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceEngine registers /api/:service/hits on a group made for each
+// service's middleware config, behind a global "jwt" middleware.
+func serviceEngine(t *testing.T, services map[string]config.ServiceMiddlewareConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Auth: config.AuthConfig{Type: "jwt", Secret: "test-secret"}}
+	engine := gin.New()
+	var chain middleware.Chain
+	chain.Use(engine, "jwt", middleware.JWTRequired("test-secret"))
+	api := engine.Group("/api")
+	for name, mc := range services {
+		group, err := middleware.ServiceGroup(api, &chain, mc, cfg, logger.NewQuiet(false, nil))
+		require.NoError(t, err)
+		hits := 0
+		group.GET("/"+name+"/hits", func(c *gin.Context) {
+			hits++
+			c.JSON(http.StatusOK, gin.H{"hits": hits, "role": c.GetString("role")})
+		})
+	}
+	return engine
+}
+
+func get(engine *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestServiceGroup(t *testing.T) {
+	engine := serviceEngine(t, map[string]config.ServiceMiddlewareConfig{
+		"global":  {},
+		"public":  {Auth: middleware.ServiceAuthNone},
+		"admin":   {Auth: middleware.ServiceAuthJWT, Roles: []string{"admin"}},
+		"limited": {Auth: middleware.ServiceAuthNone, RateLimit: config.ServiceRateLimit{Requests: 2}},
+		"cached":  {Skip: []string{"jwt"}, Cache: config.ServiceCacheConfig{TTL: 60}},
+	})
+	admin, err := middleware.GenerateToken("1", "ada", "ada@example.com", "admin", "test-secret", time.Hour)
+	require.NoError(t, err)
+	user, err := middleware.GenerateToken("2", "bob", "bob@example.com", "user", "test-secret", time.Hour)
+	require.NoError(t, err)
+
+	// The global chain applies unless the service overrides it
+	assert.Equal(t, http.StatusUnauthorized, get(engine, "/api/global/hits", "").Code)
+	assert.Equal(t, http.StatusOK, get(engine, "/api/global/hits", user).Code)
+	assert.Equal(t, http.StatusOK, get(engine, "/api/public/hits", "").Code)
+
+	assert.Equal(t, http.StatusUnauthorized, get(engine, "/api/admin/hits", "").Code)
+	assert.Equal(t, http.StatusForbidden, get(engine, "/api/admin/hits", user).Code)
+	w := get(engine, "/api/admin/hits", admin)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"admin"`)
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, get(engine, "/api/limited/hits", "").Code, "request "+strconv.Itoa(i))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get(engine, "/api/limited/hits", "").Code)
+
+	// Cached per URL and caller
+	first := get(engine, "/api/cached/hits", "")
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	second := get(engine, "/api/cached/hits", "")
+	assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "MISS", get(engine, "/api/cached/hits", user).Header().Get("X-Cache"))
+	assert.Equal(t, "MISS", get(engine, "/api/cached/hits?page=2", "").Header().Get("X-Cache"))
+}
+
+func TestCheckServiceMiddleware(t *testing.T) {
+	assert.NoError(t, middleware.CheckServiceMiddleware(config.ServiceMiddlewareConfig{Auth: "jwt", Roles: []string{"admin"}, Use: []string{"security"}}))
+	assert.ErrorContains(t, middleware.CheckServiceMiddleware(config.ServiceMiddlewareConfig{Auth: "basic"}), "auth must be")
+	assert.ErrorContains(t, middleware.CheckServiceMiddleware(config.ServiceMiddlewareConfig{Auth: "none", Roles: []string{"admin"}}), "roles need authentication")
+	assert.ErrorContains(t, middleware.CheckServiceMiddleware(config.ServiceMiddlewareConfig{Skip: []string{"nosuch"}}), `unknown middleware "nosuch"`)
+}
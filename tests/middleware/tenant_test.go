@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantRequest serves target through the tenant middleware and returns
+// the status and the tenant the handler saw.
+func tenantRequest(t *testing.T, opts middleware.TenantOptions, host, target string, header map[string]string) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Tenant(opts))
+	var seen string
+	handler := func(c *gin.Context) {
+		seen = tenancy.FromContext(c.Request.Context())
+		assert.Equal(t, seen, c.GetString("tenant"))
+		c.Status(http.StatusNoContent)
+	}
+	r.GET("/orders/:tenant", handler)
+	r.GET("/orders", handler)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if host != "" {
+		req.Host = host
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, seen
+}
+
+func tenantToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+func TestTenant_Sources(t *testing.T) {
+	opts := middleware.TenantOptions{BaseDomain: "example.com", Secret: "s3cret"}
+
+	code, tenant := tenantRequest(t, opts, "", "/orders/acme", map[string]string{tenancy.HeaderTenantID: "globex"})
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Equal(t, "acme", tenant, "the path comes first by default")
+
+	_, tenant = tenantRequest(t, opts, "", "/orders", map[string]string{tenancy.HeaderTenantID: "globex"})
+	assert.Equal(t, "globex", tenant)
+
+	_, tenant = tenantRequest(t, opts, "Initech.Example.com:8080", "/orders", nil)
+	assert.Equal(t, "initech", tenant)
+	_, tenant = tenantRequest(t, opts, "a.b.example.com", "/orders", nil)
+	assert.Empty(t, tenant, "only a single label names a tenant")
+	_, tenant = tenantRequest(t, opts, "example.com", "/orders", nil)
+	assert.Empty(t, tenant)
+
+	auth := tenantToken(t, "s3cret", jwt.MapClaims{"tenant_id": "umbrella"})
+	_, tenant = tenantRequest(t, opts, "", "/orders", map[string]string{"Authorization": auth})
+	assert.Equal(t, "umbrella", tenant)
+	forged := tenantToken(t, "other", jwt.MapClaims{"tenant_id": "umbrella"})
+	_, tenant = tenantRequest(t, opts, "", "/orders", map[string]string{"Authorization": forged})
+	assert.Empty(t, tenant, "tokens with a bad signature name no tenant")
+
+	// Custom order, header name and claim
+	opts = middleware.TenantOptions{
+		Sources: []string{middleware.TenantFromJWT, middleware.TenantFromHeader},
+		Header:  "X-Org",
+		Claim:   "org",
+		Secret:  "s3cret",
+	}
+	auth = tenantToken(t, "s3cret", jwt.MapClaims{"org": "hooli"})
+	_, tenant = tenantRequest(t, opts, "", "/orders/acme", map[string]string{"Authorization": auth, "X-Org": "globex"})
+	assert.Equal(t, "hooli", tenant)
+	_, tenant = tenantRequest(t, opts, "", "/orders/acme", map[string]string{"X-Org": "globex"})
+	assert.Equal(t, "globex", tenant)
+	_, tenant = tenantRequest(t, opts, "", "/orders/acme", nil)
+	assert.Empty(t, tenant, "the path is not a source")
+}
+
+func TestTenant_Registry(t *testing.T) {
+	registry := tenancy.NewRegistry()
+	opts := middleware.TenantOptions{Registry: registry, Required: true}
+
+	code, _ := tenantRequest(t, opts, "", "/orders", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = tenantRequest(t, opts, "", "/orders/bad%20id", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// An empty registry accepts any tenant
+	code, tenant := tenantRequest(t, opts, "", "/orders/anyone", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Equal(t, "anyone", tenant)
+
+	require.NoError(t, registry.Register(tenancy.Tenant{ID: "acme"}))
+	require.NoError(t, registry.Register(tenancy.Tenant{ID: "globex", Disabled: true}))
+	code, _ = tenantRequest(t, opts, "", "/orders/acme", nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = tenantRequest(t, opts, "", "/orders/anyone", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = tenantRequest(t, opts, "", "/orders/globex", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+}
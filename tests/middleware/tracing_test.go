@@ -0,0 +1,79 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing_ContinuesTraceAndCorrelates(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := tracing.Install(config.TracingConfig{SampleRatio: 1}, recorder, nil)
+	defer provider.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Tracing())
+	r.GET("/items/:id", func(c *gin.Context) { response.Success(c, gin.H{"id": c.Param("id")}) })
+	r.GET("/boom", func(c *gin.Context) { response.InternalServerError(c, "boom") })
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/items/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, traceID, w.Header().Get(middleware.HeaderTraceID))
+	var body struct {
+		CorrelationID string `json:"correlation_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, traceID, body.CorrelationID)
+
+	// An explicit request ID wins over the trace ID
+	req = httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "req-1", body.CorrelationID)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "GET /items/:id", spans[0].Name())
+	assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, "GET /boom", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestTracing_SampleRatioZero(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := tracing.Install(config.TracingConfig{SampleRatio: 0}, recorder, nil)
+	defer provider.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Tracing())
+	r.GET("/items/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	assert.Empty(t, recorder.Ended(), "no trace is started")
+
+	req := httptest.NewRequest(http.MethodGet, "/items/2", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Len(t, recorder.Ended(), 1, "sampled callers are followed")
+}
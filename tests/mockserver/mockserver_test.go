@@ -0,0 +1,76 @@
+package mockserver_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/mockserver"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startMock(t *testing.T, cfg config.MockConfig) *mockserver.Server {
+	t.Helper()
+	cfg.Port = "0"
+	mock := mockserver.New(cfg, logger.NewQuiet(false, io.Discard))
+	require.NoError(t, mock.Start())
+	t.Cleanup(func() { mock.Close() })
+	return mock
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestMockServer_ServesRoutesForRewrittenURLs(t *testing.T) {
+	mock := startMock(t, config.MockConfig{
+		DefaultStatus: http.StatusNotFound,
+		Routes: []config.MockRouteConfig{
+			{Service: "payments", Method: "GET", Path: "/health", Body: `{"status":"ok"}`, Latency: 30},
+			{Service: "payments", Path: "/v1/*", Status: http.StatusAccepted},
+			{Path: "/shared", Status: http.StatusTeapot},
+		},
+	})
+
+	url := mock.RewriteURL("payments", "https://payments.example.com/health?deep=1")
+	assert.Equal(t, mock.URL()+"/payments/health?deep=1", url)
+
+	start := time.Now()
+	status, body := get(t, url)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"status":"ok"}`, body)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	status, _ = get(t, mock.URL()+"/payments/v1/charges/42")
+	assert.Equal(t, http.StatusAccepted, status)
+	status, _ = get(t, mock.URL()+"/shared")
+	assert.Equal(t, http.StatusTeapot, status)
+	status, _ = get(t, mock.URL()+"/billing/health")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	stats := mock.GetStatus()
+	assert.Equal(t, int64(1), stats["unmatched"])
+}
+
+func TestMockServer_InjectsErrors(t *testing.T) {
+	mock := startMock(t, config.MockConfig{Routes: []config.MockRouteConfig{
+		{Service: "flaky", Path: "/status", ErrorRate: 1},
+		{Service: "broken", Path: "/status", ErrorRate: 1, Fault: mockserver.FaultReset},
+	}})
+
+	status, _ := get(t, mock.URL()+"/flaky/status")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	_, err := http.Get(mock.URL() + "/broken/status")
+	assert.Error(t, err)
+}
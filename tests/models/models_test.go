@@ -0,0 +1,151 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"stackyrd/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ddlDriver answers every query with a zero count, so GORM sees no table
+// yet, and records the statements executed per connection. Statements on
+// connections named "broken" fail.
+type ddlDriver struct {
+	mu    sync.Mutex
+	execs map[string][]string
+}
+
+func (d *ddlDriver) Open(name string) (driver.Conn, error) { return &ddlConn{name: name, driver: d}, nil }
+
+func (d *ddlDriver) executed(name string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.execs[name]...)
+}
+
+type ddlConn struct {
+	name   string
+	driver *ddlDriver
+}
+
+func (c *ddlConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *ddlConn) Close() error                        { return nil }
+func (c *ddlConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *ddlConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &countRows{}, nil
+}
+
+func (c *ddlConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if c.name == "broken" {
+		return nil, errors.New("permission denied for schema public")
+	}
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	if c.driver.execs == nil {
+		c.driver.execs = make(map[string][]string)
+	}
+	c.driver.execs[c.name] = append(c.driver.execs[c.name], query)
+	return driver.RowsAffected(0), nil
+}
+
+type countRows struct{ done bool }
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(0)
+	return nil
+}
+
+var ddl = &ddlDriver{}
+
+func init() { sql.Register("ddl", ddl) }
+
+func openORM(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("ddl", name)
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return db
+}
+
+type Invoice struct {
+	ID     uint `gorm:"primaryKey"`
+	Number string
+}
+
+type Ledger struct {
+	ID      uint `gorm:"primaryKey"`
+	Balance int64
+}
+
+func TestMigrate(t *testing.T) {
+	models.Register("billing_service", models.DefaultConnection, &Invoice{})
+	models.Register("ledger_service", models.AllConnections, &Ledger{})
+	models.Register("disabled_service", models.DefaultConnection, &Invoice{})
+	models.Register("billing_service", "archive", &Invoice{}) // not connected
+
+	dbs := map[string]*gorm.DB{
+		"main":   openORM(t, "main"),
+		"tenant": openORM(t, "tenant"),
+		"broken": openORM(t, "broken"),
+	}
+	enabled := func(service string) bool { return service != "disabled_service" }
+	results := models.Migrate(context.Background(), dbs, "main", enabled)
+
+	require.Len(t, results, 4, "invoices on the default connection, ledgers on each")
+	assert.Equal(t, "billing_service", results[0].Service)
+	assert.Equal(t, "main", results[0].Connection)
+	assert.Equal(t, "models_test.Invoice", results[0].Model)
+	assert.Equal(t, "invoices", results[0].Table)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, []models.Column{{Name: "id", Type: "bigserial", PrimaryKey: true}, {Name: "number", Type: "text"}}, results[0].Columns)
+
+	var ledgers []string
+	for _, result := range results[1:] {
+		assert.Equal(t, "ledgers", result.Table)
+		ledgers = append(ledgers, result.Connection)
+	}
+	assert.Equal(t, []string{"broken", "main", "tenant"}, ledgers)
+	assert.Error(t, results[1].Err)
+	assert.Contains(t, results[1].Error, "permission denied")
+	assert.NoError(t, results[3].Err, "a failing connection does not stop the others")
+
+	var created []string
+	for _, query := range ddl.executed("main") {
+		if strings.HasPrefix(query, "CREATE TABLE") {
+			created = append(created, query)
+		}
+	}
+	require.Len(t, created, 2)
+	assert.Contains(t, created[0], `"invoices"`)
+
+	applied := models.Applied()
+	require.Len(t, applied, 4)
+	assert.Equal(t, "broken", applied[0].Connection)
+	assert.NotEmpty(t, applied[0].Error)
+}
+
+func TestModelName(t *testing.T) {
+	assert.Equal(t, "models_test.Invoice", models.ModelName(&Invoice{}))
+	assert.Equal(t, "models_test.Invoice", models.ModelName(Invoice{}))
+	assert.Equal(t, "<nil>", models.ModelName(nil))
+}
@@ -0,0 +1,236 @@
+package monitorclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/monitorclient"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer serves the monitoring API of cfg with the access keys
+// view-key, op-key and admin-key.
+func newServer(t *testing.T, cfg *config.Config, deps *registry.Dependencies) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	cfg.Monitoring.Access = config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{
+			{Name: "dashboard", Key: "view-key", Role: "viewer"},
+			{Name: "oncall", Key: "op-key", Role: "operator"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+		},
+	}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newClient(t *testing.T, url string, opts monitorclient.Options) *monitorclient.Client {
+	client, err := monitorclient.New(url, opts)
+	require.NoError(t, err)
+	return client
+}
+
+func TestClient_StatusQueriesAndErrors(t *testing.T) {
+	deps := registry.NewDependencies()
+	deps.Set("querybook", querybook.New(nil, 10))
+	cfg := &config.Config{}
+	cfg.App.Name = "orders"
+	server := newServer(t, cfg, deps)
+	ctx := context.Background()
+
+	viewer := newClient(t, server.URL, monitorclient.Options{APIKey: "view-key"})
+	assert.Equal(t, server.URL+"/api", viewer.BaseURL())
+	status, err := viewer.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", status.App.Name)
+
+	jobs, err := viewer.CronJobs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	// Role and validation errors keep the API's status and code
+	_, err = viewer.CreateQuery(ctx, querybook.SavedQuery{Name: "locks", Engine: "postgres", Query: "SELECT 1"})
+	assert.True(t, monitorclient.IsStatus(err, http.StatusForbidden), err)
+	bad := newClient(t, server.URL, monitorclient.Options{APIKey: "nope"})
+	_, err = bad.Status(ctx)
+	assert.True(t, monitorclient.IsStatus(err, http.StatusUnauthorized), err)
+
+	operator := newClient(t, server.URL, monitorclient.Options{APIKey: "op-key"})
+	saved, err := operator.CreateQuery(ctx, querybook.SavedQuery{Name: "locks", Engine: "postgres", Query: "SELECT * FROM pg_locks"})
+	require.NoError(t, err)
+	assert.Equal(t, "oncall", saved.CreatedBy)
+	_, err = operator.CreateQuery(ctx, *saved)
+	assert.True(t, monitorclient.IsConflict(err), err)
+
+	queries, err := viewer.Queries(ctx, "postgres")
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "locks", queries[0].Name)
+
+	require.NoError(t, operator.DeleteQuery(ctx, "locks"))
+	_, err = viewer.Query(ctx, "locks")
+	assert.True(t, monitorclient.IsNotFound(err), err)
+
+	// Endpoints without a typed call go through Get and Do
+	var endpoints map[string]interface{}
+	require.NoError(t, viewer.Get(ctx, "/endpoints", &endpoints))
+}
+
+func TestClient_ConfigSections(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"),
+		[]byte("app:\n  name: orders\nredis:\n  enabled: false\n  password: hunter2\n"), 0o600))
+	t.Chdir(dir)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+	server := newServer(t, cfg, registry.NewDependencies())
+	ctx := context.Background()
+	admin := newClient(t, server.URL, monitorclient.Options{APIKey: "admin-key"})
+
+	sections, err := admin.ConfigSections(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, sections)
+
+	section, err := admin.ConfigSection(ctx, "redis")
+	require.NoError(t, err)
+	assert.Equal(t, config.SecretMask, section.Value.(map[string]interface{})["password"])
+
+	value := section.Value.(map[string]interface{})
+	value["enabled"] = true
+	saved, err := admin.SaveConfigSection(ctx, "redis", value, section.Version)
+	require.NoError(t, err)
+	assert.NotEqual(t, section.Version, saved.Section.Version)
+
+	// The old version no longer matches
+	_, err = admin.SaveConfigSection(ctx, "redis", value, section.Version)
+	assert.True(t, monitorclient.IsConflict(err), err)
+	data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "hunter2")
+
+	schema, err := admin.ConfigSchema(ctx, "redis")
+	require.NoError(t, err)
+	assert.Equal(t, "object", schema["type"])
+
+	effective, err := admin.EffectiveConfig(ctx, "app", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, effective.Keys)
+}
+
+func TestClient_FollowLogs(t *testing.T) {
+	logs := logger.NewLogBroadcaster(100)
+	deps := registry.NewDependencies()
+	deps.Set("logs", logs)
+	server := newServer(t, &config.Config{}, deps)
+	client := newClient(t, server.URL, monitorclient.Options{APIKey: "view-key"})
+
+	logs.Publish(logger.LogEntry{Message: "first"})
+	batch, err := client.Logs(context.Background(), 0, 0)
+	require.NoError(t, err)
+	require.Len(t, batch.Entries, 1)
+	assert.Equal(t, uint64(1), batch.Cursor)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		logs.Publish(logger.LogEntry{Message: "second"})
+		logs.Publish(logger.LogEntry{Message: "third"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var messages []string
+	err = client.FollowLogs(ctx, batch.Cursor, func(batch monitorclient.LogBatch) error {
+		for _, e := range batch.Entries {
+			messages = append(messages, e.Message)
+		}
+		if len(messages) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"second", "third"}, messages)
+}
+
+func TestClient_RetriesAndLogin(t *testing.T) {
+	var mu sync.Mutex
+	var logins, calls atomic.Int32
+	token := "token-1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/auth/login":
+			logins.Add(1)
+			var creds map[string]string
+			json.NewDecoder(r.Body).Decode(&creds)
+			if creds["password"] != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"success":false,"error":{"code":"UNAUTHORIZED","message":"Invalid credentials"}}`))
+				return
+			}
+			w.Write([]byte(`{"success":true,"data":{"token":"` + token + `"}}`))
+		case "/api/status":
+			// The first call fails over, then the token expires once
+			switch calls.Add(1) {
+			case 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			case 2:
+				token = "token-2"
+			}
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"success":false,"error":{"code":"UNAUTHORIZED","message":"Invalid token"}}`))
+				return
+			}
+			w.Write([]byte(`{"success":true,"data":{"app":{"name":"orders"}}}`))
+		case "/api/restart":
+			calls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client := newClient(t, server.URL, monitorclient.Options{Username: "ops", Password: "secret", RetryDelay: time.Millisecond})
+	status, err := client.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", status.App.Name)
+	assert.Equal(t, int32(2), logins.Load())
+	assert.Equal(t, int32(3), calls.Load())
+
+	// POSTs are not retried
+	calls.Store(0)
+	err = client.Do(ctx, http.MethodPost, "/restart", nil, nil)
+	assert.True(t, monitorclient.IsStatus(err, http.StatusServiceUnavailable), err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	wrong := newClient(t, server.URL, monitorclient.Options{Username: "ops", Password: "wrong"})
+	assert.True(t, monitorclient.IsStatus(wrong.Login(ctx), http.StatusUnauthorized))
+
+	_, err = monitorclient.New("localhost:8080", monitorclient.Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPI(t *testing.T, access config.AccessConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.Auth = config.AuthConfig{Type: "jwt", Secret: "test-secret"}
+	cfg.Monitoring.Access = access
+	m := monitoring.New(cfg, logger.New(false, nil), registry.NewDependencies(), nil)
+	r := gin.New()
+	m.RegisterRoutes(r.Group("/api"))
+	return r
+}
+
+func call(r *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAccess_Roles(t *testing.T) {
+	r := newAPI(t, config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{
+			{Name: "dashboard", Key: "view-key", Role: "viewer"},
+			{Name: "oncall", Key: "ops-key", Role: "operator"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+		},
+	})
+	key := func(k string) map[string]string { return map[string]string{"X-API-Key": k} }
+
+	// Status is readable by viewers
+	assert.Equal(t, http.StatusOK, call(r, "GET", "/api/status", key("view-key")).Code)
+
+	// Actions need an operator; the route's own response (404: no buffer) shows it passed
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/messaging/buffer/flush", key("view-key")).Code)
+	assert.NotEqual(t, http.StatusForbidden, call(r, "POST", "/api/messaging/buffer/flush", key("ops-key")).Code)
+
+	// Config edits need an admin
+	assert.Equal(t, http.StatusForbidden, call(r, "GET", "/api/config/sections", key("view-key")).Code)
+	assert.Equal(t, http.StatusForbidden, call(r, "PUT", "/api/config/section/app", key("ops-key")).Code)
+	assert.NotEqual(t, http.StatusForbidden, call(r, "PUT", "/api/config/section/app", key("admin-key")).Code)
+
+	// Unknown keys and anonymous callers are rejected
+	assert.Equal(t, http.StatusUnauthorized, call(r, "GET", "/api/status", key("nope")).Code)
+	assert.Equal(t, http.StatusUnauthorized, call(r, "GET", "/api/status", nil).Code)
+}
+
+func TestAccess_UsersAndDefaultRole(t *testing.T) {
+	r := newAPI(t, config.AccessConfig{
+		Enabled:     true,
+		DefaultRole: "viewer",
+		Users:       map[string]string{"alice": "admin"},
+	})
+	bearer := func(username, role string) map[string]string {
+		token, err := middleware.GenerateToken("1", username, username+"@example.com", role, "test-secret", time.Hour)
+		require.NoError(t, err)
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+	whoami := func(headers map[string]string) map[string]interface{} {
+		w := call(r, "GET", "/api/access/whoami", headers)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	// The configured role overrides the token's claim
+	me := whoami(bearer("alice", "viewer"))
+	assert.Equal(t, "alice", me["name"])
+	assert.Equal(t, "admin", me["role"])
+	assert.Equal(t, "user", me["via"])
+
+	// Other users get the default role whatever their token claims
+	assert.Equal(t, "viewer", whoami(bearer("bob", "admin"))["role"])
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/messaging/buffer/flush", bearer("bob", "admin")).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, call(r, "GET", "/api/status", map[string]string{"Authorization": "Bearer forged"}).Code)
+
+	anonymous := whoami(nil)
+	assert.Equal(t, "viewer", anonymous["role"])
+	assert.Equal(t, "default", anonymous["via"])
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/messaging/buffer/flush", nil).Code)
+}
+
+func TestAccess_Disabled(t *testing.T) {
+	r := newAPI(t, config.AccessConfig{})
+
+	// Everyone may read, nobody may act
+	assert.Equal(t, http.StatusOK, call(r, "GET", "/api/status", nil).Code)
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/messaging/buffer/flush", nil).Code)
+	assert.Equal(t, http.StatusForbidden, call(r, "PUT", "/api/config/section/app", nil).Code)
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/restart", nil).Code)
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/postgres/query", nil).Code)
+
+	w := call(r, "GET", "/api/access/whoami", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+}
+
+// adminConfig returns a config granting anonymous callers the admin role,
+// for tests of the routes rather than of access control.
+func adminConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Monitoring.Access = config.AccessConfig{Enabled: true, DefaultRole: "admin"}
+	return cfg
+}
+
+func TestRequiredRole(t *testing.T) {
+	assert.Equal(t, monitoring.RoleViewer, monitoring.RequiredRole("GET", "/jobs/:id"))
+	assert.Equal(t, monitoring.RoleOperator, monitoring.RequiredRole("POST", "/messaging/buffer/flush"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("POST", "/tenants/:tenant/deletion"))
+	assert.Equal(t, monitoring.RoleViewer, monitoring.RequiredRole("PUT", "/preferences"))
+	assert.Equal(t, monitoring.RoleOperator, monitoring.RequiredRole("GET", "/postgres/schema"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("GET", "/mongo/collections/:collection/documents"))
+	assert.Equal(t, monitoring.RoleViewer, monitoring.RequiredRole("GET", "/mongo/collections/:collection/indexes"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("DELETE", "/mongo/collections/:collection/indexes/:name"))
+	assert.Equal(t, monitoring.RoleViewer, monitoring.RequiredRole("GET", "/mongo/indexes"))
+	assert.Equal(t, monitoring.RoleViewer, monitoring.RequiredRole("GET", "/redis/keys"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("GET", "/redis/key"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("DELETE", "/redis/key"))
+	assert.Equal(t, monitoring.RoleOperator, monitoring.RequiredRole("GET", "/redis/slowlog"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("POST", "/accounts/disable"))
+	assert.Equal(t, monitoring.RoleAdmin, monitoring.RequiredRole("GET", "/kafka/topics/:topic/tail"))
+	assert.Equal(t, monitoring.RoleViewer, monitoring.RequiredRole("GET", "/kafka/topics"))
+
+	role, ok := monitoring.ParseRole("Operator")
+	assert.True(t, ok)
+	assert.Equal(t, monitoring.RoleOperator, role)
+	_, ok = monitoring.ParseRole("none")
+	assert.False(t, ok)
+}
@@ -0,0 +1,215 @@
+package monitoring_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/accounts"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMailer struct {
+	to   []string
+	body string
+	err  error
+}
+
+func (f *fakeMailer) Send(_ context.Context, to []string, _, body string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.to, f.body = append(f.to, to...), body
+	return nil
+}
+
+func TestAccounts_InviteAndLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	directory, err := accounts.New(nil, accounts.Options{MinPasswordLength: 8})
+	require.NoError(t, err)
+	mailer := &fakeMailer{}
+	deps := registry.NewDependencies()
+	deps.Set("accounts", directory)
+	deps.Set("mail", mailer)
+
+	cfg := &config.Config{}
+	cfg.Auth = config.AuthConfig{Type: "jwt", Secret: "test-secret"}
+	cfg.Monitoring.Accounts = config.AccountsConfig{Enabled: true, SetupURL: "https://ops.example.com/setup", TokenTTL: 3600}
+	cfg.Monitoring.Access = config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{{Name: "root", Key: "admin-key", Role: "admin"}},
+	}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	send := func(method, path string, headers map[string]string, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data  map[string]interface{} `json:"data"`
+			Error map[string]interface{} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Data == nil {
+			resp.Data = resp.Error
+		}
+		return w.Code, resp.Data
+	}
+	admin := map[string]string{"X-API-Key": "admin-key"}
+
+	// Only admins manage accounts
+	code, _ := send("POST", "/api/accounts", nil, `{}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, data := send("POST", "/api/accounts", admin, `{"accounts":[
+		{"username":"ada","email":"ada@example.com","role":"operator"},
+		{"username":"bob","email":"bob@example.com","role":"viewer","password":"initial pw"},
+		{"username":"ada","email":"ada@example.com","role":"viewer"}]}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(2), data["created"])
+	assert.Equal(t, float64(1), data["failed"])
+	assert.Equal(t, []string{"ada@example.com"}, mailer.to)
+
+	// The emailed link carries the setup token
+	start := strings.Index(mailer.body, "https://")
+	link, err := url.Parse(strings.Fields(mailer.body[start:])[0])
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+	require.NotEmpty(t, token)
+
+	code, _ = send("POST", "/api/accounts/setup", nil, `{"token":"`+token+`","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, code)
+	code, data = send("POST", "/api/auth/login", nil, `{"username":"ada","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, code)
+	bearer := map[string]string{"Authorization": "Bearer " + data["token"].(string)}
+
+	// The account's role applies to its token
+	code, data = send("GET", "/api/access/whoami", bearer, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "operator", data["role"])
+
+	// Accounts with an initial password must change it first
+	code, data = send("POST", "/api/auth/login", nil, `{"username":"bob","password":"initial pw"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "PASSWORD_RESET_REQUIRED", data["code"])
+	code, _ = send("POST", "/api/auth/password", nil, `{"username":"bob","current_password":"initial pw","new_password":"battery staple"}`)
+	assert.Equal(t, http.StatusOK, code)
+
+	// A password change revokes the tokens issued before
+	code, data = send("POST", "/api/auth/password", nil, `{"username":"ada","current_password":"correct horse","new_password":"battery staple"}`)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = send("GET", "/api/status", bearer, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	bearer = map[string]string{"Authorization": "Bearer " + data["token"].(string)}
+	code, _ = send("GET", "/api/status", bearer, "")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Disabling revokes issued tokens at once
+	code, _ = send("POST", "/api/accounts/disable", admin, `{"usernames":["ada"]}`)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = send("GET", "/api/status", bearer, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Without a working mailer the admin gets the link to pass on
+	mailer.err = errors.New("smtp down")
+	code, data = send("POST", "/api/accounts/bob/invite", admin, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, data["sent"])
+	assert.Contains(t, data["setup_link"], "https://ops.example.com/setup?token=")
+
+	account, ok := directory.Get("ada")
+	require.True(t, ok)
+	assert.NotNil(t, account.LastActivityAt)
+}
+
+func TestAccounts_LoginTokensWithoutJWTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	directory, err := accounts.New(nil, accounts.Options{MinPasswordLength: 8})
+	require.NoError(t, err)
+	_, invitation, err := directory.Create(accounts.NewAccount{Username: "ada", Email: "ada@example.com", Role: "operator"}, "admin")
+	require.NoError(t, err)
+	_, err = directory.AcceptInvite(invitation.Token, "correct horse")
+	require.NoError(t, err)
+	deps := registry.NewDependencies()
+	deps.Set("accounts", directory)
+
+	// Logins issue tokens signed with auth.secret whatever auth.type is
+	cfg := &config.Config{}
+	cfg.Auth = config.AuthConfig{Type: "none", Secret: "test-secret"}
+	cfg.Monitoring.Accounts = config.AccountsConfig{Enabled: true, TokenTTL: 3600}
+	cfg.Monitoring.Access = config.AccessConfig{Enabled: true}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"username":"ada","password":"correct horse"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var login struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+
+	// Login tokens are for the monitoring API only
+	_, err = middleware.ParseToken(login.Data.Token, "test-secret")
+	assert.Error(t, err)
+
+	req = httptest.NewRequest("GET", "/api/access/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Data.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"operator"`)
+
+	// Tokens signed with the secret but not issued by a login get nothing
+	for _, token := range []string{"forged", serviceToken(t, "ada", "admin"), monitoringToken(t, "mallory")} {
+		req = httptest.NewRequest("GET", "/api/access/whoami", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// A new invitation revokes the tokens issued before
+	_, err = directory.Invite("ada")
+	require.NoError(t, err)
+	req = httptest.NewRequest("GET", "/api/access/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Data.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func serviceToken(t *testing.T, username, role string) string {
+	token, err := middleware.GenerateToken("1", username, username+"@example.com", role, "test-secret", time.Hour)
+	require.NoError(t, err)
+	return token
+}
+
+func monitoringToken(t *testing.T, username string) string {
+	token, err := middleware.GenerateMonitoringToken(username, username+"@example.com", 0, "test-secret", time.Hour)
+	require.NoError(t, err)
+	return token
+}
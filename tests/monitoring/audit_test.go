@@ -0,0 +1,89 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/audit"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditTrail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, err := audit.NewFileLog(filepath.Join(t.TempDir(), "audit.jsonl"), 0)
+	require.NoError(t, err)
+	defer log.Close()
+	deps := registry.NewDependencies()
+	deps.Set("audit", log)
+
+	cfg := &config.Config{}
+	cfg.Monitoring.Access = config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{
+			{Name: "dashboard", Key: "view-key", Role: "viewer"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+		},
+	}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	send := func(method, path, key, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Reads are not audited, actions are, whether refused or not
+	send("GET", "/api/status", "view-key", "")
+	send("POST", "/api/messaging/buffer/flush", "view-key", "")
+	send("PUT", "/api/config/section/grafana", "admin-key", `{"value":{"api_key":"s3cret","url":"http://g"}}`)
+
+	entries, total, err := log.Search(audit.Query{})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	edit := entries[0]
+	assert.Equal(t, "root", edit.Actor)
+	assert.Equal(t, "admin", edit.Role)
+	assert.Equal(t, "PUT /config/section/*path", edit.Action)
+	assert.Equal(t, "/grafana", edit.Params["path"])
+	raw, _ := json.Marshal(edit.Request)
+	assert.NotContains(t, string(raw), "s3cret", "secrets are masked")
+	assert.Contains(t, string(raw), "http://g")
+
+	denied := entries[1]
+	assert.Equal(t, "dashboard", denied.Actor)
+	assert.Equal(t, http.StatusForbidden, denied.Status)
+	assert.False(t, denied.Success)
+
+	// The audit log is searchable by operators, and reading it is not audited
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/audit", "view-key", ""))
+	req := httptest.NewRequest("GET", "/api/audit?actor=root", nil)
+	req.Header.Set("X-API-Key", "admin-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data []audit.Entry `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, "/api/config/section/grafana", body.Data[0].Path)
+
+	_, total, _ = log.Search(audit.Query{})
+	assert.Equal(t, 2, total)
+}
@@ -0,0 +1,69 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	banner := filepath.Join(t.TempDir(), "banner.txt")
+	require.NoError(t, os.WriteFile(banner, []byte("STACKYRD"), 0o644))
+	cfg := &config.Config{}
+	cfg.App.Name = "stackyrd"
+	cfg.App.BannerPath = banner
+	cfg.Monitoring.SQLReadOnly = true
+
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), registry.NewDependencies(), nil).RegisterRoutes(r.Group("/api"))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/api/bootstrap")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=5", w.Header().Get("Cache-Control"))
+	var body struct {
+		Data struct {
+			Sections map[string]struct {
+				Data   map[string]interface{} `json:"data"`
+				MaxAge int                    `json:"max_age"`
+			} `json:"sections"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	sections := body.Data.Sections
+	for _, name := range []string{"app", "access", "monitoring", "status", "endpoints"} {
+		assert.Contains(t, sections, name)
+	}
+	assert.Equal(t, "STACKYRD", sections["app"].Data["banner"])
+	assert.Equal(t, 3600, sections["app"].MaxAge)
+	// Without monitoring.access every caller is a viewer
+	assert.Equal(t, "viewer", sections["access"].Data["role"])
+	assert.Equal(t, true, sections["monitoring"].Data["sql_readonly"])
+
+	w = get("/api/bootstrap?sections=app,monitoring")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=300", w.Header().Get("Cache-Control"))
+	body.Data.Sections = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Data.Sections, 2)
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/bootstrap?sections=nope").Code)
+}
@@ -0,0 +1,61 @@
+package monitoring_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	banner := filepath.Join(t.TempDir(), "banner.txt")
+	require.NoError(t, os.WriteFile(banner, []byte("v1"), 0o644))
+	cfg := &config.Config{}
+	cfg.App.BannerPath = banner
+
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), registry.NewDependencies(), nil).RegisterRoutes(r.Group("/api"))
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/banner", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get(nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	lastModified := first.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+
+	w := get(map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusNotModified, get(map[string]string{"If-Modified-Since": lastModified}).Code)
+	assert.Equal(t, http.StatusOK, get(map[string]string{"If-None-Match": `W/"other"`}).Code)
+
+	// Editing the banner changes both validators
+	require.NoError(t, os.WriteFile(banner, []byte("v2"), 0o644))
+	later := time.Now().Add(2 * time.Second)
+	require.NoError(t, os.Chtimes(banner, later, later))
+	w = get(map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, get(map[string]string{"If-Modified-Since": lastModified}).Code)
+}
@@ -0,0 +1,131 @@
+package monitoring_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"stackyrd/internal/middleware"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/endpointstats"
+	"stackyrd/pkg/inflight"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/timeseries"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	r := gin.New()
+	monitoring.New(adminConfig(), logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	assert.Equal(t, http.StatusNotFound, call(r, "GET", "/api/metrics/history", nil).Code)
+
+	history := timeseries.New(timeseries.Options{})
+	start := time.Now().Add(-2 * time.Minute).Truncate(time.Minute)
+	history.Add(start, map[string]float64{"cpu_percent": 10, "disk_percent": 50})
+	history.Add(start.Add(time.Minute), map[string]float64{"cpu_percent": 30, "disk_percent": 50})
+	deps.Set("metrics_history", history)
+
+	w := call(r, "GET", "/api/metrics/history?metrics=cpu_percent&resolution=raw", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Resolution string                        `json:"resolution"`
+			Metrics    []string                      `json:"metrics"`
+			Series     map[string][]timeseries.Point `json:"series"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "raw", body.Data.Resolution)
+	assert.Equal(t, []string{"cpu_percent", "disk_percent"}, body.Data.Metrics)
+	require.Len(t, body.Data.Series, 1)
+	assert.Len(t, body.Data.Series["cpu_percent"], 2)
+
+	w = call(r, "GET", "/api/metrics/history", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "1m", body.Data.Resolution)
+	assert.Len(t, body.Data.Series["disk_percent"], 2)
+
+	assert.Equal(t, http.StatusBadRequest, call(r, "GET", "/api/metrics/history?resolution=5m", nil).Code)
+	assert.Equal(t, http.StatusNotFound, call(r, "GET", "/api/metrics/history?metrics=nope", nil).Code)
+}
+
+func TestEndpointStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stats := endpointstats.Default()
+	stats.Reset()
+	r := gin.New()
+	r.Use(middleware.EndpointStats(stats))
+	monitor := monitoring.New(adminConfig(), logger.New(false, nil), registry.NewDependencies(), nil)
+	monitor.SetRoutes(r.Routes)
+	monitor.RegisterRoutes(r.Group("/api"))
+
+	for range 3 {
+		call(r, "GET", "/api/status", nil)
+	}
+	call(r, "GET", "/api/endpoints/stats?sort=slowest", nil)
+
+	w := call(r, "GET", "/api/endpoints/stats?limit=1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Requests int64                      `json:"requests"`
+			Routes   []endpointstats.RouteStats `json:"routes"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, int64(4), body.Data.Requests)
+	require.Len(t, body.Data.Routes, 1)
+	assert.Equal(t, "/api/status", body.Data.Routes[0].Route)
+	assert.Equal(t, int64(3), body.Data.Routes[0].Requests)
+	assert.Equal(t, map[string]int64{"2xx": 1, "4xx": 1}, stats.Snapshot()[0].StatusClasses, "the rejected sort and the listing")
+
+	// The endpoints listing carries the traffic of each route
+	w = call(r, "GET", "/api/endpoints", nil)
+	assert.Contains(t, w.Body.String(), `"p95_latency_ms"`)
+
+	assert.Equal(t, http.StatusOK, call(r, "DELETE", "/api/endpoints/stats", nil).Code)
+	assert.Len(t, stats.Snapshot(), 1, "only the reset itself")
+}
+
+func TestInFlightRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.InFlight(inflight.Default()))
+	monitor := monitoring.New(adminConfig(), logger.New(false, nil), registry.NewDependencies(), nil)
+	monitor.RegisterRoutes(r.Group("/api"))
+
+	ctx, done := inflight.Default().Begin(context.Background(), inflight.Request{Method: "POST", Path: "/reports", StartedAt: time.Now().Add(-time.Minute)})
+	defer done()
+
+	w := call(r, "GET", "/api/requests/inflight?min_duration_ms=1000", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Count    int                `json:"count"`
+			Requests []inflight.Request `json:"requests"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, 1, body.Data.Count, "the listing itself is faster")
+	slow := body.Data.Requests[0]
+	assert.Equal(t, "/reports", slow.Path)
+	assert.GreaterOrEqual(t, slow.DurationMs, float64(time.Minute/time.Millisecond))
+
+	w = call(r, "GET", "/api/requests/inflight", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 2, body.Data.Count)
+	assert.Equal(t, "/api/requests/inflight", body.Data.Requests[1].Route)
+
+	assert.Equal(t, http.StatusBadRequest, call(r, "GET", "/api/requests/inflight?min_duration_ms=-1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, call(r, "DELETE", "/api/requests/inflight/0", nil).Code)
+	require.Equal(t, http.StatusOK, call(r, "DELETE", "/api/requests/inflight/"+slow.ID, nil).Code)
+	assert.ErrorIs(t, context.Cause(ctx), inflight.ErrCancelled)
+}
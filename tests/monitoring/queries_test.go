@@ -0,0 +1,181 @@
+package monitoring_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/querybook"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedQueriesAndHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	book := querybook.New(nil, 10)
+	deps := registry.NewDependencies()
+	deps.Set("querybook", book)
+
+	cfg := &config.Config{}
+	cfg.Monitoring.Access = config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{
+			{Name: "dashboard", Key: "view-key", Role: "viewer"},
+			{Name: "oncall", Key: "op-key", Role: "operator"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+		},
+	}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	query := `{"name":"locks","engine":"postgres","query":"SELECT * FROM pg_locks"}`
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/queries", "view-key", query).Code)
+	assert.Equal(t, http.StatusCreated, send("POST", "/api/queries", "op-key", query).Code)
+	assert.Equal(t, http.StatusConflict, send("POST", "/api/queries", "op-key", query).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/queries", "op-key", `{"name":"x","engine":"mysql","query":"SELECT 1"}`).Code)
+
+	w := send("GET", "/api/queries/locks", "view-key", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var saved struct {
+		Data querybook.SavedQuery `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	assert.Equal(t, "oncall", saved.Data.CreatedBy)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/queries/locks", "op-key", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/queries/locks", "view-key", "").Code)
+
+	// History is per caller; only admins may read someone else's
+	require.NoError(t, book.Record(querybook.HistoryEntry{User: "oncall", Engine: querybook.EnginePostgres, Query: "SELECT 1"}))
+	history := func(path, key string) (int, int) {
+		w := send("GET", path, key, "")
+		var body struct {
+			Data []querybook.HistoryEntry `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, len(body.Data)
+	}
+	code, n := history("/api/query-history", "op-key")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, n)
+	code, n = history("/api/query-history", "view-key")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, n)
+	code, _ = history("/api/query-history?user=oncall", "view-key")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, n = history("/api/query-history?user=oncall", "admin-key")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, n)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/query-history", "op-key", "").Code)
+	_, n = history("/api/query-history", "op-key")
+	assert.Equal(t, 0, n)
+}
+
+// reportCron runs report jobs when the test calls them.
+type reportCron struct{ jobs []func() }
+
+func (r *reportCron) AddAsyncJob(_, _ string, cmd func()) (int, error) {
+	r.jobs = append(r.jobs, cmd)
+	return len(r.jobs), nil
+}
+
+func (r *reportCron) RemoveJob(int) error { return nil }
+
+func TestSavedQueryVisibilityAndReports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	book := querybook.New(nil, 10)
+	cron := &reportCron{}
+	run := func(_ context.Context, q querybook.SavedQuery, params map[string]interface{}) (*querybook.Result, error) {
+		return &querybook.Result{Columns: []string{"region", "total"}, Rows: [][]interface{}{{params["region"], 12.5}, {"a,b", nil}}, RowCount: 2}, nil
+	}
+	deps := registry.NewDependencies()
+	deps.Set("querybook", book)
+	deps.Set("query_reports", querybook.NewScheduler(book, cron, run, nil))
+
+	cfg := &config.Config{}
+	cfg.Monitoring.Access = config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{
+			{Name: "oncall", Key: "op-key", Role: "operator"},
+			{Name: "other", Key: "other-key", Role: "operator"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+		},
+	}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	query := `{"name":"sales","engine":"postgres","private":true,
+		"query":"SELECT region, sum(total) FROM sales WHERE region = {{region}} GROUP BY 1",
+		"parameters":[{"name":"region","default":"emea"}]}`
+	require.Equal(t, http.StatusCreated, send("POST", "/api/queries", "op-key", query).Code)
+
+	// Private queries are only visible to their creator and admins
+	count := func(key string) int {
+		var body struct {
+			Data []querybook.SavedQuery `json:"data"`
+		}
+		json.Unmarshal(send("GET", "/api/queries", key, "").Body.Bytes(), &body)
+		return len(body.Data)
+	}
+	assert.Equal(t, 1, count("op-key"))
+	assert.Equal(t, 0, count("other-key"))
+	assert.Equal(t, 1, count("admin-key"))
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/queries/sales", "other-key", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/queries/sales", "other-key", "").Code)
+
+	// Running needs the admin role and a Postgres connection
+	assert.Equal(t, http.StatusForbidden, send("POST", "/api/queries/sales/run", "op-key", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/queries/sales/run", "admin-key", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/queries/sales/run", "admin-key", `{"params":{"country":"fr"}}`).Code)
+
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/queries/sales/reports/latest", "admin-key", "").Code)
+	w := send("PUT", "/api/queries/sales/report", "admin-key", `{"schedule":"0 0 6 * * *","params":{"region":"apac"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, cron.jobs, 1)
+	cron.jobs[0]()
+
+	w = send("GET", "/api/queries/sales/reports", "admin-key", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var runs struct {
+		Data []querybook.ReportRun `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	require.Len(t, runs.Data, 1)
+	assert.True(t, runs.Data[0].Success)
+
+	w = send("GET", "/api/queries/sales/reports/latest?format=csv", "admin-key", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	assert.Equal(t, "region,total\napac,12.5\n\"a,b\",\n", w.Body.String())
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/queries/sales/report", "admin-key", "").Code)
+	saved, _ := book.Get("sales")
+	assert.Nil(t, saved.Report)
+}
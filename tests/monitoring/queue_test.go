@@ -0,0 +1,59 @@
+package monitoring_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/queue"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	r := gin.New()
+	monitoring.New(adminConfig(), logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	call := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/queue").Code, "disabled")
+
+	backend := queue.NewMemoryBackend()
+	deps.Set("queue", queue.New(backend, queue.Options{}))
+	failed := time.Now()
+	require.NoError(t, backend.Bury(context.Background(), queue.Job{ID: "j1", Type: "report", Attempts: 5, LastError: "timeout", FailedAt: &failed}))
+
+	w := call("GET", "/api/queue")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var status struct {
+		Data struct {
+			Backend string      `json:"backend"`
+			Depth   queue.Depth `json:"depth"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, queue.BackendMemory, status.Data.Backend)
+	assert.Equal(t, int64(1), status.Data.Depth.Dead)
+
+	w = call("GET", "/api/queue/dead")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"last_error":"timeout"`)
+
+	assert.Equal(t, http.StatusOK, call("POST", "/api/queue/dead/j1/retry").Code)
+	assert.Equal(t, http.StatusNotFound, call("POST", "/api/queue/dead/j1/retry").Code, "no longer dead")
+	depth, err := backend.Depth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{Ready: 1}, depth)
+}
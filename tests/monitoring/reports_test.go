@@ -0,0 +1,64 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/internal/reporting"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	l := logger.New(false, nil)
+	r := gin.New()
+	monitoring.New(adminConfig(), l, deps, nil).RegisterRoutes(r.Group("/api"))
+	call := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/reports").Code, "disabled")
+
+	deps.Set("reports", reporting.NewGenerator(config.ReportsConfig{}, reporting.Sources{
+		App: config.AppConfig{Name: "stackyrd"},
+		Components: func() map[string]map[string]interface{} {
+			return map[string]map[string]interface{}{"redis": {"connected": true}}
+		},
+	}, nil, l))
+
+	w := call("POST", "/api/reports")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data reporting.Report `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	id := created.Data.ID
+
+	w = call("GET", "/api/reports")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), id)
+
+	w = call("GET", "/api/reports/"+id+"?format=csv")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="report-`+id+`.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Contains(t, w.Body.String(), "infrastructure,redis,status,up")
+
+	w = call("GET", "/api/reports/"+id+"?format=html")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>stackyrd status report</h1>")
+
+	assert.Equal(t, http.StatusBadRequest, call("GET", "/api/reports/"+id+"?format=pdf").Code)
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/reports/missing").Code)
+}
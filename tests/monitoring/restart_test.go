@@ -0,0 +1,94 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type kafkaStub struct{}
+
+func (kafkaStub) Name() string { return "Kafka" }
+func (kafkaStub) Close() error { return nil }
+func (kafkaStub) GetStatus() map[string]interface{} {
+	return map[string]interface{}{"connected": true}
+}
+
+func TestRestartComponent_StreamsProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	components := &infrastructure.ComponentRegistry{}
+	components.Register("kafka", func(*config.Config, *logger.Logger) (infrastructure.InfrastructureComponent, error) {
+		return kafkaStub{}, nil
+	})
+	require.NoError(t, components.Initialize(&config.Config{}, logger.New(false, nil)))
+	restarter := infrastructure.NewRestarter(components)
+	restarted := make(chan string, 1)
+	restarter.OnRestarted = func(name string, _ infrastructure.InfrastructureComponent, _ infrastructure.ConnectResult) {
+		restarted <- name
+	}
+	deps := registry.NewDependencies()
+	deps.Set("restarter", restarter)
+	r := gin.New()
+	monitoring.New(adminConfig(), logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	call := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/infrastructure/kafka/restart").Code)
+	assert.Equal(t, http.StatusNotFound, call("POST", "/api/infrastructure/cassandra/restart").Code)
+
+	w := call("POST", "/api/infrastructure/kafka/restart")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "/api/infrastructure/kafka/restart/stream", w.Header().Get("Location"))
+	select {
+	case name := <-restarted:
+		assert.Equal(t, "kafka", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("component was not handed over")
+	}
+
+	// Long poll the steps until the restart is done
+	var steps []string
+	since := "0"
+	for len(steps) == 0 || steps[len(steps)-1] != infrastructure.RestartDone {
+		w = call("GET", "/api/infrastructure/kafka/restart/poll?timeout=5&since="+since)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data struct {
+				Events []infrastructure.RestartStep `json:"events"`
+				Cursor json.Number                  `json:"cursor"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		require.NotEmpty(t, body.Data.Events)
+		for _, e := range body.Data.Events {
+			steps = append(steps, e.Step)
+		}
+		since = body.Data.Cursor.String()
+	}
+	assert.Equal(t, []string{"closing", "closed", "connected", "reloading", "done"}, steps)
+
+	w = call("GET", "/api/infrastructure/kafka/restart")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status struct {
+		Data infrastructure.RestartStatus `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, infrastructure.RestartSucceeded, status.Data.State)
+	assert.NotNil(t, status.Data.FinishedAt)
+}
@@ -0,0 +1,46 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/shutdown"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	r := gin.New()
+	monitoring.New(&config.Config{}, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/debug/last-shutdown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "no report on a first start")
+
+	report := shutdown.New("signal: terminated", "1.2.0")
+	report.Components = append(report.Components, shutdown.Component{Name: "redis", Status: shutdown.StatusTimeout, Error: "redis: forced shutdown (timeout)"})
+	report.Errors = append(report.Errors, "redis: forced shutdown (timeout)")
+	report.Finish(true)
+	deps.Set("last_shutdown", report)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/debug/last-shutdown", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data shutdown.Report `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "signal: terminated", body.Data.Reason)
+	require.Len(t, body.Data.Components, 1)
+	assert.Equal(t, shutdown.StatusTimeout, body.Data.Components[0].Status)
+}
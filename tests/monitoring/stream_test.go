@@ -0,0 +1,363 @@
+package monitoring_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/jsonpatch"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pollBody struct {
+	Data struct {
+		Events    []logger.LogEntry `json:"events"`
+		Cursor    uint64            `json:"cursor"`
+		Missed    bool              `json:"missed"`
+		Transport string            `json:"transport"`
+	} `json:"data"`
+}
+
+func streamRouter(logs *logger.LogBroadcaster) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	deps.Set("logs", logs)
+	r := gin.New()
+	monitoring.New(&config.Config{}, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	return r
+}
+
+func TestLogStream_LongPoll(t *testing.T) {
+	logs := logger.NewLogBroadcaster(100)
+	r := streamRouter(logs)
+	poll := func(query string) pollBody {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/poll"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body pollBody
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	logs.Publish(logger.LogEntry{Message: "first"})
+	logs.Publish(logger.LogEntry{Message: "second"})
+	body := poll("")
+	assert.Equal(t, "poll", body.Data.Transport)
+	require.Len(t, body.Data.Events, 2)
+	assert.Equal(t, uint64(2), body.Data.Cursor)
+
+	// Nothing new: the poll times out empty and keeps the cursor
+	body = poll("?since=2&timeout=0")
+	assert.Empty(t, body.Data.Events)
+	assert.Equal(t, uint64(2), body.Data.Cursor)
+
+	// A waiting poll answers as soon as a line is logged
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		logs.Publish(logger.LogEntry{Message: "third"})
+	}()
+	start := time.Now()
+	body = poll("?since=2&timeout=5")
+	assert.Less(t, time.Since(start), 2*time.Second)
+	require.Len(t, body.Data.Events, 1)
+	assert.Equal(t, "third", body.Data.Events[0].Message)
+	assert.Equal(t, uint64(3), body.Data.Cursor)
+
+	// Without an EventSource Accept header /stream long-polls too
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/stream?since=3&timeout=0", nil))
+	assert.Contains(t, w.Body.String(), `"transport":"poll"`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/poll?since=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLogStream_ServerSentEvents(t *testing.T) {
+	logs := logger.NewLogBroadcaster(100)
+	logs.Publish(logger.LogEntry{Message: "before"})
+	srv := httptest.NewServer(streamRouter(logs))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/logs/stream", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	logs.Publish(logger.LogEntry{Message: "after"})
+	scanner := bufio.NewScanner(resp.Body)
+	var event []string
+	for scanner.Scan() && scanner.Text() != "" {
+		event = append(event, scanner.Text())
+	}
+	require.Len(t, event, 3)
+	assert.Equal(t, "id: 2", event[0], "the stream resumes after Last-Event-ID")
+	assert.Equal(t, "event: log", event[1])
+	assert.True(t, strings.HasPrefix(event[2], "data: "))
+	assert.Contains(t, event[2], `"message":"after"`)
+}
+
+func TestLogStream_CoalescesBursts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := logger.NewLogBroadcaster(100)
+	deps := registry.NewDependencies()
+	deps.Set("logs", logs)
+	cfg := &config.Config{}
+	cfg.Monitoring.Stream = config.StreamConfig{FlushInterval: 300, FlushEvents: 100}
+	r := gin.New()
+	monitoring.New(cfg, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/logs/stream", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	lines := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "data: ") {
+				lines <- scanner.Text()
+			}
+		}
+	}()
+	next := func() time.Duration {
+		start := time.Now()
+		select {
+		case <-lines:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event")
+		}
+		return time.Since(start)
+	}
+
+	logs.Publish(logger.LogEntry{Message: "first"})
+	assert.Less(t, next(), 200*time.Millisecond, "a quiet stream sends at once")
+
+	logs.Publish(logger.LogEntry{Message: "second"})
+	logs.Publish(logger.LogEntry{Message: "third"})
+	assert.Greater(t, next(), 150*time.Millisecond, "a burst waits for the window")
+	assert.Less(t, next(), 50*time.Millisecond, "and is flushed together")
+
+	time.Sleep(400 * time.Millisecond)
+	logs.Publish(logger.LogEntry{Message: "fourth"})
+	assert.Less(t, next(), 200*time.Millisecond)
+}
+
+func TestLogStats(t *testing.T) {
+	logs := logger.NewLogBroadcaster(2)
+	_, cancel := logs.SubscribeWith(logger.SubscribeOptions{Name: "tail", Buffer: 1})
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		logs.Publish(logger.LogEntry{Message: "line"})
+	}
+	r := streamRouter(logs)
+
+	// A poll from line 1 misses lines 2 (3 and 4 are still buffered)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/poll?since=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"missed":true`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/stats", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data logger.BroadcasterStats `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, uint64(4), body.Data.Published)
+	assert.Equal(t, uint64(3), body.Data.Dropped)
+	assert.Equal(t, uint64(1), body.Data.StreamMissed)
+	require.Len(t, body.Data.Subscribers, 1)
+	assert.Equal(t, "tail", body.Data.Subscribers[0].Name)
+}
+
+func TestLogStats_Sinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := logger.NewLogBroadcaster(10)
+	deps := registry.NewDependencies()
+	deps.Set("logs", logs)
+	r := gin.New()
+	monitoring.New(&config.Config{}, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	stats := func() []logger.SinkStats {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/stats", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data struct {
+				Published uint64             `json:"published"`
+				Sinks     []logger.SinkStats `json:"sinks"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data.Sinks
+	}
+	assert.NotNil(t, stats(), "no sinks is an empty list, not null")
+
+	sinks, err := logger.NewSinkSet([]logger.SinkConfig{{Name: "file", Type: logger.SinkFile, Level: "warn", Path: filepath.Join(t.TempDir(), "app.jsonl")}})
+	require.NoError(t, err)
+	deps.Set("log_sinks", sinks)
+	l := logger.NewQuiet(true, logs).WithSinks(sinks)
+	l.Info("started")
+	l.Warn("disk low")
+	require.NoError(t, sinks.Close())
+
+	got := stats()
+	require.Len(t, got, 1)
+	assert.Equal(t, "file", got[0].Name)
+	assert.Equal(t, logger.SinkFile, got[0].Type)
+	assert.Equal(t, uint64(1), got[0].Written, "info is below the sink's level")
+}
+
+func TestStatusStream_Poll(t *testing.T) {
+	r := streamRouter(logger.NewLogBroadcaster(10))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/status/poll", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Events []map[string]interface{} `json:"events"`
+			Cursor uint64                   `json:"cursor"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data.Events, 1)
+	assert.Contains(t, body.Data.Events[0], "infrastructure")
+
+	// The same version waits for a change
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/status/poll?timeout=0&since="+strconv.FormatUint(body.Data.Cursor, 10), nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Empty(t, body.Data.Events)
+}
+
+type toggleComponent struct {
+	mu        sync.Mutex
+	connected bool
+}
+
+func (t *toggleComponent) set(connected bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.connected = connected
+}
+
+func (t *toggleComponent) GetStatus() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return map[string]interface{}{"connected": t.connected}
+}
+
+func TestStatusDeltaStream_Poll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	component := &toggleComponent{connected: true}
+	deps := registry.NewDependencies()
+	deps.Set("fake", component)
+	r := gin.New()
+	monitoring.New(&config.Config{}, logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+
+	type delta struct {
+		Type    string                 `json:"type"`
+		Version uint64                 `json:"version"`
+		From    uint64                 `json:"from"`
+		Status  map[string]interface{} `json:"status"`
+		Ops     []jsonpatch.Operation  `json:"ops"`
+	}
+	poll := func(query string) ([]delta, uint64) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/status/delta/poll"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			Data struct {
+				Events []delta `json:"events"`
+				Cursor uint64  `json:"cursor"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data.Events, body.Data.Cursor
+	}
+
+	events, cursor := poll("")
+	require.Len(t, events, 1)
+	snapshot := events[0]
+	assert.Equal(t, "snapshot", snapshot.Type)
+	assert.Equal(t, cursor, snapshot.Version)
+	assert.NotContains(t, snapshot.Status, "uptime_seconds")
+
+	events, _ = poll("?timeout=0&since=" + strconv.FormatUint(cursor, 10))
+	assert.Empty(t, events, "nothing is sent while the status is unchanged")
+
+	component.set(false)
+	events, next := poll("?timeout=0&since=" + strconv.FormatUint(cursor, 10))
+	require.Len(t, events, 1)
+	patch := events[0]
+	assert.Equal(t, "patch", patch.Type)
+	assert.Equal(t, cursor, patch.From)
+	assert.Equal(t, next, patch.Version)
+	assert.Equal(t, []jsonpatch.Operation{{Op: "replace", Path: "/infrastructure/fake/connected", Value: false}}, patch.Ops)
+
+	var doc interface{} = snapshot.Status
+	doc, err := jsonpatch.Apply(doc, patch.Ops)
+	require.NoError(t, err)
+	assert.Equal(t, false, doc.(map[string]interface{})["infrastructure"].(map[string]interface{})["fake"].(map[string]interface{})["connected"])
+
+	// An unknown version starts over with a snapshot
+	events, _ = poll("?timeout=0&since=12345")
+	require.Len(t, events, 1)
+	assert.Equal(t, "snapshot", events[0].Type)
+}
+
+func TestLogHistory_ClampsPages(t *testing.T) {
+	logs := logger.NewLogBroadcaster(100)
+	for i := 0; i < 3; i++ {
+		logs.Publish(logger.LogEntry{Message: "line " + strconv.Itoa(i)})
+	}
+	r := streamRouter(logs)
+	history := func(query string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/logs/history"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			Data []logger.LogEntry      `json:"data"`
+			Meta map[string]interface{} `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		body.Meta["entries"] = len(body.Data)
+		return body.Meta
+	}
+
+	meta := history("?per_page=1000")
+	assert.Equal(t, 3, meta["entries"])
+	assert.Equal(t, float64(100), meta["per_page"], "per_page is capped")
+
+	meta = history("?page=" + strconv.Itoa(math.MaxInt) + "&per_page=100")
+	assert.Equal(t, 0, meta["entries"])
+	assert.Equal(t, float64(100), meta["page"], "pages stop at the search window")
+}
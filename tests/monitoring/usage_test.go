@@ -0,0 +1,96 @@
+package monitoring_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/usage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	usage.Default().Reset()
+	keys := []config.AccessKeyConfig{{Name: "partner", Key: "k-partner", Role: "viewer"}}
+
+	r := gin.New()
+	r.Use(middleware.APIUsage(usage.Default(), keys))
+	// Stands in for the jwt middleware, which may run after api_usage
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("username", user)
+		}
+	})
+	r.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	monitoring.New(&config.Config{}, logger.New(false, nil), registry.NewDependencies(), nil).RegisterRoutes(r.Group("/api"))
+
+	call := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	call("/orders", "X-API-Key", "k-partner")
+	call("/orders", "X-API-Key", "k-partner")
+	call("/orders/7", "X-API-Key", "k-partner")
+	call("/orders", "X-Test-User", "alice")
+	call("/orders", "X-API-Key", "leaked-or-wrong")
+	call("/missing", "X-API-Key", "k-partner")
+
+	w := call("/api/usage?type=api_key")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Calls      int64         `json:"calls"`
+			Principals []usage.Usage `json:"principals"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data.Principals, 2)
+	partner := body.Data.Principals[0]
+	assert.Equal(t, "partner", partner.Principal)
+	assert.Equal(t, int64(3), partner.Calls, "unrouted requests are not counted")
+	assert.InDelta(t, 1.0/3, partner.ErrorRate, 0.0001)
+	assert.Equal(t, "/orders", partner.TopEndpoints[0].Route)
+	unknown := body.Data.Principals[1]
+	assert.True(t, strings.HasPrefix(unknown.Principal, "key-"))
+	assert.NotContains(t, w.Body.String(), "leaked-or-wrong", "unknown keys are digested")
+
+	w = call("/api/usage/user/alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"calls":1`)
+	assert.Equal(t, http.StatusNotFound, call("/api/usage/user/bob").Code)
+
+	w = call("/api/usage/export?by=endpoint&type=api_key")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"principal", "type", "method", "route", "calls", "errors", "last_seen"}, rows[0])
+	assert.Equal(t, []string{"partner", "api_key", "GET", "/orders", "2", "0"}, rows[1][:6])
+	assert.Equal(t, []string{"partner", "api_key", "GET", "/orders/:id", "1", "1"}, rows[2][:6])
+
+	w = call("/api/usage/export?format=json")
+	require.Equal(t, http.StatusOK, w.Code)
+	var exported []usage.Usage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	assert.NotEmpty(t, exported)
+
+	assert.Equal(t, http.StatusBadRequest, call("/api/usage?sort=nope").Code)
+}
@@ -0,0 +1,79 @@
+package monitoring_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWeb(t *testing.T, web config.WebConfig) (*gin.Engine, string) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.App.Name = "web-test"
+	cfg.Monitoring.Web = web
+	cfg.Monitoring.Access = config.AccessConfig{
+		Enabled: true,
+		APIKeys: []config.AccessKeyConfig{
+			{Name: "oncall", Key: "ops-key", Role: "operator"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+		},
+	}
+	m := monitoring.New(cfg, logger.New(false, nil), registry.NewDependencies(), nil)
+	r := gin.New()
+	m.RegisterRoutes(r.Group("/api"))
+	return r, m.RegisterWeb(r, "/api")
+}
+
+func TestWeb_Fallback(t *testing.T) {
+	r, source := newWeb(t, config.WebConfig{Path: filepath.Join(t.TempDir(), "web"), Route: "/dashboard", Fallback: true})
+	assert.Equal(t, monitoring.WebEmbedded, source)
+
+	w := call(r, "GET", "/dashboard", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "web-test")
+	assert.Contains(t, w.Body.String(), `const api = "/api";`)
+}
+
+func TestWeb_Directory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>dashboard</h1>"), 0o644))
+	r, source := newWeb(t, config.WebConfig{Path: dir, Route: "/dashboard", Fallback: true})
+	assert.Equal(t, monitoring.WebDirectory, source)
+
+	w := call(r, "GET", "/dashboard/", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>dashboard</h1>")
+}
+
+func TestWeb_Disabled(t *testing.T) {
+	r, source := newWeb(t, config.WebConfig{Path: filepath.Join(t.TempDir(), "web"), Route: "/dashboard"})
+	assert.Equal(t, monitoring.WebDisabled, source)
+	assert.Equal(t, http.StatusNotFound, call(r, "GET", "/dashboard", nil).Code)
+}
+
+func TestWeb_Restart(t *testing.T) {
+	r, _ := newWeb(t, config.WebConfig{})
+	key := func(k string) map[string]string { return map[string]string{"X-API-Key": k} }
+
+	assert.Equal(t, http.StatusForbidden, call(r, "POST", "/api/restart", key("ops-key")).Code)
+	assert.Empty(t, utils.RestartChan)
+
+	require.Equal(t, http.StatusOK, call(r, "POST", "/api/restart", key("admin-key")).Code)
+	select {
+	case <-utils.RestartChan:
+	default:
+		t.Fatal("restart was not requested")
+	}
+}
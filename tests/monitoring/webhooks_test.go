@@ -0,0 +1,75 @@
+package monitoring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/internal/monitoring"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deps := registry.NewDependencies()
+	r := gin.New()
+	monitoring.New(adminConfig(), logger.New(false, nil), deps, nil).RegisterRoutes(r.Group("/api"))
+	call := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/webhooks").Code, "disabled")
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer hook.Close()
+	dispatcher, err := webhook.New([]webhook.Destination{{Name: "crm", URL: hook.URL}}, webhook.Options{Workers: 1})
+	require.NoError(t, err)
+	dispatcher.Start()
+	defer dispatcher.Close()
+	deps.Set("webhooks", dispatcher)
+
+	require.Equal(t, http.StatusOK, call("POST", "/api/webhooks/destinations/crm/test").Code)
+	assert.Equal(t, http.StatusNotFound, call("POST", "/api/webhooks/destinations/erp/test").Code)
+	require.Eventually(t, func() bool {
+		return len(dispatcher.Deliveries(webhook.DeliveryFilter{Status: webhook.StatusFailed})) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	w := call("GET", "/api/webhooks")
+	require.Equal(t, http.StatusOK, w.Code)
+	var overview struct {
+		Data struct {
+			Destinations   []webhook.DestinationStats `json:"destinations"`
+			RecentFailures []webhook.Delivery         `json:"recent_failures"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &overview))
+	require.Len(t, overview.Data.Destinations, 1)
+	assert.Equal(t, int64(1), overview.Data.Destinations[0].Failed)
+	require.Len(t, overview.Data.RecentFailures, 1)
+	failed := overview.Data.RecentFailures[0]
+	assert.Equal(t, "webhook.test", failed.Event)
+	assert.Equal(t, http.StatusGone, failed.StatusCode)
+
+	w = call("GET", "/api/webhooks/deliveries?destination=crm&status=failed")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), failed.ID)
+	assert.Equal(t, http.StatusOK, call("GET", "/api/webhooks/deliveries/"+failed.ID).Code)
+	assert.Equal(t, http.StatusNotFound, call("GET", "/api/webhooks/deliveries/dlv_0").Code)
+
+	assert.Equal(t, http.StatusOK, call("POST", "/api/webhooks/deliveries/"+failed.ID+"/retry").Code)
+	require.Eventually(t, func() bool {
+		d, err := dispatcher.Delivery(failed.ID)
+		return err == nil && d.Status == webhook.StatusFailed
+	}, 2*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,273 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/messaging"
+	"stackyrd/pkg/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB answers the statements of the outbox from memory, standing in for
+// Postgres.
+type fakeDB struct {
+	mu     sync.Mutex
+	rows   []*fakeRow
+	nextID int64
+	locked bool // another instance holds the relay lock
+}
+
+type fakeRow struct {
+	id        int64
+	topic     string
+	key       []byte
+	value     []byte
+	headers   interface{}
+	created   time.Time
+	published bool
+	attempts  int
+	lastError string
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c *fakeConn) Commit() error                       { return nil }
+func (c *fakeConn) Rollback() error                     { return nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	f := c.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "CREATE"):
+	case strings.HasPrefix(query, "INSERT"):
+		f.nextID++
+		key, _ := args[1].Value.([]byte)
+		f.rows = append(f.rows, &fakeRow{id: f.nextID, topic: args[0].Value.(string), key: key,
+			value: args[2].Value.([]byte), headers: args[3].Value, created: time.Now()})
+	case strings.Contains(query, "SET published_at"):
+		row := f.row(args[0].Value.(int64))
+		row.published, row.attempts, row.lastError = true, row.attempts+1, ""
+	case strings.Contains(query, "SET attempts"):
+		row := f.row(args[0].Value.(int64))
+		row.attempts, row.lastError = row.attempts+1, args[1].Value.(string)
+	case strings.HasPrefix(query, "DELETE"):
+		kept := f.rows[:0]
+		for _, row := range f.rows {
+			if !row.published {
+				kept = append(kept, row)
+			}
+		}
+		f.rows = kept
+	default:
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	f := c.db
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.Contains(query, "pg_try_advisory_xact_lock"):
+		return &fakeRows{cols: []string{"locked"}, values: [][]driver.Value{{!f.locked}}}, nil
+	case strings.Contains(query, "count(*)"):
+		var count int64
+		var oldest interface{}
+		for _, row := range f.rows {
+			if !row.published {
+				if count == 0 {
+					oldest = row.created
+				}
+				count++
+			}
+		}
+		return &fakeRows{cols: []string{"count", "min"}, values: [][]driver.Value{{count, oldest}}}, nil
+	case strings.HasPrefix(query, "SELECT id"):
+		limit := int(args[0].Value.(int64))
+		rows := &fakeRows{cols: []string{"id", "topic", "key", "value", "headers", "created_at"}}
+		for _, row := range f.rows {
+			if !row.published && len(rows.values) < limit {
+				rows.values = append(rows.values, []driver.Value{row.id, row.topic, row.key, row.value, row.headers, row.created})
+			}
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func (f *fakeDB) row(id int64) *fakeRow {
+	for _, row := range f.rows {
+		if row.id == id {
+			return row
+		}
+	}
+	return &fakeRow{}
+}
+
+type fakeRows struct {
+	cols   []string
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// recordingBroker keeps published messages and fails while down.
+type recordingBroker struct {
+	mu        sync.Mutex
+	published []messaging.Message
+	down      bool
+}
+
+func (b *recordingBroker) BrokerType() string { return messaging.BrokerKafka }
+
+func (b *recordingBroker) PublishMessage(_ context.Context, msg messaging.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.down {
+		return errors.New("broker unreachable")
+	}
+	b.published = append(b.published, msg)
+	return nil
+}
+
+func (b *recordingBroker) Subscribe(context.Context, string, messaging.SubscribeOptions, messaging.Handler) (messaging.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func (b *recordingBroker) topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var topics []string
+	for _, msg := range b.published {
+		topics = append(topics, msg.Topic)
+	}
+	return topics
+}
+
+func newOutbox(t *testing.T, fake *fakeDB, broker messaging.Broker) (*outbox.Outbox, *sql.DB) {
+	t.Helper()
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	box := outbox.New(func() *sql.DB { return db }, func() messaging.Broker { return broker }, outbox.Options{BatchSize: 2})
+	t.Cleanup(func() { box.Close() })
+	return box, db
+}
+
+func enqueue(t *testing.T, box *outbox.Outbox, db *sql.DB, topics ...string) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	for _, topic := range topics {
+		require.NoError(t, box.Enqueue(ctx, tx, messaging.Message{Topic: topic, Value: []byte(`{}`), Headers: map[string]string{"source": "test"}}))
+	}
+	require.NoError(t, tx.Commit())
+}
+
+func TestOutbox_Relay(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDB{}
+	broker := &recordingBroker{}
+	box, db := newOutbox(t, fake, broker)
+
+	enqueue(t, box, db, "orders.created", "orders.paid", "orders.shipped")
+	backlog, oldest, err := box.Backlog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), backlog)
+	assert.False(t, oldest.IsZero())
+
+	relayed, err := box.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, relayed, "batches continue until the backlog is empty")
+	assert.Equal(t, []string{"orders.created", "orders.paid", "orders.shipped"}, broker.topics())
+	assert.Equal(t, "test", broker.published[0].Headers["source"])
+
+	backlog, _, err = box.Backlog(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, backlog)
+	assert.Empty(t, fake.rows, "published events past the retention are deleted")
+	stats := box.Stats(ctx)
+	assert.Equal(t, int64(3), stats["published_total"])
+	assert.Equal(t, true, box.GetStatus()["connected"])
+}
+
+func TestOutbox_BrokerDown(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDB{}
+	broker := &recordingBroker{down: true}
+	box, db := newOutbox(t, fake, broker)
+
+	enqueue(t, box, db, "a", "b")
+	_, err := box.Relay(ctx)
+	require.Error(t, err)
+	assert.Equal(t, 1, fake.rows[0].attempts)
+	assert.Equal(t, "broker unreachable", fake.rows[0].lastError)
+	status := box.GetStatus()
+	assert.Equal(t, false, status["connected"])
+	assert.Equal(t, int64(2), status["backlog"])
+
+	broker.down = false
+	relayed, err := box.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	assert.Equal(t, []string{"a", "b"}, broker.topics(), "order kept across the outage")
+	assert.Equal(t, true, box.GetStatus()["connected"])
+}
+
+func TestOutbox_LockedByAnotherInstance(t *testing.T) {
+	fake := &fakeDB{locked: true}
+	broker := &recordingBroker{}
+	box, db := newOutbox(t, fake, broker)
+
+	enqueue(t, box, db, "a")
+	relayed, err := box.Relay(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, relayed)
+	assert.Empty(t, broker.topics())
+}
+
+func TestOutbox_NotConnected(t *testing.T) {
+	box := outbox.New(func() *sql.DB { return nil }, func() messaging.Broker { return nil }, outbox.Options{})
+	assert.ErrorIs(t, box.Enqueue(context.Background(), nil, messaging.Message{Topic: "a"}), outbox.ErrNotConnected)
+	_, err := box.Relay(context.Background())
+	assert.ErrorIs(t, err, outbox.ErrNotConnected)
+	assert.NoError(t, box.Close())
+}
+
+func TestOutbox_Start(t *testing.T) {
+	fake := &fakeDB{}
+	broker := &recordingBroker{}
+	box, db := newOutbox(t, fake, broker)
+	box.Start()
+
+	enqueue(t, box, db, "a")
+	assert.Eventually(t, func() bool { return len(broker.topics()) == 1 }, 2*time.Second, 10*time.Millisecond,
+		"enqueueing wakes the relay")
+}
@@ -0,0 +1,140 @@
+package photos_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/photos"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngOf(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func newStore(t *testing.T) (*photos.Store, infrastructure.LocalObjectStorage) {
+	objects := infrastructure.LocalObjectStorage{Dir: t.TempDir()}
+	return photos.New(objects, config.PhotosConfig{
+		Prefix:       "photos/",
+		MaxSizeMB:    1,
+		MaxDimension: 64,
+		AllowedTypes: []string{"image/png", "image/jpeg"},
+	}), objects
+}
+
+func TestUploadReplacesPreviousPhoto(t *testing.T) {
+	store, objects := newStore(t)
+	ctx := context.Background()
+
+	first, err := store.Upload(ctx, "1", bytes.NewReader(pngOf(t, 10, 20)))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", first.ContentType)
+	assert.Equal(t, 10, first.Width)
+	assert.Equal(t, 20, first.Height)
+	assert.True(t, strings.HasPrefix(first.Name, "photos/1/"))
+	assert.True(t, strings.HasSuffix(first.Name, ".png"))
+
+	second, err := store.Upload(ctx, "1", bytes.NewReader(pngOf(t, 8, 8)))
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Name, second.Name)
+
+	listed, err := objects.List(ctx, "photos/1/")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, second.Name, listed[0].Name)
+
+	rc, info, err := store.Open(ctx, second.Name)
+	require.NoError(t, err)
+	defer rc.Close()
+	assert.Equal(t, second.Size, info.Size)
+	_, _, err = store.Open(ctx, first.Name)
+	assert.ErrorIs(t, err, photos.ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, "1"))
+	listed, err = objects.List(ctx, "photos/")
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+}
+
+func TestUploadValidation(t *testing.T) {
+	store, _ := newStore(t)
+	ctx := context.Background()
+
+	_, err := store.Upload(ctx, "1", strings.NewReader("<html>not a photo</html>"))
+	assert.ErrorIs(t, err, photos.ErrUnsupportedType)
+
+	_, err = store.Upload(ctx, "1", bytes.NewReader(make([]byte, 2<<20)))
+	assert.ErrorIs(t, err, photos.ErrTooLarge)
+
+	_, err = store.Upload(ctx, "1", bytes.NewReader(pngOf(t, 65, 10)))
+	assert.ErrorIs(t, err, photos.ErrTooLarge)
+
+	// A PNG signature alone is not an image
+	_, err = store.Upload(ctx, "1", strings.NewReader("\x89PNG\r\n\x1a\ngarbage"))
+	assert.ErrorIs(t, err, photos.ErrInvalidImage)
+
+	_, err = store.Upload(ctx, "../1", bytes.NewReader(pngOf(t, 8, 8)))
+	assert.Error(t, err)
+}
+
+func TestCleanupRemovesOrphans(t *testing.T) {
+	store, objects := newStore(t)
+	ctx := context.Background()
+
+	kept, err := store.Upload(ctx, "1", bytes.NewReader(pngOf(t, 8, 8)))
+	require.NoError(t, err)
+	_, err = store.Upload(ctx, "2", bytes.NewReader(pngOf(t, 8, 8)))
+	require.NoError(t, err)
+	// Left behind by an upload whose replacement step failed
+	stray := filepath.Join(objects.Dir, "photos", "1", "stray.png")
+	require.NoError(t, os.WriteFile(stray, pngOf(t, 8, 8), 0o644))
+
+	current := func(owner, name string) bool { return owner == "1" && name == kept.Name }
+
+	// Within the grace period nothing is removed
+	removed, err := store.Cleanup(ctx, current, time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	removed, err = store.Cleanup(ctx, current, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	listed, err := objects.List(ctx, "photos/")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, kept.Name, listed[0].Name)
+}
+
+func TestLocalObjectStorageRejectsEscapingNames(t *testing.T) {
+	objects := infrastructure.LocalObjectStorage{Dir: t.TempDir()}
+	ctx := context.Background()
+	for _, name := range []string{"../x", "/etc/passwd", "a/../../x", ""} {
+		assert.Error(t, objects.Put(ctx, name, strings.NewReader("x"), 1, ""), name)
+	}
+	_, _, err := objects.Get(ctx, "missing.png")
+	assert.ErrorIs(t, err, infrastructure.ErrObjectNotFound)
+}
+
+func TestBackend(t *testing.T) {
+	objects, err := photos.Backend(config.PhotosConfig{LocalDir: "data/photos"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, infrastructure.LocalObjectStorage{Dir: "data/photos"}, objects)
+
+	_, err = photos.Backend(config.PhotosConfig{Backend: "storage"}, nil)
+	assert.Error(t, err)
+	_, err = photos.Backend(config.PhotosConfig{Backend: "ftp"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package querybook_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/querybook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func books(t *testing.T) map[string]func(size int) *querybook.Book {
+	return map[string]func(size int) *querybook.Book{
+		"memory": func(size int) *querybook.Book { return querybook.New(nil, size) },
+		"store": func(size int) *querybook.Book {
+			store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return querybook.New(store, size)
+		},
+	}
+}
+
+func TestSavedQueries(t *testing.T) {
+	for name, newBook := range books(t) {
+		t.Run(name, func(t *testing.T) {
+			book := newBook(10)
+			slow := querybook.SavedQuery{Name: "slow queries", Engine: querybook.EnginePostgres, Query: "SELECT * FROM pg_stat_activity"}
+			created, err := book.Create(slow, "alice")
+			require.NoError(t, err)
+			assert.Equal(t, "alice", created.CreatedBy)
+
+			_, err = book.Create(slow, "bob")
+			assert.ErrorIs(t, err, querybook.ErrExists)
+			_, err = book.Create(querybook.SavedQuery{Name: "orders", Engine: querybook.EngineMongo, Collection: "orders", Query: "{not json"}, "bob")
+			assert.ErrorIs(t, err, querybook.ErrInvalid)
+			_, err = book.Create(querybook.SavedQuery{Name: "orders", Engine: querybook.EngineMongo, Collection: "orders", Query: `{"status": "open"}`}, "bob")
+			require.NoError(t, err)
+
+			slow.Query = "SELECT pid, query FROM pg_stat_activity"
+			updated, err := book.Update("slow queries", slow, "bob")
+			require.NoError(t, err)
+			assert.Equal(t, "alice", updated.CreatedBy)
+			assert.Equal(t, "bob", updated.UpdatedBy)
+
+			all, err := book.List("")
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "orders", all[0].Name)
+			pg, _ := book.List(querybook.EnginePostgres)
+			require.Len(t, pg, 1)
+			assert.Equal(t, "SELECT pid, query FROM pg_stat_activity", pg[0].Query)
+
+			require.NoError(t, book.Delete("orders"))
+			assert.ErrorIs(t, book.Delete("orders"), querybook.ErrNotFound)
+			_, err = book.Update("orders", slow, "bob")
+			assert.ErrorIs(t, err, querybook.ErrNotFound)
+		})
+	}
+}
+
+func TestQueryHistory(t *testing.T) {
+	for name, newBook := range books(t) {
+		t.Run(name, func(t *testing.T) {
+			book := newBook(3)
+			base := time.Now()
+			for i := 0; i < 5; i++ {
+				require.NoError(t, book.Record(querybook.HistoryEntry{
+					User:     "alice",
+					Engine:   querybook.EnginePostgres,
+					Query:    fmt.Sprintf("SELECT %d", i),
+					Time:     base.Add(time.Duration(i) * time.Second),
+					RowCount: 1,
+					Success:  true,
+				}))
+			}
+			require.NoError(t, book.Record(querybook.HistoryEntry{User: "bob", Query: "SELECT 1"}))
+
+			entries, err := book.History("alice", 0)
+			require.NoError(t, err)
+			require.Len(t, entries, 3, "only the newest entries are kept")
+			assert.Equal(t, "SELECT 4", entries[0].Query)
+			assert.Equal(t, "SELECT 2", entries[2].Query)
+
+			entries, _ = book.History("alice", 1)
+			assert.Len(t, entries, 1)
+
+			require.NoError(t, book.ClearHistory("alice"))
+			entries, _ = book.History("alice", 0)
+			assert.Empty(t, entries)
+			entries, _ = book.History("bob", 0)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package querybook_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"stackyrd/pkg/querybook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBind(t *testing.T) {
+	pg := querybook.SavedQuery{
+		Name:       "orders by status",
+		Engine:     querybook.EnginePostgres,
+		Query:      "SELECT * FROM orders WHERE status = {{status}} AND total > {{ min }} OR status = {{status}}",
+		Parameters: []querybook.Parameter{{Name: "status"}, {Name: "min", Default: 100}},
+	}
+	require.NoError(t, pg.Validate())
+	query, args, err := pg.Bind(map[string]interface{}{"status": "open'; DROP TABLE orders; --"})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM orders WHERE status = $1 AND total > $2 OR status = $1", query)
+	assert.Equal(t, []interface{}{"open'; DROP TABLE orders; --", 100}, args)
+
+	_, _, err = pg.Bind(nil)
+	assert.ErrorIs(t, err, querybook.ErrInvalid, "status has no default")
+	_, _, err = pg.Bind(map[string]interface{}{"status": "open", "limit": 5})
+	assert.ErrorIs(t, err, querybook.ErrInvalid, "unknown parameter")
+
+	mongo := querybook.SavedQuery{
+		Name:       "big orders",
+		Engine:     querybook.EngineMongo,
+		Collection: "orders",
+		Query:      `{"status": "{{status}}", "total": {"$gt": "{{min}}"}}`,
+		Parameters: []querybook.Parameter{{Name: "status", Default: "open"}, {Name: "min", Default: 100}},
+	}
+	require.NoError(t, mongo.Validate())
+	query, args, err = mongo.Bind(map[string]interface{}{"status": `a"b`})
+	require.NoError(t, err)
+	assert.Nil(t, args)
+	assert.Equal(t, `{"status": "a\"b", "total": {"$gt": 100}}`, query)
+}
+
+func TestValidateParameters(t *testing.T) {
+	for name, q := range map[string]querybook.SavedQuery{
+		"undeclared": {Engine: querybook.EnginePostgres, Query: "SELECT {{x}}"},
+		"bad name":   {Engine: querybook.EnginePostgres, Query: "SELECT 1", Parameters: []querybook.Parameter{{Name: "1x"}}},
+		"duplicate":  {Engine: querybook.EnginePostgres, Query: "SELECT {{x}}", Parameters: []querybook.Parameter{{Name: "x"}, {Name: "x"}}},
+		"unquoted":   {Engine: querybook.EngineMongo, Collection: "c", Query: `{"a": "x{{x}}"}`, Parameters: []querybook.Parameter{{Name: "x"}}},
+	} {
+		q.Name = name
+		assert.ErrorIs(t, q.Validate(), querybook.ErrInvalid, name)
+	}
+}
+
+// fakeCron records the jobs the scheduler adds.
+type fakeCron struct {
+	jobs   map[int]func()
+	nextID int
+}
+
+func (f *fakeCron) AddAsyncJob(_, schedule string, cmd func()) (int, error) {
+	if schedule == "never" {
+		return 0, errors.New("bad schedule")
+	}
+	f.nextID++
+	f.jobs[f.nextID] = cmd
+	return f.nextID, nil
+}
+
+func (f *fakeCron) RemoveJob(id int) error {
+	delete(f.jobs, id)
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	for name, newBook := range books(t) {
+		t.Run(name, func(t *testing.T) {
+			book := newBook(10)
+			_, err := book.Create(querybook.SavedQuery{
+				Name:       "signups",
+				Engine:     querybook.EnginePostgres,
+				Query:      "SELECT count(*) FROM users WHERE created_at > now() - {{since}}::interval",
+				Parameters: []querybook.Parameter{{Name: "since"}},
+			}, "alice")
+			require.NoError(t, err)
+
+			var calls int
+			run := func(_ context.Context, q querybook.SavedQuery, params map[string]interface{}) (*querybook.Result, error) {
+				calls++
+				if calls == 2 {
+					return nil, errors.New("connection refused")
+				}
+				return &querybook.Result{Columns: []string{"count"}, Rows: [][]interface{}{{calls}}, RowCount: 1}, nil
+			}
+
+			_, err = querybook.NewScheduler(book, nil, run, nil).SetReport("signups", querybook.Report{Schedule: "@daily"}, "alice")
+			assert.ErrorIs(t, err, querybook.ErrNoScheduler)
+
+			cron := &fakeCron{jobs: make(map[int]func())}
+			reports := querybook.NewScheduler(book, cron, run, nil)
+			_, err = reports.SetReport("signups", querybook.Report{Schedule: "@daily"}, "alice")
+			assert.ErrorIs(t, err, querybook.ErrInvalid, "since is required")
+			_, err = reports.SetReport("signups", querybook.Report{Schedule: "never", Params: map[string]interface{}{"since": "1 day"}}, "alice")
+			assert.ErrorIs(t, err, querybook.ErrInvalid)
+			_, err = reports.SetReport("missing", querybook.Report{Schedule: "@daily"}, "alice")
+			assert.ErrorIs(t, err, querybook.ErrNotFound)
+			assert.Empty(t, cron.jobs)
+
+			saved, err := reports.SetReport("signups", querybook.Report{Schedule: "@daily", Params: map[string]interface{}{"since": "1 day"}, Keep: 2}, "bob")
+			require.NoError(t, err)
+			require.NotNil(t, saved.Report)
+			assert.Equal(t, "bob", saved.Report.UpdatedBy)
+			_, err = reports.SetReport("signups", querybook.Report{Schedule: "@hourly", Params: map[string]interface{}{"since": "1 day"}, Keep: 2}, "bob")
+			require.NoError(t, err)
+			require.Len(t, cron.jobs, 1, "the previous schedule is replaced")
+
+			// Editing the query keeps its schedule
+			q, _ := book.Get("signups")
+			q.Description = "daily signups"
+			updated, err := book.Update("signups", q, "carol")
+			require.NoError(t, err)
+			assert.Equal(t, "@hourly", updated.Report.Schedule)
+
+			for _, job := range cron.jobs {
+				job()
+				job()
+				job()
+			}
+			runs, err := book.Runs("signups", 0)
+			require.NoError(t, err)
+			require.Len(t, runs, 2, "only Keep runs are kept")
+			assert.True(t, runs[0].Success)
+			assert.EqualValues(t, 3, runs[0].Result.Rows[0][0], "newest first")
+			assert.False(t, runs[1].Success)
+			assert.Equal(t, "connection refused", runs[1].Error)
+
+			// A restart schedules the stored reports again
+			restarted := &fakeCron{jobs: make(map[int]func())}
+			require.NoError(t, querybook.NewScheduler(book, restarted, run, nil).Start())
+			assert.Len(t, restarted.jobs, 1)
+
+			unscheduled, err := reports.RemoveReport("signups")
+			require.NoError(t, err)
+			assert.Nil(t, unscheduled.Report)
+			assert.Empty(t, cron.jobs)
+
+			require.NoError(t, book.Delete("signups"))
+			runs, err = book.Runs("signups", 0)
+			require.NoError(t, err)
+			assert.Empty(t, runs)
+		})
+	}
+}
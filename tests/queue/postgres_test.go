@@ -0,0 +1,231 @@
+package queue_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptResult is the answer of a scripted connection to a statement:
+// rows for queries, affected rows for the others.
+type scriptResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// statement is a statement a scripted connection ran.
+type statement struct {
+	query string
+	args  []driver.Value
+}
+
+// script answers statements with the result whose key the statement
+// contains, and records them. Statements matching no key succeed without
+// rows.
+type script struct {
+	mu         sync.Mutex
+	results    map[string]scriptResult
+	statements []statement
+}
+
+func (s *script) run(query string, args []driver.NamedValue) scriptResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	s.statements = append(s.statements, statement{query: query, args: values})
+	for key, result := range s.results {
+		if strings.Contains(query, key) {
+			return result
+		}
+	}
+	return scriptResult{}
+}
+
+// ran returns the statements containing key.
+func (s *script) ran(key string) []statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []statement
+	for _, st := range s.statements {
+		if strings.Contains(st.query, key) {
+			matched = append(matched, st)
+		}
+	}
+	return matched
+}
+
+// scriptDriver opens a connection to the script named by the data source
+// name.
+type scriptDriver struct {
+	mu      sync.Mutex
+	scripts map[string]*script
+}
+
+func (d *scriptDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &scriptConn{script: d.scripts[name]}, nil
+}
+
+type scriptConn struct{ script *script }
+
+func (c *scriptConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *scriptConn) Close() error                        { return nil }
+func (c *scriptConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *scriptConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &scriptRows{result: c.script.run(query, args)}, nil
+}
+
+func (c *scriptConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(c.script.run(query, args).affected), nil
+}
+
+type scriptRows struct {
+	result scriptResult
+	next   int
+}
+
+func (r *scriptRows) Columns() []string { return r.result.columns }
+func (r *scriptRows) Close() error      { return nil }
+func (r *scriptRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+var (
+	scripts         = &scriptDriver{scripts: make(map[string]*script)}
+	registerScripts sync.Once
+)
+
+// openScript returns a Postgres backend on a database answering with
+// results.
+func openScript(t *testing.T, results map[string]scriptResult) (*queue.PostgresBackend, *script) {
+	t.Helper()
+	registerScripts.Do(func() { sql.Register("queue-script", scripts) })
+	s := &script{results: results}
+	scripts.mu.Lock()
+	name := fmt.Sprintf("%s#%d", t.Name(), len(scripts.scripts))
+	scripts.scripts[name] = s
+	scripts.mu.Unlock()
+	db, err := sql.Open("queue-script", name)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return queue.NewPostgresBackend(func() *sql.DB { return db }, "jobs"), s
+}
+
+var jobColumns = []string{"id", "type", "payload", "priority", "attempts", "max_attempts", "run_at", "created_at", "failed_at", "last_error"}
+
+func TestPostgresBackend_NotConnected(t *testing.T) {
+	backend := queue.NewPostgresBackend(func() *sql.DB { return nil }, "")
+	_, err := backend.Claim(context.Background(), time.Now(), time.Minute)
+	assert.ErrorIs(t, err, queue.ErrNotConnected)
+}
+
+func TestPostgresBackend_CreatesTheTableOnce(t *testing.T) {
+	ctx := context.Background()
+	backend, s := openScript(t, nil)
+	now := time.Now()
+	require.NoError(t, backend.Push(ctx, queue.Job{ID: "a", Type: "sync", RunAt: now, CreatedAt: now}))
+	require.NoError(t, backend.Push(ctx, queue.Job{ID: "b", Type: "sync", Priority: 5, RunAt: now, CreatedAt: now, Payload: []byte(`{"x":1}`)}))
+
+	assert.Len(t, s.ran("CREATE TABLE IF NOT EXISTS jobs"), 1)
+	assert.Len(t, s.ran("CREATE INDEX IF NOT EXISTS jobs_due"), 1)
+	inserts := s.ran("INSERT INTO jobs")
+	require.Len(t, inserts, 2)
+	assert.Equal(t, []driver.Value{"a", "sync", nil, int64(0), "pending", int64(0), int64(0), now, now}, inserts[0].args)
+	assert.Equal(t, `{"x":1}`, inserts[1].args[2])
+	assert.Equal(t, int64(5), inserts[1].args[3])
+}
+
+func TestPostgresBackend_Claim(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	runAt := now.Add(-time.Minute)
+	backend, s := openScript(t, map[string]scriptResult{
+		"FOR UPDATE SKIP LOCKED": {columns: jobColumns, rows: [][]driver.Value{
+			{"a", "sync", []byte(`{"user_id":"42"}`), int64(10), int64(1), int64(3), runAt, runAt, nil, "timeout"},
+		}},
+	})
+
+	job, err := backend.Claim(ctx, now, 5*time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, queue.Job{
+		ID: "a", Type: "sync", Payload: []byte(`{"user_id":"42"}`), Priority: 10, Attempts: 1, MaxAttempts: 3,
+		RunAt: runAt, CreatedAt: runAt, LastError: "timeout",
+	}, *job)
+
+	// One statement leases the due job of highest priority, skipping
+	// those other workers hold, and takes over expired leases
+	claims := s.ran("FOR UPDATE SKIP LOCKED")
+	require.Len(t, claims, 1)
+	claim := claims[0]
+	assert.Contains(t, claim.query, "UPDATE jobs SET state = $3, locked_until = $2")
+	assert.Contains(t, claim.query, "(state = $4 AND run_at <= $1) OR (state = $3 AND locked_until < $1)")
+	assert.Contains(t, claim.query, "ORDER BY priority DESC, run_at")
+	assert.Equal(t, []driver.Value{now, now.Add(5 * time.Minute), "running", "pending"}, claim.args)
+
+	idle, _ := openScript(t, map[string]scriptResult{"FOR UPDATE SKIP LOCKED": {columns: jobColumns}})
+	job, err = idle.Claim(ctx, now, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, job, "no due job")
+}
+
+func TestPostgresBackend_RetryBuryRequeue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backend, s := openScript(t, map[string]scriptResult{
+		"AND state = $4": {affected: 1},
+	})
+	job := queue.Job{ID: "a", Type: "sync", Attempts: 2, RunAt: now.Add(time.Minute), LastError: "timeout"}
+
+	require.NoError(t, backend.Retry(ctx, job))
+	retries := s.ran("SET state = $2, attempts = $3, run_at = $4")
+	require.Len(t, retries, 1)
+	assert.Contains(t, retries[0].query, "locked_until = NULL")
+	assert.Equal(t, []driver.Value{"a", "pending", int64(2), now.Add(time.Minute), "timeout"}, retries[0].args)
+
+	failedAt := now.Add(time.Hour)
+	job.FailedAt = &failedAt
+	require.NoError(t, backend.Bury(ctx, job))
+	buries := s.ran("failed_at = $4")
+	require.Len(t, buries, 1)
+	assert.Equal(t, []driver.Value{"a", "dead", int64(2), failedAt, "timeout"}, buries[0].args)
+
+	ok, err := backend.Requeue(ctx, "a", now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	requeues := s.ran("attempts = 0")
+	require.Len(t, requeues, 1)
+	assert.Contains(t, requeues[0].query, "failed_at = NULL")
+	assert.Equal(t, []driver.Value{"a", "pending", now, "dead"}, requeues[0].args, "only dead jobs are requeued")
+
+	gone, _ := openScript(t, nil)
+	ok, err = gone.Requeue(ctx, "a", now)
+	require.NoError(t, err)
+	assert.False(t, ok, "no dead job of that ID")
+	ok, err = gone.Discard(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
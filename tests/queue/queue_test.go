@@ -0,0 +1,158 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type welcomeEmail struct {
+	UserID string `json:"user_id"`
+}
+
+func newQueue(t *testing.T, backend queue.Backend) *queue.Queue {
+	t.Helper()
+	q := queue.New(backend, queue.Options{Workers: 1, PollInterval: 5 * time.Millisecond,
+		Retry: queue.RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}})
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestQueue_TypedJob(t *testing.T) {
+	q := newQueue(t, queue.NewMemoryBackend())
+	welcome := queue.NewType[welcomeEmail]("send_welcome_email")
+	sent := make(chan string, 1)
+	welcome.Handle(q, func(ctx context.Context, payload welcomeEmail) error {
+		sent <- payload.UserID
+		return nil
+	}, queue.RetryPolicy{})
+	q.Start()
+
+	_, err := welcome.Enqueue(context.Background(), q, welcomeEmail{UserID: "42"})
+	require.NoError(t, err)
+	select {
+	case id := <-sent:
+		assert.Equal(t, "42", id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not run")
+	}
+	assert.Eventually(t, func() bool {
+		depth, _ := q.Depth(context.Background())
+		return depth == queue.Depth{}
+	}, time.Second, 5*time.Millisecond, "completed jobs are removed")
+}
+
+func TestQueue_RetriesThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	q := newQueue(t, queue.NewMemoryBackend())
+	var mu sync.Mutex
+	attempts := 0
+	q.Register("flaky", func(ctx context.Context, job queue.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		return errors.New("upstream down")
+	}, queue.RetryPolicy{MaxAttempts: 3})
+	q.Start()
+
+	job, err := q.Enqueue(ctx, "flaky", nil)
+	require.NoError(t, err)
+	var dead []queue.Job
+	require.Eventually(t, func() bool {
+		dead, _ = q.Dead(ctx, 10)
+		return len(dead) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, job.ID, dead[0].ID)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, "upstream down", dead[0].LastError)
+	assert.NotNil(t, dead[0].FailedAt)
+	mu.Lock()
+	assert.Equal(t, 3, attempts)
+	mu.Unlock()
+
+	// A manual retry runs it again with fresh attempts
+	found, err := q.Retry(ctx, job.ID)
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts == 6
+	}, 2*time.Second, 5*time.Millisecond)
+
+	found, err = q.Retry(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestQueue_PermanentError(t *testing.T) {
+	ctx := context.Background()
+	q := newQueue(t, queue.NewMemoryBackend())
+	q.Register("invalid", func(ctx context.Context, job queue.Job) error {
+		return queue.Permanent(errors.New("bad input"))
+	}, queue.RetryPolicy{MaxAttempts: 10})
+	q.Start()
+
+	job, err := q.Enqueue(ctx, "invalid", map[string]int{"n": 1})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		dead, _ := q.Dead(ctx, 10)
+		return len(dead) == 1 && dead[0].Attempts == 1
+	}, 2*time.Second, 5*time.Millisecond, "dead-lettered without retries")
+
+	found, err := q.Discard(ctx, job.ID)
+	require.NoError(t, err)
+	assert.True(t, found)
+	depth, err := q.Depth(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, depth.Dead)
+}
+
+func TestMemoryBackend_ClaimOrder(t *testing.T) {
+	ctx := context.Background()
+	b := queue.NewMemoryBackend()
+	now := time.Now()
+	push := func(id string, priority int, runAt time.Time) {
+		require.NoError(t, b.Push(ctx, queue.Job{ID: id, Type: "t", Priority: priority, RunAt: runAt, CreatedAt: now}))
+	}
+	push("later", 100, now.Add(time.Hour))
+	push("old", 0, now.Add(-2*time.Second))
+	push("new", 0, now.Add(-time.Second))
+	push("urgent", 10, now)
+
+	depth, err := b.Depth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{Ready: 3, Scheduled: 1}, depth)
+
+	var order []string
+	for {
+		job, err := b.Claim(ctx, now, time.Minute)
+		require.NoError(t, err)
+		if job == nil {
+			break
+		}
+		order = append(order, job.ID)
+	}
+	assert.Equal(t, []string{"urgent", "old", "new"}, order, "priority first, then due time; delayed jobs wait")
+
+	// Expired leases make a job due again
+	job, err := b.Claim(ctx, now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Contains(t, []string{"urgent", "old", "new"}, job.ID)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := queue.RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 4*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(10))
+}
@@ -0,0 +1,161 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/queue"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisBackend(t *testing.T, prefix string) (*queue.RedisBackend, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return queue.NewRedisBackend(client, prefix), server
+}
+
+func TestRedisBackend_ClaimsByPriorityThenDueTime(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newRedisBackend(t, "queue:")
+	now := time.Now().Truncate(time.Millisecond)
+
+	for _, job := range []queue.Job{
+		{ID: "low", Type: "report", Priority: -5, RunAt: now.Add(-time.Minute)},
+		{ID: "later", Type: "report", RunAt: now.Add(-time.Second)},
+		{ID: "first", Type: "report", RunAt: now.Add(-time.Minute), Payload: json.RawMessage(`{"user_id":"42"}`)},
+		{ID: "urgent", Type: "report", Priority: 10, RunAt: now},
+		{ID: "tomorrow", Type: "report", Priority: 100, RunAt: now.Add(24 * time.Hour)},
+	} {
+		require.NoError(t, backend.Push(ctx, job))
+	}
+	depth, err := backend.Depth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{Ready: 4, Scheduled: 1}, depth)
+
+	var order []string
+	for {
+		job, err := backend.Claim(ctx, now, time.Minute)
+		require.NoError(t, err)
+		if job == nil {
+			break
+		}
+		order = append(order, job.ID)
+		if job.ID == "first" {
+			assert.JSONEq(t, `{"user_id":"42"}`, string(job.Payload))
+		}
+	}
+	assert.Equal(t, []string{"urgent", "first", "later", "low"}, order, "scheduled jobs wait until due")
+
+	depth, err = backend.Depth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{Scheduled: 1, Running: 4}, depth)
+
+	job, err := backend.Claim(ctx, now.Add(25*time.Hour), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, "tomorrow", job.ID, "due scheduled jobs are moved to ready")
+}
+
+func TestRedisBackend_ExpiredLeasesAreClaimedAgain(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newRedisBackend(t, "queue:")
+	now := time.Now()
+	require.NoError(t, backend.Push(ctx, queue.Job{ID: "a", Type: "sync", RunAt: now}))
+
+	job, err := backend.Claim(ctx, now, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	job, err = backend.Claim(ctx, now.Add(30*time.Second), time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, job, "leased to the first worker")
+
+	job, err = backend.Claim(ctx, now.Add(2*time.Minute), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job, "the lease expired")
+	require.NoError(t, backend.Complete(ctx, *job))
+	depth, err := backend.Depth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{}, depth)
+}
+
+func TestRedisBackend_RetryBuryRequeue(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newRedisBackend(t, "queue:")
+	now := time.Now()
+	require.NoError(t, backend.Push(ctx, queue.Job{ID: "a", Type: "sync", RunAt: now}))
+
+	job, err := backend.Claim(ctx, now, time.Minute)
+	require.NoError(t, err)
+	job.Attempts, job.LastError, job.RunAt = 1, "timeout", now.Add(time.Hour)
+	require.NoError(t, backend.Retry(ctx, *job))
+	depth, err := backend.Depth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{Scheduled: 1}, depth, "retried with backoff")
+
+	job, err = backend.Claim(ctx, now.Add(2*time.Hour), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "timeout", job.LastError)
+
+	failedAt := now.Add(2 * time.Hour)
+	job.Attempts, job.FailedAt = 2, &failedAt
+	require.NoError(t, backend.Bury(ctx, *job))
+	dead, err := backend.Dead(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+	depth, err = backend.Depth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Depth{Dead: 1}, depth)
+
+	ok, err := backend.Requeue(ctx, "a", now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = backend.Requeue(ctx, "a", now)
+	require.NoError(t, err)
+	assert.False(t, ok, "requeued once")
+	job, err = backend.Claim(ctx, now, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, 0, job.Attempts)
+	assert.Nil(t, job.FailedAt)
+
+	require.NoError(t, backend.Bury(ctx, *job))
+	ok, err = backend.Discard(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	dead, err = backend.Dead(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, dead)
+}
+
+func TestRedisBackend_KeysShareAHashTag(t *testing.T) {
+	ctx := context.Background()
+	for prefix, tag := range map[string]string{"queue:": "{queue}:", "app:jobs:": "{app:jobs}:", "{tenant-a}:queue:": "{tenant-a}:queue:", "": "{queue}"} {
+		backend, server := newRedisBackend(t, prefix)
+		now := time.Now()
+		require.NoError(t, backend.Push(ctx, queue.Job{ID: "a", Type: "sync", RunAt: now}))
+		require.NoError(t, backend.Push(ctx, queue.Job{ID: "b", Type: "sync", RunAt: now.Add(time.Hour)}))
+		job, err := backend.Claim(ctx, now, time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, backend.Bury(ctx, *job))
+
+		// Redis Cluster hashes only the tag, so the claim script's job keys
+		// are in the slot of its declared keys
+		keys := server.Keys()
+		require.NotEmpty(t, keys)
+		for _, key := range keys {
+			assert.True(t, strings.HasPrefix(key, tag), "%q: %q", prefix, key)
+		}
+	}
+}
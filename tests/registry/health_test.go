@@ -0,0 +1,74 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// healthService reports err from HealthCheck after delay, ignoring ctx.
+type healthService struct {
+	name    string
+	enabled bool
+	err     error
+	delay   time.Duration
+}
+
+func (s *healthService) Name() string                      { return s.name }
+func (s *healthService) WireName() string                  { return s.name }
+func (s *healthService) Enabled() bool                     { return s.enabled }
+func (s *healthService) Endpoints() []string               { return nil }
+func (s *healthService) RegisterRoutes(g *gin.RouterGroup) {}
+func (s *healthService) Get() interface{}                  { return s }
+
+func (s *healthService) HealthCheck(ctx context.Context) error {
+	time.Sleep(s.delay)
+	return s.err
+}
+
+// uncheckedService has no HealthCheck.
+type uncheckedService struct{ lifecycleService }
+
+func TestCheckHealth(t *testing.T) {
+	services := []interfaces.Service{
+		&healthService{name: "up", enabled: true},
+		&healthService{name: "down", enabled: true, err: errors.New("connection refused")},
+		&healthService{name: "off", err: errors.New("not checked")},
+		&uncheckedService{lifecycleService{name: "plain", enabled: true}},
+		&healthService{name: "slow", enabled: true, delay: time.Second},
+	}
+
+	start := time.Now()
+	results := registry.CheckHealth(context.Background(), services, 50*time.Millisecond)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "a slow check is abandoned at the timeout")
+
+	statuses := map[string]registry.ServiceHealth{}
+	for _, result := range results {
+		statuses[result.Name] = result
+	}
+	assert.Len(t, results, len(services))
+	assert.Equal(t, "up", results[0].Name, "results keep the order of the services")
+
+	assert.Equal(t, registry.HealthOK, statuses["up"].Status)
+	assert.True(t, statuses["up"].Healthy())
+
+	assert.Equal(t, registry.HealthDown, statuses["down"].Status)
+	assert.Equal(t, "connection refused", statuses["down"].Error)
+	assert.False(t, statuses["down"].Healthy())
+
+	assert.Equal(t, registry.HealthDisabled, statuses["off"].Status)
+	assert.True(t, statuses["off"].Healthy())
+
+	assert.Equal(t, registry.HealthUnchecked, statuses["plain"].Status)
+	assert.True(t, statuses["plain"].Healthy())
+
+	assert.Equal(t, registry.HealthDown, statuses["slow"].Status)
+	assert.Contains(t, statuses["slow"].Error, "deadline exceeded")
+}
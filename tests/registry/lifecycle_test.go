@@ -0,0 +1,67 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleService records its Start and Stop calls in events.
+type lifecycleService struct {
+	name     string
+	enabled  bool
+	startErr error
+	events   *[]string
+}
+
+func (s *lifecycleService) Name() string                      { return s.name }
+func (s *lifecycleService) WireName() string                  { return s.name }
+func (s *lifecycleService) Enabled() bool                     { return s.enabled }
+func (s *lifecycleService) Endpoints() []string               { return nil }
+func (s *lifecycleService) RegisterRoutes(g *gin.RouterGroup) {}
+func (s *lifecycleService) Get() interface{}                  { return s }
+
+func (s *lifecycleService) Start(ctx context.Context) error {
+	*s.events = append(*s.events, "start "+s.name)
+	return s.startErr
+}
+
+func (s *lifecycleService) Stop(ctx context.Context) error {
+	*s.events = append(*s.events, "stop "+s.name)
+	return nil
+}
+
+// plainService has no lifecycle hooks.
+type plainService struct{ lifecycleService }
+
+func (s *plainService) Start() {}
+
+func TestServiceRegistry_StartStop(t *testing.T) {
+	var events []string
+	r := registry.NewServiceRegistry(logger.NewQuiet(false, nil))
+	r.Register(&lifecycleService{name: "a", enabled: true, events: &events})
+	r.Register(&lifecycleService{name: "disabled", events: &events})
+	r.Register(&lifecycleService{name: "broken", enabled: true, startErr: errors.New("no port"), events: &events})
+	r.Register(&plainService{lifecycleService{name: "plain", enabled: true, events: &events}})
+	r.Register(&lifecycleService{name: "b", enabled: true, events: &events})
+
+	err := r.Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken: no port")
+	assert.Equal(t, []string{"start a", "start broken", "start b"}, events)
+
+	// Only the started services stop, in reverse order, and only once
+	events = nil
+	require.NoError(t, r.Stop(context.Background()))
+	assert.Equal(t, []string{"stop b", "stop a"}, events)
+	events = nil
+	require.NoError(t, r.Stop(context.Background()))
+	assert.Empty(t, events)
+}
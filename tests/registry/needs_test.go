@@ -0,0 +1,80 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusComponent reports status like an infrastructure component.
+type statusComponent map[string]interface{}
+
+func (c statusComponent) GetStatus() map[string]interface{} { return c }
+
+func TestUnmetNeeds(t *testing.T) {
+	deps := registry.NewDependencies()
+	deps.Set("kafka", statusComponent{"connected": true})
+	deps.Set("redis", statusComponent{"connected": false})
+	deps.Set("postgres", statusComponent{
+		"default":  map[string]interface{}{"connected": true},
+		"tenant_a": map[string]interface{}{"connected": false},
+	})
+	deps.Set("mongo", statusComponent{"default": map[string]interface{}{"connected": false}})
+	deps.Set("clock", "no status")
+
+	needs := []string{"kafka", "redis", "postgres", "postgres:default", "postgres:tenant_a", "postgres:tenant_b", "mongo", "clock", "nats"}
+	assert.Equal(t, []string{"redis", "postgres:tenant_a", "postgres:tenant_b", "mongo", "nats"}, registry.UnmetNeeds(deps, needs))
+	assert.Empty(t, registry.UnmetNeeds(deps, nil))
+	assert.Equal(t, "postgres", registry.NeedComponent("postgres:tenant_a"))
+}
+
+func TestAutoDiscoverServices_Needs(t *testing.T) {
+	registry.RegisterService("needs_orders_service", func(*config.Config, *logger.Logger, *registry.Dependencies) interfaces.Service {
+		return &healthService{name: "Orders", enabled: true}
+	}, "postgres:tenant_a", "kafka")
+	registry.RegisterService("needs_reports_service", func(*config.Config, *logger.Logger, *registry.Dependencies) interfaces.Service {
+		return &healthService{name: "Reports", enabled: true}
+	}, "kafka")
+	cfg := &config.Config{Services: config.ServicesConfig{}}
+	for name := range registry.GetServiceFactories() {
+		cfg.Services[name] = name == "needs_orders_service" || name == "needs_reports_service"
+	}
+
+	deps := registry.NewDependencies()
+	deps.Set("kafka", statusComponent{"connected": true})
+	deps.Set("postgres", statusComponent{"tenant_a": map[string]interface{}{"connected": false}})
+
+	services := registry.AutoDiscoverServices(cfg, logger.NewQuiet(false, nil), deps)
+	require.Len(t, services, 2)
+	deferred, ok := services[0].(*registry.DeferredService)
+	require.True(t, ok, "the orders service waits for postgres:tenant_a")
+	assert.Equal(t, "needs_orders_service", deferred.Name())
+	assert.Equal(t, "needs_orders", deferred.WireName())
+	assert.Equal(t, []string{"postgres:tenant_a"}, deferred.Missing)
+	assert.Equal(t, []string{"postgres:tenant_a", "kafka"}, registry.ServiceNeeds("needs_orders_service"))
+	assert.Equal(t, []string{"kafka", "postgres"}, registry.ServiceDependencies("needs_orders_service"))
+	assert.Equal(t, "Reports", services[1].Name())
+	assert.Equal(t, []string{"kafka"}, registry.ServiceNeeds("Reports"))
+	assert.Len(t, registry.DeferredServices(services), 1)
+
+	health := registry.CheckHealth(context.Background(), services, 0)
+	assert.Equal(t, registry.HealthDegraded, health[0].Status)
+	assert.Contains(t, health[0].Error, "postgres:tenant_a")
+	assert.True(t, health[0].Healthy(), "a deferred service does not keep the instance from being ready")
+
+	// Once the connection is up, discovering again creates the service
+	assert.False(t, deferred.Ready(deps))
+	deps.Set("postgres", statusComponent{"tenant_a": map[string]interface{}{"connected": true}})
+	assert.True(t, deferred.Ready(deps))
+	services = registry.AutoDiscoverServices(cfg, logger.NewQuiet(false, nil), deps)
+	require.Len(t, services, 2)
+	assert.Equal(t, "Orders", services[0].Name())
+	assert.Empty(t, registry.DeferredServices(services))
+}
@@ -0,0 +1,237 @@
+package remoteconfig_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/remoteconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kvStore is a single versioned key shared by the fake servers.
+type kvStore struct {
+	mu      sync.Mutex
+	value   string
+	version int
+	changed chan struct{}
+}
+
+func newStore(value string) *kvStore {
+	return &kvStore{value: value, version: 1, changed: make(chan struct{})}
+}
+
+func (s *kvStore) get() (string, int, chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value, s.version, s.changed
+}
+
+func (s *kvStore) set(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = value
+	s.version++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// fakeConsul serves /v1/kv/<key>?raw with blocking queries.
+func fakeConsul(t *testing.T, key, token string, store *kvStore) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/kv/"+key {
+			w.Header().Set("X-Consul-Index", "1")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		value, version, changed := store.get()
+		if index := r.URL.Query().Get("index"); index == strconv.Itoa(version) {
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+			value, version, _ = store.get()
+		}
+		w.Header().Set("X-Consul-Index", strconv.Itoa(version))
+		fmt.Fprint(w, value)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeEtcd serves the v3 JSON gateway's range, watch and authenticate.
+func fakeEtcd(t *testing.T, key string, store *kvStore) *httptest.Server {
+	encodedKey := base64.StdEncoding.EncodeToString([]byte(key))
+	kv := func(value string, version int) map[string]string {
+		return map[string]string{"key": encodedKey, "value": base64.StdEncoding.EncodeToString([]byte(value)), "mod_revision": strconv.Itoa(version)}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/auth/authenticate" {
+			json.NewEncoder(w).Encode(map[string]string{"token": "tok-1"})
+			return
+		}
+		if r.Header.Get("Authorization") != "tok-1" {
+			http.Error(w, `{"error":"invalid auth token"}`, http.StatusUnauthorized)
+			return
+		}
+		var body map[string]map[string]interface{}
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			value, version, _ := store.get()
+			resp := map[string]interface{}{"header": map[string]string{"revision": strconv.Itoa(version)}}
+			if req["key"] == encodedKey {
+				resp["kvs"] = []interface{}{kv(value, version)}
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/v3/watch":
+			json.NewDecoder(r.Body).Decode(&body)
+			start, _ := strconv.Atoi(fmt.Sprint(body["create_request"]["start_revision"]))
+			flusher := w.(http.Flusher)
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+			flusher.Flush()
+			for {
+				value, version, changed := store.get()
+				if version >= start {
+					json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+						"events": []interface{}{map[string]interface{}{"kv": kv(value, version)}},
+					}})
+					flusher.Flush()
+					start = version + 1
+				}
+				select {
+				case <-changed:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpen(t *testing.T) {
+	assert.True(t, remoteconfig.IsRemote("etcd://etcd:2379/app/config"))
+	assert.True(t, remoteconfig.IsRemote("consuls://consul:8501/app/config"))
+	assert.False(t, remoteconfig.IsRemote("https://config.example.com/app.yaml"))
+	assert.False(t, remoteconfig.IsRemote(""))
+
+	p, err := remoteconfig.Open("consuls://secret-token@consul:8501/app/config?dc=eu", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "consul", p.Name())
+	assert.Equal(t, "https://consul:8501", p.Endpoint(), "no credentials in the endpoint")
+	assert.Equal(t, "app/config", p.Key())
+
+	_, err = remoteconfig.Open("etcd://etcd:2379/", 0)
+	assert.Error(t, err, "a key is required")
+}
+
+func TestConsul_GetAndWait(t *testing.T) {
+	store := newStore("app:\n  name: one\n")
+	srv := fakeConsul(t, "stackyrd/config", "s3cret", store)
+	p, err := remoteconfig.Open(strings.Replace(srv.URL, "http://", "consul://s3cret@", 1)+"/stackyrd/config", time.Second)
+	require.NoError(t, err)
+
+	v, err := p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "app:\n  name: one\n", string(v.Data))
+	assert.Equal(t, "1", v.Version)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.set("app:\n  name: two\n")
+	}()
+	changed, err := p.Wait(context.Background(), v.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "2", changed.Version)
+	assert.Contains(t, string(changed.Data), "two")
+
+	missing, err := remoteconfig.Open(strings.Replace(srv.URL, "http://", "consul://s3cret@", 1)+"/other", time.Second)
+	require.NoError(t, err)
+	_, err = missing.Get(context.Background())
+	assert.ErrorIs(t, err, remoteconfig.ErrNotFound)
+}
+
+func TestEtcd_GetAndWait(t *testing.T) {
+	store := newStore("app:\n  name: one\n")
+	srv := fakeEtcd(t, "/stackyrd/config", store)
+	p, err := remoteconfig.Open(strings.Replace(srv.URL, "http://", "etcd://root:pw@", 1)+"//stackyrd/config", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "/stackyrd/config", p.Key())
+
+	v, err := p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "app:\n  name: one\n", string(v.Data))
+	assert.Equal(t, "1", v.Version)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.set("app:\n  name: two\n")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed, err := p.Wait(ctx, v.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "2", changed.Version)
+	assert.Contains(t, string(changed.Data), "two")
+}
+
+func TestWatcher_ReportsChanges(t *testing.T) {
+	store := newStore("app:\n  name: stackyrd\n")
+	srv := fakeConsul(t, "cfg", "", store)
+	p, err := remoteconfig.Open(strings.Replace(srv.URL, "http://", "consul://", 1)+"/cfg", time.Second)
+	require.NoError(t, err)
+
+	running, err := config.ReadConfigData([]byte("app:\n  name: stackyrd\n"), config.FormatYAML)
+	require.NoError(t, err)
+	w := remoteconfig.NewWatcher(p, config.Fingerprint(running), remoteconfig.RefreshWatch, 50*time.Millisecond, nil)
+	changes := make(chan remoteconfig.State, 4)
+	w.OnChange = func(s remoteconfig.State) { changes <- s }
+	w.Start()
+	defer w.Close()
+
+	require.Eventually(t, func() bool {
+		last, ok := w.Last()
+		return ok && last.OK() && !last.Changed
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// An invalid document is reported but never applied
+	store.set("app: [unclosed\n")
+	require.Eventually(t, func() bool {
+		last, _ := w.Last()
+		return !last.OK()
+	}, 2*time.Second, 10*time.Millisecond)
+
+	store.set("app:\n  name: renamed\n")
+	select {
+	case s := <-changes:
+		assert.True(t, s.Changed)
+		assert.Equal(t, "3", s.Version)
+	case <-time.After(3 * time.Second):
+		t.Fatal("change not reported")
+	}
+	status := w.Status()
+	assert.Equal(t, "consul", status["provider"])
+	assert.Equal(t, "watch", status["refresh"])
+	assert.Len(t, changes, 0, "each new config is reported once")
+}
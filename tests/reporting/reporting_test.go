@@ -0,0 +1,196 @@
+package reporting_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/reporting"
+	"stackyrd/pkg/endpointstats"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMailer records the emails sent.
+type fakeMailer struct {
+	mu    sync.Mutex
+	err   error
+	sent  []string
+	to    []string
+	calls int
+}
+
+func (f *fakeMailer) Send(_ context.Context, to []string, subject, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return f.err
+	}
+	f.to = to
+	f.sent = append(f.sent, subject+"\n"+body)
+	return nil
+}
+
+func testSources(logs *logger.LogBroadcaster, mailer reporting.Mailer) reporting.Sources {
+	return reporting.Sources{
+		App:       config.AppConfig{Name: "stackyrd", Version: "1.2.3", Env: "test"},
+		StartedAt: time.Now().Add(-time.Hour),
+		Components: func() map[string]map[string]interface{} {
+			return map[string]map[string]interface{}{
+				"redis":    {"connected": true},
+				"postgres": {"connected": false, "error": "connection refused"},
+				"cron":     {"jobs": 3},
+			}
+		},
+		Endpoints: func() []endpointstats.RouteStats {
+			return []endpointstats.RouteStats{
+				{Method: "GET", Route: "/health", Requests: 10},
+				{Method: "POST", Route: "/orders", Requests: 40, Errors: 4, P95LatencyMs: 120},
+				{Method: "GET", Route: "/orders/:id", Requests: 20, Errors: 1},
+			}
+		},
+		Logs:   logs,
+		Mailer: func() reporting.Mailer { return mailer },
+	}
+}
+
+func TestGenerator_Snapshot(t *testing.T) {
+	logs := logger.NewLogBroadcaster(10)
+	logs.Publish(logger.LogEntry{Level: "error", Message: "boom"})
+	logs.Publish(logger.LogEntry{Level: "info", Message: "ok"})
+	g := reporting.NewGenerator(config.ReportsConfig{TopEndpoints: 2}, testSources(logs, nil), nil, logger.New(false, nil))
+
+	report, err := g.Generate(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "stackyrd", report.App)
+	assert.InDelta(t, 3600, report.UptimeSeconds, 5)
+	assert.Equal(t, []reporting.ComponentHealth{
+		{Name: "cron", Status: "unknown"},
+		{Name: "postgres", Status: "down", Error: "connection refused"},
+		{Name: "redis", Status: "up"},
+	}, report.Infrastructure)
+	assert.Equal(t, 1, report.Down)
+	assert.Equal(t, int64(70), report.Requests, "totals cover every route")
+	assert.Equal(t, int64(5), report.Errors)
+	require.Len(t, report.Endpoints, 2, "only the busiest routes are listed")
+	assert.Equal(t, "/orders", report.Endpoints[0].Route)
+	assert.Equal(t, 10.0, report.Endpoints[0].ErrorRate)
+	assert.Equal(t, map[string]uint64{"error": 1, "info": 1}, report.Logs)
+
+	// Logs count from the previous report
+	logs.Publish(logger.LogEntry{Level: "warn", Message: "slow"})
+	next, err := g.Generate(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"warn": 1}, next.Logs)
+	assert.Equal(t, report.GeneratedAt, next.From)
+	assert.Greater(t, next.ID, report.ID)
+
+	list, err := g.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, next.ID, list[0].ID, "newest first")
+	assert.Equal(t, uint64(1), list[1].LogErrors)
+}
+
+func TestGenerator_Render(t *testing.T) {
+	g := reporting.NewGenerator(config.ReportsConfig{}, testSources(nil, nil), nil, logger.New(false, nil))
+	report, err := g.Generate(context.Background(), false)
+	require.NoError(t, err)
+
+	data, contentType, err := reporting.Render(report, reporting.FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	var decoded reporting.Report
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, report.ID, decoded.ID)
+
+	data, _, err = reporting.Render(report, reporting.FormatCSV)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"section", "name", "metric", "value"}, rows[0])
+	assert.Contains(t, rows, []string{"infrastructure", "postgres", "status", "down"})
+	assert.Contains(t, rows, []string{"endpoint", "POST /orders", "error_rate", "10.00"})
+
+	data, contentType, err = reporting.Render(report, reporting.FormatHTML)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", contentType)
+	assert.Contains(t, string(data), `<td class="down">down</td>`)
+	assert.Contains(t, string(data), "POST /orders")
+
+	_, _, err = reporting.Render(report, "pdf")
+	assert.Error(t, err)
+}
+
+func TestGenerator_Email(t *testing.T) {
+	mailer := &fakeMailer{}
+	cfg := config.ReportsConfig{Recipients: []string{"ops@example.com"}}
+	g := reporting.NewGenerator(cfg, testSources(nil, mailer), nil, logger.New(false, nil))
+
+	report, err := g.Generate(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops@example.com"}, report.Emailed)
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, []string{"ops@example.com"}, mailer.to)
+	assert.Contains(t, mailer.sent[0], "1 component(s) down")
+	assert.Contains(t, mailer.sent[0], "POST /orders")
+
+	_, err = g.Generate(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mailer.calls, "not emailed unless asked")
+
+	mailer.err = errors.New("smtp unavailable")
+	report, err = g.Generate(context.Background(), true)
+	require.NoError(t, err, "a failed send keeps the report")
+	assert.Empty(t, report.Emailed)
+	assert.Equal(t, "smtp unavailable", report.EmailError)
+
+	g = reporting.NewGenerator(cfg, testSources(nil, nil), nil, logger.New(false, nil))
+	report, err = g.Generate(context.Background(), true)
+	require.NoError(t, err)
+	assert.Contains(t, report.EmailError, "no mailer")
+}
+
+func TestGenerator_KeepsInStore(t *testing.T) {
+	l := logger.New(false, nil)
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, l)
+	require.NoError(t, err)
+	defer store.Close()
+
+	g := reporting.NewGenerator(config.ReportsConfig{Keep: 3}, testSources(nil, nil), store, l)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		report, err := g.Generate(context.Background(), false)
+		require.NoError(t, err)
+		ids = append(ids, report.ID)
+	}
+
+	list, err := g.List()
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, ids[4], list[0].ID)
+	assert.Equal(t, ids[2], list[2].ID)
+
+	_, err = g.Get(ids[0])
+	assert.ErrorIs(t, err, reporting.ErrReportNotFound, "pruned")
+	report, err := g.Get(ids[3])
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Down)
+
+	// Kept across restarts
+	g = reporting.NewGenerator(config.ReportsConfig{Keep: 3}, testSources(nil, nil), store, l)
+	list, err = g.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 3)
+}
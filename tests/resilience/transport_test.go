@@ -0,0 +1,107 @@
+package resilience_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/resilience"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	cb := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{Name: "x", MaxFailures: 2, ResetTimeout: 20 * time.Millisecond, HalfOpenMaxRequests: 1})
+	cb.RecordFailure()
+	cb.RecordFailure()
+	assert.Equal(t, resilience.StateOpen, cb.GetState())
+	assert.ErrorIs(t, cb.Execute(func() error { return nil }), resilience.ErrCircuitOpen)
+
+	time.Sleep(30 * time.Millisecond)
+	require.True(t, cb.AllowRequest())
+	assert.Equal(t, resilience.StateHalfOpen, cb.GetState())
+	assert.False(t, cb.AllowRequest(), "one trial request at a time")
+	cb.RecordSuccess()
+	assert.Equal(t, resilience.StateClosed, cb.GetState())
+}
+
+func TestBulkhead(t *testing.T) {
+	b := resilience.NewBulkhead(1, 10*time.Millisecond)
+	release, err := b.Acquire(context.Background())
+	require.NoError(t, err)
+	_, err = b.Acquire(context.Background())
+	assert.ErrorIs(t, err, resilience.ErrBulkheadFull)
+	release()
+	assert.NoError(t, b.Execute(context.Background(), func() error { return nil }))
+	assert.Equal(t, int64(1), b.GetStats()["rejected"])
+}
+
+func TestTransport(t *testing.T) {
+	var calls, failing atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	retry := resilience.DefaultRetryConfig()
+	retry.InitialDelay = time.Millisecond
+	transport := resilience.NewTransport(nil, resilience.TransportConfig{
+		Name:          "test",
+		MaxFailures:   3,
+		ResetTimeout:  50 * time.Millisecond,
+		Retry:         retry,
+		MaxConcurrent: 2,
+	})
+	client := &http.Client{Transport: transport}
+
+	// Idempotent requests are retried until they pass
+	failing.Store(2)
+	resp, err := client.Get(upstream.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(3), calls.Load())
+
+	// Others are sent once
+	calls.Store(0)
+	failing.Store(1)
+	resp, err = client.Post(upstream.URL, "text/plain", strings.NewReader("x"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int64(1), calls.Load())
+
+	// With that failure, three in a row open the breaker of the host,
+	// ending the retries
+	calls.Store(0)
+	failing.Store(100)
+	_, err = client.Get(upstream.URL)
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	assert.Equal(t, resilience.StateOpen, transport.State(host))
+	_, err = client.Get(upstream.URL)
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.Equal(t, int64(2), calls.Load(), "the open breaker sends no request")
+
+	// A trial request after the reset timeout closes it again
+	failing.Store(0)
+	time.Sleep(60 * time.Millisecond)
+	resp, err = client.Get(upstream.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resilience.StateClosed, transport.State(host))
+
+	stats := transport.GetStats()
+	assert.Len(t, stats["breakers"], 1)
+	assert.Contains(t, stats, "bulkhead")
+}
@@ -0,0 +1,106 @@
+package scaffold_test
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"stackyrd/pkg/scaffold"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNames(t *testing.T) {
+	for _, name := range []string{"OrderItems", "order_items", "order-items", "order items", "OrderItemsService"} {
+		names, err := scaffold.NewNames(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, "OrderItemsService", names.Type, name)
+		assert.Equal(t, "OrderItem", names.Item, name)
+		assert.Equal(t, "order_items_service", names.Key, name)
+		assert.Equal(t, "order_items", names.Wire, name)
+		assert.Equal(t, "/order-items", names.Route, name)
+		assert.Equal(t, "order_items_service.go", names.File, name)
+	}
+
+	names, err := scaffold.NewNames("HTTPProbes")
+	require.NoError(t, err)
+	assert.Equal(t, "http_probes_service", names.Key)
+
+	for _, name := range []string{"", "service", "2fa", "orders!"} {
+		_, err := scaffold.NewNames(name)
+		assert.Error(t, err, name)
+	}
+}
+
+// newProject is a project root with a go.mod, an existing service and cfg
+// as its config file.
+func newProject(t *testing.T, file, cfg string) string {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module stackyrd\n"), 0o644))
+	modules := filepath.Join(root, scaffold.ServicesDir)
+	require.NoError(t, os.MkdirAll(modules, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(modules, "catalog.go"),
+		[]byte("package modules\n\nfunc init() {\n\tregistry.RegisterService(\"products_service\", nil)\n}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, file), []byte(cfg), 0o644))
+	return root
+}
+
+func TestGenerate(t *testing.T) {
+	const cfg = "app:\n  name: \"shop\"   # aligned comment\n\nservices:\n  users_service: true\n  # products\n  products_service: false\n\nmiddleware:\n  cors: true\n"
+	root := newProject(t, "config.yaml", cfg)
+
+	result, err := scaffold.Generate("invoices", scaffold.Options{Root: root})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(scaffold.ServicesDir, "invoices_service.go"),
+		filepath.Join(scaffold.TestsDir, "invoices_service_test.go"),
+	}, result.Files)
+	assert.Equal(t, "config.yaml", result.ConfigFile)
+
+	for _, file := range result.Files {
+		_, err := parser.ParseFile(token.NewFileSet(), filepath.Join(root, file), nil, parser.AllErrors)
+		assert.NoError(t, err, file)
+	}
+	source, err := os.ReadFile(filepath.Join(root, result.Files[0]))
+	require.NoError(t, err)
+	assert.Contains(t, string(source), `registry.RegisterService("invoices_service"`)
+	assert.Contains(t, string(source), `config.Services.IsEnabled("invoices_service")`)
+
+	// Only the toggle line is added to the config file
+	data, err := os.ReadFile(filepath.Join(root, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "app:\n  name: \"shop\"   # aligned comment\n\nservices:\n  users_service: true\n  # products\n  products_service: false\n  invoices_service: true\n\nmiddleware:\n  cors: true\n", string(data))
+
+	// Nothing is overwritten
+	_, err = scaffold.Generate("Invoices", scaffold.Options{Root: root})
+	assert.ErrorIs(t, err, scaffold.ErrExists)
+	_, err = scaffold.Generate("products", scaffold.Options{Root: root})
+	assert.ErrorIs(t, err, scaffold.ErrExists)
+	_, err = scaffold.Generate("users", scaffold.Options{Root: root, DryRun: true})
+	assert.ErrorIs(t, err, scaffold.ErrExists)
+
+	// Dry runs write nothing
+	result, err = scaffold.Generate("refunds", scaffold.Options{Root: root, DryRun: true, SkipTest: true})
+	require.NoError(t, err)
+	assert.Len(t, result.Files, 1)
+	assert.NoFileExists(t, filepath.Join(root, result.Files[0]))
+}
+
+func TestGenerate_JSONConfig(t *testing.T) {
+	root := newProject(t, "config.json", `{"app": {"name": "shop"}}`)
+
+	result, err := scaffold.Generate("invoices", scaffold.Options{Root: root, SkipTest: true})
+	require.NoError(t, err)
+	assert.Equal(t, "config.json", result.ConfigFile)
+
+	data, err := os.ReadFile(filepath.Join(root, "config.json"))
+	require.NoError(t, err)
+	var doc map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, true, doc["services"]["invoices_service"])
+	assert.Equal(t, "shop", doc["app"]["name"])
+}
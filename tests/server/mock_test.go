@@ -0,0 +1,65 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/server"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_MockRedirectsHTTPClients(t *testing.T) {
+	port, mockPort := freePort(t), freePort(t)
+	cfg := &config.Config{}
+	cfg.Server.Port = port
+	cfg.Services = config.ServicesConfig{}
+	cfg.Middleware = config.MiddlewareConfig{"jwt": false, "permission_check": false, "encryption": false}
+	cfg.HTTPClients = config.HTTPClientsConfig{
+		"payments": {BaseURL: "https://payments.example.invalid/v1/"},
+		"local":    {},
+	}
+	cfg.Mock = config.MockConfig{
+		Enabled:          true,
+		Port:             mockPort,
+		RedirectExternal: true,
+		DefaultStatus:    http.StatusNotFound,
+		Routes: []config.MockRouteConfig{
+			{Service: "payments", Method: "GET", Path: "/v1/charges", Body: `{"charges":[]}`},
+		},
+	}
+	l := logger.New(false, nil)
+
+	srv := server.New(cfg, l)
+	go srv.Start()
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/health")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	defer srv.Shutdown(context.Background(), l)
+
+	clients, ok := registry.GetTyped[*infrastructure.HTTPClientManager](srv.Dependencies(), "http_clients")
+	require.True(t, ok)
+	payments, ok := clients.Client("payments")
+	require.True(t, ok)
+	assert.Equal(t, "http://127.0.0.1:"+mockPort+"/payments/v1", payments.BaseURL)
+
+	var got map[string]interface{}
+	require.NoError(t, payments.DoJSON(context.Background(), http.MethodGet, "charges", nil, &got))
+	assert.Equal(t, map[string]interface{}{"charges": []interface{}{}}, got)
+
+	local, ok := clients.Client("local")
+	require.True(t, ok)
+	assert.Empty(t, local.BaseURL, "clients without a base URL are left alone")
+}
@@ -0,0 +1,145 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/server"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/shutdown"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowService answers GET /slow after a delay, standing in for a request
+// that is in flight when shutdown begins.
+type slowService struct {
+	started chan struct{}
+	delay   time.Duration
+}
+
+func (s *slowService) Name() string        { return "Slow Service" }
+func (s *slowService) WireName() string    { return "slow" }
+func (s *slowService) Enabled() bool       { return true }
+func (s *slowService) Endpoints() []string { return []string{"/slow"} }
+func (s *slowService) Get() interface{}    { return s }
+
+func (s *slowService) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/slow", func(c *gin.Context) {
+		close(s.started)
+		time.Sleep(s.delay)
+		c.String(http.StatusOK, "done")
+	})
+}
+
+var slow = &slowService{started: make(chan struct{}), delay: 300 * time.Millisecond}
+
+func init() {
+	registry.RegisterService("slow_drain_service", func(*config.Config, *logger.Logger, *registry.Dependencies) interfaces.Service {
+		return slow
+	})
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return fmt.Sprint(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestShutdown_DrainsInFlightRequests(t *testing.T) {
+	slow.started = make(chan struct{})
+	port := freePort(t)
+	cfg := &config.Config{}
+	cfg.Server.Port = port
+	cfg.Server.ShutdownTimeout = 10
+	cfg.Server.ShutdownReport = filepath.Join(t.TempDir(), "last-shutdown.json")
+	cfg.Services = config.ServicesConfig{"slow_drain_service": true}
+	cfg.Middleware = config.MiddlewareConfig{"jwt": false, "permission_check": false, "encryption": false}
+	l := logger.New(false, nil)
+
+	srv := server.New(cfg, l)
+	go srv.Start()
+
+	base := "http://127.0.0.1:" + port
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(base + "/health")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		done <- result{status: resp.StatusCode, body: string(b), err: err}
+	}()
+	select {
+	case <-slow.started:
+	case r := <-done:
+		t.Fatalf("slow request returned early: %+v", r)
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow request never started")
+	}
+	assert.EqualValues(t, 1, srv.InFlight())
+
+	// Only the slow request's connection stays open; the drain does not
+	// wait on the idle one of the health checks
+	http.DefaultClient.CloseIdleConnections()
+	srv.SetShutdownReason("signal: terminated")
+	require.NoError(t, srv.Shutdown(context.Background(), l))
+
+	// Shutdown returned only after the in-flight request completed
+	assert.EqualValues(t, 0, srv.InFlight())
+	select {
+	case r := <-done:
+		require.NoError(t, r.err)
+		assert.Equal(t, http.StatusOK, r.status)
+		assert.Equal(t, "done", r.body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight request did not complete")
+	}
+
+	// New connections are refused
+	_, err := http.Get(base + "/health")
+	assert.Error(t, err)
+
+	// The shutdown was reported for the next run
+	report, err := shutdown.Read(cfg.Server.ShutdownReport)
+	require.NoError(t, err)
+	assert.Equal(t, "signal: terminated", report.Reason)
+	assert.True(t, report.Complete)
+	assert.EqualValues(t, 1, report.Drain.InFlight)
+	assert.GreaterOrEqual(t, report.Drain.OpenConnections, int64(1))
+	assert.False(t, report.Drain.TimedOut)
+	assert.GreaterOrEqual(t, report.Drain.DurationMS, 100.0, "the drain waited for the slow request")
+	assert.Empty(t, report.Errors)
+	for _, c := range report.Components {
+		assert.Equal(t, shutdown.StatusOK, c.Status, c.Name)
+	}
+}
@@ -0,0 +1,225 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/server"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selfSignedCert writes a certificate for 127.0.0.1 and its key to dir.
+func selfSignedCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewTLSSetup_Validation(t *testing.T) {
+	setup, err := server.NewTLSSetup(config.ServerConfig{}, config.AuthConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, setup)
+
+	_, err = server.NewTLSSetup(config.ServerConfig{TLS: config.TLSConfig{Enabled: true}}, config.AuthConfig{})
+	assert.ErrorContains(t, err, "cert_file")
+
+	_, err = server.NewTLSSetup(config.ServerConfig{TLS: config.TLSConfig{Enabled: true, Autocert: config.AutocertConfig{Enabled: true}}}, config.AuthConfig{})
+	assert.ErrorContains(t, err, "domains")
+
+	certFile, keyFile := selfSignedCert(t, t.TempDir())
+	_, err = server.NewTLSSetup(config.ServerConfig{TLS: config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.1"}}, config.AuthConfig{})
+	assert.ErrorContains(t, err, "min_version")
+}
+
+func TestNewTLSSetup_AutocertHTTPHandler(t *testing.T) {
+	serve := func(redirect bool) *httptest.ResponseRecorder {
+		setup, err := server.NewTLSSetup(config.ServerConfig{Port: "8443", TLS: config.TLSConfig{
+			Enabled:      true,
+			RedirectHTTP: redirect,
+			Autocert:     config.AutocertConfig{Enabled: true, Domains: []string{"api.example.com"}},
+		}}, config.AuthConfig{})
+		require.NoError(t, err)
+		require.NotNil(t, setup.HTTPHandler, "HTTP-01 challenges need the plain listener")
+		w := httptest.NewRecorder()
+		setup.HTTPHandler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://api.example.com/health", nil))
+		return w
+	}
+
+	w := serve(false)
+	assert.Equal(t, http.StatusNotFound, w.Code, "no redirect unless redirect_http")
+	assert.Empty(t, w.Header().Get("Location"))
+
+	w = serve(true)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://api.example.com:8443/health", w.Header().Get("Location"))
+}
+
+func TestServer_HTTPSWithHTTP2AndRedirect(t *testing.T) {
+	certFile, keyFile := selfSignedCert(t, t.TempDir())
+	port, httpPort := freePort(t), freePort(t)
+
+	cfg := &config.Config{}
+	cfg.Server.Port = port
+	cfg.Server.TLS = config.TLSConfig{
+		Enabled:      true,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		RedirectHTTP: true,
+		HTTPPort:     httpPort,
+	}
+	cfg.Services = config.ServicesConfig{}
+	cfg.Middleware = config.MiddlewareConfig{"jwt": false, "permission_check": false, "encryption": false}
+	l := logger.New(false, nil)
+
+	srv := server.New(cfg, l)
+	go srv.Start()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		r, err := client.Get("https://127.0.0.1:" + port + "/health")
+		if err != nil {
+			return false
+		}
+		resp = r
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	require.Eventually(t, func() bool {
+		r, err := noFollow.Get("http://127.0.0.1:" + httpPort + "/health?x=1")
+		if err != nil {
+			return false
+		}
+		resp = r
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, "https://127.0.0.1:"+port+"/health?x=1", resp.Header.Get("Location"))
+
+	require.NoError(t, srv.Shutdown(t.Context(), l))
+}
+
+// clientCA returns a CA certificate file and a client certificate for cn
+// issued by it.
+func clientCA(t *testing.T, dir, cn string) (caFile string, client tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(10),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(11),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	caFile = filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+	return caFile, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := selfSignedCert(t, dir)
+	caFile, clientCert := clientCA(t, dir, "billing-worker")
+	port := freePort(t)
+
+	cfg := &config.Config{}
+	cfg.Server.Port = port
+	cfg.Server.TLS = config.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile}
+	cfg.Auth = config.AuthConfig{Type: "mtls", MTLS: config.MTLSConfig{CAFile: caFile}}
+	cfg.Services = config.ServicesConfig{}
+	cfg.Middleware = config.MiddlewareConfig{"jwt": false, "permission_check": false, "encryption": false}
+	l := logger.New(false, nil)
+
+	_, err := server.NewTLSSetup(cfg.Server, config.AuthConfig{Type: "mtls"})
+	assert.ErrorContains(t, err, "ca_file")
+	_, err = server.NewTLSSetup(config.ServerConfig{}, cfg.Auth)
+	assert.ErrorContains(t, err, "requires server.tls")
+
+	srv := server.New(cfg, l)
+	go srv.Start()
+	defer srv.Shutdown(t.Context(), l)
+
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{clientCert},
+	}}}
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		r, err := withCert.Get("https://127.0.0.1:" + port + "/health")
+		if err != nil {
+			return false
+		}
+		resp = r
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Without a client certificate the handshake is rejected
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	_, err = withoutCert.Get("https://127.0.0.1:" + port + "/health")
+	assert.Error(t, err)
+}
@@ -3,12 +3,18 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"stackyrd/config"
 	"stackyrd/internal/services/modules"
+	"stackyrd/pkg/infrastructure"
 	"stackyrd/pkg/logger"
+	"stackyrd/pkg/photos"
 	"stackyrd/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -228,3 +234,46 @@ func TestUsersService_DisabledService(t *testing.T) {
 	service := modules.NewUsersService(false, l)
 	assert.False(t, service.Enabled())
 }
+
+func TestUsersService_Photo(t *testing.T) {
+	l := logger.New(false, nil)
+	service := modules.NewUsersService(true, l)
+	router := setupTestRouter(service)
+
+	// Without a store the photo endpoints are unavailable
+	req, _ := http.NewRequest("GET", "/api/v1/users/1/photo", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	service.SetPhotoStore(photos.New(infrastructure.LocalObjectStorage{Dir: t.TempDir()}, config.PhotosConfig{
+		Prefix: "photos/", MaxSizeMB: 1, AllowedTypes: []string{"image/png"},
+	}))
+
+	var img bytes.Buffer
+	assert.NoError(t, png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))))
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("photo", "me.png")
+	part.Write(img.Bytes())
+	mw.Close()
+	req, _ = http.NewRequest("PUT", "/api/v1/users/1/photo", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/v1/users/1/photo", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, img.Bytes(), w.Body.Bytes())
+
+	// The type is detected from the data, not the declared content type
+	req, _ = http.NewRequest("PUT", "/api/v1/users/1/photo", bytes.NewBufferString("GIF89a not really"))
+	req.Header.Set("Content-Type", "image/png")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
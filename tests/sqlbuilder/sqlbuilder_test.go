@@ -0,0 +1,68 @@
+package sqlbuilder_test
+
+import (
+	"testing"
+
+	"stackyrd/pkg/response"
+	"stackyrd/pkg/sqlbuilder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, "a = $1 AND b IN ($2, $3)", sqlbuilder.Rebind("a = ? AND b IN (?, ?)"))
+	assert.Equal(t, `x = '?' AND "we?rd" = $1 -- why?`+"\nAND y = $2",
+		sqlbuilder.Rebind(`x = '?' AND "we?rd" = ? -- why?`+"\nAND y = ?"))
+	assert.Equal(t, "tags ?| $1", sqlbuilder.Rebind("tags ?| ?"))
+	assert.Equal(t, "x = 'it''s' AND y = $1", sqlbuilder.Rebind("x = 'it''s' AND y = ?"))
+	assert.Equal(t, "a = $1 /* b = ? */ AND c = $2", sqlbuilder.Rebind("a = ? /* b = ? */ AND c = ?"))
+	assert.Equal(t, "a = $1 /* outer /* inner ? */ still ? */ AND c = $2", sqlbuilder.Rebind("a = ? /* outer /* inner ? */ still ? */ AND c = ?"))
+	assert.Equal(t, "a = $1 /* open ?", sqlbuilder.Rebind("a = ? /* open ?"))
+	assert.Equal(t, "a / $1 * $2", sqlbuilder.Rebind("a / ? * ?"))
+}
+
+func TestSelect(t *testing.T) {
+	b := sqlbuilder.Select("id", "total").From(sqlbuilder.Ident("billing", "invoices")).
+		Where("tenant_id = ?", "acme").
+		Where("status = ? OR status = ?", "open", "late")
+
+	query, args := b.SQL()
+	assert.Equal(t, `SELECT id, total FROM "billing"."invoices" WHERE (tenant_id = $1) AND (status = $2 OR status = $3)`, query)
+	assert.Equal(t, []interface{}{"acme", "open", "late"}, args)
+
+	query, args = b.CountSQL()
+	assert.Equal(t, `SELECT count(*) FROM "billing"."invoices" WHERE (tenant_id = $1) AND (status = $2 OR status = $3)`, query)
+	assert.Len(t, args, 3)
+
+	query, _ = sqlbuilder.Select().From("orders").SQL()
+	assert.Equal(t, "SELECT * FROM orders", query)
+}
+
+func TestPage(t *testing.T) {
+	sort := sqlbuilder.Sort{
+		Columns:  map[string]string{"created": "created_at", "name": "lower(name)"},
+		Default:  "created",
+		Tiebreak: "id",
+	}
+
+	b, err := sqlbuilder.Select().From("users").Where("active = ?", true).
+		Page(response.PaginationRequest{Page: 3, PerPage: 20}, sort)
+	require.NoError(t, err)
+	query, args := b.SQL()
+	assert.Equal(t, "SELECT * FROM users WHERE active = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3", query)
+	assert.Equal(t, []interface{}{true, 20, 40}, args)
+
+	b, err = sqlbuilder.Select().From("users").
+		Page(response.PaginationRequest{Sort: "name", Order: "ASC", PerPage: 500}, sort)
+	require.NoError(t, err)
+	query, args = b.SQL()
+	assert.Equal(t, "SELECT * FROM users ORDER BY lower(name) ASC, id ASC LIMIT $1 OFFSET $2", query, "the first page has the text of the others")
+	assert.Equal(t, []interface{}{100, 0}, args, "per_page is capped")
+
+	_, err = sort.OrderBy("password; DROP TABLE users", "asc")
+	assert.ErrorIs(t, err, sqlbuilder.ErrInvalidSort)
+	assert.Contains(t, err.Error(), "created, name")
+	_, err = sort.OrderBy("name", "sideways")
+	assert.ErrorIs(t, err, sqlbuilder.ErrInvalidSort)
+}
@@ -0,0 +1,108 @@
+package sqlguard_test
+
+import (
+	"testing"
+
+	"stackyrd/pkg/sqlguard"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck_ReadOnly(t *testing.T) {
+	readOnly := sqlguard.Policy{ReadOnly: true}
+	allowed := []string{
+		"SELECT * FROM orders WHERE status = 'paid';",
+		"  select 1 ;;",
+		"WITH recent AS (SELECT * FROM orders) SELECT count(*) FROM recent",
+		"SELECT 'DELETE FROM users; DROP TABLE x' AS text",
+		"SELECT $$ insert into t values (1) $$, E'it\\'s; fine'",
+		"SELECT \"update\" FROM audit -- ; DROP TABLE audit",
+		"SELECT /* nested /* DELETE */ comment */ 1",
+		"SHOW statement_timeout",
+		"VALUES (1), (2)",
+		"TABLE orders",
+		"EXPLAIN DELETE FROM orders",
+		"EXPLAIN (ANALYZE, BUFFERS) SELECT * FROM orders",
+		"EXPLAIN (ANALYZE false) UPDATE orders SET x = 1",
+	}
+	for _, query := range allowed {
+		stmt, err := sqlguard.Check(query, readOnly)
+		if assert.NoError(t, err, query) {
+			assert.True(t, stmt.ReadOnly, query)
+		}
+	}
+
+	rejected := []string{
+		"",
+		"-- only a comment",
+		"DELETE FROM orders",
+		"SELECT 1; DELETE FROM orders",
+		"WITH gone AS (DELETE FROM orders RETURNING *) SELECT * FROM gone",
+		"SELECT * INTO backup FROM orders",
+		"SELECT * FROM orders FOR UPDATE",
+		"EXPLAIN ANALYZE DELETE FROM orders",
+		"EXPLAIN (ANALYZE) INSERT INTO t VALUES (1)",
+		"SELECT pg_terminate_backend(123)",
+		"SELECT nextval('orders_id_seq')",
+		"CREATE TABLE t (id int)",
+		"SET search_path = other",
+		"SELECT 'unterminated",
+		"SELECT 1 /* unterminated",
+	}
+	for _, query := range rejected {
+		_, err := sqlguard.Check(query, readOnly)
+		assert.ErrorIs(t, err, sqlguard.ErrRejected, query)
+	}
+}
+
+func TestCheck_QuotedFunctions(t *testing.T) {
+	readOnly := sqlguard.Policy{ReadOnly: true}
+	rejected := []string{
+		`SELECT "pg_terminate_backend"(pid) FROM pg_stat_activity`,
+		`SELECT "PG_CANCEL_BACKEND" (pid) FROM pg_stat_activity`,
+		`SELECT pg_catalog."pg_read_file"('/etc/passwd')`,
+		`SELECT "pg_catalog"."lo_export"(1, '/tmp/x')`,
+		`SELECT "public" . "dblink" /* call */ ('host=x', 'select 1')`,
+	}
+	for _, query := range rejected {
+		_, err := sqlguard.Check(query, readOnly)
+		assert.ErrorIs(t, err, sqlguard.ErrRejected, query)
+	}
+
+	// Columns and literals with those names are not calls
+	allowed := []string{
+		`SELECT "pg_terminate_backend" FROM audit`,
+		`SELECT 'pg_terminate_backend(1)'`,
+		`SELECT "say ""dblink""" FROM audit`,
+	}
+	for _, query := range allowed {
+		_, err := sqlguard.Check(query, readOnly)
+		assert.NoError(t, err, query)
+	}
+}
+
+func TestCheck_Statement(t *testing.T) {
+	stmt, err := sqlguard.Check("UPDATE orders SET paid = true WHERE id = 1;", sqlguard.Policy{})
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE", stmt.Command)
+	assert.Equal(t, "UPDATE orders SET paid = true WHERE id = 1", stmt.SQL)
+	assert.False(t, stmt.ReadOnly)
+	assert.False(t, stmt.ReturnsRows)
+
+	stmt, err = sqlguard.Check("INSERT INTO t VALUES (1) RETURNING id", sqlguard.Policy{})
+	require.NoError(t, err)
+	assert.True(t, stmt.ReturnsRows)
+
+	stmt, err = sqlguard.Check("EXPLAIN (ANALYZE, VERBOSE) SELECT * FROM orders;", sqlguard.Policy{})
+	require.NoError(t, err)
+	assert.True(t, stmt.Analyze)
+	assert.Equal(t, "SELECT * FROM orders", stmt.Target)
+
+	// Outside read-only mode writes and unsafe functions are the caller's call,
+	// but still one statement at a time
+	_, err = sqlguard.Check("SELECT pg_cancel_backend(1)", sqlguard.Policy{})
+	assert.NoError(t, err)
+	_, err = sqlguard.Check("UPDATE t SET x = 1; UPDATE t SET y = 2", sqlguard.Policy{})
+	assert.ErrorIs(t, err, sqlguard.ErrRejected)
+}
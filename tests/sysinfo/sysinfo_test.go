@@ -0,0 +1,120 @@
+package sysinfo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/sysinfo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource stands in for gopsutil; a nil error field means the metric
+// is readable.
+type fakeSource struct {
+	cpuErr, coresErr, memErr, netErr, loadErr error
+	disks                                     map[string]sysinfo.DiskStats
+	partitions                                []string
+}
+
+func (f fakeSource) CPUPercent(time.Duration) (float64, error) { return 42.5, f.cpuErr }
+func (f fakeSource) CPUCores() (int, error)                    { return 8, f.coresErr }
+func (f fakeSource) Memory() (sysinfo.MemoryStats, error) {
+	if f.memErr != nil {
+		return sysinfo.MemoryStats{TotalMB: 99}, f.memErr
+	}
+	return sysinfo.MemoryStats{TotalMB: 16384, UsedMB: 4096, UsedPercent: 25}, nil
+}
+func (f fakeSource) DiskUsage(path string) (sysinfo.DiskStats, error) {
+	if d, ok := f.disks[path]; ok {
+		return d, nil
+	}
+	return sysinfo.DiskStats{}, errors.New("no such volume: " + path)
+}
+func (f fakeSource) DiskPartitions() ([]string, error) { return f.partitions, nil }
+func (f fakeSource) Network() (sysinfo.NetworkStats, error) {
+	return sysinfo.NetworkStats{BytesSent: 10, BytesRecv: 20}, f.netErr
+}
+func (f fakeSource) Load() (sysinfo.LoadStats, error) {
+	return sysinfo.LoadStats{Load1: 1.5}, f.loadErr
+}
+
+func TestCollect_AllAvailable(t *testing.T) {
+	src := fakeSource{disks: map[string]sysinfo.DiskStats{"/data": {Path: "/data", UsedPercent: 70}}}
+	s := sysinfo.New(src, 0).WithDiskPath("/data").Collect()
+
+	assert.Equal(t, runtime.GOOS, s.OS)
+	assert.Equal(t, runtime.GOARCH, s.Arch)
+	assert.Equal(t, 42.5, s.CPU.Percent)
+	assert.Equal(t, 8, s.CPU.Cores)
+	assert.Equal(t, uint64(4096), s.Memory.UsedMB)
+	assert.Equal(t, "/data", s.Disk.Path)
+	assert.Equal(t, uint64(20), s.Network.BytesRecv)
+	for _, metric := range []string{sysinfo.MetricCPU, sysinfo.MetricMemory, sysinfo.MetricDisk, sysinfo.MetricNetwork} {
+		assert.True(t, s.Available[metric], metric)
+	}
+	if runtime.GOOS != "windows" {
+		assert.True(t, s.Available[sysinfo.MetricLoad])
+		assert.Equal(t, 1.5, s.Load.Load1)
+		assert.Empty(t, s.Errors)
+	}
+}
+
+func TestCollect_DegradesPerMetric(t *testing.T) {
+	src := fakeSource{
+		coresErr:   errors.New("topology hidden"),
+		memErr:     errors.New("not implemented"),
+		netErr:     errors.New("no counters"),
+		disks:      map[string]sysinfo.DiskStats{"/mnt/c": {Path: "/mnt/c", UsedPercent: 10}},
+		partitions: []string{"/boot", "/mnt/c"},
+	}
+	s := sysinfo.New(src, 0).WithDiskPath("/missing").Collect()
+
+	// Hidden CPU topology falls back to the Go runtime count
+	assert.True(t, s.Available[sysinfo.MetricCPU])
+	assert.Equal(t, runtime.NumCPU(), s.CPU.Cores)
+
+	assert.False(t, s.Available[sysinfo.MetricMemory])
+	assert.Equal(t, "not implemented", s.Errors[sysinfo.MetricMemory])
+	assert.Zero(t, s.Memory.TotalMB, "unavailable figures read as zero")
+
+	// The first readable partition stands in for a missing system volume
+	assert.True(t, s.Available[sysinfo.MetricDisk])
+	assert.Equal(t, "/mnt/c", s.Disk.Path)
+
+	assert.False(t, s.Available[sysinfo.MetricNetwork])
+	assert.Zero(t, s.Network.BytesSent)
+
+	// Every group keeps its key, whatever the platform provides
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	for _, key := range []string{"cpu", "memory", "disk", "network", "load", "available"} {
+		assert.Contains(t, decoded, key)
+	}
+	assert.Len(t, decoded["available"], len(sysinfo.Metrics))
+}
+
+func TestCollect_CPUUnavailable(t *testing.T) {
+	s := sysinfo.New(fakeSource{cpuErr: errors.New("not implemented"), loadErr: errors.New("no loadavg")}, 0).Collect()
+	assert.False(t, s.Available[sysinfo.MetricCPU])
+	assert.Zero(t, s.CPU.Cores)
+	assert.False(t, s.Available[sysinfo.MetricDisk], "neither the system volume nor a partition is readable")
+	assert.False(t, s.Available[sysinfo.MetricLoad])
+	assert.NotEmpty(t, s.Errors[sysinfo.MetricLoad])
+}
+
+func TestHost_ReportsCommonSchema(t *testing.T) {
+	s := sysinfo.New(sysinfo.Host(), 0).Collect()
+	assert.Len(t, s.Available, len(sysinfo.Metrics))
+	for _, metric := range sysinfo.Metrics {
+		if !s.Available[metric] {
+			assert.NotEmpty(t, s.Errors[metric], metric)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package tenancy_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/middleware"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantMetricsMiddleware_AttributesRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	collector := tenancy.NewCollector()
+	r := gin.New()
+	// Ahead of the tenant middleware, as the server orders them
+	r.Use(middleware.TenantMetrics(collector))
+	r.Use(middleware.Tenant(middleware.TenantOptions{
+		Registry: tenancy.NewRegistry(tenancy.Tenant{ID: "acme"}, tenancy.Tenant{ID: "globex"}),
+	}))
+
+	var seen string
+	r.GET("/tenants/:tenant/items", func(c *gin.Context) {
+		seen = tenancy.FromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+	r.GET("/items", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tenants/acme/items", nil))
+	assert.Equal(t, "acme", seen)
+
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(tenancy.HeaderTenantID, "globex")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Requests without a tenant, or naming one the tenant middleware
+	// rejects, are not tracked
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	req = httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(tenancy.HeaderTenantID, "made-up")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusInternalServerError, w.Code)
+
+	usage := collector.Snapshot()
+	require.Len(t, usage, 2)
+	assert.Equal(t, "acme", usage[0].Tenant)
+	assert.Equal(t, int64(1), usage[0].Requests)
+	assert.Equal(t, int64(0), usage[0].Errors)
+	assert.Equal(t, "globex", usage[1].Tenant)
+	assert.Equal(t, int64(1), usage[1].Errors)
+}
+
+func TestCollector_UsageAndOverflow(t *testing.T) {
+	collector := tenancy.NewCollector()
+	collector.RecordQuery("acme", 20*time.Millisecond)
+	collector.RecordQuery("acme", 10*time.Millisecond)
+	collector.RecordCache("acme", true, false)
+	collector.RecordCache("acme", false, true)
+	collector.RecordCache("acme", false, false)
+	collector.SetStorage("acme", 3, 4096)
+
+	usage := collector.Snapshot()
+	require.Len(t, usage, 1)
+	assert.Equal(t, int64(2), usage[0].DBQueries)
+	assert.InDelta(t, 30, usage[0].DBTimeMs, 0.001)
+	assert.Equal(t, int64(3), usage[0].CacheOps)
+	assert.Equal(t, int64(1), usage[0].CacheHits)
+	assert.Equal(t, int64(1), usage[0].CacheMisses)
+	assert.Equal(t, int64(4096), usage[0].StorageBytes)
+
+	for i := 0; i < tenancy.MaxTrackedTenants+5; i++ {
+		collector.RecordRequest(fmt.Sprintf("t%d", i), http.StatusOK, time.Millisecond)
+	}
+	tenants := collector.Tenants()
+	assert.Len(t, tenants, tenancy.MaxTrackedTenants+1)
+	assert.Contains(t, tenants, tenancy.OverflowTenant)
+
+	collector.Reset()
+	assert.Empty(t, collector.Snapshot())
+}
+
+func TestRegistry(t *testing.T) {
+	r := tenancy.NewRegistry()
+	require.NoError(t, r.Load([]config.TenantConfig{
+		{ID: "globex", Name: "Globex", Mongo: "shared"},
+		{ID: "acme", Postgres: "acme_pg"},
+	}))
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, []string{"acme", "globex"}, []string{r.List()[0].ID, r.List()[1].ID})
+
+	acme, ok := r.Get("acme")
+	require.True(t, ok)
+	assert.Equal(t, "acme_pg", acme.PostgresConnection())
+	assert.Equal(t, "acme", acme.MongoConnection(), "unmapped connections are named after the tenant")
+
+	owner, ok := r.PostgresOwner("acme_pg")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", owner)
+	owner, _ = r.MongoOwner("shared")
+	assert.Equal(t, "globex", owner)
+	_, ok = r.PostgresOwner("globex_pg")
+	assert.False(t, ok, "globex keeps its data on the connection named after it")
+	require.NoError(t, r.Register(tenancy.Tenant{ID: "hooli", Mongo: "shared"}))
+	_, ok = r.MongoOwner("shared")
+	assert.False(t, ok, "a shared connection has no owner")
+	r.Remove("hooli")
+
+	assert.Error(t, r.Load([]config.TenantConfig{{ID: "a"}, {ID: "a"}}), "duplicate ids")
+	assert.Error(t, r.Load([]config.TenantConfig{{ID: "a/b"}}))
+	assert.Equal(t, 2, r.Len(), "a failed load keeps the tenants")
+
+	assert.Error(t, r.Register(tenancy.Tenant{ID: ""}))
+	require.NoError(t, r.Register(tenancy.Tenant{ID: "initech"}))
+	assert.True(t, r.Remove("initech"))
+	assert.False(t, r.Remove("initech"))
+	_, ok = r.Get("initech")
+	assert.False(t, ok)
+
+	assert.True(t, tenancy.ValidID("tenant_a-1"))
+	assert.False(t, tenancy.ValidID("acme.example"))
+}
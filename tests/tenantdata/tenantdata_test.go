@@ -0,0 +1,175 @@
+package tenantdata_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/internal/jobs"
+	"stackyrd/internal/tenantdata"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySource holds rows per tenant; leak keeps one row after Delete to
+// simulate a deletion that cannot be verified.
+type memorySource struct {
+	mu   sync.Mutex
+	rows map[string][]string
+	leak bool
+}
+
+func (s *memorySource) Name() string { return "memory" }
+
+func (s *memorySource) Count(ctx context.Context, tenant string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int64{}
+	if n := len(s.rows[tenant]); n > 0 {
+		counts["memory/rows"] = int64(n)
+	}
+	return counts, nil
+}
+
+func (s *memorySource) Export(ctx context.Context, tenant string, archive *zip.Writer) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := archive.Create("memory/rows.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range s.rows[tenant] {
+		fmt.Fprintln(f, row)
+	}
+	return map[string]int64{"memory/rows": int64(len(s.rows[tenant]))}, nil
+}
+
+func (s *memorySource) Delete(ctx context.Context, tenant string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.rows[tenant])
+	if s.leak {
+		s.rows[tenant] = s.rows[tenant][:1]
+		n--
+	} else {
+		delete(s.rows, tenant)
+	}
+	return map[string]int64{"memory/rows": int64(n)}, nil
+}
+
+func newService(t *testing.T, src tenantdata.Source) (*tenantdata.Service, *jobs.Manager) {
+	t.Helper()
+	l := logger.NewQuiet(false, io.Discard)
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, l)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	manager, err := jobs.NewManager(store, 1, l)
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.Close() })
+
+	service, err := tenantdata.NewService(config.TenantDataConfig{PlanTTL: 60}, []tenantdata.Source{src}, nil, manager, store, l)
+	require.NoError(t, err)
+	return service, manager
+}
+
+func waitJob(t *testing.T, manager *jobs.Manager, id string) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		job, _ = manager.Get(id)
+		return job.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestTenantDeletion_RequiresConfirmedDryRun(t *testing.T) {
+	src := &memorySource{rows: map[string][]string{"acme": {"a", "b", "c"}, "other": {"x"}}}
+	service, manager := newService(t, src)
+
+	_, err := service.StartDeletion("acme", "anything", "tester")
+	assert.ErrorIs(t, err, tenantdata.ErrNoPlan)
+
+	dry := waitJob(t, manager, service.StartDryRun("acme", "tester").ID)
+	require.Equal(t, jobs.StatusSucceeded, dry.Status)
+	plan, found, err := service.Plan("acme")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, int64(3), plan.Total)
+
+	_, err = service.StartDeletion("acme", "wrong-token", "tester")
+	assert.ErrorIs(t, err, tenantdata.ErrTokenMismatch)
+
+	job, err := service.StartDeletion("acme", plan.Token, "tester")
+	require.NoError(t, err)
+	done := waitJob(t, manager, job.ID)
+	assert.Equal(t, jobs.StatusSucceeded, done.Status)
+	result := done.Result.(*tenantdata.DeletionResult)
+	assert.True(t, result.Verified)
+	assert.Equal(t, int64(3), result.Deleted["memory/rows"])
+	assert.Equal(t, []string{"x"}, src.rows["other"])
+
+	// The token is consumed by the deletion
+	_, err = service.StartDeletion("acme", plan.Token, "tester")
+	assert.ErrorIs(t, err, tenantdata.ErrNoPlan)
+
+	records, err := service.Audit("acme", 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, tenantdata.ActionDeletion, records[0].Action)
+	assert.True(t, records[0].Success)
+	assert.Equal(t, tenantdata.ActionDeletionDryRun, records[1].Action)
+	assert.Equal(t, "tester", records[1].Actor)
+}
+
+func TestTenantDeletion_FailsWhenDataRemains(t *testing.T) {
+	src := &memorySource{rows: map[string][]string{"acme": {"a", "b"}}, leak: true}
+	service, manager := newService(t, src)
+
+	waitJob(t, manager, service.StartDryRun("acme", "tester").ID)
+	plan, _, err := service.Plan("acme")
+	require.NoError(t, err)
+
+	job, err := service.StartDeletion("acme", plan.Token, "tester")
+	require.NoError(t, err)
+	done := waitJob(t, manager, job.ID)
+	assert.Equal(t, jobs.StatusFailed, done.Status)
+	assert.Contains(t, done.Error, "remains")
+
+	records, err := service.Audit("acme", 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.False(t, records[0].Success)
+	assert.Equal(t, false, records[0].Details["verified"])
+}
+
+func TestTenantExport_ArchiveAndStorageRequirement(t *testing.T) {
+	src := &memorySource{rows: map[string][]string{"acme": {`{"id":1}`, `{"id":2}`}}}
+	service, _ := newService(t, src)
+
+	_, err := service.StartExport("acme", "tester")
+	assert.ErrorIs(t, err, tenantdata.ErrNoStorage)
+
+	var buf bytes.Buffer
+	counts, err := tenantdata.WriteArchive(context.Background(), "acme", []tenantdata.Source{src}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), counts["memory/rows"])
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.ElementsMatch(t, []string{"memory/rows.jsonl", "manifest.json"}, names)
+}
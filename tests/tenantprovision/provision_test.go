@@ -0,0 +1,282 @@
+package tenantprovision_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"stackyrd/config"
+	"stackyrd/internal/tenantprovision"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/tenancy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePostgres struct {
+	conns   map[string]config.PostgresConnectionConfig
+	created []string
+	removed []string
+}
+
+func (f *fakePostgres) GetConnection(name string) (*infrastructure.PostgresManager, bool) {
+	_, ok := f.conns[name]
+	return &infrastructure.PostgresManager{}, ok
+}
+
+func (f *fakePostgres) AddConnection(cfg config.PostgresConnectionConfig) (*infrastructure.PostgresManager, error) {
+	f.conns[cfg.Name] = cfg
+	return &infrastructure.PostgresManager{}, nil
+}
+
+func (f *fakePostgres) RemoveConnection(name string) error {
+	delete(f.conns, name)
+	f.removed = append(f.removed, name)
+	return nil
+}
+
+func (f *fakePostgres) CreateDatabase(ctx context.Context, via, name string) (bool, error) {
+	f.created = append(f.created, via+":"+name)
+	return true, nil
+}
+
+type fakeMongo struct {
+	conns map[string]config.MongoConnectionConfig
+}
+
+func (f *fakeMongo) GetConnection(name string) (*infrastructure.MongoManager, bool) {
+	_, ok := f.conns[name]
+	return &infrastructure.MongoManager{}, ok
+}
+
+func (f *fakeMongo) AddConnection(cfg config.MongoConnectionConfig, l *logger.Logger) (*infrastructure.MongoManager, error) {
+	if cfg.URI == "mongodb://down" {
+		return nil, errors.New("server selection timeout")
+	}
+	f.conns[cfg.Name] = cfg
+	return &infrastructure.MongoManager{}, nil
+}
+
+func (f *fakeMongo) RemoveConnection(name string) error {
+	delete(f.conns, name)
+	return nil
+}
+
+const sampleConfig = `app:
+  name: "stackyrd"
+
+postgres:
+  enabled: true
+  connections:
+    - name: "primary"
+      enabled: true
+      host: "localhost"
+      port: 5432
+      password: "s3cret" # admin
+`
+
+type fixture struct {
+	cfg      *config.Config
+	pg       *fakePostgres
+	mongo    *fakeMongo
+	tenants  *tenancy.Registry
+	path     string
+	migrated int
+	migrate  error
+	p        *tenantprovision.Provisioner
+}
+
+func newFixture(t *testing.T) *fixture {
+	key, err := config.GenerateConfigKey()
+	require.NoError(t, err)
+	t.Setenv(config.ConfigKeyEnv, key)
+	t.Setenv(config.ConfigKeyFileEnv, "")
+
+	f := &fixture{
+		cfg:     &config.Config{},
+		pg:      &fakePostgres{conns: map[string]config.PostgresConnectionConfig{"primary": {}}},
+		mongo:   &fakeMongo{conns: map[string]config.MongoConnectionConfig{}},
+		tenants: tenancy.NewRegistry(),
+		path:    filepath.Join(t.TempDir(), "config.yaml"),
+	}
+	require.NoError(t, os.WriteFile(f.path, []byte(sampleConfig), 0o600))
+	f.cfg.Tenancy.Provisioning = config.TenantProvisioningConfig{
+		Enabled:          true,
+		PostgresTemplate: "postgres://app:pw@db.internal:6432/tenant_{tenant}?sslmode=require",
+		CreateDatabase:   true,
+		AdminConnection:  "primary",
+		MongoTemplate:    "mongodb://mongo.internal:27017",
+		MongoDatabase:    "{tenant}_docs",
+		Persist:          true,
+	}
+	f.p, err = tenantprovision.New(f.cfg, tenantprovision.Options{
+		Postgres: f.pg,
+		Mongo:    f.mongo,
+		Tenants:  f.tenants,
+		Migrate: func(ctx context.Context) error {
+			f.migrated++
+			return f.migrate
+		},
+		ConfigFile: config.NewSectionFile(f.path),
+	}, logger.New(false, nil))
+	require.NoError(t, err)
+	return f
+}
+
+func TestPostgresConnection(t *testing.T) {
+	conn, err := tenantprovision.PostgresConnection("postgres://app:p%40ss@db:6432/tenant_{tenant}", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, config.PostgresConnectionConfig{
+		Name: "acme", Enabled: true, Host: "db", Port: 6432, User: "app", Password: "p@ss", DBName: "tenant_acme", SSLMode: "disable",
+	}, conn)
+
+	conn, err = tenantprovision.PostgresConnection("postgresql://db/{tenant}?sslmode=verify-full", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, 5432, conn.Port)
+	assert.Equal(t, "verify-full", conn.SSLMode)
+
+	for _, template := range []string{"mysql://db/x", "postgres://db", "host=db dbname=x", "postgres://db:port/x"} {
+		_, err = tenantprovision.PostgresConnection(template, "acme")
+		assert.Error(t, err, template)
+	}
+}
+
+func TestProvision(t *testing.T) {
+	f := newFixture(t)
+	result, err := f.p.Provision(context.Background(), tenantprovision.Request{ID: "acme", Name: "Acme Corp"})
+	require.NoError(t, err)
+
+	assert.Equal(t, tenancy.Tenant{ID: "acme", Name: "Acme Corp", Postgres: "acme", Mongo: "acme"}, result.Tenant)
+	assert.Equal(t, "tenant_acme", result.PostgresDatabase)
+	assert.Equal(t, "acme_docs", result.MongoDatabase)
+	assert.True(t, result.DatabaseCreated)
+	assert.True(t, result.Persisted, result.PersistError)
+	assert.Equal(t, []string{"primary:tenant_acme"}, f.pg.created)
+	assert.Equal(t, 1, f.migrated)
+
+	assert.Equal(t, "db.internal", f.pg.conns["acme"].Host)
+	assert.Equal(t, "mongodb://mongo.internal:27017", f.mongo.conns["acme"].URI)
+	registered, ok := f.tenants.Get("acme")
+	require.True(t, ok)
+	assert.Equal(t, result.Tenant, registered)
+
+	// The running configuration keeps the tenant for reloads
+	require.Len(t, f.cfg.Tenancy.Tenants, 1)
+	assert.Equal(t, "acme", f.cfg.Tenancy.Tenants[0].Postgres)
+	require.Len(t, f.cfg.PostgresMultiConfig.Connections, 1)
+	require.Len(t, f.cfg.MongoMultiConfig.Connections, 1)
+
+	// Appended to the file, existing entries and secrets kept
+	file := config.NewSectionFile(f.path)
+	section, err := file.Get("postgres.connections")
+	require.NoError(t, err)
+	conns := section.Value.([]interface{})
+	require.Len(t, conns, 2)
+	assert.Equal(t, "primary", conns[0].(map[string]interface{})["name"])
+	assert.Equal(t, "tenant_acme", conns[1].(map[string]interface{})["dbname"])
+	raw, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `password: "s3cret" # admin`)
+	assert.Contains(t, string(raw), "sslmode: require")
+
+	// The resolved password is saved encrypted
+	assert.NotContains(t, string(raw), "password: pw")
+	assert.Contains(t, string(raw), "password: ENC[AES256_GCM,")
+	loaded, err := config.ReadConfigFile(f.path)
+	require.NoError(t, err)
+	assert.Equal(t, "pw", loaded.PostgresMultiConfig.Connections[1].Password)
+
+	section, err = file.Get("mongo.connections.0")
+	require.NoError(t, err)
+	assert.Equal(t, "acme_docs", section.Value.(map[string]interface{})["database"])
+	section, err = file.Get("tenancy.tenants.0")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "acme", "name": "Acme Corp", "postgres": "acme", "mongo": "acme"}, section.Value)
+
+	// The next tenant is appended after it
+	_, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "globex"})
+	require.NoError(t, err)
+	section, err = file.Get("tenancy.tenants")
+	require.NoError(t, err)
+	assert.Len(t, section.Value, 2)
+	require.NoError(t, config.ValidateFile(f.path), "the saved file is a valid config")
+}
+
+func TestProvision_Rejects(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.p.Provision(context.Background(), tenantprovision.Request{ID: "../etc"})
+	assert.ErrorIs(t, err, tenantprovision.ErrInvalidTenant)
+
+	_, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "primary"})
+	assert.ErrorIs(t, err, tenantprovision.ErrTenantExists, "a connection of that name exists")
+
+	_, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "acme"})
+	require.NoError(t, err)
+	_, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "acme"})
+	assert.ErrorIs(t, err, tenantprovision.ErrTenantExists)
+
+	_, err = tenantprovision.New(&config.Config{}, tenantprovision.Options{}, logger.New(false, nil))
+	assert.Error(t, err, "no template")
+	cfg := &config.Config{}
+	cfg.Tenancy.Provisioning.MongoTemplate = "mongodb://localhost"
+	_, err = tenantprovision.New(cfg, tenantprovision.Options{}, logger.New(false, nil))
+	assert.Error(t, err, "mongo template without mongo connections")
+}
+
+func TestProvision_RollsBack(t *testing.T) {
+	f := newFixture(t)
+	before, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+
+	f.migrate = errors.New("orders: relation exists")
+	_, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "acme"})
+	assert.ErrorIs(t, err, tenantprovision.ErrMigrationFailed)
+	assert.Equal(t, []string{"acme"}, f.pg.removed)
+	assert.NotContains(t, f.mongo.conns, "acme")
+
+	// A failing Mongo connection undoes the Postgres one
+	f.migrate = nil
+	f.cfg.Tenancy.Provisioning.MongoTemplate = "mongodb://down"
+	_, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "globex"})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"acme", "globex"}, f.pg.removed)
+
+	assert.Equal(t, 0, f.tenants.Len())
+	assert.Empty(t, f.cfg.Tenancy.Tenants)
+	after, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "nothing is saved")
+}
+
+func TestProvision_PersistNeedsTheConfigKey(t *testing.T) {
+	f := newFixture(t)
+	t.Setenv(config.ConfigKeyEnv, "")
+	before, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+
+	result, err := f.p.Provision(context.Background(), tenantprovision.Request{ID: "acme"})
+	require.NoError(t, err, "the tenant is live until the next restart")
+	assert.False(t, result.Persisted)
+	assert.Contains(t, result.PersistError, "master key")
+	after, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "no password is saved in plaintext")
+
+	// A Mongo URI with credentials is a secret as well
+	f = newFixture(t)
+	f.cfg.Tenancy.Provisioning.PostgresTemplate = ""
+	f.cfg.Tenancy.Provisioning.MongoTemplate = "mongodb://app:pw@mongo.internal:27017"
+	result, err = f.p.Provision(context.Background(), tenantprovision.Request{ID: "globex"})
+	require.NoError(t, err)
+	require.True(t, result.Persisted, result.PersistError)
+	raw, err := os.ReadFile(f.path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "app:pw")
+	loaded, err := config.ReadConfigFile(f.path)
+	require.NoError(t, err)
+	assert.Equal(t, "mongodb://app:pw@mongo.internal:27017", loaded.MongoMultiConfig.Connections[0].URI)
+}
@@ -0,0 +1,51 @@
+package testkit_test
+
+import (
+	"net/http"
+	"testing"
+
+	"stackyrd/internal/services/modules"
+	"stackyrd/pkg/testkit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKit_UsersServiceContract(t *testing.T) {
+	kit := testkit.New(t, testkit.WithServices("users_service"))
+
+	kit.GET("/users").Query("page", "1").Query("per_page", "10").Expect().
+		OK().
+		Message("Users retrieved successfully").
+		Pagination(1, 10, 2).
+		CorrelationID("")
+
+	var user modules.User
+	kit.GET("/users/1").Header("X-Request-ID", "req-42").Expect().
+		OK().
+		CorrelationID("req-42").
+		Data(&user)
+	assert.Equal(t, "Alice", user.Name)
+
+	kit.GET("/users/999").Expect().
+		Status(http.StatusNotFound).
+		ErrorCode("NOT_FOUND")
+
+	kit.POST("/users", map[string]interface{}{"name": "x"}).Expect().
+		Status(http.StatusUnprocessableEntity).
+		ErrorCode("VALIDATION_ERROR")
+}
+
+func TestKit_InMemoryInfrastructure(t *testing.T) {
+	kit := testkit.New(t, testkit.WithServices("products_service"), testkit.WithDependency("flag", true))
+
+	assert.NotNil(t, kit.Service("products"))
+	_, ok := kit.Deps.Get("store")
+	assert.True(t, ok)
+	_, ok = kit.Deps.Get("messaging")
+	assert.True(t, ok)
+	_, ok = kit.Deps.Get("flag")
+	assert.True(t, ok)
+
+	assert.NoError(t, kit.Store.PutJSON("items", "a", map[string]int{"n": 1}))
+	kit.GET("/products").Expect().OK()
+}
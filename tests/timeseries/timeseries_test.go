@@ -0,0 +1,108 @@
+package timeseries_test
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/timeseries"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+
+func TestHistory_Rollups(t *testing.T) {
+	h := timeseries.New(timeseries.Options{RawSize: 5})
+	// Two minutes of samples every 20 seconds, then one in the next hour
+	for i, v := range []float64{10, 20, 30, 40, 50, 60} {
+		h.Add(base.Add(time.Duration(i)*20*time.Second), map[string]float64{"cpu_percent": v})
+	}
+	h.Add(base.Add(time.Hour), map[string]float64{"cpu_percent": 100})
+
+	raw, ok := h.Query("cpu_percent", timeseries.Raw, time.Time{}, time.Time{})
+	require.True(t, ok)
+	assert.Len(t, raw, 5, "raw samples are bounded")
+	assert.Equal(t, 100.0, raw[4].Avg)
+
+	minutes, _ := h.Query("cpu_percent", timeseries.Minute, time.Time{}, time.Time{})
+	require.Len(t, minutes, 3, "two closed minutes and the open one")
+	assert.Equal(t, timeseries.Point{Time: base, Avg: 20, Min: 10, Max: 30, Count: 3}, minutes[0])
+	assert.Equal(t, timeseries.Point{Time: base.Add(time.Minute), Avg: 50, Min: 40, Max: 60, Count: 3}, minutes[1])
+	assert.Equal(t, base.Add(time.Hour), minutes[2].Time)
+
+	hours, _ := h.Query("cpu_percent", timeseries.Hour, time.Time{}, time.Time{})
+	require.Len(t, hours, 2)
+	assert.Equal(t, 35.0, hours[0].Avg)
+	assert.Equal(t, 6, hours[0].Count)
+
+	window, _ := h.Query("cpu_percent", timeseries.Minute, base.Add(time.Minute), base.Add(30*time.Minute))
+	assert.Len(t, window, 1)
+
+	_, ok = h.Query("missing", timeseries.Raw, time.Time{}, time.Time{})
+	assert.False(t, ok)
+	assert.Equal(t, []string{"cpu_percent"}, h.Metrics())
+
+	_, err := timeseries.ParseResolution("5m")
+	assert.Error(t, err)
+}
+
+func TestHistory_StoreBackend(t *testing.T) {
+	store, err := infrastructure.NewEmbeddedStore(config.StoreConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "test.db")}, nil)
+	require.NoError(t, err)
+	defer store.Close()
+	backend := timeseries.NewStoreBackend(store)
+
+	now := time.Now().Truncate(time.Minute)
+	h := timeseries.New(timeseries.Options{Backend: backend})
+	h.Add(now.Add(-3*time.Minute), map[string]float64{"memory_percent": 40, "infra.redis": 1})
+	h.Add(now.Add(-2*time.Minute), map[string]float64{"memory_percent": 60, "infra.redis": 0})
+	h.Add(now.Add(-time.Minute), map[string]float64{"memory_percent": 80})
+
+	// A restarted history gets the closed rollups back
+	restarted := timeseries.New(timeseries.Options{Backend: backend})
+	require.NoError(t, restarted.Restore())
+	minutes, ok := restarted.Query("memory_percent", timeseries.Minute, time.Time{}, time.Time{})
+	require.True(t, ok)
+	require.Len(t, minutes, 2)
+	assert.Equal(t, 40.0, minutes[0].Avg)
+	assert.True(t, minutes[0].Time.Equal(now.Add(-3*time.Minute)))
+	assert.Equal(t, 60.0, minutes[1].Avg)
+	redis, _ := restarted.Query("infra.redis", timeseries.Minute, time.Time{}, time.Time{})
+	assert.Len(t, redis, 1)
+
+	// Rollups past retention are pruned
+	require.NoError(t, backend.Prune(timeseries.Minute, now.Add(-150*time.Second)))
+	loaded, err := backend.Load(timeseries.Minute, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, loaded["memory_percent"], 1)
+	assert.Empty(t, loaded["infra.redis"])
+}
+
+func TestHistory_Sampling(t *testing.T) {
+	calls := 0
+	var shipped atomic.Int32
+	h := timeseries.New(timeseries.Options{
+		Interval: 10 * time.Millisecond,
+		Sample: func() map[string]float64 {
+			calls++
+			return map[string]float64{"goroutines": float64(calls)}
+		},
+		OnSample: func(t time.Time, values map[string]float64) {
+			if values["goroutines"] > 0 && !t.IsZero() {
+				shipped.Add(1)
+			}
+		},
+	})
+	h.Start()
+	require.Eventually(t, func() bool {
+		raw, _ := h.Query("goroutines", timeseries.Raw, time.Time{}, time.Time{})
+		return len(raw) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, h.Close())
+	assert.GreaterOrEqual(t, shipped.Load(), int32(3), "every sample reaches OnSample")
+}
@@ -0,0 +1,120 @@
+package timesync_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/timesync"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ntpEpochOffset = 2208988800
+
+func ntpTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	return secs<<32 | frac
+}
+
+// fakeNTP answers SNTP requests with a clock offset from the local one.
+func fakeNTP(t *testing.T, offset time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0] = 0x24 // version 4, mode 4 (server)
+			resp[1] = 2    // stratum
+			copy(resp[24:32], buf[40:48])
+			now := ntpTime(time.Now().Add(offset))
+			binary.BigEndian.PutUint64(resp[32:], now)
+			binary.BigEndian.PutUint64(resp[40:], now)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestQueryNTP(t *testing.T) {
+	// The server is 3s ahead, so the local clock is 3s behind
+	server := fakeNTP(t, 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	skew, err := timesync.QueryNTP(ctx, server)
+	require.NoError(t, err)
+	assert.InDelta(t, -3*time.Second, skew, float64(50*time.Millisecond))
+}
+
+func TestServerTimeSource(t *testing.T) {
+	source := timesync.ServerTimeSource{
+		SourceName: "db",
+		Now: func(context.Context) (time.Time, error) {
+			return time.Now().Add(-10 * time.Second), nil
+		},
+	}
+	skew, err := source.Skew(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, 10*time.Second, skew, float64(50*time.Millisecond))
+}
+
+func TestMonitor_FallsBackAndFlagsSkew(t *testing.T) {
+	failing := timesync.ServerTimeSource{
+		SourceName: "down",
+		Now: func(context.Context) (time.Time, error) {
+			return time.Time{}, errors.New("unreachable")
+		},
+	}
+	m := timesync.NewMonitor([]timesync.Source{
+		failing,
+		timesync.NTPSource{Server: fakeNTP(t, -5*time.Second)},
+	}, time.Hour, 2*time.Second, logger.NewQuiet(false, io.Discard))
+
+	_, ok := m.Last()
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	got := m.Check(ctx)
+	assert.True(t, got.OK())
+	assert.True(t, got.Exceeded)
+	assert.Contains(t, got.Source, "ntp:")
+	assert.InDelta(t, 5*time.Second, got.Skew(), float64(50*time.Millisecond))
+
+	status := m.Status()
+	assert.Equal(t, got, status["last"])
+	assert.Len(t, status["sources"], 2)
+}
+
+func TestMonitor_AllSourcesFail(t *testing.T) {
+	m := timesync.NewMonitor(nil, time.Hour, time.Second, nil)
+	m.Start()
+	require.Eventually(t, func() bool {
+		_, ok := m.Last()
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, m.Close())
+
+	last, _ := m.Last()
+	assert.False(t, last.OK())
+	assert.Contains(t, last.Error, "no clock source")
+}
@@ -0,0 +1,154 @@
+package topology_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stackyrd/config"
+	"stackyrd/pkg/infrastructure"
+	"stackyrd/pkg/interfaces"
+	"stackyrd/pkg/logger"
+	"stackyrd/pkg/registry"
+	"stackyrd/pkg/topology"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeComponent struct {
+	name      string
+	connected bool
+}
+
+func (f *fakeComponent) Name() string { return f.name }
+func (f *fakeComponent) GetStatus() map[string]interface{} {
+	return map[string]interface{}{"connected": f.connected}
+}
+
+type fakeService struct {
+	name, wire string
+	enabled    bool
+}
+
+func (s *fakeService) Name() string                    { return s.name }
+func (s *fakeService) WireName() string                { return s.wire }
+func (s *fakeService) Enabled() bool                   { return s.enabled }
+func (s *fakeService) Endpoints() []string             { return nil }
+func (s *fakeService) RegisterRoutes(*gin.RouterGroup) {}
+func (s *fakeService) Get() interface{}                { return s }
+
+func TestBuild(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+	checker := infrastructure.NewHTTPManager(config.ExternalConfig{
+		Services: []config.ExternalService{{Name: "payments", URL: upstream.URL}},
+	}, nil)
+	checker.Start()
+	defer checker.Close()
+	require.Eventually(t, func() bool { return checker.Statuses()[0].Last != nil }, 5*time.Second, 10*time.Millisecond)
+
+	postgres := &fakeComponent{name: "PostgreSQL", connected: true}
+	redis := &fakeComponent{name: "Redis", connected: false}
+	deps := registry.NewDependencies()
+	deps.Set("postgres", postgres)
+	deps.Set("postgres.default", postgres) // alias of the default connection
+	deps.Set("redis", redis)
+	deps.Set("cron", &fakeComponent{name: "Cron", connected: true})
+	deps.Set("external", checker)
+	deps.Set("settings", "not a component")
+
+	// Factories look their dependencies up like the real services do
+	registry.RegisterService("topology_orders", func(_ *config.Config, _ *logger.Logger, deps *registry.Dependencies) interfaces.Service {
+		registry.GetTyped[*fakeComponent](deps, "postgres.default")
+		registry.GetTyped[*fakeComponent](deps, "redis")
+		registry.GetTyped[*fakeComponent](deps, "missing")
+		return &fakeService{name: "Orders", wire: "orders", enabled: true}
+	})
+	registry.RegisterService("topology_reports", func(_ *config.Config, _ *logger.Logger, deps *registry.Dependencies) interfaces.Service {
+		registry.GetTyped[*fakeComponent](deps, "postgres")
+		return &fakeService{name: "Reports", wire: "reports", enabled: false}
+	})
+	cfg := &config.Config{Services: config.ServicesConfig{}}
+	for name := range registry.GetServiceFactories() {
+		cfg.Services[name] = name == "topology_orders" || name == "topology_reports"
+	}
+	services := registry.AutoDiscoverServices(cfg, logger.New(false, nil), deps)
+	require.Len(t, services, 2)
+	assert.Equal(t, []string{"missing", "postgres.default", "redis"}, registry.ServiceDependencies("Orders"))
+
+	g := topology.Build("stackyrd", deps, services)
+
+	ids := make([]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[i] = n.ID
+	}
+	assert.Equal(t, []string{
+		"app",
+		"service:orders", "service:reports",
+		"infrastructure:cron", "infrastructure:external", "infrastructure:postgres", "infrastructure:redis",
+		"external:payments",
+	}, ids)
+
+	app, _ := g.Node("app")
+	assert.Equal(t, topology.StatusDegraded, app.Status)
+	orders, _ := g.Node("service:orders")
+	assert.Equal(t, topology.StatusDegraded, orders.Status)
+	assert.Equal(t, topology.Colors[topology.StatusDegraded], orders.Color)
+	assert.Equal(t, "Redis is down", orders.Detail)
+	reports, _ := g.Node("service:reports")
+	assert.Equal(t, topology.StatusDisabled, reports.Status)
+	redisNode, _ := g.Node("infrastructure:redis")
+	assert.Equal(t, topology.StatusDown, redisNode.Status)
+	assert.Equal(t, "Redis", redisNode.Label)
+	external, _ := g.Node("infrastructure:external")
+	assert.Equal(t, topology.StatusDown, external.Status)
+	payments, _ := g.Node("external:payments")
+	assert.Equal(t, topology.StatusDown, payments.Status)
+	assert.Contains(t, payments.Detail, "status 503")
+
+	// Aliases resolve to the component's first name; unused components
+	// hang off the app
+	assert.Equal(t, []string{"service:orders", "service:reports", "infrastructure:cron", "infrastructure:external"}, g.DependsOn("app"))
+	assert.Equal(t, []string{"infrastructure:postgres", "infrastructure:redis"}, g.DependsOn("service:orders"))
+	assert.Equal(t, []string{"infrastructure:postgres"}, g.DependsOn("service:reports"))
+	assert.Equal(t, []string{"external:payments"}, g.DependsOn("infrastructure:external"))
+}
+
+func TestBuild_Healthy(t *testing.T) {
+	deps := registry.NewDependencies()
+	deps.Set("redis", &fakeComponent{name: "Redis", connected: true})
+	g := topology.Build("stackyrd", deps, nil)
+
+	app, _ := g.Node("app")
+	assert.Equal(t, topology.StatusOK, app.Status)
+	assert.Equal(t, []string{"infrastructure:redis"}, g.DependsOn("app"))
+}
+
+func TestBuild_Deferred(t *testing.T) {
+	deps := registry.NewDependencies()
+	deps.Set("postgres", &fakeComponent{name: "PostgreSQL", connected: true})
+	registry.RegisterService("topology_events", func(*config.Config, *logger.Logger, *registry.Dependencies) interfaces.Service {
+		return &fakeService{name: "Events", wire: "events", enabled: true}
+	}, "postgres", "kafka")
+	cfg := &config.Config{Services: config.ServicesConfig{}}
+	for name := range registry.GetServiceFactories() {
+		cfg.Services[name] = name == "topology_events"
+	}
+	services := registry.AutoDiscoverServices(cfg, logger.New(false, nil), deps)
+	require.Len(t, services, 1)
+
+	g := topology.Build("stackyrd", deps, services)
+	events, ok := g.Node("service:topology_events")
+	require.True(t, ok)
+	assert.Equal(t, topology.StatusDegraded, events.Status)
+	assert.Equal(t, "waiting for kafka", events.Detail)
+	kafka, ok := g.Node("infrastructure:kafka")
+	require.True(t, ok, "a missing need is drawn as down")
+	assert.Equal(t, topology.StatusDown, kafka.Status)
+	assert.Equal(t, []string{"infrastructure:kafka", "infrastructure:postgres"}, g.DependsOn("service:topology_events"))
+}
@@ -0,0 +1,529 @@
+package tui_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/tui"
+	"stackyrd/pkg/tui/template"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/teatest"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+)
+
+// Snapshots live in testdata/<test name>.golden; regenerate them after an
+// intended layout or theme change with:
+//
+//	go test ./tests/tui/ -update
+
+var fixedNow = time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	// Render with full colors regardless of the terminal running the tests,
+	// so theming is part of the snapshot
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	lipgloss.SetHasDarkBackground(true)
+	restore := tui.SetEnvironment(tui.Environment{
+		Now:        func() time.Time { return fixedNow },
+		MemSelfMiB: func() uint64 { return 42 },
+		Goroutines: func() int { return 17 },
+	})
+	code := m.Run()
+	restore()
+	os.Exit(code)
+}
+
+// drain runs cmd and returns the messages it produces, flattening batches.
+// Spinner ticks are dropped so spinners stay on their first frame.
+func drain(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	switch msg := cmd().(type) {
+	case nil, spinner.TickMsg, tea.QuitMsg:
+		return nil
+	case tea.BatchMsg:
+		results := make([][]tea.Msg, len(msg))
+		var wg sync.WaitGroup
+		for i, c := range msg {
+			wg.Add(1)
+			go func(i int, c tea.Cmd) {
+				defer wg.Done()
+				results[i] = drain(c)
+			}(i, c)
+		}
+		wg.Wait()
+		var out []tea.Msg
+		for _, r := range results {
+			out = append(out, r...)
+		}
+		return out
+	default:
+		return []tea.Msg{msg}
+	}
+}
+
+// harness is the model a test program runs. It holds the commands of the
+// model under test instead of returning them, so timers and spinners only
+// advance when the test steps the program.
+type harness struct {
+	model tea.Model
+	mu    sync.Mutex
+	held  []tea.Cmd
+}
+
+// synced is closed by the harness once the messages sent before it are
+// handled.
+type synced chan struct{}
+
+func (h *harness) Init() tea.Cmd {
+	h.hold(h.model.Init())
+	return nil
+}
+
+func (h *harness) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if done, ok := msg.(synced); ok {
+		close(done)
+		return h, nil
+	}
+	var cmd tea.Cmd
+	h.model, cmd = h.model.Update(msg)
+	h.hold(cmd)
+	return h, nil
+}
+
+func (h *harness) View() string { return h.model.View() }
+
+func (h *harness) hold(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	h.mu.Lock()
+	h.held = append(h.held, cmd)
+	h.mu.Unlock()
+}
+
+func (h *harness) take() []tea.Cmd {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	held := h.held
+	h.held = nil
+	return held
+}
+
+// program runs a model under teatest.
+type program struct {
+	t  *testing.T
+	tm *teatest.TestModel
+	h  *harness
+}
+
+func start(t *testing.T, m tea.Model, opts ...teatest.TestOption) *program {
+	t.Helper()
+	h := &harness{model: m}
+	return &program{t: t, tm: teatest.NewTestModel(t, h, opts...), h: h}
+}
+
+// send sends msgs to the model and waits until they are handled.
+func (p *program) send(msgs ...tea.Msg) {
+	p.t.Helper()
+	for _, msg := range msgs {
+		p.tm.Send(msg)
+	}
+	p.sync()
+}
+
+func (p *program) sync() {
+	p.t.Helper()
+	done := make(synced)
+	p.tm.Send(done)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		p.t.Fatal("the program did not handle the messages sent")
+	}
+}
+
+// step runs the commands the model returned so far and sends it the
+// messages they produce.
+func (p *program) step() {
+	p.t.Helper()
+	p.sync()
+	for _, cmd := range p.h.take() {
+		for _, msg := range drain(cmd) {
+			p.tm.Send(msg)
+		}
+	}
+	p.sync()
+}
+
+// final quits the program and returns the model under test.
+func (p *program) final() tea.Model {
+	p.t.Helper()
+	p.sync()
+	p.tm.Quit()
+	return p.tm.FinalModel(p.t, teatest.WithFinalTimeout(5*time.Second)).(*harness).model
+}
+
+// requireView compares the view of m with the snapshot of the test.
+func requireView(t *testing.T, m tea.Model) {
+	t.Helper()
+	teatest.RequireEqualOutput(t, []byte(m.View()))
+}
+
+func keys(s string) []tea.Msg {
+	msgs := make([]tea.Msg, 0, len(s))
+	for _, r := range s {
+		msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return msgs
+}
+
+func TestBootModel(t *testing.T) {
+	cfg := tui.StartupConfig{AppName: "stackyrd", AppVersion: "1.2.3", Port: "8080", Env: "test"}
+	queue := []tui.ServiceInit{
+		{Name: "Postgres", Enabled: true, InitFunc: func() error { return nil }, DetailFunc: func() string { return "2 connections" }},
+		{Name: "Redis", Enabled: true, InitFunc: func() error { return errors.New("dial tcp: connection refused") }},
+		{Name: "Kafka", Enabled: false},
+	}
+
+	// The model advances one boot tick per step: six intro ticks, then one
+	// service per tick
+	for _, tc := range []struct {
+		name  string
+		ticks int
+	}{
+		{"starting", 0},
+		{"initializing", 7},
+		{"complete", 9},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := start(t, tui.NewBootModel(cfg, queue), teatest.WithInitialTermSize(100, 30))
+			for i := 0; i < tc.ticks; i++ {
+				p.step()
+			}
+			requireView(t, p.final())
+		})
+	}
+}
+
+func TestBootModel_ConnectionEvents(t *testing.T) {
+	cfg := tui.StartupConfig{AppName: "stackyrd", AppVersion: "1.2.3", Port: "8080", Env: "test"}
+	queue := []tui.ServiceInit{
+		{Name: "Configuration", Enabled: true},
+		{Name: "PostgreSQL", Enabled: true, Component: "postgres"},
+		{Name: "Kafka Messaging", Enabled: true, Component: "kafka"},
+		{Name: "Redis Cache", Enabled: true, Component: "redis"},
+		{Name: "MongoDB", Enabled: false, Component: "mongo"},
+	}
+	events := []tui.BootEvent{
+		{Component: "kafka", Status: "loading", Message: "attempt 1 failed, retrying in 1s: dial tcp: connection refused"},
+		{Component: "postgres", Status: "success", Message: "connected in 200ms"},
+		{Component: "kafka", Status: "success", Message: "connected after 3 retries in 4.2s"},
+		{Component: "redis", Status: "error", Message: "failed after 3 attempts in 3.1s: dial tcp: connection refused"},
+	}
+
+	// Entries with a component wait for their events, so boot completes
+	// only once every connection succeeded or failed
+	for _, tc := range []struct {
+		name   string
+		events []tui.BootEvent
+	}{
+		{"connecting", events[:1]},
+		{"complete", events},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ch := make(chan tui.BootEvent, len(tc.events))
+			for _, e := range tc.events {
+				ch <- e
+			}
+			close(ch)
+
+			p := start(t, tui.NewBootModel(cfg, queue).WithEvents(ch), teatest.WithInitialTermSize(100, 30))
+			for i := 0; i < 14; i++ {
+				p.step()
+			}
+			requireView(t, p.final())
+		})
+	}
+}
+
+func dashboardFixture() tui.DashboardModel {
+	cfg := tui.DashboardConfig{
+		AppName:    "stackyrd",
+		AppVersion: "1.2.3",
+		Port:       "8080",
+		Env:        "test",
+		StartTime:  fixedNow.Add(-90 * time.Minute),
+	}
+	infra := []tui.InfraStatus{
+		{Name: "Postgres", Enabled: true, Connected: true},
+		{Name: "Redis", Enabled: true, Connected: false},
+		{Name: "Kafka", Enabled: false},
+	}
+	services := []tui.ServiceStatus{
+		{Name: "users_service", Status: "success", Message: "Ready"},
+		{Name: "products_service", Status: "success", Message: "Ready"},
+		{Name: "cache_service", Status: "error", Message: "redis unavailable"},
+		{Name: "grafana_service", Status: "skipped"},
+	}
+	return tui.NewDashboardModel(cfg, infra, services)
+}
+
+func TestDashboardModel(t *testing.T) {
+	for _, size := range []tea.WindowSizeMsg{{Width: 80, Height: 24}, {Width: 120, Height: 40}} {
+		t.Run(fmt.Sprintf("%dx%d", size.Width, size.Height), func(t *testing.T) {
+			p := start(t, dashboardFixture(), teatest.WithInitialTermSize(size.Width, size.Height))
+			requireView(t, p.final())
+		})
+	}
+
+	t.Run("filtered", func(t *testing.T) {
+		p := start(t, dashboardFixture(), teatest.WithInitialTermSize(100, 40))
+		p.send(append(keys("/redis"), tea.KeyMsg{Type: tea.KeyEnter})...)
+		requireView(t, p.final())
+	})
+}
+
+// liveFixture starts a live model with logs entries, then sized to size.
+func liveFixture(t *testing.T, logs int, size tea.WindowSizeMsg) *program {
+	t.Helper()
+	p := start(t, tui.NewLiveModel(tui.LiveConfig{AppName: "stackyrd", AppVersion: "1.2.3", Port: "8080", Env: "test"}))
+	levels := []string{"info", "debug", "warn", "error"}
+	for i := 0; i < logs; i++ {
+		p.send(tui.LogEntryMsg(tui.LogEntry{
+			Time:    fixedNow.Add(time.Duration(i) * time.Second),
+			Level:   levels[i%len(levels)],
+			Message: fmt.Sprintf("request %d handled by users_service in %dms", i, 3+i),
+		}))
+	}
+	p.send(size)
+	return p
+}
+
+func TestLiveModel(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		requireView(t, liveFixture(t, 0, tea.WindowSizeMsg{Width: 100, Height: 24}).final())
+	})
+
+	// More logs than fit: auto-scroll keeps the newest visible
+	t.Run("scrolled", func(t *testing.T) {
+		requireView(t, liveFixture(t, 30, tea.WindowSizeMsg{Width: 80, Height: 24}).final())
+	})
+
+	t.Run("wide", func(t *testing.T) {
+		requireView(t, liveFixture(t, 6, tea.WindowSizeMsg{Width: 140, Height: 30}).final())
+	})
+}
+
+func TestLiveModel_CommandPalette(t *testing.T) {
+	ran := make(chan string, 1)
+	newProgram := func(t *testing.T) *program {
+		return start(t, tui.NewLiveModel(tui.LiveConfig{
+			AppName: "stackyrd", AppVersion: "1.2.3", Port: "8080", Env: "test",
+			Actions: func() []tui.PaletteAction {
+				return []tui.PaletteAction{
+					{Category: "Cron", Title: "Run report now", Hint: "0 * * * *", Run: func() (string, error) {
+						ran <- "report"
+						return "Cron job report triggered", nil
+					}},
+					{Category: "Service", Title: "Disable users_service", Run: func() (string, error) {
+						return "", errors.New("reload failed")
+					}},
+				}
+			},
+		}), teatest.WithInitialTermSize(100, 30))
+	}
+	ctrlK := tea.KeyMsg{Type: tea.KeyCtrlK}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	t.Run("open", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(ctrlK)
+		requireView(t, p.final())
+	})
+
+	t.Run("fuzzy search", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append([]tea.Msg{ctrlK}, keys("rptnow")...)...)
+		requireView(t, p.final())
+	})
+
+	t.Run("run action", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append([]tea.Msg{ctrlK}, keys("run report")...)...)
+		p.send(enter)
+		p.step()
+		assert.Equal(t, "report", <-ran)
+		assert.Contains(t, p.final().View(), "Cron job report triggered")
+	})
+
+	t.Run("failed action is logged", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append([]tea.Msg{ctrlK}, keys("disable users")...)...)
+		p.send(enter)
+		p.step()
+		assert.Contains(t, p.final().View(), "Service: Disable users_service failed: reload failed")
+	})
+
+	t.Run("built-in command", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append([]tea.Msg{ctrlK}, keys("filter logs")...)...)
+		p.send(enter)
+		assert.Contains(t, p.final().View(), "Filter Logs")
+	})
+
+	t.Run("escape closes", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(ctrlK, tea.KeyMsg{Type: tea.KeyEsc})
+		assert.NotContains(t, p.final().View(), "Command Palette")
+	})
+}
+
+func TestFuzzyScore(t *testing.T) {
+	_, ok := template.FuzzyScore("rptnow", "Cron: Run report now")
+	assert.True(t, ok)
+	_, ok = template.FuzzyScore("xyz", "Cron: Run report now")
+	assert.False(t, ok)
+
+	// Word starts and consecutive characters rank higher
+	prefix, _ := template.FuzzyScore("log", "Logs: Clear logs")
+	scattered, _ := template.FuzzyScore("log", "App: Toggle debug")
+	assert.Greater(t, prefix, scattered)
+
+	score, ok := template.FuzzyScore("", "anything")
+	assert.True(t, ok)
+	assert.Zero(t, score)
+}
+
+func TestLiveModel_ContextView(t *testing.T) {
+	filter := func(text string) []tea.Msg {
+		msgs := append([]tea.Msg{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")}}, keys(text)...)
+		return append(msgs, tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	newProgram := func(t *testing.T) *program {
+		return liveFixture(t, 40, tea.WindowSizeMsg{Width: 100, Height: 30})
+	}
+
+	t.Run("filtered selection", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append(filter("error"), down, down)...)
+		requireView(t, p.final())
+	})
+
+	// Request 11 is the third error; its neighbours show whatever their level
+	t.Run("context", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append(filter("error"), down, down, enter)...)
+		m := p.final()
+		view := m.View()
+		assert.Contains(t, view, "Context ±5")
+		assert.Contains(t, view, "request 6 handled")
+		assert.Contains(t, view, "request 16 handled")
+		assert.NotContains(t, view, "request 5 handled")
+		assert.NotContains(t, view, "request 17 handled")
+		requireView(t, m)
+	})
+
+	// Twenty-one lines do not fit the eighteen rows, so they are centered
+	// on request 11
+	t.Run("widen context", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append(filter("error"), down, down, enter,
+			tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("+")})...)
+		view := p.final().View()
+		assert.Contains(t, view, "Context ±10")
+		assert.Contains(t, view, "request 2 handled")
+		assert.Contains(t, view, "request 19 handled")
+		assert.NotContains(t, view, "request 20 handled")
+	})
+
+	t.Run("back to filter", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(append(filter("error"), down, down, enter, tea.KeyMsg{Type: tea.KeyEsc})...)
+		view := p.final().View()
+		assert.NotContains(t, view, "Context ±")
+		assert.NotContains(t, view, "request 6 handled")
+		assert.Contains(t, view, "▸ ")
+	})
+
+	t.Run("configured context", func(t *testing.T) {
+		p := start(t, tui.NewLiveModel(tui.LiveConfig{AppName: "stackyrd", ContextLines: 2}))
+		for i := 0; i < 10; i++ {
+			p.send(tui.LogEntryMsg(tui.LogEntry{Time: fixedNow, Level: "info", Message: fmt.Sprintf("line %d", i)}))
+		}
+		p.send(append(filter("line 5"), enter)...)
+		view := p.final().View()
+		assert.Contains(t, view, "line 3")
+		assert.Contains(t, view, "line 7")
+		assert.NotContains(t, view, "line 2")
+		assert.NotContains(t, view, "line 8")
+	})
+}
+
+func TestLiveModel_Topology(t *testing.T) {
+	nodes := []tui.TopologyNode{
+		{ID: "app", Label: "stackyrd", Kind: "app", Status: "degraded", Uses: []string{"service:orders", "service:users", "infrastructure:external"}},
+		{ID: "service:orders", Label: "Orders", Kind: "service", Status: "degraded", Detail: "Redis is down", Uses: []string{"infrastructure:postgres", "infrastructure:redis"}},
+		{ID: "service:users", Label: "Users", Kind: "service", Status: "ok", Uses: []string{"infrastructure:postgres"}},
+		{ID: "infrastructure:postgres", Label: "PostgreSQL", Kind: "infrastructure", Status: "ok"},
+		{ID: "infrastructure:redis", Label: "Redis", Kind: "infrastructure", Status: "down"},
+		{ID: "infrastructure:external", Label: "External Services", Kind: "infrastructure", Status: "ok", Uses: []string{"external:payments"}},
+		{ID: "external:payments", Label: "payments", Kind: "external", Status: "ok"},
+	}
+	var calls atomic.Int32
+	newProgram := func(t *testing.T) *program {
+		return start(t, tui.NewLiveModel(tui.LiveConfig{
+			AppName: "stackyrd", AppVersion: "1.2.3", Port: "8080", Env: "test",
+			Topology: func() []tui.TopologyNode {
+				calls.Add(1)
+				return nodes
+			},
+		}), teatest.WithInitialTermSize(100, 30))
+	}
+	topologyKey := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")}
+
+	t.Run("view", func(t *testing.T) {
+		p := newProgram(t)
+		p.send(topologyKey)
+		p.step()
+		m := p.final()
+		view := m.View()
+		assert.Contains(t, view, "▪ Topology")
+		assert.Contains(t, view, "│  ├─ ")
+		requireView(t, m)
+	})
+
+	t.Run("refresh and close", func(t *testing.T) {
+		calls.Store(0)
+		p := newProgram(t)
+		p.send(topologyKey)
+		p.step()
+		p.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+		p.step()
+		assert.Equal(t, int32(2), calls.Load())
+		p.send(tea.KeyMsg{Type: tea.KeyEsc})
+		assert.NotContains(t, p.final().View(), "▪ Topology")
+	})
+
+	t.Run("render", func(t *testing.T) {
+		lines := tui.RenderTopology(nodes)
+		// PostgreSQL is used twice but expanded once
+		assert.Len(t, lines, 8)
+		assert.Contains(t, lines[0], "stackyrd")
+		assert.Contains(t, lines[3], "Redis")
+		assert.Contains(t, lines[3], "down")
+		assert.Contains(t, lines[5], "↑")
+	})
+}
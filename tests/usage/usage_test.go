@@ -0,0 +1,66 @@
+package usage_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/usage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_PerPrincipal(t *testing.T) {
+	c := usage.NewCollector()
+	start := time.Now()
+	record := func(typ, who, route string, status int, at int) {
+		c.Record(usage.Call{Type: typ, Principal: who, Method: "GET", Route: route, Status: status,
+			Latency: 10 * time.Millisecond, Time: start.Add(time.Duration(at) * time.Second)})
+	}
+	for i := 0; i < 6; i++ {
+		record(usage.TypeAPIKey, "partner", "/orders", 200, i)
+	}
+	record(usage.TypeAPIKey, "partner", "/orders/:id", 404, 10)
+	record(usage.TypeAPIKey, "partner", "/orders/:id", 500, 11)
+	record(usage.TypeUser, "alice", "/products", 200, 3)
+	c.Record(usage.Call{Type: usage.TypeUser, Route: "/ignored"})
+
+	list := c.Snapshot(1)
+	require.Len(t, list, 2)
+	partner := list[0]
+	assert.Equal(t, "partner", partner.Principal)
+	assert.Equal(t, int64(8), partner.Calls)
+	assert.Equal(t, int64(2), partner.Errors)
+	assert.Equal(t, int64(1), partner.ServerErrors)
+	assert.InDelta(t, 0.25, partner.ErrorRate, 0.0001)
+	assert.InDelta(t, 10, partner.AvgLatencyMs, 0.0001)
+	assert.Equal(t, start, partner.FirstSeen)
+	assert.Equal(t, start.Add(11*time.Second), partner.LastSeen)
+	require.Len(t, partner.TopEndpoints, 1, "top limits the endpoints")
+	assert.Equal(t, "/orders", partner.TopEndpoints[0].Route)
+	assert.Equal(t, int64(6), partner.TopEndpoints[0].Calls)
+
+	full, ok := c.Get(usage.TypeAPIKey, "partner")
+	require.True(t, ok)
+	require.Len(t, full.TopEndpoints, 2)
+	assert.Equal(t, int64(2), full.TopEndpoints[1].Errors)
+
+	_, ok = c.Get(usage.TypeUser, "partner")
+	assert.False(t, ok, "principals are distinct per type")
+
+	c.Reset()
+	assert.Empty(t, c.Snapshot(5))
+}
+
+func TestCollector_Bounds(t *testing.T) {
+	c := usage.NewCollector()
+	for i := 0; i < usage.MaxEndpointsPerPrincipal+10; i++ {
+		c.Record(usage.Call{Type: usage.TypeUser, Principal: "crawler", Method: "GET", Route: fmt.Sprintf("/r%d", i), Status: 200})
+	}
+	u, ok := c.Get(usage.TypeUser, "crawler")
+	require.True(t, ok)
+	assert.Len(t, u.TopEndpoints, usage.MaxEndpointsPerPrincipal+1)
+	assert.Equal(t, usage.OverflowEndpoint, u.TopEndpoints[0].Route)
+	assert.Equal(t, int64(10), u.TopEndpoints[0].Calls)
+}
@@ -0,0 +1,78 @@
+package utils_test
+
+import (
+	"fmt"
+	"testing"
+
+	"stackyrd/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func messages(events []utils.EventData) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.Message)
+	}
+	return out
+}
+
+func TestEventBroadcaster_ReplayAfterLastEventID(t *testing.T) {
+	eb := utils.NewEventBroadcasterWithReplay(3)
+	first := eb.Subscribe("orders")
+	eb.Broadcast("orders", "update", "one", nil)
+	eb.Broadcast("other", "update", "elsewhere", nil)
+	eb.Broadcast("orders", "update", "two", nil)
+	last := <-first.Channel
+	eb.Unsubscribe(first.ID)
+
+	// Published while the client was away
+	eb.Broadcast("orders", "update", "three", nil)
+	eb.BroadcastToAll("notice", "four", nil)
+
+	client, replay, missed := eb.SubscribeFrom("orders", last.ID)
+	defer eb.Unsubscribe(client.ID)
+	assert.False(t, missed)
+	assert.Equal(t, []string{"two", "three", "four"}, messages(replay))
+	assert.Equal(t, "orders", replay[2].StreamID, "broadcasts to all are kept per stream")
+
+	// New events arrive on the channel, not twice
+	eb.Broadcast("orders", "update", "five", nil)
+	assert.Equal(t, "five", (<-client.Channel).Message)
+	assert.Empty(t, client.Channel)
+}
+
+func TestEventBroadcaster_ReplayMissed(t *testing.T) {
+	eb := utils.NewEventBroadcasterWithReplay(2)
+	client := eb.Subscribe("metrics")
+	eb.Broadcast("metrics", "tick", "one", nil)
+	first := <-client.Channel
+	eb.Unsubscribe(client.ID)
+	for i := 2; i <= 4; i++ {
+		eb.Broadcast("metrics", "tick", fmt.Sprint(i), nil)
+	}
+
+	client, replay, missed := eb.SubscribeFrom("metrics", first.ID)
+	eb.Unsubscribe(client.ID)
+	assert.True(t, missed, "event 2 left the buffer")
+	assert.Equal(t, []string{"3", "4"}, messages(replay))
+
+	client, replay, missed = eb.SubscribeFrom("metrics", "evt_999")
+	eb.Unsubscribe(client.ID)
+	assert.True(t, missed, "an ID from before a restart")
+	assert.Empty(t, replay)
+
+	client, replay, missed = eb.SubscribeFrom("metrics", "")
+	eb.Unsubscribe(client.ID)
+	assert.False(t, missed)
+	assert.Empty(t, replay)
+}
+
+func TestEventSeq(t *testing.T) {
+	seq, ok := utils.EventSeq("evt_42")
+	require.True(t, ok)
+	assert.Equal(t, uint64(42), seq)
+	_, ok = utils.EventSeq("connected")
+	assert.False(t, ok)
+}
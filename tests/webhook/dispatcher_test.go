@@ -0,0 +1,191 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook endpoint answering with the queued status codes,
+// then 200.
+type receiver struct {
+	mu       sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	code := http.StatusOK
+	if len(r.codes) > 0 {
+		code, r.codes = r.codes[0], r.codes[1:]
+	}
+	r.mu.Unlock()
+	w.WriteHeader(code)
+	w.Write([]byte(http.StatusText(code)))
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newDispatcher(t *testing.T, dests []webhook.Destination) *webhook.Dispatcher {
+	d, err := webhook.New(dests, webhook.Options{
+		Workers:     2,
+		MaxAttempts: 3,
+		Backoff:     10 * time.Millisecond,
+		MaxBackoff:  50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	d.Start()
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func waitStatus(t *testing.T, d *webhook.Dispatcher, status string, n int) []webhook.Delivery {
+	var list []webhook.Delivery
+	require.Eventually(t, func() bool {
+		list = d.Deliveries(webhook.DeliveryFilter{Status: status})
+		return len(list) == n
+	}, 2*time.Second, 5*time.Millisecond, "waiting for %d %s deliveries", n, status)
+	return list
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	d := newDispatcher(t, []webhook.Destination{
+		{Name: "billing", URL: srv.URL, Secret: "s3cret", Events: []string{"orders.*"}, Headers: map[string]string{"X-Source": "test"}},
+		{Name: "audit", URL: srv.URL, Events: []string{"users.deleted"}},
+	})
+
+	id, err := d.Publish("orders.paid", map[string]interface{}{"order": 42})
+	require.NoError(t, err)
+	delivered := waitStatus(t, d, webhook.StatusDelivered, 1)
+	assert.Equal(t, "billing", delivered[0].Destination, "only destinations taking the event")
+	assert.Equal(t, id, delivered[0].EventID)
+	assert.Equal(t, 1, delivered[0].Attempts)
+	assert.Equal(t, http.StatusOK, delivered[0].StatusCode)
+
+	require.Equal(t, 1, rec.count())
+	req, body := rec.requests[0], rec.bodies[0]
+	assert.True(t, webhook.VerifySignature(body, req.Header.Get(webhook.HeaderSignature), "s3cret"))
+	assert.Equal(t, "orders.paid", req.Header.Get(webhook.HeaderEvent))
+	assert.Equal(t, id, req.Header.Get(webhook.HeaderEventID))
+	assert.Equal(t, "test", req.Header.Get("X-Source"))
+
+	var event struct {
+		ID   string         `json:"id"`
+		Type string         `json:"type"`
+		Data map[string]int `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, id, event.ID)
+	assert.Equal(t, 42, event.Data["order"])
+
+	// No destination takes the event
+	_, err = d.Publish("products.created", nil)
+	require.NoError(t, err)
+	assert.Len(t, d.Deliveries(webhook.DeliveryFilter{}), 1)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	rec := &receiver{codes: []int{http.StatusInternalServerError, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	d := newDispatcher(t, []webhook.Destination{{Name: "flaky", URL: srv.URL}})
+
+	_, err := d.Publish("orders.paid", nil)
+	require.NoError(t, err)
+	delivered := waitStatus(t, d, webhook.StatusDelivered, 1)
+	assert.Equal(t, 3, delivered[0].Attempts)
+	assert.Equal(t, 3, rec.count())
+	assert.Equal(t, "3", rec.requests[2].Header.Get(webhook.HeaderAttempt))
+
+	stats := d.Destinations()[0]
+	assert.Equal(t, int64(1), stats.Delivered)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.NotNil(t, stats.LastSuccess)
+}
+
+func TestDispatcher_FailuresAndRedeliver(t *testing.T) {
+	rec := &receiver{codes: []int{http.StatusBadRequest, 500, 500, 500}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	d := newDispatcher(t, []webhook.Destination{{Name: "strict", URL: srv.URL}})
+
+	_, err := d.Publish("orders.paid", nil)
+	require.NoError(t, err)
+	failed := waitStatus(t, d, webhook.StatusFailed, 1)
+	assert.Equal(t, 1, failed[0].Attempts, "client errors are not retried")
+	assert.Equal(t, "webhook returned status 400", failed[0].Error)
+	assert.Equal(t, "Bad Request", failed[0].Response)
+
+	_, err = d.Redeliver(failed[0].ID)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		got, err := d.Delivery(failed[0].ID)
+		return err == nil && got.Status == webhook.StatusFailed && got.Attempts == 3
+	}, 2*time.Second, 5*time.Millisecond, "server errors are retried up to max_attempts")
+
+	stats := d.Destinations()[0]
+	assert.Equal(t, int64(2), stats.Failed)
+	assert.Equal(t, "webhook returned status 500", stats.LastError)
+
+	_, err = d.Redeliver("dlv_missing")
+	assert.ErrorIs(t, err, webhook.ErrDeliveryNotFound)
+
+	_, err = d.PublishTo("strict", "webhook.test", nil)
+	require.NoError(t, err)
+	delivered := waitStatus(t, d, webhook.StatusDelivered, 1)
+	_, err = d.Redeliver(delivered[0].ID)
+	assert.ErrorIs(t, err, webhook.ErrNotFailed)
+	_, err = d.PublishTo("missing", "webhook.test", nil)
+	assert.ErrorIs(t, err, webhook.ErrDestinationNotFound)
+}
+
+func TestDispatcher_LogSize(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+	d, err := webhook.New([]webhook.Destination{{Name: "a", URL: srv.URL}}, webhook.Options{Workers: 1, LogSize: 3})
+	require.NoError(t, err)
+	d.Start()
+	defer d.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := d.Publish("tick", i)
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool { return hits.Load() == 5 }, 2*time.Second, 5*time.Millisecond)
+	_, err = d.Publish("tick", 5)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(d.Deliveries(webhook.DeliveryFilter{})), 3)
+	assert.Equal(t, int64(5), d.Destinations()[0].Delivered, "counters outlive the log")
+}
+
+func TestNew_InvalidDestinations(t *testing.T) {
+	_, err := webhook.New([]webhook.Destination{{Name: "a"}}, webhook.Options{})
+	assert.Error(t, err, "url is required")
+	_, err = webhook.New([]webhook.Destination{{Name: "a", URL: "http://x"}, {Name: "a", URL: "http://y"}}, webhook.Options{})
+	assert.Error(t, err, "duplicate name")
+	_, err = webhook.New([]webhook.Destination{{Name: "a", URL: "http://x", Events: []string{"orders.["}}}, webhook.Options{})
+	assert.Error(t, err, "bad pattern")
+}
@@ -0,0 +1,140 @@
+package websocket_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stackyrd/pkg/websocket"
+
+	"github.com/gin-gonic/gin"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHubServer(t *testing.T, name string, opts websocket.Options) (*websocket.Hub, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	hub := websocket.NewHub(name, opts, nil)
+	t.Cleanup(hub.Close)
+
+	r := gin.New()
+	r.GET("/ws", hub.Handler())
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return hub, "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+func dial(t *testing.T, url string) *gorilla.Conn {
+	t.Helper()
+	conn, _, err := gorilla.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *gorilla.Conn) websocket.Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg websocket.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	require.Eventually(t, cond, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHub_RoomsAndBroadcast(t *testing.T) {
+	hub, url := newHubServer(t, "test_rooms", websocket.Options{})
+
+	alice := dial(t, url+"?client_id=alice&room=news")
+	bob := dial(t, url+"?client_id=bob")
+	waitFor(t, func() bool { return hub.Stats().Clients == 2 })
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: "join", Room: "sports"}))
+	assert.Equal(t, "joined", read(t, bob).Type)
+
+	stats := hub.Stats()
+	assert.Equal(t, map[string]int{"news": 1, "sports": 1}, stats.Rooms)
+
+	require.NoError(t, hub.BroadcastMessage("headline", "news", "hello"))
+	msg := read(t, alice)
+	assert.Equal(t, "headline", msg.Type)
+	assert.Equal(t, "news", msg.Room)
+	assert.Equal(t, "hello", msg.Payload)
+
+	// Bob is not in news, so the next thing he sees is the broadcast to all
+	require.NoError(t, hub.BroadcastMessage("notice", "", nil))
+	assert.Equal(t, "notice", read(t, bob).Type)
+	assert.Equal(t, "notice", read(t, alice).Type)
+
+	assert.True(t, hub.SendToClient("bob", []byte(`{"type":"direct"}`)))
+	assert.Equal(t, "direct", read(t, bob).Type)
+	assert.False(t, hub.SendToClient("carol", []byte(`{"type":"direct"}`)))
+}
+
+func TestHub_PingAndDisconnect(t *testing.T) {
+	received := make(chan string, 1)
+	hub, url := newHubServer(t, "test_ping", websocket.Options{
+		PingInterval: 50 * time.Millisecond,
+		OnMessage: func(c *websocket.Client, msg websocket.Message) {
+			received <- c.ID + ":" + msg.Type
+			c.SendMessage(websocket.Message{Type: "ack"})
+		},
+	})
+
+	conn := dial(t, url+"?client_id=c1&room=a")
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(gorilla.PongMessage, nil, time.Now().Add(time.Second))
+	})
+
+	require.NoError(t, conn.WriteJSON(websocket.Message{Type: "ping"}))
+	assert.Equal(t, "pong", read(t, conn).Type)
+	require.NoError(t, conn.WriteJSON(websocket.Message{Type: "custom"}))
+	assert.Equal(t, "ack", read(t, conn).Type)
+	assert.Equal(t, "c1:custom", <-received)
+
+	// Control frames are handled while reading
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-pinged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not ping")
+	}
+
+	conn.Close()
+	waitFor(t, func() bool { return hub.Stats().Clients == 0 })
+	stats := hub.Stats()
+	assert.Empty(t, stats.Rooms)
+	assert.Equal(t, int64(1), stats.TotalConnects)
+}
+
+func TestHubs_Registry(t *testing.T) {
+	hub, _ := newHubServer(t, "test_registry", websocket.Options{})
+
+	var names []string
+	for _, h := range websocket.Hubs() {
+		names = append(names, h.Name())
+	}
+	assert.Contains(t, names, "test_registry")
+
+	hub.Close()
+	for _, h := range websocket.Hubs() {
+		assert.NotEqual(t, "test_registry", h.Name())
+	}
+}